@@ -28,6 +28,7 @@ func accountLinkCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig
 	flags = append(flags, commonFlags...)
 	flags = append(flags, dryRunFlag())
 	flags = append(flags, newBoolFlag(PROMOTE_FLAG, "p", "Promote the change from stage to prod", false))
+	flags = append(flags, promoteConfirmFlag())
 
 	return &cli.Command{
 		Name:      "link",
@@ -54,6 +55,7 @@ func accountLinkCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig
 			values.Add("appPathGlob", cCtx.Args().Get(2))
 			values.Add(DRY_RUN_ARG, strconv.FormatBool(cCtx.Bool(DRY_RUN_FLAG)))
 			values.Add(PROMOTE_ARG, strconv.FormatBool(cCtx.Bool(PROMOTE_FLAG)))
+			values.Add(CONFIRM_ARG, strconv.FormatBool(cCtx.Bool(CONFIRM_FLAG)))
 
 			var linkResponse types.AppLinkAccountResponse
 			err := client.Post("/_openrun/link_account", values, nil, &linkResponse)
@@ -150,6 +152,7 @@ func updateParamsCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfi
 	flags = append(flags, commonFlags...)
 	flags = append(flags, dryRunFlag())
 	flags = append(flags, newBoolFlag(PROMOTE_FLAG, "p", "Promote the change from stage to prod", false))
+	flags = append(flags, promoteConfirmFlag())
 
 	return &cli.Command{
 		Name:      "update",
@@ -177,6 +180,7 @@ func updateParamsCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfi
 			values.Add("appPathGlob", cCtx.Args().Get(2))
 			values.Add(DRY_RUN_ARG, strconv.FormatBool(cCtx.Bool(DRY_RUN_FLAG)))
 			values.Add(PROMOTE_ARG, strconv.FormatBool(cCtx.Bool(PROMOTE_FLAG)))
+			values.Add(CONFIRM_ARG, strconv.FormatBool(cCtx.Bool(CONFIRM_FLAG)))
 
 			var updateResponse types.AppLinkAccountResponse
 			err := client.Post("/_openrun/update_param", values, nil, &updateResponse)
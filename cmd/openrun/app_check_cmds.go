@@ -0,0 +1,126 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+func appCheckCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+4)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newBoolFlag("a11y", "", "Check the pages for accessibility issues", false))
+	flags = append(flags, newBoolFlag("links", "", "Check the pages for broken internal links", false))
+	flags = append(flags, newIntFlag("max-pages", "m", "The maximum number of pages to crawl, default is 50", 0))
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table, basic, csv, json, jsonl and jsonl_pretty", ""))
+
+	return &cli.Command{
+		Name:      "check",
+		Usage:     "Check the pages of the stage app for broken links and accessibility issues",
+		Flags:     flags,
+		ArgsUsage: "<appPath>",
+		UsageText: `args: <appPath>
+
+    <app_path> is a required first argument. The optional domain and path are separated by a ":".
+    For a prod app, the checks run against its stage app. Dev and stage apps are checked directly.
+
+    The pages are crawled starting from the GET HTML routes of the app, following the links within
+    the app. Only GET requests are made, forms are not submitted. With --links, every internal link,
+    image, script and stylesheet referenced from the pages is requested and error responses are
+    reported. With --a11y, the pages are checked for basic WCAG issues like images without alt text,
+    form fields without labels, links and buttons without text, missing page title and lang, duplicate
+    ids and skipped heading levels. If neither option is specified, both checks are done. The command
+    fails if any issue is found, for use as a quality gate.
+
+	Examples:
+		openrun app check /myapp
+		openrun app check --a11y --max-pages 200 /myapp
+		openrun app check --links --format json example.com:/myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPath>")
+			}
+
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+			values.Add("a11y", strconv.FormatBool(cCtx.Bool("a11y")))
+			values.Add("links", strconv.FormatBool(cCtx.Bool("links")))
+			values.Add("maxPages", strconv.Itoa(cCtx.Int("max-pages")))
+
+			client := newHttpClient(clientConfig)
+			var response types.AppCheckResponse
+			if err := client.Post("/_openrun/app_check", values, nil, &response); err != nil {
+				return err
+			}
+
+			printAppCheckResults(cCtx, &response, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			truncated := ""
+			if response.Truncated {
+				truncated = " (limit reached, not all pages were checked)"
+			}
+			printStdout(cCtx, "App %s: %d pages, %d links checked, %d broken links, %d accessibility issues%s\n", response.AppPath,
+				len(response.Pages), response.LinksChecked, len(response.BrokenLinks), len(response.Issues), truncated)
+			if failed := len(response.BrokenLinks) + len(response.Issues); failed > 0 {
+				return cli.Exit(fmt.Sprintf("%d issue(s) found", failed), 1)
+			}
+			return nil
+		},
+	}
+}
+
+func printAppCheckResults(cCtx *cli.Context, response *types.AppCheckResponse, format string) {
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		enc.Encode(response) //nolint:errcheck
+	case FORMAT_JSONL:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		for _, l := range response.BrokenLinks {
+			enc.Encode(l) //nolint:errcheck
+		}
+		for _, i := range response.Issues {
+			enc.Encode(i) //nolint:errcheck
+		}
+	case FORMAT_JSONL_PRETTY:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		for _, l := range response.BrokenLinks {
+			enc.Encode(l) //nolint:errcheck
+			printStdout(cCtx, "\n")
+		}
+		for _, i := range response.Issues {
+			enc.Encode(i) //nolint:errcheck
+			printStdout(cCtx, "\n")
+		}
+	case FORMAT_BASIC:
+		fallthrough
+	case FORMAT_TABLE:
+		for _, l := range response.BrokenLinks {
+			printStdout(cCtx, "%s %-30s %-40s %3d %s\n", RED+"LINK"+RESET, cmp.Or(l.Page, "(route)"), l.Link, l.Status, l.Error)
+		}
+		for _, i := range response.Issues {
+			printStdout(cCtx, "%s %-30s %-15s %-6s %s\n", RED+"A11Y"+RESET, i.Page, i.Rule, i.WCAG, i.Message)
+			printStdout(cCtx, "       %s\n", i.Element)
+		}
+	case FORMAT_CSV:
+		for _, l := range response.BrokenLinks {
+			printStdout(cCtx, "link,%s,%s,%d,,\"%s\"\n", l.Page, l.Link, l.Status, strings.ReplaceAll(l.Error, "\"", "\"\""))
+		}
+		for _, i := range response.Issues {
+			printStdout(cCtx, "a11y,%s,%s,%s,\"%s\",\"%s\"\n", i.Page, i.Rule, i.WCAG,
+				strings.ReplaceAll(i.Element, "\"", "\"\""), strings.ReplaceAll(i.Message, "\"", "\"\""))
+		}
+	default:
+		panic(fmt.Errorf("unknown format %s", format))
+	}
+}
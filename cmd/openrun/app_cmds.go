@@ -9,8 +9,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"reflect"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/openrundev/openrun/internal/types"
 	"github.com/urfave/cli/v2"
@@ -28,6 +31,8 @@ To prevent shell expansion for *, placing the path in quotes is recommended.
 `
 	PROMOTE_FLAG = "promote"
 	PROMOTE_ARG  = "promote"
+	CONFIRM_FLAG = "yes"
+	CONFIRM_ARG  = "yes"
 )
 
 func initAppCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
@@ -42,7 +47,26 @@ func initAppCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *c
 			appReloadCommand(commonFlags, clientConfig),
 			appPromoteCommand(commonFlags, clientConfig),
 			appUpdateSettingsCommand(commonFlags, clientConfig),
+			appPatchSettingsCommand(commonFlags, clientConfig),
 			appUpdateMetadataCommand(commonFlags, clientConfig),
+			appJobsCommand(commonFlags, clientConfig),
+			appCronsCommand(commonFlags, clientConfig),
+			appConfigCommand(commonFlags, clientConfig),
+			appLogsCommand(commonFlags, clientConfig),
+			appExecCommand(commonFlags, clientConfig),
+			appStatsCommand(commonFlags, clientConfig),
+			appE2ECommand(commonFlags, clientConfig),
+			appTestCommand(commonFlags, clientConfig),
+			appGoldenCommand(commonFlags, clientConfig),
+			appContractCommand(commonFlags, clientConfig),
+			appReplCommand(commonFlags, clientConfig),
+			appCheckCommand(commonFlags, clientConfig),
+			appTransferCommand(commonFlags, clientConfig),
+			appDeprecateCommand(commonFlags, clientConfig),
+			appPauseCommand(commonFlags, clientConfig),
+			appArchiveCommand(commonFlags, clientConfig),
+			appLocksCommand(commonFlags, clientConfig),
+			appUnlockCommand(commonFlags, clientConfig),
 		},
 	}
 }
@@ -51,6 +75,10 @@ func dryRunFlag() *cli.BoolFlag {
 	return newBoolFlag(DRY_RUN_FLAG, "", "Verify command but don't commit any changes", false)
 }
 
+func promoteConfirmFlag() *cli.BoolFlag {
+	return newBoolFlag(CONFIRM_FLAG, "y", "Confirm the promote when it changes the app permissions or container settings", false)
+}
+
 func appCreateCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
 	flags := make([]cli.Flag, 0, len(commonFlags)+2)
 	flags = append(flags, commonFlags...)
@@ -59,8 +87,13 @@ func appCreateCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig)
 	flags = append(flags, newStringFlag("auth", "", "The authentication mode for the app: can be default or none or system or an OAuth account config", "default"))
 	flags = append(flags, newStringFlag("branch", "b", "The branch to checkout if using git source", "main"))
 	flags = append(flags, newStringFlag("commit", "c", "The commit SHA to checkout if using git source. This takes precedence over branch", ""))
+	flags = append(flags, newStringFlag("tag", "", "The git tag or semver constraint, like v1.x, to checkout instead of a branch. The latest matching tag is used on reload", ""))
 	flags = append(flags, newStringFlag("git-auth", "g", "The name of the git_auth entry in server config to use", ""))
+	flags = append(flags, newStringFlag("checksum", "", "The sha256 checksum to verify if using an archive source url", ""))
 	flags = append(flags, newStringFlag("spec", "", "The spec to use for the app", ""))
+	flags = append(flags, newStringFlag("image", "", "Create the app from a prebuilt image, with no source checkout or build. The image spec is used", ""))
+	flags = append(flags, newBoolFlag("pin-digest", "", "Pin the image to its current digest when the app is created", false))
+	flags = append(flags, newBoolFlag("interactive", "i", "Prompt for the app param values, with a summary shown before the app is created", false))
 	flags = append(flags, newStringFlag("stage-at", "", `Where to create the staging app: "domain", "path", or a staging domain. Defaults to system stage_at ("domain" by default)`, ""))
 	flags = append(flags,
 		&cli.StringSliceFlag{
@@ -121,17 +154,37 @@ func appCreateCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig)
 
 <app_path> is a required second argument. The optional domain and path are separated by a ":". If no domain is specified, the app is created for the default domain.
 
+When --image is specified, the source url is optional. The app runs the prebuilt image, the params are passed to the container as env values.
+
 Examples:
   Create app from github source: openrun app create --approve github.com/openrundev/openrun/examples/memory_usage/ /memory_usage
   Create app from local disk: openrun app create --approve $HOME/openrun_source/openrun/examples/memory_usage/ /memory_usage
   Create app for development (source has to be disk): openrun app create --approve --dev $HOME/openrun_source/openrun/examples/memory_usage/ /memory_usage
   Create app from a git commit: openrun app create --approve --commit 1234567890  github.com/openrundev/openrun/examples/memory_usage/ /memory_usage
   Create app from a git branch: openrun app create --approve --branch main github.com/openrundev/openrun/examples/memory_usage/ /memory_usage
+  Create app from the latest v1 release tag: openrun app create --approve --tag v1.x github.com/openrundev/openrun/examples/memory_usage/ /memory_usage
+  Create app from a release archive, verifying its checksum: openrun app create --approve --checksum sha256:4a5f... https://example.com/releases/myapp-1.2.tar.gz /myapp
+  Create app from an OCI artifact: openrun app create --approve oci://ghcr.io/example/myapp-source:1.2 /myapp
   Create app using git url: openrun app create --approve git@github.com:openrundev/openrun.git/examples/disk_usage /disk_usage
   Create app using git url, with git private key auth: openrun app create --approve --git-auth mykey git@github.com:openrundev/privaterepo.git/examples/disk_usage /disk_usage
-  Create app for specified domain, no auth : openrun app create --approve --auth=none github.com/openrundev/openrun/examples/memory_usage/ openrun.example.com:/`,
+  Create app for specified domain, no auth : openrun app create --approve --auth=none github.com/openrundev/openrun/examples/memory_usage/ openrun.example.com:/
+  Create app, prompting for the param values: openrun app create --approve --interactive --spec python-flask ./myapp /myapp
+  Create app from a prebuilt image, pinned to the current digest: openrun app create --approve --image ghcr.io/org/tool:1.4 --pin-digest --param port=8080 /tool
+  Create app from a prebuilt image source url: openrun app create --approve --param port=8080 image://ghcr.io/org/tool:1.4 /tool`,
 		Action: func(cCtx *cli.Context) error {
-			if cCtx.NArg() != 2 {
+			image := cCtx.String("image")
+			if image == "" && cCtx.Bool("pin-digest") {
+				return fmt.Errorf("--pin-digest requires --image")
+			}
+			args := cCtx.Args().Slice()
+			if image != "" && len(args) == 1 {
+				// No source required for an image app
+				args = []string{types.NO_SOURCE, args[0]}
+			}
+			if len(args) != 2 {
+				if image != "" {
+					return fmt.Errorf("require one argument with --image: <app_path>")
+				}
 				return fmt.Errorf("require two arguments: <app_source_url> <app_path>")
 			}
 
@@ -191,19 +244,20 @@ Examples:
 				confMap[key] = "\"" + value + "\""
 			}
 
-			sourceUrl, err := makeAbsolute(cCtx.Args().Get(0))
+			sourceUrl, err := makeAbsolute(args[0])
 			if err != nil {
 				return err
 			}
 
 			body := types.CreateAppRequest{
-				Path:             cCtx.Args().Get(1),
+				Path:             args[1],
 				SourceUrl:        sourceUrl,
 				IsDev:            cCtx.Bool("dev"),
 				AppAuthn:         types.AppAuthnType(cCtx.String("auth")),
 				GitBranch:        cCtx.String("branch"),
 				GitCommit:        cCtx.String("commit"),
 				GitAuthName:      cCtx.String("git-auth"),
+				SourceChecksum:   cCtx.String("checksum"),
 				Spec:             types.AppSpec(cCtx.String("spec")),
 				ParamValues:      paramValues,
 				ContainerOptions: coptMap,
@@ -212,9 +266,28 @@ Examples:
 				AppConfig:        confMap,
 				Bindings:         bindings,
 				StageAt:          cCtx.String("stage-at"),
+				Image:            image,
+				PinDigest:        cCtx.Bool("pin-digest"),
+			}
+			if tag := cCtx.String("tag"); tag != "" {
+				body.GitTag = tag
+				if !cCtx.IsSet("branch") {
+					body.GitBranch = "" // the default branch is not used with a tag
+				}
 			}
-			var createResult types.AppCreateResponse
 			client := newHttpClient(clientConfig)
+			if cCtx.Bool("interactive") {
+				confirmed, err := promptCreateParams(cCtx, client, values, &body)
+				if err != nil {
+					return err
+				}
+				if !confirmed {
+					fmt.Println("App not created")
+					return nil
+				}
+			}
+
+			var createResult types.AppCreateResponse
 			err = client.Post("/_openrun/app", values, body, &createResult)
 			if err != nil {
 				return err
@@ -241,6 +314,9 @@ func printCreateResult(cCtx *cli.Context, createResult types.AppCreateResponse)
 	if createResult.OrigSourceUrl != "" {
 		fmt.Printf("   Source: %s (created from %s)\n", createResult.SourceUrl, createResult.OrigSourceUrl)
 	}
+	if createResult.Image != "" {
+		fmt.Printf("    Image: %s\n", createResult.Image)
+	}
 	approveResult := createResult.ApproveResults[0]
 	printApproveResult(approveResult)
 
@@ -256,10 +332,14 @@ func printCreateResult(cCtx *cli.Context, createResult types.AppCreateResponse)
 }
 
 func appListCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
-	flags := make([]cli.Flag, 0, len(commonFlags)+2)
+	flags := make([]cli.Flag, 0, len(commonFlags)+6)
 	flags = append(flags, commonFlags...)
 	flags = append(flags, newBoolFlag("internal", "i", "Include internal apps", false))
+	flags = append(flags, newBoolFlag("detail", "d", "Include the time taken by the last load of the apps loaded on the server", false))
 	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table, basic, csv, json, jsonl and jsonl_pretty", ""))
+	flags = append(flags, newStringFlag("search", "", "Search the apps, the matching apps are listed in the order of relevance", ""))
+	flags = append(flags, newIntFlag("limit", "", "The maximum number of apps to list for a search, default is 50", 0))
+	flags = append(flags, newIntFlag("offset", "", "The number of matching apps to skip for a search", 0))
 
 	return &cli.Command{
 		Name:      "list",
@@ -278,29 +358,90 @@ Examples:
   List all apps with no domain specified: openrun app list "**"
   List all apps with no domain, under the /utils folder: openrun app list "/utils/**"
   List all apps with no domain, including staging apps, under the /utils folder: openrun app list --internal "/utils/**"
-  List apps at the lop level with no domain specified, with jsonl format: openrun app list --format jsonl "*"`,
+  List apps at the lop level with no domain specified, with jsonl format: openrun app list --format jsonl "*"
+  Search apps for grafana owned by the infra team: openrun app list --search "grafana team:infra"
+  List apps with the app load time breakdown: openrun app list --detail "/utils/**"
+
+The search terms are matched against the app name, path, domain, tags, spec, source url and owner. All the
+terms have to match. A term can be limited to one field using name:, path:, domain:, tag:, spec:, source:,
+owner: or team: (the group owning the app) as prefix.
+
+With --detail, the time taken by the last load of the app is shown, split into the source load, the Starlark
+init, the template parse and the container start. The times are for the server node which handles the API
+call, apps not loaded on that node are listed without the timing.`,
 		Action: func(cCtx *cli.Context) error {
 			if cCtx.NArg() > 1 {
 				return fmt.Errorf("only one argument expected: <appPathGlob>")
 			}
 			values := url.Values{}
 			values.Add("internal", fmt.Sprintf("%t", cCtx.Bool("internal")))
+			values.Add("detail", fmt.Sprintf("%t", cCtx.Bool("detail")))
 			if cCtx.NArg() == 1 {
 				values.Add("appPathGlob", cCtx.Args().Get(0))
 			}
 
 			client := newHttpClient(clientConfig)
+			if cCtx.IsSet("search") {
+				values.Add("q", cCtx.String("search"))
+				values.Add("limit", strconv.Itoa(cCtx.Int("limit")))
+				values.Add("offset", strconv.Itoa(cCtx.Int("offset")))
+				var searchResponse types.AppSearchResponse
+				if err := client.Get("/_openrun/app_search", values, &searchResponse); err != nil {
+					return err
+				}
+				format := cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat)
+				printAppList(cCtx, searchResponse.Apps, format)
+				if cCtx.Bool("detail") {
+					printAppStartTimings(cCtx, searchResponse.Apps, format)
+				}
+				if searchResponse.Offset+len(searchResponse.Apps) < searchResponse.Total {
+					fmt.Fprintf(cCtx.App.ErrWriter, "Listed %d of %d matching apps, use --offset %d for more\n", //nolint:errcheck
+						len(searchResponse.Apps), searchResponse.Total, searchResponse.Offset+len(searchResponse.Apps))
+				}
+				return nil
+			}
+
 			var appListResponse types.AppListResponse
 			err := client.Get("/_openrun/apps", values, &appListResponse)
 			if err != nil {
 				return err
 			}
-			printAppList(cCtx, appListResponse.Apps, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			format := cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat)
+			printAppList(cCtx, appListResponse.Apps, format)
+			if cCtx.Bool("detail") {
+				printAppStartTimings(cCtx, appListResponse.Apps, format)
+			}
 			return nil
 		},
 	}
 }
 
+// printAppStartTimings prints the app load times for the table formats, the JSON formats include the
+// timing in the app entries
+func printAppStartTimings(cCtx *cli.Context, apps []types.AppResponse, format string) {
+	if format != FORMAT_TABLE && format != FORMAT_BASIC {
+		return
+	}
+	formatStr := "%-40s %-19s %-6s %10s %10s %10s %10s %10s\n"
+	printStdout(cCtx, "\nApp load times (ms)\n")
+	printStdout(cCtx, formatStr, "AppPath", "LoadTime", "Type", "Total", "Source", "Starlark", "Template", "Container")
+	msStr := func(ms float64) string { return strconv.FormatFloat(ms, 'f', 1, 64) }
+	for _, app := range apps {
+		timing := app.StartTiming
+		if timing == nil {
+			printStdout(cCtx, formatStr, app.AppPathDomain(), "not loaded", "", "", "", "", "", "")
+			continue
+		}
+		loadType := "cold"
+		if timing.Reload {
+			loadType = "reload"
+		}
+		printStdout(cCtx, formatStr, app.AppPathDomain(), timing.Time.Format("2006-01-02 15:04:05"), loadType,
+			msStr(timing.TotalMs), msStr(timing.SourceLoadMs), msStr(timing.StarlarkInitMs),
+			msStr(timing.TemplateParseMs), msStr(timing.ContainerStartMs))
+	}
+}
+
 func printAppList(cCtx *cli.Context, apps []types.AppResponse, format string) {
 	switch format {
 	case FORMAT_JSON:
@@ -399,12 +540,31 @@ func permType(perm types.Permission) string {
 }
 
 func printApproveResult(approveResult types.ApproveResult) {
+	// Entries not in the permission bundle declared by the app spec are highlighted
+	specMarker := func(undeclared bool) string {
+		if approveResult.Spec == "" || !undeclared {
+			return ""
+		}
+		return fmt.Sprintf(" %s<NOT DECLARED BY SPEC %s>%s", YELLOW, approveResult.Spec, RESET)
+	}
+
+	if approveResult.Spec != "" {
+		deviations := len(approveResult.UndeclaredLoads) + len(approveResult.UndeclaredPermissions)
+		if deviations == 0 {
+			fmt.Printf("  Spec %s: permissions match the declared bundle\n", approveResult.Spec)
+		} else {
+			fmt.Printf("  Spec %s: %d entries not in the declared bundle\n", approveResult.Spec, deviations)
+		}
+	}
 	fmt.Printf("  Plugins :\n")
 	for _, load := range approveResult.NewLoads {
-		fmt.Printf("    %s\n", load)
+		fmt.Printf("    %s%s\n", load, specMarker(slices.Contains(approveResult.UndeclaredLoads, load)))
 	}
 	fmt.Printf("  Permissions:\n")
 	for _, perm := range approveResult.NewPermissions {
+		undeclared := slices.ContainsFunc(approveResult.UndeclaredPermissions, func(p types.Permission) bool {
+			return reflect.DeepEqual(p, perm)
+		})
 		secrets := ""
 		if len(perm.Secrets) > 0 {
 			buf := new(bytes.Buffer)
@@ -421,7 +581,13 @@ func printApproveResult(approveResult types.ApproveResult) {
 		if len(perm.Permit) > 0 {
 			permit = fmt.Sprintf(" permit=%s", strings.Join(perm.Permit, ","))
 		}
-		fmt.Printf("    %s.%s %s %s%s%s\n", perm.Plugin, perm.Method, perm.Arguments, permType(perm), secrets, permit)
+		fmt.Printf("    %s.%s %s %s%s%s%s\n", perm.Plugin, perm.Method, perm.Arguments, permType(perm), secrets, permit, specMarker(undeclared))
+	}
+	if len(approveResult.NewEnv) > 0 {
+		fmt.Printf("  Env:\n")
+		for _, env := range approveResult.NewEnv {
+			fmt.Printf("    %s\n", env)
+		}
 	}
 }
 
@@ -478,6 +644,7 @@ func appApproveCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig)
 	flags = append(flags, commonFlags...)
 	flags = append(flags, dryRunFlag())
 	flags = append(flags, newBoolFlag(PROMOTE_FLAG, "p", "Promote the change from stage to prod", false))
+	flags = append(flags, promoteConfirmFlag())
 
 	return &cli.Command{
 		Name:      "approve",
@@ -504,6 +671,7 @@ func appApproveCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig)
 			values.Add("appPathGlob", cCtx.Args().Get(0))
 			values.Add(DRY_RUN_ARG, strconv.FormatBool(cCtx.Bool(DRY_RUN_FLAG)))
 			values.Add(PROMOTE_ARG, strconv.FormatBool(cCtx.Bool(PROMOTE_FLAG)))
+			values.Add(CONFIRM_ARG, strconv.FormatBool(cCtx.Bool(CONFIRM_FLAG)))
 
 			var approveResponse types.AppApproveResponse
 			err := client.Post("/_openrun/approve", values, nil, &approveResponse)
@@ -550,6 +718,7 @@ func appReloadCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig)
 	flags = append(flags, commonFlags...)
 	flags = append(flags, newBoolFlag("approve", "a", "Approve the app permissions", false))
 	flags = append(flags, newBoolFlag("promote", "p", "Promote the change from stage to prod", false))
+	flags = append(flags, promoteConfirmFlag())
 	flags = append(flags, newBoolFlag("verify", "", "Verify reload by reloading the app container", false))
 	flags = append(flags, newBoolFlag("force-reload", "f", "Force reload even if there is no new commit", false))
 	flags = append(flags, newStringFlag("branch", "b", "The branch to checkout if using git source", ""))
@@ -591,6 +760,7 @@ func appReloadCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig)
 			values.Add("appPathGlob", cCtx.Args().First())
 			values.Add("approve", strconv.FormatBool(cCtx.Bool("approve")))
 			values.Add("promote", strconv.FormatBool(cCtx.Bool("promote")))
+			values.Add(CONFIRM_ARG, strconv.FormatBool(cCtx.Bool(CONFIRM_FLAG)))
 			values.Add("verify", strconv.FormatBool(cCtx.Bool("verify")))
 			values.Add("forceReload", strconv.FormatBool(cCtx.Bool("force-reload")))
 			values.Add("branch", cCtx.String("branch"))
@@ -663,9 +833,11 @@ func appReloadCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig)
 }
 
 func appPromoteCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
-	flags := make([]cli.Flag, 0, len(commonFlags)+2)
+	flags := make([]cli.Flag, 0, len(commonFlags)+3)
 	flags = append(flags, commonFlags...)
 	flags = append(flags, dryRunFlag())
+	flags = append(flags, newBoolFlag("contract-check", "", "Run the contract.star checks against the stage apps, promote only if all the checks pass", false))
+	flags = append(flags, promoteConfirmFlag())
 
 	return &cli.Command{
 		Name:      "promote",
@@ -678,7 +850,10 @@ func appPromoteCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig)
 
 	Examples:
 	  Promote all apps, across domains: openrun app promote all
-	  Promote apps in the example.com domain: openrun app promote "example.com:**"`,
+	  Promote apps in the example.com domain: openrun app promote "example.com:**"
+	  Promote after verifying the proxied backend: openrun app promote --contract-check /myapp
+	  Review the changes to the prod app: openrun app promote --dry-run /myapp
+	  Promote with permission or container changes: openrun app promote --yes /myapp`,
 
 		Action: func(cCtx *cli.Context) error {
 			if cCtx.NArg() != 1 {
@@ -689,6 +864,8 @@ func appPromoteCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig)
 			values := url.Values{}
 			values.Add("appPathGlob", cCtx.Args().First())
 			values.Add(DRY_RUN_ARG, strconv.FormatBool(cCtx.Bool(DRY_RUN_FLAG)))
+			values.Add("contractCheck", strconv.FormatBool(cCtx.Bool("contract-check")))
+			values.Add(CONFIRM_ARG, strconv.FormatBool(cCtx.Bool(CONFIRM_FLAG)))
 
 			var promoteResponse types.AppPromoteResponse
 			err := client.Post("/_openrun/promote", values, nil, &promoteResponse)
@@ -696,6 +873,15 @@ func appPromoteCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig)
 				return err
 			}
 
+			for _, diff := range promoteResponse.Diffs {
+				if len(diff.Changes) == 0 {
+					continue
+				}
+				fmt.Printf("Changes for %s:\n", diff.AppPathDomain)
+				for _, change := range diff.Changes {
+					fmt.Printf("  %s\n", change)
+				}
+			}
 			for _, approveResult := range promoteResponse.PromoteResults {
 				fmt.Printf("Promoting %s\n", approveResult)
 			}
@@ -708,3 +894,185 @@ func appPromoteCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig)
 		},
 	}
 }
+
+func appJobsCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+3)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("status", "s", "Filter by job status: pending, running, succeeded, failed or cancelled", ""))
+	flags = append(flags, newIntFlag("limit", "l", "The maximum number of jobs to list, most recent first", 100))
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table, basic, csv, json, jsonl and jsonl_pretty", ""))
+
+	return &cli.Command{
+		Name:      "jobs",
+		Usage:     "List the background jobs submitted by an app",
+		Flags:     flags,
+		ArgsUsage: "<appPath>",
+		UsageText: `args: <appPath>
+
+    <app_path> is a required first argument. The optional domain and path are separated by a ":".
+
+	Examples:
+		openrun app jobs example.com:/myapp
+		openrun app jobs --status failed /myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPath>")
+			}
+
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+			values.Add("status", cCtx.String("status"))
+			values.Add("limit", strconv.Itoa(cCtx.Int("limit")))
+
+			client := newHttpClient(clientConfig)
+			var response types.JobListResponse
+			if err := client.Get("/_openrun/app_jobs", values, &response); err != nil {
+				return err
+			}
+			printJobList(cCtx, response.Jobs, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			return nil
+		},
+	}
+}
+
+func printJobList(cCtx *cli.Context, jobs []*types.JobEntry, format string) {
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		enc.Encode(jobs) //nolint:errcheck
+	case FORMAT_JSONL:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		for _, job := range jobs {
+			enc.Encode(job) //nolint:errcheck
+		}
+	case FORMAT_JSONL_PRETTY:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		for _, job := range jobs {
+			enc.Encode(job) //nolint:errcheck
+			printStdout(cCtx, "\n")
+		}
+	case FORMAT_BASIC:
+		formatStr := "%-32s %-25s %-10s %-8s %s\n"
+		printStdout(cCtx, formatStr, "Id", "Function", "Status", "Attempts", "Error")
+		for _, job := range jobs {
+			printStdout(cCtx, formatStr, job.Id, job.FuncName, job.Status, strconv.Itoa(job.Attempts), job.Error)
+		}
+	case FORMAT_TABLE:
+		formatStr := "%-32s %-25s %-10s %-8s %-20s %-20s %-20s %s\n"
+		printStdout(cCtx, formatStr, "Id", "Function", "Status", "Attempts", "User", "Created", "Ended", "Error")
+		for _, job := range jobs {
+			endTime := ""
+			if job.EndTime != nil {
+				endTime = job.EndTime.Local().Format(time.DateTime)
+			}
+			printStdout(cCtx, formatStr, job.Id, job.FuncName, job.Status, fmt.Sprintf("%d/%d", job.Attempts, job.MaxRetries+1),
+				job.UserId, job.CreateTime.Local().Format(time.DateTime), endTime, job.Error)
+		}
+	case FORMAT_CSV:
+		for _, job := range jobs {
+			printStdout(cCtx, "%s,%s,%s,%d,%d,%s,%s,\"%s\"\n", job.Id, job.FuncName, job.Status, job.Attempts, job.MaxRetries,
+				job.UserId, job.CreateTime.Format(time.RFC3339), job.Error)
+		}
+	default:
+		panic(fmt.Errorf("unknown format %s", format))
+	}
+}
+
+func appCronsCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+1)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table, basic, csv, json, jsonl and jsonl_pretty", ""))
+
+	return &cli.Command{
+		Name:      "crons",
+		Usage:     "List the scheduled tasks declared by an app, with the last run status",
+		Flags:     flags,
+		ArgsUsage: "<appPath>",
+		UsageText: `args: <appPath>
+
+    <app_path> is a required first argument. The optional domain and path are separated by a ":".
+    Scheduled tasks are run for prod apps only.
+
+	Examples:
+		openrun app crons example.com:/myapp
+		openrun app crons --format json /myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPath>")
+			}
+
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+
+			client := newHttpClient(clientConfig)
+			var response types.CronListResponse
+			if err := client.Get("/_openrun/app_crons", values, &response); err != nil {
+				return err
+			}
+			printCronList(cCtx, response.Crons, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			return nil
+		},
+	}
+}
+
+func printCronList(cCtx *cli.Context, crons []types.CronStatus, format string) {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Local().Format(time.DateTime)
+	}
+	lastStatus := func(c types.CronStatus) (string, string) {
+		if c.LastJob == nil {
+			return "", ""
+		}
+		return string(c.LastJob.Status), c.LastJob.Error
+	}
+
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		enc.Encode(crons) //nolint:errcheck
+	case FORMAT_JSONL:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		for _, c := range crons {
+			enc.Encode(c) //nolint:errcheck
+		}
+	case FORMAT_JSONL_PRETTY:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		for _, c := range crons {
+			enc.Encode(c) //nolint:errcheck
+			printStdout(cCtx, "\n")
+		}
+	case FORMAT_BASIC:
+		formatStr := "%-20s %-20s %-25s %s\n"
+		printStdout(cCtx, formatStr, "Name", "Schedule", "Handler", "Last Status")
+		for _, c := range crons {
+			status, _ := lastStatus(c)
+			printStdout(cCtx, formatStr, c.Name, c.Schedule, c.Handler, status)
+		}
+	case FORMAT_TABLE:
+		formatStr := "%-20s %-20s %-25s %-20s %-20s %-10s %-6s %s\n"
+		printStdout(cCtx, formatStr, "Name", "Schedule", "Handler", "Last Run", "Next Run", "Status", "Skips", "Error")
+		for _, c := range crons {
+			status, errMsg := lastStatus(c)
+			printStdout(cCtx, formatStr, c.Name, c.Schedule, c.Handler, formatTime(c.LastRunTime), formatTime(c.NextRunTime),
+				status, strconv.Itoa(c.SkipCount), errMsg)
+		}
+	case FORMAT_CSV:
+		for _, c := range crons {
+			status, errMsg := lastStatus(c)
+			lastRun := ""
+			if c.LastRunTime != nil {
+				lastRun = c.LastRunTime.Format(time.RFC3339)
+			}
+			printStdout(cCtx, "%s,\"%s\",%s,%s,%s,%d,\"%s\"\n", c.Name, c.Schedule, c.Handler, lastRun, status, c.SkipCount, errMsg)
+		}
+	default:
+		panic(fmt.Errorf("unknown format %s", format))
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+func appConfigCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	return &cli.Command{
+		Name:  "config",
+		Usage: "View the app config, layered from the server app_config, the tag_config for the app tags and the per-app config",
+		Subcommands: []*cli.Command{
+			appConfigShowCommand(commonFlags, clientConfig),
+		},
+	}
+}
+
+func appConfigShowCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+2)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newBoolFlag("effective", "e", "Show the effective config with all the layers merged, with the source of each value", false))
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table, basic, csv, json, jsonl and jsonl_pretty", ""))
+
+	return &cli.Command{
+		Name:      "show",
+		Usage:     "Show the per-app config entries, or the effective app config",
+		Flags:     flags,
+		ArgsUsage: "<appPath>",
+		UsageText: `args: <appPath>
+
+    <app_path> is a required first argument. The optional domain and path are separated by a ":".
+    The per-app config entries set with "app update conf" are shown by default. With --effective,
+    the resolved config is shown: the server app_config, overridden by the tag_config entries for
+    the app tags, overridden by the per-app config. The source column has the layer for each value.
+
+	Examples:
+		openrun app config show /myapp
+		openrun app config show --effective example.com:/myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPath>")
+			}
+
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+			values.Add("effective", strconv.FormatBool(cCtx.Bool("effective")))
+
+			client := newHttpClient(clientConfig)
+			var response types.AppConfigResponse
+			if err := client.Get("/_openrun/app_config", values, &response); err != nil {
+				return err
+			}
+			printAppConfig(cCtx, response.Values, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			return nil
+		},
+	}
+}
+
+func printAppConfig(cCtx *cli.Context, configValues []types.AppConfigValue, format string) {
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		enc.Encode(configValues) //nolint:errcheck
+	case FORMAT_JSONL:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		for _, v := range configValues {
+			enc.Encode(v) //nolint:errcheck
+		}
+	case FORMAT_JSONL_PRETTY:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		for _, v := range configValues {
+			enc.Encode(v) //nolint:errcheck
+			printStdout(cCtx, "\n")
+		}
+	case FORMAT_BASIC:
+		formatStr := "%-45s %s\n"
+		printStdout(cCtx, formatStr, "Key", "Value")
+		for _, v := range configValues {
+			printStdout(cCtx, formatStr, v.Key, v.Value)
+		}
+	case FORMAT_TABLE:
+		formatStr := "%-45s %-40s %s\n"
+		printStdout(cCtx, formatStr, "Key", "Value", "Source")
+		for _, v := range configValues {
+			printStdout(cCtx, formatStr, v.Key, v.Value, v.Source)
+		}
+	case FORMAT_CSV:
+		for _, v := range configValues {
+			printStdout(cCtx, "%s,\"%s\",%s\n", v.Key, strings.ReplaceAll(v.Value, `"`, `""`), v.Source)
+		}
+	default:
+		panic(fmt.Errorf("unknown format %s", format))
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+func appContractCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+1)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table, basic, csv, json, jsonl and jsonl_pretty", ""))
+
+	return &cli.Command{
+		Name:      "contract",
+		Usage:     "Run the contract checks against the proxied backend of the stage app",
+		Flags:     flags,
+		ArgsUsage: "<appPath>",
+		UsageText: `args: <appPath>
+
+    <app_path> is a required first argument. The optional domain and path are separated by a ":".
+    For a prod app, the checks run against its stage app. Dev and stage apps are checked directly.
+
+    The checks are declared in the contract.star file in the app source, for proxy and container apps.
+    Each check(path, name=, method=, headers=, body=, status=200, expect_headers=, contains=, not_contains=)
+    makes a request through the app proxy route and verifies the status, the response headers (the value
+    should contain the expected string) and the body fragments. The command fails if any check fails.
+    Use "openrun app promote --contract-check" to run the checks before promoting.
+
+	Examples:
+		openrun app contract /myapp
+		openrun app contract --format json example.com:/myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPath>")
+			}
+
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+
+			client := newHttpClient(clientConfig)
+			var response types.ContractResponse
+			if err := client.Post("/_openrun/app_contract", values, nil, &response); err != nil {
+				return err
+			}
+
+			printContractResults(cCtx, response.Results, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			printStdout(cCtx, "App %s: %d passed, %d failed\n", response.AppPath, response.Passed, response.Failed)
+			if response.Failed > 0 {
+				return cli.Exit(fmt.Sprintf("%d check(s) failed", response.Failed), 1)
+			}
+			return nil
+		},
+	}
+}
+
+func printContractResults(cCtx *cli.Context, results []types.ContractCheckResult, format string) {
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		enc.Encode(results) //nolint:errcheck
+	case FORMAT_JSONL:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		for _, r := range results {
+			enc.Encode(r) //nolint:errcheck
+		}
+	case FORMAT_JSONL_PRETTY:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		for _, r := range results {
+			enc.Encode(r) //nolint:errcheck
+			printStdout(cCtx, "\n")
+		}
+	case FORMAT_BASIC:
+		fallthrough
+	case FORMAT_TABLE:
+		for _, r := range results {
+			status := GREEN + "PASS" + RESET
+			if !r.Passed {
+				status = RED + "FAIL" + RESET
+			}
+			printStdout(cCtx, "%s %-30s %-6s %-40s %3d %6dms\n", status, r.Name, r.Method, r.Path, r.Status, r.DurationMs)
+			for _, e := range r.Errors {
+				printStdout(cCtx, "       %s\n", e)
+			}
+		}
+	case FORMAT_CSV:
+		for _, r := range results {
+			printStdout(cCtx, "%s,%s,%s,%t,%d,%d,\"%s\"\n", r.Name, r.Method, r.Path, r.Passed, r.Status, r.DurationMs,
+				strings.ReplaceAll(strings.Join(r.Errors, "; "), "\"", "\"\""))
+		}
+	default:
+		panic(fmt.Errorf("unknown format %s", format))
+	}
+}
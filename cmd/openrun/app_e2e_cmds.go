@@ -0,0 +1,184 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+const snapshotExt = ".html"
+
+func appE2ECommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+5)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("spec", "s", "The test script to run", "tests/e2e.star"))
+	flags = append(flags, newStringFlag("snapshots", "", "The directory with the snapshot files, default is the snapshots directory next to the test script", ""))
+	flags = append(flags, newBoolFlag("update-snapshots", "u", "Write new and mismatched snapshots to the snapshot directory", false))
+	flags = append(flags, newStringFlag("filter", "", "Run only the tests whose name matches the glob pattern", ""))
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table, basic, csv, json, jsonl and jsonl_pretty", ""))
+
+	return &cli.Command{
+		Name:      "e2e",
+		Usage:     "Run end-to-end tests against the stage app",
+		Flags:     flags,
+		ArgsUsage: "<appPath>",
+		UsageText: `args: <appPath>
+
+    <app_path> is a required first argument. The optional domain and path are separated by a ":".
+    For a prod app, the tests run against its stage app. Dev and stage apps are tested directly.
+
+    Each top level function named test_* in the script is a test. It is called with a test context t which has
+    get/post/put/delete/request functions to call the app (with a cookie jar per test) and assert_status,
+    assert_contains, assert_not_contains, assert_equals, assert_true, fail and snapshot for checks.
+    Snapshots are read from and written to <snapshots>/<name>.html. The command fails if any test fails.
+
+	Examples:
+		openrun app e2e --spec tests/e2e.star /myapp
+		openrun app e2e --update-snapshots --filter "test_home*" example.com:/myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPath>")
+			}
+
+			specFile := cCtx.String("spec")
+			script, err := os.ReadFile(specFile)
+			if err != nil {
+				return fmt.Errorf("error reading test script: %w", err)
+			}
+			snapshotDir := cmp.Or(cCtx.String("snapshots"), filepath.Join(filepath.Dir(specFile), "snapshots"))
+			snapshots, err := readSnapshots(snapshotDir)
+			if err != nil {
+				return err
+			}
+
+			body := types.E2ERequest{
+				ScriptName: filepath.Base(specFile),
+				Script:     string(script),
+				Snapshots:  snapshots,
+				Filter:     cCtx.String("filter"),
+				Update:     cCtx.Bool("update-snapshots"),
+			}
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+
+			client := newHttpClient(clientConfig)
+			var response types.E2EResponse
+			if err := client.Post("/_openrun/app_e2e", values, body, &response); err != nil {
+				return err
+			}
+
+			printE2EResults(cCtx, response.Results, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			if cCtx.Bool("update-snapshots") {
+				if err := writeSnapshots(cCtx, snapshotDir, response.Results); err != nil {
+					return err
+				}
+			}
+
+			printStdout(cCtx, "App %s: %d passed, %d failed\n", response.AppPath, response.Passed, response.Failed)
+			if response.Failed > 0 {
+				return cli.Exit(fmt.Sprintf("%d test(s) failed", response.Failed), 1)
+			}
+			return nil
+		},
+	}
+}
+
+// readSnapshots reads the snapshot files, a missing directory means there are no snapshots yet
+func readSnapshots(dir string) (map[string]string, error) {
+	snapshots := map[string]string{}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return snapshots, nil
+		}
+		return nil, fmt.Errorf("error reading snapshot directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), snapshotExt) {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		snapshots[strings.TrimSuffix(entry.Name(), snapshotExt)] = string(data)
+	}
+	return snapshots, nil
+}
+
+func writeSnapshots(cCtx *cli.Context, dir string, results []types.E2ETestResult) error {
+	for _, result := range results {
+		for _, snapshot := range result.Snapshots {
+			if snapshot.Status == types.E2ESnapshotMatch {
+				continue
+			}
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+			fileName := filepath.Join(dir, snapshot.Name+snapshotExt)
+			if err := os.WriteFile(fileName, []byte(snapshot.Actual), 0o644); err != nil {
+				return err
+			}
+			printStdout(cCtx, "Updated snapshot %s\n", fileName)
+		}
+	}
+	return nil
+}
+
+func printE2EResults(cCtx *cli.Context, results []types.E2ETestResult, format string) {
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		enc.Encode(results) //nolint:errcheck
+	case FORMAT_JSONL:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		for _, r := range results {
+			enc.Encode(r) //nolint:errcheck
+		}
+	case FORMAT_JSONL_PRETTY:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		for _, r := range results {
+			enc.Encode(r) //nolint:errcheck
+			printStdout(cCtx, "\n")
+		}
+	case FORMAT_BASIC:
+		fallthrough
+	case FORMAT_TABLE:
+		for _, r := range results {
+			status := GREEN + "PASS" + RESET
+			if !r.Passed {
+				status = RED + "FAIL" + RESET
+			}
+			printStdout(cCtx, "%s %-40s %4d requests %4d assertions %6dms\n", status, r.Name, r.Requests, r.Assertions, r.DurationMs)
+			for _, snapshot := range r.Snapshots {
+				if snapshot.Status != types.E2ESnapshotMatch {
+					printStdout(cCtx, "       snapshot %s: %s\n", snapshot.Name, snapshot.Status)
+				}
+			}
+			if r.Error != "" {
+				printStdout(cCtx, "       %s\n", strings.ReplaceAll(r.Error, "\n", "\n       "))
+			}
+		}
+	case FORMAT_CSV:
+		for _, r := range results {
+			printStdout(cCtx, "%s,%t,%d,%d,%d,\"%s\"\n", r.Name, r.Passed, r.Requests, r.Assertions, r.DurationMs,
+				strings.ReplaceAll(r.Error, "\"", "\"\""))
+		}
+	default:
+		panic(fmt.Errorf("unknown format %s", format))
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+func appExecCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+2)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newBoolFlag("interactive", "i", "Pass the stdin to the command", false))
+	flags = append(flags, newStringFlag("service", "", "Run the command in the named service container", ""))
+
+	return &cli.Command{
+		Name:      "exec",
+		Usage:     "Run a command in the app container",
+		Flags:     flags,
+		ArgsUsage: "<appPath> -- <command> [args...]",
+		UsageText: `args: <appPath> -- <command> [args...]
+
+    <app_path> is a required first argument. The optional domain and path are separated by a ":".
+    The command is run in the running app container, or in the named service container with --service.
+    The command output is streamed back and the exit code of the command is returned. With --interactive,
+    the stdin is passed to the command. A terminal (TTY) is not allocated for the command. Running a
+    command requires the app:exec permission on the app, the command is recorded in the audit log.
+
+	Examples:
+		openrun app exec /myapp -- ls -l /app
+		openrun app exec --service postgres /myapp -- psql -c "select 1"
+		cat data.sql | openrun app exec -i --service postgres /myapp -- psql`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() < 2 {
+				return fmt.Errorf("requires arguments: <appPath> -- <command> [args...]")
+			}
+
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+			values.Add("service", cCtx.String("service"))
+			values.Add("stdin", strconv.FormatBool(cCtx.Bool("interactive")))
+			for _, arg := range cCtx.Args().Tail() {
+				values.Add("cmd", arg)
+			}
+
+			client := newHttpClient(clientConfig)
+			conn, err := client.Upgrade("/_openrun/app_exec", values, system.EXEC_PROTOCOL)
+			if err != nil {
+				return err
+			}
+			defer conn.Close() //nolint:errcheck
+
+			if cCtx.Bool("interactive") {
+				go sendExecStdin(cCtx.App.Reader, conn)
+			}
+
+			for {
+				stream, data, err := system.ReadExecFrame(conn)
+				if err != nil {
+					return fmt.Errorf("error reading command output: %w", err)
+				}
+				switch stream {
+				case system.ExecStreamStdout:
+					cCtx.App.Writer.Write(data) //nolint:errcheck
+				case system.ExecStreamStderr:
+					cCtx.App.ErrWriter.Write(data) //nolint:errcheck
+				case system.ExecStreamExit:
+					var result types.AppExecResult
+					if err := json.Unmarshal(data, &result); err != nil {
+						return fmt.Errorf("error parsing command result: %w", err)
+					}
+					if result.Error != "" {
+						return fmt.Errorf("%s", result.Error)
+					}
+					if result.ExitCode != 0 {
+						return cli.Exit("", result.ExitCode)
+					}
+					return nil
+				}
+			}
+		},
+	}
+}
+
+// sendExecStdin sends the input as stdin frames, followed by an empty frame which closes the
+// command stdin
+func sendExecStdin(in io.Reader, conn io.Writer) {
+	writer := system.NewExecFrameWriter(&sync.Mutex{}, conn, system.ExecStreamStdin)
+	if _, err := io.Copy(writer, in); err != nil {
+		return
+	}
+	_ = system.WriteExecFrame(conn, system.ExecStreamStdin, nil)
+}
@@ -0,0 +1,171 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+func appGoldenCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+5)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("fixtures", "", "The JSON file with the list of fixtures to render", "tests/golden/fixtures.json"))
+	flags = append(flags, newStringFlag("dir", "", "The directory with the golden files, default is the directory of the fixtures file", ""))
+	flags = append(flags, newBoolFlag("update-golden", "u", "Write new and mismatched golden files to the golden directory", false))
+	flags = append(flags,
+		&cli.StringSliceFlag{
+			Name:  "ignore",
+			Usage: "Regex for the parts of the output to mask before comparing, like CSRF tokens",
+		})
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table, basic, csv, json, jsonl and jsonl_pretty", ""))
+
+	return &cli.Command{
+		Name:      "golden",
+		Usage:     "Render the app HTML routes with fixture data and compare with golden files",
+		Flags:     flags,
+		ArgsUsage: "<appPath>",
+		UsageText: `args: <appPath>
+
+    <app_path> is a required first argument. The optional domain and path are separated by a ":".
+    For a prod app, the stage app is rendered. Dev and stage apps are rendered directly.
+
+    The fixtures file is a JSON list of fixtures, each with a name, the route path (as declared in the app),
+    optional method, url_params, query, user_id and partial, and the data passed to the template in place
+    of the handler response. Handlers are not called. The rendered HTML is compared with <dir>/<name>.html,
+    mismatches are shown as a line diff. Routes without a fixture are listed as uncovered.
+    The command fails if any fixture does not match.
+
+	Examples:
+		openrun app golden /myapp
+		openrun app golden --update-golden --fixtures tests/golden/fixtures.json example.com:/myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPath>")
+			}
+
+			fixturesFile := cCtx.String("fixtures")
+			data, err := os.ReadFile(fixturesFile)
+			if err != nil {
+				return fmt.Errorf("error reading fixtures file: %w", err)
+			}
+			var fixtures []types.GoldenFixture
+			if err := json.Unmarshal(data, &fixtures); err != nil {
+				return fmt.Errorf("error parsing fixtures file %s: %w", fixturesFile, err)
+			}
+			goldenDir := cmp.Or(cCtx.String("dir"), filepath.Dir(fixturesFile))
+			golden, err := readSnapshots(goldenDir)
+			if err != nil {
+				return err
+			}
+
+			body := types.GoldenRequest{
+				Fixtures: fixtures,
+				Golden:   golden,
+				Ignore:   cCtx.StringSlice("ignore"),
+				Update:   cCtx.Bool("update-golden"),
+			}
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+
+			client := newHttpClient(clientConfig)
+			var response types.GoldenResponse
+			if err := client.Post("/_openrun/app_golden", values, body, &response); err != nil {
+				return err
+			}
+
+			printGoldenResults(cCtx, response.Results, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			if cCtx.Bool("update-golden") {
+				if err := writeGoldenFiles(cCtx, goldenDir, response.Results); err != nil {
+					return err
+				}
+			}
+
+			if len(response.Uncovered) > 0 {
+				printStdout(cCtx, "Routes without a fixture: %s\n", strings.Join(response.Uncovered, ", "))
+			}
+			printStdout(cCtx, "App %s: %d passed, %d failed\n", response.AppPath, response.Passed, response.Failed)
+			if response.Failed > 0 {
+				return cli.Exit(fmt.Sprintf("%d fixture(s) failed", response.Failed), 1)
+			}
+			return nil
+		},
+	}
+}
+
+func writeGoldenFiles(cCtx *cli.Context, dir string, results []types.GoldenResult) error {
+	for _, result := range results {
+		if result.Status != types.GoldenNew && result.Status != types.GoldenMismatch {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return err
+		}
+		fileName := filepath.Join(dir, result.Name+snapshotExt)
+		if err := os.WriteFile(fileName, []byte(result.Actual), 0o644); err != nil {
+			return err
+		}
+		printStdout(cCtx, "Updated golden file %s\n", fileName)
+	}
+	return nil
+}
+
+func printGoldenResults(cCtx *cli.Context, results []types.GoldenResult, format string) {
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		enc.Encode(results) //nolint:errcheck
+	case FORMAT_JSONL:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		for _, r := range results {
+			enc.Encode(r) //nolint:errcheck
+		}
+	case FORMAT_JSONL_PRETTY:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		for _, r := range results {
+			enc.Encode(r) //nolint:errcheck
+			printStdout(cCtx, "\n")
+		}
+	case FORMAT_BASIC:
+		fallthrough
+	case FORMAT_TABLE:
+		for _, r := range results {
+			status := GREEN + "PASS" + RESET
+			if r.Status != types.GoldenMatch {
+				status = RED + strings.ToUpper(string(r.Status)) + RESET
+			}
+			printStdout(cCtx, "%-8s %-30s %s\n", status, r.Name, r.Route)
+			if r.Error != "" {
+				printStdout(cCtx, "       %s\n", strings.ReplaceAll(r.Error, "\n", "\n       "))
+			}
+			for _, line := range strings.Split(strings.TrimSuffix(r.Diff, "\n"), "\n") {
+				switch {
+				case line == "":
+				case strings.HasPrefix(line, "-"):
+					printStdout(cCtx, "       %s%s%s\n", RED, line, RESET)
+				case strings.HasPrefix(line, "+"):
+					printStdout(cCtx, "       %s%s%s\n", GREEN, line, RESET)
+				default:
+					printStdout(cCtx, "       %s\n", line)
+				}
+			}
+		}
+	case FORMAT_CSV:
+		for _, r := range results {
+			printStdout(cCtx, "%s,%s,%s,\"%s\"\n", r.Name, r.Route, r.Status, strings.ReplaceAll(r.Error, "\"", "\"\""))
+		}
+	default:
+		panic(fmt.Errorf("unknown format %s", format))
+	}
+}
@@ -0,0 +1,241 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+func appTransferCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+2)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, dryRunFlag())
+	flags = append(flags, newStringFlag("to", "t", "The new owner, a user id or a team as group:<name>. A group name from the rbac config is taken to be a team", ""))
+
+	return &cli.Command{
+		Name:      "transfer",
+		Usage:     "Transfer the ownership of apps to another user or team",
+		Flags:     flags,
+		ArgsUsage: "<appPathGlob>",
+
+		UsageText: `args: <appPathGlob>
+
+<appPathGlob> is a required argument. ` + PATH_SPEC_HELP + `
+
+Examples:
+  Transfer an app to a team: openrun app transfer /tools/report --to team-b
+  Transfer apps to a user: openrun app transfer "example.com:/tools/**" --to github:alice`,
+
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPathGlob>")
+			}
+			if cCtx.String("to") == "" {
+				return fmt.Errorf("the new owner is required, set --to")
+			}
+
+			client := newHttpClient(clientConfig)
+			values := url.Values{}
+			values.Add("appPathGlob", cCtx.Args().Get(0))
+			values.Add("to", cCtx.String("to"))
+			values.Add(DRY_RUN_ARG, strconv.FormatBool(cCtx.Bool(DRY_RUN_FLAG)))
+
+			var updateResponse types.AppUpdateSettingsResponse
+			if err := client.Post("/_openrun/app_transfer", values, nil, &updateResponse); err != nil {
+				return err
+			}
+
+			for _, updateResult := range updateResponse.UpdateResults {
+				printStdout(cCtx, "Transferring %s\n", updateResult)
+			}
+			printStdout(cCtx, "%d app(s) transferred.\n", len(updateResponse.UpdateResults))
+
+			if updateResponse.DryRun {
+				fmt.Print(DRY_RUN_MESSAGE)
+			}
+			return nil
+		},
+	}
+}
+
+func appDeprecateCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+4)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, dryRunFlag())
+	flags = append(flags, newStringFlag("message", "m", "The message shown in the banner to the app users", ""))
+	flags = append(flags, newStringFlag("delete-after", "", "Schedule the app deletion after this date (like 2026-01-31) or RFC 3339 timestamp", ""))
+	flags = append(flags, newBoolFlag("undo", "", "Remove the deprecation, cancelling any scheduled deletion", false))
+
+	return &cli.Command{
+		Name:      "deprecate",
+		Usage:     "Deprecate apps, showing a banner to the users and optionally scheduling the deletion",
+		Flags:     flags,
+		ArgsUsage: "<appPathGlob>",
+
+		UsageText: `args: <appPathGlob>
+
+<appPathGlob> is a required argument. ` + PATH_SPEC_HELP + `
+
+Examples:
+  Deprecate an app: openrun app deprecate /tools/report --message "Use /tools/reports instead"
+  Deprecate and schedule deletion: openrun app deprecate /tools/report --delete-after 2026-01-31
+  Remove the deprecation: openrun app deprecate /tools/report --undo`,
+
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPathGlob>")
+			}
+
+			client := newHttpClient(clientConfig)
+			values := url.Values{}
+			values.Add("appPathGlob", cCtx.Args().Get(0))
+			values.Add("message", cCtx.String("message"))
+			values.Add("deleteAfter", cCtx.String("delete-after"))
+			values.Add("undo", strconv.FormatBool(cCtx.Bool("undo")))
+			values.Add(DRY_RUN_ARG, strconv.FormatBool(cCtx.Bool(DRY_RUN_FLAG)))
+
+			var updateResponse types.AppUpdateSettingsResponse
+			if err := client.Post("/_openrun/app_deprecate", values, nil, &updateResponse); err != nil {
+				return err
+			}
+
+			action := "Deprecating"
+			if cCtx.Bool("undo") {
+				action = "Removing deprecation for"
+			}
+			for _, updateResult := range updateResponse.UpdateResults {
+				printStdout(cCtx, "%s %s\n", action, updateResult)
+			}
+			printStdout(cCtx, "%d app(s) updated.\n", len(updateResponse.UpdateResults))
+
+			if updateResponse.DryRun {
+				fmt.Print(DRY_RUN_MESSAGE)
+			}
+			return nil
+		},
+	}
+}
+
+func appPauseCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+3)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, dryRunFlag())
+	flags = append(flags, newStringFlag("message", "m", "The message shown in the maintenance page to the app users", ""))
+	flags = append(flags, newBoolFlag("undo", "", "Resume the paused apps", false))
+
+	return &cli.Command{
+		Name:      "pause",
+		Usage:     "Pause apps, stopping the app containers and showing a maintenance page to the users",
+		Flags:     flags,
+		ArgsUsage: "<appPathGlob>",
+
+		UsageText: `args: <appPathGlob>
+
+<appPathGlob> is a required argument. ` + PATH_SPEC_HELP + `
+
+The app metadata is kept, the app is started again when it is resumed.
+
+Examples:
+  Pause an app: openrun app pause /tools/report --message "Back after the database upgrade"
+  Resume the app: openrun app pause /tools/report --undo`,
+
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPathGlob>")
+			}
+
+			client := newHttpClient(clientConfig)
+			values := url.Values{}
+			values.Add("appPathGlob", cCtx.Args().Get(0))
+			values.Add("message", cCtx.String("message"))
+			values.Add("undo", strconv.FormatBool(cCtx.Bool("undo")))
+			values.Add(DRY_RUN_ARG, strconv.FormatBool(cCtx.Bool(DRY_RUN_FLAG)))
+
+			var updateResponse types.AppUpdateSettingsResponse
+			if err := client.Post("/_openrun/app_pause", values, nil, &updateResponse); err != nil {
+				return err
+			}
+
+			action := "Pausing"
+			if cCtx.Bool("undo") {
+				action = "Resuming"
+			}
+			for _, updateResult := range updateResponse.UpdateResults {
+				printStdout(cCtx, "%s %s\n", action, updateResult)
+			}
+			printStdout(cCtx, "%d app(s) updated.\n", len(updateResponse.UpdateResults))
+
+			if updateResponse.DryRun {
+				fmt.Print(DRY_RUN_MESSAGE)
+			}
+			return nil
+		},
+	}
+}
+
+func appArchiveCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+2)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, dryRunFlag())
+	flags = append(flags, newBoolFlag("undo", "", "Restore the archived apps", false))
+
+	return &cli.Command{
+		Name:      "archive",
+		Usage:     "Archive apps, exporting a bundle and removing the app containers and images",
+		Flags:     flags,
+		ArgsUsage: "<appPathGlob>",
+
+		UsageText: `args: <appPathGlob>
+
+<appPathGlob> is a required argument. ` + PATH_SPEC_HELP + `
+
+The app config and source files are exported to a zip bundle in the server
+system.app_archive_dir directory. The app entry is kept, so that the app can
+be restored. Requires the delete permission on the apps.
+
+Examples:
+  Archive an app: openrun app archive /tools/report
+  Restore the app: openrun app archive /tools/report --undo`,
+
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPathGlob>")
+			}
+
+			client := newHttpClient(clientConfig)
+			values := url.Values{}
+			values.Add("appPathGlob", cCtx.Args().Get(0))
+			values.Add("undo", strconv.FormatBool(cCtx.Bool("undo")))
+			values.Add(DRY_RUN_ARG, strconv.FormatBool(cCtx.Bool(DRY_RUN_FLAG)))
+
+			var archiveResponse types.AppArchiveResponse
+			if err := client.Post("/_openrun/app_archive", values, nil, &archiveResponse); err != nil {
+				return err
+			}
+
+			action := "Archiving"
+			if cCtx.Bool("undo") {
+				action = "Restoring"
+			}
+			for _, updateResult := range archiveResponse.UpdateResults {
+				if bundlePath, ok := archiveResponse.BundlePaths[updateResult.String()]; ok {
+					printStdout(cCtx, "%s %s, bundle %s\n", action, updateResult, bundlePath)
+				} else {
+					printStdout(cCtx, "%s %s\n", action, updateResult)
+				}
+			}
+			printStdout(cCtx, "%d app(s) updated.\n", len(archiveResponse.UpdateResults))
+
+			if archiveResponse.DryRun {
+				fmt.Print(DRY_RUN_MESSAGE)
+			}
+			return nil
+		},
+	}
+}
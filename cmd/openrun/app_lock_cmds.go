@@ -0,0 +1,134 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+func appLocksCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+2)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table, basic, csv, json, jsonl and jsonl_pretty", ""))
+
+	return &cli.Command{
+		Name:  "locks",
+		Usage: "List the app locks held by reload, promote, apply and delete operations in progress",
+		Flags: flags,
+		UsageText: `Examples:
+  List the app locks: openrun app locks`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 0 {
+				return fmt.Errorf("expected no args")
+			}
+
+			client := newHttpClient(clientConfig)
+			var response types.AppLockListResponse
+			if err := client.Get("/_openrun/app_locks", url.Values{}, &response); err != nil {
+				return err
+			}
+
+			printAppLocks(cCtx, response.Locks, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			return nil
+		},
+	}
+}
+
+func appUnlockCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+2)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, dryRunFlag())
+
+	return &cli.Command{
+		Name:      "unlock",
+		Usage:     "Force remove app locks left over from a stuck operation",
+		Flags:     flags,
+		ArgsUsage: "<appPathGlob>",
+
+		UsageText: `args: <appPathGlob>
+
+<appPathGlob> is a required argument. ` + PATH_SPEC_HELP + `
+
+The operation holding the lock is not stopped, only the lock is removed. Locks not renewed by the
+holder, like after a server crash, expire after two minutes without being removed.
+
+Examples:
+  Remove the lock on an app: openrun app unlock /myapp
+  List the locks which would be removed: openrun app unlock --dry-run "example.com:**"`,
+
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPathGlob>")
+			}
+
+			client := newHttpClient(clientConfig)
+			values := url.Values{}
+			values.Add("appPathGlob", cCtx.Args().Get(0))
+			values.Add(DRY_RUN_ARG, strconv.FormatBool(cCtx.Bool(DRY_RUN_FLAG)))
+
+			var unlockResult types.AppUnlockResponse
+			if err := client.Delete("/_openrun/app_locks", values, &unlockResult); err != nil {
+				return err
+			}
+
+			for _, lock := range unlockResult.Unlocked {
+				printStdout(cCtx, "Unlocking %s - held by %s for %s on %s\n", lock.Path, lock.Operation, lock.UserId, lock.Hostname)
+			}
+			printStdout(cCtx, "%d lock(s) removed.\n", len(unlockResult.Unlocked))
+
+			if unlockResult.DryRun {
+				fmt.Print(DRY_RUN_MESSAGE)
+			}
+			return nil
+		},
+	}
+}
+
+func printAppLocks(cCtx *cli.Context, locks []types.AppLock, format string) {
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		enc.Encode(locks) //nolint:errcheck
+	case FORMAT_JSONL:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		for _, lock := range locks {
+			enc.Encode(lock) //nolint:errcheck
+		}
+	case FORMAT_JSONL_PRETTY:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		for _, lock := range locks {
+			enc.Encode(lock) //nolint:errcheck
+		}
+	case FORMAT_BASIC:
+		formatStr := "%-30s %-15s %-20s\n"
+		printStdout(cCtx, formatStr, "Path", "Operation", "User")
+		for _, lock := range locks {
+			printStdout(cCtx, formatStr, lock.Path, lock.Operation, lock.UserId)
+		}
+	case FORMAT_TABLE, "":
+		formatStr := "%-30s %-15s %-20s %-20s %-20s %-20s\n"
+		printStdout(cCtx, formatStr, "Path", "Operation", "User", "Host", "Acquired", "Expires")
+		for _, lock := range locks {
+			printStdout(cCtx, formatStr, lock.Path, lock.Operation, lock.UserId, lock.Hostname,
+				lock.AcquireTime.Format(time.DateTime), lock.ExpireTime.Format(time.DateTime))
+		}
+	case FORMAT_CSV:
+		for _, lock := range locks {
+			printStdout(cCtx, "%s,%s,%s,%s,%s,%s,%s\n", lock.Path, lock.Operation, lock.UserId, lock.Hostname,
+				lock.HolderId, lock.AcquireTime.Format(time.RFC3339), lock.ExpireTime.Format(time.RFC3339))
+		}
+	default:
+		panic(fmt.Errorf("unknown format %s", format))
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+func appLogsCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+8)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newBoolFlag("follow", "F", "Keep streaming new log lines until interrupted", false))
+	flags = append(flags, newStringFlag("since", "s", "Show lines since a duration like 10m or a RFC3339 timestamp", ""))
+	flags = append(flags, newStringFlag("grep", "g", "Show only the lines matching the regex", ""))
+	flags = append(flags, newIntFlag("lines", "n", "The number of recent lines to show before following", 100))
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are basic and jsonl", ""))
+	flags = append(flags, newBoolFlag("container", "", "Show only the container logs, streamed from the container manager", false))
+	flags = append(flags, newStringFlag("service", "", "Show only the logs of the named service container", ""))
+	flags = append(flags, newBoolFlag("raw", "", "Keep the ANSI escape sequences in the container logs", false))
+
+	return &cli.Command{
+		Name:      "logs",
+		Usage:     "Show the app logs, merged with the container logs",
+		Flags:     flags,
+		ArgsUsage: "<appPath>",
+		UsageText: `args: <appPath>
+
+    <app_path> is a required first argument. The optional domain and path are separated by a ":".
+    The app logs, the print output from the handlers and, for containerized apps, the container
+    stdout and stderr are shown as a single stream in time order. Each line is tagged with its source:
+    handler, error (app logs at warn level and above) or container. The app logs are kept in memory
+    on the server, the recent lines for each app are available. Container logs are included for the
+    docker and podman container managers.
+
+    With --container, only the app container logs are shown, read directly from the container manager
+    (including Kubernetes). --service shows the logs of a service container instead. The ANSI escape
+    sequences are removed unless --raw is set. The lines are rate limited on the server, the count of
+    the dropped lines is shown if the container logs faster than the limit.
+
+	Examples:
+		openrun app logs /myapp
+		openrun app logs --follow --grep "timeout|refused" example.com:/myapp
+		openrun app logs --since 30m --lines 500 /myapp
+		openrun app logs --container --follow /myapp
+		openrun app logs --service postgres --lines 50 /myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPath>")
+			}
+
+			format := cmp.Or(cCtx.String("format"), FORMAT_BASIC)
+			if format != FORMAT_BASIC && format != FORMAT_JSONL {
+				return fmt.Errorf("unsupported format %s, valid options are basic and jsonl", format)
+			}
+
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+			values.Add("follow", strconv.FormatBool(cCtx.Bool("follow")))
+			apiPath := "/_openrun/app_logs"
+			if cCtx.Bool("container") || cCtx.String("service") != "" {
+				if cCtx.String("since") != "" || cCtx.String("grep") != "" {
+					return fmt.Errorf("since and grep are not supported with the container and service options")
+				}
+				apiPath = "/_openrun/app_container_logs"
+				values.Add("service", cCtx.String("service"))
+				values.Add("tail", strconv.Itoa(cCtx.Int("lines")))
+				values.Add("raw", strconv.FormatBool(cCtx.Bool("raw")))
+			} else {
+				values.Add("since", cCtx.String("since"))
+				values.Add("grep", cCtx.String("grep"))
+				values.Add("lines", strconv.Itoa(cCtx.Int("lines")))
+			}
+
+			client := newHttpClient(clientConfig)
+			return client.GetStream(apiPath, values, func(line []byte) error {
+				if format == FORMAT_JSONL {
+					printStdout(cCtx, "%s\n", line)
+					return nil
+				}
+				var entry types.AppLogEntry
+				if err := json.Unmarshal(line, &entry); err != nil {
+					return fmt.Errorf("error parsing log entry: %w", err)
+				}
+				printLogEntry(cCtx, entry)
+				return nil
+			})
+		},
+	}
+}
+
+func printLogEntry(cCtx *cli.Context, entry types.AppLogEntry) {
+	source := fmt.Sprintf("%-9s", entry.Source)
+	switch entry.Source {
+	case types.AppLogSourceError:
+		source = RED + source + RESET
+	case types.AppLogSourceContainer:
+		source = YELLOW + source + RESET
+	}
+	printStdout(cCtx, "%s %s %s\n", entry.Time.Local().Format("2006-01-02 15:04:05.000"), source, entry.Message)
+}
@@ -0,0 +1,132 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+	"go.starlark.net/syntax"
+)
+
+func appReplCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+2)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newBoolFlag("live", "", "Allow the write plugin calls also. Default is to run only the read plugin calls", false))
+	flags = append(flags, newBoolFlag("dry-run", "", "Print the plugin calls without running them", false))
+
+	return &cli.Command{
+		Name:      "repl",
+		Usage:     "Start an interactive Starlark session with the app builtins, params and plugins loaded",
+		Flags:     flags,
+		ArgsUsage: "<appPath>",
+		UsageText: `args: <appPath>
+
+    <app_path> is a required first argument. The optional domain and path are separated by a ":".
+    The input is evaluated on the server, using the current version of the app. The app params are
+    available as the param global, app files and plugins can be loaded, like load("app.star", "handler")
+    or load("http.in", "http"). Plugin calls run with the permissions approved for the app, with secrets
+    in the arguments resolved like for the app. By default, only the read plugin calls are allowed, the
+    write calls fail. With --live, the write calls are also run. With --dry-run, plugin calls print
+    the call without running it, like in an audit.
+    The app update permission is required. End the session with Ctrl-D.
+
+	Examples:
+		openrun app repl /myapp
+		openrun app repl --live example.com:/myapp
+		openrun app repl --dry-run /myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPath>")
+			}
+
+			if cCtx.Bool("live") && cCtx.Bool("dry-run") {
+				return fmt.Errorf("only one of --live and --dry-run can be specified")
+			}
+			mode := types.ReplModeReadOnly
+			if cCtx.Bool("live") {
+				mode = types.ReplModeLive
+			} else if cCtx.Bool("dry-run") {
+				mode = types.ReplModeDryRun
+			}
+
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+			values.Add("mode", string(mode))
+			client := newHttpClient(clientConfig)
+			sessionId := ""
+			defer func() {
+				if sessionId != "" {
+					closeValues := url.Values{}
+					closeValues.Add("appPath", cCtx.Args().First())
+					closeValues.Add("sessionId", sessionId)
+					client.Delete("/_openrun/app_repl", closeValues, &map[string]any{}) //nolint:errcheck
+				}
+			}()
+
+			reader := bufio.NewReader(cCtx.App.Reader)
+			for {
+				input, err := readReplInput(cCtx, reader)
+				if err == io.EOF {
+					printStdout(cCtx, "\n")
+					return nil
+				} else if err != nil {
+					printStdout(cCtx, "%s\n", RED+err.Error()+RESET)
+					continue
+				}
+				if strings.TrimSpace(input) == "" {
+					continue
+				}
+
+				var response types.AppReplResponse
+				if err := client.Post("/_openrun/app_repl", values, types.AppReplRequest{SessionId: sessionId, Input: input}, &response); err != nil {
+					return err
+				}
+				sessionId = response.SessionId
+				printStdout(cCtx, "%s", response.Output)
+				if response.Result != "" {
+					printStdout(cCtx, "%s\n", response.Result)
+				}
+				if response.Error != "" {
+					printStdout(cCtx, "%s\n", RED+response.Error+RESET)
+				}
+			}
+		},
+	}
+}
+
+// readReplInput reads one statement, continuing on more lines for compound statements like def
+// and for. The statement is parsed locally only to find where it ends, it is evaluated on the
+// server. io.EOF is returned at the end of the input
+func readReplInput(cCtx *cli.Context, reader *bufio.Reader) (string, error) {
+	var input strings.Builder
+	prompt := ">>> "
+	eof := false
+	readline := func() ([]byte, error) {
+		printStdout(cCtx, "%s", prompt)
+		prompt = "... "
+		line, err := reader.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			eof = err == io.EOF
+			return nil, err
+		}
+		line = strings.TrimSuffix(line, "\n") + "\n"
+		input.WriteString(line)
+		return []byte(line), nil
+	}
+
+	opts := &syntax.FileOptions{While: true, Recursion: true, LoadBindsGlobally: true}
+	if _, err := opts.ParseCompoundStmt("<repl>", readline); err != nil {
+		if eof {
+			return "", io.EOF
+		}
+		return "", err
+	}
+	return input.String(), nil
+}
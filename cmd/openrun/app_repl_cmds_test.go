@@ -0,0 +1,45 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestReadReplInput(t *testing.T) {
+	app := cli.NewApp()
+	out := &bytes.Buffer{}
+	app.Writer = out
+	cCtx := cli.NewContext(app, flag.NewFlagSet("test", flag.ContinueOnError), nil)
+
+	reader := bufio.NewReader(strings.NewReader("x = 1\ndef f(a):\n    return a + x\n\nf(2\n)\n1 +\n"))
+	expected := []string{"x = 1\n", "def f(a):\n    return a + x\n\n", "f(2\n)\n"}
+	for _, exp := range expected {
+		input, err := readReplInput(cCtx, reader)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if input != exp {
+			t.Fatalf("expected %q, got %q", exp, input)
+		}
+	}
+	if prompts := out.String(); prompts != ">>> >>> ... ... >>> ... " {
+		t.Fatalf("unexpected prompts %q", prompts)
+	}
+
+	// A syntax error is returned, the next statement can be read after that
+	if _, err := readReplInput(cCtx, reader); err == nil || !strings.Contains(err.Error(), "want primary expression") {
+		t.Fatalf("expected syntax error, got %v", err)
+	}
+	if _, err := readReplInput(cCtx, reader); err != io.EOF {
+		t.Fatalf("expected EOF, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+func appStatsCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+1)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are basic and json", ""))
+
+	return &cli.Command{
+		Name:      "stats",
+		Usage:     "Show the resource limits and the current resource usage of the app containers",
+		Flags:     flags,
+		ArgsUsage: "<appPath>",
+		UsageText: `args: <appPath>
+
+    <app_path> is a required first argument. The optional domain and path are separated by a ":".
+    The cpus, memory and pids limits of the app container are shown, along with the limits summed
+    over all the apps on the server and the container_quota totals. The current usage of the app
+    and service containers is read using the docker or podman stats command.
+
+	Examples:
+		openrun app stats /myapp
+		openrun app stats --format json example.com:/myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPath>")
+			}
+			format := cmp.Or(cCtx.String("format"), FORMAT_BASIC)
+			if format != FORMAT_BASIC && format != FORMAT_JSON {
+				return fmt.Errorf("unsupported format %s, valid options are basic and json", format)
+			}
+
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+
+			client := newHttpClient(clientConfig)
+			var response types.AppStatsResponse
+			if err := client.Get("/_openrun/app_stats", values, &response); err != nil {
+				return err
+			}
+
+			if format == FORMAT_JSON {
+				enc := json.NewEncoder(cCtx.App.Writer)
+				enc.SetIndent("", "  ")
+				enc.Encode(response) //nolint:errcheck
+				return nil
+			}
+
+			limits := response.Limits
+			printStdout(cCtx, "Limits:       cpus %s, memory %s, pids %s\n",
+				formatCpuLimit(limits.CpuMilli), formatMemoryLimit(limits.MemoryBytes), formatPidsLimit(limits.Pids))
+			printStdout(cCtx, "Server total: cpus %s of %s, memory %s of %s\n",
+				formatCpuLimit(response.TotalUsed.CpuMilli), formatCpuLimit(response.TotalQuota.CpuMilli),
+				formatMemoryLimit(response.TotalUsed.MemoryBytes), formatMemoryLimit(response.TotalQuota.MemoryBytes))
+			if len(response.Containers) == 0 {
+				printStdout(cCtx, "No running containers\n")
+				return nil
+			}
+			formatStr := "%-40s %-8s %-24s %-8s %s\n"
+			printStdout(cCtx, "\n"+formatStr, "Container", "CPU", "Memory", "Mem %", "Pids")
+			for _, c := range response.Containers {
+				printStdout(cCtx, formatStr, c.Name, c.CPUPercent, c.MemUsage, c.MemPercent, c.PIDs)
+			}
+			return nil
+		},
+	}
+}
+
+func formatCpuLimit(milli int64) string {
+	if milli == 0 {
+		return "-"
+	}
+	return strconv.FormatFloat(float64(milli)/1000, 'f', -1, 64)
+}
+
+func formatMemoryLimit(bytes int64) string {
+	if bytes == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1fMiB", float64(bytes)/(1024*1024))
+}
+
+func formatPidsLimit(pids int64) string {
+	if pids == 0 {
+		return "-"
+	}
+	return strconv.FormatInt(pids, 10)
+}
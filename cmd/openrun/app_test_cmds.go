@@ -0,0 +1,123 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/openrundev/openrun/pkg/api"
+	"github.com/urfave/cli/v2"
+)
+
+func appTestCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+3)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("filter", "", "Run only the tests whose name matches the glob pattern", ""))
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table, basic, csv, json, jsonl and jsonl_pretty", ""))
+	flags = append(flags,
+		&cli.StringSliceFlag{
+			Name:    "param",
+			Aliases: []string{"p"},
+			Usage:   "Set a parameter value. Format is paramName=paramValue",
+		})
+
+	return &cli.Command{
+		Name:      "test",
+		Usage:     "Run the Starlark unit tests for an app source directory",
+		Flags:     flags,
+		ArgsUsage: "<app_source_dir>",
+		UsageText: `args: <app_source_dir>
+
+    <app_source_dir> is a required argument, the local directory with the app source.
+    The tests run in the openrun client process, no server is required.
+
+    Files named *_test.star, in the app directory or its tests directory, are test files. Each top level
+    function named test_* in a test file is a test. The test files load the app code using load("app.star", ...)
+    and have the assert module (eq, ne, true, false, contains, fails) and the test module predeclared.
+    test.request creates a request to pass to a handler, test.mock sets the value returned by a plugin
+    function and test.calls returns the calls made to a mocked plugin function. A plugin call without
+    a mock fails the test. The command fails if any test fails.
+
+	Examples:
+		openrun app test ./myapp
+		openrun app test --filter "test_login*" --param env=test ./myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <app_source_dir>")
+			}
+
+			params := make(map[string]string)
+			for _, param := range cCtx.StringSlice("param") {
+				key, value, ok := strings.Cut(param, "=")
+				if !ok {
+					return fmt.Errorf("invalid param format: %s", param)
+				}
+				params[key] = value
+			}
+
+			results, err := api.RunAppTests(cCtx.Context, cCtx.Args().First(), cCtx.String("filter"), params)
+			if err != nil {
+				return err
+			}
+
+			printAppTestResults(cCtx, results, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			failed := 0
+			for _, r := range results {
+				if !r.Passed {
+					failed++
+				}
+			}
+			printStdout(cCtx, "%d passed, %d failed\n", len(results)-failed, failed)
+			if failed > 0 {
+				return cli.Exit(fmt.Sprintf("%d test(s) failed", failed), 1)
+			}
+			return nil
+		},
+	}
+}
+
+func printAppTestResults(cCtx *cli.Context, results []types.AppTestResult, format string) {
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		enc.Encode(results) //nolint:errcheck
+	case FORMAT_JSONL:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		for _, r := range results {
+			enc.Encode(r) //nolint:errcheck
+		}
+	case FORMAT_JSONL_PRETTY:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		for _, r := range results {
+			enc.Encode(r) //nolint:errcheck
+			printStdout(cCtx, "\n")
+		}
+	case FORMAT_BASIC:
+		fallthrough
+	case FORMAT_TABLE:
+		for _, r := range results {
+			status := GREEN + "PASS" + RESET
+			if !r.Passed {
+				status = RED + "FAIL" + RESET
+			}
+			printStdout(cCtx, "%s %-30s %-40s %4d assertions %6dms\n", status, r.File, r.Name, r.Assertions, r.DurationMs)
+			if r.Error != "" {
+				printStdout(cCtx, "       %s\n", strings.ReplaceAll(r.Error, "\n", "\n       "))
+			}
+		}
+	case FORMAT_CSV:
+		for _, r := range results {
+			printStdout(cCtx, "%s,%s,%t,%d,%d,\"%s\"\n", r.File, r.Name, r.Passed, r.Assertions, r.DurationMs,
+				strings.ReplaceAll(r.Error, "\"", "\"\""))
+		}
+	default:
+		panic(fmt.Errorf("unknown format %s", format))
+	}
+}
@@ -4,8 +4,11 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
+	"os"
 	"strconv"
 
 	"github.com/openrundev/openrun/internal/types"
@@ -145,6 +148,90 @@ The second required argument is <appPathGlob>. ` + PATH_SPEC_HELP + `
 	}
 }
 
+func appPatchSettingsCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+4)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, dryRunFlag())
+	flags = append(flags, newStringFlag("patch", "", "The JSON merge patch file with the settings to update, - to read from stdin", ""))
+	flags = append(flags, newBoolFlag(PROMOTE_FLAG, "p", "Promote the staged changes from stage to prod", false))
+	flags = append(flags, promoteConfirmFlag())
+	flags = append(flags, newIntFlag("if-version", "", "Update only if the app row version is this value, the app path has to match one app", -1))
+
+	return &cli.Command{
+		Name:      "update-settings",
+		Usage:     "Update the settings for apps using a JSON merge patch, applied in one transaction across the matched apps",
+		Flags:     flags,
+		ArgsUsage: "<appPathGlob>",
+
+		UsageText: `args: <appPathGlob>
+
+<appPathGlob> is a required argument. ` + PATH_SPEC_HELP + `
+
+The patch is a JSON merge patch (RFC 7396), a null value removes the setting. The supported settings are
+stage_write_access and preview_write_access, which apply immediately, and authn_type, git_auth_name,
+container_options, container_args, container_volumes and app_config, which are staged like metadata updates.
+
+With --if-version, the update fails with a conflict error if the app was updated after it was read, like by a
+sync. The row_version of the app is shown by "openrun app list -f json". After a conflict, read the app
+again and retry the update.
+
+	Examples:
+	  Update apps using a patch file: openrun app update-settings --patch settings.json "example.com:**"
+	  Update and promote: echo '{"container_options": {"cpus": "2", "memory": null}}' | openrun app update-settings --patch - --promote /tools/*
+	  Update if the app is unchanged: openrun app update-settings --patch settings.json --if-version 4 /tools/disk_usage`,
+
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPathGlob>")
+			}
+			patchFile := cCtx.String("patch")
+			if patchFile == "" {
+				return fmt.Errorf("the patch file is required, set --patch")
+			}
+
+			var data []byte
+			var err error
+			if patchFile == "-" {
+				data, err = io.ReadAll(os.Stdin)
+			} else {
+				data, err = os.ReadFile(patchFile)
+			}
+			if err != nil {
+				return fmt.Errorf("error reading patch %s: %w", patchFile, err)
+			}
+			var patch map[string]any
+			if err := json.Unmarshal(data, &patch); err != nil {
+				return fmt.Errorf("error parsing patch %s, expected a JSON object: %w", patchFile, err)
+			}
+
+			client := newHttpClient(clientConfig)
+			values := url.Values{}
+			values.Add("appPathGlob", cCtx.Args().Get(0))
+			values.Add(DRY_RUN_ARG, strconv.FormatBool(cCtx.Bool(DRY_RUN_FLAG)))
+			values.Add(PROMOTE_ARG, strconv.FormatBool(cCtx.Bool(PROMOTE_FLAG)))
+			values.Add(CONFIRM_ARG, strconv.FormatBool(cCtx.Bool(CONFIRM_FLAG)))
+			if cCtx.Int("if-version") >= 0 {
+				values.Add("ifVersion", strconv.Itoa(cCtx.Int("if-version")))
+			}
+
+			var updateResponse types.AppUpdateSettingsResponse
+			if err := client.Post("/_openrun/app_settings_patch", values, patch, &updateResponse); err != nil {
+				return err
+			}
+
+			for _, updateResult := range updateResponse.UpdateResults {
+				printStdout(cCtx, "Updating %s\n", updateResult)
+			}
+			printStdout(cCtx, "%d app(s) updated.\n", len(updateResponse.UpdateResults))
+
+			if updateResponse.DryRun {
+				fmt.Print(DRY_RUN_MESSAGE)
+			}
+			return nil
+		},
+	}
+}
+
 func appUpdateMetadataCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
 	return &cli.Command{
 		Name:  "update",
@@ -167,6 +254,7 @@ func appUpdateAppSpec(commonFlags []cli.Flag, clientConfig *types.ClientConfig)
 	flags = append(flags, commonFlags...)
 	flags = append(flags, dryRunFlag())
 	flags = append(flags, newBoolFlag(PROMOTE_FLAG, "p", "Promote the change from stage to prod", false))
+	flags = append(flags, promoteConfirmFlag())
 
 	return &cli.Command{
 		Name:      "spec",
@@ -193,6 +281,7 @@ The last required argument is <appPathGlob>. ` + PATH_SPEC_HELP + `
 			values.Add("appPathGlob", cCtx.Args().Get(1))
 			values.Add(DRY_RUN_ARG, strconv.FormatBool(cCtx.Bool(DRY_RUN_FLAG)))
 			values.Add(PROMOTE_ARG, strconv.FormatBool(cCtx.Bool(PROMOTE_FLAG)))
+			values.Add(CONFIRM_ARG, strconv.FormatBool(cCtx.Bool(CONFIRM_FLAG)))
 
 			body := types.CreateUpdateAppMetadataRequest()
 			body.Spec = types.StringValue(cCtx.Args().Get(0))
@@ -234,6 +323,7 @@ func appUpdateConfig(commonFlags []cli.Flag, clientConfig *types.ClientConfig, a
 	flags = append(flags, commonFlags...)
 	flags = append(flags, dryRunFlag())
 	flags = append(flags, newBoolFlag(PROMOTE_FLAG, "p", "Promote the change from stage to prod", false))
+	flags = append(flags, promoteConfirmFlag())
 
 	cmd := &cli.Command{
 		Name:      arg,
@@ -259,6 +349,7 @@ The initial argument are strings. The last argument is <appPathGlob>. `+PATH_SPE
 			values.Add("appPathGlob", cCtx.Args().Get(cCtx.NArg()-1))
 			values.Add(DRY_RUN_ARG, strconv.FormatBool(cCtx.Bool(DRY_RUN_FLAG)))
 			values.Add(PROMOTE_ARG, strconv.FormatBool(cCtx.Bool(PROMOTE_FLAG)))
+			values.Add(CONFIRM_ARG, strconv.FormatBool(cCtx.Bool(CONFIRM_FLAG)))
 
 			body := types.CreateUpdateAppMetadataRequest()
 			body.ConfigType = configType
@@ -23,9 +23,13 @@ func initApplyCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig)
 	flags = append(flags, newBoolFlag("approve", "a", "Approve the app permissions", false))
 	flags = append(flags, newStringFlag("reload", "r", "Which apps to reload: none, updated, matched", ""))
 	flags = append(flags, newBoolFlag("promote", "p", "Promote changes from stage to prod", false))
+	flags = append(flags, promoteConfirmFlag())
 	flags = append(flags, newBoolFlag("verify", "", "Verify reload by reloading app containers", false))
 	flags = append(flags, newBoolFlag("clobber", "", "Force update app config, overwriting non-declarative changes", false))
 	flags = append(flags, newBoolFlag("force-reload", "f", "Force reload even if there is no new commit", false))
+	flags = append(flags, newBoolFlag("plan", "", "Save the changes as a plan, to be reviewed and applied later using --plan-id", false))
+	flags = append(flags, newStringFlag("plan-id", "", "The id of the plan to apply, requires --execute", ""))
+	flags = append(flags, newBoolFlag("execute", "", "Apply the plan given by --plan-id", false))
 	flags = append(flags, dryRunFlag())
 
 	return &cli.Command{
@@ -45,9 +49,20 @@ Examples:
   Apply app config from git for all apps: openrun apply --promote --approve github.com/openrundev/apps/apps.ace all
   Apply app config with reload verification: openrun apply --verify --promote --approve github.com/openrundev/apps/apps.ace all
   Apply app config from git for all apps, overwriting changes: openrun apply --promote --clobber github.com/openrundev/apps/apps.ace all
+  Save the changes for review: openrun apply --plan --promote github.com/openrundev/apps/apps.ace all
+  Apply a reviewed plan: openrun apply --plan-id apl_pln_2ojkqtqbl1cf8yyuxbsz7lo9wig --execute
 `,
 
 		Action: func(cCtx *cli.Context) error {
+			if planId := cCtx.String("plan-id"); planId != "" {
+				return executeApplyPlan(cCtx, clientConfig, planId)
+			}
+			if cCtx.Bool("execute") {
+				return fmt.Errorf("--execute requires --plan-id")
+			}
+			if cCtx.Bool("plan") && cCtx.Bool(DRY_RUN_FLAG) {
+				return fmt.Errorf("--plan cannot be used with --dry-run, the plan is not applied until executed")
+			}
 			if cCtx.NArg() == 0 || cCtx.NArg() > 2 {
 				return fmt.Errorf("expected one or two arguments: <filePath> [<appPathGlob>]")
 			}
@@ -72,12 +87,24 @@ Examples:
 			values.Add("gitAuth", cCtx.String("git-auth"))
 			values.Add("reload", string(reloadMode))
 			values.Add("promote", strconv.FormatBool(cCtx.Bool("promote")))
+			values.Add(CONFIRM_ARG, strconv.FormatBool(cCtx.Bool(CONFIRM_FLAG)))
 			values.Add("verify", strconv.FormatBool(cCtx.Bool("verify")))
 			values.Add("clobber", strconv.FormatBool(cCtx.Bool("clobber")))
 			values.Add("forceReload", strconv.FormatBool(cCtx.Bool("force-reload")))
 			values.Add("dev", strconv.FormatBool(cCtx.Bool("dev")))
 
 			client := newHttpClient(clientConfig)
+			if cCtx.Bool("plan") {
+				values.Add("plan", "true")
+				var plan types.ApplyPlan
+				if err := client.Post("/_openrun/apply", values, nil, &plan); err != nil {
+					return err
+				}
+				printApplyResponse(cCtx, &plan.Response)
+				printStdout(cCtx, "Plan %s saved. Apply it using: openrun apply --plan-id %s --execute\n", plan.Id, plan.Id)
+				return nil
+			}
+
 			var applyResponse types.AppApplyResponse
 			err = client.Post("/_openrun/apply", values, nil, &applyResponse)
 			if err != nil {
@@ -94,6 +121,28 @@ Examples:
 	}
 }
 
+func executeApplyPlan(cCtx *cli.Context, clientConfig *types.ClientConfig, planId string) error {
+	if cCtx.NArg() != 0 {
+		return fmt.Errorf("no arguments expected with --plan-id, the plan has the apply file and app path glob")
+	}
+	if !cCtx.Bool("execute") {
+		return fmt.Errorf("--execute is required to apply plan %s", planId)
+	}
+
+	values := url.Values{}
+	values.Add("planId", planId)
+	values.Add("execute", "true")
+	values.Add(CONFIRM_ARG, strconv.FormatBool(cCtx.Bool(CONFIRM_FLAG)))
+
+	client := newHttpClient(clientConfig)
+	var applyResponse types.AppApplyResponse
+	if err := client.Post("/_openrun/apply", values, nil, &applyResponse); err != nil {
+		return err
+	}
+	printApplyResponse(cCtx, &applyResponse)
+	return nil
+}
+
 func printApplyResponse(cCtx *cli.Context, applyResponse *types.AppApplyResponse) {
 	if len(applyResponse.CreateResults) > 0 {
 		printStdout(cCtx, "Created apps:\n")
@@ -0,0 +1,233 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+func initAuditCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	return &cli.Command{
+		Name:  "audit",
+		Usage: "View the audit log",
+		Subcommands: []*cli.Command{
+			auditListCommand(commonFlags, clientConfig),
+			auditVerifyCommand(commonFlags, clientConfig),
+		},
+	}
+}
+
+func auditListCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+12)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("app", "a", "The app glob pattern, list only the events for the matching apps", ""))
+	flags = append(flags, newStringFlag("user", "u", "List only the events for the user id", ""))
+	flags = append(flags, newStringFlag("type", "t", "List only the events of the type: system, http or custom", ""))
+	flags = append(flags, newStringFlag("operation", "o", "List only the events for the operation, like reload_apps or POST", ""))
+	flags = append(flags, newStringFlag("target", "", "List only the events for the target", ""))
+	flags = append(flags, newStringFlag("status", "", "List only the events with the status", ""))
+	flags = append(flags, newStringFlag("rid", "", "List only the events for the request id", ""))
+	flags = append(flags, newStringFlag("start", "", "List events after this time, a date (2006-01-02) or a RFC3339 timestamp", ""))
+	flags = append(flags, newStringFlag("end", "", "List events before this time, a date (2006-01-02, inclusive) or a RFC3339 timestamp", ""))
+	flags = append(flags, newStringFlag("before", "", "Get the next page, the value printed at the end of the previous page", ""))
+	flags = append(flags, newIntFlag("limit", "n", "The number of events to list", 50))
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table, basic, csv, json, jsonl and jsonl_pretty", ""))
+
+	return &cli.Command{
+		Name:  "list",
+		Usage: "List audit events, newest first",
+		Flags: flags,
+		UsageText: `Examples:
+  List recent events:           openrun audit list
+  List events for apps:         openrun audit list --app "example.com:**"
+  List reloads by a user:       openrun audit list --user admin --operation reload_apps
+  List events in a time range:  openrun audit list --start 2025-01-01 --end 2025-01-31
+  Get the next page:            openrun audit list --before 1735689600000000000`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 0 {
+				return fmt.Errorf("expected no args")
+			}
+
+			values := url.Values{}
+			for flag, param := range map[string]string{
+				"app": "app", "user": "user", "type": "event_type", "operation": "operation", "target": "target",
+				"status": "status", "rid": "rid", "start": "start", "end": "end", "before": "before",
+			} {
+				if value := cCtx.String(flag); value != "" {
+					values.Add(param, value)
+				}
+			}
+			values.Add("limit", strconv.Itoa(cCtx.Int("limit")))
+
+			client := newHttpClient(clientConfig)
+			var response types.AuditListResponse
+			if err := client.Get("/_openrun/audit", values, &response); err != nil {
+				return err
+			}
+
+			format := cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat)
+			printAuditEvents(cCtx, response.Events, format)
+			if response.NextBefore != 0 && (format == FORMAT_TABLE || format == FORMAT_BASIC || format == "") {
+				fmt.Fprintf(cCtx.App.ErrWriter, "More events available, use --before %d for the next page\n", response.NextBefore) //nolint:errcheck
+			}
+			return nil
+		},
+	}
+}
+
+func auditVerifyCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+1)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table, basic, csv, json, jsonl and jsonl_pretty", ""))
+
+	return &cli.Command{
+		Name:  "verify",
+		Usage: "Verify the audit log hash chains and signed checkpoints, to detect tampering",
+		Flags: flags,
+		UsageText: `Each audit event includes the hash of the previous event, modified, deleted and inserted events
+break the chain. The chain heads are signed periodically with the keyring key, events are checked
+against these checkpoints. The command fails if any tampering is found.
+
+Examples:
+  Verify the audit log: openrun audit verify`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 0 {
+				return fmt.Errorf("expected no args")
+			}
+
+			client := newHttpClient(clientConfig)
+			var response types.AuditVerifyResponse
+			if err := client.Get("/_openrun/audit/verify", url.Values{}, &response); err != nil {
+				return err
+			}
+
+			printAuditVerify(cCtx, &response, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			if !response.Verified {
+				return fmt.Errorf("audit log verification failed, %d errors", len(response.Errors))
+			}
+			return nil
+		},
+	}
+}
+
+func printAuditVerify(cCtx *cli.Context, response *types.AuditVerifyResponse, format string) {
+	checkpointTime := func(c types.AuditChainStatus) string {
+		if c.CheckpointTime == nil {
+			return "-"
+		}
+		return c.CheckpointTime.Local().Format(time.DateTime)
+	}
+
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		enc.Encode(response) //nolint:errcheck
+		return
+	case FORMAT_JSONL:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		for _, c := range response.Chains {
+			enc.Encode(c) //nolint:errcheck
+		}
+		return
+	case FORMAT_JSONL_PRETTY:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		for _, c := range response.Chains {
+			enc.Encode(c) //nolint:errcheck
+		}
+		return
+	case FORMAT_BASIC:
+		formatStr := "%-45s %-10s %s\n"
+		printStdout(cCtx, formatStr, "Chain", "Events", "LastSeq")
+		for _, c := range response.Chains {
+			printStdout(cCtx, formatStr, c.ChainId, strconv.FormatInt(c.Events, 10), strconv.FormatInt(c.LastSeq, 10))
+		}
+	case FORMAT_TABLE, "":
+		formatStr := "%-45s %-10s %-10s %-10s %-12s %s\n"
+		printStdout(cCtx, formatStr, "Chain", "Events", "FirstSeq", "LastSeq", "Checkpoint", "CheckpointTime")
+		for _, c := range response.Chains {
+			printStdout(cCtx, formatStr, c.ChainId, strconv.FormatInt(c.Events, 10), strconv.FormatInt(c.FirstSeq, 10),
+				strconv.FormatInt(c.LastSeq, 10), strconv.FormatInt(c.CheckpointSeq, 10), checkpointTime(c))
+		}
+	case FORMAT_CSV:
+		for _, c := range response.Chains {
+			printStdout(cCtx, "%s,%d,%d,%d,%d,%s\n", c.ChainId, c.Events, c.FirstSeq, c.LastSeq, c.CheckpointSeq, checkpointTime(c))
+		}
+		return
+	default:
+		panic(fmt.Errorf("unknown format %s", format))
+	}
+
+	if response.Unchained > 0 {
+		printStdout(cCtx, "\n%d events from before the hash chains are not verified\n", response.Unchained)
+	}
+	for _, warning := range response.Warnings {
+		printStdout(cCtx, "Warning: %s\n", warning)
+	}
+	for _, e := range response.Errors {
+		printStdout(cCtx, "Error: %s\n", e)
+	}
+	if response.Verified {
+		printStdout(cCtx, "Audit log verified\n")
+	}
+}
+
+func auditEventApp(event types.AuditEventInfo) string {
+	if event.AppPath == "" {
+		return string(event.AppId)
+	}
+	if event.AppEnv == "" || event.AppEnv == "prod" {
+		return event.AppPath
+	}
+	return event.AppPath + " (" + event.AppEnv + ")"
+}
+
+func printAuditEvents(cCtx *cli.Context, events []types.AuditEventInfo, format string) {
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		enc.Encode(events) //nolint:errcheck
+	case FORMAT_JSONL:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		for _, e := range events {
+			enc.Encode(e) //nolint:errcheck
+		}
+	case FORMAT_JSONL_PRETTY:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		for _, e := range events {
+			enc.Encode(e) //nolint:errcheck
+		}
+	case FORMAT_BASIC:
+		formatStr := "%-20s %-20s %-25s %-8s\n"
+		printStdout(cCtx, formatStr, "Time", "User", "Operation", "Status")
+		for _, e := range events {
+			printStdout(cCtx, formatStr, e.CreateTime.Local().Format(time.DateTime), e.UserId, e.Operation, e.Status)
+		}
+	case FORMAT_TABLE, "":
+		formatStr := "%-20s %-20s %-8s %-25s %-30s %-30s %-8s\n"
+		printStdout(cCtx, formatStr, "Time", "User", "Type", "Operation", "App", "Target", "Status")
+		for _, e := range events {
+			printStdout(cCtx, formatStr, e.CreateTime.Local().Format(time.DateTime), e.UserId, e.EventType, e.Operation,
+				auditEventApp(e), e.Target, e.Status)
+		}
+	case FORMAT_CSV:
+		for _, e := range events {
+			printStdout(cCtx, "%s,%s,%s,%s,%s,%s,%s,%s,%s,%q\n", e.CreateTime.Format(time.RFC3339Nano), e.Rid, e.UserId,
+				e.EventType, e.Operation, e.AppId, e.AppEnv, e.Target, e.Status, e.Detail)
+		}
+	default:
+		panic(fmt.Errorf("unknown format %s", format))
+	}
+}
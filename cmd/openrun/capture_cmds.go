@@ -0,0 +1,244 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+func initCaptureCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	return &cli.Command{
+		Name:  "app-capture",
+		Usage: "Capture app traffic for debugging and replay it against the stage app",
+		Subcommands: []*cli.Command{
+			captureStartCommand(commonFlags, clientConfig),
+			captureStopCommand(commonFlags, clientConfig),
+			captureDownloadCommand(commonFlags, clientConfig),
+			captureReplayCommand(commonFlags, clientConfig),
+		},
+	}
+}
+
+func printCaptureStatus(cCtx *cli.Context, status types.CaptureStatus) {
+	printStdout(cCtx, "App        : %s\n", status.AppPath)
+	printStdout(cCtx, "Active     : %t\n", status.Active)
+	printStdout(cCtx, "Window     : %s - %s\n", status.StartTime.Format("2006-01-02 15:04:05"), status.EndTime.Format("2006-01-02 15:04:05"))
+	printStdout(cCtx, "Entries    : %d (max %d, dropped %d)\n", status.EntryCount, status.MaxEntries, status.Dropped)
+	printStdout(cCtx, "Body limit : %d bytes\n", status.MaxBodyBytes)
+}
+
+func captureStartCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+3)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newIntFlag("duration", "d", "The capture window in seconds, default is 600", 0))
+	flags = append(flags, newIntFlag("max-entries", "m", "The maximum number of requests to capture, default is 1000", 0))
+	flags = append(flags, newIntFlag("max-body-bytes", "b", "The maximum bytes captured for each request and response body, default is 65536. Use -1 to skip bodies", 0))
+
+	return &cli.Command{
+		Name:      "start",
+		Usage:     "Start capturing requests for an app",
+		Flags:     flags,
+		ArgsUsage: "<appPath>",
+		UsageText: `args: <appPath>
+
+    <app_path> is a required first argument. The optional domain and path are separated by a ":".
+
+    Sensitive headers (like Authorization and Cookie) and sensitive looking query and form values are
+    redacted. The capture is kept in memory on the server which handles the API call and only records
+    the requests served by that server.
+
+	Examples:
+		openrun app-capture start --duration 300 example.com:/myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPath>")
+			}
+
+			client := newHttpClient(clientConfig)
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+			values.Add("durationSecs", strconv.Itoa(cCtx.Int("duration")))
+			values.Add("maxEntries", strconv.Itoa(cCtx.Int("max-entries")))
+			values.Add("maxBodyBytes", strconv.Itoa(cCtx.Int("max-body-bytes")))
+
+			var response types.CaptureStatus
+			if err := client.Post("/_openrun/app_capture", values, map[string]string{}, &response); err != nil {
+				return err
+			}
+			printCaptureStatus(cCtx, response)
+			return nil
+		},
+	}
+}
+
+func captureStopCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+1)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newBoolFlag("clear", "c", "Discard the captured entries", false))
+
+	return &cli.Command{
+		Name:      "stop",
+		Usage:     "Stop capturing requests for an app",
+		Flags:     flags,
+		ArgsUsage: "<appPath>",
+		UsageText: `args: <appPath>
+
+    <app_path> is a required first argument. The optional domain and path are separated by a ":".
+    The captured entries are available for download and replay until the next start, unless --clear is used.
+
+	Examples:
+		openrun app-capture stop example.com:/myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPath>")
+			}
+
+			client := newHttpClient(clientConfig)
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+			values.Add("clear", strconv.FormatBool(cCtx.Bool("clear")))
+
+			var response types.CaptureStatus
+			if err := client.Delete("/_openrun/app_capture", values, &response); err != nil {
+				return err
+			}
+			printCaptureStatus(cCtx, response)
+			return nil
+		},
+	}
+}
+
+func captureDownloadCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+1)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("output", "o", "The file to write the captured entries to, default is stdout", ""))
+
+	return &cli.Command{
+		Name:      "download",
+		Usage:     "Download the captured requests for an app, as JSON",
+		Flags:     flags,
+		ArgsUsage: "<appPath>",
+		UsageText: `args: <appPath>
+
+    <app_path> is a required first argument. The optional domain and path are separated by a ":".
+
+	Examples:
+		openrun app-capture download -o capture.json example.com:/myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPath>")
+			}
+
+			client := newHttpClient(clientConfig)
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+
+			var response types.CaptureDownloadResponse
+			if err := client.Get("/_openrun/app_capture", values, &response); err != nil {
+				return err
+			}
+
+			data, err := json.MarshalIndent(response, "", "  ")
+			if err != nil {
+				return err
+			}
+			if outputFile := cCtx.String("output"); outputFile != "" {
+				return os.WriteFile(outputFile, data, 0600)
+			}
+			printStdout(cCtx, "%s\n", data)
+			return nil
+		},
+	}
+}
+
+func captureReplayCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+2)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newBoolFlag("include-writes", "w", "Replay non GET/HEAD requests also. This can modify the stage app data", false))
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table, basic, csv, json, jsonl and jsonl_pretty", ""))
+
+	return &cli.Command{
+		Name:      "replay",
+		Usage:     "Replay the captured requests for a prod app against its stage app",
+		Flags:     flags,
+		ArgsUsage: "<appPath>",
+		UsageText: `args: <appPath>
+
+    <app_path> is a required first argument. The optional domain and path are separated by a ":".
+    The requests captured for the prod app are sent to the stage app, as the user running the command.
+    Redacted header values are not sent.
+
+	Examples:
+		openrun app-capture replay example.com:/myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPath>")
+			}
+
+			client := newHttpClient(clientConfig)
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+			values.Add("includeWrites", strconv.FormatBool(cCtx.Bool("include-writes")))
+
+			var response types.CaptureReplayResponse
+			if err := client.Post("/_openrun/app_capture/replay", values, map[string]string{}, &response); err != nil {
+				return err
+			}
+
+			printReplayResults(cCtx, response.Results, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			printStdout(cCtx, "Stage app %s: %d matched, %d mismatched, %d skipped\n",
+				response.StageAppPath, response.Matched, response.Mismatched, response.Skipped)
+			return nil
+		},
+	}
+}
+
+func printReplayResults(cCtx *cli.Context, results []types.CaptureReplayResult, format string) {
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		enc.Encode(results) //nolint:errcheck
+	case FORMAT_JSONL:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		for _, r := range results {
+			enc.Encode(r) //nolint:errcheck
+		}
+	case FORMAT_JSONL_PRETTY:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		for _, r := range results {
+			enc.Encode(r) //nolint:errcheck
+			printStdout(cCtx, "\n")
+		}
+	case FORMAT_BASIC:
+		fallthrough
+	case FORMAT_TABLE:
+		formatStr := "%-7s %-8s %-6s %-9s %-40s %s\n"
+		printStdout(cCtx, formatStr, "Method", "Captured", "Replay", "BodyMatch", "Path", "Error")
+		for _, r := range results {
+			replayStatus := strconv.Itoa(r.ReplayStatus)
+			if r.Skipped {
+				replayStatus = "skip"
+			}
+			printStdout(cCtx, formatStr, r.Method, strconv.Itoa(r.CapturedStatus), replayStatus,
+				strconv.FormatBool(r.BodyMatch), r.Path, r.Error)
+		}
+	case FORMAT_CSV:
+		for _, r := range results {
+			printStdout(cCtx, "%s,%d,%d,%t,%t,%s,\"%s\"\n", r.Method, r.CapturedStatus, r.ReplayStatus,
+				r.Skipped, r.BodyMatch, r.Path, r.Error)
+		}
+	default:
+		panic(fmt.Errorf("unknown format %s", format))
+	}
+}
@@ -15,6 +15,7 @@ func getClientCommands(clientConfig *types.ClientConfig) ([]*cli.Command, error)
 	commands = append(commands, initApplyCommand(flags, clientConfig))
 	commands = append(commands, initExportCommand(flags, clientConfig))
 	commands = append(commands, initPrettyPrintCommand(flags, clientConfig))
+	commands = append(commands, initGraphCommand(flags, clientConfig))
 	commands = append(commands, initSyncCommand(flags, clientConfig))
 	commands = append(commands, initServiceCommand(flags, clientConfig))
 	commands = append(commands, initProviderCommand(flags, clientConfig))
@@ -23,8 +24,16 @@ func getClientCommands(clientConfig *types.ClientConfig) ([]*cli.Command, error)
 	commands = append(commands, initParamCommand(flags, clientConfig))
 	commands = append(commands, initVersionCommand(flags, clientConfig))
 	commands = append(commands, initWebhookCommand(flags, clientConfig))
+	commands = append(commands, initCaptureCommand(flags, clientConfig))
+	commands = append(commands, initProfileCommand(flags, clientConfig))
 	commands = append(commands, initPreviewCommand(flags, clientConfig))
 	commands = append(commands, initAccountCommand(flags, clientConfig))
 	commands = append(commands, initUserCommand(flags, clientConfig))
+	commands = append(commands, initTenantCommand(flags, clientConfig))
+	commands = append(commands, initQuotaCommand(flags, clientConfig))
+	commands = append(commands, initAuditCommand(flags, clientConfig))
+	commands = append(commands, initReportCommand(flags, clientConfig))
+	commands = append(commands, initVolumeCommand(flags, clientConfig))
+	commands = append(commands, initKeyringCommand(flags, clientConfig))
 	return commands, nil
 }
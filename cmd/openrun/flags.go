@@ -93,7 +93,7 @@ func validateNoFlagLikeValues(flagName string, valueName string, values []string
 // This needs to be called in the client before the call to system.NewHttpClient
 // since that changes the cwd to $OPENRUN_HOME
 func makeAbsolute(sourceUrl string) (string, error) {
-	if sourceUrl == "-" || system.IsGit(sourceUrl) {
+	if sourceUrl == "-" || system.IsGit(sourceUrl) || system.IsPackaged(sourceUrl) || strings.HasPrefix(sourceUrl, types.IMAGE_SOURCE_PREFIX) {
 		return sourceUrl, nil
 	}
 
@@ -0,0 +1,119 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+const FORMAT_DOT = "dot"
+
+func initGraphCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+2)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are dot, table, basic, csv, json", ""))
+	flags = append(flags, newStringFlag("resource", "r", "Show only the apps and resources which depend on this resource, like secret:db/api_key", ""))
+
+	return &cli.Command{
+		Name:      "graph",
+		Usage:     "Show the dependency graph between apps and the resources they use",
+		Flags:     flags,
+		ArgsUsage: "[<appPathGlob>]",
+		UsageText: `args: [<appPathGlob>]
+
+<appPathGlob> is an optional argument, defaulting to "all".
+` + PATH_SPEC_HELP +
+			`
+The graph has the apps and the resources they depend on: git repos (git_repo), source directories
+(directory), container images for image spec apps (image), secrets referenced in params and config
+(secret), bindings (binding) and their services (service), git auth entries (git_auth) and the apps
+whose blocks are included with appBlock (recorded when rendered, since the server start). Resources
+are named type:name. With --resource, only the resource and the apps and resources depending on it,
+directly or indirectly, are shown.
+
+Examples:
+  Show all dependencies: openrun graph
+  Render as an image using graphviz: openrun graph --format dot | dot -Tsvg > graph.svg
+  Apps using a secret: openrun graph --resource secret:db/api_key
+  Apps built from a repo: openrun graph --resource git_repo:https://github.com/openrundev/apps
+`,
+
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() > 1 {
+				return fmt.Errorf("expected at most one argument: [<appPathGlob>]")
+			}
+			values := url.Values{}
+			values.Add("appPathGlob", cmp.Or(cCtx.Args().First(), "all"))
+			values.Add("resource", cCtx.String("resource"))
+
+			client := newHttpClient(clientConfig)
+			var response types.DependencyGraph
+			if err := client.Get("/_openrun/graph", values, &response); err != nil {
+				return err
+			}
+			return printGraph(cCtx, &response, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+		},
+	}
+}
+
+func printGraph(cCtx *cli.Context, graph *types.DependencyGraph, format string) error {
+	switch format {
+	case FORMAT_DOT:
+		printStdout(cCtx, "%s", graphDot(graph))
+	case FORMAT_JSON:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		enc.Encode(graph) //nolint:errcheck
+	case FORMAT_BASIC, FORMAT_TABLE:
+		formatStr := "%-50s %-14s %s\n"
+		printStdout(cCtx, formatStr, "From", "Type", "To")
+		for _, edge := range graph.Edges {
+			printStdout(cCtx, formatStr, edge.From, edge.Type, edge.To)
+		}
+	case FORMAT_CSV:
+		for _, edge := range graph.Edges {
+			printStdout(cCtx, "\"%s\",%s,\"%s\"\n", edge.From, edge.Type, edge.To)
+		}
+	default:
+		return fmt.Errorf("unknown format %s", format)
+	}
+	return nil
+}
+
+// graphNodeShapes are the graphviz shapes for the node types, apps use the default box
+var graphNodeShapes = map[string]string{
+	"git_repo":  "folder",
+	"directory": "folder",
+	"image":     "box3d",
+	"secret":    "diamond",
+	"binding":   "ellipse",
+	"service":   "cylinder",
+	"git_auth":  "diamond",
+}
+
+// graphDot returns the graph in the graphviz dot format
+func graphDot(graph *types.DependencyGraph) string {
+	var b strings.Builder
+	b.WriteString("digraph openrun {\n  rankdir=LR;\n  node [shape=box];\n")
+	for _, node := range graph.Nodes {
+		attrs := "label=" + strconv.Quote(node.Name)
+		if shape, ok := graphNodeShapes[node.Type]; ok {
+			attrs += ", shape=" + shape
+		}
+		fmt.Fprintf(&b, "  %s [%s];\n", strconv.Quote(node.Id), attrs)
+	}
+	for _, edge := range graph.Edges {
+		fmt.Fprintf(&b, "  %s -> %s [label=%s];\n", strconv.Quote(edge.From), strconv.Quote(edge.To), strconv.Quote(edge.Type))
+	}
+	b.WriteString("}\n")
+	return b.String()
+}
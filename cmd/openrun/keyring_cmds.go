@@ -0,0 +1,169 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+func initKeyringCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	return &cli.Command{
+		Name:  "keyring",
+		Usage: "Manage the server keys used to sign session cookies and webhook secrets",
+		Subcommands: []*cli.Command{
+			keyringListCommand(commonFlags, clientConfig),
+			keyringRotateCommand(commonFlags, clientConfig),
+			keyringRemoveCommand(commonFlags, clientConfig),
+		},
+	}
+}
+
+func keyringFormatFlags(commonFlags []cli.Flag) []cli.Flag {
+	flags := make([]cli.Flag, 0, len(commonFlags)+1)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table, basic, csv, json, jsonl and jsonl_pretty", ""))
+	return flags
+}
+
+func keyringListCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	return &cli.Command{
+		Name:      "list",
+		Usage:     "List the keyring keys",
+		Flags:     keyringFormatFlags(commonFlags),
+		ArgsUsage: "",
+		UsageText: `
+	The active key signs new session cookies and webhook secrets, the retired keys are still accepted.
+
+	Examples:
+	  List keys: openrun keyring list`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() > 0 {
+				return fmt.Errorf("no args expected")
+			}
+
+			client := newHttpClient(clientConfig)
+			var response types.KeyringResponse
+			if err := client.Get("/_openrun/keyring", url.Values{}, &response); err != nil {
+				return err
+			}
+
+			printKeyring(cCtx, response.Keys, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			return nil
+		},
+	}
+}
+
+func keyringRotateCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	return &cli.Command{
+		Name:      "rotate",
+		Usage:     "Add a new active key, the current active key is retired",
+		Flags:     keyringFormatFlags(commonFlags),
+		ArgsUsage: "",
+		UsageText: `
+	The sessions and webhook secrets signed with the retired key stay valid till the key is removed.
+	The sessions move to the new key as they are saved. Update the webhook secrets in the git provider
+	using "openrun sync webhook-secret" before removing the retired key.
+
+	Examples:
+	  Rotate keyring: openrun keyring rotate`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() > 0 {
+				return fmt.Errorf("no args expected")
+			}
+
+			client := newHttpClient(clientConfig)
+			var response types.KeyringResponse
+			if err := client.Post("/_openrun/keyring/rotate", url.Values{}, nil, &response); err != nil {
+				return err
+			}
+
+			printKeyring(cCtx, response.Keys, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			return nil
+		},
+	}
+}
+
+func keyringRemoveCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	return &cli.Command{
+		Name:      "remove",
+		Usage:     "Remove a retired key",
+		Flags:     keyringFormatFlags(commonFlags),
+		ArgsUsage: "<keyId>",
+		UsageText: `args: <keyId>
+
+	The sessions and webhook secrets signed with the key are no longer accepted. The active key
+	cannot be removed.
+
+	Examples:
+	  Remove key: openrun keyring remove k4xa2b9c1`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("expected one arg: <keyId>")
+			}
+
+			client := newHttpClient(clientConfig)
+			values := url.Values{}
+			values.Add("id", cCtx.Args().First())
+			var response types.KeyringResponse
+			if err := client.Delete("/_openrun/keyring", values, &response); err != nil {
+				return err
+			}
+
+			printKeyring(cCtx, response.Keys, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			return nil
+		},
+	}
+}
+
+func printKeyring(cCtx *cli.Context, keys []types.KeyringKey, format string) {
+	retireTime := func(k types.KeyringKey) string {
+		if k.RetireTime == nil {
+			return "-"
+		}
+		return k.RetireTime.Format(time.RFC3339)
+	}
+
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		enc.Encode(keys) //nolint:errcheck
+	case FORMAT_JSONL:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		for _, k := range keys {
+			enc.Encode(k) //nolint:errcheck
+		}
+	case FORMAT_JSONL_PRETTY:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		for _, k := range keys {
+			enc.Encode(k) //nolint:errcheck
+		}
+	case FORMAT_BASIC:
+		formatStr := "%-12s %s\n"
+		printStdout(cCtx, formatStr, "Id", "Status")
+		for _, k := range keys {
+			printStdout(cCtx, formatStr, k.Id, k.Status)
+		}
+	case FORMAT_TABLE, "":
+		formatStr := "%-12s %-8s %-25s %s\n"
+		printStdout(cCtx, formatStr, "Id", "Status", "Created", "Retired")
+		for _, k := range keys {
+			printStdout(cCtx, formatStr, k.Id, k.Status, k.CreateTime.Format(time.RFC3339), retireTime(k))
+		}
+	case FORMAT_CSV:
+		for _, k := range keys {
+			printStdout(cCtx, "%s,%s,%s,%s\n", k.Id, k.Status, k.CreateTime.Format(time.RFC3339), retireTime(k))
+		}
+	default:
+		panic(fmt.Errorf("unknown format %s", format))
+	}
+}
@@ -206,11 +206,12 @@ func main() {
 			if err != nil {
 				return fmt.Errorf("error parsing config: %w", err)
 			}
+			setCliLanguage(clientConfig.Client.Language)
 			return nil
 		},
 		ExitErrHandler: func(c *cli.Context, err error) {
 			if err != nil {
-				fmt.Fprintf(cli.ErrWriter, RED+"error: %s\n"+RESET, err) //nolint:errcheck
+				fmt.Fprintf(cli.ErrWriter, "%s\n", RED+cliMessage("cli.error", err)+RESET) //nolint:errcheck
 				system.NotifyServiceFailed(1)
 				os.Exit(1)
 			}
@@ -228,7 +229,7 @@ func main() {
 	}
 
 	if err := app.Run(normalizeInterspersedFlags(app, os.Args)); err != nil {
-		fmt.Fprintf(os.Stderr, "error: %s", err) //nolint:errcheck
+		fmt.Fprint(os.Stderr, cliMessage("cli.error", err)) //nolint:errcheck
 		system.NotifyServiceFailed(1)
 		os.Exit(1)
 	}
@@ -0,0 +1,33 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/i18n"
+)
+
+// cliLanguage is the language for the CLI messages, set from the client config before the
+// command is run
+var cliLanguage = i18n.DEFAULT_LANGUAGE
+
+// setCliLanguage sets the CLI language from the client.language config, or from the locale env
+// values if that is not set. Unsupported languages use the default language
+func setCliLanguage(configured string) {
+	cliLanguage = i18n.Builtin().Match("", cmp.Or(configured, i18n.EnvLanguage()))
+}
+
+// cliMessage returns the message for the key in the CLI language
+func cliMessage(key string, args ...any) string {
+	return i18n.Builtin().Message(cliLanguage, key, args...)
+}
+
+// isYesAnswer returns true if the answer to a confirmation prompt is yes in the CLI language
+func isYesAnswer(answer string) bool {
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer != "" && slices.Contains(strings.Split(cliMessage("cli.yes_answers"), ","), answer)
+}
@@ -0,0 +1,168 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"syscall"
+	"unicode/utf8"
+
+	"github.com/openrundev/openrun/internal/app/apptype"
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+)
+
+const maskedValue = "********"
+
+// promptCreateParams reads the param definitions for the app being created and prompts for the
+// param values not set using --param. A summary is shown before the app is created, returns
+// false if the user does not confirm the create
+func promptCreateParams(cCtx *cli.Context, client *system.HttpClient, values url.Values, body *types.CreateAppRequest) (bool, error) {
+	paramsRequest := *body
+	paramsRequest.ParamsOnly = true
+	var paramsResult types.AppCreateResponse
+	if err := client.Post("/_openrun/app", values, paramsRequest, &paramsResult); err != nil {
+		return false, err
+	}
+
+	reader := bufio.NewReader(cCtx.App.Reader)
+	readSecret := func() (string, error) {
+		if !term.IsTerminal(int(syscall.Stdin)) {
+			return readPromptLine(reader)
+		}
+		value, err := readPassword()
+		printStdout(cCtx, "\n")
+		return value, err
+	}
+
+	if err := promptParamValues(cCtx, reader, readSecret, paramsResult.Params, body.ParamValues); err != nil {
+		return false, err
+	}
+
+	printCreateSummary(cCtx, body, paramsResult.Params)
+	printStdout(cCtx, "\n%s", cliMessage("cli.create_confirm"))
+	answer, err := readPromptLine(reader)
+	if err != nil {
+		return false, err
+	}
+	return isYesAnswer(answer), nil
+}
+
+// readPromptLine reads one line of input, without the line ending. io.ErrUnexpectedEOF is
+// returned if the input ends before a line is read
+func readPromptLine(reader *bufio.Reader) (string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		if err == io.EOF {
+			return "", io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	return strings.TrimSpace(line), nil
+}
+
+// promptParamValues prompts for the values of the params which are not already set in
+// paramValues. Values are validated against the param definition, an invalid value is prompted
+// for again. An empty value keeps the default, params which are required and have no default
+// need a value. readSecret is used to read the value for password params, without echo
+func promptParamValues(cCtx *cli.Context, reader *bufio.Reader, readSecret func() (string, error),
+	params []types.AppParamInfo, paramValues map[string]string) error {
+	for _, info := range params {
+		if _, ok := paramValues[info.Name]; ok {
+			continue
+		}
+		param, err := apptype.ParamFromInfo(info)
+		if err != nil {
+			return err
+		}
+
+		printStdout(cCtx, "\n%s (%s)", info.Name, strings.ToLower(info.Type))
+		if info.Description != "" {
+			printStdout(cCtx, ": %s", info.Description)
+		}
+		printStdout(cCtx, "\n")
+		if len(info.Values) > 0 {
+			printStdout(cCtx, "  %s\n", cliMessage("cli.allowed_values", strings.Join(info.Values, ", ")))
+		}
+
+		mustSet := info.Required && !info.HasDefault
+		for {
+			switch {
+			case mustSet:
+				printStdout(cCtx, "  %s", cliMessage("cli.value_required_prompt"))
+			case info.HasDefault && info.Default != "":
+				printStdout(cCtx, "  %s", cliMessage("cli.value_default_prompt", paramDisplayValue(info, info.Default)))
+			default:
+				printStdout(cCtx, "  %s", cliMessage("cli.value_optional_prompt"))
+			}
+
+			var value string
+			if info.DisplayType == string(apptype.DisplayTypePassword) {
+				value, err = readSecret()
+			} else {
+				value, err = readPromptLine(reader)
+			}
+			if err != nil {
+				return fmt.Errorf("error reading value for param %s: %w", info.Name, err)
+			}
+
+			if value == "" {
+				if !mustSet {
+					break // use the default
+				}
+				printStdout(cCtx, "  %s\n", RED+cliMessage("cli.value_required", info.Name)+RESET)
+				continue
+			}
+			if err := param.ValidateValue(value); err != nil {
+				printStdout(cCtx, "  %s\n", RED+err.Error()+RESET)
+				continue
+			}
+			paramValues[info.Name] = value
+			break
+		}
+	}
+	return nil
+}
+
+// paramDisplayValue returns the value to display for the param, password values are masked
+func paramDisplayValue(info types.AppParamInfo, value string) string {
+	if value != "" && info.DisplayType == string(apptype.DisplayTypePassword) {
+		return maskedValue
+	}
+	return value
+}
+
+// printCreateSummary prints the app details and the param values which will be used for the create
+func printCreateSummary(cCtx *cli.Context, body *types.CreateAppRequest, params []types.AppParamInfo) {
+	appLabel, sourceLabel, specLabel, paramsLabel := cliMessage("cli.summary_app"), cliMessage("cli.summary_source"),
+		cliMessage("cli.summary_spec"), cliMessage("cli.summary_params")
+	// Right align the labels, the translated labels differ in length
+	width := 2 + max(utf8.RuneCountInString(appLabel), utf8.RuneCountInString(sourceLabel),
+		utf8.RuneCountInString(specLabel), utf8.RuneCountInString(paramsLabel))
+	printStdout(cCtx, "\n%*s: %s\n", width, appLabel, body.Path)
+	printStdout(cCtx, "%*s: %s\n", width, sourceLabel, body.SourceUrl)
+	if body.Spec != "" {
+		printStdout(cCtx, "%*s: %s\n", width, specLabel, body.Spec)
+	}
+	if len(params) == 0 {
+		return
+	}
+
+	printStdout(cCtx, "%*s:\n", width, paramsLabel)
+	for _, info := range params {
+		value, ok := body.ParamValues[info.Name]
+		source := ""
+		if !ok {
+			value = info.Default
+			source = " " + cliMessage("cli.summary_default")
+		}
+		printStdout(cCtx, "    %s = %s%s\n", info.Name, paramDisplayValue(info, value), source)
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/i18n"
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+func TestPromptParamValues(t *testing.T) {
+	app := cli.NewApp()
+	out := &bytes.Buffer{}
+	app.Writer = out
+	cCtx := cli.NewContext(app, flag.NewFlagSet("test", flag.ContinueOnError), nil)
+
+	params := []types.AppParamInfo{
+		{Name: "preset", Type: "STRING", Required: true},
+		{Name: "port", Type: "INT", Required: true, HasDefault: true, Default: "8000"},
+		{Name: "workers", Type: "INT", Required: true},
+		{Name: "mode", Type: "ENUM", Required: true, Values: []string{"dev", "prod"}, HasDefault: true, Default: "dev"},
+		{Name: "password", Type: "STRING", Required: true, DisplayType: "password"},
+		{Name: "title", Type: "STRING", Description: "The app title", HasDefault: true},
+	}
+
+	// workers gets an empty and an invalid value before a valid one, mode an invalid value
+	reader := bufio.NewReader(strings.NewReader("\n\nabc\n4\ntest\nprod\n\n"))
+	secrets := []string{"", "s3cret"}
+	readSecret := func() (string, error) {
+		if len(secrets) == 0 {
+			return "", io.ErrUnexpectedEOF
+		}
+		secret := secrets[0]
+		secrets = secrets[1:]
+		return secret, nil
+	}
+
+	values := map[string]string{"preset": "set"}
+	if err := promptParamValues(cCtx, reader, readSecret, params, values); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := map[string]string{"preset": "set", "workers": "4", "mode": "prod", "password": "s3cret"}
+	if len(values) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, values)
+	}
+	for k, v := range expected {
+		if values[k] != v {
+			t.Errorf("param %s: expected %q, got %q", k, v, values[k])
+		}
+	}
+
+	output := out.String()
+	for _, text := range []string{"Value [8000]: ", "A value is required for workers", "param workers is not an int",
+		`param mode value "test" is not one of the allowed values dev, prod`, "Allowed values: dev, prod",
+		"title (string): The app title", "Value (optional): "} {
+		if !strings.Contains(output, text) {
+			t.Errorf("output missing %q: %s", text, output)
+		}
+	}
+	if strings.Contains(output, "preset") {
+		t.Errorf("param set using --param was prompted for: %s", output)
+	}
+
+	out.Reset()
+	printCreateSummary(cCtx, &types.CreateAppRequest{Path: "/myapp", SourceUrl: "/src", Spec: "python-flask", ParamValues: values}, params)
+	summary := out.String()
+	for _, text := range []string{"App: /myapp", "Spec: python-flask", "port = 8000 (default)", "password = ********", "mode = prod\n"} {
+		if !strings.Contains(summary, text) {
+			t.Errorf("summary missing %q: %s", text, summary)
+		}
+	}
+	if strings.Contains(summary, "s3cret") {
+		t.Errorf("password not masked: %s", summary)
+	}
+
+	// Input ending before the required values are entered is an error
+	err := promptParamValues(cCtx, bufio.NewReader(strings.NewReader("")), readSecret, params[2:3], map[string]string{})
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected unexpected EOF, got %v", err)
+	}
+}
+
+func TestCliLanguage(t *testing.T) {
+	defer func() { cliLanguage = i18n.DEFAULT_LANGUAGE }()
+
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "fr_FR.UTF-8")
+	setCliLanguage("")
+	if cliLanguage != "fr-fr" {
+		t.Fatalf("env language = %s", cliLanguage)
+	}
+	if msg := cliMessage("cli.value_optional_prompt"); msg != "Valeur (facultative) : " {
+		t.Errorf("message = %q", msg)
+	}
+	if !isYesAnswer(" Oui") || isYesAnswer("non") {
+		t.Errorf("yes answer check failed for fr")
+	}
+
+	setCliLanguage("ja")
+	if cliLanguage != i18n.DEFAULT_LANGUAGE || !isYesAnswer("yes") || isYesAnswer("") {
+		t.Errorf("unsupported language = %s", cliLanguage)
+	}
+
+	// The summary labels are aligned to the longest translated label
+	setCliLanguage("de")
+	app := cli.NewApp()
+	var out bytes.Buffer
+	app.Writer = &out
+	cCtx := cli.NewContext(app, flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	printCreateSummary(cCtx, &types.CreateAppRequest{Path: "/myapp", SourceUrl: "/src"}, nil)
+	if out.String() != "\n        App: /myapp\n     Quelle: /src\n" {
+		t.Errorf("summary = %q", out.String())
+	}
+}
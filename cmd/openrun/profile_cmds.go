@@ -0,0 +1,200 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+// profileTopCount is the number of functions and plugin calls listed by profile show
+const profileTopCount = 20
+
+func initProfileCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	return &cli.Command{
+		Name:  "app-profile",
+		Usage: "Profile the time spent in the Starlark functions and plugin calls of app handlers",
+		Subcommands: []*cli.Command{
+			profileStartCommand(commonFlags, clientConfig),
+			profileStopCommand(commonFlags, clientConfig),
+			profileShowCommand(commonFlags, clientConfig),
+		},
+	}
+}
+
+func printProfileStatus(cCtx *cli.Context, status types.ProfileStatus) {
+	printStdout(cCtx, "App         : %s\n", status.AppPath)
+	printStdout(cCtx, "Active      : %t\n", status.Active)
+	printStdout(cCtx, "Window      : %s - %s\n", status.StartTime.Format("2006-01-02 15:04:05"), status.EndTime.Format("2006-01-02 15:04:05"))
+	printStdout(cCtx, "Sample rate : %g\n", status.SampleRate)
+	printStdout(cCtx, "Profiles    : %d (sampled %d, keeping slowest %d)\n", status.ProfileCount, status.Sampled, status.MaxProfiles)
+}
+
+func profileStartCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+3)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newIntFlag("duration", "d", "The profiling window in seconds, default is 600", 0))
+	flags = append(flags, &cli.Float64Flag{Name: "sample-rate", Aliases: []string{"r"}, Usage: "The fraction of the requests to profile, between 0 and 1. Default is 1, all requests"})
+	flags = append(flags, newIntFlag("max-profiles", "m", "The number of profiles to keep, the slowest requests are kept. Default is 50", 0))
+
+	return &cli.Command{
+		Name:      "start",
+		Usage:     "Start profiling the handlers for an app",
+		Flags:     flags,
+		ArgsUsage: "<appPath>",
+		UsageText: `args: <appPath>
+
+    <app_path> is a required first argument. The optional domain and path are separated by a ":".
+
+    The Starlark call stack of the sampled requests is sampled periodically and the plugin calls are
+    timed. The profiles are kept in memory on the server which handles the API call and only record
+    the requests served by that server.
+
+	Examples:
+		openrun app-profile start --sample-rate 0.1 --duration 300 example.com:/myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPath>")
+			}
+
+			client := newHttpClient(clientConfig)
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+			values.Add("durationSecs", strconv.Itoa(cCtx.Int("duration")))
+			values.Add("sampleRate", strconv.FormatFloat(cCtx.Float64("sample-rate"), 'f', -1, 64))
+			values.Add("maxProfiles", strconv.Itoa(cCtx.Int("max-profiles")))
+
+			var response types.ProfileStatus
+			if err := client.Post("/_openrun/app_profile", values, map[string]string{}, &response); err != nil {
+				return err
+			}
+			printProfileStatus(cCtx, response)
+			return nil
+		},
+	}
+}
+
+func profileStopCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+1)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newBoolFlag("clear", "", "Discard the recorded profiles", false))
+
+	return &cli.Command{
+		Name:      "stop",
+		Usage:     "Stop profiling the handlers for an app",
+		Flags:     flags,
+		ArgsUsage: "<appPath>",
+		UsageText: `args: <appPath>
+
+    <app_path> is a required first argument. The optional domain and path are separated by a ":".
+    The recorded profiles are available until the next start, unless --clear is used.
+
+	Examples:
+		openrun app-profile stop example.com:/myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPath>")
+			}
+
+			client := newHttpClient(clientConfig)
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+			values.Add("clear", strconv.FormatBool(cCtx.Bool("clear")))
+
+			var response types.ProfileStatus
+			if err := client.Delete("/_openrun/app_profile", values, &response); err != nil {
+				return err
+			}
+			printProfileStatus(cCtx, response)
+			return nil
+		},
+	}
+}
+
+func profileShowCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+2)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newBoolFlag("folded", "", "Print the merged folded stacks, for use with flame graph tools", false))
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table and json", ""))
+
+	return &cli.Command{
+		Name:      "show",
+		Usage:     "Show the handler profiles recorded for an app",
+		Flags:     flags,
+		ArgsUsage: "<appPath>",
+		UsageText: `args: <appPath>
+
+    <app_path> is a required first argument. The optional domain and path are separated by a ":".
+    The slowest requests are listed, followed by the functions and plugin calls with the most time,
+    added up across the profiles. The time is in milliseconds. With --folded, the stacks are printed
+    with the time in microseconds, in the format used by flamegraph.pl and speedscope.
+
+	Examples:
+		openrun app-profile show example.com:/myapp
+		openrun app-profile show --folded example.com:/myapp > stacks.txt`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("requires one argument: <appPath>")
+			}
+
+			client := newHttpClient(clientConfig)
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+
+			var response types.ProfileDownloadResponse
+			if err := client.Get("/_openrun/app_profile", values, &response); err != nil {
+				return err
+			}
+
+			if cCtx.Bool("folded") {
+				for _, stack := range slices.Sorted(maps.Keys(response.Merged.Stacks)) {
+					printStdout(cCtx, "%s %d\n", stack, response.Merged.Stacks[stack])
+				}
+				return nil
+			}
+			if cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat) == FORMAT_JSON {
+				enc := json.NewEncoder(cCtx.App.Writer)
+				enc.SetIndent("", "  ")
+				return enc.Encode(response)
+			}
+			printProfiles(cCtx, response)
+			return nil
+		},
+	}
+}
+
+func printProfiles(cCtx *cli.Context, response types.ProfileDownloadResponse) {
+	printProfileStatus(cCtx, response.Status)
+
+	printStdout(cCtx, "\nSlowest requests\n")
+	formatStr := "%-12s %10s  %-7s %-30s %s\n"
+	printStdout(cCtx, formatStr, "Time", "Duration", "Method", "Path", "Handler")
+	for _, p := range response.Profiles[:min(len(response.Profiles), profileTopCount)] {
+		printStdout(cCtx, formatStr, p.Time.Format("15:04:05.000"), strconv.FormatFloat(p.DurationMs, 'f', 2, 64),
+			p.Method, p.Path, p.Handler)
+	}
+
+	printStdout(cCtx, "\nFunctions\n")
+	formatStr = "%10s %10s  %s\n"
+	printStdout(cCtx, formatStr, "Self", "Total", "Name")
+	for _, f := range response.Merged.Functions[:min(len(response.Merged.Functions), profileTopCount)] {
+		printStdout(cCtx, formatStr, strconv.FormatFloat(f.SelfMs, 'f', 2, 64), strconv.FormatFloat(f.TotalMs, 'f', 2, 64), f.Name)
+	}
+
+	printStdout(cCtx, "\nPlugin calls\n")
+	formatStr = "%10s %8s %10s  %s\n"
+	printStdout(cCtx, formatStr, "Total", "Count", "Max", "Name")
+	for _, c := range response.Merged.PluginCalls[:min(len(response.Merged.PluginCalls), profileTopCount)] {
+		printStdout(cCtx, formatStr, strconv.FormatFloat(c.TotalMs, 'f', 2, 64), strconv.Itoa(c.Count),
+			strconv.FormatFloat(c.MaxMs, 'f', 2, 64), c.Name)
+	}
+}
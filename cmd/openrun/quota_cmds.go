@@ -0,0 +1,114 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+func initQuotaCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	return &cli.Command{
+		Name:  "quota",
+		Usage: "View quotas, configured in the rbac.quotas dynamic config",
+		Subcommands: []*cli.Command{
+			quotaShowCommand(commonFlags, clientConfig),
+		},
+	}
+}
+
+func quotaShowCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+2)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("user", "u", "The user to show the quotas for, defaults to the current user", ""))
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table, basic, csv, json, jsonl and jsonl_pretty", ""))
+
+	return &cli.Command{
+		Name:  "show",
+		Usage: "Show the quotas which apply to a user, with the current usage",
+		Flags: flags,
+		UsageText: `Examples:
+  Show quotas for the current user: openrun quota show
+  Show quotas for a user:           openrun quota show --user github:alice`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 0 {
+				return fmt.Errorf("expected no args")
+			}
+
+			values := url.Values{}
+			values.Add("user", cCtx.String("user"))
+
+			client := newHttpClient(clientConfig)
+			var response types.QuotaShowResponse
+			if err := client.Get("/_openrun/quota", values, &response); err != nil {
+				return err
+			}
+
+			printQuotas(cCtx, response, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			return nil
+		},
+	}
+}
+
+// quotaLimit formats the usage against the limit, zero limit is unlimited
+func quotaLimit[T int | int64](usage, limit T) string {
+	if limit == 0 {
+		return fmt.Sprintf("%d", usage)
+	}
+	return fmt.Sprintf("%d/%d", usage, limit)
+}
+
+func printQuotas(cCtx *cli.Context, response types.QuotaShowResponse, format string) {
+	storageMB := func(q types.QuotaStatus) int64 {
+		return q.Usage.StorageBytes / (1024 * 1024)
+	}
+
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		enc.Encode(response) //nolint:errcheck
+	case FORMAT_JSONL:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		for _, q := range response.Quotas {
+			enc.Encode(q) //nolint:errcheck
+		}
+	case FORMAT_JSONL_PRETTY:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		for _, q := range response.Quotas {
+			enc.Encode(q) //nolint:errcheck
+		}
+	case FORMAT_BASIC:
+		formatStr := "%-30s %-10s %-12s %-14s\n"
+		printStdout(cCtx, formatStr, "Description", "Apps", "Containers", "Storage MB")
+		for _, q := range response.Quotas {
+			printStdout(cCtx, formatStr, q.Description, quotaLimit(q.Usage.Apps, q.MaxApps),
+				quotaLimit(q.Usage.Containers, q.MaxContainers), quotaLimit(storageMB(q), q.MaxStorageMB))
+		}
+	case FORMAT_TABLE, "":
+		printStdout(cCtx, "Quotas for user %s\n", response.User)
+		formatStr := "%-30s %-8s %-10s %-12s %-14s %-40s\n"
+		printStdout(cCtx, formatStr, "Description", "Shared", "Apps", "Containers", "Storage MB", "Users")
+		for _, q := range response.Quotas {
+			printStdout(cCtx, formatStr, q.Description, strconv.FormatBool(q.Shared), quotaLimit(q.Usage.Apps, q.MaxApps),
+				quotaLimit(q.Usage.Containers, q.MaxContainers), quotaLimit(storageMB(q), q.MaxStorageMB),
+				strings.Join(q.Users, ","))
+		}
+	case FORMAT_CSV:
+		for _, q := range response.Quotas {
+			printStdout(cCtx, "%s,%t,%d,%d,%d,%d,%d,%d,%s\n", q.Description, q.Shared, q.Usage.Apps, q.MaxApps,
+				q.Usage.Containers, q.MaxContainers, q.Usage.StorageBytes, q.MaxStorageMB, strings.Join(q.Users, " "))
+		}
+	default:
+		panic(fmt.Errorf("unknown format %s", format))
+	}
+}
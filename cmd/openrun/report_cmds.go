@@ -0,0 +1,211 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+const (
+	complianceManifestFile  = "manifest.json"
+	complianceSignatureFile = "manifest.sig"
+)
+
+func initReportCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	return &cli.Command{
+		Name:  "report",
+		Usage: "Create and verify compliance reports",
+		Subcommands: []*cli.Command{
+			reportComplianceCommand(commonFlags, clientConfig),
+			reportVerifyCommand(commonFlags, clientConfig),
+		},
+	}
+}
+
+func reportComplianceCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+2)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("period", "p", "The report period: a quarter (Q1, 2025-Q1), a month (2025-03) or a year (2025)", ""))
+	flags = append(flags, newStringFlag("output", "o", "The archive file to create, defaults to openrun-compliance-<period>.tar.gz", ""))
+
+	return &cli.Command{
+		Name:  "compliance",
+		Usage: "Create a signed archive with the compliance evidence for a period",
+		Flags: flags,
+		UsageText: `The archive contains the http access summary by app and user, the permission approvals, the
+admin mutations, the current app inventory with the approved permissions and the audit log
+verification result. The manifest lists the SHA256 hash of each file, it is signed with the
+server keyring key. A quarter without a year is the latest such quarter which has started.
+
+Examples:
+  Create the report for the first quarter: openrun report compliance --period Q1
+  Create the report for a month:           openrun report compliance --period 2025-03 -o march.tar.gz
+  Verify a report:                         openrun report verify openrun-compliance-2025-Q1.tar.gz`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 0 {
+				return fmt.Errorf("expected no args")
+			}
+			if cCtx.String("period") == "" {
+				return fmt.Errorf("--period is required")
+			}
+
+			values := url.Values{}
+			values.Add("period", cCtx.String("period"))
+			client := newHttpClient(clientConfig)
+			var response types.ComplianceReportResponse
+			if err := client.Get("/_openrun/report/compliance", values, &response); err != nil {
+				return err
+			}
+
+			outputFile := cCtx.String("output")
+			if outputFile == "" {
+				outputFile = response.FileName
+			}
+			if err := os.WriteFile(outputFile, response.Archive, 0600); err != nil {
+				return err
+			}
+			for _, warning := range response.Warnings {
+				fmt.Fprintf(cCtx.App.ErrWriter, "Warning: %s\n", warning) //nolint:errcheck
+			}
+			printStdout(cCtx, "Created compliance report %s for %s to %s, signed with key %s\n",
+				outputFile, response.Start.Format(time.DateOnly), response.End.Format(time.DateOnly), response.KeyId)
+			return nil
+		},
+	}
+}
+
+func reportVerifyCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	return &cli.Command{
+		Name:      "verify",
+		Usage:     "Verify the file hashes and the signature of a compliance report archive",
+		Flags:     commonFlags,
+		ArgsUsage: "<archiveFile>",
+		UsageText: `args: <archiveFile>
+
+The file hashes are checked against the manifest, the manifest signature is checked by the
+server using the keyring keys. The command fails if any file was modified, added or removed.
+
+Examples:
+  Verify a report: openrun report verify openrun-compliance-2025-Q1.tar.gz`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("expected one arg: <archiveFile>")
+			}
+			files, err := readComplianceArchive(cCtx.Args().Get(0))
+			if err != nil {
+				return err
+			}
+			request, err := checkComplianceFiles(files)
+			if err != nil {
+				return err
+			}
+
+			client := newHttpClient(clientConfig)
+			var response types.ComplianceVerifyResponse
+			if err := client.Post("/_openrun/report/compliance/verify", url.Values{}, request, &response); err != nil {
+				return err
+			}
+			if !response.Verified {
+				return fmt.Errorf("compliance report verification failed: %s", response.Error)
+			}
+			printStdout(cCtx, "Compliance report verified, %d files signed with key %s\n", len(files)-2, request.Signature.KeyId)
+			return nil
+		},
+	}
+}
+
+// readComplianceArchive returns the contents of the files in the archive, by file name
+func readComplianceArchive(archiveFile string) (map[string][]byte, error) {
+	f, err := os.Open(archiveFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("error reading archive %s: %w", archiveFile, err)
+	}
+	defer gzr.Close() //nolint:errcheck
+
+	files := map[string][]byte{}
+	tr := tar.NewReader(gzr)
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading archive %s: %w", archiveFile, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[path.Base(header.Name)] = content
+	}
+	return files, nil
+}
+
+// checkComplianceFiles checks the file hashes against the manifest and returns the request to
+// verify the manifest signature
+func checkComplianceFiles(files map[string][]byte) (*types.ComplianceVerifyRequest, error) {
+	request := &types.ComplianceVerifyRequest{Manifest: files[complianceManifestFile]}
+	if request.Manifest == nil {
+		return nil, fmt.Errorf("%s not found in the archive", complianceManifestFile)
+	}
+	sig, ok := files[complianceSignatureFile]
+	if !ok {
+		return nil, fmt.Errorf("%s not found in the archive", complianceSignatureFile)
+	}
+	if err := json.Unmarshal(sig, &request.Signature); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", complianceSignatureFile, err)
+	}
+	var manifest types.ComplianceManifest
+	if err := json.Unmarshal(request.Manifest, &manifest); err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", complianceManifestFile, err)
+	}
+
+	errs := []error{}
+	listed := map[string]bool{complianceManifestFile: true, complianceSignatureFile: true}
+	for _, file := range manifest.Files {
+		listed[file.Name] = true
+		content, ok := files[file.Name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("file %s is missing", file.Name))
+			continue
+		}
+		hash := sha256.Sum256(content)
+		if hex.EncodeToString(hash[:]) != file.Sha256 {
+			errs = append(errs, fmt.Errorf("file %s has been modified", file.Name))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		if !listed[name] {
+			errs = append(errs, fmt.Errorf("file %s is not in the manifest", name))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return request, nil
+}
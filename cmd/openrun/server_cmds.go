@@ -73,6 +73,23 @@ func getServerCommands(serverConfig *types.ServerConfig, clientConfig *types.Cli
 						return updateConfig(cCtx, clientConfig)
 					},
 				},
+				{
+					Name:  "prune-images",
+					Usage: "Remove the older app container images, keeping the recent versions of each app",
+					Flags: []cli.Flag{
+						newIntFlag("keep", "k", "The number of image versions to keep per app, defaults to the builder keep_versions config", 0),
+						dryRunFlag(),
+					},
+					UsageText: `The OpenRun generated images of each app are listed, the most recent versions are kept and the
+	older ones are removed. Images used by a container, running or stopped, are not removed. Dev mode
+	images are not pruned. Images pushed to a remote registry are not affected.
+
+	Examples:
+		openrun server prune-images --keep 2 --dry-run`,
+					Action: func(cCtx *cli.Context) error {
+						return pruneImages(cCtx, clientConfig)
+					},
+				},
 			},
 		},
 	}, nil
@@ -276,3 +293,36 @@ func updateConfig(cCtx *cli.Context, clientConfig *types.ClientConfig) error {
 	fmt.Printf("%s\n", string(json))
 	return nil
 }
+
+func pruneImages(cCtx *cli.Context, clientConfig *types.ClientConfig) error {
+	client := newHttpClient(clientConfig)
+
+	values := url.Values{}
+	values.Add("keep", strconv.Itoa(cCtx.Int("keep")))
+	values.Add(DRY_RUN_ARG, strconv.FormatBool(cCtx.Bool(DRY_RUN_FLAG)))
+
+	var response types.ImagePruneResponse
+	err := client.Post("/_openrun/image_prune", values, nil, &response)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, image := range response.Removed {
+		switch {
+		case image.Error != "":
+			failed++
+			fmt.Printf("Error removing %s: %s\n", image.Name, image.Error)
+		case response.DryRun:
+			fmt.Printf("Would remove %s (created %s)\n", image.Name, image.Created.Format("2006-01-02 15:04:05"))
+		default:
+			fmt.Printf("Removed %s (created %s)\n", image.Name, image.Created.Format("2006-01-02 15:04:05"))
+		}
+	}
+	fmt.Printf("%d image(s) removed, %d kept (last %d versions per app and images in use)\n",
+		len(response.Removed)-failed, len(response.Kept), response.Keep)
+	if response.DryRun {
+		fmt.Print(DRY_RUN_MESSAGE)
+	}
+	return nil
+}
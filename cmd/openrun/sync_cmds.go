@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/openrundev/openrun/internal/types"
 	"github.com/urfave/cli/v2"
@@ -20,26 +21,112 @@ func initSyncCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *
 		Usage: "Manage sync operations, scheduled and webhook",
 		Subcommands: []*cli.Command{
 			syncScheduleCommand(commonFlags, clientConfig),
+			syncWebhookCommand(commonFlags, clientConfig),
+			syncWebhookSecretCommand(commonFlags, clientConfig),
 			syncRunCommand(commonFlags, clientConfig),
 			syncListCommand(commonFlags, clientConfig),
+			syncHistoryCommand(commonFlags, clientConfig),
 			syncDeleteCommand(commonFlags, clientConfig),
 		},
 	}
 }
 
-func syncScheduleCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
-	flags := make([]cli.Flag, 0, len(commonFlags)+2)
+// syncCreateFlags returns the flags for creating a sync entry, the scheduled sync adds the schedule flags
+func syncCreateFlags(commonFlags []cli.Flag, scheduled bool) []cli.Flag {
+	flags := make([]cli.Flag, 0, len(commonFlags)+15)
 	flags = append(flags, commonFlags...)
 	flags = append(flags, newStringFlag("branch", "b", "The branch to checkout if using git source", "main"))
 	flags = append(flags, newStringFlag("git-auth", "g", "The name of the git_auth entry in server config to use", ""))
 	flags = append(flags, newBoolFlag("approve", "a", "Approve the app permissions", false))
 	flags = append(flags, newStringFlag("reload", "r", "Which apps to reload: none, updated, matched", ""))
 	flags = append(flags, newBoolFlag("promote", "p", "Promote changes from stage to prod", false))
+	flags = append(flags, promoteConfirmFlag())
 	flags = append(flags, newBoolFlag("verify", "", "Verify reload by reloading app containers", false))
-	flags = append(flags, newIntFlag("minutes", "s", "Schedule sync for every N minutes", 0))
+	if scheduled {
+		flags = append(flags, newIntFlag("minutes", "s", "Schedule sync for every N minutes", 0))
+		flags = append(flags, newStringFlag("cron", "", "Schedule sync using a cron expression, like \"0 2 * * *\" for 2AM daily", ""))
+		flags = append(flags, newStringFlag("timezone", "", "The timezone for the cron expression, like America/New_York. Defaults to the server local time", ""))
+	} else {
+		flags = append(flags, newBoolFlag("pr-preview", "", "Create preview apps for the pull requests, deleted when the pull request is closed", false))
+		flags = append(flags, newIntFlag("pr-preview-ttl", "", "Hours after the last update the preview apps are deleted. Defaults to system.pr_preview_ttl_hours", 0))
+		flags = append(flags, newStringFlag("pr-preview-auth", "", "The auth type for the preview apps. Defaults to system.pr_preview_auth, the main app auth if not set", ""))
+	}
 	flags = append(flags, newBoolFlag("clobber", "", "Force update app config, overwriting non-declarative changes", false))
 	flags = append(flags, newBoolFlag("force-reload", "f", "Force reload even if there are no new commits", false))
+	flags = append(flags, newBoolFlag("commit-status", "", "Post the sync result as a status on the GitHub/GitLab commit, using the api_token from the git_auth entry", false))
+	flags = append(flags,
+		&cli.StringSliceFlag{
+			Name:  "notify",
+			Usage: "Send the sync results to the notify entry from the server config. Can be repeated",
+		})
 	flags = append(flags, dryRunFlag())
+	return flags
+}
+
+// createSync creates the sync entry using the flags from syncCreateFlags
+func createSync(cCtx *cli.Context, clientConfig *types.ClientConfig, scheduled bool) (*types.SyncCreateResponse, error) {
+	reloadMode := types.AppReloadOption(cmp.Or(cCtx.String("reload"), string(types.AppReloadOptionMatched)))
+	values := url.Values{}
+
+	sourceUrl, err := makeAbsolute(cCtx.Args().Get(0))
+	if err != nil {
+		return nil, err
+	}
+
+	values.Add("path", sourceUrl)
+	values.Add(DRY_RUN_ARG, strconv.FormatBool(cCtx.Bool(DRY_RUN_FLAG)))
+	values.Add("scheduled", strconv.FormatBool(scheduled))
+
+	sync := types.SyncMetadata{
+		GitBranch:    cCtx.String("branch"),
+		GitAuth:      cCtx.String("git-auth"),
+		Promote:      cCtx.Bool("promote"),
+		Confirm:      cCtx.Bool(CONFIRM_FLAG),
+		Approve:      cCtx.Bool("approve"),
+		Verify:       cCtx.Bool("verify"),
+		Reload:       string(reloadMode),
+		Clobber:      cCtx.Bool("clobber"),
+		ForceReload:  cCtx.Bool("force-reload"),
+		CommitStatus: cCtx.Bool("commit-status"),
+		Notify:       cCtx.StringSlice("notify"),
+	}
+	if scheduled {
+		sync.ScheduleFrequency = cCtx.Int("minutes")
+		sync.ScheduleCron = cCtx.String("cron")
+		sync.ScheduleTimezone = cCtx.String("timezone")
+		if sync.ScheduleFrequency > 0 && sync.ScheduleCron != "" {
+			return nil, fmt.Errorf("only one of --minutes and --cron can be specified")
+		}
+	} else {
+		sync.PrPreview = cCtx.Bool("pr-preview")
+		sync.PrPreviewTTLHours = cCtx.Int("pr-preview-ttl")
+		sync.PrPreviewAuth = cCtx.String("pr-preview-auth")
+		if !sync.PrPreview && (sync.PrPreviewTTLHours != 0 || sync.PrPreviewAuth != "") {
+			return nil, fmt.Errorf("--pr-preview-ttl and --pr-preview-auth require --pr-preview")
+		}
+	}
+
+	client := newHttpClient(clientConfig)
+	var syncResponse types.SyncCreateResponse
+	err = client.Post("/_openrun/sync", values, sync, &syncResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	if syncResponse.SyncJobStatus.Error != "" {
+		return nil, fmt.Errorf("error creating sync job: %s", syncResponse.SyncJobStatus.Error)
+	}
+
+	printApplyResponse(cCtx, &syncResponse.SyncJobStatus.ApplyResponse)
+	fmt.Printf("\nSync job created with Id: %s\n", syncResponse.Id)
+	if syncResponse.NextRunTime != nil {
+		fmt.Printf("Next run at: %s\n", syncResponse.NextRunTime.Format(time.RFC3339))
+	}
+	return &syncResponse, nil
+}
+
+func syncScheduleCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := syncCreateFlags(commonFlags, true)
 
 	return &cli.Command{
 		Name:      "schedule",
@@ -56,54 +143,98 @@ Examples:
   Create scheduled sync, promoting changes: openrun sync schedule --promote --approve github.com/openrundev/apps/apps.ace
   Create scheduled sync, verifying reload before promoting changes: openrun sync schedule --verify --promote --approve github.com/openrundev/apps/apps.ace
   Create scheduled sync, overwriting changes: openrun sync schedule --promote --clobber github.com/openrundev/apps/apps.ace
+  Create scheduled sync, running nightly at 2AM: openrun sync schedule --cron "0 2 * * *" --timezone America/New_York github.com/openrundev/apps/apps.ace
+  Create scheduled sync, reporting results on the commits: openrun sync schedule --commit-status --git-auth mypat github.com/myorg/apps/apps.ace
 `,
 		Action: func(cCtx *cli.Context) error {
 			if cCtx.NArg() != 1 {
 				return fmt.Errorf("expected one arg : <filePath>")
 			}
 
-			reloadMode := types.AppReloadOption(cmp.Or(cCtx.String("reload"), string(types.AppReloadOptionMatched)))
-			values := url.Values{}
-
-			sourceUrl, err := makeAbsolute(cCtx.Args().Get(0))
+			syncResponse, err := createSync(cCtx, clientConfig, true)
 			if err != nil {
 				return err
 			}
+			if syncResponse.DryRun {
+				fmt.Print(DRY_RUN_MESSAGE)
+			}
 
-			values.Add("path", sourceUrl)
-			values.Add(DRY_RUN_ARG, strconv.FormatBool(cCtx.Bool(DRY_RUN_FLAG)))
-			values.Add("scheduled", "true")
-
-			sync := types.SyncMetadata{
-				GitBranch:         cCtx.String("branch"),
-				GitAuth:           cCtx.String("git-auth"),
-				Promote:           cCtx.Bool("promote"),
-				Approve:           cCtx.Bool("approve"),
-				Verify:            cCtx.Bool("verify"),
-				Reload:            string(reloadMode),
-				Clobber:           cCtx.Bool("clobber"),
-				ForceReload:       cCtx.Bool("force-reload"),
-				ScheduleFrequency: cCtx.Int("minutes"),
+			return nil
+		},
+	}
+}
+
+func syncWebhookCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	return &cli.Command{
+		Name:      "webhook",
+		Usage:     "Create webhook sync job for updating app config on a git push",
+		Flags:     syncCreateFlags(commonFlags, false),
+		ArgsUsage: "<filePath>",
+		UsageText: `args: <filePath>
+
+<filePath> is the path to the apply file containing the app configuration.
+
+The webhook url and secret are printed, configure them as a push webhook in GitHub, GitLab or Bitbucket
+with the JSON content type. A push to the sync branch runs the sync job in the background.
+
+With --pr-preview, pull request events create preview apps like /myapp_cl_pr_12 for the apps from the same
+repo, using the pull request branch. The previews are updated on new pushes and deleted when the pull request is
+closed. Enable the pull request events for the webhook in the git provider. Pull requests from forks are ignored.
+
+Examples:
+  Create webhook sync, promoting changes: openrun sync webhook --promote --approve github.com/myorg/apps/apps.ace
+  Create webhook sync with previews: openrun sync webhook --pr-preview --pr-preview-auth system github.com/myorg/apps/apps.ace
+  Create webhook sync for a branch: openrun sync webhook --branch release github.com/myorg/apps/apps.ace
+`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("expected one arg : <filePath>")
 			}
 
-			client := newHttpClient(clientConfig)
-			var syncResponse types.SyncCreateResponse
-			err = client.Post("/_openrun/sync", values, sync, &syncResponse)
+			syncResponse, err := createSync(cCtx, clientConfig, false)
 			if err != nil {
 				return err
 			}
-
-			if syncResponse.SyncJobStatus.Error != "" {
-				return fmt.Errorf("error creating sync job: %s", syncResponse.SyncJobStatus.Error)
+			fmt.Printf("Webhook url: %s\n", syncResponse.WebhookUrl)
+			fmt.Printf("Webhook secret: %s\n", syncResponse.WebhookSecret)
+			if syncResponse.DryRun {
+				fmt.Print(DRY_RUN_MESSAGE)
 			}
 
-			printApplyResponse(cCtx, &syncResponse.SyncJobStatus.ApplyResponse)
+			return nil
+		},
+	}
+}
+
+func syncWebhookSecretCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	return &cli.Command{
+		Name:      "webhook-secret",
+		Usage:     "Show the webhook secret for specified webhook sync job",
+		Flags:     commonFlags,
+		ArgsUsage: "args: <syncId>",
+		UsageText: `args: <syncId>
 
-			fmt.Printf("\nSync job created with Id: %s\n", syncResponse.Id)
-			if syncResponse.DryRun {
-				fmt.Print(DRY_RUN_MESSAGE)
+The secret is derived from the active keyring key. After "openrun keyring rotate", update the webhook
+secret in the git provider before removing the retired key.
+
+	Examples:
+	  Show webhook secret: openrun sync webhook-secret cl_sync_44asd232`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("expected one args: <syncId>")
 			}
 
+			client := newHttpClient(clientConfig)
+			values := url.Values{}
+			values.Add("id", cCtx.Args().First())
+
+			var response types.SyncWebhookSecretResponse
+			if err := client.Get("/_openrun/sync/webhook_secret", values, &response); err != nil {
+				return err
+			}
+			printStdout(cCtx, "Webhook url: %s\n", response.WebhookUrl)
+			printStdout(cCtx, "Webhook secret: %s\n", response.WebhookSecret)
+			printStdout(cCtx, "Keyring key: %s\n", response.KeyId)
 			return nil
 		},
 	}
@@ -187,6 +318,48 @@ func syncRunCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *c
 	}
 }
 
+func syncHistoryCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+3)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table, basic, csv, json, jsonl and jsonl_pretty", ""))
+	flags = append(flags, newIntFlag("limit", "l", "The maximum number of runs to list, most recent first", 20))
+	flags = append(flags, newIntFlag("offset", "", "The number of runs to skip", 0))
+
+	return &cli.Command{
+		Name:      "history",
+		Usage:     "List the runs of specified sync job, with the apps changed by each run",
+		Flags:     flags,
+		ArgsUsage: "args: <syncId>",
+		UsageText: `
+	Examples:
+	  List the latest runs: openrun sync history cl_sync_44asd232
+	  List the next page of runs: openrun sync history --offset 20 cl_sync_44asd232`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 1 {
+				return fmt.Errorf("expected one args: <syncId>")
+			}
+
+			client := newHttpClient(clientConfig)
+			values := url.Values{}
+			values.Add("id", cCtx.Args().First())
+			values.Add("limit", strconv.Itoa(cCtx.Int("limit")))
+			values.Add("offset", strconv.Itoa(cCtx.Int("offset")))
+
+			var response types.SyncHistoryResponse
+			if err := client.Get("/_openrun/sync/history", values, &response); err != nil {
+				return err
+			}
+
+			printSyncHistory(cCtx, response.Runs, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			if response.Offset+len(response.Runs) < response.Total {
+				fmt.Fprintf(cCtx.App.ErrWriter, "Listed %d of %d runs, use --offset %d for more\n", //nolint:errcheck
+					len(response.Runs), response.Total, response.Offset+len(response.Runs))
+			}
+			return nil
+		},
+	}
+}
+
 func syncDeleteCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
 	flags := make([]cli.Flag, 0, len(commonFlags)+2)
 	flags = append(flags, commonFlags...)
@@ -246,24 +419,24 @@ func printSyncList(cCtx *cli.Context, sync []*types.SyncEntry, format string) {
 			enc.Encode(s) //nolint:errcheck
 		}
 	case FORMAT_BASIC:
-		formatStr := "%-35s %-9s %-12s %-s\n"
-		printStdout(cCtx, formatStr, "Id", "State", "SyncType", "Path")
+		formatStr := "%-35s %-9s %-16s %-25s %-s\n"
+		printStdout(cCtx, formatStr, "Id", "State", "SyncType", "NextRun", "Path")
 
 		for _, s := range sync {
-			printStdout(cCtx, formatStr, s.Id, s.Status.State, getSyncType(s), s.Path)
+			printStdout(cCtx, formatStr, s.Id, s.Status.State, getSyncType(s), getSyncNextRun(s), s.Path)
 		}
 	case FORMAT_TABLE:
-		formatStrHead := "%-35s %-9s %-12s %-8s %-8s %-7s %-7s %-7s %-10s %-15s %-60s %-s\n"
-		formatStrData := "%-35s %-9s %-12s %-8s %-8t %-7t %-7t %-7t %-10s %-15s %-60s %-s\n"
-		printStdout(cCtx, formatStrHead, "Id", "State", "SyncType", "Reload", "Promote", "Approve", "Verify", "Clobber", "GitAuth", "Branch", "Path", "Error")
+		formatStrHead := "%-35s %-9s %-16s %-25s %-8s %-8s %-7s %-7s %-7s %-10s %-15s %-60s %-s\n"
+		formatStrData := "%-35s %-9s %-16s %-25s %-8s %-8t %-7t %-7t %-7t %-10s %-15s %-60s %-s\n"
+		printStdout(cCtx, formatStrHead, "Id", "State", "SyncType", "NextRun", "Reload", "Promote", "Approve", "Verify", "Clobber", "GitAuth", "Branch", "Path", "Error")
 
 		for _, s := range sync {
-			printStdout(cCtx, formatStrData, s.Id, s.Status.State, getSyncType(s), s.Metadata.Reload, s.Metadata.Promote,
+			printStdout(cCtx, formatStrData, s.Id, s.Status.State, getSyncType(s), getSyncNextRun(s), s.Metadata.Reload, s.Metadata.Promote,
 				s.Metadata.Approve, s.Metadata.Verify, s.Metadata.Clobber, s.Metadata.GitAuth, s.Metadata.GitBranch, s.Path, s.Status.Error)
 		}
 	case FORMAT_CSV:
 		for _, s := range sync {
-			printStdout(cCtx, "%s,%s,%s,%s,%t,%t,%t,%t,%s,%s,%s,%s,%s\n", s.Id, s.Status.State, getSyncType(s), s.Metadata.Reload, s.Metadata.Promote, s.Metadata.Approve, s.Metadata.Verify, s.Metadata.Clobber,
+			printStdout(cCtx, "%s,%s,%s,%s,%s,%t,%t,%t,%t,%s,%s,%s,%s,%s\n", s.Id, s.Status.State, getSyncType(s), getSyncNextRun(s), s.Metadata.Reload, s.Metadata.Promote, s.Metadata.Approve, s.Metadata.Verify, s.Metadata.Clobber,
 				s.Metadata.GitAuth, s.Metadata.GitBranch, s.Path, s.Metadata.WebhookUrl, s.Status.Error)
 		}
 	default:
@@ -271,9 +444,101 @@ func printSyncList(cCtx *cli.Context, sync []*types.SyncEntry, format string) {
 	}
 }
 
+func printSyncHistory(cCtx *cli.Context, runs []*types.SyncRun, format string) {
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		enc.Encode(runs) //nolint:errcheck
+	case FORMAT_JSONL:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		for _, r := range runs {
+			enc.Encode(r) //nolint:errcheck
+		}
+	case FORMAT_JSONL_PRETTY:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		for _, r := range runs {
+			enc.Encode(r) //nolint:errcheck
+		}
+	case FORMAT_BASIC:
+		formatStr := "%-35s %-25s %-10s %-12s %-s\n"
+		printStdout(cCtx, formatStr, "Id", "StartTime", "Duration", "Commit", "Changes")
+
+		for _, r := range runs {
+			printStdout(cCtx, formatStr, r.Id, r.StartTime.Format(time.RFC3339), getSyncRunDuration(r), getSyncRunCommit(r), getSyncRunChanges(r))
+		}
+	case FORMAT_TABLE:
+		formatStr := "%-35s %-25s %-10s %-12s %-40s %-s\n"
+		printStdout(cCtx, formatStr, "Id", "StartTime", "Duration", "Commit", "Changes", "Error")
+
+		for _, r := range runs {
+			printStdout(cCtx, formatStr, r.Id, r.StartTime.Format(time.RFC3339), getSyncRunDuration(r), getSyncRunCommit(r), getSyncRunChanges(r), r.Error)
+			for _, line := range getSyncRunApps(r) {
+				printStdout(cCtx, "    %s\n", line)
+			}
+		}
+	case FORMAT_CSV:
+		for _, r := range runs {
+			printStdout(cCtx, "%s,%s,%d,%s,%d,%d,%d,%d,%d,%d,%t,%s\n", r.Id, r.StartTime.Format(time.RFC3339), r.DurationMs, r.CommitId,
+				len(r.Changes.Created), len(r.Changes.Updated), len(r.Changes.Reloaded), len(r.Changes.Approved), len(r.Changes.Promoted),
+				len(r.Changes.Skipped), r.Changes.SkippedApply, r.Error)
+		}
+	default:
+		panic(fmt.Errorf("unknown format %s", format))
+	}
+}
+
+func getSyncRunDuration(run *types.SyncRun) string {
+	return (time.Duration(run.DurationMs) * time.Millisecond).String()
+}
+
+func getSyncRunCommit(run *types.SyncRun) string {
+	if run.CommitId == "" {
+		return "-"
+	}
+	return run.CommitId[:min(len(run.CommitId), 12)]
+}
+
+// getSyncRunChanges returns the count of the apps changed by a sync run
+func getSyncRunChanges(run *types.SyncRun) string {
+	if run.Changes.SkippedApply && len(run.Changes.Reloaded) == 0 {
+		return "no new commit"
+	}
+	return fmt.Sprintf("%d created, %d updated, %d reloaded, %d promoted", len(run.Changes.Created), len(run.Changes.Updated),
+		len(run.Changes.Reloaded), len(run.Changes.Promoted))
+}
+
+// getSyncRunApps returns the apps changed by a sync run, one line per change
+func getSyncRunApps(run *types.SyncRun) []string {
+	ret := []string{}
+	add := func(change string, apps []types.AppPathDomain) {
+		for _, app := range apps {
+			ret = append(ret, change+" "+app.String())
+		}
+	}
+	add("created ", run.Changes.Created)
+	add("updated ", run.Changes.Updated)
+	add("reloaded", run.Changes.Reloaded)
+	add("approved", run.Changes.Approved)
+	add("promoted", run.Changes.Promoted)
+	add("skipped ", run.Changes.Skipped)
+	return ret
+}
+
 func getSyncType(sync *types.SyncEntry) string {
+	if sync.Metadata.ScheduleCron != "" {
+		return sync.Metadata.ScheduleCron
+	}
 	if sync.Metadata.ScheduleFrequency > 0 {
 		return fmt.Sprintf("%d (mins)", sync.Metadata.ScheduleFrequency)
 	}
 	return "Webhook"
 }
+
+func getSyncNextRun(sync *types.SyncEntry) string {
+	if sync.NextRunTime == nil {
+		return "-"
+	}
+	return sync.NextRunTime.Format(time.RFC3339)
+}
@@ -0,0 +1,100 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+func initTenantCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	return &cli.Command{
+		Name:  "tenant",
+		Usage: "View tenants, configured in the rbac.tenants dynamic config",
+		Subcommands: []*cli.Command{
+			tenantListCommand(commonFlags, clientConfig),
+		},
+	}
+}
+
+func tenantListCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+1)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table, basic, csv, json, jsonl and jsonl_pretty", ""))
+
+	return &cli.Command{
+		Name:  "list",
+		Usage: "List tenants with their namespaces and app counts",
+		Flags: flags,
+		UsageText: `Examples:
+  List tenants: openrun tenant list`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 0 {
+				return fmt.Errorf("expected no args")
+			}
+
+			client := newHttpClient(clientConfig)
+			var response []types.TenantInfo
+			if err := client.Get("/_openrun/tenants", nil, &response); err != nil {
+				return err
+			}
+
+			printTenantList(cCtx, response, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			return nil
+		},
+	}
+}
+
+func tenantAppLimit(tenant types.TenantInfo) string {
+	if tenant.MaxApps == 0 {
+		return strconv.Itoa(tenant.AppCount)
+	}
+	return fmt.Sprintf("%d/%d", tenant.AppCount, tenant.MaxApps)
+}
+
+func printTenantList(cCtx *cli.Context, tenants []types.TenantInfo, format string) {
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		enc.Encode(tenants) //nolint:errcheck
+	case FORMAT_JSONL:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		for _, t := range tenants {
+			enc.Encode(t) //nolint:errcheck
+		}
+	case FORMAT_JSONL_PRETTY:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		for _, t := range tenants {
+			enc.Encode(t) //nolint:errcheck
+		}
+	case FORMAT_BASIC:
+		formatStr := "%-20s %-10s\n"
+		printStdout(cCtx, formatStr, "Name", "Apps")
+		for _, t := range tenants {
+			printStdout(cCtx, formatStr, t.Name, tenantAppLimit(t))
+		}
+	case FORMAT_TABLE, "":
+		formatStr := "%-20s %-10s %-30s %-30s %-30s\n"
+		printStdout(cCtx, formatStr, "Name", "Apps", "Domains", "Paths", "Admins")
+		for _, t := range tenants {
+			printStdout(cCtx, formatStr, t.Name, tenantAppLimit(t), strings.Join(t.Domains, ","),
+				strings.Join(t.Paths, ","), strings.Join(t.Admins, ","))
+		}
+	case FORMAT_CSV:
+		for _, t := range tenants {
+			printStdout(cCtx, "%s,%d,%d,%s,%s,%s\n", t.Name, t.AppCount, t.MaxApps, strings.Join(t.Domains, " "),
+				strings.Join(t.Paths, " "), strings.Join(t.Admins, " "))
+		}
+	default:
+		panic(fmt.Errorf("unknown format %s", format))
+	}
+}
@@ -0,0 +1,243 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package main
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/urfave/cli/v2"
+)
+
+func initVolumeCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	return &cli.Command{
+		Name:  "volume",
+		Usage: "Manage the container volumes created for apps",
+		Subcommands: []*cli.Command{
+			volumeListCommand(commonFlags, clientConfig),
+			volumeBackupCommand(commonFlags, clientConfig),
+			volumeRestoreCommand(commonFlags, clientConfig),
+			volumeGCCommand(commonFlags, clientConfig),
+		},
+	}
+}
+
+func volumeListCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+1)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("format", "f", "The display format. Valid options are table, basic, csv, json, jsonl and jsonl_pretty", ""))
+
+	return &cli.Command{
+		Name:      "list",
+		Usage:     "List the app volumes",
+		Flags:     flags,
+		ArgsUsage: "[<appPath>]",
+		UsageText: `args: [<appPath>]
+
+	<appPath> is an optional argument, the volumes of the app and its stage and preview apps are listed.
+	The dir is the volume name or the mount path from the app config, shown if the app is loaded.
+	Volumes whose app was deleted are marked as orphaned, use "openrun volume gc" to remove them.
+
+	Examples:
+		List all volumes: openrun volume list
+		List app volumes: openrun volume list /myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() > 1 {
+				return fmt.Errorf("expected at most one arg: [<appPath>]")
+			}
+
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().First())
+
+			client := newHttpClient(clientConfig)
+			var response types.VolumeListResponse
+			if err := client.Get("/_openrun/volumes", values, &response); err != nil {
+				return err
+			}
+
+			printVolumes(cCtx, response.Volumes, cmp.Or(cCtx.String("format"), clientConfig.Client.DefaultFormat))
+			return nil
+		},
+	}
+}
+
+func printVolumes(cCtx *cli.Context, volumes []types.AppVolume, format string) {
+	switch format {
+	case FORMAT_JSON:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		enc.Encode(volumes) //nolint:errcheck
+	case FORMAT_JSONL:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		for _, v := range volumes {
+			enc.Encode(v) //nolint:errcheck
+		}
+	case FORMAT_JSONL_PRETTY:
+		enc := json.NewEncoder(cCtx.App.Writer)
+		enc.SetIndent("", "  ")
+		for _, v := range volumes {
+			enc.Encode(v) //nolint:errcheck
+		}
+	case FORMAT_BASIC:
+		formatStr := "%-30s %-20s %s\n"
+		printStdout(cCtx, formatStr, "App", "Dir", "Volume")
+		for _, v := range volumes {
+			printStdout(cCtx, formatStr, v.AppPath, v.Dir, v.Name)
+		}
+	case FORMAT_TABLE, "":
+		formatStr := "%-30s %-20s %-7s %-9s %s\n"
+		printStdout(cCtx, formatStr, "App", "Dir", "InUse", "Orphaned", "Volume")
+		for _, v := range volumes {
+			printStdout(cCtx, formatStr, v.AppPath, v.Dir, strconv.FormatBool(v.InUse), strconv.FormatBool(v.Orphaned), v.Name)
+		}
+	case FORMAT_CSV:
+		for _, v := range volumes {
+			printStdout(cCtx, "%s,%s,%s,%s,%t,%t\n", v.Name, v.AppId, v.AppPath, v.Dir, v.InUse, v.Orphaned)
+		}
+	default:
+		panic(fmt.Errorf("unknown format %s", format))
+	}
+}
+
+func volumeBackupCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+1)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("target", "t", "The s3://bucket/prefix url to upload the backup to. Defaults to system.volume_backup_dir on the server", ""))
+
+	return &cli.Command{
+		Name:      "backup",
+		Usage:     "Backup an app volume to a tar file",
+		Flags:     flags,
+		ArgsUsage: "<appPath> <volume>",
+		UsageText: `args: <appPath> <volume>
+
+	<appPath> and <volume> are required arguments. The volume is the dir from "openrun volume list", which
+	is the volume name or the mount path from the app config, or the full volume name. The backup is a tar
+	file saved in the system.volume_backup_dir on the server, or uploaded to the s3 target. The s3 url
+	supports the region and endpoint query params. The volume is read while the app is running, pause the
+	app for a consistent backup of volumes which are being written to.
+
+	Examples:
+		Backup to the server backup dir: openrun volume backup /myapp data
+		Backup to S3:                    openrun volume backup --target s3://mybucket/backups /myapp data`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 2 {
+				return fmt.Errorf("requires two arguments: <appPath> <volume>")
+			}
+
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().Get(0))
+			values.Add("volume", cCtx.Args().Get(1))
+			values.Add("target", cCtx.String("target"))
+
+			client := newHttpClient(clientConfig)
+			var response types.VolumeBackupResponse
+			if err := client.Post("/_openrun/volume_backup", values, nil, &response); err != nil {
+				return err
+			}
+			fmt.Printf("Backed up volume %s to %s (%d bytes)\n", response.Volume, response.Location, response.Size)
+			return nil
+		},
+	}
+}
+
+func volumeRestoreCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+1)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, newStringFlag("source", "s", "The backup file name in system.volume_backup_dir or the s3:// url of the backup", ""))
+
+	return &cli.Command{
+		Name:      "restore",
+		Usage:     "Restore an app volume from a backup",
+		Flags:     flags,
+		ArgsUsage: "<appPath> <volume>",
+		UsageText: `args: <appPath> <volume>
+
+	<appPath> and <volume> are required arguments, --source is required. The files in the backup overwrite
+	the files in the volume, the volume is created if it does not exist. The volume should not be used by a
+	running container, pause the app before the restore and resume it after.
+
+	Examples:
+		openrun app pause /myapp
+		openrun volume restore --source clv-app_prd_xyz-abc-20260101-120000.tar /myapp data
+		openrun app pause --undo /myapp`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 2 {
+				return fmt.Errorf("requires two arguments: <appPath> <volume>")
+			}
+			if cCtx.String("source") == "" {
+				return fmt.Errorf("--source is required")
+			}
+
+			values := url.Values{}
+			values.Add("appPath", cCtx.Args().Get(0))
+			values.Add("volume", cCtx.Args().Get(1))
+			values.Add("source", cCtx.String("source"))
+
+			client := newHttpClient(clientConfig)
+			var response types.VolumeRestoreResponse
+			if err := client.Post("/_openrun/volume_restore", values, nil, &response); err != nil {
+				return err
+			}
+			fmt.Printf("Restored volume %s from %s\n", response.Volume, response.Source)
+			return nil
+		},
+	}
+}
+
+func volumeGCCommand(commonFlags []cli.Flag, clientConfig *types.ClientConfig) *cli.Command {
+	flags := make([]cli.Flag, 0, len(commonFlags)+1)
+	flags = append(flags, commonFlags...)
+	flags = append(flags, dryRunFlag())
+
+	return &cli.Command{
+		Name:  "gc",
+		Usage: "Remove the volumes of deleted apps",
+		Flags: flags,
+		UsageText: `The volumes whose apps were deleted are removed. The volumes of archived apps are retained.
+	Volumes used by a container are not removed.
+
+	Examples:
+		openrun volume gc --dry-run
+		openrun volume gc`,
+		Action: func(cCtx *cli.Context) error {
+			if cCtx.NArg() != 0 {
+				return fmt.Errorf("expected no args")
+			}
+
+			values := url.Values{}
+			values.Add(DRY_RUN_ARG, strconv.FormatBool(cCtx.Bool(DRY_RUN_FLAG)))
+
+			client := newHttpClient(clientConfig)
+			var response types.VolumeGCResponse
+			if err := client.Post("/_openrun/volume_gc", values, nil, &response); err != nil {
+				return err
+			}
+
+			for _, v := range response.Removed {
+				if response.DryRun {
+					fmt.Printf("Would remove %s (app %s)\n", v.Name, v.AppId)
+				} else {
+					fmt.Printf("Removed %s (app %s)\n", v.Name, v.AppId)
+				}
+			}
+			for _, v := range response.Kept {
+				if v.Error != "" {
+					fmt.Printf("Error removing %s: %s\n", v.Name, v.Error)
+				} else {
+					fmt.Printf("Kept %s (app %s), in use by a container\n", v.Name, v.AppId)
+				}
+			}
+			fmt.Printf("%d volume(s) removed, %d kept\n", len(response.Removed), len(response.Kept))
+			if response.DryRun {
+				fmt.Print(DRY_RUN_MESSAGE)
+			}
+			return nil
+		},
+	}
+}
@@ -111,7 +111,7 @@ func webhookCreateCommand(commonFlags []cli.Flag, clientConfig *types.ClientConf
 		ArgsUsage: "<webhookType> <appPath>",
 		UsageText: `args: <webhookType> appPath>
 
-    <webhookType> is the required first argument. Supported types are: reload, reload_promote and promote.
+    <webhookType> is the required first argument. Supported types are: reload, reload_promote, promote, registry and registry_promote.
     <app_path> is the required second argument. The optional domain and path are separated by a ":". This is the app for which webhooks are created.
 
 	Examples:
@@ -157,7 +157,7 @@ func webhookDeleteCommand(commonFlags []cli.Flag, clientConfig *types.ClientConf
 		ArgsUsage: "<webhookType> <appPath>",
 		UsageText: `args: <webhookType> appPath>
 
-    <webhookType> is the required first argument. Supported types are: reload, reload_promote and promote.
+    <webhookType> is the required first argument. Supported types are: reload, reload_promote, promote, registry and registry_promote.
     <app_path> is the required second argument. The optional domain and path are separated by a ":". This is the app for which webhooks are deleted.
 
 	Examples:
@@ -405,6 +405,10 @@ func (a *Action) execAction(w http.ResponseWriter, r *http.Request, isSuggest, i
 					http.Error(w, err.Error(), http.StatusBadRequest)
 					return
 				}
+				if err := param.Validate(formValue); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
 				args[param.Name] = newVal
 
 				if param.DisplayType != apptype.DisplayTypePassword {
@@ -875,6 +879,10 @@ func (a *Action) getForm(w http.ResponseWriter, r *http.Request) {
 			param.InputType = "select"
 			param.Options = options[p.Name]
 			param.Value = value
+		} else if p.Type == apptype.ENUM {
+			param.InputType = "select"
+			param.Options = p.Values
+			param.Value = value
 		}
 
 		if p.DisplayType != "" {
@@ -14,18 +14,19 @@ import (
 	"io"
 	"io/fs"
 	"maps"
+	"math"
 	"net"
 	"net/http"
 	"os"
 	"path"
 	"path/filepath"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
-	"github.com/BurntSushi/toml"
 	"github.com/bmatcuk/doublestar/v4"
 	"github.com/fsnotify/fsnotify"
 	"github.com/go-chi/chi/v5"
@@ -70,23 +71,32 @@ type App struct {
 	paramInfo        map[string]apptype.AppParam
 	paramValuesStr   map[string]string   // the param values for the app, from metadata and defaults
 	paramDict        starlark.StringDict // the Starlark param values for the app
+	appEnv           map[string]string   // the env values declared by the app, passed to handlers and the container
 	plugins          *AppPlugins
 	containerHandler *ContainerHandler
 	serverConfig     *types.ServerConfig
 
-	globals      starlark.StringDict    // global variables defined in starlark code
-	appDef       *starlarkstruct.Struct // app starlark definition
-	errorHandler starlark.Callable      // error handler function
-	appRouter    *chi.Mux               // router for the app
-	actions      []*action.Action       // actions defined for the app
-
-	usesHtmlTemplate bool                          // Whether the app uses HTML templates, false if only JSON APIs
-	template         *template.Template            // unstructured templates, no base_templates defined
-	templateMap      map[string]*template.Template // structured templates, base_templates defined
-	templateBase     *template.Template            // the base templates alone, for routes/blocks naming a base define instead of a file
-	staticOnly       bool                          // app has only static files, no HTML routes
-	redirectBarePath bool                          // whether to redirect bare path requests to the full path with trailing slash
-	jsLibs           []types.JSLibrary             // JS libraries used by the app
+	globals        starlark.StringDict    // global variables defined in starlark code
+	appDef         *starlarkstruct.Struct // app starlark definition
+	errorHandler   starlark.Callable      // error handler function
+	appRouter      *chi.Mux               // router for the app
+	actions        []*action.Action       // actions defined for the app
+	htmlRoutes     []htmlRoute            // HTML page and fragment routes, for rendering with fixture data
+	templateRefs   []templateRef          // template names used in the route definitions, checked after the templates are parsed
+	markdownRoutes []*markdownRoute       // markdown routes, their templates are set after the app templates are parsed
+	apiRoutes      []apiRoute             // API routes, for the OpenAPI spec
+	proxyPaths     []string               // paths of the proxy routes, for the contract checks
+
+	usesHtmlTemplate bool                           // Whether the app uses HTML templates, false if only JSON APIs
+	template         *template.Template             // unstructured templates, no base_templates defined
+	templateMap      map[string]*template.Template  // structured templates, base_templates defined
+	templateBase     *template.Template             // the base templates alone, for routes/blocks naming a base define instead of a file
+	sharedTemplate   *template.Template             // clone of the templates for rendering blocks shared with other apps, nil if none are shared
+	blockRenderer    BlockRenderer                  // renders blocks shared by other apps, set by the server
+	threadPrint      func(*starlark.Thread, string) // print handler for the request threads
+	staticOnly       bool                           // app has only static files, no HTML routes
+	redirectBarePath bool                           // whether to redirect bare path requests to the full path with trailing slash
+	jsLibs           []types.JSLibrary              // JS libraries used by the app
 
 	watcher *fsnotify.Watcher
 	// sseListeners has its own lock (not initMutex) since notifyClients runs
@@ -100,9 +110,24 @@ type App struct {
 	// It is important that this property is used instead of reading from app metadata config, so that toml
 	// config defaults are applied.
 	AppConfig types.AppConfig
+	// activeHours is the parsed schedule.active_hours config, nil if the app is always active
+	activeHours *system.ActiveHours
 
 	lastRequestTime atomic.Int64
+	captures        *CaptureRegistry // traffic capture sessions, nil when not set by the server
+	profiles        *ProfileRegistry // handler profiling sessions, nil when not set by the server
+	faults          *faultInjector   // fault injection for stage apps, nil when not enabled
+	mocks           *pluginMocker    // plugin mocks for dev apps, nil when not enabled
+	minifier        *pageMinifier    // minifies the rendered pages, nil when not enabled
+	rateLimiter     *rateLimiter     // limits the requests per client IP, nil when not enabled
+	imageWidthsMu   sync.Mutex
+	imageWidths     map[string]int   // widths of the static images used with srcset, by the hashed name
+	resourceQuota   *ResourceQuota   // container quota tracker, nil when not set by the server
+	sandbox         *apptype.Sandbox // the Starlark builtins available, based on the app trust level
+	debugger        *debugger        // starlark breakpoints, set for dev apps only
 	secretEvalFunc  func([][]string, string, string) (string, error)
+	loadSecretsMu   sync.Mutex
+	loadSecrets     []loadSecret // secrets evaluated at app load, checked for rotation
 	auditInsert     func(*types.AuditEvent) error
 	AppRunPath      string       // path to the app run directory
 	rbacApi         rbac.RBACAPI // the rbac api to use
@@ -120,7 +145,13 @@ type App struct {
 	appUrlLocal any
 
 	activeContainerName container.ContainerName
+	activeServiceNames  []container.ContainerName
 	bindings            []*types.Binding
+
+	// loadTiming is the timing of the load in progress, guarded by initMutex. startTiming is
+	// the timing of the last successful load
+	loadTiming  *types.AppStartTiming
+	startTiming atomic.Pointer[types.AppStartTiming]
 }
 
 type starlarkCacheEntry struct {
@@ -155,16 +186,34 @@ func NewApp(sourceFS *appfs.SourceFs, workFS *appfs.WorkFs, logger *types.Logger
 		appUrl:         types.GetAppUrl(appEntry.AppPathDomain(), serverConfig),
 	}
 	newApp.appUrlLocal = newApp.appUrl // pre-box once for the thread-local hot path
+	newApp.threadPrint = starlarkThreadPrint
 	newApp.plugins = NewAppPlugins(newApp, plugins, appEntry.Metadata.Accounts)
 	newApp.AppConfig = appConfig
 	if err := newApp.updateAppConfig(); err != nil {
 		return nil, err
 	}
+	if err := newApp.loadActiveHours(); err != nil {
+		return nil, err
+	}
+	newApp.sandbox = apptype.NewSandbox(newApp.isTrusted(), serverConfig.Security.UntrustedAllowedBuiltins)
+	if !newApp.sandbox.Trusted {
+		newApp.Debug().Strs("disallowed", newApp.sandbox.Disallowed).Msg("App source is not trusted, Starlark builtins restricted")
+	}
+	if newApp.faults = newFaultInjector(appEntry.Id, newApp.AppConfig.Fault); newApp.faults != nil {
+		newApp.Warn().Float64("error_rate", newApp.AppConfig.Fault.ErrorRate).Float64("latency_rate", newApp.AppConfig.Fault.LatencyRate).
+			Int("latency_ms", newApp.AppConfig.Fault.LatencyMs).Msg("Fault injection enabled for app")
+	}
+	if newApp.mocks = newPluginMocker(logger, appEntry.IsDev, newApp.AppConfig.Mock); newApp.mocks != nil {
+		newApp.Warn().Strs("plugins", newApp.AppConfig.Mock.Plugins).Bool("record", newApp.AppConfig.Mock.Record).Msg("Plugin mocks enabled for app")
+	}
+	newApp.minifier = newPageMinifier(newApp.AppConfig.Minify, appEntry.IsDev)
+	newApp.rateLimiter = newRateLimiter(newApp.AppConfig.RateLimit, appEntry.IsDev)
 	newApp.telemetryAttrs = telemetry.AppAttributes(appEntry)
 	newApp.telemetryIdentityAttrs = telemetry.AppIdentityAttributes(appEntry)
 
 	if appEntry.IsDev {
 		newApp.appDev = dev.NewAppDev(logger, &appfs.WritableSourceFs{SourceFs: sourceFS}, workFS, newApp.appStyle, systemConfig)
+		newApp.debugger = newDebugger()
 	}
 
 	funcMap := system.GetFuncMap()
@@ -182,6 +231,11 @@ func NewApp(sourceFS *appfs.SourceFs, workFS *appfs.WorkFs, logger *types.Logger
 		return fi.Size() > 0
 	}
 
+	funcMap["srcset"] = newApp.srcset
+	funcMap["appBlock"] = newApp.appBlock
+	funcMap["markdown"] = renderMarkdown
+	funcMap["flush"] = templateFlush
+
 	newApp.funcMap = funcMap
 
 	clHome := cmp.Or(os.Getenv("OPENRUN_HOME"), "./")
@@ -193,9 +247,33 @@ func NewApp(sourceFS *appfs.SourceFs, workFS *appfs.WorkFs, logger *types.Logger
 }
 
 func (a *App) Initialize(ctx context.Context, dryRun types.DryRun) error {
+	if err := a.initialize(ctx, dryRun, false); err != nil {
+		return err
+	}
+	if a.containerHandler != nil {
+		// The app could have been initialized by a request, wait for the container to be ready
+		ready, err := a.containerHandler.WaitReady(ctx)
+		if err != nil {
+			return fmt.Errorf("error waiting for health: %w", err)
+		}
+		if !ready {
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// InitializeServing initializes the app to serve a request. The prod container health check
+// is not waited for, the container proxy holds the requests until the container is ready
+func (a *App) InitializeServing(ctx context.Context) error {
+	return a.initialize(ctx, types.DryRunFalse, true)
+}
+
+func (a *App) initialize(ctx context.Context, dryRun types.DryRun, gateReadiness bool) error {
 	var reloaded bool
 	var err error
-	if reloaded, err = a.Reload(ctx, false, true, dryRun, ReloadOptions{ReloadContainer: true, Verify: false}); err != nil {
+	if reloaded, err = a.Reload(ctx, false, true, dryRun,
+		ReloadOptions{ReloadContainer: true, Verify: false, GateReadiness: gateReadiness}); err != nil {
 		return err
 	}
 
@@ -221,6 +299,10 @@ func (a *App) Close() error {
 		_ = a.appDev.Close()
 	}
 
+	if a.debugger != nil {
+		a.debugger.resume(0) // continue any paused handlers
+	}
+
 	if a.containerHandler != nil {
 		if err := a.containerHandler.Close(); err != nil {
 			return err
@@ -282,11 +364,47 @@ func (a *App) ActiveContainerName() (container.ContainerName, bool) {
 	return a.activeContainerName, true
 }
 
+// ActiveServiceNames returns the service containers from the last successful app reload.
+func (a *App) ActiveServiceNames() []container.ContainerName {
+	a.initMutex.Lock()
+	defer a.initMutex.Unlock()
+	return slices.Clone(a.activeServiceNames)
+}
+
+// ContainerState returns the state of the app container, for the metrics. false is returned
+// if the app does not use a container or if the app is being initialized, the metrics
+// collection does not wait for an app reload to complete
+func (a *App) ContainerState() (ContainerState, bool) {
+	if !a.initMutex.TryLock() {
+		return "", false
+	}
+	handler := a.containerHandler
+	a.initMutex.Unlock()
+	if handler == nil {
+		return "", false
+	}
+	return handler.State()
+}
+
+// VolumeDirs returns the volumes used by the app containers, mapped to the volume dir. nil is
+// returned if the app does not use a container
+func (a *App) VolumeDirs() map[container.VolumeName]string {
+	a.initMutex.Lock()
+	handler := a.containerHandler
+	a.initMutex.Unlock()
+	if handler == nil {
+		return nil
+	}
+	return handler.VolumeDirs()
+}
+
 func (a *App) updateActiveContainerNameLocked() {
 	a.activeContainerName = ""
+	a.activeServiceNames = nil
 	if a.containerHandler == nil {
 		return
 	}
+	a.activeServiceNames = a.containerHandler.ActiveServiceNames()
 	if name, ok := a.containerHandler.ActiveContainerName(); ok {
 		a.activeContainerName = name
 	}
@@ -312,6 +430,10 @@ type ReloadOptions struct {
 	// by the image pre-build pass, which needs the app fully configured but
 	// must not touch containers.
 	SkipContainer bool
+	// GateReadiness returns once the prod container is started, without waiting for its
+	// health check. Requests to the container proxy routes wait until the container is
+	// ready. Used when the app is initialized to serve a request.
+	GateReadiness bool
 }
 
 func (a *App) Reload(ctx context.Context, force, immediate bool, dryRun types.DryRun, opts ReloadOptions) (bool, error) {
@@ -340,6 +462,7 @@ func (a *App) Reload(ctx context.Context, force, immediate bool, dryRun types.Dr
 		time.Sleep(time.Duration(a.systemConfig.FileWatcherDebounceMillis) * time.Millisecond)
 	}
 	a.reloadStartTime = time.Now()
+	a.loadTiming = &types.AppStartTiming{Time: a.reloadStartTime, Reload: a.startTiming.Load() != nil}
 
 	var err error
 	a.Info().Msg("Reloading app definition")
@@ -383,11 +506,19 @@ func (a *App) Reload(ctx context.Context, force, immediate bool, dryRun types.Dr
 		}
 	}
 
+	a.loadTiming.SourceLoadMs = msSince(a.reloadStartTime)
+	sourceLoadMs := a.loadTiming.SourceLoadMs
+
 	// Load Starlark config, AppConfig is updated with the settings contents
+	starlarkStart := time.Now()
 	if err = a.loadStarlarkConfig(ctx, dryRun, opts); err != nil {
 		return false, fmt.Errorf("error during initial setup: %w", err)
 	}
 	a.Metadata.Name = a.Name
+	// The app source read and the container start within the Starlark load are timed separately
+	a.loadTiming.StarlarkInitMs = msSince(starlarkStart) - a.loadTiming.ContainerStartMs -
+		(a.loadTiming.SourceLoadMs - sourceLoadMs)
+	templateStart := time.Now()
 
 	// Initialize style configuration
 	if err := a.appStyle.Init(a.Id, a.appDef); err != nil {
@@ -479,6 +610,15 @@ func (a *App) Reload(ctx context.Context, force, immediate bool, dryRun types.Dr
 			}
 		}
 	}
+	if err := a.initSharedTemplate(); err != nil {
+		return false, err
+	}
+	if err := a.initMarkdownTemplates(); err != nil {
+		return false, err
+	}
+	if err := a.checkTemplateRefs(); err != nil {
+		return false, err
+	}
 	for _, action := range a.actions {
 		// structured templates are not supported for actions currently
 		action.AppTemplate = a.template
@@ -486,8 +626,10 @@ func (a *App) Reload(ctx context.Context, force, immediate bool, dryRun types.Dr
 		action.LightTheme = cmp.Or(a.appStyle.Light, apptype.DEFAULT_DAISYUI_LIGHT_THEME)
 		action.DarkTheme = cmp.Or(a.appStyle.Dark, apptype.DEFAULT_DAISYUI_DARK_THEME)
 	}
+	a.loadTiming.TemplateParseMs = msSince(templateStart)
 	a.initialized = true
 	a.updateActiveContainerNameLocked()
+	a.finishLoadTiming(ctx)
 
 	if a.IsDev {
 		a.notifyClients()
@@ -569,6 +711,16 @@ func (a *App) loadContainerManager(ctx context.Context, stripAppPath bool) error
 		return fmt.Errorf("error reading health: %w", err)
 	}
 
+	healthInterval, err := apptype.GetIntAttr(configAttr, "health_interval")
+	if err != nil {
+		return fmt.Errorf("error reading health_interval: %w", err)
+	}
+
+	healthRetries, err := apptype.GetIntAttr(configAttr, "health_retries")
+	if err != nil {
+		return fmt.Errorf("error reading health_retries: %w", err)
+	}
+
 	buildDir, err := apptype.GetStringAttr(configAttr, "build_dir")
 	if err != nil {
 		return fmt.Errorf("error reading build_dir: %w", err)
@@ -596,6 +748,15 @@ func (a *App) loadContainerManager(ctx context.Context, stripAppPath bool) error
 		return fmt.Errorf("error parsing dev_settings: %w", err)
 	}
 
+	servicesMap, err := apptype.GetDictAttr(configAttr, "services", true)
+	if err != nil {
+		return fmt.Errorf("error reading services: %w", err)
+	}
+	services, err := parseContainerServices(servicesMap)
+	if err != nil {
+		return fmt.Errorf("error parsing services: %w", err)
+	}
+
 	// Parse the source file specification
 	var fileName string
 	switch src {
@@ -642,10 +803,20 @@ func (a *App) loadContainerManager(ctx context.Context, stripAppPath bool) error
 		return fmt.Errorf("error converting port to int32: %w", err)
 	}
 
+	// The app health check settings override the app config
+	appContainerConfig := a.AppConfig.Container
+	if healthInterval > 0 {
+		appContainerConfig.HealthIntervalSecs = int(healthInterval)
+	}
+	if healthRetries > 0 {
+		appContainerConfig.DeployHealthAttempts = int(healthRetries)
+		appContainerConfig.HealthAttemptsAfterStartup = int(healthRetries)
+	}
+
 	a.containerHandler, err = NewContainerHandler(a.Logger, a,
 		fileName, a.serverConfig, portInt, lifetime, scheme, health, buildDir,
-		a.sourceFS, a.paramValuesStr, a.AppConfig.Container, stripAppPath, volumes,
-		a.getSecretsAllowed("container.in", "config"), cargs, a.bindings, devSettings)
+		a.sourceFS, a.paramValuesStr, appContainerConfig, stripAppPath, volumes,
+		a.getSecretsAllowed("container.in", "config"), cargs, a.bindings, devSettings, services)
 	if err != nil {
 		return fmt.Errorf("error creating container handler: %w", err)
 	}
@@ -766,7 +937,21 @@ func parseDevSettings(m map[string]any) (*types.DevSettings, error) {
 	return ds, nil
 }
 
+// executeTemplate renders the template, or the partial block if set. The {{flush}} points in the
+// template flush the response if w supports flushing, else they are removed from the output. A
+// flushWriter is used as is, the caller has to call finish on it
 func (a *App) executeTemplate(w io.Writer, template, partial string, data any) error {
+	if fw, ok := w.(*flushWriter); ok {
+		return a.renderTemplate(fw, template, partial, data)
+	}
+	fw := newFlushWriter(w, nil)
+	if err := a.renderTemplate(fw, template, partial, data); err != nil {
+		return err
+	}
+	return fw.finish()
+}
+
+func (a *App) renderTemplate(w io.Writer, template, partial string, data any) error {
 	var err error
 	if a.template != nil {
 		exec := partial
@@ -842,7 +1027,7 @@ func (a *App) loadParamsInfo(sourceFS *appfs.SourceFs) error {
 		return nil // Ignore absence of params file
 	}
 
-	a.paramInfo, err = apptype.ReadParamInfo(a.getStarPath(apptype.PARAMS_FILE_NAME), paramsInfoData, a.serverConfig)
+	a.paramInfo, err = apptype.ReadParamInfo(a.getStarPath(apptype.PARAMS_FILE_NAME), paramsInfoData, a.serverConfig, a.sandbox)
 	if err != nil {
 		return fmt.Errorf("error reading params info: %w", err)
 	}
@@ -966,11 +1151,21 @@ func (a *App) MaterializeSource() (string, error) {
 	return a.sourceFS.CreateTempSourceDir()
 }
 
+// routePattern returns the matched route for the request metrics, like /app/items/{id}. The
+// patterns come from the app routes, so the cardinality is bounded unlike the request path
+func routePattern(r *http.Request) string {
+	if route := chi.RouteContext(r.Context()).RoutePattern(); route != "" {
+		return route
+	}
+	return "unmatched"
+}
+
 func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	if a.Info().Enabled() {
 		a.Info().Str("method", r.Method).Str("url", r.URL.String()).Msg("App Received request")
 	}
 	telemetry.RecordAppRequest(r.Context(), r.Method, a.telemetryIdentityAttrs...)
+	start := time.Now()
 
 	var rw = w
 	if a.AppConfig.Security.HeadersLevel >= 2 {
@@ -983,8 +1178,31 @@ func (a *App) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			status = http.StatusOK
 		}
 		telemetry.RecordAppResponse(r.Context(), status, a.telemetryIdentityAttrs...)
+		if telemetry.MetricsEnabled() {
+			telemetry.RecordAppRequestDuration(r.Context(), routePattern(r), status, start, a.telemetryIdentityAttrs...)
+		}
 	}()
 
+	if a.rateLimiter != nil {
+		if ok, wait := a.rateLimiter.allow(a.getRemoteIP(r)); !ok {
+			wrapper.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(wrapper, "too many requests", http.StatusTooManyRequests)
+			return
+		}
+	}
+	if maxBytes := a.AppConfig.Security.MaxRequestBodyBytes; maxBytes > 0 && r.Body != nil {
+		if r.ContentLength > maxBytes {
+			http.Error(wrapper, "request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(wrapper, r.Body, maxBytes)
+	}
+
+	if session := a.captures.recorder(a.Id); session != nil {
+		done := a.startCapture(session, wrapper, r)
+		defer done()
+	}
+
 	if errPtr := a.reloadError.Load(); errPtr != nil && *errPtr != nil {
 		reloadErr := *errPtr
 		a.Warn().Err(reloadErr).Msg("Last reload had failed")
@@ -1282,12 +1500,17 @@ func (a *App) loadStarlark(thread *starlark.Thread, module string, cache map[str
 		if err != nil {
 			return nil, err
 		}
+		if a.debugger != nil {
+			if buf, err = a.debugger.instrument(a.getStarPath(module), buf); err != nil {
+				return nil, err
+			}
+		}
 
 		builtin, err := a.createBuiltin()
 		if err != nil {
 			return nil, err
 		}
-		globals, err := starlark.ExecFileOptions(AppFileOptions(), thread, module, buf, builtin)
+		globals, err := starlark.ExecFileOptions(a.sandbox.FileOptions(), thread, module, buf, builtin)
 		cacheEntry = &starlarkCacheEntry{globals, err}
 		// Update the cache.
 		cache[module] = cacheEntry
@@ -1295,6 +1518,25 @@ func (a *App) loadStarlark(thread *starlark.Thread, module string, cache map[str
 	return cacheEntry.globals, cacheEntry.err
 }
 
+// isTrusted reports whether the app source matches one of the security.trusted_sources patterns.
+// Dev apps are always trusted, all apps are trusted if trusted_sources is not set
+func (a *App) isTrusted() bool {
+	if a.IsDev || a.serverConfig.Security.TrustedSources == nil {
+		return true
+	}
+	for _, pattern := range a.serverConfig.Security.TrustedSources {
+		if match, err := doublestar.Match(pattern, a.SourceUrl); err == nil && match {
+			return true
+		}
+	}
+	return false
+}
+
+// Sandbox returns the Starlark capabilities available to the app
+func (a *App) Sandbox() *apptype.Sandbox {
+	return a.sandbox
+}
+
 func AppFileOptions() *syntax.FileOptions {
 	return &syntax.FileOptions{
 		While:     true,
@@ -1302,20 +1544,15 @@ func AppFileOptions() *syntax.FileOptions {
 	}
 }
 
-// updateAppConfig updates the app defaults from the metadata
-// It creates a TOML intermediate string so that the TOML parsing can be used
+// updateAppConfig applies the tag config and the per-app config from the metadata over the
+// server app config defaults
 func (a *App) updateAppConfig() error {
-	if len(a.Metadata.AppConfig) == 0 {
-		return nil
-	}
-
-	buf := strings.Builder{}
-	for key, value := range a.Metadata.AppConfig {
-		buf.WriteString(fmt.Sprintf("%s=%s\n", key, value))
+	config, _, err := ResolveAppConfig(a.Logger, a.AppConfig, a.serverConfig.TagConfig, a.AppEntry)
+	if err != nil {
+		return err
 	}
-
-	_, err := toml.Decode(buf.String(), &a.AppConfig)
-	return err
+	a.AppConfig = config
+	return nil
 }
 
 func (a *App) getSecretsAllowed(plugin, function string) [][]string {
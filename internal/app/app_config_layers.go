@@ -0,0 +1,185 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// ResolveAppConfig returns the app config for an app, built from the layers in order of
+// precedence: the server app_config (passed as defaults), the security profile, the tag_config
+// entries for the app tags and the per-app config entries. Tags are applied in sorted order, when
+// two tags set the same key the later one wins. The returned sources map has the config layer for
+// each key set by the profile, a tag or the app
+func ResolveAppConfig(logger *types.Logger, defaults types.AppConfig, tagConfig map[string]map[string]any,
+	appEntry *types.AppEntry) (types.AppConfig, map[string]string, error) {
+	profile := defaults.Security.Profile
+	config, sources, err := resolveAppConfigLayers(logger, defaults, profile, tagConfig, appEntry)
+	if err != nil || config.Security.Profile == profile {
+		return config, sources, err
+	}
+	// A tag or the app selected another profile, or opted out using none, resolve again
+	return resolveAppConfigLayers(logger, defaults, config.Security.Profile, tagConfig, appEntry)
+}
+
+func resolveAppConfigLayers(logger *types.Logger, defaults types.AppConfig, profile string,
+	tagConfig map[string]map[string]any, appEntry *types.AppEntry) (types.AppConfig, map[string]string, error) {
+	config := defaults
+	sources := map[string]string{}
+
+	profileConfig, err := securityProfileConfig(profile)
+	if err != nil {
+		return config, nil, err
+	}
+	if err := applyConfigLayer(logger, &config, profileConfig, "security profile "+profile,
+		types.AppConfigSourceProfilePrefix+profile, sources); err != nil {
+		return config, nil, err
+	}
+
+	tags := slices.Sorted(slices.Values(appEntry.Settings.Tags))
+	for _, tag := range slices.Compact(tags) {
+		if err := applyConfigLayer(logger, &config, tagConfig[tag], "tag_config."+tag,
+			types.AppConfigSourceTagPrefix+tag, sources); err != nil {
+			return config, nil, err
+		}
+	}
+
+	if len(appEntry.Metadata.AppConfig) > 0 {
+		// A TOML intermediate string is created so that the TOML parsing can be used
+		buf := strings.Builder{}
+		for key, value := range appEntry.Metadata.AppConfig {
+			buf.WriteString(fmt.Sprintf("%s=%s\n", key, value))
+			sources[key] = types.AppConfigSourceApp
+		}
+		if _, err := toml.Decode(buf.String(), &config); err != nil {
+			return config, nil, err
+		}
+	}
+	return config, sources, nil
+}
+
+// applyConfigLayer sets the config entries, nested tables or dotted keys, on the app config. The
+// source of each key set is recorded in sources
+func applyConfigLayer(logger *types.Logger, config *types.AppConfig, entries map[string]any,
+	name, source string, sources map[string]string) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	flat := map[string]any{}
+	flattenConfig("", entries, flat)
+
+	nested := map[string]any{}
+	for key, value := range flat {
+		setNestedConfig(nested, strings.Split(key, "."), value)
+	}
+	buf := bytes.Buffer{}
+	if err := toml.NewEncoder(&buf).Encode(nested); err != nil {
+		return fmt.Errorf("error encoding %s: %w", name, err)
+	}
+	md, err := toml.Decode(buf.String(), config)
+	if err != nil {
+		return fmt.Errorf("error applying %s: %w", name, err)
+	}
+	for _, key := range md.Undecoded() {
+		logger.Warn().Msgf("%s key %s is not a valid app config key, ignored", name, key)
+	}
+	for key := range flat {
+		sources[key] = source
+	}
+	return nil
+}
+
+// EffectiveAppConfig lists all the keys of the resolved app config with their values and the
+// config layer which set each value. Keys not set by a tag or the app come from the server config
+func EffectiveAppConfig(config types.AppConfig, sources map[string]string) ([]types.AppConfigValue, error) {
+	buf := bytes.Buffer{}
+	if err := toml.NewEncoder(&buf).Encode(config); err != nil {
+		return nil, err
+	}
+	decoded := map[string]any{}
+	if _, err := toml.Decode(buf.String(), &decoded); err != nil {
+		return nil, err
+	}
+	flat := map[string]any{}
+	flattenConfig("", decoded, flat)
+
+	ret := make([]types.AppConfigValue, 0, len(flat))
+	for _, key := range slices.Sorted(maps.Keys(flat)) {
+		value, err := tomlValueString(flat[key])
+		if err != nil {
+			return nil, fmt.Errorf("error encoding %s: %w", key, err)
+		}
+		ret = append(ret, types.AppConfigValue{Key: key, Value: value, Source: configSource(key, sources)})
+	}
+	return ret, nil
+}
+
+// configSource returns the layer for a key. A layer can set a parent key to a table value, like
+// cors = {allow_origin = "*"}, so the longest matching parent key is used
+func configSource(key string, sources map[string]string) string {
+	for k := key; k != ""; {
+		if source, ok := sources[k]; ok {
+			return source
+		}
+		idx := strings.LastIndex(k, ".")
+		if idx < 0 {
+			break
+		}
+		k = k[:idx]
+	}
+	return types.AppConfigSourceServer
+}
+
+// flattenConfig converts nested tables to dotted keys. Keys which are already dotted, as set
+// through the dynamic config API, are kept as is. Whole number floats, as decoded from JSON,
+// are converted to integers so that they can be set on the integer config fields
+func flattenConfig(prefix string, m map[string]any, out map[string]any) {
+	for key, value := range m {
+		fullKey := key
+		if prefix != "" {
+			fullKey = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case map[string]any:
+			flattenConfig(fullKey, v, out)
+		case float64:
+			if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+				out[fullKey] = int64(v)
+			} else {
+				out[fullKey] = v
+			}
+		default:
+			out[fullKey] = v
+		}
+	}
+}
+
+func setNestedConfig(m map[string]any, keys []string, value any) {
+	for _, key := range keys[:len(keys)-1] {
+		child, ok := m[key].(map[string]any)
+		if !ok {
+			child = map[string]any{}
+			m[key] = child
+		}
+		m = child
+	}
+	m[keys[len(keys)-1]] = value
+}
+
+// tomlValueString returns the TOML representation of a single value, like "true" or "\"*\""
+func tomlValueString(value any) (string, error) {
+	buf := bytes.Buffer{}
+	if err := toml.NewEncoder(&buf).Encode(map[string]any{"v": value}); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(strings.TrimPrefix(buf.String(), "v = ")), nil
+}
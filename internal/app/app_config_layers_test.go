@@ -0,0 +1,62 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestResolveAppConfigLayers(t *testing.T) {
+	defaults := types.AppConfig{}
+	defaults.Proxy.MaxResponseBytes = 100
+	defaults.Proxy.MaxResponseSecs = 10
+	defaults.CORS.AllowOrigin = "origin"
+
+	tagConfig := map[string]map[string]any{
+		// Nested tables, as read from openrun.toml
+		"internal": {"proxy": map[string]any{"max_response_bytes": int64(200), "max_response_secs": int64(20)}},
+		// Dotted keys with JSON numbers, as set through the dynamic config API
+		"large":  {"proxy.max_response_bytes": float64(300)},
+		"unused": {"proxy.max_response_secs": int64(99)},
+	}
+	appEntry := &types.AppEntry{
+		Settings: types.AppSettings{Tags: []string{"large", "internal"}},
+		Metadata: types.AppMetadata{AppConfig: map[string]string{"cors.allow_origin": `"app"`}},
+	}
+
+	config, sources, err := ResolveAppConfig(testutil.TestLogger(), defaults, tagConfig, appEntry)
+	testutil.AssertNoError(t, err)
+	// Tags are applied in sorted order, large overrides internal
+	testutil.AssertEqualsInt(t, "max bytes", 300, int(config.Proxy.MaxResponseBytes))
+	testutil.AssertEqualsInt(t, "max secs", 20, config.Proxy.MaxResponseSecs)
+	testutil.AssertEqualsString(t, "allow origin", "app", config.CORS.AllowOrigin)
+	testutil.AssertEqualsString(t, "defaults unchanged", "origin", defaults.CORS.AllowOrigin)
+
+	values, err := EffectiveAppConfig(config, sources)
+	testutil.AssertNoError(t, err)
+	got := map[string]types.AppConfigValue{}
+	for _, v := range values {
+		got[v.Key] = v
+	}
+	testutil.AssertEqualsString(t, "bytes value", "300", got["proxy.max_response_bytes"].Value)
+	testutil.AssertEqualsString(t, "bytes source", "tag:large", got["proxy.max_response_bytes"].Source)
+	testutil.AssertEqualsString(t, "secs source", "tag:internal", got["proxy.max_response_secs"].Source)
+	testutil.AssertEqualsString(t, "origin value", `"app"`, got["cors.allow_origin"].Value)
+	testutil.AssertEqualsString(t, "origin source", "app", got["cors.allow_origin"].Source)
+	testutil.AssertEqualsString(t, "server source", "server", got["proxy.rewrite_html"].Source)
+}
+
+func TestResolveAppConfigTableValue(t *testing.T) {
+	appEntry := &types.AppEntry{
+		Metadata: types.AppMetadata{AppConfig: map[string]string{"cors": `{allow_origin = "x"}`}},
+	}
+	config, sources, err := ResolveAppConfig(testutil.TestLogger(), types.AppConfig{}, nil, appEntry)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "allow origin", "x", config.CORS.AllowOrigin)
+	testutil.AssertEqualsString(t, "source", "app", configSource("cors.allow_origin", sources))
+	testutil.AssertEqualsString(t, "source", "server", configSource("proxy.max_response_bytes", sources))
+}
@@ -0,0 +1,92 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/app/apptype"
+	"go.starlark.net/starlarkstruct"
+)
+
+var envNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// reservedEnv are set by OpenRun for containers, apps cannot override them
+var reservedEnv = []string{"PORT", "CL_APP_PATH", "CL_APP_URL"}
+
+// envAllowAllSecrets is the secrets permission used when resolving an env value. The value
+// template is approved as is in the audit, so any secret it references is allowed
+var envAllowAllSecrets = [][]string{{"regex:.*"}}
+
+// loadEnvDefs reads the env entries declared in the app definition, sorted by name. An entry
+// with a None value is read from the server process env and is returned as NAME, other entries
+// are returned as NAME=value. The entries are approved in this format in the app audit
+func loadEnvDefs(appDef *starlarkstruct.Struct) ([]string, error) {
+	envDict, err := apptype.GetDictAttr(appDef, "env", true)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]string, 0, len(envDict))
+	for name, value := range envDict {
+		if !envNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("env %s: invalid name, should be letters, digits and underscore", name)
+		}
+		if slices.Contains(reservedEnv, name) {
+			return nil, fmt.Errorf("env %s: name is reserved for use by OpenRun", name)
+		}
+		switch v := value.(type) {
+		case nil:
+			ret = append(ret, name)
+		case string:
+			ret = append(ret, name+"="+v)
+		default:
+			return nil, fmt.Errorf("env %s: value should be a string or None, got %T", name, value)
+		}
+	}
+	slices.Sort(ret)
+	return ret, nil
+}
+
+// envNeedsApproval checks whether any of the env entries is not approved. Entries read from the
+// server env are allowed without approval if the name is in the allowed_env system config
+func envNeedsApproval(newEnv, approvedEnv, allowedEnv []string) bool {
+	for _, entry := range newEnv {
+		if slices.Contains(approvedEnv, entry) {
+			continue
+		}
+		if !strings.Contains(entry, "=") && slices.Contains(allowedEnv, entry) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// resolveEnv returns the values for the env entries, to be passed to the handlers and to the
+// container. The app load fails if an entry is not approved
+func (a *App) resolveEnv(envDefs []string) (map[string]string, error) {
+	ret := make(map[string]string, len(envDefs))
+	for _, entry := range envDefs {
+		name, value, hasValue := strings.Cut(entry, "=")
+		if envNeedsApproval([]string{entry}, a.Metadata.Env, a.systemConfig.AllowedEnv) {
+			return nil, fmt.Errorf("app %s is not permitted to use env %s. Audit the app and approve permissions", a.Path, name)
+		}
+
+		if !hasValue {
+			ret[name] = os.Getenv(name)
+			continue
+		}
+		var err error
+		if value, err = a.evalLoadSecret(envAllowAllSecrets, value); err != nil {
+			return nil, fmt.Errorf("error evaluating secret for env %s: %w", name, err)
+		}
+		ret[name] = value
+	}
+	return ret, nil
+}
@@ -0,0 +1,72 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+func envAppDef(env map[string]starlark.Value) *starlarkstruct.Struct {
+	dict := starlark.NewDict(len(env))
+	for k, v := range env {
+		dict.SetKey(starlark.String(k), v) //nolint:errcheck
+	}
+	return starlarkstruct.FromStringDict(starlark.String("App"), starlark.StringDict{"env": dict})
+}
+
+func TestLoadEnvDefs(t *testing.T) {
+	t.Parallel()
+
+	env, err := loadEnvDefs(envAppDef(map[string]starlark.Value{
+		"REGION":  starlark.None,
+		"DB_PASS": starlark.String(`{{secret "db_pass"}}`),
+		"MODE":    starlark.String("a=b"),
+	}))
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "env", `DB_PASS={{secret "db_pass"}},MODE=a=b,REGION`, strings.Join(env, ","))
+
+	_, err = loadEnvDefs(envAppDef(map[string]starlark.Value{"1ABC": starlark.None}))
+	testutil.AssertErrorContains(t, err, "env 1ABC: invalid name")
+	_, err = loadEnvDefs(envAppDef(map[string]starlark.Value{"PORT": starlark.String("80")}))
+	testutil.AssertErrorContains(t, err, "env PORT: name is reserved")
+	_, err = loadEnvDefs(envAppDef(map[string]starlark.Value{"COUNT": starlark.MakeInt(1)}))
+	testutil.AssertErrorContains(t, err, "value should be a string or None")
+}
+
+func TestEnvNeedsApproval(t *testing.T) {
+	t.Parallel()
+
+	allowedEnv := []string{"HOME"}
+	testutil.AssertEqualsBool(t, "allowed env", false, envNeedsApproval([]string{"HOME"}, nil, allowedEnv))
+	testutil.AssertEqualsBool(t, "not allowed env", true, envNeedsApproval([]string{"REGION"}, nil, allowedEnv))
+	testutil.AssertEqualsBool(t, "allowed env with value", true, envNeedsApproval([]string{"HOME=/tmp"}, nil, allowedEnv))
+	testutil.AssertEqualsBool(t, "approved", false, envNeedsApproval([]string{"HOME=/tmp", "REGION"}, []string{"HOME=/tmp", "REGION"}, allowedEnv))
+	testutil.AssertEqualsBool(t, "removed", false, envNeedsApproval([]string{"REGION"}, []string{"HOME=/tmp", "REGION"}, allowedEnv))
+	testutil.AssertEqualsBool(t, "value changed", true, envNeedsApproval([]string{"MODE=b"}, []string{"MODE=a"}, allowedEnv))
+}
+
+func TestResolveEnv(t *testing.T) {
+	t.Setenv("OPENRUN_TEST_REGION", "us-east-1")
+	a := &App{
+		AppEntry:     &types.AppEntry{Path: "/test", Metadata: types.AppMetadata{Env: []string{"OPENRUN_TEST_REGION", "PASS={{secret \"pass\"}}"}}},
+		systemConfig: &types.SystemConfig{AllowedEnv: []string{"HOME"}},
+		secretEvalFunc: func(allowed [][]string, _, value string) (string, error) {
+			return "resolved:" + value, nil
+		},
+	}
+
+	env, err := a.resolveEnv([]string{"OPENRUN_TEST_REGION", "PASS={{secret \"pass\"}}"})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "region", "us-east-1", env["OPENRUN_TEST_REGION"])
+	testutil.AssertEqualsString(t, "pass", "resolved:{{secret \"pass\"}}", env["PASS"])
+
+	_, err = a.resolveEnv([]string{"PASS={{secret \"other\"}}"})
+	testutil.AssertErrorContains(t, err, "app /test is not permitted to use env PASS")
+}
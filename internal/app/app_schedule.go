@@ -0,0 +1,75 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/openrundev/openrun/internal/system"
+)
+
+// loadActiveHours parses the schedule app config. Dev apps are always active
+func (a *App) loadActiveHours() error {
+	if a.IsDev {
+		return nil
+	}
+	hours, err := system.ParseActiveHours(a.AppConfig.Schedule.ActiveHours, a.AppConfig.Schedule.Timezone)
+	if err != nil {
+		return err
+	}
+	a.activeHours = hours
+	return nil
+}
+
+// OutsideActiveHours reports whether the app is outside its active hours at t, along with the
+// start of the next active window. Apps without active hours are always active
+func (a *App) OutsideActiveHours(t time.Time) (bool, time.Time) {
+	if a.activeHours == nil || a.activeHours.IsActive(t) {
+		return false, time.Time{}
+	}
+	return true, a.activeHours.NextStart(t)
+}
+
+// HasActiveHours reports whether the app has active hours configured
+func (a *App) HasActiveHours() bool {
+	return a.activeHours != nil
+}
+
+// NeedsInitialize reports whether the app is loaded but not initialized. false is returned
+// while the app is being initialized
+func (a *App) NeedsInitialize() bool {
+	if !a.initMutex.TryLock() {
+		return false
+	}
+	defer a.initMutex.Unlock()
+	return !a.initialized
+}
+
+// StopForSchedule stops the app container and its services when the app is outside its active
+// hours. Returns false if the app has no container running. The app has to be closed after
+// this, it is initialized again when the next active window opens
+func (a *App) StopForSchedule(ctx context.Context) bool {
+	a.initMutex.Lock()
+	handler := a.containerHandler
+	a.initMutex.Unlock()
+	if handler == nil {
+		return false
+	}
+	return handler.stopForSchedule(ctx)
+}
+
+func (h *ContainerHandler) stopForSchedule(ctx context.Context) bool {
+	h.stateLock.Lock()
+	defer h.stateLock.Unlock()
+	if h.activeContainerName == "" || (h.currentState != ContainerStateRunning && h.currentState != ContainerStateStarting) {
+		return false
+	}
+	h.currentState = ContainerStateScheduledStop
+	if err := h.manager.StopContainer(ctx, h.activeContainerName); err != nil {
+		h.Error().Err(err).Msgf("Error stopping app %s outside active hours", h.app.Id)
+	}
+	h.stopServices(ctx)
+	return true
+}
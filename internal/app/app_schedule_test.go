@@ -0,0 +1,46 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestOutsideActiveHours(t *testing.T) {
+	a := &App{AppEntry: &types.AppEntry{Id: "app_prd_123"}}
+	a.AppConfig.Schedule = types.Schedule{ActiveHours: []string{"mon-fri 09:00-17:00"}, Timezone: "UTC"}
+	if err := a.loadActiveHours(); err != nil {
+		t.Fatal(err)
+	}
+	if !a.HasActiveHours() {
+		t.Fatal("expected active hours")
+	}
+
+	wed := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)
+	if outside, _ := a.OutsideActiveHours(wed.Add(10 * time.Hour)); outside {
+		t.Error("expected app to be active")
+	}
+	outside, next := a.OutsideActiveHours(wed.Add(20 * time.Hour))
+	if !outside || !next.Equal(wed.AddDate(0, 0, 1).Add(9*time.Hour)) {
+		t.Errorf("unexpected schedule state %t %s", outside, next)
+	}
+
+	// Dev apps are always active
+	dev := &App{AppEntry: &types.AppEntry{Id: "app_dev_123", IsDev: true}}
+	dev.AppConfig.Schedule = a.AppConfig.Schedule
+	if err := dev.loadActiveHours(); err != nil {
+		t.Fatal(err)
+	}
+	if outside, _ := dev.OutsideActiveHours(wed.Add(20 * time.Hour)); outside || dev.HasActiveHours() {
+		t.Error("dev app should not have active hours")
+	}
+
+	a.AppConfig.Schedule.ActiveHours = []string{"weekdays"}
+	if err := a.loadActiveHours(); err == nil {
+		t.Error("expected error for invalid active hours")
+	}
+}
@@ -0,0 +1,32 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestAppIsTrusted(t *testing.T) {
+	newTrustApp := func(sourceUrl string, isDev bool, trusted []string) *App {
+		serverConfig := &types.ServerConfig{}
+		serverConfig.Security.TrustedSources = trusted
+		return &App{
+			AppEntry:     &types.AppEntry{SourceUrl: sourceUrl, IsDev: isDev},
+			serverConfig: serverConfig,
+		}
+	}
+
+	// trusted_sources not set trusts all the apps
+	testutil.AssertEqualsBool(t, "not set", true, newTrustApp("github.com/other/app", false, nil).isTrusted())
+	testutil.AssertEqualsBool(t, "empty", false, newTrustApp("github.com/other/app", false, []string{}).isTrusted())
+
+	trusted := []string{"github.com/myorg/**", "/opt/apps/**"}
+	testutil.AssertEqualsBool(t, "org", true, newTrustApp("github.com/myorg/app", false, trusted).isTrusted())
+	testutil.AssertEqualsBool(t, "local", true, newTrustApp("/opt/apps/a/b", false, trusted).isTrusted())
+	testutil.AssertEqualsBool(t, "other", false, newTrustApp("github.com/other/app", false, trusted).isTrusted())
+	testutil.AssertEqualsBool(t, "dev", true, newTrustApp("github.com/other/app", true, trusted).isTrusted())
+}
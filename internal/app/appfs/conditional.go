@@ -0,0 +1,60 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package appfs
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETagMatches does the weak comparison of the ETag with the If-None-Match header value, which
+// can be a list of ETags or "*". The compression middleware converts strong ETags to weak ones,
+// so the comparison ignores the W/ prefix
+func ETagMatches(header, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckNotModified checks the conditional request headers against the ETag and the modification
+// time, and writes a 304 response if the client copy is current. If-None-Match takes precedence
+// over If-Modified-Since. Returns true if the response was written
+func CheckNotModified(w http.ResponseWriter, r *http.Request, etag string, modtime time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		if etag == "" || !ETagMatches(ifNoneMatch, etag) {
+			return false
+		}
+	} else if ifModifiedSince := r.Header.Get("If-Modified-Since"); ifModifiedSince != "" {
+		if modtime.IsZero() || modtime.Equal(unixEpochTime) {
+			return false
+		}
+		since, err := http.ParseTime(ifModifiedSince)
+		// The header has second precision, ignore the sub-second part of the modification time
+		if err != nil || modtime.Truncate(time.Second).After(since) {
+			return false
+		}
+	} else {
+		return false
+	}
+
+	header := w.Header()
+	delete(header, "Content-Type")
+	delete(header, "Content-Length")
+	delete(header, "Content-Encoding")
+	if etag != "" {
+		header.Set("ETag", etag)
+	}
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
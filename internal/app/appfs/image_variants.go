@@ -0,0 +1,254 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package appfs
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	IMAGE_FORMAT_WEBP = "webp"
+	IMAGE_FORMAT_AVIF = "avif"
+
+	// maxImagePixels is the max size of the images for which variants are created, the decoded
+	// image is held in memory
+	maxImagePixels = 25_000_000
+)
+
+// ImageOptions are the options for creating the static image variants
+type ImageOptions struct {
+	Widths      []int
+	JpegQuality int
+	WebPCommand string // encoder command for the WebP variants, empty to skip WebP
+	AVIFCommand string // encoder command for the AVIF variants, empty to skip AVIF
+}
+
+// Enabled returns true if any variant is to be created
+func (o ImageOptions) Enabled() bool {
+	return len(o.Widths) > 0 || o.WebPCommand != "" || o.AVIFCommand != ""
+}
+
+// ImageVariant is a generated variant of a static image, saved in the same folder as the image
+type ImageVariant struct {
+	Name string
+	Data []byte
+}
+
+// IsVariantSource returns true if variants can be created for the file, which are the JPEG and
+// PNG files in the static folder
+func IsVariantSource(name string) bool {
+	if !strings.HasPrefix(name, "static/") {
+		return false
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg", ".png":
+		return true
+	}
+	return false
+}
+
+// ImageVariantName returns the name of the image variant for the width. The format is the file
+// extension of the variant, the extension of the image is used if format is empty.
+// For "static/img/photo.jpg", width 480 and format webp, the name is "static/img/photo-480w.webp"
+func ImageVariantName(name string, width int, format string) string {
+	ext := path.Ext(name)
+	if format != "" {
+		ext = "." + format
+	}
+	return strings.TrimSuffix(name, path.Ext(name)) + "-" + strconv.Itoa(width) + "w" + ext
+}
+
+// ImageWidth returns the width of the image, only the image header is decoded
+func ImageWidth(r io.Reader) (int, error) {
+	config, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return 0, err
+	}
+	return config.Width, nil
+}
+
+// VariantWidths returns the widths of the resized variants for an image of the given width, the
+// widths not smaller than the image are skipped
+func VariantWidths(widths []int, imageWidth int) []int {
+	ret := []int{}
+	for _, width := range widths {
+		if width > 0 && width < imageWidth && !slices.Contains(ret, width) {
+			ret = append(ret, width)
+		}
+	}
+	slices.Sort(ret)
+	return ret
+}
+
+// CreateImageVariants creates the variants for a static image. Resized variants are created in
+// the image format for each of the widths. The WebP and AVIF variants are created for each of the
+// widths and for the full image width, using the encoder commands
+func CreateImageVariants(ctx context.Context, name string, data []byte, options ImageOptions) ([]ImageVariant, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error reading image %s: %w", name, err)
+	}
+	if config.Width*config.Height > maxImagePixels {
+		return nil, fmt.Errorf("image %s is too large for creating variants: %dx%d", name, config.Width, config.Height)
+	}
+	if format != "jpeg" && format != "png" {
+		return nil, fmt.Errorf("image %s has unsupported format %s", name, format)
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("error decoding image %s: %w", name, err)
+	}
+	src := image.NewRGBA(image.Rect(0, 0, config.Width, config.Height))
+	draw.Draw(src, src.Bounds(), decoded, decoded.Bounds().Min, draw.Src)
+
+	quality := options.JpegQuality
+	if quality <= 0 || quality > 100 {
+		quality = jpeg.DefaultQuality
+	}
+	encoders := [][2]string{{IMAGE_FORMAT_WEBP, options.WebPCommand}, {IMAGE_FORMAT_AVIF, options.AVIFCommand}}
+	variants := []ImageVariant{}
+	for _, width := range append(VariantWidths(options.Widths, config.Width), config.Width) {
+		img := src
+		if width != config.Width {
+			img = resizeImage(src, width)
+			var buf bytes.Buffer
+			if format == "jpeg" {
+				err = jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+			} else {
+				err = png.Encode(&buf, img)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("error encoding image %s: %w", name, err)
+			}
+			variants = append(variants, ImageVariant{Name: ImageVariantName(name, width, ""), Data: buf.Bytes()})
+		}
+
+		for _, encoder := range encoders {
+			if encoder[1] == "" {
+				continue
+			}
+			encoded, err := runImageEncoder(ctx, encoder[1], img, encoder[0])
+			if err != nil {
+				return nil, fmt.Errorf("error creating %s variant for image %s: %w", encoder[0], name, err)
+			}
+			variants = append(variants, ImageVariant{Name: ImageVariantName(name, width, encoder[0]), Data: encoded})
+		}
+	}
+	return variants, nil
+}
+
+// runImageEncoder runs the encoder command, with the image written as a PNG file. The {input} and
+// {output} arguments are replaced with the input and output file paths
+func runImageEncoder(ctx context.Context, command string, img image.Image, format string) ([]byte, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return nil, fmt.Errorf("encoder command is empty")
+	}
+
+	dir, err := os.MkdirTemp("", "openrun-image-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	input := filepath.Join(dir, "input.png")
+	output := filepath.Join(dir, "output."+format)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(input, buf.Bytes(), 0600); err != nil {
+		return nil, err
+	}
+
+	replacer := strings.NewReplacer("{input}", input, "{output}", output)
+	for i, arg := range args {
+		args[i] = replacer.Replace(arg)
+	}
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return nil, fmt.Errorf("error running %s: %w: %s", args[0], err, strings.TrimSpace(string(out)))
+	}
+	return os.ReadFile(output)
+}
+
+// axisWeights are the weights of the source pixels for one destination pixel, along one axis
+type axisWeights struct {
+	start   int
+	weights []float32
+}
+
+// areaWeights returns the weights for scaling down srcLen pixels to dstLen pixels. Each
+// destination pixel is the average of the source pixels it covers, weighted by the overlap
+func areaWeights(srcLen, dstLen int) []axisWeights {
+	scale := float64(srcLen) / float64(dstLen)
+	ret := make([]axisWeights, dstLen)
+	for i := range dstLen {
+		low, high := float64(i)*scale, float64(i+1)*scale
+		start := int(low)
+		end := min(int(math.Ceil(high)), srcLen)
+		weights := make([]float32, end-start)
+		for j := start; j < end; j++ {
+			overlap := min(high, float64(j+1)) - max(low, float64(j))
+			weights[j-start] = float32(overlap / scale)
+		}
+		ret[i] = axisWeights{start: start, weights: weights}
+	}
+	return ret
+}
+
+// resizeImage scales down the image to the width, keeping the aspect ratio. The averaging is done
+// on the premultiplied RGBA values, so that transparent pixels do not darken the edges
+func resizeImage(src *image.RGBA, width int) *image.RGBA {
+	srcWidth, srcHeight := src.Bounds().Dx(), src.Bounds().Dy()
+	height := max(1, int(math.Round(float64(srcHeight)*float64(width)/float64(srcWidth))))
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+
+	xWeights := areaWeights(srcWidth, width)
+	yWeights := areaWeights(srcHeight, height)
+	row := make([]float32, width*4) // a source row scaled horizontally
+	sum := make([]float32, width*4)
+	for y, yw := range yWeights {
+		clear(sum)
+		for k, weight := range yw.weights {
+			srcRow := src.Pix[(yw.start+k)*src.Stride:]
+			for x, xw := range xWeights {
+				var r, g, b, a float32
+				for j, w := range xw.weights {
+					p := srcRow[(xw.start+j)*4:]
+					r += float32(p[0]) * w
+					g += float32(p[1]) * w
+					b += float32(p[2]) * w
+					a += float32(p[3]) * w
+				}
+				row[x*4], row[x*4+1], row[x*4+2], row[x*4+3] = r, g, b, a
+			}
+			for i, v := range row {
+				sum[i] += v * weight
+			}
+		}
+
+		dstRow := dst.Pix[y*dst.Stride:]
+		for i, v := range sum {
+			dstRow[i] = uint8(min(255, v+0.5))
+		}
+	}
+	return dst
+}
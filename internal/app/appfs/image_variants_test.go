@@ -0,0 +1,106 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package appfs
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func testImage(t *testing.T, width, height int, encode func(*bytes.Buffer, image.Image) error) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for x := range width {
+		for y := range height {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	testutil.AssertNoError(t, encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestImageVariantName(t *testing.T) {
+	testutil.AssertEqualsString(t, "jpg", "static/img/photo-480w.jpg", ImageVariantName("static/img/photo.jpg", 480, ""))
+	testutil.AssertEqualsString(t, "webp", "static/img/photo-480w.webp", ImageVariantName("static/img/photo.jpg", 480, IMAGE_FORMAT_WEBP))
+	testutil.AssertEqualsString(t, "no ext", "static/photo-10w.avif", ImageVariantName("static/photo", 10, IMAGE_FORMAT_AVIF))
+
+	testutil.AssertEqualsBool(t, "png", true, IsVariantSource("static/a/b.PNG"))
+	testutil.AssertEqualsBool(t, "svg", false, IsVariantSource("static/a/b.svg"))
+	testutil.AssertEqualsBool(t, "not static", false, IsVariantSource("static_root/b.png"))
+}
+
+func TestVariantWidths(t *testing.T) {
+	widths := VariantWidths([]int{1920, 480, 0, 960, 480, 2000}, 1920)
+	testutil.AssertEqualsInt(t, "count", 2, len(widths))
+	testutil.AssertEqualsInt(t, "first", 480, widths[0])
+	testutil.AssertEqualsInt(t, "second", 960, widths[1])
+}
+
+func TestResizeImage(t *testing.T) {
+	// Alternate white and transparent columns average to half transparent white
+	src := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	for x := range 4 {
+		for y := range 2 {
+			if x%2 == 0 {
+				src.Set(x, y, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+			}
+		}
+	}
+	dst := resizeImage(src, 2)
+	testutil.AssertEqualsInt(t, "width", 2, dst.Bounds().Dx())
+	testutil.AssertEqualsInt(t, "height", 1, dst.Bounds().Dy())
+	r, g, b, a := dst.At(1, 0).RGBA()
+	testutil.AssertEqualsInt(t, "alpha", 128, int(a>>8))
+	testutil.AssertEqualsBool(t, "color", true, r == a && g == a && b == a)
+
+	// Scaling by a fraction weights the partially covered pixels
+	dst = resizeImage(src, 3)
+	testutil.AssertEqualsInt(t, "height", 2, dst.Bounds().Dy())
+	_, _, _, a = dst.At(0, 0).RGBA()
+	testutil.AssertEqualsInt(t, "alpha", 191, int(a>>8))
+}
+
+func TestCreateImageVariants(t *testing.T) {
+	data := testImage(t, 100, 50, func(buf *bytes.Buffer, img image.Image) error { return png.Encode(buf, img) })
+	variants, err := CreateImageVariants(context.Background(), "static/photo.png", data, ImageOptions{Widths: []int{200, 40, 80}})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "count", 2, len(variants))
+	testutil.AssertEqualsString(t, "name", "static/photo-40w.png", variants[0].Name)
+	testutil.AssertEqualsString(t, "name", "static/photo-80w.png", variants[1].Name)
+	config, format, err := image.DecodeConfig(bytes.NewReader(variants[1].Data))
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "format", "png", format)
+	testutil.AssertEqualsInt(t, "width", 80, config.Width)
+	testutil.AssertEqualsInt(t, "height", 40, config.Height)
+
+	// The encoder command creates the variants for the resized widths and the full width
+	data = testImage(t, 100, 50, func(buf *bytes.Buffer, img image.Image) error { return jpeg.Encode(buf, img, nil) })
+	variants, err = CreateImageVariants(context.Background(), "static/photo.jpg", data,
+		ImageOptions{Widths: []int{50}, JpegQuality: 90, WebPCommand: "cp {input} {output}"})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "count", 3, len(variants))
+	names := []string{"static/photo-50w.jpg", "static/photo-50w.webp", "static/photo-100w.webp"}
+	formats := []string{"jpeg", "png", "png"}
+	widths := []int{50, 50, 100}
+	for i, variant := range variants {
+		testutil.AssertEqualsString(t, "name", names[i], variant.Name)
+		config, format, err := image.DecodeConfig(bytes.NewReader(variant.Data))
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqualsString(t, "format", formats[i], format)
+		testutil.AssertEqualsInt(t, "width", widths[i], config.Width)
+	}
+
+	_, err = CreateImageVariants(context.Background(), "static/photo.jpg", data, ImageOptions{WebPCommand: "false {input}"})
+	testutil.AssertErrorContains(t, err, "error creating webp variant for image static/photo.jpg")
+	_, err = CreateImageVariants(context.Background(), "static/photo.jpg", []byte("not an image"), ImageOptions{Widths: []int{50}})
+	testutil.AssertErrorContains(t, err, "error reading image static/photo.jpg")
+}
@@ -41,6 +41,12 @@ type CompressedReader interface {
 	ReadCompressed() (data []byte, compressionType string, err error)
 }
 
+// VariantReader is implemented by files which have precompressed variants stored in addition to
+// the brotli compressed content, for the clients which do not accept brotli
+type VariantReader interface {
+	ReadVariant(encoding string) ([]byte, error) // returns nil if the variant is not available
+}
+
 // WritableFS is the interface for the writable underlying file system used by AppFS
 type WritableFS interface {
 	ReadableFS
@@ -227,6 +233,15 @@ func (f *SourceFs) HashName(name string) string {
 	return hashname
 }
 
+// ContentHash returns the hex sha256 hash of the file contents, empty if the file cannot be read.
+// The hash is cached along with the hash name
+func (f *SourceFs) ContentHash(name string) string {
+	hashName := f.HashName(name)
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	return f.hashToName[hashName][1]
+}
+
 // FormatName returns a hash name that inserts hash before the filename's
 // extension. If no extension exists on filename then the hash is appended.
 // Returns blank string the original filename if hash is blank. Returns a blank
@@ -287,8 +302,8 @@ var hashSuffixRegex = regexp.MustCompile(`-[0-9a-f]{64}`)
 // cache files on the client since the file hash is in the filename.
 //
 // Because FileServer is focused on small known path files, several features
-// of http.FileServer have been removed including canonicalizing directories,
-// defaulting index.html pages, precondition checks, & content range headers.
+// of http.FileServer have been removed including canonicalizing directories
+// and defaulting index.html pages.
 func FileServer(fsys *SourceFs, indexPage string) http.Handler {
 	return &fsHandler{fsys: fsys, indexPage: indexPage}
 }
@@ -344,10 +359,20 @@ func (h *fsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Cache the file aggressively if the file contains a hash.
+	// Cache the file aggressively if the file contains a hash. Files requested without the
+	// hash get the same strong ETag, so that the client can revalidate
 	if hash != "" {
 		w.Header().Set("Cache-Control", `public, max-age=31536000`)
-		w.Header().Set("ETag", "\""+hash+"\"")
+	} else {
+		hash = h.fsys.ContentHash(filename)
+	}
+	etag := ""
+	if hash != "" {
+		etag = "\"" + hash + "\""
+		w.Header().Set("ETag", etag)
+	}
+	if CheckNotModified(w, r, etag, fi.ModTime()) {
+		return
 	}
 
 	seeker, ok := f.(io.ReadSeeker)
@@ -356,8 +381,8 @@ func (h *fsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// If this is a request without Range headers and brotli encoding is accepted,
-	// Return the data which is already in a compressed form
+	// If this is a request without Range headers and brotli or gzip encoding is accepted,
+	// return the data which is already in a compressed form
 	served, err := h.serveCompressed(w, r, filename, fi.ModTime(), seeker)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -370,69 +395,107 @@ func (h *fsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	http.ServeContent(w, r, filename, fi.ModTime(), seeker)
 }
 
-const COMPRESSION_TYPE = "br" // brotli uses br as the encoding type
+const (
+	COMPRESSION_TYPE = "br"   // brotli uses br as the encoding type
+	GZIP_ENCODING    = "gzip" // the encoding of the precompressed variant
 
-func (h *fsHandler) canServeCompressed(r *http.Request) bool {
-	rangeHeader := r.Header.Get("Range")
-	if rangeHeader != "" {
+	// PRECOMPRESS_MIN_SIZE is the min size for creating the gzip variant, smaller files are
+	// not compressed by the compression middleware either
+	PRECOMPRESS_MIN_SIZE = 1024
+)
+
+// precompressExtensions are the file types for which the gzip variant is created, the text based
+// types which compress well
+var precompressExtensions = map[string]bool{
+	".js": true, ".mjs": true, ".css": true, ".html": true, ".htm": true, ".svg": true, ".json": true,
+	".map": true, ".xml": true, ".txt": true, ".wasm": true, ".ttf": true, ".otf": true, ".eot": true,
+}
+
+// PrecompressVariant reports whether the gzip variant is created for the file when the app is
+// installed. Images and archives are not compressed further by gzip, they are skipped
+func PrecompressVariant(name string, size int) bool {
+	return size >= PRECOMPRESS_MIN_SIZE && precompressExtensions[strings.ToLower(path.Ext(name))]
+}
+
+// acceptedEncodings returns the precompressed encodings accepted by the request. Encodings with
+// q=0 are not accepted. Nothing is accepted for range requests, since the range is on the
+// uncompressed content
+func acceptedEncodings(r *http.Request) (brotli, gzip bool) {
+	if r.Header.Get("Range") != "" {
 		// Range headers are being used, fallback to http.ServeContent
-		return false
+		return false, false
 	}
 
-	encodingHeader := r.Header.Get("Accept-Encoding")
-	acceptedEncodings := strings.Split(strings.ToLower(encodingHeader), ",")
-	brotliMatchFound := false
-
-	for _, acceptedEncoding := range acceptedEncodings {
-		if strings.TrimSpace(acceptedEncoding) == COMPRESSION_TYPE {
-			brotliMatchFound = true
-			break
+	for part := range strings.SplitSeq(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(part, ";")
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok && strings.Trim(value, "0.") == "" {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case COMPRESSION_TYPE:
+			brotli = true
+		case GZIP_ENCODING:
+			gzip = true
 		}
 	}
-
-	return brotliMatchFound
+	return brotli, gzip
 }
 
 var unixEpochTime = time.Unix(0, 0)
 
 // serveCompressed checks if the compressed file data can be streamed directly to the client, without
 // the need to decompress and then recompress. If the client accepts brotli compressed data and there are no
-// range headers, then this optimization can be used.
+// range headers, then this optimization can be used. Clients which accept gzip but not brotli get the
+// gzip variant, if one was created when the app was installed.
 func (h *fsHandler) serveCompressed(w http.ResponseWriter, r *http.Request, filename string, modtime time.Time, content io.ReadSeeker) (bool, error) {
 	compressedReader, ok := content.(CompressedReader)
 	if !ok {
 		// Disk backed files are not stored compressed, skip the header checks
 		return false, nil
 	}
-	if !h.canServeCompressed(r) {
+	acceptsBrotli, acceptsGzip := acceptedEncodings(r)
+	contentType := mime.TypeByExtension(filepath.Ext(filename))
+	if contentType == "" || (!acceptsBrotli && !acceptsGzip) {
 		return false, nil
 	}
 
-	data, compressionType, err := compressedReader.ReadCompressed()
-	if err != nil {
-		return false, err
+	if acceptsBrotli {
+		data, compressionType, err := compressedReader.ReadCompressed()
+		if err != nil {
+			return false, err
+		}
+		if compressionType == COMPRESSION_TYPE {
+			writeCompressed(w, contentType, modtime, COMPRESSION_TYPE, data)
+			return true, nil
+		}
+		// the data is not compressed with brotli, try the gzip variant
 	}
 
-	if compressionType != COMPRESSION_TYPE {
-		// the data is not compressed with brotli, fallback to http.ServeContent
+	variantReader, ok := content.(VariantReader)
+	if !acceptsGzip || !ok {
 		return false, nil
 	}
-
-	contentType := mime.TypeByExtension(filepath.Ext(filename))
-	if contentType == "" {
+	data, err := variantReader.ReadVariant(GZIP_ENCODING)
+	if err != nil {
+		return false, err
+	}
+	if data == nil {
+		// No variant, the compression middleware compresses the response if enabled
 		return false, nil
 	}
+	writeCompressed(w, contentType, modtime, GZIP_ENCODING, data)
+	return true, nil
+}
 
+func writeCompressed(w http.ResponseWriter, contentType string, modtime time.Time, encoding string, data []byte) {
 	if !modtime.IsZero() && !modtime.Equal(unixEpochTime) {
 		w.Header().Set("Last-Modified", modtime.UTC().Format(http.TimeFormat))
 	}
 	w.Header().Set("Content-Type", contentType)
-	w.Header().Set("Content-Encoding", COMPRESSION_TYPE)
+	w.Header().Set("Content-Encoding", encoding)
 	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(data)))
 	w.Header().Set("X-OpenRun-Compressed", "true")
 	w.Header().Add("Vary", "Accept-Encoding")
 	w.WriteHeader(http.StatusOK)
 	w.Write(data) //nolint:errcheck
-
-	return true, nil
 }
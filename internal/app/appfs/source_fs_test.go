@@ -0,0 +1,83 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package appfs
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+// variantFile is a file with brotli compressed content and an optional gzip variant
+type variantFile struct {
+	*bytes.Reader
+	compressionType string
+	gzipVariant     []byte
+}
+
+func (f *variantFile) ReadCompressed() ([]byte, string, error) {
+	return []byte("brotli data"), f.compressionType, nil
+}
+
+func (f *variantFile) ReadVariant(encoding string) ([]byte, error) {
+	if encoding != GZIP_ENCODING {
+		return nil, nil
+	}
+	return f.gzipVariant, nil
+}
+
+func TestServeCompressed(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		rangeHeader    string
+		file           *variantFile
+		filename       string
+		wantEncoding   string
+		wantBody       string
+	}{
+		{name: "brotli", acceptEncoding: "gzip, deflate, br", file: &variantFile{compressionType: "br", gzipVariant: []byte("gzip data")},
+			wantEncoding: "br", wantBody: "brotli data"},
+		{name: "gzip", acceptEncoding: "gzip, deflate", file: &variantFile{compressionType: "br", gzipVariant: []byte("gzip data")},
+			wantEncoding: "gzip", wantBody: "gzip data"},
+		{name: "brotli disabled", acceptEncoding: "br;q=0, gzip", file: &variantFile{compressionType: "br", gzipVariant: []byte("gzip data")},
+			wantEncoding: "gzip", wantBody: "gzip data"},
+		{name: "no variant", acceptEncoding: "gzip", file: &variantFile{compressionType: "br"}},
+		{name: "not compressed", acceptEncoding: "br", file: &variantFile{}},
+		{name: "not accepted", acceptEncoding: "deflate", file: &variantFile{compressionType: "br", gzipVariant: []byte("gzip data")}},
+		{name: "range", acceptEncoding: "br, gzip", rangeHeader: "bytes=0-10", file: &variantFile{compressionType: "br", gzipVariant: []byte("gzip data")}},
+		{name: "unknown type", acceptEncoding: "br", filename: "data.unknownext", file: &variantFile{compressionType: "br"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/static/app.js", nil)
+			r.Header.Set("Accept-Encoding", tc.acceptEncoding)
+			if tc.rangeHeader != "" {
+				r.Header.Set("Range", tc.rangeHeader)
+			}
+			w := httptest.NewRecorder()
+			tc.file.Reader = bytes.NewReader([]byte("uncompressed"))
+			filename := tc.filename
+			if filename == "" {
+				filename = "app.js"
+			}
+
+			h := &fsHandler{}
+			served, err := h.serveCompressed(w, r, filename, time.Now(), tc.file)
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqualsBool(t, "served", tc.wantEncoding != "", served)
+			testutil.AssertEqualsString(t, "encoding", tc.wantEncoding, w.Header().Get("Content-Encoding"))
+			testutil.AssertEqualsString(t, "body", tc.wantBody, w.Body.String())
+		})
+	}
+}
+
+func TestPrecompressVariant(t *testing.T) {
+	testutil.AssertEqualsBool(t, "js", true, PrecompressVariant("static/app.JS", 2048))
+	testutil.AssertEqualsBool(t, "small", false, PrecompressVariant("static/app.js", 100))
+	testutil.AssertEqualsBool(t, "image", false, PrecompressVariant("static/image.png", 2048))
+}
@@ -18,6 +18,9 @@ type RouteConfig struct {
 	// glob patterns for files which are excluded from container content change check
 	ContainerExclude []string `json:"container_exclude"`
 	StaticFromDisk   bool     `json:"static_from_disk"`
+
+	// blocks which other apps can include with the appBlock template function, "*" for all
+	SharedBlocks []string `json:"shared_blocks"`
 }
 
 type HtmxConfig struct {
@@ -37,6 +37,9 @@ const (
 	RESULT                = "result"
 	AUDIT                 = "audit"
 	OUTPUT                = "output"
+	CRON                  = "cron"
+	GRAPHQL               = "graphql"
+	MARKDOWN              = "markdown"
 	CONTAINER_URL         = "<CONTAINER_URL>" // special url to use for proxying to the container
 	DEFAULT_REDIRECT_CODE = 303
 )
@@ -72,15 +75,16 @@ var (
 func createAppBuiltin(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var customLayout, staticOnly, singleFile, redirectBarePath starlark.Bool
 	var name, index starlark.String
-	var routes, actions *starlark.List
-	var settings *starlark.Dict
+	var routes, actions, crons *starlark.List
+	var settings, env *starlark.Dict
 	var permissions, libraries *starlark.List
 	var style *starlarkstruct.Struct
 	var containerConfig starlark.Value
 	if err := starlark.UnpackArgs(APP, args, kwargs, "name", &name,
 		"routes?", &routes, "style?", &style, "permissions?", &permissions, "libraries?", &libraries, "settings?",
 		&settings, "custom_layout?", &customLayout, "container?", &containerConfig, "actions?", &actions,
-		"static_only?", &staticOnly, "index?", &index, "single_file?", &singleFile, "redirect_bare_path?", &redirectBarePath); err != nil {
+		"static_only?", &staticOnly, "index?", &index, "single_file?", &singleFile, "redirect_bare_path?", &redirectBarePath,
+		"crons?", &crons, "env?", &env); err != nil {
 		return nil, fmt.Errorf("error unpacking app args: %w", err)
 	}
 
@@ -93,9 +97,15 @@ func createAppBuiltin(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tup
 	if libraries == nil {
 		libraries = starlark.NewList([]starlark.Value{})
 	}
+	if crons == nil {
+		crons = starlark.NewList([]starlark.Value{})
+	}
 	if settings == nil {
 		settings = starlark.NewDict(0)
 	}
+	if env == nil {
+		env = starlark.NewDict(0)
+	}
 
 	if permissions == nil {
 		permissions = starlark.NewList([]starlark.Value{})
@@ -109,6 +119,8 @@ func createAppBuiltin(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tup
 		"permissions":        permissions,
 		"libraries":          libraries,
 		"actions":            actions,
+		"crons":              crons,
+		"env":                env,
 		"static_only":        staticOnly,
 		"index":              index,
 		"single_file":        singleFile,
@@ -416,6 +428,25 @@ func createOutputBuiltin(thread *starlark.Thread, _ *starlark.Builtin, args star
 	return output, nil
 }
 
+func createCronBuiltin(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var schedule, name starlark.String
+	var handler starlark.Callable
+	if err := starlark.UnpackArgs(CRON, args, kwargs, "schedule", &schedule, "handler", &handler, "name?", &name); err != nil {
+		return nil, fmt.Errorf("error unpacking cron args: %w", err)
+	}
+
+	if name == "" {
+		name = starlark.String(handler.Name())
+	}
+
+	fields := starlark.StringDict{
+		"schedule": schedule,
+		"handler":  handler,
+		"name":     name,
+	}
+	return starlarkstruct.FromStringDict(starlark.String(CRON), fields), nil
+}
+
 func createProxyBuiltin(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var path starlark.String
 	var config starlark.Value
@@ -434,7 +465,10 @@ func createAPIBuiltin(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tup
 	var path, rtype starlark.String
 	var handler starlark.Callable
 	var method starlark.String
-	if err := starlark.UnpackArgs(API, args, kwargs, "path", &path, "handler?", &handler, "method?", &method, "type?", &rtype); err != nil {
+	var request, response starlark.Value
+	var query *starlark.Dict
+	if err := starlark.UnpackArgs(API, args, kwargs, "path", &path, "handler?", &handler, "method?", &method, "type?", &rtype,
+		"request?", &request, "response?", &response, "query?", &query); err != nil {
 		return nil, fmt.Errorf("error unpacking api args: %w", err)
 	}
 
@@ -458,9 +492,79 @@ func createAPIBuiltin(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tup
 	if handler != nil {
 		fields["handler"] = handler
 	}
+	// The request, response and query types are used only for the OpenAPI spec
+	if request != nil {
+		fields["request"] = request
+	}
+	if response != nil {
+		fields["response"] = response
+	}
+	if query != nil {
+		fields["query"] = query
+	}
 	return starlarkstruct.FromStringDict(starlark.String(API), fields), nil
 }
 
+func createGraphQLBuiltin(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var path, schema starlark.String
+	var resolvers *starlark.Dict
+	introspection := starlark.Bool(true)
+	maxBatch := starlark.MakeInt(10)
+	maxDepth := starlark.MakeInt(15)
+	if err := starlark.UnpackArgs(GRAPHQL, args, kwargs, "path", &path, "resolvers", &resolvers, "schema?", &schema,
+		"introspection?", &introspection, "max_batch?", &maxBatch, "max_depth?", &maxDepth); err != nil {
+		return nil, fmt.Errorf("error unpacking graphql args: %w", err)
+	}
+
+	if schema == "" {
+		schema = "schema.graphql"
+	}
+	for _, item := range resolvers.Items() {
+		key, ok := item[0].(starlark.String)
+		if !ok || !strings.Contains(string(key), ".") {
+			return nil, fmt.Errorf("graphql resolver key %s should be a string of the form \"Type.field\"", item[0])
+		}
+		if _, ok := item[1].(starlark.Callable); !ok {
+			return nil, fmt.Errorf("graphql resolver %s is not a function", key)
+		}
+	}
+
+	fields := starlark.StringDict{
+		"path":          path,
+		"schema":        schema,
+		"resolvers":     resolvers,
+		"introspection": introspection,
+		"max_batch":     maxBatch,
+		"max_depth":     maxDepth,
+	}
+	return starlarkstruct.FromStringDict(starlark.String(GRAPHQL), fields), nil
+}
+
+func createMarkdownBuiltin(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var path, dir, full, index starlark.String
+	if err := starlark.UnpackArgs(MARKDOWN, args, kwargs, "path", &path, "dir?", &dir, "full?", &full, "index?", &index); err != nil {
+		return nil, fmt.Errorf("error unpacking markdown args: %w", err)
+	}
+
+	if dir == "" {
+		dir = "docs"
+	}
+	if index == "" {
+		index = "index.md"
+	}
+	if !strings.HasSuffix(string(index), ".md") {
+		return nil, fmt.Errorf("markdown index file %s should have a .md extension", index)
+	}
+
+	fields := starlark.StringDict{
+		"path":  path,
+		"dir":   dir,
+		"full":  full,
+		"index": index,
+	}
+	return starlarkstruct.FromStringDict(starlark.String(MARKDOWN), fields), nil
+}
+
 func CreateConfigBuiltin(nodeConfig types.NodeConfig, allowedEnv []string) func(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	return func(thread *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 		var key starlark.String
@@ -557,7 +661,13 @@ func CreateBuiltin(nodeConfig types.NodeConfig, allowedEnv []string) starlark.St
 					RESULT:     starlark.NewBuiltin(RESULT, createResultBuiltin),
 					AUDIT:      starlark.NewBuiltin(AUDIT, createAuditBuiltin),
 					OUTPUT:     starlark.NewBuiltin(OUTPUT, createOutputBuiltin),
+					CRON:       starlark.NewBuiltin(CRON, createCronBuiltin),
+					GRAPHQL:    starlark.NewBuiltin(GRAPHQL, createGraphQLBuiltin),
+					MARKDOWN:   starlark.NewBuiltin(MARKDOWN, createMarkdownBuiltin),
 					CONFIG:     starlark.NewBuiltin(CONFIG, CreateConfigBuiltin(nodeConfig, allowedEnv)),
+					BASIC:      starlark.NewBuiltin(BASIC, createBasicBuiltin),
+					BEARER:     starlark.NewBuiltin(BEARER, createBearerBuiltin),
+					TOKEN:      starlark.NewBuiltin(TOKEN, createTokenBuiltin),
 
 					GET:             starlark.String(GET),
 					POST:            starlark.String(POST),
@@ -12,6 +12,8 @@ const (
 	CONFIG_LOCK_FILE_NAME = "config_gen.lock"
 	SCHEMA_FILE_NAME      = "schema.star"
 	PARAMS_FILE_NAME      = "params.star"
+	CONTRACT_FILE_NAME    = "contract.star"
+	SPEC_PERMS_FILE_NAME  = "spec_permissions.toml"
 	BUILTIN_PLUGIN_SUFFIX = "in"
 	STARLARK_FILE_SUFFIX  = ".star"
 	INDEX_FILE            = "index.go.html"
@@ -0,0 +1,141 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package apptype
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+const (
+	CHECK = "check"
+)
+
+// ContractCheck is a check on a path served by the proxied backend, declared in contract.star.
+// The response should have the expected status. Each expected header should contain the
+// given value and the body should contain (and not contain) the given fragments
+type ContractCheck struct {
+	Name          string
+	Path          string
+	Method        string
+	Headers       map[string]string // request headers
+	Body          string            // request body
+	Status        int
+	ExpectHeaders map[string]string
+	Contains      []string
+	NotContains   []string
+}
+
+func LoadContractChecks(fileName string, data []byte) ([]ContractCheck, error) {
+	checks := []ContractCheck{}
+	names := map[string]bool{}
+
+	checkBuiltin := func(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var path, name, method, body starlark.String
+		var headers, expectHeaders *starlark.Dict
+		var contains, notContains *starlark.List
+		var status = starlark.MakeInt(http.StatusOK)
+		if err := starlark.UnpackArgs(CHECK, args, kwargs, "path", &path, "name?", &name, "method?", &method,
+			"headers?", &headers, "body?", &body, "status?", &status, "expect_headers?", &expectHeaders,
+			"contains?", &contains, "not_contains?", &notContains); err != nil {
+			return nil, err
+		}
+
+		check := ContractCheck{
+			Path:   path.GoString(),
+			Method: strings.ToUpper(method.GoString()),
+			Body:   body.GoString(),
+		}
+		if !strings.HasPrefix(check.Path, "/") {
+			return nil, fmt.Errorf("check path %s should start with /", check.Path)
+		}
+		if check.Method == "" {
+			check.Method = http.MethodGet
+		}
+		check.Name = name.GoString()
+		if check.Name == "" {
+			check.Name = check.Method + " " + check.Path
+		}
+		if names[check.Name] {
+			return nil, fmt.Errorf("check %s already defined", check.Name)
+		}
+		names[check.Name] = true
+
+		statusInt, ok := status.Int64()
+		if !ok || statusInt < 100 || statusInt > 599 {
+			return nil, fmt.Errorf("invalid status %s for check %s", status, check.Name)
+		}
+		check.Status = int(statusInt)
+
+		var err error
+		if check.Headers, err = stringDict(headers, "headers"); err != nil {
+			return nil, err
+		}
+		if check.ExpectHeaders, err = stringDict(expectHeaders, "expect_headers"); err != nil {
+			return nil, err
+		}
+		if check.Contains, err = stringList(contains, "contains"); err != nil {
+			return nil, err
+		}
+		if check.NotContains, err = stringList(notContains, "not_contains"); err != nil {
+			return nil, err
+		}
+
+		checks = append(checks, check)
+		return starlarkstruct.FromStringDict(starlark.String(CHECK), starlark.StringDict{
+			"name": starlark.String(check.Name),
+			"path": path,
+		}), nil
+	}
+
+	builtins := starlark.StringDict{
+		CHECK: starlark.NewBuiltin(CHECK, checkBuiltin),
+	}
+
+	thread := &starlark.Thread{
+		Name:  fileName,
+		Print: func(_ *starlark.Thread, msg string) { fmt.Println(msg) },
+	}
+
+	if _, err := starlark.ExecFileOptions(&syntax.FileOptions{}, thread, fileName, data, builtins); err != nil {
+		return nil, fmt.Errorf("error loading contract checks: %w", err)
+	}
+	return checks, nil
+}
+
+func stringDict(dict *starlark.Dict, key string) (map[string]string, error) {
+	ret := map[string]string{}
+	if dict == nil {
+		return ret, nil
+	}
+	for _, item := range dict.Items() {
+		k, ok1 := item[0].(starlark.String)
+		v, ok2 := item[1].(starlark.String)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("%s should be a dict of strings", key)
+		}
+		ret[k.GoString()] = v.GoString()
+	}
+	return ret, nil
+}
+
+func stringList(list *starlark.List, key string) ([]string, error) {
+	ret := []string{}
+	if list == nil {
+		return ret, nil
+	}
+	for i := range list.Len() {
+		v, ok := list.Index(i).(starlark.String)
+		if !ok {
+			return nil, fmt.Errorf("%s should be a list of strings", key)
+		}
+		ret = append(ret, v.GoString())
+	}
+	return ret, nil
+}
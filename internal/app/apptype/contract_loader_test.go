@@ -0,0 +1,39 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package apptype
+
+import (
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func TestLoadContractChecks(t *testing.T) {
+	checks, err := LoadContractChecks("contract.star", []byte(`
+check("/health", contains=["ok"])
+check("/api/items", name="items", method="post", headers={"Accept": "application/json"}, body="{}",
+      status=201, expect_headers={"Content-Type": "json"}, not_contains=["error"])
+`))
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "count", 2, len(checks))
+	testutil.AssertEqualsString(t, "default name", "GET /health", checks[0].Name)
+	testutil.AssertEqualsInt(t, "default status", 200, checks[0].Status)
+	testutil.AssertEqualsString(t, "contains", "ok", checks[0].Contains[0])
+	testutil.AssertEqualsString(t, "name", "items", checks[1].Name)
+	testutil.AssertEqualsString(t, "method", "POST", checks[1].Method)
+	testutil.AssertEqualsInt(t, "status", 201, checks[1].Status)
+	testutil.AssertEqualsString(t, "header", "application/json", checks[1].Headers["Accept"])
+	testutil.AssertEqualsString(t, "expect header", "json", checks[1].ExpectHeaders["Content-Type"])
+	testutil.AssertEqualsString(t, "not contains", "error", checks[1].NotContains[0])
+
+	_, err = LoadContractChecks("contract.star", []byte(`check("health")`))
+	testutil.AssertErrorContains(t, err, "should start with /")
+	_, err = LoadContractChecks("contract.star", []byte(`check("/a")
+check("/a")`))
+	testutil.AssertErrorContains(t, err, "already defined")
+	_, err = LoadContractChecks("contract.star", []byte(`check("/a", status=1000)`))
+	testutil.AssertErrorContains(t, err, "invalid status")
+	_, err = LoadContractChecks("contract.star", []byte(`check("/a", contains=[1])`))
+	testutil.AssertErrorContains(t, err, "contains should be a list of strings")
+}
@@ -6,7 +6,9 @@ package apptype
 import (
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -21,6 +23,16 @@ const (
 	PARAM = "param"
 )
 
+// The param types in addition to the basic starlark types. The values are strings, validated
+// for the type when the app is loaded and when the param is updated
+const (
+	ENUM   starlark_type.TypeName = "ENUM"   // one of the values listed in values
+	SECRET starlark_type.TypeName = "SECRET" // a secret reference like {{secret "name"}}, plain text values are not allowed
+	URL    starlark_type.TypeName = "URL"    // an absolute url, like https://example.com
+)
+
+var secretRefRegex = regexp.MustCompile(`^\{\{\s*secret(_from)?\s.*\}\}$`)
+
 type DisplayType string
 
 const (
@@ -39,10 +51,128 @@ type AppParam struct {
 	DefaultValue       starlark.Value
 	DisplayType        DisplayType
 	DisplayTypeOptions string
+	Values             []string       // the allowed values for an enum
+	Regex              *regexp.Regexp // the pattern the value has to match, for string types
+}
+
+// IsStringType returns true if the param value is a string
+func IsStringType(typeName starlark_type.TypeName) bool {
+	return typeName == starlark_type.STRING || typeName == ENUM || typeName == SECRET || typeName == URL
+}
+
+// Validate checks the value, in the string format, against the param type and validation rules.
+// The type conversion is done by ParamStringToType
+func (p *AppParam) Validate(valueStr string) error {
+	if !IsStringType(p.Type) || (valueStr == "" && !p.Required) {
+		return nil
+	}
+	switch p.Type {
+	case ENUM:
+		if !slices.Contains(p.Values, valueStr) {
+			return fmt.Errorf("param %s value %q is not one of the allowed values %s", p.Name, valueStr, strings.Join(p.Values, ", "))
+		}
+	case SECRET:
+		if !secretRefRegex.MatchString(strings.TrimSpace(valueStr)) {
+			return fmt.Errorf(`param %s requires a secret reference like {{secret "name"}}, plain text value is not allowed`, p.Name)
+		}
+	case URL:
+		u, err := url.Parse(valueStr)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("param %s value %q is not an absolute url, like https://example.com", p.Name, valueStr)
+		}
+	}
+	if p.Regex != nil && !p.Regex.MatchString(valueStr) {
+		return fmt.Errorf("param %s value %q does not match the pattern %s", p.Name, valueStr, p.Regex)
+	}
+	return nil
+}
+
+// DefaultString returns the default value in the string format used for the param values,
+// empty if the param has no default
+func (p *AppParam) DefaultString() (string, error) {
+	if p.DefaultValue == nil || p.DefaultValue == starlark.None {
+		return "", nil
+	}
+	switch p.Type {
+	case starlark_type.STRING, ENUM, SECRET, URL:
+		return string(p.DefaultValue.(starlark.String)), nil
+	case starlark_type.INT:
+		intVal, ok := p.DefaultValue.(starlark.Int).Int64()
+		if !ok {
+			return "", fmt.Errorf("param %s is not an int", p.Name)
+		}
+		return strconv.FormatInt(intVal, 10), nil
+	case starlark_type.BOOLEAN:
+		return strconv.FormatBool(bool(p.DefaultValue.(starlark.Bool))), nil
+	case starlark_type.DICT, starlark_type.LIST:
+		val, err := starlark_type.UnmarshalStarlark(p.DefaultValue)
+		if err != nil {
+			return "", err
+		}
+		jsonVal, err := json.Marshal(val)
+		if err != nil {
+			return "", err
+		}
+		return string(jsonVal), nil
+	}
+	return "", fmt.Errorf("unknown type %s for %s", p.Type, p.Name)
 }
 
-func ReadParamInfo(fileName string, inp []byte, serverConfig *types.ServerConfig) (map[string]AppParam, error) {
-	paramInfo, err := LoadParamInfo(fileName, inp, serverConfig)
+// Info returns the param definition in the API format
+func (p *AppParam) Info() (types.AppParamInfo, error) {
+	defaultValue, err := p.DefaultString()
+	if err != nil {
+		return types.AppParamInfo{}, err
+	}
+	info := types.AppParamInfo{
+		Name:        p.Name,
+		Description: p.Description,
+		Type:        string(p.Type),
+		Required:    p.Required,
+		HasDefault:  p.DefaultValue != nil && p.DefaultValue != starlark.None,
+		Default:     defaultValue,
+		DisplayType: string(p.DisplayType),
+		Values:      p.Values,
+	}
+	if p.Regex != nil {
+		info.Regex = p.Regex.String()
+	}
+	return info, nil
+}
+
+// ParamFromInfo creates a param from the API format definition, for validating values on the client
+func ParamFromInfo(info types.AppParamInfo) (AppParam, error) {
+	param := AppParam{
+		Name:        info.Name,
+		Description: info.Description,
+		Required:    info.Required,
+		Type:        starlark_type.TypeName(info.Type),
+		DisplayType: DisplayType(info.DisplayType),
+		Values:      info.Values,
+	}
+	if info.Regex != "" {
+		var err error
+		if param.Regex, err = regexp.Compile(info.Regex); err != nil {
+			return AppParam{}, fmt.Errorf("invalid regex for param %s: %w", info.Name, err)
+		}
+	}
+	return param, nil
+}
+
+// ValidateValue checks the value, in the string format, against the param type and the
+// validation rules
+func (p *AppParam) ValidateValue(valueStr string) error {
+	if _, err := ParamStringToType(p.Name, p.Type, valueStr); err != nil {
+		return err
+	}
+	if IsStringType(p.Type) && p.Required && valueStr == "" {
+		return fmt.Errorf("param %s is a required param, value cannot be empty", p.Name)
+	}
+	return p.Validate(valueStr)
+}
+
+func ReadParamInfo(fileName string, inp []byte, serverConfig *types.ServerConfig, sandbox *Sandbox) (map[string]AppParam, error) {
+	paramInfo, err := LoadParamInfo(fileName, inp, serverConfig, sandbox)
 	if err != nil {
 		return nil, err
 	}
@@ -74,9 +204,13 @@ func validateParamInfo(paramInfo map[string]AppParam) error {
 			if _, ok := p.DefaultValue.(starlark.Int); !ok {
 				return fmt.Errorf("param %s is of type int but default value is not an int", p.Name)
 			}
-		case starlark_type.STRING:
-			if _, ok := p.DefaultValue.(starlark.String); !ok {
-				return fmt.Errorf("param %s is of type string but default value is not a string", p.Name)
+		case starlark_type.STRING, ENUM, SECRET, URL:
+			defaultValue, ok := p.DefaultValue.(starlark.String)
+			if !ok {
+				return fmt.Errorf("param %s is of type %s but default value is not a string", p.Name, strings.ToLower(string(p.Type)))
+			}
+			if err := p.Validate(string(defaultValue)); err != nil {
+				return fmt.Errorf("invalid default: %w", err)
 			}
 		case starlark_type.BOOLEAN:
 			if _, ok := p.DefaultValue.(starlark.Bool); !ok {
@@ -98,24 +232,26 @@ func validateParamInfo(paramInfo map[string]AppParam) error {
 			return fmt.Errorf("unknown display type %s for %s", p.DisplayType, p.Name)
 		}
 
-		if p.DisplayType != "" && p.Type != starlark_type.STRING {
+		if p.DisplayType != "" && p.Type != starlark_type.STRING && p.Type != SECRET {
 			return fmt.Errorf("display_type %s is allowed for string type %s only", p.DisplayType, p.Name)
 		}
 	}
 	return nil
 }
 
-func LoadParamInfo(fileName string, data []byte, serverConfig *types.ServerConfig) (map[string]AppParam, error) {
+func LoadParamInfo(fileName string, data []byte, serverConfig *types.ServerConfig, sandbox *Sandbox) (map[string]AppParam, error) {
 	definedParams := make(map[string]AppParam)
 	index := 0
 
 	paramBuiltin := func(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
-		var name, description, dataType, displayType starlark.String
+		var name, description, dataType, displayType, regex starlark.String
 		var defaultValue starlark.Value = starlark.None
 		var required = starlark.Bool(true)
+		var values *starlark.List
 
 		if err := starlark.UnpackArgs(PARAM, args, kwargs, "name", &name, "type?", &dataType, "default?", &defaultValue,
-			"description?", &description, "required?", &required, "display_type?", &displayType); err != nil {
+			"description?", &description, "required?", &required, "display_type?", &displayType,
+			"values?", &values, "regex?", &regex); err != nil {
 			return nil, err
 		}
 
@@ -127,16 +263,46 @@ func LoadParamInfo(fileName string, data []byte, serverConfig *types.ServerConfi
 		if typeVal == "" {
 			typeVal = starlark_type.STRING
 		}
-		if typeVal != starlark_type.INT && typeVal != starlark_type.STRING &&
+		if typeVal != starlark_type.INT && !IsStringType(typeVal) &&
 			typeVal != starlark_type.BOOLEAN && typeVal != starlark_type.DICT && typeVal != starlark_type.LIST {
 			return nil, fmt.Errorf("unknown type %s for %s", typeVal, name)
 		}
 
+		enumValues := []string{}
+		valueList := []starlark.Value{}
+		if values != nil {
+			if typeVal != ENUM {
+				return nil, fmt.Errorf("values is allowed for ENUM type only, param %s is %s", name, typeVal)
+			}
+			for v := range values.Elements() {
+				valueStr, ok := v.(starlark.String)
+				if !ok {
+					return nil, fmt.Errorf("values for param %s have to be strings, got %s", name, v.Type())
+				}
+				enumValues = append(enumValues, string(valueStr))
+				valueList = append(valueList, valueStr)
+			}
+		}
+		if typeVal == ENUM && len(enumValues) == 0 {
+			return nil, fmt.Errorf("param %s is of type ENUM, values has to be specified", name)
+		}
+
+		var regexVal *regexp.Regexp
+		if regex != "" {
+			if typeVal != starlark_type.STRING && typeVal != URL {
+				return nil, fmt.Errorf("regex is allowed for STRING and URL types only, param %s is %s", name, typeVal)
+			}
+			var err error
+			if regexVal, err = regexp.Compile(string(regex)); err != nil {
+				return nil, fmt.Errorf("invalid regex for param %s: %w", name, err)
+			}
+		}
+
 		if required == starlark.False && defaultValue == starlark.None {
 			switch typeVal {
 			case starlark_type.INT:
 				defaultValue = starlark.MakeInt(0)
-			case starlark_type.STRING:
+			case starlark_type.STRING, ENUM, SECRET, URL:
 				defaultValue = starlark.String("")
 			case starlark_type.BOOLEAN:
 				defaultValue = starlark.Bool(false)
@@ -159,6 +325,8 @@ func LoadParamInfo(fileName string, data []byte, serverConfig *types.ServerConfi
 			Required:           bool(required),
 			DisplayType:        DisplayType(dt),
 			DisplayTypeOptions: dto,
+			Values:             enumValues,
+			Regex:              regexVal,
 		}
 
 		paramDict := starlark.StringDict{
@@ -170,6 +338,8 @@ func LoadParamInfo(fileName string, data []byte, serverConfig *types.ServerConfi
 			"required":             required,
 			"display_type":         displayType,
 			"display_type_options": starlark.String(dto),
+			"values":               starlark.NewList(valueList),
+			"regex":                regex,
 		}
 		return starlarkstruct.FromStringDict(starlark.String(PARAM), paramDict), nil
 	}
@@ -182,6 +352,9 @@ func LoadParamInfo(fileName string, data []byte, serverConfig *types.ServerConfi
 		string(starlark_type.BOOLEAN): starlark.String(starlark_type.BOOLEAN),
 		string(starlark_type.DICT):    starlark.String(starlark_type.DICT),
 		string(starlark_type.LIST):    starlark.String(starlark_type.LIST),
+		string(ENUM):                  starlark.String(ENUM),
+		string(SECRET):                starlark.String(SECRET),
+		string(URL):                   starlark.String(URL),
 		strings.ToUpper(string(DisplayTypePassword)):   starlark.String(DisplayTypePassword),
 		strings.ToUpper(string(DisplayTypeTextArea)):   starlark.String(DisplayTypeTextArea),
 		strings.ToUpper(string(DisplayTypeFileUpload)): starlark.String(DisplayTypeFileUpload),
@@ -192,7 +365,7 @@ func LoadParamInfo(fileName string, data []byte, serverConfig *types.ServerConfi
 		Print: func(_ *starlark.Thread, msg string) { fmt.Println(msg) },
 	}
 
-	_, err := starlark.ExecFileOptions(&syntax.FileOptions{}, thread, fileName, data, builtins)
+	_, err := starlark.ExecFileOptions(&syntax.FileOptions{}, thread, fileName, data, sandbox.Restrict(builtins))
 	if err != nil {
 		if evalErr, ok := err.(*starlark.EvalError); ok {
 			fmt.Printf("Error loading app params: %s\n", evalErr.Backtrace()) // TODO: log
@@ -205,7 +378,7 @@ func LoadParamInfo(fileName string, data []byte, serverConfig *types.ServerConfi
 
 func ParamStringToType(name string, typeName starlark_type.TypeName, valueStr string) (starlark.Value, error) {
 	switch typeName {
-	case starlark_type.STRING:
+	case starlark_type.STRING, ENUM, SECRET, URL:
 		return starlark.String(valueStr), nil
 	case starlark_type.INT:
 		intValue, err := strconv.Atoi(valueStr)
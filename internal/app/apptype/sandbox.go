@@ -0,0 +1,109 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package apptype
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// Language features which can be disallowed for untrusted apps, listed along with the builtin names
+const (
+	FEATURE_WHILE     = "while"
+	FEATURE_RECURSION = "recursion"
+)
+
+// UntrustedDisallowed are the Starlark builtins and language features which are not available to
+// untrusted apps, unless added to security.untrusted_allowed_builtins. The Starlark code does not
+// have any file or network access, all external calls go through plugins which require the
+// permissions to be approved. The builtins listed here are the ones which expose server state or
+// allow the app code to run without a bound
+var UntrustedDisallowed = []string{
+	DEFAULT_MODULE + "." + CONFIG, // reads the server node_config and the allowed env values
+	CONFIG,                        // ace.config as available in params.star
+	FEATURE_WHILE,                 // while loops, which can run forever
+	FEATURE_RECURSION,             // recursive function calls
+}
+
+// Sandbox has the Starlark capabilities available to an app. A nil sandbox allows everything
+type Sandbox struct {
+	Trusted    bool
+	Disallowed []string // the disallowed builtins and language features, sorted
+}
+
+// NewSandbox returns the sandbox for an app. Trusted apps get all the builtins. Untrusted apps get
+// the builtins except the UntrustedDisallowed ones, the allowed list adds back specific entries
+func NewSandbox(trusted bool, allowed []string) *Sandbox {
+	ret := &Sandbox{Trusted: trusted, Disallowed: []string{}}
+	if trusted {
+		return ret
+	}
+	for _, name := range UntrustedDisallowed {
+		if !slices.Contains(allowed, name) {
+			ret.Disallowed = append(ret.Disallowed, name)
+		}
+	}
+	slices.Sort(ret.Disallowed)
+	return ret
+}
+
+// Allows reports whether the builtin or language feature is available
+func (s *Sandbox) Allows(name string) bool {
+	return s == nil || !slices.Contains(s.Disallowed, name)
+}
+
+// FileOptions returns the Starlark file options with the language features allowed by the sandbox
+func (s *Sandbox) FileOptions() *syntax.FileOptions {
+	return &syntax.FileOptions{
+		While:     s.Allows(FEATURE_WHILE),
+		Recursion: s.Allows(FEATURE_RECURSION),
+	}
+}
+
+// Restrict returns the builtins with the disallowed ones replaced by a builtin which fails when
+// called. Module members, like ace.config, are replaced in a copy of the module. Disallowed
+// Starlark universe builtins are shadowed. The passed builtins are not modified
+func (s *Sandbox) Restrict(builtins starlark.StringDict) starlark.StringDict {
+	if s == nil || len(s.Disallowed) == 0 {
+		return builtins
+	}
+
+	ret := maps.Clone(builtins)
+	for _, name := range s.Disallowed {
+		if name == FEATURE_WHILE || name == FEATURE_RECURSION {
+			continue
+		}
+		moduleName, member, isMember := strings.Cut(name, ".")
+		if !isMember {
+			if _, ok := ret[name]; ok || starlark.Universe.Has(name) {
+				ret[name] = disallowedBuiltin(name)
+			}
+			continue
+		}
+
+		module, ok := ret[moduleName].(*starlarkstruct.Module)
+		if !ok {
+			continue
+		}
+		if _, ok := module.Members[member]; !ok {
+			continue
+		}
+		members := maps.Clone(module.Members)
+		members[member] = disallowedBuiltin(name)
+		ret[moduleName] = &starlarkstruct.Module{Name: module.Name, Members: members}
+	}
+	return ret
+}
+
+func disallowedBuiltin(name string) *starlark.Builtin {
+	return starlark.NewBuiltin(name, func(_ *starlark.Thread, _ *starlark.Builtin, _ starlark.Tuple, _ []starlark.Tuple) (starlark.Value, error) {
+		return nil, fmt.Errorf("%s is not allowed for untrusted apps, it can be enabled using security.untrusted_allowed_builtins", name)
+	})
+}
@@ -0,0 +1,64 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package apptype
+
+import (
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+func sandboxBuiltins() starlark.StringDict {
+	ret := starlark.NewBuiltin(CONFIG, func(_ *starlark.Thread, _ *starlark.Builtin, _ starlark.Tuple, _ []starlark.Tuple) (starlark.Value, error) {
+		return starlark.String("value"), nil
+	})
+	return starlark.StringDict{
+		DEFAULT_MODULE: &starlarkstruct.Module{
+			Name:    DEFAULT_MODULE,
+			Members: starlark.StringDict{CONFIG: ret, "name": starlark.String("ace")},
+		},
+		CONFIG: ret,
+	}
+}
+
+func TestNewSandbox(t *testing.T) {
+	s := NewSandbox(true, nil)
+	testutil.AssertEqualsInt(t, "trusted", 0, len(s.Disallowed))
+	testutil.AssertEqualsBool(t, "while", true, s.FileOptions().While)
+
+	s = NewSandbox(false, []string{FEATURE_WHILE})
+	testutil.AssertEqualsInt(t, "untrusted", len(UntrustedDisallowed)-1, len(s.Disallowed))
+	testutil.AssertEqualsBool(t, "allows while", true, s.Allows(FEATURE_WHILE))
+	testutil.AssertEqualsBool(t, "allows config", false, s.Allows("ace.config"))
+	testutil.AssertEqualsBool(t, "while option", true, s.FileOptions().While)
+	testutil.AssertEqualsBool(t, "recursion option", false, s.FileOptions().Recursion)
+
+	var nilSandbox *Sandbox
+	testutil.AssertEqualsBool(t, "nil allows", true, nilSandbox.Allows(CONFIG))
+}
+
+func TestSandboxRestrict(t *testing.T) {
+	builtins := sandboxBuiltins()
+	restricted := NewSandbox(false, nil).Restrict(builtins)
+
+	_, err := starlark.Eval(&starlark.Thread{}, "test.star", `ace.config("key")`, restricted)
+	testutil.AssertErrorContains(t, err, "ace.config is not allowed for untrusted apps")
+	_, err = starlark.Eval(&starlark.Thread{}, "test.star", `config("key")`, restricted)
+	testutil.AssertErrorContains(t, err, "config is not allowed for untrusted apps")
+	val, err := starlark.Eval(&starlark.Thread{}, "test.star", `ace.name`, restricted)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "member", `"ace"`, val.String())
+
+	// The passed builtins are not modified
+	val, err = starlark.Eval(&starlark.Thread{}, "test.star", `ace.config("key")`, builtins)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "original", `"value"`, val.String())
+
+	// Allowed builtins are available
+	restricted = NewSandbox(false, []string{"ace.config"}).Restrict(builtins)
+	_, err = starlark.Eval(&starlark.Thread{}, "test.star", `ace.config("key")`, restricted)
+	testutil.AssertNoError(t, err)
+}
@@ -0,0 +1,121 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package apptype
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"go.starlark.net/starlark"
+)
+
+const (
+	BASIC  = "basic"
+	BEARER = "bearer"
+	TOKEN  = "token"
+)
+
+// UpstreamAuth is the credential injected by the proxy into requests to the upstream. The
+// values can have secret references, which are resolved when the proxy is set up. The values
+// are not readable from Starlark and are not included in the string representation, so they
+// do not show up in app logs
+type UpstreamAuth struct {
+	AuthType string // basic, bearer or token
+	User     string
+	Password string
+	Token    string
+	Header   string // header name for the token type
+}
+
+var _ starlark.Value = (*UpstreamAuth)(nil)
+
+func (u *UpstreamAuth) String() string {
+	if u.AuthType == TOKEN {
+		return fmt.Sprintf("UpstreamAuth(%s, header=%s)", u.AuthType, u.Header)
+	}
+	return fmt.Sprintf("UpstreamAuth(%s)", u.AuthType)
+}
+
+func (u *UpstreamAuth) Type() string {
+	return "UpstreamAuth"
+}
+
+func (u *UpstreamAuth) Freeze() {
+}
+
+func (u *UpstreamAuth) Truth() starlark.Bool {
+	return starlark.True
+}
+
+func (u *UpstreamAuth) Hash() (uint32, error) {
+	return 0, fmt.Errorf("unhashable type: %s", u.Type())
+}
+
+// Resolve returns a copy of the auth with the values passed through evalFunc, used to
+// evaluate the secret references
+func (u *UpstreamAuth) Resolve(evalFunc func(string) (string, error)) (*UpstreamAuth, error) {
+	ret := *u
+	var err error
+	for _, value := range []*string{&ret.User, &ret.Password, &ret.Token} {
+		if *value == "" {
+			continue
+		}
+		if *value, err = evalFunc(*value); err != nil {
+			return nil, fmt.Errorf("error evaluating upstream_auth %s: %w", u.AuthType, err)
+		}
+	}
+	return &ret, nil
+}
+
+// HeaderValue returns the header name and value to set on the upstream request
+func (u *UpstreamAuth) HeaderValue() (string, string) {
+	switch u.AuthType {
+	case BASIC:
+		return "Authorization", "Basic " + base64.StdEncoding.EncodeToString([]byte(u.User+":"+u.Password))
+	case BEARER:
+		return "Authorization", "Bearer " + u.Token
+	default:
+		return u.Header, u.Token
+	}
+}
+
+func createBasicBuiltin(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var user, password starlark.String
+	if err := starlark.UnpackArgs(BASIC, args, kwargs, "user", &user, "password", &password); err != nil {
+		return nil, fmt.Errorf("error unpacking basic args: %w", err)
+	}
+	if user.GoString() == "" {
+		return nil, fmt.Errorf("basic: user is required")
+	}
+	return &UpstreamAuth{AuthType: BASIC, User: user.GoString(), Password: password.GoString()}, nil
+}
+
+func createBearerBuiltin(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var token starlark.String
+	if err := starlark.UnpackArgs(BEARER, args, kwargs, "token", &token); err != nil {
+		return nil, fmt.Errorf("error unpacking bearer args: %w", err)
+	}
+	if token.GoString() == "" {
+		return nil, fmt.Errorf("bearer: token is required")
+	}
+	return &UpstreamAuth{AuthType: BEARER, Token: token.GoString()}, nil
+}
+
+func createTokenBuiltin(_ *starlark.Thread, _ *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var token starlark.String
+	header := starlark.String("Authorization")
+	if err := starlark.UnpackArgs(TOKEN, args, kwargs, "token", &token, "header?", &header); err != nil {
+		return nil, fmt.Errorf("error unpacking token args: %w", err)
+	}
+	if token.GoString() == "" {
+		return nil, fmt.Errorf("token: token is required")
+	}
+	headerName := http.CanonicalHeaderKey(strings.TrimSpace(header.GoString()))
+	if headerName == "" || strings.ContainsAny(headerName, " :\t\r\n") {
+		return nil, fmt.Errorf("token: invalid header name %q", header.GoString())
+	}
+	return &UpstreamAuth{AuthType: TOKEN, Token: token.GoString(), Header: headerName}, nil
+}
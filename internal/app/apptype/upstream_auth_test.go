@@ -0,0 +1,78 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package apptype
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"go.starlark.net/starlark"
+)
+
+func evalAuthExpr(expr string) (starlark.Value, error) {
+	predeclared := starlark.StringDict{
+		BASIC:  starlark.NewBuiltin(BASIC, createBasicBuiltin),
+		BEARER: starlark.NewBuiltin(BEARER, createBearerBuiltin),
+		TOKEN:  starlark.NewBuiltin(TOKEN, createTokenBuiltin),
+	}
+	return starlark.Eval(&starlark.Thread{}, "test.star", expr, predeclared)
+}
+
+func evalUpstreamAuth(t *testing.T, expr string) (*UpstreamAuth, error) {
+	t.Helper()
+	val, err := evalAuthExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	auth, ok := val.(*UpstreamAuth)
+	if !ok {
+		t.Fatalf("expected UpstreamAuth, got %s", val.Type())
+	}
+	return auth, nil
+}
+
+func TestUpstreamAuth(t *testing.T) {
+	auth, err := evalUpstreamAuth(t, `basic("alice", "s3cret")`)
+	testutil.AssertNoError(t, err)
+	name, value := auth.HeaderValue()
+	testutil.AssertEqualsString(t, "basic header", "Authorization", name)
+	testutil.AssertEqualsString(t, "basic value", "Basic YWxpY2U6czNjcmV0", value)
+	testutil.AssertEqualsString(t, "basic string", "UpstreamAuth(basic)", auth.String())
+
+	auth, err = evalUpstreamAuth(t, `bearer(token="abc")`)
+	testutil.AssertNoError(t, err)
+	name, value = auth.HeaderValue()
+	testutil.AssertEqualsString(t, "bearer header", "Authorization", name)
+	testutil.AssertEqualsString(t, "bearer value", "Bearer abc", value)
+
+	auth, err = evalUpstreamAuth(t, `token("abc", header="x-api-key")`)
+	testutil.AssertNoError(t, err)
+	name, value = auth.HeaderValue()
+	testutil.AssertEqualsString(t, "token header", "X-Api-Key", name)
+	testutil.AssertEqualsString(t, "token value", "abc", value)
+	testutil.AssertEqualsString(t, "token string", "UpstreamAuth(token, header=X-Api-Key)", auth.String())
+
+	_, err = evalUpstreamAuth(t, `bearer("")`)
+	testutil.AssertErrorContains(t, err, "token is required")
+	_, err = evalUpstreamAuth(t, `token("abc", header="bad header")`)
+	testutil.AssertErrorContains(t, err, "invalid header name")
+
+	// The values are not readable from Starlark
+	_, err = evalAuthExpr(`basic("alice", "s3cret").password`)
+	testutil.AssertErrorContains(t, err, "has no .password field")
+	val, err := evalAuthExpr(`str(token("s3cret", header="X-Key"))`)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "str", `"UpstreamAuth(token, header=X-Key)"`, val.String())
+}
+
+func TestUpstreamAuthResolve(t *testing.T) {
+	auth := &UpstreamAuth{AuthType: BASIC, User: "alice", Password: "{{secret \"pass\"}}"}
+	resolved, err := auth.Resolve(func(value string) (string, error) {
+		return strings.ReplaceAll(value, "{{secret \"pass\"}}", "resolved"), nil
+	})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "password", "resolved", resolved.Password)
+	testutil.AssertEqualsString(t, "original", "{{secret \"pass\"}}", auth.Password)
+}
@@ -46,31 +46,9 @@ func (a *App) Audit() (*types.ApproveResult, error) {
 		// The loader in audit mode is used to track the modules that are loaded.
 		// A copy of the real loader's response is returned, with builtins replaced with dummy methods,
 		// so that the audit can be run without any side effects
-
-		modulePath, moduleName, _ := parseModulePath(moduleFullPath)
-
-		pluginMap, err := a.pluginLookup(thread, modulePath)
-		if err != nil {
-			return nil, err
-		}
-
-		// Replace all the builtins with dummy methods
-		dummyDict := make(starlark.StringDict)
-		for name, pluginInfo := range pluginMap {
-			if pluginInfo.HandlerName == "" {
-				dummyDict[name] = pluginInfo.ConstantValue
-			} else {
-				dummyDict[name] = starlark.NewBuiltin(name, func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
-					a.Info().Msgf("Plugin called during audit: %s.%s", modulePath, name)
-					return starlarkstruct.FromStringDict(starlarkstruct.Default, make(starlark.StringDict)), nil
-				})
-			}
-		}
-
-		ret := make(starlark.StringDict)
-		ret[moduleName] = starlarkstruct.FromStringDict(starlarkstruct.Default, dummyDict)
-
-		return ret, nil
+		return a.dummyPluginLoad(thread, moduleFullPath, func(_ *starlark.Thread, modulePath, name string) {
+			a.Info().Msgf("Plugin called during audit: %s.%s", modulePath, name)
+		})
 	}
 
 	thread := &starlark.Thread{
@@ -95,7 +73,11 @@ func (a *App) Audit() (*types.ApproveResult, error) {
 		return nil, err
 	}
 
-	_, prog, err := starlark.SourceProgramOptions(AppFileOptions(), a.getStarPath(apptype.APP_FILE_NAME), buf, builtin.Has)
+	if _, err = a.staticCheck(buf, builtin); err != nil {
+		return nil, err
+	}
+
+	_, prog, err := starlark.SourceProgramOptions(a.sandbox.FileOptions(), a.getStarPath(apptype.APP_FILE_NAME), buf, builtin.Has)
 	if err != nil {
 		return nil, fmt.Errorf("parsing source failed %v", err)
 	}
@@ -123,8 +105,29 @@ func (a *App) Audit() (*types.ApproveResult, error) {
 		return nil, err
 	}
 
+	crons, err := loadCronDefs(appDef, globals)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := loadEnvDefs(appDef)
+	if err != nil {
+		return nil, err
+	}
+
+	specPerms, err := a.loadSpecPermissions()
+	if err != nil {
+		return nil, err
+	}
+
 	a.Metadata.Name = name
-	return a.createApproveResponse(loads, globals)
+	a.Metadata.Crons = crons
+	results, err := a.createApproveResponse(loads, env, globals)
+	if err != nil {
+		return nil, err
+	}
+	addSpecDeviations(results, a.Metadata.Spec, specPerms)
+	return results, nil
 }
 
 func needsApproval(a *types.ApproveResult) bool {
@@ -250,7 +253,7 @@ func permissionCoveredByServerConfig(perm types.Permission, serverPerms []types.
 	return false
 }
 
-func (a *App) createApproveResponse(loads []string, globals starlark.StringDict) (*types.ApproveResult, error) {
+func (a *App) createApproveResponse(loads, env []string, globals starlark.StringDict) (*types.ApproveResult, error) {
 	// the App entry should not get updated during the audit call, since there
 	// can be audit calls when the app is running.
 	appDef, err := verifyConfig(globals)
@@ -266,6 +269,8 @@ func (a *App) createApproveResponse(loads []string, globals starlark.StringDict)
 		NewPermissions:      perms,
 		ApprovedLoads:       a.Metadata.Loads,
 		ApprovedPermissions: a.Metadata.Permissions,
+		NewEnv:              env,
+		ApprovedEnv:         a.Metadata.Env,
 	}
 	permissions, err := appDef.Attr("permissions")
 	if err != nil {
@@ -274,6 +279,7 @@ func (a *App) createApproveResponse(loads []string, globals starlark.StringDict)
 		if results.NeedsApproval && len(a.serverConfig.Permissions.Allow) > 0 {
 			results.NeedsApproval = needsApprovalWithServerConfig(&results, a.serverConfig.Permissions.Allow)
 		}
+		results.NeedsApproval = results.NeedsApproval || envNeedsApproval(env, a.Metadata.Env, a.systemConfig.AllowedEnv)
 		return &results, nil
 	}
 
@@ -334,5 +340,33 @@ func (a *App) createApproveResponse(loads []string, globals starlark.StringDict)
 	if results.NeedsApproval && len(a.serverConfig.Permissions.Allow) > 0 {
 		results.NeedsApproval = needsApprovalWithServerConfig(&results, a.serverConfig.Permissions.Allow)
 	}
+	results.NeedsApproval = results.NeedsApproval || envNeedsApproval(env, a.Metadata.Env, a.systemConfig.AllowedEnv)
 	return &results, nil
 }
+
+// dummyPluginLoad returns the plugin module with the builtins replaced with dummy methods, which
+// return an empty struct without any side effects. onCall is called for each call
+func (a *App) dummyPluginLoad(thread *starlark.Thread, moduleFullPath string, onCall func(thread *starlark.Thread, modulePath, name string)) (starlark.StringDict, error) {
+	modulePath, moduleName, _ := parseModulePath(moduleFullPath)
+	pluginMap, err := a.pluginLookup(thread, modulePath)
+	if err != nil {
+		return nil, err
+	}
+
+	// Replace all the builtins with dummy methods
+	dummyDict := make(starlark.StringDict)
+	for name, pluginInfo := range pluginMap {
+		if pluginInfo.HandlerName == "" {
+			dummyDict[name] = pluginInfo.ConstantValue
+		} else {
+			dummyDict[name] = starlark.NewBuiltin(name, func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+				onCall(thread, modulePath, name)
+				return starlarkstruct.FromStringDict(starlarkstruct.Default, make(starlark.StringDict)), nil
+			})
+		}
+	}
+
+	ret := make(starlark.StringDict)
+	ret[moduleName] = starlarkstruct.FromStringDict(starlarkstruct.Default, dummyDict)
+	return ret, nil
+}
@@ -48,3 +48,42 @@ func TestServerConfigCoverageChecksPermissionPermit(t *testing.T) {
 		t.Fatal("did not expect approval when permit list is covered by server config")
 	}
 }
+
+func TestSpecDeviations(t *testing.T) {
+	t.Parallel()
+
+	specPerms := &types.SpecPermissions{
+		Loads: []string{"proxy.in", "container.in"},
+		Permissions: []types.Permission{
+			{Plugin: "proxy.in", Method: "config", Arguments: []string{"<CONTAINER_URL>"}},
+			{Plugin: "container.in", Method: "config", Arguments: []string{"regex:.*"}},
+		},
+	}
+	result := &types.ApproveResult{
+		NewLoads: []string{"proxy.in", "container.in", "exec.in"},
+		NewPermissions: []types.Permission{
+			{Plugin: "proxy.in", Method: "config", Arguments: []string{"<CONTAINER_URL>"}},
+			{Plugin: "container.in", Method: "config", Arguments: []string{"AUTO"}},
+			{Plugin: "container.in", Method: "config", Arguments: []string{"AUTO"}, Secrets: [][]string{{"DB_PASSWORD"}}},
+			{Plugin: "exec.in", Method: "run", Arguments: []string{"ls"}},
+		},
+	}
+	addSpecDeviations(result, "container", specPerms)
+	if result.Spec != "container" || len(result.SpecPermissions) != 2 {
+		t.Fatalf("expected the spec bundle in the result, got %+v", result)
+	}
+	if len(result.UndeclaredLoads) != 1 || result.UndeclaredLoads[0] != "exec.in" {
+		t.Fatalf("expected exec.in as undeclared load, got %v", result.UndeclaredLoads)
+	}
+	if len(result.UndeclaredPermissions) != 2 || result.UndeclaredPermissions[0].Secrets == nil ||
+		result.UndeclaredPermissions[1].Plugin != "exec.in" {
+		t.Fatalf("expected the secrets and exec permissions as undeclared, got %+v", result.UndeclaredPermissions)
+	}
+
+	// No bundle declared by the spec
+	result = &types.ApproveResult{NewLoads: []string{"exec.in"}}
+	addSpecDeviations(result, "container", nil)
+	if result.Spec != "" || result.UndeclaredLoads != nil {
+		t.Fatalf("expected no spec deviations, got %+v", result)
+	}
+}
@@ -0,0 +1,427 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	CAPTURE_REDACTED          = "[REDACTED]"
+	DEFAULT_CAPTURE_DURATION  = 10 * time.Minute
+	MAX_CAPTURE_DURATION      = 24 * time.Hour
+	DEFAULT_CAPTURE_ENTRIES   = 1000
+	MAX_CAPTURE_ENTRIES       = 10000
+	DEFAULT_CAPTURE_BODY_SIZE = 64 * 1024
+	MAX_CAPTURE_BODY_SIZE     = 1024 * 1024
+)
+
+// captureRedactHeaders are always redacted, in addition to any header whose
+// name contains one of the captureSensitiveWords
+var captureRedactHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+var captureSensitiveWords = []string{"token", "secret", "password", "passwd", "api-key", "apikey", "api_key", "session", "csrf"}
+
+// CaptureOptions are the limits for a capture session
+type CaptureOptions struct {
+	Duration     time.Duration
+	MaxEntries   int
+	MaxBodyBytes int
+}
+
+// CaptureRegistry tracks the traffic capture sessions for the apps served by
+// this server node. Capture is opt-in: when no session was ever started for
+// any app, the request path does a single atomic load. Sessions are kept in
+// memory and are keyed by app id, so they survive the app being reloaded, but
+// not a server restart. Each node records only the requests it serves
+type CaptureRegistry struct {
+	mu       sync.RWMutex
+	sessions map[types.AppId]*captureSession
+	count    atomic.Int32
+}
+
+func NewCaptureRegistry() *CaptureRegistry {
+	return &CaptureRegistry{sessions: map[types.AppId]*captureSession{}}
+}
+
+type captureSession struct {
+	mu        sync.Mutex
+	opts      CaptureOptions
+	startTime time.Time
+	endTime   time.Time
+	entries   []types.CaptureEntry
+	dropped   int
+}
+
+// Start begins a new capture session for the app, discarding any entries
+// recorded by an earlier session
+func (c *CaptureRegistry) Start(appId types.AppId, opts CaptureOptions) types.CaptureStatus {
+	opts = normalizeCaptureOptions(opts)
+	now := time.Now()
+	session := &captureSession{
+		opts:      opts,
+		startTime: now,
+		endTime:   now.Add(opts.Duration),
+		entries:   make([]types.CaptureEntry, 0),
+	}
+
+	c.mu.Lock()
+	if _, ok := c.sessions[appId]; !ok {
+		c.count.Add(1)
+	}
+	c.sessions[appId] = session
+	c.mu.Unlock()
+	return session.status()
+}
+
+// Stop ends the capture session for the app. The recorded entries are kept
+// for download, unless clearEntries is set
+func (c *CaptureRegistry) Stop(appId types.AppId, clearEntries bool) (types.CaptureStatus, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	session, ok := c.sessions[appId]
+	if !ok {
+		return types.CaptureStatus{}, false
+	}
+
+	if clearEntries {
+		delete(c.sessions, appId)
+		c.count.Add(-1)
+	}
+
+	session.mu.Lock()
+	if now := time.Now(); session.endTime.After(now) {
+		session.endTime = now
+	}
+	session.mu.Unlock()
+	return session.status(), true
+}
+
+// Entries returns a copy of the entries recorded for the app
+func (c *CaptureRegistry) Entries(appId types.AppId) (types.CaptureStatus, []types.CaptureEntry, bool) {
+	session := c.session(appId)
+	if session == nil {
+		return types.CaptureStatus{}, nil, false
+	}
+
+	session.mu.Lock()
+	entries := make([]types.CaptureEntry, len(session.entries))
+	copy(entries, session.entries)
+	session.mu.Unlock()
+	return session.status(), entries, true
+}
+
+// Status returns the capture status for the app
+func (c *CaptureRegistry) Status(appId types.AppId) (types.CaptureStatus, bool) {
+	session := c.session(appId)
+	if session == nil {
+		return types.CaptureStatus{}, false
+	}
+	return session.status(), true
+}
+
+func (c *CaptureRegistry) session(appId types.AppId) *captureSession {
+	if c == nil || c.count.Load() == 0 {
+		return nil
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.sessions[appId]
+}
+
+// recorder returns the session to record into if capture is active for the app
+func (c *CaptureRegistry) recorder(appId types.AppId) *captureSession {
+	session := c.session(appId)
+	if session == nil || !session.active(time.Now()) {
+		return nil
+	}
+	return session
+}
+
+func normalizeCaptureOptions(opts CaptureOptions) CaptureOptions {
+	if opts.Duration <= 0 {
+		opts.Duration = DEFAULT_CAPTURE_DURATION
+	}
+	opts.Duration = min(opts.Duration, MAX_CAPTURE_DURATION)
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = DEFAULT_CAPTURE_ENTRIES
+	}
+	opts.MaxEntries = min(opts.MaxEntries, MAX_CAPTURE_ENTRIES)
+	if opts.MaxBodyBytes < 0 {
+		opts.MaxBodyBytes = 0
+	} else if opts.MaxBodyBytes == 0 {
+		opts.MaxBodyBytes = DEFAULT_CAPTURE_BODY_SIZE
+	}
+	opts.MaxBodyBytes = min(opts.MaxBodyBytes, MAX_CAPTURE_BODY_SIZE)
+	return opts
+}
+
+func (s *captureSession) active(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return now.Before(s.endTime) && len(s.entries) < s.opts.MaxEntries
+}
+
+func (s *captureSession) status() types.CaptureStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return types.CaptureStatus{
+		Active:       time.Now().Before(s.endTime) && len(s.entries) < s.opts.MaxEntries,
+		StartTime:    s.startTime,
+		EndTime:      s.endTime,
+		MaxEntries:   s.opts.MaxEntries,
+		MaxBodyBytes: s.opts.MaxBodyBytes,
+		EntryCount:   len(s.entries),
+		Dropped:      s.dropped,
+	}
+}
+
+func (s *captureSession) add(entry types.CaptureEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) >= s.opts.MaxEntries {
+		s.dropped++
+		return
+	}
+	s.entries = append(s.entries, entry)
+}
+
+// limitedBuffer keeps the first limit bytes written to it and records whether
+// more data was seen. Writes never fail, so it is safe to use as a tee target
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (l *limitedBuffer) Write(p []byte) (int, error) {
+	remaining := l.limit - l.buf.Len()
+	if remaining <= 0 {
+		if len(p) > 0 {
+			l.truncated = true
+		}
+		return len(p), nil
+	}
+	if len(p) > remaining {
+		l.buf.Write(p[:remaining])
+		l.truncated = true
+		return len(p), nil
+	}
+	l.buf.Write(p)
+	return len(p), nil
+}
+
+func (l *limitedBuffer) Bytes() []byte {
+	if l.buf.Len() == 0 {
+		return nil
+	}
+	return bytes.Clone(l.buf.Bytes())
+}
+
+// captureBody tees the request body into a limited buffer as the handler reads it
+type captureBody struct {
+	io.ReadCloser
+	tee io.Reader
+}
+
+func (c *captureBody) Read(p []byte) (int, error) {
+	return c.tee.Read(p)
+}
+
+// captureRequest holds the in-flight state for one captured request
+type captureRequest struct {
+	session     *captureSession
+	start       time.Time
+	method      string
+	path        string
+	query       string
+	headers     http.Header
+	reqBody     *limitedBuffer
+	respBody    *limitedBuffer
+	contentType string
+}
+
+// startCapture sets up the recording of the request and the response written
+// through wrapper. The returned function has to be called once the handler is done
+func (a *App) startCapture(session *captureSession, wrapper middleware.WrapResponseWriter, r *http.Request) func() {
+	limit := session.opts.MaxBodyBytes
+	c := &captureRequest{
+		session:     session,
+		start:       time.Now(),
+		method:      r.Method,
+		path:        a.relativeRequestPath(r),
+		query:       sanitizeCaptureQuery(r.URL.RawQuery),
+		headers:     sanitizeCaptureHeaders(r.Header),
+		reqBody:     &limitedBuffer{limit: limit},
+		respBody:    &limitedBuffer{limit: limit},
+		contentType: r.Header.Get("Content-Type"),
+	}
+	if r.Body != nil && r.Body != http.NoBody {
+		r.Body = &captureBody{ReadCloser: r.Body, tee: io.TeeReader(r.Body, c.reqBody)}
+	}
+	wrapper.Tee(c.respBody)
+
+	return func() {
+		status := wrapper.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		c.session.add(types.CaptureEntry{
+			Time:                  c.start,
+			Method:                c.method,
+			Path:                  c.path,
+			Query:                 c.query,
+			RequestHeaders:        c.headers,
+			RequestBody:           sanitizeCaptureBody(c.contentType, c.reqBody.Bytes(), c.reqBody.truncated),
+			RequestBodyTruncated:  c.reqBody.truncated,
+			Status:                status,
+			ResponseHeaders:       sanitizeCaptureHeaders(wrapper.Header()),
+			ResponseBody:          c.respBody.Bytes(),
+			ResponseBodyTruncated: c.respBody.truncated,
+			DurationMs:            time.Since(c.start).Milliseconds(),
+		})
+	}
+}
+
+func isSensitiveCaptureName(name string) bool {
+	lower := strings.ToLower(name)
+	for _, word := range captureSensitiveWords {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeCaptureHeaders returns a copy of the headers with credentials redacted.
+// The openrun internal headers are dropped
+func sanitizeCaptureHeaders(header http.Header) http.Header {
+	ret := make(http.Header, len(header))
+	for key, values := range header {
+		if strings.HasPrefix(strings.ToLower(key), "x-openrun-") {
+			continue
+		}
+		if captureRedactHeaders[key] || isSensitiveCaptureName(key) {
+			ret[key] = []string{CAPTURE_REDACTED}
+			continue
+		}
+		ret[key] = append([]string(nil), values...)
+	}
+	return ret
+}
+
+// sanitizeCaptureQuery redacts the values of sensitive looking keys in an url
+// encoded query string (also used for form bodies). Unparseable input is
+// redacted entirely
+func sanitizeCaptureQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return CAPTURE_REDACTED
+	}
+	redacted := false
+	for key, vals := range values {
+		if isSensitiveCaptureName(key) {
+			for i := range vals {
+				vals[i] = CAPTURE_REDACTED
+			}
+			redacted = true
+		}
+	}
+	if !redacted {
+		return rawQuery
+	}
+	return values.Encode()
+}
+
+// sanitizeCaptureBody redacts the values of sensitive looking keys in form and JSON request
+// bodies. A truncated form or JSON body cannot be parsed, it is redacted entirely
+func sanitizeCaptureBody(contentType string, body []byte, truncated bool) []byte {
+	if len(body) == 0 {
+		return body
+	}
+	mediaType, _, _ := strings.Cut(strings.ToLower(contentType), ";")
+	mediaType = strings.TrimSpace(mediaType)
+	isForm := mediaType == "application/x-www-form-urlencoded"
+	isJSON := mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+	switch {
+	case !isForm && !isJSON:
+		return body
+	case truncated:
+		return []byte(CAPTURE_REDACTED)
+	case isForm:
+		return []byte(sanitizeCaptureQuery(string(body)))
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return []byte(CAPTURE_REDACTED)
+	}
+	if !redactCaptureJSON(value) {
+		return body
+	}
+	ret, err := json.Marshal(value)
+	if err != nil {
+		return []byte(CAPTURE_REDACTED)
+	}
+	return ret
+}
+
+// redactCaptureJSON redacts in place the values of sensitive looking keys in the decoded JSON,
+// returns true if any value was redacted
+func redactCaptureJSON(value any) bool {
+	redacted := false
+	switch v := value.(type) {
+	case map[string]any:
+		for key, child := range v {
+			if isSensitiveCaptureName(key) {
+				v[key] = CAPTURE_REDACTED
+				redacted = true
+			} else if redactCaptureJSON(child) {
+				redacted = true
+			}
+		}
+	case []any:
+		for _, child := range v {
+			if redactCaptureJSON(child) {
+				redacted = true
+			}
+		}
+	}
+	return redacted
+}
+
+// relativeRequestPath returns the request path relative to the app path
+func (a *App) relativeRequestPath(r *http.Request) string {
+	ret := strings.TrimPrefix(r.URL.Path, strings.TrimSuffix(a.Path, "/"))
+	if ret == "" {
+		ret = "/"
+	}
+	return ret
+}
+
+// SetCaptureRegistry sets the registry used to look up the capture session for the app
+func (a *App) SetCaptureRegistry(registry *CaptureRegistry) {
+	a.captures = registry
+}
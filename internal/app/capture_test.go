@@ -0,0 +1,143 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+package app
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func newCaptureTestApp(registry *CaptureRegistry) *App {
+	router := chi.NewRouter()
+	router.Post("/test/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Set-Cookie", "sid=abc")
+		w.Header().Set("X-Result", "ok")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte("echo:" + string(body)))
+	})
+	router.Get("/test/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("index"))
+	})
+
+	a := &App{
+		Logger:    testutil.TestLogger(),
+		AppEntry:  &types.AppEntry{Id: "app_prd_capture", Path: "/test"},
+		appRouter: router,
+	}
+	a.SetCaptureRegistry(registry)
+	return a
+}
+
+func TestCaptureDisabled(t *testing.T) {
+	registry := NewCaptureRegistry()
+	a := newCaptureTestApp(registry)
+
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/test/", nil))
+	testutil.AssertEqualsString(t, "body", "index", rec.Body.String())
+
+	_, _, ok := registry.Entries(a.Id)
+	testutil.AssertEqualsBool(t, "no session", false, ok)
+}
+
+func TestCaptureRecordsSanitized(t *testing.T) {
+	registry := NewCaptureRegistry()
+	a := newCaptureTestApp(registry)
+	registry.Start(a.Id, CaptureOptions{MaxBodyBytes: 8})
+
+	req := httptest.NewRequest(http.MethodPost, "/test/echo?q=1&access_token=xyz", strings.NewReader("0123456789"))
+	req.Header.Set("Authorization", "Bearer abc")
+	req.Header.Set("X-Api-Key", "key")
+	req.Header.Set("X-Openrun-Internal", "1")
+	req.Header.Set("Accept", "text/plain")
+	rec := httptest.NewRecorder()
+	a.ServeHTTP(rec, req)
+
+	// The app response is not changed by the capture
+	testutil.AssertEqualsInt(t, "status", http.StatusCreated, rec.Code)
+	testutil.AssertEqualsString(t, "body", "echo:0123456789", rec.Body.String())
+
+	status, entries, ok := registry.Entries(a.Id)
+	testutil.AssertEqualsBool(t, "session", true, ok)
+	testutil.AssertEqualsInt(t, "count", 1, status.EntryCount)
+	e := entries[0]
+	testutil.AssertEqualsString(t, "method", http.MethodPost, e.Method)
+	testutil.AssertEqualsString(t, "path", "/echo", e.Path)
+	testutil.AssertEqualsString(t, "query", "access_token=%5BREDACTED%5D&q=1", e.Query)
+	testutil.AssertEqualsString(t, "auth", CAPTURE_REDACTED, e.RequestHeaders.Get("Authorization"))
+	testutil.AssertEqualsString(t, "api key", CAPTURE_REDACTED, e.RequestHeaders.Get("X-Api-Key"))
+	testutil.AssertEqualsString(t, "internal", "", e.RequestHeaders.Get("X-Openrun-Internal"))
+	testutil.AssertEqualsString(t, "accept", "text/plain", e.RequestHeaders.Get("Accept"))
+	testutil.AssertEqualsString(t, "req body", "01234567", string(e.RequestBody))
+	testutil.AssertEqualsBool(t, "req truncated", true, e.RequestBodyTruncated)
+	testutil.AssertEqualsInt(t, "resp status", http.StatusCreated, e.Status)
+	testutil.AssertEqualsString(t, "resp body", "echo:012", string(e.ResponseBody))
+	testutil.AssertEqualsBool(t, "resp truncated", true, e.ResponseBodyTruncated)
+	testutil.AssertEqualsString(t, "set cookie", CAPTURE_REDACTED, e.ResponseHeaders.Get("Set-Cookie"))
+	testutil.AssertEqualsString(t, "resp header", "ok", e.ResponseHeaders.Get("X-Result"))
+}
+
+func TestCaptureLimits(t *testing.T) {
+	registry := NewCaptureRegistry()
+	a := newCaptureTestApp(registry)
+	registry.Start(a.Id, CaptureOptions{MaxEntries: 2})
+
+	for range 3 {
+		a.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/test/", nil))
+	}
+	status, entries, _ := registry.Entries(a.Id)
+	testutil.AssertEqualsInt(t, "entries", 2, len(entries))
+	testutil.AssertEqualsBool(t, "inactive when full", false, status.Active)
+
+	// Stop keeps the entries, the window is closed
+	status, ok := registry.Stop(a.Id, false)
+	testutil.AssertEqualsBool(t, "stopped", true, ok)
+	testutil.AssertEqualsBool(t, "end time", true, !status.EndTime.After(time.Now()))
+	_, entries, _ = registry.Entries(a.Id)
+	testutil.AssertEqualsInt(t, "entries after stop", 2, len(entries))
+
+	// Restart discards old entries, clear removes the session
+	registry.Start(a.Id, CaptureOptions{})
+	_, entries, _ = registry.Entries(a.Id)
+	testutil.AssertEqualsInt(t, "entries after restart", 0, len(entries))
+	registry.Stop(a.Id, true)
+	_, _, ok = registry.Entries(a.Id)
+	testutil.AssertEqualsBool(t, "cleared", false, ok)
+	testutil.AssertEqualsInt(t, "count", 0, int(registry.count.Load()))
+}
+
+func TestSanitizeCaptureQuery(t *testing.T) {
+	testutil.AssertEqualsString(t, "empty", "", sanitizeCaptureQuery(""))
+	testutil.AssertEqualsString(t, "unchanged", "b=2&a=1", sanitizeCaptureQuery("b=2&a=1"))
+	testutil.AssertEqualsString(t, "redacted", "password=%5BREDACTED%5D&user=x", sanitizeCaptureQuery("user=x&password=secret"))
+	testutil.AssertEqualsString(t, "invalid", CAPTURE_REDACTED, sanitizeCaptureQuery("a=%zz"))
+}
+
+func TestSanitizeCaptureBody(t *testing.T) {
+	const form = "application/x-www-form-urlencoded"
+	testutil.AssertEqualsString(t, "form", "password=%5BREDACTED%5D&user=x",
+		string(sanitizeCaptureBody(form, []byte("user=x&password=secret"), false)))
+	testutil.AssertEqualsString(t, "truncated form", CAPTURE_REDACTED,
+		string(sanitizeCaptureBody(form+"; charset=utf-8", []byte("user=x&passw"), true)))
+
+	testutil.AssertEqualsString(t, "json", `{"items":[{"api_key":"[REDACTED]","id":1}],"user":"x"}`,
+		string(sanitizeCaptureBody("application/json", []byte(`{"user": "x", "items": [{"id": 1, "api_key": "k1"}]}`), false)))
+	testutil.AssertEqualsString(t, "json unchanged", `{"user": "x"}`,
+		string(sanitizeCaptureBody("application/json", []byte(`{"user": "x"}`), false)))
+	testutil.AssertEqualsString(t, "truncated json", CAPTURE_REDACTED,
+		string(sanitizeCaptureBody("application/json", []byte(`{"user": "x", "token": "t`), true)))
+	testutil.AssertEqualsString(t, "invalid json", CAPTURE_REDACTED,
+		string(sanitizeCaptureBody("application/vnd.api+json", []byte(`{"token"`), false)))
+
+	testutil.AssertEqualsString(t, "text", "password=secret",
+		string(sanitizeCaptureBody("text/plain", []byte("password=secret"), false)))
+}
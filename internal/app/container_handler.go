@@ -22,6 +22,7 @@ import (
 	"strings"
 	"sync"
 	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/openrundev/openrun/internal/app/appfs"
@@ -36,9 +37,11 @@ type ContainerState string
 
 const (
 	ContainerStateUnknown       ContainerState = "unknown"
+	ContainerStateStarting      ContainerState = "starting"
 	ContainerStateRunning       ContainerState = "running"
 	ContainerStateIdleShutdown  ContainerState = "idle_shutdown"
 	ContainerStateHealthFailure ContainerState = "health_failure"
+	ContainerStateScheduledStop ContainerState = "scheduled_stop"
 )
 
 type ContainerHandler struct {
@@ -61,6 +64,10 @@ type ContainerHandler struct {
 	volumeInfo      []*container.VolumeInfo
 	containerConfig types.Container
 	excludeGlob     []string
+	// containerOptions are the app container options with the resource limits from the app config
+	// and the container quota added
+	containerOptions map[string]string
+	resourceLimits   types.ResourceLimits
 
 	// closeCh unblocks the idle shutdown and health check goroutines on
 	// Close; stopping the tickers alone would leave them blocked forever
@@ -99,13 +106,29 @@ type ContainerHandler struct {
 	envMapHash  string
 	bindings    []*types.Binding
 	devSettings *types.DevSettings
+
+	// services are the service containers of a multi-container app, started on the app network
+	// before the app container. activeServiceNames is guarded by stateLock
+	services           []types.ContainerService
+	serviceVolumes     map[string][]*container.VolumeInfo
+	activeServiceNames []container.ContainerName
+
+	// readiness is set when the container was started without waiting for the health check,
+	// requests to the container proxy wait on it until the container is ready
+	readiness atomic.Pointer[readinessGate]
+
+	// lazyStarting is the container start triggered by a request which found the container not
+	// reachable, guarded by lazyStartLock. lazyStartFunc overrides the start, used in tests
+	lazyStartLock sync.Mutex
+	lazyStarting  *lazyStartCall
+	lazyStartFunc func(ctx context.Context) error
 }
 
 func NewContainerHandler(logger *types.Logger, app *App, containerFile string,
 	serverConfig *types.ServerConfig, configPort int32, lifetime, scheme, health, buildDir string, sourceFS appfs.ReadableFS,
 	paramMap map[string]string, containerConfig types.Container, stripAppPath bool,
 	containerVolumes []string, secretsAllowed [][]string, cargs map[string]any, bindings []*types.Binding,
-	devSettings *types.DevSettings) (*ContainerHandler, error) {
+	devSettings *types.DevSettings, services []types.ContainerService) (*ContainerHandler, error) {
 
 	if !app.IsDev {
 		// dev_settings apply to dev mode only, prod is unaffected
@@ -133,6 +156,15 @@ func NewContainerHandler(logger *types.Logger, app *App, containerFile string,
 	}
 	containerManager = container.WrapContainerManager(containerManager, containerManagerKind)
 
+	if len(services) > 0 {
+		if isKubernetes {
+			return nil, fmt.Errorf("container services are not supported with the kubernetes container manager")
+		}
+		if lifetime == types.CONTAINER_LIFETIME_COMMAND {
+			return nil, fmt.Errorf("container services are not supported for command lifetime apps")
+		}
+	}
+
 	image := ""
 	volumes := []string{}
 	if strings.HasPrefix(containerFile, types.CONTAINER_SOURCE_IMAGE_PREFIX) {
@@ -150,17 +182,13 @@ func NewContainerHandler(logger *types.Logger, app *App, containerFile string,
 			return nil, fmt.Errorf("error parsing container file %s : %w", containerFile, err)
 		}
 
-		var filePort int32
+		exposed := []string{}
 		// Loop through the parsed result to find the EXPOSE and VOLUME instructions
 		for _, child := range result.AST.Children {
 			switch strings.ToUpper(child.Value) {
 			case "EXPOSE":
-				portVal, err := strconv.ParseInt(strings.TrimSpace(child.Next.Value), 10, 32)
-				if err != nil {
-					// Can fail if value is an arg like $PORT
-					logger.Warn().Msgf("Error parsing EXPOSE port %s in container file %s", child.Next.Value, containerFile)
-				} else {
-					filePort = int32(portVal)
+				for n := child.Next; n != nil; n = n.Next {
+					exposed = append(exposed, n.Value)
 				}
 			case "VOLUME":
 				v := extractVolumes(child)
@@ -170,7 +198,35 @@ func NewContainerHandler(logger *types.Logger, app *App, containerFile string,
 
 		if configPort == 0 {
 			// No port configured in app config, use the one from the container file
-			configPort = filePort
+			filePorts, skipped := container.ParseExposedPorts(exposed)
+			for _, value := range skipped {
+				// Can be an arg like $PORT or a UDP port
+				logger.Warn().Msgf("Ignoring EXPOSE port %s in container file %s", value, containerFile)
+			}
+			configPort, err = selectContainerPort(app.Metadata.Spec, "container file "+containerFile, filePorts)
+			if err != nil && lifetime != types.CONTAINER_LIFETIME_COMMAND {
+				// Command containers do not need a port
+				return nil, err
+			}
+		}
+	}
+
+	if image != "" && configPort == 0 && lifetime != types.CONTAINER_LIFETIME_COMMAND {
+		// No port configured in app config, use the ports exposed by the image
+		var imagePorts []int32
+		if inspector, ok := container.AsImagePortInspector(containerManager); ok {
+			ctx, cancel := context.WithTimeout(context.Background(), imagePortInspectTimeout)
+			exposed, err := inspector.ImageExposedPorts(ctx, container.ImageName(image))
+			cancel()
+			if err != nil {
+				logger.Warn().Err(err).Msgf("Error reading exposed ports for image %s", image)
+			} else {
+				imagePorts, _ = container.ParseExposedPorts(exposed)
+			}
+		}
+		configPort, err = selectContainerPort(app.Metadata.Spec, "image "+image, imagePorts)
+		if err != nil {
+			return nil, err
 		}
 	}
 
@@ -178,13 +234,17 @@ func NewContainerHandler(logger *types.Logger, app *App, containerFile string,
 	logger.Debug().Msgf("volumes %v %s", volumes, containerFile)
 
 	if configPort == 0 && lifetime != types.CONTAINER_LIFETIME_COMMAND {
+		if image != "" {
+			return nil, fmt.Errorf("port not specified in app config and no port exposed by image %s. "+
+				"Add port number in app config", image)
+		}
 		return nil, fmt.Errorf("port not specified in app config and in container file %s. Either "+
 			"add a EXPOSE directive in %s or add port number in app config", containerFile, containerFile)
 	}
 
 	// Evaluate secrets in the paramMap
 	for k, v := range paramMap {
-		val, err := app.secretEvalFunc(secretsAllowed, app.AppConfig.Security.DefaultSecretsProvider, v)
+		val, err := app.evalLoadSecret(secretsAllowed, v)
 		if err != nil {
 			return nil, fmt.Errorf("error evaluating secret for %s: %w", k, err)
 		}
@@ -203,35 +263,54 @@ func NewContainerHandler(logger *types.Logger, app *App, containerFile string,
 
 	// Evaluate secrets in the build args
 	for k, v := range cargs_map {
-		val, err := app.secretEvalFunc(secretsAllowed, app.AppConfig.Security.DefaultSecretsProvider, v)
+		val, err := app.evalLoadSecret(secretsAllowed, v)
 		if err != nil {
 			return nil, fmt.Errorf("error evaluating secret for %s: %w", k, err)
 		}
 		cargs_map[k] = val
 	}
 
+	containerOptions, resourceLimits, err := resolveResourceLimits(app.Metadata.ContainerOptions,
+		containerConfig, serverConfig.ContainerQuota)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDevicePolicy(containerOptions, app.AppEntry, serverConfig.ContainerDevices); err != nil {
+		return nil, err
+	}
+
 	h := &ContainerHandler{
-		Logger:          logger,
-		app:             app,
-		containerFile:   containerFile,
-		image:           image,
-		serverConfig:    serverConfig,
-		port:            configPort,
-		lifetime:        lifetime,
-		scheme:          scheme,
-		buildDir:        buildDir,
-		sourceFS:        sourceFS,
-		manager:         containerManager,
-		isKubernetes:    isKubernetes,
-		paramMap:        paramMap,
-		containerConfig: containerConfig,
-		closeCh:         make(chan struct{}),
-		stateLock:       sync.RWMutex{},
-		currentState:    ContainerStateUnknown,
-		stripAppPath:    stripAppPath,
-		cargs:           cargs_map,
-		bindings:        bindings,
-		devSettings:     devSettings,
+		Logger:           logger,
+		app:              app,
+		containerFile:    containerFile,
+		image:            image,
+		serverConfig:     serverConfig,
+		port:             configPort,
+		lifetime:         lifetime,
+		scheme:           scheme,
+		buildDir:         buildDir,
+		sourceFS:         sourceFS,
+		manager:          containerManager,
+		isKubernetes:     isKubernetes,
+		paramMap:         paramMap,
+		containerConfig:  containerConfig,
+		closeCh:          make(chan struct{}),
+		stateLock:        sync.RWMutex{},
+		currentState:     ContainerStateUnknown,
+		stripAppPath:     stripAppPath,
+		cargs:            cargs_map,
+		bindings:         bindings,
+		devSettings:      devSettings,
+		services:         services,
+		serviceVolumes:   map[string][]*container.VolumeInfo{},
+		containerOptions: containerOptions,
+		resourceLimits:   resourceLimits,
+	}
+
+	for _, svc := range services {
+		if h.serviceVolumes[svc.Name], err = h.parseServiceVolumes(svc); err != nil {
+			return nil, err
+		}
 	}
 
 	if containerConfig.IdleShutdownSecs > 0 &&
@@ -301,9 +380,54 @@ func NewContainerHandler(logger *types.Logger, app *App, containerFile string,
 	}
 	h.volumeInfo = volumeInfo
 
+	if app.resourceQuota != nil {
+		if err := app.resourceQuota.reserve(app.Id, h, resourceLimits, serverConfig.ContainerQuota); err != nil {
+			_ = h.Close()
+			return nil, err
+		}
+	}
 	return h, nil
 }
 
+// imagePortInspectTimeout is the timeout for reading the exposed ports of an image, which
+// can require pulling the image
+const imagePortInspectTimeout = 5 * time.Minute
+
+// specDefaultPorts are the ports the frameworks used by the app specs listen on by default.
+// Used when the container file or image does not expose a port, and to pick between
+// multiple exposed ports
+var specDefaultPorts = map[types.AppSpec]int32{
+	"python-fastapi":   8000,
+	"python-fasthtml":  5001,
+	"python-flask":     5000,
+	"python-gradio":    7860,
+	"python-streamlit": 8501,
+}
+
+// selectContainerPort picks the container port from the exposed ports of the container
+// file or image. A single exposed port is used as is. With multiple ports, the spec default
+// port is used if it is one of them, otherwise an error listing the candidates is returned.
+// With no exposed ports, the spec default port is used. Returns zero if no port is found
+func selectContainerPort(spec types.AppSpec, source string, candidates []int32) (int32, error) {
+	specPort := specDefaultPorts[spec]
+	switch {
+	case len(candidates) == 1:
+		return candidates[0], nil
+	case len(candidates) > 1:
+		if slices.Contains(candidates, specPort) {
+			return specPort, nil
+		}
+		portStrs := make([]string, 0, len(candidates))
+		for _, p := range candidates {
+			portStrs = append(portStrs, strconv.Itoa(int(p)))
+		}
+		return 0, fmt.Errorf("multiple ports exposed by %s: %s. Set the port to use in the app config, "+
+			"like container.config(port=%s)", source, strings.Join(portStrs, ", "), portStrs[0])
+	default:
+		return specPort, nil
+	}
+}
+
 const (
 	VOL_PREFIX_SECRET = "cl_secret:"
 )
@@ -348,7 +472,7 @@ func (h *ContainerHandler) idleAppShutdown(ctx context.Context) {
 		currentState := h.currentState
 		versionHash := h.activeVersionHash
 		h.stateLock.RUnlock()
-		if currentState != ContainerStateRunning {
+		if currentState != ContainerStateRunning || h.isStarting() {
 			continue
 		}
 		if h.idlePaused.Load() {
@@ -412,6 +536,7 @@ func (h *ContainerHandler) idleAppShutdown(ctx context.Context) {
 		if err != nil {
 			h.Error().Err(err).Msgf("Error stopping idle app %s", h.app.Id)
 		}
+		h.stopServices(ctx)
 		h.stateLock.Unlock()
 		return
 	}
@@ -442,7 +567,7 @@ func (h *ContainerHandler) healthChecker(ctx context.Context) {
 		h.stateLock.RLock()
 		containerName := h.activeContainerName
 		versionHash := h.activeVersionHash
-		running := h.currentState == ContainerStateRunning && containerName != ""
+		running := h.currentState == ContainerStateRunning && containerName != "" && !h.isStarting()
 		h.stateLock.RUnlock()
 		if !running {
 			h.Trace().Msgf("Health checker waiting for app %s to start", h.app.Id)
@@ -481,6 +606,7 @@ func (h *ContainerHandler) healthChecker(ctx context.Context) {
 		if err != nil {
 			h.Error().Err(err).Msgf("Error stopping app %s after health failure", h.app.Id)
 		}
+		h.stopServices(ctx)
 		h.stateLock.Unlock()
 		return
 	}
@@ -597,6 +723,9 @@ func (h *ContainerHandler) getEnvMap() (map[string]string, error) {
 	for paramName, paramVal := range h.paramMap {
 		ret[paramName] = paramVal
 	}
+	for envName, envVal := range h.app.appEnv {
+		ret[envName] = envVal
+	}
 
 	pathValue := h.app.Path
 	if pathValue == "/" {
@@ -651,16 +780,16 @@ func (h *ContainerHandler) createSpecFiles() ([]string, error) {
 }
 
 func (h *ContainerHandler) createVolumes(ctx context.Context) error {
-	for _, volInfo := range h.volumeInfo {
-		if volInfo.VolumeName == "" {
-			// bind mount
+	return h.createNamedVolumes(ctx, h.volumeInfo)
+}
+
+// createNamedVolumes creates the named and unnamed volumes in the list, bind mounts are skipped
+func (h *ContainerHandler) createNamedVolumes(ctx context.Context, volumes []*container.VolumeInfo) error {
+	for _, volInfo := range volumes {
+		dir, ok := volumeDir(volInfo)
+		if !ok {
 			continue
 		}
-		dir := volInfo.VolumeName
-		if dir == container.UNNAMED_VOLUME {
-			// unnamed volume, use the path for generating the volume name
-			dir = volInfo.TargetPath
-		}
 
 		genVolumeName := container.GenVolumeName(h.app.Id, dir)
 		h.Info().Msgf("Applying volume %s for app %s dir %s", genVolumeName, h.app.Id, dir)
@@ -676,6 +805,36 @@ func (h *ContainerHandler) createVolumes(ctx context.Context) error {
 	return nil
 }
 
+// volumeDir returns the dir used for generating the volume name, false for bind mounts
+func volumeDir(volInfo *container.VolumeInfo) (string, bool) {
+	if volInfo.VolumeName == "" {
+		// bind mount
+		return "", false
+	}
+	if volInfo.VolumeName == container.UNNAMED_VOLUME {
+		// unnamed volume, use the path for generating the volume name
+		return volInfo.TargetPath, true
+	}
+	return volInfo.VolumeName, true
+}
+
+// VolumeDirs returns the volumes used by the app and its services, mapped to the volume dir
+func (h *ContainerHandler) VolumeDirs() map[container.VolumeName]string {
+	dirs := map[container.VolumeName]string{}
+	add := func(volumes []*container.VolumeInfo) {
+		for _, volInfo := range volumes {
+			if dir, ok := volumeDir(volInfo); ok {
+				dirs[container.GenVolumeName(h.app.Id, dir)] = dir
+			}
+		}
+	}
+	add(h.volumeInfo)
+	for _, volumes := range h.serviceVolumes {
+		add(volumes)
+	}
+	return dirs
+}
+
 func parseBindPaths(vol string) (string, string, bool) {
 	vol, readOnly := strings.CutSuffix(vol, ":ro")
 	p1, p2, ok := strings.Cut(vol, ":")
@@ -705,7 +864,7 @@ func (h *ContainerHandler) validateVolumeSource(src string, sourceRelative bool)
 
 	allowedRoots := []string{}
 	allowedRoots = append(allowedRoots, h.serverConfig.Security.AllowedMounts...)
-	if h.app.SourceUrl != "" && h.app.SourceUrl != types.NO_SOURCE && !system.IsGit(h.app.SourceUrl) {
+	if h.app.SourceUrl != "" && h.app.SourceUrl != types.NO_SOURCE && !system.IsGit(h.app.SourceUrl) && !system.IsPackaged(h.app.SourceUrl) {
 		allowedRoots = append(allowedRoots, h.app.SourceUrl)
 	}
 	if h.app.AppRunPath != "" {
@@ -859,6 +1018,10 @@ func (h *ContainerHandler) DevReload(ctx context.Context, dryRun bool) error {
 		return err
 	}
 
+	if err = h.startServices(ctx, ""); err != nil {
+		return err
+	}
+
 	h.stateLock.Lock()
 	defer h.stateLock.Unlock()
 
@@ -867,7 +1030,7 @@ func (h *ContainerHandler) DevReload(ctx context.Context, dryRun bool) error {
 		return nil
 	}
 	err = devCM.RunContainer(ctx, h.app.AppEntry, h.app.SourceUrl, containerName,
-		h.GenImageName, h.port, h.envMap, h.volumeInfo, h.app.Metadata.ContainerOptions, h.paramMap, "", h.IsImageSpec(), nil)
+		h.GenImageName, h.port, h.envMap, h.volumeInfo, h.containerOptions, h.paramMap, "", h.IsImageSpec(), nil)
 	if err != nil {
 		return fmt.Errorf("error running container: %w", err)
 	}
@@ -924,7 +1087,7 @@ func (h *ContainerHandler) devReloadFast(ctx context.Context, devCM container.De
 	if h.image != "" {
 		h.GenImageName = container.ImageName(h.image)
 	} else {
-		h.GenImageName = container.GenImageName(h.app.Id, "dev-"+imageHash)
+		h.GenImageName = container.GenImageName(h.app.Id, container.DEV_IMAGE_TAG_PREFIX+imageHash)
 	}
 	runHash, err := h.devRunHash(imageHash)
 	if err != nil {
@@ -932,6 +1095,16 @@ func (h *ContainerHandler) devReloadFast(ctx context.Context, devCM container.De
 	}
 	containerName := container.GenContainerName(h.app.Id, "", h.manager.SupportsInPlaceUpdate())
 
+	if h.image == "" && h.servicesUseAppImage() {
+		// Services using the app image need the image to be present
+		if err := h.buildDevImageIfMissing(ctx, devCM); err != nil {
+			return err
+		}
+	}
+	if err = h.startServices(ctx, container.DEV_IMAGE_TAG_PREFIX+imageHash); err != nil {
+		return err
+	}
+
 	exists, matches, hostPort, running, err := devCM.GetDevContainerInfo(ctx, containerName, runHash)
 	if err != nil {
 		return fmt.Errorf("error checking dev container: %w", err)
@@ -956,15 +1129,8 @@ func (h *ContainerHandler) devReloadFast(ctx context.Context, devCM container.De
 
 	// (Re)create the container, building the image only if it is missing
 	if h.image == "" {
-		imageExists, err := devCM.ImageExists(ctx, h.GenImageName)
-		if err != nil {
-			return fmt.Errorf("error checking image: %w", err)
-		}
-		if !imageExists {
-			buildDir := path.Join(h.app.SourceUrl, h.buildDir)
-			if err := devCM.BuildImageTarget(ctx, h.GenImageName, buildDir, h.containerFile, h.cargs, h.devBuildTarget()); err != nil {
-				return err
-			}
+		if err := h.buildDevImageIfMissing(ctx, devCM); err != nil {
+			return err
 		}
 	}
 
@@ -994,7 +1160,7 @@ func (h *ContainerHandler) devReloadFast(ctx context.Context, devCM container.De
 	}
 
 	err = devCM.RunDevContainer(ctx, h.app.AppEntry, h.app.SourceUrl, containerName,
-		h.GenImageName, h.port, h.envMap, h.volumeInfo, h.app.Metadata.ContainerOptions, h.paramMap,
+		h.GenImageName, h.port, h.envMap, h.volumeInfo, h.containerOptions, h.paramMap,
 		container.DevRunOptions{RunHash: runHash, WorkDir: h.devSettings.Dir, Command: h.devSettings.Command})
 	if err != nil {
 		return fmt.Errorf("error running container: %w", err)
@@ -1003,6 +1169,19 @@ func (h *ContainerHandler) devReloadFast(ctx context.Context, devCM container.De
 	return h.finishDevContainerStart(ctx, devCM, containerName, "", false, true)
 }
 
+// buildDevImageIfMissing builds the dev image for the current image hash if it is not present
+func (h *ContainerHandler) buildDevImageIfMissing(ctx context.Context, devCM container.DevContainerManager) error {
+	imageExists, err := devCM.ImageExists(ctx, h.GenImageName)
+	if err != nil {
+		return fmt.Errorf("error checking image: %w", err)
+	}
+	if imageExists {
+		return nil
+	}
+	buildDir := path.Join(h.app.SourceUrl, h.buildDir)
+	return devCM.BuildImageTarget(ctx, h.GenImageName, buildDir, h.containerFile, h.cargs, h.devBuildTarget())
+}
+
 // finishDevContainerStart updates the handler state from the running dev
 // container and optionally waits for the health check to pass.
 func (h *ContainerHandler) finishDevContainerStart(ctx context.Context, devCM container.DevContainerManager,
@@ -1101,7 +1280,7 @@ func (h *ContainerHandler) devImageHash() (string, error) {
 // devRunHash identifies the full runtime config of the dev container. When
 // unchanged, the running container is reused (restarted or left alone).
 func (h *ContainerHandler) devRunHash(imageHash string) (string, error) {
-	coptHash, err := getMapHash(h.app.Metadata.ContainerOptions)
+	coptHash, err := getMapHash(h.containerOptions)
 	if err != nil {
 		return "", fmt.Errorf("error getting copt hash: %w", err)
 	}
@@ -1159,6 +1338,10 @@ func (h *ContainerHandler) WaitForHealth(attempts int, containerName container.C
 		sleepMillis *= 2
 		sleepMillis = int(math.Min(float64(sleepMillis), maxSleepMillis))
 		sleepTime := time.Duration(sleepMillis) * time.Millisecond
+		if h.containerConfig.HealthIntervalSecs > 0 && retryDeadline.IsZero() {
+			// Health interval configured for the app, no backoff
+			sleepTime = time.Duration(h.containerConfig.HealthIntervalSecs) * time.Second
+		}
 		if !retryDeadline.IsZero() && attempt >= attempts {
 			remaining := time.Until(retryDeadline)
 			if remaining <= 0 {
@@ -1256,7 +1439,7 @@ func (h *ContainerHandler) getAppHash() (string, error) {
 		return "", fmt.Errorf("error getting file hash: %w", err)
 	}
 
-	coptHash, err := getMapHash(h.app.Metadata.ContainerOptions)
+	coptHash, err := getMapHash(h.containerOptions)
 	if err != nil {
 		return "", fmt.Errorf("error getting copt hash: %w", err)
 	}
@@ -1390,6 +1573,19 @@ func (h *ContainerHandler) ActiveContainerName() (container.ContainerName, bool)
 	return h.activeContainerName, true
 }
 
+// State returns the current container state. It does not block when the state is being
+// changed (like during an idle shutdown stop), false is returned in that case
+func (h *ContainerHandler) State() (ContainerState, bool) {
+	if h.isStarting() {
+		return ContainerStateStarting, true
+	}
+	if !h.stateLock.TryRLock() {
+		return "", false
+	}
+	defer h.stateLock.RUnlock()
+	return h.currentState, true
+}
+
 // BuildPlan captures the state needed to build an app image after the DB
 // transaction backing the app's source FS has been closed. PrepareBuild does
 // all source reads (image identity hash and temp source dir extraction);
@@ -1492,8 +1688,10 @@ func (h *ContainerHandler) registerDeployTxn(ctx context.Context, containerName
 // update to be verified and rollback-capable; for in-place managers this makes
 // a snapshot failure fatal (we refuse to mutate the live Deployment when we
 // cannot capture the state needed to roll it back), rather than proceeding with
-// an irreversible update.
-func (h *ContainerHandler) ProdReload(ctx context.Context, dryRun bool, verify bool) error {
+// an irreversible update. With gateReadiness, the container health check is done in
+// the background after the container is started and the proxy holds requests until the
+// container is ready.
+func (h *ContainerHandler) ProdReload(ctx context.Context, dryRun bool, verify bool, gateReadiness bool) error {
 	var err error
 
 	// For image-spec apps (where the operator supplied an upstream image
@@ -1557,8 +1755,19 @@ func (h *ContainerHandler) ProdReload(ctx context.Context, dryRun bool, verify b
 		return h.prodReloadKubernetes(ctx, fullHash, verify)
 	}
 
+	if h.image == "" && h.servicesUseAppImage() {
+		// Services using the app image need the image to be present
+		if err := h.buildProdImageIfMissing(ctx); err != nil {
+			return err
+		}
+	}
+	if err := h.startServices(ctx, fullHash); err != nil {
+		return err
+	}
+
 	containerName := container.GenContainerName(h.app.Id, fullHash, false)
 	startedExisting := false
+	var wakeStart time.Time
 	if h.lifetime != types.CONTAINER_LIFETIME_COMMAND {
 		hostNamePort, running, err := h.manager.GetContainerState(ctx, containerName, fullHash)
 		if err != nil {
@@ -1578,6 +1787,8 @@ func (h *ContainerHandler) ProdReload(ctx context.Context, dryRun bool, verify b
 			return nil
 		}
 		if hostNamePort != "" && !running {
+			// The container was stopped, like after an idle shutdown, wake it up
+			wakeStart = time.Now()
 			if err := h.manager.StartContainer(ctx, containerName); err != nil {
 				return fmt.Errorf("error starting stopped container: %w", err)
 			}
@@ -1633,7 +1844,7 @@ func (h *ContainerHandler) ProdReload(ctx context.Context, dryRun bool, verify b
 
 	if !startedExisting {
 		if err := h.manager.RunContainer(ctx, h.app.AppEntry, sourceDir, containerName,
-			h.GenImageName, h.port, h.envMap, h.volumeInfo, h.app.Metadata.ContainerOptions, h.paramMap, fullHash, h.IsImageSpec(),
+			h.GenImageName, h.port, h.envMap, h.volumeInfo, h.containerOptions, h.paramMap, fullHash, h.IsImageSpec(),
 			nil); err != nil {
 			return fmt.Errorf("error starting container after update: %w", err)
 		}
@@ -1642,6 +1853,13 @@ func (h *ContainerHandler) ProdReload(ctx context.Context, dryRun bool, verify b
 		h.registerDeployTxn(ctx, containerName, true)
 	}
 
+	if h.health != "" && gateReadiness {
+		// Requests wait for the container to be ready in the proxy, the health check is done
+		// in the background
+		h.startReadinessCheck(containerName, fullHash, wakeStart)
+		return nil
+	}
+
 	if h.health != "" {
 		if err := h.WaitForHealth(h.containerConfig.DeployHealthAttempts, containerName, fullHash); err != nil {
 			if h.containerConfig.ShowLogsForFailure {
@@ -1668,6 +1886,9 @@ func (h *ContainerHandler) ProdReload(ctx context.Context, dryRun bool, verify b
 	h.activeContainerName = containerName
 	h.activeVersionHash = fullHash
 	h.hostNamePort = hostNamePort
+	if startedExisting {
+		h.recordWakeup(ctx, wakeStart, nil)
+	}
 	return nil
 }
 
@@ -1726,7 +1947,7 @@ func (h *ContainerHandler) prodReloadKubernetes(ctx context.Context, fullHash st
 		Port:               h.port,
 		EnvMap:             h.envMap,
 		Volumes:            h.volumeInfo,
-		ContainerOptions:   h.app.Metadata.ContainerOptions,
+		ContainerOptions:   h.containerOptions,
 		ParamMap:           h.paramMap,
 		VersionHash:        fullHash,
 		IsImageSpec:        h.IsImageSpec(),
@@ -1767,6 +1988,9 @@ func (h *ContainerHandler) buildHealthProbe() *container.HealthProbe {
 		probePath = path.Join("/", h.app.Path, h.health)
 	}
 	period := int32(h.containerConfig.DeployProbePeriodSecs)
+	if h.containerConfig.HealthIntervalSecs > 0 {
+		period = int32(h.containerConfig.HealthIntervalSecs)
+	}
 	if period <= 0 {
 		period = 1
 	}
@@ -1826,6 +2050,9 @@ func (h *ContainerHandler) Close() error {
 	if h.healthCheckTicker != nil {
 		h.healthCheckTicker.Stop()
 	}
+	if h.app.resourceQuota != nil {
+		h.app.resourceQuota.release(h.app.Id, h)
+	}
 	return nil
 }
 
@@ -1843,7 +2070,7 @@ func (h *ContainerHandler) Run(ctx context.Context, path string, cmdArgs []strin
 	}
 
 	// Add container related args
-	commandOptions, err := container.ParseCommandOptions(h.serverConfig.System.ContainerCommand, h.app.Metadata.ContainerOptions)
+	commandOptions, err := container.ParseCommandOptions(h.serverConfig.System.ContainerCommand, h.containerOptions)
 	if err != nil {
 		return nil, err
 	}
@@ -1863,9 +2090,19 @@ func (h *ContainerHandler) Run(ctx context.Context, path string, cmdArgs []strin
 	h.Debug().Msgf("Running command with args: %v", container.RedactEnvArgs(args))
 
 	cmd := exec.CommandContext(ctx, h.serverConfig.System.ContainerCommand, args...)
+	// When ctx is canceled, like when the client of a streaming response disconnects, the
+	// container CLI is sent SIGTERM, which it forwards to the container. Killing the CLI would
+	// leave the container running. The CLI is killed if it does not exit after the delay
+	cmd.Cancel = func() error {
+		return cmd.Process.Signal(syscall.SIGTERM)
+	}
+	cmd.WaitDelay = runCancelWaitDelay
 	return cmd, nil
 }
 
+// runCancelWaitDelay is how long a canceled container command gets to stop before being killed
+const runCancelWaitDelay = 10 * time.Second
+
 func (h *ContainerHandler) getBindingEnv() (map[string]string, error) {
 	// stage, dev and preview apps use staging binding
 	useProdAccount := strings.HasPrefix(string(h.app.Id), types.ID_PREFIX_APP_PROD)
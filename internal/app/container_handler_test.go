@@ -335,6 +335,37 @@ func TestParseDevSettings(t *testing.T) {
 	}
 }
 
+func TestSelectContainerPort(t *testing.T) {
+	t.Parallel()
+
+	for _, test := range []struct {
+		name       string
+		spec       types.AppSpec
+		candidates []int32
+		want       int32
+		wantErr    string
+	}{
+		{"single port", "", []int32{8080}, 8080, ""},
+		{"single port ignores spec", "python-flask", []int32{8080}, 8080, ""},
+		{"no port no spec", "", nil, 0, ""},
+		{"no port spec default", "python-streamlit", nil, 8501, ""},
+		{"multiple with spec default", "python-gradio", []int32{443, 7860}, 7860, ""},
+		{"multiple ambiguous", "", []int32{80, 443}, 0, "multiple ports exposed by image nginx: 80, 443"},
+		{"multiple spec default missing", "python-flask", []int32{80, 443}, 0, "container.config(port=80)"},
+	} {
+		port, err := selectContainerPort(test.spec, "image nginx", test.candidates)
+		if test.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: error = %v, want %q", test.name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil || port != test.want {
+			t.Errorf("%s: port = (%d, %v), want %d", test.name, port, err, test.want)
+		}
+	}
+}
+
 func TestParseDevSettingsRejectsInvalidValues(t *testing.T) {
 	t.Parallel()
 
@@ -708,7 +739,7 @@ func TestProdReloadCommandKeepsSourceDirForSecretVolumeWhenImageAlreadyExists(t
 		},
 	}
 
-	if err := h.ProdReload(context.Background(), false, false); err != nil {
+	if err := h.ProdReload(context.Background(), false, false, false); err != nil {
 		t.Fatalf("ProdReload returned error: %v", err)
 	}
 	if manager.runSourceDir != sourceDir {
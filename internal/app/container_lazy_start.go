@@ -0,0 +1,138 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// lazyStartCall tracks a start of the container triggered by a request which found the container
+// not running, like after a crash or an external stop. done is closed when the start completes,
+// err is set before that. The requests received during the start share the call
+type lazyStartCall struct {
+	done chan struct{}
+	err  error
+}
+
+// lazyStartRetry is added to the request context when the request can be retried after starting
+// the container. The proxy error handler sets unreachable instead of writing the error response
+type lazyStartRetry struct {
+	unreachable bool
+}
+
+type lazyStartRetryKey struct{}
+
+// lazyStartEnabled reports whether requests can start the container. Dev apps, Kubernetes apps
+// (where the pods are restarted by Kubernetes) and command lifetime apps are not started
+func (h *ContainerHandler) lazyStartEnabled() bool {
+	return h.containerConfig.LazyStart && !h.app.IsDev && !h.isKubernetes &&
+		h.lifetime != types.CONTAINER_LIFETIME_COMMAND
+}
+
+// pendingLazyStart returns the container start in progress, nil if there is none
+func (h *ContainerHandler) pendingLazyStart() *lazyStartCall {
+	h.lazyStartLock.Lock()
+	defer h.lazyStartLock.Unlock()
+	call := h.lazyStarting
+	if call == nil {
+		return nil
+	}
+	select {
+	case <-call.done:
+		return nil
+	default:
+		return call
+	}
+}
+
+// lazyStart starts the container in the background, unless a start is already in progress.
+// Returns nil if the handler is closed, the app is being reloaded in that case
+func (h *ContainerHandler) lazyStart() *lazyStartCall {
+	h.lazyStartLock.Lock()
+	defer h.lazyStartLock.Unlock()
+	if call := h.lazyStarting; call != nil {
+		select {
+		case <-call.done:
+		default:
+			return call
+		}
+	}
+	select {
+	case <-h.closeCh:
+		return nil
+	default:
+	}
+
+	call := &lazyStartCall{done: make(chan struct{})}
+	h.lazyStarting = call
+	h.Info().Msgf("Container for app %s is not reachable, starting it", h.app.Id)
+	go func() {
+		start := h.lazyStartFunc
+		if start == nil {
+			// The existing container is started if it was stopped, else a new one is run. The
+			// requests wait on the readiness gate for the health check
+			start = func(ctx context.Context) error {
+				return h.ProdReload(ctx, false, false, true)
+			}
+		}
+		call.err = start(context.Background())
+		if call.err != nil {
+			h.Error().Err(call.err).Msgf("Error starting container for app %s", h.app.Id)
+		}
+		close(call.done)
+	}()
+	return call
+}
+
+// lazyStartRetryable reports whether the request can be retried after starting the container.
+// Requests with a body are not retried, since the body is consumed by the failed attempt
+func (h *ContainerHandler) lazyStartRetryable(r *http.Request) bool {
+	return h.lazyStartEnabled() && (r.Body == nil || r.Body == http.NoBody)
+}
+
+// markLazyStartRetry is called from the container proxy error handler. Returns true if the
+// container could not be reached and the request is retried after starting the container, the
+// error response should not be written in that case
+func markLazyStartRetry(r *http.Request, err error) bool {
+	retry, ok := r.Context().Value(lazyStartRetryKey{}).(*lazyStartRetry)
+	if !ok {
+		return false
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Op != "dial" {
+		return false
+	}
+	retry.unreachable = true
+	return true
+}
+
+// serveLazyStart serves the request, starting the container and retrying the request if the
+// container is not reachable. The retried request waits up to container.wakeup_wait_secs for
+// the container to be ready
+func (h *ContainerHandler) serveLazyStart(handler http.Handler, w http.ResponseWriter, r *http.Request) {
+	if !h.lazyStartRetryable(r) {
+		handler.ServeHTTP(w, r)
+		return
+	}
+
+	retry := &lazyStartRetry{}
+	handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), lazyStartRetryKey{}, retry)))
+	if !retry.unreachable {
+		return
+	}
+
+	call := h.lazyStart()
+	if call == nil {
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+	if h.waitForContainer(w, r, call) {
+		handler.ServeHTTP(w, r)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func lazyStartTestHandler(startErr error) (*ContainerHandler, *atomic.Int32) {
+	h := readinessTestHandler(0)
+	h.Logger = testutil.TestLogger()
+	h.closeCh = make(chan struct{})
+	h.containerConfig.LazyStart = true
+	h.containerConfig.WakeupWaitSecs = 10
+	starts := &atomic.Int32{}
+	h.lazyStartFunc = func(ctx context.Context) error {
+		starts.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return startErr
+	}
+	return h, starts
+}
+
+// unreachableProxy simulates the container proxy, failing with a dial error until the container
+// is started
+func unreachableProxy(starts *atomic.Int32, served *atomic.Int32) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if starts.Load() == 0 {
+			err := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+			if !markLazyStartRetry(r, err) {
+				w.WriteHeader(http.StatusBadGateway)
+			}
+			return
+		}
+		served.Add(1)
+	})
+}
+
+func TestLazyStartRetry(t *testing.T) {
+	h, starts := lazyStartTestHandler(nil)
+	served := &atomic.Int32{}
+	handler := h.readinessHandler(unreachableProxy(starts, served))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || served.Load() != 1 || starts.Load() != 1 {
+		t.Errorf("expected request to be served after start, got %d served %d starts %d", w.Code, served.Load(), starts.Load())
+	}
+
+	// Requests with a body are not retried
+	h2, starts2 := lazyStartTestHandler(nil)
+	served2 := &atomic.Int32{}
+	w = httptest.NewRecorder()
+	h2.readinessHandler(unreachableProxy(starts2, served2)).ServeHTTP(w,
+		httptest.NewRequest(http.MethodPost, "/", strings.NewReader("data")))
+	if w.Code != http.StatusBadGateway || starts2.Load() != 0 {
+		t.Errorf("expected proxy error, got %d starts %d", w.Code, starts2.Load())
+	}
+}
+
+func TestLazyStartDisabled(t *testing.T) {
+	h, starts := lazyStartTestHandler(nil)
+	h.containerConfig.LazyStart = false
+	served := &atomic.Int32{}
+	w := httptest.NewRecorder()
+	h.readinessHandler(unreachableProxy(starts, served)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusBadGateway || starts.Load() != 0 {
+		t.Errorf("expected proxy error, got %d starts %d", w.Code, starts.Load())
+	}
+
+	// No start after the handler is closed
+	h.containerConfig.LazyStart = true
+	close(h.closeCh)
+	w = httptest.NewRecorder()
+	h.readinessHandler(unreachableProxy(starts, served)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusBadGateway || starts.Load() != 0 {
+		t.Errorf("expected proxy error after close, got %d starts %d", w.Code, starts.Load())
+	}
+}
+
+func TestLazyStartFailure(t *testing.T) {
+	h, starts := lazyStartTestHandler(errors.New("error starting container"))
+	served := &atomic.Int32{}
+	w := httptest.NewRecorder()
+	h.readinessHandler(unreachableProxy(starts, served)).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusServiceUnavailable || served.Load() != 0 {
+		t.Errorf("expected start failure, got %d served %d", w.Code, served.Load())
+	}
+}
+
+func TestLazyStartShared(t *testing.T) {
+	h, starts := lazyStartTestHandler(nil)
+	call := h.lazyStart()
+	if h.lazyStart() != call || h.pendingLazyStart() != call {
+		t.Error("expected the start in progress to be shared")
+	}
+
+	// Requests received during the start are queued until it completes
+	served := false
+	w := httptest.NewRecorder()
+	h.readinessHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = true
+	})).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !served || starts.Load() != 1 {
+		t.Errorf("expected request to be served after start, served %t starts %d", served, starts.Load())
+	}
+	if h.pendingLazyStart() != nil {
+		t.Error("expected no start in progress")
+	}
+}
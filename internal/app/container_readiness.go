@@ -0,0 +1,251 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/container"
+	"github.com/openrundev/openrun/internal/telemetry"
+)
+
+// readinessGate tracks the startup health check of a container which was started without
+// waiting for its health check. done is closed when the check completes, err is set before that.
+// wakeStart is set when a stopped container was started, like after an idle shutdown
+type readinessGate struct {
+	done      chan struct{}
+	err       error
+	wakeStart time.Time
+}
+
+// startingPageTemplate is the page shown to browsers while the app container is starting. The
+// page reloads itself, the app is served once the container is ready
+var startingPageTemplate = template.Must(template.New("starting").Parse(`<!doctype html>
+<html lang="en">
+  <head>
+    <meta charset="UTF-8" />
+    <meta name="viewport" content="width=device-width, initial-scale=1.0" />
+    <meta http-equiv="refresh" content="{{ .RetrySecs }}" />
+    <title>Starting - OpenRun</title>
+  </head>
+  <body style="font-family: sans-serif; display: flex; min-height: 90vh; align-items: center; justify-content: center">
+    <main style="text-align: center">
+      <h2>{{ .Title }}</h2>
+      <p>{{ .Message }}</p>
+    </main>
+  </body>
+</html>
+`))
+
+// startReadinessCheck marks the container as starting and runs the startup health check in the
+// background. Called from ProdReload with stateLock held, after the container is started
+func (h *ContainerHandler) startReadinessCheck(containerName container.ContainerName, fullHash string, wakeStart time.Time) {
+	gate := &readinessGate{done: make(chan struct{}), wakeStart: wakeStart}
+	h.readiness.Store(gate)
+	h.currentState = ContainerStateStarting
+	h.activeContainerName = containerName
+	h.activeVersionHash = fullHash
+	go h.readinessCheck(gate, containerName, fullHash)
+}
+
+func (h *ContainerHandler) readinessCheck(gate *readinessGate, containerName container.ContainerName, fullHash string) {
+	ctx := context.Background()
+	// stateLock is not held during the wait, so that the state can be reported as starting.
+	// WaitForHealth updates the host port as the container comes up
+	err := h.WaitForHealth(h.containerConfig.DeployHealthAttempts, containerName, fullHash)
+	var hostNamePort string
+	if err == nil {
+		var running bool
+		hostNamePort, running, err = h.manager.GetContainerState(ctx, containerName, fullHash)
+		if err == nil && (hostNamePort == "" || !running) {
+			err = fmt.Errorf("container not running")
+		}
+	}
+	if err != nil && h.containerConfig.ShowLogsForFailure {
+		logs, _ := h.manager.GetContainerLogs(ctx, containerName, h.containerConfig.LogLinesToShow)
+		err = fmt.Errorf("%w. Logs\n %s", err, logs)
+	}
+
+	closed := false
+	select {
+	case <-h.closeCh:
+		// The app was reloaded or closed, the container could be in use by the new handler
+		closed = true
+	default:
+	}
+
+	h.stateLock.Lock()
+	if err == nil {
+		h.currentState = ContainerStateRunning
+		h.hostNamePort = hostNamePort
+	} else {
+		h.Error().Err(err).Msgf("Container for app %s did not become ready", h.app.Id)
+		h.currentState = ContainerStateHealthFailure
+		if !closed {
+			if stopErr := h.manager.StopContainer(ctx, containerName); stopErr != nil {
+				h.Error().Err(stopErr).Msgf("Error stopping app %s after health failure", h.app.Id)
+			}
+			h.stopServices(ctx)
+		}
+	}
+	h.stateLock.Unlock()
+
+	if !gate.wakeStart.IsZero() {
+		h.recordWakeup(ctx, gate.wakeStart, err)
+	}
+	gate.err = err
+	close(gate.done)
+
+	if err != nil && !closed && h.app.notifyClose != nil {
+		// Notify the server to close the app so that it gets reinitialized on next API call
+		select {
+		case h.app.notifyClose <- h.app.AppPathDomain():
+		case <-h.closeCh:
+		}
+	}
+}
+
+// isStarting reports whether the startup health check of the container is in progress
+func (h *ContainerHandler) isStarting() bool {
+	gate := h.readiness.Load()
+	if gate == nil {
+		return false
+	}
+	select {
+	case <-gate.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// WaitReady waits for the startup health check of the container to complete. ready is false if
+// the context is done first. The health check error is returned if the container did not
+// become ready. Returns immediately if the container was started with a blocking health check
+func (h *ContainerHandler) WaitReady(ctx context.Context) (ready bool, err error) {
+	gate := h.readiness.Load()
+	if gate == nil {
+		return true, nil
+	}
+	// Checked first, so that a completed check is reported even if the context is done
+	select {
+	case <-gate.done:
+		return true, gate.err
+	default:
+	}
+	select {
+	case <-gate.done:
+		return true, gate.err
+	case <-ctx.Done():
+		return false, nil
+	}
+}
+
+// readinessHandler wraps the container proxy handler. Requests received while the container is
+// starting wait up to container.readiness_wait_secs for it to become ready, after that a 503
+// response is returned with a page which retries the request. When a stopped container is woken
+// up, the requests wait up to container.wakeup_wait_secs, so that the wakeup is transparent. With
+// container.lazy_start, a request which finds the container not reachable starts it
+func (h *ContainerHandler) readinessHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.waitForContainer(w, r, h.pendingLazyStart()) {
+			h.serveLazyStart(handler, w, r)
+		}
+	})
+}
+
+// waitForContainer waits for the container start in progress, call is set for a start triggered
+// by a request. Returns true if the container is ready, else the response has been written
+func (h *ContainerHandler) waitForContainer(w http.ResponseWriter, r *http.Request, call *lazyStartCall) bool {
+	gate := h.readiness.Load()
+	if gate == nil && call == nil {
+		return true
+	}
+
+	waitSecs := h.containerConfig.ReadinessWaitSecs
+	if call != nil || !gate.wakeStart.IsZero() {
+		waitSecs = max(waitSecs, h.containerConfig.WakeupWaitSecs)
+	}
+	// Only the requests which were queued are recorded in the wait metric
+	queued := call != nil || h.isStarting()
+	waitStart := time.Now()
+	ctx, cancel := context.WithTimeout(r.Context(), time.Duration(waitSecs)*time.Second)
+	ready, err := h.waitStarted(ctx, call)
+	cancel()
+
+	var outcome string
+	switch {
+	case !ready && r.Context().Err() != nil:
+		// Client went away
+		outcome = "canceled"
+	case !ready:
+		outcome = "timeout"
+		h.startingResponse(w, r)
+	case err != nil:
+		outcome = "failed"
+		http.Error(w, "App failed to start", http.StatusServiceUnavailable)
+	default:
+		outcome = "ready"
+	}
+	if queued {
+		telemetry.RecordContainerRequestWait(r.Context(), waitStart, outcome, h.app.telemetryIdentityAttrs...)
+	}
+	return outcome == "ready"
+}
+
+// waitStarted waits for the request triggered start of the container, if any, and then for the
+// startup health check
+func (h *ContainerHandler) waitStarted(ctx context.Context, call *lazyStartCall) (ready bool, err error) {
+	if call != nil {
+		select {
+		case <-call.done:
+		case <-ctx.Done():
+			return false, nil
+		}
+		if call.err != nil {
+			return true, call.err
+		}
+	}
+	return h.WaitReady(ctx)
+}
+
+// recordWakeup logs and records the time taken to start a stopped container until it is ready
+func (h *ContainerHandler) recordWakeup(ctx context.Context, start time.Time, err error) {
+	if err == nil {
+		h.Info().Msgf("Started stopped container for app %s in %s", h.app.Id, time.Since(start).Round(time.Millisecond))
+	}
+	telemetry.RecordContainerWakeup(ctx, start, err, h.app.telemetryIdentityAttrs...)
+}
+
+// startingResponse writes the 503 response for a request to a starting container. Browser
+// navigations get a page which reloads after the retry interval
+func (h *ContainerHandler) startingResponse(w http.ResponseWriter, r *http.Request) {
+	retrySecs := max(h.containerConfig.HealthIntervalSecs, 2)
+	w.Header().Set("Retry-After", strconv.Itoa(retrySecs))
+	w.Header().Set("Cache-Control", "no-store")
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) ||
+		!strings.Contains(r.Header.Get("Accept"), "text/html") || r.Header.Get("HX-Request") == "true" {
+		http.Error(w, "App is starting, retry after "+strconv.Itoa(retrySecs)+" seconds", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	data := map[string]any{
+		"Title":     "Starting " + cmp.Or(h.app.Name, "app"),
+		"Message":   "The app is starting, this page will refresh when it is ready.",
+		"RetrySecs": retrySecs,
+	}
+	if err := startingPageTemplate.Execute(w, data); err != nil {
+		h.Error().Err(err).Msg("error rendering starting page")
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func readinessTestHandler(waitSecs int) *ContainerHandler {
+	return &ContainerHandler{
+		app:             &App{AppEntry: &types.AppEntry{Id: "app_prd_123"}},
+		containerConfig: types.Container{ReadinessWaitSecs: waitSecs},
+		currentState:    ContainerStateRunning,
+	}
+}
+
+func TestReadinessHandlerStarting(t *testing.T) {
+	h := readinessTestHandler(0)
+	served := false
+	handler := h.readinessHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = true
+	}))
+
+	// No startup check, requests are proxied
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !served {
+		t.Fatal("expected request to be served")
+	}
+
+	gate := &readinessGate{done: make(chan struct{})}
+	h.readiness.Store(gate)
+	if state, _ := h.State(); state != ContainerStateStarting {
+		t.Errorf("unexpected state %s", state)
+	}
+
+	served = false
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if served || w.Code != http.StatusServiceUnavailable || w.Header().Get("Retry-After") != "2" {
+		t.Errorf("unexpected response %d %v", w.Code, w.Header())
+	}
+	if !strings.Contains(w.Body.String(), "App is starting") {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), `http-equiv="refresh"`) {
+		t.Errorf("expected starting page, got %d %q", w.Code, w.Body.String())
+	}
+
+	close(gate.done)
+	if state, _ := h.State(); state != ContainerStateRunning {
+		t.Errorf("unexpected state %s", state)
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !served {
+		t.Error("expected request to be served once ready")
+	}
+}
+
+func TestReadinessHandlerWait(t *testing.T) {
+	h := readinessTestHandler(10)
+	gate := &readinessGate{done: make(chan struct{})}
+	h.readiness.Store(gate)
+	served := false
+	handler := h.readinessHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = true
+	}))
+
+	// The request waits for the container to become ready
+	time.AfterFunc(20*time.Millisecond, func() { close(gate.done) })
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !served || w.Code != http.StatusOK {
+		t.Errorf("expected request to be served, got %d", w.Code)
+	}
+
+	failed := &readinessGate{done: make(chan struct{}), err: errors.New("health check returned status 500")}
+	close(failed.done)
+	h.readiness.Store(failed)
+	served = false
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if served || w.Code != http.StatusServiceUnavailable {
+		t.Errorf("unexpected response %d", w.Code)
+	}
+	if ready, err := h.WaitReady(t.Context()); !ready || err == nil {
+		t.Errorf("expected health failure, got %t %v", ready, err)
+	}
+}
+
+func TestReadinessHandlerWakeup(t *testing.T) {
+	h := readinessTestHandler(0)
+	h.containerConfig.WakeupWaitSecs = 10
+	served := false
+	handler := h.readinessHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = true
+	}))
+
+	// Requests to a container woken up from an idle shutdown wait for it to be ready
+	gate := &readinessGate{done: make(chan struct{}), wakeStart: time.Now()}
+	h.readiness.Store(gate)
+	time.AfterFunc(20*time.Millisecond, func() { close(gate.done) })
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !served || w.Code != http.StatusOK {
+		t.Errorf("expected request to be served after wakeup, got %d", w.Code)
+	}
+}
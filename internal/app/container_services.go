@@ -0,0 +1,285 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/container"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// serviceNameRegex restricts service names to DNS labels, the name is the host name of the
+// service on the app network
+var serviceNameRegex = regexp.MustCompile(`^[a-z][a-z0-9-]{0,31}$`)
+
+// parseContainerServices converts the services dict from container.config into the service
+// list, sorted by name. Each entry maps the service name to a dict with the image, src,
+// command, env and volumes keys
+func parseContainerServices(m map[string]any) ([]types.ContainerService, error) {
+	ret := make([]types.ContainerService, 0, len(m))
+	for _, name := range slices.Sorted(maps.Keys(m)) {
+		if !serviceNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("invalid service name %q, names must start with a lowercase letter and have lowercase letters, digits or dashes", name)
+		}
+		entry, ok := m[name].(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("service %s config must be a dict", name)
+		}
+
+		svc := types.ContainerService{Name: name}
+		for _, key := range slices.Sorted(maps.Keys(entry)) {
+			var err error
+			switch key {
+			case "image":
+				svc.Image, err = serviceString(name, key, entry[key])
+			case "src":
+				svc.Source, err = serviceString(name, key, entry[key])
+			case "command":
+				svc.Command, err = serviceStringList(name, key, entry[key])
+			case "volumes":
+				svc.Volumes, err = serviceStringList(name, key, entry[key])
+			case "env":
+				env, ok := entry[key].(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("service %s env must be a dict", name)
+				}
+				svc.Env = make(map[string]string, len(env))
+				for k, v := range env {
+					svc.Env[k] = fmt.Sprint(v)
+				}
+			default:
+				return nil, fmt.Errorf("unsupported service %s key %q, allowed keys are %s", name, key,
+					strings.Join(types.ContainerServiceKeys, ", "))
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		svc.Image = strings.TrimPrefix(svc.Image, types.CONTAINER_SOURCE_IMAGE_PREFIX)
+		if svc.Image != "" && svc.Source != "" {
+			return nil, fmt.Errorf("service %s cannot set both image and src", name)
+		}
+		ret = append(ret, svc)
+	}
+	return ret, nil
+}
+
+func serviceString(name, key string, v any) (string, error) {
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("service %s %s must be a string", name, key)
+	}
+	return s, nil
+}
+
+func serviceStringList(name, key string, v any) ([]string, error) {
+	list, ok := v.([]any)
+	if !ok {
+		return nil, fmt.Errorf("service %s %s must be a list of strings", name, key)
+	}
+	ret := make([]string, 0, len(list))
+	for _, entry := range list {
+		s, ok := entry.(string)
+		if !ok {
+			return nil, fmt.Errorf("service %s %s must be a list of strings", name, key)
+		}
+		ret = append(ret, s)
+	}
+	return ret, nil
+}
+
+// parseServiceVolumes parses the service volumes. Only named and unnamed volumes are
+// supported, a volume with the same name as an app container volume shares the data
+func (h *ContainerHandler) parseServiceVolumes(svc types.ContainerService) ([]*container.VolumeInfo, error) {
+	ret := make([]*container.VolumeInfo, 0, len(svc.Volumes))
+	for _, vol := range svc.Volumes {
+		volInfo, err := h.parseVolumeString(vol)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing service %s volume %s: %w", svc.Name, vol, err)
+		}
+		if volInfo.VolumeName == "" {
+			return nil, fmt.Errorf("service %s volume %s is not supported, only named volumes can be used for services", svc.Name, vol)
+		}
+		ret = append(ret, volInfo)
+	}
+	return ret, nil
+}
+
+// serviceSpec returns the run spec for the service. appHash is the app version hash, used for
+// the services which run the app image or an image built from the app source
+func (h *ContainerHandler) serviceSpec(svc types.ContainerService, appHash string) (container.ServiceSpec, error) {
+	spec := container.ServiceSpec{
+		Name:    svc.Name,
+		Command: svc.Command,
+		Volumes: h.serviceVolumes[svc.Name],
+		Env:     maps.Clone(svc.Env),
+	}
+
+	identity := svc.Image
+	switch {
+	case svc.Image != "":
+		spec.Image = container.ImageName(svc.Image)
+	case svc.Source != "":
+		spec.Image = container.GenServiceImageName(h.app.Id, svc.Name, appHash)
+		identity = "src:" + svc.Source + ":" + appHash
+	default:
+		// Runs the app image with the app env, like a worker using the app code
+		spec.Image = h.GenImageName
+		identity = "app:" + string(h.GenImageName) + ":" + appHash + ":" + h.envMapHash
+		env := maps.Clone(h.envMap)
+		maps.Copy(env, svc.Env)
+		spec.Env = env
+	}
+
+	envHash, err := getMapHash(svc.Env)
+	if err != nil {
+		return spec, err
+	}
+	spec.VersionHash, err = getValuesHash(svc.Name, identity, strings.Join(svc.Command, "\x00"),
+		envHash, strings.Join(svc.Volumes, "\x00"))
+	if err != nil {
+		return spec, err
+	}
+	spec.ContainerName = container.GenServiceContainerName(h.app.Id, svc.Name, spec.VersionHash)
+	return spec, nil
+}
+
+// servicesUseAppImage reports whether any service runs the app image
+func (h *ContainerHandler) servicesUseAppImage() bool {
+	return slices.ContainsFunc(h.services, func(svc types.ContainerService) bool {
+		return svc.Image == "" && svc.Source == ""
+	})
+}
+
+// buildProdImageIfMissing builds the app image before the services are started, when a
+// service runs the app image
+func (h *ContainerHandler) buildProdImageIfMissing(ctx context.Context) error {
+	imageExists, err := h.manager.ImageExists(ctx, h.GenImageName)
+	if err != nil {
+		return fmt.Errorf("error getting images: %w", err)
+	}
+	if imageExists {
+		return nil
+	}
+	sourceDir, err := h.sourceFS.CreateTempSourceDir()
+	if err != nil {
+		return fmt.Errorf("error creating temp source dir: %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(sourceDir); err != nil {
+			h.Warn().Err(err).Msgf("error removing temp source dir for app %s", h.app.Id)
+		}
+	}()
+	if err := h.manager.BuildImage(ctx, h.GenImageName, path.Join(sourceDir, h.buildDir), h.containerFile, h.cargs); err != nil {
+		return fmt.Errorf("error building image: %w", err)
+	}
+	return nil
+}
+
+// startServices starts the service containers of a multi-container app, before the app
+// container is started. The app network is created first, the app container run after this is
+// attached to the network. An empty appHash is passed when the app image is rebuilt on every
+// reload (dev mode without dev_settings), the services using the app image or source are then
+// rebuilt and recreated too. Superseded service containers are stopped
+func (h *ContainerHandler) startServices(ctx context.Context, appHash string) error {
+	if len(h.services) == 0 {
+		return nil
+	}
+	sm, ok := container.AsServiceManager(h.manager)
+	if !ok {
+		return fmt.Errorf("container manager does not support container services")
+	}
+	if err := sm.EnsureAppNetwork(ctx); err != nil {
+		return err
+	}
+
+	sourceDir := ""
+	defer func() {
+		if sourceDir != "" && sourceDir != h.app.SourceUrl {
+			if err := os.RemoveAll(sourceDir); err != nil {
+				h.Warn().Err(err).Msgf("error removing temp source dir for app %s", h.app.Id)
+			}
+		}
+	}()
+
+	names := make([]container.ContainerName, 0, len(h.services))
+	for _, svc := range h.services {
+		spec, err := h.serviceSpec(svc, appHash)
+		if err != nil {
+			return fmt.Errorf("error creating service %s spec: %w", svc.Name, err)
+		}
+		rebuild := appHash == "" && svc.Image == ""
+
+		if svc.Source != "" {
+			exists, err := h.manager.ImageExists(ctx, spec.Image)
+			if err != nil {
+				return fmt.Errorf("error checking service %s image: %w", svc.Name, err)
+			}
+			if !exists || rebuild {
+				if sourceDir == "" {
+					if h.app.IsDev {
+						sourceDir = h.app.SourceUrl
+					} else if sourceDir, err = h.sourceFS.CreateTempSourceDir(); err != nil {
+						return fmt.Errorf("error creating temp source dir: %w", err)
+					}
+				}
+				if err := h.manager.BuildImage(ctx, spec.Image, path.Join(sourceDir, h.buildDir), svc.Source, h.cargs); err != nil {
+					return fmt.Errorf("error building service %s image: %w", svc.Name, err)
+				}
+			}
+		}
+
+		if rebuild {
+			if devCM, ok := h.manager.(container.DevContainerManager); ok {
+				_ = devCM.RemoveContainer(ctx, spec.ContainerName)
+			}
+		}
+		if err := h.createNamedVolumes(ctx, spec.Volumes); err != nil {
+			return err
+		}
+		if err := sm.RunService(ctx, h.app.AppEntry, spec); err != nil {
+			return err
+		}
+		names = append(names, spec.ContainerName)
+	}
+
+	if err := sm.StopAppServicesExcept(ctx, names); err != nil {
+		h.Warn().Err(err).Msgf("Error stopping superseded services for app %s", h.app.Id)
+	}
+	h.stateLock.Lock()
+	h.activeServiceNames = names
+	h.stateLock.Unlock()
+	return nil
+}
+
+// stopServices stops the service containers along with the app container, on idle shutdown
+// and health failure. They are started again on the next app reload
+func (h *ContainerHandler) stopServices(ctx context.Context) {
+	if len(h.services) == 0 {
+		return
+	}
+	sm, ok := container.AsServiceManager(h.manager)
+	if !ok {
+		return
+	}
+	if err := sm.StopAppServicesExcept(ctx, nil); err != nil {
+		h.Error().Err(err).Msgf("Error stopping services for app %s", h.app.Id)
+	}
+}
+
+// ActiveServiceNames returns the service containers started by the last app reload
+func (h *ContainerHandler) ActiveServiceNames() []container.ContainerName {
+	h.stateLock.RLock()
+	defer h.stateLock.RUnlock()
+	return slices.Clone(h.activeServiceNames)
+}
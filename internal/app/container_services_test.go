@@ -0,0 +1,102 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/container"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestParseContainerServices(t *testing.T) {
+	services, err := parseContainerServices(map[string]any{
+		"worker": map[string]any{"command": []any{"python", "worker.py"}, "env": map[string]any{"QUEUE": "jobs", "N": 2}},
+		"redis":  map[string]any{"image": "image:redis:7", "volumes": []any{"redis-data:/data"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(services) != 2 || services[0].Name != "redis" || services[1].Name != "worker" {
+		t.Fatalf("unexpected services %+v", services)
+	}
+	if services[0].Image != "redis:7" || len(services[0].Volumes) != 1 {
+		t.Errorf("unexpected redis service %+v", services[0])
+	}
+	if strings.Join(services[1].Command, " ") != "python worker.py" || services[1].Env["N"] != "2" {
+		t.Errorf("unexpected worker service %+v", services[1])
+	}
+
+	invalid := []struct {
+		name     string
+		services map[string]any
+		err      string
+	}{
+		{"bad name", map[string]any{"Redis": map[string]any{}}, "invalid service name"},
+		{"not dict", map[string]any{"redis": "redis:7"}, "must be a dict"},
+		{"unknown key", map[string]any{"redis": map[string]any{"ports": []any{"6379"}}}, "unsupported service redis key"},
+		{"image and src", map[string]any{"redis": map[string]any{"image": "redis", "src": "Containerfile.redis"}}, "cannot set both"},
+		{"bad command", map[string]any{"redis": map[string]any{"command": "redis-server"}}, "must be a list of strings"},
+	}
+	for _, tt := range invalid {
+		if _, err := parseContainerServices(tt.services); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: expected error %q, got %v", tt.name, tt.err, err)
+		}
+	}
+}
+
+func TestServiceSpec(t *testing.T) {
+	h := &ContainerHandler{
+		app:          &App{AppEntry: &types.AppEntry{Id: "app_prd_123"}},
+		GenImageName: "cli-app_prd_123:abc",
+		envMap:       map[string]string{"DB": "x", "QUEUE": "app"},
+		envMapHash:   "envhash",
+	}
+
+	redis, err := h.serviceSpec(types.ContainerService{Name: "redis", Image: "redis:7"}, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redis.Image != "redis:7" || len(redis.Env) != 0 {
+		t.Errorf("unexpected redis spec %+v", redis)
+	}
+	if !strings.HasPrefix(string(redis.ContainerName), "clc-app_prd_123-redis-") {
+		t.Errorf("unexpected container name %s", redis.ContainerName)
+	}
+	redis2, err := h.serviceSpec(types.ContainerService{Name: "redis", Image: "redis:7"}, "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redis.ContainerName != redis2.ContainerName {
+		t.Error("image services should not be recreated on app version change")
+	}
+
+	worker := types.ContainerService{Name: "worker", Command: []string{"worker"}, Env: map[string]string{"QUEUE": "jobs"}}
+	spec, err := h.serviceSpec(worker, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.Image != h.GenImageName || spec.Env["DB"] != "x" || spec.Env["QUEUE"] != "jobs" {
+		t.Errorf("unexpected worker spec %+v", spec)
+	}
+	if h.envMap["QUEUE"] != "app" {
+		t.Error("app env should not be modified")
+	}
+	spec2, err := h.serviceSpec(worker, "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spec.ContainerName == spec2.ContainerName {
+		t.Error("app image services should be recreated on app version change")
+	}
+
+	built, err := h.serviceSpec(types.ContainerService{Name: "proxy", Source: "Containerfile.proxy"}, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if built.Image != container.GenServiceImageName("app_prd_123", "proxy", "v1") {
+		t.Errorf("unexpected built image %s", built.Image)
+	}
+}
@@ -0,0 +1,45 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+
+	"github.com/openrundev/openrun/internal/app/apptype"
+)
+
+// ContractChecks returns the checks declared in contract.star, for verifying that the proxied
+// backend still behaves as the app expects. Each check path should be under a proxy route.
+// Returns nil if the app has no contract file
+func (a *App) ContractChecks() ([]apptype.ContractCheck, error) {
+	fileName := a.getStarPath(apptype.CONTRACT_FILE_NAME)
+	data, err := a.sourceFS.ReadFile(fileName)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	checks, err := apptype.LoadContractChecks(fileName, data)
+	if err != nil {
+		return nil, err
+	}
+
+	a.initMutex.Lock()
+	proxyPaths := slices.Clone(a.proxyPaths)
+	a.initMutex.Unlock()
+	if len(proxyPaths) == 0 {
+		return nil, fmt.Errorf("app has no proxy routes, contract checks are supported for proxy and container apps only")
+	}
+	for _, check := range checks {
+		if !slices.ContainsFunc(proxyPaths, func(p string) bool { return pathHasPrefix(check.Path, p) }) {
+			return nil, fmt.Errorf("check %s: path %s is not under a proxy route", check.Name, check.Path)
+		}
+	}
+	return checks, nil
+}
@@ -0,0 +1,69 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/openrundev/openrun/internal/app/apptype"
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// loadCronDefs reads the scheduled tasks declared with ace.cron in the app definition. The
+// handler is run as a background job, so it has to be a top level function which can be
+// looked up by name in the app globals when the job runs
+func loadCronDefs(appDef *starlarkstruct.Struct, globals starlark.StringDict) ([]types.CronDef, error) {
+	cronsAttr, err := appDef.Attr("crons")
+	if err != nil || cronsAttr == nil {
+		return nil, nil
+	}
+	cronList, ok := cronsAttr.(*starlark.List)
+	if !ok {
+		return nil, fmt.Errorf("crons is not a list")
+	}
+
+	crons := []types.CronDef{}
+	names := []string{}
+	for i := range cronList.Len() {
+		cronDef, ok := cronList.Index(i).(*starlarkstruct.Struct)
+		if !ok {
+			return nil, fmt.Errorf("crons entry %d is not a struct", i+1)
+		}
+		name, err := apptype.GetStringAttr(cronDef, "name")
+		if err != nil {
+			return nil, err
+		}
+		schedule, err := apptype.GetStringAttr(cronDef, "schedule")
+		if err != nil {
+			return nil, err
+		}
+		handler, err := apptype.GetCallableAttr(cronDef, "handler")
+		if err != nil {
+			return nil, err
+		}
+
+		if _, err := system.ParseCronSchedule(schedule); err != nil {
+			return nil, fmt.Errorf("cron %s: %w", name, err)
+		}
+		starFn, ok := handler.(*starlark.Function)
+		if !ok || globals[starFn.Name()] != starFn {
+			return nil, fmt.Errorf("cron %s: handler should be a top level function defined in the app, got %s", name, handler.Name())
+		}
+		if slices.Contains(names, name) {
+			return nil, fmt.Errorf("cron %s: duplicate cron name", name)
+		}
+		names = append(names, name)
+
+		crons = append(crons, types.CronDef{
+			Name:     name,
+			Schedule: schedule,
+			Handler:  starFn.Name(),
+		})
+	}
+	return crons, nil
+}
@@ -0,0 +1,66 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+package app
+
+import (
+	"testing"
+
+	"github.com/openrundev/openrun/internal/app/apptype"
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+)
+
+func loadTestCrons(t *testing.T, src string) ([]types.CronDef, error) {
+	t.Helper()
+	globals, err := starlark.ExecFile(&starlark.Thread{}, "app.star", src, apptype.CreateBuiltin(nil, nil))
+	testutil.AssertNoError(t, err)
+	appDef, err := verifyConfig(globals)
+	testutil.AssertNoError(t, err)
+	return loadCronDefs(appDef, globals)
+}
+
+func TestLoadCronDefs(t *testing.T) {
+	crons, err := loadTestCrons(t, `
+def refresh_cache():
+    pass
+
+def cleanup():
+    pass
+
+app = ace.app("test", crons=[
+    ace.cron("*/5 * * * *", handler=refresh_cache),
+    ace.cron("@daily", cleanup, name="nightly"),
+])
+`)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "count", 2, len(crons))
+	testutil.AssertEqualsString(t, "name", "refresh_cache", crons[0].Name)
+	testutil.AssertEqualsString(t, "schedule", "*/5 * * * *", crons[0].Schedule)
+	testutil.AssertEqualsString(t, "handler", "refresh_cache", crons[0].Handler)
+	testutil.AssertEqualsString(t, "named", "nightly", crons[1].Name)
+	testutil.AssertEqualsString(t, "named handler", "cleanup", crons[1].Handler)
+
+	crons, err = loadTestCrons(t, `app = ace.app("test")`)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "no crons", 0, len(crons))
+
+	_, err = loadTestCrons(t, `
+def f():
+    pass
+app = ace.app("test", crons=[ace.cron("* * *", f)])
+`)
+	testutil.AssertErrorContains(t, err, "expected 5 fields")
+
+	_, err = loadTestCrons(t, `
+def f():
+    pass
+app = ace.app("test", crons=[ace.cron("@hourly", f), ace.cron("@daily", f)])
+`)
+	testutil.AssertErrorContains(t, err, "duplicate cron name")
+
+	_, err = loadTestCrons(t, `
+app = ace.app("test", crons=[ace.cron("@hourly", lambda: None, name="anon")])
+`)
+	testutil.AssertErrorContains(t, err, "top level function")
+}
@@ -0,0 +1,361 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openrundev/openrun/internal/app/apptype"
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+const (
+	debugBreakBuiltin = "__debug_break__"
+	debugPauseTimeout = 5 * time.Minute
+	debugWaitTimeout  = 30 * time.Second
+	debugMaxValueLen  = 1024
+)
+
+// debugger implements breakpoints for the Starlark code of dev apps. Starlark does not have a
+// line level hook, so breakpoints are added by instrumenting the source when it is loaded: a
+// call to the debug break builtin is added on the breakpoint line, before the statement. A
+// handler reaching the call pauses until it is continued through the debug API, the locals
+// of the paused frames can be inspected meanwhile
+type debugger struct {
+	mu          sync.Mutex
+	breakpoints map[string][]int // the verified breakpoint lines, by source file
+	stops       map[int]*debugStop
+	nextId      int
+	stopNotify  chan struct{} // closed and replaced when a handler pauses
+}
+
+type debugStop struct {
+	stop   types.DebugStop
+	resume chan struct{}
+}
+
+func newDebugger() *debugger {
+	return &debugger{
+		breakpoints: map[string][]int{},
+		stops:       map[int]*debugStop{},
+		stopNotify:  make(chan struct{}),
+	}
+}
+
+// simpleStmtColumns records the column of the first simple statement starting on each line. The
+// break call can be added, semicolon separated, only before a simple statement
+func simpleStmtColumns(stmts []syntax.Stmt, cols map[int]int) {
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *syntax.DefStmt:
+			simpleStmtColumns(s.Body, cols)
+		case *syntax.IfStmt:
+			simpleStmtColumns(s.True, cols)
+			simpleStmtColumns(s.False, cols)
+		case *syntax.ForStmt:
+			simpleStmtColumns(s.Body, cols)
+		case *syntax.WhileStmt:
+			simpleStmtColumns(s.Body, cols)
+		default:
+			start, _ := stmt.Span()
+			line, col := int(start.Line), int(start.Col)
+			if current, ok := cols[line]; !ok || col < current {
+				cols[line] = col
+			}
+		}
+	}
+}
+
+// instrumentBreakpoints adds the break call on each of the breakpoint lines. The call is added
+// on the same line so that the line numbers in errors and backtraces are unchanged. A breakpoint
+// on a line which does not start a simple statement, like a def or an if, is moved to the next
+// line which does. Returns the updated source and the verified breakpoint lines
+func instrumentBreakpoints(fileName string, src []byte, breakLines []int) ([]byte, []int, error) {
+	file, err := AppFileOptions().Parse(fileName, src, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	cols := map[int]int{}
+	simpleStmtColumns(file.Stmts, cols)
+	stmtLines := slices.Sorted(maps.Keys(cols))
+
+	verified := []int{}
+	for _, line := range breakLines {
+		index, _ := slices.BinarySearch(stmtLines, line)
+		if index == len(stmtLines) {
+			return nil, nil, fmt.Errorf("no statement found at or after line %d in %s", line, fileName)
+		}
+		if !slices.Contains(verified, stmtLines[index]) {
+			verified = append(verified, stmtLines[index])
+		}
+	}
+	slices.Sort(verified)
+
+	lines := bytes.Split(src, []byte("\n"))
+	for _, line := range verified {
+		// The column is in runes, convert to a byte offset
+		text := lines[line-1]
+		offset := len(string([]rune(string(text))[:cols[line]-1]))
+		updated := make([]byte, 0, len(text)+len(debugBreakBuiltin)+4)
+		updated = append(updated, text[:offset]...)
+		updated = append(updated, debugBreakBuiltin+"(); "...)
+		lines[line-1] = append(updated, text[offset:]...)
+	}
+	return bytes.Join(lines, []byte("\n")), verified, nil
+}
+
+// setBreakpoints validates and sets the breakpoints. The breakpoints replace the ones set earlier,
+// the app has to be reloaded for the change to apply
+func (d *debugger) setBreakpoints(sourceFS fileReader, breakpoints []types.DebugBreakpoint) ([]types.DebugBreakpoint, error) {
+	fileLines := map[string][]int{}
+	for _, bp := range breakpoints {
+		if !strings.HasSuffix(bp.File, apptype.STARLARK_FILE_SUFFIX) {
+			return nil, fmt.Errorf("breakpoint file %s is not a starlark file", bp.File)
+		}
+		if bp.Line <= 0 {
+			return nil, fmt.Errorf("invalid breakpoint line %d in %s", bp.Line, bp.File)
+		}
+		fileLines[bp.File] = append(fileLines[bp.File], bp.Line)
+	}
+
+	verified := map[string][]int{}
+	for file, lines := range fileLines {
+		src, err := sourceFS.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("error reading %s: %w", file, err)
+		}
+		if _, verified[file], err = instrumentBreakpoints(file, src, lines); err != nil {
+			return nil, err
+		}
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints = verified
+	return d.breakpointList(), nil
+}
+
+// fileReader is the source file access needed by the debugger
+type fileReader interface {
+	ReadFile(name string) ([]byte, error)
+}
+
+func (d *debugger) breakpointList() []types.DebugBreakpoint {
+	ret := []types.DebugBreakpoint{}
+	for _, file := range slices.Sorted(maps.Keys(d.breakpoints)) {
+		for _, line := range d.breakpoints[file] {
+			ret = append(ret, types.DebugBreakpoint{File: file, Line: line})
+		}
+	}
+	return ret
+}
+
+// instrument returns the source with the break calls added, if there are breakpoints in the file
+func (d *debugger) instrument(fileName string, src []byte) ([]byte, error) {
+	d.mu.Lock()
+	lines := d.breakpoints[fileName]
+	d.mu.Unlock()
+	if len(lines) == 0 {
+		return src, nil
+	}
+	updated, _, err := instrumentBreakpoints(fileName, src, lines)
+	return updated, err
+}
+
+// breakBuiltin is the builtin called on the breakpoint lines. The handler thread is paused until
+// it is continued, the request is cancelled or the pause times out. The call is a no-op when the
+// file is loaded, breakpoints apply to handler invocations only
+func (d *debugger) breakBuiltin(thread *starlark.Thread, _ *starlark.Builtin, _ starlark.Tuple, _ []starlark.Tuple) (starlark.Value, error) {
+	ctx, ok := thread.Local(types.TL_CONTEXT).(context.Context)
+	if !ok {
+		return starlark.None, nil
+	}
+
+	stop := d.addStop(thread)
+	defer d.removeStop(stop.stop.Id)
+	select {
+	case <-stop.resume:
+	case <-ctx.Done():
+	case <-time.After(debugPauseTimeout):
+	}
+	return starlark.None, nil
+}
+
+func (d *debugger) addStop(thread *starlark.Thread) *debugStop {
+	frames := []types.DebugFrame{}
+	// Frame 0 is the break builtin, skip that
+	for depth := 1; depth < thread.CallStackDepth(); depth++ {
+		frame := thread.DebugFrame(depth)
+		pos := frame.Position()
+		debugFrame := types.DebugFrame{
+			Function: frame.Callable().Name(),
+			File:     pos.Filename(),
+			Line:     int(pos.Line),
+			Locals:   []types.DebugVariable{},
+		}
+		for i := range frame.NumLocals() {
+			binding, value := frame.Local(i)
+			if value == nil {
+				continue // not yet assigned
+			}
+			debugFrame.Locals = append(debugFrame.Locals, types.DebugVariable{
+				Name:  binding.Name,
+				Type:  value.Type(),
+				Value: debugValueString(value),
+			})
+		}
+		frames = append(frames, debugFrame)
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.nextId++
+	stop := &debugStop{
+		stop: types.DebugStop{
+			Id:       d.nextId,
+			Frames:   frames,
+			PausedAt: time.Now(),
+		},
+		resume: make(chan struct{}),
+	}
+	if len(frames) > 0 {
+		stop.stop.File = frames[0].File
+		stop.stop.Line = frames[0].Line
+	}
+	d.stops[stop.stop.Id] = stop
+	close(d.stopNotify)
+	d.stopNotify = make(chan struct{})
+	return stop
+}
+
+func debugValueString(value starlark.Value) string {
+	str := value.String()
+	if len(str) > debugMaxValueLen {
+		str = str[:debugMaxValueLen] + "..."
+	}
+	return str
+}
+
+func (d *debugger) removeStop(id int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.stops, id)
+}
+
+// resume continues the paused handler with the given id, all the paused handlers if id is zero.
+// Returns the number of handlers resumed
+func (d *debugger) resume(id int) int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	count := 0
+	for stopId, stop := range d.stops {
+		if id == 0 || stopId == id {
+			close(stop.resume)
+			delete(d.stops, stopId)
+			count++
+		}
+	}
+	return count
+}
+
+// state returns the breakpoints and the paused handlers. If wait is set and no handler is
+// paused, this waits for a handler to pause, until the timeout
+func (d *debugger) state(ctx context.Context, wait bool, timeout time.Duration) types.DebugState {
+	d.mu.Lock()
+	if wait && len(d.stops) == 0 {
+		notify := d.stopNotify
+		d.mu.Unlock()
+		select {
+		case <-notify:
+		case <-ctx.Done():
+		case <-time.After(timeout):
+		}
+		d.mu.Lock()
+	}
+	defer d.mu.Unlock()
+
+	ret := types.DebugState{
+		Breakpoints: d.breakpointList(),
+		Stops:       make([]types.DebugStop, 0, len(d.stops)),
+	}
+	for _, id := range slices.Sorted(maps.Keys(d.stops)) {
+		ret.Stops = append(ret.Stops, d.stops[id].stop)
+	}
+	return ret
+}
+
+// createDebugRoutes adds the debug API, for dev apps only
+func (a *App) createDebugRoutes(router *chi.Mux) {
+	router.Get(types.APP_INTERNAL_URL_PREFIX+"/debug", a.debugStateHandler)
+	router.Post(types.APP_INTERNAL_URL_PREFIX+"/debug/breakpoints", a.debugBreakpointsHandler)
+	router.Post(types.APP_INTERNAL_URL_PREFIX+"/debug/continue", a.debugContinueHandler)
+}
+
+func writeDebugResponse(w http.ResponseWriter, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value) //nolint:errcheck
+}
+
+func (a *App) debugStateHandler(w http.ResponseWriter, r *http.Request) {
+	wait := r.URL.Query().Get("wait") == "true"
+	writeDebugResponse(w, a.debugger.state(r.Context(), wait, debugWaitTimeout))
+}
+
+func (a *App) debugBreakpointsHandler(w http.ResponseWriter, r *http.Request) {
+	var breakpoints []types.DebugBreakpoint
+	if err := json.NewDecoder(r.Body).Decode(&breakpoints); err != nil {
+		http.Error(w, "invalid breakpoints: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	for i := range breakpoints {
+		breakpoints[i].File = a.getStarPath(breakpoints[i].File)
+	}
+	verified, err := a.debugger.setBreakpoints(a.sourceFS, breakpoints)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Reload the app for the instrumented source to be used. The reload is done in the background
+	// since this request is being served by the current router
+	go func() {
+		_, err := a.Reload(context.Background(), true, true, types.DryRun(false), ReloadOptions{ReloadContainer: true, Verify: false})
+		a.reloadError.Store(&err)
+		if err != nil {
+			a.Error().Err(err).Msg("Error reloading app after breakpoint update")
+		}
+	}()
+	a.Info().Int("count", len(verified)).Msg("Updated debug breakpoints")
+	writeDebugResponse(w, verified)
+}
+
+func (a *App) debugContinueHandler(w http.ResponseWriter, r *http.Request) {
+	id := 0
+	if idStr := r.URL.Query().Get("id"); idStr != "" {
+		var err error
+		if id, err = strconv.Atoi(idStr); err != nil {
+			http.Error(w, "invalid id: "+idStr, http.StatusBadRequest)
+			return
+		}
+	}
+	count := a.debugger.resume(id)
+	if id != 0 && count == 0 {
+		http.Error(w, fmt.Sprintf("no paused handler with id %d", id), http.StatusNotFound)
+		return
+	}
+	writeDebugResponse(w, map[string]int{"resumed": count})
+}
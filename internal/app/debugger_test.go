@@ -0,0 +1,99 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+)
+
+const debugTestSource = `
+x = 1
+
+def handler(req):
+	name = req + "_" + "x"
+	if name:
+		count = len(name)
+	for i in range(2): total = i
+	return {"name": name, "count": count}
+`
+
+func TestInstrumentBreakpoints(t *testing.T) {
+	src, lines, err := instrumentBreakpoints("app.star", []byte(debugTestSource), []int{2, 4, 6, 8, 5})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "lines", "[2 5 7 8]", fmt.Sprint(lines))
+	testutil.AssertStringContains(t, string(src), "\n__debug_break__(); x = 1\n")
+	testutil.AssertStringContains(t, string(src), "\n\t__debug_break__(); name = req")
+	testutil.AssertStringContains(t, string(src), "\n\t\t__debug_break__(); count = len(name)\n")
+	testutil.AssertStringContains(t, string(src), "\n\tfor i in range(2): __debug_break__(); total = i\n")
+
+	_, _, err = instrumentBreakpoints("app.star", []byte(debugTestSource), []int{20})
+	testutil.AssertErrorContains(t, err, "no statement found at or after line 20 in app.star")
+	_, _, err = instrumentBreakpoints("app.star", []byte("def x("), []int{1})
+	testutil.AssertErrorContains(t, err, "app.star:1")
+}
+
+type testFileReader map[string]string
+
+func (t testFileReader) ReadFile(name string) ([]byte, error) {
+	if data, ok := t[name]; ok {
+		return []byte(data), nil
+	}
+	return nil, fmt.Errorf("file %s not found", name)
+}
+
+func TestDebuggerBreakpoints(t *testing.T) {
+	d := newDebugger()
+	files := testFileReader{"app.star": debugTestSource}
+	_, err := d.setBreakpoints(files, []types.DebugBreakpoint{{File: "app.py", Line: 1}})
+	testutil.AssertErrorContains(t, err, "breakpoint file app.py is not a starlark file")
+	_, err = d.setBreakpoints(files, []types.DebugBreakpoint{{File: "other.star", Line: 1}})
+	testutil.AssertErrorContains(t, err, "file other.star not found")
+
+	verified, err := d.setBreakpoints(files, []types.DebugBreakpoint{{File: "app.star", Line: 6}})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "verified", "[{app.star 7}]", fmt.Sprint(verified))
+
+	src, err := d.instrument("app.star", []byte(debugTestSource))
+	testutil.AssertNoError(t, err)
+	builtin := starlark.StringDict{debugBreakBuiltin: starlark.NewBuiltin(debugBreakBuiltin, d.breakBuiltin)}
+	globals, err := starlark.ExecFileOptions(AppFileOptions(), &starlark.Thread{}, "app.star", src, builtin)
+	testutil.AssertNoError(t, err) // no pause when loading
+
+	thread := &starlark.Thread{}
+	thread.SetLocal(types.TL_CONTEXT, context.Background())
+	done := make(chan starlark.Value)
+	go func() {
+		ret, err := starlark.Call(thread, globals["handler"], starlark.Tuple{starlark.String("abc")}, nil)
+		testutil.AssertNoError(t, err)
+		done <- ret
+	}()
+
+	state := d.state(context.Background(), true, 5*time.Second)
+	testutil.AssertEqualsInt(t, "stops", 1, len(state.Stops))
+	stop := state.Stops[0]
+	testutil.AssertEqualsInt(t, "line", 7, stop.Line)
+	testutil.AssertEqualsString(t, "file", "app.star", stop.File)
+	testutil.AssertEqualsString(t, "function", "handler", stop.Frames[0].Function)
+	testutil.AssertEqualsString(t, "locals", `[{req string "abc"} {name string "abc_x"}]`, fmt.Sprint(stop.Frames[0].Locals))
+
+	testutil.AssertEqualsInt(t, "resume unknown", 0, d.resume(stop.Id+1))
+	testutil.AssertEqualsInt(t, "resume", 1, d.resume(stop.Id))
+	select {
+	case ret := <-done:
+		testutil.AssertEqualsString(t, "ret", `{"name": "abc_x", "count": 5}`, ret.String())
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler not resumed")
+	}
+
+	state = d.state(context.Background(), true, 10*time.Millisecond)
+	testutil.AssertEqualsInt(t, "no stops", 0, len(state.Stops))
+	testutil.AssertEqualsInt(t, "breakpoints", 1, len(state.Breakpoints))
+}
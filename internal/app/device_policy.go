@@ -0,0 +1,111 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/openrundev/openrun/internal/container"
+	"github.com/openrundev/openrun/internal/rbac"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// checkDevicePolicy validates the gpus, devices and shm_size container options against the
+// container_devices server config. GPUs and host devices are allowed only for the apps matching
+// allowed_apps, the device host paths have to match allowed_devices. Stage and preview apps are
+// allowed if the linked prod app is allowed
+func checkDevicePolicy(options map[string]string, appEntry *types.AppEntry, policy types.ContainerDevicesConfig) error {
+	gpus, hasGpus := optionValue(options, "gpus")
+	devices, hasDevices := optionValue(options, "devices")
+	if hasGpus || hasDevices {
+		allowed, err := deviceAppAllowed(appEntry, policy.AllowedApps)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			return fmt.Errorf("app %s is not allowed to use gpus or devices, add it to container_devices.allowed_apps", appEntry.AppPathDomain())
+		}
+	}
+
+	if hasGpus {
+		request, err := container.ParseGpus(gpus)
+		if err != nil {
+			return err
+		}
+		if policy.MaxGpus > 0 {
+			if request.All {
+				return fmt.Errorf("gpus value all is not allowed since container_devices.max_gpus is set, use a count")
+			}
+			if request.NumGpus() > policy.MaxGpus {
+				return fmt.Errorf("gpus value %s is above container_devices.max_gpus of %d", gpus, policy.MaxGpus)
+			}
+		}
+	}
+
+	if hasDevices {
+		mappings, err := container.ParseDevices(devices)
+		if err != nil {
+			return err
+		}
+		for _, mapping := range mappings {
+			allowed := false
+			for _, pattern := range policy.AllowedDevices {
+				if allowed, err = doublestar.Match(pattern, mapping.HostPath); err != nil {
+					return fmt.Errorf("invalid container_devices.allowed_devices pattern %q: %w", pattern, err)
+				} else if allowed {
+					break
+				}
+			}
+			if !allowed {
+				return fmt.Errorf("device %s is not allowed, add it to container_devices.allowed_devices", mapping.HostPath)
+			}
+		}
+	}
+
+	if shmSize, ok := optionValue(options, "shm_size"); ok && policy.MaxShmSize != "" {
+		size, err := parseShmBytes(shmSize)
+		if err != nil {
+			return err
+		}
+		maxSize, err := parseShmBytes(policy.MaxShmSize)
+		if err != nil {
+			return fmt.Errorf("invalid container_devices.max_shm_size: %w", err)
+		}
+		if size > maxSize {
+			return fmt.Errorf("shm_size %s is above container_devices.max_shm_size of %s", shmSize, policy.MaxShmSize)
+		}
+	}
+	return nil
+}
+
+// deviceAppAllowed checks whether the app, or the prod app it is linked to, matches one of the globs
+func deviceAppAllowed(appEntry *types.AppEntry, allowedApps []string) (bool, error) {
+	apps := []types.AppPathDomain{appEntry.AppPathDomain()}
+	if appEntry.MainApp != "" {
+		// Stage and preview apps use the prod app path
+		apps = append(apps, types.AppPathDomain{Path: appEntry.LinkedAppPath, Domain: appEntry.Domain})
+	}
+	for _, glob := range allowedApps {
+		for _, app := range apps {
+			matched, err := rbac.MatchGlob(glob, app)
+			if err != nil {
+				return false, fmt.Errorf("invalid container_devices.allowed_apps entry %q: %w", glob, err)
+			}
+			if matched {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+func parseShmBytes(value string) (int64, error) {
+	bytes, err := container.BytesString(value)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing shm_size value %q: %w", value, err)
+	}
+	return strconv.ParseInt(bytes, 10, 64)
+}
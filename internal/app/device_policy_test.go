@@ -0,0 +1,44 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestCheckDevicePolicy(t *testing.T) {
+	prodApp := &types.AppEntry{Path: "/llm/chat"}
+	stageApp := &types.AppEntry{Path: "/llm/chat_cl_stage", MainApp: "app_prd_1", LinkedAppPath: "/llm/chat"}
+	otherApp := &types.AppEntry{Path: "/other"}
+	policy := types.ContainerDevicesConfig{
+		AllowedApps:    []string{"/llm/**"},
+		AllowedDevices: []string{"/dev/dri/*"},
+		MaxGpus:        2,
+		MaxShmSize:     "1g",
+	}
+
+	testutil.AssertNoError(t, checkDevicePolicy(map[string]string{"gpus": "2", "devices": "/dev/dri/renderD128"}, prodApp, policy))
+	testutil.AssertNoError(t, checkDevicePolicy(map[string]string{"docker.gpus": "device=0"}, stageApp, policy))
+	// shm_size does not need the app to be allowed
+	testutil.AssertNoError(t, checkDevicePolicy(map[string]string{"shm_size": "512m"}, otherApp, policy))
+
+	err := checkDevicePolicy(map[string]string{"gpus": "1"}, otherApp, policy)
+	testutil.AssertErrorContains(t, err, "app /other is not allowed to use gpus or devices")
+	err = checkDevicePolicy(map[string]string{"gpus": "3"}, prodApp, policy)
+	testutil.AssertErrorContains(t, err, "above container_devices.max_gpus of 2")
+	err = checkDevicePolicy(map[string]string{"gpus": "all"}, prodApp, policy)
+	testutil.AssertErrorContains(t, err, "gpus value all is not allowed")
+	err = checkDevicePolicy(map[string]string{"devices": "/dev/fuse"}, prodApp, policy)
+	testutil.AssertErrorContains(t, err, "device /dev/fuse is not allowed")
+	err = checkDevicePolicy(map[string]string{"shm_size": "2g"}, otherApp, policy)
+	testutil.AssertErrorContains(t, err, "above container_devices.max_shm_size of 1g")
+
+	// With no policy, GPUs and devices are not allowed for any app
+	err = checkDevicePolicy(map[string]string{"gpus": "all"}, prodApp, types.ContainerDevicesConfig{})
+	testutil.AssertErrorContains(t, err, "not allowed to use gpus or devices")
+	testutil.AssertNoError(t, checkDevicePolicy(map[string]string{"cpus": "1"}, prodApp, types.ContainerDevicesConfig{}))
+}
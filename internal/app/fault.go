@@ -0,0 +1,127 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+)
+
+// faultInjector adds latency and errors to plugin calls and proxied upstream calls, so that
+// the error handling of an app can be tested. It is created only for stage apps with a
+// non zero rate configured
+type faultInjector struct {
+	config types.FaultConfig
+	random func() float64
+}
+
+// newFaultInjector returns the fault injector for the app, nil if faults are not enabled
+func newFaultInjector(appId types.AppId, config types.FaultConfig) *faultInjector {
+	if !strings.HasPrefix(string(appId), types.ID_PREFIX_APP_STAGE) {
+		return nil
+	}
+	if config.ErrorRate <= 0 && (config.LatencyRate <= 0 || config.LatencyMs <= 0) {
+		return nil
+	}
+	return &faultInjector{config: config, random: rand.Float64}
+}
+
+// inject sleeps if latency is to be added and returns true if the call is to fail
+func (f *faultInjector) inject(ctx context.Context) (bool, error) {
+	if f.config.LatencyMs > 0 && f.random() < f.config.LatencyRate {
+		timer := time.NewTimer(time.Duration(f.config.LatencyMs) * time.Millisecond)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return false, ctx.Err()
+		}
+	}
+	return f.random() < f.config.ErrorRate, nil
+}
+
+func (f *faultInjector) matchesPlugin(modulePath, functionName string) bool {
+	name := modulePath + "." + functionName
+	for _, pattern := range f.config.Plugins {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// wrapPlugin returns the plugin function with faults injected. An injected error is returned
+// as a plugin error, so it is handled by the app like an actual plugin failure
+func (f *faultInjector) wrapPlugin(modulePath, functionName string, fn StarlarkFunction) StarlarkFunction {
+	if f == nil || !f.matchesPlugin(modulePath, functionName) {
+		return fn
+	}
+	return func(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		ctx := GetContext(thread)
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		fail, err := f.inject(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if fail {
+			return nil, fmt.Errorf("fault injected error for %s.%s", modulePath, functionName)
+		}
+		return fn(thread, builtin, args, kwargs)
+	}
+}
+
+// faultTransport injects faults into proxied upstream calls
+type faultTransport struct {
+	injector *faultInjector
+	next     http.RoundTripper
+}
+
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fail, err := t.injector.inject(req.Context())
+	if err != nil {
+		return nil, err
+	}
+	if !fail {
+		return t.next.RoundTrip(req)
+	}
+
+	if req.Body != nil {
+		req.Body.Close() //nolint:errcheck
+	}
+	status := t.injector.config.ErrorStatus
+	if status == 0 {
+		return nil, fmt.Errorf("fault injected error for upstream %s", req.URL.Host)
+	}
+	body := "fault injected error\n"
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"text/plain; charset=utf-8"}},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// wrapTransport returns the proxy transport with faults injected, if enabled for proxy calls
+func (f *faultInjector) wrapTransport(next http.RoundTripper) http.RoundTripper {
+	if f == nil || !f.config.Proxy {
+		return next
+	}
+	return &faultTransport{injector: f, next: next}
+}
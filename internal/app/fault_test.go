@@ -0,0 +1,93 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+package app
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+)
+
+func TestFaultInjectorStageOnly(t *testing.T) {
+	config := types.FaultConfig{ErrorRate: 0.5, Plugins: []string{"*"}}
+	testutil.AssertEqualsBool(t, "prod", true, newFaultInjector("app_prd_1", config) == nil)
+	testutil.AssertEqualsBool(t, "dev", true, newFaultInjector("app_dev_1", config) == nil)
+	testutil.AssertEqualsBool(t, "stage", true, newFaultInjector("app_stg_1", config) != nil)
+	testutil.AssertEqualsBool(t, "no rates", true, newFaultInjector("app_stg_1", types.FaultConfig{LatencyRate: 1}) == nil)
+}
+
+func TestFaultInjectorPlugin(t *testing.T) {
+	calls := 0
+	fn := func(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		calls++
+		return starlark.None, nil
+	}
+
+	f := newFaultInjector("app_stg_1", types.FaultConfig{ErrorRate: 1, Plugins: []string{"http.in.*"}})
+	thread := &starlark.Thread{}
+
+	_, err := f.wrapPlugin("http.in", "get", fn)(thread, nil, nil, nil)
+	testutil.AssertErrorContains(t, err, "fault injected error for http.in.get")
+	testutil.AssertEqualsInt(t, "not called", 0, calls)
+
+	// Plugins not matching the patterns are not affected
+	_, err = f.wrapPlugin("store.in", "select", fn)(thread, nil, nil, nil)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "called", 1, calls)
+
+	// A nil injector returns the function as is
+	var none *faultInjector
+	_, err = none.wrapPlugin("http.in", "get", fn)(thread, nil, nil, nil)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "nil injector", 2, calls)
+}
+
+func TestFaultInjectorLatency(t *testing.T) {
+	f := newFaultInjector("app_stg_1", types.FaultConfig{LatencyMs: 50, LatencyRate: 1})
+	start := time.Now()
+	fail, err := f.inject(context.Background())
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsBool(t, "no error", false, fail)
+	testutil.AssertEqualsBool(t, "delayed", true, time.Since(start) >= 50*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = f.inject(ctx)
+	testutil.AssertErrorContains(t, err, "context canceled")
+}
+
+func TestFaultInjectorTransport(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+
+	f := newFaultInjector("app_stg_1", types.FaultConfig{ErrorRate: 1, ErrorStatus: http.StatusServiceUnavailable, Proxy: true})
+	client := &http.Client{Transport: f.wrapTransport(http.DefaultTransport)}
+	resp, err := client.Get(upstream.URL)
+	testutil.AssertNoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close() //nolint:errcheck
+	testutil.AssertEqualsInt(t, "status", http.StatusServiceUnavailable, resp.StatusCode)
+	testutil.AssertEqualsString(t, "body", "fault injected error\n", string(body))
+
+	// Rate decides per call
+	f.random = func() float64 { return 0.99 }
+	f.config.ErrorRate = 0.5
+	resp, err = client.Get(upstream.URL)
+	testutil.AssertNoError(t, err)
+	resp.Body.Close() //nolint:errcheck
+	testutil.AssertEqualsInt(t, "passed through", http.StatusOK, resp.StatusCode)
+
+	// Proxy faults can be disabled
+	f.config.Proxy = false
+	_, ok := f.wrapTransport(http.DefaultTransport).(*faultTransport)
+	testutil.AssertEqualsBool(t, "not wrapped", false, ok)
+}
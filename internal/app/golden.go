@@ -0,0 +1,109 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/app/starlark_type"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// htmlRoute is an HTML page or fragment route declared in the app definition
+type htmlRoute struct {
+	Path     string
+	Method   string
+	Template string
+	Partial  string
+	Fragment bool
+}
+
+// HTMLRoutes returns the declared HTML page and fragment routes, as "METHOD path" strings
+func (a *App) HTMLRoutes() []string {
+	a.initMutex.Lock()
+	defer a.initMutex.Unlock()
+	ret := make([]string, 0, len(a.htmlRoutes))
+	for _, route := range a.htmlRoutes {
+		ret = append(ret, route.Method+" "+route.Path)
+	}
+	return ret
+}
+
+// RenderRoute renders the template of a declared HTML route with the fixture data used as the
+// handler response, the handler is not called. Fragment routes render their partial block. For
+// page routes, the partial block is rendered if the fixture sets partial, like for an HTMX request.
+// The app url is set to the app path, so that the output does not depend on the server address
+func (a *App) RenderRoute(fixture *types.GoldenFixture) (string, error) {
+	method := strings.ToUpper(cmp.Or(fixture.Method, http.MethodGet))
+	a.initMutex.Lock()
+	var route *htmlRoute
+	for i := range a.htmlRoutes {
+		if a.htmlRoutes[i].Path == fixture.Path && a.htmlRoutes[i].Method == method {
+			r := a.htmlRoutes[i]
+			route = &r
+			break
+		}
+	}
+	a.initMutex.Unlock()
+	if route == nil {
+		return "", fmt.Errorf("no HTML route declared for %s %s", method, fixture.Path)
+	}
+
+	partial := ""
+	isPartial := route.Fragment || fixture.Partial
+	if isPartial {
+		if route.Partial == "" {
+			return "", fmt.Errorf("route %s %s has no partial block", method, fixture.Path)
+		}
+		partial = route.Partial
+	}
+
+	appPath := a.Path
+	if appPath == "/" {
+		appPath = ""
+	}
+	pagePath := route.Path
+	for key, value := range fixture.UrlParams {
+		pagePath = strings.ReplaceAll(pagePath, "{"+key+"}", value)
+	}
+	if pagePath == "/" {
+		pagePath = ""
+	}
+	query := url.Values{}
+	for key, value := range fixture.Query {
+		query.Set(key, value)
+	}
+
+	requestData := starlark_type.Request{
+		AppName:     a.Name,
+		AppPath:     appPath,
+		AppUrl:      appPath,
+		PagePath:    pagePath,
+		PageUrl:     appPath + pagePath,
+		Method:      method,
+		IsDev:       a.IsDev,
+		IsPartial:   isPartial,
+		PushEvents:  a.codeConfig.Routing.PushEvents,
+		HtmxVersion: a.codeConfig.Htmx.Version,
+		Headers:     http.Header{},
+		UrlParams:   fixture.UrlParams,
+		Form:        query,
+		Query:       query,
+		PostForm:    url.Values{},
+		UserId:      fixture.UserId,
+		Env:         a.appEnv,
+		Data:        fixture.Data,
+	}
+
+	var buf bytes.Buffer
+	if err := a.executeTemplate(&buf, route.Template, partial, requestData); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
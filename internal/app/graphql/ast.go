@@ -0,0 +1,184 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TypeRef is a reference to a type, like [Book!]!. Elem is set for a list type
+type TypeRef struct {
+	Name    string
+	Elem    *TypeRef
+	NonNull bool
+}
+
+func (t *TypeRef) String() string {
+	ret := t.Name
+	if t.Elem != nil {
+		ret = "[" + t.Elem.String() + "]"
+	}
+	if t.NonNull {
+		ret += "!"
+	}
+	return ret
+}
+
+// namedType returns the name of the innermost type
+func (t *TypeRef) namedType() string {
+	if t.Elem != nil {
+		return t.Elem.namedType()
+	}
+	return t.Name
+}
+
+type ValueKind int
+
+const (
+	IntValue ValueKind = iota
+	FloatValue
+	StringValue
+	BooleanValue
+	NullValue
+	EnumValue
+	ListValue
+	ObjectValue
+	VariableValue
+)
+
+// Value is an input value literal in a document
+type Value struct {
+	Kind   ValueKind
+	Raw    string // the literal for scalars and enums, the name for variables
+	List   []*Value
+	Fields []*ObjectField
+	Loc    Location
+}
+
+// String returns the value in GraphQL syntax, as used for default values in introspection
+func (v *Value) String() string {
+	switch v.Kind {
+	case StringValue:
+		b, _ := json.Marshal(v.Raw)
+		return string(b)
+	case VariableValue:
+		return "$" + v.Raw
+	case ListValue:
+		items := make([]string, 0, len(v.List))
+		for _, item := range v.List {
+			items = append(items, item.String())
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case ObjectValue:
+		items := make([]string, 0, len(v.Fields))
+		for _, field := range v.Fields {
+			items = append(items, field.Name+": "+field.Value.String())
+		}
+		return "{" + strings.Join(items, ", ") + "}"
+	default:
+		return v.Raw
+	}
+}
+
+type ObjectField struct {
+	Name  string
+	Value *Value
+}
+
+type Argument struct {
+	Name  string
+	Value *Value
+	Loc   Location
+}
+
+type Directive struct {
+	Name string
+	Args []*Argument
+	Loc  Location
+}
+
+// Selection is a field, a fragment spread or an inline fragment
+type Selection interface {
+	selectionDirectives() []*Directive
+}
+
+type Field struct {
+	Alias        string
+	Name         string
+	Args         []*Argument
+	Directives   []*Directive
+	SelectionSet []Selection
+	Loc          Location
+}
+
+// ResponseKey is the key for the field in the result, the alias if set
+func (f *Field) ResponseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+type FragmentSpread struct {
+	Name       string
+	Directives []*Directive
+	Loc        Location
+}
+
+type InlineFragment struct {
+	TypeCondition string // empty if there is no type condition
+	Directives    []*Directive
+	SelectionSet  []Selection
+	Loc           Location
+}
+
+func (f *Field) selectionDirectives() []*Directive          { return f.Directives }
+func (f *FragmentSpread) selectionDirectives() []*Directive { return f.Directives }
+func (f *InlineFragment) selectionDirectives() []*Directive { return f.Directives }
+
+type VariableDef struct {
+	Name    string
+	Type    *TypeRef
+	Default *Value
+	Loc     Location
+}
+
+type Operation struct {
+	Type         string // query, mutation or subscription
+	Name         string
+	Variables    []*VariableDef
+	Directives   []*Directive
+	SelectionSet []Selection
+	Loc          Location
+}
+
+type FragmentDef struct {
+	Name          string
+	TypeCondition string
+	Directives    []*Directive
+	SelectionSet  []Selection
+	Loc           Location
+}
+
+// QueryDocument is a parsed executable document, with operations and fragments
+type QueryDocument struct {
+	Operations []*Operation
+	Fragments  map[string]*FragmentDef
+}
+
+// Error is a GraphQL error, as returned in the errors list of the response
+type Error struct {
+	Message   string     `json:"message"`
+	Locations []Location `json:"locations,omitempty"`
+	Path      []any      `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if len(e.Locations) > 0 {
+		return fmt.Sprintf("%s (line %d, column %d)", e.Message, e.Locations[0].Line, e.Locations[0].Column)
+	}
+	return e.Message
+}
@@ -0,0 +1,304 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package graphql
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+)
+
+// coerceVariables validates the variable values from the request against the variable types.
+// Variables which are not provided and have no default are not added
+func (c *execContext) coerceVariables(defs []*VariableDef, input map[string]any) (map[string]any, error) {
+	ret := map[string]any{}
+	for _, def := range defs {
+		name := "variable $" + def.Name
+		value, ok := input[def.Name]
+		var err error
+		switch {
+		case ok:
+			value, err = c.coerceInput(value, def.Type, name)
+		case def.Default != nil:
+			value, err = c.coerceLiteral(def.Default, def.Type, name)
+		case def.Type.NonNull:
+			err = fmt.Errorf("%s of required type %s was not provided", name, def.Type)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, &Error{Message: err.Error(), Locations: []Location{def.Loc}}
+		}
+		ret[def.Name] = value
+	}
+	return ret, nil
+}
+
+// coerceArgs returns the argument values for a field, with the defaults applied
+func (c *execContext) coerceArgs(defs []*InputValue, args []*Argument) (map[string]any, error) {
+	ret := make(map[string]any, len(defs))
+	for _, def := range defs {
+		name := "argument " + def.Name
+		idx := slices.IndexFunc(args, func(a *Argument) bool { return a.Name == def.Name })
+		if idx >= 0 {
+			value := args[idx].Value
+			if _, provided := c.vars[value.Raw]; value.Kind != VariableValue || provided {
+				v, err := c.coerceLiteral(value, def.Type, name)
+				if err != nil {
+					return nil, err
+				}
+				ret[def.Name] = v
+				continue
+			}
+		}
+
+		if def.Default != nil {
+			v, err := c.coerceLiteral(def.Default, def.Type, name)
+			if err != nil {
+				return nil, err
+			}
+			ret[def.Name] = v
+		} else if def.Type.NonNull {
+			return nil, fmt.Errorf("%s of required type %s was not provided", name, def.Type)
+		}
+	}
+	return ret, nil
+}
+
+// coerceLiteral converts a literal value from the document to the input type
+func (c *execContext) coerceLiteral(value *Value, ref *TypeRef, name string) (any, error) {
+	if value.Kind == VariableValue {
+		// The variable value is already coerced to the variable type
+		v, ok := c.vars[value.Raw]
+		if (!ok || v == nil) && ref.NonNull {
+			return nil, fmt.Errorf("%s: variable $%s of non-null type %s was not provided", name, value.Raw, ref)
+		}
+		return v, nil
+	}
+	if value.Kind == NullValue {
+		if ref.NonNull {
+			return nil, fmt.Errorf("%s: expected non-null value of type %s", name, ref)
+		}
+		return nil, nil
+	}
+
+	if ref.Elem != nil {
+		if value.Kind != ListValue {
+			item, err := c.coerceLiteral(value, ref.Elem, name)
+			if err != nil {
+				return nil, err
+			}
+			return []any{item}, nil
+		}
+		ret := make([]any, 0, len(value.List))
+		for i, item := range value.List {
+			v, err := c.coerceLiteral(item, ref.Elem, fmt.Sprintf("%s[%d]", name, i))
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, v)
+		}
+		return ret, nil
+	}
+
+	typeDef := c.Schema.Types[ref.Name]
+	switch typeDef.Kind {
+	case KindScalar:
+		switch typeDef.Name {
+		case "Int":
+			if value.Kind == IntValue {
+				if n, err := strconv.ParseInt(value.Raw, 10, 32); err == nil {
+					return n, nil
+				}
+			}
+		case "Float":
+			if value.Kind == IntValue || value.Kind == FloatValue {
+				if f, err := strconv.ParseFloat(value.Raw, 64); err == nil {
+					return f, nil
+				}
+			}
+		case "String":
+			if value.Kind == StringValue {
+				return value.Raw, nil
+			}
+		case "Boolean":
+			if value.Kind == BooleanValue {
+				return value.Raw == "true", nil
+			}
+		case "ID":
+			if value.Kind == StringValue || value.Kind == IntValue {
+				return value.Raw, nil
+			}
+		default:
+			return c.literalValue(value), nil
+		}
+	case KindEnum:
+		if value.Kind == EnumValue && typeDef.hasEnumValue(value.Raw) {
+			return value.Raw, nil
+		}
+	case KindInputObject:
+		if value.Kind == ObjectValue {
+			fields := make(map[string]*Value, len(value.Fields))
+			for _, field := range value.Fields {
+				fields[field.Name] = field.Value
+			}
+			return c.coerceInputObject(typeDef, name, func(fieldName string) (*Value, any, bool) {
+				v, ok := fields[fieldName]
+				return v, nil, ok
+			}, len(fields))
+		}
+	}
+	return nil, fmt.Errorf("%s: expected a value of type %s, found %s", name, ref, value)
+}
+
+// coerceInput converts a JSON value from the request variables to the input type
+func (c *execContext) coerceInput(value any, ref *TypeRef, name string) (any, error) {
+	if value == nil {
+		if ref.NonNull {
+			return nil, fmt.Errorf("%s: expected non-null value of type %s", name, ref)
+		}
+		return nil, nil
+	}
+
+	if ref.Elem != nil {
+		items, ok := value.([]any)
+		if !ok {
+			item, err := c.coerceInput(value, ref.Elem, name)
+			if err != nil {
+				return nil, err
+			}
+			return []any{item}, nil
+		}
+		ret := make([]any, 0, len(items))
+		for i, item := range items {
+			v, err := c.coerceInput(item, ref.Elem, fmt.Sprintf("%s[%d]", name, i))
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, v)
+		}
+		return ret, nil
+	}
+
+	typeDef := c.Schema.Types[ref.Name]
+	switch typeDef.Kind {
+	case KindScalar:
+		switch typeDef.Name {
+		case "Int":
+			if n, ok := toInt(value); ok && n >= math.MinInt32 && n <= math.MaxInt32 {
+				return n, nil
+			}
+		case "Float":
+			if f, ok := toFloat(value); ok {
+				return f, nil
+			}
+		case "String":
+			if s, ok := value.(string); ok {
+				return s, nil
+			}
+		case "Boolean":
+			if b, ok := value.(bool); ok {
+				return b, nil
+			}
+		case "ID":
+			if s, ok := value.(string); ok {
+				return s, nil
+			}
+			if n, ok := toInt(value); ok {
+				return strconv.FormatInt(n, 10), nil
+			}
+		default:
+			return value, nil
+		}
+	case KindEnum:
+		if s, ok := value.(string); ok && typeDef.hasEnumValue(s) {
+			return s, nil
+		}
+	case KindInputObject:
+		if m, ok := value.(map[string]any); ok {
+			return c.coerceInputObject(typeDef, name, func(fieldName string) (*Value, any, bool) {
+				v, ok := m[fieldName]
+				return nil, v, ok
+			}, len(m))
+		}
+	}
+	return nil, fmt.Errorf("%s: expected a value of type %s, found %v", name, ref, value)
+}
+
+// coerceInputObject builds an input object value. The lookup function returns either the
+// literal or the JSON value for a field
+func (c *execContext) coerceInputObject(typeDef *TypeDef, name string, lookup func(string) (*Value, any, bool), count int) (any, error) {
+	ret := make(map[string]any, len(typeDef.InputFields))
+	found := 0
+	for _, field := range typeDef.InputFields {
+		fieldName := name + "." + field.Name
+		literal, jsonValue, ok := lookup(field.Name)
+		var v any
+		var err error
+		switch {
+		case ok && literal != nil:
+			found++
+			if _, provided := c.vars[literal.Raw]; literal.Kind == VariableValue && !provided {
+				if field.Default != nil {
+					v, err = c.coerceLiteral(field.Default, field.Type, fieldName)
+					break
+				}
+			}
+			v, err = c.coerceLiteral(literal, field.Type, fieldName)
+		case ok:
+			found++
+			v, err = c.coerceInput(jsonValue, field.Type, fieldName)
+		case field.Default != nil:
+			v, err = c.coerceLiteral(field.Default, field.Type, fieldName)
+		case field.Type.NonNull:
+			err = fmt.Errorf("%s of required type %s was not provided", fieldName, field.Type)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		ret[field.Name] = v
+	}
+	if found != count {
+		return nil, fmt.Errorf("%s: unknown field for input type %s", name, typeDef.Name)
+	}
+	return ret, nil
+}
+
+// literalValue converts a literal to a plain value, used for custom scalars
+func (c *execContext) literalValue(value *Value) any {
+	switch value.Kind {
+	case IntValue:
+		if n, err := strconv.ParseInt(value.Raw, 10, 64); err == nil {
+			return n
+		}
+		f, _ := strconv.ParseFloat(value.Raw, 64)
+		return f
+	case FloatValue:
+		f, _ := strconv.ParseFloat(value.Raw, 64)
+		return f
+	case BooleanValue:
+		return value.Raw == "true"
+	case NullValue:
+		return nil
+	case VariableValue:
+		return c.vars[value.Raw]
+	case ListValue:
+		ret := make([]any, 0, len(value.List))
+		for _, item := range value.List {
+			ret = append(ret, c.literalValue(item))
+		}
+		return ret
+	case ObjectValue:
+		ret := make(map[string]any, len(value.Fields))
+		for _, field := range value.Fields {
+			ret[field.Name] = c.literalValue(field.Value)
+		}
+		return ret
+	default:
+		return value.Raw
+	}
+}
@@ -0,0 +1,673 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"slices"
+	"strconv"
+)
+
+// Request is a GraphQL request, as sent in the body of a POST request
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Response is the result of executing a request. Data is not included in the JSON if the
+// request failed before the execution started, like for a syntax or a validation error
+type Response struct {
+	Data     *OrderedMap
+	Errors   []*Error
+	executed bool
+}
+
+func (r *Response) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	if r.executed {
+		data, err := json.Marshal(r.Data)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteString(`"data":`)
+		buf.Write(data)
+	}
+	if len(r.Errors) > 0 {
+		errs, err := json.Marshal(r.Errors)
+		if err != nil {
+			return nil, err
+		}
+		if r.executed {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(`"errors":`)
+		buf.Write(errs)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// OrderedMap is a result object, which keeps the fields in the order of the query selections
+type OrderedMap struct {
+	Keys   []string
+	Values map[string]any
+}
+
+func newOrderedMap(size int) *OrderedMap {
+	return &OrderedMap{Keys: make([]string, 0, size), Values: make(map[string]any, size)}
+}
+
+func (m *OrderedMap) set(key string, value any) {
+	if _, ok := m.Values[key]; !ok {
+		m.Keys = append(m.Keys, key)
+	}
+	m.Values[key] = value
+}
+
+// Get returns the value for the key, nil if not present
+func (m *OrderedMap) Get(key string) any {
+	return m.Values[key]
+}
+
+func (m *OrderedMap) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, key := range m.Keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		keyJson, err := json.Marshal(key)
+		if err != nil {
+			return nil, err
+		}
+		valueJson, err := json.Marshal(m.Values[key])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(keyJson)
+		buf.WriteByte(':')
+		buf.Write(valueJson)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// Resolver returns the value for a field. parent is the value of the parent object, nil for the
+// root fields. args has the field arguments, with the defaults applied
+type Resolver func(parent any, args map[string]any) (any, error)
+
+// Executor executes requests against a schema. Fields without a resolver are looked up by name
+// in the parent value, which should be a map
+type Executor struct {
+	Schema        *Schema
+	Resolvers     map[string]Resolver // keyed by "Type.field"
+	Introspection bool                // whether __schema and __type queries are allowed
+	MaxDepth      int                 // the maximum selection depth, zero for no limit
+}
+
+var (
+	schemaMetaField = &FieldDef{Name: "__schema", Type: &TypeRef{Name: "__Schema", NonNull: true}}
+	typeMetaField   = &FieldDef{Name: "__type", Type: &TypeRef{Name: "__Type"},
+		Args: []*InputValue{{Name: "name", Type: &TypeRef{Name: "String", NonNull: true}}}}
+)
+
+type execContext struct {
+	*Executor
+	doc           *QueryDocument
+	vars          map[string]any
+	errors        []*Error
+	seenErrors    map[string]bool
+	depthExceeded bool
+	introspection *introspection
+}
+
+func errorResponse(err error) *Response {
+	var gqlErr *Error
+	if !errors.As(err, &gqlErr) {
+		gqlErr = &Error{Message: err.Error()}
+	}
+	return &Response{Errors: []*Error{gqlErr}}
+}
+
+// Execute runs the request. Mutations are not run if allowMutation is false, as for GET requests
+func (e *Executor) Execute(req *Request, allowMutation bool) *Response {
+	doc, err := ParseQuery(req.Query)
+	if err != nil {
+		return errorResponse(err)
+	}
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return errorResponse(err)
+	}
+
+	rootName := e.Schema.QueryType
+	switch op.Type {
+	case "subscription":
+		return errorResponse(&Error{Message: "subscriptions are not supported", Locations: []Location{op.Loc}})
+	case "mutation":
+		if e.Schema.MutationType == "" {
+			return errorResponse(&Error{Message: "schema does not support mutations", Locations: []Location{op.Loc}})
+		}
+		if !allowMutation {
+			return errorResponse(&Error{Message: "mutations are not allowed for this request, use POST", Locations: []Location{op.Loc}})
+		}
+		rootName = e.Schema.MutationType
+	}
+	root := e.Schema.Types[rootName]
+
+	c := &execContext{Executor: e, doc: doc, seenErrors: map[string]bool{}}
+	c.validate(op, root)
+	if len(c.errors) > 0 {
+		return &Response{Errors: c.errors}
+	}
+	if c.vars, err = c.coerceVariables(op.Variables, req.Variables); err != nil {
+		return errorResponse(err)
+	}
+
+	// Fields are resolved one at a time, so mutation fields are run serially as required
+	data, _ := c.executeSelectionSet(root, nil, op.SelectionSet, nil)
+	return &Response{Data: data, Errors: c.errors, executed: true}
+}
+
+func selectOperation(doc *QueryDocument, name string) (*Operation, error) {
+	if name == "" {
+		if len(doc.Operations) > 1 {
+			return nil, &Error{Message: "operationName is required when the document has multiple operations"}
+		}
+		return doc.Operations[0], nil
+	}
+	for _, op := range doc.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, &Error{Message: fmt.Sprintf("unknown operation %s", name)}
+}
+
+// fieldDef returns the field definition, including the introspection meta fields on the query
+// type. The second return is true for the meta fields
+func (c *execContext) fieldDef(parent *TypeDef, name string) (*FieldDef, bool) {
+	if parent.Name == c.Schema.QueryType {
+		switch name {
+		case "__schema":
+			return schemaMetaField, true
+		case "__type":
+			return typeMetaField, true
+		}
+	}
+	return parent.Field(name), false
+}
+
+func (c *execContext) validationError(loc Location, format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	if c.seenErrors[msg] {
+		return // fragments used more than once report the same errors
+	}
+	c.seenErrors[msg] = true
+	c.errors = append(c.errors, &Error{Message: msg, Locations: []Location{loc}})
+}
+
+// validate checks the operation against the schema before it is executed
+func (c *execContext) validate(op *Operation, root *TypeDef) {
+	declared := map[string]bool{}
+	for _, def := range op.Variables {
+		if declared[def.Name] {
+			c.validationError(def.Loc, "variable $%s is defined more than once", def.Name)
+		}
+		declared[def.Name] = true
+		if varType, ok := c.Schema.Types[def.Type.namedType()]; !ok || !varType.isInput() {
+			c.validationError(def.Loc, "variable $%s cannot be of non-input type %s", def.Name, def.Type)
+		}
+	}
+	c.validateSelections(root, op.SelectionSet, 1, false, map[string]bool{}, declared)
+}
+
+func (c *execContext) validateSelections(parent *TypeDef, sels []Selection, depth int, meta bool, fragPath, declared map[string]bool) {
+	if c.MaxDepth > 0 && depth > c.MaxDepth && !meta {
+		if !c.depthExceeded {
+			c.depthExceeded = true
+			c.errors = append(c.errors, &Error{Message: fmt.Sprintf("query exceeds the maximum depth of %d", c.MaxDepth)})
+		}
+		return
+	}
+
+	for _, sel := range sels {
+		c.validateDirectives(sel.selectionDirectives(), declared)
+		switch sel := sel.(type) {
+		case *Field:
+			if sel.Name == "__typename" {
+				if len(sel.SelectionSet) > 0 {
+					c.validationError(sel.Loc, "field __typename cannot have a selection")
+				}
+				continue
+			}
+			fieldDef, isMeta := c.fieldDef(parent, sel.Name)
+			if fieldDef == nil {
+				c.validationError(sel.Loc, "cannot query field %s on type %s", sel.Name, parent.Name)
+				continue
+			}
+			if isMeta && !c.Introspection {
+				c.validationError(sel.Loc, "introspection is disabled, cannot query field %s", sel.Name)
+				continue
+			}
+			c.validateArgs(parent.Name+"."+sel.Name, fieldDef.Args, sel.Args, sel.Loc, declared)
+
+			fieldType := c.Schema.Types[fieldDef.Type.namedType()]
+			if fieldType.isLeaf() {
+				if len(sel.SelectionSet) > 0 {
+					c.validationError(sel.Loc, "field %s of type %s cannot have a selection", sel.Name, fieldDef.Type)
+				}
+			} else if len(sel.SelectionSet) == 0 {
+				c.validationError(sel.Loc, "field %s of type %s must have a selection of subfields", sel.Name, fieldDef.Type)
+			} else {
+				c.validateSelections(fieldType, sel.SelectionSet, depth+1, meta || isMeta, fragPath, declared)
+			}
+		case *FragmentSpread:
+			frag, ok := c.doc.Fragments[sel.Name]
+			if !ok {
+				c.validationError(sel.Loc, "unknown fragment %s", sel.Name)
+				continue
+			}
+			if fragPath[sel.Name] {
+				c.validationError(sel.Loc, "fragment %s cannot spread itself", sel.Name)
+				continue
+			}
+			if condType := c.fragmentType(frag.TypeCondition, frag.Loc); condType != nil {
+				fragPath[sel.Name] = true
+				c.validateSelections(condType, frag.SelectionSet, depth, meta, fragPath, declared)
+				delete(fragPath, sel.Name)
+			}
+		case *InlineFragment:
+			condType := parent
+			if sel.TypeCondition != "" {
+				condType = c.fragmentType(sel.TypeCondition, sel.Loc)
+			}
+			if condType != nil {
+				c.validateSelections(condType, sel.SelectionSet, depth, meta, fragPath, declared)
+			}
+		}
+	}
+}
+
+func (c *execContext) fragmentType(name string, loc Location) *TypeDef {
+	condType, ok := c.Schema.Types[name]
+	if !ok {
+		c.validationError(loc, "unknown type %s in fragment", name)
+		return nil
+	}
+	if condType.isInput() {
+		c.validationError(loc, "fragment cannot have non composite type %s as condition", name)
+		return nil
+	}
+	return condType
+}
+
+func (c *execContext) validateArgs(owner string, defs []*InputValue, args []*Argument, loc Location, declared map[string]bool) {
+	seen := map[string]bool{}
+	for _, arg := range args {
+		if seen[arg.Name] {
+			c.validationError(arg.Loc, "argument %s is specified more than once", arg.Name)
+		}
+		seen[arg.Name] = true
+		if !slices.ContainsFunc(defs, func(d *InputValue) bool { return d.Name == arg.Name }) {
+			c.validationError(arg.Loc, "unknown argument %s on field %s", arg.Name, owner)
+		}
+		c.validateVariables(arg.Value, declared)
+	}
+	for _, def := range defs {
+		if def.Type.NonNull && def.Default == nil && !seen[def.Name] {
+			c.validationError(loc, "argument %s of type %s is required for field %s", def.Name, def.Type, owner)
+		}
+	}
+}
+
+func (c *execContext) validateDirectives(directives []*Directive, declared map[string]bool) {
+	for _, d := range directives {
+		if d.Name != "skip" && d.Name != "include" {
+			c.validationError(d.Loc, "unknown directive @%s", d.Name)
+			continue
+		}
+		if len(d.Args) != 1 || d.Args[0].Name != "if" {
+			c.validationError(d.Loc, "directive @%s requires the if argument", d.Name)
+			continue
+		}
+		c.validateVariables(d.Args[0].Value, declared)
+	}
+}
+
+func (c *execContext) validateVariables(value *Value, declared map[string]bool) {
+	switch value.Kind {
+	case VariableValue:
+		if !declared[value.Raw] {
+			c.validationError(value.Loc, "variable $%s is not defined", value.Raw)
+		}
+	case ListValue:
+		for _, item := range value.List {
+			c.validateVariables(item, declared)
+		}
+	case ObjectValue:
+		for _, field := range value.Fields {
+			c.validateVariables(field.Value, declared)
+		}
+	}
+}
+
+// appendPath returns a new path with the element added, the original is not modified
+func appendPath(path []any, elem any) []any {
+	return append(slices.Clip(path), elem)
+}
+
+func (c *execContext) fieldError(err error, field *Field, path []any) {
+	c.errors = append(c.errors, &Error{Message: err.Error(), Locations: []Location{field.Loc}, Path: path})
+}
+
+// executeSelectionSet resolves the selected fields of an object. If a non-null field fails, the
+// second return is true and the null is propagated to the parent
+func (c *execContext) executeSelectionSet(objType *TypeDef, parent any, sels []Selection, path []any) (*OrderedMap, bool) {
+	var keys []string
+	grouped := map[string][]*Field{}
+	c.collectFields(objType, sels, map[string]bool{}, &keys, grouped)
+
+	ret := newOrderedMap(len(keys))
+	for _, key := range keys {
+		value, failed := c.executeField(objType, parent, grouped[key], appendPath(path, key))
+		if failed {
+			return nil, true
+		}
+		ret.set(key, value)
+	}
+	return ret, false
+}
+
+// collectFields groups the fields by the response key, expanding the fragments which apply
+// to the object type
+func (c *execContext) collectFields(objType *TypeDef, sels []Selection, visited map[string]bool, keys *[]string, grouped map[string][]*Field) {
+	for _, sel := range sels {
+		if !c.shouldInclude(sel.selectionDirectives()) {
+			continue
+		}
+		switch sel := sel.(type) {
+		case *Field:
+			key := sel.ResponseKey()
+			if _, ok := grouped[key]; !ok {
+				*keys = append(*keys, key)
+			}
+			grouped[key] = append(grouped[key], sel)
+		case *FragmentSpread:
+			if visited[sel.Name] {
+				continue
+			}
+			visited[sel.Name] = true
+			frag := c.doc.Fragments[sel.Name]
+			if c.fragmentApplies(objType, frag.TypeCondition) {
+				c.collectFields(objType, frag.SelectionSet, visited, keys, grouped)
+			}
+		case *InlineFragment:
+			if c.fragmentApplies(objType, sel.TypeCondition) {
+				c.collectFields(objType, sel.SelectionSet, visited, keys, grouped)
+			}
+		}
+	}
+}
+
+func (c *execContext) shouldInclude(directives []*Directive) bool {
+	for _, d := range directives {
+		value, err := c.coerceLiteral(d.Args[0].Value, &TypeRef{Name: "Boolean", NonNull: true}, "@"+d.Name)
+		cond, _ := value.(bool)
+		if err != nil {
+			cond = false
+		}
+		if (d.Name == "skip" && cond) || (d.Name == "include" && !cond) {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *execContext) fragmentApplies(objType *TypeDef, cond string) bool {
+	if cond == "" || cond == objType.Name {
+		return true
+	}
+	condType, ok := c.Schema.Types[cond]
+	if !ok {
+		return false
+	}
+	return (condType.Kind == KindInterface || condType.Kind == KindUnion) && slices.Contains(condType.PossibleTypes, objType.Name)
+}
+
+func (c *execContext) executeField(objType *TypeDef, parent any, fields []*Field, path []any) (any, bool) {
+	field := fields[0]
+	if field.Name == "__typename" {
+		return objType.Name, false
+	}
+
+	fieldDef, isMeta := c.fieldDef(objType, field.Name)
+	args, err := c.coerceArgs(fieldDef.Args, field.Args)
+	if err != nil {
+		c.fieldError(err, field, path)
+		return nil, fieldDef.Type.NonNull
+	}
+
+	var value any
+	if isMeta {
+		value = c.resolveMeta(field.Name, args)
+	} else if resolver := c.Resolvers[objType.Name+"."+field.Name]; resolver != nil {
+		value, err = resolver(parent, args)
+	} else {
+		value, err = defaultResolve(parent, field.Name, args)
+	}
+	if err != nil {
+		c.fieldError(err, field, path)
+		return nil, fieldDef.Type.NonNull
+	}
+
+	sels := field.SelectionSet
+	for _, f := range fields[1:] {
+		sels = append(slices.Clip(sels), f.SelectionSet...)
+	}
+	return c.completeValue(fieldDef.Type, field, sels, value, path)
+}
+
+// defaultResolve looks up the field in the parent map. A function value is called with the
+// field arguments, this is used for the introspection fields which take arguments
+func defaultResolve(parent any, name string, args map[string]any) (any, error) {
+	switch p := parent.(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		if fn, ok := p[name].(func(map[string]any) any); ok {
+			return fn(args), nil
+		}
+		return p[name], nil
+	}
+	return nil, fmt.Errorf("cannot resolve field %s, the parent value is of type %T, not an object", name, parent)
+}
+
+func (c *execContext) resolveMeta(name string, args map[string]any) any {
+	if c.introspection == nil {
+		c.introspection = newIntrospection(c.Schema)
+	}
+	if name == "__schema" {
+		return c.introspection.schemaValue()
+	}
+	typeName, _ := args["name"].(string)
+	return c.introspection.namedType(typeName)
+}
+
+// completeValue converts the resolved value to the field type. If a non-null value fails, the
+// second return is true and the null is propagated to the parent
+func (c *execContext) completeValue(ref *TypeRef, field *Field, sels []Selection, value any, path []any) (any, bool) {
+	ret, failed := c.completeNullable(ref, field, sels, value, path)
+	if !ref.NonNull {
+		return ret, false // a failed nullable value is set to null
+	}
+	if failed {
+		return nil, true
+	}
+	if ret == nil {
+		c.fieldError(fmt.Errorf("cannot return null for non-null field %s", field.Name), field, path)
+		return nil, true
+	}
+	return ret, false
+}
+
+// completeNullable converts the value ignoring the non-null of the type. The second return
+// is true if the value failed, the error is already recorded
+func (c *execContext) completeNullable(ref *TypeRef, field *Field, sels []Selection, value any, path []any) (any, bool) {
+	if value == nil {
+		return nil, false
+	}
+
+	if ref.Elem != nil {
+		// Resolvers can return typed slices like []string, so the list is read using reflection
+		items := reflect.ValueOf(value)
+		if items.Kind() != reflect.Slice && items.Kind() != reflect.Array {
+			c.fieldError(fmt.Errorf("expected a list for field %s, got %T", field.Name, value), field, path)
+			return nil, true
+		}
+		ret := make([]any, 0, items.Len())
+		for i := range items.Len() {
+			v, failed := c.completeValue(ref.Elem, field, sels, items.Index(i).Interface(), appendPath(path, i))
+			if failed {
+				return nil, true
+			}
+			ret = append(ret, v)
+		}
+		return ret, false
+	}
+
+	typeDef := c.Schema.Types[ref.Name]
+	if typeDef.isLeaf() {
+		ret, err := serialize(typeDef, value)
+		if err != nil {
+			c.fieldError(err, field, path)
+			return nil, true
+		}
+		return ret, false
+	}
+
+	objType := typeDef
+	if typeDef.Kind != KindObject {
+		var err error
+		if objType, err = c.resolveAbstractType(typeDef, value); err != nil {
+			c.fieldError(err, field, path)
+			return nil, true
+		}
+	}
+	ret, failed := c.executeSelectionSet(objType, value, sels, path)
+	if failed {
+		return nil, true
+	}
+	return ret, false
+}
+
+// resolveAbstractType returns the object type for an interface or union value, using the
+// __typename key in the value
+func (c *execContext) resolveAbstractType(abstract *TypeDef, value any) (*TypeDef, error) {
+	var typeName string
+	if m, ok := value.(map[string]any); ok {
+		typeName, _ = m["__typename"].(string)
+	}
+	if typeName == "" {
+		return nil, fmt.Errorf("cannot determine the object type for %s value, set the __typename key in the value", abstract.Name)
+	}
+	objType, ok := c.Schema.Types[typeName]
+	if !ok || !slices.Contains(abstract.PossibleTypes, typeName) {
+		return nil, fmt.Errorf("type %s is not a possible type for %s", typeName, abstract.Name)
+	}
+	return objType, nil
+}
+
+// serialize converts a resolved value to a scalar or enum result
+func serialize(typeDef *TypeDef, value any) (any, error) {
+	if typeDef.Kind == KindEnum {
+		if s, ok := value.(string); ok && typeDef.hasEnumValue(s) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("enum %s cannot represent value %v", typeDef.Name, value)
+	}
+
+	switch typeDef.Name {
+	case "Int":
+		if n, ok := toInt(value); ok && n >= math.MinInt32 && n <= math.MaxInt32 {
+			return n, nil
+		}
+	case "Float":
+		if f, ok := toFloat(value); ok && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f, nil
+		}
+	case "String":
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case bool:
+			return strconv.FormatBool(v), nil
+		}
+		if n, ok := toInt(value); ok {
+			return strconv.FormatInt(n, 10), nil
+		}
+	case "Boolean":
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+	case "ID":
+		if s, ok := value.(string); ok {
+			return s, nil
+		}
+		if n, ok := toInt(value); ok {
+			return strconv.FormatInt(n, 10), nil
+		}
+	default:
+		return value, nil // custom scalars are returned as is
+	}
+	return nil, fmt.Errorf("%s cannot represent value %v", typeDef.Name, value)
+}
+
+func toInt(value any) (int64, bool) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), true
+	case int32:
+		return int64(v), true
+	case int64:
+		return v, true
+	case uint:
+		return int64(v), v <= math.MaxInt64
+	case uint32:
+		return int64(v), true
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	case float32:
+		return int64(v), float32(int64(v)) == v
+	case float64:
+		return int64(v), float64(int64(v)) == v
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+func toFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case float32:
+		return float64(v), true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	if n, ok := toInt(value); ok {
+		return float64(n), true
+	}
+	return 0, false
+}
@@ -0,0 +1,221 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+const testSchema = `
+"A book in the library"
+type Book {
+  id: ID!
+  title: String!
+  author: Author
+  tags: [String!]!
+  isbn: String @deprecated(reason: "use id")
+}
+
+type Author {
+  name: String!
+  books: [Book!]!
+}
+
+interface Named { name: String! }
+type Shelf implements Named { name: String! count: Int }
+union SearchResult = Book | Author
+
+enum Genre { FICTION HISTORY }
+
+input BookInput {
+  title: String!
+  genre: Genre = FICTION
+  tags: [String!]
+}
+
+type Query {
+  book(id: ID!): Book
+  books(genre: Genre, limit: Int = 10): [Book!]!
+  search(text: String!): [SearchResult!]!
+  named: Named
+  fail: String!
+}
+
+type Mutation {
+  addBook(input: BookInput!): Book!
+}
+`
+
+func testExecutor(t *testing.T) *Executor {
+	t.Helper()
+	schema, err := ParseSchema(testSchema)
+	testutil.AssertNoError(t, err)
+
+	author := map[string]any{"name": "Tolstoy"}
+	books := []any{
+		map[string]any{"id": "1", "title": "War and Peace", "author": author, "tags": []string{"classic"}},
+		map[string]any{"id": "2", "title": "Anna Karenina", "author": author, "tags": []any{}},
+	}
+	author["books"] = books
+
+	return &Executor{
+		Schema:        schema,
+		Introspection: true,
+		MaxDepth:      5,
+		Resolvers: map[string]Resolver{
+			"Query.book": func(parent any, args map[string]any) (any, error) {
+				for _, b := range books {
+					if b.(map[string]any)["id"] == args["id"] {
+						return b, nil
+					}
+				}
+				return nil, nil
+			},
+			"Query.books": func(parent any, args map[string]any) (any, error) {
+				limit := int(args["limit"].(int64))
+				return books[:min(limit, len(books))], nil
+			},
+			"Query.search": func(parent any, args map[string]any) (any, error) {
+				return []any{
+					map[string]any{"__typename": "Book", "id": "1", "title": "War and Peace", "tags": []any{}},
+					map[string]any{"__typename": "Author", "name": "Tolstoy", "books": []any{}},
+				}, nil
+			},
+			"Query.named": func(parent any, args map[string]any) (any, error) {
+				return map[string]any{"__typename": "Shelf", "name": "top", "count": 3}, nil
+			},
+			"Query.fail": func(parent any, args map[string]any) (any, error) {
+				return nil, fmt.Errorf("resolver failed")
+			},
+			"Mutation.addBook": func(parent any, args map[string]any) (any, error) {
+				input := args["input"].(map[string]any)
+				return map[string]any{"id": "3", "title": input["title"], "tags": []any{input["genre"]}}, nil
+			},
+		},
+	}
+}
+
+func execJson(t *testing.T, e *Executor, req *Request, allowMutation bool) string {
+	t.Helper()
+	out, err := json.Marshal(e.Execute(req, allowMutation))
+	testutil.AssertNoError(t, err)
+	return string(out)
+}
+
+func TestExecute(t *testing.T) {
+	e := testExecutor(t)
+	tests := []struct {
+		name     string
+		query    string
+		vars     map[string]any
+		expected string
+	}{
+		{"basic", `{ book(id: "1") { title author { name } } }`, nil,
+			`{"data":{"book":{"title":"War and Peace","author":{"name":"Tolstoy"}}}}`},
+		{"alias and order", `{ b: book(id: 2) { title id } }`, nil,
+			`{"data":{"b":{"title":"Anna Karenina","id":"2"}}}`},
+		{"default arg", `{ books { id } }`, nil,
+			`{"data":{"books":[{"id":"1"},{"id":"2"}]}}`},
+		{"variables", `query Q($n: Int) { books(limit: $n) { id } }`, map[string]any{"n": float64(1)},
+			`{"data":{"books":[{"id":"1"}]}}`},
+		{"fragments", `{ book(id: "1") { ...F } } fragment F on Book { id ... on Book { title } }`, nil,
+			`{"data":{"book":{"id":"1","title":"War and Peace"}}}`},
+		{"skip include", `query ($s: Boolean!) { book(id: "1") { id @skip(if: $s) title @include(if: false) } }`,
+			map[string]any{"s": true}, `{"data":{"book":{}}}`},
+		{"union", `{ search(text: "x") { __typename ... on Book { title } ... on Author { name } } }`, nil,
+			`{"data":{"search":[{"__typename":"Book","title":"War and Peace"},{"__typename":"Author","name":"Tolstoy"}]}}`},
+		{"interface", `{ named { name ... on Shelf { count } } }`, nil,
+			`{"data":{"named":{"name":"top","count":3}}}`},
+		{"null propagation", `{ book(id: "1") { id } fail }`, nil,
+			`{"data":null,"errors":[{"message":"resolver failed","locations":[{"line":1,"column":24}],"path":["fail"]}]}`},
+		{"unknown field", `{ book(id: "1") { price } }`, nil,
+			`{"errors":[{"message":"cannot query field price on type Book","locations":[{"line":1,"column":19}]}]}`},
+		{"missing arg", `{ book { id } }`, nil,
+			`{"errors":[{"message":"argument id of type ID! is required for field Query.book","locations":[{"line":1,"column":3}]}]}`},
+		{"syntax error", `{ book(id: "1") { id } ]`, nil,
+			`{"errors":[{"message":"syntax error: expected name, found ]","locations":[{"line":1,"column":24}]}]}`},
+		{"depth", `{ book(id: "1") { author { books { author { books { author { name } } } } } } }`, nil,
+			`{"errors":[{"message":"query exceeds the maximum depth of 5"}]}`},
+		{"missing variable", `query ($id: ID!) { book(id: $id) { id } }`, nil,
+			`{"errors":[{"message":"variable $id of required type ID! was not provided","locations":[{"line":1,"column":8}]}]}`},
+		{"typename", `{ __typename }`, nil, `{"data":{"__typename":"Query"}}`},
+		{"deprecated field", `{ __type(name: "Book") { fields { name } } }`, nil,
+			`{"data":{"__type":{"fields":[{"name":"id"},{"name":"title"},{"name":"author"},{"name":"tags"}]}}}`},
+		{"introspection type", `{ __type(name: "BookInput") { kind inputFields { name defaultValue type { kind ofType { name } } } } }`, nil,
+			`{"data":{"__type":{"kind":"INPUT_OBJECT","inputFields":[{"name":"title","defaultValue":null,"type":{"kind":"NON_NULL","ofType":{"name":"String"}}},` +
+				`{"name":"genre","defaultValue":"FICTION","type":{"kind":"ENUM","ofType":null}},{"name":"tags","defaultValue":null,"type":{"kind":"LIST","ofType":{"name":null}}}]}}}`},
+		{"schema", `{ __schema { queryType { name } mutationType { name } } }`, nil,
+			`{"data":{"__schema":{"queryType":{"name":"Query"},"mutationType":{"name":"Mutation"}}}}`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			testutil.AssertEqualsString(t, "response", test.expected, execJson(t, e, &Request{Query: test.query, Variables: test.vars}, false))
+		})
+	}
+}
+
+func TestMutation(t *testing.T) {
+	e := testExecutor(t)
+	req := &Request{
+		Query:     `mutation Add($in: BookInput!) { addBook(input: $in) { id title tags } }`,
+		Variables: map[string]any{"in": map[string]any{"title": "Resurrection"}},
+	}
+	testutil.AssertEqualsString(t, "mutation", `{"data":{"addBook":{"id":"3","title":"Resurrection","tags":["FICTION"]}}}`, execJson(t, e, req, true))
+	testutil.AssertEqualsString(t, "get mutation",
+		`{"errors":[{"message":"mutations are not allowed for this request, use POST","locations":[{"line":1,"column":1}]}]}`, execJson(t, e, req, false))
+
+	req.Variables = map[string]any{"in": map[string]any{"title": "x", "genre": "POETRY"}}
+	testutil.AssertStringContains(t, execJson(t, e, req, true), "expected a value of type Genre, found POETRY")
+
+	req = &Request{Query: `mutation { addBook(input: {title: "Hadji Murat", genre: HISTORY}) { tags } }`}
+	testutil.AssertEqualsString(t, "literal input", `{"data":{"addBook":{"tags":["HISTORY"]}}}`, execJson(t, e, req, true))
+}
+
+func TestIntrospectionDisabled(t *testing.T) {
+	e := testExecutor(t)
+	e.Introspection = false
+	testutil.AssertEqualsString(t, "disabled",
+		`{"errors":[{"message":"introspection is disabled, cannot query field __schema","locations":[{"line":1,"column":3}]}]}`,
+		execJson(t, e, &Request{Query: `{ __schema { types { name } } }`}, false))
+	testutil.AssertEqualsString(t, "typename", `{"data":{"__typename":"Query"}}`, execJson(t, e, &Request{Query: `{ __typename }`}, false))
+}
+
+func TestOperationName(t *testing.T) {
+	e := testExecutor(t)
+	query := `query A { book(id: "1") { id } } query B { book(id: "2") { id } }`
+	testutil.AssertEqualsString(t, "select", `{"data":{"book":{"id":"2"}}}`,
+		execJson(t, e, &Request{Query: query, OperationName: "B"}, false))
+	testutil.AssertEqualsString(t, "required", `{"errors":[{"message":"operationName is required when the document has multiple operations"}]}`,
+		execJson(t, e, &Request{Query: query}, false))
+}
+
+func TestParseSchemaErrors(t *testing.T) {
+	tests := []struct {
+		schema string
+		err    string
+	}{
+		{`type Foo { a: Int }`, "schema query type Query is not defined as an object type"},
+		{`type Query { a: Bar }`, "field Query.a has unknown type Bar"},
+		{`type Query { a(x: Query): Int }`, "Query.a: x cannot be of output type Query"},
+		{`type Query { a: Int } type Query { b: Int }`, "type Query is defined more than once"},
+		{`type Query { __a: Int }`, "names starting with __ are reserved"},
+		{`type Query { a: Int } union U = Query | Int`, "union U member Int is not an object type"},
+		{`interface I { id: ID } type Query implements I { a: Int }`, "type Query implements I but does not have field id"},
+		{`type Query { a: Int`, "syntax error: expected name, found <EOF>"},
+	}
+	for _, test := range tests {
+		_, err := ParseSchema(test.schema)
+		testutil.AssertErrorContains(t, err, test.err)
+	}
+
+	schema, err := ParseSchema(`schema { query: Root } type Root { a: Int } extend type Root { b: String }`)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "query type", "Root", schema.QueryType)
+	testutil.AssertEqualsString(t, "extended", "String", schema.Types["Root"].Field("b").Type.String())
+}
@@ -0,0 +1,180 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package graphql
+
+import (
+	"maps"
+	"slices"
+)
+
+// introspection builds the values for the __schema and __type meta fields, as maps which are
+// read by the default resolver. The named type values are cached since the types can refer to
+// each other in cycles
+type introspection struct {
+	schema *Schema
+	types  map[string]map[string]any
+}
+
+func newIntrospection(schema *Schema) *introspection {
+	return &introspection{schema: schema, types: map[string]map[string]any{}}
+}
+
+func optString(s string) any {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func (in *introspection) schemaValue() any {
+	types := make([]any, 0, len(in.schema.Types))
+	for _, name := range slices.Sorted(maps.Keys(in.schema.Types)) {
+		types = append(types, in.namedType(name))
+	}
+
+	ret := map[string]any{
+		"types":      types,
+		"queryType":  in.namedType(in.schema.QueryType),
+		"directives": in.directives(),
+	}
+	if in.schema.MutationType != "" {
+		ret["mutationType"] = in.namedType(in.schema.MutationType)
+	}
+	return ret
+}
+
+// namedType returns the value for a named type, nil if the type is not defined
+func (in *introspection) namedType(name string) any {
+	if ret, ok := in.types[name]; ok {
+		return ret
+	}
+	def, ok := in.schema.Types[name]
+	if !ok {
+		return nil
+	}
+
+	ret := map[string]any{
+		"kind":        string(def.Kind),
+		"name":        def.Name,
+		"description": optString(def.Description),
+	}
+	in.types[name] = ret
+
+	switch def.Kind {
+	case KindObject, KindInterface:
+		ret["fields"] = func(args map[string]any) any {
+			includeDeprecated, _ := args["includeDeprecated"].(bool)
+			fields := []any{}
+			for _, field := range def.Fields {
+				if !field.Deprecated || includeDeprecated {
+					fields = append(fields, in.field(field))
+				}
+			}
+			return fields
+		}
+		ret["interfaces"] = in.typeList(def.Interfaces)
+		if def.Kind == KindInterface {
+			ret["possibleTypes"] = in.typeList(def.PossibleTypes)
+		}
+	case KindUnion:
+		ret["possibleTypes"] = in.typeList(def.PossibleTypes)
+	case KindEnum:
+		ret["enumValues"] = func(args map[string]any) any {
+			includeDeprecated, _ := args["includeDeprecated"].(bool)
+			values := []any{}
+			for _, value := range def.EnumValues {
+				if !value.Deprecated || includeDeprecated {
+					values = append(values, map[string]any{
+						"name":              value.Name,
+						"description":       optString(value.Description),
+						"isDeprecated":      value.Deprecated,
+						"deprecationReason": optString(value.DeprecationReason),
+					})
+				}
+			}
+			return values
+		}
+	case KindInputObject:
+		ret["inputFields"] = func(map[string]any) any {
+			return in.inputValues(def.InputFields)
+		}
+	}
+	return ret
+}
+
+func (in *introspection) typeList(names []string) []any {
+	ret := make([]any, 0, len(names))
+	for _, name := range names {
+		ret = append(ret, in.namedType(name))
+	}
+	return ret
+}
+
+// typeRef returns the value for a type reference, with the NON_NULL and LIST wrappers
+func (in *introspection) typeRef(ref *TypeRef) any {
+	if ref.NonNull {
+		inner := *ref
+		inner.NonNull = false
+		return map[string]any{"kind": "NON_NULL", "ofType": in.typeRef(&inner)}
+	}
+	if ref.Elem != nil {
+		return map[string]any{"kind": "LIST", "ofType": in.typeRef(ref.Elem)}
+	}
+	return in.namedType(ref.Name)
+}
+
+func (in *introspection) field(field *FieldDef) map[string]any {
+	return map[string]any{
+		"name":        field.Name,
+		"description": optString(field.Description),
+		"args": func(map[string]any) any {
+			return in.inputValues(field.Args)
+		},
+		"type":              in.typeRef(field.Type),
+		"isDeprecated":      field.Deprecated,
+		"deprecationReason": optString(field.DeprecationReason),
+	}
+}
+
+func (in *introspection) inputValues(values []*InputValue) []any {
+	ret := make([]any, 0, len(values))
+	for _, value := range values {
+		var defaultValue any
+		if value.Default != nil {
+			defaultValue = value.Default.String()
+		}
+		ret = append(ret, map[string]any{
+			"name":         value.Name,
+			"description":  optString(value.Description),
+			"type":         in.typeRef(value.Type),
+			"defaultValue": defaultValue,
+			"isDeprecated": false,
+		})
+	}
+	return ret
+}
+
+func (in *introspection) directives() []any {
+	ifArg := []*InputValue{{Name: "if", Type: &TypeRef{Name: "Boolean", NonNull: true}}}
+	directive := func(name, description string, locations []any, args []*InputValue) any {
+		return map[string]any{
+			"name":        name,
+			"description": description,
+			"locations":   locations,
+			"args": func(map[string]any) any {
+				return in.inputValues(args)
+			},
+			"isRepeatable": false,
+		}
+	}
+	return []any{
+		directive("include", "Directs the executor to include this field or fragment only when the if argument is true",
+			[]any{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"}, ifArg),
+		directive("skip", "Directs the executor to skip this field or fragment when the if argument is true",
+			[]any{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"}, ifArg),
+		directive("deprecated", "Marks an element of a GraphQL schema as no longer supported",
+			[]any{"FIELD_DEFINITION", "ARGUMENT_DEFINITION", "INPUT_FIELD_DEFINITION", "ENUM_VALUE"},
+			[]*InputValue{{Name: "reason", Type: &TypeRef{Name: "String"}, Default: &Value{Kind: StringValue, Raw: DEFAULT_DEPRECATION_REASON}}}),
+	}
+}
@@ -0,0 +1,495 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	line  int
+	col   int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "<EOF>"
+	case tokenString:
+		return strconv.Quote(t.value)
+	default:
+		return t.value
+	}
+}
+
+// Location is a line and column position in a GraphQL document, both starting at 1
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// lexer splits a GraphQL document into tokens. Whitespace, commas and comments are skipped
+type lexer struct {
+	src       string
+	pos       int
+	line      int
+	lineStart int
+}
+
+func newLexer(src string) *lexer {
+	return &lexer{src: strings.TrimPrefix(src, "\ufeff"), line: 1}
+}
+
+func (l *lexer) errorf(line, col int, format string, args ...any) error {
+	return &Error{Message: fmt.Sprintf("syntax error: "+format, args...), Locations: []Location{{Line: line, Column: col}}}
+}
+
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\n':
+			l.pos++
+			l.line++
+			l.lineStart = l.pos
+		case c == ' ' || c == '\t' || c == '\r' || c == ',':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipIgnored()
+	tok := token{line: l.line, col: l.pos - l.lineStart + 1}
+	if l.pos >= len(l.src) {
+		tok.kind = tokenEOF
+		return tok, nil
+	}
+
+	c := l.src[l.pos]
+	switch {
+	case strings.IndexByte("!$&()[]{}:=@|", c) >= 0:
+		tok.kind = tokenPunct
+		tok.value = string(c)
+		l.pos++
+	case c == '.':
+		if !strings.HasPrefix(l.src[l.pos:], "...") {
+			return tok, l.errorf(tok.line, tok.col, "unexpected character %q", c)
+		}
+		tok.kind = tokenPunct
+		tok.value = "..."
+		l.pos += 3
+	case c == '_' || isLetter(c):
+		start := l.pos
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		tok.kind = tokenName
+		tok.value = l.src[start:l.pos]
+	case c == '-' || isDigit(c):
+		return l.readNumber(tok)
+	case c == '"':
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			return l.readBlockString(tok)
+		}
+		return l.readString(tok)
+	default:
+		r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+		return tok, l.errorf(tok.line, tok.col, "unexpected character %q", r)
+	}
+	return tok, nil
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (l *lexer) readNumber(tok token) (token, error) {
+	start := l.pos
+	tok.kind = tokenInt
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	digits := func() int {
+		n := 0
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+			n++
+		}
+		return n
+	}
+	if digits() == 0 {
+		return tok, l.errorf(tok.line, tok.col, "invalid number")
+	}
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		tok.kind = tokenFloat
+		l.pos++
+		if digits() == 0 {
+			return tok, l.errorf(tok.line, tok.col, "invalid number")
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		tok.kind = tokenFloat
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		if digits() == 0 {
+			return tok, l.errorf(tok.line, tok.col, "invalid number")
+		}
+	}
+	tok.value = l.src[start:l.pos]
+	return tok, nil
+}
+
+func (l *lexer) readString(tok token) (token, error) {
+	l.pos++ // opening quote
+	var sb strings.Builder
+	for {
+		if l.pos >= len(l.src) || l.src[l.pos] == '\n' {
+			return tok, l.errorf(tok.line, tok.col, "unterminated string")
+		}
+		c := l.src[l.pos]
+		switch c {
+		case '"':
+			l.pos++
+			tok.kind = tokenString
+			tok.value = sb.String()
+			return tok, nil
+		case '\\':
+			if l.pos+1 >= len(l.src) {
+				return tok, l.errorf(tok.line, tok.col, "unterminated string")
+			}
+			esc := l.src[l.pos+1]
+			l.pos += 2
+			switch esc {
+			case '"', '\\', '/':
+				sb.WriteByte(esc)
+			case 'b':
+				sb.WriteByte('\b')
+			case 'f':
+				sb.WriteByte('\f')
+			case 'n':
+				sb.WriteByte('\n')
+			case 'r':
+				sb.WriteByte('\r')
+			case 't':
+				sb.WriteByte('\t')
+			case 'u':
+				if l.pos+4 > len(l.src) {
+					return tok, l.errorf(tok.line, tok.col, "invalid unicode escape")
+				}
+				code, err := strconv.ParseUint(l.src[l.pos:l.pos+4], 16, 32)
+				if err != nil {
+					return tok, l.errorf(tok.line, tok.col, "invalid unicode escape")
+				}
+				sb.WriteRune(rune(code))
+				l.pos += 4
+			default:
+				return tok, l.errorf(tok.line, tok.col, "invalid escape \\%c", esc)
+			}
+		default:
+			sb.WriteByte(c)
+			l.pos++
+		}
+	}
+}
+
+// readBlockString reads a """ string. The common indentation and the leading and trailing
+// blank lines are removed, as in the spec
+func (l *lexer) readBlockString(tok token) (token, error) {
+	l.pos += 3
+	start := l.pos
+	for {
+		if l.pos >= len(l.src) {
+			return tok, l.errorf(tok.line, tok.col, "unterminated block string")
+		}
+		if strings.HasPrefix(l.src[l.pos:], `\"""`) {
+			l.pos += 4
+			continue
+		}
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			break
+		}
+		if l.src[l.pos] == '\n' {
+			l.line++
+			l.lineStart = l.pos + 1
+		}
+		l.pos++
+	}
+	raw := strings.ReplaceAll(l.src[start:l.pos], `\"""`, `"""`)
+	l.pos += 3
+
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	indent := -1
+	for i, line := range lines {
+		if i == 0 || strings.TrimSpace(line) == "" {
+			continue
+		}
+		n := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i := 1; i < len(lines) && indent > 0; i++ {
+		if len(lines[i]) >= indent {
+			lines[i] = lines[i][indent:]
+		} else {
+			lines[i] = strings.TrimLeft(lines[i], " \t")
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	tok.kind = tokenString
+	tok.value = strings.Join(lines, "\n")
+	return tok, nil
+}
+
+// parser is the base for the schema and query parsers, with one token of lookahead
+type parser struct {
+	lex *lexer
+	tok token
+}
+
+func newParser(src string) (*parser, error) {
+	p := &parser{lex: newLexer(src)}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) loc() Location {
+	return Location{Line: p.tok.line, Column: p.tok.col}
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	return p.lex.errorf(p.tok.line, p.tok.col, format, args...)
+}
+
+func (p *parser) peek(punct string) bool {
+	return p.tok.kind == tokenPunct && p.tok.value == punct
+}
+
+func (p *parser) peekKeyword(name string) bool {
+	return p.tok.kind == tokenName && p.tok.value == name
+}
+
+// skip consumes the punctuator if it is next, returning whether it was found
+func (p *parser) skip(punct string) (bool, error) {
+	if !p.peek(punct) {
+		return false, nil
+	}
+	return true, p.advance()
+}
+
+func (p *parser) expect(punct string) error {
+	if !p.peek(punct) {
+		return p.errorf("expected %q, found %s", punct, p.tok)
+	}
+	return p.advance()
+}
+
+func (p *parser) expectKeyword(name string) error {
+	if !p.peekKeyword(name) {
+		return p.errorf("expected %q, found %s", name, p.tok)
+	}
+	return p.advance()
+}
+
+func (p *parser) name() (string, error) {
+	if p.tok.kind != tokenName {
+		return "", p.errorf("expected name, found %s", p.tok)
+	}
+	name := p.tok.value
+	return name, p.advance()
+}
+
+// typeRef parses a type reference like [Book!]!
+func (p *parser) typeRef() (*TypeRef, error) {
+	var ref *TypeRef
+	if ok, err := p.skip("["); err != nil {
+		return nil, err
+	} else if ok {
+		elem, err := p.typeRef()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		ref = &TypeRef{Elem: elem}
+	} else {
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		ref = &TypeRef{Name: name}
+	}
+	if ok, err := p.skip("!"); err != nil {
+		return nil, err
+	} else if ok {
+		ref.NonNull = true
+	}
+	return ref, nil
+}
+
+// value parses an input value. Variables are allowed only in queries, not in the schema
+func (p *parser) value(allowVars bool) (*Value, error) {
+	loc := p.loc()
+	switch p.tok.kind {
+	case tokenInt:
+		v := &Value{Kind: IntValue, Raw: p.tok.value, Loc: loc}
+		return v, p.advance()
+	case tokenFloat:
+		v := &Value{Kind: FloatValue, Raw: p.tok.value, Loc: loc}
+		return v, p.advance()
+	case tokenString:
+		v := &Value{Kind: StringValue, Raw: p.tok.value, Loc: loc}
+		return v, p.advance()
+	case tokenName:
+		v := &Value{Raw: p.tok.value, Loc: loc}
+		switch p.tok.value {
+		case "true", "false":
+			v.Kind = BooleanValue
+		case "null":
+			v.Kind = NullValue
+		default:
+			v.Kind = EnumValue
+		}
+		return v, p.advance()
+	}
+
+	switch {
+	case p.peek("$"):
+		if !allowVars {
+			return nil, p.errorf("variables are not allowed here")
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		return &Value{Kind: VariableValue, Raw: name, Loc: loc}, nil
+	case p.peek("["):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		v := &Value{Kind: ListValue, Loc: loc}
+		for !p.peek("]") {
+			item, err := p.value(allowVars)
+			if err != nil {
+				return nil, err
+			}
+			v.List = append(v.List, item)
+		}
+		return v, p.advance()
+	case p.peek("{"):
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		v := &Value{Kind: ObjectValue, Loc: loc}
+		for !p.peek("}") {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			item, err := p.value(allowVars)
+			if err != nil {
+				return nil, err
+			}
+			v.Fields = append(v.Fields, &ObjectField{Name: name, Value: item})
+		}
+		return v, p.advance()
+	}
+	return nil, p.errorf("unexpected %s", p.tok)
+}
+
+// directives parses a list of directives like @include(if: $flag)
+func (p *parser) directives(allowVars bool) ([]*Directive, error) {
+	var ret []*Directive
+	for p.peek("@") {
+		loc := p.loc()
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		args, err := p.arguments(allowVars)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, &Directive{Name: name, Args: args, Loc: loc})
+	}
+	return ret, nil
+}
+
+func (p *parser) arguments(allowVars bool) ([]*Argument, error) {
+	if ok, err := p.skip("("); err != nil || !ok {
+		return nil, err
+	}
+	var ret []*Argument
+	for !p.peek(")") {
+		loc := p.loc()
+		name, err := p.name()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		value, err := p.value(allowVars)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, &Argument{Name: name, Value: value, Loc: loc})
+	}
+	return ret, p.advance()
+}
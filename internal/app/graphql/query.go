@@ -0,0 +1,236 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package graphql
+
+import "fmt"
+
+// ParseQuery parses an executable document, with operations and fragment definitions
+func ParseQuery(src string) (*QueryDocument, error) {
+	p, err := newParser(src)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &QueryDocument{Fragments: map[string]*FragmentDef{}}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peek("{"):
+			op := &Operation{Type: "query", Loc: p.loc()}
+			if op.SelectionSet, err = p.selectionSet(); err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case p.peekKeyword("query"), p.peekKeyword("mutation"), p.peekKeyword("subscription"):
+			op, err := p.operation()
+			if err != nil {
+				return nil, err
+			}
+			doc.Operations = append(doc.Operations, op)
+		case p.peekKeyword("fragment"):
+			frag, err := p.fragmentDef()
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.Fragments[frag.Name]; ok {
+				return nil, &Error{Message: fmt.Sprintf("fragment %s is defined more than once", frag.Name), Locations: []Location{frag.Loc}}
+			}
+			doc.Fragments[frag.Name] = frag
+		default:
+			return nil, p.errorf("unexpected %s", p.tok)
+		}
+	}
+
+	if len(doc.Operations) == 0 {
+		return nil, &Error{Message: "document does not have any operation"}
+	}
+	if len(doc.Operations) > 1 {
+		names := map[string]bool{}
+		for _, op := range doc.Operations {
+			if op.Name == "" {
+				return nil, &Error{Message: "anonymous operation must be the only operation in the document", Locations: []Location{op.Loc}}
+			}
+			if names[op.Name] {
+				return nil, &Error{Message: fmt.Sprintf("operation %s is defined more than once", op.Name), Locations: []Location{op.Loc}}
+			}
+			names[op.Name] = true
+		}
+	}
+	return doc, nil
+}
+
+func (p *parser) operation() (*Operation, error) {
+	op := &Operation{Type: p.tok.value, Loc: p.loc()}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	var err error
+	if p.tok.kind == tokenName {
+		if op.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if p.peek("(") {
+		if op.Variables, err = p.variableDefs(); err != nil {
+			return nil, err
+		}
+	}
+	if op.Directives, err = p.directives(true); err != nil {
+		return nil, err
+	}
+	if op.SelectionSet, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+func (p *parser) variableDefs() ([]*VariableDef, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var ret []*VariableDef
+	for !p.peek(")") {
+		def := &VariableDef{Loc: p.loc()}
+		if err := p.expect("$"); err != nil {
+			return nil, err
+		}
+		var err error
+		if def.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if def.Type, err = p.typeRef(); err != nil {
+			return nil, err
+		}
+		if ok, err := p.skip("="); err != nil {
+			return nil, err
+		} else if ok {
+			if def.Default, err = p.value(false); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := p.directives(false); err != nil {
+			return nil, err
+		}
+		ret = append(ret, def)
+	}
+	return ret, p.advance()
+}
+
+func (p *parser) fragmentDef() (*FragmentDef, error) {
+	frag := &FragmentDef{Loc: p.loc()}
+	if err := p.expectKeyword("fragment"); err != nil {
+		return nil, err
+	}
+	if p.peekKeyword("on") {
+		return nil, p.errorf("fragment name cannot be \"on\"")
+	}
+	var err error
+	if frag.Name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("on"); err != nil {
+		return nil, err
+	}
+	if frag.TypeCondition, err = p.name(); err != nil {
+		return nil, err
+	}
+	if frag.Directives, err = p.directives(true); err != nil {
+		return nil, err
+	}
+	if frag.SelectionSet, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return frag, nil
+}
+
+func (p *parser) selectionSet() ([]Selection, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var ret []Selection
+	for !p.peek("}") {
+		var sel Selection
+		var err error
+		if p.peek("...") {
+			sel, err = p.fragment()
+		} else {
+			sel, err = p.field()
+		}
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, sel)
+	}
+	if len(ret) == 0 {
+		return nil, p.errorf("selection set cannot be empty")
+	}
+	return ret, p.advance()
+}
+
+func (p *parser) field() (*Field, error) {
+	field := &Field{Loc: p.loc()}
+	var err error
+	if field.Name, err = p.name(); err != nil {
+		return nil, err
+	}
+	if ok, err := p.skip(":"); err != nil {
+		return nil, err
+	} else if ok {
+		field.Alias = field.Name
+		if field.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if field.Args, err = p.arguments(true); err != nil {
+		return nil, err
+	}
+	if field.Directives, err = p.directives(true); err != nil {
+		return nil, err
+	}
+	if p.peek("{") {
+		if field.SelectionSet, err = p.selectionSet(); err != nil {
+			return nil, err
+		}
+	}
+	return field, nil
+}
+
+// fragment parses a fragment spread (...Name) or an inline fragment (... on Type { })
+func (p *parser) fragment() (Selection, error) {
+	loc := p.loc()
+	if err := p.expect("..."); err != nil {
+		return nil, err
+	}
+	if p.tok.kind == tokenName && !p.peekKeyword("on") {
+		spread := &FragmentSpread{Loc: loc}
+		var err error
+		if spread.Name, err = p.name(); err != nil {
+			return nil, err
+		}
+		if spread.Directives, err = p.directives(true); err != nil {
+			return nil, err
+		}
+		return spread, nil
+	}
+
+	inline := &InlineFragment{Loc: loc}
+	var err error
+	if p.peekKeyword("on") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if inline.TypeCondition, err = p.name(); err != nil {
+			return nil, err
+		}
+	}
+	if inline.Directives, err = p.directives(true); err != nil {
+		return nil, err
+	}
+	if inline.SelectionSet, err = p.selectionSet(); err != nil {
+		return nil, err
+	}
+	return inline, nil
+}
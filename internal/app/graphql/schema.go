@@ -0,0 +1,677 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package graphql
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+type TypeKind string
+
+const (
+	KindScalar      TypeKind = "SCALAR"
+	KindObject      TypeKind = "OBJECT"
+	KindInterface   TypeKind = "INTERFACE"
+	KindUnion       TypeKind = "UNION"
+	KindEnum        TypeKind = "ENUM"
+	KindInputObject TypeKind = "INPUT_OBJECT"
+)
+
+const DEFAULT_DEPRECATION_REASON = "No longer supported"
+
+// Schema is a parsed GraphQL schema. The builtin scalars and the introspection types are
+// always included
+type Schema struct {
+	Types        map[string]*TypeDef
+	QueryType    string
+	MutationType string
+}
+
+// TypeDef is a named type in the schema
+type TypeDef struct {
+	Kind          TypeKind
+	Name          string
+	Description   string
+	Fields        []*FieldDef     // object and interface
+	Interfaces    []string        // object and interface
+	PossibleTypes []string        // union members, the implementing objects for an interface
+	EnumValues    []*EnumValueDef // enum
+	InputFields   []*InputValue   // input object
+	fieldIndex    map[string]*FieldDef
+}
+
+// Field returns the field definition, nil if the type has no such field
+func (t *TypeDef) Field(name string) *FieldDef {
+	return t.fieldIndex[name]
+}
+
+func (t *TypeDef) isLeaf() bool {
+	return t.Kind == KindScalar || t.Kind == KindEnum
+}
+
+func (t *TypeDef) isInput() bool {
+	return t.Kind == KindScalar || t.Kind == KindEnum || t.Kind == KindInputObject
+}
+
+func (t *TypeDef) hasEnumValue(name string) bool {
+	return slices.ContainsFunc(t.EnumValues, func(v *EnumValueDef) bool { return v.Name == name })
+}
+
+type FieldDef struct {
+	Name              string
+	Description       string
+	Args              []*InputValue
+	Type              *TypeRef
+	Deprecated        bool
+	DeprecationReason string
+}
+
+// InputValue is a field argument or an input object field
+type InputValue struct {
+	Name        string
+	Description string
+	Type        *TypeRef
+	Default     *Value
+}
+
+type EnumValueDef struct {
+	Name              string
+	Description       string
+	Deprecated        bool
+	DeprecationReason string
+}
+
+// ParseSchema parses a schema in the GraphQL schema definition language and validates
+// the type references
+func ParseSchema(src string) (*Schema, error) {
+	s := &Schema{Types: map[string]*TypeDef{}}
+	if err := s.parse(builtinSDL, true); err != nil {
+		return nil, fmt.Errorf("error parsing builtin types: %w", err)
+	}
+	if err := s.parse(src, false); err != nil {
+		return nil, err
+	}
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+type schemaParser struct {
+	*parser
+	schema  *Schema
+	builtin bool
+}
+
+func (s *Schema) parse(src string, builtin bool) error {
+	base, err := newParser(src)
+	if err != nil {
+		return err
+	}
+	p := &schemaParser{parser: base, schema: s, builtin: builtin}
+
+	var extensions []*TypeDef
+	for p.tok.kind != tokenEOF {
+		description, err := p.description()
+		if err != nil {
+			return err
+		}
+		if p.tok.kind != tokenName {
+			return p.errorf("unexpected %s", p.tok)
+		}
+
+		switch p.tok.value {
+		case "schema":
+			err = p.schemaDef()
+		case "directive":
+			err = p.directiveDef()
+		case "extend":
+			if err = p.advance(); err != nil {
+				return err
+			}
+			var def *TypeDef
+			if def, err = p.typeDef(""); err == nil {
+				extensions = append(extensions, def)
+			}
+		default:
+			var def *TypeDef
+			if def, err = p.typeDef(description); err == nil {
+				if _, ok := s.Types[def.Name]; ok {
+					return fmt.Errorf("type %s is defined more than once", def.Name)
+				}
+				s.Types[def.Name] = def
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, ext := range extensions {
+		def, ok := s.Types[ext.Name]
+		if !ok {
+			return fmt.Errorf("cannot extend type %s, it is not defined", ext.Name)
+		}
+		if def.Kind != ext.Kind {
+			return fmt.Errorf("cannot extend type %s, it is not of kind %s", ext.Name, ext.Kind)
+		}
+		def.Fields = append(def.Fields, ext.Fields...)
+		def.Interfaces = append(def.Interfaces, ext.Interfaces...)
+		def.PossibleTypes = append(def.PossibleTypes, ext.PossibleTypes...)
+		def.EnumValues = append(def.EnumValues, ext.EnumValues...)
+		def.InputFields = append(def.InputFields, ext.InputFields...)
+	}
+	return nil
+}
+
+func (p *schemaParser) description() (string, error) {
+	if p.tok.kind != tokenString {
+		return "", nil
+	}
+	description := p.tok.value
+	return description, p.advance()
+}
+
+// typeName parses a name being defined. Names starting with __ are reserved for introspection
+func (p *schemaParser) typeName() (string, error) {
+	if !p.builtin && p.tok.kind == tokenName && strings.HasPrefix(p.tok.value, "__") {
+		return "", p.errorf("name %s is invalid, names starting with __ are reserved for introspection", p.tok.value)
+	}
+	return p.name()
+}
+
+func (p *schemaParser) schemaDef() error {
+	if err := p.advance(); err != nil {
+		return err
+	}
+	if _, err := p.directives(false); err != nil {
+		return err
+	}
+	if err := p.expect("{"); err != nil {
+		return err
+	}
+	for !p.peek("}") {
+		operation, err := p.name()
+		if err != nil {
+			return err
+		}
+		if err := p.expect(":"); err != nil {
+			return err
+		}
+		typeName, err := p.name()
+		if err != nil {
+			return err
+		}
+		switch operation {
+		case "query":
+			p.schema.QueryType = typeName
+		case "mutation":
+			p.schema.MutationType = typeName
+		case "subscription":
+			return fmt.Errorf("subscriptions are not supported")
+		default:
+			return fmt.Errorf("invalid operation type %s in schema definition", operation)
+		}
+	}
+	return p.advance()
+}
+
+// directiveDef parses a directive definition. Custom directives are accepted in the schema
+// but they have no effect
+func (p *schemaParser) directiveDef() error {
+	if err := p.advance(); err != nil {
+		return err
+	}
+	if err := p.expect("@"); err != nil {
+		return err
+	}
+	if _, err := p.name(); err != nil {
+		return err
+	}
+	if p.peek("(") {
+		if _, err := p.inputValues("(", ")"); err != nil {
+			return err
+		}
+	}
+	if p.peekKeyword("repeatable") {
+		if err := p.advance(); err != nil {
+			return err
+		}
+	}
+	if err := p.expectKeyword("on"); err != nil {
+		return err
+	}
+	if _, err := p.skip("|"); err != nil {
+		return err
+	}
+	for {
+		if _, err := p.name(); err != nil {
+			return err
+		}
+		if ok, err := p.skip("|"); err != nil || !ok {
+			return err
+		}
+	}
+}
+
+func (p *schemaParser) typeDef(description string) (*TypeDef, error) {
+	def := &TypeDef{Description: description}
+	switch p.tok.value {
+	case "scalar":
+		def.Kind = KindScalar
+	case "type":
+		def.Kind = KindObject
+	case "interface":
+		def.Kind = KindInterface
+	case "union":
+		def.Kind = KindUnion
+	case "enum":
+		def.Kind = KindEnum
+	case "input":
+		def.Kind = KindInputObject
+	default:
+		return nil, p.errorf("unexpected %s", p.tok)
+	}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+
+	var err error
+	if def.Name, err = p.typeName(); err != nil {
+		return nil, err
+	}
+
+	if (def.Kind == KindObject || def.Kind == KindInterface) && p.peekKeyword("implements") {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if _, err := p.skip("&"); err != nil {
+			return nil, err
+		}
+		for {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			def.Interfaces = append(def.Interfaces, name)
+			if ok, err := p.skip("&"); err != nil {
+				return nil, err
+			} else if !ok {
+				break
+			}
+		}
+	}
+
+	if _, err := p.directives(false); err != nil {
+		return nil, err
+	}
+
+	switch def.Kind {
+	case KindObject, KindInterface:
+		if p.peek("{") {
+			def.Fields, err = p.fieldDefs()
+		}
+	case KindUnion:
+		if ok, err := p.skip("="); err != nil || !ok {
+			return def, err
+		}
+		if _, err := p.skip("|"); err != nil {
+			return nil, err
+		}
+		for {
+			name, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			def.PossibleTypes = append(def.PossibleTypes, name)
+			if ok, err := p.skip("|"); err != nil {
+				return nil, err
+			} else if !ok {
+				break
+			}
+		}
+	case KindEnum:
+		if p.peek("{") {
+			def.EnumValues, err = p.enumValues()
+		}
+	case KindInputObject:
+		if p.peek("{") {
+			def.InputFields, err = p.inputValues("{", "}")
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return def, nil
+}
+
+func (p *schemaParser) fieldDefs() ([]*FieldDef, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var ret []*FieldDef
+	for !p.peek("}") {
+		field := &FieldDef{}
+		var err error
+		if field.Description, err = p.description(); err != nil {
+			return nil, err
+		}
+		if field.Name, err = p.typeName(); err != nil {
+			return nil, err
+		}
+		if p.peek("(") {
+			if field.Args, err = p.inputValues("(", ")"); err != nil {
+				return nil, err
+			}
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if field.Type, err = p.typeRef(); err != nil {
+			return nil, err
+		}
+		directives, err := p.directives(false)
+		if err != nil {
+			return nil, err
+		}
+		field.Deprecated, field.DeprecationReason = deprecation(directives)
+		ret = append(ret, field)
+	}
+	return ret, p.advance()
+}
+
+func (p *schemaParser) inputValues(open, close string) ([]*InputValue, error) {
+	if err := p.expect(open); err != nil {
+		return nil, err
+	}
+	var ret []*InputValue
+	for !p.peek(close) {
+		value := &InputValue{}
+		var err error
+		if value.Description, err = p.description(); err != nil {
+			return nil, err
+		}
+		if value.Name, err = p.typeName(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(":"); err != nil {
+			return nil, err
+		}
+		if value.Type, err = p.typeRef(); err != nil {
+			return nil, err
+		}
+		if ok, err := p.skip("="); err != nil {
+			return nil, err
+		} else if ok {
+			if value.Default, err = p.value(false); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := p.directives(false); err != nil {
+			return nil, err
+		}
+		ret = append(ret, value)
+	}
+	return ret, p.advance()
+}
+
+func (p *schemaParser) enumValues() ([]*EnumValueDef, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var ret []*EnumValueDef
+	for !p.peek("}") {
+		value := &EnumValueDef{}
+		var err error
+		if value.Description, err = p.description(); err != nil {
+			return nil, err
+		}
+		if p.peekKeyword("true") || p.peekKeyword("false") || p.peekKeyword("null") {
+			return nil, p.errorf("enum value cannot be %s", p.tok.value)
+		}
+		if value.Name, err = p.typeName(); err != nil {
+			return nil, err
+		}
+		directives, err := p.directives(false)
+		if err != nil {
+			return nil, err
+		}
+		value.Deprecated, value.DeprecationReason = deprecation(directives)
+		ret = append(ret, value)
+	}
+	return ret, p.advance()
+}
+
+// deprecation returns the deprecation status from the @deprecated(reason:) directive
+func deprecation(directives []*Directive) (bool, string) {
+	for _, d := range directives {
+		if d.Name != "deprecated" {
+			continue
+		}
+		for _, arg := range d.Args {
+			if arg.Name == "reason" && arg.Value.Kind == StringValue {
+				return true, arg.Value.Raw
+			}
+		}
+		return true, DEFAULT_DEPRECATION_REASON
+	}
+	return false, ""
+}
+
+// validate checks that all type references are valid, after all the types are parsed
+func (s *Schema) validate() error {
+	if s.QueryType == "" {
+		s.QueryType = "Query"
+	}
+	if query, ok := s.Types[s.QueryType]; !ok || query.Kind != KindObject {
+		return fmt.Errorf("schema query type %s is not defined as an object type", s.QueryType)
+	}
+	if s.MutationType == "" {
+		if _, ok := s.Types["Mutation"]; ok {
+			s.MutationType = "Mutation"
+		}
+	} else if mutation, ok := s.Types[s.MutationType]; !ok || mutation.Kind != KindObject {
+		return fmt.Errorf("schema mutation type %s is not defined as an object type", s.MutationType)
+	}
+
+	// The implementing objects are added to the interface possible types in name order
+	for _, name := range slices.Sorted(maps.Keys(s.Types)) {
+		def := s.Types[name]
+		switch def.Kind {
+		case KindObject, KindInterface:
+			if err := s.validateFields(def); err != nil {
+				return err
+			}
+			for _, iface := range def.Interfaces {
+				if err := s.validateImplements(def, iface); err != nil {
+					return err
+				}
+			}
+		case KindUnion:
+			if len(def.PossibleTypes) == 0 {
+				return fmt.Errorf("union %s should have at least one member", name)
+			}
+			for _, member := range def.PossibleTypes {
+				if memberDef, ok := s.Types[member]; !ok || memberDef.Kind != KindObject {
+					return fmt.Errorf("union %s member %s is not an object type", name, member)
+				}
+			}
+		case KindEnum:
+			if len(def.EnumValues) == 0 {
+				return fmt.Errorf("enum %s should have at least one value", name)
+			}
+		case KindInputObject:
+			if len(def.InputFields) == 0 {
+				return fmt.Errorf("input %s should have at least one field", name)
+			}
+			if err := s.validateInputValues(name, def.InputFields); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateFields(def *TypeDef) error {
+	if len(def.Fields) == 0 {
+		return fmt.Errorf("type %s should have at least one field", def.Name)
+	}
+	def.fieldIndex = make(map[string]*FieldDef, len(def.Fields))
+	for _, field := range def.Fields {
+		if _, ok := def.fieldIndex[field.Name]; ok {
+			return fmt.Errorf("field %s.%s is defined more than once", def.Name, field.Name)
+		}
+		def.fieldIndex[field.Name] = field
+		fieldType, ok := s.Types[field.Type.namedType()]
+		if !ok {
+			return fmt.Errorf("field %s.%s has unknown type %s", def.Name, field.Name, field.Type.namedType())
+		}
+		if fieldType.Kind == KindInputObject {
+			return fmt.Errorf("field %s.%s cannot be of input type %s", def.Name, field.Name, fieldType.Name)
+		}
+		if err := s.validateInputValues(def.Name+"."+field.Name, field.Args); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Schema) validateImplements(def *TypeDef, ifaceName string) error {
+	iface, ok := s.Types[ifaceName]
+	if !ok || iface.Kind != KindInterface {
+		return fmt.Errorf("type %s implements %s, which is not an interface", def.Name, ifaceName)
+	}
+	for _, field := range iface.Fields {
+		if !slices.ContainsFunc(def.Fields, func(f *FieldDef) bool { return f.Name == field.Name }) {
+			return fmt.Errorf("type %s implements %s but does not have field %s", def.Name, ifaceName, field.Name)
+		}
+	}
+	if def.Kind == KindObject && !slices.Contains(iface.PossibleTypes, def.Name) {
+		iface.PossibleTypes = append(iface.PossibleTypes, def.Name)
+	}
+	return nil
+}
+
+func (s *Schema) validateInputValues(owner string, values []*InputValue) error {
+	seen := map[string]bool{}
+	for _, value := range values {
+		if seen[value.Name] {
+			return fmt.Errorf("%s: %s is defined more than once", owner, value.Name)
+		}
+		seen[value.Name] = true
+		valueType, ok := s.Types[value.Type.namedType()]
+		if !ok {
+			return fmt.Errorf("%s: %s has unknown type %s", owner, value.Name, value.Type.namedType())
+		}
+		if !valueType.isInput() {
+			return fmt.Errorf("%s: %s cannot be of output type %s", owner, value.Name, valueType.Name)
+		}
+	}
+	return nil
+}
+
+// builtinSDL has the builtin scalars and the types used for introspection queries
+const builtinSDL = `
+"The Int scalar type represents non-fractional signed whole numeric values between -(2^31) and 2^31 - 1"
+scalar Int
+"The Float scalar type represents signed double-precision fractional values"
+scalar Float
+"The String scalar type represents textual data, as UTF-8 character sequences"
+scalar String
+"The Boolean scalar type represents true or false"
+scalar Boolean
+"The ID scalar type represents a unique identifier, serialized as a String"
+scalar ID
+
+type __Schema {
+  description: String
+  types: [__Type!]!
+  queryType: __Type!
+  mutationType: __Type
+  subscriptionType: __Type
+  directives: [__Directive!]!
+}
+
+type __Type {
+  kind: __TypeKind!
+  name: String
+  description: String
+  specifiedByURL: String
+  fields(includeDeprecated: Boolean = false): [__Field!]
+  interfaces: [__Type!]
+  possibleTypes: [__Type!]
+  enumValues(includeDeprecated: Boolean = false): [__EnumValue!]
+  inputFields(includeDeprecated: Boolean = false): [__InputValue!]
+  ofType: __Type
+}
+
+type __Field {
+  name: String!
+  description: String
+  args(includeDeprecated: Boolean = false): [__InputValue!]!
+  type: __Type!
+  isDeprecated: Boolean!
+  deprecationReason: String
+}
+
+type __InputValue {
+  name: String!
+  description: String
+  type: __Type!
+  defaultValue: String
+  isDeprecated: Boolean!
+  deprecationReason: String
+}
+
+type __EnumValue {
+  name: String!
+  description: String
+  isDeprecated: Boolean!
+  deprecationReason: String
+}
+
+type __Directive {
+  name: String!
+  description: String
+  locations: [__DirectiveLocation!]!
+  args(includeDeprecated: Boolean = false): [__InputValue!]!
+  isRepeatable: Boolean!
+}
+
+enum __TypeKind {
+  SCALAR
+  OBJECT
+  INTERFACE
+  UNION
+  ENUM
+  INPUT_OBJECT
+  LIST
+  NON_NULL
+}
+
+enum __DirectiveLocation {
+  QUERY
+  MUTATION
+  SUBSCRIPTION
+  FIELD
+  FRAGMENT_DEFINITION
+  FRAGMENT_SPREAD
+  INLINE_FRAGMENT
+  VARIABLE_DEFINITION
+  SCHEMA
+  SCALAR
+  OBJECT
+  FIELD_DEFINITION
+  ARGUMENT_DEFINITION
+  INTERFACE
+  UNION
+  ENUM
+  ENUM_VALUE
+  INPUT_OBJECT
+  INPUT_FIELD_DEFINITION
+}
+`
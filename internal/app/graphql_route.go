@@ -0,0 +1,239 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openrundev/openrun/internal/app/action"
+	"github.com/openrundev/openrun/internal/app/apptype"
+	"github.com/openrundev/openrun/internal/app/graphql"
+	"github.com/openrundev/openrun/internal/app/starlark_type"
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+const graphqlMaxBodySize = 1024 * 1024
+
+// graphqlRoute is a GraphQL endpoint, with the field resolvers implemented as starlark functions
+type graphqlRoute struct {
+	schema        *graphql.Schema
+	resolvers     map[string]starlark.Callable // keyed by "Type.field"
+	introspection bool
+	maxBatch      int
+	maxDepth      int
+}
+
+func (a *App) addGraphQLRoute(router *chi.Mux, routeDef *starlarkstruct.Struct) error {
+	var err error
+	var pathStr, schemaFile string
+	if pathStr, err = apptype.GetStringAttr(routeDef, "path"); err != nil {
+		return err
+	}
+	if schemaFile, err = apptype.GetStringAttr(routeDef, "schema"); err != nil {
+		return err
+	}
+	route := &graphqlRoute{resolvers: map[string]starlark.Callable{}}
+	if route.introspection, err = apptype.GetBoolAttr(routeDef, "introspection"); err != nil {
+		return err
+	}
+	maxBatch, err := apptype.GetIntAttr(routeDef, "max_batch")
+	if err != nil {
+		return err
+	}
+	maxDepth, err := apptype.GetIntAttr(routeDef, "max_depth")
+	if err != nil {
+		return err
+	}
+	route.maxBatch, route.maxDepth = int(maxBatch), int(maxDepth)
+
+	schemaData, err := a.sourceFS.ReadFile(schemaFile)
+	if err != nil {
+		return fmt.Errorf("graphql %s: error reading schema file %s: %w", pathStr, schemaFile, err)
+	}
+	if route.schema, err = graphql.ParseSchema(string(schemaData)); err != nil {
+		return fmt.Errorf("graphql %s: error parsing schema file %s: %w", pathStr, schemaFile, err)
+	}
+
+	resolversAttr, err := routeDef.Attr("resolvers")
+	if err != nil {
+		return err
+	}
+	for _, item := range resolversAttr.(*starlark.Dict).Items() {
+		key := string(item[0].(starlark.String))
+		typeName, fieldName, _ := strings.Cut(key, ".")
+		typeDef, ok := route.schema.Types[typeName]
+		if !ok || typeDef.Kind != graphql.KindObject || strings.HasPrefix(typeName, "__") {
+			return fmt.Errorf("graphql %s: resolver %s, %s is not an object type in the schema", pathStr, key, typeName)
+		}
+		if typeDef.Field(fieldName) == nil {
+			return fmt.Errorf("graphql %s: resolver %s, type %s has no field %s", pathStr, key, typeName, fieldName)
+		}
+		route.resolvers[key] = item[1].(starlark.Callable)
+	}
+
+	// The root fields have no parent value to read from, so they require a resolver
+	for _, root := range []string{route.schema.QueryType, route.schema.MutationType} {
+		if root == "" {
+			continue
+		}
+		for _, field := range route.schema.Types[root].Fields {
+			if _, ok := route.resolvers[root+"."+field.Name]; !ok {
+				return fmt.Errorf("graphql %s: no resolver defined for %s.%s", pathStr, root, field.Name)
+			}
+		}
+	}
+
+	handlerFunc := a.graphqlHandler(route)
+	router.Get(pathStr, handlerFunc)
+	router.Post(pathStr, handlerFunc)
+	return nil
+}
+
+// graphqlHandler returns the handler for the GraphQL endpoint. All the resolvers for a request,
+// including all the queries in a batch, are run on one starlark thread
+func (a *App) graphqlHandler(route *graphqlRoute) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requests, isBatch, err := readGraphQLRequests(w, r, route.maxBatch)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		thread := &starlark.Thread{
+			Name:  a.Path,
+			Print: starlarkThreadPrint,
+		}
+		thread.SetLocal(types.TL_CONTEXT, r.Context())
+		if a.containerHandler != nil {
+			thread.SetLocal(types.TL_CONTAINER_HANDLER, a.containerHandler)
+			thread.SetLocal(types.TL_CONTAINER_URL, a.containerHandler.GetProxyUrl())
+		}
+		thread.SetLocal(types.TL_APP_URL, a.appUrlLocal)
+		requestData, scratch := a.newRequestData(r, false)
+		defer scratch.release()
+
+		executor := &graphql.Executor{
+			Schema:        route.schema,
+			Resolvers:     make(map[string]graphql.Resolver, len(route.resolvers)),
+			Introspection: route.introspection,
+			MaxDepth:      route.maxDepth,
+		}
+		for key, resolver := range route.resolvers {
+			executor.Resolvers[key] = func(parent any, args map[string]any) (any, error) {
+				return a.callResolver(r, thread, resolver, parent, args, requestData)
+			}
+		}
+
+		responses := make([]*graphql.Response, 0, len(requests))
+		for _, req := range requests {
+			// Mutations are not allowed over GET, to avoid updates from cross site links
+			responses = append(responses, executor.Execute(req, r.Method == http.MethodPost))
+		}
+
+		if err := action.RunDeferredCleanup(thread); err != nil {
+			a.Error().Err(err).Msg("error cleaning up plugins")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var ret any = responses[0]
+		if isBatch {
+			ret = responses
+		}
+		respHeader := w.Header()
+		respHeader["Content-Type"] = CONTENT_TYPE_JSON
+		respHeader["Server"] = SERVER_NAME
+		if err := json.NewEncoder(w).Encode(ret); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}
+}
+
+// callResolver calls the starlark resolver as resolver(parent, args, req). A plugin failure
+// which is not checked by the resolver is returned as a field error
+func (a *App) callResolver(r *http.Request, thread *starlark.Thread, resolver starlark.Callable, parent any, args map[string]any, requestData starlark_type.Request) (any, error) {
+	parentValue, err := starlark_type.MarshalStarlark(parent)
+	if err != nil {
+		return nil, fmt.Errorf("error converting parent value: %w", err)
+	}
+	argsValue, err := starlark_type.MarshalStarlark(args)
+	if err != nil {
+		return nil, fmt.Errorf("error converting arguments: %w", err)
+	}
+
+	ret, err := a.callStarlarkHandler(r, thread, resolver, starlark.Tuple{parentValue, argsValue, requestData})
+	if pluginErr := thread.Local(types.TL_PLUGIN_API_FAILED_ERROR); pluginErr != nil {
+		// Reset the failure, so that the other resolvers can call plugins
+		thread.SetLocal(types.TL_PLUGIN_API_FAILED_ERROR, nil)
+		if err == nil {
+			err = pluginErr.(error)
+		}
+	}
+	if err != nil {
+		a.Error().Err(err).Str("resolver", resolver.Name()).Msg("error calling graphql resolver")
+		return nil, err
+	}
+	return starlark_type.UnmarshalStarlark(ret)
+}
+
+// readGraphQLRequests reads the requests from the query params for GET. For POST, the body can
+// be a JSON request, a JSON list of requests for batching or the query with application/graphql
+func readGraphQLRequests(w http.ResponseWriter, r *http.Request, maxBatch int) ([]*graphql.Request, bool, error) {
+	if r.Method == http.MethodGet {
+		query := r.URL.Query()
+		req := &graphql.Request{Query: query.Get("query"), OperationName: query.Get("operationName")}
+		if variables := query.Get("variables"); variables != "" {
+			if err := json.Unmarshal([]byte(variables), &req.Variables); err != nil {
+				return nil, false, fmt.Errorf("invalid variables: %w", err)
+			}
+		}
+		if req.Query == "" {
+			return nil, false, fmt.Errorf("query param is required")
+		}
+		return []*graphql.Request{req}, false, nil
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, graphqlMaxBodySize))
+	if err != nil {
+		return nil, false, fmt.Errorf("error reading request body: %w", err)
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType == "application/graphql" {
+		return []*graphql.Request{{Query: string(body)}}, false, nil
+	}
+
+	var requests []*graphql.Request
+	isBatch := bytes.HasPrefix(bytes.TrimSpace(body), []byte("["))
+	if isBatch {
+		if err := json.Unmarshal(body, &requests); err != nil {
+			return nil, false, fmt.Errorf("invalid request body: %w", err)
+		}
+		if maxBatch <= 0 {
+			return nil, false, fmt.Errorf("query batching is disabled")
+		}
+		if len(requests) == 0 || len(requests) > maxBatch {
+			return nil, false, fmt.Errorf("batch should have between 1 and %d queries, got %d", maxBatch, len(requests))
+		}
+	} else {
+		req := &graphql.Request{}
+		if err := json.Unmarshal(body, req); err != nil {
+			return nil, false, fmt.Errorf("invalid request body: %w", err)
+		}
+		requests = []*graphql.Request{req}
+	}
+	for _, req := range requests {
+		if req == nil || req.Query == "" {
+			return nil, false, fmt.Errorf("query is required")
+		}
+	}
+	return requests, isBatch, nil
+}
@@ -7,8 +7,11 @@ import (
 	"bufio"
 	"bytes"
 	"cmp"
+	"context"
 	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"io"
 	"net/http"
 	"net/url"
 	"path"
@@ -18,6 +21,7 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/openrundev/openrun/internal/app/action"
+	"github.com/openrundev/openrun/internal/app/appfs"
 	"github.com/openrundev/openrun/internal/app/apptype"
 	"github.com/openrundev/openrun/internal/app/starlark_type"
 	"github.com/openrundev/openrun/internal/rbac"
@@ -148,19 +152,63 @@ var encoderPool = sync.Pool{
 	},
 }
 
+// streamResponseKey is the key set in the converted plugin response for streaming responses,
+// pre-boxed for the dict lookup
+var streamResponseKey starlark.Value = starlark.String("is_stream")
+
+// directJSON checks whether the handler response can be encoded to JSON directly from the
+// starlark value. Other values, like stream responses, need the conversion to Go values
+func directJSON(ret starlark.Value) bool {
+	switch v := ret.(type) {
+	case *starlark.List, starlark.Tuple:
+		return true
+	case *starlark.Dict:
+		_, found, _ := v.Get(streamResponseKey)
+		return !found
+	}
+	return false
+}
+
 // starlarkThreadPrint is the print handler for starlark threads, shared across
 // requests so each request does not allocate a fresh closure
 func starlarkThreadPrint(_ *starlark.Thread, msg string) {
 	fmt.Println(msg)
 }
 
+// SetPrintHandler sets a handler which gets the print output from the request threads, in
+// addition to the output being written to stdout. The handler is set before the app serves
+// requests, it is bound once so each request does not allocate a closure
+func (a *App) SetPrintHandler(handler func(msg string)) {
+	a.threadPrint = func(thread *starlark.Thread, msg string) {
+		starlarkThreadPrint(thread, msg)
+		handler(msg)
+	}
+}
+
 func (a *App) createHandlerFunc(fullHtml, fragment string, handler starlark.Callable, rtype string) http.HandlerFunc {
 	hasArgs := handler != nil && !strings.HasSuffix(handler.Name(), "_no_args")
 	rtype = strings.ToUpper(rtype)
+	handlerName := ""
+	if handler != nil {
+		handlerName = handler.Name()
+	}
 	goHandler := func(w http.ResponseWriter, r *http.Request) {
+		if telemetry.Enabled() {
+			// The route span is the parent for the handler, plugin and template spans
+			ctx, span := telemetry.StartSpan(r.Context(), "openrun.app.route",
+				attribute.String("openrun.app.id", string(a.Id)),
+				attribute.String("openrun.app.path", a.Path),
+				attribute.String("http.route", routePattern(r)),
+				attribute.String("openrun.route.type", rtype),
+				attribute.String("openrun.handler", handlerName),
+			)
+			defer span.End()
+			r = r.WithContext(ctx)
+		}
+
 		thread := &starlark.Thread{
 			Name:  a.Path,
-			Print: starlarkThreadPrint,
+			Print: a.threadPrint,
 		}
 
 		// Save the request context in the starlark thread local
@@ -173,6 +221,14 @@ func (a *App) createHandlerFunc(fullHtml, fragment string, handler starlark.Call
 		// allocate to box the string on every request
 		thread.SetLocal(types.TL_APP_URL, a.appUrlLocal)
 
+		var profiler *handlerProfiler
+		if session := a.profiles.recorder(a.Id); session != nil {
+			profiler = startHandlerProfile(thread, handlerName)
+			defer func() {
+				session.add(profiler.finish(r.Method, a.relativeRequestPath(r)))
+			}()
+		}
+
 		header := r.Header
 		isHtmxRequest := types.GetHTTPHeader(header, "Hx-Request") == "true" &&
 			!(types.GetHTTPHeader(header, "Hx-Boosted") == "true") //nolint:staticcheck
@@ -187,80 +243,14 @@ func (a *App) createHandlerFunc(fullHtml, fragment string, handler starlark.Call
 
 		var requestData starlark_type.Request
 		if hasArgs || rtype == apptype.HTML_TYPE {
-			// effectivePath keeps _cl_ test URL directives in app-absolute URLs
-			appPath := a.effectivePath(r.Context())
-			if appPath == "/" {
-				appPath = ""
-			}
-			pagePath := r.URL.Path
-			if pagePath == "/" {
-				pagePath = ""
-			}
-			appUrl := a.getRequestUrl(r) + appPath
-
-			// The sanitized req.Headers view (clone the incoming headers, strip
-			// spoofed openrun headers, set the trusted ones) is built lazily:
-			// most handlers never read headers, and cloning the whole map per
-			// request was a top allocation source. The result is memoized for
-			// handlers that read it more than once (one request, one goroutine).
-			var cachedHeaders http.Header
-			headersFunc := func() http.Header {
-				if cachedHeaders == nil {
-					h := header.Clone()
-					deleteOpenRunHeaders(h)
-					setOpenRunHeaders(h, r.Context())
-					cachedHeaders = h
-				}
-				return cachedHeaders
-			}
-
-			requestData = starlark_type.Request{
-				AppName:        a.Name,
-				AppPath:        appPath,
-				AppUrl:         appUrl,
-				PagePath:       pagePath,
-				PageUrl:        appUrl + pagePath,
-				Method:         r.Method,
-				IsDev:          a.IsDev,
-				IsPartial:      isHtmxRequest,
-				PushEvents:     a.codeConfig.Routing.PushEvents,
-				HtmxVersion:    a.codeConfig.Htmx.Version,
-				HeadersFunc:    headersFunc,
-				RemoteIP:       a.getRemoteIP(r),
-				UserId:         system.GetContextUserId(r.Context()),
-				UserSubject:    system.GetContextUserSubject(r.Context()),
-				UserEmail:      system.GetContextUserEmail(r.Context()),
-				CustomPerms:    system.GetCustomPerms(r.Context()),
-				AppRBACEnabled: rbac.AppRBACActive(r.Context()),
-			}
-
-			// Only allocate the params map when the route actually has URL
-			// params (most do not)
-			if chiContext := chi.RouteContext(r.Context()); chiContext != nil && len(chiContext.URLParams.Keys) > 0 {
-				params := make(map[string]string, len(chiContext.URLParams.Keys))
-				for i, k := range chiContext.URLParams.Keys {
-					params[k] = chiContext.URLParams.Values[i]
-				}
-				requestData.UrlParams = params
-			}
-
-			// ParseForm parses the URL query into r.Form (and the body into
-			// r.PostForm for non-GET). Reuse r.Form for the query instead of
-			// calling r.URL.Query(), which would parse the query string a second
-			// time.
-			r.ParseForm() //nolint:errcheck // ignore error if no form data is passed
-			requestData.Form = r.Form
-			requestData.PostForm = r.PostForm
-			if r.Method == http.MethodGet {
-				// For GET there is no body, so r.Form holds exactly the query
-				requestData.Query = r.Form
-			} else {
-				requestData.Query = r.URL.Query()
-			}
+			var scratch *requestScratch
+			requestData, scratch = a.newRequestData(r, isHtmxRequest)
+			defer scratch.release()
 		}
 
 		var deferredCleanup func() error
 		var handlerResponse any = map[string]any{} // no handler means empty Data map is passed into template
+		var jsonEncoder *starlark_type.JSONEncoder // set if the JSON response was encoded from the starlark value
 		if handler != nil {
 			deferredCleanup = func() error {
 				// Check for any deferred cleanups
@@ -311,6 +301,7 @@ func (a *App) createHandlerFunc(fullHtml, fragment string, handler starlark.Call
 			} else {
 				ret, err = a.callStarlarkHandler(r, thread, handler, nil)
 			}
+			profiler.handlerDone()
 
 			if err == nil {
 				pluginErrLocal := thread.Local(types.TL_PLUGIN_API_FAILED_ERROR)
@@ -389,7 +380,17 @@ func (a *App) createHandlerFunc(fullHtml, fragment string, handler starlark.Call
 				return
 			}
 
-			if ret != nil {
+			if ret != nil && rtype == apptype.JSON && directJSON(ret) {
+				// JSON responses are encoded directly, without the allocations for converting
+				// the response to Go values
+				jsonEncoder = starlark_type.GetJSONEncoder()
+				defer starlark_type.PutJSONEncoder(jsonEncoder)
+				if err = jsonEncoder.Encode(ret); err != nil {
+					a.Error().Err(err).Msg("error converting response")
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+					return
+				}
+			} else if ret != nil {
 				// Response from handler, or if handler failed, response from error_handler if defined
 				handlerResponse, err = starlark_type.UnmarshalStarlark(ret)
 				if err != nil {
@@ -412,13 +413,19 @@ func (a *App) createHandlerFunc(fullHtml, fragment string, handler starlark.Call
 
 		streamResponse, ok := handlerResponse.(map[string]any)
 		if ok && streamResponse["is_stream"] == true {
-			a.handleStreamResponse(w, r, rtype, cmp.Or(fragment, fullHtml), streamResponse)
+			a.handleStreamResponse(w, r, thread, rtype, cmp.Or(fragment, fullHtml), streamResponse)
 			return
 		}
 
 		if rtype == apptype.JSON { //nolint:staticcheck
 			// If the route type is JSON, then return the handler response as JSON
 			respHeader["Content-Type"] = CONTENT_TYPE_JSON
+			if jsonEncoder != nil {
+				if _, err := w.Write(jsonEncoder.Bytes()); err != nil {
+					http.Error(w, err.Error(), http.StatusInternalServerError)
+				}
+				return
+			}
 
 			encoder := encoderPool.Get().(*pooled)
 			encoder.buf.Reset()
@@ -449,10 +456,16 @@ func (a *App) createHandlerFunc(fullHtml, fragment string, handler starlark.Call
 		if respHeader.Get("Content-Type") == "" {
 			respHeader["Content-Type"] = CONTENT_TYPE_HTML
 		}
+		// The output is rendered into a buffer, to compute the ETag before the response is written.
+		// If the template has flush points, the output is streamed from the first flush point
+		buf := renderBufferPool.Get().(*bytes.Buffer)
+		buf.Reset()
+		defer putRenderBuffer(buf)
+		out := newFlushWriter(w, buf)
 		var err error
 		if isHtmxRequest && fragment != "" {
 			a.Trace().Msgf("Rendering block %s", fragment)
-			err = a.executeTemplateTraced(r, w, fullHtml, fragment, requestData)
+			err = a.executeTemplateTraced(r, out, fullHtml, fragment, requestData)
 		} else {
 			referrer := types.GetHTTPHeader(header, "Referer")
 			isUpdateRequest := r.Method != http.MethodGet && r.Method != http.MethodHead && r.Method != http.MethodOptions
@@ -467,18 +480,196 @@ func (a *App) createHandlerFunc(fullHtml, fragment string, handler starlark.Call
 			}
 
 			a.Trace().Msgf("Rendering page %s", fullHtml)
-			err = a.executeTemplateTraced(r, w, fullHtml, "", requestData)
+			err = a.executeTemplateTraced(r, out, fullHtml, "", requestData)
+		}
+		if err == nil {
+			err = out.finish()
 		}
 
 		if err != nil {
+			if out.streaming() {
+				// Part of the page was sent, abort the connection so that the truncated page is
+				// not seen as complete
+				a.Error().Err(err).Msgf("error rendering streamed page %s", fullHtml)
+				panic(http.ErrAbortHandler)
+			}
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if !out.streaming() {
+			page := buf.Bytes()
+			if a.minifier != nil && strings.HasPrefix(respHeader.Get("Content-Type"), "text/html") {
+				page = a.minifier.minify(fullHtml+"#"+fragment, page)
+			}
+			writeRendered(w, r, page)
+		}
 	}
 	return goHandler
 }
 
-func (a *App) callStarlarkHandler(r *http.Request, thread *starlark.Thread, handler starlark.Callable, args starlark.Tuple) (starlark.Value, error) {
+// maxPooledRenderBuffer is the largest buffer returned to the pool, so that one large page does
+// not keep the memory allocated
+const maxPooledRenderBuffer = 1024 * 1024
+
+var renderBufferPool = sync.Pool{
+	New: func() any {
+		return bytes.NewBuffer(make([]byte, 0, 16*1024))
+	},
+}
+
+func putRenderBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledRenderBuffer {
+		renderBufferPool.Put(buf)
+	}
+}
+
+// writeRendered writes the rendered page, with a weak ETag for GET requests. The ETag is weak
+// since it is computed on the output, which can change without the page content changing (like
+// for a different CSRF token). A 304 response is sent if the ETag matches If-None-Match
+func writeRendered(w http.ResponseWriter, r *http.Request, data []byte) {
+	if r.Method == http.MethodGet || r.Method == http.MethodHead {
+		hash := fnv.New64a()
+		hash.Write(data) //nolint:errcheck
+		etag := fmt.Sprintf(`W/"%x"`, hash.Sum64())
+		w.Header().Set("ETag", etag)
+		if appfs.CheckNotModified(w, r, etag, time.Time{}) {
+			return
+		}
+	}
+	w.Write(data) //nolint:errcheck
+}
+
+// maxPooledRequestMap is the largest header or URL params map returned to the pool, so that one
+// request with many headers does not keep the memory allocated
+const maxPooledRequestMap = 64
+
+// requestScratch holds the maps and the header func used by newRequestData, reused across requests
+// through requestScratchPool. The values are passed to starlark through MarshalStarlark, which
+// copies them, and to the templates which are rendered before the request completes. release is
+// called once the request is done, nothing can reference the maps after that
+type requestScratch struct {
+	header      http.Header // the incoming request headers
+	ctx         context.Context
+	headers     http.Header // the sanitized view, built on first use
+	built       bool
+	headersFunc func() http.Header // bound once, so that setting HeadersFunc per request does not allocate
+	params      map[string]string
+}
+
+var requestScratchPool = sync.Pool{
+	New: func() any {
+		s := &requestScratch{
+			headers: make(http.Header),
+			params:  make(map[string]string),
+		}
+		s.headersFunc = s.sanitizedHeaders
+		return s
+	},
+}
+
+// sanitizedHeaders builds the req.Headers view: the incoming headers, with spoofed openrun headers
+// removed and the trusted ones set. The view shares the value slices with the incoming headers,
+// which is fine since the values are only read and the openrun headers are replaced, not appended
+// to. The result is memoized for handlers that read it more than once (one request, one goroutine)
+func (s *requestScratch) sanitizedHeaders() http.Header {
+	if !s.built {
+		for key, values := range s.header {
+			s.headers[key] = values
+		}
+		deleteOpenRunHeaders(s.headers)
+		setOpenRunHeaders(s.headers, s.ctx)
+		s.built = true
+	}
+	return s.headers
+}
+
+func (s *requestScratch) release() {
+	if len(s.headers) > maxPooledRequestMap || len(s.params) > maxPooledRequestMap {
+		return
+	}
+	clear(s.headers)
+	clear(s.params)
+	s.header = nil
+	s.ctx = nil
+	s.built = false
+	requestScratchPool.Put(s)
+}
+
+// newRequestData creates the request value passed to the starlark handlers. The returned scratch
+// has to be released after the request is done, the request value should not be used after that
+func (a *App) newRequestData(r *http.Request, isHtmxRequest bool) (starlark_type.Request, *requestScratch) {
+	// effectivePath keeps _cl_ test URL directives in app-absolute URLs
+	appPath := a.effectivePath(r.Context())
+	if appPath == "/" {
+		appPath = ""
+	}
+	pagePath := r.URL.Path
+	if pagePath == "/" {
+		pagePath = ""
+	}
+	appUrl := a.getRequestUrl(r) + appPath
+
+	// The sanitized req.Headers view is built lazily: most handlers never
+	// read headers, and cloning the whole map per request was a top
+	// allocation source. The header map is reused from the pool.
+	scratch := requestScratchPool.Get().(*requestScratch)
+	scratch.header = r.Header
+	scratch.ctx = r.Context()
+
+	requestData := starlark_type.Request{
+		AppName:        a.Name,
+		AppPath:        appPath,
+		AppUrl:         appUrl,
+		PagePath:       pagePath,
+		PageUrl:        appUrl + pagePath,
+		Method:         r.Method,
+		IsDev:          a.IsDev,
+		IsPartial:      isHtmxRequest,
+		PushEvents:     a.codeConfig.Routing.PushEvents,
+		HtmxVersion:    a.codeConfig.Htmx.Version,
+		HeadersFunc:    scratch.headersFunc,
+		RemoteIP:       a.getRemoteIP(r),
+		UserId:         system.GetContextUserId(r.Context()),
+		UserSubject:    system.GetContextUserSubject(r.Context()),
+		UserEmail:      system.GetContextUserEmail(r.Context()),
+		CustomPerms:    system.GetCustomPerms(r.Context()),
+		AppRBACEnabled: rbac.AppRBACActive(r.Context()),
+		UserGroups:     system.GetContextGroups(r.Context()),
+		Env:            a.appEnv,
+	}
+
+	// Only set the params map when the route actually has URL
+	// params (most do not)
+	if chiContext := chi.RouteContext(r.Context()); chiContext != nil && len(chiContext.URLParams.Keys) > 0 {
+		for i, k := range chiContext.URLParams.Keys {
+			scratch.params[k] = chiContext.URLParams.Values[i]
+		}
+		requestData.UrlParams = scratch.params
+	}
+
+	// ParseForm parses the URL query into r.Form (and the body into
+	// r.PostForm for non-GET). Reuse r.Form for the query instead of
+	// calling r.URL.Query(), which would parse the query string a second
+	// time.
+	r.ParseForm() //nolint:errcheck // ignore error if no form data is passed
+	requestData.Form = r.Form
+	requestData.PostForm = r.PostForm
+	if r.Method == http.MethodGet {
+		// For GET there is no body, so r.Form holds exactly the query
+		requestData.Query = r.Form
+	} else {
+		requestData.Query = r.URL.Query()
+	}
+	return requestData, scratch
+}
+
+func (a *App) callStarlarkHandler(r *http.Request, thread *starlark.Thread, handler starlark.Callable, args starlark.Tuple) (ret starlark.Value, err error) {
+	if telemetry.MetricsEnabled() {
+		start := time.Now()
+		defer func() {
+			telemetry.RecordStarlarkHandler(r.Context(), handler.Name(), start, err, a.telemetryIdentityAttrs...)
+		}()
+	}
 	if !telemetry.Enabled() {
 		return starlark.Call(thread, handler, args, nil)
 	}
@@ -491,12 +682,12 @@ func (a *App) callStarlarkHandler(r *http.Request, thread *starlark.Thread, hand
 	defer span.End()
 	defer pushThreadContext(thread, ctx, r.Context())()
 
-	ret, err := starlark.Call(thread, handler, args, nil)
+	ret, err = starlark.Call(thread, handler, args, nil)
 	telemetry.RecordError(span, err)
 	return ret, err
 }
 
-func (a *App) executeTemplateTraced(r *http.Request, w http.ResponseWriter, fullHtml, fragment string, data any) error {
+func (a *App) executeTemplateTraced(r *http.Request, w io.Writer, fullHtml, fragment string, data any) error {
 	if !telemetry.Enabled() {
 		return a.executeTemplate(w, fullHtml, fragment, data)
 	}
@@ -610,6 +801,24 @@ func (a *App) handleResponse(retStruct *starlarkstruct.Struct, r *http.Request,
 		return true, nil
 	}
 
+	if strings.ToUpper(responseRtype) == apptype.JSON && directJSON(data) {
+		jsonEncoder := starlark_type.GetJSONEncoder()
+		defer starlark_type.PutJSONEncoder(jsonEncoder)
+		if err := jsonEncoder.Encode(data); err != nil {
+			a.Error().Err(err).Msg("error converting response")
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return true, nil
+		}
+		if deferredCleanup != nil && deferredCleanup() != nil {
+			return true, nil
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(jsonEncoder.Bytes()); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return true, nil
+	}
+
 	templateValue, err := starlark_type.UnmarshalStarlark(data)
 	if err != nil {
 		a.Error().Err(err).Msg("error converting response")
@@ -787,7 +996,7 @@ func (a *App) getRemoteIP(r *http.Request) string {
 	return system.GetClientIP(r, a.serverConfig.Security.TrustedProxies)
 }
 
-func (a *App) handleStreamResponse(w http.ResponseWriter, r *http.Request, rtype string, fragment string, streamResponse map[string]any) {
+func (a *App) handleStreamResponse(w http.ResponseWriter, r *http.Request, thread *starlark.Thread, rtype string, fragment string, streamResponse map[string]any) {
 	// Stream the response to the client
 	if rtype == apptype.JSON { //nolint:staticcheck
 		w.Header().Set("Content-Type", "application/json")
@@ -816,7 +1025,19 @@ func (a *App) handleStreamResponse(w http.ResponseWriter, r *http.Request, rtype
 	}
 
 	w.WriteHeader(http.StatusOK)
+	// The request context is canceled when the client disconnects. Breaking out of the loop stops
+	// the iteration, the stream producers use the request context for the underlying calls, so
+	// that they are canceled promptly, instead of generating output till the sequence ends.
+	// The starlark thread is canceled also, so that a generator computing the next value in
+	// starlark code is interrupted instead of running till the value is ready
+	ctx := r.Context()
+	stop := context.AfterFunc(ctx, func() { thread.Cancel("client disconnected") })
+	defer stop()
 	for v := range retSeq {
+		if ctx.Err() != nil {
+			a.Debug().Str("path", r.URL.Path).Msg("client disconnected, stopping stream response")
+			return
+		}
 		if rtype == apptype.TEXT || (rtype == apptype.HTML_TYPE && (fragment == "" || fragment == "-")) {
 			vStr, ok := v.(string)
 			if !ok {
@@ -851,6 +1072,9 @@ func (a *App) handleStreamResponse(w http.ResponseWriter, r *http.Request, rtype
 
 		flusher.Flush()
 	}
+	if ctx.Err() != nil {
+		return
+	}
 
 	if rtype == apptype.HTML_TYPE {
 		w.Write([]byte("<!--cl_stream_end-->\n\n")) //nolint:errcheck
@@ -0,0 +1,147 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/app/action"
+	"github.com/openrundev/openrun/internal/app/starlark_type"
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+)
+
+// RunJob runs one attempt of a background job. funcName is a top level function in the app
+// definition, args is the JSON encoded argument list (positional) or dict (keyword). The function
+// return value is returned JSON encoded. The context is expected to carry the user and app ids
+func (a *App) RunJob(ctx context.Context, funcName string, args string) (string, error) {
+	a.initMutex.Lock()
+	fnValue, ok := a.globals[funcName]
+	a.initMutex.Unlock()
+	if !ok {
+		return "", fmt.Errorf("job function %s not found in app %s", funcName, a.Path)
+	}
+	fn, ok := fnValue.(starlark.Callable)
+	if !ok {
+		return "", fmt.Errorf("job function %s is not callable, got %s", funcName, fnValue.Type())
+	}
+
+	posArgs, kwargs, err := decodeJobArgs(args)
+	if err != nil {
+		return "", err
+	}
+
+	thread := &starlark.Thread{
+		Name:  a.Path,
+		Print: starlarkThreadPrint,
+	}
+	thread.SetLocal(types.TL_CONTEXT, ctx)
+	if a.containerHandler != nil {
+		thread.SetLocal(types.TL_CONTAINER_HANDLER, a.containerHandler)
+		thread.SetLocal(types.TL_CONTAINER_URL, a.containerHandler.GetProxyUrl())
+	}
+	thread.SetLocal(types.TL_APP_URL, a.appUrlLocal)
+	if ctx.Done() != nil {
+		stop := context.AfterFunc(ctx, func() { thread.Cancel(ctx.Err().Error()) })
+		defer stop()
+	}
+
+	ret, err := starlark.Call(thread, fn, posArgs, kwargs)
+	if cleanupErr := action.RunDeferredCleanup(thread); cleanupErr != nil {
+		a.Error().Err(cleanupErr).Msg("error cleaning up plugins after job")
+	}
+	if err == nil {
+		if pluginErr := thread.Local(types.TL_PLUGIN_API_FAILED_ERROR); pluginErr != nil {
+			err = pluginErr.(error)
+		}
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if ret == nil || ret == starlark.None {
+		return "", nil
+	}
+	value, err := starlark_type.UnmarshalStarlark(ret)
+	if err != nil {
+		return "", fmt.Errorf("error converting job %s result: %w", funcName, err)
+	}
+	result, err := json.Marshal(value)
+	if err != nil {
+		return "", fmt.Errorf("error encoding job %s result: %w", funcName, err)
+	}
+	return string(result), nil
+}
+
+// decodeJobArgs converts the JSON encoded job arguments to starlark values. A list is passed
+// as positional arguments, a dict as keyword arguments
+func decodeJobArgs(args string) (starlark.Tuple, []starlark.Tuple, error) {
+	if args == "" || args == "null" {
+		return nil, nil, nil
+	}
+	value, err := DecodeJobValue(args)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error decoding job args: %w", err)
+	}
+
+	switch v := value.(type) {
+	case []any:
+		posArgs := make(starlark.Tuple, 0, len(v))
+		for _, arg := range v {
+			sv, err := starlark_type.MarshalStarlark(arg)
+			if err != nil {
+				return nil, nil, err
+			}
+			posArgs = append(posArgs, sv)
+		}
+		return posArgs, nil, nil
+	case map[string]any:
+		kwargs := make([]starlark.Tuple, 0, len(v))
+		for key, arg := range v {
+			sv, err := starlark_type.MarshalStarlark(arg)
+			if err != nil {
+				return nil, nil, err
+			}
+			kwargs = append(kwargs, starlark.Tuple{starlark.String(key), sv})
+		}
+		return nil, kwargs, nil
+	default:
+		return nil, nil, fmt.Errorf("job args should be a list or dict, got %T", value)
+	}
+}
+
+// DecodeJobValue decodes the JSON encoded job args or result. Integral numbers are decoded
+// as int64 instead of float64, so that they are ints when passed to starlark
+func DecodeJobValue(data string) (any, error) {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return convertJobNumbers(value), nil
+}
+
+func convertJobNumbers(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	case []any:
+		for i := range v {
+			v[i] = convertJobNumbers(v[i])
+		}
+	case map[string]any:
+		for key, val := range v {
+			v[key] = convertJobNumbers(val)
+		}
+	}
+	return value
+}
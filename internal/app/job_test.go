@@ -0,0 +1,65 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+package app
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+)
+
+func newJobTestApp(t *testing.T, src string) *App {
+	t.Helper()
+	globals, err := starlark.ExecFile(&starlark.Thread{}, "app.star", src, nil)
+	testutil.AssertNoError(t, err)
+	return &App{
+		Logger:   testutil.TestLogger(),
+		AppEntry: &types.AppEntry{Id: "app_prd_job", Path: "/test"},
+		globals:  globals,
+	}
+}
+
+func TestRunJob(t *testing.T) {
+	a := newJobTestApp(t, `
+def add(a, b=1):
+    return {"sum": a + b, "items": [a] * b}
+
+def do_fail():
+    fail("bad input")
+
+value = 10
+`)
+	ctx := context.Background()
+
+	ret, err := a.RunJob(ctx, "add", `[2, 3]`)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "positional", `{"items":[2,2,2],"sum":5}`, ret)
+
+	ret, err = a.RunJob(ctx, "add", `{"a": 4}`)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "kwargs", `{"items":[4],"sum":5}`, ret)
+
+	_, err = a.RunJob(ctx, "do_fail", "null")
+	testutil.AssertErrorContains(t, err, "bad input")
+	_, err = a.RunJob(ctx, "missing", "null")
+	testutil.AssertErrorContains(t, err, "not found")
+	_, err = a.RunJob(ctx, "value", "null")
+	testutil.AssertErrorContains(t, err, "not callable")
+	_, err = a.RunJob(ctx, "add", `"abc"`)
+	testutil.AssertErrorContains(t, err, "should be a list or dict")
+}
+
+func TestRunJobCancel(t *testing.T) {
+	a := newJobTestApp(t, `
+def loop():
+    for i in range(100000000):
+        pass
+`)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := a.RunJob(ctx, "loop", "[]")
+	testutil.AssertErrorContains(t, err, "context canceled")
+}
@@ -0,0 +1,399 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"html/template"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openrundev/openrun/internal/app/apptype"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"go.starlark.net/starlarkstruct"
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	// MARKDOWN_BLOCK is the block rendering the markdown content, the app can define it to customize the page
+	MARKDOWN_BLOCK         = "openrun_markdown"
+	markdownDefaultBlock   = `<article class="prose max-w-none">{{ .Data.content }}</article>`
+	markdownFileExtension  = ".md"
+	markdownFrontMatterSep = "---"
+)
+
+// markdownConverter renders markdown for the markdown template function. Raw HTML in the
+// markdown is not rendered, since the text could come from the users
+var markdownConverter = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+)
+
+// markdownPageConverter renders the markdown route pages. The links to other markdown files
+// are updated to point to the page urls
+var markdownPageConverter = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithParserOptions(parser.WithAutoHeadingID(),
+		parser.WithASTTransformers(util.Prioritized(markdownLinkTransformer{}, 100))),
+)
+
+// markdownPage is an entry in the page list passed to the templates, for navigation
+type markdownPage struct {
+	Path   string `json:"path"` // url path relative to the route path
+	Title  string `json:"title"`
+	Weight int    `json:"weight"`
+}
+
+// markdownRoute renders a directory of markdown files into the app layout
+type markdownRoute struct {
+	path     string
+	dir      string
+	layout   string
+	index    string
+	pages    []markdownPage
+	template *template.Template // clone of the app templates, with the body set to the markdown block
+}
+
+// renderMarkdown is the markdown template function, the front matter if any is skipped
+func renderMarkdown(input string) (template.HTML, error) {
+	_, body, err := splitFrontMatter([]byte(input))
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := markdownConverter.Convert(body, &buf); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil //nolint:gosec // raw HTML is not rendered by goldmark
+}
+
+// splitFrontMatter splits the YAML front matter, between --- lines at the start of the file,
+// from the markdown body
+func splitFrontMatter(source []byte) (map[string]any, []byte, error) {
+	first, rest, found := bytes.Cut(source, []byte("\n"))
+	if !found || string(bytes.TrimSpace(first)) != markdownFrontMatterSep {
+		return nil, source, nil
+	}
+
+	offset := 0
+	for offset < len(rest) {
+		line, _, _ := bytes.Cut(rest[offset:], []byte("\n"))
+		if string(bytes.TrimSpace(line)) == markdownFrontMatterSep {
+			meta := map[string]any{}
+			if err := yaml.Unmarshal(rest[:offset], &meta); err != nil {
+				return nil, nil, fmt.Errorf("error parsing markdown front matter: %w", err)
+			}
+			return meta, rest[min(offset+len(line)+1, len(rest)):], nil
+		}
+		offset += len(line) + 1
+	}
+	return nil, nil, fmt.Errorf("markdown front matter is not terminated by %s", markdownFrontMatterSep)
+}
+
+// markdownLinkTransformer updates relative links to markdown files, like guide.md#setup, to
+// point to the page url, guide#setup. Links to index files point to the directory
+type markdownLinkTransformer struct{}
+
+func (markdownLinkTransformer) Transform(doc *ast.Document, _ text.Reader, _ parser.Context) {
+	ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) { //nolint:errcheck
+		link, ok := n.(*ast.Link)
+		if !entering || !ok {
+			return ast.WalkContinue, nil
+		}
+		dest, err := url.Parse(string(link.Destination))
+		if err != nil || dest.IsAbs() || dest.Host != "" || !strings.HasSuffix(dest.Path, markdownFileExtension) {
+			return ast.WalkContinue, nil
+		}
+		if path.Base(dest.Path) == "index"+markdownFileExtension {
+			dest.Path = strings.TrimSuffix(dest.Path, "index"+markdownFileExtension)
+			if dest.Path == "" {
+				dest.Path = "./"
+			}
+		} else {
+			dest.Path = strings.TrimSuffix(dest.Path, markdownFileExtension)
+		}
+		link.Destination = []byte(dest.String())
+		return ast.WalkContinue, nil
+	})
+}
+
+func (a *App) addMarkdownRoute(router *chi.Mux, routeDef *starlarkstruct.Struct) error {
+	var err error
+	route := &markdownRoute{}
+	if route.path, err = apptype.GetStringAttr(routeDef, "path"); err != nil {
+		return err
+	}
+	var dir string
+	if dir, err = apptype.GetStringAttr(routeDef, "dir"); err != nil {
+		return err
+	}
+	if route.layout, err = apptype.GetStringAttr(routeDef, "full"); err != nil {
+		return err
+	}
+	if route.index, err = apptype.GetStringAttr(routeDef, "index"); err != nil {
+		return err
+	}
+	if a.staticOnly {
+		return fmt.Errorf("static_only app cannot have markdown routes")
+	}
+
+	route.dir = path.Clean(dir)
+	if route.dir == "." || !fs.ValidPath(route.dir) {
+		return fmt.Errorf("markdown %s: dir %s should be a sub directory in the app source", route.path, dir)
+	}
+	if route.layout == "" {
+		if a.CustomLayout {
+			route.layout = apptype.INDEX_FILE
+		} else {
+			route.layout = apptype.INDEX_GEN_FILE
+		}
+	}
+	if route.pages, err = a.loadMarkdownPages(route); err != nil {
+		return err
+	}
+
+	a.usesHtmlTemplate = true
+	a.markdownRoutes = append(a.markdownRoutes, route)
+	a.htmlRoutes = append(a.htmlRoutes, htmlRoute{Path: route.path, Method: http.MethodGet, Template: route.layout})
+	a.Trace().Msgf("Adding markdown route <%s> for dir %s", route.path, route.dir)
+
+	router.Get(route.path, a.markdownHandler(route, false))
+	router.Get(strings.TrimSuffix(route.path, "/")+"/*", a.markdownHandler(route, true))
+	return nil
+}
+
+// loadMarkdownPages reads the front matter of the markdown files in the dir and one level of
+// sub directories, to create the page list sorted by weight and path
+func (a *App) loadMarkdownPages(route *markdownRoute) ([]markdownPage, error) {
+	files, err := a.sourceFS.Glob(path.Join(route.dir, "*"+markdownFileExtension))
+	if err != nil {
+		return nil, err
+	}
+	subFiles, err := a.sourceFS.Glob(path.Join(route.dir, "*", "*"+markdownFileExtension))
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, subFiles...)
+
+	pages := make([]markdownPage, 0, len(files))
+	for _, file := range files {
+		data, err := a.sourceFS.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		meta, body, err := splitFrontMatter(data)
+		if err != nil {
+			return nil, fmt.Errorf("markdown %s: file %s: %w", route.path, file, err)
+		}
+		if draft, _ := meta["draft"].(bool); draft {
+			continue
+		}
+
+		pagePath := strings.TrimSuffix(strings.TrimPrefix(file, route.dir+"/"), markdownFileExtension)
+		if path.Base(file) == route.index {
+			// Index pages are served at the directory path
+			pagePath = path.Dir(pagePath) + "/"
+			if pagePath == "./" {
+				pagePath = ""
+			}
+		}
+		weight, _ := meta["weight"].(int)
+		pages = append(pages, markdownPage{Path: pagePath, Title: markdownTitle(meta, body, a.markdownPageName(pagePath)), Weight: weight})
+	}
+
+	slices.SortFunc(pages, func(x, y markdownPage) int {
+		return cmp.Or(cmp.Compare(x.Weight, y.Weight), cmp.Compare(x.Path, y.Path))
+	})
+	return pages, nil
+}
+
+// markdownTitle returns the title from the front matter, else the first heading, else the page name
+func markdownTitle(meta map[string]any, body []byte, name string) string {
+	if title, ok := meta["title"].(string); ok && title != "" {
+		return title
+	}
+	for line := range strings.Lines(string(body)) {
+		if heading, ok := strings.CutPrefix(line, "# "); ok {
+			return strings.TrimSpace(heading)
+		}
+	}
+	return name
+}
+
+// markdownPageName returns the name of the page, used as the title if the page does not have one
+func (a *App) markdownPageName(pagePath string) string {
+	name := strings.TrimSuffix(path.Base(strings.TrimSuffix(pagePath, "/")), markdownFileExtension)
+	if name == "." || name == "/" {
+		return a.Name
+	}
+	return name
+}
+
+// initMarkdownTemplates creates the templates for the markdown routes. The layout template is
+// cloned with the openrun_body block set to render the markdown block. This has to be called
+// before the app templates are executed, a template cannot be cloned after execution
+func (a *App) initMarkdownTemplates() error {
+	for _, route := range a.markdownRoutes {
+		source := a.template
+		if source == nil {
+			source = a.templateMap[route.layout]
+		}
+		if source == nil || source.Lookup(route.layout) == nil {
+			return fmt.Errorf("markdown %s: layout template %s not found", route.path, route.layout)
+		}
+
+		tmpl, err := source.Clone()
+		if err != nil {
+			return err
+		}
+		if tmpl.Lookup(MARKDOWN_BLOCK) == nil {
+			if _, err := tmpl.Parse(`{{ define "` + MARKDOWN_BLOCK + `" }}` + markdownDefaultBlock + `{{ end }}`); err != nil {
+				return err
+			}
+		}
+		if _, err := tmpl.Parse(`{{ define "openrun_body" }}{{ template "` + MARKDOWN_BLOCK + `" . }}{{ end }}`); err != nil {
+			return err
+		}
+		route.template = tmpl
+	}
+	return nil
+}
+
+// markdownHandler returns the handler for the markdown route. A request for guide renders
+// guide.md from the dir, a request for a directory renders its index file. Other files in the
+// dir, like images, are served as is
+func (a *App) markdownHandler(route *markdownRoute, wildcard bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		suffix := ""
+		if wildcard {
+			// The app router mount also sets the wildcard param, so it is read only for the wildcard route
+			suffix = chi.URLParam(r, "*")
+		}
+		name := path.Join(route.dir, suffix)
+		if name != route.dir && !strings.HasPrefix(name, route.dir+"/") {
+			http.NotFound(w, r)
+			return
+		}
+
+		isDir := suffix == "" || strings.HasSuffix(suffix, "/")
+		if isDir && !strings.HasSuffix(r.URL.Path, "/") {
+			// Redirect to the path with the trailing slash, for the relative links in the page to work
+			redirectToDir(w, r)
+			return
+		}
+
+		file := name
+		switch {
+		case isDir:
+			file = path.Join(name, route.index)
+		case path.Ext(name) == "":
+			file = name + markdownFileExtension
+			if _, err := a.sourceFS.Stat(file); err != nil {
+				if _, err := a.sourceFS.Stat(path.Join(name, route.index)); err == nil {
+					redirectToDir(w, r)
+					return
+				}
+			}
+		case path.Ext(name) != markdownFileExtension:
+			a.serveMarkdownDirFile(w, r, name)
+			return
+		}
+
+		data, err := a.sourceFS.ReadFile(file)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				http.NotFound(w, r)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		a.renderMarkdownPage(w, r, route, suffix, file, data)
+	}
+}
+
+func (a *App) renderMarkdownPage(w http.ResponseWriter, r *http.Request, route *markdownRoute, pagePath, file string, data []byte) {
+	meta, body, err := splitFrontMatter(data)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("%s: %s", file, err), http.StatusInternalServerError)
+		return
+	}
+	if draft, _ := meta["draft"].(bool); draft && !a.IsDev {
+		http.NotFound(w, r)
+		return
+	}
+
+	var content bytes.Buffer
+	if err := markdownPageConverter.Convert(body, &content); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	requestData, scratch := a.newRequestData(r, false)
+	defer scratch.release()
+	requestData.Data = map[string]any{
+		"content": template.HTML(content.String()), //nolint:gosec // raw HTML is not rendered by goldmark
+		"title":   markdownTitle(meta, body, a.markdownPageName(pagePath)),
+		"meta":    meta,
+		"path":    pagePath,
+		"pages":   route.pages,
+	}
+
+	respHeader := w.Header()
+	respHeader["Vary"] = VARY_HEADER_VALUE
+	respHeader["Server"] = SERVER_NAME
+	respHeader["Content-Type"] = CONTENT_TYPE_HTML
+
+	buf := renderBufferPool.Get().(*bytes.Buffer)
+	buf.Reset()
+	defer putRenderBuffer(buf)
+	if err := route.template.ExecuteTemplate(buf, route.layout, requestData); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeRendered(w, r, buf.Bytes())
+}
+
+// serveMarkdownDirFile serves a non markdown file from the markdown dir, like an image
+func (a *App) serveMarkdownDirFile(w http.ResponseWriter, r *http.Request, name string) {
+	data, err := a.sourceFS.ReadFile(name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			http.NotFound(w, r)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	http.ServeContent(w, r, path.Base(name), time.Time{}, bytes.NewReader(data))
+}
+
+// redirectToDir redirects to the request path with a trailing slash. The location is relative,
+// since the request path does not include the app path prefix
+func redirectToDir(w http.ResponseWriter, r *http.Request) {
+	location := path.Base(r.URL.Path) + "/"
+	if r.URL.RawQuery != "" {
+		location += "?" + r.URL.RawQuery
+	}
+	w.Header().Set("Location", location)
+	w.WriteHeader(http.StatusMovedPermanently)
+}
@@ -0,0 +1,604 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// maxMinifyCachePage is the largest page whose minified output is cached, larger pages are
+// minified on every request
+const maxMinifyCachePage = 512 * 1024
+
+// pageMinifier minifies the HTML output of the app templates. The minified output is cached by the
+// template name and the hash of the rendered output, so a page rendered with the same data is
+// minified once. The minification is conservative, content which cannot be parsed is left as is
+type pageMinifier struct {
+	css, js    bool
+	maxEntries int
+
+	mu      sync.Mutex
+	clock   uint64
+	entries map[minifyCacheKey]*minifyCacheEntry
+}
+
+type minifyCacheKey struct {
+	template string
+	hash     [sha256.Size]byte
+}
+
+type minifyCacheEntry struct {
+	data     []byte
+	lastUsed uint64
+}
+
+// newPageMinifier returns the minifier for the app, nil if minification is not enabled. Dev apps
+// are not minified, so that the output is readable while developing
+func newPageMinifier(config types.MinifyConfig, isDev bool) *pageMinifier {
+	if !config.HTML || isDev {
+		return nil
+	}
+	return &pageMinifier{
+		css:        config.CSS,
+		js:         config.JS,
+		maxEntries: config.CacheEntries,
+		entries:    make(map[minifyCacheKey]*minifyCacheEntry),
+	}
+}
+
+// minify returns the minified page. The returned slice should not be modified, it can be shared
+// with other requests
+func (m *pageMinifier) minify(template string, page []byte) []byte {
+	if m.maxEntries <= 0 || len(page) > maxMinifyCachePage {
+		return minifyHTML(page, m.css, m.js)
+	}
+
+	key := minifyCacheKey{template: template, hash: sha256.Sum256(page)}
+	m.mu.Lock()
+	m.clock++
+	if entry, ok := m.entries[key]; ok {
+		entry.lastUsed = m.clock
+		m.mu.Unlock()
+		return entry.data
+	}
+	m.mu.Unlock()
+
+	data := minifyHTML(page, m.css, m.js)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries[key] = &minifyCacheEntry{data: data, lastUsed: m.clock}
+	for len(m.entries) > m.maxEntries {
+		var oldestKey minifyCacheKey
+		var oldest *minifyCacheEntry
+		for k, e := range m.entries {
+			if oldest == nil || e.lastUsed < oldest.lastUsed {
+				oldestKey, oldest = k, e
+			}
+		}
+		delete(m.entries, oldestKey)
+	}
+	return data
+}
+
+// whitespaceOnlyTags are the tags between which whitespace text is not rendered, the whitespace
+// between other tags is collapsed but not removed since it can affect the inline layout
+var whitespaceOnlyTags = map[string]bool{
+	"!doctype": true, "html": true, "head": true, "body": true, "meta": true, "link": true, "title": true,
+	"base": true, "script": true, "style": true, "noscript": true, "template": true,
+	"table": true, "thead": true, "tbody": true, "tfoot": true, "tr": true, "colgroup": true, "col": true,
+}
+
+// minifyHTML removes comments and collapses whitespace in the HTML text. Conditional comments and
+// the content of pre and textarea are retained as is. Inline CSS and JS are minified if enabled,
+// JSON script blocks are compacted
+func minifyHTML(src []byte, css, js bool) []byte {
+	out := make([]byte, 0, len(src))
+	lastTag := "!doctype" // whitespace at the start of the document is removed
+	i := 0
+	for i < len(src) {
+		if src[i] != '<' {
+			end := bytes.IndexByte(src[i:], '<')
+			if end < 0 {
+				end = len(src)
+			} else {
+				end += i
+			}
+			text := src[i:end]
+			if isHTMLSpace(text) {
+				if !whitespaceOnlyTags[lastTag] || !whitespaceOnlyTags[nextTagName(src, end)] {
+					out = appendCollapsedSpace(out, text)
+				}
+			} else {
+				out = appendCollapsedText(out, text)
+			}
+			i = end
+			continue
+		}
+
+		if bytes.HasPrefix(src[i:], []byte("<!--")) {
+			end := bytes.Index(src[i+4:], []byte("-->"))
+			if end < 0 {
+				return append(out, src[i:]...)
+			}
+			end += i + 7
+			if bytes.HasPrefix(src[i:], []byte("<!--[if")) || bytes.HasPrefix(src[i:], []byte("<!--!")) {
+				out = append(out, src[i:end]...)
+			}
+			i = end
+			continue
+		}
+
+		name, closing := htmlTagName(src[i:])
+		if name == "" {
+			// Not a tag, like a < in the text
+			out = append(out, '<')
+			i++
+			continue
+		}
+		end := htmlTagEnd(src, i)
+		if end < 0 {
+			return append(out, src[i:]...)
+		}
+		tag := src[i:end]
+		out = append(out, tag...)
+		i = end
+		lastTag = name
+		if closing {
+			continue
+		}
+
+		switch name {
+		case "script", "style", "pre", "textarea":
+			contentEnd := htmlClosingTag(src, i, name)
+			content := src[i:contentEnd]
+			switch {
+			case name == "style" && css:
+				out = append(out, minifyCSS(content)...)
+			case name == "script":
+				out = append(out, minifyScript(tag, content, js)...)
+			default:
+				out = append(out, content...)
+			}
+			i = contentEnd
+		}
+	}
+	return out
+}
+
+// htmlTagName returns the lower case name of the tag at the start of src, empty if src does not
+// start with a tag
+func htmlTagName(src []byte) (name string, closing bool) {
+	i := 1
+	if i < len(src) && src[i] == '/' {
+		closing = true
+		i++
+	}
+	start := i
+	if i < len(src) && src[i] == '!' && !closing {
+		i++
+	}
+	for i < len(src) && (isAlphaNum(src[i]) || (i > start && src[i] == '-')) {
+		i++
+	}
+	if i == start || (src[start] != '!' && !isAlpha(src[start])) {
+		return "", false
+	}
+	return strings.ToLower(string(src[start:i])), closing
+}
+
+// nextTagName returns the name of the next tag from i, skipping the whitespace and comments
+func nextTagName(src []byte, i int) string {
+	for i < len(src) {
+		switch {
+		case isSpace(src[i]):
+			i++
+		case bytes.HasPrefix(src[i:], []byte("<!--")):
+			end := bytes.Index(src[i+4:], []byte("-->"))
+			if end < 0 {
+				return ""
+			}
+			i += end + 7
+		case src[i] == '<':
+			name, _ := htmlTagName(src[i:])
+			return name
+		default:
+			return ""
+		}
+	}
+	return "!doctype" // whitespace at the end of the document is removed
+}
+
+// htmlTagEnd returns the index after the '>' which ends the tag starting at i, -1 if the tag is
+// not terminated. A '>' within quoted attribute values does not end the tag
+func htmlTagEnd(src []byte, i int) int {
+	var quote byte
+	for j := i + 1; j < len(src); j++ {
+		switch c := src[j]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			return j + 1
+		}
+	}
+	return -1
+}
+
+// htmlClosingTag returns the index of the closing tag for the raw text element, the end of src if
+// it is not closed
+func htmlClosingTag(src []byte, i int, name string) int {
+	closeTag := "</" + name
+	for j := i; j < len(src); {
+		index := bytes.IndexByte(src[j:], '<')
+		if index < 0 {
+			break
+		}
+		j += index
+		if len(src)-j >= len(closeTag) && strings.EqualFold(string(src[j:j+len(closeTag)]), closeTag) {
+			return j
+		}
+		j++
+	}
+	return len(src)
+}
+
+func isHTMLSpace(text []byte) bool {
+	for _, c := range text {
+		if !isSpace(c) {
+			return false
+		}
+	}
+	return true
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+func isAlpha(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isAlphaNum(c byte) bool {
+	return isAlpha(c) || (c >= '0' && c <= '9')
+}
+
+// appendCollapsedSpace appends one whitespace char for the whitespace run, a newline if the run
+// has one. Nothing is appended if the output ends with whitespace, like for the whitespace on both
+// sides of a removed comment
+func appendCollapsedSpace(out, space []byte) []byte {
+	if len(out) > 0 && isSpace(out[len(out)-1]) {
+		return out
+	}
+	if bytes.IndexByte(space, '\n') >= 0 {
+		return append(out, '\n')
+	}
+	return append(out, ' ')
+}
+
+// appendCollapsedText appends the text with each whitespace run collapsed to one char
+func appendCollapsedText(out, text []byte) []byte {
+	for i := 0; i < len(text); {
+		if !isSpace(text[i]) {
+			out = append(out, text[i])
+			i++
+			continue
+		}
+		j := i
+		for j < len(text) && isSpace(text[j]) {
+			j++
+		}
+		out = appendCollapsedSpace(out, text[i:j])
+		i = j
+	}
+	return out
+}
+
+var scriptTypeRegex = regexp.MustCompile(`(?i)\stype\s*=\s*["']?([^"'\s>]+)`)
+
+// minifyScript minifies the content of a script tag based on its type. JSON is compacted, JS is
+// minified if enabled and other types, like templates, are left as is
+func minifyScript(tag, content []byte, js bool) []byte {
+	scriptType := ""
+	if match := scriptTypeRegex.FindSubmatch(tag); match != nil {
+		scriptType = strings.ToLower(string(match[1]))
+	}
+
+	switch scriptType {
+	case "", "text/javascript", "application/javascript", "module":
+		if js {
+			return minifyJS(content)
+		}
+	case "application/json", "application/ld+json", "importmap":
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, content); err == nil {
+			return buf.Bytes()
+		}
+	}
+	return content
+}
+
+// minifyCSS removes comments and the whitespace which is not needed. The whitespace before a ':'
+// is retained since it is significant in selectors. The input is returned as is if it has an
+// unterminated comment or string
+func minifyCSS(src []byte) []byte {
+	out := make([]byte, 0, len(src))
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case isSpace(c) || (c == '/' && i+1 < len(src) && src[i+1] == '*'):
+			// Comments are handled like whitespace, since they can separate two tokens
+			for i < len(src) {
+				if isSpace(src[i]) {
+					i++
+				} else if src[i] == '/' && i+1 < len(src) && src[i+1] == '*' {
+					end := bytes.Index(src[i+2:], []byte("*/"))
+					if end < 0 {
+						return src
+					}
+					i += end + 4
+				} else {
+					break
+				}
+			}
+			if len(out) == 0 || i == len(src) || strings.IndexByte("{};,>:", out[len(out)-1]) >= 0 ||
+				strings.IndexByte("{};,>", src[i]) >= 0 {
+				continue
+			}
+			out = append(out, ' ')
+		case c == '"' || c == '\'':
+			end := skipQuoted(src, i)
+			if end < 0 {
+				return src
+			}
+			out = append(out, src[i:end]...)
+			i = end
+		case c == '}' && len(out) > 0 && out[len(out)-1] == ';':
+			out[len(out)-1] = '}'
+			i++
+		default:
+			out = append(out, c)
+			i++
+		}
+	}
+	return out
+}
+
+// skipQuoted returns the index after the string starting at i, -1 if the string is not
+// terminated on the line
+func skipQuoted(src []byte, i int) int {
+	quote := src[i]
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case quote:
+			return j + 1
+		case '\n':
+			return -1
+		}
+	}
+	return -1
+}
+
+// jsRegexKeywords are the keywords after which a '/' starts a regex literal
+var jsRegexKeywords = map[string]bool{
+	"return": true, "typeof": true, "instanceof": true, "case": true, "do": true, "else": true, "in": true,
+	"of": true, "new": true, "delete": true, "void": true, "throw": true, "yield": true, "await": true,
+}
+
+// minifyJS removes comments and the whitespace which is not needed. Strings, template literals and
+// regex literals are retained as is. A line break is retained where removing it could change the
+// automatic semicolon insertion. The input is returned as is if it has an unterminated comment,
+// string or literal
+func minifyJS(src []byte) []byte {
+	out := make([]byte, 0, len(src))
+	pendingSpace := byte(0) // the whitespace to add before the next token, ' ' or '\n'
+	addSpace := func(c byte) {
+		if c == '\n' || pendingSpace == 0 {
+			pendingSpace = c
+		}
+	}
+	emit := func(token []byte) {
+		if pendingSpace != 0 && len(out) > 0 && jsNeedsSpace(out[len(out)-1], token[0], pendingSpace) {
+			out = append(out, pendingSpace)
+		}
+		pendingSpace = 0
+		out = append(out, token...)
+	}
+
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case isSpace(c):
+			if c == '\n' {
+				addSpace('\n')
+			} else {
+				addSpace(' ')
+			}
+			i++
+		case c == '/' && i+1 < len(src) && src[i+1] == '/':
+			end := bytes.IndexByte(src[i:], '\n')
+			if end < 0 {
+				i = len(src)
+			} else {
+				i += end
+			}
+		case c == '/' && i+1 < len(src) && src[i+1] == '*':
+			end := bytes.Index(src[i+2:], []byte("*/"))
+			if end < 0 {
+				return src
+			}
+			if bytes.IndexByte(src[i:i+end+2], '\n') >= 0 {
+				addSpace('\n')
+			} else {
+				addSpace(' ')
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			end := skipQuoted(src, i)
+			if end < 0 {
+				return src
+			}
+			emit(src[i:end])
+			i = end
+		case c == '`':
+			end := skipJSTemplate(src, i)
+			if end < 0 {
+				return src
+			}
+			emit(src[i:end])
+			i = end
+		case c == '/' && jsRegexAllowed(out):
+			end := skipJSRegex(src, i)
+			if end < 0 {
+				emit(src[i : i+1])
+				i++
+				continue
+			}
+			emit(src[i:end])
+			i = end
+		case isJSIdentChar(c):
+			j := i
+			for j < len(src) && isJSIdentChar(src[j]) {
+				j++
+			}
+			emit(src[i:j])
+			i = j
+		default:
+			emit(src[i : i+1])
+			i++
+		}
+	}
+	return out
+}
+
+// jsNeedsSpace reports whether the whitespace between the two chars has to be retained
+func jsNeedsSpace(prev, next, space byte) bool {
+	if isJSIdentChar(prev) && isJSIdentChar(next) {
+		return true
+	}
+	if (prev == '+' || prev == '-' || prev == '/') && prev == next {
+		// a + +b, a - -b and a / /re/
+		return true
+	}
+	if prev >= '0' && prev <= '9' && next == '.' || prev == '<' && (next == '/' || next == '!') {
+		// 1 .toString(), and a < /re/ or a < !b which would form a closing tag or a comment
+		return true
+	}
+	if space != '\n' {
+		return false
+	}
+	// The line break could end the statement, it is not needed after chars which cannot end a
+	// statement and before chars which cannot start one
+	return strings.IndexByte("{;,([=:?&|!<>*%^~", prev) < 0 && strings.IndexByte("});,]=:?.&|*%^<>", next) < 0
+}
+
+func isJSIdentChar(c byte) bool {
+	return isAlphaNum(c) || c == '_' || c == '$' || c == '\\' || c >= 0x80
+}
+
+// jsRegexAllowed reports whether a '/' after the output so far starts a regex literal instead of
+// being a division. When in doubt, the '/' is taken to be a regex, which is copied as is
+func jsRegexAllowed(out []byte) bool {
+	if len(out) == 0 {
+		return true
+	}
+	last := out[len(out)-1]
+	if last == ')' || last == ']' {
+		return false
+	}
+	if !isJSIdentChar(last) {
+		return true
+	}
+	start := len(out)
+	for start > 0 && isJSIdentChar(out[start-1]) {
+		start--
+	}
+	return jsRegexKeywords[string(out[start:])]
+}
+
+// skipJSRegex returns the index after the regex literal starting at i, including the flags. -1 if
+// the regex is not terminated on the line
+func skipJSRegex(src []byte, i int) int {
+	inClass := false
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '[':
+			inClass = true
+		case ']':
+			inClass = false
+		case '\n':
+			return -1
+		case '/':
+			if inClass {
+				continue
+			}
+			j++
+			for j < len(src) && isAlpha(src[j]) {
+				j++
+			}
+			return j
+		}
+	}
+	return -1
+}
+
+// skipJSTemplate returns the index after the template literal starting at i, -1 if it is not
+// terminated. The ${} expressions can have strings and nested template literals
+func skipJSTemplate(src []byte, i int) int {
+	for j := i + 1; j < len(src); j++ {
+		switch src[j] {
+		case '\\':
+			j++
+		case '`':
+			return j + 1
+		case '$':
+			if j+1 >= len(src) || src[j+1] != '{' {
+				continue
+			}
+			depth := 0
+			for j++; j < len(src); j++ {
+				switch src[j] {
+				case '{':
+					depth++
+				case '}':
+					depth--
+				case '"', '\'':
+					end := skipQuoted(src, j)
+					if end < 0 {
+						return -1
+					}
+					j = end - 1
+				case '`':
+					end := skipJSTemplate(src, j)
+					if end < 0 {
+						return -1
+					}
+					j = end - 1
+				}
+				if depth == 0 {
+					break
+				}
+			}
+			if depth != 0 {
+				return -1
+			}
+		}
+	}
+	return -1
+}
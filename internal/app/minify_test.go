@@ -0,0 +1,96 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestMinifyHTML(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "head", in: "<!DOCTYPE html>\n<html>\n  <head>\n    <title>t</title>\n  </head>\n  <body>\n  </body>\n</html>\n",
+			want: "<!DOCTYPE html><html><head><title>t</title></head><body></body></html>"},
+		{name: "inline whitespace", in: "<p>\n  <span>a</span>   <span>b</span>\n</p>",
+			want: "<p>\n<span>a</span> <span>b</span>\n</p>"},
+		{name: "text", in: "<p>one   two\n\n   three</p>", want: "<p>one two\nthree</p>"},
+		{name: "comments", in: "<p>a<!-- comment -->b</p><!--[if IE]>x<![endif]--><!--! keep -->",
+			want: "<p>ab</p><!--[if IE]>x<![endif]--><!--! keep -->"},
+		{name: "comment whitespace", in: "<head></head>\n  <!-- c -->\n  <body><p>a <!-- c --> b</p></body>",
+			want: "<head></head><body><p>a b</p></body>"},
+		{name: "pre", in: "<pre>\n  a   b\n</pre>  <textarea>  x  </textarea>",
+			want: "<pre>\n  a   b\n</pre> <textarea>  x  </textarea>"},
+		{name: "attribute", in: `<a title="x > y"   href="/">a  b</a>`, want: `<a title="x > y"   href="/">a b</a>`},
+		{name: "less than", in: "<p>a < b</p>", want: "<p>a < b</p>"},
+		{name: "style", in: "<style>\n  /* c */\n  a:hover , .b > p {\n    color : red;\n  }\n</style>",
+			want: "<style>a:hover,.b>p{color :red}</style>"},
+		{name: "json", in: `<script type="application/json">{ "a" : [1, 2] }</script>`,
+			want: `<script type="application/json">{"a":[1,2]}</script>`},
+		{name: "template script", in: "<script type=\"text/template\">\n  <p>  x </p>\n</script>",
+			want: "<script type=\"text/template\">\n  <p>  x </p>\n</script>"},
+		{name: "unterminated tag", in: "<p>a</p>  <div", want: "<p>a</p> <div"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			testutil.AssertEqualsString(t, "output", tc.want, string(minifyHTML([]byte(tc.in), true, true)))
+		})
+	}
+
+	// CSS and JS are not changed if disabled
+	in := "<style> a { b: c } </style><script> f( 1 ) </script>"
+	testutil.AssertEqualsString(t, "disabled", in, string(minifyHTML([]byte(in), false, false)))
+}
+
+func TestMinifyJS(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{name: "whitespace", in: "  function f ( a , b ) {\n    return a + b ;\n  }\n", want: "function f(a,b){return a+b;}"},
+		{name: "line breaks", in: "let a = 1\nlet b = a\n++b\nx = {}\nfoo()", want: "let a=1\nlet b=a\n++b\nx={}\nfoo()"},
+		{name: "return", in: "return\nx", want: "return\nx"},
+		{name: "comments", in: "a = 1 // one\n/* two\n */ b = 2 /* three */ + 3", want: "a=1\nb=2+3"},
+		{name: "strings", in: `s = "a  // b" + 'c  /* d */' + ` + "`e  ${ f( `g  h` ) }  i`",
+			want: `s="a  // b"+'c  /* d */'+` + "`e  ${ f( `g  h` ) }  i`"},
+		{name: "regex", in: "r = /a  b\\/ [/]  c/g ; x = a / b / c", want: "r=/a  b\\/ [/]  c/g;x=a/b/c"},
+		{name: "regex after keyword", in: "return /a  b/.test( s )", want: "return/a  b/.test(s)"},
+		{name: "operators", in: "a + +b - -c + ++d; x = y / /re/", want: "a+ +b- -c+ ++d;x=y/ /re/"},
+		{name: "number", in: "1 .toString(); a . b", want: "1 .toString();a.b"},
+		{name: "closing tag", in: "a < /script/.x", want: "a< /script/.x"},
+		{name: "unterminated string", in: "a = 'b\n  c", want: "a = 'b\n  c"},
+		{name: "unterminated comment", in: "a = 1 /* b", want: "a = 1 /* b"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			testutil.AssertEqualsString(t, "output", tc.want, string(minifyJS([]byte(tc.in))))
+		})
+	}
+}
+
+func TestPageMinifier(t *testing.T) {
+	testutil.AssertEqualsBool(t, "disabled", true, newPageMinifier(types.MinifyConfig{HTML: false}, false) == nil)
+	testutil.AssertEqualsBool(t, "dev", true, newPageMinifier(types.MinifyConfig{HTML: true}, true) == nil)
+
+	m := newPageMinifier(types.MinifyConfig{HTML: true, CSS: true, JS: true, CacheEntries: 2}, false)
+	page1 := []byte("<p>  1  </p>")
+	testutil.AssertEqualsString(t, "page1", "<p> 1 </p>", string(m.minify("index.go.html", page1)))
+	testutil.AssertEqualsString(t, "page2", "<p> 2 </p>", string(m.minify("index.go.html", []byte("<p>  2  </p>"))))
+	testutil.AssertEqualsString(t, "page1 cached", "<p> 1 </p>", string(m.minify("index.go.html", page1)))
+	testutil.AssertEqualsString(t, "page3", "<p> 3 </p>", string(m.minify("index.go.html", []byte("<p>  3  </p>"))))
+
+	// The least recently used page is evicted
+	testutil.AssertEqualsInt(t, "entries", 2, len(m.entries))
+	for key := range m.entries {
+		if string(m.entries[key].data) == "<p> 2 </p>" {
+			t.Errorf("expected page2 to be evicted")
+		}
+	}
+}
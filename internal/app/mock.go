@@ -0,0 +1,499 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/openrundev/openrun/internal/app/starlark_type"
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+const (
+	DEFAULT_MOCK_DIR = "mocks"
+	// mockMethodSuffix marks a fixture object key as a method, like "json()" for the http
+	// response, the method returns the value
+	mockMethodSuffix = "()"
+)
+
+// pluginMocker replaces plugin calls with the responses from the fixture files in the app
+// source, so that the handler and UI logic can be worked on while the upstream APIs are not
+// available. In record mode, the plugin is called and the call is saved to the fixture file.
+// It is created only for dev apps with mock plugins configured
+type pluginMocker struct {
+	*types.Logger
+	plugins  []string
+	dir      string
+	record   bool
+	recordMu sync.Mutex // serializes the fixture file updates when recording
+}
+
+// mockFixtureFS is the app source, which the fixtures are read from and recorded to
+type mockFixtureFS interface {
+	ReadFile(name string) ([]byte, error)
+	Write(name string, data []byte) error
+}
+
+// mockEntry is a response in a fixture file. The entry is used for a call if the args and
+// kwargs match, the ones not set in the entry match any value
+type mockEntry struct {
+	Args   []any          `json:"args,omitempty"`
+	Kwargs map[string]any `json:"kwargs,omitempty"`
+	Value  any            `json:"value,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// newPluginMocker returns the plugin mocker for the app, nil if mocks are not enabled
+func newPluginMocker(logger *types.Logger, isDev bool, config types.MockConfig) *pluginMocker {
+	if !isDev || len(config.Plugins) == 0 {
+		return nil
+	}
+	return &pluginMocker{Logger: logger, plugins: config.Plugins, dir: cmp.Or(config.Dir, DEFAULT_MOCK_DIR), record: config.Record}
+}
+
+func (m *pluginMocker) matchesPlugin(modulePath, functionName string) bool {
+	if m == nil {
+		return false
+	}
+	name := modulePath + "." + functionName
+	for _, pattern := range m.plugins {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// fixturePath returns the fixture file for the plugin function, like mocks/http.in/get.json
+func (m *pluginMocker) fixturePath(modulePath, functionName string) string {
+	return path.Join(m.dir, modulePath, functionName+".json")
+}
+
+// wrapPlugin returns the plugin function with the call replaced by the fixture response, if the
+// plugin call is mocked. The fixture is read on every call, so edits are used without a reload.
+// The fixture error is returned as a plugin error, like an actual plugin failure. In record mode,
+// the plugin is called and the call is recorded, with the secretValues resolved in the args redacted
+func (m *pluginMocker) wrapPlugin(fixtures mockFixtureFS, modulePath, functionName string, secretValues func() []string, fn StarlarkFunction) StarlarkFunction {
+	if !m.matchesPlugin(modulePath, functionName) {
+		return fn
+	}
+	fixturePath := m.fixturePath(modulePath, functionName)
+	if m.record {
+		return func(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			return m.recordCall(thread, fixtures, fixturePath, secretValues(), fn, builtin, args, kwargs)
+		}
+	}
+	return func(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		data, err := fixtures.ReadFile(fixturePath)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("no mock fixture for %s.%s, create %s", modulePath, functionName, fixturePath)
+		} else if err != nil {
+			return nil, err
+		}
+		entries, err := parseMockEntries(data)
+		if err != nil {
+			return nil, fmt.Errorf("error reading mock fixture %s: %w", fixturePath, err)
+		}
+		return replayMockEntries(entries, fixturePath, args, kwargs)
+	}
+}
+
+// recordCall calls the plugin and saves the call args and the response to the fixture file. A
+// recording failure is logged, the plugin response is returned as is
+func (m *pluginMocker) recordCall(thread *starlark.Thread, fixtures mockFixtureFS, fixturePath string, secretValues []string,
+	fn StarlarkFunction, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	// The args are converted before the call, since the plugin could update them
+	entry, argsErr := mockCallEntry(args, kwargs)
+	value, err := fn(thread, builtin, args, kwargs)
+	if argsErr != nil {
+		m.Warn().Err(argsErr).Msgf("error recording call args for %s", fixturePath)
+		return value, err
+	}
+
+	if err != nil {
+		entry.Error = err.Error()
+	} else {
+		recorded, recordErr := mockRecordValue(thread, value)
+		if recordErr != nil {
+			m.Warn().Err(recordErr).Msgf("error recording response for %s", fixturePath)
+			return value, err
+		}
+		entry.Value = recorded
+	}
+	entry.Args, _ = redactMockValue(entry.Args, secretValues).([]any)
+	entry.Kwargs, _ = redactMockValue(entry.Kwargs, secretValues).(map[string]any)
+	entry.Value = redactMockValue(entry.Value, secretValues)
+	entry.Error = redactMockValue(entry.Error, secretValues).(string)
+
+	if saveErr := m.saveEntry(fixtures, fixturePath, entry); saveErr != nil {
+		m.Warn().Err(saveErr).Msgf("error saving recorded call to %s", fixturePath)
+	}
+	return value, err
+}
+
+// saveEntry adds the recorded entry to the fixture file. An entry with the same args replaces the
+// earlier recording, so that the fixture has the latest response for each call
+func (m *pluginMocker) saveEntry(fixtures mockFixtureFS, fixturePath string, entry mockEntry) error {
+	m.recordMu.Lock()
+	defer m.recordMu.Unlock()
+
+	entries := []mockEntry{}
+	data, err := fixtures.ReadFile(fixturePath)
+	if err == nil {
+		if entries, err = parseMockEntries(data); err != nil {
+			return fmt.Errorf("error reading mock fixture %s: %w", fixturePath, err)
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	replaced := false
+	for i := range entries {
+		if reflect.DeepEqual(entries[i].Args, entry.Args) && reflect.DeepEqual(entries[i].Kwargs, entry.Kwargs) {
+			entries[i] = entry
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		entries = append(entries, entry)
+	}
+	if data, err = json.MarshalIndent(entries, "", "  "); err != nil {
+		return err
+	}
+	return fixtures.Write(fixturePath, append(data, '\n'))
+}
+
+// replayMockEntries returns the response for the call from the fixture entries
+func replayMockEntries(entries []mockEntry, fixturePath string, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	entry, err := matchMockEntry(entries, args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("no mock response in %s matches the call args %s", fixturePath, args.String())
+	}
+	if entry.Error != "" {
+		return nil, errors.New(entry.Error)
+	}
+	return mockValue(entry.Value)
+}
+
+// parseMockEntries parses the fixture file, which has a list of entries or a single entry
+func parseMockEntries(data []byte) ([]mockEntry, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] != '[' {
+		entry := mockEntry{}
+		if err := json.Unmarshal(data, &entry); err != nil {
+			return nil, err
+		}
+		return []mockEntry{entry}, nil
+	}
+	entries := []mockEntry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// matchMockEntry returns the first entry which matches the call args, nil if none match
+func matchMockEntry(entries []mockEntry, args starlark.Tuple, kwargs []starlark.Tuple) (*mockEntry, error) {
+	call, err := mockCallEntry(args, kwargs)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+		if entry.Args != nil && !mockValueMatches(entry.Args, call.Args) {
+			continue
+		}
+		kwargsMatch := true
+		for key, value := range entry.Kwargs {
+			if callValue, ok := call.Kwargs[key]; !ok || !mockValueMatches(value, callValue) {
+				kwargsMatch = false
+				break
+			}
+		}
+		if kwargsMatch {
+			return entry, nil
+		}
+	}
+	return nil, nil
+}
+
+// mockCallEntry returns the entry with the call args, as they would be in the fixture JSON
+func mockCallEntry(args starlark.Tuple, kwargs []starlark.Tuple) (mockEntry, error) {
+	entry := mockEntry{}
+	for _, arg := range args {
+		value, err := mockCallValue(arg)
+		if err != nil {
+			return entry, err
+		}
+		entry.Args = append(entry.Args, value)
+	}
+	for _, kwarg := range kwargs {
+		value, err := mockCallValue(kwarg[1])
+		if err != nil {
+			return entry, err
+		}
+		if entry.Kwargs == nil {
+			entry.Kwargs = map[string]any{}
+		}
+		entry.Kwargs[string(kwarg[0].(starlark.String))] = value
+	}
+	return entry, nil
+}
+
+// mockValueMatches checks whether the call value matches the fixture value. A value redacted when
+// recording matches any value, a string with a redacted part matches any text for that part
+func mockValueMatches(fixtureValue, callValue any) bool {
+	switch f := fixtureValue.(type) {
+	case string:
+		if f == CAPTURE_REDACTED {
+			return true
+		}
+		c, ok := callValue.(string)
+		if !ok {
+			return false
+		}
+		if !strings.Contains(f, CAPTURE_REDACTED) {
+			return f == c
+		}
+		parts := strings.Split(f, CAPTURE_REDACTED)
+		for i, part := range parts {
+			parts[i] = regexp.QuoteMeta(part)
+		}
+		return regexp.MustCompile("^" + strings.Join(parts, ".*") + "$").MatchString(c)
+	case []any:
+		c, ok := callValue.([]any)
+		if !ok || len(f) != len(c) {
+			return false
+		}
+		for i := range f {
+			if !mockValueMatches(f[i], c[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]any:
+		c, ok := callValue.(map[string]any)
+		if !ok || len(f) != len(c) {
+			return false
+		}
+		for key, value := range f {
+			if callFieldValue, ok := c[key]; !ok || !mockValueMatches(value, callFieldValue) {
+				return false
+			}
+		}
+		return true
+	default:
+		return reflect.DeepEqual(fixtureValue, callValue)
+	}
+}
+
+// redactMockValue returns the recorded value with the secret values replaced by CAPTURE_REDACTED.
+// The string values of sensitive looking keys, like authorization headers and tokens, are
+// replaced entirely
+func redactMockValue(value any, secretValues []string) any {
+	switch v := value.(type) {
+	case string:
+		for _, secret := range secretValues {
+			if secret != "" {
+				v = strings.ReplaceAll(v, secret, CAPTURE_REDACTED)
+			}
+		}
+		return v
+	case []any:
+		for i := range v {
+			v[i] = redactMockValue(v[i], secretValues)
+		}
+		return v
+	case map[string]any:
+		for key, fieldValue := range v {
+			name := strings.TrimSuffix(key, mockMethodSuffix)
+			if _, isString := fieldValue.(string); isString &&
+				(captureRedactHeaders[http.CanonicalHeaderKey(name)] || isSensitiveCaptureName(name)) {
+				v[key] = CAPTURE_REDACTED
+				continue
+			}
+			v[key] = redactMockValue(fieldValue, secretValues)
+		}
+		return v
+	default:
+		return value
+	}
+}
+
+// mockRecordValue converts the plugin response to the fixture value. For objects, the attributes
+// are recorded and the methods which take no arguments are called to record their return value,
+// like json() for the http response
+func mockRecordValue(thread *starlark.Thread, value starlark.Value) (any, error) {
+	switch v := value.(type) {
+	case *starlark.Dict:
+		ret := make(map[string]any, v.Len())
+		for _, item := range v.Items() {
+			key, ok := item[0].(starlark.String)
+			if !ok {
+				return nil, fmt.Errorf("cannot record dict with %s key", item[0].Type())
+			}
+			fieldValue, err := mockRecordValue(thread, item[1])
+			if err != nil {
+				return nil, err
+			}
+			ret[string(key)] = fieldValue
+		}
+		return ret, nil
+	case *starlark.List:
+		ret := make([]any, 0, v.Len())
+		for i := range v.Len() {
+			elem, err := mockRecordValue(thread, v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, elem)
+		}
+		return ret, nil
+	case starlark.Tuple:
+		return mockRecordValue(thread, starlark.NewList(v))
+	case starlark.NoneType, starlark.Bool, starlark.Int, starlark.Float, starlark.String:
+		return mockCallValue(v)
+	case starlark.HasAttrs:
+		if _, ok := v.(starlark_type.TypeUnmarshaler); ok {
+			return mockCallValue(v)
+		}
+		ret := map[string]any{}
+		for _, name := range v.AttrNames() {
+			attr, err := v.Attr(name)
+			if err != nil {
+				return nil, err
+			}
+			if method, ok := attr.(*starlark.Builtin); ok {
+				result, err := starlark.Call(thread, method, nil, nil)
+				if err != nil {
+					continue // method which needs args, or which failed, like json() for a non JSON body
+				}
+				if ret[name+mockMethodSuffix], err = mockRecordValue(thread, result); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if ret[name], err = mockRecordValue(thread, attr); err != nil {
+				return nil, err
+			}
+		}
+		return ret, nil
+	default:
+		return mockCallValue(v)
+	}
+}
+
+// mockCallValue converts the call arg to the value it would have in the fixture JSON
+func mockCallValue(arg starlark.Value) (any, error) {
+	value, err := starlark_type.UnmarshalStarlark(arg)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var ret any
+	if err := json.Unmarshal(data, &ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// mockValue converts the fixture value to the plugin response value. JSON objects are converted
+// to mockObject, so that the fields are available as attributes, like for the plugin responses
+func mockValue(value any) (starlark.Value, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		dict := starlark.NewDict(len(v))
+		for key, fieldValue := range v {
+			field, err := mockValue(fieldValue)
+			if err != nil {
+				return nil, err
+			}
+			if err := dict.SetKey(starlark.String(key), field); err != nil {
+				return nil, err
+			}
+		}
+		return mockObject{dict}, nil
+	case []any:
+		elems := make([]starlark.Value, 0, len(v))
+		for _, elemValue := range v {
+			elem, err := mockValue(elemValue)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, elem)
+		}
+		return starlark.NewList(elems), nil
+	case float64:
+		if v == float64(int64(v)) {
+			return starlark.MakeInt64(int64(v)), nil
+		}
+		return starlark.Float(v), nil
+	default:
+		return starlark_type.MarshalStarlark(v)
+	}
+}
+
+// mockObject is a dict from a fixture, with the keys also available as attributes. A key with
+// the () suffix is a method which returns the value, like response.json() for the http plugin
+type mockObject struct {
+	*starlark.Dict
+}
+
+var _ starlark.HasAttrs = mockObject{}
+var _ starlark_type.TypeUnmarshaler = mockObject{}
+
+func (m mockObject) Type() string {
+	return "mock_object"
+}
+
+func (m mockObject) CompareSameType(op syntax.Token, y starlark.Value, depth int) (bool, error) {
+	return m.Dict.CompareSameType(op, y.(mockObject).Dict, depth)
+}
+
+func (m mockObject) UnmarshalStarlarkType() (any, error) {
+	return starlark_type.UnmarshalStarlark(m.Dict)
+}
+
+func (m mockObject) Attr(name string) (starlark.Value, error) {
+	if value, found, _ := m.Dict.Get(starlark.String(name)); found {
+		return value, nil
+	}
+	if value, found, _ := m.Dict.Get(starlark.String(name + mockMethodSuffix)); found {
+		return starlark.NewBuiltin(name, func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			return value, nil
+		}), nil
+	}
+	return m.Dict.Attr(name)
+}
+
+func (m mockObject) AttrNames() []string {
+	names := m.Dict.AttrNames()
+	for _, key := range m.Dict.Keys() {
+		if name, ok := key.(starlark.String); ok {
+			names = append(names, strings.TrimSuffix(string(name), mockMethodSuffix))
+		}
+	}
+	return names
+}
@@ -0,0 +1,182 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+package app
+
+import (
+	"errors"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+func TestPluginMockerDevOnly(t *testing.T) {
+	config := types.MockConfig{Plugins: []string{"http.in.*"}}
+	logger := testutil.TestLogger()
+	testutil.AssertEqualsBool(t, "prod", true, newPluginMocker(logger, false, config) == nil)
+	testutil.AssertEqualsBool(t, "dev", true, newPluginMocker(logger, true, config) != nil)
+	testutil.AssertEqualsBool(t, "no plugins", true, newPluginMocker(logger, true, types.MockConfig{}) == nil)
+	testutil.AssertEqualsString(t, "default dir", "mocks/http.in/get.json", newPluginMocker(logger, true, config).fixturePath("http.in", "get"))
+}
+
+// mapFixtureFS is an in memory app source for the fixture files
+type mapFixtureFS map[string]string
+
+func (m mapFixtureFS) ReadFile(name string) ([]byte, error) {
+	data, ok := m[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	return []byte(data), nil
+}
+
+func (m mapFixtureFS) Write(name string, data []byte) error {
+	m[name] = string(data)
+	return nil
+}
+
+func noSecrets() []string { return nil }
+
+func TestPluginMocker(t *testing.T) {
+	fixtures := mapFixtureFS{
+		"mocks/http.in/get.json": `[
+			{"args": ["https://example.com/users"], "value": {"status_code": 200, "json()": {"users": ["a", "b"]}}},
+			{"kwargs": {"params": {"id": 2}}, "value": {"status_code": 404}},
+			{"args": ["https://example.com/down"], "error": "connection refused"}
+		]`,
+		"mocks/http.in/post.json": `{"value": {"status_code": 201}}`,
+	}
+	calls := 0
+	fn := func(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		calls++
+		return starlark.None, nil
+	}
+	m := newPluginMocker(testutil.TestLogger(), true, types.MockConfig{Plugins: []string{"http.in.*"}})
+	thread := &starlark.Thread{}
+	call := func(functionName string, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		return m.wrapPlugin(fixtures, "http.in", functionName, noSecrets, fn)(thread, nil, args, kwargs)
+	}
+
+	value, err := call("get", starlark.Tuple{starlark.String("https://example.com/users")}, nil)
+	testutil.AssertNoError(t, err)
+	statusCode, err := value.(starlark.HasAttrs).Attr("status_code")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "status code", "200", statusCode.String())
+	jsonMethod, err := value.(starlark.HasAttrs).Attr("json")
+	testutil.AssertNoError(t, err)
+	body, err := starlark.Call(thread, jsonMethod, nil, nil)
+	testutil.AssertNoError(t, err)
+	users, err := body.(starlark.HasAttrs).Attr("users")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "users", `["a", "b"]`, users.String())
+
+	// Entries are matched by the kwargs
+	params := starlark.NewDict(1)
+	params.SetKey(starlark.String("id"), starlark.MakeInt(2)) //nolint:errcheck
+	value, err = call("get", starlark.Tuple{starlark.String("https://example.com/other")},
+		[]starlark.Tuple{{starlark.String("params"), params}})
+	testutil.AssertNoError(t, err)
+	statusCode, err = value.(starlark.HasAttrs).Attr("status_code")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "status code", "404", statusCode.String())
+
+	_, err = call("get", starlark.Tuple{starlark.String("https://example.com/down")}, nil)
+	testutil.AssertErrorContains(t, err, "connection refused")
+	_, err = call("get", starlark.Tuple{starlark.String("https://example.com/other")}, nil)
+	testutil.AssertErrorContains(t, err, "no mock response in mocks/http.in/get.json matches the call args")
+
+	// A single entry matches all calls
+	_, err = call("post", starlark.Tuple{starlark.String("https://example.com/users")}, nil)
+	testutil.AssertNoError(t, err)
+	_, err = call("delete", nil, nil)
+	testutil.AssertErrorContains(t, err, "no mock fixture for http.in.delete, create mocks/http.in/delete.json")
+	testutil.AssertEqualsInt(t, "plugin not called", 0, calls)
+
+	// Plugins not matching the patterns are called
+	_, err = m.wrapPlugin(fixtures, "store.in", "select", noSecrets, fn)(thread, nil, nil, nil)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "plugin called", 1, calls)
+}
+
+func TestPluginMockerRecord(t *testing.T) {
+	fixtures := mapFixtureFS{}
+	response := func(statusCode int) starlark.Value {
+		headers := starlark.NewDict(2)
+		headers.SetKey(starlark.String("Content-Type"), starlark.String("application/json")) //nolint:errcheck
+		headers.SetKey(starlark.String("Set-Cookie"), starlark.String("session=abc"))        //nolint:errcheck
+		body := starlark.NewDict(2)
+		body.SetKey(starlark.String("users"), starlark.NewList([]starlark.Value{starlark.String("a")})) //nolint:errcheck
+		body.SetKey(starlark.String("access_token"), starlark.String("tok123"))                         //nolint:errcheck
+		return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+			"status_code": starlark.MakeInt(statusCode),
+			"headers":     headers,
+			"json": starlark.NewBuiltin("json", func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+				return body, nil
+			}),
+		})
+	}
+	statusCode := 200
+	fn := func(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if strings.HasPrefix(string(args[0].(starlark.String)), "https://example.com/down") {
+			return nil, errors.New("connection refused")
+		}
+		return response(statusCode), nil
+	}
+	thread := &starlark.Thread{}
+	secrets := func() []string { return []string{"key123"} }
+	recorder := newPluginMocker(testutil.TestLogger(), true, types.MockConfig{Plugins: []string{"http.in.*"}, Record: true})
+	call := func(url string) (starlark.Value, error) {
+		headers := starlark.NewDict(1)
+		headers.SetKey(starlark.String("Authorization"), starlark.String("Bearer key123")) //nolint:errcheck
+		return recorder.wrapPlugin(fixtures, "http.in", "get", secrets, fn)(thread, nil,
+			starlark.Tuple{starlark.String(url + "?api=key123")}, []starlark.Tuple{{starlark.String("headers"), headers}})
+	}
+
+	value, err := call("https://example.com/users")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "type", "struct", value.Type())
+	_, err = call("https://example.com/down")
+	testutil.AssertErrorContains(t, err, "connection refused")
+	// A call with the same args replaces the earlier recording
+	statusCode = 201
+	_, err = call("https://example.com/users")
+	testutil.AssertNoError(t, err)
+
+	entries, err := parseMockEntries([]byte(fixtures["mocks/http.in/get.json"]))
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "entries", 2, len(entries))
+	testutil.AssertEqualsString(t, "arg", "https://example.com/users?api=[REDACTED]", entries[0].Args[0].(string))
+	testutil.AssertEqualsString(t, "auth", "[REDACTED]", entries[0].Kwargs["headers"].(map[string]any)["Authorization"].(string))
+	testutil.AssertEqualsString(t, "error", "connection refused", entries[1].Error)
+	for _, secret := range []string{"key123", "abc", "tok123"} {
+		if strings.Contains(fixtures["mocks/http.in/get.json"], secret) {
+			t.Errorf("fixture has secret %s: %s", secret, fixtures["mocks/http.in/get.json"])
+		}
+	}
+
+	// The recorded calls are replayed, the redacted values match any value
+	replayer := newPluginMocker(testutil.TestLogger(), true, types.MockConfig{Plugins: []string{"http.in.*"}})
+	headers := starlark.NewDict(1)
+	headers.SetKey(starlark.String("Authorization"), starlark.String("Bearer other")) //nolint:errcheck
+	value, err = replayer.wrapPlugin(fixtures, "http.in", "get", noSecrets, fn)(thread, nil,
+		starlark.Tuple{starlark.String("https://example.com/users?api=other")}, []starlark.Tuple{{starlark.String("headers"), headers}})
+	testutil.AssertNoError(t, err)
+	status, err := value.(starlark.HasAttrs).Attr("status_code")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "status code", "201", status.String())
+	jsonMethod, err := value.(starlark.HasAttrs).Attr("json")
+	testutil.AssertNoError(t, err)
+	body, err := starlark.Call(thread, jsonMethod, nil, nil)
+	testutil.AssertNoError(t, err)
+	users, err := body.(starlark.HasAttrs).Attr("users")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "users", `["a"]`, users.String())
+
+	_, err = replayer.wrapPlugin(fixtures, "http.in", "get", noSecrets, fn)(thread, nil,
+		starlark.Tuple{starlark.String("https://example.com/other?api=x")}, []starlark.Tuple{{starlark.String("headers"), headers}})
+	testutil.AssertErrorContains(t, err, "no mock response in mocks/http.in/get.json matches the call args")
+}
@@ -0,0 +1,297 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/app/apptype"
+	"github.com/openrundev/openrun/internal/app/starlark_type"
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+)
+
+// schemaRef refers to a type for a request or response body, a schema.star type or a basic type
+// like STRING. List is set if the body is a list of the type
+type schemaRef struct {
+	Type string
+	List bool
+}
+
+// apiRoute is an API route declared in the app definition, used for the OpenAPI spec
+type apiRoute struct {
+	Path     string
+	Method   string
+	Type     string // JSON or TEXT
+	Doc      string // handler doc string
+	Request  *schemaRef
+	Response *schemaRef
+	Query    map[string]starlark_type.TypeName
+}
+
+var basicTypes = []starlark_type.TypeName{starlark_type.INT, starlark_type.FLOAT, starlark_type.STRING,
+	starlark_type.BOOLEAN, starlark_type.DATETIME, starlark_type.LIST, starlark_type.DICT}
+
+// newAPIRoute reads the OpenAPI annotations of an API route. The request and response types are
+// either a type name or a list with one type name, for a list body
+func (a *App) newAPIRoute(fullPath, method, rtype string, apiDef *starlarkstruct.Struct, handler starlark.Callable) (apiRoute, error) {
+	route := apiRoute{Path: fullPath, Method: strings.ToUpper(method), Type: rtype}
+	if fn, ok := handler.(*starlark.Function); ok {
+		route.Doc = strings.TrimSpace(fn.Doc())
+	}
+
+	var err error
+	if route.Request, err = a.readSchemaRef(apiDef, "request"); err != nil {
+		return route, err
+	}
+	if route.Response, err = a.readSchemaRef(apiDef, "response"); err != nil {
+		return route, err
+	}
+
+	queryAttr, _ := apiDef.Attr("query")
+	if queryAttr == nil {
+		return route, nil
+	}
+	queryDict, ok := queryAttr.(*starlark.Dict)
+	if !ok {
+		return route, fmt.Errorf("query is not a dict")
+	}
+	route.Query = map[string]starlark_type.TypeName{}
+	for _, item := range queryDict.Items() {
+		name, ok := item[0].(starlark.String)
+		if !ok {
+			return route, fmt.Errorf("query param name %s is not a string", item[0])
+		}
+		typeName, ok := item[1].(starlark.String)
+		if !ok || !slices.Contains(basicTypes, starlark_type.TypeName(strings.ToUpper(typeName.GoString()))) {
+			return route, fmt.Errorf("query param %s has invalid type %s", name.GoString(), item[1])
+		}
+		route.Query[name.GoString()] = starlark_type.TypeName(strings.ToUpper(typeName.GoString()))
+	}
+	return route, nil
+}
+
+func (a *App) readSchemaRef(apiDef *starlarkstruct.Struct, key string) (*schemaRef, error) {
+	value, _ := apiDef.Attr(key)
+	if value == nil || value == starlark.None {
+		return nil, nil
+	}
+
+	ref := &schemaRef{}
+	if list, ok := value.(*starlark.List); ok {
+		if list.Len() != 1 {
+			return nil, fmt.Errorf("%s list should have one type name", key)
+		}
+		ref.List = true
+		value = list.Index(0)
+	}
+	typeName, ok := value.(starlark.String)
+	if !ok {
+		return nil, fmt.Errorf("%s should be a type name, got %s", key, value.Type())
+	}
+	ref.Type = typeName.GoString()
+	if a.storeType(ref.Type) != nil {
+		return ref, nil
+	}
+	if basic := strings.ToUpper(ref.Type); slices.Contains(basicTypes, starlark_type.TypeName(basic)) {
+		ref.Type = basic
+		return ref, nil
+	}
+	return nil, fmt.Errorf("%s type %s is not defined in %s", key, ref.Type, apptype.SCHEMA_FILE_NAME)
+}
+
+func (a *App) storeType(name string) *starlark_type.StoreType {
+	if a.storeInfo == nil {
+		return nil
+	}
+	for i := range a.storeInfo.Types {
+		if a.storeInfo.Types[i].Name == name {
+			return &a.storeInfo.Types[i]
+		}
+	}
+	return nil
+}
+
+// pathParamRegex matches the chi path params, like {id} or {id:[0-9]+}
+var pathParamRegex = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// openAPIPath converts a chi route path to the OpenAPI format. The regex of a path param is
+// dropped and a trailing wildcard is shown as the path param "wildcard"
+func openAPIPath(routePath string) (string, []string) {
+	params := []string{}
+	for _, match := range pathParamRegex.FindAllStringSubmatch(routePath, -1) {
+		params = append(params, match[1])
+	}
+	ret := pathParamRegex.ReplaceAllString(routePath, "{$1}")
+	if strings.HasSuffix(ret, "*") {
+		ret = strings.TrimSuffix(ret, "*") + "{wildcard}"
+		params = append(params, "wildcard")
+	}
+	return ret, params
+}
+
+func basicTypeSchema(typeName starlark_type.TypeName) map[string]any {
+	switch typeName {
+	case starlark_type.INT:
+		return map[string]any{"type": "integer"}
+	case starlark_type.FLOAT:
+		return map[string]any{"type": "number"}
+	case starlark_type.BOOLEAN:
+		return map[string]any{"type": "boolean"}
+	case starlark_type.DATETIME:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case starlark_type.LIST:
+		return map[string]any{"type": "array", "items": map[string]any{}}
+	case starlark_type.DICT:
+		return map[string]any{"type": "object"}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
+
+// refSchema returns the schema for a request or response body, adding the referenced schema.star
+// type to the spec components
+func (a *App) refSchema(ref *schemaRef, components map[string]any) map[string]any {
+	var schema map[string]any
+	if storeType := a.storeType(ref.Type); storeType != nil {
+		if _, ok := components[storeType.Name]; !ok {
+			properties := map[string]any{"_id": map[string]any{"type": "integer"}}
+			for _, field := range storeType.Fields {
+				properties[field.Name] = basicTypeSchema(field.Type)
+			}
+			components[storeType.Name] = map[string]any{"type": "object", "properties": properties}
+		}
+		schema = map[string]any{"$ref": "#/components/schemas/" + storeType.Name}
+	} else {
+		schema = basicTypeSchema(starlark_type.TypeName(ref.Type))
+	}
+	if ref.List {
+		return map[string]any{"type": "array", "items": schema}
+	}
+	return schema
+}
+
+// openAPISpec generates an OpenAPI 3 document for the API routes of the app. Paths are relative
+// to the app path, which is set as the server url
+func (a *App) openAPISpec() map[string]any {
+	a.initMutex.Lock()
+	routes := slices.Clone(a.apiRoutes)
+	a.initMutex.Unlock()
+
+	paths := map[string]any{}
+	components := map[string]any{}
+	for _, route := range routes {
+		specPath, pathParams := openAPIPath(route.Path)
+		params := []any{}
+		for _, name := range pathParams {
+			params = append(params, map[string]any{"name": name, "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+		}
+		queryNames := make([]string, 0, len(route.Query))
+		for name := range route.Query {
+			queryNames = append(queryNames, name)
+		}
+		slices.Sort(queryNames)
+		for _, name := range queryNames {
+			params = append(params, map[string]any{"name": name, "in": "query", "schema": basicTypeSchema(route.Query[name])})
+		}
+
+		operation := map[string]any{
+			"operationId": strings.ToLower(route.Method) + strings.NewReplacer("/", "_", "{", "", "}", "").Replace(specPath),
+		}
+		if route.Doc != "" {
+			summary, description, _ := strings.Cut(route.Doc, "\n")
+			operation["summary"] = strings.TrimSpace(summary)
+			if description = strings.TrimSpace(description); description != "" {
+				operation["description"] = description
+			}
+		}
+		if len(params) > 0 {
+			operation["parameters"] = params
+		}
+		if route.Request != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": a.refSchema(route.Request, components)}},
+			}
+		}
+
+		responseContent := map[string]any{"text/plain": map[string]any{"schema": map[string]any{"type": "string"}}}
+		if route.Type == apptype.JSON {
+			schema := map[string]any{}
+			if route.Response != nil {
+				schema = a.refSchema(route.Response, components)
+			}
+			responseContent = map[string]any{"application/json": map[string]any{"schema": schema}}
+		}
+		operation["responses"] = map[string]any{
+			"200": map[string]any{"description": "Success", "content": responseContent},
+		}
+
+		pathItem, ok := paths[specPath].(map[string]any)
+		if !ok {
+			pathItem = map[string]any{}
+			paths[specPath] = pathItem
+		}
+		pathItem[strings.ToLower(route.Method)] = operation
+	}
+
+	spec := map[string]any{
+		"openapi": "3.0.3",
+		"info":    map[string]any{"title": a.Name, "version": strconv.Itoa(a.Metadata.VersionMetadata.Version)},
+		"servers": []any{map[string]any{"url": a.Path}},
+		"paths":   paths,
+	}
+	if len(components) > 0 {
+		spec["components"] = map[string]any{"schemas": components}
+	}
+	return spec
+}
+
+func (a *App) openAPIHandler(w http.ResponseWriter, r *http.Request) {
+	a.initMutex.Lock()
+	noRoutes := len(a.apiRoutes) == 0
+	a.initMutex.Unlock()
+	if noRoutes {
+		http.Error(w, "404 No API routes defined", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(a.openAPISpec()) //nolint:errcheck
+}
+
+var swaggerUITemplate = template.Must(template.New("swagger").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>{{ .Title }} API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: {{ .SpecUrl }}, dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`))
+
+// swaggerUIHandler serves a Swagger UI page for the app OpenAPI spec. The Swagger UI assets are
+// loaded from unpkg.com
+func (a *App) swaggerUIHandler(w http.ResponseWriter, r *http.Request) {
+	specUrl := strings.TrimRight(a.Path, "/") + types.OPENAPI_URL_PREFIX + "/openapi.json"
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	swaggerUITemplate.Execute(w, map[string]string{"Title": a.Name, "SpecUrl": specUrl}) //nolint:errcheck
+}
@@ -14,6 +14,7 @@ import (
 	"unicode"
 	"unicode/utf8"
 
+	"github.com/openrundev/openrun/internal/app/appfs"
 	"github.com/openrundev/openrun/internal/app/apptype"
 	"github.com/openrundev/openrun/internal/plugin"
 	"github.com/openrundev/openrun/internal/rbac"
@@ -328,6 +329,14 @@ func (a *App) pluginHook(appPath, modulePath, accountName, functionName string,
 		if disallowed {
 			return nil, fmt.Errorf("app %s is not permitted to call %s.%s: the call is disallowed by the server config (permissions.disallow)", a.Path, modulePath, functionName)
 		}
+		if pluginDenied(a.AppConfig.Security.DeniedPlugins, modulePath, functionName) {
+			return nil, fmt.Errorf("app %s is not permitted to call %s.%s: the call is denied by the app config (security.denied_plugins)", a.Path, modulePath, functionName)
+		}
+		// Read only threads, like the REPL default mode, use the plugin defined type. The is_read
+		// override in the app permissions is not used, so a write call is never run
+		if readOnly, _ := thread.Local(types.TL_READ_ONLY).(bool); readOnly && !pluginInfo.IsRead {
+			return nil, fmt.Errorf("%s.%s is a write operation, it is not permitted in read only mode", modulePath, functionName)
+		}
 
 		permsList := append([]types.Permission(nil), a.Metadata.Permissions...)
 		if len(a.serverConfig.Permissions.Allow) > 0 {
@@ -370,8 +379,21 @@ func (a *App) pluginHook(appPath, modulePath, accountName, functionName string,
 		}
 		thread.SetLocal(types.TL_PLUGIN_API_FAILED_ERROR, nil)
 
-		// Wrap the plugin function call with error handling
-		errorHandlingWrapper := pluginErrorWrapper(builtinFunc, a.errorHandler)
+		// secretValues are the secrets resolved in the args, redacted when recording the call
+		var secretValues []string
+		evalSecret := func(value string) (string, error) {
+			ret, err := a.secretEvalFunc(secrets, a.AppConfig.Security.DefaultSecretsProvider, value)
+			if err == nil && ret != value {
+				secretValues = append(secretValues, ret)
+			}
+			return ret, err
+		}
+
+		// Wrap the plugin function call with error handling. For dev apps, mocked calls get the
+		// response from the fixture file instead of calling the plugin
+		pluginFunc := a.mocks.wrapPlugin(&appfs.WritableSourceFs{SourceFs: a.sourceFS}, modulePath, functionName,
+			func() []string { return secretValues }, a.faults.wrapPlugin(modulePath, functionName, builtinFunc))
+		errorHandlingWrapper := pluginErrorWrapper(pluginFunc, a.errorHandler)
 
 		// Pass the module full path as a thread local
 		thread.SetLocal(types.TL_CURRENT_MODULE_FULL_PATH, modulePath)
@@ -381,7 +403,7 @@ func (a *App) pluginHook(appPath, modulePath, accountName, functionName string,
 			for i, arg := range args {
 				switch v := arg.(type) {
 				case starlark.String:
-					evalString, err := a.secretEvalFunc(secrets, a.AppConfig.Security.DefaultSecretsProvider, v.GoString())
+					evalString, err := evalSecret(v.GoString())
 					if err != nil {
 						return nil, err
 					}
@@ -390,7 +412,7 @@ func (a *App) pluginHook(appPath, modulePath, accountName, functionName string,
 					for i := 0; i < v.Len(); i++ {
 						switch sv := v.Index(i).(type) {
 						case starlark.String:
-							evalString, err := a.secretEvalFunc(secrets, a.AppConfig.Security.DefaultSecretsProvider, sv.GoString())
+							evalString, err := evalSecret(sv.GoString())
 							if err != nil {
 								return nil, err
 							}
@@ -405,7 +427,7 @@ func (a *App) pluginHook(appPath, modulePath, accountName, functionName string,
 						}
 						switch sv := value.(type) {
 						case starlark.String:
-							evalString, err := a.secretEvalFunc(secrets, a.AppConfig.Security.DefaultSecretsProvider, sv.GoString())
+							evalString, err := evalSecret(sv.GoString())
 							if err != nil {
 								return nil, err
 							}
@@ -419,7 +441,7 @@ func (a *App) pluginHook(appPath, modulePath, accountName, functionName string,
 			for i, kwarg := range kwargs {
 				switch v := kwarg[1].(type) {
 				case starlark.String:
-					evalString, err := a.secretEvalFunc(secrets, a.AppConfig.Security.DefaultSecretsProvider, v.GoString())
+					evalString, err := evalSecret(v.GoString())
 					if err != nil {
 						return nil, err
 
@@ -429,7 +451,7 @@ func (a *App) pluginHook(appPath, modulePath, accountName, functionName string,
 					for i := 0; i < v.Len(); i++ {
 						switch sv := v.Index(i).(type) {
 						case starlark.String:
-							evalString, err := a.secretEvalFunc(secrets, a.AppConfig.Security.DefaultSecretsProvider, sv.GoString())
+							evalString, err := evalSecret(sv.GoString())
 							if err != nil {
 								return nil, err
 							}
@@ -444,7 +466,7 @@ func (a *App) pluginHook(appPath, modulePath, accountName, functionName string,
 						}
 						switch sv := value.(type) {
 						case starlark.String:
-							evalString, err := a.secretEvalFunc(secrets, a.AppConfig.Security.DefaultSecretsProvider, sv.GoString())
+							evalString, err := evalSecret(sv.GoString())
 							if err != nil {
 								return nil, err
 							}
@@ -455,6 +477,9 @@ func (a *App) pluginHook(appPath, modulePath, accountName, functionName string,
 			}
 		}
 
+		// Time the plugin call if the request is being profiled
+		defer getProfiler(thread).pluginCall(thread, modulePath+"."+functionName)()
+
 		// Call the builtin function
 		newBuiltin := starlark.NewBuiltin(functionName, errorHandlingWrapper)
 		// Plugin spans are gated separately because data-heavy apps may issue
@@ -0,0 +1,216 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fmt"
+	"html/template"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// flameNode is a frame in the flame view, Value is the time in microseconds
+type flameNode struct {
+	Name     string
+	Value    int64
+	Pct      float64 // width relative to the parent
+	Children []*flameNode
+}
+
+func (f *flameNode) Ms() string {
+	return strconv.FormatFloat(float64(f.Value)/1000, 'f', 2, 64)
+}
+
+func (f *flameNode) Class() string {
+	switch {
+	case strings.HasPrefix(f.Name, PROFILE_PLUGIN_PREFIX):
+		return "plugin"
+	case f.Name == PROFILE_RENDER_FRAME:
+		return "render"
+	default:
+		return "func"
+	}
+}
+
+// buildFlameTree returns the tree of frames for the folded stacks, the children are sorted by name
+// so that the same stacks line up across reloads
+func buildFlameTree(stacks map[string]int64) *flameNode {
+	root := &flameNode{Name: "all", Pct: 100}
+	index := map[*flameNode]map[string]*flameNode{}
+	for _, stack := range slices.Sorted(maps.Keys(stacks)) {
+		us := stacks[stack]
+		root.Value += us
+		node := root
+		for _, frame := range strings.Split(stack, ";") {
+			if index[node] == nil {
+				index[node] = map[string]*flameNode{}
+			}
+			child, ok := index[node][frame]
+			if !ok {
+				child = &flameNode{Name: frame}
+				index[node][frame] = child
+				node.Children = append(node.Children, child)
+			}
+			child.Value += us
+			node = child
+		}
+	}
+	setFlamePct(root)
+	return root
+}
+
+func setFlamePct(node *flameNode) {
+	slices.SortFunc(node.Children, func(a, b *flameNode) int { return strings.Compare(a.Name, b.Name) })
+	for _, child := range node.Children {
+		if node.Value > 0 {
+			child.Pct = float64(child.Value) * 100 / float64(node.Value)
+		}
+		setFlamePct(child)
+	}
+}
+
+var flameViewTemplate = template.Must(template.New("flame").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8" />
+  <title>{{.Title}} - Profile</title>
+  <style>
+    body { font-family: sans-serif; font-size: 13px; margin: 1em; }
+    table { border-collapse: collapse; margin-bottom: 1em; }
+    td, th { border: 1px solid #ccc; padding: 2px 8px; text-align: left; }
+    .flame { width: 100%; }
+    .node { box-sizing: border-box; overflow: hidden; }
+    .label { white-space: nowrap; overflow: hidden; text-overflow: ellipsis; padding: 1px 3px;
+      border: 1px solid #fff; cursor: default; }
+    .func { background: #f5b971; }
+    .plugin { background: #8fc1e3; }
+    .render { background: #b8d8a8; }
+    .children { display: flex; }
+  </style>
+</head>
+<body>
+  <h3>{{.Title}} - Handler Profile</h3>
+  {{if .Status}}
+  <p>Active: {{.Status.Active}}, sampled requests: {{.Status.Sampled}}, kept profiles: {{.Status.ProfileCount}} (slowest {{.Status.MaxProfiles}})</p>
+  {{else}}
+  <p>Profiling is not started.</p>
+  {{end}}
+  <form method="post" action="{{.BaseUrl}}" style="display:inline"><button>Start</button></form>
+  <form method="post" action="{{.BaseUrl}}/stop" style="display:inline"><button>Stop</button></form>
+  <a href="{{.BaseUrl}}?format=json">JSON</a>
+
+  {{if .Profiles}}
+  <h4>Requests</h4>
+  <table>
+    <tr><th></th><th>Time</th><th>Request</th><th>Handler</th><th>Duration (ms)</th></tr>
+    <tr><td><a href="{{.BaseUrl}}">all</a></td><td colspan="4">Merged profile</td></tr>
+    {{range $i, $p := .Profiles}}
+    <tr><td><a href="{{$.BaseUrl}}?profile={{$i}}">view</a></td><td>{{$p.Time.Format "15:04:05.000"}}</td>
+      <td>{{$p.Method}} {{$p.Path}}</td><td>{{$p.Handler}}</td><td>{{printf "%.2f" $p.DurationMs}}</td></tr>
+    {{end}}
+  </table>
+
+  <h4>{{.Selected}}</h4>
+  <div class="flame">{{template "node" .Tree}}</div>
+  {{end}}
+</body>
+</html>
+{{define "node"}}<div class="node" style="width: {{printf "%.4f" .Pct}}%"><div class="label {{.Class}}" title="{{.Name}}: {{.Ms}} ms">{{.Name}} {{.Ms}} ms</div>{{if .Children}}<div class="children">{{range .Children}}{{template "node" .}}{{end}}</div>{{end}}</div>{{end}}
+`))
+
+// createProfileRoutes adds the flame view for the handler profiles, for dev apps only
+func (a *App) createProfileRoutes(router *chi.Mux) {
+	router.Get(types.APP_INTERNAL_URL_PREFIX+"/profile", a.profileViewHandler)
+	router.Post(types.APP_INTERNAL_URL_PREFIX+"/profile", a.profileStartHandler)
+	router.Post(types.APP_INTERNAL_URL_PREFIX+"/profile/stop", a.profileStopHandler)
+}
+
+func (a *App) profileBaseUrl() string {
+	return strings.TrimSuffix(a.Path, "/") + types.APP_INTERNAL_URL_PREFIX + "/profile"
+}
+
+func (a *App) profileViewHandler(w http.ResponseWriter, r *http.Request) {
+	status, profiles, ok := a.profiles.Profiles(a.Id)
+	merged := MergeProfiles(profiles)
+	if r.URL.Query().Get("format") == "json" {
+		status.AppPath = a.Path
+		writeDebugResponse(w, types.ProfileDownloadResponse{Status: status, Merged: merged, Profiles: profiles})
+		return
+	}
+
+	selected := "Merged profile"
+	stacks := merged.Stacks
+	if indexStr := r.URL.Query().Get("profile"); indexStr != "" {
+		index, err := strconv.Atoi(indexStr)
+		if err != nil || index < 0 || index >= len(profiles) {
+			http.Error(w, "invalid profile: "+indexStr, http.StatusBadRequest)
+			return
+		}
+		selected = fmt.Sprintf("%s %s (%.2f ms)", profiles[index].Method, profiles[index].Path, profiles[index].DurationMs)
+		stacks = profiles[index].Stacks
+	}
+
+	data := map[string]any{
+		"Title":    a.Name,
+		"BaseUrl":  a.profileBaseUrl(),
+		"Profiles": profiles,
+		"Selected": selected,
+		"Tree":     buildFlameTree(stacks),
+		"Status":   nil,
+	}
+	if ok {
+		data["Status"] = status
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := flameViewTemplate.Execute(w, data); err != nil {
+		a.Error().Err(err).Msg("error rendering profile view")
+	}
+}
+
+func (a *App) profileStartHandler(w http.ResponseWriter, r *http.Request) {
+	if a.profiles == nil {
+		http.Error(w, "profiling is not available", http.StatusServiceUnavailable)
+		return
+	}
+	opts := ProfileOptions{}
+	if rate := r.URL.Query().Get("sample_rate"); rate != "" {
+		var err error
+		if opts.SampleRate, err = strconv.ParseFloat(rate, 64); err != nil {
+			http.Error(w, "invalid sample_rate: "+rate, http.StatusBadRequest)
+			return
+		}
+	}
+	status := a.profiles.Start(a.Id, opts)
+	a.Info().Float64("sample_rate", status.SampleRate).Msg("Started handler profiling")
+	a.profileRedirect(w, r, status)
+}
+
+func (a *App) profileStopHandler(w http.ResponseWriter, r *http.Request) {
+	if a.profiles == nil {
+		http.Error(w, "profiling is not available", http.StatusServiceUnavailable)
+		return
+	}
+	status, ok := a.profiles.Stop(a.Id, false)
+	if !ok {
+		http.Error(w, "profiling is not started", http.StatusNotFound)
+		return
+	}
+	a.profileRedirect(w, r, status)
+}
+
+// profileRedirect sends the browser form posts back to the flame view, API clients get the status
+func (a *App) profileRedirect(w http.ResponseWriter, r *http.Request, status types.ProfileStatus) {
+	if strings.Contains(r.Header.Get("Accept"), "text/html") {
+		http.Redirect(w, r, a.profileBaseUrl(), http.StatusSeeOther)
+		return
+	}
+	status.AppPath = a.Path
+	writeDebugResponse(w, status)
+}
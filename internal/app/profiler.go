@@ -0,0 +1,431 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+)
+
+const (
+	DEFAULT_PROFILE_DURATION = 10 * time.Minute
+	MAX_PROFILE_DURATION     = 24 * time.Hour
+	DEFAULT_PROFILE_COUNT    = 50
+	MAX_PROFILE_COUNT        = 500
+
+	// profileSampleSteps is the number of Starlark steps between the stack samples
+	profileSampleSteps = 200
+	// profileMaxDepth is the max number of frames recorded for a stack, deeper frames are dropped
+	profileMaxDepth = 64
+
+	PROFILE_PLUGIN_PREFIX = "plugin:"
+	PROFILE_RENDER_FRAME  = "<render>"
+	PROFILE_NO_HANDLER    = "<no_handler>"
+)
+
+// ProfileOptions are the settings for a profiling session. SampleRate is the fraction of the
+// requests which are profiled, between 0 and 1
+type ProfileOptions struct {
+	Duration    time.Duration
+	SampleRate  float64
+	MaxProfiles int
+}
+
+// ProfileRegistry tracks the handler profiling sessions for the apps served by this server node.
+// Profiling is opt-in: when no session was ever started for any app, the request path does a
+// single atomic load. Like the capture sessions, the profiles are kept in memory keyed by app id
+type ProfileRegistry struct {
+	mu       sync.RWMutex
+	sessions map[types.AppId]*profileSession
+	count    atomic.Int32
+}
+
+func NewProfileRegistry() *ProfileRegistry {
+	return &ProfileRegistry{sessions: map[types.AppId]*profileSession{}}
+}
+
+type profileSession struct {
+	mu        sync.Mutex
+	opts      ProfileOptions
+	startTime time.Time
+	endTime   time.Time
+	profiles  []types.HandlerProfile
+	sampled   int
+}
+
+// Start begins a new profiling session for the app, discarding any profiles recorded by an
+// earlier session
+func (p *ProfileRegistry) Start(appId types.AppId, opts ProfileOptions) types.ProfileStatus {
+	opts = normalizeProfileOptions(opts)
+	now := time.Now()
+	session := &profileSession{
+		opts:      opts,
+		startTime: now,
+		endTime:   now.Add(opts.Duration),
+		profiles:  make([]types.HandlerProfile, 0),
+	}
+
+	p.mu.Lock()
+	if _, ok := p.sessions[appId]; !ok {
+		p.count.Add(1)
+	}
+	p.sessions[appId] = session
+	p.mu.Unlock()
+	return session.status()
+}
+
+// Stop ends the profiling session for the app. The profiles are kept for download, unless
+// clearProfiles is set
+func (p *ProfileRegistry) Stop(appId types.AppId, clearProfiles bool) (types.ProfileStatus, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	session, ok := p.sessions[appId]
+	if !ok {
+		return types.ProfileStatus{}, false
+	}
+
+	if clearProfiles {
+		delete(p.sessions, appId)
+		p.count.Add(-1)
+	}
+
+	session.mu.Lock()
+	if now := time.Now(); session.endTime.After(now) {
+		session.endTime = now
+	}
+	session.mu.Unlock()
+	return session.status(), true
+}
+
+// Profiles returns a copy of the profiles recorded for the app, slowest first
+func (p *ProfileRegistry) Profiles(appId types.AppId) (types.ProfileStatus, []types.HandlerProfile, bool) {
+	session := p.session(appId)
+	if session == nil {
+		return types.ProfileStatus{}, nil, false
+	}
+
+	session.mu.Lock()
+	profiles := slices.Clone(session.profiles)
+	session.mu.Unlock()
+	slices.SortStableFunc(profiles, func(a, b types.HandlerProfile) int {
+		return cmp.Compare(b.DurationMs, a.DurationMs)
+	})
+	return session.status(), profiles, true
+}
+
+func (p *ProfileRegistry) session(appId types.AppId) *profileSession {
+	if p == nil || p.count.Load() == 0 {
+		return nil
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.sessions[appId]
+}
+
+// recorder returns the session to record into if profiling is active for the app and the
+// request is picked by the sampling
+func (p *ProfileRegistry) recorder(appId types.AppId) *profileSession {
+	session := p.session(appId)
+	if session == nil || !session.sample(time.Now()) {
+		return nil
+	}
+	return session
+}
+
+func normalizeProfileOptions(opts ProfileOptions) ProfileOptions {
+	if opts.Duration <= 0 {
+		opts.Duration = DEFAULT_PROFILE_DURATION
+	}
+	opts.Duration = min(opts.Duration, MAX_PROFILE_DURATION)
+	if opts.SampleRate <= 0 || opts.SampleRate > 1 {
+		opts.SampleRate = 1
+	}
+	if opts.MaxProfiles <= 0 {
+		opts.MaxProfiles = DEFAULT_PROFILE_COUNT
+	}
+	opts.MaxProfiles = min(opts.MaxProfiles, MAX_PROFILE_COUNT)
+	return opts
+}
+
+func (s *profileSession) sample(now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !now.Before(s.endTime) {
+		return false
+	}
+	if s.opts.SampleRate < 1 && rand.Float64() >= s.opts.SampleRate {
+		return false
+	}
+	s.sampled++
+	return true
+}
+
+func (s *profileSession) status() types.ProfileStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return types.ProfileStatus{
+		Active:       time.Now().Before(s.endTime),
+		StartTime:    s.startTime,
+		EndTime:      s.endTime,
+		SampleRate:   s.opts.SampleRate,
+		MaxProfiles:  s.opts.MaxProfiles,
+		ProfileCount: len(s.profiles),
+		Sampled:      s.sampled,
+	}
+}
+
+// add records the profile. When the session is full, the fastest profile is replaced if the new
+// one is slower, so that the slow requests are the ones kept
+func (s *profileSession) add(profile types.HandlerProfile) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.profiles) < s.opts.MaxProfiles {
+		s.profiles = append(s.profiles, profile)
+		return
+	}
+	fastest := 0
+	for i := range s.profiles {
+		if s.profiles[i].DurationMs < s.profiles[fastest].DurationMs {
+			fastest = i
+		}
+	}
+	if profile.DurationMs > s.profiles[fastest].DurationMs {
+		s.profiles[fastest] = profile
+	}
+}
+
+type pluginCallStats struct {
+	count int
+	total time.Duration
+	max   time.Duration
+}
+
+// handlerProfiler records the profile for one request. The Starlark call stack is sampled every
+// profileSampleSteps steps, the time since the previous sample is added to the sampled stack.
+// Plugin calls are timed exactly. The profiler is used only by the thread running the handler,
+// so no locking is required
+type handlerProfiler struct {
+	handler string
+	start   time.Time
+	last    time.Time
+	samples int
+	stacks  map[string]time.Duration
+	plugins map[string]*pluginCallStats
+}
+
+// startHandlerProfile sets up the thread for profiling. The thread should not be running yet
+func startHandlerProfile(thread *starlark.Thread, handler string) *handlerProfiler {
+	if handler == "" {
+		handler = PROFILE_NO_HANDLER
+	}
+	now := time.Now()
+	p := &handlerProfiler{
+		handler: handler,
+		start:   now,
+		last:    now,
+		stacks:  map[string]time.Duration{},
+		plugins: map[string]*pluginCallStats{},
+	}
+	thread.SetLocal(types.TL_PROFILER, p)
+	thread.OnMaxSteps = func(thread *starlark.Thread) {
+		p.record(foldStack(thread, 0), time.Now())
+		thread.SetMaxExecutionSteps(thread.ExecutionSteps() + profileSampleSteps)
+	}
+	thread.SetMaxExecutionSteps(thread.ExecutionSteps() + profileSampleSteps)
+	return p
+}
+
+// getProfiler returns the profiler for the thread, nil if the request is not being profiled
+func getProfiler(thread *starlark.Thread) *handlerProfiler {
+	p, _ := thread.Local(types.TL_PROFILER).(*handlerProfiler)
+	return p
+}
+
+func (p *handlerProfiler) record(stack string, now time.Time) {
+	if stack == "" {
+		stack = p.handler
+	}
+	p.stacks[stack] += now.Sub(p.last)
+	p.last = now
+	p.samples++
+}
+
+// foldStack returns the Starlark call stack as a ";" separated list of function names, outermost
+// first. skip is the number of frames to skip from the top, like the builtin being called
+func foldStack(thread *starlark.Thread, skip int) string {
+	frames := thread.CallStack()
+	frames = frames[:max(len(frames)-skip, 0)]
+	if len(frames) > profileMaxDepth {
+		frames = frames[:profileMaxDepth]
+	}
+	names := make([]string, 0, len(frames))
+	for _, frame := range frames {
+		names = append(names, strings.ReplaceAll(frame.Name, ";", "_"))
+	}
+	return strings.Join(names, ";")
+}
+
+// pluginCall starts timing a plugin call made from the thread. The time till the call is added to
+// the calling stack. The returned func has to be called once the plugin call is done
+func (p *handlerProfiler) pluginCall(thread *starlark.Thread, name string) func() {
+	if p == nil {
+		return func() {}
+	}
+	stack := foldStack(thread, 1) // skip the plugin builtin frame
+	start := time.Now()
+	p.record(stack, start)
+	return func() {
+		now := time.Now()
+		duration := now.Sub(start)
+		if stack == "" {
+			stack = p.handler
+		}
+		p.stacks[stack+";"+PROFILE_PLUGIN_PREFIX+name] += duration
+		p.last = now
+
+		stats, ok := p.plugins[name]
+		if !ok {
+			stats = &pluginCallStats{}
+			p.plugins[name] = stats
+		}
+		stats.count++
+		stats.total += duration
+		stats.max = max(stats.max, duration)
+	}
+}
+
+// handlerDone records the time till the handler function returned
+func (p *handlerProfiler) handlerDone() {
+	if p == nil {
+		return
+	}
+	p.record(p.handler, time.Now())
+}
+
+// finish returns the profile for the request. The time after the handler returned, for rendering
+// the response, is added under the render frame
+func (p *handlerProfiler) finish(method, path string) types.HandlerProfile {
+	now := time.Now()
+	if now.After(p.last) {
+		p.stacks[p.handler+";"+PROFILE_RENDER_FRAME] += now.Sub(p.last)
+		p.last = now
+	}
+
+	ret := types.HandlerProfile{
+		Time:        p.start,
+		Method:      method,
+		Path:        path,
+		Handler:     p.handler,
+		DurationMs:  durationMs(now.Sub(p.start)),
+		Samples:     p.samples,
+		Stacks:      make(map[string]int64, len(p.stacks)),
+		PluginCalls: make([]types.ProfilePluginCall, 0, len(p.plugins)),
+	}
+	for stack, duration := range p.stacks {
+		ret.Stacks[stack] = duration.Microseconds()
+	}
+	ret.Functions = profileFunctions(ret.Stacks)
+	for name, stats := range p.plugins {
+		ret.PluginCalls = append(ret.PluginCalls, types.ProfilePluginCall{
+			Name:    name,
+			Count:   stats.count,
+			TotalMs: durationMs(stats.total),
+			MaxMs:   durationMs(stats.max),
+		})
+	}
+	sortPluginCalls(ret.PluginCalls)
+	return ret
+}
+
+// profileFunctions returns the self and total time for the functions in the folded stacks,
+// slowest self time first. Recursive calls are counted once for the total time
+func profileFunctions(stacks map[string]int64) []types.ProfileFunction {
+	self := map[string]int64{}
+	total := map[string]int64{}
+	for stack, us := range stacks {
+		frames := strings.Split(stack, ";")
+		seen := map[string]bool{}
+		for i, frame := range frames {
+			if strings.HasPrefix(frame, PROFILE_PLUGIN_PREFIX) {
+				continue
+			}
+			if !seen[frame] {
+				seen[frame] = true
+				total[frame] += us
+			}
+			if i == len(frames)-1 {
+				self[frame] += us
+			}
+		}
+	}
+
+	ret := make([]types.ProfileFunction, 0, len(total))
+	for name, us := range total {
+		ret = append(ret, types.ProfileFunction{
+			Name:    name,
+			SelfMs:  float64(self[name]) / 1000,
+			TotalMs: float64(us) / 1000,
+		})
+	}
+	slices.SortFunc(ret, func(a, b types.ProfileFunction) int {
+		return cmp.Or(cmp.Compare(b.SelfMs, a.SelfMs), cmp.Compare(b.TotalMs, a.TotalMs), cmp.Compare(a.Name, b.Name))
+	})
+	return ret
+}
+
+// MergeProfiles returns the profile with the time across all the profiles added up. The plugin call
+// max time is the max across the profiles
+func MergeProfiles(profiles []types.HandlerProfile) types.HandlerProfile {
+	ret := types.HandlerProfile{
+		Stacks:      map[string]int64{},
+		PluginCalls: []types.ProfilePluginCall{},
+	}
+	plugins := map[string]*types.ProfilePluginCall{}
+	for _, profile := range profiles {
+		ret.DurationMs += profile.DurationMs
+		ret.Samples += profile.Samples
+		for stack, us := range profile.Stacks {
+			ret.Stacks[stack] += us
+		}
+		for _, call := range profile.PluginCalls {
+			merged, ok := plugins[call.Name]
+			if !ok {
+				merged = &types.ProfilePluginCall{Name: call.Name}
+				plugins[call.Name] = merged
+			}
+			merged.Count += call.Count
+			merged.TotalMs += call.TotalMs
+			merged.MaxMs = max(merged.MaxMs, call.MaxMs)
+		}
+	}
+	ret.Functions = profileFunctions(ret.Stacks)
+	for _, call := range plugins {
+		ret.PluginCalls = append(ret.PluginCalls, *call)
+	}
+	sortPluginCalls(ret.PluginCalls)
+	return ret
+}
+
+func sortPluginCalls(calls []types.ProfilePluginCall) {
+	slices.SortFunc(calls, func(a, b types.ProfilePluginCall) int {
+		return cmp.Or(cmp.Compare(b.TotalMs, a.TotalMs), cmp.Compare(a.Name, b.Name))
+	})
+}
+
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// SetProfileRegistry sets the registry used to look up the profiling session for the app
+func (a *App) SetProfileRegistry(registry *ProfileRegistry) {
+	a.profiles = registry
+}
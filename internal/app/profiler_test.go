@@ -0,0 +1,178 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+)
+
+const profileTestSource = `
+def compute(n):
+	total = 0
+	for i in range(n):
+		total += i * i
+	return total
+
+def handler(req):
+	total = compute(20000)
+	db_query()
+	return total
+`
+
+func TestHandlerProfile(t *testing.T) {
+	dbQuery := starlark.NewBuiltin("db_query", func(thread *starlark.Thread, _ *starlark.Builtin, _ starlark.Tuple, _ []starlark.Tuple) (starlark.Value, error) {
+		defer getProfiler(thread).pluginCall(thread, "store.in.select")()
+		time.Sleep(20 * time.Millisecond)
+		return starlark.None, nil
+	})
+	globals, err := starlark.ExecFile(&starlark.Thread{}, "app.star", profileTestSource, starlark.StringDict{"db_query": dbQuery})
+	testutil.AssertNoError(t, err)
+
+	thread := &starlark.Thread{}
+	profiler := startHandlerProfile(thread, "handler")
+	_, err = starlark.Call(thread, globals["handler"], starlark.Tuple{starlark.None}, nil)
+	testutil.AssertNoError(t, err)
+	profiler.handlerDone()
+	profile := profiler.finish(http.MethodGet, "/items")
+
+	testutil.AssertEqualsString(t, "path", "/items", profile.Path)
+	testutil.AssertEqualsString(t, "handler", "handler", profile.Handler)
+	if profile.Samples < 10 {
+		t.Errorf("expected stack samples, got %d", profile.Samples)
+	}
+	if _, ok := profile.Stacks["handler;compute"]; !ok {
+		t.Errorf("compute stack missing: %v", profile.Stacks)
+	}
+	if profile.Stacks["handler;plugin:store.in.select"] < 20000 {
+		t.Errorf("plugin time not recorded: %v", profile.Stacks)
+	}
+
+	testutil.AssertEqualsInt(t, "plugin calls", 1, len(profile.PluginCalls))
+	testutil.AssertEqualsString(t, "plugin name", "store.in.select", profile.PluginCalls[0].Name)
+	testutil.AssertEqualsInt(t, "plugin count", 1, profile.PluginCalls[0].Count)
+
+	// The time in the stacks adds up to the request duration
+	var total int64
+	for _, us := range profile.Stacks {
+		total += us
+	}
+	if diff := float64(total)/1000 - profile.DurationMs; diff > 1 || diff < -1 {
+		t.Errorf("stack time %d us does not match duration %f ms", total, profile.DurationMs)
+	}
+
+	functions := map[string]types.ProfileFunction{}
+	for _, f := range profile.Functions {
+		functions[f.Name] = f
+	}
+	if functions["handler"].TotalMs < 20 || functions["handler"].TotalMs < functions["compute"].TotalMs {
+		t.Errorf("unexpected handler total: %v", profile.Functions)
+	}
+	if _, ok := functions["plugin:store.in.select"]; ok {
+		t.Errorf("plugin listed as function: %v", profile.Functions)
+	}
+}
+
+func TestProfileFunctions(t *testing.T) {
+	functions := profileFunctions(map[string]int64{
+		"handler":                         1000,
+		"handler;fib;fib":                 3000,
+		"handler;fib":                     2000,
+		"handler;plugin:http.get":         5000,
+		"handler;" + PROFILE_RENDER_FRAME: 500,
+	})
+	got := []string{}
+	for _, f := range functions {
+		got = append(got, fmt.Sprintf("%s:%g:%g", f.Name, f.SelfMs, f.TotalMs))
+	}
+	testutil.AssertEqualsString(t, "functions", "fib:5:5 handler:1:11.5 <render>:0.5:0.5", strings.Join(got, " "))
+}
+
+func TestProfileRegistry(t *testing.T) {
+	registry := NewProfileRegistry()
+	testutil.AssertEqualsBool(t, "disabled", true, registry.recorder("app1") == nil)
+
+	status := registry.Start("app1", ProfileOptions{MaxProfiles: 2})
+	testutil.AssertEqualsBool(t, "active", true, status.Active)
+	testutil.AssertEqualsInt(t, "sample rate", 1, int(status.SampleRate))
+	testutil.AssertEqualsBool(t, "other app", true, registry.recorder("app2") == nil)
+
+	for _, duration := range []float64{10, 30, 5, 20} {
+		session := registry.recorder("app1")
+		testutil.AssertEqualsBool(t, "sampled", true, session != nil)
+		session.add(types.HandlerProfile{DurationMs: duration, Stacks: map[string]int64{"handler": int64(duration * 1000)}})
+	}
+
+	status, profiles, ok := registry.Profiles("app1")
+	testutil.AssertEqualsBool(t, "found", true, ok)
+	testutil.AssertEqualsInt(t, "sampled", 4, status.Sampled)
+	testutil.AssertEqualsInt(t, "kept", 2, len(profiles))
+	testutil.AssertEqualsString(t, "slowest kept", "30 20", fmt.Sprintf("%g %g", profiles[0].DurationMs, profiles[1].DurationMs))
+
+	merged := MergeProfiles(profiles)
+	testutil.AssertEqualsInt(t, "merged", 50000, int(merged.Stacks["handler"]))
+
+	status, ok = registry.Stop("app1", false)
+	testutil.AssertEqualsBool(t, "stopped", true, ok)
+	testutil.AssertEqualsBool(t, "inactive", false, status.Active)
+	testutil.AssertEqualsBool(t, "not sampled after stop", true, registry.recorder("app1") == nil)
+	_, profiles, _ = registry.Profiles("app1")
+	testutil.AssertEqualsInt(t, "kept after stop", 2, len(profiles))
+
+	_, ok = registry.Stop("app1", true)
+	testutil.AssertEqualsBool(t, "cleared", true, ok)
+	_, _, ok = registry.Profiles("app1")
+	testutil.AssertEqualsBool(t, "no profiles", false, ok)
+
+	registry.Start("app1", ProfileOptions{SampleRate: 0.000001})
+	testutil.AssertEqualsBool(t, "not sampled", true, registry.recorder("app1") == nil)
+}
+
+func TestProfileView(t *testing.T) {
+	registry := NewProfileRegistry()
+	a := &App{
+		Logger:   testutil.TestLogger(),
+		AppEntry: &types.AppEntry{Id: "app_dev_profile", Path: "/test"},
+		Name:     "Test",
+	}
+	a.SetProfileRegistry(registry)
+	router := chi.NewRouter()
+	a.createProfileRoutes(router)
+
+	req := httptest.NewRequest(http.MethodPost, types.APP_INTERNAL_URL_PREFIX+"/profile", nil)
+	req.Header.Set("Accept", "text/html")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	testutil.AssertEqualsInt(t, "redirect", http.StatusSeeOther, rec.Code)
+	testutil.AssertEqualsString(t, "location", "/test"+types.APP_INTERNAL_URL_PREFIX+"/profile", rec.Header().Get("Location"))
+
+	registry.recorder(a.Id).add(types.HandlerProfile{Method: "GET", Path: "/items", Handler: "handler", DurationMs: 3,
+		Stacks: map[string]int64{"handler;compute": 2000, "handler;plugin:store.in.select": 1000}})
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, types.APP_INTERNAL_URL_PREFIX+"/profile?profile=0", nil))
+	testutil.AssertEqualsInt(t, "view", http.StatusOK, rec.Code)
+	body := rec.Body.String()
+	testutil.AssertStringContains(t, body, "GET /items (3.00 ms)")
+	testutil.AssertStringContains(t, body, `style="width: 66.6667%"`)
+	testutil.AssertStringContains(t, body, `class="label plugin" title="plugin:store.in.select: 1.00 ms"`)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, types.APP_INTERNAL_URL_PREFIX+"/profile?profile=5", nil))
+	testutil.AssertEqualsInt(t, "invalid", http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, types.APP_INTERNAL_URL_PREFIX+"/profile/stop", nil))
+	testutil.AssertEqualsInt(t, "stop", http.StatusOK, rec.Code)
+	testutil.AssertStringContains(t, rec.Body.String(), `"active":false`)
+}
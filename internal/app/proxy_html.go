@@ -0,0 +1,186 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// htmlRewriteAttrs has the attributes rewritten for each tag name, the "*" key applies to all tags
+type htmlRewriteAttrs map[string][]string
+
+// parseHTMLRewriteAttrs parses the proxy.rewrite_html_attrs config, entries are in the "tag:attr"
+// format, like "a:href". A "*" tag matches any tag
+func parseHTMLRewriteAttrs(entries []string) (htmlRewriteAttrs, error) {
+	ret := htmlRewriteAttrs{}
+	for _, entry := range entries {
+		tag, attr, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		attr = strings.ToLower(strings.TrimSpace(attr))
+		if !ok || tag == "" || attr == "" {
+			return nil, fmt.Errorf("invalid proxy.rewrite_html_attrs entry %q, expected tag:attr", entry)
+		}
+		ret[tag] = append(ret[tag], attr)
+	}
+	return ret, nil
+}
+
+func (h htmlRewriteAttrs) matches(tag, attr string) bool {
+	return slices.Contains(h[tag], attr) || slices.Contains(h["*"], attr)
+}
+
+// shouldRewriteHTML checks whether the upstream response is an uncompressed HTML document. The
+// Accept-Encoding header is removed from the upstream request when rewriting is enabled, so a
+// compressed response is only received from upstreams which ignore that
+func shouldRewriteHTML(resp *http.Response) bool {
+	if resp.Body == nil || resp.Body == http.NoBody || resp.StatusCode == http.StatusNoContent {
+		return false
+	}
+	if enc := resp.Header.Get("Content-Encoding"); enc != "" && !strings.EqualFold(enc, "identity") {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// rewriteProxyHTML wraps the HTML response body so that path-absolute URLs in the configured
+// attributes get the stripped path prefix added back, similar to rewriteProxyLocation for
+// redirects. This allows apps which do not support a base path to be mounted under a path. The
+// body is rewritten as it is streamed, the Content-Length is removed since the size changes
+func rewriteProxyHTML(resp *http.Response, stripPath string, attrs htmlRewriteAttrs) {
+	if stripPath == "" || stripPath == "/" || len(attrs) == 0 || !shouldRewriteHTML(resp) {
+		return
+	}
+
+	resp.Body = newHTMLRewriter(resp.Body, stripPath, attrs)
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+}
+
+// htmlRewriter is a streaming io.ReadCloser which tokenizes the upstream HTML and rewrites the
+// matching attribute values. Tokens which are not changed are passed through as is
+type htmlRewriter struct {
+	body      io.ReadCloser
+	tokenizer *html.Tokenizer
+	stripPath string
+	attrs     htmlRewriteAttrs
+	buf       bytes.Buffer
+	err       error
+}
+
+func newHTMLRewriter(body io.ReadCloser, stripPath string, attrs htmlRewriteAttrs) *htmlRewriter {
+	return &htmlRewriter{
+		body:      body,
+		tokenizer: html.NewTokenizer(body),
+		stripPath: strings.TrimRight(stripPath, "/"),
+		attrs:     attrs,
+	}
+}
+
+func (r *htmlRewriter) Read(p []byte) (int, error) {
+	for r.buf.Len() == 0 && r.err == nil {
+		r.next()
+	}
+	if r.buf.Len() > 0 {
+		return r.buf.Read(p)
+	}
+	return 0, r.err
+}
+
+func (r *htmlRewriter) Close() error {
+	return r.body.Close()
+}
+
+// next processes one token into the buffer
+func (r *htmlRewriter) next() {
+	tokenType := r.tokenizer.Next()
+	switch tokenType {
+	case html.ErrorToken:
+		// The tokenizer returns any unprocessed input as the raw bytes of the error token
+		r.buf.Write(r.tokenizer.Raw())
+		r.err = r.tokenizer.Err()
+		return
+	case html.StartTagToken, html.SelfClosingTagToken:
+		raw := r.tokenizer.Raw()
+		// Raw is only valid until the next call into the tokenizer, copy it before the Token call
+		rawCopy := append([]byte(nil), raw...)
+		token := r.tokenizer.Token()
+		if r.rewriteToken(&token) {
+			r.buf.WriteString(token.String())
+		} else {
+			r.buf.Write(rawCopy)
+		}
+	default:
+		r.buf.Write(r.tokenizer.Raw())
+	}
+}
+
+// rewriteToken updates the matching attributes of the tag, returns false if nothing was changed
+func (r *htmlRewriter) rewriteToken(token *html.Token) bool {
+	changed := false
+	for i, attr := range token.Attr {
+		if attr.Namespace != "" || !r.attrs.matches(token.Data, attr.Key) {
+			continue
+		}
+		var value string
+		var ok bool
+		if attr.Key == "srcset" {
+			value, ok = r.rewriteSrcset(attr.Val)
+		} else {
+			value, ok = r.rewriteURL(attr.Val)
+		}
+		if ok {
+			token.Attr[i].Val = value
+			changed = true
+		}
+	}
+	return changed
+}
+
+// rewriteURL adds the strip path prefix to a path-absolute URL. Protocol relative URLs (//host)
+// and URLs already under the strip path are not changed
+func (r *htmlRewriter) rewriteURL(value string) (string, bool) {
+	trimmed := strings.TrimSpace(value)
+	if !strings.HasPrefix(trimmed, "/") || strings.HasPrefix(trimmed, "//") || strings.HasPrefix(trimmed, "/\\") {
+		return "", false
+	}
+	urlPath, _, _ := strings.Cut(trimmed, "?")
+	urlPath, _, _ = strings.Cut(urlPath, "#")
+	if pathHasPrefix(urlPath, r.stripPath) {
+		return "", false
+	}
+	return r.stripPath + trimmed, true
+}
+
+// rewriteSrcset rewrites each candidate URL in a srcset value, like "/a.png 1x, /b.png 2x"
+func (r *htmlRewriter) rewriteSrcset(value string) (string, bool) {
+	candidates := []string{}
+	changed := false
+	for candidate := range strings.SplitSeq(value, ",") {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		if rewritten, ok := r.rewriteURL(fields[0]); ok {
+			fields[0] = rewritten
+			changed = true
+		}
+		candidates = append(candidates, strings.Join(fields, " "))
+	}
+	if !changed {
+		return "", false
+	}
+	return strings.Join(candidates, ", "), true
+}
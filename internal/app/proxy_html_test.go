@@ -0,0 +1,116 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func htmlResponse(body, contentType string) *http.Response {
+	resp := &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+	}
+	resp.Header.Set("Content-Type", contentType)
+	resp.Header.Set("Content-Length", "100")
+	return resp
+}
+
+func TestRewriteProxyHTML(t *testing.T) {
+	attrs, err := parseHTMLRewriteAttrs([]string{"a:href", "link:href", "script:src", "img:srcset", "*:data-url"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"absolute href", `<a href="/docs?x=1#top">Docs</a>`, `<a href="/tool/docs?x=1#top">Docs</a>`},
+		{"already prefixed", `<a href="/tool/docs">Docs</a>`, `<a href="/tool/docs">Docs</a>`},
+		{"strip path itself", `<a href="/tool">Home</a>`, `<a href="/tool">Home</a>`},
+		{"prefix lookalike", `<a href="/toolbox">Box</a>`, `<a href="/tool/toolbox">Box</a>`},
+		{"relative", `<a href="docs">Docs</a>`, `<a href="docs">Docs</a>`},
+		{"protocol relative", `<script src="//cdn.example.com/a.js"></script>`, `<script src="//cdn.example.com/a.js"></script>`},
+		{"full url", `<link href="https://example.com/a.css">`, `<link href="https://example.com/a.css">`},
+		{"unconfigured attr", `<img src="/a.png">`, `<img src="/a.png">`},
+		{"srcset", `<img srcset="/a.png 1x,  /tool/b.png 2x">`, `<img srcset="/tool/a.png 1x, /tool/b.png 2x">`},
+		{"any tag", `<div data-url="/api" class=x>`, `<div data-url="/tool/api" class="x">`},
+		{"script body untouched", `<script>var u = "<a href='/x'>";</script>`, `<script>var u = "<a href='/x'>";</script>`},
+		{"unchanged raw kept", `<A HREF='https://x.com' Class=c>`, `<A HREF='https://x.com' Class=c>`},
+		{"comment and doctype", `<!DOCTYPE html><!-- <a href="/x"> --><p>text</p>`, `<!DOCTYPE html><!-- <a href="/x"> --><p>text</p>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := htmlResponse(tt.in, "text/html; charset=utf-8")
+			rewriteProxyHTML(resp, "/tool/", attrs)
+			out, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tt.want {
+				t.Errorf("got %q, want %q", out, tt.want)
+			}
+		})
+	}
+}
+
+func TestRewriteProxyHTMLSkipped(t *testing.T) {
+	attrs, err := parseHTMLRewriteAttrs([]string{"a:href"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := `<a href="/docs">Docs</a>`
+
+	resp := htmlResponse(body, "application/json")
+	rewriteProxyHTML(resp, "/tool", attrs)
+	if resp.Header.Get("Content-Length") == "" {
+		t.Error("non html response should not be rewritten")
+	}
+
+	resp = htmlResponse(body, "text/html")
+	resp.Header.Set("Content-Encoding", "gzip")
+	rewriteProxyHTML(resp, "/tool", attrs)
+	if resp.Header.Get("Content-Length") == "" {
+		t.Error("compressed response should not be rewritten")
+	}
+
+	resp = htmlResponse(body, "text/html")
+	rewriteProxyHTML(resp, "/", attrs)
+	if resp.Header.Get("Content-Length") == "" {
+		t.Error("response should not be rewritten without a strip path")
+	}
+
+	resp = htmlResponse(body, "text/html")
+	rewriteProxyHTML(resp, "/tool", attrs)
+	if resp.Header.Get("Content-Length") != "" || resp.ContentLength != -1 {
+		t.Errorf("content length not removed: %v %d", resp.Header, resp.ContentLength)
+	}
+}
+
+func TestParseHTMLRewriteAttrs(t *testing.T) {
+	attrs, err := parseHTMLRewriteAttrs([]string{"A:HREF", " img : src ", "*:poster"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !attrs.matches("a", "href") || !attrs.matches("img", "src") || !attrs.matches("video", "poster") {
+		t.Errorf("expected matches: %v", attrs)
+	}
+	if attrs.matches("a", "src") {
+		t.Errorf("unexpected match: %v", attrs)
+	}
+
+	for _, entry := range []string{"href", ":href", "a:"} {
+		if _, err := parseHTMLRewriteAttrs([]string{entry}); err == nil {
+			t.Errorf("expected error for %q", entry)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/openrundev/openrun/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+var errProxyResponseTooLarge = errors.New("upstream response exceeds the proxy.max_response_bytes limit")
+
+// proxyLimits has the limits applied to proxied responses. The responses are streamed, with
+// backpressure from the client, the limits cap the total size and duration of a response so that
+// large downloads do not hold on to a proxy connection indefinitely. Zero values mean no limit
+type proxyLimits struct {
+	maxBytes int64
+	maxTime  time.Duration
+	attrs    []attribute.KeyValue
+}
+
+// withDeadline wraps the handler so that the proxied request, including the streaming of the
+// response body, is canceled after the time limit. WebSocket upgrades are not limited
+func (l proxyLimits) withDeadline(handler http.Handler) http.Handler {
+	if l.maxTime <= 0 {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), l.maxTime)
+		defer cancel()
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// limitResponse is called from ModifyResponse. A response with a Content-Length above the size
+// limit is rejected before any data is sent to the client. Otherwise, the body is wrapped so
+// that the copy is aborted when the limits are exceeded while streaming
+func (l proxyLimits) limitResponse(resp *http.Response) error {
+	if l.maxBytes <= 0 && l.maxTime <= 0 {
+		return nil
+	}
+	ctx := context.Background()
+	if resp.Request != nil {
+		ctx = resp.Request.Context()
+	}
+	if l.maxBytes > 0 && resp.ContentLength > l.maxBytes {
+		telemetry.RecordAppProxyLimitExceeded(ctx, "size", l.attrs...)
+		_ = resp.Body.Close()
+		return fmt.Errorf("%w: content length %d", errProxyResponseTooLarge, resp.ContentLength)
+	}
+	if resp.Body == nil || resp.Body == http.NoBody || resp.StatusCode == http.StatusSwitchingProtocols {
+		return nil
+	}
+	resp.Body = &limitedProxyBody{body: resp.Body, ctx: ctx, limits: l, remaining: l.maxBytes}
+	return nil
+}
+
+// statusForError returns the status code for a proxy error, used by the proxy ErrorHandler
+func (l proxyLimits) statusForError(r *http.Request, err error) int {
+	switch {
+	case errors.Is(err, errProxyResponseTooLarge):
+		return http.StatusBadGateway
+	case l.maxTime > 0 && errors.Is(r.Context().Err(), context.DeadlineExceeded):
+		telemetry.RecordAppProxyLimitExceeded(r.Context(), "time", l.attrs...)
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// limitedProxyBody is the upstream response body with the size and time limits applied. The
+// reverse proxy aborts the client connection when the body returns an error, so the client
+// sees a truncated response instead of a complete one
+type limitedProxyBody struct {
+	body      io.ReadCloser
+	ctx       context.Context
+	limits    proxyLimits
+	remaining int64
+	once      sync.Once
+}
+
+func (b *limitedProxyBody) Read(p []byte) (int, error) {
+	if b.limits.maxBytes > 0 {
+		if b.remaining <= 0 {
+			// Check whether the upstream has more data, a body exactly at the limit is allowed
+			var probe [1]byte
+			n, err := b.body.Read(probe[:])
+			if n > 0 {
+				b.record("size")
+				return 0, errProxyResponseTooLarge
+			}
+			return 0, err
+		}
+		if int64(len(p)) > b.remaining {
+			p = p[:b.remaining]
+		}
+	}
+
+	n, err := b.body.Read(p)
+	b.remaining -= int64(n)
+	if err != nil && err != io.EOF && errors.Is(b.ctx.Err(), context.DeadlineExceeded) {
+		b.record("time")
+	}
+	return n, err
+}
+
+func (b *limitedProxyBody) record(limit string) {
+	b.once.Do(func() {
+		telemetry.RecordAppProxyLimitExceeded(b.ctx, limit, b.limits.attrs...)
+	})
+}
+
+func (b *limitedProxyBody) Close() error {
+	return b.body.Close()
+}
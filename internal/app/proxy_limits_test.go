@@ -0,0 +1,112 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func limitTestResponse(body string, contentLength int64) *http.Response {
+	return &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{},
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: contentLength,
+		Request:       httptest.NewRequest(http.MethodGet, "/", nil),
+	}
+}
+
+func TestProxyLimitsContentLength(t *testing.T) {
+	limits := proxyLimits{maxBytes: 5}
+	err := limits.limitResponse(limitTestResponse("0123456789", 10))
+	if !errors.Is(err, errProxyResponseTooLarge) {
+		t.Fatalf("expected too large error, got %v", err)
+	}
+	if status := limits.statusForError(httptest.NewRequest(http.MethodGet, "/", nil), err); status != http.StatusBadGateway {
+		t.Errorf("unexpected status %d", status)
+	}
+}
+
+func TestProxyLimitsStreaming(t *testing.T) {
+	limits := proxyLimits{maxBytes: 5}
+
+	// Unknown length, aborted once more than the limit is read
+	resp := limitTestResponse("0123456789", -1)
+	if err := limits.limitResponse(resp); err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(resp.Body)
+	if !errors.Is(err, errProxyResponseTooLarge) {
+		t.Fatalf("expected too large error, got %v", err)
+	}
+	if string(out) != "01234" {
+		t.Errorf("unexpected data %q", out)
+	}
+
+	// Exactly at the limit is allowed
+	resp = limitTestResponse("01234", -1)
+	if err := limits.limitResponse(resp); err != nil {
+		t.Fatal(err)
+	}
+	out, err = io.ReadAll(resp.Body)
+	if err != nil || string(out) != "01234" {
+		t.Errorf("unexpected read %q %v", out, err)
+	}
+
+	// No limits, the body is not wrapped
+	resp = limitTestResponse("0123456789", 10)
+	body := resp.Body
+	if err := (proxyLimits{}).limitResponse(resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Body != body {
+		t.Error("body should not be wrapped without limits")
+	}
+}
+
+func TestProxyLimitsDeadline(t *testing.T) {
+	limits := proxyLimits{maxTime: 10 * time.Millisecond}
+	var deadlineSet, upgradeDeadline bool
+	handler := limits.withDeadline(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, ok := r.Context().Deadline()
+		if r.Header.Get("Upgrade") != "" {
+			upgradeDeadline = ok
+			return
+		}
+		deadlineSet = ok
+		<-r.Context().Done()
+		w.WriteHeader(limits.statusForError(r, r.Context().Err()))
+	}))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if !deadlineSet {
+		t.Error("expected deadline to be set")
+	}
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("unexpected status %d", w.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Upgrade", "websocket")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if upgradeDeadline {
+		t.Error("websocket upgrade should not have a deadline")
+	}
+
+	// Client cancellation is not reported as a timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req = httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx)
+	if status := limits.statusForError(req, context.Canceled); status != http.StatusBadGateway {
+		t.Errorf("unexpected status %d", status)
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// scrubProxyHeaders removes the configured headers from an upstream response, so that details
+// like the upstream server version are not exposed to clients. Set-Cookie headers for the
+// configured cookie names are also removed, for internal cookies used between OpenRun and the
+// upstream
+func scrubProxyHeaders(h http.Header, headers, cookies []string) {
+	for _, name := range headers {
+		h.Del(name)
+	}
+	if len(cookies) == 0 {
+		return
+	}
+
+	setCookies := h.Values("Set-Cookie")
+	if len(setCookies) == 0 {
+		return
+	}
+	kept := make([]string, 0, len(setCookies))
+	for _, value := range setCookies {
+		name, _, _ := strings.Cut(value, "=")
+		if !slices.Contains(cookies, strings.TrimSpace(name)) {
+			kept = append(kept, value)
+		}
+	}
+	h.Del("Set-Cookie")
+	for _, value := range kept {
+		h.Add("Set-Cookie", value)
+	}
+}
+
+// rewriteProxyCookie rewrites an upstream Set-Cookie value so the cookie is stored for the app,
+// similar to rewriteProxyLocation for redirects:
+//
+//   - a Domain attribute for the upstream host is dropped, the browser would reject it since
+//     the client sees the public host. The cookie then defaults to the request host.
+//
+//   - a Path attribute is re-prefixed with the path stripped before forwarding, so that the
+//     cookie is sent on the follow-up requests to the app.
+//
+// Other attributes are passed through unchanged. The bool return is false if the value was
+// not changed
+func rewriteProxyCookie(value string, upstream *url.URL, stripPath string) (string, bool) {
+	parts := strings.Split(value, ";")
+	if len(parts) < 2 {
+		return "", false
+	}
+
+	hasStrip := stripPath != "" && stripPath != "/"
+	upstreamHost := upstream.Hostname()
+	changed := false
+	kept := make([]string, 0, len(parts))
+	kept = append(kept, parts[0])
+	for _, part := range parts[1:] {
+		attr, attrVal, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch {
+		case strings.EqualFold(attr, "domain"):
+			domain := strings.TrimPrefix(strings.TrimSpace(attrVal), ".")
+			if strings.EqualFold(domain, upstreamHost) {
+				changed = true
+				continue
+			}
+		case strings.EqualFold(attr, "path"):
+			cookiePath := strings.TrimSpace(attrVal)
+			if hasStrip && strings.HasPrefix(cookiePath, "/") && !pathHasPrefix(cookiePath, stripPath) {
+				newPath := strings.TrimRight(stripPath, "/")
+				if cookiePath != "/" {
+					newPath += cookiePath
+				}
+				kept = append(kept, " Path="+newPath)
+				changed = true
+				continue
+			}
+		}
+		kept = append(kept, part)
+	}
+	if !changed {
+		return "", false
+	}
+	return strings.Join(kept, ";"), true
+}
@@ -0,0 +1,112 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestScrubProxyHeaders(t *testing.T) {
+	h := http.Header{}
+	h.Set("Server", "gunicorn/21.2")
+	h.Set("X-Powered-By", "Express")
+	h.Set("Content-Type", "text/html")
+	h.Add("Set-Cookie", "session=abc; Path=/")
+	h.Add("Set-Cookie", "internal_route=node1; Path=/")
+	h.Add("Set-Cookie", " theme=dark")
+
+	scrubProxyHeaders(h, []string{"Server", "x-powered-by"}, []string{"internal_route"})
+	if h.Get("Server") != "" || h.Get("X-Powered-By") != "" {
+		t.Errorf("headers not scrubbed: %v", h)
+	}
+	if h.Get("Content-Type") != "text/html" {
+		t.Errorf("unexpected content type %q", h.Get("Content-Type"))
+	}
+	want := []string{"session=abc; Path=/", " theme=dark"}
+	if got := h.Values("Set-Cookie"); !slices.Equal(got, want) {
+		t.Errorf("Set-Cookie = %q, want %q", got, want)
+	}
+
+	// No cookies configured, Set-Cookie is not touched
+	h = http.Header{}
+	h.Add("Set-Cookie", "a=1")
+	scrubProxyHeaders(h, nil, nil)
+	if got := h.Values("Set-Cookie"); !slices.Equal(got, []string{"a=1"}) {
+		t.Errorf("Set-Cookie = %q", got)
+	}
+}
+
+func TestRewriteProxyCookie(t *testing.T) {
+	upstream, _ := url.Parse("http://127.0.0.1:32899")
+	tests := []struct {
+		name      string
+		cookie    string
+		stripPath string
+		want      string
+		wantOK    bool
+	}{
+		{
+			name:   "upstream domain is dropped",
+			cookie: "session=abc; Domain=127.0.0.1; HttpOnly",
+			want:   "session=abc; HttpOnly",
+			wantOK: true,
+		},
+		{
+			name:   "upstream domain with leading dot is dropped",
+			cookie: "session=abc; domain=.127.0.0.1",
+			want:   "session=abc",
+			wantOK: true,
+		},
+		{
+			name:   "other domain is kept",
+			cookie: "session=abc; Domain=example.com",
+			wantOK: false,
+		},
+		{
+			name:      "path is re-prefixed with stripPath",
+			cookie:    "session=abc; Path=/api; Secure",
+			stripPath: "/app1",
+			want:      "session=abc; Path=/app1/api; Secure",
+			wantOK:    true,
+		},
+		{
+			name:      "root path becomes stripPath",
+			cookie:    "session=abc; Path=/",
+			stripPath: "/app1/",
+			want:      "session=abc; Path=/app1",
+			wantOK:    true,
+		},
+		{
+			name:      "path already under stripPath is not double-prefixed",
+			cookie:    "session=abc; Path=/app1/api",
+			stripPath: "/app1",
+			wantOK:    false,
+		},
+		{
+			name:   "path without stripPath is kept",
+			cookie: "session=abc; Path=/api",
+			wantOK: false,
+		},
+		{
+			name:   "no attributes",
+			cookie: "session=abc",
+			wantOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := rewriteProxyCookie(tt.cookie, upstream, tt.stripPath)
+			if ok != tt.wantOK {
+				t.Fatalf("ok = %v, want %v (got %q)", ok, tt.wantOK, got)
+			}
+			if ok && got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -252,15 +252,19 @@ func (t *Tracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	sentCounter := &dirCounter{bw: t.bw, sent: true}
 	crw := &countingResponseWriter{ResponseWriter: w, c: sentCounter, ctx: ctx}
-	t.proxy.ServeHTTP(crw, r)
 
 	// Flush counts accumulated since the last second rollover. For upgraded
-	// (websocket) connections the countingConn flushes on close instead.
-	now := time.Now()
-	sentCounter.flush(ctx, now)
-	if recvCounter != nil {
-		recvCounter.flush(ctx, now)
-	}
+	// (websocket) connections the countingConn flushes on close instead. This
+	// is deferred since the reverse proxy panics with http.ErrAbortHandler when
+	// the response copy fails, like when a response limit is exceeded.
+	defer func() {
+		now := time.Now()
+		sentCounter.flush(ctx, now)
+		if recvCounter != nil {
+			recvCounter.flush(ctx, now)
+		}
+	}()
+	t.proxy.ServeHTTP(crw, r)
 }
 
 // Accessor to read the rolling totals.
@@ -0,0 +1,86 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// rateLimitSweepInterval is how often the buckets of the idle clients are removed
+const rateLimitSweepInterval = time.Minute
+
+// rateLimiter limits the requests per client IP for an app, using a token bucket per client
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64 // tokens added per second
+	burst     float64
+	clients   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// newRateLimiter returns the rate limiter for the app, nil if rate limiting is not enabled. Dev
+// apps are not rate limited
+func newRateLimiter(config types.RateLimitConfig, isDev bool) *rateLimiter {
+	if isDev || config.RequestsPerMin <= 0 {
+		return nil
+	}
+	burst := config.Burst
+	if burst <= 0 {
+		burst = max(1, config.RequestsPerMin/10)
+	}
+	return &rateLimiter{
+		rate:    float64(config.RequestsPerMin) / 60,
+		burst:   float64(burst),
+		clients: map[string]*tokenBucket{},
+		now:     time.Now,
+	}
+}
+
+// allow returns true if the client request is allowed. Otherwise, the wait till the next request
+// is allowed is returned
+func (l *rateLimiter) allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastSweep) >= rateLimitSweepInterval {
+		l.sweep(now)
+	}
+
+	bucket, ok := l.clients[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.clients[client] = bucket
+	} else {
+		bucket.tokens = min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+		bucket.last = now
+	}
+
+	if bucket.tokens < 1 {
+		wait := time.Duration(math.Ceil((1 - bucket.tokens) / l.rate * float64(time.Second)))
+		return false, wait
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// sweep removes the buckets which are full again, they are the same as new buckets
+func (l *rateLimiter) sweep(now time.Time) {
+	l.lastSweep = now
+	for client, bucket := range l.clients {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate >= l.burst {
+			delete(l.clients, client)
+		}
+	}
+}
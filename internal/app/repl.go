@@ -0,0 +1,134 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openrundev/openrun/internal/app/apptype"
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+const replEvalTimeout = time.Minute
+
+// ReplSession is an interactive Starlark session for an app. The session has the app builtins
+// and params, the globals defined by the input are kept across calls. App starlark files can be
+// loaded, like load("app.star", "handler"). Plugin calls are made with the app permissions, in
+// read only mode the write calls fail. In dry run mode, the plugin functions are dummies which
+// print the call
+type ReplSession struct {
+	app     *App
+	mode    types.ReplMode
+	mu      sync.Mutex
+	globals starlark.StringDict
+	cache   map[string]*starlarkCacheEntry
+}
+
+// NewReplSession creates a REPL session for the app
+func (a *App) NewReplSession(mode types.ReplMode) (*ReplSession, error) {
+	switch mode {
+	case types.ReplModeReadOnly, types.ReplModeLive, types.ReplModeDryRun:
+	default:
+		return nil, fmt.Errorf("invalid repl mode %q, expected %s, %s or %s", mode,
+			types.ReplModeReadOnly, types.ReplModeLive, types.ReplModeDryRun)
+	}
+	builtin, err := a.createBuiltin()
+	if err != nil {
+		return nil, err
+	}
+	return &ReplSession{
+		app:     a,
+		mode:    mode,
+		globals: maps.Clone(builtin),
+		cache:   map[string]*starlarkCacheEntry{},
+	}, nil
+}
+
+// Mode returns how plugin calls are handled in the session
+func (r *ReplSession) Mode() types.ReplMode {
+	return r.mode
+}
+
+func (r *ReplSession) load(thread *starlark.Thread, moduleFullPath string) (starlark.StringDict, error) {
+	if strings.HasSuffix(moduleFullPath, apptype.STARLARK_FILE_SUFFIX) {
+		return r.app.loadStarlark(thread, moduleFullPath, r.cache)
+	}
+	if r.mode != types.ReplModeDryRun {
+		return r.app.loader(thread, moduleFullPath)
+	}
+	return r.app.dummyPluginLoad(thread, moduleFullPath, func(thread *starlark.Thread, modulePath, name string) {
+		thread.Print(thread, fmt.Sprintf("dummy call to %s.%s, start the repl without --dry-run for plugin calls", modulePath, name))
+	})
+}
+
+// Eval runs the input, which has one or more statements. If the input is a single expression,
+// the result has its value. Errors are returned in the response, the session can be used after
+// an error
+func (r *ReplSession) Eval(ctx context.Context, input string) *types.AppReplResponse {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, replEvalTimeout)
+	defer cancel()
+
+	var output strings.Builder
+	thread := &starlark.Thread{
+		Name: r.app.Path,
+		Print: func(_ *starlark.Thread, msg string) {
+			output.WriteString(msg)
+			output.WriteString("\n")
+		},
+		Load: r.load,
+	}
+	thread.SetLocal(types.TL_CONTEXT, ctx)
+	thread.SetLocal(types.TL_APP_URL, r.app.appUrl)
+	thread.SetLocal(types.TL_READ_ONLY, r.mode == types.ReplModeReadOnly)
+	stop := context.AfterFunc(ctx, func() {
+		thread.Cancel(fmt.Sprintf("repl evaluation stopped: %s", context.Cause(ctx)))
+	})
+	defer stop()
+
+	ret := &types.AppReplResponse{}
+	// Load bindings are global in the REPL, so that loaded names are available in later inputs
+	opts := *r.app.sandbox.FileOptions()
+	opts.LoadBindsGlobally = true
+	file, err := opts.Parse("<repl>", input, 0)
+	if err == nil {
+		if expr := replSoleExpr(file); expr != nil {
+			var value starlark.Value
+			if value, err = starlark.EvalExprOptions(file.Options, thread, expr, r.globals); err == nil && value != starlark.None {
+				ret.Result = value.String()
+			}
+		} else {
+			err = starlark.ExecREPLChunk(file, thread, r.globals)
+		}
+	}
+
+	if err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			ret.Error = evalErr.Backtrace()
+		} else {
+			ret.Error = err.Error()
+		}
+	}
+	ret.Output = output.String()
+	return ret
+}
+
+// replSoleExpr returns the expression if the file has a single expression statement
+func replSoleExpr(file *syntax.File) syntax.Expr {
+	if len(file.Stmts) == 1 {
+		if stmt, ok := file.Stmts[0].(*syntax.ExprStmt); ok {
+			return stmt.X
+		}
+	}
+	return nil
+}
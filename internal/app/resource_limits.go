@@ -0,0 +1,241 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/openrundev/openrun/internal/container"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// resolveResourceLimits returns the container options with the resource limits from the app
+// config and the container quota added. The cpus, memory and pids_limit container options take
+// precedence over the app config, the app quota is used for the limits which are not set. An
+// error is returned if a limit is above the app quota. The options map is returned unchanged if
+// no limit is added, so that the container hash is not changed
+func resolveResourceLimits(options map[string]string, config types.Container,
+	quota types.ContainerQuotaConfig) (map[string]string, types.ResourceLimits, error) {
+	var limits types.ResourceLimits
+	appQuota, _, err := ParseResourceQuota(quota)
+	if err != nil {
+		return nil, limits, err
+	}
+
+	resolved := options
+	cloned := false
+	resolve := func(name, configValue, quotaValue string) string {
+		if value, ok := optionValue(options, name); ok {
+			return value
+		}
+		value := cmp.Or(configValue, quotaValue)
+		if value != "" {
+			if !cloned {
+				resolved = maps.Clone(options)
+				if resolved == nil {
+					resolved = map[string]string{}
+				}
+				cloned = true
+			}
+			resolved[name] = value
+		}
+		return value
+	}
+
+	cpus := resolve("cpus", config.Cpus, quota.AppCpus)
+	if cpus != "" {
+		if limits.CpuMilli, err = parseCpuMilli(cpus); err != nil {
+			return nil, limits, err
+		}
+		if appQuota.CpuMilli > 0 && limits.CpuMilli > appQuota.CpuMilli {
+			return nil, limits, fmt.Errorf("cpus limit %s is above the app quota of %s cpus", cpus, quota.AppCpus)
+		}
+	}
+
+	memory := resolve("memory", config.Memory, quota.AppMemory)
+	if memory != "" {
+		if limits.MemoryBytes, err = parseMemoryBytes(memory); err != nil {
+			return nil, limits, err
+		}
+		if appQuota.MemoryBytes > 0 && limits.MemoryBytes > appQuota.MemoryBytes {
+			return nil, limits, fmt.Errorf("memory limit %s is above the app quota of %s", memory, quota.AppMemory)
+		}
+	}
+
+	pids := resolve("pids_limit", intString(config.PidsLimit), intString(quota.AppPids))
+	if pids != "" {
+		value, err := container.PidsString(pids)
+		if err != nil {
+			return nil, limits, err
+		}
+		limits.Pids, _ = strconv.ParseInt(value, 10, 64)
+		if appQuota.Pids > 0 && limits.Pids > appQuota.Pids {
+			return nil, limits, fmt.Errorf("pids limit %s is above the app quota of %d", pids, quota.AppPids)
+		}
+	}
+	return resolved, limits, nil
+}
+
+// optionValue returns the container option value for name. Options prefixed with the container
+// manager name, like docker.cpus, are also checked
+func optionValue(options map[string]string, name string) (string, bool) {
+	if value, ok := options[name]; ok {
+		return value, true
+	}
+	for _, key := range slices.Sorted(maps.Keys(options)) {
+		if strings.HasSuffix(key, "."+name) {
+			return options[key], true
+		}
+	}
+	return "", false
+}
+
+func intString(value int) string {
+	if value <= 0 {
+		return ""
+	}
+	return strconv.Itoa(value)
+}
+
+func parseCpuMilli(value string) (int64, error) {
+	milli, err := container.CPUString(value, false)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing cpus value %q: %w", value, err)
+	}
+	return strconv.ParseInt(milli, 10, 64)
+}
+
+func parseMemoryBytes(value string) (int64, error) {
+	bytes, err := container.BytesString(value)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing memory value %q: %w", value, err)
+	}
+	return strconv.ParseInt(bytes, 10, 64)
+}
+
+// ParseResourceQuota returns the per-app and the total limits from the container quota config.
+// Zero values mean no quota
+func ParseResourceQuota(quota types.ContainerQuotaConfig) (appQuota, totalQuota types.ResourceLimits, err error) {
+	parse := func(key, value string, parseFunc func(string) (int64, error)) int64 {
+		if value == "" || err != nil {
+			return 0
+		}
+		ret, parseErr := parseFunc(value)
+		if parseErr != nil {
+			err = fmt.Errorf("invalid container_quota.%s: %w", key, parseErr)
+		}
+		return ret
+	}
+	appQuota.CpuMilli = parse("app_cpus", quota.AppCpus, parseCpuMilli)
+	appQuota.MemoryBytes = parse("app_memory", quota.AppMemory, parseMemoryBytes)
+	appQuota.Pids = int64(max(quota.AppPids, 0))
+	totalQuota.CpuMilli = parse("total_cpus", quota.TotalCpus, parseCpuMilli)
+	totalQuota.MemoryBytes = parse("total_memory", quota.TotalMemory, parseMemoryBytes)
+	return appQuota, totalQuota, err
+}
+
+// ResourceQuota tracks the resource limits of the app containers on the server, for enforcing
+// the container quota totals. The limits are reserved when the container handler for an app is
+// created and released when it is closed
+type ResourceQuota struct {
+	mu   sync.Mutex
+	apps map[types.AppId]quotaReservation
+}
+
+type quotaReservation struct {
+	owner  *ContainerHandler
+	limits types.ResourceLimits
+}
+
+func NewResourceQuota() *ResourceQuota {
+	return &ResourceQuota{apps: map[types.AppId]quotaReservation{}}
+}
+
+// reserve reserves the limits for the app. When a total quota is set, the app is required to
+// have a limit for that resource and an error is returned if the limits summed over all the apps
+// would be above the quota. A previous reservation for the app, like from the app being replaced
+// by a reload, is not counted
+func (q *ResourceQuota) reserve(appId types.AppId, owner *ContainerHandler, limits types.ResourceLimits,
+	quota types.ContainerQuotaConfig) error {
+	_, totalQuota, err := ParseResourceQuota(quota)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var used types.ResourceLimits
+	for id, reservation := range q.apps {
+		if id != appId {
+			used.CpuMilli += reservation.limits.CpuMilli
+			used.MemoryBytes += reservation.limits.MemoryBytes
+		}
+	}
+
+	if totalQuota.CpuMilli > 0 {
+		if limits.CpuMilli == 0 {
+			return fmt.Errorf("app %s has no cpus limit, a limit is required since container_quota.total_cpus is set", appId)
+		}
+		if used.CpuMilli+limits.CpuMilli > totalQuota.CpuMilli {
+			return fmt.Errorf("app %s cpus limit %dm is above the available quota, %dm of %s cpus is used by other apps",
+				appId, limits.CpuMilli, used.CpuMilli, quota.TotalCpus)
+		}
+	}
+	if totalQuota.MemoryBytes > 0 {
+		if limits.MemoryBytes == 0 {
+			return fmt.Errorf("app %s has no memory limit, a limit is required since container_quota.total_memory is set", appId)
+		}
+		if used.MemoryBytes+limits.MemoryBytes > totalQuota.MemoryBytes {
+			return fmt.Errorf("app %s memory limit %d bytes is above the available quota, %d bytes of %s is used by other apps",
+				appId, limits.MemoryBytes, used.MemoryBytes, quota.TotalMemory)
+		}
+	}
+	q.apps[appId] = quotaReservation{owner: owner, limits: limits}
+	return nil
+}
+
+// release removes the reservation for the app, if it is held by owner
+func (q *ResourceQuota) release(appId types.AppId, owner *ContainerHandler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if reservation, ok := q.apps[appId]; ok && reservation.owner == owner {
+		delete(q.apps, appId)
+	}
+}
+
+// Used returns the limits summed over all the apps
+func (q *ResourceQuota) Used() types.ResourceLimits {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var used types.ResourceLimits
+	for _, reservation := range q.apps {
+		used.CpuMilli += reservation.limits.CpuMilli
+		used.MemoryBytes += reservation.limits.MemoryBytes
+		used.Pids += reservation.limits.Pids
+	}
+	return used
+}
+
+// SetResourceQuota sets the tracker used to enforce the container quota totals
+func (a *App) SetResourceQuota(quota *ResourceQuota) {
+	a.resourceQuota = quota
+}
+
+// ResourceLimits returns the resource limits of the app container. false is returned if the app
+// does not have a container
+func (a *App) ResourceLimits() (types.ResourceLimits, bool) {
+	a.initMutex.Lock()
+	handler := a.containerHandler
+	a.initMutex.Unlock()
+	if handler == nil {
+		return types.ResourceLimits{}, false
+	}
+	return handler.resourceLimits, true
+}
@@ -0,0 +1,70 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestResolveResourceLimits(t *testing.T) {
+	options := map[string]string{"docker.memory": "256m"}
+	config := types.Container{Cpus: "500m", Memory: "1g", PidsLimit: 50}
+	resolved, limits, err := resolveResourceLimits(options, config, types.ContainerQuotaConfig{})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "cpus", "500m", resolved["cpus"])
+	testutil.AssertEqualsString(t, "pids", "50", resolved["pids_limit"])
+	if _, ok := resolved["memory"]; ok {
+		t.Error("memory option should take precedence over the app config")
+	}
+	testutil.AssertEqualsInt(t, "cpu milli", 500, int(limits.CpuMilli))
+	testutil.AssertEqualsInt(t, "memory", 256*1024*1024, int(limits.MemoryBytes))
+	testutil.AssertEqualsInt(t, "pids", 50, int(limits.Pids))
+	testutil.AssertEqualsInt(t, "options unchanged", 1, len(options))
+
+	// No limits, the options are returned as is
+	resolved, limits, err = resolveResourceLimits(options, types.Container{}, types.ContainerQuotaConfig{})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "resolved", 1, len(resolved))
+	testutil.AssertEqualsInt(t, "no cpu limit", 0, int(limits.CpuMilli))
+
+	// The app quota is used when no limit is set
+	quota := types.ContainerQuotaConfig{AppCpus: "1", AppMemory: "512m", AppPids: 100}
+	resolved, limits, err = resolveResourceLimits(nil, types.Container{}, quota)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "quota cpus", "1", resolved["cpus"])
+	testutil.AssertEqualsString(t, "quota memory", "512m", resolved["memory"])
+	testutil.AssertEqualsInt(t, "quota pids", 100, int(limits.Pids))
+
+	_, _, err = resolveResourceLimits(nil, types.Container{Cpus: "2"}, quota)
+	testutil.AssertErrorContains(t, err, "cpus limit 2 is above the app quota")
+	_, _, err = resolveResourceLimits(map[string]string{"memory": "1g"}, types.Container{}, quota)
+	testutil.AssertErrorContains(t, err, "memory limit 1g is above the app quota")
+	_, _, err = resolveResourceLimits(nil, types.Container{}, types.ContainerQuotaConfig{AppCpus: "abc"})
+	testutil.AssertErrorContains(t, err, "invalid container_quota.app_cpus")
+}
+
+func TestResourceQuotaReserve(t *testing.T) {
+	quota := NewResourceQuota()
+	config := types.ContainerQuotaConfig{TotalCpus: "2", TotalMemory: "1g"}
+	h1, h2 := &ContainerHandler{}, &ContainerHandler{}
+
+	testutil.AssertNoError(t, quota.reserve("app1", h1, types.ResourceLimits{CpuMilli: 1500, MemoryBytes: 512 << 20}, config))
+	err := quota.reserve("app2", h2, types.ResourceLimits{CpuMilli: 1000, MemoryBytes: 256 << 20}, config)
+	testutil.AssertErrorContains(t, err, "cpus limit 1000m is above the available quota")
+	err = quota.reserve("app2", h2, types.ResourceLimits{MemoryBytes: 256 << 20}, config)
+	testutil.AssertErrorContains(t, err, "has no cpus limit")
+
+	// A reload of app1 replaces its reservation, the release by the old handler is ignored
+	testutil.AssertNoError(t, quota.reserve("app1", h2, types.ResourceLimits{CpuMilli: 1000, MemoryBytes: 512 << 20}, config))
+	quota.release("app1", h1)
+	testutil.AssertEqualsInt(t, "used cpu", 1000, int(quota.Used().CpuMilli))
+	testutil.AssertNoError(t, quota.reserve("app2", h1, types.ResourceLimits{CpuMilli: 1000, MemoryBytes: 512 << 20}, config))
+
+	quota.release("app1", h2)
+	quota.release("app2", h1)
+	testutil.AssertEqualsInt(t, "used memory", 0, int(quota.Used().MemoryBytes))
+}
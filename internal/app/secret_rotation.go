@@ -0,0 +1,62 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"crypto/sha256"
+	"slices"
+)
+
+// loadSecret is a value with secret references which was evaluated at app load. Only a hash of
+// the evaluated value is kept, to check whether the secret was rotated
+type loadSecret struct {
+	appSecrets [][]string
+	input      string
+	valueHash  [sha256.Size]byte
+}
+
+// evalLoadSecret evaluates the secret references in a value used at app load (env values,
+// container params and build args). Values which reference secrets are recorded, so that
+// SecretsChanged can check them later
+func (a *App) evalLoadSecret(appSecrets [][]string, input string) (string, error) {
+	if a.secretEvalFunc == nil {
+		return input, nil
+	}
+	value, err := a.secretEvalFunc(appSecrets, a.AppConfig.Security.DefaultSecretsProvider, input)
+	if err != nil {
+		return "", err
+	}
+	if value != input {
+		a.loadSecretsMu.Lock()
+		a.loadSecrets = append(a.loadSecrets, loadSecret{appSecrets: appSecrets, input: input, valueHash: sha256.Sum256([]byte(value))})
+		a.loadSecretsMu.Unlock()
+	}
+	return value, nil
+}
+
+func (a *App) resetLoadSecrets() {
+	a.loadSecretsMu.Lock()
+	a.loadSecrets = nil
+	a.loadSecretsMu.Unlock()
+}
+
+// SecretsChanged re-evaluates the secret references used at app load and reports whether any
+// value is different now. The app has to be reloaded to use the rotated value. Secrets used in
+// plugin calls are evaluated on each call and are not checked
+func (a *App) SecretsChanged() (bool, error) {
+	a.loadSecretsMu.Lock()
+	secrets := slices.Clone(a.loadSecrets)
+	a.loadSecretsMu.Unlock()
+
+	for _, secret := range secrets {
+		value, err := a.secretEvalFunc(secret.appSecrets, a.AppConfig.Security.DefaultSecretsProvider, secret.input)
+		if err != nil {
+			return false, err
+		}
+		if sha256.Sum256([]byte(value)) != secret.valueHash {
+			return true, nil
+		}
+	}
+	return false, nil
+}
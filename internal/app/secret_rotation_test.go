@@ -0,0 +1,61 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func TestSecretsChanged(t *testing.T) {
+	t.Parallel()
+	secrets := map[string]string{"pass": "pass1"}
+	evalCount := 0
+	a := &App{
+		secretEvalFunc: func(allowed [][]string, _, value string) (string, error) {
+			evalCount++
+			name, ok := strings.CutPrefix(value, "secret:")
+			if !ok {
+				return value, nil
+			}
+			secret, ok := secrets[name]
+			if !ok {
+				return "", fmt.Errorf("secret %s not found", name)
+			}
+			return secret, nil
+		},
+	}
+
+	value, err := a.evalLoadSecret(envAllowAllSecrets, "secret:pass")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "value", "pass1", value)
+	value, err = a.evalLoadSecret(envAllowAllSecrets, "plain")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "plain value", "plain", value)
+	testutil.AssertEqualsInt(t, "recorded", 1, len(a.loadSecrets))
+
+	changed, err := a.SecretsChanged()
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsBool(t, "unchanged", false, changed)
+
+	secrets["pass"] = "pass2"
+	changed, err = a.SecretsChanged()
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsBool(t, "rotated", true, changed)
+
+	delete(secrets, "pass")
+	_, err = a.SecretsChanged()
+	testutil.AssertErrorContains(t, err, "secret pass not found")
+
+	// A reload records the values again
+	a.resetLoadSecrets()
+	evalCount = 0
+	changed, err = a.SecretsChanged()
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsBool(t, "after reset", false, changed)
+	testutil.AssertEqualsInt(t, "no evals after reset", 0, evalCount)
+}
@@ -0,0 +1,67 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fmt"
+	"path"
+)
+
+const (
+	SECURITY_PROFILE_NONE     = "none"
+	SECURITY_PROFILE_STANDARD = "standard"
+	SECURITY_PROFILE_STRICT   = "strict"
+)
+
+// securityProfiles are the app config values set by each security profile, as dotted keys
+var securityProfiles = map[string]map[string]any{
+	SECURITY_PROFILE_STANDARD: {
+		"security.headers_level":          int64(5),
+		"security.max_request_body_bytes": int64(64 << 20),
+		"action.max_request_body_bytes":   int64(32 << 20),
+		"rate_limit.requests_per_min":     int64(1200),
+		"rate_limit.burst":                int64(200),
+		"audit.skip_http_events":          false,
+	},
+	SECURITY_PROFILE_STRICT: {
+		"security.headers_level":          int64(10),
+		"security.max_request_body_bytes": int64(10 << 20),
+		"action.max_request_body_bytes":   int64(10 << 20),
+		"security.denied_plugins":         []string{"exec.in.*"},
+		"rate_limit.requests_per_min":     int64(600),
+		"rate_limit.burst":                int64(100),
+		"audit.skip_http_events":          false,
+		"audit.redact_url":                true,
+	},
+}
+
+// securityProfileConfig returns the app config values for the security profile, nil if no
+// profile is set
+func securityProfileConfig(profile string) (map[string]any, error) {
+	if profile == "" || profile == SECURITY_PROFILE_NONE {
+		return nil, nil
+	}
+	config, ok := securityProfiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("invalid security profile %q, expected standard, strict or none", profile)
+	}
+	return config, nil
+}
+
+// ValidateSecurityProfile checks the security profile name
+func ValidateSecurityProfile(profile string) error {
+	_, err := securityProfileConfig(profile)
+	return err
+}
+
+// pluginDenied returns true if the plugin call matches one of the denied plugin patterns
+func pluginDenied(deniedPlugins []string, modulePath, functionName string) bool {
+	name := modulePath + "." + functionName
+	for _, pattern := range deniedPlugins {
+		if matched, _ := path.Match(pattern, name); matched {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,98 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestResolveAppConfigProfile(t *testing.T) {
+	defaults := types.AppConfig{}
+	defaults.Security.Profile = SECURITY_PROFILE_STRICT
+	defaults.Security.HeadersLevel = 2
+
+	config, sources, err := ResolveAppConfig(testutil.TestLogger(), defaults, nil, &types.AppEntry{})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "headers level", 10, config.Security.HeadersLevel)
+	testutil.AssertEqualsInt(t, "rate limit", 600, config.RateLimit.RequestsPerMin)
+	testutil.AssertEqualsInt(t, "denied plugins", 1, len(config.Security.DeniedPlugins))
+	testutil.AssertEqualsBool(t, "redact url", true, config.Audit.RedactUrl)
+	testutil.AssertEqualsString(t, "source", "profile:strict", configSource("security.headers_level", sources))
+
+	// The tag and app config override the profile values
+	tagConfig := map[string]map[string]any{"public": {"rate_limit.requests_per_min": int64(60)}}
+	appEntry := &types.AppEntry{
+		Settings: types.AppSettings{Tags: []string{"public"}},
+		Metadata: types.AppMetadata{AppConfig: map[string]string{"security.denied_plugins": "[]"}},
+	}
+	config, sources, err = ResolveAppConfig(testutil.TestLogger(), defaults, tagConfig, appEntry)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "tag rate limit", 60, config.RateLimit.RequestsPerMin)
+	testutil.AssertEqualsInt(t, "app denied plugins", 0, len(config.Security.DeniedPlugins))
+	testutil.AssertEqualsInt(t, "profile headers level", 10, config.Security.HeadersLevel)
+	testutil.AssertEqualsString(t, "source", "tag:public", configSource("rate_limit.requests_per_min", sources))
+
+	// The app opts out of the profile, or selects another profile
+	appEntry = &types.AppEntry{Metadata: types.AppMetadata{AppConfig: map[string]string{"security.profile": `"none"`}}}
+	config, sources, err = ResolveAppConfig(testutil.TestLogger(), defaults, nil, appEntry)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "opt out headers level", 2, config.Security.HeadersLevel)
+	testutil.AssertEqualsInt(t, "opt out rate limit", 0, config.RateLimit.RequestsPerMin)
+	testutil.AssertEqualsString(t, "source", "server", configSource("security.headers_level", sources))
+
+	appEntry = &types.AppEntry{Metadata: types.AppMetadata{AppConfig: map[string]string{"security.profile": `"standard"`}}}
+	config, _, err = ResolveAppConfig(testutil.TestLogger(), defaults, nil, appEntry)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "standard headers level", 5, config.Security.HeadersLevel)
+	testutil.AssertEqualsInt(t, "standard denied plugins", 0, len(config.Security.DeniedPlugins))
+
+	defaults.Security.Profile = "paranoid"
+	_, _, err = ResolveAppConfig(testutil.TestLogger(), defaults, nil, &types.AppEntry{})
+	testutil.AssertErrorContains(t, err, `invalid security profile "paranoid"`)
+}
+
+func TestPluginDenied(t *testing.T) {
+	testutil.AssertEqualsBool(t, "denied", true, pluginDenied([]string{"exec.in.*"}, "exec.in", "run"))
+	testutil.AssertEqualsBool(t, "other plugin", false, pluginDenied([]string{"exec.in.*"}, "http.in", "get"))
+	testutil.AssertEqualsBool(t, "function", true, pluginDenied([]string{"fs.in.write*"}, "fs.in", "write_file"))
+	testutil.AssertEqualsBool(t, "none", false, pluginDenied(nil, "exec.in", "run"))
+}
+
+func TestRateLimiter(t *testing.T) {
+	testutil.AssertEqualsBool(t, "disabled", true, newRateLimiter(types.RateLimitConfig{}, false) == nil)
+	testutil.AssertEqualsBool(t, "dev", true, newRateLimiter(types.RateLimitConfig{RequestsPerMin: 60}, true) == nil)
+
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := newRateLimiter(types.RateLimitConfig{RequestsPerMin: 60, Burst: 2}, false)
+	limiter.now = func() time.Time { return now }
+
+	for range 2 {
+		ok, _ := limiter.allow("10.0.0.1")
+		testutil.AssertEqualsBool(t, "burst", true, ok)
+	}
+	ok, wait := limiter.allow("10.0.0.1")
+	testutil.AssertEqualsBool(t, "limited", false, ok)
+	testutil.AssertEqualsInt(t, "wait", 1000, int(wait.Milliseconds()))
+	ok, _ = limiter.allow("10.0.0.2")
+	testutil.AssertEqualsBool(t, "other client", true, ok)
+
+	// One request per second is added back
+	now = now.Add(time.Second)
+	ok, _ = limiter.allow("10.0.0.1")
+	testutil.AssertEqualsBool(t, "refilled", true, ok)
+	ok, _ = limiter.allow("10.0.0.1")
+	testutil.AssertEqualsBool(t, "limited again", false, ok)
+
+	// Idle clients are removed
+	now = now.Add(time.Hour)
+	limiter.allow("10.0.0.3")
+	testutil.AssertEqualsInt(t, "clients", 1, len(limiter.clients))
+
+	// The default burst is one tenth of the rate
+	testutil.AssertEqualsInt(t, "default burst", 6, int(newRateLimiter(types.RateLimitConfig{RequestsPerMin: 60}, false).burst))
+}
@@ -5,6 +5,7 @@ package app
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
@@ -37,11 +38,31 @@ import (
 
 func (a *App) loadStarlarkConfig(ctx context.Context, dryRun types.DryRun, opts ReloadOptions) error {
 	a.Info().Str("path", a.Path).Str("domain", a.Domain).Msg("Loading app")
+	a.resetLoadSecrets()
 
+	readStart := time.Now()
 	buf, err := a.sourceFS.ReadFile(a.getStarPath(apptype.APP_FILE_NAME))
 	if err != nil {
 		return fmt.Errorf("error reading %s: %w", a.getStarPath(apptype.APP_FILE_NAME), err)
 	}
+	if a.loadTiming != nil {
+		a.loadTiming.SourceLoadMs += msSince(readStart)
+	}
+
+	builtin, err := a.createBuiltin()
+	if err != nil {
+		return err
+	}
+	// The checks run on the source before the debugger instrumentation
+	if a.templateRefs, err = a.staticCheck(buf, builtin); err != nil {
+		return err
+	}
+
+	if a.debugger != nil {
+		if buf, err = a.debugger.instrument(a.getStarPath(apptype.APP_FILE_NAME), buf); err != nil {
+			return err
+		}
+	}
 
 	thread := &starlark.Thread{
 		Name:  a.Path,
@@ -50,12 +71,7 @@ func (a *App) loadStarlarkConfig(ctx context.Context, dryRun types.DryRun, opts
 	}
 	thread.SetLocal(types.TL_APP_URL, a.appUrl)
 
-	builtin, err := a.createBuiltin()
-	if err != nil {
-		return err
-	}
-
-	a.globals, err = starlark.ExecFileOptions(AppFileOptions(), thread, a.getStarPath(apptype.APP_FILE_NAME), buf, builtin)
+	a.globals, err = starlark.ExecFileOptions(a.sandbox.FileOptions(), thread, a.getStarPath(apptype.APP_FILE_NAME), buf, builtin)
 	if err != nil {
 		if evalErr, ok := err.(*starlark.EvalError); ok {
 			a.Error().Err(err).Str("trace", evalErr.Backtrace()).Msg("Error loading app")
@@ -86,6 +102,16 @@ func (a *App) loadStarlarkConfig(ctx context.Context, dryRun types.DryRun, opts
 	if err != nil {
 		return err
 	}
+	if _, err = loadCronDefs(a.appDef, a.globals); err != nil {
+		return err
+	}
+	envDefs, err := loadEnvDefs(a.appDef)
+	if err != nil {
+		return err
+	}
+	if a.appEnv, err = a.resolveEnv(envDefs); err != nil {
+		return err
+	}
 
 	a.jsLibs, err = a.loadLibraryInfo()
 	if err != nil {
@@ -135,6 +161,7 @@ func (a *App) loadStarlarkConfig(ctx context.Context, dryRun types.DryRun, opts
 		return err
 	}
 
+	containerStart := time.Now()
 	if a.containerHandler != nil {
 		// Container handler is present, reload the container
 		if a.IsDev {
@@ -147,18 +174,38 @@ func (a *App) loadStarlarkConfig(ctx context.Context, dryRun types.DryRun, opts
 			// image digest is resolved and the container is recreated if the
 			// tag has moved; build-spec apps rely on the source-content hash
 			// to detect changes and so only need reload on Initialize.
-			if err := a.containerHandler.ProdReload(ctx, bool(dryRun), opts.Verify); err != nil {
+			if err := a.containerHandler.ProdReload(ctx, bool(dryRun), opts.Verify, opts.GateReadiness); err != nil {
 				return err
 			}
 		}
 	}
 
+	if a.loadTiming != nil {
+		a.loadTiming.ContainerStartMs += msSince(containerStart)
+	}
+
 	// Initialize the router configuration
 	err = a.initRouter()
 	if err != nil {
 		return err
 	}
 
+	if opts.GateReadiness && a.containerHandler != nil && a.containerHandler.proxyTracker == nil {
+		// Only the container proxy routes wait for a starting container, the app handlers
+		// could call the container directly, wait for the health check here
+		waitStart := time.Now()
+		ready, err := a.containerHandler.WaitReady(ctx)
+		if err != nil {
+			return fmt.Errorf("error waiting for health: %w", err)
+		}
+		if !ready {
+			return ctx.Err()
+		}
+		if a.loadTiming != nil {
+			a.loadTiming.ContainerStartMs += msSince(waitStart)
+		}
+	}
+
 	return nil
 }
 
@@ -177,7 +224,10 @@ func (a *App) createBuiltin() (starlark.StringDict, error) {
 		return nil, err
 	}
 
-	return builtin, nil
+	if a.debugger != nil {
+		builtin[debugBreakBuiltin] = starlark.NewBuiltin(debugBreakBuiltin, a.debugger.breakBuiltin)
+	}
+	return a.sandbox.Restrict(builtin), nil
 }
 
 func (a *App) addSchemaTypes(builtin starlark.StringDict) (starlark.StringDict, error) {
@@ -234,36 +284,19 @@ func (a *App) addParams(builtin starlark.StringDict) (starlark.StringDict, error
 		a.paramDict[p.Name] = p.DefaultValue
 
 		if p.DefaultValue != starlark.None {
-			switch p.Type {
 			// Set the default value in the paramMap (in the string format)
-			case starlark_type.STRING:
-				a.paramValuesStr[p.Name] = string(p.DefaultValue.(starlark.String))
-			case starlark_type.INT:
-				intVal, ok := p.DefaultValue.(starlark.Int).Int64()
-				if !ok {
-					return nil, fmt.Errorf("param %s is not an int", p.Name)
-				}
-				a.paramValuesStr[p.Name] = fmt.Sprintf("%d", intVal)
-			case starlark_type.BOOLEAN:
-				a.paramValuesStr[p.Name] = strconv.FormatBool(bool(p.DefaultValue.(starlark.Bool)))
-			case starlark_type.DICT, starlark_type.LIST:
-				val, err := starlark_type.UnmarshalStarlark(p.DefaultValue)
-				if err != nil {
-					return nil, err
-				}
-				jsonVal, err := json.Marshal(val)
-				if err != nil {
-					return nil, err
-				}
-				a.paramValuesStr[p.Name] = string(jsonVal)
+			defaultStr, err := p.DefaultString()
+			if err != nil {
+				return nil, err
 			}
+			a.paramValuesStr[p.Name] = defaultStr
 		}
 
 		valueStr, ok := a.Metadata.ParamValues[p.Name]
 		if !ok {
 			// no custom value specified
 			if p.Required && p.DefaultValue == starlark.None {
-				return nil, fmt.Errorf("param %s is a required param, a value has to be provided", p.Name)
+				return nil, a.paramError(p.Name, fmt.Errorf("param %s is a required param, a value has to be provided", p.Name))
 			}
 			continue
 		}
@@ -271,12 +304,15 @@ func (a *App) addParams(builtin starlark.StringDict) (starlark.StringDict, error
 		a.paramValuesStr[p.Name] = valueStr
 		value, err := apptype.ParamStringToType(p.Name, p.Type, valueStr)
 		if err != nil {
-			return nil, fmt.Errorf("error parsing param %s: %w", p.Name, err)
+			return nil, a.paramError(p.Name, fmt.Errorf("error parsing param %s: %w", p.Name, err))
 		}
 		a.paramDict[p.Name] = value
 
-		if p.Type == starlark_type.STRING && p.Required && valueStr == "" {
-			return nil, fmt.Errorf("param %s is a required param, value cannot be empty", p.Name)
+		if apptype.IsStringType(p.Type) && p.Required && valueStr == "" {
+			return nil, a.paramError(p.Name, fmt.Errorf("param %s is a required param, value cannot be empty", p.Name))
+		}
+		if err := p.Validate(valueStr); err != nil {
+			return nil, a.paramError(p.Name, err)
 		}
 	}
 
@@ -295,6 +331,41 @@ func (a *App) addParams(builtin starlark.StringDict) (starlark.StringDict, error
 	return newBuiltins, nil
 }
 
+// paramError adds the command to fix the param value to the error
+func (a *App) paramError(name string, err error) error {
+	return fmt.Errorf("%w. Set the value using: openrun param update %s <value> %s", err, name, a.AppPathDomain())
+}
+
+// ValidateParams checks the param values in the app metadata against the param definitions in
+// params.star, without initializing the app. Used to validate param updates before they are saved
+func (a *App) ValidateParams() error {
+	if err := a.loadParamsInfo(a.sourceFS); err != nil {
+		return err
+	}
+	_, err := a.addParams(starlark.StringDict{})
+	return err
+}
+
+// ParamDefinitions returns the param definitions from params.star, in the order they are
+// defined. Used to prompt for the param values when creating an app interactively
+func (a *App) ParamDefinitions() ([]types.AppParamInfo, error) {
+	if err := a.loadParamsInfo(a.sourceFS); err != nil {
+		return nil, err
+	}
+	params := slices.SortedFunc(maps.Values(a.paramInfo), func(p1, p2 apptype.AppParam) int {
+		return cmp.Compare(p1.Index, p2.Index)
+	})
+	ret := make([]types.AppParamInfo, 0, len(params))
+	for _, p := range params {
+		info, err := p.Info()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, info)
+	}
+	return ret, nil
+}
+
 func verifyConfig(globals starlark.StringDict) (*starlarkstruct.Struct, error) {
 	if !globals.Has(apptype.APP_CONFIG_KEY) {
 		return nil, fmt.Errorf("%s not defined, check %s, add '%s = ace.app(...)'", apptype.APP_CONFIG_KEY, apptype.APP_FILE_NAME, apptype.APP_CONFIG_KEY)
@@ -390,6 +461,10 @@ func (a *App) initRouter() error {
 	if err := a.createInternalRoutes(router); err != nil {
 		return err
 	}
+	a.htmlRoutes = nil
+	a.markdownRoutes = nil
+	a.apiRoutes = nil
+	a.proxyPaths = nil
 
 	// Iterate through all the routes
 	routes, err := a.appDef.Attr("routes")
@@ -623,6 +698,16 @@ func (a *App) addStaticRoot(router *chi.Mux) error {
 				return
 			}
 
+			// Root files are not cached aggressively, the strong ETag allows the client to revalidate
+			etag := ""
+			if hash := a.sourceFS.ContentHash(rootFile); hash != "" {
+				etag = "\"" + hash + "\""
+				w.Header().Set("ETag", etag)
+			}
+			if appfs.CheckNotModified(w, r, etag, fi.ModTime()) {
+				return
+			}
+
 			http.ServeContent(w, r, fileName, fi.ModTime(), seeker)
 		})
 
@@ -647,6 +732,13 @@ func (a *App) addRoute(count int, router *chi.Mux, routeVal starlark.Value, defa
 		return a.addProxyConfig(count, router, pageDef)
 	}
 
+	if pageDef.Constructor() == starlark.String(apptype.GRAPHQL) {
+		return rootWildcard, a.addGraphQLRoute(router, pageDef)
+	}
+	if pageDef.Constructor() == starlark.String(apptype.MARKDOWN) {
+		return rootWildcard, a.addMarkdownRoute(router, pageDef)
+	}
+
 	_, err = pageDef.Attr("full")
 	if err != nil {
 		// "full" is not defined, this must be a API route instead of a html route
@@ -688,6 +780,7 @@ func (a *App) addRoute(count int, router *chi.Mux, routeVal starlark.Value, defa
 	}
 
 	handlerFunc := a.createHandlerFunc(htmlFile, blockStr, handler, apptype.HTML_TYPE)
+	a.htmlRoutes = append(a.htmlRoutes, htmlRoute{Path: pathStr, Method: methodStr, Template: htmlFile, Partial: blockStr})
 	if err = a.handleFragments(router, pathStr, count, htmlFile, blockStr, pageDef, handler); err != nil {
 		return rootWildcard, err
 	}
@@ -802,6 +895,24 @@ func (a *App) addProxyConfig(count int, router *chi.Mux, proxyDef *starlarkstruc
 		return rootWildcard, err
 	}
 
+	// The upstream credentials are resolved once at setup, secret references are allowed as
+	// per the secrets approved for the proxy.config permission. The values are never logged
+	var authHeader, authValue string
+	if authAttr, err := configAttr.Attr("upstream_auth"); err == nil && authAttr != nil && authAttr != starlark.None {
+		upstreamAuth, ok := authAttr.(*apptype.UpstreamAuth)
+		if !ok {
+			return rootWildcard, fmt.Errorf("proxy entry %d:%s upstream_auth is not valid, got %s", count, pathStr, authAttr.Type())
+		}
+		secretsAllowed := a.getSecretsAllowed("proxy.in", "config")
+		upstreamAuth, err = upstreamAuth.Resolve(func(value string) (string, error) {
+			return a.evalLoadSecret(secretsAllowed, value)
+		})
+		if err != nil {
+			return rootWildcard, fmt.Errorf("proxy entry %d:%s %w", count, pathStr, err)
+		}
+		authHeader, authValue = upstreamAuth.HeaderValue()
+	}
+
 	originalUrlStr := urlStr
 	if urlStr == apptype.CONTAINER_URL {
 		// proxying to container url
@@ -819,6 +930,20 @@ func (a *App) addProxyConfig(count int, router *chi.Mux, proxyDef *starlarkstruc
 
 	proxy := httputil.NewSingleHostReverseProxy(urlParsed)
 	proxy.BufferPool = proxyBufPool
+	limits := proxyLimits{
+		maxBytes: a.AppConfig.Proxy.MaxResponseBytes,
+		maxTime:  time.Duration(a.AppConfig.Proxy.MaxResponseSecs) * time.Second,
+		attrs:    a.telemetryIdentityAttrs,
+	}
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		if markLazyStartRetry(r, err) {
+			// The container is not reachable, the request is retried after starting it
+			a.Debug().Err(err).Str("path", r.URL.Path).Msg("container not reachable")
+			return
+		}
+		a.Warn().Err(err).Str("path", r.URL.Path).Msg("proxy error")
+		w.WriteHeader(limits.statusForError(r, err))
+	}
 
 	customTransport := http.DefaultTransport.(*http.Transport).Clone()
 	maxIdleConnCount := a.AppConfig.Proxy.MaxIdleConns
@@ -827,7 +952,7 @@ func (a *App) addProxyConfig(count int, router *chi.Mux, proxyDef *starlarkstruc
 	customTransport.MaxIdleConnsPerHost = maxIdleConnCount
 	customTransport.IdleConnTimeout = time.Duration(a.AppConfig.Proxy.IdleConnTimeoutSecs) * time.Second
 	customTransport.DisableCompression = a.AppConfig.Proxy.DisableCompression
-	proxy.Transport = telemetry.WrapTransport(customTransport)
+	proxy.Transport = telemetry.WrapTransport(a.faults.wrapTransport(customTransport))
 
 	// resolveProxyTarget returns the upstream for the current request. For
 	// container.URL the container address is re-resolved on every request
@@ -846,6 +971,13 @@ func (a *App) addProxyConfig(count int, router *chi.Mux, proxyDef *starlarkstruc
 		}
 	}
 
+	var htmlAttrs htmlRewriteAttrs
+	if a.AppConfig.Proxy.RewriteHTML {
+		if htmlAttrs, err = parseHTMLRewriteAttrs(a.AppConfig.Proxy.RewriteHTMLAttrs); err != nil {
+			return rootWildcard, err
+		}
+	}
+
 	defaultDirector := proxy.Director
 	proxy.Director = func(req *http.Request) {
 		target := resolveProxyTarget()
@@ -868,12 +1000,23 @@ func (a *App) addProxyConfig(count int, router *chi.Mux, proxyDef *starlarkstruc
 		} else if !preserveHost {
 			req.Host = target.Host
 		}
+		if authHeader != "" {
+			// Replaces any client supplied value, the upstream sees only the configured credential
+			req.Header.Set(authHeader, authValue)
+		}
+		if htmlAttrs != nil {
+			// Request an uncompressed response so that the HTML can be rewritten
+			req.Header.Del("Accept-Encoding")
+		}
 	}
 
 	// stripPath is finalized just before router.Mount below; the closure
 	// captures it by reference so the runtime value (including the stripApp
 	// join with a.Path) is what gets used when rewriting.
 	proxy.ModifyResponse = func(resp *http.Response) error {
+		if err := limits.limitResponse(resp); err != nil {
+			return err
+		}
 		if loc := resp.Header.Get("Location"); loc != "" && a.AppConfig.Proxy.RewriteLocation {
 			rewritten, ok := rewriteProxyLocation(loc, resolveProxyTarget(), stripPath)
 			if !ok {
@@ -888,6 +1031,20 @@ func (a *App) addProxyConfig(count int, router *chi.Mux, proxyDef *starlarkstruc
 				resp.Header.Set("Location", rewritten)
 			}
 		}
+		scrubProxyHeaders(resp.Header, a.AppConfig.Proxy.ScrubHeaders, a.AppConfig.Proxy.ScrubCookies)
+		if a.AppConfig.Proxy.RewriteCookies {
+			if setCookies := resp.Header.Values("Set-Cookie"); len(setCookies) > 0 {
+				upstream := resolveProxyTarget()
+				for i, value := range setCookies {
+					if rewritten, ok := rewriteProxyCookie(value, upstream, stripPath); ok {
+						setCookies[i] = rewritten
+					}
+				}
+			}
+		}
+		if htmlAttrs != nil {
+			rewriteProxyHTML(resp, stripPath, htmlAttrs)
+		}
 		return nil
 	}
 
@@ -965,7 +1122,12 @@ func (a *App) addProxyConfig(count int, router *chi.Mux, proxyDef *starlarkstruc
 	if stripApp {
 		stripPath = path.Join(a.Path, stripPath)
 	}
-	router.Mount(pathStr, http.StripPrefix(stripPath, permsHandler(proxyWrapper)))
+	proxyHandler := limits.withDeadline(proxyWrapper)
+	if originalUrlStr == apptype.CONTAINER_URL {
+		proxyHandler = a.containerHandler.readinessHandler(proxyHandler)
+	}
+	router.Mount(pathStr, http.StripPrefix(stripPath, permsHandler(proxyHandler)))
+	a.proxyPaths = append(a.proxyPaths, pathStr)
 	return rootWildcard, nil
 }
 
@@ -1101,6 +1263,11 @@ func (a *App) addAPIRoute(basePath string, router *chi.Mux, apiDef *starlarkstru
 	if basePath != "" {
 		fullPath = path.Join(basePath, pathStr)
 	}
+	route, err := a.newAPIRoute(fullPath, method, rtype, apiDef, handler)
+	if err != nil {
+		return fmt.Errorf("API %s: %w", fullPath, err)
+	}
+	a.apiRoutes = append(a.apiRoutes, route)
 	router.Method(method, fullPath, handlerFunc)
 	return nil
 }
@@ -1167,6 +1334,7 @@ func (a *App) handleFragments(router *chi.Mux, pagePath string, pageCount int, h
 		handlerFunc := a.createHandlerFunc(htmlFile, blockStr, fragmentCallback, apptype.HTML_TYPE)
 
 		fragmentPath := path.Join(pagePath, pathStr)
+		a.htmlRoutes = append(a.htmlRoutes, htmlRoute{Path: fragmentPath, Method: methodStr, Template: htmlFile, Partial: blockStr, Fragment: true})
 		a.Trace().Msgf("Adding fragment route %s <%s>", methodStr, fragmentPath)
 		router.Method(methodStr, fragmentPath, handlerFunc)
 	}
@@ -1179,7 +1347,21 @@ func (a *App) createInternalRoutes(router *chi.Mux) error {
 		router.Get(types.APP_INTERNAL_URL_PREFIX+"/sse", a.sseHandler)
 	}
 
+	if a.debugger != nil {
+		a.createDebugRoutes(router)
+	}
+	if a.IsDev {
+		a.createProfileRoutes(router)
+		a.createPlaygroundRoutes(router)
+	}
+
 	router.Get(types.APP_INTERNAL_URL_PREFIX+"/file/{file_id}", a.userFileHandler)
+	if a.AppConfig.OpenAPI.Enabled {
+		router.Get(types.OPENAPI_URL_PREFIX+"/openapi.json", a.openAPIHandler)
+		if a.AppConfig.OpenAPI.SwaggerUI {
+			router.Get(types.OPENAPI_URL_PREFIX+"/docs", a.swaggerUIHandler)
+		}
+	}
 	router.Get(types.APP_INTERNAL_URL_PREFIX+"/verify_file/{file_name}", func(w http.ResponseWriter, r *http.Request) {
 		fileName := chi.URLParam(r, "file_name")
 		cleanFileName, err := system.CleanRelativePath(fileName)
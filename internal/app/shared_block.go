@@ -0,0 +1,88 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"slices"
+)
+
+// BlockRenderer renders a shared block from the app at appPath, for the appBlock template
+// function of the caller app. The server resolves the target app and does the permission checks
+type BlockRenderer func(caller *App, appPath, block string, data any) (template.HTML, error)
+
+// SetBlockRenderer sets the renderer used by the appBlock template function
+func (a *App) SetBlockRenderer(renderer BlockRenderer) {
+	a.blockRenderer = renderer
+}
+
+// appBlock is the appBlock template function, which renders a block shared by another app. data
+// should be the request value (the dot value in the page template), it is passed to the block
+func (a *App) appBlock(appPath, block string, data any) (template.HTML, error) {
+	if a.blockRenderer == nil {
+		return "", fmt.Errorf("appBlock is not supported")
+	}
+	return a.blockRenderer(a, appPath, block, data)
+}
+
+// initSharedTemplate creates the template used to render the shared blocks for other apps.
+// The shared template is a clone with appBlock disabled, so that a shared block cannot include
+// blocks from other apps, which could lead to include cycles across apps. This has to be
+// called before the app templates are executed, a template cannot be cloned after execution.
+// Called from Reload, with the initMutex held
+func (a *App) initSharedTemplate() error {
+	a.sharedTemplate = nil
+	if len(a.codeConfig.Routing.SharedBlocks) == 0 {
+		return nil
+	}
+
+	source := a.template
+	if source == nil {
+		// For structured templates, the blocks shared are the base template defines
+		source = a.templateBase
+	}
+	if source == nil {
+		return fmt.Errorf("app shares blocks %v, but has no templates", a.codeConfig.Routing.SharedBlocks)
+	}
+
+	shared, err := source.Clone()
+	if err != nil {
+		return err
+	}
+	shared.Funcs(template.FuncMap{
+		"appBlock": func(appPath, block string, data any) (template.HTML, error) {
+			return "", fmt.Errorf("appBlock cannot be used within a shared block")
+		},
+	})
+	a.sharedTemplate = shared
+	return nil
+}
+
+// RenderSharedBlock renders a block for another app. The block has to be listed in the
+// routing.shared_blocks setting of this app, "*" shares all blocks
+func (a *App) RenderSharedBlock(block string, data any) (template.HTML, error) {
+	// The shared template is replaced on reload, the lock is not held while the block is executed
+	a.initMutex.Lock()
+	var shared []string
+	if a.codeConfig != nil {
+		shared = a.codeConfig.Routing.SharedBlocks
+	}
+	sharedTemplate := a.sharedTemplate
+	a.initMutex.Unlock()
+
+	if !slices.Contains(shared, block) && !slices.Contains(shared, "*") {
+		return "", fmt.Errorf("block %s is not shared by app %s", block, a.AppPathDomain())
+	}
+	if sharedTemplate == nil || sharedTemplate.Lookup(block) == nil {
+		return "", fmt.Errorf("block %s not found in app %s", block, a.AppPathDomain())
+	}
+
+	var buf bytes.Buffer
+	if err := sharedTemplate.ExecuteTemplate(&buf, block, data); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil //nolint:gosec // output of html/template, already escaped
+}
@@ -0,0 +1,74 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+package app
+
+import (
+	"html/template"
+	"sync"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/app/apptype"
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func newSharedBlockTestApp(t *testing.T, sharedBlocks []string) *App {
+	t.Helper()
+	tmpl := template.Must(template.New("index.go.html").Funcs(template.FuncMap{
+		"appBlock": func(appPath, block string, data any) (template.HTML, error) { return "", nil },
+	}).Parse(`{{define "header"}}<h1>{{.}}</h1>{{end}}{{define "footer"}}<p>footer</p>{{end}}{{define "nested"}}{{appBlock "/other" "x" .}}{{end}}`))
+	codeConfig := apptype.NewCodeConfig()
+	codeConfig.Routing.SharedBlocks = sharedBlocks
+	a := &App{
+		Logger:     testutil.TestLogger(),
+		AppEntry:   &types.AppEntry{Id: "app_prd_design", Path: "/design"},
+		codeConfig: codeConfig,
+		template:   tmpl,
+	}
+	testutil.AssertNoError(t, a.initSharedTemplate())
+	return a
+}
+
+func TestRenderSharedBlock(t *testing.T) {
+	a := newSharedBlockTestApp(t, []string{"header", "nested", "missing"})
+
+	out, err := a.RenderSharedBlock("header", "<title>")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "header", "<h1>&lt;title&gt;</h1>", string(out))
+
+	_, err = a.RenderSharedBlock("footer", nil)
+	testutil.AssertErrorContains(t, err, "block footer is not shared by app /design")
+	_, err = a.RenderSharedBlock("missing", nil)
+	testutil.AssertErrorContains(t, err, "block missing not found in app /design")
+	_, err = a.RenderSharedBlock("nested", nil)
+	testutil.AssertErrorContains(t, err, "appBlock cannot be used within a shared block")
+
+	// "*" shares all the blocks
+	a = newSharedBlockTestApp(t, []string{"*"})
+	out, err = a.RenderSharedBlock("footer", nil)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "footer", "<p>footer</p>", string(out))
+}
+
+func TestRenderSharedBlockDuringReload(t *testing.T) {
+	a := newSharedBlockTestApp(t, []string{"*"})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for range 100 {
+			a.initMutex.Lock()
+			err := a.initSharedTemplate()
+			a.initMutex.Unlock()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	for range 100 {
+		_, err := a.RenderSharedBlock("footer", nil)
+		testutil.AssertNoError(t, err)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,69 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+
+	"github.com/BurntSushi/toml"
+	"github.com/openrundev/openrun/internal/app/apptype"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// loadSpecPermissions returns the permission bundle declared by the app spec, nil if the spec does
+// not declare one. The file is read from the spec files and not from the app source, so that the
+// app code cannot change the declared bundle
+func (a *App) loadSpecPermissions() (*types.SpecPermissions, error) {
+	if a.Metadata.SpecFiles == nil {
+		return nil, nil
+	}
+	data, ok := (*a.Metadata.SpecFiles)[apptype.SPEC_PERMS_FILE_NAME]
+	if !ok {
+		return nil, nil
+	}
+
+	var ret types.SpecPermissions
+	if _, err := toml.Decode(data, &ret); err != nil {
+		return nil, fmt.Errorf("error reading %s for spec %s: %w", apptype.SPEC_PERMS_FILE_NAME, a.Metadata.Spec, err)
+	}
+	return &ret, nil
+}
+
+// addSpecDeviations adds the spec bundle and the loads and permissions used by the app which are
+// not declared by the spec to the audit result
+func addSpecDeviations(result *types.ApproveResult, spec types.AppSpec, specPerms *types.SpecPermissions) {
+	if specPerms == nil {
+		return
+	}
+	result.Spec = spec
+	result.SpecLoads = specPerms.Loads
+	result.SpecPermissions = specPerms.Permissions
+	result.UndeclaredLoads = []string{}
+	result.UndeclaredPermissions = []types.Permission{}
+
+	for _, load := range result.NewLoads {
+		if !slices.Contains(specPerms.Loads, load) {
+			result.UndeclaredLoads = append(result.UndeclaredLoads, load)
+		}
+	}
+	for _, perm := range result.NewPermissions {
+		if !permissionDeclaredBySpec(perm, specPerms.Permissions) {
+			result.UndeclaredPermissions = append(result.UndeclaredPermissions, perm)
+		}
+	}
+}
+
+// permissionDeclaredBySpec checks if the permission matches one of the spec declared permissions.
+// The arguments are matched like the server config permissions, the secrets have to match exactly
+func permissionDeclaredBySpec(perm types.Permission, specPerms []types.Permission) bool {
+	matching := []types.Permission{}
+	for _, sp := range specPerms {
+		if len(sp.Secrets) == 0 && len(perm.Secrets) == 0 || reflect.DeepEqual(sp.Secrets, perm.Secrets) {
+			matching = append(matching, sp)
+		}
+	}
+	return permissionCoveredByServerConfig(perm, matching)
+}
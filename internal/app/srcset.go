@@ -0,0 +1,88 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/app/appfs"
+)
+
+// maxImageWidthEntries is the max number of image widths cached per app. In dev mode, each change
+// to an image adds an entry, the cache is cleared when full
+const maxImageWidthEntries = 1000
+
+// srcset is the srcset template function. srcset "img/photo.jpg" returns the srcset attribute
+// value listing the resized variants of the static image and the image itself, with their widths.
+// srcset "img/photo.jpg" "webp" lists the WebP variants, for use in a picture source element. The
+// value is empty if there are no variants in the format. The variants are created when a prod app
+// is installed, dev apps list just the image
+func (a *App) srcset(name string, format ...string) (string, error) {
+	if len(format) > 1 {
+		return "", fmt.Errorf("srcset takes the image name and an optional format")
+	}
+	imageFormat := ""
+	if len(format) == 1 {
+		imageFormat = strings.ToLower(format[0])
+		if imageFormat != appfs.IMAGE_FORMAT_WEBP && imageFormat != appfs.IMAGE_FORMAT_AVIF {
+			return "", fmt.Errorf("srcset format should be %s or %s, got %s", appfs.IMAGE_FORMAT_WEBP, appfs.IMAGE_FORMAT_AVIF, format[0])
+		}
+	}
+
+	staticPath := path.Join("static", name)
+	width, err := a.imageWidth(staticPath)
+	if err != nil {
+		return "", err
+	}
+
+	entries := []string{}
+	addEntry := func(name string, width int) {
+		if _, err := a.sourceFS.Stat(name); err != nil {
+			return // the variant was not created
+		}
+		entries = append(entries, path.Join(a.Path, a.sourceFS.HashName(name))+" "+strconv.Itoa(width)+"w")
+	}
+	for _, variantWidth := range appfs.VariantWidths(a.AppConfig.Image.Widths, width) {
+		addEntry(appfs.ImageVariantName(staticPath, variantWidth, imageFormat), variantWidth)
+	}
+	if imageFormat == "" {
+		addEntry(staticPath, width)
+	} else {
+		addEntry(appfs.ImageVariantName(staticPath, width, imageFormat), width)
+	}
+	return strings.Join(entries, ", "), nil
+}
+
+// imageWidth returns the width of the static image. The widths are cached by the hashed file
+// name, which changes when the image is updated
+func (a *App) imageWidth(staticPath string) (int, error) {
+	hashName := a.sourceFS.HashName(staticPath)
+	a.imageWidthsMu.Lock()
+	width, ok := a.imageWidths[hashName]
+	a.imageWidthsMu.Unlock()
+	if ok {
+		return width, nil
+	}
+
+	file, err := a.sourceFS.Open(staticPath)
+	if err != nil {
+		return 0, fmt.Errorf("error opening image %s: %w", staticPath, err)
+	}
+	defer file.Close() //nolint:errcheck
+	width, err = appfs.ImageWidth(file)
+	if err != nil {
+		return 0, fmt.Errorf("error reading image %s: %w", staticPath, err)
+	}
+
+	a.imageWidthsMu.Lock()
+	if a.imageWidths == nil || len(a.imageWidths) >= maxImageWidthEntries {
+		a.imageWidths = map[string]int{}
+	}
+	a.imageWidths[hashName] = width
+	a.imageWidthsMu.Unlock()
+	return width, nil
+}
@@ -0,0 +1,241 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark_type
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"go.starlark.net/starlark"
+)
+
+// JSONEncoder encodes starlark values to JSON directly, without first converting them to Go
+// values with UnmarshalStarlark. The output is the same as encoding the UnmarshalStarlark result
+// with a json.Encoder (HTML escaped, map keys sorted, trailing newline). Encoders are pooled,
+// get one with GetJSONEncoder and release it with PutJSONEncoder after the bytes are written
+type JSONEncoder struct {
+	buf  []byte
+	keys []starlark.Value // stack of the dict keys being sorted, shared by the nested dicts
+}
+
+// maxPooledJSONBuffer is the largest buffer returned to the pool, so that one large response
+// does not pin memory in the pool
+const maxPooledJSONBuffer = 1 << 20
+
+var jsonEncoderPool = sync.Pool{
+	New: func() any {
+		return &JSONEncoder{buf: make([]byte, 0, 512), keys: make([]starlark.Value, 0, 32)}
+	},
+}
+
+func GetJSONEncoder() *JSONEncoder {
+	return jsonEncoderPool.Get().(*JSONEncoder)
+}
+
+func PutJSONEncoder(e *JSONEncoder) {
+	if cap(e.buf) > maxPooledJSONBuffer {
+		return
+	}
+	e.buf = e.buf[:0]
+	clear(e.keys[:cap(e.keys)])
+	e.keys = e.keys[:0]
+	jsonEncoderPool.Put(e)
+}
+
+// Bytes returns the encoded value, valid until the encoder is released
+func (e *JSONEncoder) Bytes() []byte {
+	return e.buf
+}
+
+// Encode encodes the value, replacing any earlier output. Values of types which are not
+// encoded directly (like time, structs and custom types) are encoded through UnmarshalStarlark
+func (e *JSONEncoder) Encode(v starlark.Value) error {
+	e.buf = e.buf[:0]
+	e.keys = e.keys[:0]
+	if e.encodeValue(v) {
+		e.buf = append(e.buf, '\n')
+		return nil
+	}
+
+	// Fall back to the Go value conversion for the whole value, so that the errors are the
+	// same as for UnmarshalStarlark
+	goValue, err := UnmarshalStarlark(v)
+	if err != nil {
+		return err
+	}
+	buf := bytes.NewBuffer(e.buf[:0])
+	if err := json.NewEncoder(buf).Encode(goValue); err != nil {
+		return err
+	}
+	e.buf = buf.Bytes()
+	return nil
+}
+
+// encodeValue appends the JSON for the value, returning false if the value has to be
+// encoded through UnmarshalStarlark
+func (e *JSONEncoder) encodeValue(v starlark.Value) bool {
+	switch v := v.(type) {
+	case starlark.NoneType:
+		e.buf = append(e.buf, "null"...)
+	case starlark.Bool:
+		e.buf = strconv.AppendBool(e.buf, bool(v))
+	case starlark.Int:
+		i, ok := v.Int64()
+		if !ok || i != int64(int(i)) {
+			return false
+		}
+		e.buf = strconv.AppendInt(e.buf, i, 10)
+	case starlark.Float:
+		return e.encodeFloat(float64(v))
+	case starlark.String:
+		e.buf = appendJSONString(e.buf, string(v))
+	case *starlark.Dict:
+		return e.encodeDict(v)
+	case *starlark.List:
+		e.buf = append(e.buf, '[')
+		for i := range v.Len() {
+			if i > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			if !e.encodeValue(v.Index(i)) {
+				return false
+			}
+		}
+		e.buf = append(e.buf, ']')
+	case starlark.Tuple:
+		e.buf = append(e.buf, '[')
+		for i, item := range v {
+			if i > 0 {
+				e.buf = append(e.buf, ',')
+			}
+			if !e.encodeValue(item) {
+				return false
+			}
+		}
+		e.buf = append(e.buf, ']')
+	default:
+		return false
+	}
+	return true
+}
+
+// encodeDict encodes a dict with string keys, in sorted key order like encoding/json does for maps
+func (e *JSONEncoder) encodeDict(d *starlark.Dict) bool {
+	start := len(e.keys)
+	iter := d.Iterate()
+	var key starlark.Value
+	for iter.Next(&key) {
+		if _, ok := key.(starlark.String); !ok {
+			iter.Done()
+			return false
+		}
+		e.keys = append(e.keys, key)
+	}
+	iter.Done()
+	slices.SortFunc(e.keys[start:], func(a, b starlark.Value) int {
+		return strings.Compare(string(a.(starlark.String)), string(b.(starlark.String)))
+	})
+
+	e.buf = append(e.buf, '{')
+	for i := start; i < len(e.keys); i++ {
+		if i > start {
+			e.buf = append(e.buf, ',')
+		}
+		e.buf = appendJSONString(e.buf, string(e.keys[i].(starlark.String)))
+		e.buf = append(e.buf, ':')
+		value, _, _ := d.Get(e.keys[i])
+		if !e.encodeValue(value) {
+			return false
+		}
+	}
+	e.buf = append(e.buf, '}')
+	e.keys = e.keys[:start]
+	return true
+}
+
+// encodeFloat uses the same format as encoding/json. NaN and infinity are not valid JSON,
+// they are left to the fallback for the error
+func (e *JSONEncoder) encodeFloat(f float64) bool {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return false
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	e.buf = strconv.AppendFloat(e.buf, f, format, -1, 64)
+	if format == 'e' {
+		// Clean up e-09 to e-9
+		n := len(e.buf)
+		if n >= 4 && e.buf[n-4] == 'e' && e.buf[n-3] == '-' && e.buf[n-2] == '0' {
+			e.buf[n-2] = e.buf[n-1]
+			e.buf = e.buf[:n-1]
+		}
+	}
+	return true
+}
+
+const hexDigits = "0123456789abcdef"
+
+// appendJSONString appends the quoted string with the same escaping as encoding/json, with
+// HTML escaping enabled
+func appendJSONString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= ' ' && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				// Control characters and <, >, & are written as \u00XX
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 and U+2029 are escaped so that the JSON is safe to embed in script tags
+		if r == '\u2028' || r == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
@@ -0,0 +1,95 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package starlark_type
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+	"time"
+
+	startime "go.starlark.net/lib/time"
+	"go.starlark.net/starlark"
+)
+
+// encodeViaUnmarshal is the JSON encoding done before the direct encoder was added
+func encodeViaUnmarshal(v starlark.Value) (string, error) {
+	goValue, err := UnmarshalStarlark(v)
+	if err != nil {
+		return "", err
+	}
+	buf := bytes.Buffer{}
+	if err := json.NewEncoder(&buf).Encode(goValue); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func TestJSONEncoderEquivalence(t *testing.T) {
+	t.Parallel()
+
+	nested := starlark.NewDict(2)
+	nested.SetKey(starlark.String("z"), starlark.MakeInt(1))                                                     //nolint:errcheck
+	nested.SetKey(starlark.String("a"), starlark.NewList([]starlark.Value{starlark.String("x"), starlark.None})) //nolint:errcheck
+
+	values := map[string]starlark.Value{
+		"record":   buildStarlarkDict(t, appRecordMap(3)),
+		"list":     starlark.NewList([]starlark.Value{buildStarlarkDict(t, appRecordMap(1)), buildStarlarkDict(t, appRecordMap(2))}),
+		"nested":   nested,
+		"empty":    starlark.NewDict(0),
+		"emptyl":   starlark.NewList(nil),
+		"tuple":    starlark.Tuple{starlark.True, starlark.False, starlark.MakeInt(-5)},
+		"none":     starlark.None,
+		"html":     starlark.String(`<a href="x">&'\` + "  "),
+		"control":  starlark.String("\x00\x01\b\f\n\r\t\x1f\x7f"),
+		"unicode":  starlark.String("héllo 世界 \xff\xfe end"),
+		"floats":   starlark.NewList([]starlark.Value{starlark.Float(0.5), starlark.Float(1e21), starlark.Float(1e20), starlark.Float(1e-7), starlark.Float(-2.5e-9), starlark.Float(0), starlark.Float(100)}),
+		"time":     startime.Time(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+		"timedict": buildStarlarkDict(t, map[string]any{"a": 1}),
+	}
+	intKeys := starlark.NewDict(1)
+	intKeys.SetKey(starlark.MakeInt(1), starlark.String("a")) //nolint:errcheck
+	values["intkeys"] = intKeys
+	values["timedict"].(*starlark.Dict).SetKey(starlark.String("t"), startime.Time(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC))) //nolint:errcheck
+
+	encoder := GetJSONEncoder()
+	defer PutJSONEncoder(encoder)
+	for name, value := range values {
+		expected, err := encodeViaUnmarshal(value)
+		if err != nil {
+			t.Fatalf("%s: encode via unmarshal: %v", name, err)
+		}
+		if err = encoder.Encode(value); err != nil {
+			t.Fatalf("%s: encode: %v", name, err)
+		}
+		if got := string(encoder.Bytes()); got != expected {
+			t.Errorf("%s: got %q, want %q", name, got, expected)
+		}
+	}
+}
+
+func TestJSONEncoderErrors(t *testing.T) {
+	t.Parallel()
+
+	values := map[string]starlark.Value{
+		"nan":    starlark.Float(math.NaN()),
+		"bigint": starlark.MakeBigInt(new(big.Int).Lsh(big.NewInt(1), 70)),
+		"set":    starlark.NewSet(0),
+	}
+
+	encoder := GetJSONEncoder()
+	defer PutJSONEncoder(encoder)
+	for name, value := range values {
+		_, expected := encodeViaUnmarshal(value)
+		if expected == nil {
+			t.Fatalf("%s: expected error", name)
+		}
+		err := encoder.Encode(value)
+		if err == nil || err.Error() != expected.Error() {
+			t.Errorf("%s: got error %v, want %v", name, err, expected)
+		}
+	}
+}
@@ -43,6 +43,8 @@ type Request struct {
 	UserEmail      string
 	CustomPerms    []string
 	AppRBACEnabled bool
+	UserGroups     []string          // the user's login groups, for the permission checks of appBlock. Not exposed to starlark
+	Env            map[string]string // the env values declared by the app in ace.app env
 	Data           any
 }
 
@@ -94,6 +96,8 @@ func (r Request) Attr(name string) (starlark.Value, error) {
 		return MarshalStarlark(r.CustomPerms)
 	case "AppRBACEnabled":
 		return starlark.Bool(r.AppRBACEnabled), nil
+	case "Env":
+		return MarshalStarlark(r.Env)
 	case "Data":
 		return MarshalStarlark(r.Data)
 	default:
@@ -102,7 +106,7 @@ func (r Request) Attr(name string) (starlark.Value, error) {
 }
 
 func (r Request) AttrNames() []string {
-	return []string{"AppName", "AppPath", "AppUrl", "PagePath", "PageUrl", "Method", "IsDev", "IsPartial", "PushEvents", "HtmxVersion", "Headers", "RemoteIP", "UrlParams", "Form", "Query", "PostForm", "UserId", "UserSubject", "UserEmail", "CustomPerms", "AppRBACEnabled", "Data"}
+	return []string{"AppName", "AppPath", "AppUrl", "PagePath", "PageUrl", "Method", "IsDev", "IsPartial", "PushEvents", "HtmxVersion", "Headers", "RemoteIP", "UrlParams", "Form", "Query", "PostForm", "UserId", "UserSubject", "UserEmail", "CustomPerms", "AppRBACEnabled", "Env", "Data"}
 }
 
 func (r Request) String() string {
@@ -94,3 +94,38 @@ func BenchmarkMarshalStarlarkDict(b *testing.B) {
 		}
 	}
 }
+
+// BenchmarkJSONViaUnmarshal measures the JSON API response encoding through the Go values,
+// for comparison with BenchmarkJSONEncoder
+func BenchmarkJSONViaUnmarshal(b *testing.B) {
+	list := benchRecordList(b)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := encodeViaUnmarshal(list); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkJSONEncoder measures the direct starlark to JSON encoding used for JSON API responses
+func BenchmarkJSONEncoder(b *testing.B) {
+	list := benchRecordList(b)
+
+	b.ReportAllocs()
+	for b.Loop() {
+		encoder := GetJSONEncoder()
+		if err := encoder.Encode(list); err != nil {
+			b.Fatal(err)
+		}
+		PutJSONEncoder(encoder)
+	}
+}
+
+func benchRecordList(b *testing.B) starlark.Value {
+	elems := make([]starlark.Value, 50)
+	for i := range elems {
+		elems[i] = buildStarlarkDict(b, appRecordMap(i))
+	}
+	return starlark.NewList(elems)
+}
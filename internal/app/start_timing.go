@@ -0,0 +1,51 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"time"
+
+	"github.com/openrundev/openrun/internal/telemetry"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// msSince returns the time since start in milliseconds
+func msSince(start time.Time) float64 {
+	return durationMs(time.Since(start))
+}
+
+// finishLoadTiming saves the timing of the completed app load and records the metrics. The load
+// timing is set only while a reload is running, with the init mutex held
+func (a *App) finishLoadTiming(ctx context.Context) {
+	timing := a.loadTiming
+	a.loadTiming = nil
+	if timing == nil {
+		return
+	}
+	timing.TotalMs = msSince(timing.Time)
+	a.startTiming.Store(timing)
+	a.Info().Bool("reload", timing.Reload).Float64("total_ms", timing.TotalMs).Float64("source_load_ms", timing.SourceLoadMs).
+		Float64("starlark_init_ms", timing.StarlarkInitMs).Float64("template_parse_ms", timing.TemplateParseMs).
+		Float64("container_start_ms", timing.ContainerStartMs).Msg("App loaded")
+	telemetry.RecordAppStart(ctx, telemetry.AppStartPhases{
+		Reload:         timing.Reload,
+		Total:          timing.TotalMs,
+		SourceLoad:     timing.SourceLoadMs,
+		StarlarkInit:   timing.StarlarkInitMs,
+		TemplateParse:  timing.TemplateParseMs,
+		ContainerStart: timing.ContainerStartMs,
+	}, a.telemetryIdentityAttrs...)
+}
+
+// StartTiming returns the time taken by the last successful load of the app, nil if the app was
+// not loaded yet
+func (a *App) StartTiming() *types.AppStartTiming {
+	timing := a.startTiming.Load()
+	if timing == nil {
+		return nil
+	}
+	ret := *timing
+	return &ret
+}
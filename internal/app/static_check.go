@@ -0,0 +1,796 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fmt"
+	"html/template"
+	"regexp"
+	"slices"
+	"strings"
+	"text/template/parse"
+
+	"github.com/openrundev/openrun/internal/app/apptype"
+	"github.com/openrundev/openrun/internal/plugin"
+	"go.starlark.net/resolve"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// typeAnnotationRegex matches the optional type annotation comment on a def line, like
+// def handler(req):  # type: (Request) -> dict
+var typeAnnotationRegex = regexp.MustCompile(`#\s*type:\s*\((.*)\)\s*->\s*(.+?)\s*$`)
+
+// annotationTypes are the type names supported in type annotations. Schema types are referenced
+// as doc.<TypeName>
+var annotationTypes = []string{"any", "None", "bool", "int", "float", "str", "bytes", "dict", "list", "tuple", "Request"}
+
+// routeHandlerArgs is the positional index of the handler argument for the ace route builtins
+var routeHandlerArgs = map[string]int{apptype.HTML: 3, apptype.FRAGMENT: 2, apptype.API: 1}
+
+// staticFunc is a top level function defined in an app starlark file
+type staticFunc struct {
+	def           *syntax.DefStmt
+	params        []string // param names, excluding *args and **kwargs
+	required      []bool
+	numPositional int // params before the * separator
+	varargs       bool
+	kwargs        bool
+	argTypes      [][]string // from the type annotation, nil if not annotated
+	returnType    []string
+}
+
+// templateRef is a template name used in a route definition or a block name used in ace.response
+type templateRef struct {
+	pos        syntax.Position
+	name       string
+	block      bool     // block name from ace.response
+	dataSource string   // the handler or ace.response providing the data for the template
+	dataKeys   []string // keys in the template data, nil if not known statically
+}
+
+// staticChecker runs load time checks on the app starlark files, so that errors are reported
+// with the file location before the code is run. All the undefined names, calls to unknown plugin
+// functions, wrong arity calls to functions defined in the app and type annotation mismatches are
+// reported together
+type staticChecker struct {
+	builtin   starlark.StringDict
+	readFile  func(module string) ([]byte, error)
+	pluginMap func(modulePath string) (plugin.PluginMap, bool)
+
+	fileFuncs     map[string]map[string]*staticFunc // top level functions in each checked file
+	issues        []string
+	templateRefs  []templateRef
+	fragmentCalls map[*syntax.CallExpr]*staticFunc // routes in fragments lists, with the page handler used by default
+	usesDefault   bool                             // whether a route uses the default handler
+}
+
+type loadedPlugin struct {
+	path  string
+	funcs plugin.PluginMap
+}
+
+func newStaticChecker(builtin starlark.StringDict, readFile func(module string) ([]byte, error),
+	pluginMap func(modulePath string) (plugin.PluginMap, bool)) *staticChecker {
+	return &staticChecker{
+		builtin:       builtin,
+		readFile:      readFile,
+		pluginMap:     pluginMap,
+		fileFuncs:     map[string]map[string]*staticFunc{},
+		fragmentCalls: map[*syntax.CallExpr]*staticFunc{},
+	}
+}
+
+func (c *staticChecker) addIssue(pos syntax.Position, format string, args ...any) {
+	c.issues = append(c.issues, fmt.Sprintf("%s: %s", pos, fmt.Sprintf(format, args...)))
+}
+
+func (c *staticChecker) err() error {
+	if len(c.issues) == 0 {
+		return nil
+	}
+	return fmt.Errorf("static checks failed:\n%s", strings.Join(c.issues, "\n"))
+}
+
+// checkApp checks the app.star file and the starlark files loaded by it
+func (c *staticChecker) checkApp(fileName string, src []byte) {
+	funcs := c.checkFile(fileName, src)
+	if handler, ok := funcs[apptype.DEFAULT_HANDLER]; ok && c.usesDefault {
+		c.checkHandler(handler.def.Def, handler, "the default handler")
+	}
+}
+
+// checkFile checks one starlark file, returning the top level functions defined in the file
+func (c *staticChecker) checkFile(fileName string, src []byte) map[string]*staticFunc {
+	if funcs, ok := c.fileFuncs[fileName]; ok {
+		// Already checked. For a load cycle, this returns the partial list, the cycle is
+		// reported by the loader
+		return funcs
+	}
+	funcs := map[string]*staticFunc{}
+	c.fileFuncs[fileName] = funcs
+
+	file, err := AppFileOptions().Parse(fileName, src, 0)
+	if err != nil {
+		c.issues = append(c.issues, err.Error())
+		return funcs
+	}
+	if err := resolve.File(file, c.builtin.Has, starlark.Universe.Has); err != nil {
+		if errList, ok := err.(resolve.ErrorList); ok {
+			for _, e := range errList {
+				c.addIssue(e.Pos, "%s", e.Msg)
+			}
+		} else {
+			c.issues = append(c.issues, err.Error())
+		}
+	}
+
+	// The functions and plugins are looked up using the first binding of the name, since
+	// references from within functions get a new binding
+	lines := strings.Split(string(src), "\n")
+	boundFuncs := map[*syntax.Ident]*staticFunc{}
+	plugins := map[*syntax.Ident]loadedPlugin{}
+	for _, stmt := range file.Stmts {
+		switch stmt := stmt.(type) {
+		case *syntax.LoadStmt:
+			c.checkLoad(stmt, boundFuncs, plugins)
+		case *syntax.DefStmt:
+			fn := c.newStaticFunc(fileName, stmt, lines)
+			funcs[stmt.Name.Name] = fn
+			boundFuncs[stmt.Name] = fn
+		}
+	}
+
+	syntax.Walk(file, func(n syntax.Node) bool {
+		switch n := n.(type) {
+		case *syntax.DotExpr:
+			c.checkDot(n, plugins)
+		case *syntax.CallExpr:
+			c.checkCall(n, boundFuncs, plugins)
+		}
+		return true
+	})
+	return funcs
+}
+
+// firstBinding returns the identifier which first bound the name, nil if the name is not bound
+func firstBinding(id *syntax.Ident) *syntax.Ident {
+	binding, ok := id.Binding.(*resolve.Binding)
+	if !ok || binding == nil {
+		return nil
+	}
+	return binding.First
+}
+
+func isPredeclared(id *syntax.Ident) bool {
+	binding, ok := id.Binding.(*resolve.Binding)
+	return ok && binding != nil && binding.Scope == resolve.Predeclared
+}
+
+func (c *staticChecker) checkLoad(stmt *syntax.LoadStmt, boundFuncs map[*syntax.Ident]*staticFunc, plugins map[*syntax.Ident]loadedPlugin) {
+	module, _ := stmt.Module.Value.(string)
+	if strings.HasSuffix(module, apptype.STARLARK_FILE_SUFFIX) {
+		src, err := c.readFile(module)
+		if err != nil {
+			c.addIssue(stmt.Module.TokenPos, "cannot load %s: %s", module, err)
+			return
+		}
+		loaded := c.checkFile(module, src)
+		for i, from := range stmt.From {
+			if fn, ok := loaded[from.Name]; ok {
+				boundFuncs[stmt.To[i]] = fn
+			}
+		}
+		return
+	}
+
+	modulePath, moduleName, _ := parseModulePath(module)
+	pluginFuncs, ok := c.pluginMap(modulePath)
+	if !ok {
+		c.addIssue(stmt.Module.TokenPos, "unknown plugin %s", modulePath)
+		return
+	}
+	for i, from := range stmt.From {
+		if from.Name != moduleName {
+			c.addIssue(from.NamePos, "plugin %s does not define %s, load it as %q", modulePath, from.Name, moduleName)
+			continue
+		}
+		plugins[stmt.To[i]] = loadedPlugin{path: modulePath, funcs: pluginFuncs}
+	}
+}
+
+// checkDot checks references to plugin functions and to members of the builtin modules, like ace and param
+func (c *staticChecker) checkDot(dot *syntax.DotExpr, plugins map[*syntax.Ident]loadedPlugin) {
+	x, ok := dot.X.(*syntax.Ident)
+	if !ok {
+		return
+	}
+	if p, ok := plugins[firstBinding(x)]; ok {
+		if _, ok := p.funcs[dot.Name.Name]; !ok {
+			c.addIssue(dot.Name.NamePos, "plugin %s has no function %s", p.path, dot.Name.Name)
+		}
+		return
+	}
+	if !isPredeclared(x) {
+		return
+	}
+	if module, ok := c.builtin[x.Name].(*starlarkstruct.Module); ok {
+		if _, ok := module.Members[dot.Name.Name]; !ok {
+			c.addIssue(dot.Name.NamePos, "%s.%s is not defined", x.Name, dot.Name.Name)
+		}
+	}
+}
+
+func (c *staticChecker) checkCall(call *syntax.CallExpr, boundFuncs map[*syntax.Ident]*staticFunc, plugins map[*syntax.Ident]loadedPlugin) {
+	switch fn := call.Fn.(type) {
+	case *syntax.Ident:
+		if sf, ok := boundFuncs[firstBinding(fn)]; ok {
+			c.checkArgs(call, sf, fn.Name)
+		}
+	case *syntax.DotExpr:
+		x, ok := fn.X.(*syntax.Ident)
+		if !ok {
+			return
+		}
+		if p, ok := plugins[firstBinding(x)]; ok {
+			if info, ok := p.funcs[fn.Name.Name]; ok && info.HandlerName == "" {
+				c.addIssue(fn.Name.NamePos, "%s.%s is a constant, not a function", x.Name, fn.Name.Name)
+			}
+			return
+		}
+		if x.Name != apptype.DEFAULT_MODULE || !isPredeclared(x) {
+			return
+		}
+
+		builtinName := fn.Name.Name
+		pageHandler, isFragment := c.fragmentCalls[call]
+		var handlerFunc *staticFunc
+		if index, ok := routeHandlerArgs[builtinName]; ok {
+			handlerArg := callArg(call, "handler", index)
+			if handler, ok := handlerArg.(*syntax.Ident); ok {
+				if sf, ok := boundFuncs[firstBinding(handler)]; ok {
+					c.checkHandler(handler.NamePos, sf, "handler "+handler.Name)
+					handlerFunc = sf
+				}
+			} else if handlerArg == nil && isFragment {
+				handlerFunc = pageHandler
+			} else if handlerArg == nil && builtinName != apptype.FRAGMENT {
+				c.usesDefault = true
+			}
+		}
+		switch builtinName {
+		case apptype.HTML:
+			c.addTemplateRef(callArg(call, "full", 1), handlerFunc)
+			c.addTemplateRef(callArg(call, "partial", 2), handlerFunc)
+			// The page is visited before the fragments, so the fragment routes can be marked here
+			if fragments, ok := callArg(call, "fragments", 4).(*syntax.ListExpr); ok {
+				for _, fragment := range fragments.List {
+					if fragmentCall, ok := fragment.(*syntax.CallExpr); ok {
+						c.fragmentCalls[fragmentCall] = handlerFunc
+					}
+				}
+			}
+		case apptype.FRAGMENT:
+			c.addTemplateRef(callArg(call, "partial", 1), handlerFunc)
+		case apptype.RESPONSE:
+			c.addBlockRef(call)
+		}
+	}
+}
+
+// callArg returns the argument passed by keyword or at the positional index, nil if not passed
+func callArg(call *syntax.CallExpr, name string, index int) syntax.Expr {
+	position := 0
+	for _, arg := range call.Args {
+		if binary, ok := arg.(*syntax.BinaryExpr); ok && binary.Op == syntax.EQ {
+			if id, ok := binary.X.(*syntax.Ident); ok && id.Name == name {
+				return binary.Y
+			}
+			continue
+		}
+		if unary, ok := arg.(*syntax.UnaryExpr); ok && (unary.Op == syntax.STAR || unary.Op == syntax.STARSTAR) {
+			return nil
+		}
+		if position == index {
+			return arg
+		}
+		position++
+	}
+	return nil
+}
+
+// stringLiteral returns the value of a string literal, empty string for other expressions
+func stringLiteral(expr syntax.Expr) (string, syntax.Position) {
+	if lit, ok := expr.(*syntax.Literal); ok && lit.Token == syntax.STRING {
+		name, _ := lit.Value.(string)
+		return name, lit.TokenPos
+	}
+	return "", syntax.Position{}
+}
+
+// addTemplateRef adds a template used by a route. If the handler is known, the data keys
+// returned by it are recorded for checking the template variables
+func (c *staticChecker) addTemplateRef(expr syntax.Expr, handler *staticFunc) {
+	name, pos := stringLiteral(expr)
+	if name == "" {
+		return
+	}
+	ref := templateRef{pos: pos, name: name}
+	if handler != nil {
+		ref.dataSource = "handler " + handler.def.Name.Name
+		ref.dataKeys = handlerDataKeys(handler)
+	}
+	c.templateRefs = append(c.templateRefs, ref)
+}
+
+// addBlockRef adds the block used in an ace.response call, with the keys of the data if
+// it is a dict literal
+func (c *staticChecker) addBlockRef(call *syntax.CallExpr) {
+	name, pos := stringLiteral(callArg(call, "block", 1))
+	if name == "" {
+		return
+	}
+	c.templateRefs = append(c.templateRefs, templateRef{pos: pos, name: name, block: true,
+		dataSource: apptype.DEFAULT_MODULE + "." + apptype.RESPONSE, dataKeys: dictKeys(callArg(call, "data", 0))})
+}
+
+// dictKeys returns the keys of a dict literal, nil if the expression is not a dict literal
+// with string keys
+func dictKeys(expr syntax.Expr) []string {
+	if paren, ok := expr.(*syntax.ParenExpr); ok {
+		expr = paren.X
+	}
+	dict, ok := expr.(*syntax.DictExpr)
+	if !ok {
+		return nil
+	}
+	keys := []string{}
+	for _, entry := range dict.List {
+		key, _ := stringLiteral(entry.(*syntax.DictEntry).Key)
+		if key == "" {
+			return nil
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// handlerDataKeys returns the keys of the data returned by a handler, for rendering the route
+// template. The keys are known only if every value returned is a dict literal, None or an
+// ace.response/ace.redirect (which do not render the route template). Nil is returned otherwise
+func handlerDataKeys(fn *staticFunc) []string {
+	var keys []string
+	known, found := true, false
+	for _, stmt := range fn.def.Body {
+		syntax.Walk(stmt, func(n syntax.Node) bool {
+			switch n := n.(type) {
+			case *syntax.DefStmt, *syntax.LambdaExpr:
+				return false
+			case *syntax.ReturnStmt:
+				if literalType(n.Result) == "None" || isAceCall(n.Result, apptype.RESPONSE, apptype.REDIRECT) {
+					return false
+				}
+				retKeys := dictKeys(n.Result)
+				if retKeys == nil {
+					known = false
+				}
+				found = true
+				keys = append(keys, retKeys...)
+			}
+			return true
+		})
+	}
+	if !known || !found {
+		return nil
+	}
+	slices.Sort(keys)
+	return slices.Compact(keys)
+}
+
+// isAceCall checks whether the expression is a call to one of the given ace builtins
+func isAceCall(expr syntax.Expr, names ...string) bool {
+	call, ok := expr.(*syntax.CallExpr)
+	if !ok {
+		return false
+	}
+	dot, ok := call.Fn.(*syntax.DotExpr)
+	if !ok {
+		return false
+	}
+	x, ok := dot.X.(*syntax.Ident)
+	return ok && x.Name == apptype.DEFAULT_MODULE && isPredeclared(x) && slices.Contains(names, dot.Name.Name)
+}
+
+// checkArgs checks the call arguments against the function params. Calls with *args or
+// **kwargs are not checked
+func (c *staticChecker) checkArgs(call *syntax.CallExpr, fn *staticFunc, name string) {
+	var positional []syntax.Expr
+	var keywords []*syntax.BinaryExpr
+	for _, arg := range call.Args {
+		if unary, ok := arg.(*syntax.UnaryExpr); ok && (unary.Op == syntax.STAR || unary.Op == syntax.STARSTAR) {
+			return
+		}
+		if binary, ok := arg.(*syntax.BinaryExpr); ok && binary.Op == syntax.EQ {
+			keywords = append(keywords, binary)
+			continue
+		}
+		positional = append(positional, arg)
+	}
+
+	if len(positional) > fn.numPositional && !fn.varargs {
+		c.addIssue(call.Lparen, "function %s accepts no more than %d positional arguments (%d given)", name, fn.numPositional, len(positional))
+	}
+	passed := map[string]bool{}
+	for i := range min(len(positional), fn.numPositional) {
+		passed[fn.params[i]] = true
+		if fn.argTypes != nil {
+			c.checkType(call.Lparen, positional[i], fn.argTypes[i], fmt.Sprintf("argument %s of %s", fn.params[i], name))
+		}
+	}
+	for _, kw := range keywords {
+		id := kw.X.(*syntax.Ident)
+		index := slices.Index(fn.params, id.Name)
+		switch {
+		case index < 0 && !fn.kwargs:
+			c.addIssue(id.NamePos, "function %s got an unexpected keyword argument %s", name, id.Name)
+		case index >= 0 && passed[id.Name]:
+			c.addIssue(id.NamePos, "function %s got multiple values for parameter %s", name, id.Name)
+		case index >= 0:
+			passed[id.Name] = true
+			if fn.argTypes != nil {
+				c.checkType(id.NamePos, kw.Y, fn.argTypes[index], fmt.Sprintf("argument %s of %s", id.Name, name))
+			}
+		}
+	}
+
+	var missing []string
+	for i, param := range fn.params {
+		if fn.required[i] && !passed[param] {
+			missing = append(missing, param)
+		}
+	}
+	if len(missing) > 0 {
+		c.addIssue(call.Lparen, "function %s missing %d argument(s) (%s)", name, len(missing), strings.Join(missing, ", "))
+	}
+}
+
+// checkHandler checks that a route handler accepts the request argument, or no
+// arguments for the handlers named *_no_args
+func (c *staticChecker) checkHandler(pos syntax.Position, fn *staticFunc, desc string) {
+	requiredPositional, requiredKeyword := 0, 0
+	for i, required := range fn.required {
+		if required && i < fn.numPositional {
+			requiredPositional++
+		} else if required {
+			requiredKeyword++
+		}
+	}
+	if pos != fn.def.Def {
+		desc = fmt.Sprintf("%s (defined at %s)", desc, fn.def.Def)
+	}
+	if strings.HasSuffix(fn.def.Name.Name, "_no_args") {
+		// Handlers named *_no_args are called without the request
+		if requiredPositional > 0 || requiredKeyword > 0 {
+			c.addIssue(pos, "%s should not require any arguments", desc)
+		}
+		return
+	}
+	if (fn.numPositional == 0 && !fn.varargs) || requiredPositional > 1 || requiredKeyword > 0 {
+		c.addIssue(pos, "%s should accept one argument, the request", desc)
+		return
+	}
+	if len(fn.argTypes) > 0 && !slices.Contains(fn.argTypes[0], "Request") && !slices.Contains(fn.argTypes[0], "any") {
+		c.addIssue(pos, "%s request param is annotated as %s, should be Request", desc, strings.Join(fn.argTypes[0], " | "))
+	}
+}
+
+func (c *staticChecker) newStaticFunc(fileName string, def *syntax.DefStmt, lines []string) *staticFunc {
+	fn := &staticFunc{def: def}
+	seenStar := false
+	for _, param := range def.Params {
+		switch p := param.(type) {
+		case *syntax.Ident:
+			fn.params = append(fn.params, p.Name)
+			fn.required = append(fn.required, true)
+		case *syntax.BinaryExpr:
+			if id, ok := p.X.(*syntax.Ident); ok {
+				fn.params = append(fn.params, id.Name)
+				fn.required = append(fn.required, false)
+			}
+		case *syntax.UnaryExpr:
+			if p.Op == syntax.STARSTAR {
+				fn.kwargs = true
+			} else {
+				seenStar = true
+				fn.varargs = p.X != nil
+			}
+			continue
+		}
+		if !seenStar {
+			fn.numPositional++
+		}
+	}
+
+	// The annotation is a comment on the def line, or on the last line of a multi-line def
+	lastLine := def.Def.Line
+	if len(def.Body) > 0 {
+		bodyStart, _ := def.Body[0].Span()
+		lastLine = max(def.Def.Line, bodyStart.Line-1)
+	}
+	for line := def.Def.Line; line <= lastLine && int(line) <= len(lines); line++ {
+		match := typeAnnotationRegex.FindStringSubmatchIndex(lines[line-1])
+		if match == nil {
+			continue
+		}
+		text := lines[line-1]
+		pos := syntax.MakePosition(&fileName, line, int32(match[0]+1))
+		c.parseAnnotation(pos, fn, text[match[2]:match[3]], text[match[4]:match[5]])
+		break
+	}
+	return fn
+}
+
+func (c *staticChecker) parseAnnotation(pos syntax.Position, fn *staticFunc, params, ret string) {
+	name := fn.def.Name.Name
+	var argTypes [][]string
+	if params = strings.TrimSpace(params); params != "" {
+		for _, param := range strings.Split(params, ",") {
+			argTypes = append(argTypes, c.parseType(pos, name, param))
+		}
+	}
+	fn.returnType = c.parseType(pos, name, ret)
+
+	if len(argTypes) != len(fn.params) {
+		c.addIssue(pos, "type annotation for %s has %d params, the function has %d", name, len(argTypes), len(fn.params))
+	} else {
+		fn.argTypes = argTypes
+	}
+
+	// Check the literal values returned by the function, nested functions are skipped
+	for _, stmt := range fn.def.Body {
+		syntax.Walk(stmt, func(n syntax.Node) bool {
+			switch n := n.(type) {
+			case *syntax.DefStmt, *syntax.LambdaExpr:
+				return false
+			case *syntax.ReturnStmt:
+				c.checkType(n.Return, n.Result, fn.returnType, "return value of "+name)
+			}
+			return true
+		})
+	}
+}
+
+// parseType parses a type annotation like "dict | None"
+func (c *staticChecker) parseType(pos syntax.Position, funcName, typeStr string) []string {
+	var ret []string
+	for _, t := range strings.Split(typeStr, "|") {
+		t = strings.TrimSpace(t)
+		if !slices.Contains(annotationTypes, t) && !c.isSchemaType(t) {
+			c.addIssue(pos, "unknown type %q in type annotation for %s", t, funcName)
+		}
+		ret = append(ret, t)
+	}
+	return ret
+}
+
+func (c *staticChecker) isSchemaType(t string) bool {
+	moduleName, typeName, ok := strings.Cut(t, ".")
+	if !ok || moduleName != apptype.DOC_MODULE {
+		return false
+	}
+	module, ok := c.builtin[moduleName].(*starlarkstruct.Module)
+	return ok && module.Members[typeName] != nil
+}
+
+func (c *staticChecker) checkType(pos syntax.Position, expr syntax.Expr, allowed []string, desc string) {
+	valueType := literalType(expr)
+	if valueType == "" {
+		return
+	}
+	for _, t := range allowed {
+		if t == "any" || t == valueType || (valueType == "int" && t == "float") ||
+			(valueType == "dict" && !slices.Contains(annotationTypes, t)) {
+			return
+		}
+	}
+	c.addIssue(pos, "%s is %s, annotated as %s", desc, valueType, strings.Join(allowed, " | "))
+}
+
+// literalType returns the type of literal values, empty string if the type is not known statically
+func literalType(expr syntax.Expr) string {
+	switch e := expr.(type) {
+	case nil:
+		return "None"
+	case *syntax.ParenExpr:
+		return literalType(e.X)
+	case *syntax.Literal:
+		switch e.Token {
+		case syntax.STRING:
+			return "str"
+		case syntax.BYTES:
+			return "bytes"
+		case syntax.INT:
+			return "int"
+		case syntax.FLOAT:
+			return "float"
+		}
+	case *syntax.DictExpr:
+		return "dict"
+	case *syntax.ListExpr:
+		return "list"
+	case *syntax.TupleExpr:
+		return "tuple"
+	case *syntax.Comprehension:
+		if e.Curly {
+			return "dict"
+		}
+		return "list"
+	case *syntax.Ident:
+		if binding, ok := e.Binding.(*resolve.Binding); ok && binding != nil && binding.Scope == resolve.Universal {
+			switch e.Name {
+			case "None":
+				return "None"
+			case "True", "False":
+				return "bool"
+			}
+		}
+	}
+	return ""
+}
+
+// staticCheck runs the load time checks on app.star and the starlark files loaded by it. The
+// template references are returned, they are checked after the templates are parsed
+func (a *App) staticCheck(src []byte, builtin starlark.StringDict) ([]templateRef, error) {
+	checker := newStaticChecker(builtin, func(module string) ([]byte, error) {
+		return a.sourceFS.ReadFile(a.getStarPath(module))
+	}, func(modulePath string) (plugin.PluginMap, bool) {
+		pluginMap, ok := builtInPlugins[modulePath]
+		return pluginMap, ok
+	})
+	checker.checkApp(a.getStarPath(apptype.APP_FILE_NAME), src)
+	return checker.templateRefs, checker.err()
+}
+
+// checkTemplateRefs checks that the templates named in the route definitions and the blocks named
+// in ace.response are defined. If the data keys are known, the .Data fields referenced in the
+// templates are checked against them. All the issues are reported together
+func (a *App) checkTemplateRefs() error {
+	if a.template == nil && a.templateBase == nil {
+		return nil
+	}
+
+	var issues []string
+	for _, ref := range a.templateRefs {
+		t := a.lookupTemplate(ref.name)
+		if t == nil {
+			kind := "template"
+			if ref.block {
+				kind = "block"
+			}
+			issues = append(issues, fmt.Sprintf("%s: %s %s is not defined", ref.pos, kind, ref.name))
+			continue
+		}
+		if ref.dataKeys == nil {
+			continue
+		}
+		for _, field := range templateDataFields(t, ref.name) {
+			if !slices.Contains(ref.dataKeys, field.key) {
+				issues = append(issues, fmt.Sprintf("%s: .Data.%s used in %s is not in the data from %s", ref.pos, field.key, field.location, ref.dataSource))
+			}
+		}
+	}
+	if len(issues) > 0 {
+		return fmt.Errorf("static checks failed:\n%s", strings.Join(slices.Compact(issues), "\n"))
+	}
+	return nil
+}
+
+// lookupTemplate returns the template with the name, which is a template file or a block defined in
+// any template. Nil is returned if the template is not defined
+func (a *App) lookupTemplate(name string) *template.Template {
+	if a.template != nil && a.template.Lookup(name) != nil {
+		return a.template.Lookup(name)
+	}
+	if a.templateBase != nil && a.templateBase.Lookup(name) != nil {
+		return a.templateBase.Lookup(name)
+	}
+	if t, ok := a.templateMap[name]; ok {
+		return t
+	}
+	for _, t := range a.templateMap {
+		if t.Lookup(name) != nil {
+			return t.Lookup(name)
+		}
+	}
+	return nil
+}
+
+// templateDefined checks whether the name is a template file or a block defined in any template
+func (a *App) templateDefined(name string) bool {
+	return a.lookupTemplate(name) != nil
+}
+
+// templateDataField is a .Data.<key> reference in a template
+type templateDataField struct {
+	key      string
+	location string
+}
+
+// templateDataFields returns the .Data fields referenced by the template, including the
+// templates it invokes with the same dot. Fields within range and with blocks are included
+// only if referenced through $
+func templateDataFields(t *template.Template, name string) []templateDataField {
+	var fields []templateDataField
+	visited := map[string]bool{}
+	var walk func(tree *parse.Tree, node parse.Node, atRoot bool)
+	addField := func(tree *parse.Tree, node parse.Node, idents []string) {
+		if len(idents) > 1 && idents[0] == "Data" {
+			location, _ := tree.ErrorContext(node)
+			fields = append(fields, templateDataField{key: idents[1], location: location})
+		}
+	}
+	var walkTemplate func(name string)
+	walk = func(tree *parse.Tree, node parse.Node, atRoot bool) {
+		switch n := node.(type) {
+		case *parse.ListNode:
+			if n == nil {
+				return
+			}
+			for _, child := range n.Nodes {
+				walk(tree, child, atRoot)
+			}
+		case *parse.ActionNode:
+			walk(tree, n.Pipe, atRoot)
+		case *parse.PipeNode:
+			if n == nil {
+				return
+			}
+			for _, cmd := range n.Cmds {
+				for _, arg := range cmd.Args {
+					walk(tree, arg, atRoot)
+				}
+			}
+		case *parse.FieldNode:
+			if atRoot {
+				addField(tree, n, n.Ident)
+			}
+		case *parse.VariableNode:
+			if n.Ident[0] == "$" {
+				addField(tree, n, n.Ident[1:])
+			}
+		case *parse.IfNode:
+			walk(tree, n.Pipe, atRoot)
+			walk(tree, n.List, atRoot)
+			walk(tree, n.ElseList, atRoot)
+		case *parse.RangeNode:
+			walk(tree, n.Pipe, atRoot)
+			walk(tree, n.List, false)
+			walk(tree, n.ElseList, atRoot)
+		case *parse.WithNode:
+			walk(tree, n.Pipe, atRoot)
+			walk(tree, n.List, false)
+			walk(tree, n.ElseList, atRoot)
+		case *parse.TemplateNode:
+			walk(tree, n.Pipe, atRoot)
+			// Invoked templates get their own $, so they are checked only if passed the root dot
+			if atRoot && n.Pipe != nil && len(n.Pipe.Cmds) == 1 && len(n.Pipe.Cmds[0].Args) == 1 {
+				if _, ok := n.Pipe.Cmds[0].Args[0].(*parse.DotNode); ok {
+					walkTemplate(n.Name)
+				}
+			}
+		}
+	}
+	walkTemplate = func(name string) {
+		if visited[name] {
+			return
+		}
+		visited[name] = true
+		if sub := t.Lookup(name); sub != nil && sub.Tree != nil {
+			walk(sub.Tree, sub.Tree.Root, true)
+		}
+	}
+	walkTemplate(name)
+	return fields
+}
@@ -0,0 +1,271 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/app/apptype"
+	"github.com/openrundev/openrun/internal/plugin"
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+func runStaticCheck(files map[string]string) ([]templateRef, error) {
+	builtin := starlark.StringDict{}
+	for k, v := range apptype.CreateBuiltin(types.NodeConfig{}, nil) {
+		builtin[k] = v
+	}
+	builtin[apptype.PARAM_MODULE] = &starlarkstruct.Module{
+		Name:    apptype.PARAM_MODULE,
+		Members: starlark.StringDict{"db_url": starlark.String("")},
+	}
+	checker := newStaticChecker(builtin, func(module string) ([]byte, error) {
+		src, ok := files[module]
+		if !ok {
+			return nil, fmt.Errorf("file not found")
+		}
+		return []byte(src), nil
+	}, func(modulePath string) (plugin.PluginMap, bool) {
+		if modulePath != "fs.in" {
+			return nil, false
+		}
+		return plugin.PluginMap{
+			"read_file": {FuncName: "read_file", HandlerName: "ReadFile"},
+			"MAX_SIZE":  {FuncName: "MAX_SIZE", ConstantValue: starlark.MakeInt(10)},
+		}, true
+	})
+	checker.checkApp("app.star", []byte(files["app.star"]))
+	return checker.templateRefs, checker.err()
+}
+
+func TestStaticCheckValid(t *testing.T) {
+	t.Parallel()
+
+	refs, err := runStaticCheck(map[string]string{
+		"app.star": `
+load("fs.in", "fs")
+load("lib.star", "fmt_name")
+
+app = ace.app("test", routes=[ace.html("/", full="index.go.html", partial="main", handler=page,
+	fragments=[ace.fragment("row", partial="row")]), ace.api("/api", handler=api)])
+
+def page(req):  # type: (Request) -> dict | None
+	if req.Query:
+		return None
+	return {"name": fmt_name("a", upper=True), "db": param.db_url, "max": fs.MAX_SIZE}
+
+def api(req, *args, **kwargs):
+	ret = fs.read_file("/tmp/a")
+	return ret.value
+
+def handler(req):
+	return page(req)
+`,
+		"lib.star": `
+def fmt_name(name, upper=False):  # type: (str, bool) -> str
+	return name.upper() if upper else name
+`,
+	})
+	testutil.AssertNoError(t, err)
+	names := []string{}
+	for _, ref := range refs {
+		names = append(names, ref.name)
+	}
+	testutil.AssertEqualsString(t, "template refs", "index.go.html,main,row", strings.Join(names, ","))
+	testutil.AssertEqualsString(t, "template ref pos", "app.star:5:50", refs[0].pos.String())
+}
+
+func TestStaticCheckErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := runStaticCheck(map[string]string{
+		"app.star": `
+load("fs.in", "fs")
+load("lib.star", "helper")
+
+app = ace.app("test", routes=[ace.htm("/"), ace.api("/api", handler=api)])
+
+def api():
+	return fs.raed_file(undefined_name)
+
+def handler(req):
+	helper(1, 2, 3)
+	helper(b=1)
+	helper(1, a=2)
+	typed(1)
+	fs.MAX_SIZE()
+	return param.db_urll
+`,
+		"lib.star": `
+def helper(a, b=2):
+	return a
+
+def typed(count):  # type: (int) -> str
+	if count:
+		return 10
+	return "ok"
+
+def bad(a, b):  # type: (int) -> Foo
+	return a
+`,
+	})
+	testutil.AssertErrorContains(t, err, "app.star:8:22: undefined: undefined_name")
+	testutil.AssertErrorContains(t, err, "app.star:5:35: ace.htm is not defined")
+	testutil.AssertErrorContains(t, err, "app.star:8:12: plugin fs.in has no function raed_file")
+	testutil.AssertErrorContains(t, err, "app.star:5:69: handler api (defined at app.star:7:1) should accept one argument, the request")
+	testutil.AssertErrorContains(t, err, "app.star:11:8: function helper accepts no more than 2 positional arguments (3 given)")
+	testutil.AssertErrorContains(t, err, "app.star:12:8: function helper missing 1 argument(s) (a)")
+	testutil.AssertErrorContains(t, err, "app.star:13:12: function helper got multiple values for parameter a")
+	testutil.AssertErrorContains(t, err, "app.star:15:5: fs.MAX_SIZE is a constant, not a function")
+	testutil.AssertErrorContains(t, err, "app.star:16:15: param.db_urll is not defined")
+	testutil.AssertErrorContains(t, err, "lib.star:7:3: return value of typed is int, annotated as str")
+	testutil.AssertErrorContains(t, err, `lib.star:10:17: unknown type "Foo" in type annotation for bad`)
+	testutil.AssertErrorContains(t, err, "lib.star:10:17: type annotation for bad has 1 params, the function has 2")
+	// typed is not loaded in app.star, so the call is reported as undefined
+	testutil.AssertErrorContains(t, err, "app.star:14:2: undefined: typed")
+}
+
+func TestStaticCheckAnnotatedCall(t *testing.T) {
+	t.Parallel()
+
+	_, err := runStaticCheck(map[string]string{
+		"app.star": `
+app = ace.app("test", routes=[ace.html("/", handler=page)])
+
+def page(req):  # type: (str) -> dict
+	return {"count": count(limit="10")}
+
+def count(limit=5):  # type: (int | None) -> int
+	return limit
+`,
+	})
+	testutil.AssertErrorContains(t, err, "app.star:5:25: argument limit of count is str, annotated as int | None")
+	testutil.AssertErrorContains(t, err, "app.star:2:53: handler page (defined at app.star:4:1) request param is annotated as str, should be Request")
+
+	_, err = runStaticCheck(map[string]string{
+		"app.star": `
+load("other.in", "other")
+app = ace.app("test")
+`,
+	})
+	testutil.AssertErrorContains(t, err, "app.star:2:6: unknown plugin other.in")
+
+	// The default handler is checked only if a route uses it
+	_, err = runStaticCheck(map[string]string{
+		"app.star": `
+app = ace.app("test", routes=[ace.html("/", handler=page, fragments=[ace.api("count")])])
+
+def page(req):
+	return {}
+
+def handler(dry_run, args):
+	return ace.result(status="done")
+`,
+	})
+	testutil.AssertNoError(t, err)
+	_, err = runStaticCheck(map[string]string{
+		"app.star": `
+app = ace.app("test", routes=[ace.api("/count")])
+
+def handler(dry_run, args):
+	return ace.result(status="done")
+`,
+	})
+	testutil.AssertErrorContains(t, err, "app.star:4:1: the default handler should accept one argument, the request")
+
+	// Handlers named *_no_args are called without the request
+	_, err = runStaticCheck(map[string]string{
+		"app.star": `
+app = ace.app("test", routes=[ace.api("/", handler=list_no_args), ace.api("/bad", handler=bad_no_args)])
+
+def list_no_args():
+	return {}
+
+def bad_no_args(req):
+	return {}
+`,
+	})
+	testutil.AssertErrorContains(t, err, "app.star:2:91: handler bad_no_args (defined at app.star:7:1) should not require any arguments")
+	if strings.Contains(err.Error(), "list_no_args") {
+		t.Errorf("unexpected issue for list_no_args: %s", err)
+	}
+}
+
+func TestStaticCheckTemplateData(t *testing.T) {
+	t.Parallel()
+
+	refs, err := runStaticCheck(map[string]string{
+		"app.star": `
+app = ace.app("test", routes=[ace.html("/", full="index.go.html", handler=page,
+	fragments=[ace.fragment("row", partial="row"), ace.fragment("edit", partial="edit", handler=edit)])])
+
+def page(req):
+	if req.Query:
+		return {"name": "a"}
+	return {"name": "b", "count": 1}
+
+def edit(req):
+	if req.Form:
+		return ace.response({"error": "x"}, block="edit_error")
+	return ace.response(build(), "edit_form")
+
+def build():
+	return {}
+`,
+	})
+	testutil.AssertNoError(t, err)
+
+	refStrs := []string{}
+	for _, ref := range refs {
+		keys := "unknown"
+		if ref.dataKeys != nil {
+			keys = strings.Join(ref.dataKeys, ",")
+		}
+		refStrs = append(refStrs, fmt.Sprintf("%s|%t|%s|%s", ref.name, ref.block, ref.dataSource, keys))
+	}
+	testutil.AssertEqualsString(t, "refs", strings.Join([]string{
+		"index.go.html|false|handler page|count,name",
+		"row|false|handler page|count,name",
+		"edit|false|handler edit|unknown",
+		"edit_error|true|ace.response|error",
+		"edit_form|true|ace.response|unknown",
+	}, "\n"), strings.Join(refStrs, "\n"))
+}
+
+func TestCheckTemplateRefs(t *testing.T) {
+	t.Parallel()
+
+	tmpl := template.Must(template.New("index.go.html").Parse(
+		`{{.Data.name}} {{range .Data.count}}{{.x}}{{$.Data.title}}{{end}}{{template "row" .}}{{template "other" .Data}}` +
+			`{{define "row"}}{{with .Data.name}}{{.y}}{{end}}{{.Data.nme}}{{end}}` +
+			`{{define "other"}}{{.Data.z}}{{end}}` +
+			`{{define "edit_error"}}{{.Data.error}} {{.Data.code}}{{end}}`))
+
+	fileName := "app.star"
+	a := &App{template: tmpl}
+	a.templateRefs = []templateRef{
+		{pos: syntax.MakePosition(&fileName, 2, 10), name: "index.go.html", dataSource: "handler page", dataKeys: []string{"count", "name"}},
+		{pos: syntax.MakePosition(&fileName, 3, 10), name: "row", dataSource: "handler page"},
+		{pos: syntax.MakePosition(&fileName, 4, 10), name: "edit_error", block: true, dataSource: "ace.response", dataKeys: []string{"error"}},
+		{pos: syntax.MakePosition(&fileName, 5, 10), name: "edit_form", block: true, dataSource: "ace.response"},
+		{pos: syntax.MakePosition(&fileName, 6, 10), name: "missing.go.html", dataSource: "handler page", dataKeys: []string{}},
+	}
+	err := a.checkTemplateRefs()
+	testutil.AssertEqualsString(t, "issues", `static checks failed:
+app.star:2:10: .Data.title used in index.go.html:1:45 is not in the data from handler page
+app.star:2:10: .Data.nme used in index.go.html:1:166 is not in the data from handler page
+app.star:4:10: .Data.code used in index.go.html:1:261 is not in the data from ace.response
+app.star:5:10: block edit_form is not defined
+app.star:6:10: template missing.go.html is not defined`, err.Error())
+
+	a.templateRefs = a.templateRefs[1:2]
+	testutil.AssertNoError(t, a.checkTemplateRefs())
+}
@@ -4,6 +4,7 @@
 package store
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -12,7 +13,9 @@ import (
 	"github.com/openrundev/openrun/internal/app/apptype"
 	"github.com/openrundev/openrun/internal/app/starlark_type"
 	"github.com/openrundev/openrun/internal/plugin"
+	"github.com/openrundev/openrun/internal/telemetry"
 	"github.com/openrundev/openrun/internal/types"
+	"go.opentelemetry.io/otel/attribute"
 	"go.starlark.net/starlark"
 )
 
@@ -59,9 +62,27 @@ func fetchTransation(thread *starlark.Thread) *sql.Tx {
 	return tx.(*sql.Tx)
 }
 
-func (s *storePlugin) Begin(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+// traceQuery returns the context for a store query, with a span for the query if store spans
+// are enabled. The returned function ends the span, recording the query error
+func traceQuery(thread *starlark.Thread, operation, table string) (context.Context, func(error)) {
 	ctx := app.GetContext(thread)
+	if !telemetry.StoreSpansEnabled() || ctx == nil {
+		return ctx, func(error) {}
+	}
+	ctx, span := telemetry.StartSpan(ctx, "openrun.store."+operation,
+		attribute.String("db.operation.name", operation),
+		attribute.String("db.collection.name", table),
+	)
+	return ctx, func(err error) {
+		telemetry.RecordError(span, err)
+		span.End()
+	}
+}
+
+func (s *storePlugin) Begin(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	ctx, endSpan := traceQuery(thread, "begin", "")
 	tx, err := s.sqlStore.Begin(ctx)
+	endSpan(err)
 	if err != nil {
 		return nil, err
 	}
@@ -71,7 +92,6 @@ func (s *storePlugin) Begin(thread *starlark.Thread, builtin *starlark.Builtin,
 }
 
 func (s *storePlugin) Commit(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
-	ctx := app.GetContext(thread)
 	tx := fetchTransation(thread)
 
 	if tx == nil {
@@ -79,7 +99,9 @@ func (s *storePlugin) Commit(thread *starlark.Thread, builtin *starlark.Builtin,
 	}
 
 	app.ClearCleanup(thread, fmt.Sprintf("transaction_%p", tx))
+	ctx, endSpan := traceQuery(thread, "commit", "")
 	err := s.sqlStore.Commit(ctx, tx)
+	endSpan(err)
 	if err != nil {
 		return nil, err
 	}
@@ -87,7 +109,6 @@ func (s *storePlugin) Commit(thread *starlark.Thread, builtin *starlark.Builtin,
 }
 
 func (s *storePlugin) Rollback(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
-	ctx := app.GetContext(thread)
 	tx := fetchTransation(thread)
 
 	if tx == nil {
@@ -95,7 +116,9 @@ func (s *storePlugin) Rollback(thread *starlark.Thread, builtin *starlark.Builti
 	}
 
 	app.ClearCleanup(thread, fmt.Sprintf("transaction_%p", tx))
+	ctx, endSpan := traceQuery(thread, "rollback", "")
 	err := s.sqlStore.Rollback(ctx, tx)
+	endSpan(err)
 	if err != nil {
 		return nil, err
 	}
@@ -110,7 +133,9 @@ func (s *storePlugin) Insert(thread *starlark.Thread, builtin *starlark.Builtin,
 		return nil, err
 	}
 
-	id, err := s.sqlStore.Insert(app.GetContext(thread), fetchTransation(thread), table, &entry)
+	ctx, endSpan := traceQuery(thread, "insert", table)
+	id, err := s.sqlStore.Insert(ctx, fetchTransation(thread), table, &entry)
+	endSpan(err)
 	if err != nil {
 		return nil, err
 	}
@@ -131,7 +156,9 @@ func (s *storePlugin) SelectById(thread *starlark.Thread, builtin *starlark.Buil
 		return nil, fmt.Errorf("invalid id value")
 	}
 
-	entry, err := s.sqlStore.SelectById(app.GetContext(thread), fetchTransation(thread), table, EntryId(idVal))
+	ctx, endSpan := traceQuery(thread, "select_by_id", table)
+	entry, err := s.sqlStore.SelectById(ctx, fetchTransation(thread), table, EntryId(idVal))
+	endSpan(err)
 	if err != nil {
 		return nil, err
 	}
@@ -151,7 +178,9 @@ func (s *storePlugin) Update(thread *starlark.Thread, builtin *starlark.Builtin,
 		return nil, err
 	}
 
-	success, err := s.sqlStore.Update(app.GetContext(thread), fetchTransation(thread), table, &entry)
+	ctx, endSpan := traceQuery(thread, "update", table)
+	success, err := s.sqlStore.Update(ctx, fetchTransation(thread), table, &entry)
+	endSpan(err)
 	if err != nil {
 		return nil, err
 	}
@@ -172,7 +201,9 @@ func (s *storePlugin) DeleteById(thread *starlark.Thread, builtin *starlark.Buil
 		return nil, fmt.Errorf("invalid id value")
 	}
 
-	rows, err := s.sqlStore.DeleteById(app.GetContext(thread), fetchTransation(thread), table, EntryId(idVal))
+	ctx, endSpan := traceQuery(thread, "delete_by_id", table)
+	rows, err := s.sqlStore.DeleteById(ctx, fetchTransation(thread), table, EntryId(idVal))
+	endSpan(err)
 	if err != nil {
 		return nil, err
 	}
@@ -201,7 +232,9 @@ func (s *storePlugin) SelectOne(thread *starlark.Thread, builtin *starlark.Built
 		return nil, errors.New("invalid filter")
 	}
 
-	entry, err := s.sqlStore.SelectOne(app.GetContext(thread), fetchTransation(thread), table, filterMap)
+	ctx, endSpan := traceQuery(thread, "select_one", table)
+	entry, err := s.sqlStore.SelectOne(ctx, fetchTransation(thread), table, filterMap)
+	endSpan(err)
 	if err != nil {
 		return nil, err
 	}
@@ -260,7 +293,9 @@ func (s *storePlugin) Select(thread *starlark.Thread, builtin *starlark.Builtin,
 		return nil, err
 	}
 
-	iterator, err := s.sqlStore.Select(app.GetContext(thread), fetchTransation(thread), thread, table, filter.data, sortList, offsetVal, limitVal)
+	ctx, endSpan := traceQuery(thread, "select", table)
+	iterator, err := s.sqlStore.Select(ctx, fetchTransation(thread), thread, table, filter.data, sortList, offsetVal, limitVal)
+	endSpan(err)
 	if err != nil {
 		return nil, err
 	}
@@ -289,7 +324,9 @@ func (s *storePlugin) Count(thread *starlark.Thread, builtin *starlark.Builtin,
 		return nil, errors.New("invalid filter")
 	}
 
-	count, err := s.sqlStore.Count(app.GetContext(thread), fetchTransation(thread), table, filterMap)
+	ctx, endSpan := traceQuery(thread, "count", table)
+	count, err := s.sqlStore.Count(ctx, fetchTransation(thread), table, filterMap)
+	endSpan(err)
 	if err != nil {
 		return nil, err
 	}
@@ -318,7 +355,9 @@ func (s *storePlugin) Delete(thread *starlark.Thread, builtin *starlark.Builtin,
 		return nil, errors.New("invalid filter")
 	}
 
-	rows, err := s.sqlStore.Delete(app.GetContext(thread), fetchTransation(thread), table, filterMap)
+	ctx, endSpan := traceQuery(thread, "delete", table)
+	rows, err := s.sqlStore.Delete(ctx, fetchTransation(thread), table, filterMap)
+	endSpan(err)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,89 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/app/apptype"
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+)
+
+func TestStreamResponseDisconnect(t *testing.T) {
+	a := &App{Logger: testutil.TestLogger(), AppEntry: &types.AppEntry{Id: "app_prd_stream", Path: "/test"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	yielded := 0
+	stopped := false
+	seq := func(yield func(any, error) bool) {
+		for i := range 5 {
+			if i == 2 {
+				// Client disconnects during the stream
+				cancel()
+			}
+			if !yield("line", nil) {
+				stopped = true
+				return
+			}
+			yielded++
+		}
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/test", nil).WithContext(ctx)
+	a.handleStreamResponse(w, r, &starlark.Thread{}, apptype.TEXT, "", map[string]any{"is_stream": true, "value": seq})
+	testutil.AssertEqualsBool(t, "stopped", true, stopped)
+	testutil.AssertEqualsInt(t, "yielded", 2, yielded)
+	testutil.AssertEqualsString(t, "body", "line\nline\n", w.Body.String())
+
+	// Without a disconnect, the full stream is written
+	w = httptest.NewRecorder()
+	a.handleStreamResponse(w, httptest.NewRequest(http.MethodGet, "/test", nil), &starlark.Thread{}, apptype.TEXT, "",
+		map[string]any{"is_stream": true, "value": seq})
+	testutil.AssertEqualsInt(t, "lines", 5, strings.Count(w.Body.String(), "line\n"))
+}
+
+func TestStreamResponseDisconnectSpin(t *testing.T) {
+	a := &App{Logger: testutil.TestLogger(), AppEntry: &types.AppEntry{Id: "app_prd_stream", Path: "/test"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	thread := &starlark.Thread{Name: "stream"}
+	var spinErr error
+	seq := func(yield func(any, error) bool) {
+		if !yield("line", nil) {
+			return
+		}
+		// The client disconnects while the generator is computing the next value
+		time.AfterFunc(50*time.Millisecond, cancel)
+		_, spinErr = starlark.ExecFileOptions(AppFileOptions(), thread, "spin.star", `
+def spin():
+    for i in range(1 << 62):
+        pass
+spin()
+`, nil)
+		yield("after spin", spinErr)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/test", nil).WithContext(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		a.handleStreamResponse(w, r, thread, apptype.TEXT, "", map[string]any{"is_stream": true, "value": seq})
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("stream generator did not stop after the client disconnect")
+	}
+	if spinErr == nil || !strings.Contains(spinErr.Error(), "client disconnected") {
+		t.Fatalf("expected client disconnected error, got %v", spinErr)
+	}
+	testutil.AssertEqualsString(t, "body", "line\n", w.Body.String())
+}
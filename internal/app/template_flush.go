@@ -0,0 +1,126 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"html/template"
+	"io"
+	"net/http"
+)
+
+// flushMarker is written to the template output by the flush template func. The template writer
+// flushes the response at the marker and removes it from the output. The random suffix ensures
+// that the marker does not match the page content. The marker has only one '<', which is used
+// for finding a partial marker at the end of a write
+var flushMarker = newFlushMarker()
+
+func newFlushMarker() []byte {
+	suffix := make([]byte, 8)
+	rand.Read(suffix) //nolint:errcheck
+	return []byte("<!--openrun_flush_" + hex.EncodeToString(suffix) + "-->")
+}
+
+// templateFlush is the flush template func. It has to be used in the HTML text context, like
+// between elements
+func templateFlush() template.HTML {
+	return template.HTML(flushMarker)
+}
+
+// flushWriter is the writer for the template output, which handles the flush points. If buf is
+// set, the output is buffered till the first flush point, so that pages without flush points are
+// written by the caller, with the ETag. At the first flush point, the buffered output is written
+// and the response is flushed, the output after that is written directly. If the response writer
+// does not support flushing, the flush points are removed and the output is not flushed
+type flushWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+	buf     *bytes.Buffer
+	flushed bool
+	pending []byte // a partial flush marker at the end of the last write
+}
+
+func newFlushWriter(w io.Writer, buf *bytes.Buffer) *flushWriter {
+	flusher, _ := w.(http.Flusher)
+	return &flushWriter{w: w, flusher: flusher, buf: buf}
+}
+
+// streaming reports whether the output was written to the response, the caller cannot write an
+// error response after that
+func (f *flushWriter) streaming() bool {
+	return f.flushed
+}
+
+func (f *flushWriter) out(p []byte) error {
+	if len(p) == 0 {
+		return nil
+	}
+	var err error
+	if f.buf != nil && !f.flushed {
+		_, err = f.buf.Write(p)
+	} else {
+		_, err = f.w.Write(p)
+	}
+	return err
+}
+
+func (f *flushWriter) flush() error {
+	if f.flusher == nil {
+		return nil
+	}
+	if f.buf != nil && !f.flushed {
+		if _, err := f.w.Write(f.buf.Bytes()); err != nil {
+			return err
+		}
+		f.buf.Reset()
+	}
+	f.flushed = true
+	f.flusher.Flush()
+	return nil
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	data := p
+	if len(f.pending) > 0 {
+		data = append(f.pending, p...)
+		f.pending = nil
+	}
+
+	for {
+		index := bytes.Index(data, flushMarker)
+		if index < 0 {
+			break
+		}
+		if err := f.out(data[:index]); err != nil {
+			return 0, err
+		}
+		if err := f.flush(); err != nil {
+			return 0, err
+		}
+		data = data[index+len(flushMarker):]
+	}
+
+	// Hold back a partial marker at the end, the rest of it could be in the next write
+	tailStart := max(0, len(data)-len(flushMarker)+1)
+	if index := bytes.LastIndexByte(data[tailStart:], '<'); index >= 0 {
+		index += tailStart
+		if bytes.HasPrefix(flushMarker, data[index:]) {
+			f.pending = append([]byte(nil), data[index:]...)
+			data = data[:index]
+		}
+	}
+	if err := f.out(data); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// finish writes the held back output, it has to be called after the template is executed
+func (f *flushWriter) finish() error {
+	pending := f.pending
+	f.pending = nil
+	return f.out(pending)
+}
@@ -0,0 +1,79 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"html/template"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+// flushRecorder records the response output at each flush
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	flushes []string
+}
+
+func (f *flushRecorder) Flush() {
+	f.flushes = append(f.flushes, f.Body.String())
+	f.ResponseRecorder.Flush()
+}
+
+func TestFlushWriter(t *testing.T) {
+	page := "<head>h</head>" + string(flushMarker) + "<body>b</body>" + string(flushMarker) + "<p>end</p>"
+
+	// The output is split at all positions, to check the markers across writes
+	for split := range len(page) {
+		w := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+		var buf bytes.Buffer
+		fw := newFlushWriter(w, &buf)
+		fw.Write([]byte(page[:split])) //nolint:errcheck
+		fw.Write([]byte(page[split:])) //nolint:errcheck
+		testutil.AssertNoError(t, fw.finish())
+
+		testutil.AssertEqualsBool(t, "streaming", true, fw.streaming())
+		testutil.AssertEqualsString(t, "body", "<head>h</head><body>b</body><p>end</p>", w.Body.String())
+		testutil.AssertEqualsInt(t, "flushes", 2, len(w.flushes))
+		testutil.AssertEqualsString(t, "first flush", "<head>h</head>", w.flushes[0])
+		testutil.AssertEqualsInt(t, "buffered", 0, buf.Len())
+	}
+}
+
+func TestFlushWriterBuffered(t *testing.T) {
+	// Without flush points, the output is buffered
+	w := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	var buf bytes.Buffer
+	fw := newFlushWriter(w, &buf)
+	fw.Write([]byte("<p>a <"))                 //nolint:errcheck
+	fw.Write([]byte("!-- comment --> b</p><")) //nolint:errcheck
+	testutil.AssertNoError(t, fw.finish())
+	testutil.AssertEqualsBool(t, "streaming", false, fw.streaming())
+	testutil.AssertEqualsString(t, "buffered", "<p>a <!-- comment --> b</p><", buf.String())
+	testutil.AssertEqualsString(t, "body", "", w.Body.String())
+
+	// Without flush support, the flush points are removed
+	var out bytes.Buffer
+	fw = newFlushWriter(&out, nil)
+	fw.Write([]byte("a" + string(flushMarker) + "b")) //nolint:errcheck
+	testutil.AssertNoError(t, fw.finish())
+	testutil.AssertEqualsBool(t, "streaming", false, fw.streaming())
+	testutil.AssertEqualsString(t, "output", "ab", out.String())
+}
+
+func TestTemplateFlush(t *testing.T) {
+	tmpl := template.Must(template.New("page").Funcs(template.FuncMap{"flush": templateFlush}).
+		Parse(`<head><title>{{.}}</title></head>{{flush}}<body>{{.}}</body>`))
+
+	w := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	var buf bytes.Buffer
+	fw := newFlushWriter(w, &buf)
+	testutil.AssertNoError(t, tmpl.Execute(fw, "<x>"))
+	testutil.AssertNoError(t, fw.finish())
+	testutil.AssertEqualsString(t, "body", "<head><title>&lt;x&gt;</title></head><body>&lt;x&gt;</body>", w.Body.String())
+	testutil.AssertEqualsInt(t, "flushes", 1, len(w.flushes))
+	testutil.AssertEqualsString(t, "first flush", "<head><title>&lt;x&gt;</title></head>", w.flushes[0])
+}
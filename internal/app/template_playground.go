@@ -0,0 +1,219 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openrundev/openrun/internal/app/starlark_type"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// playgroundMaxBody is the max size of the render request, with the JSON data
+const playgroundMaxBody = 1 << 20
+
+// playgroundRequest is the render request from the playground page
+type playgroundRequest struct {
+	Template string `json:"template"`
+	Data     any    `json:"data"`
+}
+
+var playgroundTemplate = template.Must(template.New("playground").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8" />
+  <title>{{.Title}} - Template Playground</title>
+  <style>
+    body { font-family: sans-serif; font-size: 13px; margin: 1em; }
+    .panes { display: flex; gap: 1em; }
+    .pane { flex: 1; display: flex; flex-direction: column; min-width: 0; }
+    textarea { font-family: monospace; font-size: 12px; height: 24em; }
+    iframe { border: 1px solid #ccc; height: 24em; background: #fff; }
+    pre { background: #f4f4f4; padding: 0.5em; white-space: pre-wrap; overflow: auto; max-height: 20em; }
+    .error { color: #b00020; }
+  </style>
+</head>
+<body>
+  <h3>{{.Title}} - Template Playground</h3>
+  <p>
+    <label>Template <select id="template">
+      {{range .Templates}}<option>{{.}}</option>{{end}}
+    </select></label>
+    <span id="status"></span>
+  </p>
+  <div class="panes">
+    <div class="pane">
+      <label for="data">Data, available as .Data in the template</label>
+      <textarea id="data" spellcheck="false">{}</textarea>
+    </div>
+    <div class="pane">
+      <label>Output</label>
+      <iframe id="output" sandbox></iframe>
+    </div>
+  </div>
+  <pre id="source"></pre>
+  <script>
+    const renderUrl = {{.RenderUrl}};
+    const templateSelect = document.getElementById("template");
+    const dataInput = document.getElementById("data");
+    const status = document.getElementById("status");
+    const storageKey = "openrun_playground:" + renderUrl + ":";
+    let timer = null;
+
+    async function render() {
+      let data;
+      try {
+        data = JSON.parse(dataInput.value || "{}");
+      } catch (e) {
+        status.className = "error";
+        status.textContent = "Invalid JSON: " + e.message;
+        return;
+      }
+      localStorage.setItem(storageKey + templateSelect.value, dataInput.value);
+      const response = await fetch(renderUrl, {
+        method: "POST",
+        headers: {"Content-Type": "application/json"},
+        body: JSON.stringify({template: templateSelect.value, data: data}),
+      });
+      const output = await response.text();
+      if (!response.ok) {
+        status.className = "error";
+        status.textContent = output;
+        return;
+      }
+      status.className = "";
+      status.textContent = "Rendered " + templateSelect.value;
+      document.getElementById("output").srcdoc = output;
+      document.getElementById("source").textContent = output;
+    }
+
+    function scheduleRender() {
+      clearTimeout(timer);
+      timer = setTimeout(render, 300);
+    }
+
+    templateSelect.addEventListener("change", () => {
+      dataInput.value = localStorage.getItem(storageKey + templateSelect.value) || dataInput.value;
+      render();
+    });
+    dataInput.addEventListener("input", scheduleRender);
+    dataInput.value = localStorage.getItem(storageKey + templateSelect.value) || "{}";
+    render();
+  </script>
+</body>
+</html>
+`))
+
+// createPlaygroundRoutes adds the template playground, for dev apps only
+func (a *App) createPlaygroundRoutes(router *chi.Mux) {
+	router.Get(types.APP_INTERNAL_URL_PREFIX+"/playground", a.playgroundViewHandler)
+	router.Post(types.APP_INTERNAL_URL_PREFIX+"/playground/render", a.playgroundRenderHandler)
+}
+
+// playgroundTemplateNames returns the names of the template files and the blocks defined in them
+func (a *App) playgroundTemplateNames() []string {
+	a.initMutex.Lock()
+	defer a.initMutex.Unlock()
+	var names []string
+	addNames := func(t *template.Template) {
+		if t == nil {
+			return
+		}
+		for _, entry := range t.Templates() {
+			if entry.Name() != "" && entry.Tree != nil {
+				names = append(names, entry.Name())
+			}
+		}
+	}
+	addNames(a.template)
+	addNames(a.templateBase)
+	for _, t := range a.templateMap {
+		addNames(t)
+	}
+	slices.Sort(names)
+	return slices.Compact(names)
+}
+
+// RenderPlayground renders the template file or block with the data, which is set as .Data like
+// for a handler response. The request fields are set for a partial GET request to the app root
+func (a *App) RenderPlayground(name string, data any) (string, error) {
+	a.initMutex.Lock()
+	t := a.lookupTemplate(name)
+	a.initMutex.Unlock()
+	if t == nil {
+		return "", fmt.Errorf("template %s is not defined", name)
+	}
+
+	appPath := a.Path
+	if appPath == "/" {
+		appPath = ""
+	}
+	requestData := starlark_type.Request{
+		AppName:     a.Name,
+		AppPath:     appPath,
+		AppUrl:      appPath,
+		PageUrl:     appPath,
+		Method:      http.MethodGet,
+		IsDev:       a.IsDev,
+		IsPartial:   true,
+		PushEvents:  a.codeConfig.Routing.PushEvents,
+		HtmxVersion: a.codeConfig.Htmx.Version,
+		Headers:     http.Header{},
+		UrlParams:   map[string]string{},
+		Form:        url.Values{},
+		Query:       url.Values{},
+		PostForm:    url.Values{},
+		Env:         a.appEnv,
+		Data:        data,
+	}
+
+	var buf bytes.Buffer
+	fw := newFlushWriter(&buf, nil)
+	if err := t.Execute(fw, requestData); err != nil {
+		return "", err
+	}
+	if err := fw.finish(); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (a *App) playgroundViewHandler(w http.ResponseWriter, r *http.Request) {
+	data := map[string]any{
+		"Title":     a.Name,
+		"RenderUrl": strings.TrimSuffix(a.Path, "/") + types.APP_INTERNAL_URL_PREFIX + "/playground/render",
+		"Templates": a.playgroundTemplateNames(),
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := playgroundTemplate.Execute(w, data); err != nil {
+		a.Error().Err(err).Msg("error rendering template playground")
+	}
+}
+
+func (a *App) playgroundRenderHandler(w http.ResponseWriter, r *http.Request) {
+	var request playgroundRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, playgroundMaxBody)).Decode(&request); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if request.Template == "" {
+		http.Error(w, "template is required", http.StatusBadRequest)
+		return
+	}
+	output, err := a.RenderPlayground(request.Template, request.Data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(output)) //nolint:errcheck
+}
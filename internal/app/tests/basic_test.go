@@ -11,6 +11,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/openrundev/openrun/internal/app"
 	"github.com/openrundev/openrun/internal/app/apptype"
 	"github.com/openrundev/openrun/internal/testutil"
 	"github.com/openrundev/openrun/internal/types"
@@ -96,6 +97,39 @@ def handler(req):
 	testutil.AssertEqualsString(t, "config", "2.0.3", config.Htmx.Version)
 }
 
+func TestAppStartTiming(t *testing.T) {
+	logger := testutil.TestLogger()
+	a, _, err := CreateTestApp(logger, map[string]string{
+		"app.star": `
+app = ace.app("testApp", routes = [ace.html("/")])
+
+def handler(req):
+	return {"key": "myvalue"}`,
+		"index.go.html": `Template got {{ .Data.key }}.`,
+	})
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+
+	timing := a.StartTiming()
+	if timing == nil {
+		t.Fatal("expected start timing to be set")
+	}
+	testutil.AssertEqualsBool(t, "cold start", false, timing.Reload)
+	if timing.TotalMs <= 0 || timing.StarlarkInitMs <= 0 || timing.TemplateParseMs <= 0 {
+		t.Errorf("expected phase times to be set: %+v", *timing)
+	}
+	testutil.AssertEqualsBool(t, "no container", true, timing.ContainerStartMs == 0)
+	sum := timing.SourceLoadMs + timing.StarlarkInitMs + timing.TemplateParseMs + timing.ContainerStartMs
+	if sum > timing.TotalMs+0.01 {
+		t.Errorf("phase times %f above total %f", sum, timing.TotalMs)
+	}
+
+	_, err = a.Reload(context.Background(), true, true, types.DryRunFalse, app.ReloadOptions{ReloadContainer: true})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsBool(t, "reload", true, a.StartTiming().Reload)
+}
+
 func TestAppLoadNoHtml(t *testing.T) {
 	logger := testutil.TestLogger()
 	fileData := map[string]string{
@@ -234,19 +268,8 @@ def handler(req):
 		"./templates/t1.tmpl":         `Template got {{ .key }}.`,
 		apptype.CONFIG_LOCK_FILE_NAME: `{ "htmx": { "version": "1.8" } }`,
 	}
-	a, _, err := CreateTestApp(logger, fileData)
-	if err != nil {
-		t.Fatalf("Error %s", err)
-	}
-
-	request := httptest.NewRequest("GET", "/test", nil)
-	response := httptest.NewRecorder()
-	a.ServeHTTP(response, request)
-
-	testutil.AssertEqualsInt(t, "code", 500, response.Code)
-	testutil.AssertEqualsString(t, "body",
-		`html/template: "t12.tmpl" is undefined`,
-		strings.TrimSpace(response.Body.String()))
+	_, _, err := CreateTestApp(logger, fileData)
+	testutil.AssertErrorContains(t, err, "app.star:2:55: template t12.tmpl is not defined")
 	var config apptype.CodeConfig
 
 	json.Unmarshal([]byte(fileData[apptype.CONFIG_LOCK_FILE_NAME]), &config) //nolint:errcheck
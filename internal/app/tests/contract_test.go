@@ -0,0 +1,62 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app_test
+
+import (
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func createContractTestApp(t *testing.T, contract string) ([]string, error) {
+	t.Helper()
+	logger := testutil.TestLogger()
+	fileData := map[string]string{
+		"app.star": `
+load("proxy.in", "proxy")
+
+app = ace.app("testApp", routes = [ace.proxy("/backend", proxy.config("http://localhost:9999"))],
+permissions=[
+	ace.permission("proxy.in", "config"),
+]
+)`,
+	}
+	if contract != "" {
+		fileData["contract.star"] = contract
+	}
+
+	a, _, err := CreateTestAppPlugin(logger, fileData, []string{"proxy.in"},
+		[]types.Permission{
+			{Plugin: "proxy.in", Method: "config"},
+		}, map[string]types.PluginSettings{})
+	testutil.AssertNoError(t, err)
+
+	checks, err := a.ContractChecks()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{}
+	for _, check := range checks {
+		names = append(names, check.Name)
+	}
+	return names, nil
+}
+
+func TestContractChecks(t *testing.T) {
+	names, err := createContractTestApp(t, `
+check("/backend/health", contains=["ok"])
+check("/backend/items", name="items")
+`)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "count", 2, len(names))
+	testutil.AssertEqualsString(t, "name", "GET /backend/health", names[0])
+
+	names, err = createContractTestApp(t, "")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "no contract", 0, len(names))
+
+	_, err = createContractTestApp(t, `check("/other")`)
+	testutil.AssertErrorContains(t, err, "not under a proxy route")
+}
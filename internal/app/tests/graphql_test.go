@@ -0,0 +1,144 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app_test
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+const graphqlSchema = `
+type Book {
+  id: ID!
+  title: String!
+  tags: [String!]!
+  author: Author
+}
+
+type Author {
+  name: String!
+}
+
+type Query {
+  book(id: ID!): Book
+  books(limit: Int = 10): [Book!]!
+}
+
+type Mutation {
+  addBook(title: String!): Book!
+}
+`
+
+const graphqlApp = `
+BOOKS = [
+    {"id": "1", "title": "War and Peace", "tags": ["classic"], "author_id": 1},
+    {"id": "2", "title": "Anna Karenina", "tags": [], "author_id": 1},
+]
+
+def get_book(parent, args, req):
+    for b in BOOKS:
+        if b["id"] == args["id"]:
+            return b
+    return None
+
+def list_books(parent, args, req):
+    return BOOKS[:args["limit"]]
+
+def book_author(parent, args, req):
+    return {"name": "Tolstoy %d" % parent["author_id"]}
+
+def add_book(parent, args, req):
+    return {"id": "3", "title": args["title"] + " " + req.Method, "tags": []}
+
+app = ace.app("testApp", routes = [
+    ace.graphql("/graphql", resolvers={
+        "Query.book": get_book,
+        "Query.books": list_books,
+        "Book.author": book_author,
+        "Mutation.addBook": add_book,
+    }, introspection=INTROSPECTION, max_batch=2),
+])
+`
+
+func graphqlTestApp(t *testing.T, introspection string) func(method, target, contentType, body string) (int, string) {
+	t.Helper()
+	logger := testutil.TestLogger()
+	fileData := map[string]string{
+		"app.star":       strings.Replace(graphqlApp, "INTROSPECTION", introspection, 1),
+		"schema.graphql": graphqlSchema,
+	}
+	a, _, err := CreateTestApp(logger, fileData)
+	testutil.AssertNoError(t, err)
+
+	return func(method, target, contentType, body string) (int, string) {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		if contentType != "" {
+			request.Header.Set("Content-Type", contentType)
+		}
+		response := httptest.NewRecorder()
+		a.ServeHTTP(response, request)
+		return response.Code, strings.TrimSpace(response.Body.String())
+	}
+}
+
+func TestGraphQLQuery(t *testing.T) {
+	call := graphqlTestApp(t, "True")
+
+	code, body := call("POST", "/test/graphql", "application/json",
+		`{"query": "query Q($id: ID!) { book(id: $id) { title tags author { name } } }", "variables": {"id": "1"}}`)
+	testutil.AssertEqualsInt(t, "code", 200, code)
+	testutil.AssertEqualsString(t, "body", `{"data":{"book":{"title":"War and Peace","tags":["classic"],"author":{"name":"Tolstoy 1"}}}}`, body)
+
+	query := url.Values{"query": {"{ books(limit: 1) { id } }"}}
+	code, body = call("GET", "/test/graphql?"+query.Encode(), "", "")
+	testutil.AssertEqualsInt(t, "code", 200, code)
+	testutil.AssertEqualsString(t, "get", `{"data":{"books":[{"id":"1"}]}}`, body)
+
+	code, body = call("POST", "/test/graphql", "application/graphql", `mutation { addBook(title: "Resurrection") { title } }`)
+	testutil.AssertEqualsInt(t, "code", 200, code)
+	testutil.AssertEqualsString(t, "mutation", `{"data":{"addBook":{"title":"Resurrection POST"}}}`, body)
+
+	query = url.Values{"query": {`mutation { addBook(title: "x") { id } }`}}
+	_, body = call("GET", "/test/graphql?"+query.Encode(), "", "")
+	testutil.AssertStringContains(t, body, "mutations are not allowed for this request")
+
+	_, body = call("POST", "/test/graphql", "application/json", `{"query": "{ __type(name: \"Book\") { name } }"}`)
+	testutil.AssertEqualsString(t, "introspection", `{"data":{"__type":{"name":"Book"}}}`, body)
+}
+
+func TestGraphQLBatch(t *testing.T) {
+	call := graphqlTestApp(t, "False")
+
+	code, body := call("POST", "/test/graphql", "application/json",
+		`[{"query": "{ book(id: \"2\") { title } }"}, {"query": "{ book(id: \"3\") { title } }"}]`)
+	testutil.AssertEqualsInt(t, "code", 200, code)
+	testutil.AssertEqualsString(t, "batch", `[{"data":{"book":{"title":"Anna Karenina"}}},{"data":{"book":null}}]`, body)
+
+	code, body = call("POST", "/test/graphql", "application/json",
+		`[{"query": "{ book(id: 1) { id } }"}, {"query": "{ book(id: 1) { id } }"}, {"query": "{ book(id: 1) { id } }"}]`)
+	testutil.AssertEqualsInt(t, "code", 400, code)
+	testutil.AssertStringContains(t, body, "batch should have between 1 and 2 queries, got 3")
+
+	_, body = call("POST", "/test/graphql", "application/json", `{"query": "{ __schema { types { name } } }"}`)
+	testutil.AssertStringContains(t, body, "introspection is disabled")
+}
+
+func TestGraphQLResolverErrors(t *testing.T) {
+	logger := testutil.TestLogger()
+	appData := strings.Replace(graphqlApp, "INTROSPECTION", "True", 1)
+	fileData := map[string]string{
+		"app.star":       strings.Replace(appData, `"Mutation.addBook": add_book,`, "", 1),
+		"schema.graphql": graphqlSchema,
+	}
+	_, _, err := CreateTestApp(logger, fileData)
+	testutil.AssertErrorContains(t, err, "no resolver defined for Mutation.addBook")
+
+	fileData["app.star"] = strings.Replace(appData, `"Book.author"`, `"Book.writer"`, 1)
+	_, _, err = CreateTestApp(logger, fileData)
+	testutil.AssertErrorContains(t, err, "resolver Book.writer, type Book has no field writer")
+}
@@ -0,0 +1,130 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app_test
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func TestMarkdownRoute(t *testing.T) {
+	logger := testutil.TestLogger()
+	fileData := map[string]string{
+		"app.star": `
+app = ace.app("testApp", custom_layout=True, routes = [ace.markdown("/docs")])`,
+		"index.go.html":     `<title>{{ .Data.title }}</title>{{ range .Data.pages }}[{{ .Path }}:{{ .Title }}]{{ end }}{{ template "openrun_body" . }}`,
+		"docs/index.md":     "---\ntitle: Home\nweight: -1\n---\nSee the [guide](guide.md#setup)\n",
+		"docs/guide.md":     "# User Guide\n\n<script>alert(1)</script>\n\n| a |\n|---|\n| 1 |\n",
+		"docs/draft.md":     "---\ndraft: true\n---\nnot ready\n",
+		"docs/sub/index.md": "Sub page",
+		"docs/image.txt":    "image data",
+		"app.star.bak":      "not served",
+	}
+	a, _, err := CreateTestApp(logger, fileData)
+	testutil.AssertNoError(t, err)
+
+	get := func(target string) (int, string) {
+		request := httptest.NewRequest("GET", target, nil)
+		response := httptest.NewRecorder()
+		a.ServeHTTP(response, request)
+		return response.Code, response.Body.String()
+	}
+
+	code, body := get("/test/docs/")
+	testutil.AssertEqualsInt(t, "code", 200, code)
+	testutil.AssertStringContains(t, body, "<title>Home</title>[:Home][guide:User Guide][sub/:sub]")
+	testutil.AssertStringContains(t, body, `<article class="prose max-w-none"><p>See the <a href="guide#setup">guide</a></p>`)
+
+	code, body = get("/test/docs/guide")
+	testutil.AssertEqualsInt(t, "code", 200, code)
+	testutil.AssertStringContains(t, body, "<title>User Guide</title>")
+	testutil.AssertStringContains(t, body, `<h1 id="user-guide">User Guide</h1>`)
+	testutil.AssertStringContains(t, body, "<td>1</td>")
+	if strings.Contains(body, "<script>") {
+		t.Errorf("raw html should not be rendered: %s", body)
+	}
+
+	code, _ = get("/test/docs")
+	testutil.AssertEqualsInt(t, "code", 301, code)
+	code, _ = get("/test/docs/sub")
+	testutil.AssertEqualsInt(t, "code", 301, code)
+	code, body = get("/test/docs/sub/")
+	testutil.AssertEqualsInt(t, "code", 200, code)
+	testutil.AssertStringContains(t, body, "<p>Sub page</p>")
+
+	code, body = get("/test/docs/image.txt")
+	testutil.AssertEqualsInt(t, "code", 200, code)
+	testutil.AssertEqualsString(t, "body", "image data", body)
+
+	code, _ = get("/test/docs/draft")
+	testutil.AssertEqualsInt(t, "code", 404, code)
+	code, _ = get("/test/docs/missing")
+	testutil.AssertEqualsInt(t, "code", 404, code)
+	code, _ = get("/test/docs/../app.star.bak")
+	testutil.AssertEqualsInt(t, "code", 404, code)
+}
+
+func TestMarkdownBlock(t *testing.T) {
+	logger := testutil.TestLogger()
+	fileData := map[string]string{
+		"app.star": `
+app = ace.app("testApp", custom_layout=True, routes = [ace.markdown("/", dir="pages")])`,
+		"index.go.html":  `{{ template "openrun_body" . }}`,
+		"blocks.go.html": `{{ define "openrun_markdown" }}<main>{{ .Data.path }} {{ .Data.meta.author }} {{ .Data.content }}</main>{{ end }}`,
+		"pages/index.md": "---\nauthor: Jane\n---\n*hello*",
+	}
+	a, _, err := CreateTestApp(logger, fileData)
+	testutil.AssertNoError(t, err)
+
+	request := httptest.NewRequest("GET", "/test/", nil)
+	response := httptest.NewRecorder()
+	a.ServeHTTP(response, request)
+	testutil.AssertEqualsInt(t, "code", 200, response.Code)
+	testutil.AssertEqualsString(t, "body", "<main> Jane <p><em>hello</em></p>\n</main>", response.Body.String())
+}
+
+func TestMarkdownFunc(t *testing.T) {
+	logger := testutil.TestLogger()
+	fileData := map[string]string{
+		"app.star": `
+app = ace.app("testApp", custom_layout=True, routes = [ace.html("/")])
+
+def handler(req):
+	return {"text": "**bold** <b>raw</b>"}`,
+		"index.go.html": `{{ markdown .Data.text }}`,
+	}
+	a, _, err := CreateTestApp(logger, fileData)
+	testutil.AssertNoError(t, err)
+
+	request := httptest.NewRequest("GET", "/test", nil)
+	response := httptest.NewRecorder()
+	a.ServeHTTP(response, request)
+	testutil.AssertEqualsInt(t, "code", 200, response.Code)
+	testutil.AssertEqualsString(t, "body", "<p><strong>bold</strong> <!-- raw HTML omitted -->raw<!-- raw HTML omitted --></p>\n", response.Body.String())
+}
+
+func TestMarkdownErrors(t *testing.T) {
+	logger := testutil.TestLogger()
+	_, _, err := CreateTestApp(logger, map[string]string{
+		"app.star":      `app = ace.app("testApp", custom_layout=True, routes = [ace.markdown("/docs", dir="../")])`,
+		"index.go.html": `{{ template "openrun_body" . }}`,
+	})
+	testutil.AssertErrorContains(t, err, "should be a sub directory in the app source")
+
+	_, _, err = CreateTestApp(logger, map[string]string{
+		"app.star":      `app = ace.app("testApp", custom_layout=True, routes = [ace.markdown("/docs", index="README")])`,
+		"index.go.html": `{{ template "openrun_body" . }}`,
+	})
+	testutil.AssertErrorContains(t, err, "should have a .md extension")
+
+	_, _, err = CreateTestApp(logger, map[string]string{
+		"app.star":      `app = ace.app("testApp", custom_layout=True, routes = [ace.markdown("/docs")])`,
+		"index.go.html": `{{ template "openrun_body" . }}`,
+		"docs/bad.md":   "---\ntitle: x\n",
+	})
+	testutil.AssertErrorContains(t, err, "markdown front matter is not terminated")
+}
@@ -0,0 +1,130 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app_test
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestOpenAPISpec(t *testing.T) {
+	logger := testutil.TestLogger()
+	fileData := map[string]string{
+		"app.star": `
+def list_books(req):
+    """List the books
+
+    Returns the books matching the filter"""
+    return []
+
+def get_book(req):
+    return {}
+
+def add_book(req):
+    """Add a book"""
+    return {}
+
+app = ace.app("testApp", routes = [
+    ace.api("/books", handler=list_books, response=[table.book], query={"author": "string", "limit": "int"}),
+    ace.api("/books/{id:[0-9]+}", handler=get_book, response=table.book),
+    ace.api("/books", handler=add_book, method=ace.POST, request=table.book, response="int"),
+    ace.api("/health", type="text"),
+])
+
+def handler(req):
+    return "ok"
+`,
+		"schema.star": `
+type("book", fields=[
+    field("title", STRING),
+    field("year", INT),
+])`,
+	}
+	appConfig := types.AppConfig{OpenAPI: types.OpenAPIConfig{Enabled: true, SwaggerUI: true}}
+	a, _, err := CreateTestAppConfig(logger, fileData, appConfig)
+	testutil.AssertNoError(t, err)
+
+	request := httptest.NewRequest("GET", "/test/openrun_api/openapi.json", nil)
+	response := httptest.NewRecorder()
+	a.ServeHTTP(response, request)
+	testutil.AssertEqualsInt(t, "code", 200, response.Code)
+
+	var spec map[string]any
+	testutil.AssertNoError(t, json.Unmarshal(response.Body.Bytes(), &spec))
+	testutil.AssertEqualsString(t, "version", "3.0.3", spec["openapi"].(string))
+	testutil.AssertEqualsString(t, "server", "/test", spec["servers"].([]any)[0].(map[string]any)["url"].(string))
+
+	paths := spec["paths"].(map[string]any)
+	testutil.AssertEqualsInt(t, "paths", 3, len(paths))
+	books := paths["/books"].(map[string]any)
+	list := books["get"].(map[string]any)
+	testutil.AssertEqualsString(t, "summary", "List the books", list["summary"].(string))
+	testutil.AssertEqualsString(t, "description", "Returns the books matching the filter", list["description"].(string))
+	testutil.AssertEqualsInt(t, "query params", 2, len(list["parameters"].([]any)))
+	listSchema := list["responses"].(map[string]any)["200"].(map[string]any)["content"].(map[string]any)["application/json"].(map[string]any)["schema"].(map[string]any)
+	testutil.AssertEqualsString(t, "list", "array", listSchema["type"].(string))
+	testutil.AssertEqualsString(t, "ref", "#/components/schemas/book", listSchema["items"].(map[string]any)["$ref"].(string))
+
+	add := books["post"].(map[string]any)
+	testutil.AssertEqualsString(t, "add summary", "Add a book", add["summary"].(string))
+	if _, ok := add["requestBody"]; !ok {
+		t.Errorf("expected request body for add")
+	}
+
+	get := paths["/books/{id}"].(map[string]any)["get"].(map[string]any)
+	param := get["parameters"].([]any)[0].(map[string]any)
+	testutil.AssertEqualsString(t, "path param", "id", param["name"].(string))
+	testutil.AssertEqualsString(t, "path param in", "path", param["in"].(string))
+
+	health := paths["/health"].(map[string]any)["get"].(map[string]any)
+	if _, ok := health["responses"].(map[string]any)["200"].(map[string]any)["content"].(map[string]any)["text/plain"]; !ok {
+		t.Errorf("expected text response for health")
+	}
+
+	book := spec["components"].(map[string]any)["schemas"].(map[string]any)["book"].(map[string]any)
+	testutil.AssertEqualsString(t, "field type", "integer", book["properties"].(map[string]any)["year"].(map[string]any)["type"].(string))
+
+	request = httptest.NewRequest("GET", "/test/openrun_api/docs", nil)
+	response = httptest.NewRecorder()
+	a.ServeHTTP(response, request)
+	testutil.AssertEqualsInt(t, "docs code", 200, response.Code)
+	testutil.AssertStringContains(t, response.Body.String(), `/test/openrun_api/openapi.json`)
+}
+
+func TestOpenAPIInvalidType(t *testing.T) {
+	logger := testutil.TestLogger()
+	fileData := map[string]string{
+		"app.star": `
+app = ace.app("testApp", routes = [ace.api("/books", response="unknown")])
+
+def handler(req):
+    return []
+`,
+	}
+	_, _, err := CreateTestApp(logger, fileData)
+	testutil.AssertErrorContains(t, err, "response type unknown is not defined")
+}
+
+func TestOpenAPIDisabled(t *testing.T) {
+	logger := testutil.TestLogger()
+	fileData := map[string]string{
+		"app.star": `
+app = ace.app("testApp", routes = [ace.api("/books")])
+
+def handler(req):
+    return []
+`,
+	}
+	a, _, err := CreateTestApp(logger, fileData)
+	testutil.AssertNoError(t, err)
+
+	request := httptest.NewRequest("GET", "/test/openrun_api/openapi.json", nil)
+	response := httptest.NewRecorder()
+	a.ServeHTTP(response, request)
+	testutil.AssertEqualsInt(t, "code", 404, response.Code)
+}
@@ -202,3 +202,63 @@ def handler(req):
 	_, _, err = CreateTestAppParams(logger, fileData, map[string]string{"p1": ""})
 	testutil.AssertErrorContains(t, err, "param p1 is a required param, value cannot be empty")
 }
+
+func TestParamsValidation(t *testing.T) {
+	logger := testutil.TestLogger()
+	fileData := map[string]string{
+		"app.star": `
+app = ace.app("testApp", routes = [ace.api("/", type=ace.TEXT)])
+
+def handler(req):
+	return param.p1
+		`,
+	}
+
+	fileData["params.star"] = `param("p1", type=ENUM, values=["dev", "prod"], default="dev")`
+	a, _, err := CreateTestAppParams(logger, fileData, map[string]string{"p1": "prod"})
+	testutil.AssertNoError(t, err)
+	request := httptest.NewRequest("GET", "/test", nil)
+	response := httptest.NewRecorder()
+	a.ServeHTTP(response, request)
+	testutil.AssertEqualsInt(t, "code", 200, response.Code)
+	testutil.AssertStringContains(t, response.Body.String(), "prod")
+
+	_, _, err = CreateTestAppParams(logger, fileData, map[string]string{"p1": "qa"})
+	testutil.AssertErrorContains(t, err, `param p1 value "qa" is not one of the allowed values dev, prod. Set the value using: openrun param update p1 <value> /test`)
+
+	fileData["params.star"] = `param("p1", type=ENUM, values=["dev", "prod"], default="qa")`
+	_, _, err = CreateTestApp(logger, fileData)
+	testutil.AssertErrorContains(t, err, `invalid default: param p1 value "qa" is not one of the allowed values dev, prod`)
+
+	fileData["params.star"] = `param("p1", type=ENUM)`
+	_, _, err = CreateTestApp(logger, fileData)
+	testutil.AssertErrorContains(t, err, "param \"p1\" is of type ENUM, values has to be specified")
+
+	fileData["params.star"] = `param("p1", type=SECRET)`
+	_, _, err = CreateTestAppParams(logger, fileData, map[string]string{"p1": `{{secret "api_key"}}`})
+	testutil.AssertNoError(t, err)
+	_, _, err = CreateTestAppParams(logger, fileData, map[string]string{"p1": "plain_text"})
+	testutil.AssertErrorContains(t, err, `param p1 requires a secret reference like {{secret "name"}}, plain text value is not allowed`)
+
+	fileData["params.star"] = `param("p1", type=URL, regex="^https://")`
+	_, _, err = CreateTestAppParams(logger, fileData, map[string]string{"p1": "https://example.com"})
+	testutil.AssertNoError(t, err)
+	_, _, err = CreateTestAppParams(logger, fileData, map[string]string{"p1": "example.com"})
+	testutil.AssertErrorContains(t, err, `param p1 value "example.com" is not an absolute url`)
+	_, _, err = CreateTestAppParams(logger, fileData, map[string]string{"p1": "http://example.com"})
+	testutil.AssertErrorContains(t, err, `param p1 value "http://example.com" does not match the pattern ^https://`)
+
+	fileData["params.star"] = `param("p1", type=STRING, regex="^[a-z]+$", required=False)`
+	_, _, err = CreateTestApp(logger, fileData)
+	testutil.AssertNoError(t, err)
+	_, _, err = CreateTestAppParams(logger, fileData, map[string]string{"p1": "Abc"})
+	testutil.AssertErrorContains(t, err, `param p1 value "Abc" does not match the pattern ^[a-z]+$`)
+
+	fileData["params.star"] = `param("p1", type=INT, regex="^[0-9]+$")`
+	_, _, err = CreateTestApp(logger, fileData)
+	testutil.AssertErrorContains(t, err, "regex is allowed for STRING and URL types only")
+
+	fileData["params.star"] = `param("p1", type=INT, default=10)`
+	_, _, err = CreateTestAppParams(logger, fileData, map[string]string{"p1": "abc"})
+	testutil.AssertErrorContains(t, err, "param p1 is not an int. Set the value using: openrun param update p1 <value> /test")
+}
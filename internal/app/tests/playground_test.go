@@ -0,0 +1,73 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func TestTemplatePlayground(t *testing.T) {
+	// Serve the htmx libraries locally, so that the dev app does not download them
+	testServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "htmx contents") //nolint:errcheck
+	}))
+	defer testServer.Close()
+
+	logger := testutil.TestLogger()
+	fileData := map[string]string{
+		"app.star": fmt.Sprintf(`
+app = ace.app("testApp", routes = [ace.html("/")],
+			     libraries=["%[1]s/htmx.org/htmx.min.js", "%[1]s/htmx.org/ext/sse.js"])
+
+def handler(req):
+	return {"key": "myvalue"}`, testServer.URL),
+		"index.go.html": `{{block "item" .}}<li>{{.Data.name}} {{.AppPath}}</li>{{end}}`,
+	}
+
+	a, _, err := CreateDevModeTestApp(logger, fileData)
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+
+	request := httptest.NewRequest("GET", "/test/_openrun_app/playground", nil)
+	response := httptest.NewRecorder()
+	a.ServeHTTP(response, request)
+	testutil.AssertEqualsInt(t, "code", 200, response.Code)
+	for _, want := range []string{"<option>index.go.html</option>", "<option>item</option>", `"/test/_openrun_app/playground/render"`} {
+		if !strings.Contains(response.Body.String(), want) {
+			t.Errorf("playground page missing %s: %s", want, response.Body.String())
+		}
+	}
+
+	request = httptest.NewRequest("POST", "/test/_openrun_app/playground/render",
+		strings.NewReader(`{"template": "item", "data": {"name": "<abc>"}}`))
+	response = httptest.NewRecorder()
+	a.ServeHTTP(response, request)
+	testutil.AssertEqualsInt(t, "code", 200, response.Code)
+	testutil.AssertEqualsString(t, "body", "<li>&lt;abc&gt; /test</li>", response.Body.String())
+
+	request = httptest.NewRequest("POST", "/test/_openrun_app/playground/render",
+		strings.NewReader(`{"template": "unknown", "data": {}}`))
+	response = httptest.NewRecorder()
+	a.ServeHTTP(response, request)
+	testutil.AssertEqualsInt(t, "code", 400, response.Code)
+	testutil.AssertStringContains(t, response.Body.String(), "template unknown is not defined")
+
+	// The playground is available for dev apps only
+	prodApp, _, err := CreateTestApp(logger, fileData)
+	if err != nil {
+		t.Fatalf("Error %s", err)
+	}
+	request = httptest.NewRequest("GET", "/test/_openrun_app/playground", nil)
+	response = httptest.NewRecorder()
+	prodApp.ServeHTTP(response, request)
+	testutil.AssertEqualsInt(t, "code", 404, response.Code)
+}
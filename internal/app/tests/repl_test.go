@@ -0,0 +1,82 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/plugin"
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+	"go.starlark.net/starlark"
+)
+
+type testReplPlugin struct{}
+
+func (p *testReplPlugin) Get(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	return app.NewResponse(starlark.String("got")), nil
+}
+
+func (p *testReplPlugin) Put(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	return app.NewResponse(starlark.String("put")), nil
+}
+
+func init() {
+	p := &testReplPlugin{}
+	app.RegisterPlugin("testrepl", func(pluginContext *types.PluginContext) (any, error) {
+		return &testReplPlugin{}, nil
+	}, []plugin.PluginFunc{
+		app.CreatePluginApiName(p.Get, app.READ, "get"),
+		app.CreatePluginApiName(p.Put, app.WRITE, "put"),
+	})
+}
+
+func TestReplModes(t *testing.T) {
+	logger := testutil.TestLogger()
+	fileData := map[string]string{
+		"app.star": `
+app = ace.app("testApp", routes = [ace.api("/", type=ace.TEXT)],
+    permissions=[
+	ace.permission("testrepl.in", "get"),
+	ace.permission("testrepl.in", "put"),
+	])
+
+def handler(req):
+	return "ok"
+`,
+	}
+	a, _, err := CreateTestAppPlugin(logger, fileData, []string{"testrepl.in"},
+		[]types.Permission{{Plugin: "testrepl.in", Method: "get"}, {Plugin: "testrepl.in", Method: "put"}}, nil)
+	testutil.AssertNoError(t, err)
+
+	eval := func(session *app.ReplSession, input string) *types.AppReplResponse {
+		return session.Eval(context.Background(), input)
+	}
+
+	// Read only mode runs the read calls, the write calls fail
+	session, err := a.NewReplSession(types.ReplModeReadOnly)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "load", "", eval(session, `load("testrepl.in", "testrepl")`).Error)
+	testutil.AssertEqualsString(t, "get", `"got"`, eval(session, "testrepl.get().value").Result)
+	ret := eval(session, "testrepl.put()")
+	testutil.AssertStringContains(t, ret.Error, "testrepl.in.put is a write operation, it is not permitted in read only mode")
+
+	// Live mode runs the write calls
+	session, err = a.NewReplSession(types.ReplModeLive)
+	testutil.AssertNoError(t, err)
+	eval(session, `load("testrepl.in", "testrepl")`)
+	testutil.AssertEqualsString(t, "put", `"put"`, eval(session, "testrepl.put().value").Result)
+
+	// Dry run mode prints the calls
+	session, err = a.NewReplSession(types.ReplModeDryRun)
+	testutil.AssertNoError(t, err)
+	eval(session, `load("testrepl.in", "testrepl")`)
+	ret = eval(session, "testrepl.get()")
+	testutil.AssertStringContains(t, ret.Output, "dummy call to testrepl.in.get")
+
+	_, err = a.NewReplSession("write")
+	testutil.AssertErrorContains(t, err, `invalid repl mode "write"`)
+}
@@ -145,6 +145,36 @@ func BenchmarkAppServeJSONAPIWithHeaders(b *testing.B) {
 	}
 }
 
+// BenchmarkAppServeJSONAPIReadHeaders is the variant where the handler reads
+// req.Headers and a URL param, building the sanitized header view and the
+// params map (both reused from a pool) on every request.
+func BenchmarkAppServeJSONAPIReadHeaders(b *testing.B) {
+	logger := types.NewLogger(&types.LogConfig{Level: "WARN"})
+	fileData := map[string]string{
+		"app.star": `
+app = ace.app("benchApp", routes = [ace.api("/items/{id}", type="json")])
+
+def handler(req):
+	return {"id": req.UrlParams["id"], "agent": req.Headers["User-Agent"][0]}
+`,
+	}
+	a, _, err := CreateTestApp(logger, fileData)
+	if err != nil {
+		b.Fatalf("error creating app: %s", err)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		req := httptest.NewRequest("GET", "/test/items/10", nil)
+		setBrowserHeaders(req)
+		w := httptest.NewRecorder()
+		a.ServeHTTP(w, req)
+		if w.Code != 200 {
+			b.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+		}
+	}
+}
+
 func BenchmarkAppServeJSONAPIParallel(b *testing.B) {
 	a := newBenchApp(b)
 
@@ -4,11 +4,19 @@
 package app_test
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"image"
+	"image/png"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/openrundev/openrun/internal/app/appfs"
 	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
 )
 
 func TestStaticLoad(t *testing.T) {
@@ -61,7 +69,7 @@ def handler(req):
 	response = httptest.NewRecorder()
 	a.ServeHTTP(response, request)
 	testutil.AssertEqualsString(t, "header cache", "", response.Header().Get("Cache-Control"))
-	testutil.AssertEqualsString(t, "header etag", response.Header().Get("ETag"), "") // etag is not set for now
+	testutil.AssertEqualsBool(t, "header etag", true, response.Header().Get("ETag") != "")
 	testutil.AssertEqualsInt(t, "code", 200, response.Code)
 	testutil.AssertStringMatch(t, "body", `deny *`, response.Body.String())
 
@@ -139,9 +147,9 @@ def handler(req):
 	testutil.AssertStringMatch(t, "body", want, response.Body.String())
 
 	// When accessing static file without the hash in file name, the cache directives are not set.
-	// The etag is also not set currently, that needs to be added in the future
+	// The etag is set from the file contents
 	testutil.AssertEqualsString(t, "header", "", response.Header().Get("Cache-Control"))
-	testutil.AssertEqualsBool(t, "header etag", true, response.Header().Get("ETag") == "")
+	testutil.AssertEqualsString(t, "header etag", `"d044e5b148745e322fe3e916e5f3bb9c9182892fdf99850baf4ed82c2864dd30"`, response.Header().Get("ETag"))
 }
 
 func TestVerifyFile(t *testing.T) {
@@ -401,3 +409,93 @@ def handler(req):
 
 	testutil.AssertEqualsInt(t, "code", 200, response.Code) // no redirect for the full path with trailing slash
 }
+
+func TestConditionalRequests(t *testing.T) {
+	logger := testutil.TestLogger()
+	fileData := map[string]string{
+		"app.star": `
+app = ace.app("testApp", custom_layout=True, routes = [ace.html("/")])
+
+def handler(req):
+	return {"key": "myvalue"}`,
+		"index.go.html":          `abc {{.Data.key}}`,
+		"static/file2.txt":       `file2data`,
+		"static_root/robots.txt": `file2data`,
+	}
+
+	a, _, err := CreateTestApp(logger, fileData)
+	testutil.AssertNoError(t, err)
+
+	call := func(path, header, value string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", path, nil)
+		if header != "" {
+			request.Header.Set(header, value)
+		}
+		response := httptest.NewRecorder()
+		a.ServeHTTP(response, request)
+		return response
+	}
+
+	// Rendered pages have a weak etag computed on the output
+	response := call("/test", "", "")
+	testutil.AssertEqualsInt(t, "code", 200, response.Code)
+	etag := response.Header().Get("ETag")
+	testutil.AssertEqualsBool(t, "weak etag", true, strings.HasPrefix(etag, `W/"`))
+	response = call("/test", "If-None-Match", etag)
+	testutil.AssertEqualsInt(t, "page not modified", 304, response.Code)
+	testutil.AssertEqualsString(t, "page body", "", response.Body.String())
+	response = call("/test", "If-None-Match", `W/"abc"`)
+	testutil.AssertEqualsInt(t, "page modified", 200, response.Code)
+	testutil.AssertStringMatch(t, "body", "abc myvalue", response.Body.String())
+
+	// Static files have a strong etag from the file hash, with or without the hash in the name
+	hashName := "/test/static/file2-d044e5b148745e322fe3e916e5f3bb9c9182892fdf99850baf4ed82c2864dd30.txt"
+	etag = `"d044e5b148745e322fe3e916e5f3bb9c9182892fdf99850baf4ed82c2864dd30"`
+	response = call(hashName, "If-None-Match", etag)
+	testutil.AssertEqualsInt(t, "static not modified", 304, response.Code)
+	response = call("/test/static/file2.txt", "If-None-Match", `"other", W/`+etag)
+	testutil.AssertEqualsInt(t, "weak match", 304, response.Code)
+	response = call("/test/static/file2.txt", "If-None-Match", `"other"`)
+	testutil.AssertEqualsInt(t, "static modified", 200, response.Code)
+	testutil.AssertStringMatch(t, "static body", "file2data", response.Body.String())
+
+	// Static root files have the same strong etag
+	response = call("/test/robots.txt", "", "")
+	testutil.AssertEqualsString(t, "root etag", etag, response.Header().Get("ETag"))
+	response = call("/test/robots.txt", "If-None-Match", etag)
+	testutil.AssertEqualsInt(t, "root not modified", 304, response.Code)
+}
+
+func TestStaticSrcset(t *testing.T) {
+	logger := testutil.TestLogger()
+	var buf bytes.Buffer
+	testutil.AssertNoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 100, 50))))
+	fileData := map[string]string{
+		"app.star": `
+app = ace.app("testApp", custom_layout=True, routes = [ace.html("/")])
+
+def handler(req):
+	return {"key": "myvalue"}`,
+		"index.go.html":              `<img srcset="{{srcset "img/photo.png"}}"><source srcset="{{srcset "img/photo.png" "webp"}}">|{{srcset "img/photo.png" "avif"}}|`,
+		"static/img/photo.png":       buf.String(),
+		"static/img/photo-50w.png":   "variant50",
+		"static/img/photo-100w.webp": "webp100",
+	}
+	hashName := func(name, data string) string {
+		hash := sha256.Sum256([]byte(data))
+		return "/test/" + appfs.FormatName(name, hex.EncodeToString(hash[:]))
+	}
+
+	// The widths without a variant are skipped
+	appConfig := types.AppConfig{Image: types.ImageConfig{Widths: []int{50, 80, 200}}}
+	a, _, err := CreateTestAppConfig(logger, fileData, appConfig)
+	testutil.AssertNoError(t, err)
+
+	request := httptest.NewRequest("GET", "/test", nil)
+	response := httptest.NewRecorder()
+	a.ServeHTTP(response, request)
+	testutil.AssertEqualsInt(t, "code", 200, response.Code)
+	want := `<img srcset="` + hashName("static/img/photo-50w.png", "variant50") + " 50w, " + hashName("static/img/photo.png", buf.String()) + ` 100w">` +
+		`<source srcset="` + hashName("static/img/photo-100w.webp", "webp100") + ` 100w">||`
+	testutil.AssertEqualsString(t, "body", want, response.Body.String())
+}
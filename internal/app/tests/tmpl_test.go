@@ -8,6 +8,7 @@ import (
 	"testing"
 
 	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
 )
 
 func TestBaseTemplate(t *testing.T) {
@@ -132,3 +133,34 @@ def handler(req):
 	testutil.AssertEqualsInt(t, "code", 200, response.Code)
 	testutil.AssertEqualsString(t, "body", "frag respvalue", response.Body.String())
 }
+
+func TestMinifyTemplateOutput(t *testing.T) {
+	logger := testutil.TestLogger()
+	fileData := map[string]string{
+		"app.star": `
+app = ace.app("testApp", custom_layout=True, routes = [ace.html("/")])
+
+def handler(req):
+	return {"key": "myvalue"}`,
+		"index.go.html": `<html>
+  <head>
+    <style> p { color : red; } </style>
+  </head>
+  <!-- comment -->
+  <body>
+    <p>  {{.Data.key}}  </p>
+  </body>
+</html>`,
+	}
+	appConfig := types.AppConfig{Minify: types.MinifyConfig{HTML: true, CSS: true, JS: true, CacheEntries: 10}}
+	a, _, err := CreateTestAppConfig(logger, fileData, appConfig)
+	testutil.AssertNoError(t, err)
+
+	for range 2 {
+		request := httptest.NewRequest("GET", "/test", nil)
+		response := httptest.NewRecorder()
+		a.ServeHTTP(response, request)
+		testutil.AssertEqualsInt(t, "code", 200, response.Code)
+		testutil.AssertEqualsString(t, "body", "<html><head><style>p{color :red}</style></head><body>\n<p> myvalue </p>\n</body></html>", response.Body.String())
+	}
+}
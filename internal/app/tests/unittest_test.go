@@ -0,0 +1,179 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/app/appfs"
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func runUnitTests(t *testing.T, fileData map[string]string, filter string) ([]types.AppTestResult, error) {
+	t.Helper()
+	sourceFS, err := appfs.NewSourceFs("", &TestReadFS{fileData: fileData}, false)
+	testutil.AssertNoError(t, err)
+	return app.RunUnitTests(context.Background(), testutil.TestLogger(), sourceFS, filter, map[string]string{"greeting": "hi"})
+}
+
+const unitTestApp = `
+load("http.in", "http")
+load("util.star", "double")
+
+app = ace.app("testApp", routes = [ace.html("/")])
+
+def handler(req):
+	resp = http.get("https://example.com/items", params={"q": req.Query.get("q", [""])[0]})
+	if resp.error:
+		return {"error": resp.error}
+	return {"items": resp.value, "count": double(len(resp.value)), "user": req.UserId, "greeting": param.greeting}
+`
+
+func TestUnitTestRunner(t *testing.T) {
+	fileData := map[string]string{
+		"app.star":    unitTestApp,
+		"params.star": `param("greeting", type=STRING, default="hello")`,
+		"util.star": `
+def double(x):
+	return x * 2`,
+		"app_test.star": `
+load("app.star", "handler")
+
+def test_handler():
+	test.mock("http.in", "get", value=["a", "b"])
+	ret = handler(test.request("/", query={"q": "x"}, user_id="u1"))
+	assert.eq(ret["count"], 4)
+	assert.eq(ret["user"], "u1")
+	assert.eq(ret["greeting"], "hi")
+	assert.contains(ret["items"], "a")
+	calls = test.calls("http.in", "get")
+	assert.eq(len(calls), 1)
+	assert.eq(calls[0].kwargs["params"], {"q": "x"})
+
+def test_handler_error():
+	test.mock("http.in", "get", error="connection refused")
+	ret = handler(test.request())
+	assert.contains(ret["error"], "refused")
+
+def test_mock_func():
+	test.mock("http.in", "get", value=lambda url, params={}: [url])
+	ret = handler(test.request())
+	assert.eq(ret["items"], ["https://example.com/items"])
+
+def test_no_mock():
+	handler(test.request())
+
+def test_assert_fail():
+	assert.fails(lambda: fail("boom"), match="boom")
+	assert.ne(1, 1, msg="values")
+
+def helper():
+	pass
+`,
+		"tests/other_test.star": `
+def test_other():
+	assert.true(True)
+	assert.false(False)
+`,
+		"tests/bad_test.star": `test_x = `,
+	}
+
+	results, err := runUnitTests(t, fileData, "")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "results", 7, len(results))
+
+	byName := map[string]types.AppTestResult{}
+	for _, r := range results {
+		byName[r.Name] = r
+	}
+	testutil.AssertEqualsString(t, "first", "test_handler", results[0].Name)
+	testutil.AssertEqualsBool(t, "handler", true, byName["test_handler"].Passed)
+	testutil.AssertEqualsInt(t, "assertions", 6, byName["test_handler"].Assertions)
+	testutil.AssertEqualsBool(t, "handler error", true, byName["test_handler_error"].Passed)
+	testutil.AssertEqualsBool(t, "mock func", true, byName["test_mock_func"].Passed)
+	testutil.AssertEqualsBool(t, "no mock", false, byName["test_no_mock"].Passed)
+	testutil.AssertStringContains(t, byName["test_no_mock"].Error, "no mock set for plugin call http.in.get")
+	testutil.AssertEqualsBool(t, "assert fail", false, byName["test_assert_fail"].Passed)
+	testutil.AssertStringContains(t, byName["test_assert_fail"].Error, "assertion failed: values: expected value other than 1")
+	testutil.AssertEqualsBool(t, "other", true, byName["test_other"].Passed)
+	testutil.AssertEqualsString(t, "other file", "tests/other_test.star", byName["test_other"].File)
+	testutil.AssertEqualsBool(t, "bad file", false, byName["tests/bad_test.star"].Passed)
+	testutil.AssertStringContains(t, byName["tests/bad_test.star"].Error, "error loading test file")
+
+	results, err = runUnitTests(t, fileData, "test_handler*")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "filtered", 3, len(results))
+}
+
+func TestUnitTestRunnerErrors(t *testing.T) {
+	_, err := runUnitTests(t, map[string]string{"app.star": unitTestApp}, "")
+	testutil.AssertErrorContains(t, err, "no test files found")
+
+	_, err = runUnitTests(t, map[string]string{
+		"app.star":      `app = ace.app("testApp", routes = [ace.html("/")]`,
+		"app_test.star": `def test_x(): pass`,
+	}, "")
+	testutil.AssertErrorContains(t, err, "error loading app")
+
+	results, err := runUnitTests(t, map[string]string{
+		"app.star": `app = ace.app("testApp", routes = [ace.html("/")])`,
+		"app_test.star": `
+def test_mock():
+	test.mock("http.in", "unknown", value=1)`,
+	}, "")
+	testutil.AssertNoError(t, err)
+	testutil.AssertStringContains(t, results[0].Error, "unknown is not a function in module http.in")
+}
+
+func TestUnitTestRunnerReplay(t *testing.T) {
+	fileData := map[string]string{
+		"app.star":    unitTestApp,
+		"params.star": `param("greeting", type=STRING, default="hello")`,
+		"util.star": `
+def double(x):
+	return x * 2`,
+		"mocks/http.in/get.json": `[
+			{"args": ["https://example.com/items"], "kwargs": {"params": {"q": "x"}}, "value": ["a", "b"]},
+			{"error": "connection refused"}
+		]`,
+		"fixtures/items.json": `{"value": ["z"]}`,
+		"app_test.star": `
+load("app.star", "handler")
+
+def test_replay():
+	test.replay("http.in", "get")
+	ret = handler(test.request("/", query={"q": "x"}))
+	assert.eq(ret["count"], 4)
+	assert.eq(len(test.calls("http.in", "get")), 1)
+
+def test_replay_error():
+	test.replay("http.in", "get")
+	ret = handler(test.request("/", query={"q": "y"}))
+	assert.contains(ret["error"], "refused")
+
+def test_replay_file():
+	test.replay("http.in", "get", file="fixtures/items.json")
+	ret = handler(test.request())
+	assert.eq(ret["items"], ["z"])
+
+def test_replay_missing():
+	test.replay("http.in", "post")
+`,
+	}
+
+	results, err := runUnitTests(t, fileData, "")
+	testutil.AssertNoError(t, err)
+	byName := map[string]types.AppTestResult{}
+	for _, r := range results {
+		byName[r.Name] = r
+	}
+	testutil.AssertEqualsBool(t, "replay", true, byName["test_replay"].Passed)
+	testutil.AssertEqualsBool(t, "replay error", true, byName["test_replay_error"].Passed)
+	testutil.AssertEqualsBool(t, "replay file", true, byName["test_replay_file"].Passed)
+	testutil.AssertEqualsBool(t, "replay missing", false, byName["test_replay_missing"].Passed)
+	testutil.AssertStringContains(t, byName["test_replay_missing"].Error, "error reading fixture mocks/http.in/post.json")
+}
@@ -0,0 +1,572 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package app
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/app/appfs"
+	"github.com/openrundev/openrun/internal/app/apptype"
+	"github.com/openrundev/openrun/internal/app/starlark_type"
+	"github.com/openrundev/openrun/internal/types"
+	starlarkjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+const (
+	unitTestFileSuffix = "_test" + apptype.STARLARK_FILE_SUFFIX
+	unitTestDir        = "tests"
+	unitTestPrefix     = "test_"
+	unitTestTimeout    = time.Minute
+)
+
+// unitTestRunner runs the Starlark unit tests for an app. Each top level function named test_*
+// in a *_test.star file is a test, called with the assert and test modules predeclared. The test
+// files load the app code using load("app.star", ...). The plugin modules loaded by the app code
+// are replaced with mocks, a plugin call for which the test has not set a mock fails the test
+type unitTestRunner struct {
+	app    *App
+	mocks  map[string]unitTestMock     // the mock for each plugin function, reset for each test
+	calls  map[string][]starlark.Value // the calls made to each mocked plugin function
+	result *types.AppTestResult        // the result for the running test
+}
+
+type unitTestMock struct {
+	value starlark.Value // the value to return, called with the plugin call args if callable
+	err   string         // the error to return, if set
+}
+
+// RunUnitTests loads the app source and runs the Starlark unit tests from the *_test.star files in
+// the app root and the tests directory. The app is not initialized, no routes or containers are
+// set up and no plugin calls are made. Tests whose name does not match the filter glob are skipped
+func RunUnitTests(ctx context.Context, logger *types.Logger, sourceFS *appfs.SourceFs, filter string, params map[string]string) ([]types.AppTestResult, error) {
+	serverConfig := &types.ServerConfig{}
+	appEntry := &types.AppEntry{
+		Path:     "/",
+		Metadata: types.AppMetadata{ParamValues: params},
+	}
+	a := &App{
+		Logger:        logger,
+		AppEntry:      appEntry,
+		sourceFS:      sourceFS,
+		systemConfig:  &types.SystemConfig{},
+		serverConfig:  serverConfig,
+		starlarkCache: map[string]*starlarkCacheEntry{},
+		appUrl:        types.GetAppUrl(appEntry.AppPathDomain(), serverConfig),
+	}
+	a.appUrlLocal = a.appUrl
+	if err := a.loadSchemaInfo(sourceFS); err != nil {
+		return nil, err
+	}
+	if err := a.loadParamsInfo(sourceFS); err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, pattern := range []string{"*" + unitTestFileSuffix, path.Join(unitTestDir, "*"+unitTestFileSuffix)} {
+		matches, err := sourceFS.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no test files found, test files are named *%s, in the app or the %s directory", unitTestFileSuffix, unitTestDir)
+	}
+	slices.Sort(files)
+
+	r := &unitTestRunner{app: a}
+	if err := r.loadApp(ctx); err != nil {
+		return nil, err
+	}
+
+	results := make([]types.AppTestResult, 0)
+	for _, file := range files {
+		fileResults, err := r.runFile(ctx, file, filter)
+		if err != nil {
+			// Report the file load failure as a failed test, the other files are still run
+			results = append(results, types.AppTestResult{Name: file, File: file, Error: err.Error()})
+			continue
+		}
+		results = append(results, fileResults...)
+	}
+	return results, nil
+}
+
+// loadApp loads the app definition, to check that it is valid and to set the app name
+func (r *unitTestRunner) loadApp(ctx context.Context) error {
+	r.resetMocks()
+	thread := r.newThread(ctx, apptype.APP_FILE_NAME)
+	globals, err := r.app.loadStarlark(thread, apptype.APP_FILE_NAME, r.app.starlarkCache)
+	if err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			return fmt.Errorf("error loading app: %s", evalErr.Backtrace())
+		}
+		return fmt.Errorf("error loading app: %w", err)
+	}
+	if r.app.appDef, err = verifyConfig(globals); err != nil {
+		return err
+	}
+	r.app.Name, err = apptype.GetStringAttr(r.app.appDef, "name")
+	return err
+}
+
+func (r *unitTestRunner) newThread(ctx context.Context, name string) *starlark.Thread {
+	thread := &starlark.Thread{
+		Name:  name,
+		Print: starlarkThreadPrint,
+		Load:  r.load,
+	}
+	thread.SetLocal(types.TL_CONTEXT, ctx)
+	thread.SetLocal(types.TL_APP_URL, r.app.appUrlLocal)
+	return thread
+}
+
+func (r *unitTestRunner) runFile(ctx context.Context, file, filter string) ([]types.AppTestResult, error) {
+	buf, err := r.app.sourceFS.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s: %w", file, err)
+	}
+	builtin, err := r.app.createBuiltin()
+	if err != nil {
+		return nil, err
+	}
+	builtin["assert"] = r.assertModule()
+	builtin["test"] = r.testModule()
+	builtin["json"] = starlarkjson.Module
+
+	r.resetMocks()
+	globals, err := starlark.ExecFileOptions(r.app.sandbox.FileOptions(), r.newThread(ctx, file), file, buf, builtin)
+	if err != nil {
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			return nil, fmt.Errorf("error loading test file: %s", evalErr.Backtrace())
+		}
+		return nil, fmt.Errorf("error loading test file: %w", err)
+	}
+
+	tests := make([]*starlark.Function, 0)
+	for name, value := range globals {
+		fn, ok := value.(*starlark.Function)
+		if !ok || !strings.HasPrefix(name, unitTestPrefix) {
+			continue
+		}
+		if filter != "" {
+			if matched, _ := path.Match(filter, name); !matched {
+				continue
+			}
+		}
+		tests = append(tests, fn)
+	}
+	// Run the tests in the order they are defined in the file
+	slices.SortFunc(tests, func(a, b *starlark.Function) int {
+		return int(a.Position().Line) - int(b.Position().Line)
+	})
+
+	results := make([]types.AppTestResult, 0, len(tests))
+	for _, fn := range tests {
+		results = append(results, r.runTest(ctx, file, fn))
+	}
+	return results, nil
+}
+
+func (r *unitTestRunner) runTest(ctx context.Context, file string, fn *starlark.Function) (result types.AppTestResult) {
+	result = types.AppTestResult{Name: fn.Name(), File: file}
+	start := time.Now()
+	defer func() {
+		if rec := recover(); rec != nil {
+			result.Passed = false
+			result.Error = fmt.Sprintf("panic in test: %v", rec)
+		}
+		result.DurationMs = time.Since(start).Milliseconds()
+		r.result = nil
+	}()
+
+	r.resetMocks()
+	r.result = &result
+	testCtx, cancel := context.WithTimeout(ctx, unitTestTimeout)
+	defer cancel()
+	thread := r.newThread(testCtx, fn.Name())
+	stop := context.AfterFunc(testCtx, func() { thread.Cancel(testCtx.Err().Error()) })
+	defer stop()
+
+	if _, err := starlark.Call(thread, fn, nil, nil); err != nil {
+		result.Error = err.Error()
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			result.Error = evalErr.Backtrace()
+		}
+		return result
+	}
+	result.Passed = true
+	return result
+}
+
+func (r *unitTestRunner) resetMocks() {
+	r.mocks = map[string]unitTestMock{}
+	r.calls = map[string][]starlark.Value{}
+}
+
+// load loads the starlark files from the app source. Plugin modules are loaded with every
+// function replaced by a mock, the plugin constants are available as is. No permission
+// checks are done, since no plugin calls are made
+func (r *unitTestRunner) load(thread *starlark.Thread, moduleFullPath string) (starlark.StringDict, error) {
+	if strings.HasSuffix(moduleFullPath, apptype.STARLARK_FILE_SUFFIX) {
+		return r.app.loadStarlark(thread, moduleFullPath, r.app.starlarkCache)
+	}
+
+	modulePath, moduleName, _ := parseModulePath(moduleFullPath)
+	plugin, err := r.app.pluginLookup(thread, modulePath)
+	if err != nil {
+		return nil, err
+	}
+
+	mockedDict := make(starlark.StringDict)
+	for funcName, pluginInfo := range plugin {
+		if pluginInfo.HandlerName == "" {
+			mockedDict[funcName] = pluginInfo.ConstantValue
+		} else {
+			mockedDict[funcName] = r.mockBuiltin(modulePath, funcName)
+		}
+	}
+
+	ret := make(starlark.StringDict)
+	ret[moduleName] = starlarkstruct.FromStringDict(starlarkstruct.Default, mockedDict)
+	return ret, nil
+}
+
+// mockBuiltin returns the builtin which replaces a plugin function. The mock value is wrapped
+// in a plugin response, like the value returned by the plugin would be
+func (r *unitTestRunner) mockBuiltin(modulePath, funcName string) *starlark.Builtin {
+	key := modulePath + "." + funcName
+	mockFunc := func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		mock := r.mocks[key]
+		if mock.err != "" {
+			return nil, errors.New(mock.err)
+		}
+		if callable, ok := mock.value.(starlark.Callable); ok {
+			return starlark.Call(thread, callable, args, kwargs)
+		}
+		return mock.value, nil
+	}
+	wrapped := pluginErrorWrapper(mockFunc, nil)
+
+	return starlark.NewBuiltin(funcName, func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		if _, ok := r.mocks[key]; !ok {
+			return nil, fmt.Errorf("no mock set for plugin call %s, add test.mock(%q, %q, ...) to the test", key, modulePath, funcName)
+		}
+		kwargsDict := starlark.NewDict(len(kwargs))
+		for _, kwarg := range kwargs {
+			kwargsDict.SetKey(kwarg[0], kwarg[1]) //nolint:errcheck
+		}
+		r.calls[key] = append(r.calls[key], starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+			"args":   slices.Clone(args),
+			"kwargs": kwargsDict,
+		}))
+		return wrapped(thread, fn, args, kwargs)
+	})
+}
+
+// testModule returns the test module, with functions to create requests and to mock plugin calls
+func (r *unitTestRunner) testModule() *starlarkstruct.Module {
+	return &starlarkstruct.Module{
+		Name: "test",
+		Members: starlark.StringDict{
+			"request": starlark.NewBuiltin("request", r.request),
+			"mock":    starlark.NewBuiltin("mock", r.mock),
+			"replay":  starlark.NewBuiltin("replay", r.replay),
+			"calls":   starlark.NewBuiltin("calls", r.mockCalls),
+		},
+	}
+}
+
+// request creates a request to pass to a handler function,
+// request(path="/", method="GET", query={}, form={}, headers={}, url_params={}, user_id="", is_partial=False)
+func (r *unitTestRunner) request(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	pagePath, method := starlark.String("/"), starlark.String(http.MethodGet)
+	var query, form, headers, urlParams *starlark.Dict
+	var userId starlark.String
+	var isPartial starlark.Bool
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "path?", &pagePath, "method?", &method, "query?", &query,
+		"form?", &form, "headers?", &headers, "url_params?", &urlParams, "user_id?", &userId, "is_partial?", &isPartial); err != nil {
+		return nil, err
+	}
+
+	queryValues, err := unitTestValues(query)
+	if err != nil {
+		return nil, fmt.Errorf("%s: query %w", fn.Name(), err)
+	}
+	formValues, err := unitTestValues(form)
+	if err != nil {
+		return nil, fmt.Errorf("%s: form %w", fn.Name(), err)
+	}
+	headerValues, err := unitTestValues(headers)
+	if err != nil {
+		return nil, fmt.Errorf("%s: headers %w", fn.Name(), err)
+	}
+	header := http.Header{}
+	for key, values := range headerValues {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+	params := map[string]string{}
+	paramValues, err := unitTestValues(urlParams)
+	if err != nil {
+		return nil, fmt.Errorf("%s: url_params %w", fn.Name(), err)
+	}
+	for key := range paramValues {
+		params[key] = paramValues.Get(key)
+	}
+
+	// Form has the post form values followed by the query values, like for http.Request
+	allValues := url.Values{}
+	for key, values := range formValues {
+		allValues[key] = append(allValues[key], values...)
+	}
+	for key, values := range queryValues {
+		allValues[key] = append(allValues[key], values...)
+	}
+
+	reqPath := pagePath.GoString()
+	if reqPath == "/" {
+		reqPath = ""
+	}
+	return starlark_type.Request{
+		AppName:   r.app.Name,
+		AppPath:   "",
+		AppUrl:    r.app.appUrl,
+		PagePath:  reqPath,
+		PageUrl:   r.app.appUrl + reqPath,
+		Method:    strings.ToUpper(method.GoString()),
+		IsPartial: bool(isPartial),
+		Headers:   header,
+		RemoteIP:  "127.0.0.1",
+		UrlParams: params,
+		Form:      allValues,
+		Query:     queryValues,
+		PostForm:  formValues,
+		UserId:    cmp.Or(userId.GoString(), types.ANONYMOUS_USER),
+	}, nil
+}
+
+// mock sets the value returned by a plugin function, mock(module, function, value=None, error="").
+// If value is callable, it is called with the plugin call args and its return value is used
+func (r *unitTestRunner) mock(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var module, funcName, errorMsg starlark.String
+	var value starlark.Value = starlark.None
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "module", &module, "function", &funcName,
+		"value?", &value, "error?", &errorMsg); err != nil {
+		return nil, err
+	}
+
+	modulePath, err := r.pluginFunction(thread, fn.Name(), module.GoString(), funcName.GoString())
+	if err != nil {
+		return nil, err
+	}
+	r.mocks[modulePath+"."+funcName.GoString()] = unitTestMock{value: value, err: errorMsg.GoString()}
+	return starlark.None, nil
+}
+
+// replay sets the plugin function mock to return the responses from a fixture file, matched by
+// the call args like for the mock plugin mode, replay(module, function, file=""). The default
+// file is mocks/<module>/<function>.json in the app source, as saved by the mock record mode
+func (r *unitTestRunner) replay(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var module, funcName, file starlark.String
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "module", &module, "function", &funcName, "file?", &file); err != nil {
+		return nil, err
+	}
+
+	modulePath, err := r.pluginFunction(thread, fn.Name(), module.GoString(), funcName.GoString())
+	if err != nil {
+		return nil, err
+	}
+	fixturePath := file.GoString()
+	if fixturePath == "" {
+		fixturePath = path.Join(cmp.Or(r.app.AppConfig.Mock.Dir, DEFAULT_MOCK_DIR), modulePath, funcName.GoString()+".json")
+	}
+	data, err := r.app.sourceFS.ReadFile(fixturePath)
+	if err != nil {
+		return nil, fmt.Errorf("%s: error reading fixture %s: %w", fn.Name(), fixturePath, err)
+	}
+	entries, err := parseMockEntries(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: error reading fixture %s: %w", fn.Name(), fixturePath, err)
+	}
+
+	replayFunc := starlark.NewBuiltin(funcName.GoString(), func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		return replayMockEntries(entries, fixturePath, args, kwargs)
+	})
+	r.mocks[modulePath+"."+funcName.GoString()] = unitTestMock{value: replayFunc}
+	return starlark.None, nil
+}
+
+// pluginFunction checks that the function is defined in the plugin module, returns the module path
+func (r *unitTestRunner) pluginFunction(thread *starlark.Thread, caller, module, funcName string) (string, error) {
+	modulePath, _, _ := parseModulePath(module)
+	plugin, err := r.app.pluginLookup(thread, modulePath)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", caller, err)
+	}
+	if pluginInfo, ok := plugin[funcName]; !ok || pluginInfo.HandlerName == "" {
+		return "", fmt.Errorf("%s: %s is not a function in module %s", caller, funcName, modulePath)
+	}
+	return modulePath, nil
+}
+
+// mockCalls returns the calls made to a mocked plugin function in the test, as a list of
+// structs with the args and kwargs, calls(module, function)
+func (r *unitTestRunner) mockCalls(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var module, funcName starlark.String
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "module", &module, "function", &funcName); err != nil {
+		return nil, err
+	}
+	modulePath, _, _ := parseModulePath(module.GoString())
+	return starlark.NewList(slices.Clone(r.calls[modulePath+"."+funcName.GoString()])), nil
+}
+
+// assertModule returns the assert module, each function fails the test if the check fails
+func (r *unitTestRunner) assertModule() *starlarkstruct.Module {
+	return &starlarkstruct.Module{
+		Name: "assert",
+		Members: starlark.StringDict{
+			"eq":       starlark.NewBuiltin("eq", r.assertEqual(true)),
+			"ne":       starlark.NewBuiltin("ne", r.assertEqual(false)),
+			"true":     starlark.NewBuiltin("true", r.assertTruth(true)),
+			"false":    starlark.NewBuiltin("false", r.assertTruth(false)),
+			"contains": starlark.NewBuiltin("contains", r.assertContains),
+			"fails":    starlark.NewBuiltin("fails", r.assertFails),
+		},
+	}
+}
+
+func unitTestAssertion(msg starlark.String, format string, args ...any) error {
+	if msg != "" {
+		return fmt.Errorf("assertion failed: %s: %s", msg.GoString(), fmt.Sprintf(format, args...))
+	}
+	return fmt.Errorf("assertion failed: %s", fmt.Sprintf(format, args...))
+}
+
+func (r *unitTestRunner) countAssertion() {
+	if r.result != nil {
+		r.result.Assertions++
+	}
+}
+
+func (r *unitTestRunner) assertEqual(expectEqual bool) StarlarkFunction {
+	return func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var actual, expected starlark.Value
+		var msg starlark.String
+		if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "actual", &actual, "expected", &expected, "msg?", &msg); err != nil {
+			return nil, err
+		}
+		r.countAssertion()
+		equal, err := starlark.Equal(actual, expected)
+		if err != nil {
+			return nil, err
+		}
+		if equal != expectEqual {
+			if expectEqual {
+				return nil, unitTestAssertion(msg, "expected %s, got %s", expected.String(), actual.String())
+			}
+			return nil, unitTestAssertion(msg, "expected value other than %s", expected.String())
+		}
+		return starlark.None, nil
+	}
+}
+
+func (r *unitTestRunner) assertTruth(expect bool) StarlarkFunction {
+	return func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var cond starlark.Value
+		var msg starlark.String
+		if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "cond", &cond, "msg?", &msg); err != nil {
+			return nil, err
+		}
+		r.countAssertion()
+		if bool(cond.Truth()) != expect {
+			return nil, unitTestAssertion(msg, "expected %t, got %s", expect, cond.String())
+		}
+		return starlark.None, nil
+	}
+}
+
+// assertContains checks for a substring if both values are strings, for membership using
+// the starlark in operator otherwise
+func (r *unitTestRunner) assertContains(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var container, item starlark.Value
+	var msg starlark.String
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "container", &container, "item", &item, "msg?", &msg); err != nil {
+		return nil, err
+	}
+	r.countAssertion()
+	found, err := starlark.Binary(syntax.IN, item, container)
+	if err != nil {
+		return nil, err
+	}
+	if !found.Truth() {
+		return nil, unitTestAssertion(msg, "%s not found in %s", item.String(), container.String())
+	}
+	return starlark.None, nil
+}
+
+// assertFails calls the function with no args and checks that it fails, with an error containing
+// match if set. The error message is returned, fails(fn, match="", msg="")
+func (r *unitTestRunner) assertFails(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var callable starlark.Callable
+	var match, msg starlark.String
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "fn", &callable, "match?", &match, "msg?", &msg); err != nil {
+		return nil, err
+	}
+	r.countAssertion()
+	_, err := starlark.Call(thread, callable, nil, nil)
+	if err == nil {
+		return nil, unitTestAssertion(msg, "expected %s to fail", callable.Name())
+	}
+	errMsg := err.Error()
+	if evalErr, ok := err.(*starlark.EvalError); ok {
+		errMsg = evalErr.Msg
+	}
+	if !strings.Contains(errMsg, match.GoString()) {
+		return nil, unitTestAssertion(msg, "expected error containing %q, got %q", match.GoString(), errMsg)
+	}
+	return starlark.String(errMsg), nil
+}
+
+// unitTestValues converts a dict of strings or lists of strings to url values
+func unitTestValues(dict *starlark.Dict) (url.Values, error) {
+	values := url.Values{}
+	if dict == nil {
+		return values, nil
+	}
+	for _, item := range dict.Items() {
+		key, ok := item[0].(starlark.String)
+		if !ok {
+			return nil, fmt.Errorf("should be a dict with string keys, got %s", item[0].Type())
+		}
+		switch v := item[1].(type) {
+		case starlark.String:
+			values.Add(key.GoString(), v.GoString())
+		case *starlark.List:
+			for i := range v.Len() {
+				s, ok := v.Index(i).(starlark.String)
+				if !ok {
+					return nil, fmt.Errorf("value for %s should be a string, got %s", key.GoString(), v.Index(i).Type())
+				}
+				values.Add(key.GoString(), s.GoString())
+			}
+		default:
+			values.Add(key.GoString(), v.String())
+		}
+	}
+	return values, nil
+}
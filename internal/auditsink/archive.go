@@ -0,0 +1,219 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package auditsink
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awscfg "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// Archiver saves the audit events expired by the retention policy, before they are deleted
+// from the audit DB
+type Archiver interface {
+	// Archive writes the records as a gzipped JSON lines file with the given name
+	Archive(ctx context.Context, name string, records []Record) error
+	// Target returns the archive location, for logging
+	Target() string
+}
+
+// NewArchiver creates the archiver for the target, which is either a local directory or an
+// s3://bucket/prefix url. The s3 url supports the region and endpoint query params, the
+// endpoint is for S3 compatible stores like MinIO and uses path style addressing.
+func NewArchiver(ctx context.Context, target string) (Archiver, error) {
+	if strings.HasPrefix(target, "s3://") {
+		return newS3Archiver(ctx, target)
+	}
+	if strings.Contains(target, "://") {
+		return nil, fmt.Errorf("invalid audit archive %s, has to be a directory or a s3:// url", target)
+	}
+	return newDirArchiver(target)
+}
+
+// encodeRecords returns the gzipped JSON lines for the records
+func encodeRecords(records []Record) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	encoder := json.NewEncoder(gz)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return nil, err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type dirArchiver struct {
+	dir string
+}
+
+var _ Archiver = (*dirArchiver)(nil)
+
+func newDirArchiver(dir string) (*dirArchiver, error) {
+	dir, err := filepath.Abs(os.ExpandEnv(dir))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating audit archive directory %s: %w", dir, err)
+	}
+	return &dirArchiver{dir: dir}, nil
+}
+
+func (d *dirArchiver) Archive(ctx context.Context, name string, records []Record) error {
+	data, err := encodeRecords(records)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temp file and rename, so a partial file is never left with the final name
+	tmp, err := os.CreateTemp(d.dir, "."+name+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close() //nolint:errcheck
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close() //nolint:errcheck
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(d.dir, name))
+}
+
+func (d *dirArchiver) Target() string {
+	return d.dir
+}
+
+// s3Archiver uploads the archive files using a SigV4 signed PUT request. The credentials are
+// loaded using the default AWS config chain (env, shared config, instance role)
+type s3Archiver struct {
+	target      string
+	bucket      string
+	prefix      string
+	region      string
+	endpoint    string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	client      *http.Client
+}
+
+var _ Archiver = (*s3Archiver)(nil)
+
+func newS3Archiver(ctx context.Context, target string) (*s3Archiver, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid audit archive url %s: %w", target, err)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid audit archive url %s, bucket is required", target)
+	}
+
+	options := []func(*awscfg.LoadOptions) error{}
+	if region := u.Query().Get("region"); region != "" {
+		options = append(options, awscfg.WithRegion(region))
+	}
+	cfg, err := awscfg.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("error loading aws config for audit archive: %w", err)
+	}
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("region is required for audit archive %s, set the region query param or AWS_REGION", target)
+	}
+
+	endpoint := strings.TrimSuffix(u.Query().Get("endpoint"), "/")
+	if endpoint != "" && !strings.HasPrefix(endpoint, "https://") && !strings.HasPrefix(endpoint, "http://") {
+		return nil, fmt.Errorf("invalid endpoint %s for audit archive, has to be a http(s) url", endpoint)
+	}
+
+	prefix := strings.Trim(u.Path, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &s3Archiver{
+		target:      "s3://" + u.Host + "/" + prefix,
+		bucket:      u.Host,
+		prefix:      prefix,
+		region:      cfg.Region,
+		endpoint:    endpoint,
+		credentials: cfg.Credentials,
+		signer:      v4.NewSigner(),
+		client:      &http.Client{Timeout: 5 * time.Minute},
+	}, nil
+}
+
+func (s *s3Archiver) objectUrl(key string) string {
+	escapedKey := (&url.URL{Path: key}).EscapedPath()
+	if s.endpoint != "" {
+		return s.endpoint + "/" + s.bucket + "/" + escapedKey
+	}
+	return "https://" + s.bucket + ".s3." + s.region + ".amazonaws.com/" + escapedKey
+}
+
+func (s *s3Archiver) Archive(ctx context.Context, name string, records []Record) error {
+	data, err := encodeRecords(records)
+	if err != nil {
+		return err
+	}
+	if s.credentials == nil {
+		return fmt.Errorf("no aws credentials found for audit archive %s", s.target)
+	}
+	creds, err := s.credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("error loading aws credentials for audit archive: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectUrl(s.prefix+name), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	hash := sha256.Sum256(data)
+	payloadHash := hex.EncodeToString(hash[:])
+	req.Header.Set("Content-Type", "application/gzip")
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if err := s.signer.SignHTTP(ctx, creds, req, payloadHash, "s3", s.region, time.Now(), func(o *v4.SignerOptions) {
+		// S3 signs the path as sent, without escaping it again
+		o.DisableURIPathEscaping = true
+	}); err != nil {
+		return fmt.Errorf("error signing audit archive request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("error uploading audit archive %s%s, status %d: %s", s.target, name, resp.StatusCode, respBody)
+	}
+	return nil
+}
+
+func (s *s3Archiver) Target() string {
+	return s.target
+}
@@ -0,0 +1,347 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+// Package auditsink forwards audit events to external systems (syslog, webhooks and OTLP log
+// receivers), for shipping the audit log to a SIEM. It also archives the events expired by the
+// retention policy, to a directory or to S3.
+package auditsink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	SINK_SYSLOG  = "syslog"
+	SINK_WEBHOOK = "webhook"
+	SINK_OTLP    = "otlp"
+)
+
+const (
+	defaultBatchSize       = 100
+	defaultFlushIntervalMs = 1000
+	defaultQueueSize       = 10_000
+	defaultMaxRetries      = 5
+	defaultTimeoutSecs     = 10
+
+	initialRetryBackoff = 500 * time.Millisecond
+	maxRetryBackoff     = 30 * time.Second
+	// dropLogInterval limits the logging of dropped events when a sink queue is full
+	dropLogInterval = time.Minute
+	// closeTimeout is the max wait for the queued events to be sent on shutdown
+	closeTimeout = 10 * time.Second
+)
+
+// Record is the format in which the audit events are sent to the sinks
+type Record struct {
+	Rid        string    `json:"rid"`
+	AppId      string    `json:"app_id,omitempty"`
+	CreateTime time.Time `json:"create_time"`
+	UserId     string    `json:"user_id"`
+	EventType  string    `json:"event_type"`
+	Operation  string    `json:"operation"`
+	Target     string    `json:"target"`
+	Status     string    `json:"status"`
+	Detail     string    `json:"detail,omitempty"`
+}
+
+func newRecord(event *types.AuditEvent) Record {
+	return Record{
+		Rid:        event.RequestId,
+		AppId:      string(event.AppId),
+		CreateTime: event.CreateTime.UTC(),
+		UserId:     event.UserId,
+		EventType:  string(event.EventType),
+		Operation:  event.Operation,
+		Target:     event.Target,
+		Status:     event.Status,
+		Detail:     event.Detail,
+	}
+}
+
+// failed returns true if the event is for a failed operation
+func (r *Record) failed() bool {
+	return r.Status == string(types.EventStatusFailure) || (len(r.Status) == 3 && r.Status >= "500")
+}
+
+// Sink sends a batch of audit events to an external system
+type Sink interface {
+	Send(ctx context.Context, records []Record) error
+	Close() error
+}
+
+// permanentError is a send failure which a retry cannot fix, like the receiver rejecting the request
+type permanentError struct {
+	error
+}
+
+func (e permanentError) Unwrap() error {
+	return e.error
+}
+
+// SecretEvalFunc resolves the {{secret}} references in a config value
+type SecretEvalFunc func(input string) (string, error)
+
+// NewSink creates the sink for the config. Secret references in the config are resolved on
+// every send, so rotated values are used without a restart
+func NewSink(name string, config types.AuditSinkConfig, evalSecret SecretEvalFunc) (Sink, error) {
+	if config.Url == "" {
+		return nil, fmt.Errorf("audit sink %s: url is required", name)
+	}
+	switch config.Type {
+	case SINK_SYSLOG:
+		return newSyslogSink(config)
+	case SINK_WEBHOOK:
+		return newWebhookSink(config, evalSecret)
+	case SINK_OTLP:
+		return newOTLPSink(config, evalSecret)
+	default:
+		return nil, fmt.Errorf("audit sink %s: invalid type %q, valid types are %s, %s and %s",
+			name, config.Type, SINK_SYSLOG, SINK_WEBHOOK, SINK_OTLP)
+	}
+}
+
+// Forwarder sends the audit events to the configured sinks. Each sink has its own queue and
+// worker, a slow or failing sink does not delay the others
+type Forwarder struct {
+	workers []*worker
+	closed  atomic.Bool
+}
+
+// NewForwarder creates the forwarder for the sink configs. Returns nil if no sinks are
+// configured, the Forwarder methods are no-ops on nil
+func NewForwarder(logger *types.Logger, configs map[string]types.AuditSinkConfig, evalSecret SecretEvalFunc) (*Forwarder, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+
+	f := &Forwarder{}
+	for _, name := range slices.Sorted(maps.Keys(configs)) {
+		config := configs[name]
+		sink, err := NewSink(name, config, evalSecret)
+		if err != nil {
+			f.closeSinks()
+			return nil, err
+		}
+		f.workers = append(f.workers, newWorker(logger, name, config, sink))
+	}
+
+	for _, w := range f.workers {
+		go w.run()
+	}
+	return f, nil
+}
+
+// Forward queues the event for all the sinks. This never blocks, if a sink queue is full the
+// event is dropped for that sink
+func (f *Forwarder) Forward(event *types.AuditEvent) {
+	if f == nil || f.closed.Load() {
+		return
+	}
+	record := newRecord(event)
+	for _, w := range f.workers {
+		w.enqueue(record)
+	}
+}
+
+// Dropped returns the count of events dropped per sink, since the forwarder was created
+func (f *Forwarder) Dropped() map[string]int64 {
+	ret := map[string]int64{}
+	if f == nil {
+		return ret
+	}
+	for _, w := range f.workers {
+		ret[w.name] = w.dropped.Load()
+	}
+	return ret
+}
+
+// Close sends the queued events and stops the workers. Events queued after Close are dropped
+func (f *Forwarder) Close() {
+	if f == nil || f.closed.Swap(true) {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, w := range f.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.close()
+		}()
+	}
+	wg.Wait()
+	f.closeSinks()
+}
+
+func (f *Forwarder) closeSinks() {
+	for _, w := range f.workers {
+		if err := w.sink.Close(); err != nil {
+			w.Warn().Err(err).Str("sink", w.name).Msg("error closing audit sink")
+		}
+	}
+}
+
+type worker struct {
+	*types.Logger
+	name          string
+	sink          Sink
+	eventTypes    []string
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	timeout       time.Duration
+	queue         chan Record
+	stop          chan struct{}
+	stopDeadline  time.Time // the deadline for sending the queued events on shutdown
+	done          chan struct{}
+	dropped       atomic.Int64
+	lastDropLog   atomic.Int64 // unix nano time of the last dropped events warning
+}
+
+func newWorker(logger *types.Logger, name string, config types.AuditSinkConfig, sink Sink) *worker {
+	withDefault := func(value, defaultValue int) int {
+		if value <= 0 {
+			return defaultValue
+		}
+		return value
+	}
+	maxRetries := config.MaxRetries
+	if maxRetries < 0 {
+		maxRetries = 0
+	} else if maxRetries == 0 {
+		maxRetries = defaultMaxRetries
+	}
+
+	return &worker{
+		Logger:        logger,
+		name:          name,
+		sink:          sink,
+		eventTypes:    config.EventTypes,
+		batchSize:     withDefault(config.BatchSize, defaultBatchSize),
+		flushInterval: time.Duration(withDefault(config.FlushIntervalMs, defaultFlushIntervalMs)) * time.Millisecond,
+		maxRetries:    maxRetries,
+		timeout:       time.Duration(withDefault(config.TimeoutSecs, defaultTimeoutSecs)) * time.Second,
+		queue:         make(chan Record, withDefault(config.QueueSize, defaultQueueSize)),
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+}
+
+func (w *worker) enqueue(record Record) {
+	if len(w.eventTypes) > 0 && !slices.Contains(w.eventTypes, record.EventType) {
+		return
+	}
+
+	select {
+	case w.queue <- record:
+		return
+	default:
+	}
+
+	// Queue is full, the sink is down or is not keeping up. Drop the event instead of
+	// blocking the request path
+	dropped := w.dropped.Add(1)
+	now := time.Now().UnixNano()
+	last := w.lastDropLog.Load()
+	if now-last >= int64(dropLogInterval) && w.lastDropLog.CompareAndSwap(last, now) {
+		w.Warn().Str("sink", w.name).Int64("dropped_total", dropped).Msg("audit sink queue is full, dropping events")
+	}
+}
+
+func (w *worker) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Record, 0, w.batchSize)
+	for {
+		select {
+		case record := <-w.queue:
+			batch = append(batch, record)
+			if len(batch) >= w.batchSize {
+				w.send(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				w.send(batch)
+				batch = batch[:0]
+			}
+		case <-w.stop:
+			// Send the queued events, the retries are limited by the close timeout
+			for {
+				select {
+				case record := <-w.queue:
+					batch = append(batch, record)
+					if len(batch) >= w.batchSize {
+						w.send(batch)
+						batch = batch[:0]
+					}
+					continue
+				default:
+				}
+				break
+			}
+			if len(batch) > 0 {
+				w.send(batch)
+			}
+			return
+		}
+	}
+}
+
+// send sends the batch, retrying with exponential backoff. The batch is dropped after the
+// retries are exhausted or on a permanent error. On shutdown, the retries continue till the
+// close timeout
+func (w *worker) send(batch []Record) {
+	backoff := initialRetryBackoff
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), w.timeout)
+		err := w.sink.Send(ctx, batch)
+		cancel()
+		if err == nil {
+			return
+		}
+
+		var permErr permanentError
+		if errors.As(err, &permErr) || attempt >= w.maxRetries || w.pastStopDeadline(backoff) {
+			w.Error().Err(err).Str("sink", w.name).Int("events", len(batch)).Msg("error sending audit events, events dropped")
+			return
+		}
+
+		w.Warn().Err(err).Str("sink", w.name).Int("attempt", attempt+1).Msg("error sending audit events, retrying")
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxRetryBackoff)
+	}
+}
+
+// pastStopDeadline returns true if the worker is stopping and a retry after the wait would
+// be past the close timeout
+func (w *worker) pastStopDeadline(wait time.Duration) bool {
+	select {
+	case <-w.stop:
+		return time.Now().Add(wait).After(w.stopDeadline)
+	default:
+		return false
+	}
+}
+
+func (w *worker) close() {
+	// stopDeadline is read by the worker only after the stop channel is closed
+	w.stopDeadline = time.Now().Add(closeTimeout)
+	close(w.stop)
+	select {
+	case <-w.done:
+	case <-time.After(closeTimeout + time.Second):
+		w.Warn().Str("sink", w.name).Int("queued", len(w.queue)).Msg("timeout sending queued audit events on shutdown")
+	}
+}
@@ -0,0 +1,317 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package auditsink
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func testEvent(rid string, eventType types.EventType, status string) *types.AuditEvent {
+	return &types.AuditEvent{
+		RequestId:  rid,
+		AppId:      "app_prd_123",
+		CreateTime: time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC),
+		UserId:     "admin",
+		EventType:  eventType,
+		Operation:  "reload_apps",
+		Target:     "/myapp",
+		Status:     status,
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	received := []Record{}
+	signatureOk := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte("key1"))
+		mac.Write(body) //nolint:errcheck
+		signatureOk = signatureOk && r.Header.Get(SIGNATURE_HEADER) == "sha256="+hex.EncodeToString(mac.Sum(nil)) &&
+			r.Header.Get("Authorization") == "Bearer token1"
+		var payload struct {
+			Events []Record `json:"events"`
+		}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("invalid payload: %s", body)
+		}
+		received = append(received, payload.Events...)
+	}))
+	defer server.Close()
+
+	evalSecret := func(input string) (string, error) {
+		return strings.ReplaceAll(input, `{{secret "token"}}`, "token1"), nil
+	}
+	forwarder, err := NewForwarder(testutil.TestLogger(), map[string]types.AuditSinkConfig{
+		"siem": {Type: SINK_WEBHOOK, Url: server.URL, Secret: "key1", BatchSize: 2, FlushIntervalMs: 10,
+			Headers: map[string]string{"Authorization": `Bearer {{secret "token"}}`}},
+	}, evalSecret)
+	testutil.AssertNoError(t, err)
+
+	for i := range 3 {
+		forwarder.Forward(testEvent("rid"+strconv.Itoa(i), types.EventTypeSystem, "Success"))
+	}
+	forwarder.Close()
+	// Events forwarded after close are dropped
+	forwarder.Forward(testEvent("rid_late", types.EventTypeSystem, "Success"))
+
+	mu.Lock()
+	defer mu.Unlock()
+	testutil.AssertEqualsInt(t, "received", 3, len(received))
+	testutil.AssertEqualsString(t, "first rid", "rid0", received[0].Rid)
+	testutil.AssertEqualsString(t, "operation", "reload_apps", received[0].Operation)
+	testutil.AssertEqualsBool(t, "signature", true, signatureOk)
+	if requests < 3 {
+		t.Errorf("expected a retry after the failure, got %d requests", requests)
+	}
+}
+
+func TestWebhookPermanentError(t *testing.T) {
+	var mu sync.Mutex
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	forwarder, err := NewForwarder(testutil.TestLogger(), map[string]types.AuditSinkConfig{
+		"siem": {Type: SINK_WEBHOOK, Url: server.URL, FlushIntervalMs: 10},
+	}, nil)
+	testutil.AssertNoError(t, err)
+	forwarder.Forward(testEvent("rid1", types.EventTypeSystem, "Success"))
+	time.Sleep(100 * time.Millisecond)
+	forwarder.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	testutil.AssertEqualsInt(t, "no retries for client error", 1, requests)
+}
+
+func TestSyslogSink(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	testutil.AssertNoError(t, err)
+	defer listener.Close() //nolint:errcheck
+
+	messages := make(chan string, 10)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close() //nolint:errcheck
+		reader := bufio.NewReader(conn)
+		for {
+			// Octet counting framing: "<len> <msg>"
+			lenStr, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(lenStr))
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(reader, msg); err != nil {
+				return
+			}
+			messages <- string(msg)
+		}
+	}()
+
+	forwarder, err := NewForwarder(testutil.TestLogger(), map[string]types.AuditSinkConfig{
+		"syslog": {Type: SINK_SYSLOG, Url: "tcp://" + listener.Addr().String(), FlushIntervalMs: 10,
+			EventTypes: []string{"system"}},
+	}, nil)
+	testutil.AssertNoError(t, err)
+	forwarder.Forward(testEvent("rid_http", types.EventTypeHTTP, "200"))
+	forwarder.Forward(testEvent("rid1", types.EventTypeSystem, "Success"))
+	forwarder.Forward(testEvent("rid2", types.EventTypeSystem, string(types.EventStatusFailure)))
+	forwarder.Close()
+
+	for _, want := range []struct{ prefix, rid string }{
+		{"<134>1 2025-03-01T10:00:00Z ", `"rid":"rid1"`}, // local0.info
+		{"<132>1 2025-03-01T10:00:00Z ", `"rid":"rid2"`}, // local0.warning
+	} {
+		select {
+		case msg := <-messages:
+			if !strings.HasPrefix(msg, want.prefix) || !strings.Contains(msg, " openrun ") ||
+				!strings.Contains(msg, " system - {") || !strings.Contains(msg, want.rid) {
+				t.Errorf("unexpected syslog message %q", msg)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timeout waiting for syslog message %s", want.rid)
+		}
+	}
+	select {
+	case msg := <-messages:
+		t.Errorf("http event should be filtered out, got %q", msg)
+	default:
+	}
+}
+
+func TestOTLPSink(t *testing.T) {
+	var mu sync.Mutex
+	var path string
+	var payload map[string]any
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&payload) //nolint:errcheck
+	}))
+	defer server.Close()
+
+	forwarder, err := NewForwarder(testutil.TestLogger(), map[string]types.AuditSinkConfig{
+		"otel": {Type: SINK_OTLP, Url: server.URL, FlushIntervalMs: 10},
+	}, nil)
+	testutil.AssertNoError(t, err)
+	forwarder.Forward(testEvent("rid1", types.EventTypeSystem, "Success"))
+	forwarder.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	testutil.AssertEqualsString(t, "path", otlpLogsPath, path)
+	data, _ := json.Marshal(payload)
+	for _, want := range []string{`"service.name"`, `"name":"openrun.audit"`, `"timeUnixNano":"1740823200000000000"`,
+		`"severityText":"INFO"`, `{"key":"audit.rid","value":{"stringValue":"rid1"}}`, `"body":{"stringValue":"reload_apps /myapp Success"}`} {
+		testutil.AssertStringContains(t, string(data), want)
+	}
+}
+
+func TestQueueFullDropsEvents(t *testing.T) {
+	w := newWorker(testutil.TestLogger(), "slow", types.AuditSinkConfig{QueueSize: 2}, nil)
+	// The worker is not running, the queue fills up
+	for i := range 5 {
+		w.enqueue(newRecord(testEvent("rid"+strconv.Itoa(i), types.EventTypeSystem, "Success")))
+	}
+	testutil.AssertEqualsInt(t, "queued", 2, len(w.queue))
+	testutil.AssertEqualsInt(t, "dropped", 3, int(w.dropped.Load()))
+
+	forwarder := &Forwarder{workers: []*worker{w}}
+	testutil.AssertEqualsInt(t, "dropped count", 3, int(forwarder.Dropped()["slow"]))
+}
+
+func TestSinkConfigErrors(t *testing.T) {
+	for _, test := range []struct {
+		config  types.AuditSinkConfig
+		wantErr string
+	}{
+		{types.AuditSinkConfig{Type: "kafka", Url: "https://example.com"}, `invalid type "kafka"`},
+		{types.AuditSinkConfig{Type: SINK_WEBHOOK}, "url is required"},
+		{types.AuditSinkConfig{Type: SINK_WEBHOOK, Url: "http://example.com/hook"}, "https is required"},
+		{types.AuditSinkConfig{Type: SINK_SYSLOG, Url: "http://example.com:514"}, "scheme has to be udp, tcp or tls"},
+		{types.AuditSinkConfig{Type: SINK_SYSLOG, Url: "udp://example.com"}, "port is required"},
+		{types.AuditSinkConfig{Type: SINK_SYSLOG, Url: "udp://example.com:514", Facility: "local9"}, "invalid syslog facility"},
+	} {
+		_, err := NewForwarder(testutil.TestLogger(), map[string]types.AuditSinkConfig{"test": test.config}, nil)
+		testutil.AssertErrorContains(t, err, test.wantErr)
+	}
+
+	forwarder, err := NewForwarder(testutil.TestLogger(), nil, nil)
+	if forwarder != nil || err != nil {
+		t.Fatalf("expected nil forwarder, got %v %v", forwarder, err)
+	}
+	// Methods are no-ops on nil
+	forwarder.Forward(testEvent("rid1", types.EventTypeSystem, "Success"))
+	forwarder.Close()
+}
+
+func readArchive(t *testing.T, data []byte) []Record {
+	t.Helper()
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	testutil.AssertNoError(t, err)
+	records := []Record{}
+	scanner := bufio.NewScanner(gz)
+	for scanner.Scan() {
+		var record Record
+		testutil.AssertNoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	testutil.AssertNoError(t, scanner.Err())
+	return records
+}
+
+func TestDirArchiver(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "archive")
+	archiver, err := NewArchiver(context.Background(), dir)
+	testutil.AssertNoError(t, err)
+	records := []Record{newRecord(testEvent("rid1", types.EventTypeHTTP, "200")), newRecord(testEvent("rid2", types.EventTypeHTTP, "500"))}
+	testutil.AssertNoError(t, archiver.Archive(context.Background(), "audit-http-1.jsonl.gz", records))
+
+	entries, err := os.ReadDir(dir)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "files", 1, len(entries))
+	data, err := os.ReadFile(filepath.Join(dir, "audit-http-1.jsonl.gz"))
+	testutil.AssertNoError(t, err)
+	archived := readArchive(t, data)
+	testutil.AssertEqualsInt(t, "records", 2, len(archived))
+	testutil.AssertEqualsString(t, "rid", "rid2", archived[1].Rid)
+	testutil.AssertEqualsString(t, "status", "500", archived[1].Status)
+
+	_, err = NewArchiver(context.Background(), "gs://bucket/audit")
+	testutil.AssertErrorContains(t, err, "has to be a directory or a s3:// url")
+}
+
+func TestS3Archiver(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(t.TempDir(), "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(t.TempDir(), "credentials"))
+
+	var mu sync.Mutex
+	var method, path, auth string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		method, path, auth = r.Method, r.URL.Path, r.Header.Get("Authorization")
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	archiver, err := NewArchiver(context.Background(), "s3://audit-bucket/openrun/prod?region=us-west-2&endpoint="+server.URL)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "target", "s3://audit-bucket/openrun/prod/", archiver.Target())
+	testutil.AssertNoError(t, archiver.Archive(context.Background(), "audit-system-1.jsonl.gz",
+		[]Record{newRecord(testEvent("rid1", types.EventTypeSystem, "Success"))}))
+
+	mu.Lock()
+	defer mu.Unlock()
+	testutil.AssertEqualsString(t, "method", http.MethodPut, method)
+	testutil.AssertEqualsString(t, "path", "/audit-bucket/openrun/prod/audit-system-1.jsonl.gz", path)
+	testutil.AssertStringContains(t, auth, "AWS4-HMAC-SHA256 Credential=AKIDTEST/")
+	testutil.AssertStringContains(t, auth, "/us-west-2/s3/aws4_request")
+	archived := readArchive(t, body)
+	testutil.AssertEqualsInt(t, "records", 1, len(archived))
+	testutil.AssertEqualsString(t, "rid", "rid1", archived[0].Rid)
+}
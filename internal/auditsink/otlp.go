@@ -0,0 +1,124 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package auditsink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	otlpLogsPath = "/v1/logs"
+	otlpScope    = "openrun.audit"
+
+	otlpSeverityInfo = 9
+	otlpSeverityWarn = 13
+)
+
+// otlpSink sends the events as OpenTelemetry log records, using the OTLP/HTTP protocol with
+// JSON encoding. The event fields are set as the log record attributes
+type otlpSink struct {
+	*httpSender
+}
+
+var _ Sink = (*otlpSink)(nil)
+
+func newOTLPSink(config types.AuditSinkConfig, evalSecret SecretEvalFunc) (*otlpSink, error) {
+	u, err := url.Parse(config.Url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid otlp url %s: %w", config.Url, err)
+	}
+	if u.Path == "" || u.Path == "/" {
+		// Endpoint is the collector base url, like for the telemetry config
+		u.Path = otlpLogsPath
+	}
+	sender, err := newHttpSender(u.String(), config, evalSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &otlpSink{httpSender: sender}, nil
+}
+
+type otlpValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string          `json:"timeUnixNano"`
+	ObservedTimeUnixNano string          `json:"observedTimeUnixNano"`
+	SeverityNumber       int             `json:"severityNumber"`
+	SeverityText         string          `json:"severityText"`
+	Body                 otlpValue       `json:"body"`
+	Attributes           []otlpAttribute `json:"attributes"`
+}
+
+func otlpRecord(record Record) otlpLogRecord {
+	severity, severityText := otlpSeverityInfo, "INFO"
+	if record.failed() {
+		severity, severityText = otlpSeverityWarn, "WARN"
+	}
+	attributes := []otlpAttribute{}
+	for _, attr := range [][2]string{
+		{"audit.rid", record.Rid},
+		{"audit.app_id", record.AppId},
+		{"audit.user_id", record.UserId},
+		{"audit.event_type", record.EventType},
+		{"audit.operation", record.Operation},
+		{"audit.target", record.Target},
+		{"audit.status", record.Status},
+		{"audit.detail", record.Detail},
+	} {
+		if attr[1] != "" {
+			attributes = append(attributes, otlpAttribute{Key: attr[0], Value: otlpValue{StringValue: attr[1]}})
+		}
+	}
+	timeNanos := strconv.FormatInt(record.CreateTime.UnixNano(), 10)
+	return otlpLogRecord{
+		TimeUnixNano:         timeNanos,
+		ObservedTimeUnixNano: timeNanos,
+		SeverityNumber:       severity,
+		SeverityText:         severityText,
+		Body:                 otlpValue{StringValue: strings.TrimSpace(record.Operation + " " + record.Target + " " + record.Status)},
+		Attributes:           attributes,
+	}
+}
+
+func (o *otlpSink) Send(ctx context.Context, records []Record) error {
+	logRecords := make([]otlpLogRecord, 0, len(records))
+	for _, record := range records {
+		logRecords = append(logRecords, otlpRecord(record))
+	}
+
+	request := map[string]any{
+		"resourceLogs": []any{
+			map[string]any{
+				"resource": map[string]any{
+					"attributes": []otlpAttribute{{Key: "service.name", Value: otlpValue{StringValue: "openrun"}}},
+				},
+				"scopeLogs": []any{
+					map[string]any{
+						"scope":      map[string]any{"name": otlpScope},
+						"logRecords": logRecords,
+					},
+				},
+			},
+		},
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return permanentError{err}
+	}
+	return o.post(ctx, "application/json", body, nil)
+}
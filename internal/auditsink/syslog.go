@@ -0,0 +1,166 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package auditsink
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5, "lpr": 6, "news": 7,
+	"uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19, "local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+const (
+	syslogSeverityWarning = 4
+	syslogSeverityInfo    = 6
+)
+
+// syslogSink sends the events as RFC 5424 messages, with the event JSON as the message. UDP
+// sends one datagram per event, TCP and TLS use octet counting framing (RFC 6587)
+type syslogSink struct {
+	network   string // udp, tcp or tls
+	address   string
+	facility  int
+	appName   string
+	hostname  string
+	procId    string
+	tlsConfig *tls.Config
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+var _ Sink = (*syslogSink)(nil)
+
+func newSyslogSink(config types.AuditSinkConfig) (*syslogSink, error) {
+	u, err := url.Parse(config.Url)
+	if err != nil {
+		return nil, fmt.Errorf("invalid syslog url %s: %w", config.Url, err)
+	}
+	if u.Scheme != "udp" && u.Scheme != "tcp" && u.Scheme != "tls" {
+		return nil, fmt.Errorf("invalid syslog url %s, scheme has to be udp, tcp or tls", config.Url)
+	}
+	if u.Port() == "" {
+		return nil, fmt.Errorf("invalid syslog url %s, port is required", config.Url)
+	}
+
+	facility := 16 // local0
+	if config.Facility != "" {
+		var ok bool
+		if facility, ok = syslogFacilities[strings.ToLower(config.Facility)]; !ok {
+			return nil, fmt.Errorf("invalid syslog facility %s", config.Facility)
+		}
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	s := &syslogSink{
+		network:  u.Scheme,
+		address:  u.Host,
+		facility: facility,
+		appName:  config.AppName,
+		hostname: hostname,
+		procId:   strconv.Itoa(os.Getpid()),
+	}
+	if s.appName == "" {
+		s.appName = "openrun"
+	}
+	if u.Scheme == "tls" {
+		s.tlsConfig = clientTLSConfig(config.SkipVerify)
+		s.tlsConfig.ServerName = u.Hostname()
+	}
+	return s, nil
+}
+
+// format returns the RFC 5424 message for the record
+func (s *syslogSink) format(record Record) ([]byte, error) {
+	msg, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+	severity := syslogSeverityInfo
+	if record.failed() {
+		severity = syslogSeverityWarning
+	}
+	msgId := record.EventType
+	if msgId == "" {
+		msgId = "-"
+	}
+	header := fmt.Sprintf("<%d>1 %s %s %s %s %s - ", s.facility*8+severity,
+		record.CreateTime.Format(time.RFC3339Nano), s.hostname, s.appName, s.procId, msgId)
+	return append([]byte(header), msg...), nil
+}
+
+func (s *syslogSink) dial(ctx context.Context) (net.Conn, error) {
+	switch s.network {
+	case "tls":
+		dialer := &tls.Dialer{Config: s.tlsConfig}
+		return dialer.DialContext(ctx, "tcp", s.address)
+	default:
+		var dialer net.Dialer
+		return dialer.DialContext(ctx, s.network, s.address)
+	}
+}
+
+func (s *syslogSink) Send(ctx context.Context, records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.conn == nil {
+		conn, err := s.dial(ctx)
+		if err != nil {
+			return fmt.Errorf("error connecting to syslog %s: %w", s.address, err)
+		}
+		s.conn = conn
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		s.conn.SetWriteDeadline(deadline) //nolint:errcheck
+	}
+
+	for _, record := range records {
+		msg, err := s.format(record)
+		if err != nil {
+			return permanentError{err}
+		}
+		if s.network != "udp" {
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		}
+		if _, err := s.conn.Write(msg); err != nil {
+			// Reconnect on the retry. Events before the failed one were sent, a retry can
+			// duplicate those
+			s.conn.Close() //nolint:errcheck
+			s.conn = nil
+			return fmt.Errorf("error writing to syslog %s: %w", s.address, err)
+		}
+	}
+	return nil
+}
+
+func (s *syslogSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,153 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package auditsink
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const SIGNATURE_HEADER = "X-OpenRun-Signature"
+
+// httpSender posts the request body to the sink url, shared by the webhook and OTLP sinks
+type httpSender struct {
+	url        string
+	headers    map[string]string
+	evalSecret SecretEvalFunc
+	client     *http.Client
+}
+
+func newHttpSender(sinkUrl string, config types.AuditSinkConfig, evalSecret SecretEvalFunc) (*httpSender, error) {
+	u, err := url.Parse(sinkUrl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid url %s: %w", sinkUrl, err)
+	}
+	if u.Scheme != "https" && (u.Scheme != "http" || !isLoopbackHost(u.Hostname())) {
+		return nil, fmt.Errorf("invalid url %s, https is required (http is allowed for localhost only)", sinkUrl)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = clientTLSConfig(config.SkipVerify)
+	return &httpSender{
+		url:        sinkUrl,
+		headers:    config.Headers,
+		evalSecret: evalSecret,
+		client:     &http.Client{Transport: transport},
+	}, nil
+}
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// clientTLSConfig returns a copy of the TLS config of the default HTTP transport, which has the
+// server TLS policy applied
+func clientTLSConfig(skipVerify bool) *tls.Config {
+	tlsConfig := &tls.Config{}
+	if base := http.DefaultTransport.(*http.Transport).TLSClientConfig; base != nil {
+		tlsConfig = base.Clone()
+	}
+	tlsConfig.InsecureSkipVerify = skipVerify //nolint:gosec
+	return tlsConfig
+}
+
+// resolve evaluates the secret references in the config value
+func (h *httpSender) resolve(value string) (string, error) {
+	if h.evalSecret == nil {
+		return value, nil
+	}
+	return h.evalSecret(value)
+}
+
+func (h *httpSender) post(ctx context.Context, contentType string, body []byte, extraHeaders map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range h.headers {
+		value, err := h.resolve(v)
+		if err != nil {
+			return fmt.Errorf("error resolving header %s: %w", k, err)
+		}
+		req.Header.Set(k, value)
+	}
+	for k, v := range extraHeaders {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= 500:
+		return fmt.Errorf("%s returned status %d: %s", h.url, resp.StatusCode, respBody)
+	default:
+		// Other client errors are not fixed by a retry
+		return permanentError{fmt.Errorf("%s returned status %d: %s", h.url, resp.StatusCode, respBody)}
+	}
+}
+
+func (h *httpSender) Close() error {
+	h.client.CloseIdleConnections()
+	return nil
+}
+
+// webhookSink posts the events as a JSON document {"events": [...]}. If a secret is
+// configured, the X-OpenRun-Signature header has the HMAC-SHA256 of the body, as sha256=<hex>
+type webhookSink struct {
+	*httpSender
+	secret string
+}
+
+var _ Sink = (*webhookSink)(nil)
+
+func newWebhookSink(config types.AuditSinkConfig, evalSecret SecretEvalFunc) (*webhookSink, error) {
+	sender, err := newHttpSender(config.Url, config, evalSecret)
+	if err != nil {
+		return nil, err
+	}
+	return &webhookSink{httpSender: sender, secret: config.Secret}, nil
+}
+
+func (w *webhookSink) Send(ctx context.Context, records []Record) error {
+	body, err := json.Marshal(map[string]any{"events": records})
+	if err != nil {
+		return permanentError{err}
+	}
+
+	var headers map[string]string
+	if w.secret != "" {
+		secret, err := w.resolve(w.secret)
+		if err != nil {
+			return fmt.Errorf("error resolving webhook secret: %w", err)
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body) //nolint:errcheck
+		headers = map[string]string{SIGNATURE_HEADER: "sha256=" + hex.EncodeToString(mac.Sum(nil))}
+	}
+	return w.post(ctx, "application/json", body, headers)
+}
@@ -4,14 +4,11 @@
 package container
 
 import (
-	"bufio"
 	"bytes"
-	"container/ring"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"maps"
 	"os"
 	"os/exec"
@@ -56,9 +53,15 @@ func acquireBuildLock(ctx context.Context, config *types.SystemConfig, buildId s
 }
 
 type CommandOptions struct {
-	Cpus   string         `mapstructure:"cpus"`
-	Memory string         `mapstructure:"memory"`
-	Other  map[string]any `mapstructure:",remain"`
+	Cpus      string         `mapstructure:"cpus"`
+	Memory    string         `mapstructure:"memory"`
+	PidsLimit string         `mapstructure:"pids_limit"`
+	Gpus      string         `mapstructure:"gpus"`     // "all", a count or "device=<ids>"
+	Devices   string         `mapstructure:"devices"`  // comma separated host device mappings
+	ShmSize   string         `mapstructure:"shm_size"` // size of /dev/shm
+	Other     map[string]any `mapstructure:",remain"`
+
+	command string // the container command name, the GPU args differ for podman
 }
 
 func parseCommandOptions(command string, options map[string]string) (CommandOptions, error) {
@@ -88,6 +91,7 @@ func parseCommandOptions(command string, options map[string]string) (CommandOpti
 	if err != nil {
 		return CommandOptions{}, err
 	}
+	ret.command = command
 	return ret, nil
 }
 
@@ -100,9 +104,13 @@ type CommandCM struct {
 	appRunDir string
 	appId     types.AppId
 	config    *types.ServerConfig
+	cli       *cliDriver      // runs the container CLI, for the operations not in the driver
+	driver    containerDriver // runs the list, build, run, stop and logs operations
+	network   string          // app network for multi-container apps, set by EnsureAppNetwork
 }
 
 var _ DevContainerManager = (*CommandCM)(nil)
+var _ ServiceManager = (*CommandCM)(nil)
 
 func NewCommandCM(logger *types.Logger, config *types.ServerConfig, appId types.AppId, appRunDir string) *CommandCM {
 	return &CommandCM{
@@ -110,6 +118,8 @@ func NewCommandCM(logger *types.Logger, config *types.ServerConfig, appId types.
 		config:    config,
 		appId:     appId,
 		appRunDir: appRunDir,
+		cli:       newCLIDriver(logger, config),
+		driver:    newContainerDriver(logger, config),
 	}
 }
 
@@ -118,7 +128,7 @@ func (k *CommandCM) SupportsInPlaceUpdate() bool {
 }
 
 func (c *CommandCM) RemoveImage(ctx context.Context, name ImageName) error {
-	cmd := exec.CommandContext(ctx, c.config.System.ContainerCommand, "rmi", string(name))
+	cmd := c.cli.cmd(ctx, "rmi", string(name))
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("error removing image: %s : %s", output, err)
@@ -131,7 +141,7 @@ func (c *CommandCM) RemoveImage(ctx context.Context, name ImageName) error {
 // cleaning up dev images left behind by image hash changes.
 func (c *CommandCM) RemoveSupersededImages(ctx context.Context, keep ImageName) error {
 	repo := string(GenImageName(c.appId, ""))
-	cmd := exec.CommandContext(ctx, c.config.System.ContainerCommand, "images",
+	cmd := c.cli.cmd(ctx, "images",
 		"--filter", "reference="+repo, "--format", "{{.Repository}}:{{.Tag}}")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -177,7 +187,13 @@ func (c *CommandCM) BuildImage(ctx context.Context, imgName ImageName, sourceUrl
 		return fmt.Errorf("invalid builder mode for command based container manager: %s", c.config.Builder.Mode)
 	}
 
-	return buildImageCommand(ctx, c.Logger, c.config, imgName, sourceUrl, containerFile, containerArgs, "", c.config.System.ContainerCommand)
+	return buildImageDriver(ctx, c.Logger, c.config, c.buildDriver(), buildSpec{
+		Image:         imgName,
+		ContextDir:    sourceUrl,
+		ContainerFile: containerFile,
+		BuildArgs:     containerArgs,
+		CacheFrom:     c.cacheFromImages(ctx, imgName),
+	})
 }
 
 // BuildImageTarget builds the image up to the named Containerfile stage
@@ -192,38 +208,44 @@ func (c *CommandCM) BuildImageTarget(ctx context.Context, imgName ImageName, sou
 		return fmt.Errorf("invalid builder mode for command based container manager: %s", c.config.Builder.Mode)
 	}
 
-	return buildImageCommand(ctx, c.Logger, c.config, imgName, sourceUrl, containerFile, containerArgs, buildTarget, c.config.System.ContainerCommand)
+	return buildImageDriver(ctx, c.Logger, c.config, c.buildDriver(), buildSpec{
+		Image:         imgName,
+		ContextDir:    sourceUrl,
+		ContainerFile: containerFile,
+		BuildArgs:     containerArgs,
+		Target:        buildTarget,
+		CacheFrom:     c.cacheFromImages(ctx, imgName),
+	})
 }
 
 func buildImageCommand(ctx context.Context, logger *types.Logger, config *types.ServerConfig,
 	imgName ImageName, sourceUrl, containerFile string, containerArgs map[string]string, buildTarget, containerCommand string) error {
-	releaseLock, err := acquireBuildLock(ctx, &config.System, string(imgName))
+	driver := &cliDriver{Logger: logger, command: containerCommand}
+	return buildImageDriver(ctx, logger, config, driver, buildSpec{
+		Image:         imgName,
+		ContextDir:    sourceUrl,
+		ContainerFile: containerFile,
+		BuildArgs:     containerArgs,
+		Target:        buildTarget,
+	})
+}
+
+// buildImageDriver builds the image using the driver, holding a build lock. The image is pushed
+// to the remote registry if one is configured
+func buildImageDriver(ctx context.Context, logger *types.Logger, config *types.ServerConfig, driver containerDriver, spec buildSpec) error {
+	releaseLock, err := acquireBuildLock(ctx, &config.System, string(spec.Image))
 	if err != nil {
 		return fmt.Errorf("error acquiring build lock: %w", err)
 	}
 	defer releaseLock()
 
-	logger.Debug().Msgf("Building image %s from %s with %s", imgName, containerFile, sourceUrl)
-	args := []string{containerCommand, "build", "-t", string(imgName), "-f", containerFile}
-	if buildTarget != "" {
-		args = append(args, "--target", buildTarget)
-	}
-
-	for k, v := range containerArgs {
-		args = append(args, "--build-arg", fmt.Sprintf("%s=%s", k, v))
-	}
-
-	args = append(args, ".")
-	cmd := exec.Command(args[0], args[1:]...)
-
-	logger.Debug().Msgf("Running command: %s", cmd.String())
-	cmd.Dir = sourceUrl
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("error building image: %s : %s", output, err)
+	spec.Buildkit = config.Builder.Buildkit
+	logger.Debug().Msgf("Building image %s from %s with %s", spec.Image, spec.ContainerFile, spec.ContextDir)
+	if err := driver.buildImage(ctx, spec); err != nil {
+		return err
 	}
 	if config.Registry.URL != "" {
-		err = pushToRemoteRegistry(ctx, logger, config, string(imgName), &config.Registry)
+		err = pushToRemoteRegistry(ctx, logger, config, string(spec.Image), &config.Registry)
 		if err != nil {
 			return fmt.Errorf("error pushing image to remote registry: %w", err)
 		}
@@ -234,7 +256,7 @@ func buildImageCommand(ctx context.Context, logger *types.Logger, config *types.
 
 func (c *CommandCM) RemoveContainer(ctx context.Context, name ContainerName) error {
 	c.Debug().Msgf("Force removing dev container %s", name)
-	cmd := exec.CommandContext(ctx, c.config.System.ContainerCommand, "rm", "--force", string(name))
+	cmd := c.cli.cmd(ctx, "rm", "--force", string(name))
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("error removing container: %s : %s", output, err)
@@ -282,7 +304,7 @@ func (c *CommandCM) getContainers(ctx context.Context, name ContainerName, getAl
 	if name != "" {
 		filters = append(filters, fmt.Sprintf("name=%s", name))
 	}
-	return c.listContainers(ctx, filters, getAll)
+	return c.driver.listContainers(ctx, filters, getAll)
 }
 
 // ListOpenRunContainers returns running containers started by THIS server
@@ -292,114 +314,12 @@ func (c *CommandCM) getContainers(ctx context.Context, name ContainerName, getAl
 // or a kubernetes-managed install whose pod containers carry the app.id
 // label too). Containers started before the label existed are not returned
 func (c *CommandCM) ListOpenRunContainers(ctx context.Context) ([]Container, error) {
-	return c.listContainers(ctx, []string{fmt.Sprintf("label=%sserver.home=%s", LABEL_PREFIX, serverHomeLabelValue())}, false)
-}
-
-// listContainers runs `<containerCommand> ps --format json` with the given
-// filters and parses the result. Handles both Podman (JSON array, Names/Ports
-// as arrays) and Docker (newline-separated JSON objects).
-func (c *CommandCM) listContainers(ctx context.Context, filters []string, getAll bool) ([]Container, error) {
-	args := []string{"ps", "--format", "json"}
-	for _, f := range filters {
-		args = append(args, "--filter", f)
-	}
-	if getAll {
-		args = append(args, "--all")
-	}
-	cmd := exec.CommandContext(ctx, c.config.System.ContainerCommand, args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return nil, fmt.Errorf("error listing containers: %s : %s", output, err)
-	}
-
-	resp := []Container{}
-	if len(output) == 0 {
-		c.Debug().Msg("No containers found")
-		return resp, nil
-	}
-
-	if output[0] == '[' { //nolint:staticcheck
-		// Podman format (Names and Ports are arrays)
-		type Port struct {
-			// only HostPort is needed
-			HostPort int `json:"host_port"`
-		}
-
-		type ContainerPodman struct {
-			ID     string            `json:"ID"`
-			Names  []string          `json:"Names"`
-			Image  string            `json:"Image"`
-			State  string            `json:"State"`
-			Status string            `json:"Status"`
-			Ports  []Port            `json:"Ports"`
-			Labels map[string]string `json:"Labels"`
-		}
-		result := []ContainerPodman{}
-
-		// JSON output (podman)
-		err = json.Unmarshal(output, &result)
-		if err != nil {
-			return nil, err
-		}
-
-		for _, c := range result {
-			port := 0
-			if len(c.Ports) > 0 {
-				port = c.Ports[0].HostPort
-			}
-			name := ""
-			if len(c.Names) > 0 {
-				name = c.Names[0]
-			}
-			resp = append(resp, Container{
-				ID:     c.ID,
-				Names:  name,
-				Image:  c.Image,
-				State:  c.State,
-				Status: c.Status,
-				Port:   port,
-				Labels: c.Labels,
-			})
-		}
-	} else if output[0] == '{' {
-		// Newline separated JSON (Docker)
-		decoder := json.NewDecoder(bytes.NewReader(output))
-		for decoder.More() {
-			var c Container
-			if err := decoder.Decode(&c); err != nil {
-				return nil, fmt.Errorf("error decoding container output: %v", err)
-			}
-
-			if c.PortString != "" {
-				// "Ports":"127.0.0.1:55000-\u003e5000/tcp"
-				_, v, ok := strings.Cut(c.PortString, ":")
-				if !ok {
-					return nil, fmt.Errorf("error parsing \":\" from port string: %s", c.PortString)
-				}
-				v, _, ok = strings.Cut(v, "-")
-				if !ok {
-					return nil, fmt.Errorf("error parsing \"-\" from port string: %s", v)
-				}
-
-				c.Port, err = strconv.Atoi(v)
-				if err != nil {
-					return nil, fmt.Errorf("error converting to int port string: %s", v)
-				}
-			}
-
-			resp = append(resp, c)
-		}
-	} else {
-		return nil, fmt.Errorf("\"%s ps\" returned unknown output: %s", c.config.System.ContainerCommand, output)
-	}
-
-	c.Debug().Msgf("Found containers: %+v", resp)
-	return resp, nil
+	return c.driver.listContainers(ctx, []string{fmt.Sprintf("label=%sserver.home=%s", LABEL_PREFIX, serverHomeLabelValue())}, false)
 }
 
 func (c *CommandCM) GetContainerLogs(ctx context.Context, name ContainerName, linesToShow int) (string, error) {
 	c.Debug().Msgf("Getting container logs %s", name)
-	lines, err := c.ExecTailN(ctx, c.config.System.ContainerCommand, []string{"logs", string(name)}, linesToShow)
+	lines, err := c.driver.containerLogs(ctx, name, linesToShow)
 	if err != nil {
 		return "", fmt.Errorf("error getting container %s logs: %s", name, err)
 	}
@@ -409,13 +329,7 @@ func (c *CommandCM) GetContainerLogs(ctx context.Context, name ContainerName, li
 
 func (c *CommandCM) StopContainer(ctx context.Context, name ContainerName) error {
 	c.Debug().Msgf("Stopping container %s", name)
-	cmd := exec.CommandContext(ctx, c.config.System.ContainerCommand, "stop", "-t", "1", string(name))
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("error stopping container: %s : %s", output, err)
-	}
-
-	return nil
+	return c.driver.stopContainer(ctx, name, 1)
 }
 
 // StopAppContainersExcept stops all running containers of the given app other
@@ -424,7 +338,7 @@ func (c *CommandCM) StopContainer(ctx context.Context, name ContainerName) error
 // superseded versions at operation commit instead of leaving them for the
 // periodic stale container sweeper.
 func (c *CommandCM) StopAppContainersExcept(ctx context.Context, appId types.AppId, keep ContainerName) error {
-	containers, err := c.listContainers(ctx, []string{fmt.Sprintf("label=%sapp.id=%s", LABEL_PREFIX, appId)}, false)
+	containers, err := c.driver.listContainers(ctx, []string{fmt.Sprintf("label=%sapp.id=%s", LABEL_PREFIX, appId)}, false)
 	if err != nil {
 		return err
 	}
@@ -442,7 +356,7 @@ func (c *CommandCM) StopAppContainersExcept(ctx context.Context, appId types.App
 
 func (c *CommandCM) StartContainer(ctx context.Context, name ContainerName) error {
 	c.Debug().Msgf("Starting container %s", name)
-	cmd := exec.CommandContext(ctx, c.config.System.ContainerCommand, "start", string(name))
+	cmd := c.cli.cmd(ctx, "start", string(name))
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("error starting container: %s : %s", output, err)
@@ -490,7 +404,7 @@ func (c *CommandCM) RunDevContainer(ctx context.Context, appEntry *types.AppEntr
 // whether it carries the given run hash label, its published host port and
 // whether it is currently running, using a single listing call.
 func (c *CommandCM) GetDevContainerInfo(ctx context.Context, name ContainerName, runHash string) (bool, bool, string, bool, error) {
-	containers, err := c.listContainers(ctx, []string{"name=" + string(name)}, true)
+	containers, err := c.driver.listContainers(ctx, []string{"name=" + string(name)}, true)
 	if err != nil {
 		return false, false, "", false, fmt.Errorf("error checking dev container: %w", err)
 	}
@@ -513,7 +427,7 @@ func (c *CommandCM) GetDevContainerInfo(ctx context.Context, name ContainerName,
 // with no stop grace period, prioritizing the dev feedback loop.
 func (c *CommandCM) RestartDevContainer(ctx context.Context, name ContainerName) error {
 	c.Debug().Msgf("Restarting dev container %s", name)
-	cmd := exec.CommandContext(ctx, c.config.System.ContainerCommand, "restart", "-t", "0", string(name))
+	cmd := c.cli.cmd(ctx, "restart", "-t", "0", string(name))
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("error restarting container: %s : %s", output, err)
@@ -526,83 +440,189 @@ func (c *CommandCM) runContainer(ctx context.Context, appEntry *types.AppEntry,
 	containerOptions map[string]string, paramMap map[string]string, versionHash string, devOpts *DevRunOptions) error {
 	c.Debug().Msgf("Running container %s from image %s with port %d env %+v mountArgs %+v",
 		containerName, imageName, port, slices.Collect(maps.Keys(envMap)), volumes)
-	publish := fmt.Sprintf("127.0.0.1::%d", port)
-
-	imageUrl := string(imageName)
-	if strings.HasPrefix(string(imageName), IMAGE_NAME_PREFIX) && c.config.Registry.URL != "" {
-		if c.config.Registry.Project != "" {
-			imageUrl = c.config.Registry.URL + "/" + c.config.Registry.Project + "/" + string(imageName)
-		} else {
-			imageUrl = c.config.Registry.URL + "/" + string(imageName)
-		}
-	}
 
-	args := []string{"run", "--name", string(containerName), "--detach", "--publish", publish}
-	mountArgs, err := c.genMountArgs(sourceDir, volumes, paramMap)
+	spec := runSpec{
+		Name:       containerName,
+		Image:      c.imageUrl(imageName),
+		Port:       port,
+		Env:        envMap,
+		ExtraHosts: localhostExtraHosts(c.config.System.ContainerCommand),
+		Network:    c.network,
+	}
+	var err error
+	spec.Volumes, err = c.genMountArgs(sourceDir, volumes, paramMap)
 	if err != nil {
 		return fmt.Errorf("error generating mount args: %w", err)
 	}
-	if len(mountArgs) > 0 {
-		args = append(args, mountArgs...)
-	}
 
-	args = append(args, "--label", LABEL_PREFIX+"app.id="+string(appEntry.Id))
-	args = append(args, "--label", LABEL_PREFIX+"app.path="+appEntry.Path)
-	args = append(args, "--label", LABEL_PREFIX+"server.home="+serverHomeLabelValue())
+	spec.Labels = map[string]string{
+		LABEL_PREFIX + "app.id":      string(appEntry.Id),
+		LABEL_PREFIX + "app.path":    appEntry.Path,
+		LABEL_PREFIX + "server.home": serverHomeLabelValue(),
+	}
 	if devOpts != nil {
 		if devOpts.RunHash != "" {
-			args = append(args, "--label", fmt.Sprintf("%s%s=%s", LABEL_PREFIX, DEV_HASH_LABEL, devOpts.RunHash))
-		}
-		if devOpts.WorkDir != "" {
-			args = append(args, "--workdir", devOpts.WorkDir)
+			spec.Labels[LABEL_PREFIX+DEV_HASH_LABEL] = devOpts.RunHash
 		}
+		spec.WorkDir = devOpts.WorkDir
 		if devOpts.Command != "" {
 			// Bypass the image entrypoint so the dev command runs as specified
-			args = append(args, "--entrypoint", "sh")
+			spec.Entrypoint = "sh"
+			spec.Cmd = []string{"-c", devOpts.Command}
 		}
 	}
 	if appEntry.IsDev {
-		args = append(args, "--label", LABEL_PREFIX+"dev=true")
+		spec.Labels[LABEL_PREFIX+"dev"] = "true"
 	} else {
-		args = append(args, "--label", LABEL_PREFIX+"dev=false")
-		args = append(args, "--label", LABEL_PREFIX+"app.version="+strconv.Itoa(appEntry.Metadata.VersionMetadata.Version))
-		args = append(args, "--label", LABEL_PREFIX+"git.sha="+appEntry.Metadata.VersionMetadata.GitCommit)
-		args = append(args, "--label", LABEL_PREFIX+"git.message="+appEntry.Metadata.VersionMetadata.GitMessage)
-		args = append(args, "--label", LABEL_PREFIX+"version.hash="+versionHash)
-	}
-
-	// Add env args
-	for k, v := range envMap {
-		args = append(args, "--env", fmt.Sprintf("%s=%s", k, v))
+		spec.Labels[LABEL_PREFIX+"dev"] = "false"
+		spec.Labels[LABEL_PREFIX+"app.version"] = strconv.Itoa(appEntry.Metadata.VersionMetadata.Version)
+		spec.Labels[LABEL_PREFIX+"git.sha"] = appEntry.Metadata.VersionMetadata.GitCommit
+		spec.Labels[LABEL_PREFIX+"git.message"] = appEntry.Metadata.VersionMetadata.GitMessage
+		spec.Labels[LABEL_PREFIX+"version.hash"] = versionHash
 	}
 
 	// Add container related args
-	commandOptions, err := ParseCommandOptions(c.config.System.ContainerCommand, containerOptions)
+	spec.Options, err = ParseCommandOptions(c.config.System.ContainerCommand, containerOptions)
 	if err != nil {
 		return fmt.Errorf("error parsing command options: %w", err)
 	}
-	args = append(args, LocalhostHostGatewayArgs(c.config.System.ContainerCommand)...)
-	commandOptionArgs, err := CommandOptionArgs(commandOptions, c.config.Security.AllowedContainerArgs)
+	spec.OptionArgs, err = CommandOptionArgs(spec.Options, c.config.Security.AllowedContainerArgs)
 	if err != nil {
 		return err
 	}
-	args = append(args, commandOptionArgs...)
 
-	args = append(args, imageUrl)
-	if devOpts != nil && devOpts.Command != "" {
-		args = append(args, "-c", devOpts.Command)
+	return c.driver.runContainer(ctx, spec)
+}
+
+// imageUrl returns the image reference to run. Generated images are pulled from the registry
+// when one is configured
+func (c *CommandCM) imageUrl(imageName ImageName) string {
+	if !strings.HasPrefix(string(imageName), IMAGE_NAME_PREFIX) || c.config.Registry.URL == "" {
+		return string(imageName)
 	}
+	if c.config.Registry.Project != "" {
+		return c.config.Registry.URL + "/" + c.config.Registry.Project + "/" + string(imageName)
+	}
+	return c.config.Registry.URL + "/" + string(imageName)
+}
 
-	c.Debug().Msgf("Running container with args: %v", RedactEnvArgs(args))
-	cmd := exec.CommandContext(ctx, c.config.System.ContainerCommand, args...)
-	output, err := cmd.CombinedOutput()
+// EnsureAppNetwork creates the network shared by the app container and its services
+func (c *CommandCM) EnsureAppNetwork(ctx context.Context) error {
+	network := GenNetworkName(c.appId)
+	if _, err := c.cli.cmd(ctx, "network", "inspect", network).CombinedOutput(); err != nil {
+		c.Info().Msgf("Creating network %s for app %s", network, c.appId)
+		output, err := c.cli.cmd(ctx, "network", "create",
+			"--label", LABEL_PREFIX+"app.id="+string(c.appId),
+			"--label", LABEL_PREFIX+"server.home="+serverHomeLabelValue(),
+			network).CombinedOutput()
+		if err != nil {
+			return fmt.Errorf("error creating network %s: %s : %s", network, output, err)
+		}
+	}
+	c.network = network
+	return nil
+}
+
+// RunService starts a service container on the app network. The service containers carry the
+// service.app.id label instead of app.id, so that StopAppContainersExcept does not treat them
+// as superseded versions of the app container
+func (c *CommandCM) RunService(ctx context.Context, appEntry *types.AppEntry, service ServiceSpec) error {
+	if c.network == "" {
+		return fmt.Errorf("app network not created for service %s", service.Name)
+	}
+	containers, err := c.getContainers(ctx, service.ContainerName, true)
 	if err != nil {
-		return fmt.Errorf("error running container: %s : %s", output, err)
+		return fmt.Errorf("error getting service containers: %w", err)
+	}
+	for _, cont := range containers {
+		// The name filter is a substring match, verify exact name
+		if cont.Names != string(service.ContainerName) {
+			continue
+		}
+		if strings.EqualFold(cont.State, "running") {
+			return nil
+		}
+		return c.StartContainer(ctx, service.ContainerName)
+	}
+
+	spec := runSpec{
+		Name:           service.ContainerName,
+		Image:          c.imageUrl(service.Image),
+		Env:            service.Env,
+		Cmd:            service.Command,
+		ExtraHosts:     localhostExtraHosts(c.config.System.ContainerCommand),
+		Network:        c.network,
+		NetworkAliases: []string{service.Name},
+		Labels: map[string]string{
+			LABEL_PREFIX + "service.app.id": string(appEntry.Id),
+			LABEL_PREFIX + "service.name":   service.Name,
+			LABEL_PREFIX + "app.path":       appEntry.Path,
+			LABEL_PREFIX + "server.home":    serverHomeLabelValue(),
+			LABEL_PREFIX + "version.hash":   service.VersionHash,
+		},
+	}
+	if spec.Volumes, err = c.genMountArgs("", service.Volumes, nil); err != nil {
+		return fmt.Errorf("error generating mount args: %w", err)
 	}
 
+	c.Debug().Msgf("Running service %s container %s from image %s", service.Name, service.ContainerName, spec.Image)
+	if err := c.driver.runContainer(ctx, spec); err != nil {
+		return fmt.Errorf("error running service %s: %w", service.Name, err)
+	}
 	return nil
 }
 
+// StopAppServicesExcept stops the running service containers of the app, other than keep.
+// Service containers are named with their version hash, a changed service config leaves the
+// previous container running until it is stopped here
+func (c *CommandCM) StopAppServicesExcept(ctx context.Context, keep []ContainerName) error {
+	containers, err := c.driver.listContainers(ctx, []string{fmt.Sprintf("label=%sservice.app.id=%s", LABEL_PREFIX, c.appId)}, false)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, cont := range containers {
+		name := ContainerName(cont.Names)
+		if name == "" || slices.Contains(keep, name) {
+			continue
+		}
+		c.Info().Msgf("Stopping service container %s for app %s", name, c.appId)
+		errs = append(errs, c.StopContainer(ctx, name))
+	}
+	return errors.Join(errs...)
+}
+
+// RemoveAppRuntime force removes the app and service containers of the app, including the
+// stopped ones, the generated images and the app network. Used when the app is archived, the
+// volumes are kept so that the app data is available when the app is restored
+func (c *CommandCM) RemoveAppRuntime(ctx context.Context) error {
+	var errs []error
+	for _, label := range []string{"app.id", "service.app.id"} {
+		containers, err := c.driver.listContainers(ctx, []string{fmt.Sprintf("label=%s%s=%s", LABEL_PREFIX, label, c.appId)}, true)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, cont := range containers {
+			name := ContainerName(cont.Names)
+			if name == "" {
+				continue
+			}
+			c.Info().Msgf("Removing container %s for app %s", name, c.appId)
+			errs = append(errs, c.RemoveContainer(ctx, name))
+		}
+	}
+	errs = append(errs, c.RemoveSupersededImages(ctx, ""))
+
+	network := GenNetworkName(c.appId)
+	if _, err := c.cli.cmd(ctx, "network", "inspect", network).CombinedOutput(); err == nil {
+		if output, err := c.cli.cmd(ctx, "network", "rm", network).CombinedOutput(); err != nil {
+			errs = append(errs, fmt.Errorf("error removing network %s: %s : %s", network, output, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
 func (c *CommandCM) DeployContainer(ctx context.Context, req DeployRequest) (DeployResult, error) {
 	if err := c.RunContainer(ctx, req.AppEntry, req.SourceDir, req.ContainerName,
 		req.ImageName, req.Port, req.EnvMap, req.Volumes, req.ContainerOptions, req.ParamMap,
@@ -628,12 +648,17 @@ func (c *CommandCM) DeployContainer(ctx context.Context, req DeployRequest) (Dep
 // locally rather than pulled).
 func (c *CommandCM) RefreshImage(ctx context.Context, name ImageName) (string, error) {
 	c.Debug().Msgf("Pulling image %s", name)
-	pullCmd := exec.CommandContext(ctx, c.config.System.ContainerCommand, "pull", string(name))
+	pullCmd := c.cli.cmd(ctx, "pull", string(name))
+	cleanup, err := WithRegistryAuth(c.config, pullCmd, string(name))
+	defer cleanup()
+	if err != nil {
+		return "", err
+	}
 	if output, err := pullCmd.CombinedOutput(); err != nil {
 		return "", fmt.Errorf("error pulling image %s: %s : %w", name, output, err)
 	}
 
-	inspectCmd := exec.CommandContext(ctx, c.config.System.ContainerCommand,
+	inspectCmd := c.cli.cmd(ctx,
 		"image", "inspect",
 		"--format", "{{if .RepoDigests}}{{index .RepoDigests 0}}{{else}}{{.Id}}{{end}}",
 		string(name))
@@ -657,6 +682,37 @@ func (c *CommandCM) RefreshImage(ctx context.Context, name ImageName) (string, e
 	return value, nil
 }
 
+var _ ImagePortInspector = (*CommandCM)(nil)
+
+// ImageExposedPorts returns the exposed ports from the image config. The image
+// is pulled if it is not present locally
+func (c *CommandCM) ImageExposedPorts(ctx context.Context, name ImageName) ([]string, error) {
+	inspect := func() ([]byte, error) {
+		inspectCmd := c.cli.cmd(ctx,
+			"image", "inspect", "--format", "{{json .Config.ExposedPorts}}", string(name))
+		return inspectCmd.CombinedOutput()
+	}
+
+	output, err := inspect()
+	if err != nil {
+		c.Debug().Msgf("Pulling image %s to read exposed ports", name)
+		pullCmd := c.cli.cmd(ctx, "pull", string(name))
+		if pullOutput, err := pullCmd.CombinedOutput(); err != nil {
+			return nil, fmt.Errorf("error pulling image %s: %s : %w", name, pullOutput, err)
+		}
+		if output, err = inspect(); err != nil {
+			return nil, fmt.Errorf("error inspecting image %s: %s : %w", name, output, err)
+		}
+	}
+
+	// Output is like {"8080/tcp":{}}, null if the image does not expose any ports
+	exposed := map[string]any{}
+	if err := json.Unmarshal(bytes.TrimSpace(output), &exposed); err != nil {
+		return nil, fmt.Errorf("error parsing exposed ports for image %s: %s : %w", name, output, err)
+	}
+	return slices.Sorted(maps.Keys(exposed)), nil
+}
+
 func (c *CommandCM) ImageExists(ctx context.Context, name ImageName) (bool, error) {
 	if c.config.Registry.URL != "" {
 		return ImageExists(ctx, c.Logger, string(name), &c.config.Registry)
@@ -664,7 +720,7 @@ func (c *CommandCM) ImageExists(ctx context.Context, name ImageName) (bool, erro
 
 	c.Debug().Msgf("Getting images with name %s", name)
 	args := []string{"image", "ls", "--quiet", string(name)}
-	cmd := exec.CommandContext(ctx, c.config.System.ContainerCommand, args...)
+	cmd := c.cli.cmd(ctx, args...)
 	output, err := cmd.Output()
 	if err != nil {
 		var stderr string
@@ -682,57 +738,12 @@ func (c *CommandCM) ImageExists(ctx context.Context, name ImageName) (bool, erro
 
 // ExecTailN executes a command and returns the last n lines of output
 func (c *CommandCM) ExecTailN(ctx context.Context, command string, args []string, n int) ([]string, error) {
-	cmd := exec.CommandContext(ctx, command, args...)
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		return nil, fmt.Errorf("error creating stdout pipe: %s", err)
-	}
-
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return nil, fmt.Errorf("error creating stderr pipe: %s", err)
-	}
-
-	if err := cmd.Start(); err != nil {
-		return nil, fmt.Errorf("error starting command: %s", err)
-	}
-
-	multi := bufio.NewReader(io.MultiReader(stdout, stderr))
-
-	// Create a ring buffer to hold the last 1000 lines of output
-	ringBuffer := ring.New(n)
-
-	scanner := bufio.NewScanner(multi)
-	for scanner.Scan() {
-		// Push the latest line into the ring buffer, displacing the oldest line if necessary
-		ringBuffer.Value = scanner.Text()
-		ringBuffer = ringBuffer.Next()
-	}
-
-	if err := scanner.Err(); err != nil {
-		// Reap the process before returning so it does not linger as a zombie
-		_ = cmd.Process.Kill()
-		_ = cmd.Wait()
-		return nil, fmt.Errorf("error scanning output: %s", err)
-	}
-
-	if err := cmd.Wait(); err != nil {
-		return nil, fmt.Errorf("error waiting for command: %s", err)
-	}
-
-	ret := make([]string, 0, n)
-	ringBuffer.Do(func(p any) {
-		if line, ok := p.(string); ok {
-			ret = append(ret, line)
-		}
-	})
-
-	return ret, nil
+	return execTailN(exec.CommandContext(ctx, command, args...), n)
 }
 
 func (c CommandCM) VolumeExists(ctx context.Context, name VolumeName) bool {
 	c.Debug().Msgf("Checking volume exists %s", name)
-	cmd := exec.CommandContext(ctx, c.config.System.ContainerCommand, "volume", "inspect", string(name))
+	cmd := c.cli.cmd(ctx, "volume", "inspect", string(name))
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		c.Debug().Msgf("volume exists check failed %s %s %s", name, err, output)
@@ -743,7 +754,7 @@ func (c CommandCM) VolumeExists(ctx context.Context, name VolumeName) bool {
 
 func (c CommandCM) VolumeCreate(ctx context.Context, name VolumeName) error {
 	c.Debug().Msgf("Creating volume %s", name)
-	cmd := exec.Command(c.config.System.ContainerCommand, "volume", "create", string(name))
+	cmd := c.cli.cmd(context.Background(), "volume", "create", string(name))
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("error creating volume %s: %w %s", name, err, output)
@@ -751,6 +762,7 @@ func (c CommandCM) VolumeCreate(ctx context.Context, name VolumeName) error {
 	return nil
 }
 
+// genMountArgs returns the volume specs for the mounts, in the "source:target[:ro]" format
 func (c *CommandCM) genMountArgs(sourceDir string, volumeInfo []*VolumeInfo, paramMap map[string]string) ([]string, error) {
 	args := make([]string, 0, len(volumeInfo))
 
@@ -774,7 +786,7 @@ func (c *CommandCM) genMountArgs(sourceDir string, volumeInfo []*VolumeInfo, par
 				volStr += ":ro"
 			}
 			c.Info().Msgf("Mounting secret %s for app %s src %s dest %s", volStr, c.appId, srcFile, destFile)
-			args = append(args, fmt.Sprintf("--volume=%s", volStr))
+			args = append(args, volStr)
 			continue
 		}
 
@@ -785,7 +797,7 @@ func (c *CommandCM) genMountArgs(sourceDir string, volumeInfo []*VolumeInfo, par
 			if volInfo.ReadOnly {
 				volStr += ":ro"
 			}
-			args = append(args, fmt.Sprintf("--volume=%s", volStr))
+			args = append(args, volStr)
 			continue
 		}
 
@@ -806,7 +818,7 @@ func (c *CommandCM) genMountArgs(sourceDir string, volumeInfo []*VolumeInfo, par
 		}
 
 		c.Info().Msgf("Mounting volume %s for app %s dir %s, mount arg %s", genVolumeName, c.appId, dir, volStr)
-		args = append(args, fmt.Sprintf("--volume=%s", volStr))
+		args = append(args, volStr)
 	}
 	return args, nil
 }
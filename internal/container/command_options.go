@@ -39,10 +39,20 @@ func LocalhostBindingHostname(containerCommand string) string {
 // LocalhostHostGatewayArgs returns runtime args needed for host.docker.internal
 // to resolve inside Docker app containers.
 func LocalhostHostGatewayArgs(containerCommand string) []string {
+	hosts := localhostExtraHosts(containerCommand)
+	if len(hosts) == 0 {
+		return nil
+	}
+	return []string{"--add-host", hosts[0]}
+}
+
+// localhostExtraHosts returns the hosts entries needed for host.docker.internal to resolve
+// inside Docker app containers
+func localhostExtraHosts(containerCommand string) []string {
 	if containerCommandName(containerCommand) != DOCKER_COMMAND {
 		return nil
 	}
-	return []string{"--add-host", DockerLocalhostBindingHostname + ":" + dockerHostGatewayTarget}
+	return []string{DockerLocalhostBindingHostname + ":" + dockerHostGatewayTarget}
 }
 
 func containerCommandName(containerCommand string) string {
@@ -73,6 +83,18 @@ func CommandOptionArgs(options CommandOptions, allowedContainerArgs map[string]s
 		}
 		args = append(args, "--memory", memory)
 	}
+	if options.PidsLimit != "" {
+		pids, err := PidsString(options.PidsLimit)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "--pids-limit", pids)
+	}
+	deviceArgs, err := deviceOptionArgs(options)
+	if err != nil {
+		return nil, err
+	}
+	args = append(args, deviceArgs...)
 
 	otherArgs, err := commandOtherOptionArgs(options.Other, allowedContainerArgs)
 	if err != nil {
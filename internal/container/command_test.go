@@ -504,14 +504,15 @@ func TestCommandOptionArgsRejectsWrongValue(t *testing.T) {
 
 func TestCommandOptionArgsParsesBuiltInLimits(t *testing.T) {
 	got, err := CommandOptionArgs(CommandOptions{
-		Cpus:   "500m",
-		Memory: "512m",
+		Cpus:      "500m",
+		Memory:    "512m",
+		PidsLimit: "100",
 	}, nil)
 	if err != nil {
 		t.Fatalf("CommandOptionArgs returned error: %v", err)
 	}
 
-	want := []string{"--cpus", "0.5", "--memory", "536870912"}
+	want := []string{"--cpus", "0.5", "--memory", "536870912", "--pids-limit", "100"}
 	if !slices.Equal(got, want) {
 		t.Fatalf("CommandOptionArgs = %#v, want %#v", got, want)
 	}
@@ -527,6 +528,11 @@ func TestCommandOptionArgsRejectsInvalidBuiltInLimits(t *testing.T) {
 	if err == nil || !strings.Contains(err.Error(), "error parsing memory value") {
 		t.Fatalf("CommandOptionArgs memory error = %v, want memory parse error", err)
 	}
+
+	_, err = CommandOptionArgs(CommandOptions{PidsLimit: "-1"}, nil)
+	if err == nil || !strings.Contains(err.Error(), "invalid pids limit") {
+		t.Fatalf("CommandOptionArgs pids error = %v, want pids parse error", err)
+	}
 }
 
 func TestParseCommandOptionsKeepsBuiltInLimitsOutOfOther(t *testing.T) {
@@ -0,0 +1,185 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package container
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	// GPUS_ALL requests all the GPUs on the host
+	GPUS_ALL = "all"
+	// podmanGpuDevicePrefix is the CDI device name for NVIDIA GPUs, podman maps GPUs as CDI devices
+	podmanGpuDevicePrefix = "nvidia.com/gpu="
+)
+
+var reGpuDeviceId = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// GpuRequest is the parsed gpus container option: all the GPUs, a count of GPUs or
+// the specific GPU device ids (indexes or UUIDs)
+type GpuRequest struct {
+	All       bool
+	Count     int
+	DeviceIds []string
+}
+
+// ParseGpus parses the gpus container option, which can be "all", a count like "2" or the device
+// ids like "device=0,1", the same as the docker --gpus flag
+func ParseGpus(value string) (GpuRequest, error) {
+	in := strings.TrimSpace(value)
+	if in == GPUS_ALL {
+		return GpuRequest{All: true}, nil
+	}
+	if ids, ok := strings.CutPrefix(in, "device="); ok {
+		ret := GpuRequest{}
+		for _, id := range strings.Split(ids, ",") {
+			id = strings.TrimSpace(id)
+			if !reGpuDeviceId.MatchString(id) {
+				return GpuRequest{}, fmt.Errorf("invalid gpu device id %q in gpus value %q", id, value)
+			}
+			ret.DeviceIds = append(ret.DeviceIds, id)
+		}
+		return ret, nil
+	}
+	count, err := strconv.Atoi(in)
+	if err != nil || count <= 0 {
+		return GpuRequest{}, fmt.Errorf("invalid gpus value %q, use all, a positive count or device=<ids>", value)
+	}
+	return GpuRequest{Count: count}, nil
+}
+
+// NumGpus returns the number of GPUs requested, -1 for all the GPUs
+func (g GpuRequest) NumGpus() int {
+	if g.All {
+		return -1
+	}
+	if len(g.DeviceIds) > 0 {
+		return len(g.DeviceIds)
+	}
+	return g.Count
+}
+
+// DeviceMapping is a host device mapped into the container
+type DeviceMapping struct {
+	HostPath      string
+	ContainerPath string
+	Permissions   string // cgroup permissions, a combination of r, w and m
+}
+
+func (d DeviceMapping) String() string {
+	return d.HostPath + ":" + d.ContainerPath + ":" + d.Permissions
+}
+
+// ParseDevices parses the devices container option, a comma separated list of host device
+// mappings in the "hostPath[:containerPath][:permissions]" format, like "/dev/dri/renderD128"
+// or "/dev/fuse:/dev/fuse:rw". The host path has to be under /dev
+func ParseDevices(value string) ([]DeviceMapping, error) {
+	ret := []DeviceMapping{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) > 3 {
+			return nil, fmt.Errorf("invalid device %q, use hostPath[:containerPath][:permissions]", entry)
+		}
+		mapping := DeviceMapping{HostPath: parts[0], ContainerPath: parts[0], Permissions: "rwm"}
+		if len(parts) == 2 && isDevicePermissions(parts[1]) {
+			mapping.Permissions = parts[1]
+		} else if len(parts) >= 2 {
+			mapping.ContainerPath = parts[1]
+		}
+		if len(parts) == 3 {
+			if !isDevicePermissions(parts[2]) {
+				return nil, fmt.Errorf("invalid device permissions %q for %s, use a combination of r, w and m", parts[2], entry)
+			}
+			mapping.Permissions = parts[2]
+		}
+
+		for _, p := range []string{mapping.HostPath, mapping.ContainerPath} {
+			if !path.IsAbs(p) || path.Clean(p) != p {
+				return nil, fmt.Errorf("invalid device path %q, an absolute clean path is required", p)
+			}
+		}
+		if !strings.HasPrefix(mapping.HostPath, "/dev/") {
+			return nil, fmt.Errorf("invalid device %q, the host path has to be under /dev", mapping.HostPath)
+		}
+		ret = append(ret, mapping)
+	}
+	if len(ret) == 0 {
+		return nil, fmt.Errorf("no devices specified in %q", value)
+	}
+	return ret, nil
+}
+
+func isDevicePermissions(value string) bool {
+	if value == "" || len(value) > 3 {
+		return false
+	}
+	for _, c := range value {
+		if !strings.ContainsRune("rwm", c) || strings.Count(value, string(c)) > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// IsDeviceOption reports whether the container option key is for GPUs or host devices, including
+// the keys prefixed with the container manager name, like docker.gpus
+func IsDeviceOption(key string) bool {
+	for _, name := range []string{"gpus", "devices"} {
+		if key == name || strings.HasSuffix(key, "."+name) {
+			return true
+		}
+	}
+	return false
+}
+
+// deviceOptionArgs returns the CLI args for the gpus, devices and shm_size options. Podman
+// maps the GPUs as CDI devices, which does not support a GPU count
+func deviceOptionArgs(options CommandOptions) ([]string, error) {
+	args := []string{}
+	if options.Gpus != "" {
+		gpus, err := ParseGpus(options.Gpus)
+		if err != nil {
+			return nil, err
+		}
+		if options.command == PODMAN_COMMAND {
+			switch {
+			case gpus.All:
+				args = append(args, "--device", podmanGpuDevicePrefix+GPUS_ALL)
+			case len(gpus.DeviceIds) > 0:
+				for _, id := range gpus.DeviceIds {
+					args = append(args, "--device", podmanGpuDevicePrefix+id)
+				}
+			default:
+				return nil, fmt.Errorf("gpus count %q is not supported with podman, use all or device=<ids>", options.Gpus)
+			}
+		} else {
+			args = append(args, "--gpus", strings.TrimSpace(options.Gpus))
+		}
+	}
+	if options.Devices != "" {
+		devices, err := ParseDevices(options.Devices)
+		if err != nil {
+			return nil, err
+		}
+		for _, device := range devices {
+			args = append(args, "--device", device.String())
+		}
+	}
+	if options.ShmSize != "" {
+		shmSize, err := BytesString(options.ShmSize)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing shm_size value %q: %w", options.ShmSize, err)
+		}
+		args = append(args, "--shm-size", shmSize)
+	}
+	return args, nil
+}
@@ -0,0 +1,128 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package container
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func TestParseGpus(t *testing.T) {
+	gpus, err := ParseGpus("all")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "all", -1, gpus.NumGpus())
+
+	gpus, err = ParseGpus("2")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "count", 2, gpus.NumGpus())
+
+	gpus, err = ParseGpus("device=0,GPU-3a4b")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "devices", 2, gpus.NumGpus())
+	testutil.AssertEqualsString(t, "device id", "GPU-3a4b", gpus.DeviceIds[1])
+
+	for _, value := range []string{"", "0", "-1", "many", "device=", "device=0;rm"} {
+		if _, err := ParseGpus(value); err == nil {
+			t.Errorf("ParseGpus(%q) succeeded, want error", value)
+		}
+	}
+}
+
+func TestParseDevices(t *testing.T) {
+	devices, err := ParseDevices("/dev/dri/renderD128, /dev/fuse:rw,/dev/video0:/dev/camera:r")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "count", 3, len(devices))
+	testutil.AssertEqualsString(t, "default", "/dev/dri/renderD128:/dev/dri/renderD128:rwm", devices[0].String())
+	testutil.AssertEqualsString(t, "permissions", "/dev/fuse:/dev/fuse:rw", devices[1].String())
+	testutil.AssertEqualsString(t, "container path", "/dev/video0:/dev/camera:r", devices[2].String())
+
+	for _, value := range []string{"", "/etc/passwd", "/dev/../etc/passwd", "dev/fuse", "/dev/fuse:/dev/fuse:rx",
+		"/dev/fuse:/dev/fuse:rw:extra", "/dev/fuse:rr"} {
+		if _, err := ParseDevices(value); err == nil {
+			t.Errorf("ParseDevices(%q) succeeded, want error", value)
+		}
+	}
+}
+
+func TestIsDeviceOption(t *testing.T) {
+	for key, want := range map[string]bool{
+		"gpus":           true,
+		"docker.devices": true,
+		"shm_size":       false,
+		"cpus":           false,
+		"label":          false,
+	} {
+		testutil.AssertEqualsBool(t, key, want, IsDeviceOption(key))
+	}
+}
+
+func TestCommandOptionArgsDevices(t *testing.T) {
+	options, err := ParseCommandOptions("docker", map[string]string{
+		"gpus":     "all",
+		"devices":  "/dev/dri/renderD128",
+		"shm_size": "1g",
+	})
+	testutil.AssertNoError(t, err)
+	got, err := CommandOptionArgs(options, nil)
+	testutil.AssertNoError(t, err)
+	want := []string{"--gpus", "all", "--device", "/dev/dri/renderD128:/dev/dri/renderD128:rwm", "--shm-size", "1073741824"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("CommandOptionArgs = %#v, want %#v", got, want)
+	}
+
+	// Podman maps the GPUs as CDI devices
+	options, err = ParseCommandOptions("/usr/bin/podman", map[string]string{"podman.gpus": "device=0,1"})
+	testutil.AssertNoError(t, err)
+	got, err = CommandOptionArgs(options, nil)
+	testutil.AssertNoError(t, err)
+	want = []string{"--device", "nvidia.com/gpu=0", "--device", "nvidia.com/gpu=1"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("CommandOptionArgs podman = %#v, want %#v", got, want)
+	}
+
+	options, err = ParseCommandOptions("podman", map[string]string{"gpus": "2"})
+	testutil.AssertNoError(t, err)
+	_, err = CommandOptionArgs(options, nil)
+	testutil.AssertErrorContains(t, err, "not supported with podman")
+
+	_, err = CommandOptionArgs(CommandOptions{Devices: "/etc/shadow"}, nil)
+	testutil.AssertErrorContains(t, err, "has to be under /dev")
+}
+
+func TestAPICreateRequestDevices(t *testing.T) {
+	req, err := newAPICreateRequest(runSpec{
+		Name:  "clc-app-1",
+		Image: "ollama/ollama",
+		Options: CommandOptions{
+			Gpus:    "all",
+			Devices: "/dev/dri/renderD128:/dev/dri/renderD128:rw",
+			ShmSize: "512m",
+		},
+	})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "device requests", 1, len(req.HostConfig.DeviceRequests))
+	testutil.AssertEqualsInt(t, "gpu count", -1, req.HostConfig.DeviceRequests[0].Count)
+	testutil.AssertEqualsString(t, "gpu capability", "gpu", req.HostConfig.DeviceRequests[0].Capabilities[0][0])
+	testutil.AssertEqualsString(t, "device", "/dev/dri/renderD128", req.HostConfig.Devices[0].PathOnHost)
+	testutil.AssertEqualsString(t, "device permissions", "rw", req.HostConfig.Devices[0].CgroupPermissions)
+	testutil.AssertEqualsInt(t, "shm size", 512*1024*1024, int(req.HostConfig.ShmSize))
+
+	req, err = newAPICreateRequest(runSpec{Options: CommandOptions{Gpus: "device=1"}})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "device ids count", 0, req.HostConfig.DeviceRequests[0].Count)
+	testutil.AssertEqualsString(t, "device id", "1", req.HostConfig.DeviceRequests[0].DeviceIDs[0])
+}
+
+func TestKubernetesGpuLimit(t *testing.T) {
+	limit, err := KubernetesOptions{Gpus: "2"}.gpuLimit()
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "count", 2, limit)
+
+	_, err = KubernetesOptions{Gpus: "all"}.gpuLimit()
+	testutil.AssertErrorContains(t, err, "only a gpus count is supported")
+	_, err = KubernetesOptions{Devices: "/dev/fuse"}.gpuLimit()
+	testutil.AssertErrorContains(t, err, "not supported for Kubernetes")
+}
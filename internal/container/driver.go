@@ -0,0 +1,349 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package container
+
+import (
+	"bufio"
+	"bytes"
+	"container/ring"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"os/exec"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// containerDriver runs the container runtime operations for CommandCM. The cli driver runs the
+// docker/podman CLI, the api driver calls the Docker Engine API, which is also served by the
+// podman socket. The operations not in the driver always use the CLI
+type containerDriver interface {
+	// listContainers returns the containers matching the filters, in the CLI "--filter" format
+	// like name=x or label=key=value. With all set, stopped containers are included
+	listContainers(ctx context.Context, filters []string, all bool) ([]Container, error)
+	// buildImage builds the image from the context directory
+	buildImage(ctx context.Context, spec buildSpec) error
+	// runContainer creates and starts the container, in the background
+	runContainer(ctx context.Context, spec runSpec) error
+	// stopContainer stops the container, waiting up to timeoutSecs before it is killed
+	stopContainer(ctx context.Context, name ContainerName, timeoutSecs int) error
+	// containerLogs returns the last tail lines of the container stdout and stderr
+	containerLogs(ctx context.Context, name ContainerName, tail int) ([]string, error)
+}
+
+// buildSpec has the options for an image build
+type buildSpec struct {
+	Image         ImageName
+	ContextDir    string
+	ContainerFile string // path of the Containerfile, relative to ContextDir
+	BuildArgs     map[string]string
+	Target        string   // build up to the named stage if set
+	CacheFrom     []string // images used as the layer cache source
+	Buildkit      bool     // build with BuildKit, CLI driver only
+}
+
+// runSpec has the options for running a container
+type runSpec struct {
+	Name       ContainerName
+	Image      string
+	Port       int32    // container port, published on a random 127.0.0.1 host port. Not published if zero
+	Volumes    []string // volume specs in the "source:target[:ro]" format
+	Labels     map[string]string
+	Env        map[string]string
+	WorkDir    string
+	Entrypoint string
+	Cmd        []string
+	ExtraHosts []string // host:ip entries added to /etc/hosts
+
+	// Network is the network the container is attached to, the default network if empty.
+	// NetworkAliases are the names the container is reachable as on that network
+	Network        string
+	NetworkAliases []string
+
+	// Options has the validated container options. OptionArgs is the CLI form of the same
+	// options, used by the cli driver
+	Options    CommandOptions
+	OptionArgs []string
+}
+
+// newContainerDriver returns the driver for the configured system.container_driver
+func newContainerDriver(logger *types.Logger, config *types.ServerConfig) containerDriver {
+	if config.System.ContainerDriver == types.CONTAINER_DRIVER_API {
+		return newAPIDriver(logger, config)
+	}
+	return newCLIDriver(logger, config)
+}
+
+// ContainerHostEnvName returns the environment variable used by the container CLI for the
+// API endpoint, DOCKER_HOST for docker and CONTAINER_HOST for podman
+func ContainerHostEnvName(containerCommand string) string {
+	if containerCommandName(containerCommand) == PODMAN_COMMAND {
+		return "CONTAINER_HOST"
+	}
+	return "DOCKER_HOST"
+}
+
+// cliDriver runs the operations using the container command CLI
+type cliDriver struct {
+	*types.Logger
+	command string
+}
+
+var _ containerDriver = (*cliDriver)(nil)
+
+func newCLIDriver(logger *types.Logger, config *types.ServerConfig) *cliDriver {
+	return &cliDriver{
+		Logger:  logger,
+		command: config.System.ContainerCommand,
+	}
+}
+
+// cmd returns the container CLI command for the args
+func (d *cliDriver) cmd(ctx context.Context, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, d.command, args...)
+}
+
+// listContainers runs `<containerCommand> ps --format json` with the given
+// filters and parses the result. Handles both Podman (JSON array, Names/Ports
+// as arrays) and Docker (newline-separated JSON objects).
+func (d *cliDriver) listContainers(ctx context.Context, filters []string, getAll bool) ([]Container, error) {
+	args := []string{"ps", "--format", "json"}
+	for _, f := range filters {
+		args = append(args, "--filter", f)
+	}
+	if getAll {
+		args = append(args, "--all")
+	}
+	cmd := d.cmd(ctx, args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("error listing containers: %s : %s", output, err)
+	}
+
+	resp := []Container{}
+	if len(output) == 0 {
+		d.Debug().Msg("No containers found")
+		return resp, nil
+	}
+
+	if output[0] == '[' { //nolint:staticcheck
+		// Podman format (Names and Ports are arrays)
+		type Port struct {
+			// only HostPort is needed
+			HostPort int `json:"host_port"`
+		}
+
+		type ContainerPodman struct {
+			ID     string            `json:"ID"`
+			Names  []string          `json:"Names"`
+			Image  string            `json:"Image"`
+			State  string            `json:"State"`
+			Status string            `json:"Status"`
+			Ports  []Port            `json:"Ports"`
+			Labels map[string]string `json:"Labels"`
+		}
+		result := []ContainerPodman{}
+
+		// JSON output (podman)
+		err = json.Unmarshal(output, &result)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range result {
+			port := 0
+			if len(c.Ports) > 0 {
+				port = c.Ports[0].HostPort
+			}
+			name := ""
+			if len(c.Names) > 0 {
+				name = c.Names[0]
+			}
+			resp = append(resp, Container{
+				ID:     c.ID,
+				Names:  name,
+				Image:  c.Image,
+				State:  c.State,
+				Status: c.Status,
+				Port:   port,
+				Labels: c.Labels,
+			})
+		}
+	} else if output[0] == '{' {
+		// Newline separated JSON (Docker)
+		decoder := json.NewDecoder(bytes.NewReader(output))
+		for decoder.More() {
+			var c Container
+			if err := decoder.Decode(&c); err != nil {
+				return nil, fmt.Errorf("error decoding container output: %v", err)
+			}
+
+			if c.PortString != "" {
+				// "Ports":"127.0.0.1:55000->5000/tcp"
+				_, v, ok := strings.Cut(c.PortString, ":")
+				if !ok {
+					return nil, fmt.Errorf("error parsing \":\" from port string: %s", c.PortString)
+				}
+				v, _, ok = strings.Cut(v, "-")
+				if !ok {
+					return nil, fmt.Errorf("error parsing \"-\" from port string: %s", v)
+				}
+
+				c.Port, err = strconv.Atoi(v)
+				if err != nil {
+					return nil, fmt.Errorf("error converting to int port string: %s", v)
+				}
+			}
+
+			resp = append(resp, c)
+		}
+	} else {
+		return nil, fmt.Errorf("\"%s ps\" returned unknown output: %s", d.command, output)
+	}
+
+	d.Debug().Msgf("Found containers: %+v", resp)
+	return resp, nil
+}
+
+func (d *cliDriver) buildImage(ctx context.Context, spec buildSpec) error {
+	args := []string{"build", "-t", string(spec.Image), "-f", spec.ContainerFile}
+	if spec.Target != "" {
+		args = append(args, "--target", spec.Target)
+	}
+
+	for k, v := range spec.BuildArgs {
+		args = append(args, "--build-arg", fmt.Sprintf("%s=%s", k, v))
+	}
+	for _, image := range spec.CacheFrom {
+		args = append(args, "--cache-from", image)
+	}
+	buildkit := spec.Buildkit && containerCommandName(d.command) != PODMAN_COMMAND
+	if buildkit {
+		// Add the cache metadata to the image, for it to be usable as a cache source by the next version
+		args = append(args, "--build-arg", "BUILDKIT_INLINE_CACHE=1")
+	}
+
+	args = append(args, ".")
+	// The build is not canceled with the request context, a partial build is not useful
+	cmd := d.cmd(context.Background(), args...)
+	if buildkit {
+		cmd.Env = append(os.Environ(), "DOCKER_BUILDKIT=1")
+	}
+
+	d.Debug().Msgf("Running command: %s", cmd.String())
+	cmd.Dir = spec.ContextDir
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error building image: %s : %s", output, err)
+	}
+	return nil
+}
+
+func (d *cliDriver) runContainer(ctx context.Context, spec runSpec) error {
+	args := []string{"run", "--name", string(spec.Name), "--detach"}
+	if spec.Port > 0 {
+		args = append(args, "--publish", fmt.Sprintf("127.0.0.1::%d", spec.Port))
+	}
+	if spec.Network != "" {
+		args = append(args, "--network", spec.Network)
+		for _, alias := range spec.NetworkAliases {
+			args = append(args, "--network-alias", alias)
+		}
+	}
+	for _, volume := range spec.Volumes {
+		args = append(args, "--volume="+volume)
+	}
+	for _, k := range slices.Sorted(maps.Keys(spec.Labels)) {
+		args = append(args, "--label", k+"="+spec.Labels[k])
+	}
+	if spec.WorkDir != "" {
+		args = append(args, "--workdir", spec.WorkDir)
+	}
+	if spec.Entrypoint != "" {
+		args = append(args, "--entrypoint", spec.Entrypoint)
+	}
+	for k, v := range spec.Env {
+		args = append(args, "--env", fmt.Sprintf("%s=%s", k, v))
+	}
+	for _, host := range spec.ExtraHosts {
+		args = append(args, "--add-host", host)
+	}
+	args = append(args, spec.OptionArgs...)
+	args = append(args, spec.Image)
+	args = append(args, spec.Cmd...)
+
+	d.Debug().Msgf("Running container with args: %v", RedactEnvArgs(args))
+	output, err := d.cmd(ctx, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error running container: %s : %s", output, err)
+	}
+	return nil
+}
+
+func (d *cliDriver) stopContainer(ctx context.Context, name ContainerName, timeoutSecs int) error {
+	output, err := d.cmd(ctx, "stop", "-t", strconv.Itoa(timeoutSecs), string(name)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error stopping container: %s : %s", output, err)
+	}
+	return nil
+}
+
+func (d *cliDriver) containerLogs(ctx context.Context, name ContainerName, tail int) ([]string, error) {
+	return execTailN(d.cmd(ctx, "logs", string(name)), tail)
+}
+
+// execTailN runs the command and returns the last n lines of the stdout and stderr output
+func execTailN(cmd *exec.Cmd, n int) ([]string, error) {
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error creating stdout pipe: %s", err)
+	}
+
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("error creating stderr pipe: %s", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("error starting command: %s", err)
+	}
+
+	multi := bufio.NewReader(io.MultiReader(stdout, stderr))
+
+	// Create a ring buffer to hold the last n lines of output
+	ringBuffer := ring.New(n)
+
+	scanner := bufio.NewScanner(multi)
+	for scanner.Scan() {
+		// Push the latest line into the ring buffer, displacing the oldest line if necessary
+		ringBuffer.Value = scanner.Text()
+		ringBuffer = ringBuffer.Next()
+	}
+
+	if err := scanner.Err(); err != nil {
+		// Reap the process before returning so it does not linger as a zombie
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, fmt.Errorf("error scanning output: %s", err)
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("error waiting for command: %s", err)
+	}
+
+	ret := make([]string, 0, n)
+	ringBuffer.Do(func(p any) {
+		if line, ok := p.(string); ok {
+			ret = append(ret, line)
+		}
+	})
+
+	return ret, nil
+}
@@ -0,0 +1,789 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package container
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// dockerAPIVersion is the Engine API version used, supported by Docker 20.10 and later and by
+// the podman compat API
+const dockerAPIVersion = "v1.41"
+
+// apiClients has the http client for each container host, shared by the app managers
+var apiClients sync.Map
+
+// apiDriver runs the operations using the Docker Engine API. The API responses are typed JSON,
+// the errors from the daemon are returned with the status code
+type apiDriver struct {
+	*types.Logger
+	config  *types.ServerConfig
+	host    string
+	baseURL string
+	client  *http.Client
+	hostErr error // set if the API host is not usable, returned by every operation
+}
+
+var _ containerDriver = (*apiDriver)(nil)
+
+func newAPIDriver(logger *types.Logger, config *types.ServerConfig) *apiDriver {
+	d := &apiDriver{Logger: logger, config: config}
+	d.host, d.hostErr = resolveContainerHost(config.System.ContainerCommand, config.System.ContainerHost)
+	if d.hostErr == nil {
+		d.baseURL, d.client, d.hostErr = newAPIClient(d.host)
+	}
+	return d
+}
+
+// resolveContainerHost returns the Engine API endpoint: the configured system.container_host,
+// else DOCKER_HOST (CONTAINER_HOST for podman), else the default local socket for the runtime
+func resolveContainerHost(containerCommand, configured string) (string, error) {
+	if configured != "" {
+		return configured, nil
+	}
+
+	var sockets []string
+	if containerCommandName(containerCommand) == PODMAN_COMMAND {
+		if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+			sockets = append(sockets, filepath.Join(dir, "podman", "podman.sock"))
+		}
+		sockets = append(sockets, "/run/podman/podman.sock")
+	} else {
+		sockets = append(sockets, "/var/run/docker.sock")
+		if home, err := os.UserHomeDir(); err == nil {
+			// Docker Desktop user socket
+			sockets = append(sockets, filepath.Join(home, ".docker", "run", "docker.sock"))
+		}
+	}
+
+	if host := os.Getenv(ContainerHostEnvName(containerCommand)); host != "" {
+		return host, nil
+	}
+	for _, socket := range sockets {
+		if _, err := os.Stat(socket); err == nil {
+			return "unix://" + socket, nil
+		}
+	}
+	return "", fmt.Errorf("no container API socket found, set system.container_host for the api container driver")
+}
+
+// newAPIClient returns the base url and the client for the container host. unix, tcp, http and
+// https hosts are supported
+func newAPIClient(host string) (string, *http.Client, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid container host %q: %w", host, err)
+	}
+
+	baseURL := ""
+	switch u.Scheme {
+	case "unix":
+		// The host in the url is not used for a unix socket, any valid name works
+		baseURL = "http://docker"
+	case "tcp", "http":
+		baseURL = "http://" + u.Host
+	case "https":
+		baseURL = "https://" + u.Host
+	default:
+		return "", nil, fmt.Errorf("unsupported container host %q, expected a unix, tcp, http or https url", host)
+	}
+
+	if client, ok := apiClients.Load(host); ok {
+		return baseURL, client.(*http.Client), nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if u.Scheme == "unix" {
+		socket := u.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+	}
+	// No client timeout, builds and pulls can be long running. The calls use the request context
+	client, _ := apiClients.LoadOrStore(host, &http.Client{Transport: transport})
+	return baseURL, client.(*http.Client), nil
+}
+
+// apiError is an error response from the Engine API
+type apiError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("container API error %d: %s", e.StatusCode, e.Message)
+}
+
+// do calls the API. A response with status code 400 or above is returned as an apiError, the
+// caller has to close the body of the returned response
+func (d *apiDriver) do(ctx context.Context, method, apiPath string, query url.Values, body io.Reader, header http.Header) (*http.Response, error) {
+	if d.hostErr != nil {
+		return nil, d.hostErr
+	}
+
+	reqUrl := d.baseURL + "/" + dockerAPIVersion + apiPath
+	if len(query) > 0 {
+		reqUrl += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqUrl, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating container API request: %w", err)
+	}
+	maps.Copy(req.Header, header)
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error calling container API at %s: %w", d.host, err)
+	}
+	if resp.StatusCode >= http.StatusBadRequest {
+		defer resp.Body.Close() //nolint:errcheck
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+		var errResp struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &errResp) != nil || errResp.Message == "" {
+			errResp.Message = strings.TrimSpace(string(data))
+		}
+		return nil, &apiError{StatusCode: resp.StatusCode, Message: errResp.Message}
+	}
+	return resp, nil
+}
+
+func (d *apiDriver) listContainers(ctx context.Context, filters []string, all bool) ([]Container, error) {
+	query := url.Values{}
+	if all {
+		query.Set("all", "1")
+	}
+	if len(filters) > 0 {
+		filterMap := map[string][]string{}
+		for _, f := range filters {
+			key, value, _ := strings.Cut(f, "=")
+			filterMap[key] = append(filterMap[key], value)
+		}
+		data, err := json.Marshal(filterMap)
+		if err != nil {
+			return nil, err
+		}
+		query.Set("filters", string(data))
+	}
+
+	resp, err := d.do(ctx, http.MethodGet, "/containers/json", query, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error listing containers: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	var result []struct {
+		Id     string   `json:"Id"`
+		Names  []string `json:"Names"`
+		Image  string   `json:"Image"`
+		State  string   `json:"State"`
+		Status string   `json:"Status"`
+		Ports  []struct {
+			PublicPort int `json:"PublicPort"`
+		} `json:"Ports"`
+		Labels map[string]string `json:"Labels"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("error decoding container list: %w", err)
+	}
+
+	ret := make([]Container, 0, len(result))
+	for _, c := range result {
+		cont := Container{
+			ID:     c.Id,
+			Image:  c.Image,
+			State:  c.State,
+			Status: c.Status,
+			Labels: c.Labels,
+		}
+		if cont.Labels == nil {
+			cont.Labels = map[string]string{}
+		}
+		if len(c.Names) > 0 {
+			// The API returns the names with a leading slash
+			cont.Names = strings.TrimPrefix(c.Names[0], "/")
+		}
+		for _, p := range c.Ports {
+			if p.PublicPort > 0 {
+				cont.Port = p.PublicPort
+				break
+			}
+		}
+		ret = append(ret, cont)
+	}
+	d.Debug().Msgf("Found containers: %+v", ret)
+	return ret, nil
+}
+
+func (d *apiDriver) buildImage(ctx context.Context, spec buildSpec) error {
+	buildArgs := spec.BuildArgs
+	if buildArgs == nil {
+		buildArgs = map[string]string{}
+	}
+	buildArgsJson, err := json.Marshal(buildArgs)
+	if err != nil {
+		return err
+	}
+	query := url.Values{
+		"t":          {string(spec.Image)},
+		"dockerfile": {filepath.ToSlash(spec.ContainerFile)},
+		"buildargs":  {string(buildArgsJson)},
+		"rm":         {"1"},
+	}
+	if spec.Target != "" {
+		query.Set("target", spec.Target)
+	}
+	if len(spec.CacheFrom) > 0 {
+		cacheFromJson, err := json.Marshal(spec.CacheFrom)
+		if err != nil {
+			return err
+		}
+		query.Set("cachefrom", string(cacheFromJson))
+	}
+
+	buildContext, err := tarBuildContext(spec.ContextDir, spec.ContainerFile)
+	if err != nil {
+		return fmt.Errorf("error creating build context: %w", err)
+	}
+	defer buildContext.Close() //nolint:errcheck
+
+	d.Debug().Msgf("Building image %s using the container API", spec.Image)
+	// The build is not canceled with the request context, a partial build is not useful
+	resp, err := d.do(context.Background(), http.MethodPost, "/build", query, buildContext,
+		http.Header{"Content-Type": {"application/x-tar"}})
+	if err != nil {
+		return fmt.Errorf("error building image: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if err := readAPIProgress(resp.Body); err != nil {
+		return fmt.Errorf("error building image: %w", err)
+	}
+	return nil
+}
+
+// readAPIProgress reads the JSON message stream returned by the build and pull APIs. An error
+// message in the stream fails the operation, the recent output is included in the error
+func readAPIProgress(r io.Reader) error {
+	decoder := json.NewDecoder(r)
+	recent := []string{}
+	for {
+		var msg struct {
+			Stream      string `json:"stream"`
+			Status      string `json:"status"`
+			Error       string `json:"error"`
+			ErrorDetail struct {
+				Message string `json:"message"`
+			} `json:"errorDetail"`
+		}
+		if err := decoder.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("error reading container API output: %w", err)
+		}
+		if msg.Error != "" || msg.ErrorDetail.Message != "" {
+			return fmt.Errorf("%s : %s", strings.Join(recent, "\n"), cmp.Or(msg.ErrorDetail.Message, msg.Error))
+		}
+		if line := strings.TrimSpace(cmp.Or(msg.Stream, msg.Status)); line != "" {
+			recent = append(recent, line)
+			if len(recent) > 20 {
+				recent = recent[1:]
+			}
+		}
+	}
+}
+
+type apiPortBinding struct {
+	HostIP   string `json:"HostIp"`
+	HostPort string `json:"HostPort"`
+}
+
+type apiHostConfig struct {
+	PortBindings map[string][]apiPortBinding `json:"PortBindings,omitempty"`
+	NetworkMode  string                      `json:"NetworkMode,omitempty"`
+	Binds        []string                    `json:"Binds,omitempty"`
+	ExtraHosts   []string                    `json:"ExtraHosts,omitempty"`
+	NanoCpus     int64                       `json:"NanoCpus,omitempty"`
+	Memory       int64                       `json:"Memory,omitempty"`
+	PidsLimit    int64                       `json:"PidsLimit,omitempty"`
+
+	DeviceRequests []apiDeviceRequest `json:"DeviceRequests,omitempty"`
+	Devices        []apiDeviceMapping `json:"Devices,omitempty"`
+	ShmSize        int64              `json:"ShmSize,omitempty"`
+}
+
+// apiDeviceRequest is a GPU request, Count -1 requests all the GPUs
+type apiDeviceRequest struct {
+	Driver       string     `json:"Driver,omitempty"`
+	Count        int        `json:"Count,omitempty"`
+	DeviceIDs    []string   `json:"DeviceIDs,omitempty"`
+	Capabilities [][]string `json:"Capabilities"`
+}
+
+type apiDeviceMapping struct {
+	PathOnHost        string `json:"PathOnHost"`
+	PathInContainer   string `json:"PathInContainer"`
+	CgroupPermissions string `json:"CgroupPermissions"`
+}
+
+type apiCreateRequest struct {
+	Image        string              `json:"Image"`
+	Env          []string            `json:"Env,omitempty"`
+	Labels       map[string]string   `json:"Labels,omitempty"`
+	WorkingDir   string              `json:"WorkingDir,omitempty"`
+	Entrypoint   []string            `json:"Entrypoint,omitempty"`
+	Cmd          []string            `json:"Cmd,omitempty"`
+	ExposedPorts map[string]struct{} `json:"ExposedPorts,omitempty"`
+	HostConfig   apiHostConfig       `json:"HostConfig"`
+
+	NetworkingConfig *apiNetworkingConfig `json:"NetworkingConfig,omitempty"`
+}
+
+type apiEndpointConfig struct {
+	Aliases []string `json:"Aliases,omitempty"`
+}
+
+type apiNetworkingConfig struct {
+	EndpointsConfig map[string]apiEndpointConfig `json:"EndpointsConfig"`
+}
+
+// newAPICreateRequest returns the container create request for the run spec. The cpus, memory,
+// pids_limit, gpus, devices and shm_size options are supported, other container options are CLI
+// args which have no API form
+func newAPICreateRequest(spec runSpec) (*apiCreateRequest, error) {
+	if len(spec.Options.Other) > 0 {
+		return nil, fmt.Errorf("container options %s are not supported with the api container driver",
+			strings.Join(slices.Sorted(maps.Keys(spec.Options.Other)), ", "))
+	}
+
+	req := &apiCreateRequest{
+		Image:      spec.Image,
+		Labels:     spec.Labels,
+		WorkingDir: spec.WorkDir,
+		Cmd:        spec.Cmd,
+		HostConfig: apiHostConfig{
+			Binds:      spec.Volumes,
+			ExtraHosts: spec.ExtraHosts,
+		},
+	}
+	if spec.Port > 0 {
+		port := fmt.Sprintf("%d/tcp", spec.Port)
+		req.ExposedPorts = map[string]struct{}{port: {}}
+		// An empty host port publishes on a random port, like "127.0.0.1::port" for the CLI
+		req.HostConfig.PortBindings = map[string][]apiPortBinding{port: {{HostIP: "127.0.0.1"}}}
+	}
+	if spec.Network != "" {
+		req.HostConfig.NetworkMode = spec.Network
+		req.NetworkingConfig = &apiNetworkingConfig{
+			EndpointsConfig: map[string]apiEndpointConfig{spec.Network: {Aliases: spec.NetworkAliases}},
+		}
+	}
+	if spec.Entrypoint != "" {
+		req.Entrypoint = []string{spec.Entrypoint}
+	}
+	for _, k := range slices.Sorted(maps.Keys(spec.Env)) {
+		req.Env = append(req.Env, k+"="+spec.Env[k])
+	}
+
+	if spec.Options.Cpus != "" {
+		milli, err := CPUString(spec.Options.Cpus, false)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing cpus value %q: %w", spec.Options.Cpus, err)
+		}
+		millicores, err := strconv.ParseInt(milli, 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		req.HostConfig.NanoCpus = millicores * 1_000_000
+	}
+	if spec.Options.Memory != "" {
+		memory, err := BytesString(spec.Options.Memory)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing memory value %q: %w", spec.Options.Memory, err)
+		}
+		if req.HostConfig.Memory, err = strconv.ParseInt(memory, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	if spec.Options.PidsLimit != "" {
+		pids, err := PidsString(spec.Options.PidsLimit)
+		if err != nil {
+			return nil, err
+		}
+		if req.HostConfig.PidsLimit, err = strconv.ParseInt(pids, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	if spec.Options.Gpus != "" {
+		gpus, err := ParseGpus(spec.Options.Gpus)
+		if err != nil {
+			return nil, err
+		}
+		request := apiDeviceRequest{DeviceIDs: gpus.DeviceIds, Capabilities: [][]string{{"gpu"}}}
+		if len(gpus.DeviceIds) == 0 {
+			request.Count = gpus.NumGpus()
+		}
+		req.HostConfig.DeviceRequests = []apiDeviceRequest{request}
+	}
+	if spec.Options.Devices != "" {
+		devices, err := ParseDevices(spec.Options.Devices)
+		if err != nil {
+			return nil, err
+		}
+		for _, device := range devices {
+			req.HostConfig.Devices = append(req.HostConfig.Devices, apiDeviceMapping{
+				PathOnHost:        device.HostPath,
+				PathInContainer:   device.ContainerPath,
+				CgroupPermissions: device.Permissions,
+			})
+		}
+	}
+	if spec.Options.ShmSize != "" {
+		shmSize, err := BytesString(spec.Options.ShmSize)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing shm_size value %q: %w", spec.Options.ShmSize, err)
+		}
+		if req.HostConfig.ShmSize, err = strconv.ParseInt(shmSize, 10, 64); err != nil {
+			return nil, err
+		}
+	}
+	return req, nil
+}
+
+func (d *apiDriver) runContainer(ctx context.Context, spec runSpec) error {
+	createReq, err := newAPICreateRequest(spec)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(createReq)
+	if err != nil {
+		return err
+	}
+
+	d.Debug().Msgf("Creating container %s from image %s using the container API", spec.Name, spec.Image)
+	query := url.Values{"name": {string(spec.Name)}}
+	header := http.Header{"Content-Type": {"application/json"}}
+	resp, err := d.do(ctx, http.MethodPost, "/containers/create", query, bytes.NewReader(body), header)
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+		// The image is not available locally, pull it and retry, like the CLI run does
+		if err := d.pullImage(ctx, spec.Image); err != nil {
+			return err
+		}
+		resp, err = d.do(ctx, http.MethodPost, "/containers/create", query, bytes.NewReader(body), header)
+	}
+	if err != nil {
+		return fmt.Errorf("error creating container: %w", err)
+	}
+	_ = resp.Body.Close()
+
+	resp, err = d.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(string(spec.Name))+"/start", nil, nil, nil)
+	if err != nil {
+		return fmt.Errorf("error starting container: %w", err)
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+// pullImage pulls the image. The configured registry credentials are used for images from the
+// registry, other images are pulled using the credentials available to the daemon
+func (d *apiDriver) pullImage(ctx context.Context, image string) error {
+	d.Debug().Msgf("Pulling image %s using the container API", image)
+	query := url.Values{"fromImage": {image}}
+	if !imageHasTagOrDigest(image) {
+		// Without a tag, the API pulls all the tags for the image
+		query.Set("tag", "latest")
+	}
+	header := http.Header{}
+	if auth := d.registryAuth(image); auth != "" {
+		header.Set("X-Registry-Auth", auth)
+	}
+
+	resp, err := d.do(ctx, http.MethodPost, "/images/create", query, nil, header)
+	if err != nil {
+		return fmt.Errorf("error pulling image %s: %w", image, err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if err := readAPIProgress(resp.Body); err != nil {
+		return fmt.Errorf("error pulling image %s: %w", image, err)
+	}
+	return nil
+}
+
+func imageHasTagOrDigest(image string) bool {
+	if strings.Contains(image, "@") {
+		return true
+	}
+	lastPart := image[strings.LastIndex(image, "/")+1:]
+	return strings.Contains(lastPart, ":")
+}
+
+// registryAuth returns the X-Registry-Auth header value for an image from the configured
+// registry, "" if the registry config does not apply or has no credentials
+func (d *apiDriver) registryAuth(image string) string {
+	registry := &d.config.Registry
+	if registry.URL == "" || !imageUsesRegistryConfig(image, registry) {
+		return ""
+	}
+	auth, err := getAuthFromRegistryConfig(registry)
+	if err != nil {
+		d.Warn().Err(err).Msg("error reading registry credentials for image pull")
+		return ""
+	}
+	if auth == nil {
+		return ""
+	}
+	host, err := mustHost(registry.URL)
+	if err != nil {
+		return ""
+	}
+	data, err := json.Marshal(map[string]string{
+		"username":      auth.Username,
+		"password":      auth.Password,
+		"serveraddress": host,
+	})
+	if err != nil {
+		return ""
+	}
+	return base64.URLEncoding.EncodeToString(data)
+}
+
+func (d *apiDriver) stopContainer(ctx context.Context, name ContainerName, timeoutSecs int) error {
+	query := url.Values{"t": {strconv.Itoa(timeoutSecs)}}
+	// 304 is returned if the container is already stopped, that is not an error
+	resp, err := d.do(ctx, http.MethodPost, "/containers/"+url.PathEscape(string(name))+"/stop", query, nil, nil)
+	if err != nil {
+		return fmt.Errorf("error stopping container: %w", err)
+	}
+	_ = resp.Body.Close()
+	return nil
+}
+
+func (d *apiDriver) containerLogs(ctx context.Context, name ContainerName, tail int) ([]string, error) {
+	query := url.Values{"stdout": {"1"}, "stderr": {"1"}, "tail": {strconv.Itoa(tail)}}
+	resp, err := d.do(ctx, http.MethodGet, "/containers/"+url.PathEscape(string(name))+"/logs", query, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error getting container logs: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading container logs: %w", err)
+	}
+	output := strings.TrimRight(string(demuxLogStream(data)), "\n")
+	if output == "" {
+		return []string{}, nil
+	}
+	lines := strings.Split(output, "\n")
+	if tail > 0 && len(lines) > tail {
+		lines = lines[len(lines)-tail:]
+	}
+	return lines, nil
+}
+
+// demuxLogStream returns the log output from the multiplexed stream format used for containers
+// without a TTY, where each frame has an 8 byte header with the stream type and the frame size.
+// Output which is not multiplexed is returned as is
+func demuxLogStream(data []byte) []byte {
+	if len(data) < 8 || data[0] > 2 || data[1] != 0 || data[2] != 0 || data[3] != 0 {
+		return data
+	}
+	var out bytes.Buffer
+	for len(data) >= 8 {
+		size := int(binary.BigEndian.Uint32(data[4:8]))
+		data = data[8:]
+		size = min(size, len(data))
+		out.Write(data[:size])
+		data = data[size:]
+	}
+	return out.Bytes()
+}
+
+// tarBuildContext returns a tar stream of the build context directory for the build API. The
+// paths matching the .dockerignore patterns are skipped, like the CLI does. The Containerfile is
+// always included
+func tarBuildContext(contextDir, containerFile string) (io.ReadCloser, error) {
+	root := filepath.Clean(contextDir)
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("build context %q is not a directory", contextDir)
+	}
+	ignore, err := readDockerIgnore(root)
+	if err != nil {
+		return nil, err
+	}
+	containerFile = path.Clean(filepath.ToSlash(containerFile))
+
+	pr, pw := io.Pipe()
+	go func() {
+		err := func() error {
+			tw := tar.NewWriter(pw)
+			err := filepath.Walk(root, func(filePath string, info os.FileInfo, walkErr error) error {
+				if walkErr != nil {
+					return walkErr
+				}
+				relPath, err := filepath.Rel(root, filePath)
+				if err != nil {
+					return err
+				}
+				if relPath == "." {
+					return nil
+				}
+				relPath = filepath.ToSlash(relPath)
+				if relPath != containerFile && ignore.excludes(relPath) {
+					if info.IsDir() && !ignore.hasNegation {
+						return filepath.SkipDir
+					}
+					return nil
+				}
+
+				link := ""
+				if info.Mode()&os.ModeSymlink != 0 {
+					if link, err = os.Readlink(filePath); err != nil {
+						return err
+					}
+				}
+				hdr, err := tar.FileInfoHeader(info, link)
+				if err != nil {
+					return err
+				}
+				hdr.Name = relPath
+				if info.IsDir() {
+					hdr.Name += "/"
+				}
+				if err := tw.WriteHeader(hdr); err != nil {
+					return err
+				}
+				if !info.Mode().IsRegular() {
+					return nil
+				}
+
+				f, err := os.Open(filePath)
+				if err != nil {
+					return err
+				}
+				_, err = io.Copy(tw, f)
+				_ = f.Close()
+				return err
+			})
+			if err != nil {
+				return err
+			}
+			return tw.Close()
+		}()
+		_ = pw.CloseWithError(err)
+	}()
+	return pr, nil
+}
+
+// dockerIgnore has the .dockerignore patterns for a build context
+type dockerIgnore struct {
+	patterns    []ignorePattern
+	hasNegation bool
+}
+
+type ignorePattern struct {
+	pattern string
+	negate  bool
+}
+
+func readDockerIgnore(root string) (*dockerIgnore, error) {
+	data, err := os.ReadFile(filepath.Join(root, ".dockerignore"))
+	if errors.Is(err, fs.ErrNotExist) {
+		return &dockerIgnore{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading .dockerignore: %w", err)
+	}
+	return parseDockerIgnore(string(data)), nil
+}
+
+func parseDockerIgnore(data string) *dockerIgnore {
+	ret := &dockerIgnore{}
+	for line := range strings.SplitSeq(data, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		negate := false
+		if rest, ok := strings.CutPrefix(line, "!"); ok {
+			negate = true
+			line = strings.TrimSpace(rest)
+		}
+		line = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(line)), "/")
+		if line == "" {
+			continue
+		}
+		ret.patterns = append(ret.patterns, ignorePattern{pattern: line, negate: negate})
+		ret.hasNegation = ret.hasNegation || negate
+	}
+	return ret
+}
+
+// excludes reports whether the path is excluded from the build context. A pattern matches the
+// path or any of its parent directories, the last matching pattern wins
+func (d *dockerIgnore) excludes(relPath string) bool {
+	excluded := false
+	for _, p := range d.patterns {
+		for candidate := relPath; candidate != "."; candidate = path.Dir(candidate) {
+			if globMatch(strings.Split(p.pattern, "/"), strings.Split(candidate, "/")) {
+				excluded = !p.negate
+				break
+			}
+		}
+	}
+	return excluded
+}
+
+// globMatch matches the path elements with the pattern elements, a "**" element matches any
+// number of directories
+func globMatch(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if globMatch(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
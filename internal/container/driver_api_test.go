@@ -0,0 +1,298 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package container
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// fakeEngine is a minimal Engine API server for the driver tests
+type fakeEngine struct {
+	mu       sync.Mutex
+	images   map[string]bool
+	created  []apiCreateRequest
+	started  []string
+	stopped  []string
+	pulled   []string
+	builds   []string
+	filters  string
+	buildErr string
+}
+
+func (f *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	apiPath := strings.TrimPrefix(r.URL.Path, "/"+dockerAPIVersion)
+	switch {
+	case r.Method == http.MethodGet && apiPath == "/containers/json":
+		f.filters = r.URL.Query().Get("filters")
+		_, _ = io.WriteString(w, `[{"Id":"abc","Names":["/clc-app-1"],"Image":"img","State":"running","Status":"Up 1 minute",`+
+			`"Ports":[{"PrivatePort":5000,"Type":"tcp"},{"IP":"127.0.0.1","PrivatePort":5000,"PublicPort":49160,"Type":"tcp"}],`+
+			`"Labels":{"dev.openrun.dev.hash":"run-hash"}}]`)
+	case r.Method == http.MethodPost && apiPath == "/containers/create":
+		var req apiCreateRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		image := req.Image
+		if !imageHasTagOrDigest(image) {
+			image += ":latest"
+		}
+		if !f.images[image] {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"message":"No such image: `+req.Image+`"}`)
+			return
+		}
+		f.created = append(f.created, req)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.WriteString(w, `{"Id":"abc","Warnings":[]}`)
+	case r.Method == http.MethodPost && apiPath == "/images/create":
+		image := r.URL.Query().Get("fromImage")
+		if tag := r.URL.Query().Get("tag"); tag != "" {
+			image += ":" + tag
+		}
+		f.pulled = append(f.pulled, image)
+		f.images[image] = true
+		_, _ = io.WriteString(w, `{"status":"Pulling from library/app"}`+"\n"+`{"status":"Download complete"}`+"\n")
+	case r.Method == http.MethodPost && strings.HasSuffix(apiPath, "/start"):
+		f.started = append(f.started, strings.Split(apiPath, "/")[2])
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodPost && strings.HasSuffix(apiPath, "/stop"):
+		name := strings.Split(apiPath, "/")[2]
+		if name == "missing" {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `{"message":"No such container: missing"}`)
+			return
+		}
+		f.stopped = append(f.stopped, name+" t="+r.URL.Query().Get("t"))
+		// Already stopped
+		w.WriteHeader(http.StatusNotModified)
+	case r.Method == http.MethodGet && strings.HasSuffix(apiPath, "/logs"):
+		for i, line := range []string{"line1\n", "line2\n", "err3\n"} {
+			header := make([]byte, 8)
+			header[0] = 1
+			if i == 2 {
+				header[0] = 2
+			}
+			binary.BigEndian.PutUint32(header[4:], uint32(len(line)))
+			_, _ = w.Write(header)
+			_, _ = io.WriteString(w, line)
+		}
+	case r.Method == http.MethodPost && apiPath == "/build":
+		files := []string{}
+		tr := tar.NewReader(r.Body)
+		for {
+			hdr, err := tr.Next()
+			if err != nil {
+				break
+			}
+			files = append(files, hdr.Name)
+		}
+		f.builds = append(f.builds, r.URL.Query().Get("t")+" "+r.URL.Query().Get("dockerfile")+" "+
+			r.URL.Query().Get("target")+" "+r.URL.Query().Get("buildargs")+" "+strings.Join(files, ","))
+		_, _ = io.WriteString(w, `{"stream":"Step 1/2 : FROM alpine\n"}`+"\n")
+		if f.buildErr != "" {
+			_, _ = io.WriteString(w, `{"errorDetail":{"message":"`+f.buildErr+`"},"error":"`+f.buildErr+`"}`+"\n")
+			return
+		}
+		_, _ = io.WriteString(w, `{"stream":"Successfully built abc\n"}`+"\n")
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = io.WriteString(w, `{"message":"page not found"}`)
+	}
+}
+
+func newTestAPIDriver(t *testing.T) (*apiDriver, *fakeEngine) {
+	t.Helper()
+	engine := &fakeEngine{images: map[string]bool{}}
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+	config := &types.ServerConfig{System: types.SystemConfig{
+		ContainerCommand:    "docker",
+		ContainerDriver:     types.CONTAINER_DRIVER_API,
+		ContainerHost:       strings.Replace(server.URL, "http://", "tcp://", 1),
+		MaxConcurrentBuilds: 1,
+		MaxBuildWaitSecs:    10,
+	}}
+	return newAPIDriver(testutil.TestLogger(), config), engine
+}
+
+func TestAPIDriverListContainers(t *testing.T) {
+	driver, engine := newTestAPIDriver(t)
+	containers, err := driver.listContainers(context.Background(), []string{"name=clc-app-1", "label=dev.openrun.app.id=app_prd_1"}, true)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "count", 1, len(containers))
+	testutil.AssertEqualsString(t, "name", "clc-app-1", containers[0].Names)
+	testutil.AssertEqualsInt(t, "port", 49160, containers[0].Port)
+	testutil.AssertEqualsBool(t, "label", true, containers[0].HasLabel("dev.openrun.dev.hash", "run-hash"))
+	testutil.AssertEqualsString(t, "filters", `{"label":["dev.openrun.app.id=app_prd_1"],"name":["clc-app-1"]}`, engine.filters)
+}
+
+func TestAPIDriverRunContainerPullsMissingImage(t *testing.T) {
+	driver, engine := newTestAPIDriver(t)
+	err := driver.runContainer(context.Background(), runSpec{
+		Name:       "clc-app-1",
+		Image:      "example.com/app",
+		Port:       5000,
+		Volumes:    []string{"/data:/app/data:ro"},
+		Labels:     map[string]string{"dev.openrun.app.id": "app_prd_1"},
+		Env:        map[string]string{"B": "2", "A": "1"},
+		Entrypoint: "sh",
+		Cmd:        []string{"-c", "npm run dev"},
+		ExtraHosts: []string{"host.docker.internal:host-gateway"},
+		Options:    CommandOptions{Cpus: "500m", Memory: "512m", PidsLimit: "200"},
+	})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "pulled", "example.com/app:latest", strings.Join(engine.pulled, ","))
+	testutil.AssertEqualsString(t, "started", "clc-app-1", strings.Join(engine.started, ","))
+	testutil.AssertEqualsInt(t, "created", 1, len(engine.created))
+
+	req := engine.created[0]
+	testutil.AssertEqualsString(t, "env", "A=1,B=2", strings.Join(req.Env, ","))
+	testutil.AssertEqualsString(t, "entrypoint", "sh", strings.Join(req.Entrypoint, ","))
+	testutil.AssertEqualsString(t, "binding", "127.0.0.1", req.HostConfig.PortBindings["5000/tcp"][0].HostIP)
+	testutil.AssertEqualsString(t, "binds", "/data:/app/data:ro", strings.Join(req.HostConfig.Binds, ","))
+	testutil.AssertEqualsInt(t, "nano cpus", 500_000_000, int(req.HostConfig.NanoCpus))
+	testutil.AssertEqualsInt(t, "memory", 512*1024*1024, int(req.HostConfig.Memory))
+	testutil.AssertEqualsInt(t, "pids limit", 200, int(req.HostConfig.PidsLimit))
+
+	// CLI only container options are rejected
+	err = driver.runContainer(context.Background(), runSpec{
+		Name:    "clc-app-2",
+		Image:   "example.com/app:latest",
+		Options: CommandOptions{Other: map[string]any{"privileged": nil}},
+	})
+	testutil.AssertErrorContains(t, err, "privileged are not supported with the api container driver")
+}
+
+func TestAPIDriverRunContainerNetwork(t *testing.T) {
+	driver, engine := newTestAPIDriver(t)
+	engine.images["redis:7"] = true
+	err := driver.runContainer(context.Background(), runSpec{
+		Name:           "clc-app-redis-1",
+		Image:          "redis:7",
+		Network:        "cln-app_prd_1",
+		NetworkAliases: []string{"redis"},
+	})
+	testutil.AssertNoError(t, err)
+
+	req := engine.created[0]
+	testutil.AssertEqualsString(t, "network", "cln-app_prd_1", req.HostConfig.NetworkMode)
+	testutil.AssertEqualsString(t, "aliases", "redis", strings.Join(req.NetworkingConfig.EndpointsConfig["cln-app_prd_1"].Aliases, ","))
+	// Services are not published on the host
+	testutil.AssertEqualsInt(t, "bindings", 0, len(req.HostConfig.PortBindings))
+	testutil.AssertEqualsInt(t, "exposed", 0, len(req.ExposedPorts))
+}
+
+func TestAPIDriverStopAndLogs(t *testing.T) {
+	driver, engine := newTestAPIDriver(t)
+	testutil.AssertNoError(t, driver.stopContainer(context.Background(), "clc-app-1", 1))
+	testutil.AssertEqualsString(t, "stopped", "clc-app-1 t=1", strings.Join(engine.stopped, ","))
+
+	err := driver.stopContainer(context.Background(), "missing", 1)
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a not found api error, got %v", err)
+	}
+	testutil.AssertErrorContains(t, err, "No such container: missing")
+
+	lines, err := driver.containerLogs(context.Background(), "clc-app-1", 2)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "logs", "line2,err3", strings.Join(lines, ","))
+}
+
+func TestAPIDriverBuildImage(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"Containerfile":             "FROM alpine",
+		".dockerignore":             "# comment\nnode_modules\n**/*.log\n!keep.log\n",
+		"app.py":                    "print(1)",
+		"debug.log":                 "x",
+		"keep.log":                  "x",
+		"src/trace.log":             "x",
+		"node_modules/pkg/index.js": "x",
+	} {
+		testutil.AssertNoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0o755))
+		testutil.AssertNoError(t, os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644))
+	}
+
+	driver, engine := newTestAPIDriver(t)
+	err := buildImageDriver(context.Background(), driver.Logger, driver.config, driver, buildSpec{
+		Image:         "ofl-app:abc",
+		ContextDir:    dir,
+		ContainerFile: "Containerfile",
+		BuildArgs:     map[string]string{"VERSION": "1"},
+		Target:        "runtime",
+	})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "build", `ofl-app:abc Containerfile runtime {"VERSION":"1"} .dockerignore,Containerfile,app.py,keep.log,src/`,
+		engine.builds[0])
+
+	engine.buildErr = "RUN failed with exit code 1"
+	err = driver.buildImage(context.Background(), buildSpec{Image: "ofl-app:abc", ContextDir: dir, ContainerFile: "Containerfile"})
+	testutil.AssertErrorContains(t, err, "Step 1/2 : FROM alpine : RUN failed with exit code 1")
+}
+
+func TestDockerIgnore(t *testing.T) {
+	ignore := parseDockerIgnore("node_modules\n/dist\n**/*.pyc\n*.md\n!README.md\n")
+	tests := map[string]bool{
+		"node_modules":        true,
+		"node_modules/a/b.js": true,
+		"dist/app.js":         true,
+		"src/dist/app.js":     false,
+		"a/b/c.pyc":           true,
+		"c.pyc":               true,
+		"CHANGES.md":          true,
+		"README.md":           false,
+		"docs/guide.md":       false,
+		"src/app.py":          false,
+	}
+	for name, want := range tests {
+		testutil.AssertEqualsBool(t, name, want, ignore.excludes(name))
+	}
+	testutil.AssertEqualsBool(t, "negation", true, ignore.hasNegation)
+}
+
+func TestResolveContainerHost(t *testing.T) {
+	host, err := resolveContainerHost("docker", "tcp://10.0.0.5:2375")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "configured", "tcp://10.0.0.5:2375", host)
+
+	t.Setenv("DOCKER_HOST", "unix:///tmp/docker.sock")
+	host, err = resolveContainerHost("/usr/bin/docker", "")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "docker env", "unix:///tmp/docker.sock", host)
+
+	t.Setenv("CONTAINER_HOST", "unix:///tmp/podman.sock")
+	host, err = resolveContainerHost("/usr/bin/podman", "")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "podman env", "unix:///tmp/podman.sock", host)
+
+	_, _, err = newAPIClient("ssh://user@host")
+	testutil.AssertErrorContains(t, err, "unsupported container host")
+}
+
+func TestDemuxLogStream(t *testing.T) {
+	raw := []byte("plain output\n")
+	testutil.AssertEqualsString(t, "raw", "plain output\n", string(demuxLogStream(raw)))
+
+	frame := append([]byte{2, 0, 0, 0, 0, 0, 0, 4}, []byte("err\n")...)
+	testutil.AssertEqualsString(t, "multiplexed", "err\n", string(demuxLogStream(frame)))
+	testutil.AssertEqualsBool(t, "image tag", true, imageHasTagOrDigest("a/b:1"))
+	testutil.AssertEqualsBool(t, "registry port", false, imageHasTagOrDigest("localhost:5000/app"))
+	testutil.AssertEqualsBool(t, "digest", true, imageHasTagOrDigest("app@sha256:abc"))
+}
@@ -0,0 +1,117 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package container
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/openrundev/openrun/internal/types"
+	core "k8s.io/api/core/v1"
+	k8sscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+	utilexec "k8s.io/client-go/util/exec"
+)
+
+// ExecStreams are the streams for a command run in a container. Stdin is optional, the command
+// stdin is closed when it returns EOF
+type ExecStreams struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// execArgs returns the docker/podman CLI args for running the command in the container
+func execArgs(containerName string, command []string, stdin bool) []string {
+	args := []string{"exec"}
+	if stdin {
+		args = append(args, "-i")
+	}
+	args = append(args, containerName)
+	return append(args, command...)
+}
+
+// ExecInContainer runs the command in the running container using the docker/podman CLI and
+// returns the command exit code. An error is returned if the command could not be run
+func ExecInContainer(ctx context.Context, containerCommand, containerName string, command []string, streams ExecStreams) (int, error) {
+	if len(command) == 0 {
+		return 0, fmt.Errorf("no command specified")
+	}
+	cmd := exec.CommandContext(ctx, containerCommand, execArgs(containerName, command, streams.Stdin != nil)...)
+	cmd.Stdout = streams.Stdout
+	cmd.Stderr = streams.Stderr
+	if streams.Stdin != nil {
+		// A pipe is used instead of setting cmd.Stdin so that Wait does not block on the stdin
+		// copy when the command exits before the input ends
+		stdin, err := cmd.StdinPipe()
+		if err != nil {
+			return 0, err
+		}
+		go func() {
+			_, _ = io.Copy(stdin, streams.Stdin)
+			_ = stdin.Close()
+		}()
+	}
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+		return exitErr.ExitCode(), nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error running command in container %s: %w", containerName, err)
+	}
+	return 0, nil
+}
+
+// ExecInWorkloadPod runs the command in an OpenRun managed pod and returns the command exit code
+func ExecInWorkloadPod(ctx context.Context, config *types.ServerConfig, name string, command []string, streams ExecStreams) (int, error) {
+	if len(command) == 0 {
+		return 0, fmt.Errorf("no command specified")
+	}
+	if _, err := GetWorkloadPod(ctx, config, name); err != nil {
+		return 0, err
+	}
+	cfg, err := loadConfig()
+	if err != nil {
+		return 0, fmt.Errorf("error loading kubernetes config: %w", err)
+	}
+	client, namespace, err := newWorkloadClient(config)
+	if err != nil {
+		return 0, err
+	}
+
+	req := client.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Namespace(namespace).
+		Name(name).
+		SubResource("exec").
+		VersionedParams(&core.PodExecOptions{
+			Command: command,
+			Stdin:   streams.Stdin != nil,
+			Stdout:  true,
+			Stderr:  true,
+		}, k8sscheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(cfg, "POST", req.URL())
+	if err != nil {
+		return 0, fmt.Errorf("error creating pod executor: %w", err)
+	}
+
+	err = executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:  streams.Stdin,
+		Stdout: streams.Stdout,
+		Stderr: streams.Stderr,
+	})
+	var exitErr utilexec.ExitError
+	if errors.As(err, &exitErr) && exitErr.Exited() {
+		return exitErr.ExitStatus(), nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("error running command in pod %s: %w", name, err)
+	}
+	return 0, nil
+}
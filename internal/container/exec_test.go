@@ -0,0 +1,62 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package container
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func TestExecArgs(t *testing.T) {
+	got := execArgs("clc-app1", []string{"ls", "-l"}, false)
+	if !slices.Equal(got, []string{"exec", "clc-app1", "ls", "-l"}) {
+		t.Fatalf("execArgs = %#v", got)
+	}
+	got = execArgs("clc-app1", []string{"cat"}, true)
+	if !slices.Equal(got, []string{"exec", "-i", "clc-app1", "cat"}) {
+		t.Fatalf("execArgs stdin = %#v", got)
+	}
+}
+
+func TestExecInContainer(t *testing.T) {
+	commandPath := filepath.Join(t.TempDir(), "docker")
+	script := `#!/bin/sh
+[ "$1" = "exec" ] || exit 64
+if [ "$2" = "-i" ]; then
+	cat
+	exit 0
+fi
+echo "$@"
+echo "failed" >&2
+exit 3
+`
+	testutil.AssertNoError(t, os.WriteFile(commandPath, []byte(script), 0o755))
+
+	var stdout, stderr bytes.Buffer
+	code, err := ExecInContainer(context.Background(), commandPath, "clc-app1", []string{"ls", "/app"},
+		ExecStreams{Stdout: &stdout, Stderr: &stderr})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "exit code", 3, code)
+	testutil.AssertEqualsString(t, "stdout", "exec clc-app1 ls /app\n", stdout.String())
+	testutil.AssertEqualsString(t, "stderr", "failed\n", stderr.String())
+
+	stdout.Reset()
+	code, err = ExecInContainer(context.Background(), commandPath, "clc-app1", []string{"cat"},
+		ExecStreams{Stdin: strings.NewReader("input"), Stdout: &stdout, Stderr: &stderr})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "stdin exit code", 0, code)
+	testutil.AssertEqualsString(t, "stdin", "input", stdout.String())
+
+	_, err = ExecInContainer(context.Background(), commandPath, "clc-app1", nil, ExecStreams{})
+	testutil.AssertErrorContains(t, err, "no command specified")
+	_, err = ExecInContainer(context.Background(), filepath.Join(t.TempDir(), "missing"), "clc-app1", []string{"ls"}, ExecStreams{})
+	testutil.AssertErrorContains(t, err, "error running command in container clc-app1")
+}
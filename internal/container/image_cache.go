@@ -0,0 +1,182 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package container
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// imageCreatedLayout is the format of the image CreatedAt field for docker and podman. Podman
+// adds fractional seconds, which time.Parse accepts without them being in the layout
+const imageCreatedLayout = "2006-01-02 15:04:05 -0700 MST"
+
+// DEV_IMAGE_TAG_PREFIX is the tag prefix for the dev mode images, see RemoveSupersededImages
+const DEV_IMAGE_TAG_PREFIX = "dev-"
+
+// splitImageName returns the repository and tag for the image name. Podman reports local
+// images with a localhost/ repository prefix, which is removed
+func splitImageName(name string) (string, string) {
+	name = strings.TrimPrefix(name, "localhost/")
+	index := strings.LastIndex(name, ":")
+	if index < 0 || strings.Contains(name[index:], "/") {
+		return name, ""
+	}
+	return name[:index], name[index+1:]
+}
+
+// parseImageList parses the "images" output in the "{{.Repository}}:{{.Tag}}\t{{.ID}}\t{{.CreatedAt}}"
+// format. Untagged images are skipped
+func parseImageList(output string) ([]types.ImageVersion, error) {
+	images := []types.ImageVersion{}
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("unexpected image list output: %s", line)
+		}
+		if strings.Contains(fields[0], "<none>") {
+			continue
+		}
+		created, err := time.Parse(imageCreatedLayout, strings.TrimSpace(fields[2]))
+		if err != nil {
+			return nil, fmt.Errorf("error parsing image %s creation time: %w", fields[0], err)
+		}
+		images = append(images, types.ImageVersion{
+			Name:    strings.TrimPrefix(fields[0], "localhost/"),
+			ID:      strings.TrimPrefix(fields[1], "sha256:"),
+			Created: created,
+		})
+	}
+	return images, nil
+}
+
+// listImages returns the local images matching the reference filter
+func (c *CommandCM) listImages(ctx context.Context, reference string) ([]types.ImageVersion, error) {
+	cmd := c.cli.cmd(ctx, "images", "--filter", "reference="+reference,
+		"--format", "{{.Repository}}:{{.Tag}}\t{{.ID}}\t{{.CreatedAt}}")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("error listing images: %s : %s", output, err)
+	}
+	return parseImageList(string(output))
+}
+
+// cacheFromImages returns the most recent earlier version of the image, used as the build cache
+// source so that the unchanged layers are reused across app versions. Podman (buildah) reuses
+// the local layers without it, its --cache-from is for remote cache repositories only
+func (c *CommandCM) cacheFromImages(ctx context.Context, imgName ImageName) []string {
+	if !c.config.Builder.CacheFrom || containerCommandName(c.config.System.ContainerCommand) == PODMAN_COMMAND {
+		return nil
+	}
+	repo, _ := splitImageName(string(imgName))
+	images, err := c.listImages(ctx, repo)
+	if err != nil {
+		// The cache is an optimization, the build is done without it
+		c.Warn().Err(err).Msgf("error listing images for build cache of %s", imgName)
+		return nil
+	}
+	var previous *types.ImageVersion
+	for i := range images {
+		if images[i].Name == string(imgName) {
+			continue
+		}
+		if previous == nil || images[i].Created.After(previous.Created) {
+			previous = &images[i]
+		}
+	}
+	if previous == nil {
+		return nil
+	}
+	c.Debug().Msgf("Using image %s as build cache for %s", previous.Name, imgName)
+	return []string{previous.Name}
+}
+
+// buildDriver returns the driver used for image builds. BuildKit builds through the container
+// API need a session connection, so the CLI is used for builds when BuildKit is enabled
+func (c *CommandCM) buildDriver() containerDriver {
+	if c.config.Builder.Buildkit {
+		return c.cli
+	}
+	return c.driver
+}
+
+// selectPruneImages splits the images into the ones kept and the ones to remove. The keep most
+// recent versions of each app image are kept, the images in use by a container are always kept.
+// Dev images are managed by the dev reload and are not considered
+func selectPruneImages(images []types.ImageVersion, keep int) ([]types.ImageVersion, []types.ImageVersion) {
+	byRepo := map[string][]types.ImageVersion{}
+	for _, image := range images {
+		repo, tag := splitImageName(image.Name)
+		if strings.HasPrefix(tag, DEV_IMAGE_TAG_PREFIX) {
+			continue
+		}
+		byRepo[repo] = append(byRepo[repo], image)
+	}
+
+	kept := []types.ImageVersion{}
+	prune := []types.ImageVersion{}
+	for _, repo := range slices.Sorted(maps.Keys(byRepo)) {
+		versions := byRepo[repo]
+		slices.SortFunc(versions, func(a, b types.ImageVersion) int {
+			return cmp.Or(b.Created.Compare(a.Created), strings.Compare(a.Name, b.Name))
+		})
+		for i, image := range versions {
+			if i < keep || image.InUse {
+				kept = append(kept, image)
+			} else {
+				prune = append(prune, image)
+			}
+		}
+	}
+	return kept, prune
+}
+
+// PruneImages removes the OpenRun generated app images other than the keep most recent versions
+// of each app. Images used by a container, running or stopped, are not removed. With dryRun, the
+// images to remove are returned without removing them. A failure to remove an image is reported
+// in the image Error, the other images are still removed
+func (c *CommandCM) PruneImages(ctx context.Context, keep int, dryRun bool) (types.ImagePruneResponse, error) {
+	if keep < 1 {
+		return types.ImagePruneResponse{}, fmt.Errorf("at least one image version has to be kept: %d", keep)
+	}
+	images, err := c.listImages(ctx, IMAGE_NAME_PREFIX+"*")
+	if err != nil {
+		return types.ImagePruneResponse{}, err
+	}
+	containers, err := c.driver.listContainers(ctx, nil, true)
+	if err != nil {
+		return types.ImagePruneResponse{}, err
+	}
+	inUse := map[string]bool{}
+	for _, cont := range containers {
+		inUse[strings.TrimPrefix(strings.TrimPrefix(cont.Image, "localhost/"), "sha256:")] = true
+	}
+	for i := range images {
+		images[i].InUse = inUse[images[i].Name] || inUse[images[i].ID]
+	}
+
+	kept, prune := selectPruneImages(images, keep)
+	resp := types.ImagePruneResponse{DryRun: dryRun, Keep: keep, Kept: kept, Removed: prune}
+	if dryRun {
+		return resp, nil
+	}
+	for i := range resp.Removed {
+		c.Info().Msgf("Pruning image %s", resp.Removed[i].Name)
+		if err := c.RemoveImage(ctx, ImageName(resp.Removed[i].Name)); err != nil {
+			resp.Removed[i].Error = err.Error()
+		}
+	}
+	return resp, nil
+}
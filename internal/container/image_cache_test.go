@@ -0,0 +1,145 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package container
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestParseImageList(t *testing.T) {
+	output := "cli-app1:aaa\tsha256:111\t2026-01-02 10:00:00 +0000 UTC\n" +
+		"localhost/cli-app1:bbb\t222\t2026-01-03 10:00:00.123456789 +0000 UTC\n" +
+		"<none>:<none>\t333\t2026-01-01 10:00:00 +0000 UTC\n"
+	images, err := parseImageList(output)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "count", 2, len(images))
+	testutil.AssertEqualsString(t, "name", "cli-app1:aaa", images[0].Name)
+	testutil.AssertEqualsString(t, "id", "111", images[0].ID)
+	testutil.AssertEqualsString(t, "podman name", "cli-app1:bbb", images[1].Name)
+	testutil.AssertEqualsBool(t, "created", true, images[1].Created.After(images[0].Created))
+
+	_, err = parseImageList("cli-app1:aaa\t111\tyesterday\n")
+	testutil.AssertErrorContains(t, err, "error parsing image cli-app1:aaa creation time")
+}
+
+func TestSplitImageName(t *testing.T) {
+	for name, want := range map[string][2]string{
+		"cli-app1:aaa":               {"cli-app1", "aaa"},
+		"localhost/cli-app1:dev-aaa": {"cli-app1", "dev-aaa"},
+		"registry:5000/cli-app1":     {"registry:5000/cli-app1", ""},
+		"cli-app1":                   {"cli-app1", ""},
+	} {
+		repo, tag := splitImageName(name)
+		testutil.AssertEqualsString(t, name+" repo", want[0], repo)
+		testutil.AssertEqualsString(t, name+" tag", want[1], tag)
+	}
+}
+
+func TestSelectPruneImages(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	image := func(name string, day int, inUse bool) types.ImageVersion {
+		return types.ImageVersion{Name: name, Created: base.AddDate(0, 0, day), InUse: inUse}
+	}
+	kept, prune := selectPruneImages([]types.ImageVersion{
+		image("cli-app1:v1", 1, true),
+		image("cli-app1:v2", 2, false),
+		image("cli-app1:v3", 3, false),
+		image("cli-app1:v4", 4, false),
+		image("cli-app1:dev-x", 0, false),
+		image("cli-app2:v1", 1, false),
+	}, 2)
+
+	names := func(images []types.ImageVersion) string {
+		ret := []string{}
+		for _, image := range images {
+			ret = append(ret, image.Name)
+		}
+		return strings.Join(ret, ",")
+	}
+	// The oldest version is kept since it is in use, dev images are not pruned
+	testutil.AssertEqualsString(t, "kept", "cli-app1:v4,cli-app1:v3,cli-app1:v1,cli-app2:v1", names(kept))
+	testutil.AssertEqualsString(t, "prune", "cli-app1:v2", names(prune))
+}
+
+func TestCommandCMPruneImages(t *testing.T) {
+	dir := t.TempDir()
+	commandPath := filepath.Join(dir, "docker")
+	removed := filepath.Join(dir, "removed")
+	script := `#!/bin/sh
+case "$1" in
+images)
+	[ "$3" = "reference=cli-*" ] || exit 64
+	printf 'cli-app1:v1\t111\t2026-01-01 10:00:00 +0000 UTC\n'
+	printf 'cli-app1:v2\t222\t2026-01-02 10:00:00 +0000 UTC\n'
+	printf 'cli-app1:v3\t333\t2026-01-03 10:00:00 +0000 UTC\n'
+	printf 'cli-app1:v4\t444\t2026-01-04 10:00:00 +0000 UTC\n'
+	;;
+ps)
+	echo '{"ID":"abc","Names":"clc-app1-old","Image":"cli-app1:v1","State":"exited","Status":"Exited","Ports":""}'
+	;;
+rmi)
+	[ "$2" = "cli-app1:v2" ] || exit 65
+	echo "$2" >> ` + removed + `
+	;;
+*)
+	echo "unexpected args: $*" >&2
+	exit 66
+	;;
+esac
+`
+	testutil.AssertNoError(t, os.WriteFile(commandPath, []byte(script), 0o755))
+
+	manager := NewCommandCM(testutil.TestLogger(), &types.ServerConfig{
+		System: types.SystemConfig{ContainerCommand: commandPath},
+	}, "", "")
+
+	resp, err := manager.PruneImages(context.Background(), 2, true)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "dry run kept", 3, len(resp.Kept))
+	testutil.AssertEqualsInt(t, "dry run removed", 1, len(resp.Removed))
+	if _, err := os.Stat(removed); !os.IsNotExist(err) {
+		t.Fatalf("dry run removed images")
+	}
+
+	resp, err = manager.PruneImages(context.Background(), 2, false)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "removed", "cli-app1:v2", resp.Removed[0].Name)
+	testutil.AssertEqualsString(t, "removed error", "", resp.Removed[0].Error)
+	data, err := os.ReadFile(removed)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "rmi", "cli-app1:v2\n", string(data))
+
+	_, err = manager.PruneImages(context.Background(), 0, true)
+	testutil.AssertErrorContains(t, err, "at least one image version")
+}
+
+func TestCommandCMCacheFromImages(t *testing.T) {
+	commandPath := filepath.Join(t.TempDir(), "docker")
+	script := `#!/bin/sh
+[ "$1" = "images" ] && [ "$3" = "reference=cli-app1" ] || exit 64
+printf 'cli-app1:v1\t111\t2026-01-01 10:00:00 +0000 UTC\n'
+printf 'cli-app1:v2\t222\t2026-01-02 10:00:00 +0000 UTC\n'
+printf 'cli-app1:v3\t333\t2026-01-03 10:00:00 +0000 UTC\n'
+`
+	testutil.AssertNoError(t, os.WriteFile(commandPath, []byte(script), 0o755))
+
+	config := &types.ServerConfig{System: types.SystemConfig{ContainerCommand: commandPath}}
+	manager := NewCommandCM(testutil.TestLogger(), config, "", "")
+	testutil.AssertEqualsInt(t, "disabled", 0, len(manager.cacheFromImages(context.Background(), "cli-app1:v4")))
+
+	config.Builder.CacheFrom = true
+	testutil.AssertEqualsString(t, "new version", "cli-app1:v3",
+		strings.Join(manager.cacheFromImages(context.Background(), "cli-app1:v4"), ","))
+	// A rebuild of an existing version uses the previous version
+	testutil.AssertEqualsString(t, "rebuild", "cli-app1:v2",
+		strings.Join(manager.cacheFromImages(context.Background(), "cli-app1:v3"), ","))
+}
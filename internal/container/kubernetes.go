@@ -81,9 +81,34 @@ type KubernetesOptions struct {
 	Memory      string         `mapstructure:"memory"`
 	MinReplicas int32          `mapstructure:"min_replicas"` // min number of replicas to run the app on
 	MaxReplicas int32          `mapstructure:"max_replicas"` // max number of replicas to run the app on
+	Gpus        string         `mapstructure:"gpus"`         // GPU count, set as the nvidia.com/gpu resource limit
+	Devices     string         `mapstructure:"devices"`      // not supported, a device plugin resource is required
+	ShmSize     string         `mapstructure:"shm_size"`     // not supported
 	Other       map[string]any `mapstructure:",remain"`
 }
 
+// KUBERNETES_GPU_RESOURCE is the extended resource name for the GPUs, from the NVIDIA device plugin
+const KUBERNETES_GPU_RESOURCE = "nvidia.com/gpu"
+
+// gpuLimit returns the GPU count for the resource limit, zero if no GPUs are requested. Only a
+// GPU count is supported, the scheduler picks the GPUs
+func (o KubernetesOptions) gpuLimit() (int, error) {
+	if o.Devices != "" || o.ShmSize != "" {
+		return 0, fmt.Errorf("the devices and shm_size container options are not supported for Kubernetes")
+	}
+	if o.Gpus == "" {
+		return 0, nil
+	}
+	gpus, err := ParseGpus(o.Gpus)
+	if err != nil {
+		return 0, err
+	}
+	if gpus.Count == 0 {
+		return 0, fmt.Errorf("only a gpus count is supported for Kubernetes: %q", o.Gpus)
+	}
+	return gpus.Count, nil
+}
+
 type DeployRequest struct {
 	AppEntry           *types.AppEntry
 	SourceDir          string
@@ -285,7 +310,11 @@ func (k *KubernetesCM) ImageExists(ctx context.Context, name ImageName) (bool, e
 }
 
 func (k *KubernetesCM) RefreshImage(ctx context.Context, name ImageName) (string, error) {
-	result, err := CheckImageReferenceExists(ctx, k.Logger, string(name), &k.config.Registry)
+	registryConfig, err := ImageRegistryConfig(k.config, string(name))
+	if err != nil {
+		return "", err
+	}
+	result, err := CheckImageReferenceExists(ctx, k.Logger, string(name), registryConfig)
 	if err == nil {
 		if !result.Exists {
 			return "", fmt.Errorf("image %s not found", name)
@@ -299,6 +328,17 @@ func (k *KubernetesCM) RefreshImage(ctx context.Context, name ImageName) (string
 	return "", nil
 }
 
+var _ ImagePortInspector = (*KubernetesCM)(nil)
+
+// ImageExposedPorts returns the exposed ports from the image config, read from the registry
+func (k *KubernetesCM) ImageExposedPorts(ctx context.Context, name ImageName) ([]string, error) {
+	registryConfig, err := ImageRegistryConfig(k.config, string(name))
+	if err != nil {
+		return nil, err
+	}
+	return ImageReferenceExposedPorts(ctx, string(name), registryConfig)
+}
+
 func (k *KubernetesCM) BuildImage(ctx context.Context, imgName ImageName, sourceUrl, containerFile string, containerArgs map[string]string) error {
 	if k.config.Registry.URL == "" {
 		return fmt.Errorf("registry url is required for kubernetes container manager")
@@ -905,8 +945,12 @@ func (k *KubernetesCM) createDeployment(ctx context.Context, serviceName, wlName
 		containerConfig = containerConfig.WithVolumeMounts(volumeMounts...)
 	}
 
-	// Add resource requirements if cpus or memory are specified
-	if kubernetesOptions.Cpus != "" || kubernetesOptions.Memory != "" {
+	gpuLimit, err := kubernetesOptions.gpuLimit()
+	if err != nil {
+		return "", err
+	}
+	// Add resource requirements if cpus, memory or gpus are specified
+	if kubernetesOptions.Cpus != "" || kubernetesOptions.Memory != "" || gpuLimit > 0 {
 		resources := corev1apply.ResourceRequirements()
 		requestsList := core.ResourceList{}
 		limitsList := core.ResourceList{}
@@ -936,6 +980,11 @@ func (k *KubernetesCM) createDeployment(ctx context.Context, serviceName, wlName
 			limitsList[core.ResourceMemory] = memQuantity
 		}
 
+		if gpuLimit > 0 {
+			// Extended resources are set as limits, the request defaults to the limit
+			limitsList[core.ResourceName(KUBERNETES_GPU_RESOURCE)] = *resource.NewQuantity(int64(gpuLimit), resource.DecimalSI)
+		}
+
 		resources = resources.WithRequests(requestsList).WithLimits(limitsList)
 		containerConfig = containerConfig.WithResources(resources)
 	}
@@ -945,6 +994,15 @@ func (k *KubernetesCM) createDeployment(ctx context.Context, serviceName, wlName
 	if len(podVolumes) > 0 {
 		podSpec = podSpec.WithVolumes(podVolumes...)
 	}
+	if isImageSpec {
+		pullSecret, err := k.imagePullSecret(ctx, image)
+		if err != nil {
+			return "", err
+		}
+		if pullSecret != "" {
+			podSpec = podSpec.WithImagePullSecrets(corev1apply.LocalObjectReference().WithName(pullSecret))
+		}
+	}
 
 	// Set deployment strategy. PVC-backed apps use Recreate (single-writer,
 	// brief downtime); other apps use a surge rolling update that keeps the
@@ -1044,6 +1102,25 @@ func (k *KubernetesCM) createDeployment(ctx context.Context, serviceName, wlName
 
 // applyService server-side-applies the stable Service with the given selector
 // and returns its in-cluster URL.
+// imagePullSecret creates or updates the image pull secret for the registry_auth entry matching
+// the image, returning the secret name. An empty name is returned if there is no matching entry
+func (k *KubernetesCM) imagePullSecret(ctx context.Context, image string) (string, error) {
+	entryName, auth, err := RegistryAuthForImage(k.config, image)
+	if err != nil || auth == nil {
+		return "", err
+	}
+	dockerCfgJSON, err := GenerateDockerConfigJSON(auth)
+	if err != nil {
+		return "", fmt.Errorf("registry_auth.%s %w", entryName, err)
+	}
+	secretName := sanitizeName("openrun-pull-" + entryName)
+	if err := CreateOrUpdateSecret(ctx, k.clientSet, k.appNamespace, secretName,
+		map[string][]byte{".dockerconfigjson": dockerCfgJSON}, core.SecretTypeDockerConfigJson); err != nil {
+		return "", fmt.Errorf("error creating image pull secret %s: %w", secretName, err)
+	}
+	return secretName, nil
+}
+
 func (k *KubernetesCM) applyService(ctx context.Context, serviceName string, selectorLabels map[string]string, port int32) (string, error) {
 	serviceType := core.ServiceTypeClusterIP
 	if k.config.Kubernetes.UseNodePort {
@@ -10,6 +10,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"text/template"
 
@@ -187,6 +189,100 @@ func AsAppContainerStopper(cm ContainerManager) (AppContainerStopper, bool) {
 	return nil, false
 }
 
+// ImagePortInspector is an optional manager capability: reading the ports
+// listed in an image's EXPOSE metadata. Used to pick the container port for
+// apps which run a prebuilt image and do not set the port in the app config.
+// The command-based manager pulls the image if it is not present locally, the
+// Kubernetes manager reads the image config from the registry.
+type ImagePortInspector interface {
+	// ImageExposedPorts returns the exposed ports in the image config format,
+	// like "8080/tcp"
+	ImageExposedPorts(ctx context.Context, name ImageName) ([]string, error)
+}
+
+// AsImagePortInspector unwraps any decorating container managers and returns
+// the underlying ImagePortInspector if one is present.
+func AsImagePortInspector(cm ContainerManager) (ImagePortInspector, bool) {
+	for cm != nil {
+		if i, ok := cm.(ImagePortInspector); ok {
+			return i, true
+		}
+		u, ok := cm.(interface{ Unwrap() ContainerManager })
+		if !ok {
+			break
+		}
+		cm = u.Unwrap()
+	}
+	return nil, false
+}
+
+// ServiceSpec describes a service container of a multi-container app, like a worker or a
+// database, which runs next to the app container. The service is reachable from the app
+// container using the service name as the host name
+type ServiceSpec struct {
+	Name          string // service name, the network alias of the container
+	ContainerName ContainerName
+	Image         ImageName
+	Env           map[string]string
+	Command       []string // overrides the image cmd if set
+	Volumes       []*VolumeInfo
+	VersionHash   string
+}
+
+// ServiceManager is an optional manager capability: running the service containers of a
+// multi-container app. The app container and the service containers are attached to a per-app
+// network, the services are not published on the host. Implemented by the command-based
+// (Docker/Podman) manager
+type ServiceManager interface {
+	// EnsureAppNetwork creates the app network if it does not exist. The app container run
+	// after this call is attached to the network
+	EnsureAppNetwork(ctx context.Context) error
+	// RunService starts the service container. An existing container with the same name is
+	// reused, it is started if stopped
+	RunService(ctx context.Context, appEntry *types.AppEntry, spec ServiceSpec) error
+	// StopAppServicesExcept stops the running service containers of the app other than the
+	// ones in keep
+	StopAppServicesExcept(ctx context.Context, keep []ContainerName) error
+}
+
+// AsServiceManager unwraps any decorating container managers and returns the underlying
+// ServiceManager if one is present.
+func AsServiceManager(cm ContainerManager) (ServiceManager, bool) {
+	for cm != nil {
+		if s, ok := cm.(ServiceManager); ok {
+			return s, true
+		}
+		u, ok := cm.(interface{ Unwrap() ContainerManager })
+		if !ok {
+			break
+		}
+		cm = u.Unwrap()
+	}
+	return nil, false
+}
+
+// ParseExposedPorts converts exposed port values (from EXPOSE directives or the
+// image config, like "8080", "8080/tcp" or "53/udp") to sorted, deduplicated
+// TCP port numbers. Values which are not a single TCP port (UDP ports, port
+// ranges, unexpanded args like $PORT) are returned in skipped.
+func ParseExposedPorts(values []string) (ports []int32, skipped []string) {
+	for _, value := range values {
+		portStr, proto, _ := strings.Cut(strings.TrimSpace(value), "/")
+		if proto != "" && !strings.EqualFold(proto, "tcp") {
+			skipped = append(skipped, value)
+			continue
+		}
+		port, err := strconv.ParseInt(portStr, 10, 32)
+		if err != nil || port <= 0 || port > 65535 {
+			skipped = append(skipped, value)
+			continue
+		}
+		ports = append(ports, int32(port))
+	}
+	slices.Sort(ports)
+	return slices.Compact(ports), skipped
+}
+
 // DevRunOptions carries the dev-mode fast reload options for RunDevContainer.
 type DevRunOptions struct {
 	// RunHash identifies the full runtime config of the dev container. It is
@@ -241,6 +337,17 @@ func GenContainerName(appId types.AppId, contentHash string, supportsInPlaceUpda
 	}
 }
 
+// GenServiceContainerName returns the container name for an app service. The name includes the
+// service version hash, a changed service config starts a new container
+func GenServiceContainerName(appId types.AppId, service, versionHash string) ContainerName {
+	return ContainerName(fmt.Sprintf("clc-%s-%s-%s", appId, service, shortHash(versionHash)))
+}
+
+// GenNetworkName returns the name of the network shared by the app container and its services
+func GenNetworkName(appId types.AppId) string {
+	return fmt.Sprintf("cln-%s", appId)
+}
+
 const IMAGE_NAME_PREFIX = "cli-"
 
 func GenImageName(appId types.AppId, contentHash string) ImageName {
@@ -251,10 +358,18 @@ func GenImageName(appId types.AppId, contentHash string) ImageName {
 	}
 }
 
+// GenServiceImageName returns the image name for an app service built from a container file
+func GenServiceImageName(appId types.AppId, service, contentHash string) ImageName {
+	if contentHash == "" {
+		return ImageName(fmt.Sprintf("%s%s-%s", IMAGE_NAME_PREFIX, appId, service))
+	}
+	return ImageName(fmt.Sprintf("%s%s-%s:%s", IMAGE_NAME_PREFIX, appId, service, shortHash(contentHash)))
+}
+
 func GenVolumeName(appId types.AppId, dirName string) VolumeName {
 	dirHash := sha256.Sum256([]byte(dirName))
 	hashHex := hex.EncodeToString(dirHash[:])
-	return VolumeName(fmt.Sprintf("clv-%s-%s", appId, strings.ToLower(hashHex)))
+	return VolumeName(fmt.Sprintf("%s%s-%s", VOLUME_NAME_PREFIX, appId, strings.ToLower(hashHex)))
 }
 
 // DigestPinned returns image with the given digest appended, replacing any
@@ -16,7 +16,7 @@ import (
 var (
 	reIntOnly     = regexp.MustCompile(`^\d+$`)
 	reDockerLike  = regexp.MustCompile(`^\d+(\.\d+)?\s*[bkmgte]b?\s*$`) // e.g. 512m, 1g, 1gb, 0.5g (case-insensitive handled below)
-	KNOWN_OPTIONS = []string{"cpus", "memory", "min_replicas", "max_replicas"}
+	KNOWN_OPTIONS = []string{"cpus", "memory", "pids_limit", "min_replicas", "max_replicas", "gpus", "devices", "shm_size"}
 )
 
 // BytesString parses s and returns bytes as a base-10 integer string.
@@ -95,3 +95,13 @@ func formatCoresFromMilli(m int64) string {
 	s = strings.TrimSuffix(s, ".")
 	return s
 }
+
+// PidsString validates a process count limit and returns it as a base-10 integer string
+func PidsString(s string) (string, error) {
+	in := strings.TrimSpace(s)
+	pids, err := strconv.ParseInt(in, 10, 64)
+	if err != nil || pids <= 0 {
+		return "", fmt.Errorf("invalid pids limit %q, a positive integer is required", in)
+	}
+	return strconv.FormatInt(pids, 10), nil
+}
@@ -4,6 +4,7 @@
 package container
 
 import (
+	"slices"
 	"testing"
 )
 
@@ -154,3 +155,16 @@ func TestCPUString(t *testing.T) {
 		})
 	}
 }
+
+func TestParseExposedPorts(t *testing.T) {
+	ports, skipped := ParseExposedPorts([]string{"8080/tcp", "443", " 80 ", "53/udp", "8080", "$PORT", "9000-9002/tcp", "0"})
+	if !slices.Equal(ports, []int32{80, 443, 8080}) {
+		t.Errorf("ports = %v", ports)
+	}
+	if !slices.Equal(skipped, []string{"53/udp", "$PORT", "9000-9002/tcp", "0"}) {
+		t.Errorf("skipped = %v", skipped)
+	}
+	if ports, skipped := ParseExposedPorts(nil); len(ports) != 0 || len(skipped) != 0 {
+		t.Errorf("empty = %v %v", ports, skipped)
+	}
+}
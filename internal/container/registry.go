@@ -12,11 +12,13 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"time"
 
@@ -294,6 +296,10 @@ func imageUsesRegistryConfig(imageRef string, registryConfig *types.RegistryConf
 	if err != nil {
 		return false
 	}
+	if registry, err := name.NewRegistry(cfgHost); err == nil {
+		// Normalizes docker.io to index.docker.io, as used in the parsed reference
+		cfgHost = registry.RegistryStr()
+	}
 	return strings.EqualFold(ref.Context().RegistryStr(), cfgHost)
 }
 
@@ -335,6 +341,24 @@ func CheckImageReferenceExists(ctx context.Context, logger *types.Logger, imageR
 	return ExistsResult{Exists: true, Digest: desc.Digest.String()}, nil
 }
 
+// ImageReferenceExposedPorts reads the image config from the registry and
+// returns the exposed ports, like "8080/tcp"
+func ImageReferenceExposedPorts(ctx context.Context, imageRef string, registryConfig *types.RegistryConfig) ([]string, error) {
+	ref, opts, err := GetImageReferenceConfig(ctx, imageRef, registryConfig)
+	if err != nil {
+		return nil, fmt.Errorf("get remote config: %w", err)
+	}
+	img, err := remote.Image(ref, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("get image %s: %w", imageRef, err)
+	}
+	configFile, err := img.ConfigFile()
+	if err != nil {
+		return nil, fmt.Errorf("get image config %s: %w", imageRef, err)
+	}
+	return slices.Sorted(maps.Keys(configFile.Config.ExposedPorts)), nil
+}
+
 // isPermanentRegistryError reports whether a registry lookup error cannot be
 // fixed by retrying: a malformed image reference, or the registry rejecting
 // the request (auth failure, missing repo). Transient errors (network,
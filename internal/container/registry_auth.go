@@ -0,0 +1,106 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package container
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// registrySecretEval resolves the {{secret}} references in the registry_auth passwords. It is
+// set by the server once the secret manager is initialized, the password is used as is before that
+var registrySecretEval atomic.Pointer[func(string) (string, error)]
+
+// SetRegistrySecretEval sets the func used to resolve the secret references in the registry_auth
+// passwords
+func SetRegistrySecretEval(eval func(string) (string, error)) {
+	registrySecretEval.Store(&eval)
+}
+
+// RegistryAuthForImage returns the registry_auth entry name and config for the registry of the
+// image, with the password secret reference resolved. An empty name is returned if no entry
+// matches. The entry url is the registry host, like ghcr.io, docker.io matches the Docker Hub images
+func RegistryAuthForImage(config *types.ServerConfig, imageRef string) (string, *types.RegistryConfig, error) {
+	if len(config.RegistryAuth) == 0 {
+		return "", nil, nil
+	}
+	ref, err := name.ParseReference(imageRef)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid image reference %s: %w", imageRef, err)
+	}
+	imageRegistry := ref.Context().RegistryStr()
+
+	for _, entryName := range slices.Sorted(maps.Keys(config.RegistryAuth)) {
+		entry := config.RegistryAuth[entryName]
+		host, err := mustHost(entry.URL)
+		if err != nil {
+			return "", nil, fmt.Errorf("registry_auth.%s %w", entryName, err)
+		}
+		registry, err := name.NewRegistry(host)
+		if err != nil {
+			return "", nil, fmt.Errorf("registry_auth.%s invalid registry %s: %w", entryName, host, err)
+		}
+		if !strings.EqualFold(registry.RegistryStr(), imageRegistry) {
+			continue
+		}
+
+		if eval := registrySecretEval.Load(); eval != nil {
+			if entry.Password, err = (*eval)(entry.Password); err != nil {
+				return "", nil, fmt.Errorf("error resolving registry_auth.%s password: %w", entryName, err)
+			}
+		}
+		return entryName, &entry, nil
+	}
+	return "", nil, nil
+}
+
+// ImageRegistryConfig returns the registry config to use for reading the image from the
+// registry: the matching registry_auth entry if present, else the registry config
+func ImageRegistryConfig(config *types.ServerConfig, imageRef string) (*types.RegistryConfig, error) {
+	_, auth, err := RegistryAuthForImage(config, imageRef)
+	if err != nil {
+		return nil, err
+	}
+	if auth != nil {
+		return auth, nil
+	}
+	return &config.Registry, nil
+}
+
+// WithRegistryAuth sets up the container CLI command to use the registry_auth credentials for the
+// image, if there is a matching entry. A temporary docker config is written, DOCKER_CONFIG is used
+// by docker and REGISTRY_AUTH_FILE by podman. The returned func removes the temporary config
+func WithRegistryAuth(config *types.ServerConfig, cmd *exec.Cmd, imageRef string) (func(), error) {
+	entryName, auth, err := RegistryAuthForImage(config, imageRef)
+	if err != nil || auth == nil {
+		return func() {}, err
+	}
+
+	configJSON, err := GenerateDockerConfigJSON(auth)
+	if err != nil {
+		return func() {}, fmt.Errorf("registry_auth.%s %w", entryName, err)
+	}
+	dir, err := os.MkdirTemp("", "openrun-registry-auth-")
+	if err != nil {
+		return func() {}, err
+	}
+	cleanup := func() { _ = os.RemoveAll(dir) }
+	configFile := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(configFile, configJSON, 0600); err != nil {
+		cleanup()
+		return func() {}, err
+	}
+
+	cmd.Env = append(cmd.Environ(), "DOCKER_CONFIG="+dir, "REGISTRY_AUTH_FILE="+configFile)
+	return cleanup, nil
+}
@@ -0,0 +1,82 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package container
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestRegistryAuthForImage(t *testing.T) {
+	config := &types.ServerConfig{
+		Registry: types.RegistryConfig{URL: "registry.example.com"},
+		RegistryAuth: map[string]types.RegistryConfig{
+			"ghcr": {URL: "ghcr.io", Username: "bot", Password: `{{secret "GHCR_TOKEN"}}`},
+			"hub":  {URL: "https://docker.io", Username: "user", Password: "pass"},
+		},
+	}
+	SetRegistrySecretEval(func(input string) (string, error) {
+		return strings.ReplaceAll(input, `{{secret "GHCR_TOKEN"}}`, "resolved"), nil
+	})
+	defer registrySecretEval.Store(nil)
+
+	name, auth, err := RegistryAuthForImage(config, "ghcr.io/org/app:v1")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "name", "ghcr", name)
+	testutil.AssertEqualsString(t, "password", "resolved", auth.Password)
+	testutil.AssertEqualsString(t, "config unchanged", `{{secret "GHCR_TOKEN"}}`, config.RegistryAuth["ghcr"].Password)
+
+	// Docker Hub images without a registry host match docker.io
+	name, _, err = RegistryAuthForImage(config, "nginx:latest")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "docker hub", "hub", name)
+
+	name, auth, err = RegistryAuthForImage(config, "quay.io/org/app")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "no match", "", name)
+	if auth != nil {
+		t.Fatal("expected no auth for unmatched registry")
+	}
+	registryConfig, err := ImageRegistryConfig(config, "quay.io/org/app")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "default registry", "registry.example.com", registryConfig.URL)
+}
+
+func TestWithRegistryAuth(t *testing.T) {
+	config := &types.ServerConfig{
+		RegistryAuth: map[string]types.RegistryConfig{"ghcr": {URL: "ghcr.io", Username: "bot", Password: "token"}},
+	}
+	cmd := exec.Command("docker", "pull", "ghcr.io/org/app:v1")
+	cleanup, err := WithRegistryAuth(config, cmd, "ghcr.io/org/app:v1")
+	testutil.AssertNoError(t, err)
+
+	idx := slices.IndexFunc(cmd.Env, func(e string) bool { return strings.HasPrefix(e, "DOCKER_CONFIG=") })
+	if idx == -1 {
+		t.Fatal("expected DOCKER_CONFIG to be set")
+	}
+	dir := strings.TrimPrefix(cmd.Env[idx], "DOCKER_CONFIG=")
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	testutil.AssertNoError(t, err)
+	testutil.AssertStringContains(t, string(data), `"ghcr.io"`)
+	cleanup()
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Errorf("expected the temp config to be removed, got %v", err)
+	}
+
+	// No matching entry, the command is unchanged
+	cmd = exec.Command("docker", "pull", "nginx")
+	cleanup, err = WithRegistryAuth(config, cmd, "nginx")
+	testutil.AssertNoError(t, err)
+	cleanup()
+	if cmd.Env != nil {
+		t.Error("expected no env change for unmatched registry")
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package container
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// VOLUME_NAME_PREFIX is the prefix for the names of the app volumes, see GenVolumeName
+const VOLUME_NAME_PREFIX = "clv-"
+
+// volumeHelperMount is the path the volume is mounted at in the helper container. The backup
+// archive has the volume files under the volume/ directory
+const volumeHelperMount = "/volume"
+
+// VolumeAppId returns the app id from a volume name generated by GenVolumeName, false is
+// returned for the volumes not created by OpenRun
+func VolumeAppId(name string) (types.AppId, bool) {
+	rest, ok := strings.CutPrefix(name, VOLUME_NAME_PREFIX)
+	if !ok {
+		return "", false
+	}
+	index := strings.LastIndex(rest, "-")
+	if index <= 0 || len(rest)-index-1 != sha256.Size*2 {
+		return "", false
+	}
+	return types.AppId(rest[:index]), true
+}
+
+// parseVolumeList parses the "volume ls" output with one name per line. The volumes not created
+// by OpenRun are skipped, the name filter for the list is a substring match
+func parseVolumeList(output string) []types.AppVolume {
+	volumes := []types.AppVolume{}
+	for _, line := range strings.Split(output, "\n") {
+		name := strings.TrimSpace(line)
+		appId, ok := VolumeAppId(name)
+		if !ok {
+			continue
+		}
+		volumes = append(volumes, types.AppVolume{Name: name, AppId: appId})
+	}
+	return volumes
+}
+
+// ListVolumes returns the volumes created for the apps, with whether each volume is used by a
+// container, running or stopped
+func (c *CommandCM) ListVolumes(ctx context.Context) ([]types.AppVolume, error) {
+	cmd := c.cli.cmd(ctx, "volume", "ls", "--filter", "name="+VOLUME_NAME_PREFIX, "--format", "{{.Name}}")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("error listing volumes: %s : %s", output, err)
+	}
+	volumes := parseVolumeList(string(output))
+	for i := range volumes {
+		if volumes[i].InUse, err = c.VolumeInUse(ctx, VolumeName(volumes[i].Name), false); err != nil {
+			return nil, err
+		}
+	}
+	return volumes, nil
+}
+
+// VolumeInUse reports whether the volume is mounted by a container. With running, only the
+// running containers are checked
+func (c *CommandCM) VolumeInUse(ctx context.Context, name VolumeName, running bool) (bool, error) {
+	containers, err := c.driver.listContainers(ctx, []string{"volume=" + string(name)}, !running)
+	if err != nil {
+		return false, err
+	}
+	return len(containers) > 0, nil
+}
+
+// RemoveVolume removes the volume, which fails if the volume is used by a container
+func (c *CommandCM) RemoveVolume(ctx context.Context, name VolumeName) error {
+	c.Info().Msgf("Removing volume %s", name)
+	output, err := c.cli.cmd(ctx, "volume", "rm", string(name)).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("error removing volume %s: %s : %w", name, output, err)
+	}
+	return nil
+}
+
+// withVolumeContainer creates a helper container with the volume mounted and calls fn with the
+// container id. The container is not started, the volume data is copied using the cp command.
+// The helper image is pulled if not present. The container is removed after fn returns
+func (c *CommandCM) withVolumeContainer(ctx context.Context, name VolumeName, fn func(id string) error) error {
+	var stderr bytes.Buffer
+	cmd := c.cli.cmd(ctx, "create", "--label", LABEL_PREFIX+"volume.helper=true",
+		"-v", string(name)+":"+volumeHelperMount, c.config.System.VolumeHelperImage)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("error creating helper container for volume %s: %s : %w", name, stderr.String(), err)
+	}
+	id := strings.TrimSpace(string(output))
+	defer func() {
+		// Removed even if the request is canceled
+		if output, err := c.cli.cmd(context.Background(), "rm", "-f", id).CombinedOutput(); err != nil {
+			c.Warn().Msgf("error removing helper container %s for volume %s: %s %s", id, name, output, err)
+		}
+	}()
+	return fn(id)
+}
+
+// BackupVolume writes the volume contents to w as a tar archive, with the files under the
+// volume/ directory
+func (c *CommandCM) BackupVolume(ctx context.Context, name VolumeName, w io.Writer) error {
+	if !c.VolumeExists(ctx, name) {
+		return fmt.Errorf("volume %s not found", name)
+	}
+	return c.withVolumeContainer(ctx, name, func(id string) error {
+		var stderr bytes.Buffer
+		cmd := c.cli.cmd(ctx, "cp", id+":"+volumeHelperMount, "-")
+		cmd.Stdout = w
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("error copying data from volume %s: %s : %w", name, stderr.String(), err)
+		}
+		return nil
+	})
+}
+
+// RestoreVolume extracts a tar archive created by BackupVolume into the volume, the volume is
+// created if it does not exist. The files in the archive overwrite the existing files, other
+// files in the volume are retained
+func (c *CommandCM) RestoreVolume(ctx context.Context, name VolumeName, r io.Reader) error {
+	if !c.VolumeExists(ctx, name) {
+		if err := c.VolumeCreate(ctx, name); err != nil {
+			return err
+		}
+	}
+	return c.withVolumeContainer(ctx, name, func(id string) error {
+		var stderr bytes.Buffer
+		cmd := c.cli.cmd(ctx, "cp", "-", id+":/")
+		cmd.Stdin = r
+		cmd.Stderr = &stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("error copying data to volume %s: %s : %w", name, stderr.String(), err)
+		}
+		return nil
+	})
+}
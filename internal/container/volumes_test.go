@@ -0,0 +1,34 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package container
+
+import (
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestVolumeAppId(t *testing.T) {
+	name := GenVolumeName("app_prd_abc-def", "data")
+	appId, ok := VolumeAppId(string(name))
+	testutil.AssertEqualsBool(t, "ok", true, ok)
+	testutil.AssertEqualsString(t, "app id", "app_prd_abc-def", string(appId))
+
+	for _, invalid := range []string{"", "mydata", "clv-app_prd_abc", "clv-app_prd_abc-1234", "clv--" + string(name)[len(name)-64:]} {
+		if _, ok := VolumeAppId(invalid); ok {
+			t.Errorf("expected %q to not be an app volume", invalid)
+		}
+	}
+}
+
+func TestParseVolumeList(t *testing.T) {
+	v1 := GenVolumeName("app_prd_one", "data")
+	v2 := GenVolumeName("app_stg_two", "/var/lib/data")
+	volumes := parseVolumeList(string(v1) + "\nother-clv-volume\n\n" + string(v2) + "\n")
+	testutil.AssertEqualsInt(t, "count", 2, len(volumes))
+	testutil.AssertEqualsString(t, "name", string(v1), volumes[0].Name)
+	testutil.AssertEqualsString(t, "app id", "app_prd_one", string(volumes[0].AppId))
+	testutil.AssertEqualsString(t, "app id", string(types.AppId("app_stg_two")), string(volumes[1].AppId))
+}
@@ -0,0 +1,243 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+// Package i18n has the message catalog for the user facing server error pages and the CLI
+// prompts. The built-in catalogs are embedded, additional languages and overrides for the
+// built-in messages can be loaded from a directory.
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DEFAULT_LANGUAGE is used when no other language matches. Every message key has to be
+// present in the default catalog
+const DEFAULT_LANGUAGE = "en"
+
+//go:embed messages/*.json
+var builtinFS embed.FS
+
+// Catalog has the messages by language, keyed by the message id. Messages are fmt format
+// strings, the args are passed by the caller
+type Catalog struct {
+	messages map[string]map[string]string
+}
+
+var builtin = sync.OnceValue(func() *Catalog {
+	catalog, err := loadBuiltin()
+	if err != nil {
+		// The embedded catalogs are validated by the tests
+		panic(err)
+	}
+	return catalog
+})
+
+// Builtin returns the catalog with the embedded messages
+func Builtin() *Catalog {
+	return builtin()
+}
+
+func loadBuiltin() (*Catalog, error) {
+	entries, err := builtinFS.ReadDir("messages")
+	if err != nil {
+		return nil, err
+	}
+	c := &Catalog{messages: map[string]map[string]string{}}
+	for _, entry := range entries {
+		data, err := builtinFS.ReadFile("messages/" + entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		if err := c.add(entry.Name(), data); err != nil {
+			return nil, err
+		}
+	}
+	if _, ok := c.messages[DEFAULT_LANGUAGE]; !ok {
+		return nil, fmt.Errorf("default language %s catalog is missing", DEFAULT_LANGUAGE)
+	}
+	return c, nil
+}
+
+// NewCatalog returns the built-in catalog merged with the <lang>.json files in dir. The
+// messages in dir override the built-in messages for the same language and key. An empty
+// dir returns the built-in catalog
+func NewCatalog(dir string) (*Catalog, error) {
+	if dir == "" {
+		return Builtin(), nil
+	}
+
+	c := &Catalog{messages: map[string]map[string]string{}}
+	for lang, messages := range Builtin().messages {
+		c.messages[lang] = maps.Clone(messages)
+	}
+
+	files, err := filepath.Glob(filepath.Join(os.ExpandEnv(dir), "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.add(filepath.Base(file), data); err != nil {
+			return nil, err
+		}
+	}
+	return c, nil
+}
+
+func (c *Catalog) add(fileName string, data []byte) error {
+	lang := Normalize(strings.TrimSuffix(fileName, ".json"))
+	if lang == "" {
+		return fmt.Errorf("invalid message catalog file name %s, expected <language>.json", fileName)
+	}
+	messages := map[string]string{}
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return fmt.Errorf("error parsing message catalog %s: %w", fileName, err)
+	}
+	if c.messages[lang] == nil {
+		c.messages[lang] = map[string]string{}
+	}
+	maps.Copy(c.messages[lang], messages)
+	return nil
+}
+
+// Languages returns the languages in the catalog, sorted
+func (c *Catalog) Languages() []string {
+	return slices.Sorted(maps.Keys(c.messages))
+}
+
+// Message returns the message for the key in the language, formatted with the args. The
+// region specific catalog (like pt-br) is checked first, then the base language (pt) and then
+// the default language. The key is returned if it is not present in any of those
+func (c *Catalog) Message(lang, key string, args ...any) string {
+	format, ok := c.lookup(Normalize(lang), key)
+	if !ok {
+		format = key
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+func (c *Catalog) lookup(lang, key string) (string, bool) {
+	for _, l := range []string{lang, baseLanguage(lang), DEFAULT_LANGUAGE} {
+		if msg, ok := c.messages[l][key]; ok {
+			return msg, true
+		}
+	}
+	return "", false
+}
+
+// Supports returns true if the catalog has messages for the language or its base language
+func (c *Catalog) Supports(lang string) bool {
+	lang = Normalize(lang)
+	if lang == "" {
+		return false
+	}
+	_, ok := c.messages[lang]
+	if !ok {
+		_, ok = c.messages[baseLanguage(lang)]
+	}
+	return ok
+}
+
+// Match returns the best supported language for the Accept-Language header value, in the
+// order of the quality values. fallback is returned if no language in the header is supported
+func (c *Catalog) Match(acceptLanguage, fallback string) string {
+	for _, lang := range parseAcceptLanguage(acceptLanguage) {
+		if c.Supports(lang) {
+			return lang
+		}
+	}
+	if c.Supports(fallback) {
+		return Normalize(fallback)
+	}
+	return DEFAULT_LANGUAGE
+}
+
+type weightedLanguage struct {
+	lang    string
+	quality float64
+}
+
+// parseAcceptLanguage returns the languages in the header, highest quality first. The * and
+// q=0 entries are skipped
+func parseAcceptLanguage(header string) []string {
+	entries := []weightedLanguage{}
+	for part := range strings.SplitSeq(header, ",") {
+		lang, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		lang = Normalize(lang)
+		if lang == "" || lang == "*" {
+			continue
+		}
+		quality := 1.0
+		for param := range strings.SplitSeq(params, ";") {
+			if value, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if q, err := strconv.ParseFloat(value, 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		if quality > 0 {
+			entries = append(entries, weightedLanguage{lang, quality})
+		}
+	}
+	// Stable sort keeps the header order for the same quality
+	slices.SortStableFunc(entries, func(a, b weightedLanguage) int {
+		switch {
+		case a.quality > b.quality:
+			return -1
+		case a.quality < b.quality:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	ret := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ret = append(ret, entry.lang)
+	}
+	return ret
+}
+
+// Normalize returns the language tag in lower case with - as the separator. The encoding and
+// modifier suffixes used in the POSIX locale env values (de_DE.UTF-8, ca_ES@valencia) are
+// removed. C and POSIX locales map to the default language
+func Normalize(lang string) string {
+	lang, _, _ = strings.Cut(lang, ".")
+	lang, _, _ = strings.Cut(lang, "@")
+	lang = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(lang), "_", "-"))
+	if lang == "c" || lang == "posix" {
+		return DEFAULT_LANGUAGE
+	}
+	return lang
+}
+
+func baseLanguage(lang string) string {
+	base, _, _ := strings.Cut(lang, "-")
+	return base
+}
+
+// EnvLanguage returns the language from the LC_ALL, LC_MESSAGES and LANG env values, empty if
+// none are set
+func EnvLanguage() string {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := Normalize(os.Getenv(env)); value != "" {
+			return value
+		}
+	}
+	return ""
+}
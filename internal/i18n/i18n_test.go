@@ -0,0 +1,109 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package i18n
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+var formatVerbRe = regexp.MustCompile(`%[a-z]`)
+
+func TestBuiltinCatalogs(t *testing.T) {
+	catalog := Builtin()
+	testutil.AssertEqualsString(t, "languages", "de,en,es,fr", strings.Join(catalog.Languages(), ","))
+
+	// Every catalog has the same keys as the default catalog, with the same format verbs
+	defaults := catalog.messages[DEFAULT_LANGUAGE]
+	for _, lang := range catalog.Languages() {
+		messages := catalog.messages[lang]
+		for key, msg := range defaults {
+			translated, ok := messages[key]
+			if !ok {
+				t.Errorf("%s: missing key %s", lang, key)
+				continue
+			}
+			if !slices.Equal(formatVerbRe.FindAllString(msg, -1), formatVerbRe.FindAllString(translated, -1)) {
+				t.Errorf("%s: format verbs for %s do not match %q", lang, key, translated)
+			}
+		}
+		for key := range messages {
+			if _, ok := defaults[key]; !ok {
+				t.Errorf("%s: key %s not in the default catalog", lang, key)
+			}
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	catalog := Builtin()
+	testutil.AssertEqualsString(t, "en", "Authentication failed", catalog.Message("en", "error.auth_failed"))
+	testutil.AssertEqualsString(t, "de", "Authentifizierung fehlgeschlagen", catalog.Message("de-DE", "error.auth_failed"))
+	testutil.AssertEqualsString(t, "fr args", "Interdit : bob n'a pas accès à /app", catalog.Message("fr", "error.forbidden_app", "bob", "/app"))
+	testutil.AssertEqualsString(t, "unsupported", "Authentication failed", catalog.Message("ja", "error.auth_failed"))
+	testutil.AssertEqualsString(t, "missing key", "no.such.key", catalog.Message("en", "no.such.key"))
+}
+
+func TestMatch(t *testing.T) {
+	catalog := Builtin()
+	for _, test := range []struct {
+		header, fallback, expected string
+	}{
+		{"", "en", "en"},
+		{"", "de", "de"},
+		{"", "ja", "en"},
+		{"fr-CH, fr;q=0.9, en;q=0.8", "en", "fr-ch"},
+		{"ja, es;q=0.5, de;q=0.7", "en", "de"},
+		{"ja, zh;q=0.8", "es", "es"},
+		{"de;q=0, fr;q=0.1", "en", "fr"},
+		{"*", "fr", "fr"},
+	} {
+		testutil.AssertEqualsString(t, test.header, test.expected, catalog.Match(test.header, test.fallback))
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	for input, expected := range map[string]string{
+		"de_DE.UTF-8":    "de-de",
+		"ca_ES@valencia": "ca-es",
+		"C":              "en",
+		"POSIX":          "en",
+		" pt-BR ":        "pt-br",
+		"":               "",
+	} {
+		testutil.AssertEqualsString(t, input, expected, Normalize(input))
+	}
+
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "")
+	t.Setenv("LANG", "es_ES.UTF-8")
+	testutil.AssertEqualsString(t, "env", "es-es", EnvLanguage())
+	t.Setenv("LC_MESSAGES", "fr_FR")
+	testutil.AssertEqualsString(t, "env", "fr-fr", EnvLanguage())
+}
+
+func TestCatalogDir(t *testing.T) {
+	dir := t.TempDir()
+	testutil.AssertNoError(t, os.WriteFile(filepath.Join(dir, "pt.json"), []byte(`{"error.auth_failed": "Falha na autenticação"}`), 0o600))
+	testutil.AssertNoError(t, os.WriteFile(filepath.Join(dir, "en.json"), []byte(`{"error.auth_failed": "Login failed"}`), 0o600))
+
+	catalog, err := NewCatalog(dir)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "pt", "Falha na autenticação", catalog.Message("pt-BR", "error.auth_failed"))
+	testutil.AssertEqualsString(t, "pt fallback", "Authentication required", catalog.Message("pt", "error.auth_required"))
+	testutil.AssertEqualsString(t, "en override", "Login failed", catalog.Message("en", "error.auth_failed"))
+	testutil.AssertEqualsString(t, "match", "pt-br", catalog.Match("pt-BR", "en"))
+	// The built-in catalog is not changed by the overrides
+	testutil.AssertEqualsString(t, "builtin", "Authentication failed", Builtin().Message("en", "error.auth_failed"))
+
+	testutil.AssertNoError(t, os.WriteFile(filepath.Join(dir, "de.json"), []byte(`{invalid`), 0o600))
+	_, err = NewCatalog(dir)
+	testutil.AssertErrorContains(t, err, "error parsing message catalog de.json")
+}
@@ -0,0 +1,173 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"time"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// The app locks are advisory locks on app paths, used to prevent operations on the same app from
+// interleaving. For postgres, the locks are in the app_locks table, shared by all the servers
+// using the database. The lock rows are updated outside of the operation transaction, so that
+// other servers see the lock while the operation is running. For sqlite, which is used by a single
+// server, the locks are kept in memory.
+
+// AcquireAppLock takes the lock on the app path for the holder. If the lock is held by another
+// holder and has not expired, the lock is not taken and the current lock is returned
+func (m *Metadata) AcquireAppLock(ctx context.Context, lock *types.AppLock) (*types.AppLock, error) {
+	if m.dbType == system.DB_TYPE_SQLITE {
+		m.appLocksMu.Lock()
+		defer m.appLocksMu.Unlock()
+		if current, ok := m.appLocks[lock.Path]; ok && current.HolderId != lock.HolderId && current.ExpireTime.After(time.Now()) {
+			return &current, nil
+		}
+		if m.appLocks == nil {
+			m.appLocks = make(map[string]types.AppLock)
+		}
+		m.appLocks[lock.Path] = *lock
+		return nil, nil
+	}
+
+	// A single conditional upsert, so that two servers racing for the lock cannot both get it. An
+	// existing lock is taken over only if it has expired
+	result, err := m.db.ExecContext(ctx, system.RebindQuery(m.dbType,
+		`insert into app_locks (path, holder_id, hostname, operation, user_id, acquire_time, expire_time) values (?, ?, ?, ?, ?, ?, ?) `+
+			`on conflict (path) do update set holder_id = excluded.holder_id, hostname = excluded.hostname, operation = excluded.operation, `+
+			`user_id = excluded.user_id, acquire_time = excluded.acquire_time, expire_time = excluded.expire_time `+
+			`where app_locks.expire_time <= ? or app_locks.holder_id = excluded.holder_id`),
+		lock.Path, lock.HolderId, lock.Hostname, lock.Operation, lock.UserId, lock.AcquireTime.UnixNano(), lock.ExpireTime.UnixNano(),
+		time.Now().UnixNano())
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring lock for app %s: %w", lock.Path, err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring lock for app %s: %w", lock.Path, err)
+	}
+	if rowsAffected > 0 {
+		return nil, nil
+	}
+
+	current, err := m.getAppLock(ctx, lock.Path)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The lock was released after the insert, retry
+		return m.AcquireAppLock(ctx, lock)
+	} else if err != nil {
+		return nil, err
+	}
+	return current, nil
+}
+
+func (m *Metadata) getAppLock(ctx context.Context, path string) (*types.AppLock, error) {
+	row := m.db.QueryRowContext(ctx, system.RebindQuery(m.dbType,
+		`select path, holder_id, hostname, operation, user_id, acquire_time, expire_time from app_locks where path = ?`), path)
+	return scanAppLock(row)
+}
+
+func scanAppLock(row interface{ Scan(...any) error }) (*types.AppLock, error) {
+	var lock types.AppLock
+	var acquireTime, expireTime int64
+	if err := row.Scan(&lock.Path, &lock.HolderId, &lock.Hostname, &lock.Operation, &lock.UserId, &acquireTime, &expireTime); err != nil {
+		return nil, err
+	}
+	lock.AcquireTime = time.Unix(0, acquireTime)
+	lock.ExpireTime = time.Unix(0, expireTime)
+	return &lock, nil
+}
+
+// RenewAppLocks extends the expiry time of all the locks held by the holder
+func (m *Metadata) RenewAppLocks(ctx context.Context, holderId string, expireTime time.Time) error {
+	if m.dbType == system.DB_TYPE_SQLITE {
+		m.appLocksMu.Lock()
+		defer m.appLocksMu.Unlock()
+		for path, lock := range m.appLocks {
+			if lock.HolderId == holderId {
+				lock.ExpireTime = expireTime
+				m.appLocks[path] = lock
+			}
+		}
+		return nil
+	}
+
+	_, err := m.db.ExecContext(ctx, system.RebindQuery(m.dbType, `update app_locks set expire_time = ? where holder_id = ?`),
+		expireTime.UnixNano(), holderId)
+	if err != nil {
+		return fmt.Errorf("error renewing app locks: %w", err)
+	}
+	return nil
+}
+
+// ReleaseAppLock releases the lock on the app path if it is held by the holder. If holderId is
+// empty, the lock is released irrespective of the holder. Returns the lock which was released,
+// nil if there was no lock
+func (m *Metadata) ReleaseAppLock(ctx context.Context, path, holderId string) (*types.AppLock, error) {
+	if m.dbType == system.DB_TYPE_SQLITE {
+		m.appLocksMu.Lock()
+		defer m.appLocksMu.Unlock()
+		lock, ok := m.appLocks[path]
+		if !ok || (holderId != "" && lock.HolderId != holderId) {
+			return nil, nil
+		}
+		delete(m.appLocks, path)
+		return &lock, nil
+	}
+
+	query := `delete from app_locks where path = ?`
+	args := []any{path}
+	if holderId != "" {
+		query += ` and holder_id = ?`
+		args = append(args, holderId)
+	}
+	row := m.db.QueryRowContext(ctx, system.RebindQuery(m.dbType,
+		query+` returning path, holder_id, hostname, operation, user_id, acquire_time, expire_time`), args...)
+	lock, err := scanAppLock(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("error releasing lock for app %s: %w", path, err)
+	}
+	return lock, nil
+}
+
+// ListAppLocks returns the app locks which have not expired, ordered by app path
+func (m *Metadata) ListAppLocks(ctx context.Context) ([]types.AppLock, error) {
+	now := time.Now()
+	if m.dbType == system.DB_TYPE_SQLITE {
+		m.appLocksMu.Lock()
+		defer m.appLocksMu.Unlock()
+		locks := make([]types.AppLock, 0, len(m.appLocks))
+		for _, path := range slices.Sorted(maps.Keys(m.appLocks)) {
+			if lock := m.appLocks[path]; lock.ExpireTime.After(now) {
+				locks = append(locks, lock)
+			}
+		}
+		return locks, nil
+	}
+
+	rows, err := m.db.QueryContext(ctx, system.RebindQuery(m.dbType,
+		`select path, holder_id, hostname, operation, user_id, acquire_time, expire_time from app_locks where expire_time > ? order by path`),
+		now.UnixNano())
+	if err != nil {
+		return nil, fmt.Errorf("error listing app locks: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+	locks := make([]types.AppLock, 0)
+	for rows.Next() {
+		lock, err := scanAppLock(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error listing app locks: %w", err)
+		}
+		locks = append(locks, *lock)
+	}
+	return locks, rows.Err()
+}
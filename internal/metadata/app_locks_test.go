@@ -0,0 +1,140 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func newTestAppLock(path, holderId string, expireTime time.Time) *types.AppLock {
+	return &types.AppLock{
+		Path:        path,
+		HolderId:    holderId,
+		Hostname:    "host1",
+		Operation:   "reload_apps",
+		UserId:      "admin",
+		AcquireTime: time.Now(),
+		ExpireTime:  expireTime,
+	}
+}
+
+func testAppLocks(t *testing.T, m *Metadata) {
+	ctx := context.Background()
+	expireTime := time.Now().Add(time.Minute)
+
+	holder, err := m.AcquireAppLock(ctx, newTestAppLock("/app1", "h1", expireTime))
+	testutil.AssertNoError(t, err)
+	if holder != nil {
+		t.Fatalf("expected lock to be acquired, held by %+v", holder)
+	}
+	// The holder can take the lock again
+	holder, err = m.AcquireAppLock(ctx, newTestAppLock("/app1", "h1", expireTime))
+	testutil.AssertNoError(t, err)
+	if holder != nil {
+		t.Fatalf("expected lock to be acquired again, held by %+v", holder)
+	}
+
+	holder, err = m.AcquireAppLock(ctx, newTestAppLock("/app1", "h2", expireTime))
+	testutil.AssertNoError(t, err)
+	if holder == nil {
+		t.Fatal("expected lock to be held")
+	}
+	testutil.AssertEqualsString(t, "holder", "h1", holder.HolderId)
+	testutil.AssertEqualsString(t, "operation", "reload_apps", holder.Operation)
+
+	// An expired lock is taken over
+	_, err = m.AcquireAppLock(ctx, newTestAppLock("/app2", "h1", time.Now().Add(-time.Second)))
+	testutil.AssertNoError(t, err)
+	holder, err = m.AcquireAppLock(ctx, newTestAppLock("/app2", "h2", expireTime))
+	testutil.AssertNoError(t, err)
+	if holder != nil {
+		t.Fatalf("expected expired lock to be taken over, held by %+v", holder)
+	}
+
+	locks, err := m.ListAppLocks(ctx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "locks", 2, len(locks))
+	testutil.AssertEqualsString(t, "first lock", "/app1", locks[0].Path)
+	testutil.AssertEqualsString(t, "second lock holder", "h2", locks[1].HolderId)
+
+	testutil.AssertNoError(t, m.RenewAppLocks(ctx, "h1", time.Now().Add(time.Hour)))
+	locks, err = m.ListAppLocks(ctx)
+	testutil.AssertNoError(t, err)
+	if time.Until(locks[0].ExpireTime) < 30*time.Minute {
+		t.Fatalf("lock was not renewed, expires at %s", locks[0].ExpireTime)
+	}
+
+	// Release by another holder does nothing, force release removes the lock
+	released, err := m.ReleaseAppLock(ctx, "/app1", "h2")
+	testutil.AssertNoError(t, err)
+	if released != nil {
+		t.Fatalf("unexpected release %+v", released)
+	}
+	released, err = m.ReleaseAppLock(ctx, "/app1", "")
+	testutil.AssertNoError(t, err)
+	if released == nil {
+		t.Fatal("expected lock to be released")
+	}
+	testutil.AssertEqualsString(t, "released holder", "h1", released.HolderId)
+	released, err = m.ReleaseAppLock(ctx, "/app2", "h2")
+	testutil.AssertNoError(t, err)
+	if released == nil {
+		t.Fatal("expected lock to be released")
+	}
+
+	locks, err = m.ListAppLocks(ctx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "locks", 0, len(locks))
+}
+
+func TestAppLocksSqlite(t *testing.T) {
+	m, cleanup := setupTestMetadata(t)
+	defer cleanup()
+	testAppLocks(t, m)
+}
+
+func TestAppLocksPostgres(t *testing.T) {
+	skipIfNoPostgres(t)
+	connStr, cleanupPostgres := startPostgres(t)
+	defer cleanupPostgres()
+
+	config := &types.ServerConfig{
+		Metadata: types.MetadataConfig{
+			DBConnection: connStr,
+			AutoUpgrade:  true,
+		},
+	}
+	config.System.LeaderElectionLeaseSecs = 10
+	config.System.LeaderElectionHeartbeatIntervalSecs = 2
+	m, err := NewMetadata(testutil.TestLogger(), config)
+	if err != nil {
+		t.Fatalf("failed to create metadata: %v", err)
+	}
+	defer m.Close()
+	testAppLocks(t, m)
+}
+
+func TestAppLocksSqliteInMemory(t *testing.T) {
+	m, cleanup := setupTestMetadata(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	// The app locks for sqlite are not in the database
+	var count int
+	testutil.AssertNoError(t, m.db.QueryRowContext(ctx,
+		`select count(*) from sqlite_master where type = 'table' and name = 'app_locks'`).Scan(&count))
+	testutil.AssertEqualsInt(t, "app_locks table", 0, count)
+
+	holder, err := m.AcquireAppLock(ctx, newTestAppLock("/app1", "h1", time.Now().Add(time.Minute)))
+	testutil.AssertNoError(t, err)
+	if holder != nil {
+		t.Fatalf("expected lock to be acquired, held by %+v", holder)
+	}
+	testutil.AssertEqualsString(t, "in memory lock", "h1", m.appLocks["/app1"].HolderId)
+}
@@ -0,0 +1,74 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+var ErrApplyPlanNotFound = errors.New("apply plan not found")
+var ErrApplyPlanExecuted = errors.New("apply plan has already been executed")
+
+func (m *Metadata) InsertApplyPlan(ctx context.Context, tx types.Transaction, plan *types.ApplyPlan) error {
+	planJson, err := json.Marshal(plan)
+	if err != nil {
+		return fmt.Errorf("error marshalling apply plan: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, system.RebindQuery(m.dbType,
+		`insert into apply_plans (id, create_time, user_id, status, plan) values (?, ?, ?, ?, ?)`),
+		plan.Id, plan.CreateTime.UTC(), plan.UserId, string(plan.Status), string(planJson)); err != nil {
+		return fmt.Errorf("error inserting apply plan: %w", err)
+	}
+	return nil
+}
+
+func (m *Metadata) GetApplyPlan(ctx context.Context, tx types.Transaction, id string) (*types.ApplyPlan, error) {
+	var status string
+	var planJson sql.NullString
+	if err := tx.QueryRowContext(ctx, system.RebindQuery(m.dbType, `select status, plan from apply_plans where id = ?`),
+		id).Scan(&status, &planJson); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrApplyPlanNotFound
+		}
+		return nil, fmt.Errorf("error querying apply plan: %w", err)
+	}
+
+	plan := types.ApplyPlan{}
+	if err := json.Unmarshal([]byte(planJson.String), &plan); err != nil {
+		return nil, fmt.Errorf("error unmarshalling apply plan: %w", err)
+	}
+	plan.Status = types.ApplyPlanStatus(status)
+	return &plan, nil
+}
+
+// MarkApplyPlanExecuted updates the plan as executed. ErrApplyPlanExecuted is returned if the
+// plan was executed already, so a plan can be executed only once
+func (m *Metadata) MarkApplyPlanExecuted(ctx context.Context, tx types.Transaction, plan *types.ApplyPlan) error {
+	plan.Status = types.ApplyPlanExecuted
+	planJson, err := json.Marshal(plan)
+	if err != nil {
+		return fmt.Errorf("error marshalling apply plan: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, system.RebindQuery(m.dbType,
+		`update apply_plans set status = ?, plan = ? where id = ? and status = ?`),
+		string(types.ApplyPlanExecuted), string(planJson), plan.Id, string(types.ApplyPlanPending))
+	if err != nil {
+		return fmt.Errorf("error updating apply plan: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrApplyPlanExecuted
+	}
+	return nil
+}
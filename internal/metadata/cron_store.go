@@ -0,0 +1,114 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// GetProdAppCrons returns the scheduled tasks declared by the prod apps, keyed by app id.
+// Crons are recorded in the app metadata by the app audit, stage, preview and dev apps
+// do not run scheduled tasks. Paused and archived apps are skipped
+func (m *Metadata) GetProdAppCrons(ctx context.Context) (map[types.AppId][]types.CronDef, error) {
+	rows, err := m.db.QueryContext(ctx, system.RebindQuery(m.dbType, `select id, metadata, settings from apps where id like ?`),
+		types.ID_PREFIX_APP_PROD+"%")
+	if err != nil {
+		return nil, fmt.Errorf("error querying app crons: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	ret := map[types.AppId][]types.CronDef{}
+	for rows.Next() {
+		var id string
+		var metadataStr, settingsStr sql.NullString
+		if err := rows.Scan(&id, &metadataStr, &settingsStr); err != nil {
+			return nil, fmt.Errorf("error scanning app crons: %w", err)
+		}
+		if !metadataStr.Valid || metadataStr.String == "" {
+			continue
+		}
+		if settingsStr.Valid && settingsStr.String != "" {
+			var settings types.AppSettings
+			if err := json.Unmarshal([]byte(settingsStr.String), &settings); err != nil {
+				return nil, fmt.Errorf("error unmarshalling settings: %w", err)
+			}
+			if settings.Inactive() {
+				continue
+			}
+		}
+		var metadata types.AppMetadata
+		if err := json.Unmarshal([]byte(metadataStr.String), &metadata); err != nil {
+			return nil, fmt.Errorf("error unmarshalling metadata: %w", err)
+		}
+		if len(metadata.Crons) > 0 {
+			ret[types.AppId(id)] = metadata.Crons
+		}
+	}
+	return ret, rows.Err()
+}
+
+// GetCronStates returns the last run state of the scheduled tasks, keyed by app id and cron
+// name. If appId is empty, the state for all apps is returned
+func (m *Metadata) GetCronStates(ctx context.Context, appId types.AppId) (map[types.AppId]map[string]*types.CronState, error) {
+	query := `select app_id, name, last_run_time, last_job_id, skip_count from app_crons`
+	args := []any{}
+	if appId != "" {
+		query += ` where app_id = ?`
+		args = append(args, string(appId))
+	}
+	rows, err := m.db.QueryContext(ctx, system.RebindQuery(m.dbType, query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying cron state: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	ret := map[types.AppId]map[string]*types.CronState{}
+	for rows.Next() {
+		state := types.CronState{}
+		var lastRunTime sql.NullTime
+		if err := rows.Scan(&state.AppId, &state.Name, &lastRunTime, &state.LastJobId, &state.SkipCount); err != nil {
+			return nil, fmt.Errorf("error scanning cron state: %w", err)
+		}
+		if lastRunTime.Valid {
+			state.LastRunTime = &lastRunTime.Time
+		}
+		if ret[state.AppId] == nil {
+			ret[state.AppId] = map[string]*types.CronState{}
+		}
+		ret[state.AppId][state.Name] = &state
+	}
+	return ret, rows.Err()
+}
+
+// SaveCronState inserts or updates the last run state of a scheduled task
+func (m *Metadata) SaveCronState(ctx context.Context, state *types.CronState) error {
+	var lastRunTime any
+	if state.LastRunTime != nil {
+		lastRunTime = state.LastRunTime.UTC()
+	}
+	_, err := m.db.ExecContext(ctx, system.RebindQuery(m.dbType,
+		`insert into app_crons(app_id, name, last_run_time, last_job_id, skip_count) values (?, ?, ?, ?, ?)
+		 on conflict(app_id, name) do update set last_run_time = excluded.last_run_time,
+		 last_job_id = excluded.last_job_id, skip_count = excluded.skip_count`),
+		string(state.AppId), state.Name, lastRunTime, state.LastJobId, state.SkipCount)
+	if err != nil {
+		return fmt.Errorf("error saving cron state: %w", err)
+	}
+	return nil
+}
+
+// DeleteCronState removes the state of a scheduled task which is no longer declared by the app
+func (m *Metadata) DeleteCronState(ctx context.Context, appId types.AppId, name string) error {
+	if _, err := m.db.ExecContext(ctx, system.RebindQuery(m.dbType, `delete from app_crons where app_id = ? and name = ?`),
+		string(appId), name); err != nil {
+		return fmt.Errorf("error deleting cron state: %w", err)
+	}
+	return nil
+}
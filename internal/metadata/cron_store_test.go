@@ -0,0 +1,40 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestCronState(t *testing.T) {
+	m, cleanup := setupTestMetadata(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	runTime := time.Now().UTC().Truncate(time.Minute)
+	testutil.AssertNoError(t, m.SaveCronState(ctx, &types.CronState{AppId: "app_prd_1", Name: "refresh", LastRunTime: &runTime, LastJobId: "job_1"}))
+	testutil.AssertNoError(t, m.SaveCronState(ctx, &types.CronState{AppId: "app_prd_2", Name: "cleanup"}))
+
+	// Update of an existing entry
+	testutil.AssertNoError(t, m.SaveCronState(ctx, &types.CronState{AppId: "app_prd_1", Name: "refresh", LastRunTime: &runTime, LastJobId: "job_1", SkipCount: 2}))
+
+	states, err := m.GetCronStates(ctx, "")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "apps", 2, len(states))
+	state := states["app_prd_1"]["refresh"]
+	testutil.AssertEqualsString(t, "job", "job_1", state.LastJobId)
+	testutil.AssertEqualsInt(t, "skips", 2, state.SkipCount)
+	testutil.AssertEqualsBool(t, "run time", true, state.LastRunTime != nil && state.LastRunTime.Equal(runTime))
+	testutil.AssertEqualsBool(t, "no run time", true, states["app_prd_2"]["cleanup"].LastRunTime == nil)
+
+	testutil.AssertNoError(t, m.DeleteCronState(ctx, "app_prd_1", "refresh"))
+	states, err = m.GetCronStates(ctx, "app_prd_1")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "after delete", 0, len(states))
+}
@@ -45,12 +45,14 @@ func NewDbFs(logger *types.Logger, fileStore *FileStore, specFiles types.SpecFil
 }
 
 type DbFile struct {
-	name   string
-	fi     DbFileInfo
-	reader *DbFileReader
+	name    string
+	fi      DbFileInfo
+	reader  *DbFileReader
+	variant func(encoding string) ([]byte, error) // reads the precompressed variant, nil for spec files
 }
 
 var _ fs.File = (*DbFile)(nil)
+var _ appfs.VariantReader = (*DbFile)(nil)
 
 func NewDBFile(name string, compressionType string, data []byte, fi DbFileInfo) *DbFile {
 	reader := NewDbFileReader(compressionType, data)
@@ -79,6 +81,15 @@ func (f *DbFile) ReadCompressed() ([]byte, string, error) {
 	return f.reader.ReadCompressed()
 }
 
+func (f *DbFile) ReadVariant(encoding string) ([]byte, error) {
+	// Variants are stored by the content sha, the file name decides whether a variant is served.
+	// Files with the same content, like a script copied with an image extension, share the variant
+	if f.variant == nil || !appfs.PrecompressVariant(f.name, int(f.fi.len)) {
+		return nil, nil
+	}
+	return f.variant(encoding)
+}
+
 func (f *DbFile) Close() error {
 	return nil
 }
@@ -194,7 +205,11 @@ func (d *DbFs) Open(name string) (fs.File, error) {
 	if err != nil {
 		return nil, err
 	}
-	return NewDBFile(name, compressionType, fileBytes, fi), nil
+	file := NewDBFile(name, compressionType, fileBytes, fi)
+	file.variant = func(encoding string) ([]byte, error) {
+		return d.fileStore.GetFileVariant(fi.sha, encoding)
+	}
+	return file, nil
 }
 
 func (d *DbFs) ReadFile(name string) ([]byte, error) {
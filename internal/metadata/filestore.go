@@ -5,6 +5,7 @@ package metadata
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/sha256"
 	"database/sql"
@@ -52,6 +53,8 @@ type FileStore struct {
 	initTx   types.Transaction // This is the transaction for the initial setup of the app, before it is committed to the database.
 	// After app is committed to database, this is not used, auto-commit transactions are used for reads
 	fileCache *FileCache
+	// imageOptions are the options for creating the static image variants when adding a version
+	imageOptions appfs.ImageOptions
 }
 
 func NewFileStore(appId types.AppId, version int, metadata *Metadata, tx types.Transaction) (*FileStore, error) {
@@ -67,6 +70,11 @@ func NewFileStore(appId types.AppId, version int, metadata *Metadata, tx types.T
 	return &FileStore{appId: appId, version: version, metadata: metadata, db: metadata.db, initTx: tx, fileCache: fileCache}, nil
 }
 
+// SetImageOptions sets the options for creating the static image variants in AddAppVersionDisk
+func (f *FileStore) SetImageOptions(options appfs.ImageOptions) {
+	f.imageOptions = options
+}
+
 func (f *FileStore) IncrementAppVersion(ctx context.Context, tx types.Transaction, metadata *types.AppMetadata) error {
 	currentVersion := metadata.VersionMetadata.Version
 	nextVersion, err := f.GetHighestVersion(ctx, tx, f.appId)
@@ -101,6 +109,7 @@ type fileEntry struct {
 	uncompressedSz int
 	compression    string
 	compressed     []byte
+	gzipVariant    []byte // the precompressed gzip variant, nil if not created
 	shaExists      bool
 	err            error
 }
@@ -155,6 +164,11 @@ func (f *FileStore) AddAppVersionDisk(ctx context.Context, tx types.Transaction,
 		return nil
 	}
 
+	sourcePaths := make(map[string]struct{}, len(filePaths))
+	for _, p := range filePaths {
+		sourcePaths[p] = struct{}{}
+	}
+
 	// Build set of existing SHAs in the files table
 	existingSHAs := make(map[string]struct{})
 	rows, err := tx.QueryContext(ctx, "SELECT sha FROM files")
@@ -193,6 +207,14 @@ func (f *FileStore) AddAppVersionDisk(ctx context.Context, tx types.Transaction,
 	}
 	defer insertAppFileStmt.Close() //nolint:errcheck
 
+	insertVariantStmt, err := tx.PrepareContext(ctx, system.RebindQuery(f.metadata.dbType,
+		system.InsertIgnorePrefix(f.metadata.dbType)+" into file_variants (sha, encoding, content, create_time) values (?, ?, ?, "+
+			system.FuncNow(f.metadata.dbType)+") "+system.InsertIgnoreSuffix(f.metadata.dbType)))
+	if err != nil {
+		return err
+	}
+	defer insertVariantStmt.Close() //nolint:errcheck
+
 	numWorkers := f.metadata.config.System.FileWorkers
 	if numWorkers <= 0 {
 		numWorkers = 4
@@ -231,45 +253,47 @@ func (f *FileStore) AddAppVersionDisk(ctx context.Context, tx types.Transaction,
 					return
 				}
 
-				hash := sha256.Sum256(buf)
-				hashHex := hex.EncodeToString(hash[:])
-
-				entry := fileEntry{
-					path:           path,
-					sha:            hashHex,
-					uncompressedSz: len(buf),
+				entry, err := newFileEntry(path, buf, existingSHAs)
+				if err != nil {
+					select {
+					case results <- fileEntry{err: err}:
+					case <-done:
+					}
+					return
+				}
+				select {
+				case results <- entry:
+				case <-done:
+					return
 				}
 
-				if _, exists := existingSHAs[hashHex]; exists {
-					entry.shaExists = true
-				} else if len(buf) > COMPRESSION_THRESHOLD {
-					entry.compression = appfs.COMPRESSION_TYPE
-					var byteBuf bytes.Buffer
-					br := brotli.NewWriterLevel(&byteBuf, BROTLI_COMPRESSION_LEVEL)
-					if _, writeErr := br.Write(buf); writeErr != nil {
-						br.Close() //nolint:errcheck
+				if !f.imageOptions.Enabled() || !appfs.IsVariantSource(path) {
+					continue
+				}
+				variants, err := appfs.CreateImageVariants(ctx, path, buf, f.imageOptions)
+				if err != nil {
+					// The app works without the variants, the srcset function lists only the image
+					f.metadata.Warn().Err(err).Str("path", path).Msg("error creating image variants, skipping")
+					continue
+				}
+				for _, variant := range variants {
+					if _, exists := sourcePaths[variant.Name]; exists {
+						// A file in the app source with the variant name is not replaced
+						continue
+					}
+					entry, err := newFileEntry(variant.Name, variant.Data, existingSHAs)
+					if err != nil {
 						select {
-						case results <- fileEntry{err: writeErr}:
+						case results <- fileEntry{err: err}:
 						case <-done:
 						}
 						return
 					}
-					if closeErr := br.Close(); closeErr != nil {
-						select {
-						case results <- fileEntry{err: closeErr}:
-						case <-done:
-						}
+					select {
+					case results <- entry:
+					case <-done:
 						return
 					}
-					entry.compressed = byteBuf.Bytes()
-				} else {
-					entry.compressed = buf
-				}
-
-				select {
-				case results <- entry:
-				case <-done:
-					return
 				}
 			}
 		}()
@@ -281,16 +305,29 @@ func (f *FileStore) AddAppVersionDisk(ctx context.Context, tx types.Transaction,
 	}()
 
 	// Consume results and insert into DB as they arrive
+	addedPaths := make(map[string]struct{}, len(filePaths))
 	for entry := range results {
 		if entry.err != nil {
 			close(done)
 			return entry.err
 		}
+		if _, exists := addedPaths[entry.path]; exists {
+			// Images with the same name and different extensions have the same WebP and AVIF variant names
+			f.metadata.Warn().Str("path", entry.path).Msg("duplicate image variant, skipping")
+			continue
+		}
+		addedPaths[entry.path] = struct{}{}
 		if !entry.shaExists {
 			if _, err := insertFileStmt.ExecContext(ctx, entry.sha, entry.compression, entry.compressed); err != nil {
 				close(done)
 				return fmt.Errorf("error inserting file: %w", err)
 			}
+			if entry.gzipVariant != nil {
+				if _, err := insertVariantStmt.ExecContext(ctx, entry.sha, appfs.GZIP_ENCODING, entry.gzipVariant); err != nil {
+					close(done)
+					return fmt.Errorf("error inserting file variant: %w", err)
+				}
+			}
 		}
 		if _, err := insertAppFileStmt.ExecContext(ctx, f.appId, metadata.VersionMetadata.Version, entry.path, entry.sha, entry.uncompressedSz); err != nil {
 			close(done)
@@ -301,6 +338,113 @@ func (f *FileStore) AddAppVersionDisk(ctx context.Context, tx types.Transaction,
 	return nil
 }
 
+// newFileEntry returns the entry for a file, with the compressed content if the file is not
+// already present in the files table
+func newFileEntry(path string, buf []byte, existingSHAs map[string]struct{}) (fileEntry, error) {
+	hash := sha256.Sum256(buf)
+	hashHex := hex.EncodeToString(hash[:])
+
+	entry := fileEntry{
+		path:           path,
+		sha:            hashHex,
+		uncompressedSz: len(buf),
+	}
+
+	if _, exists := existingSHAs[hashHex]; exists {
+		entry.shaExists = true
+		return entry, nil
+	}
+	if len(buf) <= COMPRESSION_THRESHOLD {
+		entry.compressed = buf
+		return entry, nil
+	}
+
+	entry.compression = appfs.COMPRESSION_TYPE
+	var byteBuf bytes.Buffer
+	br := brotli.NewWriterLevel(&byteBuf, BROTLI_COMPRESSION_LEVEL)
+	if _, err := br.Write(buf); err != nil {
+		br.Close() //nolint:errcheck
+		return entry, err
+	}
+	if err := br.Close(); err != nil {
+		return entry, err
+	}
+	entry.compressed = byteBuf.Bytes()
+
+	if appfs.PrecompressVariant(path, len(buf)) {
+		// The gzip variant is served to clients which do not accept brotli
+		variant, err := gzipVariant(buf)
+		if err != nil {
+			return entry, err
+		}
+		entry.gzipVariant = variant
+	}
+	return entry, nil
+}
+
+// gzipVariant returns the gzip compressed data, nil if compression does not reduce the size
+func gzipVariant(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := gz.Write(data); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	if buf.Len() >= len(data) {
+		return nil, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// GetFileVariant returns the precompressed variant of the file for the encoding, nil if there is
+// no variant. Files added before variants were supported and small files do not have a variant
+func (f *FileStore) GetFileVariant(sha, encoding string) ([]byte, error) {
+	ctx := context.Background()
+	cacheKey := sha + "." + encoding
+	if f.fileCache != nil {
+		content, _, err := f.fileCache.GetCachedFile(ctx, cacheKey)
+		if err == nil {
+			return content, nil
+		}
+		if err != sql.ErrNoRows {
+			f.metadata.Warn().Err(err).Msgf("error reading file cache for %s, falling back to metadata db", cacheKey)
+		}
+	}
+
+	var tx types.Transaction
+	if f.initTx.IsInitialized() {
+		tx = f.initTx
+	} else {
+		var err error
+		tx, err = f.metadata.BeginTransaction(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("error starting transaction: %w", err)
+		}
+		defer tx.Rollback() //nolint:errcheck
+	}
+
+	var content []byte
+	row := tx.QueryRowContext(ctx, system.RebindQuery(f.metadata.dbType, "SELECT content FROM file_variants where sha = ? and encoding = ?"), sha, encoding)
+	if err := row.Scan(&content); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error querying file variants table: %w", err)
+	}
+
+	if f.fileCache != nil {
+		if err := f.fileCache.AddCache(ctx, cacheKey, encoding, content); err != nil {
+			f.metadata.Warn().Err(err).Msgf("error adding file variant %s to cache", cacheKey)
+		}
+	}
+	return content, nil
+}
+
 func (f *FileStore) GetFileBySha(sha string) ([]byte, string, error) {
 	var tx types.Transaction
 	if f.initTx.IsInitialized() {
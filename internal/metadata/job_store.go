@@ -0,0 +1,245 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const jobColumns = `id, app_id, func_name, args, status, attempts, max_retries, error, result, user_id, run_after, create_time, update_time, end_time`
+
+// InsertJob adds a new pending job to the queue. Times are passed in (UTC) instead of using
+// the db now function, so that they compare consistently with the claim query
+func (m *Metadata) InsertJob(ctx context.Context, job *types.JobEntry) error {
+	now := time.Now().UTC()
+	job.CreateTime = now
+	job.UpdateTime = now
+	if job.RunAfter.IsZero() {
+		job.RunAfter = now
+	}
+	job.RunAfter = job.RunAfter.UTC()
+	job.Status = types.JobStatusPending
+
+	_, err := m.db.ExecContext(ctx, system.RebindQuery(m.dbType,
+		`insert into jobs(`+jobColumns+`) values(?, ?, ?, ?, ?, 0, ?, '', null, ?, ?, ?, ?, null)`),
+		job.Id, string(job.AppId), job.FuncName, job.Args, string(job.Status), job.MaxRetries, job.UserId, job.RunAfter, now, now)
+	if err != nil {
+		return fmt.Errorf("error inserting job: %w", err)
+	}
+	return nil
+}
+
+func (m *Metadata) GetJob(ctx context.Context, id string) (*types.JobEntry, error) {
+	row := m.db.QueryRowContext(ctx, system.RebindQuery(m.dbType, `select `+jobColumns+` from jobs where id = ?`), id)
+	job, err := scanJob(row.Scan)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("job %s not found", id)
+		}
+		return nil, fmt.Errorf("error querying job: %w", err)
+	}
+	return job, nil
+}
+
+// ListJobs returns the most recent jobs for the app, optionally filtered by status
+func (m *Metadata) ListJobs(ctx context.Context, appId types.AppId, status types.JobStatus, limit int) ([]*types.JobEntry, error) {
+	query := `select ` + jobColumns + ` from jobs where app_id = ?`
+	args := []any{string(appId)}
+	if status != "" {
+		query += ` and status = ?`
+		args = append(args, string(status))
+	}
+	query += ` order by create_time desc limit ?`
+	args = append(args, limit)
+
+	rows, err := m.db.QueryContext(ctx, system.RebindQuery(m.dbType, query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying jobs: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	jobs := []*types.JobEntry{}
+	for rows.Next() {
+		job, err := scanJob(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// ClaimJob picks the next runnable job, skipping the apps in skipApps. A job is runnable
+// if it is pending and its run_after time has passed. While a job is running, run_after
+// holds its lease expiry: a running job whose lease has expired (the server running it
+// went away) is claimed again. The claim is a conditional update, so concurrent workers
+// across servers never run the same attempt. Returns nil if there is no runnable job
+func (m *Metadata) ClaimJob(ctx context.Context, skipApps map[types.AppId]bool, lease time.Duration) (*types.JobEntry, error) {
+	now := time.Now().UTC()
+	rows, err := m.db.QueryContext(ctx, system.RebindQuery(m.dbType,
+		`select `+jobColumns+` from jobs where status in (?, ?) and run_after <= ? order by run_after limit 50`),
+		string(types.JobStatusPending), string(types.JobStatusRunning), now)
+	if err != nil {
+		return nil, fmt.Errorf("error querying runnable jobs: %w", err)
+	}
+	candidates := []*types.JobEntry{}
+	for rows.Next() {
+		job, err := scanJob(rows.Scan)
+		if err != nil {
+			rows.Close() //nolint:errcheck
+			return nil, fmt.Errorf("error scanning job: %w", err)
+		}
+		if !skipApps[job.AppId] {
+			candidates = append(candidates, job)
+		}
+	}
+	rows.Close() //nolint:errcheck
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, job := range candidates {
+		leaseExpiry := now.Add(lease)
+		result, err := m.db.ExecContext(ctx, system.RebindQuery(m.dbType,
+			`update jobs set status = ?, attempts = attempts + 1, run_after = ?, update_time = ? `+
+				`where id = ? and status = ? and attempts = ?`),
+			string(types.JobStatusRunning), leaseExpiry, now, job.Id, string(job.Status), job.Attempts)
+		if err != nil {
+			return nil, fmt.Errorf("error claiming job: %w", err)
+		}
+		rowsAffected, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("error getting rows affected: %w", err)
+		}
+		if rowsAffected == 0 {
+			continue // claimed by another worker
+		}
+		job.Status = types.JobStatusRunning
+		job.Attempts++
+		job.RunAfter = leaseExpiry
+		job.UpdateTime = now
+		return job, nil
+	}
+	return nil, nil
+}
+
+// CompleteJob records the final state of a running job attempt
+func (m *Metadata) CompleteJob(ctx context.Context, job *types.JobEntry) error {
+	now := time.Now().UTC()
+	var endTime any
+	if job.Done() {
+		endTime = now
+		job.EndTime = &now
+	}
+	job.UpdateTime = now
+	var result any
+	if job.Result != "" {
+		result = job.Result
+	}
+
+	// The status condition makes sure a job cancelled while running stays cancelled
+	_, err := m.db.ExecContext(ctx, system.RebindQuery(m.dbType,
+		`update jobs set status = ?, error = ?, result = ?, run_after = ?, update_time = ?, end_time = ? `+
+			`where id = ? and status = ? and attempts = ?`),
+		string(job.Status), job.Error, result, job.RunAfter.UTC(), now, endTime, job.Id, string(types.JobStatusRunning), job.Attempts)
+	if err != nil {
+		return fmt.Errorf("error updating job: %w", err)
+	}
+	return nil
+}
+
+// ExtendJobLease updates the lease expiry for a running job attempt
+func (m *Metadata) ExtendJobLease(ctx context.Context, job *types.JobEntry, lease time.Duration) error {
+	leaseExpiry := time.Now().UTC().Add(lease)
+	result, err := m.db.ExecContext(ctx, system.RebindQuery(m.dbType,
+		`update jobs set run_after = ? where id = ? and status = ? and attempts = ?`),
+		leaseExpiry, job.Id, string(types.JobStatusRunning), job.Attempts)
+	if err != nil {
+		return fmt.Errorf("error extending job lease: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("job %s is no longer running", job.Id)
+	}
+	job.RunAfter = leaseExpiry
+	return nil
+}
+
+// RequeueJob puts a running job back in the queue without counting the attempt, used when
+// the attempt was interrupted by the server stopping
+func (m *Metadata) RequeueJob(ctx context.Context, job *types.JobEntry) error {
+	now := time.Now().UTC()
+	_, err := m.db.ExecContext(ctx, system.RebindQuery(m.dbType,
+		`update jobs set status = ?, attempts = attempts - 1, run_after = ?, update_time = ? `+
+			`where id = ? and status = ? and attempts = ?`),
+		string(types.JobStatusPending), now, now, job.Id, string(types.JobStatusRunning), job.Attempts)
+	if err != nil {
+		return fmt.Errorf("error requeuing job: %w", err)
+	}
+	return nil
+}
+
+// CancelJob cancels a job which has not completed yet. A running attempt is not
+// interrupted, but its result is discarded and it is not retried
+func (m *Metadata) CancelJob(ctx context.Context, appId types.AppId, id string) error {
+	now := time.Now().UTC()
+	result, err := m.db.ExecContext(ctx, system.RebindQuery(m.dbType,
+		`update jobs set status = ?, update_time = ?, end_time = ? where id = ? and app_id = ? and status in (?, ?)`),
+		string(types.JobStatusCancelled), now, now, id, string(appId), string(types.JobStatusPending), string(types.JobStatusRunning))
+	if err != nil {
+		return fmt.Errorf("error cancelling job: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error getting rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return fmt.Errorf("job %s not found or already completed", id)
+	}
+	return nil
+}
+
+// CleanupOldJobs deletes the completed jobs older than the retention days
+func (m *Metadata) CleanupOldJobs(ctx context.Context, retentionDays int) error {
+	if retentionDays <= 0 {
+		return nil
+	}
+	cutoff := time.Now().UTC().Add(-time.Duration(retentionDays) * 24 * time.Hour)
+	_, err := m.db.ExecContext(ctx, system.RebindQuery(m.dbType,
+		`delete from jobs where status in (?, ?, ?) and end_time < ?`),
+		string(types.JobStatusSucceeded), string(types.JobStatusFailed), string(types.JobStatusCancelled), cutoff)
+	if err != nil {
+		return fmt.Errorf("error cleaning up old jobs: %w", err)
+	}
+	return nil
+}
+
+func scanJob(scan func(dest ...any) error) (*types.JobEntry, error) {
+	job := types.JobEntry{}
+	var args, result sql.NullString
+	var status string
+	var endTime sql.NullTime
+	if err := scan(&job.Id, &job.AppId, &job.FuncName, &args, &status, &job.Attempts, &job.MaxRetries,
+		&job.Error, &result, &job.UserId, &job.RunAfter, &job.CreateTime, &job.UpdateTime, &endTime); err != nil {
+		return nil, err
+	}
+	job.Status = types.JobStatus(strings.TrimSpace(status))
+	job.Args = args.String
+	job.Result = result.String
+	if endTime.Valid {
+		job.EndTime = &endTime.Time
+	}
+	return &job, nil
+}
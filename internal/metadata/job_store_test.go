@@ -0,0 +1,112 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestJobClaimComplete(t *testing.T) {
+	m, cleanup := setupTestMetadata(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	job := &types.JobEntry{Id: "job_1", AppId: "app_prd_1", FuncName: "report", Args: `[1, "a"]`, MaxRetries: 2, UserId: "u1"}
+	testutil.AssertNoError(t, m.InsertJob(ctx, job))
+	delayed := &types.JobEntry{Id: "job_2", AppId: "app_prd_1", FuncName: "report", Args: "null", RunAfter: time.Now().Add(time.Hour)}
+	testutil.AssertNoError(t, m.InsertJob(ctx, delayed))
+
+	// Skipped apps are not claimed
+	claimed, err := m.ClaimJob(ctx, map[types.AppId]bool{"app_prd_1": true}, time.Minute)
+	testutil.AssertNoError(t, err)
+	if claimed != nil {
+		t.Fatalf("expected no job for skipped app, got %s", claimed.Id)
+	}
+
+	claimed, err = m.ClaimJob(ctx, nil, time.Minute)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "id", "job_1", claimed.Id)
+	testutil.AssertEqualsString(t, "status", string(types.JobStatusRunning), string(claimed.Status))
+	testutil.AssertEqualsInt(t, "attempts", 1, claimed.Attempts)
+	testutil.AssertEqualsString(t, "args", `[1, "a"]`, claimed.Args)
+
+	// The running job is leased, the other job is delayed
+	next, err := m.ClaimJob(ctx, nil, time.Minute)
+	testutil.AssertNoError(t, err)
+	if next != nil {
+		t.Fatalf("expected no runnable job, got %s", next.Id)
+	}
+
+	claimed.Status = types.JobStatusSucceeded
+	claimed.Result = `{"rows": 10}`
+	testutil.AssertNoError(t, m.CompleteJob(ctx, claimed))
+
+	got, err := m.GetJob(ctx, "job_1")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "status", string(types.JobStatusSucceeded), string(got.Status))
+	testutil.AssertEqualsString(t, "result", `{"rows": 10}`, got.Result)
+	testutil.AssertEqualsString(t, "user", "u1", got.UserId)
+	testutil.AssertEqualsBool(t, "end time", true, got.EndTime != nil)
+
+	jobs, err := m.ListJobs(ctx, "app_prd_1", "", 10)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "all jobs", 2, len(jobs))
+	jobs, err = m.ListJobs(ctx, "app_prd_1", types.JobStatusPending, 10)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "pending jobs", 1, len(jobs))
+	testutil.AssertEqualsString(t, "pending id", "job_2", jobs[0].Id)
+
+	// Cleanup removes completed jobs past the retention
+	_, err = m.db.Exec("update jobs set end_time = ? where id = ?", time.Now().UTC().Add(-48*time.Hour), "job_1")
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, m.CleanupOldJobs(ctx, 1))
+	_, err = m.GetJob(ctx, "job_1")
+	testutil.AssertErrorContains(t, err, "not found")
+	_, err = m.GetJob(ctx, "job_2")
+	testutil.AssertNoError(t, err)
+}
+
+func TestJobLeaseExpiryAndCancel(t *testing.T) {
+	m, cleanup := setupTestMetadata(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	testutil.AssertNoError(t, m.InsertJob(ctx, &types.JobEntry{Id: "job_1", AppId: "app_prd_1", FuncName: "f", Args: "[]"}))
+
+	// A negative lease makes the running job immediately claimable again, like an orphaned attempt
+	first, err := m.ClaimJob(ctx, nil, -time.Second)
+	testutil.AssertNoError(t, err)
+	second, err := m.ClaimJob(ctx, nil, time.Minute)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "reclaimed", "job_1", second.Id)
+	testutil.AssertEqualsInt(t, "attempts", 2, second.Attempts)
+
+	// The stale attempt cannot overwrite the state of the new one
+	first.Status = types.JobStatusFailed
+	testutil.AssertNoError(t, m.CompleteJob(ctx, first))
+	got, err := m.GetJob(ctx, "job_1")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "status", string(types.JobStatusRunning), string(got.Status))
+
+	// Requeue does not count the interrupted attempt
+	testutil.AssertNoError(t, m.RequeueJob(ctx, second))
+	got, err = m.GetJob(ctx, "job_1")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "requeued", string(types.JobStatusPending), string(got.Status))
+	testutil.AssertEqualsInt(t, "requeued attempts", 1, got.Attempts)
+
+	testutil.AssertErrorContains(t, m.CancelJob(ctx, "app_prd_other", "job_1"), "not found")
+	testutil.AssertNoError(t, m.CancelJob(ctx, "app_prd_1", "job_1"))
+	testutil.AssertErrorContains(t, m.CancelJob(ctx, "app_prd_1", "job_1"), "already completed")
+	claimed, err := m.ClaimJob(ctx, nil, time.Minute)
+	testutil.AssertNoError(t, err)
+	if claimed != nil {
+		t.Fatalf("cancelled job should not be claimed")
+	}
+}
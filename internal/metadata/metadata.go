@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -25,7 +26,7 @@ import (
 	_ "modernc.org/sqlite"
 )
 
-const CURRENT_DB_VERSION = 20
+const CURRENT_DB_VERSION = 27
 
 // ErrAppNotFound is returned when an app entry does not exist in the metadata store.
 var ErrAppNotFound = errors.New("app not found")
@@ -43,6 +44,10 @@ type Metadata struct {
 	AppNotifyFunc      func(types.AppUpdatePayload)
 	ConfigNotifyFunc   func(types.ConfigUpdatePayload)
 	ProviderNotifyFunc func(types.ProviderUpdatePayload)
+	KeyringNotifyFunc  func(types.KeyringUpdatePayload)
+	// ResyncNotifyFunc is called when the postgres listener reconnects. Notifications sent
+	// while the listener was disconnected are lost, the cached state has to be reloaded
+	ResyncNotifyFunc func()
 
 	// fileCache is the shared file cache, created lazily on first use. A single
 	// instance is shared by all FileStores since each cache instance holds its
@@ -50,10 +55,17 @@ type Metadata struct {
 	fileCacheOnce sync.Once
 	fileCache     *FileCache
 	fileCacheErr  error
+
+	// appLocks are the app locks for sqlite, by app path
+	appLocksMu sync.Mutex
+	appLocks   map[string]types.AppLock
 }
 
 const pg_listen_channel = "openrun_events"
 
+// schemaUpgradeLockId is the postgres advisory lock key used to serialize the schema upgrade
+const schemaUpgradeLockId = 0x6f70656e72756e
+
 // NewMetadata creates a new metadata persistence layer
 func NewMetadata(logger *types.Logger, config *types.ServerConfig) (*Metadata, error) {
 	db, dbType, err := system.InitDBConnection(logger, config.Metadata.DBConnection, "metadata", system.DB_SQLITE_POSTGRES, &config.Metadata)
@@ -147,6 +159,16 @@ func NewMetadata(logger *types.Logger, config *types.ServerConfig) (*Metadata, e
 				if m.ProviderNotifyFunc != nil {
 					m.ProviderNotifyFunc(updateMsg.Payload)
 				}
+			case types.MessageTypeKeyringUpdate:
+				updateMsg := types.KeyringUpdateMessage{}
+				err := json.Unmarshal([]byte(notification.Payload), &updateMsg)
+				if err != nil {
+					m.Error().Err(err).Msg("error unmarshalling keyring update message")
+					return err
+				}
+				if m.KeyringNotifyFunc != nil {
+					m.KeyringNotifyFunc(updateMsg.Payload)
+				}
 			default:
 				m.Error().Msgf("unknown message type: %s", msg.MessageType)
 			}
@@ -154,7 +176,7 @@ func NewMetadata(logger *types.Logger, config *types.ServerConfig) (*Metadata, e
 			return nil
 		}
 
-		m.pgListener.Handle(pg_listen_channel, handler)
+		m.pgListener.Handle(pg_listen_channel, &listenHandler{HandlerFunc: handler, metadata: m})
 		listenCtx, listenCancel := context.WithCancel(context.Background())
 		m.pgListenerCancel = listenCancel
 		go func() {
@@ -169,11 +191,39 @@ func NewMetadata(logger *types.Logger, config *types.ServerConfig) (*Metadata, e
 	return m, nil
 }
 
+// listenHandler handles the notifications and the listener (re)connects. pgxlisten calls
+// HandleBacklog on every connect, before any notification is received on the connection
+type listenHandler struct {
+	pgxlisten.HandlerFunc
+	metadata  *Metadata
+	connected bool
+}
+
+var _ pgxlisten.BacklogHandler = (*listenHandler)(nil)
+
+func (h *listenHandler) HandleBacklog(ctx context.Context, channel string, conn *pgx.Conn) error {
+	if !h.connected {
+		// Nothing was missed before the first connect, the state was loaded at startup
+		h.connected = true
+		return nil
+	}
+	h.metadata.Warn().Msg("postgres listener reconnected, reloading state updated by other servers")
+	if h.metadata.ResyncNotifyFunc != nil {
+		h.metadata.ResyncNotifyFunc()
+	}
+	return nil
+}
+
 // IsLeader returns true if the current server is the leader
 func (m *Metadata) IsLeader() bool {
 	return m.leaderElection.IsLeader()
 }
 
+// DBStats returns the connection pool stats for the metadata database
+func (m *Metadata) DBStats() sql.DBStats {
+	return m.db.Stats()
+}
+
 // Close stops background goroutines owned by Metadata (leader election and
 // the postgres listener) and closes the database connection pools.
 func (m *Metadata) Close() {
@@ -249,6 +299,28 @@ func (m *Metadata) NotifyConfigUpdate() error {
 	return err
 }
 
+// NotifyKeyringUpdate sends a notification through the postgres listener that the keyring has been updated
+func (m *Metadata) NotifyKeyringUpdate() error {
+	if m.dbType != system.DB_TYPE_POSTGRES {
+		return nil
+	}
+
+	msg := types.KeyringUpdateMessage{
+		MessageType: types.MessageTypeKeyringUpdate,
+		Payload: types.KeyringUpdatePayload{
+			ServerId: types.CurrentServerId,
+		},
+	}
+
+	payloadBytes, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.db.Exec("select pg_notify($1,$2)", pg_listen_channel, string(payloadBytes))
+	return err
+}
+
 func (m *Metadata) VersionUpgrade(config *types.ServerConfig) error {
 	version := 0
 	row := m.db.QueryRow("SELECT version, last_upgraded FROM version")
@@ -275,6 +347,21 @@ func (m *Metadata) VersionUpgrade(config *types.ServerConfig) error {
 	}
 	defer tx.Rollback() //nolint:errcheck
 
+	if m.dbType == system.DB_TYPE_POSTGRES {
+		// Multiple servers sharing the database could start together, serialize the upgrade and
+		// recheck the version after the lock, in case another server has done the upgrade
+		if _, err := tx.ExecContext(ctx, "select pg_advisory_xact_lock($1)", schemaUpgradeLockId); err != nil {
+			return fmt.Errorf("error locking db for upgrade: %w", err)
+		}
+		if version, err = m.lockedVersion(ctx, tx); err != nil {
+			return err
+		}
+		if version >= CURRENT_DB_VERSION {
+			m.Info().Msgf("DB version %d already upgraded by another server", version)
+			return nil
+		}
+	}
+
 	if version < 1 {
 		m.Info().Msg("No version, initializing")
 		if _, err := tx.ExecContext(ctx, `create table version (version int, last_upgraded `+system.MapDataType(m.dbType, "datetime")+")"); err != nil {
@@ -524,6 +611,110 @@ func (m *Metadata) VersionUpgrade(config *types.ServerConfig) error {
 		}
 	}
 
+	if version < 21 {
+		m.Info().Msg("Upgrading to version 21")
+		if _, err := tx.ExecContext(ctx, `create table jobs (id text not null, app_id text not null, func_name text not null, `+
+			`args json, status text not null, attempts int not null default 0, max_retries int not null default 0, `+
+			`error text not null default '', result json, user_id text not null default '', `+
+			`run_after `+system.MapDataType(m.dbType, "datetime")+`, create_time `+system.MapDataType(m.dbType, "datetime")+
+			`, update_time `+system.MapDataType(m.dbType, "datetime")+`, end_time `+system.MapDataType(m.dbType, "datetime")+
+			`, PRIMARY KEY(id))`); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `create index idx_jobs_status on jobs(status, run_after)`); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `create index idx_jobs_app on jobs(app_id, create_time)`); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `update version set version=21, last_upgraded=`+system.FuncNow(m.dbType)); err != nil {
+			return err
+		}
+	}
+
+	if version < 22 {
+		m.Info().Msg("Upgrading to version 22")
+		if _, err := tx.ExecContext(ctx, `create table app_crons (app_id text not null, name text not null, `+
+			`last_run_time `+system.MapDataType(m.dbType, "datetime")+`, last_job_id text not null default '', `+
+			`skip_count int not null default 0, PRIMARY KEY(app_id, name))`); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `update version set version=22, last_upgraded=`+system.FuncNow(m.dbType)); err != nil {
+			return err
+		}
+	}
+
+	if version < 23 {
+		m.Info().Msg("Upgrading to version 23")
+		if _, err := tx.ExecContext(ctx, `create table file_variants (sha text not null, encoding text not null, content `+
+			system.MapDataType(m.dbType, "blob")+`, create_time `+system.MapDataType(m.dbType, "datetime")+`, PRIMARY KEY(sha, encoding))`); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `update version set version=23, last_upgraded=`+system.FuncNow(m.dbType)); err != nil {
+			return err
+		}
+	}
+
+	if version < 24 {
+		m.Info().Msg("Upgrading to version 24")
+		if _, err := tx.ExecContext(ctx, `create table sync_runs (id text not null, sync_id text not null, start_time `+
+			system.MapDataType(m.dbType, "datetime")+`, duration_ms int not null default 0, commit_id text not null default '', `+
+			`error text not null default '', changes json, PRIMARY KEY(id))`); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `create index idx_sync_runs_sync on sync_runs(sync_id, start_time)`); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `update version set version=24, last_upgraded=`+system.FuncNow(m.dbType)); err != nil {
+			return err
+		}
+	}
+
+	if version < 25 {
+		m.Info().Msg("Upgrading to version 25")
+		if _, err := tx.ExecContext(ctx, `alter table apps add column row_version int not null default 0`); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `update version set version=25, last_upgraded=`+system.FuncNow(m.dbType)); err != nil {
+			return err
+		}
+	}
+
+	if version < 26 {
+		m.Info().Msg("Upgrading to version 26")
+		if m.dbType == system.DB_TYPE_POSTGRES {
+			// The app locks for sqlite are in memory, sqlite is used by a single server
+			if _, err := tx.ExecContext(ctx, `create table app_locks (path text not null, holder_id text not null, hostname text not null, `+
+				`operation text not null, user_id text not null, acquire_time bigint not null, expire_time bigint not null, PRIMARY KEY(path))`); err != nil {
+				return err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, `update version set version=26, last_upgraded=`+system.FuncNow(m.dbType)); err != nil {
+			return err
+		}
+	}
+
+	if version < 27 {
+		m.Info().Msg("Upgrading to version 27")
+		if _, err := tx.ExecContext(ctx, `create table apply_plans (id text not null, create_time `+system.MapDataType(m.dbType, "datetime")+
+			`, user_id text not null default '', status text not null, plan json, PRIMARY KEY(id))`); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `update version set version=27, last_upgraded=`+system.FuncNow(m.dbType)); err != nil {
+			return err
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return err
 	}
@@ -531,6 +722,23 @@ func (m *Metadata) VersionUpgrade(config *types.ServerConfig) error {
 	return nil
 }
 
+// lockedVersion returns the db version read within the upgrade transaction, zero if the version
+// table is not present
+func (m *Metadata) lockedVersion(ctx context.Context, tx types.Transaction) (int, error) {
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "select to_regclass('version') is not null").Scan(&exists); err != nil {
+		return 0, fmt.Errorf("error checking version table: %w", err)
+	}
+	if !exists {
+		return 0, nil
+	}
+	version := 0
+	if err := tx.QueryRowContext(ctx, "SELECT version FROM version").Scan(&version); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("error reading db version: %w", err)
+	}
+	return version, nil
+}
+
 func (m *Metadata) initFileTables(ctx context.Context, tx types.Transaction) error {
 	if _, err := tx.ExecContext(ctx, `create table files (sha text, compression_type text, content `+system.MapDataType(m.dbType, "blob")+`, create_time `+system.MapDataType(m.dbType, "datetime")+", PRIMARY KEY(sha))"); err != nil {
 		return err
@@ -647,6 +855,7 @@ func (m *Metadata) CreateApp(ctx context.Context, tx types.Transaction, app *typ
 	if err != nil {
 		return fmt.Errorf("error inserting app: %w", err)
 	}
+	app.RowVersion = 0 // the entry could be copied from another app
 	return nil
 }
 
@@ -660,7 +869,7 @@ func (m *Metadata) GetAppEntry(ctx context.Context, pathDomain types.AppPathDoma
 }
 
 func (m *Metadata) GetAppEntryTx(ctx context.Context, tx types.Transaction, pathDomain types.AppPathDomain) (*types.AppEntry, error) {
-	stmt, err := tx.PrepareContext(ctx, system.RebindQuery(m.dbType, `select id, path, domain, main_app, linked_app_path, source_url, is_dev, user_id, create_time, update_time, settings, metadata from apps where path = ? and domain = ?`))
+	stmt, err := tx.PrepareContext(ctx, system.RebindQuery(m.dbType, `select id, path, domain, main_app, linked_app_path, source_url, is_dev, user_id, create_time, update_time, settings, metadata, row_version from apps where path = ? and domain = ?`))
 	if err != nil {
 		return nil, fmt.Errorf("error preparing statement: %w", err)
 	}
@@ -669,7 +878,7 @@ func (m *Metadata) GetAppEntryTx(ctx context.Context, tx types.Transaction, path
 	row := stmt.QueryRow(pathDomain.Path, pathDomain.Domain)
 	var app types.AppEntry
 	var linkedAppPath, settings, metadata sql.NullString
-	err = row.Scan(&app.Id, &app.Path, &app.Domain, &app.MainApp, &linkedAppPath, &app.SourceUrl, &app.IsDev, &app.UserID, &app.CreateTime, &app.UpdateTime, &settings, &metadata)
+	err = row.Scan(&app.Id, &app.Path, &app.Domain, &app.MainApp, &linkedAppPath, &app.SourceUrl, &app.IsDev, &app.UserID, &app.CreateTime, &app.UpdateTime, &settings, &metadata, &app.RowVersion)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, ErrAppNotFound
@@ -758,13 +967,25 @@ func (m *Metadata) GetAppsForDomain(domain string) ([]string, error) {
 }
 
 func (m *Metadata) GetAllApps(includeInternal bool) ([]types.AppInfo, error) {
+	ctx := context.Background()
+	tx, err := m.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	return m.GetAllAppsTx(ctx, tx, includeInternal)
+}
+
+// GetAllAppsTx is GetAllApps within the transaction, the apps created in the transaction are
+// included
+func (m *Metadata) GetAllAppsTx(ctx context.Context, tx types.Transaction, includeInternal bool) ([]types.AppInfo, error) {
 	sqlStr := `select domain, path, is_dev, id, main_app, linked_app_path, settings, metadata, source_url, update_time, user_id from apps`
 	if !includeInternal {
 		sqlStr += ` where main_app = ''`
 	}
 	sqlStr += ` order by create_time desc`
 
-	stmt, err := m.db.Prepare(sqlStr)
+	stmt, err := tx.PrepareContext(ctx, sqlStr)
 	if err != nil {
 		return nil, fmt.Errorf("error preparing statement: %w", err)
 	}
@@ -808,6 +1029,11 @@ func (m *Metadata) GetAllApps(includeInternal bool) ([]types.AppInfo, error) {
 		}
 
 		retainVersions := m.config.AppConfig.FS.RetainVersions
+		for _, tag := range slices.Sorted(slices.Values(settings.Tags)) {
+			if val, ok := tagRetainVersions(m.config.TagConfig[tag]); ok {
+				retainVersions = val
+			}
+		}
 		if val, ok := metadata.AppConfig["fs.retain_versions"]; ok {
 			if intVal, err := strconv.Atoi(val); err == nil && intVal >= 0 {
 				retainVersions = intVal
@@ -818,7 +1044,7 @@ func (m *Metadata) GetAllApps(includeInternal bool) ([]types.AppInfo, error) {
 			types.AppId(mainApp), linkedPath, metadata.AuthnType, sourceUrl, metadata.Spec,
 			metadata.VersionMetadata.Version, metadata.VersionMetadata.GitCommit, metadata.VersionMetadata.GitMessage,
 			metadata.VersionMetadata.GitBranch, types.StripQuotes(metadata.AppConfig["star_base"]), *updateTime, retainVersions,
-			metadata.AppliedSyncId, userId.String))
+			metadata.AppliedSyncId, userId.String, settings.Tags))
 	}
 	if err := rows.Err(); err != nil {
 		return nil, fmt.Errorf("error iterating rows: %w", err)
@@ -859,7 +1085,7 @@ func (m *Metadata) GetVersionUsers() (map[types.AppId]map[int]string, error) {
 
 // GetLinkedApps gets all the apps linked to the given main app (staging and preview apps)
 func (m *Metadata) GetLinkedApps(ctx context.Context, tx types.Transaction, mainAppId types.AppId) ([]*types.AppEntry, error) {
-	stmt, err := tx.PrepareContext(ctx, system.RebindQuery(m.dbType, `select id, path, domain, main_app, linked_app_path, source_url, is_dev, user_id, create_time, update_time, settings, metadata from apps where main_app = ?`))
+	stmt, err := tx.PrepareContext(ctx, system.RebindQuery(m.dbType, `select id, path, domain, main_app, linked_app_path, source_url, is_dev, user_id, create_time, update_time, settings, metadata, row_version from apps where main_app = ?`))
 	if err != nil {
 		return nil, fmt.Errorf("error preparing statement: %w", err)
 	}
@@ -874,7 +1100,7 @@ func (m *Metadata) GetLinkedApps(ctx context.Context, tx types.Transaction, main
 	for rows.Next() {
 		var app types.AppEntry
 		var linkedAppPath, settings, metadata sql.NullString
-		err = rows.Scan(&app.Id, &app.Path, &app.Domain, &app.MainApp, &linkedAppPath, &app.SourceUrl, &app.IsDev, &app.UserID, &app.CreateTime, &app.UpdateTime, &settings, &metadata)
+		err = rows.Scan(&app.Id, &app.Path, &app.Domain, &app.MainApp, &linkedAppPath, &app.SourceUrl, &app.IsDev, &app.UserID, &app.CreateTime, &app.UpdateTime, &settings, &metadata, &app.RowVersion)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				return apps, nil // No linked apps found, return empty slice
@@ -912,25 +1138,61 @@ func (m *Metadata) GetLinkedApps(ctx context.Context, tx types.Transaction, main
 	return apps, nil
 }
 
-func (m *Metadata) UpdateSourceUrl(ctx context.Context, tx types.Transaction, app *types.AppEntry) error {
-	_, err := tx.ExecContext(ctx, system.RebindQuery(m.dbType, `UPDATE apps set source_url = ? where path = ? and domain = ?`), app.SourceUrl, app.Path, app.Domain)
+// updateAppRow updates the app row if the row version is unchanged since the app entry was read,
+// and increments the row version. An update from a stale read returns a retriable version conflict
+// error, so that concurrent updates (like a sync and a manual settings change) do not overwrite
+// each other
+func (m *Metadata) updateAppRow(ctx context.Context, tx types.Transaction, app *types.AppEntry, setClause string, args ...any) error {
+	args = append(args, app.Path, app.Domain, app.RowVersion)
+	result, err := tx.ExecContext(ctx, system.RebindQuery(m.dbType, `UPDATE apps set `+setClause+
+		`, row_version = row_version + 1 where path = ? and domain = ? and row_version = ?`), args...)
 	if err != nil {
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		var currentVersion int64
+		err := tx.QueryRowContext(ctx, system.RebindQuery(m.dbType, `select row_version from apps where path = ? and domain = ?`),
+			app.Path, app.Domain).Scan(&currentVersion)
+		if err == sql.ErrNoRows {
+			return ErrAppNotFound
+		} else if err != nil {
+			return err
+		}
+		return types.CreateVersionConflictError(app.String(), app.RowVersion, currentVersion)
+	}
+	app.RowVersion++
+	return nil
+}
+
+func (m *Metadata) UpdateSourceUrl(ctx context.Context, tx types.Transaction, app *types.AppEntry) error {
+	if err := m.updateAppRow(ctx, tx, app, `source_url = ?`, app.SourceUrl); err != nil {
 		return fmt.Errorf("error updating app source url: %w", err)
 	}
 	return nil
 }
 
-func (m *Metadata) UpdateAppMetadata(ctx context.Context, tx types.Transaction, app *types.AppEntry) error {
-	err := m.updateAppMetadata(ctx, tx, app.Path, app.Domain, &app.Metadata)
-	if err != nil {
-		return fmt.Errorf("error updating app metadata: %w", err)
+// UpdateAppOwner updates the owner of an app, which is initially the user who created the app
+func (m *Metadata) UpdateAppOwner(ctx context.Context, tx types.Transaction, app *types.AppEntry) error {
+	if err := m.updateAppRow(ctx, tx, app, `user_id = ?, update_time = `+system.FuncNow(m.dbType), app.UserID); err != nil {
+		return fmt.Errorf("error updating app owner: %w", err)
 	}
+	return nil
+}
 
+func (m *Metadata) UpdateAppMetadata(ctx context.Context, tx types.Transaction, app *types.AppEntry) error {
 	metadataJson, err := json.Marshal(app.Metadata)
 	if err != nil {
 		return fmt.Errorf("error marshalling metadata: %w", err)
 	}
 
+	if err := m.updateAppRow(ctx, tx, app, `metadata = ?, update_time = `+system.FuncNow(m.dbType), string(metadataJson)); err != nil {
+		return fmt.Errorf("error updating app metadata: %w", err)
+	}
+
 	if strings.HasPrefix(string(app.Id), types.ID_PREFIX_APP_PROD) || strings.HasPrefix(string(app.Id), types.ID_PREFIX_APP_STAGE) {
 		_, err = tx.ExecContext(ctx, system.RebindQuery(m.dbType, `UPDATE app_versions set metadata = ? where appid = ? and version = ?`), string(metadataJson), app.Id, app.Metadata.VersionMetadata.Version)
 		if err != nil {
@@ -941,6 +1203,7 @@ func (m *Metadata) UpdateAppMetadata(ctx context.Context, tx types.Transaction,
 	return nil
 }
 
+// updateAppMetadata updates the metadata without the row version check, used by the DB upgrades
 func (m *Metadata) updateAppMetadata(ctx context.Context, tx types.Transaction, path, domain string, metadata *types.AppMetadata) error {
 	metadataJson, err := json.Marshal(metadata)
 	if err != nil {
@@ -956,9 +1219,18 @@ func (m *Metadata) updateAppMetadata(ctx context.Context, tx types.Transaction,
 }
 
 func (m *Metadata) UpdateAppSettings(ctx context.Context, tx types.Transaction, app *types.AppEntry) error {
-	return m.updateAppSettings(ctx, tx, app.Path, app.Domain, &app.Settings)
+	settingsJson, err := json.Marshal(app.Settings)
+	if err != nil {
+		return fmt.Errorf("error marshalling settings: %w", err)
+	}
+
+	if err := m.updateAppRow(ctx, tx, app, `settings = ?, update_time = `+system.FuncNow(m.dbType), string(settingsJson)); err != nil {
+		return fmt.Errorf("error updating app settings: %w", err)
+	}
+	return nil
 }
 
+// updateAppSettings updates the settings without the row version check, used by the DB upgrades
 func (m *Metadata) updateAppSettings(ctx context.Context, tx types.Transaction, path, domain string, settings *types.AppSettings) error {
 	settingsJson, err := json.Marshal(settings)
 	if err != nil {
@@ -1004,6 +1276,9 @@ func (m *Metadata) DeleteSync(ctx context.Context, tx types.Transaction, id stri
 	if rowsAffected == 0 {
 		return fmt.Errorf("no sync entry found with id for delete: %s", id)
 	}
+	if _, err := tx.ExecContext(ctx, system.RebindQuery(m.dbType, `delete from sync_runs where sync_id = ?`), id); err != nil {
+		return fmt.Errorf("error deleting sync runs: %w", err)
+	}
 	return nil
 }
 
@@ -1920,3 +2195,26 @@ func (m *Metadata) CommitTransaction(tx types.Transaction) error {
 func (m *Metadata) RollbackTransaction(tx types.Transaction) error {
 	return tx.Rollback()
 }
+
+// tagRetainVersions returns the fs.retain_versions value from a tag_config entry, which is a
+// nested table when read from openrun.toml and a dotted key when set through the dynamic config
+func tagRetainVersions(entry map[string]any) (int, bool) {
+	val, ok := entry["fs.retain_versions"]
+	if !ok {
+		fs, _ := entry["fs"].(map[string]any)
+		if val, ok = fs["retain_versions"]; !ok {
+			return 0, false
+		}
+	}
+	switch v := val.(type) {
+	case int64:
+		if v >= 0 {
+			return int(v), true
+		}
+	case float64:
+		if v >= 0 && v == float64(int(v)) {
+			return int(v), true
+		}
+	}
+	return 0, false
+}
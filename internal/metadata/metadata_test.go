@@ -4,10 +4,17 @@
 package metadata
 
 import (
+	"bytes"
+	"compress/gzip"
 	"context"
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/fs"
 	"net"
 	"os"
 	"path/filepath"
@@ -15,6 +22,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/openrundev/openrun/internal/app/appfs"
 	"github.com/openrundev/openrun/internal/testutil"
 	"github.com/openrundev/openrun/internal/types"
 )
@@ -265,6 +273,71 @@ func TestMetadata_AppLifecycle(t *testing.T) {
 	testutil.AssertErrorContains(t, err, "app not found")
 }
 
+func TestMetadata_AppRowVersionConflict(t *testing.T) {
+	m, cleanup := setupTestMetadata(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	app := &types.AppEntry{
+		Id:     types.AppId(types.ID_PREFIX_APP_DEV + "1"),
+		Path:   "/app",
+		IsDev:  true,
+		UserID: "u1",
+	}
+	tx, err := m.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, m.CreateApp(ctx, tx, app))
+	testutil.AssertNoError(t, tx.Commit())
+
+	// Two readers of the same version, like a sync and a manual settings update
+	first, err := m.GetAppEntry(ctx, app.AppPathDomain())
+	testutil.AssertNoError(t, err)
+	second, err := m.GetAppEntry(ctx, app.AppPathDomain())
+	testutil.AssertNoError(t, err)
+
+	tx, err = m.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	first.Settings.Tags = []string{"first"}
+	testutil.AssertNoError(t, m.UpdateAppSettings(ctx, tx, first))
+	first.Metadata.Name = "first"
+	testutil.AssertNoError(t, m.UpdateAppMetadata(ctx, tx, first))
+	testutil.AssertEqualsInt(t, "updated version", 2, int(first.RowVersion))
+	testutil.AssertNoError(t, tx.Commit())
+
+	tx, err = m.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	second.Settings.Tags = []string{"second"}
+	err = m.UpdateAppSettings(ctx, tx, second)
+	testutil.AssertErrorContains(t, err, "updated concurrently")
+	if !types.IsRetriableConflict(err) {
+		t.Fatalf("expected version conflict, got %v", err)
+	}
+	var reqError types.RequestError
+	if !errors.As(err, &reqError) {
+		t.Fatalf("expected request error, got %v", err)
+	}
+	testutil.AssertEqualsInt(t, "current version", 2, int(reqError.CurrentVersion))
+	testutil.AssertNoError(t, tx.Rollback())
+
+	got, err := m.GetAppEntry(ctx, app.AppPathDomain())
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "tags", "first", strings.Join(got.Settings.Tags, ","))
+	testutil.AssertEqualsInt(t, "row version", 2, int(got.RowVersion))
+
+	// A retry after reading the app again succeeds
+	tx, err = m.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	got.Settings.Tags = []string{"second"}
+	testutil.AssertNoError(t, m.UpdateAppSettings(ctx, tx, got))
+	testutil.AssertNoError(t, tx.Commit())
+
+	missing := &types.AppEntry{Path: "/missing"}
+	tx, err = m.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertErrorContains(t, m.UpdateAppSettings(ctx, tx, missing), "app not found")
+	testutil.AssertNoError(t, tx.Rollback())
+}
+
 func TestFileStoreRejectsSymlinksInSource(t *testing.T) {
 	m, cleanup := setupTestMetadata(t)
 	defer cleanup()
@@ -377,6 +450,129 @@ func TestFileStoreSkipsSocketInSource(t *testing.T) {
 	testutil.AssertNoError(t, tx.Rollback())
 }
 
+func TestFileStoreGzipVariants(t *testing.T) {
+	m, cleanup := setupTestMetadata(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	sourceDir := t.TempDir()
+	script := []byte(strings.Repeat("console.log('hello world');\n", 100))
+	files := map[string][]byte{
+		"app.star":         []byte("app = ace.app(\"test\")\n"),
+		"static/app.js":    script,
+		"static/small.css": []byte("p { color: red; }"),
+		"static/image.png": script,
+	}
+	for name, data := range files {
+		testutil.AssertNoError(t, os.MkdirAll(filepath.Dir(filepath.Join(sourceDir, name)), 0o700))
+		testutil.AssertNoError(t, os.WriteFile(filepath.Join(sourceDir, name), data, 0o600))
+	}
+
+	appEntry := &types.AppEntry{
+		Id:        types.AppId(types.ID_PREFIX_APP_PROD + "varianttest"),
+		Path:      "/variant",
+		SourceUrl: sourceDir,
+		UserID:    "u1",
+		Metadata:  types.AppMetadata{SpecFiles: &types.SpecFiles{}},
+	}
+	tx, err := m.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	defer tx.Rollback() //nolint:errcheck
+	testutil.AssertNoError(t, m.CreateApp(ctx, tx, appEntry))
+
+	fileStore, err := NewFileStore(appEntry.Id, 1, m, tx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, fileStore.AddAppVersionDisk(ctx, tx, types.AppMetadata{
+		VersionMetadata: types.VersionMetadata{Version: 1},
+	}, sourceDir))
+
+	dbFs, err := NewDbFs(m.Logger, fileStore, types.SpecFiles{})
+	testutil.AssertNoError(t, err)
+	readVariant := func(name string) []byte {
+		f, err := dbFs.Open(name)
+		testutil.AssertNoError(t, err)
+		data, err := f.(appfs.VariantReader).ReadVariant(appfs.GZIP_ENCODING)
+		testutil.AssertNoError(t, err)
+		return data
+	}
+
+	variant := readVariant("static/app.js")
+	if variant == nil {
+		t.Fatal("expected gzip variant for the script")
+	}
+	gz, err := gzip.NewReader(bytes.NewReader(variant))
+	testutil.AssertNoError(t, err)
+	uncompressed, err := io.ReadAll(gz)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "uncompressed", string(script), string(uncompressed))
+
+	// Small files and images do not have a variant
+	testutil.AssertEqualsBool(t, "small", true, readVariant("static/small.css") == nil)
+	testutil.AssertEqualsBool(t, "image", true, readVariant("static/image.png") == nil)
+}
+
+func TestFileStoreImageVariants(t *testing.T) {
+	m, cleanup := setupTestMetadata(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	sourceDir := t.TempDir()
+	var buf bytes.Buffer
+	testutil.AssertNoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 100, 50))))
+	files := map[string][]byte{
+		"app.star":               []byte("app = ace.app(\"test\")\n"),
+		"static/photo.png":       buf.Bytes(),
+		"static/photo-80w.png":   []byte("custom"),
+		"static/invalid.png":     []byte("not an image"),
+		"static_root/banner.png": buf.Bytes(),
+	}
+	for name, data := range files {
+		testutil.AssertNoError(t, os.MkdirAll(filepath.Dir(filepath.Join(sourceDir, name)), 0o700))
+		testutil.AssertNoError(t, os.WriteFile(filepath.Join(sourceDir, name), data, 0o600))
+	}
+
+	appEntry := &types.AppEntry{
+		Id:        types.AppId(types.ID_PREFIX_APP_PROD + "imagetest"),
+		Path:      "/image",
+		SourceUrl: sourceDir,
+		UserID:    "u1",
+		Metadata:  types.AppMetadata{SpecFiles: &types.SpecFiles{}},
+	}
+	tx, err := m.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	defer tx.Rollback() //nolint:errcheck
+	testutil.AssertNoError(t, m.CreateApp(ctx, tx, appEntry))
+
+	// Invalid images are skipped, the source file with a variant name is not replaced
+	fileStore, err := NewFileStore(appEntry.Id, 1, m, tx)
+	testutil.AssertNoError(t, err)
+	fileStore.SetImageOptions(appfs.ImageOptions{Widths: []int{50, 80, 480}})
+	testutil.AssertNoError(t, fileStore.AddAppVersionDisk(ctx, tx, types.AppMetadata{
+		VersionMetadata: types.VersionMetadata{Version: 1},
+	}, sourceDir))
+
+	appFiles, err := fileStore.GetAppFiles(ctx, tx)
+	testutil.AssertNoError(t, err)
+	names := []string{}
+	for _, f := range appFiles {
+		names = append(names, f.Name)
+	}
+	testutil.AssertEqualsString(t, "files", "app.star,static/invalid.png,static/photo-50w.png,static/photo-80w.png,static/photo.png,static_root/banner.png",
+		strings.Join(names, ","))
+
+	dbFs, err := NewDbFs(m.Logger, fileStore, types.SpecFiles{})
+	testutil.AssertNoError(t, err)
+	data, err := fs.ReadFile(dbFs, "static/photo-50w.png")
+	testutil.AssertNoError(t, err)
+	config, err := png.DecodeConfig(bytes.NewReader(data))
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "width", 50, config.Width)
+	testutil.AssertEqualsInt(t, "height", 25, config.Height)
+	data, err = fs.ReadFile(dbFs, "static/photo-80w.png")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "source file", "custom", string(data))
+}
+
 func TestMetadata_SyncLifecycle(t *testing.T) {
 	m, cleanup := setupTestMetadata(t)
 	defer cleanup()
@@ -439,6 +635,104 @@ func TestMetadata_SyncLifecycle(t *testing.T) {
 	testutil.AssertErrorContains(t, err, "sync entry not found")
 }
 
+func TestMetadata_SyncRuns(t *testing.T) {
+	m, cleanup := setupTestMetadata(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	entry := &types.SyncEntry{Id: "sync-runs", Path: "/prod", IsScheduled: true, UserID: "u1"}
+	tx, err := m.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, m.CreateSync(ctx, tx, entry))
+
+	// Only the latest three runs are retained
+	start := time.Now().Add(-time.Hour)
+	for i := range 5 {
+		run := &types.SyncRun{
+			Id:         fmt.Sprintf("run%d", i),
+			SyncId:     entry.Id,
+			StartTime:  start.Add(time.Duration(i) * time.Minute),
+			DurationMs: int64(i * 100),
+			CommitId:   fmt.Sprintf("commit%d", i),
+			Changes:    types.SyncRunChanges{Created: []types.AppPathDomain{{Path: fmt.Sprintf("/app%d", i)}}},
+		}
+		testutil.AssertNoError(t, m.InsertSyncRun(ctx, tx, run, 3))
+	}
+	testutil.AssertNoError(t, m.InsertSyncRun(ctx, tx, &types.SyncRun{Id: "other", SyncId: "other-sync", StartTime: start}, 3))
+	testutil.AssertNoError(t, tx.Commit())
+
+	runs, total, err := m.ListSyncRuns(ctx, entry.Id, 0, 2)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "total", 3, total)
+	testutil.AssertEqualsInt(t, "page size", 2, len(runs))
+	testutil.AssertEqualsString(t, "latest", "run4", runs[0].Id)
+	testutil.AssertEqualsString(t, "commit", "commit4", runs[0].CommitId)
+	testutil.AssertEqualsInt(t, "duration", 400, int(runs[0].DurationMs))
+	testutil.AssertEqualsString(t, "created", "/app4", runs[0].Changes.Created[0].Path)
+
+	runs, _, err = m.ListSyncRuns(ctx, entry.Id, 2, 2)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "last page", 1, len(runs))
+	testutil.AssertEqualsString(t, "oldest retained", "run2", runs[0].Id)
+
+	// Deleting the sync entry deletes its runs
+	tx, err = m.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, m.DeleteSync(ctx, tx, entry.Id))
+	testutil.AssertNoError(t, tx.Commit())
+	_, total, err = m.ListSyncRuns(ctx, entry.Id, 0, 10)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "deleted", 0, total)
+	_, total, err = m.ListSyncRuns(ctx, "other-sync", 0, 10)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "other sync", 1, total)
+}
+
+func TestMetadata_ApplyPlans(t *testing.T) {
+	m, cleanup := setupTestMetadata(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	plan := &types.ApplyPlan{
+		Id:          "apl_pln_1",
+		CreateTime:  time.Now(),
+		UserId:      "u1",
+		ApplyPath:   "github.com/example/apps/apps.ace",
+		AppPathGlob: "all",
+		Options:     types.ApplyPlanOptions{Approve: true, Reload: types.AppReloadOptionUpdated},
+		AppVersions: map[string]int64{"/app1": 3},
+		Response:    types.AppApplyResponse{DryRun: true, CommitId: "c1", UpdateResults: []types.AppPathDomain{{Path: "/app1"}}},
+		Status:      types.ApplyPlanPending,
+	}
+	tx, err := m.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, m.InsertApplyPlan(ctx, tx, plan))
+	testutil.AssertNoError(t, tx.Commit())
+
+	tx, err = m.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	defer tx.Rollback() //nolint:errcheck
+	got, err := m.GetApplyPlan(ctx, tx, plan.Id)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "status", string(types.ApplyPlanPending), string(got.Status))
+	testutil.AssertEqualsString(t, "commit", "c1", got.Response.CommitId)
+	testutil.AssertEqualsInt(t, "app version", 3, int(got.AppVersions["/app1"]))
+	testutil.AssertEqualsBool(t, "approve", true, got.Options.Approve)
+
+	// A plan is executed only once
+	testutil.AssertNoError(t, m.MarkApplyPlanExecuted(ctx, tx, got))
+	if err := m.MarkApplyPlanExecuted(ctx, tx, got); !errors.Is(err, ErrApplyPlanExecuted) {
+		t.Fatalf("expected plan executed error, got %v", err)
+	}
+	got, err = m.GetApplyPlan(ctx, tx, plan.Id)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "status", string(types.ApplyPlanExecuted), string(got.Status))
+
+	if _, err := m.GetApplyPlan(ctx, tx, "apl_pln_missing"); !errors.Is(err, ErrApplyPlanNotFound) {
+		t.Fatalf("expected plan not found error, got %v", err)
+	}
+}
+
 func TestMetadata_ServiceBindingIdsPersisted(t *testing.T) {
 	m, cleanup := setupTestMetadata(t)
 	defer cleanup()
@@ -589,6 +883,21 @@ func TestToNullTime(t *testing.T) {
 	}
 }
 
+func TestTagRetainVersions(t *testing.T) {
+	val, ok := tagRetainVersions(map[string]any{"fs": map[string]any{"retain_versions": int64(3)}})
+	testutil.AssertEqualsBool(t, "nested ok", true, ok)
+	testutil.AssertEqualsInt(t, "nested", 3, val)
+
+	val, ok = tagRetainVersions(map[string]any{"fs.retain_versions": float64(7)})
+	testutil.AssertEqualsBool(t, "dotted ok", true, ok)
+	testutil.AssertEqualsInt(t, "dotted", 7, val)
+
+	_, ok = tagRetainVersions(map[string]any{"fs.retain_versions": int64(-1)})
+	testutil.AssertEqualsBool(t, "negative ok", false, ok)
+	_, ok = tagRetainVersions(nil)
+	testutil.AssertEqualsBool(t, "missing ok", false, ok)
+}
+
 func TestMetadata_ConfigHistoryDraftAndAtomicDelete(t *testing.T) {
 	m, cleanup := setupTestMetadata(t)
 	defer cleanup()
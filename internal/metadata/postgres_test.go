@@ -0,0 +1,151 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func newPostgresMetadata(t *testing.T, connStr string) *Metadata {
+	t.Helper()
+	config := &types.ServerConfig{
+		Metadata: types.MetadataConfig{
+			DBConnection: connStr,
+			AutoUpgrade:  true,
+		},
+	}
+	m, err := NewMetadata(types.NewLogger(&types.LogConfig{Level: "INFO"}), config)
+	if err != nil {
+		t.Fatalf("failed to create metadata: %v", err)
+	}
+	return m
+}
+
+func TestPostgresConcurrentVersionUpgrade(t *testing.T) {
+	skipIfNoPostgres(t)
+	connStr, pgCleanup := startPostgres(t)
+	defer pgCleanup()
+
+	// Servers sharing the database start together, the schema upgrade is done once
+	const servers = 3
+	var wg sync.WaitGroup
+	nodes := make([]*Metadata, servers)
+	errs := make([]error, servers)
+	for i := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			config := &types.ServerConfig{Metadata: types.MetadataConfig{DBConnection: connStr, AutoUpgrade: true}}
+			nodes[i], errs[i] = NewMetadata(types.NewLogger(&types.LogConfig{Level: "INFO"}), config)
+		}()
+	}
+	wg.Wait()
+	for i := range servers {
+		testutil.AssertNoError(t, errs[i])
+		defer nodes[i].Close()
+	}
+
+	m := nodes[0]
+	testutil.AssertEqualsString(t, "db type", string(system.DB_TYPE_POSTGRES), string(m.dbType))
+	var version, rows int
+	testutil.AssertNoError(t, m.db.QueryRow("select max(version), count(*) from version").Scan(&version, &rows))
+	testutil.AssertEqualsInt(t, "db version", CURRENT_DB_VERSION, version)
+	testutil.AssertEqualsInt(t, "version rows", 1, rows)
+
+	// A restart finds the version current
+	restarted := newPostgresMetadata(t, connStr)
+	defer restarted.Close()
+	testutil.AssertNoError(t, restarted.VersionUpgrade(restarted.config))
+}
+
+func TestPostgresAppAndSyncTransactions(t *testing.T) {
+	skipIfNoPostgres(t)
+	connStr, pgCleanup := startPostgres(t)
+	defer pgCleanup()
+	m := newPostgresMetadata(t, connStr)
+	defer m.Close()
+	other := newPostgresMetadata(t, connStr)
+	defer other.Close()
+	ctx := context.Background()
+
+	app := &types.AppEntry{
+		Id:     types.AppId(types.ID_PREFIX_APP_PROD + "1"),
+		Path:   "/app",
+		Domain: "example.com",
+		UserID: "u1",
+		Metadata: types.AppMetadata{
+			Name: "app",
+		},
+	}
+	tx, err := m.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, m.CreateApp(ctx, tx, app))
+	testutil.AssertNoError(t, tx.Commit())
+
+	// The app is visible from the other server, updates from both use the row version check
+	first, err := m.GetAppEntry(ctx, app.AppPathDomain())
+	testutil.AssertNoError(t, err)
+	second, err := other.GetAppEntry(ctx, app.AppPathDomain())
+	testutil.AssertNoError(t, err)
+
+	tx, err = m.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	first.Metadata.Name = "first"
+	testutil.AssertNoError(t, m.UpdateAppMetadata(ctx, tx, first))
+	testutil.AssertNoError(t, tx.Commit())
+
+	tx, err = other.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	second.Metadata.Name = "second"
+	err = other.UpdateAppMetadata(ctx, tx, second)
+	testutil.AssertErrorContains(t, err, "updated concurrently")
+	testutil.AssertNoError(t, tx.Rollback())
+
+	got, err := other.GetAppEntry(ctx, app.AppPathDomain())
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "name", "first", got.Metadata.Name)
+
+	entry := &types.SyncEntry{
+		Id:          "sync-1",
+		Path:        "/app",
+		IsScheduled: true,
+		UserID:      "u1",
+		Metadata:    types.SyncMetadata{GitBranch: "main"},
+		Status:      types.SyncJobStatus{State: "pending"},
+	}
+	tx, err = m.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, m.CreateSync(ctx, tx, entry))
+	testutil.AssertNoError(t, tx.Commit())
+
+	tx, err = other.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, other.UpdateSyncStatus(ctx, tx, entry.Id, &types.SyncJobStatus{State: "success", CommitId: "abc123"}))
+	testutil.AssertNoError(t, tx.Commit())
+
+	gotSync, err := m.GetSyncEntry(ctx, types.Transaction{}, entry.Id)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "sync status", "success", gotSync.Status.State)
+
+	// A rolled back delete leaves the entry
+	tx, err = m.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, m.DeleteSync(ctx, tx, entry.Id))
+	testutil.AssertNoError(t, tx.Rollback())
+	_, err = other.GetSyncEntry(ctx, types.Transaction{}, entry.Id)
+	testutil.AssertNoError(t, err)
+
+	tx, err = m.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, m.DeleteSync(ctx, tx, entry.Id))
+	testutil.AssertNoError(t, tx.Commit())
+	_, err = other.GetSyncEntry(ctx, types.Transaction{}, entry.Id)
+	testutil.AssertErrorContains(t, err, "sync entry not found")
+}
@@ -0,0 +1,76 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package metadata
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// InsertSyncRun adds a run to the history of a sync entry. Only the latest retain runs are
+// kept for each sync entry, older runs are deleted. If retain is zero or less, all runs are kept
+func (m *Metadata) InsertSyncRun(ctx context.Context, tx types.Transaction, run *types.SyncRun, retain int) error {
+	changesJson, err := json.Marshal(run.Changes)
+	if err != nil {
+		return fmt.Errorf("error marshalling sync run changes: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, system.RebindQuery(m.dbType,
+		`insert into sync_runs (id, sync_id, start_time, duration_ms, commit_id, error, changes) values (?, ?, ?, ?, ?, ?, ?)`),
+		run.Id, run.SyncId, run.StartTime.UTC(), run.DurationMs, run.CommitId, run.Error, string(changesJson)); err != nil {
+		return fmt.Errorf("error inserting sync run: %w", err)
+	}
+
+	if retain <= 0 {
+		return nil
+	}
+	if _, err := tx.ExecContext(ctx, system.RebindQuery(m.dbType,
+		`delete from sync_runs where sync_id = ? and id not in `+
+			`(select id from (select id from sync_runs where sync_id = ? order by start_time desc, id desc limit ?) as retained)`),
+		run.SyncId, run.SyncId, retain); err != nil {
+		return fmt.Errorf("error deleting old sync runs: %w", err)
+	}
+	return nil
+}
+
+// ListSyncRuns returns one page of the runs of a sync entry, latest first, along with the total
+// count of the runs
+func (m *Metadata) ListSyncRuns(ctx context.Context, syncId string, offset, limit int) ([]*types.SyncRun, int, error) {
+	var total int
+	if err := m.db.QueryRowContext(ctx, system.RebindQuery(m.dbType, `select count(*) from sync_runs where sync_id = ?`),
+		syncId).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("error counting sync runs: %w", err)
+	}
+
+	rows, err := m.db.QueryContext(ctx, system.RebindQuery(m.dbType,
+		`select id, sync_id, start_time, duration_ms, commit_id, error, changes from sync_runs where sync_id = ? `+
+			`order by start_time desc, id desc limit ? offset ?`), syncId, limit, offset)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error querying sync runs: %w", err)
+	}
+	defer rows.Close() //nolint:errcheck
+
+	runs := []*types.SyncRun{}
+	for rows.Next() {
+		run := types.SyncRun{}
+		var changes sql.NullString
+		if err := rows.Scan(&run.Id, &run.SyncId, &run.StartTime, &run.DurationMs, &run.CommitId, &run.Error, &changes); err != nil {
+			return nil, 0, fmt.Errorf("error scanning sync run: %w", err)
+		}
+		if changes.Valid && changes.String != "" {
+			if err := json.Unmarshal([]byte(changes.String), &run.Changes); err != nil {
+				return nil, 0, fmt.Errorf("error unmarshalling sync run changes: %w", err)
+			}
+		}
+		runs = append(runs, &run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error iterating sync runs: %w", err)
+	}
+	return runs, total, nil
+}
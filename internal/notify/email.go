@@ -0,0 +1,133 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package notify
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	defaultSmtpPort = 587
+	smtpTLSPort     = 465 // port for SMTP with implicit TLS, other ports use STARTTLS if supported
+)
+
+// sendEmail sends the message through the SMTP server. The password auth is done only over TLS,
+// or to a localhost server
+func sendEmail(ctx context.Context, config types.NotifyConfig, event *Event, resolve SecretEvalFunc) error {
+	subject, err := Render("subject", cmp.Or(config.Subject, defaultSubject), event)
+	if err != nil {
+		return err
+	}
+	message, err := Render("message", cmp.Or(config.Template, defaultTemplate), event)
+	if err != nil {
+		return err
+	}
+	data, err := emailMessage(config.From, config.To, subject, message, event.Time)
+	if err != nil {
+		return err
+	}
+	password, err := resolve(config.SmtpPassword)
+	if err != nil {
+		return fmt.Errorf("error resolving smtp password: %w", err)
+	}
+
+	port := cmp.Or(config.SmtpPort, defaultSmtpPort)
+	addr := net.JoinHostPort(config.SmtpHost, strconv.Itoa(port))
+	tlsConfig := clientTLSConfig(config.SkipVerify)
+	tlsConfig.ServerName = config.SmtpHost
+	var conn net.Conn
+	if port == smtpTLSPort {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("error connecting to smtp server %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline) //nolint:errcheck
+	}
+
+	client, err := smtp.NewClient(conn, config.SmtpHost)
+	if err != nil {
+		conn.Close() //nolint:errcheck
+		return fmt.Errorf("error connecting to smtp server %s: %w", addr, err)
+	}
+	defer client.Close() //nolint:errcheck
+
+	if port != smtpTLSPort {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("error starting tls with smtp server %s: %w", addr, err)
+			}
+		}
+	}
+	if config.SmtpUser != "" {
+		if err := client.Auth(smtp.PlainAuth("", config.SmtpUser, password, config.SmtpHost)); err != nil {
+			return fmt.Errorf("error authenticating with smtp server %s: %w", addr, err)
+		}
+	}
+
+	if err := client.Mail(config.From); err != nil {
+		return fmt.Errorf("error sending email from %s: %w", config.From, err)
+	}
+	for _, to := range config.To {
+		if err := client.Rcpt(to); err != nil {
+			return fmt.Errorf("error sending email to %s: %w", to, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error sending email: %w", err)
+	}
+	return client.Quit()
+}
+
+// emailMessage returns the plain text email, with the body quoted-printable encoded
+func emailMessage(from string, to []string, subject, body string, date time.Time) ([]byte, error) {
+	for _, addr := range append([]string{from}, to...) {
+		if strings.ContainsAny(addr, "\r\n") {
+			return nil, fmt.Errorf("invalid email address %q", addr)
+		}
+	}
+	// The rendered subject could have line breaks, which would inject headers
+	subject = strings.Join(strings.Fields(subject), " ")
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	w := quotedprintable.NewWriter(&buf)
+	if _, err := w.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n"))); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
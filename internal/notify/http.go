@@ -0,0 +1,130 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package notify
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const SIGNATURE_HEADER = "X-OpenRun-Signature"
+
+func isLoopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// clientTLSConfig returns a copy of the TLS config of the default HTTP transport, which has the
+// server TLS policy applied
+func clientTLSConfig(skipVerify bool) *tls.Config {
+	tlsConfig := &tls.Config{}
+	if base := http.DefaultTransport.(*http.Transport).TLSClientConfig; base != nil {
+		tlsConfig = base.Clone()
+	}
+	tlsConfig.InsecureSkipVerify = skipVerify //nolint:gosec
+	return tlsConfig
+}
+
+// sendSlack posts the message to a Slack incoming webhook, as {"text": message}
+func sendSlack(ctx context.Context, config types.NotifyConfig, event *Event, resolve SecretEvalFunc) error {
+	message, err := Render("message", cmp.Or(config.Template, defaultTemplate), event)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]string{"text": message})
+	if err != nil {
+		return err
+	}
+	return post(ctx, config, "application/json", body, nil, resolve)
+}
+
+// sendWebhook posts the event as JSON. If a template is configured, the rendered template is
+// posted instead, with the configured content type. If a secret is configured, the
+// X-OpenRun-Signature header has the HMAC-SHA256 of the body, as sha256=<hex>
+func sendWebhook(ctx context.Context, config types.NotifyConfig, event *Event, resolve SecretEvalFunc) error {
+	var body []byte
+	contentType := "application/json"
+	if config.Template != "" {
+		message, err := Render("message", config.Template, event)
+		if err != nil {
+			return err
+		}
+		body = []byte(message)
+		contentType = cmp.Or(config.ContentType, "text/plain; charset=utf-8")
+	} else {
+		var err error
+		if body, err = json.Marshal(event); err != nil {
+			return err
+		}
+	}
+
+	headers := map[string]string{}
+	for k, v := range config.Headers {
+		value, err := resolve(v)
+		if err != nil {
+			return fmt.Errorf("error resolving header %s: %w", k, err)
+		}
+		headers[k] = value
+	}
+	if config.Secret != "" {
+		secret, err := resolve(config.Secret)
+		if err != nil {
+			return fmt.Errorf("error resolving webhook secret: %w", err)
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write(body) //nolint:errcheck
+		headers[SIGNATURE_HEADER] = "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	}
+	return post(ctx, config, contentType, body, headers, resolve)
+}
+
+func post(ctx context.Context, config types.NotifyConfig, contentType string, body []byte, headers map[string]string, resolve SecretEvalFunc) error {
+	targetUrl, err := resolve(config.Url)
+	if err != nil {
+		return fmt.Errorf("error resolving url: %w", err)
+	}
+	if err := checkUrl(targetUrl); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, targetUrl, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = clientTLSConfig(config.SkipVerify)
+	client := &http.Client{Transport: transport}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s notify returned status %d: %s", config.Type, resp.StatusCode, respBody)
+	}
+	return nil
+}
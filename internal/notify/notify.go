@@ -0,0 +1,267 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+// Package notify sends the results of the sync runs and applies to Slack, email and webhook
+// targets. The message bodies are rendered from Go text/templates using the Event fields.
+package notify
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	TYPE_SLACK   = "slack"
+	TYPE_EMAIL   = "email"
+	TYPE_WEBHOOK = "webhook"
+)
+
+const (
+	ON_ALL     = "all"
+	ON_SUCCESS = "success"
+	ON_FAILURE = "failure"
+)
+
+const (
+	EVENT_SYNC  = "sync"
+	EVENT_APPLY = "apply"
+)
+
+const (
+	defaultTimeoutSecs = 30
+	// maxListedApps limits the apps listed per change type in the default message
+	maxListedApps = 20
+)
+
+// defaultTemplate is the message body used for Slack and email if no template is configured
+const defaultTemplate = `{{.Title}}{{if .Success}}: {{.Summary}}{{end}}
+Source: {{.Path}}{{with .Branch}} ({{.}}){{end}}
+{{- if .CommitId}}
+Commit: {{.ShortCommit}}{{with .CommitMessage}} {{firstLine .}}{{end}}{{end}}
+{{- with .Error}}
+Error: {{.}}{{end}}
+{{- with .Created}}
+Created: {{joinApps .}}{{end}}
+{{- with .Updated}}
+Updated: {{joinApps .}}{{end}}
+{{- with .Reloaded}}
+Reloaded: {{joinApps .}}{{end}}
+{{- with .Approved}}
+Approved: {{joinApps .}}{{end}}
+{{- with .Promoted}}
+Promoted: {{joinApps .}}{{end}}
+`
+
+const defaultSubject = `[OpenRun] {{.Title}}`
+
+// SecretEvalFunc resolves the {{secret}} references in a config value
+type SecretEvalFunc func(string) (string, error)
+
+// Event is the result of a sync run or an apply. Failed runs are rolled back, so the app lists
+// are empty for failures
+type Event struct {
+	Kind          string    `json:"kind"` // sync or apply
+	SyncId        string    `json:"sync_id,omitempty"`
+	Path          string    `json:"path"` // the apply file path
+	Branch        string    `json:"branch,omitempty"`
+	CommitId      string    `json:"commit_id,omitempty"`
+	CommitMessage string    `json:"commit_message,omitempty"`
+	Success       bool      `json:"success"`
+	Error         string    `json:"error,omitempty"`
+	UserId        string    `json:"user_id,omitempty"`
+	Time          time.Time `json:"time"`
+	Created       []string  `json:"created"`
+	Updated       []string  `json:"updated"`
+	Reloaded      []string  `json:"reloaded"`
+	Approved      []string  `json:"approved"`
+	Promoted      []string  `json:"promoted"`
+}
+
+// NewEvent creates the event for an apply response. If errMsg is set, the event is for a failure
+func NewEvent(kind, path string, resp *types.AppApplyResponse, errMsg string) *Event {
+	event := &Event{
+		Kind:     kind,
+		Path:     path,
+		Success:  errMsg == "",
+		Error:    errMsg,
+		Time:     time.Now().UTC(),
+		Created:  []string{},
+		Updated:  []string{},
+		Reloaded: []string{},
+		Approved: []string{},
+		Promoted: []string{},
+	}
+	if resp == nil || errMsg != "" {
+		return event
+	}
+
+	event.CommitId = resp.CommitId
+	for _, create := range resp.CreateResults {
+		event.Created = append(event.Created, create.AppPathDomain.String())
+	}
+	for _, approve := range resp.ApproveResults {
+		event.Approved = append(event.Approved, approve.AppPathDomain.String())
+	}
+	for _, app := range resp.UpdateResults {
+		event.Updated = append(event.Updated, app.String())
+	}
+	for _, app := range resp.ReloadResults {
+		event.Reloaded = append(event.Reloaded, app.String())
+	}
+	for _, app := range resp.PromoteResults {
+		event.Promoted = append(event.Promoted, app.String())
+	}
+	return event
+}
+
+// Title returns the one line status of the event, like "Sync cl_syn_123 succeeded"
+func (e *Event) Title() string {
+	status := "succeeded"
+	if !e.Success {
+		status = "failed"
+	}
+	if e.Kind == EVENT_SYNC {
+		return fmt.Sprintf("Sync %s %s", e.SyncId, status)
+	}
+	return fmt.Sprintf("Apply %s %s", e.Path, status)
+}
+
+// Summary returns the counts of the app changes
+func (e *Event) Summary() string {
+	return fmt.Sprintf("%d created, %d updated, %d reloaded, %d promoted",
+		len(e.Created), len(e.Updated), len(e.Reloaded), len(e.Promoted))
+}
+
+// ShortCommit returns the abbreviated commit id
+func (e *Event) ShortCommit() string {
+	if len(e.CommitId) > 8 {
+		return e.CommitId[:8]
+	}
+	return e.CommitId
+}
+
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+	"firstLine": func(s string) string {
+		line, _, _ := strings.Cut(strings.TrimSpace(s), "\n")
+		return line
+	},
+	"joinApps": func(apps []string) string {
+		if len(apps) > maxListedApps {
+			return strings.Join(apps[:maxListedApps], ", ") + fmt.Sprintf(" and %d more", len(apps)-maxListedApps)
+		}
+		return strings.Join(apps, ", ")
+	},
+}
+
+func parseTemplate(name, text string) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s template: %w", name, err)
+	}
+	return tmpl, nil
+}
+
+// Render renders the template text using the event fields
+func Render(name, text string, event *Event) (string, error) {
+	tmpl, err := parseTemplate(name, text)
+	if err != nil {
+		return "", err
+	}
+	var buf strings.Builder
+	if err := tmpl.Execute(&buf, event); err != nil {
+		return "", fmt.Errorf("error rendering %s template: %w", name, err)
+	}
+	return buf.String(), nil
+}
+
+// Validate checks the notify config entry
+func Validate(name string, config types.NotifyConfig) error {
+	switch config.On {
+	case "", ON_ALL, ON_SUCCESS, ON_FAILURE:
+	default:
+		return fmt.Errorf("notify %s: invalid on value %q, expected all, success or failure", name, config.On)
+	}
+
+	switch config.Type {
+	case TYPE_SLACK, TYPE_WEBHOOK:
+		if config.Url == "" {
+			return fmt.Errorf("notify %s: url is required", name)
+		}
+		if !strings.Contains(config.Url, "{{") {
+			// urls with secret references are checked on use
+			if err := checkUrl(config.Url); err != nil {
+				return fmt.Errorf("notify %s: %w", name, err)
+			}
+		}
+	case TYPE_EMAIL:
+		if config.SmtpHost == "" || config.From == "" || len(config.To) == 0 {
+			return fmt.Errorf("notify %s: smtp_host, from and to are required for email", name)
+		}
+		if _, err := parseTemplate("subject", config.Subject); err != nil {
+			return fmt.Errorf("notify %s: %w", name, err)
+		}
+	default:
+		return fmt.Errorf("notify %s: invalid type %q, expected slack, email or webhook", name, config.Type)
+	}
+
+	if _, err := parseTemplate("message", config.Template); err != nil {
+		return fmt.Errorf("notify %s: %w", name, err)
+	}
+	return nil
+}
+
+// ShouldNotify returns true if the notify entry is to be used for the outcome
+func ShouldNotify(config types.NotifyConfig, success bool) bool {
+	switch config.On {
+	case ON_SUCCESS:
+		return success
+	case ON_FAILURE:
+		return !success
+	default:
+		return true
+	}
+}
+
+// Send sends the event to the notify target
+func Send(ctx context.Context, config types.NotifyConfig, event *Event, evalSecret SecretEvalFunc) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(cmp.Or(config.TimeoutSecs, defaultTimeoutSecs))*time.Second)
+	defer cancel()
+
+	resolve := func(value string) (string, error) {
+		if evalSecret == nil || value == "" {
+			return value, nil
+		}
+		return evalSecret(value)
+	}
+
+	switch config.Type {
+	case TYPE_SLACK:
+		return sendSlack(ctx, config, event, resolve)
+	case TYPE_WEBHOOK:
+		return sendWebhook(ctx, config, event, resolve)
+	case TYPE_EMAIL:
+		return sendEmail(ctx, config, event, resolve)
+	default:
+		return fmt.Errorf("invalid notify type %q", config.Type)
+	}
+}
+
+// checkUrl checks that the url uses https, http is allowed for localhost only
+func checkUrl(targetUrl string) error {
+	u, err := url.Parse(targetUrl)
+	if err != nil {
+		return fmt.Errorf("invalid url %s: %w", targetUrl, err)
+	}
+	if u.Scheme != "https" && (u.Scheme != "http" || !isLoopbackHost(u.Hostname())) {
+		return fmt.Errorf("invalid url %s, https is required (http is allowed for localhost only)", targetUrl)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package notify
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func testEvent() *Event {
+	event := NewEvent(EVENT_SYNC, "github.com/org/apps/apps.ace", &types.AppApplyResponse{
+		CommitId:       "0123456789abcdef",
+		CreateResults:  []types.AppCreateResponse{{AppPathDomain: types.AppPathDomain{Path: "/new"}}},
+		ReloadResults:  []types.AppPathDomain{{Path: "/a"}, {Path: "/b", Domain: "example.com"}},
+		PromoteResults: []types.AppPathDomain{{Path: "/a"}},
+	}, "")
+	event.SyncId = "cl_syn_123"
+	event.Branch = "main"
+	event.CommitMessage = "Update apps\n\nLonger description"
+	return event
+}
+
+func TestRender(t *testing.T) {
+	message, err := Render("message", defaultTemplate, testEvent())
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "message", `Sync cl_syn_123 succeeded: 1 created, 0 updated, 2 reloaded, 1 promoted
+Source: github.com/org/apps/apps.ace (main)
+Commit: 01234567 Update apps
+Created: /new
+Reloaded: /a, example.com:/b
+Promoted: /a
+`, message)
+
+	failed := NewEvent(EVENT_APPLY, "/apps/apps.ace", &types.AppApplyResponse{CommitId: "abc"}, "apply failed")
+	message, err = Render("message", defaultTemplate, failed)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "failed", "Apply /apps/apps.ace failed\nSource: /apps/apps.ace\nError: apply failed\n", message)
+
+	message, err = Render("message", `{{.Kind}} {{if .Success}}ok{{end}} {{join .Reloaded "|"}}`, testEvent())
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "custom", "sync ok /a|example.com:/b", message)
+
+	_, err = Render("message", "{{.Unknown}}", testEvent())
+	testutil.AssertErrorContains(t, err, "error rendering message template")
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  types.NotifyConfig
+		wantErr string
+	}{
+		{name: "slack", config: types.NotifyConfig{Type: TYPE_SLACK, Url: "https://hooks.slack.com/services/x"}},
+		{name: "secret url", config: types.NotifyConfig{Type: TYPE_WEBHOOK, Url: `{{secret "env" "HOOK_URL"}}`, On: ON_FAILURE}},
+		{name: "email", config: types.NotifyConfig{Type: TYPE_EMAIL, SmtpHost: "smtp.example.com", From: "a@example.com", To: []string{"b@example.com"}}},
+		{name: "http", config: types.NotifyConfig{Type: TYPE_WEBHOOK, Url: "http://example.com/hook"}, wantErr: "https is required"},
+		{name: "no url", config: types.NotifyConfig{Type: TYPE_SLACK}, wantErr: "url is required"},
+		{name: "no to", config: types.NotifyConfig{Type: TYPE_EMAIL, SmtpHost: "smtp.example.com", From: "a@example.com"}, wantErr: "from and to are required"},
+		{name: "type", config: types.NotifyConfig{Type: "sms"}, wantErr: "invalid type"},
+		{name: "on", config: types.NotifyConfig{Type: TYPE_SLACK, Url: "https://x", On: "never"}, wantErr: "invalid on value"},
+		{name: "template", config: types.NotifyConfig{Type: TYPE_SLACK, Url: "https://x", Template: "{{.Title"}, wantErr: "error parsing message template"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Validate("n1", tc.config)
+			if tc.wantErr == "" {
+				testutil.AssertNoError(t, err)
+			} else {
+				testutil.AssertErrorContains(t, err, tc.wantErr)
+			}
+		})
+	}
+
+	testutil.AssertEqualsBool(t, "all", true, ShouldNotify(types.NotifyConfig{}, false))
+	testutil.AssertEqualsBool(t, "success", false, ShouldNotify(types.NotifyConfig{On: ON_SUCCESS}, false))
+	testutil.AssertEqualsBool(t, "failure", true, ShouldNotify(types.NotifyConfig{On: ON_FAILURE}, false))
+}
+
+func TestSendSlack(t *testing.T) {
+	var text string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body) //nolint:errcheck
+		text = body["text"]
+	}))
+	defer server.Close()
+
+	err := Send(context.Background(), types.NotifyConfig{Type: TYPE_SLACK, Url: `{{secret "env" "URL"}}`, Template: "{{.Title}}"},
+		testEvent(), func(string) (string, error) { return server.URL, nil })
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "text", "Sync cl_syn_123 succeeded", text)
+}
+
+func TestSendWebhook(t *testing.T) {
+	var body []byte
+	var contentType string
+	status := http.StatusOK
+	signatureOk := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = io.ReadAll(r.Body)
+		contentType = r.Header.Get("Content-Type")
+		mac := hmac.New(sha256.New, []byte("key1"))
+		mac.Write(body) //nolint:errcheck
+		signatureOk = r.Header.Get(SIGNATURE_HEADER) == "sha256="+hex.EncodeToString(mac.Sum(nil)) && r.Header.Get("X-Test") == "v1"
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	config := types.NotifyConfig{Type: TYPE_WEBHOOK, Url: server.URL, Secret: "key1", Headers: map[string]string{"X-Test": "v1"}}
+	testutil.AssertNoError(t, Send(context.Background(), config, testEvent(), nil))
+	testutil.AssertEqualsString(t, "content type", "application/json", contentType)
+	testutil.AssertEqualsBool(t, "signature", true, signatureOk)
+	var event Event
+	testutil.AssertNoError(t, json.Unmarshal(body, &event))
+	testutil.AssertEqualsString(t, "commit", "0123456789abcdef", event.CommitId)
+	testutil.AssertEqualsInt(t, "reloaded", 2, len(event.Reloaded))
+
+	config.Template = "{{.Summary}}"
+	testutil.AssertNoError(t, Send(context.Background(), config, testEvent(), nil))
+	testutil.AssertEqualsString(t, "body", "1 created, 0 updated, 2 reloaded, 1 promoted", string(body))
+	testutil.AssertEqualsString(t, "content type", "text/plain; charset=utf-8", contentType)
+
+	status = http.StatusBadRequest
+	testutil.AssertErrorContains(t, Send(context.Background(), config, testEvent(), nil), "webhook notify returned status 400")
+}
+
+// smtpServer accepts one SMTP session and returns the message data
+func smtpServer(t *testing.T) (int, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	testutil.AssertNoError(t, err)
+	data := make(chan string, 1)
+	go func() {
+		defer listener.Close() //nolint:errcheck
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close() //nolint:errcheck
+		reader := bufio.NewReader(conn)
+		conn.Write([]byte("220 localhost\r\n")) //nolint:errcheck
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.Fields(line)[0]); cmd {
+			case "DATA":
+				conn.Write([]byte("354 go ahead\r\n")) //nolint:errcheck
+				var msg strings.Builder
+				for {
+					line, err := reader.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					msg.WriteString(line)
+				}
+				data <- msg.String()
+				conn.Write([]byte("250 OK\r\n")) //nolint:errcheck
+			case "QUIT":
+				conn.Write([]byte("221 bye\r\n")) //nolint:errcheck
+				return
+			default:
+				conn.Write([]byte("250 OK\r\n")) //nolint:errcheck
+			}
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, data
+}
+
+func TestSendEmail(t *testing.T) {
+	port, data := smtpServer(t)
+	config := types.NotifyConfig{Type: TYPE_EMAIL, SmtpHost: "127.0.0.1", SmtpPort: port,
+		From: "openrun@example.com", To: []string{"ops@example.com", "dev@example.com"}}
+	testutil.AssertNoError(t, Send(context.Background(), config, testEvent(), nil))
+
+	select {
+	case msg := <-data:
+		testutil.AssertStringContains(t, msg, "To: ops@example.com, dev@example.com\r\n")
+		testutil.AssertStringContains(t, msg, "Subject: [OpenRun] Sync cl_syn_123 succeeded\r\n")
+		testutil.AssertStringContains(t, msg, "\r\n\r\nSync cl_syn_123 succeeded: 1 created, 0 updated, 2 reloaded, 1 promoted\r\n")
+	case <-time.After(5 * time.Second):
+		t.Fatal("email not received")
+	}
+
+	_, err := emailMessage("a@example.com\r\nBcc: x@example.com", []string{"b@example.com"}, "s", "b", time.Now())
+	testutil.AssertErrorContains(t, err, "invalid email address")
+	msg, err := emailMessage("a@example.com", []string{"b@example.com"}, "line1\r\nBcc: x", "b", time.Now())
+	testutil.AssertNoError(t, err)
+	testutil.AssertStringContains(t, string(msg), "Subject: line1 Bcc: x\r\n")
+}
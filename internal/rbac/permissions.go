@@ -120,6 +120,7 @@ var appPermissions = []types.RBACPermission{
 	types.PermissionPreview,
 	types.PermissionTokenRead,
 	types.PermissionTokenManage,
+	types.PermissionExec,
 	types.PermissionAppManage,
 	types.PermissionApprove,
 }
@@ -184,25 +185,26 @@ func scopedKind(perm types.RBACPermission, isAppLevelPermission bool) (targetKin
 // session publishes or edits is separately enforced with the app permissions
 // (app:create/app:update/app:delete) on that path.
 var globalPermissions = map[types.RBACPermission]bool{
-	types.PermissionBuilderList:     true,
-	types.PermissionBuilderCreate:   true,
-	types.PermissionBuilderPublish:  true,
-	types.PermissionSyncCreate:      true,
-	types.PermissionSyncRun:         true,
-	types.PermissionSyncDelete:      true,
-	types.PermissionSyncRead:        true,
-	types.PermissionContainerRead:   true,
-	types.PermissionContainerManage: true,
-	types.PermissionConfigBasicRead: true,
-	types.PermissionConfigRead:      true,
-	types.PermissionConfigUpdate:    true,
-	types.PermissionServerStop:      true,
-	types.PermissionAuditRead:       true,
-	types.PermissionSecretCreate:    true,
-	types.PermissionSecretRead:      true,
-	types.PermissionSecretDelete:    true,
-	types.PermissionSecretReveal:    true,
-	types.PermissionAdmin:           true,
+	types.PermissionBuilderList:      true,
+	types.PermissionBuilderCreate:    true,
+	types.PermissionBuilderPublish:   true,
+	types.PermissionSyncCreate:       true,
+	types.PermissionSyncRun:          true,
+	types.PermissionSyncDelete:       true,
+	types.PermissionSyncRead:         true,
+	types.PermissionContainerRead:    true,
+	types.PermissionContainerManage:  true,
+	types.PermissionContainerDevices: true,
+	types.PermissionConfigBasicRead:  true,
+	types.PermissionConfigRead:       true,
+	types.PermissionConfigUpdate:     true,
+	types.PermissionServerStop:       true,
+	types.PermissionAuditRead:        true,
+	types.PermissionSecretCreate:     true,
+	types.PermissionSecretRead:       true,
+	types.PermissionSecretDelete:     true,
+	types.PermissionSecretReveal:     true,
+	types.PermissionAdmin:            true,
 }
 
 // globalPermissionNames is the sorted list of global permission names, for
@@ -282,7 +284,7 @@ var permissionImplications = map[types.RBACPermission][]types.RBACPermission{
 	// binding:reveal (reading back account credentials) is excluded, like
 	// app:approve from app:manage: it always needs an explicit grant
 	types.PermissionBindingManage:   managePermissions(bindingPermissions, types.PermissionBindingManage, types.PermissionBindingReveal),
-	types.PermissionContainerManage: {types.PermissionContainerRead},
+	types.PermissionContainerManage: {types.PermissionContainerRead, types.PermissionContainerDevices},
 	types.PermissionConfigRead:      {types.PermissionConfigBasicRead},
 }
 
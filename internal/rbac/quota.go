@@ -0,0 +1,70 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package rbac
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// validateQuotas validates the quota policies. Like tenants, quotas are checked even when RBAC is
+// disabled, the user matching uses the RBAC groups
+func (h *RBACManager) validateQuotas(rbacConfig *types.RBACConfig, regexCache map[string]*regexp.Regexp) error {
+	for i, quota := range rbacConfig.Quotas {
+		name := quota.Description
+		if name == "" {
+			name = fmt.Sprintf("%d", i)
+		}
+		if len(quota.Users) == 0 {
+			return fmt.Errorf("quota %s: users cannot be empty", name)
+		}
+		if quota.MaxApps < 0 || quota.MaxContainers < 0 || quota.MaxStorageMB < 0 {
+			return fmt.Errorf("quota %s: limits cannot be negative", name)
+		}
+		for _, user := range quota.Users {
+			if strings.HasPrefix(user, RBAC_REGEX_PREFIX) {
+				if err := compileUserRegex(regexCache, user[len(RBAC_REGEX_PREFIX):]); err != nil {
+					return fmt.Errorf("quota %s: %w", name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// UserQuotas returns the quota policies which apply to the user. groups are the groups from the
+// user's login, in addition to the groups in the RBAC config
+func (h *RBACManager) UserQuotas(user string, groups []string) ([]types.QuotaPolicy, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	ret := []types.QuotaPolicy{}
+	if user == "" {
+		return ret, nil
+	}
+	for _, quota := range h.RbacConfig.Quotas {
+		matched, err := h.grantUserMatchesLocked(types.RBACGrant{Users: quota.Users}, user, groups)
+		if err != nil {
+			return nil, err
+		}
+		if matched {
+			ret = append(ret, quota)
+		}
+	}
+	return ret, nil
+}
+
+// QuotaUserMatches reports whether the quota policy applies to the user. Only the groups in the
+// RBAC config are used, since this is used to find the other users sharing a quota, whose login
+// groups are not known
+func (h *RBACManager) QuotaUserMatches(quota types.QuotaPolicy, user string) (bool, error) {
+	if user == "" {
+		return false, nil
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.grantUserMatchesLocked(types.RBACGrant{Users: quota.Users}, user, nil)
+}
@@ -0,0 +1,77 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package rbac
+
+import (
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func quotaConfig(quotas ...types.QuotaPolicy) *types.RBACConfig {
+	config := grantConfig(map[string][]types.RBACPermission{})
+	config.Groups = map[string][]string{"team1": {"alice", "regex:t1_.*"}}
+	config.Quotas = quotas
+	return config
+}
+
+func TestQuotaValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		quota  types.QuotaPolicy
+		errMsg string
+	}{
+		"no users":       {types.QuotaPolicy{Description: "q1", MaxApps: 1}, "quota q1: users cannot be empty"},
+		"negative":       {types.QuotaPolicy{Users: []string{"alice"}, MaxStorageMB: -1}, "quota 0: limits cannot be negative"},
+		"invalid regex":  {types.QuotaPolicy{Description: "q1", Users: []string{"regex:("}}, "quota q1"},
+		"negative count": {types.QuotaPolicy{Users: []string{"alice"}, MaxContainers: -5}, "limits cannot be negative"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := NewRBACHandler(testutil.TestLogger(), quotaConfig(test.quota), &types.ServerConfig{})
+			testutil.AssertErrorContains(t, err, test.errMsg)
+		})
+	}
+}
+
+func TestUserQuotas(t *testing.T) {
+	t.Parallel()
+
+	manager := newTestManager(t, quotaConfig(
+		types.QuotaPolicy{Description: "everyone", Users: []string{"regex:.*"}, MaxApps: 10},
+		types.QuotaPolicy{Description: "team1", Users: []string{"group:team1"}, Shared: true, MaxApps: 5},
+		types.QuotaPolicy{Description: "sso", Users: []string{"group:sso_team"}, MaxContainers: 2},
+	))
+
+	names := func(user string, groups ...string) []string {
+		t.Helper()
+		quotas, err := manager.UserQuotas(user, groups)
+		testutil.AssertNoError(t, err)
+		ret := []string{}
+		for _, q := range quotas {
+			ret = append(ret, q.Description)
+		}
+		return ret
+	}
+
+	testutil.AssertEqualsInt(t, "alice", 2, len(names("alice")))
+	testutil.AssertEqualsInt(t, "t1_bob", 2, len(names("t1_bob")))
+	testutil.AssertEqualsInt(t, "carol", 1, len(names("carol")))
+	testutil.AssertEqualsInt(t, "carol sso", 2, len(names("carol", "sso_team")))
+	testutil.AssertEqualsInt(t, "empty user", 0, len(names("")))
+
+	team := manager.RbacConfig.Quotas[1]
+	for user, expected := range map[string]bool{"alice": true, "t1_x": true, "carol": false, "": false} {
+		matched, err := manager.QuotaUserMatches(team, user)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqualsBool(t, user, expected, matched)
+	}
+	// Login groups are not known for the other team members
+	matched, err := manager.QuotaUserMatches(manager.RbacConfig.Quotas[2], "carol")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsBool(t, "sso group", false, matched)
+}
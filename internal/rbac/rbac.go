@@ -34,6 +34,8 @@ type RBACManager struct {
 	customPerms    []string                                 // custom permissions are permissions defined by the user. This list does not have the custom: prefix
 	ownerPerms     map[string]map[types.RBACPermission]bool // resource name to permissions granted to the asset owner
 	enabled        atomic.Bool                              // RbacConfig.Enabled, readable without taking mu (hot path checks)
+	tenantDomains  map[string]*resolvedTenant               // domain to the tenant owning it
+	tenants        []*resolvedTenant                        // tenants sorted by name
 }
 
 // resolvedGroup is a group's membership resolved into lookup structures at
@@ -100,6 +102,10 @@ func (h *RBACManager) authorizeIntLocked(user string, appPathDomain types.AppPat
 		return isAdmin, err
 	}
 
+	if decided, allowed, err := h.checkTenantLocked(user, groups, appPathDomain, permission, isAppLevelPermission); err != nil || decided {
+		return allowed, err
+	}
+
 	// Callers resolve stage/preview apps to their main app path before this point, so
 	// grant checks run against the main app path directly.
 	return h.checkGrants(user, appPathDomain, "", permission, groups, isAppLevelPermission)
@@ -604,6 +610,15 @@ func (h *RBACManager) UpdateRBACConfig(rbacConfig *types.RBACConfig) error {
 		return fmt.Errorf("error validating rbac grants: %w", err)
 	}
 
+	tenantDomains, tenants, err := h.initTenants(rbacConfig, regexCache)
+	if err != nil {
+		return fmt.Errorf("error initializing rbac tenants: %w", err)
+	}
+
+	if err := h.validateQuotas(rbacConfig, regexCache); err != nil {
+		return fmt.Errorf("error validating rbac quotas: %w", err)
+	}
+
 	// Per grant resolved state: pre-parsed target globs, and whether any
 	// grant confers the admin super-user permission (when none does, the
 	// admin pre-check on every authorization skips the grant scan)
@@ -630,6 +645,8 @@ func (h *RBACManager) UpdateRBACConfig(rbacConfig *types.RBACConfig) error {
 	h.hasAdminGrant = hasAdminGrant
 	h.customPerms = customPerms
 	h.ownerPerms = ownerPerms
+	h.tenantDomains = tenantDomains
+	h.tenants = tenants
 	h.enabled.Store(rbacConfig.Enabled)
 	return nil
 }
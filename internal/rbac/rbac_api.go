@@ -186,20 +186,46 @@ func (h *RBACManager) authorizeAPIIntLocked(user string, groups []string, perm t
 		return isAdmin, err
 	}
 
-	if owner != "" && user == owner && perm != types.PermissionApprove {
+	// Tenant isolation is checked before the owner rule: an app owner who is no longer a
+	// member of the app's tenant loses access to it
+	if decided, allowed, err := h.checkTenantLocked(user, groups, target, perm, false); err != nil || decided {
+		return allowed, err
+	}
+
+	if perm != types.PermissionApprove && h.ownerPerms[PermissionResource(perm)][perm] {
 		// Owner virtual grant: the creator of an asset holds the owner
 		// permission set on it. app:approve shares the app resource prefix
 		// but is never granted through ownership (config validation also
 		// rejects it in owner_permissions; this keeps the exclusion
 		// structural)
-		if h.ownerPerms[PermissionResource(perm)][perm] {
-			return true, nil
+		if isOwner, err := h.ownerMatchesLocked(owner, user, groups); err != nil || isOwner {
+			return isOwner, err
 		}
 	}
 
 	return h.checkGrants(user, target, resourceId, perm, groups, false)
 }
 
+// ownerMatchesLocked reports whether the user is the owner of an asset. An app transferred to a
+// team has a group:<name> owner, every member of the group is an owner of the app
+func (h *RBACManager) ownerMatchesLocked(owner, user string, groups []string) (bool, error) {
+	if owner == "" {
+		return false, nil
+	}
+	if strings.HasPrefix(owner, RBAC_GROUP_PREFIX) {
+		return h.grantUserMatchesLocked(types.RBACGrant{Users: []string{owner}}, user, groups)
+	}
+	return user == owner, nil
+}
+
+// GroupExists reports whether the group is defined in the RBAC config
+func (h *RBACManager) GroupExists(group string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	_, ok := h.groups[group]
+	return ok
+}
+
 // GetAPIPermissions returns the management API permissions the user holds: app
 // permissions evaluated against target/owner plus the global permissions the user
 // holds. With no target (empty AppPathDomain, no owner) scoped permissions are
@@ -274,6 +300,13 @@ func (h *RBACManager) holdsPermSomewhereLocked(user string, groups []string, per
 	if user == "" {
 		return false, nil // fail closed, consistent with authorizeAPIIntLocked
 	}
+	if kind == targetKindApp && perm != types.PermissionApprove {
+		// tenant admins hold the app permissions, except approve, on their tenant apps
+		adminTenants, err := h.adminTenantsLocked(user, groups)
+		if err != nil || len(adminTenants) > 0 {
+			return len(adminTenants) > 0, err
+		}
+	}
 	for i, grant := range h.RbacConfig.Grants {
 		roleMatched := false
 		for _, role := range grant.Roles {
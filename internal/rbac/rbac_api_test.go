@@ -588,6 +588,7 @@ func TestContainerPermissions(t *testing.T) {
 		{"viewer lacks container:manage", "user1", types.PermissionContainerManage, false},
 		{"operator has container:manage", "user2", types.PermissionContainerManage, true},
 		{"container:manage implies container:read", "user2", types.PermissionContainerRead, true},
+		{"container:manage implies container:devices", "user2", types.PermissionContainerDevices, true},
 		{"narrow target still grants global container:read", "user3", types.PermissionContainerRead, true},
 	}
 	for _, tt := range tests {
@@ -711,6 +712,31 @@ func TestOwnerPermissionsConfigured(t *testing.T) {
 	}
 }
 
+func TestTeamOwnerPermissions(t *testing.T) {
+	t.Parallel()
+
+	// An app transferred to a team has a group: owner, the group members are owners
+	config := grantConfig(map[string][]types.RBACPermission{})
+	config.Groups = map[string][]string{"team-b": {"user1", "regex:tb_.*"}}
+	manager := newTestManager(t, config)
+
+	for user, expected := range map[string]bool{"user1": true, "tb_user": true, "user2": false} {
+		allowed, err := manager.AuthorizeAPI(enforcedCtx(user), types.PermissionUpdate, testTarget(), "group:team-b")
+		if err != nil || allowed != expected {
+			t.Errorf("team owner %s: expected %v, got %v err %v", user, expected, allowed, err)
+		}
+	}
+
+	// Unknown group owner matches nobody
+	allowed, err := manager.AuthorizeAPI(enforcedCtx("user1"), types.PermissionUpdate, testTarget(), "group:unknown")
+	if err != nil || allowed {
+		t.Errorf("unknown team owner must be denied, got %v err %v", allowed, err)
+	}
+	if !manager.GroupExists("team-b") || manager.GroupExists("unknown") {
+		t.Errorf("unexpected GroupExists result")
+	}
+}
+
 func TestOwnerPermissionsValidation(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,200 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// resolvedTenant is the tenant config resolved at config update time, with the namespace in
+// lookup form. Domains are lower cased, paths have no trailing slash
+type resolvedTenant struct {
+	name   string
+	config types.TenantConfig
+	paths  []string
+}
+
+// initTenants validates the tenant config and resolves the namespaces. A domain can be owned
+// by only one tenant and the path prefixes of different tenants cannot overlap, so that every
+// app is in at most one tenant. Tenants are resolved even when RBAC is disabled, since the app
+// quotas apply without RBAC
+func (h *RBACManager) initTenants(rbacConfig *types.RBACConfig, regexCache map[string]*regexp.Regexp) (map[string]*resolvedTenant, []*resolvedTenant, error) {
+	domains := map[string]*resolvedTenant{}
+	tenants := make([]*resolvedTenant, 0, len(rbacConfig.Tenants))
+	pathOwners := map[string]string{}
+
+	for _, name := range slices.Sorted(maps.Keys(rbacConfig.Tenants)) {
+		config := rbacConfig.Tenants[name]
+		if strings.TrimSpace(name) == "" {
+			return nil, nil, fmt.Errorf("tenant name cannot be empty")
+		}
+		if len(config.Domains) == 0 && len(config.Paths) == 0 {
+			return nil, nil, fmt.Errorf("tenant %s: at least one of domains or paths is required", name)
+		}
+		if config.MaxApps < 0 {
+			return nil, nil, fmt.Errorf("tenant %s: max_apps cannot be negative", name)
+		}
+		tenant := &resolvedTenant{name: name, config: config}
+
+		for _, domain := range config.Domains {
+			domain = strings.ToLower(strings.TrimSpace(domain))
+			if domain == "" || strings.ContainsAny(domain, ":/*") {
+				return nil, nil, fmt.Errorf("tenant %s: invalid domain %q", name, domain)
+			}
+			if other, ok := domains[domain]; ok {
+				return nil, nil, fmt.Errorf("tenant %s: domain %s is already owned by tenant %s", name, domain, other.name)
+			}
+			domains[domain] = tenant
+		}
+
+		for _, path := range config.Paths {
+			if !strings.HasPrefix(path, "/") || strings.ContainsAny(path, ":*") {
+				return nil, nil, fmt.Errorf("tenant %s: invalid path %q, expected a path starting with /", name, path)
+			}
+			path = strings.TrimRight(path, "/")
+			for otherPath, other := range pathOwners {
+				if pathWithin(path, otherPath) || pathWithin(otherPath, path) {
+					return nil, nil, fmt.Errorf("tenant %s: path %q overlaps with path %q of tenant %s", name, displayPath(path), displayPath(otherPath), other)
+				}
+			}
+			pathOwners[path] = name
+			tenant.paths = append(tenant.paths, path)
+		}
+
+		for _, user := range slices.Concat(config.Admins, config.Members) {
+			if strings.HasPrefix(user, RBAC_REGEX_PREFIX) {
+				if err := compileUserRegex(regexCache, user[len(RBAC_REGEX_PREFIX):]); err != nil {
+					return nil, nil, fmt.Errorf("tenant %s: %w", name, err)
+				}
+			}
+		}
+		tenants = append(tenants, tenant)
+	}
+	return domains, tenants, nil
+}
+
+// pathWithin reports whether path is the same as prefix or is under it. An empty prefix is
+// the root path, which contains every path
+func pathWithin(path, prefix string) bool {
+	return prefix == "" || path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// tenantForAppLocked returns the tenant which owns the app, nil if the app is not in any
+// tenant. Callers must hold h.mu
+func (h *RBACManager) tenantForAppLocked(app types.AppPathDomain) *resolvedTenant {
+	if app == (types.AppPathDomain{}) || len(h.tenants) == 0 {
+		return nil
+	}
+	domain := strings.ToLower(app.Domain)
+	if h.serverConfig != nil && domain == strings.ToLower(h.serverConfig.System.DefaultDomain) {
+		domain = ""
+	}
+	if domain != "" {
+		return h.tenantDomains[domain]
+	}
+	for _, tenant := range h.tenants {
+		for _, path := range tenant.paths {
+			if pathWithin(app.Path, path) {
+				return tenant
+			}
+		}
+	}
+	return nil
+}
+
+// checkTenantLocked applies the tenant rules for a permission check on an app. The tenant
+// admins hold all the app permissions except approve on the tenant apps. If the tenant has
+// members set, users who are not members or admins are denied, whatever their grants. decided
+// is false if the regular grant checks should be done. Callers must hold h.mu
+func (h *RBACManager) checkTenantLocked(user string, groups []string, app types.AppPathDomain,
+	perm types.RBACPermission, isAppLevelPermission bool) (decided bool, allowed bool, err error) {
+	tenant := h.tenantForAppLocked(app)
+	if tenant == nil {
+		return false, false, nil
+	}
+
+	isAdmin, err := h.grantUserMatchesLocked(types.RBACGrant{Users: tenant.config.Admins}, user, groups)
+	if err != nil {
+		return true, false, err
+	}
+	if kind, scoped := scopedKind(perm, isAppLevelPermission); isAdmin && scoped && kind == targetKindApp && perm != types.PermissionApprove {
+		return true, true, nil
+	}
+
+	if len(tenant.config.Members) > 0 && !isAdmin {
+		isMember, err := h.grantUserMatchesLocked(types.RBACGrant{Users: tenant.config.Members}, user, groups)
+		if err != nil {
+			return true, false, err
+		}
+		if !isMember {
+			if debug := h.Debug(); debug.Enabled() {
+				debug.Msgf("Denied user %s access to %s with permission %s, not a member of tenant %s",
+					user, app.String(), perm, tenant.name)
+			}
+			return true, false, nil
+		}
+	}
+	return false, false, nil
+}
+
+// adminTenantsLocked returns the names of the tenants the user is an admin of. Callers must
+// hold h.mu
+func (h *RBACManager) adminTenantsLocked(user string, groups []string) ([]string, error) {
+	ret := []string{}
+	for _, tenant := range h.tenants {
+		isAdmin, err := h.grantUserMatchesLocked(types.RBACGrant{Users: tenant.config.Admins}, user, groups)
+		if err != nil {
+			return nil, err
+		}
+		if isAdmin {
+			ret = append(ret, tenant.name)
+		}
+	}
+	return ret, nil
+}
+
+// TenantForApp returns the name of the tenant which owns the app, empty if the app is not in
+// any tenant. app should be the main app path for stage and preview apps
+func (h *RBACManager) TenantForApp(app types.AppPathDomain) string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if tenant := h.tenantForAppLocked(app); tenant != nil {
+		return tenant.name
+	}
+	return ""
+}
+
+// Tenants returns the configured tenants, keyed by name
+func (h *RBACManager) Tenants() map[string]types.TenantConfig {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return maps.Clone(h.RbacConfig.Tenants)
+}
+
+// AdminTenants returns the names of the tenants the user in the context is an admin of. This is
+// used to scope operations which otherwise need a global permission, like reading the audit log
+func (h *RBACManager) AdminTenants(ctx context.Context) ([]string, error) {
+	user := system.GetContextUserId(ctx)
+	if user == "" {
+		return []string{}, nil
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.adminTenantsLocked(user, system.GetContextGroups(ctx))
+}
@@ -0,0 +1,165 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package rbac
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func tenantConfig(tenants map[string]types.TenantConfig, grants ...types.RBACGrant) *types.RBACConfig {
+	config := grantConfig(map[string][]types.RBACPermission{
+		"viewer": {types.PermissionRead, types.PermissionAccess},
+	}, grants...)
+	config.Tenants = tenants
+	return config
+}
+
+func TestTenantValidation(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]struct {
+		tenants map[string]types.TenantConfig
+		errMsg  string
+	}{
+		"empty namespace": {
+			tenants: map[string]types.TenantConfig{"t1": {}},
+			errMsg:  "at least one of domains or paths is required",
+		},
+		"duplicate domain": {
+			tenants: map[string]types.TenantConfig{
+				"t1": {Domains: []string{"a.example.com"}},
+				"t2": {Domains: []string{"A.example.com"}},
+			},
+			errMsg: "domain a.example.com is already owned by tenant t1",
+		},
+		"overlapping paths": {
+			tenants: map[string]types.TenantConfig{
+				"t1": {Paths: []string{"/sales"}},
+				"t2": {Paths: []string{"/sales/emea/"}},
+			},
+			errMsg: `path "/sales/emea" overlaps with path "/sales" of tenant t1`,
+		},
+		"invalid path": {
+			tenants: map[string]types.TenantConfig{"t1": {Paths: []string{"sales"}}},
+			errMsg:  "invalid path",
+		},
+		"negative max apps": {
+			tenants: map[string]types.TenantConfig{"t1": {Paths: []string{"/sales"}, MaxApps: -1}},
+			errMsg:  "max_apps cannot be negative",
+		},
+		"invalid regex": {
+			tenants: map[string]types.TenantConfig{"t1": {Paths: []string{"/sales"}, Admins: []string{"regex:("}}},
+			errMsg:  "tenant t1",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := NewRBACHandler(testutil.TestLogger(), tenantConfig(test.tenants), &types.ServerConfig{})
+			if err == nil || !strings.Contains(err.Error(), test.errMsg) {
+				t.Fatalf("expected error containing %q, got %v", test.errMsg, err)
+			}
+		})
+	}
+
+	// Sibling paths with a common string prefix do not overlap
+	newTestManager(t, tenantConfig(map[string]types.TenantConfig{
+		"t1": {Paths: []string{"/sales"}},
+		"t2": {Paths: []string{"/salesops"}},
+	}))
+}
+
+func TestTenantForApp(t *testing.T) {
+	t.Parallel()
+
+	manager := newTestManager(t, tenantConfig(map[string]types.TenantConfig{
+		"sales": {Paths: []string{"/sales/"}, Domains: []string{"sales.example.com"}},
+		"eng":   {Paths: []string{"/eng"}},
+	}))
+
+	tests := map[types.AppPathDomain]string{
+		{Path: "/sales"}:                                 "sales",
+		{Path: "/sales/app1"}:                            "sales",
+		{Path: "/salesops"}:                              "",
+		{Path: "/eng/team/app"}:                          "eng",
+		{Path: "/other"}:                                 "",
+		{Path: "/eng", Domain: "Sales.example.com"}:      "sales",
+		{Path: "/sales", Domain: "other.example.com"}:    "",
+		{Path: "/anything", Domain: "sales.example.com"}: "sales",
+	}
+	for app, expected := range tests {
+		testutil.AssertEqualsString(t, app.String(), expected, manager.TenantForApp(app))
+	}
+}
+
+func TestTenantAdmin(t *testing.T) {
+	t.Parallel()
+
+	manager := newTestManager(t, tenantConfig(map[string]types.TenantConfig{
+		"sales": {Paths: []string{"/sales"}, Admins: []string{"alice", "group:sales_admins"}},
+	}))
+
+	salesApp := types.AppPathDomain{Path: "/sales/app1"}
+	for _, perm := range appPermissions {
+		allowed, err := manager.AuthorizeAPI(enforcedCtx("alice"), perm, salesApp, "")
+		testutil.AssertNoError(t, err)
+		// tenant admins hold every app permission except approve
+		testutil.AssertEqualsBool(t, string(perm), perm != types.PermissionApprove, allowed)
+	}
+
+	allowed, err := manager.AuthorizeAPI(enforcedCtx("bob", "sales_admins"), types.PermissionDelete, salesApp, "")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsBool(t, "group admin", true, allowed)
+
+	// No rights outside the tenant
+	allowed, err = manager.AuthorizeAPI(enforcedCtx("alice"), types.PermissionRead, testTarget(), "")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsBool(t, "outside tenant", false, allowed)
+
+	// No global rights
+	allowed, err = manager.AuthorizeAPI(enforcedCtx("alice"), types.PermissionAuditRead, types.AppPathDomain{}, "")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsBool(t, "audit read", false, allowed)
+
+	adminTenants, err := manager.AdminTenants(enforcedCtx("alice"))
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "admin tenants", "sales", strings.Join(adminTenants, ","))
+	adminTenants, err = manager.AdminTenants(enforcedCtx("carol"))
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "no admin tenants", 0, len(adminTenants))
+}
+
+func TestTenantMembers(t *testing.T) {
+	t.Parallel()
+
+	manager := newTestManager(t, tenantConfig(map[string]types.TenantConfig{
+		"sales": {Paths: []string{"/sales"}, Admins: []string{"alice"}, Members: []string{"bob"}},
+		"eng":   {Paths: []string{"/eng"}},
+	}, types.RBACGrant{Description: "viewers", Users: []string{"bob", "carol"},
+		Roles: []string{"viewer"}, Targets: []string{"all"}}))
+
+	check := func(user string, app string, expected bool) {
+		t.Helper()
+		allowed, err := manager.AuthorizeAPI(enforcedCtx(user), types.PermissionRead, types.AppPathDomain{Path: app}, "")
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqualsBool(t, user+" "+app, expected, allowed)
+	}
+
+	check("bob", "/sales/app1", true)
+	// carol has a grant on all apps, but is not a tenant member
+	check("carol", "/sales/app1", false)
+	check("carol", "/eng/app1", true) // eng has no members list, grants apply as usual
+	check("carol", "/test", true)
+	check("alice", "/sales/app1", true)
+
+	// The app owner loses access after leaving the tenant
+	allowed, err := manager.AuthorizeAPI(enforcedCtx("dave"), types.PermissionRead, types.AppPathDomain{Path: "/sales/app2"}, "dave")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsBool(t, "owner not member", false, allowed)
+}
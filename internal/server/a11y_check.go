@@ -0,0 +1,257 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
+	"golang.org/x/net/html"
+)
+
+// The accessibility rules checked, with the WCAG success criterion for each. These are basic
+// checks which can be done on the HTML, they do not replace a full audit
+var a11yRules = map[string]string{
+	"image-alt":      "1.1.1",
+	"form-label":     "1.3.1",
+	"heading-order":  "1.3.1",
+	"meta-viewport":  "1.4.4",
+	"document-title": "2.4.2",
+	"link-name":      "2.4.4",
+	"html-lang":      "3.1.1",
+	"duplicate-id":   "4.1.1",
+	"button-name":    "4.1.2",
+	"frame-title":    "4.1.2",
+}
+
+var fullDocumentRegex = regexp.MustCompile(`(?i)<(!doctype|html)[\s>]`)
+
+// isFullDocument returns true if the page is a full HTML document, document level rules like
+// the title are not checked for partial responses
+func isFullDocument(body string) bool {
+	return fullDocumentRegex.MatchString(body)
+}
+
+type a11yChecker struct {
+	issues      []types.AppCheckIssue
+	labelFor    map[string]bool
+	ids         map[string]int
+	lastHeading int
+}
+
+func (c *a11yChecker) add(n *html.Node, rule, message string) {
+	c.issues = append(c.issues, types.AppCheckIssue{Rule: rule, WCAG: a11yRules[rule], Message: message, Element: describeElement(n)})
+}
+
+// checkAccessibility returns the accessibility issues found in the parsed page
+func checkAccessibility(doc *html.Node, fullDocument bool) []types.AppCheckIssue {
+	c := &a11yChecker{issues: []types.AppCheckIssue{}, labelFor: map[string]bool{}, ids: map[string]int{}}
+	walkElements(doc, func(n *html.Node) {
+		if n.Data == "label" {
+			if target, _ := getAttr(n, "for"); target != "" {
+				c.labelFor[target] = true
+			}
+		}
+	})
+
+	hasTitle := false
+	walkElements(doc, func(n *html.Node) {
+		if id, _ := getAttr(n, "id"); id != "" {
+			c.ids[id]++
+			if c.ids[id] == 2 {
+				c.add(n, "duplicate-id", fmt.Sprintf("id %q is used more than once", id))
+			}
+		}
+		if n.Data == "title" && textContent(n) != "" {
+			hasTitle = true
+		}
+		c.checkElement(n)
+	})
+
+	if fullDocument {
+		htmlNode := findElement(doc, "html")
+		if lang, _ := getAttr(htmlNode, "lang"); strings.TrimSpace(lang) == "" {
+			c.add(htmlNode, "html-lang", "page has no lang attribute on the html element")
+		}
+		if !hasTitle {
+			c.add(htmlNode, "document-title", "page has no title")
+		}
+	}
+	return c.issues
+}
+
+func (c *a11yChecker) checkElement(n *html.Node) {
+	if value, _ := getAttr(n, "aria-hidden"); value == "true" {
+		return
+	}
+
+	switch n.Data {
+	case "img":
+		if _, ok := getAttr(n, "alt"); !ok {
+			c.add(n, "image-alt", "image has no alt attribute")
+		}
+	case "area":
+		if _, ok := getAttr(n, "href"); ok && !hasAriaName(n) {
+			if alt, _ := getAttr(n, "alt"); strings.TrimSpace(alt) == "" {
+				c.add(n, "image-alt", "image map area has no alt text")
+			}
+		}
+	case "a":
+		if _, ok := getAttr(n, "href"); ok && !hasAccessibleName(n) {
+			c.add(n, "link-name", "link has no text")
+		}
+	case "button":
+		if !hasAccessibleName(n) {
+			c.add(n, "button-name", "button has no text")
+		}
+	case "iframe":
+		if title, _ := getAttr(n, "title"); strings.TrimSpace(title) == "" {
+			c.add(n, "frame-title", "iframe has no title")
+		}
+	case "select", "textarea":
+		c.checkLabel(n)
+	case "input":
+		inputType, _ := getAttr(n, "type")
+		switch strings.ToLower(inputType) {
+		case "hidden", "submit", "reset":
+		case "image":
+			if alt, _ := getAttr(n, "alt"); strings.TrimSpace(alt) == "" && !hasAriaName(n) {
+				c.add(n, "image-alt", "image button has no alt text")
+			}
+		case "button":
+			if value, _ := getAttr(n, "value"); strings.TrimSpace(value) == "" && !hasAriaName(n) {
+				c.add(n, "button-name", "button has no text")
+			}
+		default:
+			c.checkLabel(n)
+		}
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		level, _ := strconv.Atoi(n.Data[1:])
+		if c.lastHeading != 0 && level > c.lastHeading+1 {
+			c.add(n, "heading-order", fmt.Sprintf("heading level jumps from h%d to h%d", c.lastHeading, level))
+		}
+		c.lastHeading = level
+	case "meta":
+		if name, _ := getAttr(n, "name"); strings.ToLower(name) == "viewport" {
+			content, _ := getAttr(n, "content")
+			if viewportBlocksZoom(content) {
+				c.add(n, "meta-viewport", "viewport disables zooming")
+			}
+		}
+	}
+}
+
+// checkLabel checks that the form field has a label, either a label element or an aria label.
+// A placeholder is not a label
+func (c *a11yChecker) checkLabel(n *html.Node) {
+	if hasAriaName(n) {
+		return
+	}
+	if id, _ := getAttr(n, "id"); id != "" && c.labelFor[id] {
+		return
+	}
+	for parent := n.Parent; parent != nil; parent = parent.Parent {
+		if parent.Type == html.ElementNode && parent.Data == "label" {
+			return
+		}
+	}
+	c.add(n, "form-label", "form field has no label")
+}
+
+func viewportBlocksZoom(content string) bool {
+	for _, part := range strings.Split(content, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.ToLower(strings.TrimSpace(value))
+		switch key {
+		case "user-scalable":
+			if value == "no" || value == "0" {
+				return true
+			}
+		case "maximum-scale":
+			if scale, err := strconv.ParseFloat(value, 64); err == nil && scale < 2 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasAriaName(n *html.Node) bool {
+	for _, key := range []string{"aria-label", "aria-labelledby", "title"} {
+		if value, _ := getAttr(n, key); strings.TrimSpace(value) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func hasAccessibleName(n *html.Node) bool {
+	return hasAriaName(n) || textContent(n) != ""
+}
+
+// textContent returns the text of the element, including the alt text of images and the aria
+// labels of child elements
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			sb.WriteString(n.Data)
+		case html.ElementNode:
+			if n.Data == "script" || n.Data == "style" {
+				return
+			}
+			if label, _ := getAttr(n, "aria-label"); label != "" {
+				sb.WriteString(label)
+				return
+			}
+			if n.Data == "img" {
+				alt, _ := getAttr(n, "alt")
+				sb.WriteString(alt)
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		walk(child)
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func findElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if ret := findElement(child, tag); ret != nil {
+			return ret
+		}
+	}
+	return nil
+}
+
+// describeElement returns the element start tag with the attributes useful to find it in the page
+func describeElement(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("<" + n.Data)
+	for _, key := range []string{"id", "name", "type", "href", "src", "class"} {
+		if value, ok := getAttr(n, key); ok {
+			if len(value) > 40 {
+				value = value[:40] + "..."
+			}
+			fmt.Fprintf(&sb, ` %s="%s"`, key, value)
+		}
+	}
+	sb.WriteString(">")
+	return sb.String()
+}
@@ -5,6 +5,8 @@ package server
 
 import (
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
@@ -46,7 +48,7 @@ func (s *Server) accessLogMiddleware(next http.Handler) http.Handler {
 		s.accessLogger.Log().
 			Str("method", r.Method).
 			Str("host", r.Host).
-			Str("url", r.RequestURI).
+			Str("url", redactWebhookToken(r)).
 			Str("proto", r.Proto).
 			Str("remote", r.RemoteAddr).
 			Int("status", status).
@@ -55,3 +57,15 @@ func (s *Server) accessLogMiddleware(next http.Handler) http.Handler {
 			Send()
 	})
 }
+
+// redactWebhookToken returns the request URI with the token query param masked for webhook
+// calls. Registry webhooks which cannot set headers pass the webhook token in the url
+func redactWebhookToken(r *http.Request) string {
+	if !strings.HasPrefix(r.URL.Path, types.WEBHOOK_URL_PREFIX) || !r.URL.Query().Has("token") {
+		return r.RequestURI
+	}
+	query := r.URL.Query()
+	query.Set("token", "REDACTED")
+	redacted := url.URL{Path: r.URL.Path, RawQuery: query.Encode()}
+	return redacted.RequestURI()
+}
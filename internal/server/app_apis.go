@@ -19,6 +19,7 @@ import (
 	"path/filepath"
 	"slices"
 	"strings"
+	"time"
 
 	"github.com/openrundev/openrun/internal/app"
 	"github.com/openrundev/openrun/internal/app/appfs"
@@ -72,6 +73,10 @@ func newAppID(isDev bool) (types.AppId, error) {
 
 func (s *Server) CreateApp(ctx context.Context, appPath string,
 	approve, dryRun bool, appRequest *types.CreateAppRequest) (_ *types.AppCreateResponse, retErr error) {
+	if appRequest.ParamsOnly {
+		// The app is set up to read the params definition, nothing is saved
+		dryRun = true
+	}
 
 	if s.rbacManager.APIEnforced(ctx) {
 		// The app does not exist yet: match grant targets against the requested path
@@ -89,6 +94,11 @@ func (s *Server) CreateApp(ctx context.Context, appPath string,
 		}
 	}
 
+	// The image digest is resolved before the transaction is opened, like the git checkout below
+	if err := s.prepareImageApp(ctx, appRequest); err != nil {
+		return nil, err
+	}
+
 	repoCache, err := NewRepoCache(s)
 	if err != nil {
 		return nil, err
@@ -101,9 +111,21 @@ func (s *Server) CreateApp(ctx context.Context, appPath string,
 	// left for CreateAppTx to report
 	if sourceUrl := strings.Split(appRequest.SourceUrl, "#")[0]; system.IsGit(sourceUrl) {
 		branch := cmp.Or(appRequest.GitBranch, "main")
-		if _, _, _, _, err := repoCache.CheckoutRepo(sourceUrl, branch, appRequest.GitCommit,
-			appRequest.GitAuthName, appRequest.IsDev); err != nil {
-			s.Debug().Err(err).Msgf("git prefetch: error checking out %s", sourceUrl)
+		commit := appRequest.GitCommit
+		if appRequest.GitTag != "" && appRequest.GitBranch == "" && commit == "" {
+			if _, commit, err = repoCache.ResolveTag(sourceUrl, appRequest.GitTag, appRequest.GitAuthName); err != nil {
+				s.Debug().Err(err).Msgf("git prefetch: error resolving tag for %s", sourceUrl)
+			}
+		}
+		if err == nil {
+			if _, _, _, _, err := repoCache.CheckoutRepo(sourceUrl, branch, commit,
+				appRequest.GitAuthName, appRequest.IsDev); err != nil {
+				s.Debug().Err(err).Msgf("git prefetch: error checking out %s", sourceUrl)
+			}
+		}
+	} else if system.IsPackaged(appRequest.SourceUrl) && !appRequest.IsDev {
+		if _, _, err := repoCache.FetchPackagedSource(ctx, appRequest.SourceUrl); err != nil {
+			s.Debug().Err(err).Msgf("source prefetch: error downloading %s", redactArchiveUrl(appRequest.SourceUrl))
 		}
 	}
 
@@ -148,6 +170,12 @@ func (s *Server) CreateAppTx(ctx context.Context, currentTx types.Transaction, a
 	if err := validatePathForCreate(appPathDomain.Path); err != nil {
 		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
 	}
+	if err := validateGitTagRequest(appRequest); err != nil {
+		return nil, err
+	}
+	if appRequest.SourceChecksum != "" && !system.IsArchive(appRequest.SourceUrl) {
+		return nil, types.CreateRequestError("source checksum can be used only with an archive source url", http.StatusBadRequest)
+	}
 
 	appPathDomain.Domain, err = s.normalizeRelativeDomain(appPathDomain.Domain, "Domain")
 	if err != nil {
@@ -172,6 +200,9 @@ func (s *Server) CreateAppTx(ctx context.Context, currentTx types.Transaction, a
 		return nil, types.CreateRequestError(
 			fmt.Sprintf("App already exists at %s", matchedApp), http.StatusBadRequest)
 	}
+	if err := s.checkTenantQuota(appPathDomain); err != nil {
+		return nil, err
+	}
 
 	sourceUrl := appRequest.SourceUrl
 	splitSource := strings.Split(sourceUrl, "#")
@@ -202,11 +233,16 @@ func (s *Server) CreateAppTx(ctx context.Context, currentTx types.Transaction, a
 	appEntry.Settings.PreviewWriteAccess = s.Config().Security.PreviewEnableWriteAccess
 
 	appEntry.Metadata.VersionMetadata = types.VersionMetadata{
-		Version: 0,
+		Version:        0,
+		GitTag:         appRequest.GitTag,
+		SourceChecksum: appRequest.SourceChecksum,
 	}
 
 	appEntry.Metadata.Spec = appRequest.Spec // validated in createApp
 	appEntry.Metadata.ParamValues = appRequest.ParamValues
+	if err := s.checkDeviceOptions(ctx, nil, appRequest.ContainerOptions); err != nil {
+		return nil, err
+	}
 	appEntry.Metadata.ContainerOptions = appRequest.ContainerOptions
 	appEntry.Metadata.ContainerArgs = appRequest.ContainerArgs
 	appEntry.Metadata.ContainerVolumes = appRequest.ContainerVolumes
@@ -230,6 +266,15 @@ func (s *Server) CreateAppTx(ctx context.Context, currentTx types.Transaction, a
 		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
 	}
 
+	// The container plugin is loaded only if approved, unapproved apps do not run a container
+	usesContainer := false
+	for _, result := range auditResult.ApproveResults {
+		usesContainer = usesContainer || (approve && slices.Contains(result.NewLoads, CONTAINER_PLUGIN))
+	}
+	if err := s.checkQuotas(ctx, currentTx, appEntry.UserID, system.GetContextGroups(ctx), usesContainer); err != nil {
+		return nil, err
+	}
+
 	return auditResult, nil
 }
 
@@ -284,11 +329,15 @@ func (s *Server) ListAppAuths() []string {
 
 func (s *Server) createApp(ctx context.Context, tx types.Transaction,
 	appEntry *types.AppEntry, approve, dryRun bool, branch, commit, gitAuth string, applyInfo *types.CreateAppRequest, repoCache *RepoCache) (*types.AppCreateResponse, error) {
-	if appEntry.Metadata.Spec == types.StaticDiskSpec && (system.IsGit(appEntry.SourceUrl) || appEntry.SourceUrl == types.NO_SOURCE) {
+	isPackaged := system.IsPackaged(appEntry.SourceUrl)
+	if appEntry.Metadata.Spec == types.StaticDiskSpec && (system.IsGit(appEntry.SourceUrl) || isPackaged || appEntry.SourceUrl == types.NO_SOURCE) {
 		return nil, fmt.Errorf("static_disk spec requires source_url to be a local disk directory")
 	}
+	if isPackaged && appEntry.IsDev {
+		return nil, fmt.Errorf("cannot create dev mode app with source url %s, source has to be disk", redactArchiveUrl(appEntry.SourceUrl))
+	}
 
-	if !system.IsGit(appEntry.SourceUrl) {
+	if !system.IsGit(appEntry.SourceUrl) && !isPackaged {
 		if appEntry.SourceUrl != types.NO_SOURCE {
 			// Make sure the source path is absolute
 			var err error
@@ -355,6 +404,11 @@ func (s *Server) createApp(ctx context.Context, tx types.Transaction,
 			return nil, fmt.Errorf("failed to load source %s from git: %w. Wrong org/repo name can show as auth error."+
 				" Use --git-auth for private repos, --branch to change branch", workEntry.SourceUrl, err)
 		}
+	} else if isPackaged {
+		// Download the archive or OCI artifact and load into database
+		if err := s.loadSourceFromPackage(ctx, tx, workEntry, repoCache); err != nil {
+			return nil, fmt.Errorf("failed to load source %s: %w", redactArchiveUrl(workEntry.SourceUrl), err)
+		}
 	} else if !workEntry.IsDev {
 		// App is loaded from disk (not git) and not in dev mode, load files into DB
 		if err := s.loadSourceFromDisk(ctx, tx, workEntry); err != nil {
@@ -368,6 +422,19 @@ func (s *Server) createApp(ctx context.Context, tx types.Transaction,
 		return nil, err
 	}
 
+	if applyInfo != nil && applyInfo.ParamsOnly {
+		params, err := application.ParamDefinitions()
+		if err != nil {
+			return nil, err
+		}
+		return &types.AppCreateResponse{
+			AppPathDomain: appEntry.AppPathDomain(),
+			DryRun:        true,
+			SourceUrl:     appEntry.SourceUrl,
+			Params:        params,
+		}, nil
+	}
+
 	s.Debug().Msgf("Created app %s %s", workEntry.Path, workEntry.Id)
 	auditResult, err := s.auditApp(ctx, tx, application, approve)
 	if err != nil {
@@ -392,7 +459,7 @@ func (s *Server) createApp(ctx context.Context, tx types.Transaction,
 	results := []types.ApproveResult{*auditResult}
 	if !workEntry.IsDev {
 		// Update the prod app metadata, promote from stage
-		if err = s.promoteApp(ctx, tx, stageAppEntry, appEntry); err != nil {
+		if _, err = s.promoteApp(newAppPromoteContext(ctx), tx, stageAppEntry, appEntry); err != nil {
 			return nil, err
 		}
 
@@ -416,6 +483,7 @@ func (s *Server) createApp(ctx context.Context, tx types.Transaction,
 		ApproveResults: results,
 		OrigSourceUrl:  appEntry.Settings.OrigSourceUrl,
 		SourceUrl:      appEntry.SourceUrl,
+		Image:          appImage(&appEntry.Metadata),
 	}
 
 	return ret, nil
@@ -534,9 +602,21 @@ func (s *Server) setupApp(ctx context.Context, appEntry *types.AppEntry, tx type
 		return nil, err
 	}
 	merged := s.Config()
-	return app.NewApp(sourceFS, workFS, &appLogger, appEntry, &merged.System,
+	newApp, err := app.NewApp(sourceFS, workFS, &appLogger, appEntry, &merged.System,
 		merged.Plugins, merged.AppConfig, s.notifyClose, s.AppEvalTemplate,
 		s.InsertAuditEvent, merged, s.rbacManager, bindings)
+	if err != nil {
+		return nil, err
+	}
+	newApp.SetCaptureRegistry(s.captures)
+	newApp.SetProfileRegistry(s.profiles)
+	newApp.SetResourceQuota(s.resourceQuota)
+	newApp.SetBlockRenderer(s.renderAppBlock)
+	appId := appEntry.Id
+	newApp.SetPrintHandler(func(msg string) {
+		s.appLogs.add(appId, types.AppLogEntry{Time: time.Now(), Source: types.AppLogSourceHandler, Level: "print", Message: msg})
+	})
+	return newApp, nil
 }
 
 func (s *Server) getAppBindings(ctx context.Context, inpTx types.Transaction, appEntry *types.AppEntry) ([]*types.Binding, error) {
@@ -626,6 +706,9 @@ func (s *Server) GetApp(ctx context.Context, pathDomain types.AppPathDomain, ini
 	if !init {
 		return application, nil
 	}
+	if application.Settings.Inactive() {
+		return nil, inactiveAppError(application)
+	}
 
 	// Initialize the app
 	if err := application.Initialize(ctx, types.DryRunFalse); err != nil {
@@ -651,6 +734,14 @@ func (s *Server) DeleteApps(ctx context.Context, appPathGlob string, dryRun bool
 		return nil, err
 	}
 
+	if !dryRun {
+		unlock, err := s.lockAppInfos(ctx, "delete_apps", filteredApps)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
 	tx, err := s.db.BeginTransaction(ctx)
 	if err != nil {
 		return nil, err
@@ -681,6 +772,7 @@ func (s *Server) DeleteApps(ctx context.Context, appPathGlob string, dryRun bool
 		if err := s.apps.ClearLinkedApps(appInfo.AppPathDomain); err != nil {
 			return nil, fmt.Errorf("error deleting app: %s", err)
 		}
+		s.appLogs.remove(appInfo.Id)
 	}
 
 	return ret, nil
@@ -737,7 +829,7 @@ func (s *Server) authenticateAndServeApp(w http.ResponseWriter, r *http.Request,
 
 	if strippedAuth == types.AppAuthnNone {
 		if s.Config().Security.AuthRequired {
-			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			s.errorPage(w, r, http.StatusUnauthorized, "error.auth_required")
 			return
 		}
 		// No authentication required
@@ -779,7 +871,7 @@ func (s *Server) authenticateAndServeApp(w http.ResponseWriter, r *http.Request,
 		}
 		if !authOk {
 			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm="%s"`, REALM))
-			http.Error(w, "Authentication failed", http.StatusUnauthorized)
+			s.errorPage(w, r, http.StatusUnauthorized, "error.auth_failed")
 			return
 		}
 	} else if strippedAuthStr == "cert" || strings.HasPrefix(strippedAuthStr, "cert_") {
@@ -841,7 +933,7 @@ func (s *Server) authenticateAndServeApp(w http.ResponseWriter, r *http.Request,
 		// The user is authenticated but not authorized (no app:access grant): 403,
 		// not 401 - re-authenticating would not help
 		s.Warn().Msgf("User %s is not authorized to access app %s", userId, app.AppPathDomain())
-		http.Error(w, fmt.Sprintf("Forbidden : %s does not have access to %s", userId, app.AppPathDomain()), http.StatusForbidden)
+		s.errorPage(w, r, http.StatusForbidden, "error.forbidden_app", userId, app.AppPathDomain())
 		return
 	}
 
@@ -896,6 +988,9 @@ func (s *Server) authenticateAndServeApp(w http.ResponseWriter, r *http.Request,
 	if forwardConfig != nil {
 		appHandler = s.forwardAuthMiddleware(appHandler, forwardConfig)
 	}
+	if app.Settings.Deprecation != nil {
+		appHandler = deprecationMiddleware(appHandler, app.Settings.Deprecation)
+	}
 	// Authentication successful, serve the app
 	appHandler.ServeHTTP(w, r)
 }
@@ -1219,8 +1314,13 @@ func (s *Server) auditApp(ctx context.Context, tx types.Transaction, app *app.Ap
 func (s *Server) approveAuditResult(app *app.App, auditResult *types.ApproveResult) {
 	app.Metadata.Loads = auditResult.NewLoads
 	app.Metadata.Permissions = auditResult.NewPermissions
-	s.Info().Msgf("Approved app %s %s: loads=%+v permissions=%+v",
-		app.Path, app.Domain, auditResult.NewLoads, auditResult.NewPermissions)
+	app.Metadata.Env = auditResult.NewEnv
+	s.Info().Msgf("Approved app %s %s: loads=%+v permissions=%+v env=%+v",
+		app.Path, app.Domain, auditResult.NewLoads, auditResult.NewPermissions, auditResult.NewEnv)
+	if len(auditResult.UndeclaredLoads) > 0 || len(auditResult.UndeclaredPermissions) > 0 {
+		s.Warn().Msgf("Approved app %s %s uses entries not declared by spec %s: loads=%+v permissions=%+v",
+			app.Path, app.Domain, auditResult.Spec, auditResult.UndeclaredLoads, auditResult.UndeclaredPermissions)
+	}
 }
 
 func (s *Server) CompleteTransaction(ctx context.Context, tx types.Transaction, entries []types.AppPathDomain, dryRun bool, op string) error {
@@ -1234,6 +1334,7 @@ func (s *Server) CompleteTransaction(ctx context.Context, tx types.Transaction,
 			return err
 		}
 		committed = true
+		s.insertPromoteAuditEvents(ctx)
 	}
 
 	// Update the in memory cache
@@ -1326,7 +1427,8 @@ func parseLinkedAppPathDomain(linkedAppPath string) (types.AppPathDomain, error)
 
 func isInternalAppPath(path string) bool {
 	return strings.HasPrefix(path, "/"+types.STAGE_SUFFIX) ||
-		strings.HasPrefix(path, "/"+types.PREVIEW_SUFFIX)
+		strings.HasPrefix(path, "/"+types.PREVIEW_SUFFIX) ||
+		strings.HasPrefix(path, "/"+types.PR_PREVIEW_SUFFIX)
 }
 
 // mainAppPathDomain returns the path-domain used for grant checks and glob matching.
@@ -1438,6 +1540,21 @@ func (s *Server) loadGitKey(gitAuth string) (*gitAuthEntry, error) {
 		return nil, fmt.Errorf("error resolving git auth %s password: %w", gitAuth, err)
 	}
 
+	if authEntry.Type != "" {
+		// GitHub App or OIDC auth, a short lived token is used for https access. For a GitHub App,
+		// the private key is the App key, not a SSH key
+		token, err := s.gitAuthToken(gitAuth, authEntry)
+		if err != nil {
+			return nil, err
+		}
+		return &gitAuthEntry{
+			user:     gitTokenUser(authEntry),
+			key:      []byte{},
+			password: token,
+			usingSSH: false,
+		}, nil
+	}
+
 	gitKey := []byte{}
 	user := authEntry.UserID
 	usingSSH := false
@@ -1464,12 +1581,26 @@ func (s *Server) loadGitKey(gitAuth string) (*gitAuthEntry, error) {
 
 func (s *Server) loadSourceFromGit(ctx context.Context, tx types.Transaction, appEntry *types.AppEntry, branch, commit, gitAuth string, repoCache *RepoCache) error {
 	gitAuth = cmp.Or(gitAuth, appEntry.Metadata.GitAuthName)
+	resolvedTag := ""
+	if followsGitTag(appEntry, branch, commit) {
+		var err error
+		resolvedTag, commit, err = repoCache.ResolveTag(appEntry.SourceUrl, appEntry.Metadata.VersionMetadata.GitTag, gitAuth)
+		if err != nil {
+			return err
+		}
+	} else if branch != "" || commit != "" {
+		// An explicit branch or commit stops the app from following the tag
+		appEntry.Metadata.VersionMetadata.GitTag = ""
+	}
 	branch = cmp.Or(branch, appEntry.Metadata.VersionMetadata.GitBranch, "main")
 
 	repo, folder, message, hash, err := repoCache.CheckoutRepo(appEntry.SourceUrl, branch, commit, gitAuth, appEntry.IsDev)
 	if err != nil {
 		return err
 	}
+	if err := s.verifySource(ctx, appEntry, hash, repoCache); err != nil {
+		return err
+	}
 
 	if system.IsGit(appEntry.SourceUrl) && appEntry.IsDev {
 		// Dev app from git, we need to point the app to the local checkout location
@@ -1486,6 +1617,7 @@ func (s *Server) loadSourceFromGit(ctx context.Context, tx types.Transaction, ap
 	// This function will persist it into the app_version metadata
 	appEntry.Metadata.VersionMetadata.GitCommit = hash
 	appEntry.Metadata.VersionMetadata.GitMessage = message
+	appEntry.Metadata.VersionMetadata.GitResolvedTag = resolvedTag
 	if commit != "" {
 		appEntry.Metadata.VersionMetadata.GitBranch = ""
 	} else {
@@ -1516,6 +1648,9 @@ func (s *Server) loadSourceFromGit(ctx context.Context, tx types.Transaction, ap
 	}
 	appEntry.Metadata.VersionMetadata.PreviousVersion = prevVersion
 	appEntry.Metadata.VersionMetadata.Version = highestVersion + 1
+	if err := s.setImageOptions(fileStore, appEntry); err != nil {
+		return err
+	}
 	if err := fileStore.AddAppVersionDisk(ctx, tx, appEntry.Metadata, checkoutFolder); err != nil {
 		return err
 	}
@@ -1524,9 +1659,14 @@ func (s *Server) loadSourceFromGit(ctx context.Context, tx types.Transaction, ap
 }
 
 func (s *Server) loadSourceFromDisk(ctx context.Context, tx types.Transaction, appEntry *types.AppEntry) error {
+	if err := s.verifySource(ctx, appEntry, "", nil); err != nil {
+		return err
+	}
 	s.Info().Msgf("Loading app sources from %s", appEntry.SourceUrl)
 	appEntry.Metadata.VersionMetadata.GitBranch = ""
 	appEntry.Metadata.VersionMetadata.GitCommit = ""
+	appEntry.Metadata.VersionMetadata.GitTag = ""
+	appEntry.Metadata.VersionMetadata.GitResolvedTag = ""
 	appEntry.Metadata.GitAuthName = ""
 	appEntry.Metadata.VersionMetadata.GitMessage = ""
 
@@ -1553,12 +1693,43 @@ func (s *Server) loadSourceFromDisk(ctx context.Context, tx types.Transaction, a
 		}
 		checkoutDir = types.NO_SOURCE
 	}
+	if err := s.setImageOptions(fileStore, appEntry); err != nil {
+		return err
+	}
 	if err := fileStore.AddAppVersionDisk(ctx, tx, appEntry.Metadata, checkoutDir); err != nil {
 		return err
 	}
 	return nil
 }
 
+// setImageOptions sets the options for creating the static image variants, from the image app
+// config. The variants are not created for dev apps
+func (s *Server) setImageOptions(fileStore *metadata.FileStore, appEntry *types.AppEntry) error {
+	if appEntry.IsDev {
+		return nil
+	}
+	config := s.Config()
+	appConfig, _, err := app.ResolveAppConfig(s.Logger, config.AppConfig, config.TagConfig, appEntry)
+	if err != nil {
+		return fmt.Errorf("error resolving app config: %w", err)
+	}
+	options := appfs.ImageOptions{Widths: appConfig.Image.Widths, JpegQuality: appConfig.Image.JpegQuality}
+	if appConfig.Image.WebP {
+		if config.System.ImageWebPCommand == "" {
+			return fmt.Errorf("image.webp is enabled but system.image_webp_command is not set")
+		}
+		options.WebPCommand = config.System.ImageWebPCommand
+	}
+	if appConfig.Image.AVIF {
+		if config.System.ImageAVIFCommand == "" {
+			return fmt.Errorf("image.avif is enabled but system.image_avif_command is not set")
+		}
+		options.AVIFCommand = config.System.ImageAVIFCommand
+	}
+	fileStore.SetImageOptions(options)
+	return nil
+}
+
 func validateStaticDiskSource(sourceUrl string) error {
 	fi, err := os.Stat(sourceUrl)
 	if err != nil {
@@ -1634,25 +1805,46 @@ func (s *Server) GetApps(ctx context.Context, appPathGlob string, internal bool)
 		if !authorized {
 			continue
 		}
-		retApp, err := s.db.GetAppEntryTx(ctx, tx, app.AppPathDomain)
+		appResponse, err := s.getAppResponse(ctx, tx, app)
 		if err != nil {
-			return nil, types.CreateRequestError(err.Error(), http.StatusInternalServerError)
+			return nil, err
 		}
+		ret = append(ret, *appResponse)
+	}
+	return ret, nil
+}
 
-		stagedChanges := false
-		if strings.HasPrefix(string(app.Id), types.ID_PREFIX_APP_PROD) {
-			stageApp, err := s.getStageApp(ctx, tx, retApp)
-			if err != nil {
-				return nil, err
-			}
-			if stageApp.Metadata.VersionMetadata.Version != retApp.Metadata.VersionMetadata.Version {
-				// staging app is at different version than prod app
-				stagedChanges = true
-			}
+// getAppResponse returns the app entry for the list response, with the staged changes flag set
+// if the staging app is at a different version than the prod app
+func (s *Server) getAppResponse(ctx context.Context, tx types.Transaction, app types.AppInfo) (*types.AppResponse, error) {
+	retApp, err := s.db.GetAppEntryTx(ctx, tx, app.AppPathDomain)
+	if err != nil {
+		return nil, types.CreateRequestError(err.Error(), http.StatusInternalServerError)
+	}
+
+	stagedChanges := false
+	if strings.HasPrefix(string(app.Id), types.ID_PREFIX_APP_PROD) {
+		stageApp, err := s.getStageApp(ctx, tx, retApp)
+		if err != nil {
+			return nil, err
+		}
+		if stageApp.Metadata.VersionMetadata.Version != retApp.Metadata.VersionMetadata.Version {
+			// staging app is at different version than prod app
+			stagedChanges = true
 		}
-		ret = append(ret, types.AppResponse{AppEntry: *retApp, StagedChanges: stagedChanges})
 	}
-	return ret, nil
+	return &types.AppResponse{AppEntry: *retApp, StagedChanges: stagedChanges}, nil
+}
+
+// addStartTimings sets the timing of the last load for the apps which are loaded on this server node
+func (s *Server) addStartTimings(apps []types.AppResponse) {
+	for i := range apps {
+		loadedApp, err := s.apps.GetApp(apps[i].AppPathDomain())
+		if err != nil {
+			continue // app not loaded on this node
+		}
+		apps[i].StartTiming = loadedApp.StartTiming()
+	}
 }
 
 func (s *Server) PreviewApp(ctx context.Context, mainAppPath, commitId string, approve, dryRun bool) (*types.AppPreviewResponse, error) {
@@ -1728,21 +1920,55 @@ func (s *Server) PreviewApp(ctx context.Context, mainAppPath, commitId string, a
 		return nil, fmt.Errorf("preview app %s already exists", previewAppEntry.AppPathDomain())
 	}
 
+	auditResult, err := s.loadPreviewApp(ctx, tx, &previewAppEntry, "", commitId, approve, repoCache)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &types.AppPreviewResponse{
+		DryRun:        dryRun,
+		HttpUrl:       s.getAppHttpUrl(&previewAppEntry),
+		HttpsUrl:      s.getAppHttpsUrl(&previewAppEntry),
+		ApproveResult: *auditResult,
+		Success:       true,
+	}
+
+	if auditResult.NeedsApproval && !approve {
+		ret.Success = false // Needs approval but not approved, do not create the preview app
+		return ret, nil
+	}
+
+	if dryRun {
+		return ret, nil
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.apps.ResetAllAppCache() // Clear the cache so that the new app is loaded next time
+	return ret, nil
+}
+
+// loadPreviewApp creates the preview app entry and loads its source from git at the branch or
+// commit. The app is audited, the caller checks the result for whether approval is needed
+func (s *Server) loadPreviewApp(ctx context.Context, tx types.Transaction, previewAppEntry *types.AppEntry,
+	branch, commitId string, approve bool, repoCache *RepoCache) (*types.ApproveResult, error) {
 	previewAppEntry.Metadata.VersionMetadata = types.VersionMetadata{
 		Version: 0,
 	}
 
-	if err := s.db.CreateApp(ctx, tx, &previewAppEntry); err != nil {
+	if err := s.db.CreateApp(ctx, tx, previewAppEntry); err != nil {
 		return nil, err
 	}
 
 	// Checkout the git repo locally and load into database
-	if err := s.loadSourceFromGit(ctx, tx, &previewAppEntry, "", commitId, previewAppEntry.Metadata.GitAuthName, repoCache); err != nil {
+	if err := s.loadSourceFromGit(ctx, tx, previewAppEntry, branch, commitId, previewAppEntry.Metadata.GitAuthName, repoCache); err != nil {
 		return nil, fmt.Errorf("failed to load source %s from git: %w", previewAppEntry.SourceUrl, err)
 	}
 
 	// Create the in memory app object
-	application, err := s.setupApp(ctx, &previewAppEntry, tx)
+	application, err := s.setupApp(ctx, previewAppEntry, tx)
 	if err != nil {
 		return nil, err
 	}
@@ -1754,36 +1980,13 @@ func (s *Server) PreviewApp(ctx context.Context, mainAppPath, commitId string, a
 	}
 
 	// Persist the metadata so that any git info is saved
-	if err := s.db.UpdateAppMetadata(ctx, tx, &previewAppEntry); err != nil {
+	if err := s.db.UpdateAppMetadata(ctx, tx, previewAppEntry); err != nil {
 		return nil, err
 	}
 
 	// Persist the settings
-	if err := s.db.UpdateAppSettings(ctx, tx, &previewAppEntry); err != nil {
-		return nil, err
-	}
-
-	ret := &types.AppPreviewResponse{
-		DryRun:        dryRun,
-		HttpUrl:       s.getAppHttpUrl(&previewAppEntry),
-		HttpsUrl:      s.getAppHttpsUrl(&previewAppEntry),
-		ApproveResult: *auditResult,
-		Success:       true,
-	}
-
-	if auditResult.NeedsApproval && !approve {
-		ret.Success = false // Needs approval but not approved, do not create the preview app
-		return ret, nil
-	}
-
-	if dryRun {
-		return ret, nil
-	}
-
-	if err = tx.Commit(); err != nil {
+	if err := s.db.UpdateAppSettings(ctx, tx, previewAppEntry); err != nil {
 		return nil, err
 	}
-
-	s.apps.ResetAllAppCache() // Clear the cache so that the new app is loaded next time
-	return ret, nil
+	return auditResult, nil
 }
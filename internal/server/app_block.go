@@ -0,0 +1,86 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/app/starlark_type"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// renderAppBlock renders a block shared by another app, for the appBlock template function. The
+// target app has to share the block and the request user has to have access to the target app,
+// see checkAppBlockAccess
+func (s *Server) renderAppBlock(caller *app.App, appPath, block string, data any) (template.HTML, error) {
+	target, err := parseAppPath(appPath)
+	if err != nil {
+		return "", err
+	}
+	if target == caller.AppPathDomain() {
+		return "", fmt.Errorf("appBlock cannot include blocks from the same app, use template instead")
+	}
+
+	targetApp, err := s.GetApp(context.Background(), target, true)
+	if err != nil {
+		return "", fmt.Errorf("appBlock: error loading app %s: %w", target, err)
+	}
+	if err := s.checkAppBlockAccess(caller, targetApp, data); err != nil {
+		return "", err
+	}
+	return targetApp.RenderSharedBlock(block, data)
+}
+
+// checkAppBlockAccess checks that the caller app can include blocks from the target app. The
+// two apps have to be in the same tenant. The auth and RBAC settings of the target app are
+// enforced for the user of the caller app request, independent of the caller app settings:
+// unless the target app allows unauthenticated access, the caller app has to use the same auth
+// type. With RBAC enabled, the user has to have the access permission on the target app
+func (s *Server) checkAppBlockAccess(caller, targetApp *app.App, data any) error {
+	callerMain := mainAppPathDomain(caller.AppPathDomain(), caller.MainApp, caller.LinkedAppPath)
+	targetMain := mainAppPathDomain(targetApp.AppPathDomain(), targetApp.MainApp, targetApp.LinkedAppPath)
+	if callerTenant, targetTenant := s.rbacManager.TenantForApp(callerMain), s.rbacManager.TenantForApp(targetMain); callerTenant != targetTenant {
+		return fmt.Errorf("appBlock: app %s is not in the same tenant as app %s", targetMain, callerMain)
+	}
+	s.recordAppBlockUse(callerMain, targetMain)
+
+	request, isRequest := data.(starlark_type.Request)
+	if !isRequest && s.rbacManager.ConfigEnabled() {
+		return fmt.Errorf("appBlock: the request (.) has to be passed as data when RBAC is enabled")
+	}
+	userId := types.ANONYMOUS_USER
+	var groups []string
+	if isRequest {
+		userId, groups = request.UserId, request.UserGroups
+	}
+
+	targetAuth := appBlockAuthType(targetApp.Metadata.AuthnType, s.Config())
+	if targetAuth != string(types.AppAuthnNone) {
+		callerAuth := appBlockAuthType(caller.Metadata.AuthnType, s.Config())
+		if !isRequest || userId == "" || userId == types.ANONYMOUS_USER || callerAuth != targetAuth {
+			return fmt.Errorf("appBlock: app %s requires %s authentication, the request to app %s is not authenticated with it",
+				targetMain, targetAuth, callerMain)
+		}
+	}
+
+	authorized, err := s.rbacManager.AuthorizeInt(userId, targetMain, types.PermissionAccess, groups, false)
+	if err != nil {
+		return err
+	}
+	if !authorized {
+		return fmt.Errorf("appBlock: user %s does not have access to app %s", userId, targetMain)
+	}
+	return nil
+}
+
+// appBlockAuthType returns the auth type the app authenticates users with, with the app default
+// resolved and without the rbac prefix and the auth modifiers
+func appBlockAuthType(appAuth types.AppAuthnType, config *types.ServerConfig) string {
+	baseType, _, _ := strings.Cut(resolveAppAuth(appAuth, config), types.AUTH_MODIFIER_DELIMITER)
+	return baseType
+}
@@ -0,0 +1,63 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"testing"
+
+	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/app/starlark_type"
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func newAppBlockTestApp(path string, auth types.AppAuthnType) *app.App {
+	return &app.App{AppEntry: &types.AppEntry{
+		Id:       types.AppId(types.ID_PREFIX_APP_PROD + path[1:]),
+		Path:     path,
+		Metadata: types.AppMetadata{AuthnType: auth},
+	}}
+}
+
+func TestAppBlockAccess(t *testing.T) {
+	server := newAsUserTestServer(t, &types.RBACConfig{
+		Enabled: true,
+		Roles: map[string][]types.RBACPermission{
+			"viewer": {types.PermissionAccess},
+		},
+		Grants: []types.RBACGrant{
+			{Description: "dev group views the design app", Users: []string{"group:dev"},
+				Roles: []string{"viewer"}, Targets: []string{"/design"}},
+		},
+	})
+	caller := newAppBlockTestApp("/caller", types.AppAuthnBuiltin)
+	design := newAppBlockTestApp("/design", types.AppAuthnBuiltin)
+	alice := starlark_type.Request{UserId: "builtin:alice", UserGroups: []string{"dev"}}
+
+	testutil.AssertNoError(t, server.checkAppBlockAccess(caller, design, alice))
+
+	// The target RBAC is checked even if RBAC is not active for the caller request
+	bob := starlark_type.Request{UserId: "builtin:bob", AppRBACEnabled: false}
+	testutil.AssertErrorContains(t, server.checkAppBlockAccess(caller, design, bob), "user builtin:bob does not have access to app /design")
+	testutil.AssertErrorContains(t, server.checkAppBlockAccess(caller, design, map[string]any{}), "the request (.) has to be passed")
+
+	// An unauthenticated caller app cannot include blocks from an app which requires auth
+	anonymous := starlark_type.Request{UserId: types.ANONYMOUS_USER}
+	testutil.AssertErrorContains(t, server.checkAppBlockAccess(newAppBlockTestApp("/public", types.AppAuthnNone), design, anonymous),
+		"app /design requires builtin authentication")
+	testutil.AssertErrorContains(t, server.checkAppBlockAccess(newAppBlockTestApp("/other", types.AppAuthnSystem), design, alice),
+		"app /design requires builtin authentication")
+}
+
+func TestAppBlockAccessNoRBAC(t *testing.T) {
+	server := newAsUserTestServer(t, &types.RBACConfig{Enabled: false})
+	caller := newAppBlockTestApp("/caller", types.AppAuthnNone)
+	anonymous := starlark_type.Request{UserId: types.ANONYMOUS_USER}
+
+	testutil.AssertNoError(t, server.checkAppBlockAccess(caller, newAppBlockTestApp("/design", types.AppAuthnNone), anonymous))
+	testutil.AssertNoError(t, server.checkAppBlockAccess(caller, newAppBlockTestApp("/design", types.AppAuthnNone), "data"))
+	// The target app auth is enforced with RBAC disabled
+	testutil.AssertErrorContains(t, server.checkAppBlockAccess(caller, newAppBlockTestApp("/design", types.AppAuthnSystem), anonymous),
+		"app /design requires system authentication")
+}
@@ -0,0 +1,53 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"maps"
+	"slices"
+
+	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// ShowAppConfig returns the app config for an app. By default, the per-app config entries are
+// returned. With effective, the config resolved from the server app_config, the tag_config for
+// the app tags and the per-app entries is returned, with the layer which set each value
+func (s *Server) ShowAppConfig(ctx context.Context, appPath string, effective bool) (*types.AppConfigResponse, error) {
+	appPathDomain, err := parseAppPath(appPath)
+	if err != nil {
+		return nil, err
+	}
+	appEntry, err := s.db.GetAppEntry(ctx, appPathDomain)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.enforceAppPermEntry(ctx, types.PermissionRead, appEntry); err != nil {
+		return nil, err
+	}
+
+	ret := &types.AppConfigResponse{AppPathDomain: appPathDomain, Effective: effective}
+	if !effective {
+		ret.Values = make([]types.AppConfigValue, 0, len(appEntry.Metadata.AppConfig))
+		for _, key := range slices.Sorted(maps.Keys(appEntry.Metadata.AppConfig)) {
+			ret.Values = append(ret.Values, types.AppConfigValue{
+				Key:    key,
+				Value:  appEntry.Metadata.AppConfig[key],
+				Source: types.AppConfigSourceApp,
+			})
+		}
+		return ret, nil
+	}
+
+	merged := s.Config()
+	config, sources, err := app.ResolveAppConfig(s.Logger, merged.AppConfig, merged.TagConfig, appEntry)
+	if err != nil {
+		return nil, err
+	}
+	if ret.Values, err = app.EffectiveAppConfig(config, sources); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
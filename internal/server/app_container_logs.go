@@ -0,0 +1,175 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/container"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	// defaultContainerLogLines is the number of recent container log lines returned before following
+	defaultContainerLogLines = 500
+	// containerLogMaxLinesPerSec is the max rate at which container log lines are streamed to a
+	// client. Lines above the rate are dropped and a line with the dropped count is sent instead,
+	// so a container writing logs in a tight loop cannot overload the server or the client
+	containerLogMaxLinesPerSec = 1000
+)
+
+// ansiEscape matches the ANSI CSI sequences, like color codes and cursor movement, and the OSC
+// sequences, like terminal title updates and hyperlinks
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[ -/]*[@-~]|\x1b\][^\x07\x1b]*(?:\x07|\x1b\\)|\x1b[@-Z\\-_]`)
+
+// stripANSI removes the terminal escape sequences from a log line
+func stripANSI(line string) string {
+	if !strings.Contains(line, "\x1b") {
+		return line
+	}
+	return ansiEscape.ReplaceAllString(line, "")
+}
+
+// logRateLimiter limits the lines streamed per second. The count of the dropped lines is
+// reported when the next line is allowed
+type logRateLimiter struct {
+	maxPerSec   int
+	windowStart time.Time
+	count       int
+	dropped     int
+}
+
+// allow reports whether a line at now can be sent. dropped is the count of the lines dropped
+// since the last allowed line, to be reported before this line
+func (l *logRateLimiter) allow(now time.Time) (ok bool, dropped int) {
+	if now.Sub(l.windowStart) >= time.Second {
+		l.windowStart = now
+		l.count = 0
+	}
+	if l.count >= l.maxPerSec {
+		l.dropped++
+		return false, 0
+	}
+	l.count++
+	dropped, l.dropped = l.dropped, 0
+	return true, dropped
+}
+
+// AppContainerLogs returns the stream of the logs of the app container, or of the named service
+// container. The last tail lines are returned first, with follow new lines are streamed until
+// ctx is canceled or the container stops. ANSI escape sequences are removed unless raw is set
+func (s *Server) AppContainerLogs(ctx context.Context, appPath, service string, tail int, follow, raw bool) (apiStream, error) {
+	appPathDomain, err := parseAppPath(appPath)
+	if err != nil {
+		return nil, err
+	}
+	application, err := s.GetApp(ctx, appPathDomain, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.enforceAppPermEntry(ctx, types.PermissionRead, application.AppEntry); err != nil {
+		return nil, err
+	}
+
+	runtime := s.containerRuntime()
+	if runtime == "" {
+		return nil, types.CreateRequestError("no container command is configured on the server", http.StatusBadRequest)
+	}
+	containerName, err := appContainerName(application, service)
+	if err != nil {
+		return nil, err
+	}
+	if tail <= 0 {
+		tail = defaultContainerLogLines
+	}
+
+	// The pod log stream is opened before returning, so that an error is returned as the API response
+	ctx, cancel := context.WithCancel(ctx)
+	ch := make(chan types.AppLogEntry, 256)
+	if runtime == types.CONTAINER_KUBERNETES {
+		reader, err := container.GetWorkloadPodLogsStream(ctx, s.Config(), containerName, tail, follow)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		go func() {
+			defer close(ch)
+			defer reader.Close() //nolint:errcheck
+			if err := scanContainerLogs(ctx, reader, ch); err != nil {
+				s.Debug().Err(err).Str("container", containerName).Msg("error reading container logs")
+			}
+		}()
+	} else {
+		cmd := s.containerLogsCmd(ctx, containerName, tail, time.Time{}, follow)
+		go func() {
+			if err := readContainerLogs(ctx, cmd, ch); err != nil {
+				s.Debug().Err(err).Str("container", containerName).Msg("error reading container logs")
+			}
+		}()
+	}
+
+	return func(yield func(any) bool) {
+		defer cancel()
+		limiter := &logRateLimiter{maxPerSec: containerLogMaxLinesPerSec}
+		for entry := range ch {
+			ok, dropped := limiter.allow(time.Now())
+			if !ok {
+				continue
+			}
+			if dropped > 0 {
+				if !yield(droppedLogEntry(dropped)) {
+					return
+				}
+			}
+			if !raw {
+				entry.Message = stripANSI(entry.Message)
+			}
+			if !yield(entry) {
+				return
+			}
+		}
+		if limiter.dropped > 0 {
+			yield(droppedLogEntry(limiter.dropped))
+		}
+	}, nil
+}
+
+// appContainerName returns the name of the running app container, or of the named service container
+func appContainerName(application *app.App, service string) (string, error) {
+	containerName := ""
+	if service == "" {
+		if name, ok := application.ActiveContainerName(); ok {
+			containerName = string(name)
+		}
+	} else {
+		prefix := fmt.Sprintf("clc-%s-%s-", application.Id, service)
+		for _, name := range application.ActiveServiceNames() {
+			if strings.HasPrefix(string(name), prefix) {
+				containerName = string(name)
+				break
+			}
+		}
+	}
+	if containerName == "" {
+		if service != "" {
+			return "", types.CreateRequestError(fmt.Sprintf("app %s has no running service %s", application.AppPathDomain(), service), http.StatusNotFound)
+		}
+		return "", types.CreateRequestError(fmt.Sprintf("app %s has no running container", application.AppPathDomain()), http.StatusNotFound)
+	}
+	return containerName, nil
+}
+
+func droppedLogEntry(dropped int) types.AppLogEntry {
+	return types.AppLogEntry{
+		Time:    time.Now(),
+		Source:  types.AppLogSourceError,
+		Message: fmt.Sprintf("%d lines dropped, the container logs are above the limit of %d lines per second", dropped, containerLogMaxLinesPerSec),
+	}
+}
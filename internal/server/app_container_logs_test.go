@@ -0,0 +1,39 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func TestStripANSI(t *testing.T) {
+	testutil.AssertEqualsString(t, "plain", "hello", stripANSI("hello"))
+	testutil.AssertEqualsString(t, "color", "INFO started", stripANSI("\x1b[32mINFO\x1b[0m started"))
+	testutil.AssertEqualsString(t, "cursor", "done", stripANSI("\x1b[2K\x1b[1Gdone"))
+	testutil.AssertEqualsString(t, "title", "ready", stripANSI("\x1b]0;my title\x07ready"))
+	testutil.AssertEqualsString(t, "link", "docs", stripANSI("\x1b]8;;https://openrun.dev\x1b\\docs\x1b]8;;\x1b\\"))
+}
+
+func TestLogRateLimiter(t *testing.T) {
+	limiter := &logRateLimiter{maxPerSec: 2}
+	now := time.Now()
+	ok, dropped := limiter.allow(now)
+	testutil.AssertEqualsBool(t, "first", true, ok)
+	ok, _ = limiter.allow(now)
+	testutil.AssertEqualsBool(t, "second", true, ok)
+	ok, _ = limiter.allow(now.Add(100 * time.Millisecond))
+	testutil.AssertEqualsBool(t, "above rate", false, ok)
+	ok, _ = limiter.allow(now.Add(200 * time.Millisecond))
+	testutil.AssertEqualsBool(t, "above rate", false, ok)
+
+	// The next window allows lines again, the dropped count is reported once
+	ok, dropped = limiter.allow(now.Add(time.Second))
+	testutil.AssertEqualsBool(t, "next window", true, ok)
+	testutil.AssertEqualsInt(t, "dropped", 2, dropped)
+	_, dropped = limiter.allow(now.Add(time.Second))
+	testutil.AssertEqualsInt(t, "dropped reset", 0, dropped)
+}
@@ -0,0 +1,162 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openrundev/openrun/internal/container"
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// apiUpgrade is returned by the API funcs which switch the connection to a bidirectional stream,
+// like app exec. It is called with the connection after the 101 Switching Protocols response is
+// sent. The returned detail is recorded in the audit event for the API call
+type apiUpgrade func(conn io.ReadWriter) (detail string, err error)
+
+// serveAPIUpgrade takes over the connection from the HTTP server and runs the upgrade func on it.
+// The connection is closed when the func returns
+func serveAPIUpgrade(w http.ResponseWriter, protocol string, upgrade apiUpgrade) (string, error) {
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "connection upgrade is not supported", http.StatusInternalServerError)
+		return "", fmt.Errorf("error hijacking connection: %w", err)
+	}
+	defer conn.Close() //nolint:errcheck
+	// The server read and write timeouts do not apply to the stream
+	_ = conn.SetDeadline(time.Time{})
+
+	if _, err := rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: " + protocol + "\r\n\r\n"); err != nil {
+		return "", err
+	}
+	if err := rw.Flush(); err != nil {
+		return "", err
+	}
+	// Reads go through the buffered reader, which could have data read ahead by the server
+	return upgrade(struct {
+		io.Reader
+		io.Writer
+	}{rw.Reader, conn})
+}
+
+// AppExec returns the func which runs the command in the app container, or in the named service
+// container, streaming the command input and output over the upgraded connection. With stdin, the
+// client input is passed to the command. The container is looked up before returning, so that
+// an error is returned as the API response
+func (s *Server) AppExec(ctx context.Context, appPath, service string, command []string, stdin bool) (apiUpgrade, error) {
+	if len(command) == 0 {
+		return nil, types.CreateRequestError("command is required", http.StatusBadRequest)
+	}
+	appPathDomain, err := parseAppPath(appPath)
+	if err != nil {
+		return nil, err
+	}
+	application, err := s.GetApp(ctx, appPathDomain, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.enforceAppPermEntry(ctx, types.PermissionExec, application.AppEntry); err != nil {
+		return nil, err
+	}
+
+	runtime := s.containerRuntime()
+	if runtime == "" {
+		return nil, types.CreateRequestError("no container command is configured on the server", http.StatusBadRequest)
+	}
+	containerName, err := appContainerName(application, service)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(conn io.ReadWriter) (string, error) {
+		detail := fmt.Sprintf("container %s command %q", containerName, strings.Join(command, " "))
+		s.Info().Str("container", containerName).Strs("command", command).Msg("Running exec command in app container")
+
+		// The command is stopped if the client connection is closed
+		execCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		mu := &sync.Mutex{}
+		streams, closeStdin := execStreams(conn, mu, stdin, cancel)
+
+		var exitCode int
+		var err error
+		if runtime == types.CONTAINER_KUBERNETES {
+			exitCode, err = container.ExecInWorkloadPod(execCtx, s.Config(), containerName, command, streams)
+		} else {
+			exitCode, err = container.ExecInContainer(execCtx, runtime, containerName, command, streams)
+		}
+		closeStdin()
+
+		result := types.AppExecResult{ExitCode: exitCode}
+		if err != nil {
+			result.Error = err.Error()
+			detail += ", error: " + err.Error()
+		} else {
+			detail += fmt.Sprintf(", exit code %d", exitCode)
+		}
+		data, _ := json.Marshal(result)
+		mu.Lock()
+		writeErr := system.WriteExecFrame(conn, system.ExecStreamExit, data)
+		mu.Unlock()
+		if err == nil {
+			err = writeErr
+		}
+		return detail, err
+	}, nil
+}
+
+// execStreams returns the command streams for the exec connection, the frame writes are done under
+// mu. The client frames are read in the background, cancel is called if the connection fails. The
+// returned func has to be called after the command is done, so that the stdin writes do not block
+func execStreams(conn io.ReadWriter, mu *sync.Mutex, stdin bool, cancel context.CancelFunc) (container.ExecStreams, func()) {
+	streams := container.ExecStreams{
+		Stdout: system.NewExecFrameWriter(mu, conn, system.ExecStreamStdout),
+		Stderr: system.NewExecFrameWriter(mu, conn, system.ExecStreamStderr),
+	}
+
+	var stdinReader *io.PipeReader
+	var stdinWriter *io.PipeWriter
+	if stdin {
+		stdinReader, stdinWriter = io.Pipe()
+		streams.Stdin = stdinReader
+	}
+	go func() {
+		for {
+			stream, data, err := system.ReadExecFrame(conn)
+			if err != nil {
+				if stdinWriter != nil {
+					stdinWriter.CloseWithError(err) //nolint:errcheck
+				}
+				cancel()
+				return
+			}
+			if stream != system.ExecStreamStdin || stdinWriter == nil {
+				continue
+			}
+			if len(data) == 0 {
+				// Close the stdin, the connection is still read for detecting the client closing
+				stdinWriter.Close() //nolint:errcheck
+				stdinWriter = nil
+				continue
+			}
+			if _, err := stdinWriter.Write(data); err != nil {
+				stdinWriter = nil
+			}
+		}
+	}()
+
+	return streams, func() {
+		if stdinReader != nil {
+			stdinReader.Close() //nolint:errcheck
+		}
+	}
+}
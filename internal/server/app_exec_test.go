@@ -0,0 +1,75 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestServeAPIUpgradeExecStreams(t *testing.T) {
+	details := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		detail, err := serveAPIUpgrade(w, system.EXEC_PROTOCOL, func(conn io.ReadWriter) (string, error) {
+			mu := &sync.Mutex{}
+			streams, closeStdin := execStreams(conn, mu, true, func() {})
+			// Echo the stdin to the stdout, like cat
+			if _, err := io.Copy(streams.Stdout, streams.Stdin); err != nil {
+				return "", err
+			}
+			closeStdin()
+			data, _ := json.Marshal(types.AppExecResult{ExitCode: 3})
+			mu.Lock()
+			defer mu.Unlock()
+			return "echo done", system.WriteExecFrame(conn, system.ExecStreamExit, data)
+		})
+		testutil.AssertNoError(t, err)
+		details <- detail
+	}))
+	defer srv.Close()
+
+	client := system.NewHttpClient(srv.URL, "", "", false)
+	conn, err := client.Upgrade("/exec", nil, system.EXEC_PROTOCOL)
+	testutil.AssertNoError(t, err)
+	defer conn.Close() //nolint:errcheck
+
+	testutil.AssertNoError(t, system.WriteExecFrame(conn, system.ExecStreamStdin, []byte("hello")))
+	testutil.AssertNoError(t, system.WriteExecFrame(conn, system.ExecStreamStdin, nil))
+
+	stream, data, err := system.ReadExecFrame(conn)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "stdout stream", int(system.ExecStreamStdout), int(stream))
+	testutil.AssertEqualsString(t, "stdout", "hello", string(data))
+
+	stream, data, err = system.ReadExecFrame(conn)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "exit stream", int(system.ExecStreamExit), int(stream))
+	var result types.AppExecResult
+	testutil.AssertNoError(t, json.Unmarshal(data, &result))
+	testutil.AssertEqualsInt(t, "exit code", 3, result.ExitCode)
+	testutil.AssertEqualsString(t, "detail", "echo done", <-details)
+}
+
+func TestExecStreamsCancel(t *testing.T) {
+	client, server := net.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	streams, closeStdin := execStreams(server, &sync.Mutex{}, false, cancel)
+	defer closeStdin()
+	if streams.Stdin != nil {
+		t.Fatal("stdin should not be set")
+	}
+	// The client closing the connection cancels the command
+	client.Close() //nolint:errcheck
+	<-ctx.Done()
+}
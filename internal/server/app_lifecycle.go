@@ -0,0 +1,443 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/rbac"
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// deprecationUser is the user id recorded for the audit events of the deprecation checks
+const deprecationUser = "deprecation"
+
+// bannerScanLimit is the max size of the response buffered looking for the body tag, larger
+// responses are passed through without the banner
+const bannerScanLimit = 64 * 1024
+
+// parseAppOwner validates the new owner for an app transfer. The owner is a user id or a
+// group:<name> team. A name which is a group in the RBAC config is taken to be a team
+func (s *Server) parseAppOwner(to string) (string, error) {
+	to = strings.TrimSpace(to)
+	if to == "" {
+		return "", fmt.Errorf("the new owner is required")
+	}
+	if strings.HasPrefix(to, rbac.RBAC_REGEX_PREFIX) {
+		return "", fmt.Errorf("the new owner cannot be a regex: %s", to)
+	}
+	if groupName, ok := strings.CutPrefix(to, rbac.RBAC_GROUP_PREFIX); ok {
+		if groupName == "" {
+			return "", fmt.Errorf("group name is required: %s", to)
+		}
+		return to, nil
+	}
+	if s.rbacManager.GroupExists(to) {
+		return rbac.RBAC_GROUP_PREFIX + to, nil
+	}
+	return to, nil
+}
+
+// TransferApps changes the owner of the matched apps, including their stage and preview apps.
+// The owner holds the owner permissions on the app. The quotas of the user getting the apps
+// are checked, quotas do not apply to a team owner
+func (s *Server) TransferApps(ctx context.Context, appPathGlob string, dryRun bool, to string) (*types.AppUpdateSettingsResponse, error) {
+	owner, err := s.parseAppOwner(to)
+	if err != nil {
+		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+	}
+
+	filteredApps, err := s.FilterApps(appPathGlob, false)
+	if err != nil {
+		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+	}
+	if err := s.enforceAppPermInfos(ctx, types.PermissionUpdate, filteredApps); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	results := make([]types.AppPathDomain, 0, len(filteredApps))
+	usesContainer := false
+	for _, appInfo := range filteredApps {
+		mainAppEntry, err := s.db.GetAppEntryTx(ctx, tx, appInfo.AppPathDomain)
+		if err != nil {
+			return nil, fmt.Errorf("error getting app %s: %w", appInfo, err)
+		}
+		linkedApps, err := s.db.GetLinkedApps(ctx, tx, mainAppEntry.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, appEntry := range append(linkedApps, mainAppEntry) {
+			appEntry.UserID = owner
+			if err := s.db.UpdateAppOwner(ctx, tx, appEntry); err != nil {
+				return nil, err
+			}
+			results = append(results, appEntry.AppPathDomain())
+		}
+		usesContainer = usesContainer || slices.Contains(mainAppEntry.Metadata.Loads, CONTAINER_PLUGIN)
+	}
+
+	if !strings.HasPrefix(owner, rbac.RBAC_GROUP_PREFIX) {
+		// The login groups of the new owner are not known, only the config groups are used
+		if err := s.checkQuotas(ctx, tx, owner, nil, usesContainer); err != nil {
+			return nil, err
+		}
+	}
+
+	ret := &types.AppUpdateSettingsResponse{
+		DryRun:        dryRun,
+		UpdateResults: results,
+	}
+	if dryRun {
+		return ret, nil
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	if err := s.apps.ClearAppsAudit(ctx, results, "transfer"); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// DeprecateApps marks the matched apps as deprecated, the users of the apps see a banner with the
+// message. If deleteAfter is set, the apps are deleted after that time, which requires the delete
+// permission on the apps. If undo is set, the deprecation is removed
+func (s *Server) DeprecateApps(ctx context.Context, appPathGlob string, dryRun bool, message string,
+	deleteAfter *time.Time, undo bool) (*types.AppUpdateSettingsResponse, error) {
+	if deleteAfter != nil && !deleteAfter.After(time.Now()) {
+		return nil, types.CreateRequestError("delete after time has to be in the future", http.StatusBadRequest)
+	}
+	if undo && (message != "" || deleteAfter != nil) {
+		return nil, types.CreateRequestError("message and delete after cannot be set when removing the deprecation", http.StatusBadRequest)
+	}
+
+	filteredApps, err := s.FilterApps(appPathGlob, false)
+	if err != nil {
+		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+	}
+	if err := s.enforceAppPermInfos(ctx, types.PermissionUpdate, filteredApps); err != nil {
+		return nil, err
+	}
+	if deleteAfter != nil {
+		if err := s.enforceAppPermInfos(ctx, types.PermissionDelete, filteredApps); err != nil {
+			return nil, err
+		}
+	}
+
+	var deprecation *types.Deprecation
+	if !undo {
+		now := time.Now()
+		deprecation = &types.Deprecation{
+			Message:      strings.TrimSpace(message),
+			DeprecatedBy: system.GetContextUserId(ctx),
+			DeprecatedAt: &now,
+			DeleteAfter:  deleteAfter,
+		}
+	}
+
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	results := make([]types.AppPathDomain, 0, len(filteredApps))
+	for _, appInfo := range filteredApps {
+		mainAppEntry, err := s.db.GetAppEntryTx(ctx, tx, appInfo.AppPathDomain)
+		if err != nil {
+			return nil, fmt.Errorf("error getting app %s: %w", appInfo, err)
+		}
+		linkedApps, err := s.db.GetLinkedApps(ctx, tx, mainAppEntry.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, appEntry := range append(linkedApps, mainAppEntry) {
+			appEntry.Settings.Deprecation = deprecation
+			if err := s.db.UpdateAppSettings(ctx, tx, appEntry); err != nil {
+				return nil, err
+			}
+			results = append(results, appEntry.AppPathDomain())
+		}
+	}
+
+	ret := &types.AppUpdateSettingsResponse{
+		DryRun:        dryRun,
+		UpdateResults: results,
+	}
+	if dryRun {
+		return ret, nil
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	if err := s.apps.ClearAppsAudit(ctx, results, "deprecate"); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// parseDeleteAfter parses the scheduled deletion time for a deprecated app, a date (in the
+// server time zone) or a RFC 3339 timestamp
+func parseDeleteAfter(value string) (time.Time, error) {
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid delete after value %q, expected a date like 2026-01-31 or a RFC 3339 timestamp", value)
+	}
+	return t, nil
+}
+
+// runDeprecationChecks notifies the owners of deprecated apps which are close to their scheduled
+// deletion and deletes the apps once the time has passed. It runs on the leader only, at most
+// once an hour
+func (s *Server) runDeprecationChecks(ctx context.Context, runner *jobRunner) {
+	now := time.Now()
+	if now.Sub(runner.lastDeprecationCheck) < time.Hour || !s.db.IsLeader() {
+		return
+	}
+	runner.lastDeprecationCheck = now
+
+	apps, err := s.db.GetAllApps(false)
+	if err != nil {
+		s.Error().Err(err).Msg("Error reading apps for deprecation check")
+		return
+	}
+	for _, appInfo := range apps {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := s.checkDeprecatedApp(ctx, appInfo, now); err != nil {
+			s.Error().Err(err).Str("app", appInfo.AppPathDomain.String()).Msg("Error checking deprecated app")
+		}
+	}
+}
+
+// checkDeprecatedApp sends the deletion notice for one app, or deletes the app if the scheduled
+// deletion time has passed. The notice is sent once, as an audit event for the app
+func (s *Server) checkDeprecatedApp(ctx context.Context, appInfo types.AppInfo, now time.Time) error {
+	appEntry, err := s.db.GetAppEntry(ctx, appInfo.AppPathDomain)
+	if err != nil {
+		return err
+	}
+	deprecation := appEntry.Settings.Deprecation
+	if deprecation == nil || deprecation.DeleteAfter == nil {
+		return nil
+	}
+
+	if !now.Before(*deprecation.DeleteAfter) {
+		s.Warn().Str("app", appEntry.String()).Str("owner", appEntry.UserID).Msg("Deleting deprecated app, scheduled deletion time has passed")
+		_, err := s.DeleteApps(newBackgroundOperationContext(deprecationUser), appEntry.String(), false)
+		if err != nil {
+			s.insertDeprecationAuditEvent(appEntry, "deprecation_delete", types.EventStatusFailure, err.Error())
+			return err
+		}
+		s.insertDeprecationAuditEvent(appEntry, "deprecation_delete", types.EventStatusSuccess,
+			fmt.Sprintf("deleted app owned by %s, deprecated by %s", appEntry.UserID, deprecation.DeprecatedBy))
+		return nil
+	}
+
+	noticeDays := s.Config().System.DeprecationNoticeDays
+	if deprecation.NoticeSent || noticeDays <= 0 || now.Before(deprecation.DeleteAfter.AddDate(0, 0, -noticeDays)) {
+		return nil
+	}
+
+	detail := fmt.Sprintf("app owned by %s is scheduled for deletion at %s: %s", appEntry.UserID,
+		deprecation.DeleteAfter.Format(time.RFC3339), cmp.Or(deprecation.Message, "app is deprecated"))
+	s.Warn().Str("app", appEntry.String()).Str("owner", appEntry.UserID).Msg("Deprecated app " + detail)
+	s.insertDeprecationAuditEvent(appEntry, "deprecation_notice", types.EventStatusSuccess, detail)
+
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	deprecation.NoticeSent = true
+	if err := s.db.UpdateAppSettings(ctx, tx, appEntry); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Server) insertDeprecationAuditEvent(appEntry *types.AppEntry, op string, status types.EventStatus, detail string) {
+	event := types.AuditEvent{
+		RequestId:  system.GetContextRequestId(newBackgroundOperationContext(deprecationUser)),
+		AppId:      appEntry.Id,
+		CreateTime: time.Now(),
+		UserId:     deprecationUser,
+		EventType:  types.EventTypeSystem,
+		Operation:  op,
+		Target:     appEntry.String(),
+		Status:     string(status),
+		Detail:     detail,
+	}
+	if err := s.InsertAuditEvent(&event); err != nil {
+		s.Error().Err(err).Str("app", appEntry.String()).Msg("Error inserting deprecation audit event")
+	}
+}
+
+// deprecationBanner returns the HTML banner shown on the pages of a deprecated app
+func deprecationBanner(deprecation *types.Deprecation) string {
+	message := cmp.Or(deprecation.Message, "This app is deprecated")
+	if deprecation.DeleteAfter != nil {
+		message = fmt.Sprintf("%s. The app is scheduled to be removed on %s.", strings.TrimRight(message, "."),
+			deprecation.DeleteAfter.Format(time.DateOnly))
+	}
+	return `<div id="openrun_deprecation_banner" role="alert" style="padding:0.5rem 1rem;background:#fef3c7;` +
+		`color:#78350f;border-bottom:1px solid #f59e0b;font-family:sans-serif;text-align:center">` +
+		html.EscapeString(message) + `</div>`
+}
+
+// deprecationMiddleware adds the Deprecation (RFC 9745) and Sunset (RFC 8594) headers to the
+// responses of a deprecated app and inserts the deprecation banner in the HTML pages. HTMX
+// requests update part of a page, they do not get the banner. Conditional requests are not
+// passed to the app, so that pages cached before the app was deprecated are not reused
+func deprecationMiddleware(next http.Handler, deprecation *types.Deprecation) http.Handler {
+	banner := deprecationBanner(deprecation)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if deprecation.DeprecatedAt != nil {
+			w.Header().Set("Deprecation", fmt.Sprintf("@%d", deprecation.DeprecatedAt.Unix()))
+		}
+		if deprecation.DeleteAfter != nil {
+			w.Header().Set("Sunset", deprecation.DeleteAfter.UTC().Format(http.TimeFormat))
+		}
+		if r.Method != http.MethodGet || r.Header.Get("HX-Request") == "true" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		r.Header.Del("If-None-Match")
+		r.Header.Del("If-Modified-Since")
+		bw := &bannerResponseWriter{ResponseWriter: w, banner: banner}
+		next.ServeHTTP(bw, r)
+		bw.flushBuffer() //nolint:errcheck
+	})
+}
+
+// bannerResponseWriter inserts a banner after the body tag of HTML pages. An HTML response is
+// buffered until the body tag is seen, up to bannerScanLimit bytes. Other responses are passed
+// through unchanged
+type bannerResponseWriter struct {
+	http.ResponseWriter
+	banner      string
+	wroteHeader bool
+	inject      bool // the response is an HTML page and the body tag has not been seen yet
+	buf         bytes.Buffer
+}
+
+func (b *bannerResponseWriter) WriteHeader(statusCode int) {
+	if b.wroteHeader {
+		return
+	}
+	b.wroteHeader = true
+	header := b.Header()
+	b.inject = statusCode == http.StatusOK && header.Get("Content-Encoding") == "" &&
+		strings.HasPrefix(header.Get("Content-Type"), "text/html")
+	if b.inject {
+		header.Del("Content-Length")
+		header.Del("ETag")
+		header.Del("Last-Modified")
+	}
+	b.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (b *bannerResponseWriter) Write(data []byte) (int, error) {
+	if !b.wroteHeader {
+		if b.Header().Get("Content-Type") == "" {
+			b.Header().Set("Content-Type", http.DetectContentType(data))
+		}
+		b.WriteHeader(http.StatusOK)
+	}
+	if !b.inject {
+		return b.ResponseWriter.Write(data)
+	}
+
+	b.buf.Write(data)
+	if index := bodyTagEnd(b.buf.Bytes()); index >= 0 {
+		buffered := b.buf.Bytes()
+		b.inject = false
+		b.buf.Reset()
+		if _, err := b.ResponseWriter.Write(buffered[:index]); err != nil {
+			return 0, err
+		}
+		if _, err := b.ResponseWriter.Write([]byte(b.banner)); err != nil {
+			return 0, err
+		}
+		if _, err := b.ResponseWriter.Write(buffered[index:]); err != nil {
+			return 0, err
+		}
+	} else if b.buf.Len() > bannerScanLimit {
+		if err := b.flushBuffer(); err != nil {
+			return 0, err
+		}
+	}
+	return len(data), nil
+}
+
+// flushBuffer writes out the buffered data without the banner, called when the body tag is not
+// found or when the response is flushed
+func (b *bannerResponseWriter) flushBuffer() error {
+	b.inject = false
+	if b.buf.Len() == 0 {
+		return nil
+	}
+	_, err := b.ResponseWriter.Write(b.buf.Bytes())
+	b.buf.Reset()
+	return err
+}
+
+func (b *bannerResponseWriter) Flush() {
+	if err := b.flushBuffer(); err != nil {
+		return
+	}
+	if flusher, ok := b.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (b *bannerResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := b.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("response writer does not support hijacking")
+}
+
+func (b *bannerResponseWriter) Unwrap() http.ResponseWriter {
+	return b.ResponseWriter
+}
+
+// bodyTagEnd returns the index after the end of the body start tag, -1 if not found
+func bodyTagEnd(data []byte) int {
+	start := bytes.Index(bytes.ToLower(data), []byte("<body"))
+	if start < 0 {
+		return -1
+	}
+	end := bytes.IndexByte(data[start:], '>')
+	if end < 0 {
+		return -1
+	}
+	return start + end + 1
+}
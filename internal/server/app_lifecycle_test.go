@@ -0,0 +1,103 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestBodyTagEnd(t *testing.T) {
+	tests := map[string]int{
+		"<html><body>x":               12,
+		`<html><BODY class="a">x`:     22,
+		"<html><head></head>":         -1,
+		"<html><body":                 -1,
+		"<div>partial response</div>": -1,
+	}
+	for input, expected := range tests {
+		testutil.AssertEqualsInt(t, input, expected, bodyTagEnd([]byte(input)))
+	}
+}
+
+func TestParseDeleteAfter(t *testing.T) {
+	date, err := parseDeleteAfter("2030-01-31")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "date", "2030-01-31", date.Format(time.DateOnly))
+
+	ts, err := parseDeleteAfter("2030-01-31T10:00:00Z")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "hour", 10, ts.UTC().Hour())
+
+	_, err = parseDeleteAfter("next week")
+	testutil.AssertErrorContains(t, err, "invalid delete after value")
+}
+
+func TestDeprecationMiddleware(t *testing.T) {
+	deprecatedAt := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	deleteAfter := time.Date(2030, 2, 1, 0, 0, 0, 0, time.UTC)
+	deprecation := &types.Deprecation{Message: "Use /new <instead>", DeprecatedAt: &deprecatedAt, DeleteAfter: &deleteAfter}
+
+	var ifNoneMatch string
+	handler := deprecationMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifNoneMatch = r.Header.Get("If-None-Match")
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("ETag", `W/"abc"`)
+			io.WriteString(w, "<html><head></head>")             //nolint:errcheck
+			io.WriteString(w, "<body><p>page</p></body></html>") //nolint:errcheck
+		case "/sniffed":
+			io.WriteString(w, "<!DOCTYPE html><html><body>sniffed</body></html>") //nolint:errcheck
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"body": "<body>"}`) //nolint:errcheck
+		case "/partial":
+			w.Header().Set("Content-Type", "text/html")
+			io.WriteString(w, "<div>partial</div>") //nolint:errcheck
+		}
+	}), deprecation)
+
+	call := func(path string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	banner := "Use /new &lt;instead&gt;. The app is scheduled to be removed on 2030-02-01."
+	rec := call("/page", map[string]string{"If-None-Match": `W/"abc"`})
+	testutil.AssertEqualsString(t, "if-none-match", "", ifNoneMatch)
+	testutil.AssertEqualsString(t, "etag", "", rec.Header().Get("ETag"))
+	testutil.AssertEqualsString(t, "deprecation", "@1893456000", rec.Header().Get("Deprecation"))
+	testutil.AssertEqualsString(t, "sunset", "Fri, 01 Feb 2030 00:00:00 GMT", rec.Header().Get("Sunset"))
+	testutil.AssertStringContains(t, rec.Body.String(), "<body><div id=\"openrun_deprecation_banner\"")
+	testutil.AssertStringContains(t, rec.Body.String(), banner)
+	testutil.AssertStringContains(t, rec.Body.String(), "<p>page</p></body></html>")
+
+	rec = call("/sniffed", nil)
+	testutil.AssertStringContains(t, rec.Body.String(), banner)
+
+	rec = call("/json", nil)
+	testutil.AssertEqualsString(t, "json", `{"body": "<body>"}`, rec.Body.String())
+
+	rec = call("/partial", nil)
+	testutil.AssertEqualsString(t, "partial", "<div>partial</div>", rec.Body.String())
+
+	rec = call("/page", map[string]string{"HX-Request": "true"})
+	if strings.Contains(rec.Body.String(), "openrun_deprecation_banner") {
+		t.Errorf("htmx request should not get the banner")
+	}
+	testutil.AssertEqualsString(t, "htmx deprecation header", "@1893456000", rec.Header().Get("Deprecation"))
+}
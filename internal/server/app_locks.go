@@ -0,0 +1,201 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"cmp"
+	"context"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/openrundev/openrun/internal/rbac"
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/segmentio/ksuid"
+)
+
+const (
+	// A lock not renewed for this duration, like after a server crash, can be taken over
+	appLockLease         = 2 * time.Minute
+	appLockRenewInterval = 30 * time.Second
+)
+
+// appLockHolderId returns the lock holder id for the operation. The request id is used so that
+// nested operations in a request, like the reloads done by an apply, reuse the outer locks
+func appLockHolderId(ctx context.Context) string {
+	requestId := system.GetContextRequestId(ctx)
+	if requestId == "" {
+		requestId = ksuid.New().String()
+	}
+	return string(types.CurrentServerId) + ":" + requestId
+}
+
+// lockAppInfos is lockApps for the apps returned by FilterApps
+func (s *Server) lockAppInfos(ctx context.Context, operation string, apps []types.AppInfo) (func(), error) {
+	appPaths := make([]types.AppPathDomain, 0, len(apps))
+	for _, appInfo := range apps {
+		appPaths = append(appPaths, appInfo.AppPathDomain)
+	}
+	return s.lockApps(ctx, operation, appPaths)
+}
+
+// lockApps takes the app locks for an operation which updates the apps, so that operations on the
+// same app from concurrent API calls and sync runs, on this server or others, do not interleave.
+// The locks on the main app path cover the linked stage and preview apps. If any app is locked by
+// another operation, no lock is taken and a retriable conflict error with the lock holder info is
+// returned. The locks are renewed till the returned unlock function is called
+func (s *Server) lockApps(ctx context.Context, operation string, appPaths []types.AppPathDomain) (func(), error) {
+	holderId := appLockHolderId(ctx)
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = string(types.CurrentServerId)
+	}
+	paths := make([]string, 0, len(appPaths))
+	for _, appPath := range appPaths {
+		paths = append(paths, appPath.String())
+	}
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+
+	acquired := make([]string, 0, len(paths))
+	newLocks := false
+	release := func() {
+		for _, path := range acquired {
+			key := holderId + "\x00" + path
+			s.heldAppLocksMu.Lock()
+			s.heldAppLocks[key]--
+			remaining := s.heldAppLocks[key]
+			if remaining <= 0 {
+				delete(s.heldAppLocks, key)
+			}
+			s.heldAppLocksMu.Unlock()
+			if remaining > 0 {
+				continue // still held by the outer operation
+			}
+			if _, err := s.db.ReleaseAppLock(context.Background(), path, holderId); err != nil {
+				s.Error().Err(err).Msgf("error releasing lock for app %s", path)
+			}
+		}
+	}
+
+	for _, path := range paths {
+		key := holderId + "\x00" + path
+		s.heldAppLocksMu.Lock()
+		if s.heldAppLocks[key] > 0 {
+			s.heldAppLocks[key]++
+			s.heldAppLocksMu.Unlock()
+			acquired = append(acquired, path)
+			continue
+		}
+		s.heldAppLocksMu.Unlock()
+
+		now := time.Now()
+		holder, err := s.db.AcquireAppLock(ctx, &types.AppLock{
+			Path:        path,
+			HolderId:    holderId,
+			Hostname:    hostname,
+			Operation:   operation,
+			UserId:      cmp.Or(system.GetContextUserId(ctx), types.ADMIN_USER),
+			AcquireTime: now,
+			ExpireTime:  now.Add(appLockLease),
+		})
+		if err != nil {
+			release()
+			return nil, err
+		}
+		if holder != nil {
+			release()
+			return nil, types.CreateAppLockedError(holder)
+		}
+
+		s.heldAppLocksMu.Lock()
+		if s.heldAppLocks == nil {
+			s.heldAppLocks = make(map[string]int)
+		}
+		s.heldAppLocks[key]++
+		s.heldAppLocksMu.Unlock()
+		acquired = append(acquired, path)
+		newLocks = true
+	}
+
+	stop := make(chan struct{})
+	if newLocks {
+		go func() {
+			ticker := time.NewTicker(appLockRenewInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-stop:
+					return
+				case <-ticker.C:
+					if err := s.db.RenewAppLocks(context.Background(), holderId, time.Now().Add(appLockLease)); err != nil {
+						s.Error().Err(err).Msgf("error renewing app locks for %s", holderId)
+					}
+				}
+			}
+		}()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(stop)
+			release()
+		})
+	}, nil
+}
+
+// ListAppLocks returns the app locks currently held
+func (s *Server) ListAppLocks(ctx context.Context) (*types.AppLockListResponse, error) {
+	if err := s.enforceGlobalPerm(ctx, types.PermissionAuditRead, ""); err != nil {
+		return nil, err
+	}
+	locks, err := s.db.ListAppLocks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &types.AppLockListResponse{Locks: locks}, nil
+}
+
+// UnlockApps force removes the locks on the apps matching the glob, for locks left over from an
+// operation which is stuck. The operation holding the lock is not stopped
+func (s *Server) UnlockApps(ctx context.Context, appPathGlob string, dryRun bool) (*types.AppUnlockResponse, error) {
+	if err := s.enforceGlobalPerm(ctx, types.PermissionAdmin, ""); err != nil {
+		return nil, err
+	}
+	locks, err := s.db.ListAppLocks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &types.AppUnlockResponse{DryRun: dryRun, Unlocked: []types.AppLock{}}
+	for _, lock := range locks {
+		appPath, err := parseAppPath(lock.Path)
+		if err != nil {
+			return nil, err
+		}
+		match, err := rbac.MatchGlob(appPathGlob, appPath)
+		if err != nil {
+			return nil, err
+		}
+		if !match {
+			continue
+		}
+		if !dryRun {
+			released, err := s.db.ReleaseAppLock(ctx, lock.Path, "")
+			if err != nil {
+				return nil, err
+			}
+			if released == nil {
+				continue // released by the holder meanwhile
+			}
+			s.Warn().Str("app", lock.Path).Str("holder", released.HolderId).Str("operation", released.Operation).
+				Msg("Force removed app lock")
+		}
+		ret.Unlocked = append(ret.Unlocked, lock)
+	}
+	return ret, nil
+}
@@ -0,0 +1,58 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestLockApps(t *testing.T) {
+	server, db, _ := newApplyTestServer(t)
+	defer db.Close()
+	app1 := types.AppPathDomain{Path: "/app1"}
+	app2 := types.AppPathDomain{Path: "/app2"}
+
+	ctx := newBackgroundOperationContext("alice")
+	unlock, err := server.lockApps(ctx, "apply", []types.AppPathDomain{app1, app2})
+	testutil.AssertNoError(t, err)
+
+	// A nested operation in the same request reuses the locks
+	nestedUnlock, err := server.lockApps(ctx, "reload_apps", []types.AppPathDomain{app1})
+	testutil.AssertNoError(t, err)
+	nestedUnlock()
+
+	// Another request gets the lock holder info, and no lock is left behind
+	otherCtx := newBackgroundOperationContext("bob")
+	_, err = server.lockApps(otherCtx, "delete_apps", []types.AppPathDomain{{Path: "/app0"}, app2})
+	testutil.AssertErrorContains(t, err, "app /app2 is locked by operation apply for user alice")
+	if !types.IsRetriableConflict(err) {
+		t.Fatalf("expected retriable conflict, got %v", err)
+	}
+	locks, err := server.ListAppLocks(ctx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "locks", 2, len(locks.Locks))
+
+	unlock()
+	unlock() // repeated unlock is a no-op
+	otherUnlock, err := server.lockApps(otherCtx, "delete_apps", []types.AppPathDomain{app2})
+	testutil.AssertNoError(t, err)
+
+	// Force unlock removes the lock held by the other request
+	unlocked, err := server.UnlockApps(ctx, "/app*", true)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "dry run unlocked", 1, len(unlocked.Unlocked))
+	unlocked, err = server.UnlockApps(ctx, "/app*", false)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "unlocked", 1, len(unlocked.Unlocked))
+	testutil.AssertEqualsString(t, "unlocked holder", "bob", unlocked.Unlocked[0].UserId)
+	_, err = server.lockApps(ctx, "apply", []types.AppPathDomain{app2})
+	testutil.AssertNoError(t, err)
+	otherUnlock() // does not release the lock now held by the first request
+	locks, err = server.ListAppLocks(ctx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "locks", 1, len(locks.Locks))
+}
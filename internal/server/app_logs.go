@@ -0,0 +1,423 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// appLogBufferLines is the number of recent log lines kept in memory for each app
+	appLogBufferLines = 1000
+	// defaultAppLogLines is the number of lines returned by default before following
+	defaultAppLogLines = 100
+)
+
+// appLogBuffer is a ring buffer with the recent log entries for an app, with the channels of
+// the followers
+type appLogBuffer struct {
+	entries   []types.AppLogEntry
+	next      int
+	followers map[chan types.AppLogEntry]struct{}
+}
+
+// appLogStore keeps the recent logs for each app in memory, for the app logs API. It is added
+// as a writer to the server logger, the log lines which have an app id are recorded for the
+// app. The print output from the app handlers is added directly
+type appLogStore struct {
+	mu   sync.Mutex
+	apps map[types.AppId]*appLogBuffer
+}
+
+func newAppLogStore() *appLogStore {
+	return &appLogStore{apps: map[types.AppId]*appLogBuffer{}}
+}
+
+// appIdMarker is checked before parsing a log line, most server log lines are not for an app
+var appIdMarker = []byte(`"id":"app_`)
+
+// Write implements io.Writer for the JSON log lines. Errors are not returned, the other log
+// writers should not be affected by a line which could not be parsed
+func (s *appLogStore) Write(p []byte) (int, error) {
+	if !bytes.Contains(p, appIdMarker) {
+		return len(p), nil
+	}
+	var line struct {
+		Id      string    `json:"id"`
+		Level   string    `json:"level"`
+		Time    time.Time `json:"time"`
+		Message string    `json:"message"`
+		Error   string    `json:"error"`
+	}
+	if err := json.Unmarshal(p, &line); err != nil || !strings.HasPrefix(line.Id, "app_") {
+		return len(p), nil
+	}
+
+	entry := types.AppLogEntry{
+		Time:    line.Time,
+		Source:  types.AppLogSourceHandler,
+		Level:   line.Level,
+		Message: line.Message,
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	if line.Error != "" {
+		entry.Message += ": " + line.Error
+	}
+	if level, err := zerolog.ParseLevel(line.Level); err == nil && level >= zerolog.WarnLevel {
+		entry.Source = types.AppLogSourceError
+	}
+	s.add(types.AppId(line.Id), entry)
+	return len(p), nil
+}
+
+// add records the entry for the app and sends it to the followers. A follower which is not
+// keeping up misses the entry, logging is never blocked by a slow client
+func (s *appLogStore) add(appId types.AppId, entry types.AppLogEntry) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buf := s.apps[appId]
+	if buf == nil {
+		buf = &appLogBuffer{followers: map[chan types.AppLogEntry]struct{}{}}
+		s.apps[appId] = buf
+	}
+	if len(buf.entries) < appLogBufferLines {
+		buf.entries = append(buf.entries, entry)
+	} else {
+		buf.entries[buf.next] = entry
+	}
+	buf.next = (buf.next + 1) % appLogBufferLines
+
+	for ch := range buf.followers {
+		select {
+		case ch <- entry:
+		default:
+		}
+	}
+}
+
+// entries returns the recorded entries for the app at or after since, oldest first
+func (s *appLogStore) entries(appId types.AppId, since time.Time) []types.AppLogEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buf := s.apps[appId]
+	if buf == nil {
+		return nil
+	}
+	ordered := buf.entries
+	if len(buf.entries) == appLogBufferLines {
+		ordered = append(slices.Clone(buf.entries[buf.next:]), buf.entries[:buf.next]...)
+	}
+	ret := make([]types.AppLogEntry, 0, len(ordered))
+	for _, entry := range ordered {
+		if !entry.Time.Before(since) {
+			ret = append(ret, entry)
+		}
+	}
+	return ret
+}
+
+// follow returns a channel which gets the new entries for the app, and the function to call
+// to stop following
+func (s *appLogStore) follow(appId types.AppId) (<-chan types.AppLogEntry, func()) {
+	ch := make(chan types.AppLogEntry, 256)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	buf := s.apps[appId]
+	if buf == nil {
+		buf = &appLogBuffer{followers: map[chan types.AppLogEntry]struct{}{}}
+		s.apps[appId] = buf
+	}
+	buf.followers[ch] = struct{}{}
+	return ch, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(buf.followers, ch)
+	}
+}
+
+// remove drops the recorded entries for a deleted app
+func (s *appLogStore) remove(appId types.AppId) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.apps, appId)
+}
+
+// parseLogSince parses the since option, either a duration like 10m which is relative to now
+// or a RFC3339 timestamp
+func parseLogSince(since string, now time.Time) (time.Time, error) {
+	if since == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(since); err == nil {
+		if d < 0 {
+			return time.Time{}, fmt.Errorf("since duration %s cannot be negative", since)
+		}
+		return now.Add(-d), nil
+	}
+	t, err := time.Parse(time.RFC3339, since)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid since value %s, expected a duration like 10m or a RFC3339 timestamp", since)
+	}
+	return t, nil
+}
+
+// parseContainerLogLine parses a line from the container logs command run with --timestamps,
+// the line starts with a RFC3339 timestamp. The current time is used if the timestamp is missing
+func parseContainerLogLine(line string) types.AppLogEntry {
+	entry := types.AppLogEntry{Source: types.AppLogSourceContainer, Message: line}
+	if ts, msg, ok := strings.Cut(line, " "); ok {
+		if t, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+			entry.Time = t
+			entry.Message = msg
+		}
+	}
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	return entry
+}
+
+// mergeAppLogs merges the app and container log entries in time order, filters by the grep
+// regex and returns the last lines entries
+func mergeAppLogs(appEntries, containerEntries []types.AppLogEntry, grep *regexp.Regexp, lines int) []types.AppLogEntry {
+	merged := make([]types.AppLogEntry, 0, len(appEntries)+len(containerEntries))
+	for _, entries := range [][]types.AppLogEntry{appEntries, containerEntries} {
+		for _, entry := range entries {
+			if grep == nil || grep.MatchString(entry.Message) {
+				merged = append(merged, entry)
+			}
+		}
+	}
+	slices.SortStableFunc(merged, func(a, b types.AppLogEntry) int {
+		return a.Time.Compare(b.Time)
+	})
+	if lines > 0 && len(merged) > lines {
+		merged = merged[len(merged)-lines:]
+	}
+	return merged
+}
+
+// containerLogsCmd returns the command to read the app container logs, nil if the app has no
+// running container. Container logs are read for the command container managers (docker and
+// podman), the Kubernetes pod logs are not included
+func (s *Server) containerLogsCmd(ctx context.Context, containerName string, tail int, since time.Time, follow bool) *exec.Cmd {
+	runtime := s.containerRuntime()
+	if containerName == "" || runtime == "" || runtime == types.CONTAINER_KUBERNETES {
+		return nil
+	}
+	args := []string{"logs", "--timestamps", "--tail", strconv.Itoa(tail)}
+	if !since.IsZero() {
+		args = append(args, "--since", since.Format(time.RFC3339Nano))
+	}
+	if follow {
+		args = append(args, "--follow")
+	}
+	args = append(args, containerName)
+	return exec.CommandContext(ctx, runtime, args...)
+}
+
+// readContainerLogs runs the container logs command, sending the parsed lines to the channel
+// until the output ends or ctx is canceled. The channel is closed when done
+func readContainerLogs(ctx context.Context, cmd *exec.Cmd, out chan<- types.AppLogEntry) error {
+	defer close(out)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	// The runtime CLI emits the container's stderr on its own stderr, both are read
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	return scanContainerLogs(ctx, stdout, out)
+}
+
+func scanContainerLogs(ctx context.Context, reader io.Reader, out chan<- types.AppLogEntry) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), maxLogChunkBytes)
+	for scanner.Scan() {
+		select {
+		case out <- parseContainerLogLine(scanner.Text()):
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// AppLogs returns the merged stream of the app logs, the handler print output and the container
+// logs for the app, in time order. The last lines entries at or after since are returned first.
+// With follow, new entries are streamed until ctx is canceled
+func (s *Server) AppLogs(ctx context.Context, appPath string, follow bool, since, grep string, lines int) (apiStream, error) {
+	appPathDomain, err := parseAppPath(appPath)
+	if err != nil {
+		return nil, err
+	}
+	application, err := s.GetApp(ctx, appPathDomain, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.enforceAppPermEntry(ctx, types.PermissionRead, application.AppEntry); err != nil {
+		return nil, err
+	}
+
+	sinceTime, err := parseLogSince(since, time.Now())
+	if err != nil {
+		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+	}
+	var grepRegex *regexp.Regexp
+	if grep != "" {
+		if grepRegex, err = regexp.Compile(grep); err != nil {
+			return nil, types.CreateRequestError(fmt.Sprintf("invalid grep regex: %s", err), http.StatusBadRequest)
+		}
+	}
+	if lines <= 0 {
+		lines = defaultAppLogLines
+	}
+
+	containerName := ""
+	if name, ok := application.ActiveContainerName(); ok {
+		containerName = string(name)
+	}
+	appId := application.Id
+
+	return func(yield func(any) bool) {
+		// Follow before reading the recorded entries, so that no entry is missed in between
+		var appCh <-chan types.AppLogEntry
+		if follow {
+			ch, stop := s.appLogs.follow(appId)
+			defer stop()
+			appCh = ch
+		}
+
+		var containerEntries []types.AppLogEntry
+		if cmd := s.containerLogsCmd(ctx, containerName, lines, sinceTime, false); cmd != nil {
+			ch := make(chan types.AppLogEntry, 256)
+			go func() {
+				if err := readContainerLogs(ctx, cmd, ch); err != nil {
+					s.Debug().Err(err).Str("container", containerName).Msg("error reading container logs")
+				}
+			}()
+			for entry := range ch {
+				containerEntries = append(containerEntries, entry)
+			}
+		}
+
+		for _, entry := range mergeAppLogs(s.appLogs.entries(appId, sinceTime), containerEntries, grepRegex, lines) {
+			if !yield(entry) {
+				return
+			}
+		}
+		if !follow {
+			return
+		}
+
+		var containerCh chan types.AppLogEntry
+		if cmd := s.containerLogsCmd(ctx, containerName, 0, time.Time{}, true); cmd != nil {
+			containerCh = make(chan types.AppLogEntry, 256)
+			go func() {
+				if err := readContainerLogs(ctx, cmd, containerCh); err != nil {
+					s.Debug().Err(err).Str("container", containerName).Msg("error following container logs")
+				}
+			}()
+		}
+
+		for {
+			var entry types.AppLogEntry
+			var ok bool
+			select {
+			case <-ctx.Done():
+				return
+			case entry = <-appCh:
+			case entry, ok = <-containerCh:
+				if !ok {
+					// Container stopped, keep following the app logs
+					containerCh = nil
+					continue
+				}
+			}
+			if grepRegex != nil && !grepRegex.MatchString(entry.Message) {
+				continue
+			}
+			if !yield(entry) {
+				return
+			}
+		}
+	}, nil
+}
+
+// apiStream is returned by the API funcs which stream the response as newline delimited JSON,
+// or as server-sent events if the client accepts text/event-stream. Each value is written and
+// flushed as it is yielded, until the stream ends or the client disconnects. The stream funcs
+// should use the request context for blocking calls, so that a disconnect is detected promptly
+type apiStream func(yield func(any) bool)
+
+// writeAPIStream writes the stream response. The server write timeout is cleared, a followed
+// stream stays open until the client disconnects
+func writeAPIStream(w http.ResponseWriter, r *http.Request, stream apiStream) {
+	sse := strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+	rc := http.NewResponseController(w)
+	_ = rc.SetWriteDeadline(time.Time{})
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+	}
+	w.WriteHeader(http.StatusOK)
+	_ = rc.Flush()
+
+	enc := json.NewEncoder(w)
+	ctx := r.Context()
+	stream(func(value any) bool {
+		if ctx.Err() != nil {
+			// Client disconnected, stop the stream
+			return false
+		}
+		if sse {
+			if _, err := io.WriteString(w, "data: "); err != nil {
+				return false
+			}
+		}
+		// Encode adds the newline, which ends the ndjson line or the SSE data line
+		if err := enc.Encode(value); err != nil {
+			return false
+		}
+		if sse {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return false
+			}
+		}
+		return rc.Flush() == nil
+	})
+}
@@ -0,0 +1,211 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestAppLogStoreWrite(t *testing.T) {
+	store := newAppLogStore()
+	lines := []string{
+		`{"level":"info","id":"app_prd_1","time":"2026-01-02T10:00:00Z","message":"request done"}`,
+		`{"level":"error","id":"app_prd_1","time":"2026-01-02T10:00:01Z","error":"dial failed","message":"upstream error"}`,
+		`{"level":"info","id":"app_prd_2","time":"2026-01-02T10:00:02Z","message":"other app"}`,
+		`{"level":"info","time":"2026-01-02T10:00:03Z","message":"server line"}`,
+		`not json "id":"app_prd_1"`,
+	}
+	for _, line := range lines {
+		n, err := store.Write([]byte(line + "\n"))
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqualsInt(t, "written", len(line)+1, n)
+	}
+
+	entries := store.entries("app_prd_1", time.Time{})
+	testutil.AssertEqualsInt(t, "entries", 2, len(entries))
+	testutil.AssertEqualsString(t, "source", types.AppLogSourceHandler, entries[0].Source)
+	testutil.AssertEqualsString(t, "message", "request done", entries[0].Message)
+	testutil.AssertEqualsString(t, "error source", types.AppLogSourceError, entries[1].Source)
+	testutil.AssertEqualsString(t, "error message", "upstream error: dial failed", entries[1].Message)
+
+	since := time.Date(2026, 1, 2, 10, 0, 1, 0, time.UTC)
+	testutil.AssertEqualsInt(t, "since", 1, len(store.entries("app_prd_1", since)))
+
+	store.remove("app_prd_1")
+	testutil.AssertEqualsInt(t, "removed", 0, len(store.entries("app_prd_1", time.Time{})))
+	testutil.AssertEqualsInt(t, "other app", 1, len(store.entries("app_prd_2", time.Time{})))
+}
+
+func TestAppLogStoreRing(t *testing.T) {
+	store := newAppLogStore()
+	start := time.Now()
+	for i := range appLogBufferLines + 10 {
+		store.add("app_prd_1", types.AppLogEntry{Time: start.Add(time.Duration(i) * time.Millisecond), Message: fmt.Sprint(i)})
+	}
+	entries := store.entries("app_prd_1", time.Time{})
+	testutil.AssertEqualsInt(t, "entries", appLogBufferLines, len(entries))
+	testutil.AssertEqualsString(t, "oldest", "10", entries[0].Message)
+	testutil.AssertEqualsString(t, "newest", fmt.Sprint(appLogBufferLines+9), entries[len(entries)-1].Message)
+}
+
+func TestAppLogStoreFollow(t *testing.T) {
+	store := newAppLogStore()
+	ch, stop := store.follow("app_prd_1")
+	store.add("app_prd_1", types.AppLogEntry{Time: time.Now(), Message: "one"})
+	store.add("app_prd_2", types.AppLogEntry{Time: time.Now(), Message: "other"})
+	select {
+	case entry := <-ch:
+		testutil.AssertEqualsString(t, "followed", "one", entry.Message)
+	case <-time.After(time.Second):
+		t.Fatal("entry not received")
+	}
+
+	stop()
+	store.add("app_prd_1", types.AppLogEntry{Time: time.Now(), Message: "two"})
+	select {
+	case entry := <-ch:
+		t.Fatalf("unexpected entry after stop: %s", entry.Message)
+	default:
+	}
+
+	// A nil store, when the server is created without the log store, is a no-op
+	var nilStore *appLogStore
+	nilStore.add("app_prd_1", types.AppLogEntry{Message: "ignored"})
+	nilStore.remove("app_prd_1")
+}
+
+func TestParseLogSince(t *testing.T) {
+	now := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	since, err := parseLogSince("", now)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsBool(t, "empty", true, since.IsZero())
+
+	since, err = parseLogSince("10m", now)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "duration", "2026-01-02T09:50:00Z", since.Format(time.RFC3339))
+
+	since, err = parseLogSince("2026-01-01T08:00:00Z", now)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "timestamp", "2026-01-01T08:00:00Z", since.Format(time.RFC3339))
+
+	_, err = parseLogSince("-5m", now)
+	testutil.AssertErrorContains(t, err, "cannot be negative")
+	_, err = parseLogSince("yesterday", now)
+	testutil.AssertErrorContains(t, err, "invalid since value")
+}
+
+func TestParseContainerLogLine(t *testing.T) {
+	entry := parseContainerLogLine("2026-01-02T10:00:00.123456789Z Listening on port 5000")
+	testutil.AssertEqualsString(t, "source", types.AppLogSourceContainer, entry.Source)
+	testutil.AssertEqualsString(t, "message", "Listening on port 5000", entry.Message)
+	testutil.AssertEqualsInt(t, "nanos", 123456789, entry.Time.Nanosecond())
+
+	entry = parseContainerLogLine("no timestamp here")
+	testutil.AssertEqualsString(t, "message", "no timestamp here", entry.Message)
+	testutil.AssertEqualsBool(t, "time set", false, entry.Time.IsZero())
+}
+
+func TestScanContainerLogs(t *testing.T) {
+	out := make(chan types.AppLogEntry, 10)
+	input := "2026-01-02T10:00:00Z first\n2026-01-02T10:00:01Z second\n"
+	err := scanContainerLogs(context.Background(), strings.NewReader(input), out)
+	testutil.AssertNoError(t, err)
+	close(out)
+	messages := []string{}
+	for entry := range out {
+		messages = append(messages, entry.Message)
+	}
+	testutil.AssertEqualsString(t, "messages", "first,second", strings.Join(messages, ","))
+}
+
+func TestMergeAppLogs(t *testing.T) {
+	base := time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC)
+	appEntries := []types.AppLogEntry{
+		{Time: base, Source: types.AppLogSourceHandler, Message: "a1"},
+		{Time: base.Add(2 * time.Second), Source: types.AppLogSourceError, Message: "a2 timeout"},
+	}
+	containerEntries := []types.AppLogEntry{
+		{Time: base.Add(time.Second), Source: types.AppLogSourceContainer, Message: "c1"},
+		{Time: base.Add(3 * time.Second), Source: types.AppLogSourceContainer, Message: "c2 timeout"},
+	}
+
+	messages := func(entries []types.AppLogEntry) []string {
+		ret := make([]string, 0, len(entries))
+		for _, entry := range entries {
+			ret = append(ret, entry.Message)
+		}
+		return ret
+	}
+
+	merged := mergeAppLogs(appEntries, containerEntries, nil, 0)
+	testutil.AssertEqualsString(t, "messages", "a1,c1,a2 timeout,c2 timeout", strings.Join(messages(merged), ","))
+
+	merged = mergeAppLogs(appEntries, containerEntries, nil, 2)
+	testutil.AssertEqualsString(t, "messages", "a2 timeout,c2 timeout", strings.Join(messages(merged), ","))
+
+	merged = mergeAppLogs(appEntries, containerEntries, regexp.MustCompile("timeout"), 1)
+	testutil.AssertEqualsString(t, "messages", "c2 timeout", strings.Join(messages(merged), ","))
+}
+
+func TestWriteAPIStream(t *testing.T) {
+	stream := func(yield func(any) bool) {
+		for _, msg := range []string{"one", "two"} {
+			if !yield(types.AppLogEntry{Source: types.AppLogSourceHandler, Message: msg}) {
+				return
+			}
+		}
+	}
+	w := httptest.NewRecorder()
+	writeAPIStream(w, httptest.NewRequest(http.MethodGet, "/_openrun/app_logs", nil), stream)
+	testutil.AssertEqualsString(t, "content type", "application/x-ndjson", w.Header().Get("Content-Type"))
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	testutil.AssertEqualsInt(t, "lines", 2, len(lines))
+	testutil.AssertStringContains(t, lines[1], `"message":"two"`)
+
+	// Server-sent events when the client accepts them
+	w = httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/_openrun/app_container_logs", nil)
+	r.Header.Set("Accept", "text/event-stream")
+	writeAPIStream(w, r, stream)
+	testutil.AssertEqualsString(t, "content type", "text/event-stream", w.Header().Get("Content-Type"))
+	events := strings.Split(strings.TrimSpace(w.Body.String()), "\n\n")
+	testutil.AssertEqualsInt(t, "events", 2, len(events))
+	if !strings.HasPrefix(events[0], "data: {") {
+		t.Errorf("expected a data event, got %q", events[0])
+	}
+}
+
+func TestWriteAPIStreamDisconnect(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	yielded := 0
+	stopped := false
+	stream := func(yield func(any) bool) {
+		for i := range 5 {
+			if i == 2 {
+				// Client disconnects during the stream
+				cancel()
+			}
+			if !yield(types.AppLogEntry{Source: types.AppLogSourceHandler, Message: "line"}) {
+				stopped = true
+				return
+			}
+			yielded++
+		}
+	}
+	w := httptest.NewRecorder()
+	writeAPIStream(w, httptest.NewRequest(http.MethodGet, "/_openrun/app_logs", nil).WithContext(ctx), stream)
+	testutil.AssertEqualsBool(t, "stopped", true, stopped)
+	testutil.AssertEqualsInt(t, "yielded", 2, yielded)
+	testutil.AssertEqualsInt(t, "lines", 2, len(strings.Split(strings.TrimSpace(w.Body.String()), "\n")))
+}
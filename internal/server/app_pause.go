@@ -0,0 +1,361 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"archive/zip"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/container"
+	"github.com/openrundev/openrun/internal/metadata"
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	archiveConfigFile = "app.ace" // the declarative config for the app, in the archive bundle
+	archiveSourceDir  = "source"  // the directory for the app source files, in the archive bundle
+)
+
+// PauseApps pauses the matched apps, including their stage and preview apps. Paused apps are
+// not served, the users see a maintenance page with the message. The app containers are
+// stopped, the app metadata is kept. If undo is set, the apps are resumed
+func (s *Server) PauseApps(ctx context.Context, appPathGlob string, dryRun bool, message string, undo bool) (*types.AppUpdateSettingsResponse, error) {
+	if undo && message != "" {
+		return nil, types.CreateRequestError("message cannot be set when resuming apps", http.StatusBadRequest)
+	}
+
+	filteredApps, err := s.FilterApps(appPathGlob, false)
+	if err != nil {
+		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+	}
+	if err := s.enforceAppPermInfos(ctx, types.PermissionUpdate, filteredApps); err != nil {
+		return nil, err
+	}
+
+	var pause *types.AppPause
+	if !undo {
+		now := time.Now()
+		pause = &types.AppPause{
+			Message:  strings.TrimSpace(message),
+			PausedBy: system.GetContextUserId(ctx),
+			PausedAt: &now,
+		}
+	}
+
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	results := make([]types.AppPathDomain, 0, len(filteredApps))
+	containerApps := make([]types.AppId, 0, len(filteredApps))
+	for _, appInfo := range filteredApps {
+		mainAppEntry, err := s.db.GetAppEntryTx(ctx, tx, appInfo.AppPathDomain)
+		if err != nil {
+			return nil, fmt.Errorf("error getting app %s: %w", appInfo, err)
+		}
+		linkedApps, err := s.db.GetLinkedApps(ctx, tx, mainAppEntry.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, appEntry := range append(linkedApps, mainAppEntry) {
+			appEntry.Settings.Pause = pause
+			if err := s.db.UpdateAppSettings(ctx, tx, appEntry); err != nil {
+				return nil, err
+			}
+			results = append(results, appEntry.AppPathDomain())
+			if slices.Contains(appEntry.Metadata.Loads, CONTAINER_PLUGIN) {
+				containerApps = append(containerApps, appEntry.Id)
+			}
+		}
+	}
+
+	ret := &types.AppUpdateSettingsResponse{
+		DryRun:        dryRun,
+		UpdateResults: results,
+	}
+	if dryRun {
+		return ret, nil
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	op := "pause"
+	if undo {
+		op = "resume"
+	}
+	if err := s.apps.ClearAppsAudit(ctx, results, op); err != nil {
+		return nil, err
+	}
+	if !undo {
+		// The apps are unloaded, they are not initialized again while paused
+		s.stopAppRuntime(ctx, containerApps, false)
+	}
+	return ret, nil
+}
+
+// ArchiveApps archives the matched apps, including their stage and preview apps. The declarative
+// config and the source files of each app are exported to a bundle file in system.app_archive_dir
+// and the app containers and images are removed. The app entry is kept as a tombstone, requests
+// to the app get a gone response. If undo is set, the apps are restored, the app is initialized
+// again on the next request. Archiving requires the delete permission on the apps
+func (s *Server) ArchiveApps(ctx context.Context, appPathGlob string, dryRun bool, undo bool) (*types.AppArchiveResponse, error) {
+	filteredApps, err := s.FilterApps(appPathGlob, false)
+	if err != nil {
+		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+	}
+	if err := s.enforceAppPermInfos(ctx, types.PermissionUpdate, filteredApps); err != nil {
+		return nil, err
+	}
+	if !undo {
+		if err := s.enforceAppPermInfos(ctx, types.PermissionDelete, filteredApps); err != nil {
+			return nil, err
+		}
+	}
+
+	archiveDir := os.ExpandEnv(s.Config().System.AppArchiveDir)
+	if !undo && archiveDir == "" {
+		return nil, types.CreateRequestError("system.app_archive_dir is not set", http.StatusBadRequest)
+	}
+
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	var bindingsByPath map[string]*types.Binding
+	if !undo {
+		allBindings, err := s.db.ListBindings(ctx, tx, "")
+		if err != nil {
+			return nil, err
+		}
+		bindingsByPath = make(map[string]*types.Binding, len(allBindings))
+		for _, binding := range allBindings {
+			bindingsByPath[binding.Path] = binding
+		}
+	}
+
+	now := time.Now()
+	results := make([]types.AppPathDomain, 0, len(filteredApps))
+	bundlePaths := make(map[string]string)
+	archivedApps := make([]types.AppId, 0, len(filteredApps))
+	for _, appInfo := range filteredApps {
+		mainAppEntry, err := s.db.GetAppEntryTx(ctx, tx, appInfo.AppPathDomain)
+		if err != nil {
+			return nil, fmt.Errorf("error getting app %s: %w", appInfo, err)
+		}
+		linkedApps, err := s.db.GetLinkedApps(ctx, tx, mainAppEntry.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		var archive *types.AppArchive
+		if !undo {
+			bundlePath := filepath.Join(archiveDir, fmt.Sprintf("%s-%s.zip", mainAppEntry.Id, now.Format("20060102-150405")))
+			if !dryRun {
+				if err := s.writeAppBundle(ctx, tx, mainAppEntry, bindingsByPath, bundlePath); err != nil {
+					return nil, fmt.Errorf("error exporting app %s: %w", appInfo, err)
+				}
+			}
+			bundlePaths[mainAppEntry.AppPathDomain().String()] = bundlePath
+			archive = &types.AppArchive{
+				BundlePath: bundlePath,
+				ArchivedBy: system.GetContextUserId(ctx),
+				ArchivedAt: &now,
+			}
+		}
+
+		for _, appEntry := range append(linkedApps, mainAppEntry) {
+			appEntry.Settings.Archive = archive
+			if err := s.db.UpdateAppSettings(ctx, tx, appEntry); err != nil {
+				return nil, err
+			}
+			results = append(results, appEntry.AppPathDomain())
+			if slices.Contains(appEntry.Metadata.Loads, CONTAINER_PLUGIN) {
+				archivedApps = append(archivedApps, appEntry.Id)
+			}
+		}
+	}
+
+	ret := &types.AppArchiveResponse{
+		DryRun:        dryRun,
+		UpdateResults: results,
+		BundlePaths:   bundlePaths,
+	}
+	if dryRun {
+		return ret, nil
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, err
+	}
+	op := "archive"
+	if undo {
+		op = "restore"
+	}
+	if err := s.apps.ClearAppsAudit(ctx, results, op); err != nil {
+		return nil, err
+	}
+	if !undo {
+		s.stopAppRuntime(ctx, archivedApps, true)
+	}
+	return ret, nil
+}
+
+// writeAppBundle writes the archive bundle for the app, the declarative config for the current
+// prod version and the source files from the metadata database. Dev apps and static disk apps
+// have their source on disk, only the config is written for them. The bundle is written to a
+// temp file first, so that a partial bundle is not left behind on errors
+func (s *Server) writeAppBundle(ctx context.Context, tx types.Transaction, appEntry *types.AppEntry,
+	bindingsByPath map[string]*types.Binding, bundlePath string) error {
+	builder := newExportBuilder(types.ExportOptions{
+		ServiceRef:  types.ExportRefDefault,
+		GitAuthRef:  types.ExportRefDefault,
+		ExactCommit: true,
+	})
+	appReq := s.exportApp(ctx, tx, appEntry, bindingsByPath, builder)
+	body, formatWarnings := formatConfig(nil, []*types.CreateAppRequest{appReq})
+	builder.warnings = append(builder.warnings, formatWarnings...)
+
+	var fileNames []string
+	var readFile func(name string) ([]byte, error)
+	if !appEntry.IsDev && appEntry.Metadata.Spec != types.StaticDiskSpec {
+		fileStore, err := metadata.NewFileStore(appEntry.Id, appEntry.Metadata.VersionMetadata.Version, s.db, tx)
+		if err != nil {
+			return err
+		}
+		files, err := fileStore.GetAppFiles(ctx, tx)
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			fileNames = append(fileNames, file.Name)
+		}
+		dbFs, err := metadata.NewDbFs(s.Logger, fileStore, *appEntry.Metadata.SpecFiles)
+		if err != nil {
+			return err
+		}
+		readFile = dbFs.ReadFile
+	}
+
+	if err := os.MkdirAll(filepath.Dir(bundlePath), 0700); err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp(filepath.Dir(bundlePath), ".bundle-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tempFile.Name()) //nolint:errcheck
+
+	if err := writeArchiveBundle(tempFile, builder.header()+body, fileNames, readFile); err != nil {
+		tempFile.Close() //nolint:errcheck
+		return err
+	}
+	if err := tempFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tempFile.Name(), bundlePath)
+}
+
+// writeArchiveBundle writes the bundle zip, with the declarative config at the root and the
+// source files under the source directory
+func writeArchiveBundle(w io.Writer, config string, fileNames []string, readFile func(name string) ([]byte, error)) error {
+	writer := zip.NewWriter(w)
+	dest, err := writer.Create(archiveConfigFile)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(dest, config); err != nil {
+		return err
+	}
+
+	for _, name := range fileNames {
+		data, err := readFile(name)
+		if err != nil {
+			return fmt.Errorf("error reading file %s: %w", name, err)
+		}
+		dest, err := writer.Create(path.Join(archiveSourceDir, name))
+		if err != nil {
+			return err
+		}
+		if _, err := dest.Write(data); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// stopAppRuntime stops the containers of the apps on this server. With remove set, used for
+// archived apps, the containers, images and app network are removed for Docker/Podman. The apps
+// have to be unloaded before this, so that the containers are not started again. The containers
+// on the other servers are stopped by their stale container cleanup
+func (s *Server) stopAppRuntime(ctx context.Context, appIds []types.AppId, remove bool) {
+	config := s.Config()
+	command := config.System.ContainerCommand
+	if command == "" {
+		return
+	}
+
+	for _, appId := range appIds {
+		var err error
+		if command == types.CONTAINER_KUBERNETES {
+			// The deployment is scaled down, the app is deployed again when it is resumed
+			var manager *container.KubernetesCM
+			if manager, err = container.NewKubernetesCM(s.Logger, config, &config.AppConfig, "", appId); err == nil {
+				err = manager.StopContainer(ctx, container.GenContainerName(appId, "", true))
+			}
+		} else {
+			manager := container.NewCommandCM(s.Logger, config, appId, "")
+			if remove {
+				err = manager.RemoveAppRuntime(ctx)
+			} else {
+				err = errors.Join(manager.StopAppContainersExcept(ctx, appId, ""), manager.StopAppServicesExcept(ctx, nil))
+			}
+		}
+		if err != nil {
+			s.Warn().Err(err).Str("app_id", string(appId)).Msg("Error stopping app containers")
+		}
+	}
+}
+
+// inactiveAppPage is the response for requests to a paused or archived app. The app is not
+// initialized, so that its container is not started
+func (s *Server) inactiveAppPage(w http.ResponseWriter, r *http.Request, application *app.App) {
+	name := cmp.Or(application.Name, application.Path)
+	if application.Settings.Archive != nil {
+		s.errorPage(w, r, http.StatusGone, "error.app_archived", name)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	if message := application.Settings.Pause.Message; message != "" {
+		s.errorPage(w, r, http.StatusServiceUnavailable, "error.app_paused_message", name, message)
+		return
+	}
+	s.errorPage(w, r, http.StatusServiceUnavailable, "error.app_paused", name)
+}
+
+// inactiveAppError is the error for the background operations on a paused or archived app
+func inactiveAppError(application *app.App) error {
+	if application.Settings.Archive != nil {
+		return types.CreateRequestError(fmt.Sprintf("app %s is archived", application.AppPathDomain()), http.StatusGone)
+	}
+	return types.CreateRequestError(fmt.Sprintf("app %s is paused", application.AppPathDomain()), http.StatusServiceUnavailable)
+}
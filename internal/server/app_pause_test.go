@@ -0,0 +1,100 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestInactiveAppPage(t *testing.T) {
+	t.Parallel()
+
+	server := &Server{
+		Logger:       testutil.TestLogger(),
+		staticConfig: &types.ServerConfig{System: types.SystemConfig{Language: "en"}},
+	}
+	now := time.Now()
+	application := &app.App{AppEntry: &types.AppEntry{Path: "/tools/report"}}
+	application.Settings.Pause = &types.AppPause{PausedAt: &now}
+
+	w := httptest.NewRecorder()
+	server.inactiveAppPage(w, httptest.NewRequest(http.MethodGet, "/tools/report", nil), application)
+	testutil.AssertEqualsInt(t, "code", http.StatusServiceUnavailable, w.Code)
+	testutil.AssertEqualsString(t, "body", "/tools/report is paused for maintenance\n", w.Body.String())
+	testutil.AssertEqualsString(t, "cache control", "no-store", w.Header().Get("Cache-Control"))
+
+	application.Settings.Pause.Message = "Back at 10:00"
+	w = httptest.NewRecorder()
+	server.inactiveAppPage(w, httptest.NewRequest(http.MethodGet, "/tools/report", nil), application)
+	testutil.AssertEqualsString(t, "body", "/tools/report is paused for maintenance: Back at 10:00\n", w.Body.String())
+
+	application.Name = "Reports"
+	application.Settings.Archive = &types.AppArchive{ArchivedAt: &now}
+	req := httptest.NewRequest(http.MethodGet, "/tools/report", nil)
+	req.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	server.inactiveAppPage(w, req, application)
+	testutil.AssertEqualsInt(t, "code", http.StatusGone, w.Code)
+	testutil.AssertStringContains(t, w.Body.String(), "410 No longer available")
+	testutil.AssertStringContains(t, w.Body.String(), "Reports has been archived")
+}
+
+func TestWriteArchiveBundle(t *testing.T) {
+	t.Parallel()
+
+	files := map[string]string{
+		"app.star":                "app = ace.app(\"Report\")",
+		"templates/index.go.html": "<h1>Report</h1>",
+	}
+	readFile := func(name string) ([]byte, error) {
+		data, ok := files[name]
+		if !ok {
+			return nil, fmt.Errorf("file %s not found", name)
+		}
+		return []byte(data), nil
+	}
+
+	var buf bytes.Buffer
+	config := "app(path=\"/tools/report\", source=\"github.com/example/report\")\n"
+	if err := writeArchiveBundle(&buf, config, []string{"app.star", "templates/index.go.html"}, readFile); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents := map[string]string{}
+	for _, file := range reader.File {
+		f, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(f)
+		f.Close() //nolint:errcheck
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents[file.Name] = string(data)
+	}
+	testutil.AssertEqualsInt(t, "files", 3, len(contents))
+	testutil.AssertEqualsString(t, "config", config, contents["app.ace"])
+	testutil.AssertEqualsString(t, "source", files["app.star"], contents["source/app.star"])
+	testutil.AssertEqualsString(t, "template", files["templates/index.go.html"], contents["source/templates/index.go.html"])
+
+	if err := writeArchiveBundle(io.Discard, config, []string{"missing.star"}, readFile); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/segmentio/ksuid"
+)
+
+const replSessionIdleTimeout = 30 * time.Minute
+
+// replSessionEntry is a REPL session for an app. The session uses the app instance from when
+// the session was created, a new session is required to see app updates
+type replSessionEntry struct {
+	appPath  types.AppPathDomain
+	userId   string
+	session  *app.ReplSession
+	lastUsed time.Time
+}
+
+// getReplSession returns the session, after removing the idle sessions. The session has to
+// be for the same app and user
+func (s *Server) getReplSession(ctx context.Context, sessionId string, appPath types.AppPathDomain) (*replSessionEntry, error) {
+	s.replMu.Lock()
+	defer s.replMu.Unlock()
+	for id, entry := range s.replSessions {
+		if time.Since(entry.lastUsed) > replSessionIdleTimeout {
+			delete(s.replSessions, id)
+		}
+	}
+
+	entry, ok := s.replSessions[sessionId]
+	if !ok || entry.appPath != appPath || entry.userId != system.GetContextUserId(ctx) {
+		return nil, types.CreateRequestError(fmt.Sprintf("repl session %s not found for app %s, it might have expired", sessionId, appPath), http.StatusNotFound)
+	}
+	entry.lastUsed = time.Now()
+	return entry, nil
+}
+
+// AppRepl evaluates the input in a REPL session for the app. A new session is created if the
+// session id is not set, the mode is used only when creating the session. The app update
+// permission is required, since the input can run any code as the app
+func (s *Server) AppRepl(ctx context.Context, appPath string, mode types.ReplMode, req *types.AppReplRequest) (*types.AppReplResponse, error) {
+	appPathDomain, err := parseAppPath(appPath)
+	if err != nil {
+		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+	}
+	appEntry, err := s.db.GetAppEntry(ctx, appPathDomain)
+	if err != nil {
+		return nil, types.CreateRequestError(err.Error(), http.StatusNotFound)
+	}
+	if err := s.enforceAppPermEntry(ctx, types.PermissionUpdate, appEntry); err != nil {
+		return nil, err
+	}
+
+	var entry *replSessionEntry
+	if req.SessionId != "" {
+		if entry, err = s.getReplSession(ctx, req.SessionId, appPathDomain); err != nil {
+			return nil, err
+		}
+	} else {
+		application, err := s.GetApp(ctx, appPathDomain, true)
+		if err != nil {
+			return nil, err
+		}
+		session, err := application.NewReplSession(cmp.Or(mode, types.ReplModeReadOnly))
+		if err != nil {
+			return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		}
+		req.SessionId = "repl_" + ksuid.New().String()
+		entry = &replSessionEntry{
+			appPath:  appPathDomain,
+			userId:   system.GetContextUserId(ctx),
+			session:  session,
+			lastUsed: time.Now(),
+		}
+		s.replMu.Lock()
+		if s.replSessions == nil {
+			s.replSessions = make(map[string]*replSessionEntry)
+		}
+		s.replSessions[req.SessionId] = entry
+		s.replMu.Unlock()
+		s.Info().Str("app", appPath).Str("mode", string(entry.session.Mode())).Msg("Created repl session")
+	}
+
+	ret := entry.session.Eval(ctx, req.Input)
+	ret.SessionId = req.SessionId
+	return ret, nil
+}
+
+// CloseAppRepl removes the REPL session
+func (s *Server) CloseAppRepl(ctx context.Context, appPath, sessionId string) error {
+	appPathDomain, err := parseAppPath(appPath)
+	if err != nil {
+		return types.CreateRequestError(err.Error(), http.StatusBadRequest)
+	}
+	if _, err := s.getReplSession(ctx, sessionId, appPathDomain); err != nil {
+		return err
+	}
+	s.replMu.Lock()
+	defer s.replMu.Unlock()
+	delete(s.replSessions, sessionId)
+	return nil
+}
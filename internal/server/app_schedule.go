@@ -0,0 +1,76 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// runAppSchedules stops the containers of the apps which are outside their active hours and
+// initializes the apps again schedule.prestart_mins before the next window opens. Runs on every
+// server, not just the leader, since each server runs its own app containers. Only the apps
+// loaded on this server are checked, the other apps have no containers running
+func (s *Server) runAppSchedules(ctx context.Context, runner *jobRunner) {
+	now := time.Now()
+	minute := now.Truncate(time.Minute)
+	if minute.Equal(runner.lastScheduleCheck) {
+		return
+	}
+	runner.lastScheduleCheck = minute
+
+	for _, application := range s.apps.LoadedApps() {
+		if ctx.Err() != nil {
+			return
+		}
+		if !application.HasActiveHours() || application.Settings.Inactive() {
+			continue
+		}
+
+		pathDomain := application.AppPathDomain()
+		outside, next := application.OutsideActiveHours(now)
+		prestart := time.Duration(application.AppConfig.Schedule.PrestartMins) * time.Minute
+		if outside && next.Sub(now) > prestart {
+			if !application.StopForSchedule(ctx) {
+				continue
+			}
+			s.Info().Str("app", pathDomain.String()).Time("next_start", next).Msg("Stopped app container outside active hours")
+			// The app is loaded again without being initialized, so that it is started before the
+			// next window opens. Other servers manage their own containers, they are not notified
+			s.apps.ClearAppsNoNotify([]types.AppPathDomain{pathDomain})
+			if _, err := s.GetApp(ctx, pathDomain, false); err != nil {
+				s.Error().Err(err).Str("app", pathDomain.String()).Msg("Error loading app stopped outside active hours")
+			}
+			continue
+		}
+
+		if application.NeedsInitialize() {
+			runner.wg.Add(1)
+			go func() {
+				defer runner.wg.Done()
+				if err := application.Initialize(ctx, types.DryRunFalse); err != nil {
+					s.Error().Err(err).Str("app", pathDomain.String()).Msg("Error starting app for active hours")
+					return
+				}
+				s.Info().Str("app", pathDomain.String()).Msg("Started app for active hours")
+			}()
+		}
+	}
+}
+
+// outsideActiveHoursPage is the response for requests to an app outside its active hours. The
+// app is not initialized, so that its container is not started
+func (s *Server) outsideActiveHoursPage(w http.ResponseWriter, r *http.Request, application *app.App, next time.Time) {
+	if !next.IsZero() {
+		w.Header().Set("Retry-After", strconv.Itoa(max(int(time.Until(next).Seconds()), 1)))
+	}
+	s.errorPage(w, r, http.StatusServiceUnavailable, "error.outside_active_hours",
+		cmp.Or(application.Name, application.Path), next.Format("Mon Jan 2 15:04 MST"))
+}
@@ -0,0 +1,47 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestOutsideActiveHoursPage(t *testing.T) {
+	t.Parallel()
+
+	server := &Server{
+		Logger:       testutil.TestLogger(),
+		staticConfig: &types.ServerConfig{System: types.SystemConfig{Language: "en"}},
+	}
+	application := &app.App{AppEntry: &types.AppEntry{Path: "/tools/report"}}
+	next := time.Date(2025, 1, 16, 9, 0, 0, 0, time.UTC)
+
+	req := httptest.NewRequest(http.MethodGet, "/tools/report", nil)
+	w := httptest.NewRecorder()
+	server.outsideActiveHoursPage(w, req, application, next)
+	testutil.AssertEqualsInt(t, "code", http.StatusServiceUnavailable, w.Code)
+	testutil.AssertEqualsString(t, "body",
+		"/tools/report is available only during its active hours, it opens again at Thu Jan 16 09:00 UTC\n", w.Body.String())
+	retry, err := strconv.Atoi(w.Header().Get("Retry-After"))
+	if err != nil || retry != 1 {
+		// next is in the past, the minimum retry is used
+		t.Errorf("unexpected Retry-After %q", w.Header().Get("Retry-After"))
+	}
+
+	application.Name = "Reports"
+	req = httptest.NewRequest(http.MethodGet, "/tools/report", nil)
+	req.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	server.outsideActiveHoursPage(w, req, application, next)
+	testutil.AssertStringContains(t, w.Body.String(), "503 Service unavailable")
+	testutil.AssertStringContains(t, w.Body.String(), "Reports is available only during its active hours")
+}
@@ -0,0 +1,214 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/rbac"
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	defaultSearchLimit = 50
+	maxSearchLimit     = 500
+)
+
+// The fields which can be used as qualifiers in the search query, like team:infra
+const (
+	searchFieldName   = "name"
+	searchFieldPath   = "path"
+	searchFieldDomain = "domain"
+	searchFieldTag    = "tag"
+	searchFieldSpec   = "spec"
+	searchFieldSource = "source"
+	searchFieldOwner  = "owner"
+	searchFieldTeam   = "team"
+)
+
+var searchFields = []string{searchFieldName, searchFieldPath, searchFieldDomain, searchFieldTag,
+	searchFieldSpec, searchFieldSource, searchFieldOwner, searchFieldTeam}
+
+// appSearchTerm is one term of the search query. A term without a field matches any field
+type appSearchTerm struct {
+	field string
+	value string // lower cased
+}
+
+// parseAppSearchQuery splits the query into terms on white space. A term of the form field:value,
+// where field is one of the search fields, matches that field only. Other terms, including app
+// paths with a domain like example.com:/app, match any field
+func parseAppSearchQuery(query string) []appSearchTerm {
+	terms := []appSearchTerm{}
+	for _, word := range strings.Fields(strings.ToLower(query)) {
+		field, value, ok := strings.Cut(word, ":")
+		if ok && value != "" && slices.Contains(searchFields, field) {
+			terms = append(terms, appSearchTerm{field: field, value: value})
+		} else {
+			terms = append(terms, appSearchTerm{value: word})
+		}
+	}
+	return terms
+}
+
+// matchScore returns the score for the value matching the text exactly, as a prefix or as a substring
+func matchScore(text, value string, exact, prefix, contains int) int {
+	text = strings.ToLower(text)
+	switch {
+	case text == "":
+		return 0
+	case text == value:
+		return exact
+	case strings.HasPrefix(text, value):
+		return prefix
+	case strings.Contains(text, value):
+		return contains
+	}
+	return 0
+}
+
+// fieldScore returns the score for the term value matching the app field, zero if it does not match.
+// Matches on the name and the tags rank higher than matches on the source url
+func fieldScore(app *types.AppInfo, field, value string) int {
+	switch field {
+	case searchFieldName:
+		return matchScore(app.Name, value, 100, 60, 40)
+	case searchFieldPath:
+		return max(matchScore(path.Base(app.Path), value, 80, 50, 30), matchScore(app.Path, value, 80, 30, 20))
+	case searchFieldDomain:
+		return matchScore(app.Domain, value, 50, 30, 20)
+	case searchFieldTag:
+		score := 0
+		for _, tag := range app.Tags {
+			score = max(score, matchScore(tag, value, 70, 40, 0))
+		}
+		return score
+	case searchFieldSpec:
+		return matchScore(string(app.Spec), value, 40, 20, 15)
+	case searchFieldSource:
+		return matchScore(app.SourceUrl, value, 20, 10, 10)
+	case searchFieldOwner:
+		return matchScore(app.UserID, value, 50, 20, 10)
+	case searchFieldTeam:
+		return matchScore(app.UserID, rbac.RBAC_GROUP_PREFIX+value, 60, 0, 0)
+	}
+	return 0
+}
+
+// appSearchScore returns the rank of the app for the query terms, zero if any term does not
+// match. The score for a term is the best score across the fields it applies to. A term without
+// a field does not match the team, since that is covered by the owner
+func appSearchScore(app *types.AppInfo, terms []appSearchTerm) int {
+	total := 0
+	for _, term := range terms {
+		score := 0
+		if term.field != "" {
+			score = fieldScore(app, term.field, term.value)
+		} else {
+			// The app path with the domain, for terms like example.com:/app
+			score = matchScore(app.String(), term.value, 80, 30, 20)
+			for _, field := range searchFields {
+				if field != searchFieldTeam {
+					score = max(score, fieldScore(app, field, term.value))
+				}
+			}
+		}
+		if score == 0 {
+			return 0
+		}
+		total += score
+	}
+	return max(total, 1) // an empty query matches all apps
+}
+
+// normalizeTags lower cases the tags and removes the empty and duplicate tags. Tags cannot
+// have white space or a colon, since they are used as search terms
+func normalizeTags(tags []string) ([]string, error) {
+	ret := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" {
+			continue
+		}
+		if strings.ContainsAny(tag, " \t\n:") {
+			return nil, fmt.Errorf("invalid tag %q, tags cannot have spaces or colons", tag)
+		}
+		if !slices.Contains(ret, tag) {
+			ret = append(ret, tag)
+		}
+	}
+	slices.Sort(ret)
+	return ret, nil
+}
+
+// SearchApps returns the apps matching the search query, ranked by relevance. The apps are
+// filtered by the glob and by the list permission of the user before the offset and limit
+// are applied. All the query terms have to match
+func (s *Server) SearchApps(ctx context.Context, appPathGlob, query string, internal bool, offset, limit int) (*types.AppSearchResponse, error) {
+	if offset < 0 {
+		return nil, types.CreateRequestError("offset cannot be negative", http.StatusBadRequest)
+	}
+	if limit <= 0 {
+		limit = defaultSearchLimit
+	}
+	limit = min(limit, maxSearchLimit)
+	terms := parseAppSearchQuery(query)
+
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	filteredApps, err := s.FilterApps(appPathGlob, internal)
+	if err != nil {
+		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+	}
+
+	type rankedApp struct {
+		app   types.AppInfo
+		score int
+	}
+	userId := system.GetContextUserId(ctx)
+	groups := system.GetContextGroups(ctx)
+	matches := make([]rankedApp, 0)
+	for _, app := range filteredApps {
+		score := appSearchScore(&app, terms)
+		if score == 0 {
+			continue
+		}
+		authorized, err := s.AuthorizeList(ctx, userId, &app, groups)
+		if err != nil {
+			return nil, types.CreateRequestError(err.Error(), http.StatusInternalServerError)
+		}
+		if authorized {
+			matches = append(matches, rankedApp{app: app, score: score})
+		}
+	}
+	slices.SortStableFunc(matches, func(a, b rankedApp) int {
+		return cmp.Or(cmp.Compare(b.score, a.score), cmp.Compare(a.app.String(), b.app.String()))
+	})
+
+	ret := &types.AppSearchResponse{
+		Apps:   make([]types.AppResponse, 0, min(limit, len(matches))),
+		Total:  len(matches),
+		Offset: offset,
+		Limit:  limit,
+	}
+	for _, match := range matches[min(offset, len(matches)):min(offset+limit, len(matches))] {
+		appResponse, err := s.getAppResponse(ctx, tx, match.app)
+		if err != nil {
+			return nil, err
+		}
+		ret.Apps = append(ret.Apps, *appResponse)
+	}
+	return ret, nil
+}
@@ -0,0 +1,67 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestParseAppSearchQuery(t *testing.T) {
+	terms := parseAppSearchQuery(" Grafana  team:Infra example.com:/app owner: ")
+	testutil.AssertEqualsInt(t, "terms", 4, len(terms))
+	testutil.AssertEqualsString(t, "free", "grafana", terms[0].value)
+	testutil.AssertEqualsString(t, "field", "team", terms[1].field)
+	testutil.AssertEqualsString(t, "value", "infra", terms[1].value)
+	testutil.AssertEqualsString(t, "domain path", "", terms[2].field)
+	testutil.AssertEqualsString(t, "domain path value", "example.com:/app", terms[2].value)
+	testutil.AssertEqualsString(t, "empty value", "owner:", terms[3].value)
+}
+
+func TestAppSearchScore(t *testing.T) {
+	apps := []types.AppInfo{
+		{AppPathDomain: types.AppPathDomain{Path: "/monitoring/grafana"}, Name: "Grafana", UserID: "group:infra"},
+		{AppPathDomain: types.AppPathDomain{Path: "/dash", Domain: "example.com"}, Name: "Dashboards",
+			SourceUrl: "github.com/org/grafana-dash", UserID: "alice", Tags: []string{"metrics"}},
+		{AppPathDomain: types.AppPathDomain{Path: "/tools/report"}, Name: "Reports", UserID: "bob"},
+	}
+	search := func(query string) []string {
+		terms := parseAppSearchQuery(query)
+		matches := []types.AppInfo{}
+		for _, app := range apps {
+			if appSearchScore(&app, terms) > 0 {
+				matches = append(matches, app)
+			}
+		}
+		slices.SortStableFunc(matches, func(a, b types.AppInfo) int {
+			return appSearchScore(&b, terms) - appSearchScore(&a, terms)
+		})
+		ret := []string{}
+		for _, app := range matches {
+			ret = append(ret, app.Name)
+		}
+		return ret
+	}
+
+	testutil.AssertEqualsString(t, "ranked", "[Grafana Dashboards]", fmt.Sprint(search("grafana")))
+	testutil.AssertEqualsString(t, "team", "[Grafana]", fmt.Sprint(search("grafana team:infra")))
+	testutil.AssertEqualsString(t, "team no match", "[]", fmt.Sprint(search("team:alice")))
+	testutil.AssertEqualsString(t, "tag", "[Dashboards]", fmt.Sprint(search("tag:metrics")))
+	testutil.AssertEqualsString(t, "domain path", "[Dashboards]", fmt.Sprint(search("example.com:/dash")))
+	testutil.AssertEqualsString(t, "field", "[]", fmt.Sprint(search("name:grafana-dash")))
+	testutil.AssertEqualsString(t, "all", "[Grafana Dashboards Reports]", fmt.Sprint(search("")))
+}
+
+func TestNormalizeTags(t *testing.T) {
+	tags, err := normalizeTags([]string{"Infra", " metrics ", "", "infra"})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "tags", "[infra metrics]", fmt.Sprint(tags))
+
+	_, err = normalizeTags([]string{"team:infra"})
+	testutil.AssertErrorContains(t, err, "tags cannot have spaces or colons")
+}
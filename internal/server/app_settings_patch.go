@@ -0,0 +1,275 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/metadata"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// patchableSettings are the app settings which can be updated with a settings patch. The
+// settings are not staged, they apply to all the linked apps
+type patchableSettings struct {
+	StageWriteAccess   bool     `json:"stage_write_access"`
+	PreviewWriteAccess bool     `json:"preview_write_access"`
+	Tags               []string `json:"tags"`
+}
+
+// patchableMetadata are the app metadata values which can be updated with a settings patch. The
+// metadata changes are staged, they apply to prod after a promote
+type patchableMetadata struct {
+	AuthnType        types.AppAuthnType `json:"authn_type"`
+	GitAuthName      string             `json:"git_auth_name"`
+	ContainerOptions map[string]string  `json:"container_options"`
+	ContainerArgs    map[string]string  `json:"container_args"`
+	ContainerVolumes []string           `json:"container_volumes"`
+	AppConfig        map[string]string  `json:"app_config"`
+}
+
+var (
+	patchSettingsKeys = jsonFieldNames(patchableSettings{})
+	patchMetadataKeys = jsonFieldNames(patchableMetadata{})
+)
+
+func jsonFieldNames(value any) map[string]bool {
+	data, _ := json.Marshal(value)
+	fields := map[string]any{}
+	json.Unmarshal(data, &fields) //nolint:errcheck
+	ret := make(map[string]bool, len(fields))
+	for name := range fields {
+		ret[name] = true
+	}
+	return ret
+}
+
+// splitSettingsPatch splits the patch into the settings and the metadata patches
+func splitSettingsPatch(patch map[string]any) (map[string]any, map[string]any, error) {
+	settingsPatch := map[string]any{}
+	metadataPatch := map[string]any{}
+	for key, value := range patch {
+		switch {
+		case patchSettingsKeys[key]:
+			settingsPatch[key] = value
+		case patchMetadataKeys[key]:
+			metadataPatch[key] = value
+		default:
+			supported := slices.Sorted(maps.Keys(patchSettingsKeys))
+			supported = append(supported, slices.Sorted(maps.Keys(patchMetadataKeys))...)
+			return nil, nil, fmt.Errorf("unsupported setting %s in patch, supported: %s", key, strings.Join(supported, ", "))
+		}
+	}
+	return settingsPatch, metadataPatch, nil
+}
+
+// applyMergePatch applies a JSON merge patch (RFC 7396) to the target value. A null in the patch
+// removes the key, objects are merged recursively and any other value replaces the target value
+func applyMergePatch(target, patch any) any {
+	patchMap, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	targetMap, ok := target.(map[string]any)
+	if !ok {
+		targetMap = map[string]any{}
+	}
+	for key, value := range patchMap {
+		if value == nil {
+			delete(targetMap, key)
+		} else {
+			targetMap[key] = applyMergePatch(targetMap[key], value)
+		}
+	}
+	return targetMap
+}
+
+// mergePatchValue returns the value with the merge patch applied. The patched value has to
+// decode into the type of the value
+func mergePatchValue[T any](value T, patch map[string]any) (T, error) {
+	var ret T
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ret, err
+	}
+	var current any
+	if err := json.Unmarshal(data, &current); err != nil {
+		return ret, err
+	}
+	if data, err = json.Marshal(applyMergePatch(current, patch)); err != nil {
+		return ret, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&ret); err != nil {
+		return ret, fmt.Errorf("invalid settings patch: %w", err)
+	}
+	return ret, nil
+}
+
+// PatchAppSettings applies a JSON merge patch of settings to the apps matching the glob, in one
+// transaction. The write access settings apply immediately to all the linked apps. The auth,
+// git auth, container and app config values are staged, they are promoted to prod if promote
+// is set. If ifVersion is not negative, the update fails with a version conflict if the app row
+// version is not ifVersion
+func (s *Server) PatchAppSettings(ctx context.Context, appPathGlob string, dryRun, promote bool, ifVersion int64, patch map[string]any) (*types.AppUpdateSettingsResponse, error) {
+	ctx = withPromoteState(ctx, dryRun)
+	if len(patch) == 0 {
+		return nil, types.CreateRequestError("settings patch is empty", http.StatusBadRequest)
+	}
+	settingsPatch, metadataPatch, err := splitSettingsPatch(patch)
+	if err != nil {
+		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+	}
+
+	filteredApps, err := s.FilterApps(appPathGlob, false)
+	if err != nil {
+		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+	}
+	if err := checkIfVersion(filteredApps, ifVersion); err != nil {
+		return nil, err
+	}
+	if err := s.enforceAppPermInfos(ctx, types.PermissionUpdate, filteredApps); err != nil {
+		return nil, err
+	}
+	if promote && len(metadataPatch) > 0 {
+		if err := s.enforceAppPermInfos(ctx, types.PermissionPromote, filteredApps); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	results := make([]types.AppPathDomain, 0, len(filteredApps))
+	for _, appInfo := range filteredApps {
+		mainAppEntry, err := s.db.GetAppEntryTx(ctx, tx, appInfo.AppPathDomain)
+		if err != nil {
+			return nil, fmt.Errorf("error getting app %s: %w", appInfo, err)
+		}
+		if err := checkAppVersion(mainAppEntry, ifVersion); err != nil {
+			return nil, err
+		}
+		linkedApps, err := s.db.GetLinkedApps(ctx, tx, mainAppEntry.Id)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(settingsPatch) > 0 {
+			for _, appEntry := range append(linkedApps, mainAppEntry) {
+				if err := s.patchSettings(ctx, tx, appEntry, settingsPatch); err != nil {
+					return nil, fmt.Errorf("app %s: %w", appEntry.AppPathDomain(), err)
+				}
+			}
+		}
+		if len(metadataPatch) > 0 {
+			if err := s.patchMetadata(ctx, tx, mainAppEntry, metadataPatch, promote); err != nil {
+				return nil, fmt.Errorf("app %s: %w", mainAppEntry.AppPathDomain(), err)
+			}
+		}
+
+		for _, appEntry := range append(linkedApps, mainAppEntry) {
+			results = append(results, appEntry.AppPathDomain())
+		}
+	}
+
+	ret := &types.AppUpdateSettingsResponse{
+		DryRun:        dryRun,
+		UpdateResults: results,
+	}
+	if err := s.CompleteTransaction(ctx, tx, results, dryRun, "patch_settings"); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+func (s *Server) patchSettings(ctx context.Context, tx types.Transaction, appEntry *types.AppEntry, patch map[string]any) error {
+	current := patchableSettings{
+		StageWriteAccess:   appEntry.Settings.StageWriteAccess,
+		PreviewWriteAccess: appEntry.Settings.PreviewWriteAccess,
+		Tags:               appEntry.Settings.Tags,
+	}
+	updated, err := mergePatchValue(current, patch)
+	if err != nil {
+		return err
+	}
+	appEntry.Settings.StageWriteAccess = updated.StageWriteAccess
+	appEntry.Settings.PreviewWriteAccess = updated.PreviewWriteAccess
+	if appEntry.Settings.Tags, err = normalizeTags(updated.Tags); err != nil {
+		return err
+	}
+	return s.db.UpdateAppSettings(ctx, tx, appEntry)
+}
+
+// patchMetadata applies the patch to the staging app metadata for prod apps, the dev app
+// metadata is updated directly. The staging app version is incremented, like for other staged updates
+func (s *Server) patchMetadata(ctx context.Context, tx types.Transaction, mainAppEntry *types.AppEntry, patch map[string]any, promote bool) error {
+	appEntry := mainAppEntry
+	var prodAppEntry *types.AppEntry
+	if !mainAppEntry.IsDev {
+		prodAppEntry = mainAppEntry
+		var err error
+		if appEntry, err = s.getStageApp(ctx, tx, mainAppEntry); err != nil {
+			return err
+		}
+		if promote {
+			checkPendingPromote(ctx, appEntry, prodAppEntry, false)
+		}
+		stagingFileStore, err := metadata.NewFileStore(appEntry.Id, appEntry.Metadata.VersionMetadata.Version, s.db, tx)
+		if err != nil {
+			return fmt.Errorf("error initializing staging file store: %w", err)
+		}
+		if err := stagingFileStore.IncrementAppVersion(ctx, tx, &appEntry.Metadata); err != nil {
+			return fmt.Errorf("error incrementing app version: %w", err)
+		}
+	}
+
+	current := patchableMetadata{
+		AuthnType:        appEntry.Metadata.AuthnType,
+		GitAuthName:      appEntry.Metadata.GitAuthName,
+		ContainerOptions: appEntry.Metadata.ContainerOptions,
+		ContainerArgs:    appEntry.Metadata.ContainerArgs,
+		ContainerVolumes: appEntry.Metadata.ContainerVolumes,
+		AppConfig:        appEntry.Metadata.AppConfig,
+	}
+	updated, err := mergePatchValue(current, patch)
+	if err != nil {
+		return err
+	}
+	if err := s.checkDeviceOptions(ctx, current.ContainerOptions, updated.ContainerOptions); err != nil {
+		return err
+	}
+	if updated.AuthnType != "" && updated.AuthnType != current.AuthnType {
+		if err := s.validateAppAuthnType(string(updated.AuthnType)); err != nil {
+			return err
+		}
+	}
+
+	appEntry.Metadata.AuthnType = updated.AuthnType
+	appEntry.Metadata.GitAuthName = updated.GitAuthName
+	appEntry.Metadata.ContainerOptions = updated.ContainerOptions
+	appEntry.Metadata.ContainerArgs = updated.ContainerArgs
+	appEntry.Metadata.ContainerVolumes = updated.ContainerVolumes
+	appEntry.Metadata.AppConfig = updated.AppConfig
+	if err := s.db.UpdateAppMetadata(ctx, tx, appEntry); err != nil {
+		return err
+	}
+
+	if promote && prodAppEntry != nil {
+		_, err = s.promoteApp(ctx, tx, appEntry, prodAppEntry)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	tests := []struct {
+		target, patch, expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":{"b":"c","d":"e"}}`, `{"a":{"b":null,"f":"g"}}`, `{"a":{"d":"e","f":"g"}}`},
+		{`{"a":["b"]}`, `{"a":["c","d"]}`, `{"a":["c","d"]}`},
+		{`{"a":"b"}`, `{"a":{"c":null}}`, `{"a":{}}`},
+		{`{"a":null}`, `{"a":{"b":"c"}}`, `{"a":{"b":"c"}}`},
+	}
+	for _, test := range tests {
+		var target, patch any
+		testutil.AssertNoError(t, json.Unmarshal([]byte(test.target), &target))
+		testutil.AssertNoError(t, json.Unmarshal([]byte(test.patch), &patch))
+		result, err := json.Marshal(applyMergePatch(target, patch))
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqualsString(t, test.patch, test.expected, string(result))
+	}
+}
+
+func TestSplitSettingsPatch(t *testing.T) {
+	settings, meta, err := splitSettingsPatch(map[string]any{
+		"stage_write_access": true,
+		"authn_type":         "oidc",
+		"container_options":  map[string]any{"cpus": "2"},
+	})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "settings", 1, len(settings))
+	testutil.AssertEqualsInt(t, "metadata", 2, len(meta))
+
+	_, _, err = splitSettingsPatch(map[string]any{"webhook_tokens": "x"})
+	testutil.AssertErrorContains(t, err, "unsupported setting webhook_tokens in patch")
+}
+
+func TestMergePatchValue(t *testing.T) {
+	current := patchableMetadata{
+		AuthnType:        "system",
+		ContainerOptions: map[string]string{"cpus": "1", "memory": "512m"},
+	}
+	updated, err := mergePatchValue(current, map[string]any{
+		"authn_type":        "none",
+		"container_options": map[string]any{"cpus": "2", "memory": nil},
+		"app_config":        map[string]any{"a.b": "c"},
+	})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "authn", "none", string(updated.AuthnType))
+	testutil.AssertEqualsString(t, "cpus", "2", updated.ContainerOptions["cpus"])
+	testutil.AssertEqualsInt(t, "options", 1, len(updated.ContainerOptions))
+	testutil.AssertEqualsString(t, "app config", "c", updated.AppConfig["a.b"])
+	testutil.AssertEqualsString(t, "current unchanged", "512m", current.ContainerOptions["memory"])
+
+	_, err = mergePatchValue(patchableSettings{}, map[string]any{"stage_write_access": "yes"})
+	testutil.AssertErrorContains(t, err, "invalid settings patch")
+}
@@ -0,0 +1,65 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+
+	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// AppStats returns the resource limits of an app container and the current usage of the app and
+// service containers on this server, along with the container quota totals. Usage is read using
+// the container manager stats, it is not available for Kubernetes
+func (s *Server) AppStats(ctx context.Context, appPath string) (*types.AppStatsResponse, error) {
+	appPathDomain, err := parseAppPath(appPath)
+	if err != nil {
+		return nil, err
+	}
+	application, err := s.GetApp(ctx, appPathDomain, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.enforceAppPermEntry(ctx, types.PermissionRead, application.AppEntry); err != nil {
+		return nil, err
+	}
+
+	ret := &types.AppStatsResponse{
+		AppPathDomain: appPathDomain,
+		Containers:    []types.AppContainerStats{},
+		TotalUsed:     s.resourceQuota.Used(),
+	}
+	ret.Limits, _ = application.ResourceLimits()
+	if _, ret.TotalQuota, err = app.ParseResourceQuota(s.Config().ContainerQuota); err != nil {
+		return nil, err
+	}
+
+	runtime := s.containerRuntime()
+	if runtime == "" || runtime == types.CONTAINER_KUBERNETES {
+		return ret, nil
+	}
+	names := []string{}
+	if name, ok := application.ActiveContainerName(); ok {
+		names = append(names, string(name))
+	}
+	for _, name := range application.ActiveServiceNames() {
+		names = append(names, string(name))
+	}
+	for _, name := range names {
+		stats := s.containerStats(ctx, runtime, name)
+		if stats == nil {
+			// The container is stopped, like after an idle shutdown
+			continue
+		}
+		ret.Containers = append(ret.Containers, types.AppContainerStats{
+			Name:       name,
+			CPUPercent: stats.CPUPercent,
+			MemUsage:   stats.MemUsage,
+			MemPercent: stats.MemPercent,
+			PIDs:       stats.PIDs,
+		})
+	}
+	return ret, nil
+}
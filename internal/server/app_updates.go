@@ -6,10 +6,12 @@ package server
 import (
 	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"maps"
 	"net/http"
 	"strings"
+	"sync"
 
 	apppkg "github.com/openrundev/openrun/internal/app"
 	"github.com/openrundev/openrun/internal/container"
@@ -32,6 +34,9 @@ func (s *Server) ReloadApp(ctx context.Context, tx types.Transaction, appEntry *
 				return nil, err
 			}
 		}
+		if promote {
+			checkPendingPromote(ctx, appEntry, prodAppEntry, approve)
+		}
 	}
 
 	var reloaded bool
@@ -104,7 +109,7 @@ func (s *Server) ReloadApp(ctx context.Context, tx types.Transaction, appEntry *
 
 	reloadResults = append(reloadResults, appEntry.AppPathDomain())
 	if promote && !appEntry.IsDev {
-		if err = s.promoteApp(ctx, tx, appEntry, prodAppEntry); err != nil {
+		if _, err = s.promoteApp(ctx, tx, appEntry, prodAppEntry); err != nil {
 			return nil, err
 		}
 		promoteResults = append(promoteResults, appEntry.AppPathDomain())
@@ -164,6 +169,14 @@ func (s *Server) ReloadApps(ctx context.Context, appPathGlob string, approve, dr
 		}
 	}
 
+	if !dryRun {
+		unlock, err := s.lockAppInfos(ctx, "reload_apps", filteredApps)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
 	// The repo cache is shared between the image pre-build pass and the main
 	// reload loop, so each git repo is checked out only once.
 	repoCache, err := NewRepoCache(s)
@@ -264,27 +277,63 @@ func (s *Server) ReloadApps(ctx context.Context, appPathGlob string, approve, dr
 // image builds run.
 func (s *Server) preBuildReloadImages(ctx context.Context, filteredApps []types.AppInfo, approve bool,
 	branch, commit, gitAuth string, repoCache *RepoCache, forceReload bool) error {
+	appPaths := make([]types.AppPathDomain, 0, len(filteredApps))
 	for _, appInfo := range filteredApps {
 		if appInfo.IsDev {
 			// Dev apps build through DevReload from local disk, not pre-built
 			continue
 		}
-		application, plan, err := s.prepareAppImage(ctx, appInfo.AppPathDomain, approve, branch, commit, gitAuth, repoCache, forceReload)
-		if err != nil {
-			return err
-		}
-		if application == nil {
+		appPaths = append(appPaths, appInfo.AppPathDomain)
+	}
+	return s.preBuildAppImages(ctx, appPaths, approve, branch, commit, gitAuth, repoCache, forceReload)
+}
+
+// preBuildAppImages builds the container images for the apps, with the same branch and commit
+// for all the apps. Empty values use the app's current branch
+func (s *Server) preBuildAppImages(ctx context.Context, appPaths []types.AppPathDomain, approve bool,
+	branch, commit, gitAuth string, repoCache *RepoCache, forceReload bool) error {
+	return s.preBuildImages(ctx, appPaths, func(appPath types.AppPathDomain) (*apppkg.App, *apppkg.BuildPlan, error) {
+		return s.prepareAppImage(ctx, appPath, approve, branch, commit, gitAuth, repoCache, forceReload)
+	})
+}
+
+// preBuildImages prepares and builds the container images for the apps. The prepare step uses a
+// throwaway transaction, it runs one app at a time. The image builds run concurrently, up to
+// system.apply_concurrency and max_concurrent_builds builds, so more builds would just wait on the
+// build lock. A failure for one app does not stop the builds for the other apps, the errors are
+// returned in the app order
+func (s *Server) preBuildImages(ctx context.Context, appPaths []types.AppPathDomain,
+	prepare func(types.AppPathDomain) (*apppkg.App, *apppkg.BuildPlan, error)) error {
+	config := s.Config().System
+	workers := make(chan struct{}, max(min(config.ApplyConcurrency, config.MaxConcurrentBuilds), 1))
+	errs := make([]error, len(appPaths))
+	var wg sync.WaitGroup
+	for i, appPath := range appPaths {
+		// Wait for a worker before preparing, so that at most workers apps have their build
+		// inputs extracted at a time
+		workers <- struct{}{}
+		application, plan, err := prepare(appPath)
+		if err != nil || application == nil {
+			<-workers
+			errs[i] = err
 			continue
 		}
-		// The throwaway transaction is closed at this point; the build reads
-		// only from the temp source dir captured in the plan
-		buildErr := application.ExecuteContainerBuild(ctx, plan)
-		application.Close() //nolint:errcheck // throwaway app object, stop its background tickers
-		if buildErr != nil {
-			return buildErr
-		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-workers }()
+			// The throwaway transaction is closed at this point; the build reads
+			// only from the temp source dir captured in the plan
+			buildErr := application.ExecuteContainerBuild(ctx, plan)
+			application.Close() //nolint:errcheck // throwaway app object, stop its background tickers
+			if buildErr != nil {
+				errs[i] = fmt.Errorf("error building image for app %s: %w", appPath, buildErr)
+			}
+		}()
 	}
-	return nil
+	wg.Wait()
+	return errors.Join(errs...)
 }
 
 // prepareAppImage loads the new app source for one app under a throwaway
@@ -361,9 +410,16 @@ func (s *Server) loadAppCode(ctx context.Context, tx types.Transaction, appEntry
 			return false, nil
 		}
 
-		branch = cmp.Or(branch, appEntry.Metadata.VersionMetadata.GitBranch, "main")
 		gitAuth = cmp.Or(gitAuth, appEntry.Metadata.GitAuthName)
-		newSha, err := repoCache.GetSha(appEntry.SourceUrl, branch, gitAuth)
+		var newSha string
+		var err error
+		if followsGitTag(appEntry, branch, commit) {
+			// The latest tag matching the app tag is deployed, the branch is left empty
+			_, newSha, err = repoCache.ResolveTag(appEntry.SourceUrl, appEntry.Metadata.VersionMetadata.GitTag, gitAuth)
+		} else {
+			branch = cmp.Or(branch, appEntry.Metadata.VersionMetadata.GitBranch, "main")
+			newSha, err = repoCache.GetSha(appEntry.SourceUrl, branch, gitAuth)
+		}
 		if err != nil {
 			return false, fmt.Errorf("error getting git commit sha for %s: %w", appEntry.SourceUrl, err)
 		}
@@ -377,6 +433,21 @@ func (s *Server) loadAppCode(ctx context.Context, tx types.Transaction, appEntry
 		if err := s.loadSourceFromGit(ctx, tx, appEntry, branch, commit, gitAuth, repoCache); err != nil {
 			return false, err
 		}
+	} else if system.IsPackaged(appEntry.SourceUrl) {
+		// OCI artifacts are pulled by the digest the tag points to currently
+		_, newSha, err := repoCache.FetchPackagedSource(ctx, appEntry.SourceUrl)
+		if err != nil {
+			return false, err
+		}
+		currentSha := cmp.Or(appEntry.Metadata.VersionMetadata.SourceArchiveSha, appEntry.Metadata.VersionMetadata.OCIDigest)
+		if !forceReload && currentSha != "" && newSha == currentSha {
+			// The archive or artifact contents have not changed, skip reload
+			s.Debug().Msgf("App %s already at source digest %s, skipping reload", appEntry.AppPathDomain(), newSha)
+			return false, nil
+		}
+		if err := s.loadSourceFromPackage(ctx, tx, appEntry, repoCache); err != nil {
+			return false, err
+		}
 	} else {
 		// App is loaded from disk (not git), load files into DB
 		if err := s.loadSourceFromDisk(ctx, tx, appEntry); err != nil {
@@ -466,6 +537,10 @@ func (s *Server) StagedUpdateAppsTx(ctx context.Context, tx types.Transaction, a
 			if err != nil {
 				return nil, nil, nil, err
 			}
+			if promote {
+				approve, _ := args["approve"].(bool)
+				checkPendingPromote(ctx, appEntry, prodAppEntry, approve)
+			}
 
 			stagingFileStore, err := metadata.NewFileStore(appEntry.Id, appEntry.Metadata.VersionMetadata.Version, s.db, tx)
 			if err != nil {
@@ -487,7 +562,7 @@ func (s *Server) StagedUpdateAppsTx(ctx context.Context, tx types.Transaction, a
 		}
 
 		if promote && prodAppEntry != nil {
-			if err = s.promoteApp(ctx, tx, appEntry, prodAppEntry); err != nil {
+			if _, err = s.promoteApp(ctx, tx, appEntry, prodAppEntry); err != nil {
 				return nil, nil, nil, err
 			}
 
@@ -524,7 +599,8 @@ func (s *Server) auditHandler(ctx context.Context, tx types.Transaction, appEntr
 // ApproveApps approves the plugin and permission usage for apps matching the
 // glob. With dryRun, the pending permissions are returned without approving
 func (s *Server) ApproveApps(ctx context.Context, appPathGlob string, dryRun, promote bool) (*types.AppStagedUpdateResponse, error) {
-	return s.StagedUpdate(ctx, appPathGlob, dryRun, promote, s.auditHandler, map[string]any{}, "approve")
+	// approve is not used by the handler, it marks the permission changes as approved for the promote
+	return s.StagedUpdate(ctx, appPathGlob, dryRun, promote, s.auditHandler, map[string]any{"approve": true}, "approve")
 }
 
 // UpdateAppParams updates a single param value for apps matching the glob.
@@ -554,7 +630,11 @@ func (s *Server) replaceParamsHandler(ctx context.Context, tx types.Transaction,
 	return appPathDomain, appPathDomain, nil
 }
 
-func (s *Server) PromoteApps(ctx context.Context, appPathGlob string, dryRun bool) (*types.AppPromoteResponse, error) {
+// PromoteApps promotes the stage apps to prod for apps matching the glob. The changes to each prod
+// app are returned in the response. When there are permission or container changes, promote fails
+// unless confirm is set, a dry run can be used to review the changes
+func (s *Server) PromoteApps(ctx context.Context, appPathGlob string, dryRun, contractCheck, confirm bool) (*types.AppPromoteResponse, error) {
+	ctx = withPromoteState(ctx, confirm || dryRun)
 	filteredApps, err := s.FilterApps(appPathGlob, false)
 	if err != nil {
 		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
@@ -564,6 +644,26 @@ func (s *Server) PromoteApps(ctx context.Context, appPathGlob string, dryRun boo
 		return nil, err
 	}
 
+	if !dryRun {
+		unlock, err := s.lockAppInfos(ctx, "promote_apps", filteredApps)
+		if err != nil {
+			return nil, err
+		}
+		defer unlock()
+	}
+
+	if contractCheck {
+		// Verify the backends of all the stage apps before any app is promoted
+		for _, appInfo := range filteredApps {
+			if !strings.HasPrefix(string(appInfo.Id), types.ID_PREFIX_APP_PROD) {
+				continue
+			}
+			if err := s.checkContractBeforePromote(ctx, appInfo.AppPathDomain); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	tx, err := s.db.BeginTransaction(ctx)
 	if err != nil {
 		return nil, err
@@ -571,6 +671,7 @@ func (s *Server) PromoteApps(ctx context.Context, appPathGlob string, dryRun boo
 	defer tx.Rollback() //nolint:errcheck
 
 	result := make([]types.AppPathDomain, 0, len(filteredApps))
+	diffs := make([]types.AppPromoteDiff, 0, len(filteredApps))
 	for _, appInfo := range filteredApps {
 		// FilterApps returns only main apps; skip anything that is not a prod app
 		if !strings.HasPrefix(string(appInfo.Id), types.ID_PREFIX_APP_PROD) {
@@ -587,9 +688,11 @@ func (s *Server) PromoteApps(ctx context.Context, appPathGlob string, dryRun boo
 			return nil, err
 		}
 
-		if err = s.promoteApp(ctx, tx, stagingApp, prodAppEntry); err != nil {
+		diff, err := s.promoteApp(ctx, tx, stagingApp, prodAppEntry)
+		if err != nil {
 			return nil, err
 		}
+		diffs = append(diffs, *diff)
 
 		prodApp, err := s.setupApp(ctx, prodAppEntry, tx)
 		if err != nil {
@@ -598,7 +701,7 @@ func (s *Server) PromoteApps(ctx context.Context, appPathGlob string, dryRun boo
 		if _, err := prodApp.Reload(ctx, true, true, types.DryRun(dryRun), apppkg.ReloadOptions{ReloadContainer: false, Verify: false}); err != nil {
 			return nil, fmt.Errorf("error reloading prod app %s: %w", prodApp.AppEntry, err)
 		}
-		result = append(result, appInfo.AppPathDomain)
+		result = append(result, prodAppEntry.AppPathDomain())
 	}
 
 	if err = s.CompleteTransaction(ctx, tx, result, dryRun, "promote"); err != nil {
@@ -607,17 +710,32 @@ func (s *Server) PromoteApps(ctx context.Context, appPathGlob string, dryRun boo
 
 	return &types.AppPromoteResponse{
 		DryRun:         dryRun,
-		PromoteResults: result}, nil
+		PromoteResults: result,
+		Diffs:          diffs}, nil
 }
 
-func (s *Server) promoteApp(ctx context.Context, tx types.Transaction, stagingApp *types.AppEntry, prodApp *types.AppEntry) error {
+// promoteApp copies the stage app metadata and files to the prod app. The changes made to the prod
+// app are returned. If the changes need a confirmation (permission, env or container changes), the
+// promote fails unless the operation context has the promote confirmed or the changes were made by
+// the operation itself (see checkPendingPromote). The diff is recorded for the audit log, which is
+// updated once the operation's transaction commits
+func (s *Server) promoteApp(ctx context.Context, tx types.Transaction, stagingApp *types.AppEntry, prodApp *types.AppEntry) (*types.AppPromoteDiff, error) {
+	// The diff is computed before the promote, which overwrites the prod metadata
+	diff, err := s.promoteDiff(ctx, tx, stagingApp, prodApp)
+	if err != nil {
+		return nil, err
+	}
+	if diff.ConfirmRequired && !isPromoteConfirmed(ctx) && !isOwnPromoteChange(ctx, prodApp.Id) {
+		return nil, promoteConfirmError(diff)
+	}
+
 	stagingFileStore, err := metadata.NewFileStore(stagingApp.Id, stagingApp.Metadata.VersionMetadata.Version, s.db, tx)
 	if err != nil {
-		return fmt.Errorf("error initializing staging file store: %w", err)
+		return nil, fmt.Errorf("error initializing staging file store: %w", err)
 	}
 	prodFileStore, err := metadata.NewFileStore(prodApp.Id, prodApp.Metadata.VersionMetadata.Version, s.db, tx)
 	if err != nil {
-		return fmt.Errorf("error initializing prod file store: %w", err)
+		return nil, fmt.Errorf("error initializing prod file store: %w", err)
 	}
 	prevVersion := prodApp.Metadata.VersionMetadata.Version
 	newVersion := stagingApp.Metadata.VersionMetadata.Version
@@ -632,23 +750,46 @@ func (s *Server) promoteApp(ctx context.Context, tx types.Transaction, stagingAp
 
 		if existingProdVersion == nil {
 			if err := stagingFileStore.PromoteApp(ctx, tx, prodApp.Id, &prodApp.Metadata); err != nil {
-				return err
+				return nil, err
 			}
 		}
 	}
 
 	// Even if there is no version change, promotion is done to update other metadata settings like account links
 	if err := s.db.UpdateAppMetadata(ctx, tx, prodApp); err != nil {
-		return err
+		return nil, err
+	}
+	recordPromoteDiff(ctx, prodApp, diff)
+	return diff, nil
+}
+
+// checkIfVersion checks that the glob matched one app, for updates which are conditional on the
+// app row version read earlier by the client
+func checkIfVersion(filteredApps []types.AppInfo, ifVersion int64) error {
+	if ifVersion >= 0 && len(filteredApps) != 1 {
+		return types.CreateRequestError(fmt.Sprintf("ifVersion requires the app path to match one app, matched %d", len(filteredApps)),
+			http.StatusBadRequest)
 	}
 	return nil
 }
 
-func (s *Server) UpdateAppSettings(ctx context.Context, appPathGlob string, dryRun bool, updateAppRequest types.UpdateAppRequest) (*types.AppUpdateSettingsResponse, error) {
+// checkAppVersion returns a version conflict error if the app was updated since the client read
+// the app. A negative ifVersion skips the check
+func checkAppVersion(appEntry *types.AppEntry, ifVersion int64) error {
+	if ifVersion >= 0 && appEntry.RowVersion != ifVersion {
+		return types.CreateVersionConflictError(appEntry.String(), ifVersion, appEntry.RowVersion)
+	}
+	return nil
+}
+
+func (s *Server) UpdateAppSettings(ctx context.Context, appPathGlob string, dryRun bool, ifVersion int64, updateAppRequest types.UpdateAppRequest) (*types.AppUpdateSettingsResponse, error) {
 	filteredApps, err := s.FilterApps(appPathGlob, false)
 	if err != nil {
 		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
 	}
+	if err := checkIfVersion(filteredApps, ifVersion); err != nil {
+		return nil, err
+	}
 
 	if err := s.enforceAppPermInfos(ctx, types.PermissionUpdate, filteredApps); err != nil {
 		return nil, err
@@ -662,10 +803,13 @@ func (s *Server) UpdateAppSettings(ctx context.Context, appPathGlob string, dryR
 
 	results := make([]types.AppPathDomain, 0, len(filteredApps))
 	for _, appInfo := range filteredApps {
-		_, err := s.GetAppEntry(ctx, tx, appInfo.AppPathDomain)
+		appEntry, err := s.GetAppEntry(ctx, tx, appInfo.AppPathDomain)
 		if err != nil {
 			return nil, fmt.Errorf("error getting prod app %s: %w", appInfo, err)
 		}
+		if err := checkAppVersion(appEntry, ifVersion); err != nil {
+			return nil, err
+		}
 
 		appResults, err := s.updateAppSettings(ctx, tx, appInfo.AppPathDomain, updateAppRequest)
 		if err != nil {
@@ -791,7 +935,15 @@ func (s *Server) updateParamHandler(ctx context.Context, tx types.Transaction, a
 		appEntry.Metadata.ParamValues[paramName] = paramValue
 	}
 
+	// Check the values against the params.star definitions now, instead of failing when the app is loaded
 	appPathDomain := appEntry.AppPathDomain()
+	app, err := s.setupApp(ctx, appEntry, tx)
+	if err != nil {
+		return nil, appPathDomain, err
+	}
+	if err := app.ValidateParams(); err != nil {
+		return nil, appPathDomain, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+	}
 	return appPathDomain, appPathDomain, nil
 }
 
@@ -873,6 +1025,7 @@ func (s *Server) updateAppMetadataConfig(ctx context.Context, tx types.Transacti
 		return nil
 	}
 
+	oldContOptions := maps.Clone(appEntry.Metadata.ContainerOptions)
 	for _, entry := range configEntries {
 		key, value, ok := strings.Cut(entry, "=")
 		if !ok {
@@ -918,5 +1071,8 @@ func (s *Server) updateAppMetadataConfig(ctx context.Context, tx types.Transacti
 		}
 	}
 
+	if configType == types.AppMetadataContainerOptions {
+		return s.checkDeviceOptions(ctx, oldContOptions, appEntry.Metadata.ContainerOptions)
+	}
 	return nil
 }
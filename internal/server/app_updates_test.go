@@ -4,11 +4,14 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	apppkg "github.com/openrundev/openrun/internal/app"
 	"github.com/openrundev/openrun/internal/types"
 )
 
@@ -89,3 +92,25 @@ func TestReloadAppsImagePreBuildStep(t *testing.T) {
 		})
 	}
 }
+
+// TestPreBuildImagesErrors checks that a prepare failure for one app does not
+// stop the other apps and that the errors are returned in the app order
+func TestPreBuildImagesErrors(t *testing.T) {
+	server := &Server{staticConfig: &types.ServerConfig{System: types.SystemConfig{ApplyConcurrency: 4, MaxConcurrentBuilds: 2}}}
+	appPaths := []types.AppPathDomain{{Path: "/a"}, {Path: "/b"}, {Path: "/c"}, {Path: "/d"}}
+	prepared := []string{}
+	err := server.preBuildImages(context.Background(), appPaths, func(appPath types.AppPathDomain) (*apppkg.App, *apppkg.BuildPlan, error) {
+		prepared = append(prepared, appPath.Path)
+		if appPath.Path == "/b" || appPath.Path == "/d" {
+			return nil, nil, fmt.Errorf("prepare failed for %s", appPath.Path)
+		}
+		// No build required for the app
+		return nil, nil, nil
+	})
+	if got := strings.Join(prepared, ","); got != "/a,/b,/c,/d" {
+		t.Errorf("prepared apps: got %s", got)
+	}
+	if err == nil || err.Error() != "prepare failed for /b\nprepare failed for /d" {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
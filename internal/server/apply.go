@@ -119,6 +119,14 @@ func appDefToApplyInfo(appDef *starlarkstruct.Struct) (*types.CreateAppRequest,
 	if err != nil {
 		return nil, err
 	}
+	gitTag, err := apptype.GetStringAttr(appDef, "git_tag")
+	if err != nil {
+		return nil, err
+	}
+	sourceChecksum, err := apptype.GetStringAttr(appDef, "source_checksum")
+	if err != nil {
+		return nil, err
+	}
 	params, err := apptype.GetDictAttr(appDef, "params", true)
 	if err != nil {
 		return nil, err
@@ -178,6 +186,8 @@ func appDefToApplyInfo(appDef *starlarkstruct.Struct) (*types.CreateAppRequest,
 		GitAuthName:      gitAuth,
 		GitBranch:        gitBranch,
 		GitCommit:        gitCommit,
+		GitTag:           gitTag,
+		SourceChecksum:   sourceChecksum,
 		Spec:             types.AppSpec(spec),
 		AppConfig:        appConfigStr,
 		ContainerOptions: containerOptsStr,
@@ -190,6 +200,9 @@ func appDefToApplyInfo(appDef *starlarkstruct.Struct) (*types.CreateAppRequest,
 }
 
 func (s *Server) setupSource(applyPath, branch, commit, gitAuth string, repoCache *RepoCache, isDev bool) (string, string, error) {
+	if system.IsPackaged(applyPath) {
+		return "", "", fmt.Errorf("%s is not supported as the apply path, use a git url or a local file", redactArchiveUrl(applyPath))
+	}
 	if !system.IsGit(applyPath) {
 		return filepath.Dir(applyPath), filepath.Base(applyPath), nil
 	}
@@ -386,6 +399,15 @@ func (s *Server) Apply(ctx context.Context, inputTx types.Transaction, applyPath
 		verifyRequested = verifyRequested || applyConfig[appPathDomain].Verify
 		filteredApps = append(filteredApps, appPathDomain)
 	}
+	// The apps are processed in path order, so the results are in the same order for every run
+	slices.SortFunc(filteredApps, compareAppPathDomain)
+	if !dryRun {
+		unlock, err := s.lockApps(ctx, "apply", filteredApps)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer unlock()
+	}
 	s.prefetchApplyAppSources(applyConfig, filteredApps, repoCache, isDev)
 
 	updateResults := make([]types.AppPathDomain, 0, len(filteredApps))
@@ -451,6 +473,12 @@ func (s *Server) Apply(ctx context.Context, inputTx types.Transaction, applyPath
 		}
 	}
 
+	if inputTx.Tx == nil && reload == types.AppReloadOptionMatched && verifyRequested && !dryRun && s.Config().System.UseImagePreBuildStep {
+		// The apply transaction has not been used yet, so the throwaway transactions used by
+		// the pre-build do not wait on it
+		s.preBuildApplyImages(ctx, applyConfig, updatedApps, verify, approve, repoCache, forceReload)
+	}
+
 	// Get list of all bindings in the database: the apply diff must see every
 	// binding (declared ones are permission-checked individually below)
 	allBindings, err := s.listBindingsInternal(ctx, "")
@@ -585,7 +613,7 @@ func (s *Server) Apply(ctx context.Context, inputTx types.Transaction, applyPath
 	for _, app := range allUpdatedApps {
 		allAppMap[app] = true
 	}
-	allUpdatedApps = slices.Collect(maps.Keys(allAppMap))
+	allUpdatedApps = slices.SortedFunc(maps.Keys(allAppMap), compareAppPathDomain)
 
 	if inputTx.Tx == nil {
 		if err := s.CompleteTransaction(ctx, tx, allUpdatedApps, dryRun, "apply"); err != nil {
@@ -619,6 +647,35 @@ func (s *Server) Apply(ctx context.Context, inputTx types.Transaction, applyPath
 	return ret, allUpdatedApps, nil
 }
 
+func compareAppPathDomain(a, b types.AppPathDomain) int {
+	if c := cmp.Compare(a.Domain, b.Domain); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.Path, b.Path)
+}
+
+// preBuildApplyImages builds the container images for the existing apps reloaded with verify by
+// the apply, before the apply transaction is used. The branch and commit are from the apply
+// config. This only warms the image cache, errors are reported when the app is reloaded
+func (s *Server) preBuildApplyImages(ctx context.Context, applyConfig map[types.AppPathDomain]*types.CreateAppRequest,
+	updatedApps []types.AppPathDomain, verify, approve bool, repoCache *RepoCache, forceReload bool) {
+	appPaths := make([]types.AppPathDomain, 0, len(updatedApps))
+	for _, appPath := range updatedApps {
+		applyInfo := applyConfig[appPath]
+		if !applyInfo.IsDev && (verify || applyInfo.Verify) {
+			appPaths = append(appPaths, appPath)
+		}
+	}
+	err := s.preBuildImages(ctx, appPaths, func(appPath types.AppPathDomain) (*apppkg.App, *apppkg.BuildPlan, error) {
+		applyInfo := applyConfig[appPath]
+		return s.prepareAppImage(ctx, appPath, approve, applyInfo.GitBranch, applyInfo.GitCommit, applyInfo.GitAuthName,
+			repoCache, forceReload)
+	})
+	if err != nil {
+		s.Debug().Err(err).Msg("image pre-build: error building apply images")
+	}
+}
+
 func convertToMapString(input map[string]any, convertToml bool) (map[string]string, error) {
 	ret := make(map[string]string)
 	for k, v := range input {
@@ -661,6 +718,9 @@ func (s *Server) applyAppUpdate(ctx context.Context, tx types.Transaction, appPa
 	approve, dryRun, promote bool, reload types.AppReloadOption, clobber bool, repoCache *RepoCache, forceReload, verify bool,
 	bindingAccounts *bindingAccountManager) (*types.AppApplyResult, error) {
 	verify = verify && !dryRun
+	if err := validateGitTagRequest(newInfo); err != nil {
+		return nil, err
+	}
 	liveApp, err := s.GetAppEntry(ctx, tx, appPathDomain)
 	if err != nil {
 		return nil, fmt.Errorf("app missing during update %w", err)
@@ -673,6 +733,9 @@ func (s *Server) applyAppUpdate(ctx context.Context, tx types.Transaction, appPa
 		if err != nil {
 			return nil, err
 		}
+		if promote {
+			checkPendingPromote(ctx, liveApp, prodApp, approve)
+		}
 	}
 
 	oldInfoStr := string(liveApp.Metadata.VersionMetadata.ApplyInfo)
@@ -739,6 +802,18 @@ func (s *Server) applyAppUpdate(ctx context.Context, tx types.Transaction, appPa
 			liveApp.Metadata.VersionMetadata.GitCommit = newInfo.GitCommit
 		}
 	}
+	gitTagChanged := checkPropertyChanged(oldInfo, func(info *types.CreateAppRequest) any {
+		return info.GitTag
+	}, newInfo.GitTag, liveApp.Metadata.VersionMetadata.GitTag, clobber)
+	if gitTagChanged {
+		liveApp.Metadata.VersionMetadata.GitTag = newInfo.GitTag
+	}
+	sourceChecksumChanged := checkPropertyChanged(oldInfo, func(info *types.CreateAppRequest) any {
+		return info.SourceChecksum
+	}, newInfo.SourceChecksum, liveApp.Metadata.VersionMetadata.SourceChecksum, clobber)
+	if sourceChecksumChanged {
+		liveApp.Metadata.VersionMetadata.SourceChecksum = newInfo.SourceChecksum
+	}
 
 	var oldParams map[string]string
 	if oldInfo != nil {
@@ -750,7 +825,13 @@ func (s *Server) applyAppUpdate(ctx context.Context, tx types.Transaction, appPa
 	if oldInfo != nil {
 		oldContOptions = oldInfo.ContainerOptions
 	}
+	liveContOptions := maps.Clone(liveApp.Metadata.ContainerOptions)
 	contConfigChanged := mergeMap(oldContOptions, newInfo.ContainerOptions, liveApp.Metadata.ContainerOptions, clobber)
+	if contConfigChanged {
+		if err := s.checkDeviceOptions(ctx, liveContOptions, liveApp.Metadata.ContainerOptions); err != nil {
+			return nil, err
+		}
+	}
 
 	var oldContArgs map[string]string
 	if oldInfo != nil {
@@ -776,9 +857,25 @@ func (s *Server) applyAppUpdate(ctx context.Context, tx types.Transaction, appPa
 	}
 	bindingsChanged := mergeSlice(oldBindings, newInfo.Bindings, &liveApp.Metadata.Bindings, clobber)
 
+	if promote && !liveApp.IsDev {
+		// The container settings which match the apply file are declared, promoting them does not
+		// need a confirmation even if they were pending on the stage app
+		var declared []string
+		if maps.Equal(liveApp.Metadata.ContainerOptions, newInfo.ContainerOptions) {
+			declared = append(declared, "container_options")
+		}
+		if maps.Equal(liveApp.Metadata.ContainerArgs, newInfo.ContainerArgs) {
+			declared = append(declared, "container_args")
+		}
+		if slices.Equal(liveApp.Metadata.ContainerVolumes, newInfo.ContainerVolumes) {
+			declared = append(declared, "container_volumes")
+		}
+		declarePromoteKinds(ctx, prodApp.Id, declared...)
+	}
+
 	var approvalResult *types.ApproveResult
 
-	updated := specChanged || gitBranchChanged || gitCommitChanged || paramsChanged ||
+	updated := specChanged || gitBranchChanged || gitCommitChanged || gitTagChanged || sourceChecksumChanged || paramsChanged ||
 		contConfigChanged || contArgsChanged || contVolsChanged || appConfigChanged || authChanged || gitAuthChanged || bindingsChanged
 	updatedApps := make([]types.AppPathDomain, 0)
 	if updated {
@@ -830,7 +927,7 @@ func (s *Server) applyAppUpdate(ctx context.Context, tx types.Transaction, appPa
 			return nil, err
 		}
 		if promote && !liveApp.IsDev {
-			if err = s.promoteApp(ctx, tx, liveApp, prodApp); err != nil {
+			if _, err = s.promoteApp(ctx, tx, liveApp, prodApp); err != nil {
 				return nil, err
 			}
 			promoteApp = true
@@ -1242,7 +1339,7 @@ func (s *Server) builtinsForApply(applyDev bool) (*applyBuiltins, error) {
 		var path, source starlark.String
 		var dev, verify starlark.Bool
 		var params = starlark.NewDict(0)
-		var auth, gitAuth, gitBranch, gitCommit, appSpec, stageAt starlark.String
+		var auth, gitAuth, gitBranch, gitCommit, gitTag, sourceChecksum, appSpec, stageAt starlark.String
 		var appConfig = starlark.NewDict(0)
 		var containerOpts = starlark.NewDict(0)
 		var containerArgs = starlark.NewDict(0)
@@ -1251,7 +1348,7 @@ func (s *Server) builtinsForApply(applyDev bool) (*applyBuiltins, error) {
 
 		if err := starlark.UnpackArgs(APP, args, kwargs, "path", &path, "source", &source, "dev?", &dev,
 			"auth?", &auth, "git_auth?", &gitAuth, "git_branch?", &gitBranch, "git_commit?", &gitCommit,
-			"params?", &params, "spec?", &appSpec, "stage_at?", &stageAt, "app_config", &appConfig,
+			"git_tag?", &gitTag, "source_checksum?", &sourceChecksum, "params?", &params, "spec?", &appSpec, "stage_at?", &stageAt, "app_config", &appConfig,
 			"container_opts?", &containerOpts, "container_args?", &containerArgs, "container_vols?", &containerVols,
 			"bindings?", &bindings, "verify?", &verify,
 		); err != nil {
@@ -1259,22 +1356,24 @@ func (s *Server) builtinsForApply(applyDev bool) (*applyBuiltins, error) {
 		}
 
 		fields := starlark.StringDict{
-			"path":           path,
-			"source":         source,
-			"dev":            cmp.Or(dev, starlark.Bool(applyDev)),
-			"auth":           auth,
-			"git_auth":       gitAuth,
-			"git_branch":     gitBranch,
-			"git_commit":     gitCommit,
-			"params":         params,
-			"spec":           appSpec,
-			"stage_at":       stageAt,
-			"app_config":     appConfig,
-			"container_opts": containerOpts,
-			"container_args": containerArgs,
-			"container_vols": containerVols,
-			"bindings":       bindings,
-			"verify":         verify,
+			"path":            path,
+			"source":          source,
+			"dev":             cmp.Or(dev, starlark.Bool(applyDev)),
+			"auth":            auth,
+			"git_auth":        gitAuth,
+			"git_branch":      gitBranch,
+			"git_commit":      gitCommit,
+			"git_tag":         gitTag,
+			"source_checksum": sourceChecksum,
+			"params":          params,
+			"spec":            appSpec,
+			"stage_at":        stageAt,
+			"app_config":      appConfig,
+			"container_opts":  containerOpts,
+			"container_args":  containerArgs,
+			"container_vols":  containerVols,
+			"bindings":        bindings,
+			"verify":          verify,
 		}
 
 		appStruct := starlarkstruct.FromStringDict(starlark.String(APP), fields)
@@ -0,0 +1,236 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/metadata"
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/segmentio/ksuid"
+)
+
+// PlanApply runs the apply in dry run mode and saves the result as a plan, to be reviewed and
+// executed later using ExecuteApplyPlan
+func (s *Server) PlanApply(ctx context.Context, applyPath, appPathGlob string, options types.ApplyPlanOptions,
+	repoCache *RepoCache) (*types.ApplyPlan, error) {
+	response, _, err := s.Apply(ctx, types.Transaction{}, applyPath, appPathGlob, options.Approve, true, options.Promote,
+		options.Reload, options.Branch, options.Commit, options.GitAuth, options.Clobber, options.ForceReload,
+		options.Verify, "", repoCache, options.Dev)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	appVersions, err := s.applyPlanAppVersions(ctx, tx, response.FilteredApps)
+	if err != nil {
+		return nil, err
+	}
+	plan := &types.ApplyPlan{
+		Id:          types.ID_PREFIX_APPLY_PLAN + strings.ToLower(ksuid.New().String()),
+		CreateTime:  time.Now(),
+		UserId:      system.GetContextUserId(ctx),
+		ApplyPath:   applyPath,
+		AppPathGlob: appPathGlob,
+		Options:     options,
+		AppVersions: appVersions,
+		Response:    *response,
+		Status:      types.ApplyPlanPending,
+	}
+	if err := s.db.InsertApplyPlan(ctx, tx, plan); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// applyPlanAppVersions returns the row version of the existing apps, and their stage apps. Apps
+// which are created by the apply are skipped
+func (s *Server) applyPlanAppVersions(ctx context.Context, tx types.Transaction, appPaths []types.AppPathDomain) (map[string]int64, error) {
+	ret := map[string]int64{}
+	for _, appPath := range appPaths {
+		appEntry, err := s.GetAppEntry(ctx, tx, appPath)
+		if errors.Is(err, metadata.ErrAppNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		ret[appPath.String()] = appEntry.RowVersion
+		if appEntry.IsDev {
+			continue
+		}
+		stageApp, err := s.getStageApp(ctx, tx, appEntry)
+		if err != nil {
+			return nil, err
+		}
+		ret[stageApp.AppPathDomain().String()] = stageApp.RowVersion
+	}
+	return ret, nil
+}
+
+// ExecuteApplyPlan runs the apply for a plan, with the options and apply file commit used for the
+// plan. The apply fails without any change if the apps were updated after the plan was created or
+// if the changes done by the apply differ from the plan. A plan can be executed only once. The plan
+// is returned if it was loaded, even if the apply failed
+func (s *Server) ExecuteApplyPlan(ctx context.Context, planId string, repoCache *RepoCache) (_ *types.AppApplyResponse, _ *types.ApplyPlan, retErr error) {
+	plan, err := s.getApplyPlan(ctx, planId)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The plan is returned with the errors after this, for the apply notification
+	options := plan.Options
+	commit := cmp.Or(plan.Response.CommitId, options.Commit)
+
+	// Check out the apply source before the transaction is opened, Apply then finds it in the repo cache
+	if _, _, _, _, err := s.checkoutApplySource(plan.ApplyPath, options.Branch, commit, options.GitAuth, "",
+		options.ForceReload, options.Reload, repoCache, options.Dev); err != nil {
+		return nil, plan, err
+	}
+
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return nil, plan, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	appVersions, err := s.applyPlanAppVersions(ctx, tx, plan.Response.FilteredApps)
+	if err != nil {
+		return nil, plan, err
+	}
+	for _, appPath := range slices.Sorted(maps.Keys(plan.AppVersions)) {
+		current, ok := appVersions[appPath]
+		if !ok {
+			return nil, plan, staleApplyPlanError(plan, fmt.Sprintf("app %s has been deleted", appPath))
+		}
+		if current != plan.AppVersions[appPath] {
+			return nil, plan, staleApplyPlanError(plan, fmt.Sprintf("app %s has been updated", appPath))
+		}
+	}
+
+	ctx, deployScope := s.beginDeployScope(ctx, true, false)
+	defer func() { retErr = deployScope.finish(ctx, retErr) }()
+
+	response, updatedApps, err := s.Apply(ctx, tx, plan.ApplyPath, plan.AppPathGlob, options.Approve, false, options.Promote,
+		options.Reload, options.Branch, commit, options.GitAuth, options.Clobber, options.ForceReload,
+		options.Verify, "", repoCache, options.Dev)
+	if err != nil {
+		return nil, plan, err
+	}
+	if drift := applyPlanDrift(&plan.Response, response); drift != "" {
+		return nil, plan, staleApplyPlanError(plan, drift)
+	}
+
+	now := time.Now()
+	plan.ExecuteTime = &now
+	plan.ExecutedBy = system.GetContextUserId(ctx)
+	if err := s.db.MarkApplyPlanExecuted(ctx, tx, plan); err != nil {
+		if errors.Is(err, metadata.ErrApplyPlanExecuted) {
+			return nil, plan, types.CreateRequestError(fmt.Sprintf("apply plan %s has already been executed", plan.Id), http.StatusConflict)
+		}
+		return nil, plan, err
+	}
+	if err := s.CompleteTransaction(ctx, tx, updatedApps, false, "apply"); err != nil {
+		return nil, plan, err
+	}
+	if err := deployScope.commit(ctx); err != nil {
+		return nil, plan, err
+	}
+	return response, plan, nil
+}
+
+func (s *Server) getApplyPlan(ctx context.Context, planId string) (*types.ApplyPlan, error) {
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	plan, err := s.db.GetApplyPlan(ctx, tx, planId)
+	if errors.Is(err, metadata.ErrApplyPlanNotFound) {
+		return nil, types.CreateRequestError(fmt.Sprintf("apply plan %s not found", planId), http.StatusNotFound)
+	} else if err != nil {
+		return nil, err
+	}
+	if plan.Status != types.ApplyPlanPending {
+		return nil, types.CreateRequestError(fmt.Sprintf("apply plan %s has already been executed", planId), http.StatusConflict)
+	}
+	return plan, nil
+}
+
+func staleApplyPlanError(plan *types.ApplyPlan, reason string) error {
+	return types.CreateRequestError(fmt.Sprintf("apply plan %s is stale, %s since the plan was created. No changes were applied, "+
+		"create a new plan", plan.Id, reason), http.StatusConflict)
+}
+
+// applyPlanDrift compares the changes done by the apply with the changes in the plan, returns the
+// difference or an empty string if the changes match
+func applyPlanDrift(planned, actual *types.AppApplyResponse) string {
+	createdPaths := func(results []types.AppCreateResponse) []types.AppPathDomain {
+		ret := make([]types.AppPathDomain, 0, len(results))
+		for _, result := range results {
+			ret = append(ret, result.AppPathDomain)
+		}
+		return ret
+	}
+	approvedPaths := func(results []types.ApproveResult) []types.AppPathDomain {
+		ret := make([]types.AppPathDomain, 0, len(results))
+		for _, result := range results {
+			ret = append(ret, result.AppPathDomain)
+		}
+		return ret
+	}
+
+	if planned.CommitId != actual.CommitId {
+		return fmt.Sprintf("the apply file commit changed from %s to %s", planned.CommitId, actual.CommitId)
+	}
+	for _, check := range []struct {
+		name            string
+		planned, actual []types.AppPathDomain
+	}{
+		{"created apps", createdPaths(planned.CreateResults), createdPaths(actual.CreateResults)},
+		{"updated apps", planned.UpdateResults, actual.UpdateResults},
+		{"reloaded apps", planned.ReloadResults, actual.ReloadResults},
+		{"skipped apps", planned.SkippedResults, actual.SkippedResults},
+		{"approved apps", approvedPaths(planned.ApproveResults), approvedPaths(actual.ApproveResults)},
+		{"promoted apps", planned.PromoteResults, actual.PromoteResults},
+	} {
+		if !slices.Equal(sortedAppPaths(check.planned), sortedAppPaths(check.actual)) {
+			return fmt.Sprintf("the %s changed from %v to %v", check.name, check.planned, check.actual)
+		}
+	}
+	for _, check := range []struct {
+		name            string
+		planned, actual []string
+	}{
+		{"created bindings", planned.CreateBindingResults, actual.CreateBindingResults},
+		{"updated bindings", planned.UpdateBindingResults, actual.UpdateBindingResults},
+		{"promoted bindings", planned.PromoteBindingResults, actual.PromoteBindingResults},
+	} {
+		if !slices.Equal(slices.Sorted(slices.Values(check.planned)), slices.Sorted(slices.Values(check.actual))) {
+			return fmt.Sprintf("the %s changed from %v to %v", check.name, check.planned, check.actual)
+		}
+	}
+	return ""
+}
+
+func sortedAppPaths(appPaths []types.AppPathDomain) []types.AppPathDomain {
+	ret := slices.Clone(appPaths)
+	slices.SortFunc(ret, compareAppPathDomain)
+	return ret
+}
@@ -0,0 +1,55 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestApplyPlanDrift(t *testing.T) {
+	app1 := types.AppPathDomain{Path: "/app1"}
+	app2 := types.AppPathDomain{Path: "/app2", Domain: "example.com"}
+	planned := &types.AppApplyResponse{
+		CommitId:             "abc",
+		CreateResults:        []types.AppCreateResponse{{AppPathDomain: app1}},
+		UpdateResults:        []types.AppPathDomain{app1, app2},
+		CreateBindingResults: []string{"b2", "b1"},
+	}
+
+	// Order of the results does not matter
+	testutil.AssertEqualsString(t, "same", "", applyPlanDrift(planned, &types.AppApplyResponse{
+		CommitId:             "abc",
+		CreateResults:        []types.AppCreateResponse{{AppPathDomain: app1}},
+		UpdateResults:        []types.AppPathDomain{app2, app1},
+		CreateBindingResults: []string{"b1", "b2"},
+	}))
+
+	drift := applyPlanDrift(planned, &types.AppApplyResponse{
+		CommitId:             "def",
+		CreateResults:        []types.AppCreateResponse{{AppPathDomain: app1}},
+		UpdateResults:        []types.AppPathDomain{app1, app2},
+		CreateBindingResults: []string{"b1", "b2"},
+	})
+	testutil.AssertStringContains(t, drift, "apply file commit changed from abc to def")
+
+	drift = applyPlanDrift(planned, &types.AppApplyResponse{
+		CommitId:             "abc",
+		CreateResults:        []types.AppCreateResponse{{AppPathDomain: app1}},
+		UpdateResults:        []types.AppPathDomain{app1},
+		CreateBindingResults: []string{"b1", "b2"},
+	})
+	testutil.AssertStringContains(t, drift, "updated apps changed")
+
+	drift = applyPlanDrift(planned, &types.AppApplyResponse{
+		CommitId:             "abc",
+		CreateResults:        []types.AppCreateResponse{{AppPathDomain: app1}},
+		UpdateResults:        []types.AppPathDomain{app1, app2},
+		CreateBindingResults: []string{"b1"},
+		PromoteResults:       []types.AppPathDomain{app1},
+	})
+	testutil.AssertStringContains(t, drift, "promoted apps changed")
+}
@@ -7,12 +7,15 @@ import (
 	"cmp"
 	"context"
 	"fmt"
+	"maps"
+	"slices"
 	"sync"
 	"time"
 
 	"github.com/openrundev/openrun/internal/app"
 	"github.com/openrundev/openrun/internal/container"
 	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/telemetry"
 	"github.com/openrundev/openrun/internal/types"
 )
 
@@ -189,6 +192,13 @@ func (a *AppStore) GetApp(pathDomain types.AppPathDomain) (*app.App, error) {
 	return app, nil
 }
 
+// LoadedApps returns the apps currently in the store
+func (a *AppStore) LoadedApps() []*app.App {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return slices.Collect(maps.Values(a.appMap))
+}
+
 // ActiveContainerNames returns the container names currently referenced by loaded apps.
 func (a *AppStore) ActiveContainerNames() map[container.ContainerName]bool {
 	a.mu.RLock()
@@ -204,10 +214,58 @@ func (a *AppStore) ActiveContainerNames() map[container.ContainerName]bool {
 		if ok {
 			names[name] = true
 		}
+		for _, serviceName := range application.ActiveServiceNames() {
+			names[serviceName] = true
+		}
 	}
 	return names
 }
 
+// ContainerStates returns the container state of the loaded apps which use a container, for
+// the metrics. Apps being initialized are skipped
+func (a *AppStore) ContainerStates() []telemetry.AppContainerState {
+	a.mu.RLock()
+	apps := make([]*app.App, 0, len(a.appMap))
+	for _, application := range a.appMap {
+		apps = append(apps, application)
+	}
+	a.mu.RUnlock()
+
+	states := make([]telemetry.AppContainerState, 0, len(apps))
+	for _, application := range apps {
+		state, ok := application.ContainerState()
+		if !ok {
+			continue
+		}
+		states = append(states, telemetry.AppContainerState{
+			Attrs: telemetry.AppIdentityAttributes(application.AppEntry),
+			State: string(state),
+		})
+	}
+	return states
+}
+
+// SecretRotatedApps returns the loaded apps for which a secret value used at app load has
+// changed. Apps for which the secrets cannot be read are skipped, they keep the current values
+func (a *AppStore) SecretRotatedApps() []types.AppPathDomain {
+	a.mu.RLock()
+	apps := maps.Clone(a.appMap)
+	a.mu.RUnlock()
+
+	rotated := []types.AppPathDomain{}
+	for pathDomain, application := range apps {
+		changed, err := application.SecretsChanged()
+		if err != nil {
+			a.Warn().Err(err).Msgf("error checking secrets for app %s", pathDomain)
+			continue
+		}
+		if changed {
+			rotated = append(rotated, pathDomain)
+		}
+	}
+	return rotated
+}
+
 // Generation returns the current store generation. Read it before loading app
 // state from the DB and pass it to AddAppIfUnchanged.
 func (a *AppStore) Generation() uint64 {
@@ -355,6 +413,20 @@ func (a *AppStore) ClearAppsNoNotify(pathDomains []types.AppPathDomain) {
 	a.resetAllAppCache()
 }
 
+// ClearAllAppsNoNotify removes all the apps from the in memory App cache, without notifying other
+// servers. Used when app update notifications from other servers could have been missed
+func (a *AppStore) ClearAllAppsNoNotify() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Invalidate in-progress GetApp loads even when no app is cached
+	a.generation++
+	for pd := range a.appMap {
+		a.clearApp(pd)
+	}
+	a.resetAllAppCache()
+}
+
 // ClearApps removes the specified apps from the in memory App cache and creates an audit entry.
 // Also clears the app info cache for all apps (so that it is reloaded on next request)
 func (a *AppStore) ClearAppsAudit(ctx context.Context, pathDomains []types.AppPathDomain, op string) error {
@@ -53,3 +53,26 @@ func TestAppStoreAddAppIfUnchanged(t *testing.T) {
 		t.Fatal("clearing an uncached path did not bump the store generation")
 	}
 }
+
+func TestAppStoreClearAllAppsNoNotify(t *testing.T) {
+	store := NewAppStore(testutil.TestLogger(), &Server{Logger: testutil.TestLogger()})
+
+	gen := store.Generation()
+	store.ClearAllAppsNoNotify()
+	if store.Generation() == gen {
+		t.Fatal("clearing an empty store did not bump the store generation")
+	}
+
+	gen = store.Generation()
+	store.AddAppIfUnchanged(testStoreApp("/app1"), gen)
+	store.AddAppIfUnchanged(testStoreApp("/app2"), gen)
+	store.ClearAllAppsNoNotify()
+	for _, path := range []string{"/app1", "/app2"} {
+		if _, err := store.GetApp(types.CreateAppPathDomain(path, "example.com")); err == nil {
+			t.Fatalf("app %s was not cleared", path)
+		}
+	}
+	if store.AddAppIfUnchanged(testStoreApp("/app3"), gen) {
+		t.Fatal("insert with stale generation accepted")
+	}
+}
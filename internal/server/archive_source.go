@@ -0,0 +1,335 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/metadata"
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	archiveDownloadTimeout = 10 * time.Minute
+	archiveMaxSize         = 1 << 30 // max size of the downloaded archive and of the extracted files
+)
+
+// archiveDir is a downloaded and extracted archive or OCI artifact source
+type archiveDir struct {
+	dir string
+	sha string // sha256 of the archive file, or the OCI manifest digest
+}
+
+// FetchPackagedSource downloads the archive or OCI artifact source url, returns the source folder
+// and the archive sha256 or the artifact digest
+func (r *RepoCache) FetchPackagedSource(ctx context.Context, sourceUrl string) (string, string, error) {
+	if system.IsOCI(sourceUrl) {
+		return r.FetchOCI(ctx, sourceUrl)
+	}
+	return r.FetchArchive(ctx, sourceUrl)
+}
+
+// FetchArchive downloads the archive source url and extracts it. Archives are cached by url for
+// the lifetime of the repo cache, so the stage and prod apps share one download. If the archive
+// has a single top level directory, that directory is returned as the source folder
+func (r *RepoCache) FetchArchive(ctx context.Context, sourceUrl string) (string, string, error) {
+	r.mu.Lock()
+	cached, ok := r.archiveCache[sourceUrl]
+	r.mu.Unlock()
+	if ok {
+		return cached.dir, cached.sha, nil
+	}
+
+	format := system.ArchiveFormat(sourceUrl)
+	if format == "" {
+		return "", "", fmt.Errorf("unsupported archive url %s", sourceUrl)
+	}
+	targetDir, err := os.MkdirTemp(r.rootDir, "archive_")
+	if err != nil {
+		return "", "", err
+	}
+	archiveFile := filepath.Join(targetDir, "source"+format)
+	sha, err := downloadArchive(ctx, sourceUrl, archiveFile)
+	if err != nil {
+		return "", "", err
+	}
+	sourceDir := filepath.Join(targetDir, "src")
+	if err := extractArchive(archiveFile, format, sourceDir); err != nil {
+		return "", "", fmt.Errorf("error extracting %s: %w", sourceUrl, err)
+	}
+	if err := os.Remove(archiveFile); err != nil {
+		return "", "", err
+	}
+	sourceDir, err = archiveRootDir(sourceDir)
+	if err != nil {
+		return "", "", err
+	}
+
+	r.server.Info().Str("url", sourceUrl).Str("sha256", sha).Msg("Downloaded archive source")
+	r.mu.Lock()
+	r.archiveCache[sourceUrl] = archiveDir{dir: sourceDir, sha: sha}
+	r.mu.Unlock()
+	return sourceDir, sha, nil
+}
+
+// downloadArchive downloads the url to the file, returns the sha256 of the contents. s3 urls are
+// downloaded using the default AWS credentials chain, like volume backups
+func downloadArchive(ctx context.Context, sourceUrl, targetFile string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, archiveDownloadTimeout)
+	defer cancel()
+
+	var body io.ReadCloser
+	if strings.HasPrefix(sourceUrl, "s3://") {
+		store, err := newVolumeS3Store(ctx, sourceUrl)
+		if err != nil {
+			return "", err
+		}
+		if body, err = store.get(ctx, store.key); err != nil {
+			return "", fmt.Errorf("error downloading %s: %w", sourceUrl, err)
+		}
+	} else {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, sourceUrl, nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("error downloading %s: %w", redactArchiveUrl(sourceUrl), err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close() //nolint:errcheck
+			return "", fmt.Errorf("error downloading %s: status %d", redactArchiveUrl(sourceUrl), resp.StatusCode)
+		}
+		body = resp.Body
+	}
+	defer body.Close() //nolint:errcheck
+
+	out, err := os.Create(targetFile)
+	if err != nil {
+		return "", err
+	}
+	defer out.Close() //nolint:errcheck
+	hasher := sha256.New()
+	written, err := io.Copy(io.MultiWriter(out, hasher), io.LimitReader(body, archiveMaxSize+1))
+	if err != nil {
+		return "", fmt.Errorf("error downloading %s: %w", redactArchiveUrl(sourceUrl), err)
+	}
+	if written > archiveMaxSize {
+		return "", fmt.Errorf("archive %s is larger than the max size of %d bytes", redactArchiveUrl(sourceUrl), archiveMaxSize)
+	}
+	if err := out.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// redactArchiveUrl removes the query string from the url for errors and logs, it can have the
+// signature for presigned urls
+func redactArchiveUrl(sourceUrl string) string {
+	u, err := url.Parse(sourceUrl)
+	if err != nil {
+		return sourceUrl
+	}
+	u.RawQuery = ""
+	return u.Redacted()
+}
+
+// verifyArchiveChecksum checks the archive sha256 against the expected checksum, which can have
+// a sha256: prefix. No checksum means no verification
+func verifyArchiveChecksum(sourceUrl, expected, actual string) error {
+	expected = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(expected), "sha256:"))
+	if expected == "" || expected == actual {
+		return nil
+	}
+	return fmt.Errorf("checksum mismatch for %s, expected sha256 %s, got %s", redactArchiveUrl(sourceUrl), expected, actual)
+}
+
+// extractArchive extracts the archive into the target dir. Entries outside the target dir are
+// rejected, links and other special files are skipped
+func extractArchive(archiveFile, format, targetDir string) error {
+	if err := os.MkdirAll(targetDir, 0o755); err != nil {
+		return err
+	}
+	if format == ".zip" {
+		return extractZip(archiveFile, targetDir)
+	}
+
+	f, err := os.Open(archiveFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint:errcheck
+	var reader io.Reader = f
+	if format != ".tar" {
+		gzr, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gzr.Close() //nolint:errcheck
+		reader = gzr
+	}
+
+	var total int64
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error reading tar entry: %w", err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			targetPath, err := system.PathInDir(targetDir, hdr.Name)
+			if err != nil {
+				return fmt.Errorf("invalid archive entry %q: %w", hdr.Name, err)
+			}
+			if err := os.MkdirAll(targetPath, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if total += hdr.Size; total > archiveMaxSize {
+				return fmt.Errorf("extracted files are larger than the max size of %d bytes", archiveMaxSize)
+			}
+			if err := writeArchiveFile(targetDir, hdr.Name, tr); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func extractZip(archiveFile, targetDir string) error {
+	zr, err := zip.OpenReader(archiveFile)
+	if err != nil {
+		return err
+	}
+	defer zr.Close() //nolint:errcheck
+
+	var total uint64
+	for _, file := range zr.File {
+		if file.FileInfo().IsDir() {
+			targetPath, err := system.PathInDir(targetDir, file.Name)
+			if err != nil {
+				return fmt.Errorf("invalid archive entry %q: %w", file.Name, err)
+			}
+			if err := os.MkdirAll(targetPath, 0o755); err != nil {
+				return err
+			}
+			continue
+		}
+		if !file.Mode().IsRegular() {
+			continue
+		}
+		if total += file.UncompressedSize64; total > archiveMaxSize {
+			return fmt.Errorf("extracted files are larger than the max size of %d bytes", archiveMaxSize)
+		}
+		rc, err := file.Open()
+		if err != nil {
+			return err
+		}
+		err = writeArchiveFile(targetDir, file.Name, rc)
+		rc.Close() //nolint:errcheck
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeArchiveFile(targetDir, name string, reader io.Reader) error {
+	targetPath, err := system.PathInDir(targetDir, name)
+	if err != nil {
+		return fmt.Errorf("invalid archive entry %q: %w", name, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(targetPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	// The size in the header can be wrong, limit the actual bytes written also
+	if _, err := io.Copy(out, io.LimitReader(reader, archiveMaxSize)); err != nil {
+		out.Close() //nolint:errcheck
+		return fmt.Errorf("error writing %s: %w", name, err)
+	}
+	return out.Close()
+}
+
+// archiveRootDir returns the single top level directory of the extracted archive, like for
+// archives created from a release folder, or the extract dir itself
+func archiveRootDir(dir string) (string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	if len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(dir, entries[0].Name()), nil
+	}
+	return dir, nil
+}
+
+// loadSourceFromPackage downloads the archive or OCI artifact source, verifies the archive
+// checksum and loads the files into the database as a new app version
+func (s *Server) loadSourceFromPackage(ctx context.Context, tx types.Transaction, appEntry *types.AppEntry, repoCache *RepoCache) error {
+	sourceDir, sha, err := repoCache.FetchPackagedSource(ctx, appEntry.SourceUrl)
+	if err != nil {
+		return err
+	}
+	if err := s.verifySource(ctx, appEntry, sha, repoCache); err != nil {
+		return err
+	}
+	appEntry.Metadata.VersionMetadata.SourceArchiveSha = ""
+	appEntry.Metadata.VersionMetadata.OCIDigest = ""
+	if system.IsOCI(appEntry.SourceUrl) {
+		appEntry.Metadata.VersionMetadata.OCIDigest = sha
+	} else {
+		if err := verifyArchiveChecksum(appEntry.SourceUrl, appEntry.Metadata.VersionMetadata.SourceChecksum, sha); err != nil {
+			return err
+		}
+		appEntry.Metadata.VersionMetadata.SourceArchiveSha = sha
+	}
+
+	appEntry.Metadata.VersionMetadata.GitBranch = ""
+	appEntry.Metadata.VersionMetadata.GitCommit = ""
+	appEntry.Metadata.VersionMetadata.GitTag = ""
+	appEntry.Metadata.VersionMetadata.GitResolvedTag = ""
+	appEntry.Metadata.VersionMetadata.GitMessage = ""
+	appEntry.Metadata.GitAuthName = ""
+
+	s.Info().Msgf("Loading app sources from %s", redactArchiveUrl(appEntry.SourceUrl))
+	fileStore, err := metadata.NewFileStore(appEntry.Id, appEntry.Metadata.VersionMetadata.Version, s.db, tx)
+	if err != nil {
+		return err
+	}
+	highestVersion, err := fileStore.GetHighestVersion(ctx, tx, appEntry.Id)
+	if err != nil {
+		return fmt.Errorf("error getting highest version: %w", err)
+	}
+	prevVersion := appEntry.Metadata.VersionMetadata.Version
+	if highestVersion == 0 {
+		prevVersion = 0 // No previous version, set to 0
+	}
+	appEntry.Metadata.VersionMetadata.PreviousVersion = prevVersion
+	appEntry.Metadata.VersionMetadata.Version = highestVersion + 1
+	if err := s.setImageOptions(fileStore, appEntry); err != nil {
+		return err
+	}
+	return fileStore.AddAppVersionDisk(ctx, tx, appEntry.Metadata, sourceDir)
+}
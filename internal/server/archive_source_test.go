@@ -0,0 +1,88 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func writeTestTarGz(t *testing.T, path string, files map[string]string) {
+	f, err := os.Create(path)
+	testutil.AssertNoError(t, err)
+	defer f.Close() //nolint:errcheck
+	gzw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gzw)
+	for name, contents := range files {
+		testutil.AssertNoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(contents)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(contents))
+		testutil.AssertNoError(t, err)
+	}
+	testutil.AssertNoError(t, tw.Close())
+	testutil.AssertNoError(t, gzw.Close())
+}
+
+func writeTestZip(t *testing.T, path string, files map[string]string) {
+	f, err := os.Create(path)
+	testutil.AssertNoError(t, err)
+	defer f.Close() //nolint:errcheck
+	zw := zip.NewWriter(f)
+	for name, contents := range files {
+		w, err := zw.Create(name)
+		testutil.AssertNoError(t, err)
+		_, err = w.Write([]byte(contents))
+		testutil.AssertNoError(t, err)
+	}
+	testutil.AssertNoError(t, zw.Close())
+}
+
+func TestExtractArchive(t *testing.T) {
+	files := map[string]string{"myapp-1.2/app.star": "app = 1\n", "myapp-1.2/static/index.html": "<html></html>"}
+	for _, format := range []string{".tar.gz", ".zip"} {
+		t.Run(format, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			archiveFile := filepath.Join(tmpDir, "source"+format)
+			if format == ".zip" {
+				writeTestZip(t, archiveFile, files)
+			} else {
+				writeTestTarGz(t, archiveFile, files)
+			}
+
+			targetDir := filepath.Join(tmpDir, "src")
+			testutil.AssertNoError(t, extractArchive(archiveFile, format, targetDir))
+			// The single top level directory is used as the source root
+			rootDir, err := archiveRootDir(targetDir)
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqualsString(t, "root", filepath.Join(targetDir, "myapp-1.2"), rootDir)
+			data, err := os.ReadFile(filepath.Join(rootDir, "static", "index.html"))
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqualsString(t, "contents", "<html></html>", string(data))
+		})
+	}
+}
+
+func TestExtractArchiveInvalidPath(t *testing.T) {
+	tmpDir := t.TempDir()
+	archiveFile := filepath.Join(tmpDir, "source.tar.gz")
+	writeTestTarGz(t, archiveFile, map[string]string{"../escape.star": "app = 1\n"})
+	err := extractArchive(archiveFile, ".tar.gz", filepath.Join(tmpDir, "src"))
+	testutil.AssertErrorContains(t, err, "invalid archive entry")
+	if _, err := os.Stat(filepath.Join(tmpDir, "escape.star")); !os.IsNotExist(err) {
+		t.Fatalf("expected file outside target dir to not be created, got %v", err)
+	}
+}
+
+func TestVerifyArchiveChecksum(t *testing.T) {
+	testutil.AssertNoError(t, verifyArchiveChecksum("https://example.com/app.zip", "", "abcd"))
+	testutil.AssertNoError(t, verifyArchiveChecksum("https://example.com/app.zip", "ABCD", "abcd"))
+	testutil.AssertNoError(t, verifyArchiveChecksum("https://example.com/app.zip", "sha256:abcd", "abcd"))
+	err := verifyArchiveChecksum("https://example.com/app.zip?X-Amz-Signature=secret", "1234", "abcd")
+	testutil.AssertErrorContains(t, err, "checksum mismatch for https://example.com/app.zip, expected sha256 1234")
+}
@@ -0,0 +1,204 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	defaultAuditListLimit = 50
+	maxAuditListLimit     = 10_000
+)
+
+// auditTimeFormats are the formats accepted for the audit time range filters, times without a
+// zone are in UTC
+var auditTimeFormats = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02T15:04", "2006-01-02"}
+
+// parseAuditTime parses a time range filter value. For the end of the range, a date without a
+// time includes the whole day
+func parseAuditTime(value string, end bool) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	for _, format := range auditTimeFormats {
+		t, err := time.Parse(format, value)
+		if err != nil {
+			continue
+		}
+		if end && format == time.DateOnly {
+			t = t.Add(24 * time.Hour)
+		}
+		return t, nil
+	}
+	return time.Time{}, types.CreateRequestError(
+		fmt.Sprintf("invalid time %q, expected a date (2006-01-02) or a RFC3339 timestamp", value), http.StatusBadRequest)
+}
+
+// auditAppEnv returns the app environment for the app id, from the id prefix
+func auditAppEnv(appId string) string {
+	switch {
+	case strings.HasPrefix(appId, types.ID_PREFIX_APP_PROD):
+		return "prod"
+	case strings.HasPrefix(appId, types.ID_PREFIX_APP_STAGE):
+		return "stage"
+	case strings.HasPrefix(appId, types.ID_PREFIX_APP_PREVIEW):
+		return "preview"
+	case strings.HasPrefix(appId, types.ID_PREFIX_APP_DEV):
+		return "dev"
+	}
+	return ""
+}
+
+// ListAuditEvents returns the audit events matching the query, newest first. audit:read grants
+// access to the audit log across all apps, tenant admins can read the events for the apps in
+// their tenants
+func (s *Server) ListAuditEvents(ctx context.Context, auditQuery types.AuditQuery) (*types.AuditListResponse, error) {
+	if auditQuery.Limit <= 0 || auditQuery.Limit > maxAuditListLimit {
+		return nil, types.CreateRequestError(fmt.Sprintf("limit has to be between 1 and %d", maxAuditListLimit), http.StatusBadRequest)
+	}
+	tenantAppIds, tenantScoped, err := s.auditTenantScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var query strings.Builder
+	query.WriteString("select rid, app_id, create_time, user_id, event_type, operation, target, status, detail from audit ")
+
+	filterConditions := []string{}
+	queryParams := []any{}
+	addInFilter := func(column string, values []any) {
+		if len(values) == 0 {
+			// No match, like for an app glob matching no apps
+			filterConditions = append(filterConditions, "1 = 0")
+			return
+		}
+		filterConditions = append(filterConditions, column+" in (?"+strings.Repeat(", ?", len(values)-1)+")")
+		queryParams = append(queryParams, values...)
+	}
+
+	if appGlob := strings.TrimSpace(auditQuery.AppGlob); appGlob != "" {
+		appInfo, err := s.ParseGlob(appGlob)
+		if err != nil {
+			return nil, err
+		}
+		appIds := make([]any, 0, len(appInfo))
+		for _, app := range appInfo {
+			appIds = append(appIds, string(app.Id))
+		}
+		addInFilter("app_id", appIds)
+	}
+	if tenantScoped {
+		// Events which are not for an app have an empty app id
+		appIds := []any{""}
+		for _, appId := range tenantAppIds {
+			appIds = append(appIds, string(appId))
+		}
+		addInFilter("app_id", appIds)
+	}
+
+	for _, filter := range []struct{ column, value string }{
+		{"user_id", auditQuery.UserId},
+		{"event_type", auditQuery.EventType},
+		{"target", auditQuery.Target},
+		{"status", auditQuery.Status},
+		{"rid", auditQuery.Rid},
+	} {
+		if value := strings.TrimSpace(filter.value); value != "" {
+			filterConditions = append(filterConditions, filter.column+" = ?")
+			queryParams = append(queryParams, value)
+		}
+	}
+
+	if operation := strings.TrimSpace(auditQuery.Operation); operation != "" {
+		opList, _ := getOpList(operation)
+		addInFilter("operation", opList)
+	}
+	if detail := strings.TrimSpace(auditQuery.Detail); detail != "" {
+		filterConditions = append(filterConditions, "detail like ?")
+		queryParams = append(queryParams, detail)
+	}
+	if !auditQuery.Start.IsZero() {
+		filterConditions = append(filterConditions, "create_time >= ?")
+		queryParams = append(queryParams, auditQuery.Start.UnixNano())
+	}
+	if !auditQuery.End.IsZero() {
+		filterConditions = append(filterConditions, "create_time <= ?")
+		queryParams = append(queryParams, auditQuery.End.UnixNano())
+	}
+	if auditQuery.Before > 0 {
+		filterConditions = append(filterConditions, "create_time < ?")
+		queryParams = append(queryParams, auditQuery.Before)
+	}
+
+	if len(filterConditions) > 0 {
+		query.WriteString(" where ")
+		query.WriteString(strings.Join(filterConditions, " and "))
+	}
+	query.WriteString(" order by create_time desc limit ?")
+	queryParams = append(queryParams, auditQuery.Limit)
+
+	// Ensure previously queued audit events are visible to the query
+	s.FlushAuditEvents()
+	rows, err := s.auditDB.QueryContext(ctx, system.RebindQuery(s.auditDbType, query.String()), queryParams...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	apps, err := s.apps.GetAllAppsInfo()
+	if err != nil {
+		return nil, err
+	}
+	appIdMap := map[types.AppId]types.AppInfo{}
+	for _, app := range apps {
+		appIdMap[app.Id] = app
+	}
+
+	ret := &types.AuditListResponse{Events: []types.AuditEventInfo{}}
+	for rows.Next() {
+		var event types.AuditEventInfo
+		var appId string
+		if err := rows.Scan(&event.Rid, &appId, &event.CreateTimeEpoch, &event.UserId, &event.EventType,
+			&event.Operation, &event.Target, &event.Status, &event.Detail); err != nil {
+			return nil, err
+		}
+		event.AppId = types.AppId(appId)
+		event.CreateTime = time.Unix(0, event.CreateTimeEpoch).UTC()
+		event.AppEnv = auditAppEnv(appId)
+		event.AppName = appId
+		if appInfo, ok := appIdMap[event.AppId]; ok {
+			// Staging events resolve to the main app, so links go to the
+			// prod app's detail page
+			if event.AppEnv == "stage" && appInfo.MainApp != "" {
+				if mainInfo, ok := appIdMap[appInfo.MainApp]; ok {
+					appInfo = mainInfo
+				}
+			}
+			event.AppName = appInfo.Name
+			event.AppPath = appInfo.String()
+		}
+		ret.Events = append(ret.Events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	if closeErr := rows.Close(); closeErr != nil {
+		return nil, fmt.Errorf("error closing rows: %w", closeErr)
+	}
+
+	if len(ret.Events) == auditQuery.Limit {
+		ret.NextBefore = ret.Events[len(ret.Events)-1].CreateTimeEpoch
+	}
+	return ret, nil
+}
@@ -0,0 +1,91 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestParseAuditTime(t *testing.T) {
+	for _, test := range []struct {
+		value    string
+		end      bool
+		expected string
+	}{
+		{"", false, "0001-01-01T00:00:00Z"},
+		{"2025-03-01", false, "2025-03-01T00:00:00Z"},
+		{"2025-03-01", true, "2025-03-02T00:00:00Z"},
+		{"2025-03-01T10:30", true, "2025-03-01T10:30:00Z"},
+		{"2025-03-01 10:30:15", false, "2025-03-01T10:30:15Z"},
+		{"2025-03-01T10:30:15+05:30", false, "2025-03-01T05:00:15Z"},
+	} {
+		got, err := parseAuditTime(test.value, test.end)
+		if err != nil {
+			t.Fatalf("parse %q: %v", test.value, err)
+		}
+		if got.UTC().Format(time.RFC3339) != test.expected {
+			t.Errorf("parse %q end=%t: got %s, expected %s", test.value, test.end, got.UTC().Format(time.RFC3339), test.expected)
+		}
+	}
+
+	if _, err := parseAuditTime("yesterday", false); err == nil || !strings.Contains(err.Error(), `invalid time "yesterday"`) {
+		t.Fatalf("invalid time error = %v", err)
+	}
+}
+
+func TestListAuditEvents(t *testing.T) {
+	server, db, ctx := newApplyTestServer(t)
+	defer db.Close()
+	if err := server.initAuditDB("sqlite:" + filepath.Join(t.TempDir(), "audit.db")); err != nil {
+		t.Fatalf("init audit db: %v", err)
+	}
+	defer func() {
+		server.stopAuditWriter()
+		_ = server.auditDB.Close()
+	}()
+
+	base := time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC)
+	for i, user := range []string{"alice", "bob", "alice"} {
+		if _, err := server.auditDB.Exec(
+			"insert into audit (rid, app_id, create_time, user_id, event_type, operation, target, status, detail) values (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			"rid_"+user, "", base.Add(time.Duration(i)*time.Hour).UnixNano(), user, "system",
+			"reload_apps", "/apps/list", "success", "list detail"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	response, err := server.ListAuditEvents(ctx, types.AuditQuery{Limit: 2})
+	if err != nil || len(response.Events) != 2 {
+		t.Fatalf("first page = %v, %v", response, err)
+	}
+	if !response.Events[0].CreateTime.Equal(base.Add(2*time.Hour)) || response.NextBefore != base.Add(time.Hour).UnixNano() {
+		t.Fatalf("first page order = %v, next %d", response.Events, response.NextBefore)
+	}
+	response, err = server.ListAuditEvents(ctx, types.AuditQuery{Limit: 2, Before: response.NextBefore})
+	if err != nil || len(response.Events) != 1 || response.Events[0].UserId != "alice" || response.NextBefore != 0 {
+		t.Fatalf("second page = %v, %v", response, err)
+	}
+
+	response, err = server.ListAuditEvents(ctx, types.AuditQuery{Limit: 10, UserId: "alice", Operation: "reload_apps"})
+	if err != nil || len(response.Events) != 2 {
+		t.Fatalf("user filter = %v, %v", response, err)
+	}
+	response, err = server.ListAuditEvents(ctx, types.AuditQuery{Limit: 10, Start: base.Add(30 * time.Minute), End: base.Add(90 * time.Minute)})
+	if err != nil || len(response.Events) != 1 || response.Events[0].UserId != "bob" {
+		t.Fatalf("time range = %v, %v", response, err)
+	}
+	response, err = server.ListAuditEvents(ctx, types.AuditQuery{Limit: 10, AppGlob: "/nomatch/**"})
+	if err != nil || len(response.Events) != 0 {
+		t.Fatalf("app glob without matches = %v, %v", response, err)
+	}
+
+	if _, err := server.ListAuditEvents(ctx, types.AuditQuery{Limit: 0}); err == nil || !strings.Contains(err.Error(), "limit has to be between") {
+		t.Fatalf("invalid limit error = %v", err)
+	}
+}
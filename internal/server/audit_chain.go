@@ -0,0 +1,470 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	auditCheckpointPurpose = "audit_checkpoint"
+
+	// auditRetentionMargin allows for the hourly retention cleanup and the event reordering in
+	// the write batches, when deciding whether a missing event was deleted by the retention cleanup
+	auditRetentionMargin = 2 * time.Hour
+)
+
+// auditChainHead is the last event written to an audit hash chain
+type auditChainHead struct {
+	seq       int64
+	hash      string
+	eventTime int64
+}
+
+// auditChainRecord is an audit event with its hash chain columns
+type auditChainRecord struct {
+	ChainId    string
+	Seq        int64
+	PrevHash   string
+	Hash       string
+	Rid        string
+	AppId      string
+	CreateTime int64
+	UserId     string
+	EventType  string
+	Operation  string
+	Target     string
+	Status     string
+	Detail     string
+}
+
+// computeHash returns the SHA256 of the event fields and the previous event hash. The fields are
+// length prefixed, so that moving text between fields changes the hash
+func (r *auditChainRecord) computeHash() string {
+	h := sha256.New()
+	for _, field := range []string{r.ChainId, strconv.FormatInt(r.Seq, 10), r.PrevHash, r.Rid, r.AppId,
+		strconv.FormatInt(r.CreateTime, 10), r.UserId, r.EventType, r.Operation, r.Target, r.Status, r.Detail} {
+		h.Write(binary.BigEndian.AppendUint64(nil, uint64(len(field))))
+		h.Write([]byte(field))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// values returns the column values in the auditInsertQuery order
+func (r *auditChainRecord) values() []any {
+	return []any{r.Rid, r.AppId, r.CreateTime, r.UserId, r.EventType, r.Operation, r.Target, r.Status, r.Detail,
+		r.ChainId, r.Seq, r.PrevHash, r.Hash}
+}
+
+const auditInsertQuery = `insert into audit (rid, app_id, create_time, user_id, event_type, operation, target, status, detail, ` +
+	`chain_id, seq, prev_hash, hash) values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+// auditChainClass returns the event class, http or nonhttp. The classes have separate chains since
+// their retention is different, so the retention cleanup deletes events from the start of the chain
+func auditChainClass(eventType string) string {
+	if eventType == string(types.EventTypeHTTP) {
+		return "http"
+	}
+	return "nonhttp"
+}
+
+// auditChainId returns the chain id for the server and event class. Each server process starts
+// new chains, so the servers sharing the audit DB do not have to coordinate the writes
+func auditChainId(serverId types.ServerId, eventType string) string {
+	return string(serverId) + "_" + auditChainClass(eventType)
+}
+
+// chainClass returns the event class from the chain id
+func chainClass(chainId string) string {
+	return chainId[strings.LastIndex(chainId, "_")+1:]
+}
+
+// linkAuditEvent adds the event to the hash chain for its class and returns the record to insert.
+// Must be called with auditChainMu held, through withAuditChain
+func (s *Server) linkAuditEvent(event *types.AuditEvent) *auditChainRecord {
+	if s.auditChains == nil {
+		s.auditChains = map[string]auditChainHead{}
+	}
+	chainId := auditChainId(types.CurrentServerId, string(event.EventType))
+	head := s.auditChains[chainId]
+	record := &auditChainRecord{
+		ChainId:    chainId,
+		Seq:        head.seq + 1,
+		PrevHash:   head.hash,
+		Rid:        event.RequestId,
+		AppId:      string(event.AppId),
+		CreateTime: event.CreateTime.UnixNano(),
+		UserId:     event.UserId,
+		EventType:  string(event.EventType),
+		Operation:  event.Operation,
+		Target:     event.Target,
+		Status:     event.Status,
+		Detail:     event.Detail,
+	}
+	record.Hash = record.computeHash()
+	s.auditChains[chainId] = auditChainHead{seq: record.Seq, hash: record.Hash, eventTime: record.CreateTime}
+	return record
+}
+
+// withAuditChain runs the audit insert with the chain lock held, so that the events are written in
+// the chain order. If the insert fails, the chain heads are restored, so that the chain does not
+// have a gap for the events which were not written
+func (s *Server) withAuditChain(insert func() error) error {
+	s.auditChainMu.Lock()
+	defer s.auditChainMu.Unlock()
+	saved := maps.Clone(s.auditChains)
+	err := insert()
+	if err != nil {
+		s.auditChains = saved
+	}
+	return err
+}
+
+// auditCheckpoint is a signed record of the head of an audit chain
+type auditCheckpoint struct {
+	ChainId    string
+	Seq        int64
+	Hash       string
+	EventTime  int64 // the create time of the event at seq
+	CreateTime int64
+	KeyId      string
+	Signature  string
+}
+
+func (c *auditCheckpoint) payload() []byte {
+	return []byte(strings.Join([]string{c.ChainId, strconv.FormatInt(c.Seq, 10), c.Hash,
+		strconv.FormatInt(c.EventTime, 10), strconv.FormatInt(c.CreateTime, 10)}, "\n"))
+}
+
+// writeAuditCheckpoints signs the head of each chain written by this server, if the chain has new
+// events since the last checkpoint. The hash chain by itself does not prevent someone with write
+// access to the audit DB from editing events and recomputing the later hashes. The checkpoints are
+// signed with the keyring key, so such edits do not match the checkpoints
+func (s *Server) writeAuditCheckpoints() error {
+	if s.keyring == nil {
+		return nil
+	}
+	s.auditChainMu.Lock()
+	heads := maps.Clone(s.auditChains)
+	s.auditChainMu.Unlock()
+
+	s.auditCheckpointMu.Lock()
+	defer s.auditCheckpointMu.Unlock()
+	if s.auditCheckpointSeqs == nil {
+		s.auditCheckpointSeqs = map[string]int64{}
+	}
+	for _, chainId := range slices.Sorted(maps.Keys(heads)) {
+		head := heads[chainId]
+		if head.seq == s.auditCheckpointSeqs[chainId] {
+			continue
+		}
+		checkpoint := auditCheckpoint{
+			ChainId:    chainId,
+			Seq:        head.seq,
+			Hash:       head.hash,
+			EventTime:  head.eventTime,
+			CreateTime: time.Now().UnixNano(),
+		}
+		keyId, sig, err := s.keyring.Sign(auditCheckpointPurpose, checkpoint.payload())
+		if err != nil {
+			return err
+		}
+		checkpoint.KeyId = keyId
+		checkpoint.Signature = base64.StdEncoding.EncodeToString(sig)
+		if _, err := s.auditDB.Exec(system.RebindQuery(s.auditDbType, `insert into audit_checkpoint `+
+			`(chain_id, seq, hash, event_time, create_time, key_id, signature) values (?, ?, ?, ?, ?, ?, ?)`),
+			checkpoint.ChainId, checkpoint.Seq, checkpoint.Hash, checkpoint.EventTime, checkpoint.CreateTime,
+			checkpoint.KeyId, checkpoint.Signature); err != nil {
+			return err
+		}
+		s.auditCheckpointSeqs[chainId] = head.seq
+	}
+	return nil
+}
+
+func (s *Server) auditCheckpointLoop(checkpointTicker *time.Ticker) {
+	defer checkpointTicker.Stop()
+	for {
+		select {
+		case <-s.auditStop:
+			// Server shutdown, stopAuditWriter writes the final checkpoint
+			return
+		case <-checkpointTicker.C:
+		}
+		if err := s.writeAuditCheckpoints(); err != nil {
+			s.Error().Err(err).Msg("error writing audit checkpoint")
+		}
+	}
+}
+
+// checkpointError checks the checkpoint signature. For an invalid signature, the error is returned.
+// If the signing key has been removed from the keyring, the signature cannot be checked, the
+// warning is returned
+func (s *Server) checkpointError(checkpoint *auditCheckpoint) (errMsg, warning string) {
+	desc := fmt.Sprintf("checkpoint at seq %d of chain %s", checkpoint.Seq, checkpoint.ChainId)
+	if s.keyring == nil {
+		return "", desc + " cannot be verified, keyring is not available"
+	}
+	sig, err := base64.StdEncoding.DecodeString(checkpoint.Signature)
+	if err != nil {
+		return desc + " has an invalid signature", ""
+	}
+	sigs, err := s.keyring.Signatures(auditCheckpointPurpose, checkpoint.payload())
+	if err != nil {
+		return desc + " cannot be verified: " + err.Error(), ""
+	}
+	for _, keySig := range sigs {
+		if hmac.Equal(keySig, sig) {
+			return "", ""
+		}
+	}
+	for _, key := range s.keyring.List().Keys {
+		if key.Id == checkpoint.KeyId {
+			return desc + " has an invalid signature", ""
+		}
+	}
+	return "", fmt.Sprintf("%s cannot be verified, key %s has been removed from the keyring", desc, checkpoint.KeyId)
+}
+
+// auditChainVerifier checks the events of the audit chains, read in chain and seq order, against
+// the hashes and the verified checkpoints
+type auditChainVerifier struct {
+	now         time.Time
+	retention   map[string]int               // retention days for each event class, zero for no cleanup
+	checkpoints map[string][]auditCheckpoint // verified checkpoints for each chain, in seq order
+	ret         *types.AuditVerifyResponse
+
+	current *types.AuditChainStatus
+	prev    *auditChainRecord
+	seen    map[string]bool
+}
+
+func newAuditChainVerifier(now time.Time, retention map[string]int, checkpoints map[string][]auditCheckpoint,
+	ret *types.AuditVerifyResponse) *auditChainVerifier {
+	return &auditChainVerifier{now: now, retention: retention, checkpoints: checkpoints, ret: ret, seen: map[string]bool{}}
+}
+
+// pruned returns true if an event created at eventTime could have been deleted by the retention cleanup
+func (v *auditChainVerifier) pruned(chainId string, eventTime int64) bool {
+	days := v.retention[chainClass(chainId)]
+	if days <= 0 {
+		return false
+	}
+	return eventTime < v.now.Add(-time.Duration(days)*24*time.Hour+auditRetentionMargin).UnixNano()
+}
+
+func (v *auditChainVerifier) errorf(format string, args ...any) {
+	v.ret.Errors = append(v.ret.Errors, fmt.Sprintf(format, args...))
+}
+
+func (v *auditChainVerifier) warnf(format string, args ...any) {
+	v.ret.Warnings = append(v.ret.Warnings, fmt.Sprintf(format, args...))
+}
+
+// add checks the next event, the events are in chain and seq order
+func (v *auditChainVerifier) add(record *auditChainRecord) {
+	if v.current == nil || v.current.ChainId != record.ChainId {
+		v.endChain()
+		v.current = &types.AuditChainStatus{ChainId: record.ChainId, FirstSeq: record.Seq}
+		v.seen[record.ChainId] = true
+	}
+	chainId := record.ChainId
+
+	if record.Hash != record.computeHash() {
+		v.errorf("chain %s: event seq %d (rid %s) has been modified, the hash does not match", chainId, record.Seq, record.Rid)
+	}
+	if v.prev != nil {
+		switch {
+		case record.Seq == v.prev.Seq:
+			v.errorf("chain %s: event seq %d is duplicated", chainId, record.Seq)
+		case record.Seq == v.prev.Seq+1:
+			if record.PrevHash != v.prev.Hash {
+				v.errorf("chain %s: event seq %d does not link to the previous event", chainId, record.Seq)
+			}
+		case v.pruned(chainId, v.prev.CreateTime):
+			v.warnf("chain %s: events seq %d to %d were deleted by the retention cleanup", chainId, v.prev.Seq+1, record.Seq-1)
+		default:
+			v.errorf("chain %s: events seq %d to %d are missing", chainId, v.prev.Seq+1, record.Seq-1)
+		}
+	}
+	for _, checkpoint := range v.checkpoints[chainId] {
+		if checkpoint.Seq == record.Seq && checkpoint.Hash != record.Hash {
+			v.errorf("chain %s: event seq %d does not match the signed checkpoint", chainId, record.Seq)
+		}
+	}
+
+	v.current.LastSeq = record.Seq
+	v.current.Events++
+	v.prev = record
+}
+
+// endChain checks the start and the end of the current chain against the checkpoints
+func (v *auditChainVerifier) endChain() {
+	if v.current == nil {
+		return
+	}
+	status := v.current
+	checkpoints := v.checkpoints[status.ChainId]
+	if len(checkpoints) > 0 {
+		last := checkpoints[len(checkpoints)-1]
+		status.Checkpoints = len(checkpoints)
+		status.CheckpointSeq = last.Seq
+		checkpointTime := time.Unix(0, last.CreateTime).UTC()
+		status.CheckpointTime = &checkpointTime
+		if last.Seq > status.LastSeq && !v.pruned(status.ChainId, last.EventTime) {
+			v.errorf("chain %s: events seq %d to %d were deleted, the checkpoint has seq %d", status.ChainId,
+				status.LastSeq+1, last.Seq, last.Seq)
+		}
+	}
+
+	if status.FirstSeq > 1 {
+		// The events at the start of the chain are missing. That is expected after the retention
+		// cleanup, unless a checkpoint shows that the deleted events were within the retention
+		var before *auditCheckpoint
+		for i := range checkpoints {
+			if checkpoints[i].Seq < status.FirstSeq {
+				before = &checkpoints[i]
+			}
+		}
+		switch {
+		case v.retention[chainClass(status.ChainId)] <= 0:
+			v.errorf("chain %s: events seq 1 to %d are missing, retention cleanup is not enabled", status.ChainId, status.FirstSeq-1)
+		case before != nil && !v.pruned(status.ChainId, before.EventTime):
+			v.errorf("chain %s: events seq 1 to %d are missing, the checkpoint at seq %d is within the retention period",
+				status.ChainId, status.FirstSeq-1, before.Seq)
+		}
+	}
+
+	v.ret.Chains = append(v.ret.Chains, *status)
+	v.current = nil
+	v.prev = nil
+}
+
+// finish ends the last chain and checks the chains which have checkpoints but no events
+func (v *auditChainVerifier) finish() {
+	v.endChain()
+	for _, chainId := range slices.Sorted(maps.Keys(v.checkpoints)) {
+		checkpoints := v.checkpoints[chainId]
+		if v.seen[chainId] || len(checkpoints) == 0 {
+			continue
+		}
+		last := checkpoints[len(checkpoints)-1]
+		if !v.pruned(chainId, last.EventTime) {
+			v.errorf("chain %s: all events were deleted, the checkpoint has seq %d", chainId, last.Seq)
+		}
+	}
+	v.ret.Verified = len(v.ret.Errors) == 0
+}
+
+// VerifyAuditLog verifies the audit hash chains and the signed checkpoints. Edited events do not
+// match their hash, deleted and inserted events break the chain links. Events after the last
+// checkpoint are protected by the hash chain only
+func (s *Server) VerifyAuditLog(ctx context.Context) (*types.AuditVerifyResponse, error) {
+	if err := s.enforceGlobalPerm(ctx, types.PermissionAuditRead, ""); err != nil {
+		return nil, err
+	}
+	// Ensure previously queued audit events are included
+	s.FlushAuditEvents()
+
+	ret := &types.AuditVerifyResponse{Chains: []types.AuditChainStatus{}, Errors: []string{}, Warnings: []string{}}
+	checkpoints, err := s.loadAuditCheckpoints(ctx, ret)
+	if err != nil {
+		return nil, err
+	}
+	config := s.Config().System
+	verifier := newAuditChainVerifier(time.Now(), map[string]int{
+		"http":    retentionDays(config.HttpEventRetentionDays, config.AuditRetentionDays),
+		"nonhttp": retentionDays(config.NonHttpEventRetentionDays, config.AuditRetentionDays),
+	}, checkpoints, ret)
+
+	rows, err := s.auditDB.QueryContext(ctx, `select chain_id, seq, prev_hash, hash, rid, app_id, create_time, user_id, `+
+		`event_type, operation, target, status, detail from audit where chain_id is not null order by chain_id, seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var firstChained int64
+	for rows.Next() {
+		var record auditChainRecord
+		if err := rows.Scan(&record.ChainId, &record.Seq, &record.PrevHash, &record.Hash, &record.Rid, &record.AppId,
+			&record.CreateTime, &record.UserId, &record.EventType, &record.Operation, &record.Target, &record.Status,
+			&record.Detail); err != nil {
+			return nil, err
+		}
+		if firstChained == 0 || record.CreateTime < firstChained {
+			firstChained = record.CreateTime
+		}
+		verifier.add(&record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	verifier.finish()
+
+	// Events without chain columns were written before the upgrade, or inserted directly in the DB
+	if err := s.auditDB.QueryRowContext(ctx, `select count(*) from audit where chain_id is null`).Scan(&ret.Unchained); err != nil {
+		return nil, err
+	}
+	if ret.Unchained > 0 && firstChained > 0 {
+		var inserted int64
+		if err := s.auditDB.QueryRowContext(ctx, system.RebindQuery(s.auditDbType,
+			`select count(*) from audit where chain_id is null and create_time >= ?`), firstChained).Scan(&inserted); err != nil {
+			return nil, err
+		}
+		if inserted > 0 {
+			ret.Errors = append(ret.Errors, fmt.Sprintf("%d events created after the start of the hash chains are not chained", inserted))
+			ret.Verified = false
+		}
+	}
+	return ret, nil
+}
+
+// loadAuditCheckpoints returns the checkpoints with a valid signature, by chain in seq order. The
+// invalid checkpoints are added to the errors
+func (s *Server) loadAuditCheckpoints(ctx context.Context, ret *types.AuditVerifyResponse) (map[string][]auditCheckpoint, error) {
+	rows, err := s.auditDB.QueryContext(ctx, `select chain_id, seq, hash, event_time, create_time, key_id, signature `+
+		`from audit_checkpoint order by chain_id, seq`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	checkpoints := map[string][]auditCheckpoint{}
+	for rows.Next() {
+		var checkpoint auditCheckpoint
+		if err := rows.Scan(&checkpoint.ChainId, &checkpoint.Seq, &checkpoint.Hash, &checkpoint.EventTime,
+			&checkpoint.CreateTime, &checkpoint.KeyId, &checkpoint.Signature); err != nil {
+			return nil, err
+		}
+		errMsg, warning := s.checkpointError(&checkpoint)
+		if errMsg != "" {
+			ret.Errors = append(ret.Errors, errMsg)
+			continue
+		}
+		if warning != "" {
+			ret.Warnings = append(ret.Warnings, warning)
+			continue
+		}
+		checkpoints[checkpoint.ChainId] = append(checkpoints[checkpoint.ChainId], checkpoint)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return checkpoints, nil
+}
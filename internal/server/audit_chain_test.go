@@ -0,0 +1,193 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestAuditChainHash(t *testing.T) {
+	record := auditChainRecord{ChainId: "srv_1_nonhttp", Seq: 1, Rid: "rid_1", CreateTime: 100, UserId: "admin",
+		EventType: "system", Operation: "reload_apps", Target: "/apps", Status: "Success", Detail: "ab"}
+	hash := record.computeHash()
+	testutil.AssertEqualsInt(t, "hash length", 64, len(hash))
+
+	moved := record
+	moved.Target, moved.Status = "/appsS", "uccess"
+	if moved.computeHash() == hash {
+		t.Fatal("expected hash to change when text moves between fields")
+	}
+	linked := record
+	linked.PrevHash = hash
+	if linked.computeHash() == hash {
+		t.Fatal("expected hash to include the previous hash")
+	}
+
+	testutil.AssertEqualsString(t, "http", "srv_1_http", auditChainId("srv_1", "http"))
+	testutil.AssertEqualsString(t, "custom", "srv_1_nonhttp", auditChainId("srv_1", "custom"))
+	testutil.AssertEqualsString(t, "class", "nonhttp", chainClass("srv_1_nonhttp"))
+}
+
+// newTestChain returns a valid chain of count events, created a minute apart ending at end
+func newTestChain(chainId string, count int, end time.Time) []*auditChainRecord {
+	ret := []*auditChainRecord{}
+	prevHash := ""
+	for i := 1; i <= count; i++ {
+		record := &auditChainRecord{ChainId: chainId, Seq: int64(i), PrevHash: prevHash, Rid: "rid",
+			CreateTime: end.Add(-time.Duration(count-i) * time.Minute).UnixNano(), EventType: "system", Operation: "op"}
+		record.Hash = record.computeHash()
+		prevHash = record.Hash
+		ret = append(ret, record)
+	}
+	return ret
+}
+
+func runTestVerifier(now time.Time, retentionDays int, checkpoints map[string][]auditCheckpoint,
+	records []*auditChainRecord) *types.AuditVerifyResponse {
+	ret := &types.AuditVerifyResponse{}
+	verifier := newAuditChainVerifier(now, map[string]int{"nonhttp": retentionDays}, checkpoints, ret)
+	for _, record := range records {
+		verifier.add(record)
+	}
+	verifier.finish()
+	return ret
+}
+
+func TestAuditChainVerifier(t *testing.T) {
+	now := time.Now()
+	chainId := "srv_1_nonhttp"
+	checkpointAt := func(chain []*auditChainRecord, seq int) map[string][]auditCheckpoint {
+		record := chain[seq-1]
+		return map[string][]auditCheckpoint{chainId: {{ChainId: chainId, Seq: record.Seq, Hash: record.Hash,
+			EventTime: record.CreateTime, CreateTime: record.CreateTime}}}
+	}
+
+	tests := []struct {
+		name      string
+		retention int
+		update    func(chain []*auditChainRecord) ([]*auditChainRecord, map[string][]auditCheckpoint)
+		errors    []string
+	}{
+		{name: "valid", update: func(chain []*auditChainRecord) ([]*auditChainRecord, map[string][]auditCheckpoint) {
+			return chain, checkpointAt(chain, 5)
+		}},
+		{name: "modified", update: func(chain []*auditChainRecord) ([]*auditChainRecord, map[string][]auditCheckpoint) {
+			chain[2].UserId = "other"
+			return chain, nil
+		}, errors: []string{"event seq 3 (rid rid) has been modified"}},
+		{name: "rehashed", update: func(chain []*auditChainRecord) ([]*auditChainRecord, map[string][]auditCheckpoint) {
+			// The attacker recomputes the hashes after the edit, the checkpoint does not match
+			checkpoints := checkpointAt(chain, 5)
+			chain[2].UserId = "other"
+			for i := 2; i < len(chain); i++ {
+				chain[i].PrevHash = chain[i-1].Hash
+				chain[i].Hash = chain[i].computeHash()
+			}
+			return chain, checkpoints
+		}, errors: []string{"event seq 5 does not match the signed checkpoint"}},
+		{name: "deleted", update: func(chain []*auditChainRecord) ([]*auditChainRecord, map[string][]auditCheckpoint) {
+			return append(chain[:2:2], chain[3:]...), nil
+		}, errors: []string{"events seq 3 to 3 are missing"}},
+		{name: "unlinked", update: func(chain []*auditChainRecord) ([]*auditChainRecord, map[string][]auditCheckpoint) {
+			chain[4].PrevHash = "abc"
+			chain[4].Hash = chain[4].computeHash()
+			return chain, nil
+		}, errors: []string{"event seq 5 does not link to the previous event"}},
+		{name: "truncated", update: func(chain []*auditChainRecord) ([]*auditChainRecord, map[string][]auditCheckpoint) {
+			return chain[:3], checkpointAt(chain, 5)
+		}, errors: []string{"events seq 4 to 5 were deleted, the checkpoint has seq 5"}},
+		{name: "head deleted no retention", update: func(chain []*auditChainRecord) ([]*auditChainRecord, map[string][]auditCheckpoint) {
+			return chain[2:], nil
+		}, errors: []string{"events seq 1 to 2 are missing, retention cleanup is not enabled"}},
+		{name: "head deleted within retention", retention: 30, update: func(chain []*auditChainRecord) ([]*auditChainRecord, map[string][]auditCheckpoint) {
+			return chain[2:], checkpointAt(chain, 2)
+		}, errors: []string{"the checkpoint at seq 2 is within the retention period"}},
+		{name: "all deleted", update: func(chain []*auditChainRecord) ([]*auditChainRecord, map[string][]auditCheckpoint) {
+			return nil, checkpointAt(chain, 5)
+		}, errors: []string{"all events were deleted, the checkpoint has seq 5"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			records, checkpoints := tc.update(newTestChain(chainId, 5, now))
+			ret := runTestVerifier(now, tc.retention, checkpoints, records)
+			testutil.AssertEqualsBool(t, "verified", len(tc.errors) == 0, ret.Verified)
+			testutil.AssertEqualsInt(t, "errors", len(tc.errors), len(ret.Errors))
+			for i, e := range tc.errors {
+				testutil.AssertStringContains(t, ret.Errors[i], e)
+			}
+		})
+	}
+
+	// Events older than the retention period were deleted by the cleanup
+	old := newTestChain(chainId, 5, now.Add(-40*24*time.Hour))
+	recent := newTestChain(chainId, 8, now)[5:]
+	ret := runTestVerifier(now, 30, checkpointAt(old, 3), append(old[3:4], recent...))
+	testutil.AssertEqualsBool(t, "verified", true, ret.Verified)
+	testutil.AssertEqualsInt(t, "warnings", 1, len(ret.Warnings))
+	testutil.AssertStringContains(t, ret.Warnings[0], "events seq 5 to 5 were deleted by the retention cleanup")
+	testutil.AssertEqualsInt(t, "first seq", 4, int(ret.Chains[0].FirstSeq))
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	server, db, ctx := newApplyTestServer(t)
+	defer db.Close()
+	keyring, err := NewKeyring(server.Logger, NewInmemoryKVStore(), "", nil)
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, keyring.Init(context.Background(), nil))
+	server.keyring = keyring
+	if err := server.initAuditDB("sqlite:" + filepath.Join(t.TempDir(), "audit.db")); err != nil {
+		t.Fatalf("init audit db: %v", err)
+	}
+	defer func() {
+		server.stopAuditWriter()
+		_ = server.auditDB.Close()
+	}()
+
+	// A legacy event from before the hash chain
+	if _, err := server.auditDB.Exec("insert into audit (rid, app_id, create_time, user_id, event_type, operation, target, status, detail) values (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		"rid_old", "", time.Now().Add(-time.Hour).UnixNano(), "admin", "system", "reload_apps", "/apps", "Success", ""); err != nil {
+		t.Fatal(err)
+	}
+	for i, eventType := range []types.EventType{types.EventTypeSystem, types.EventTypeHTTP, types.EventTypeSystem, types.EventTypeCustom} {
+		testutil.AssertNoError(t, server.InsertAuditEvent(&types.AuditEvent{RequestId: "rid_" + string(rune('a'+i)),
+			CreateTime: time.Now(), UserId: "admin", EventType: eventType, Operation: "op", Status: "Success"}))
+	}
+	server.FlushAuditEvents()
+	testutil.AssertNoError(t, server.writeAuditCheckpoints())
+
+	ret, err := server.VerifyAuditLog(ctx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsBool(t, "verified", true, ret.Verified)
+	testutil.AssertEqualsInt(t, "chains", 2, len(ret.Chains))
+	testutil.AssertEqualsInt(t, "unchained", 1, int(ret.Unchained))
+	for _, chain := range ret.Chains {
+		testutil.AssertEqualsInt(t, "checkpoint seq "+chain.ChainId, int(chain.LastSeq), int(chain.CheckpointSeq))
+	}
+
+	// Edit an event, delete the last event of the other chain
+	_, err = server.auditDB.Exec("update audit set user_id = 'other' where rid = 'rid_c'")
+	testutil.AssertNoError(t, err)
+	_, err = server.auditDB.Exec("delete from audit where rid = 'rid_b'")
+	testutil.AssertNoError(t, err)
+	ret, err = server.VerifyAuditLog(ctx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsBool(t, "verified", false, ret.Verified)
+	errors := strings.Join(ret.Errors, "\n")
+	testutil.AssertStringContains(t, errors, "event seq 2 (rid rid_c) has been modified")
+	testutil.AssertStringContains(t, errors, "_http: all events were deleted")
+
+	// A forged checkpoint is reported
+	_, err = server.auditDB.Exec("update audit_checkpoint set seq = 10")
+	testutil.AssertNoError(t, err)
+	ret, err = server.VerifyAuditLog(ctx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertStringContains(t, strings.Join(ret.Errors, "\n"), "has an invalid signature")
+}
@@ -12,6 +12,7 @@ import (
 	"time"
 
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/openrundev/openrun/internal/auditsink"
 	"github.com/openrundev/openrun/internal/system"
 	"github.com/openrundev/openrun/internal/types"
 	"github.com/segmentio/ksuid"
@@ -38,6 +39,22 @@ func (s *Server) initAuditDB(connectString string) error {
 		return err
 	}
 
+	s.auditForwarder, err = auditsink.NewForwarder(s.Logger, s.Config().AuditSink, func(input string) (string, error) {
+		if secretsMgr := s.secretsMgr(); secretsMgr != nil {
+			return secretsMgr.EvalTemplate(input)
+		}
+		return input, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if archive := s.Config().System.AuditArchive; archive != "" {
+		if s.auditArchiver, err = auditsink.NewArchiver(context.Background(), archive); err != nil {
+			return err
+		}
+	}
+
 	s.auditEvents = make(chan *types.AuditEvent, AUDIT_QUEUE_SIZE)
 	s.auditFlush = make(chan chan struct{})
 	s.auditStop = make(chan struct{})
@@ -46,10 +63,13 @@ func (s *Server) initAuditDB(connectString string) error {
 
 	cleanupTicker := time.NewTicker(1 * time.Hour)
 	go s.auditCleanupLoop(cleanupTicker)
+	if mins := s.Config().System.AuditCheckpointMins; mins > 0 && s.keyring != nil {
+		go s.auditCheckpointLoop(time.NewTicker(time.Duration(mins) * time.Minute))
+	}
 	return nil
 }
 
-const CURRENT_AUDIT_DB_VERSION = 2
+const CURRENT_AUDIT_DB_VERSION = 3
 
 func (s *Server) versionUpgradeAuditDB() error {
 	version := 0
@@ -108,6 +128,27 @@ func (s *Server) versionUpgradeAuditDB() error {
 		}
 	}
 
+	if version < 3 {
+		s.Info().Msg("Upgrading audit DB to version 3")
+		// Hash chain columns for tamper evidence, the events written before the upgrade are not chained
+		for _, stmt := range []string{
+			`alter table audit add column chain_id text`,
+			`alter table audit add column seq bigint`,
+			`alter table audit add column prev_hash text`,
+			`alter table audit add column hash text`,
+			`create index IF NOT EXISTS idx_chain_audit ON audit (chain_id, seq)`,
+			`create table IF NOT EXISTS audit_checkpoint (chain_id text, seq bigint, hash text, event_time bigint, ` +
+				`create_time bigint, key_id text, signature text)`,
+		} {
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `update audit_version set version=3, last_upgraded=`+system.FuncNow(s.auditDbType)); err != nil {
+			return err
+		}
+	}
+
 	if err := tx.Commit(); err != nil {
 		return err
 	}
@@ -129,6 +170,9 @@ const (
 // event is queued, callers can reuse the struct. Call FlushAuditEvents before
 // reading the audit table to see previously queued events.
 func (s *Server) InsertAuditEvent(event *types.AuditEvent) error {
+	// The forwarder queues a copy for the external sinks, it never blocks
+	s.auditForwarder.Forward(event)
+
 	if s.auditEvents == nil {
 		// Audit writer is not running (Server built directly in tests), write synchronously
 		return s.insertAuditEventDB(event)
@@ -160,10 +204,10 @@ func (s *Server) InsertAuditEvent(event *types.AuditEvent) error {
 }
 
 func (s *Server) insertAuditEventDB(event *types.AuditEvent) error {
-	_, err := s.auditDB.Exec(system.RebindQuery(s.auditDbType, `insert into audit (rid, app_id, create_time, user_id, event_type, operation, target, status, detail) `+
-		`values (?, ?, ?, ?, ?, ?, ?, ?, ?)`),
-		event.RequestId, event.AppId, event.CreateTime.UnixNano(), event.UserId, event.EventType, event.Operation, event.Target, event.Status, event.Detail)
-	return err
+	return s.withAuditChain(func() error {
+		_, err := s.auditDB.Exec(system.RebindQuery(s.auditDbType, auditInsertQuery), s.linkAuditEvent(event).values()...)
+		return err
+	})
 }
 
 // FlushAuditEvents blocks until all audit events queued before the call have
@@ -192,6 +236,12 @@ func (s *Server) stopAuditWriter() {
 	<-s.auditDone
 	// Drain events enqueued by writers that raced with the shutdown
 	s.writeAllQueuedAuditEvents(nil)
+	if s.Config().System.AuditCheckpointMins > 0 {
+		if err := s.writeAuditCheckpoints(); err != nil {
+			s.Error().Err(err).Msg("error writing audit checkpoint")
+		}
+	}
+	s.auditForwarder.Close()
 }
 
 func (s *Server) auditWriterLoop() {
@@ -247,28 +297,26 @@ func (s *Server) writeAuditBatch(batch []*types.AuditEvent) {
 		return
 	}
 
-	err := func() error {
+	err := s.withAuditChain(func() error {
 		tx, err := s.auditDB.Begin()
 		if err != nil {
 			return err
 		}
 		defer tx.Rollback() //nolint:errcheck
 
-		stmt, err := tx.Prepare(system.RebindQuery(s.auditDbType, `insert into audit (rid, app_id, create_time, user_id, event_type, operation, target, status, detail) `+
-			`values (?, ?, ?, ?, ?, ?, ?, ?, ?)`))
+		stmt, err := tx.Prepare(system.RebindQuery(s.auditDbType, auditInsertQuery))
 		if err != nil {
 			return err
 		}
 		defer stmt.Close() //nolint:errcheck
 
 		for _, event := range batch {
-			if _, err := stmt.Exec(event.RequestId, event.AppId, event.CreateTime.UnixNano(), event.UserId,
-				event.EventType, event.Operation, event.Target, event.Status, event.Detail); err != nil {
+			if _, err := stmt.Exec(s.linkAuditEvent(event).values()...); err != nil {
 				return err
 			}
 		}
 		return tx.Commit()
-	}()
+	})
 	if err != nil {
 		s.Error().Err(err).Int("events", len(batch)).Msg("error inserting audit event batch, retrying individually")
 		// Retry events one at a time so one bad event does not drop the batch
@@ -280,33 +328,139 @@ func (s *Server) writeAuditBatch(batch []*types.AuditEvent) {
 	}
 }
 
+// retentionDays returns the effective retention for an event class, the lower of the class
+// setting and the overall audit_retention_days. Zero means the events are retained forever
+func retentionDays(classDays, auditDays int) int {
+	switch {
+	case classDays <= 0:
+		return max(auditDays, 0)
+	case auditDays <= 0:
+		return classDays
+	default:
+		return min(classDays, auditDays)
+	}
+}
+
 func (s *Server) cleanupEvents() error {
 	// A retention setting of zero or less disables cleanup for that event class
-	var httpDeleted, nonHttpDeleted int64
-	if days := s.Config().System.HttpEventRetentionDays; days > 0 {
-		cleanupTime := time.Now().Add(-time.Duration(days) * 24 * time.Hour).UnixNano()
-		result, err := s.auditDB.Exec(system.RebindQuery(s.auditDbType, `delete from audit where event_type = 'http' and create_time < ?`), cleanupTime)
+	config := s.Config().System
+	httpDeleted, err := s.pruneEvents("http", "event_type = 'http'",
+		retentionDays(config.HttpEventRetentionDays, config.AuditRetentionDays))
+	if err != nil {
+		return err
+	}
+	nonHttpDeleted, err := s.pruneEvents("nonhttp", "event_type != 'http'",
+		retentionDays(config.NonHttpEventRetentionDays, config.AuditRetentionDays))
+	if err != nil {
+		return err
+	}
+
+	s.Info().Msgf("audit cleanup: http deleted %d, non-http deleted %d", httpDeleted, nonHttpDeleted)
+	return nil
+}
+
+// auditArchiveChunkSize is the max number of events exported to one archive file
+var auditArchiveChunkSize = 50_000
+
+// pruneEvents deletes the events matching the condition which are older than the retention
+// days. If an archive is configured, the events are exported in create_time order before
+// they are deleted. An archive failure stops the pruning, the events are retried on the next run
+func (s *Server) pruneEvents(class, condition string, days int) (int64, error) {
+	if days <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().Add(-time.Duration(days) * 24 * time.Hour).UnixNano()
+	if s.auditArchiver == nil {
+		return s.deleteEvents(condition, cutoff)
+	}
+
+	var deleted int64
+	for {
+		records, err := s.selectEvents(condition, cutoff, auditArchiveChunkSize)
 		if err != nil {
-			return err
+			return deleted, err
 		}
-		if httpDeleted, err = result.RowsAffected(); err != nil {
-			return err
+		if len(records) == 0 {
+			return deleted, nil
+		}
+
+		upper := cutoff
+		fullChunk := len(records) == auditArchiveChunkSize
+		if fullChunk {
+			// Events with the last create_time could be split across chunks, leave them for
+			// the next chunk so that the delete does not remove events not yet archived
+			last := records[len(records)-1].CreateTime.UnixNano()
+			upper = last
+			for len(records) > 0 && records[len(records)-1].CreateTime.UnixNano() == last {
+				records = records[:len(records)-1]
+			}
+			if len(records) == 0 {
+				// All the events in the chunk have the same create_time, archive all of them
+				upper = last + 1
+				if records, err = s.selectEvents(condition, upper, 0); err != nil {
+					return deleted, err
+				}
+			}
 		}
-	}
 
-	if days := s.Config().System.NonHttpEventRetentionDays; days > 0 {
-		cleanupTime := time.Now().Add(-time.Duration(days) * 24 * time.Hour).UnixNano()
-		result, err := s.auditDB.Exec(system.RebindQuery(s.auditDbType, `delete from audit where event_type != 'http' and create_time < ?`), cleanupTime)
+		name := fmt.Sprintf("audit-%s-%s-%s.jsonl.gz", class, records[0].CreateTime.Format(auditArchiveTimeFormat),
+			records[len(records)-1].CreateTime.Format(auditArchiveTimeFormat))
+		if err := s.auditArchiver.Archive(context.Background(), name, records); err != nil {
+			return deleted, fmt.Errorf("error archiving audit events to %s: %w", s.auditArchiver.Target(), err)
+		}
+		count, err := s.deleteEvents(condition, upper)
 		if err != nil {
-			return err
+			return deleted, err
 		}
-		if nonHttpDeleted, err = result.RowsAffected(); err != nil {
-			return err
+		deleted += count
+		s.Debug().Str("file", name).Int("events", len(records)).Msg("archived audit events")
+		if !fullChunk {
+			return deleted, nil
 		}
 	}
+}
 
-	s.Info().Msgf("audit cleanup: http deleted %d, non-http deleted %d", httpDeleted, nonHttpDeleted)
-	return nil
+const auditArchiveTimeFormat = "20060102T150405.000000000Z"
+
+// selectEvents returns the events matching the condition created before the upper time, oldest
+// first. A limit of zero returns all the matching events
+func (s *Server) selectEvents(condition string, upper int64, limit int) ([]auditsink.Record, error) {
+	query := `select rid, app_id, create_time, user_id, event_type, operation, target, status, detail from audit where ` +
+		condition + ` and create_time < ? order by create_time`
+	params := []any{upper}
+	if limit > 0 {
+		query += " limit ?"
+		params = append(params, limit)
+	}
+	rows, err := s.auditDB.Query(system.RebindQuery(s.auditDbType, query), params...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	records := []auditsink.Record{}
+	for rows.Next() {
+		var record auditsink.Record
+		var createTime int64
+		if err := rows.Scan(&record.Rid, &record.AppId, &createTime, &record.UserId, &record.EventType,
+			&record.Operation, &record.Target, &record.Status, &record.Detail); err != nil {
+			return nil, err
+		}
+		record.CreateTime = time.Unix(0, createTime).UTC()
+		records = append(records, record)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return records, nil
+}
+
+func (s *Server) deleteEvents(condition string, upper int64) (int64, error) {
+	result, err := s.auditDB.Exec(system.RebindQuery(s.auditDbType, `delete from audit where `+condition+` and create_time < ?`), upper)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 func (s *Server) auditCleanupLoop(cleanupTicker *time.Ticker) {
@@ -4,9 +4,18 @@
 package server
 
 import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/openrundev/openrun/internal/auditsink"
 	"github.com/openrundev/openrun/internal/system"
 )
 
@@ -32,3 +41,89 @@ func TestNewBackgroundOperationContext(t *testing.T) {
 		t.Fatalf("background context user id %q, want scheduler", user)
 	}
 }
+
+func TestRetentionDays(t *testing.T) {
+	for _, test := range []struct{ class, audit, expected int }{
+		{90, 0, 90},
+		{90, 30, 30},
+		{30, 90, 30},
+		{0, 30, 30},
+		{-1, 0, 0},
+		{0, -1, 0},
+	} {
+		if got := retentionDays(test.class, test.audit); got != test.expected {
+			t.Errorf("retentionDays(%d, %d) = %d, expected %d", test.class, test.audit, got, test.expected)
+		}
+	}
+}
+
+func TestPruneEventsArchive(t *testing.T) {
+	server, db, _ := newApplyTestServer(t)
+	defer db.Close()
+	if err := server.initAuditDB("sqlite:" + filepath.Join(t.TempDir(), "audit.db")); err != nil {
+		t.Fatalf("init audit db: %v", err)
+	}
+	defer func() {
+		server.stopAuditWriter()
+		_ = server.auditDB.Close()
+	}()
+	archiveDir := t.TempDir()
+	archiver, err := auditsink.NewArchiver(context.Background(), archiveDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.auditArchiver = archiver
+
+	oldChunkSize := auditArchiveChunkSize
+	auditArchiveChunkSize = 2
+	defer func() { auditArchiveChunkSize = oldChunkSize }()
+
+	// Five old events, the second and third have the same create time, plus one recent event
+	old := time.Now().Add(-40 * 24 * time.Hour)
+	times := []time.Time{old, old.Add(time.Second), old.Add(time.Second), old.Add(2 * time.Second), old.Add(3 * time.Second), time.Now()}
+	for i, createTime := range times {
+		if _, err := server.auditDB.Exec(
+			"insert into audit (rid, app_id, create_time, user_id, event_type, operation, target, status, detail) values (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			"rid_"+strconv.Itoa(i), "", createTime.UnixNano(), "admin", "system", "reload_apps", "/apps", "success", ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deleted, err := server.pruneEvents("nonhttp", "event_type != 'http'", 30)
+	if err != nil || deleted != 5 {
+		t.Fatalf("prune = %d, %v", deleted, err)
+	}
+	var remaining int
+	if err := server.auditDB.QueryRow("select count(*) from audit").Scan(&remaining); err != nil || remaining != 1 {
+		t.Fatalf("remaining = %d, %v", remaining, err)
+	}
+
+	files, err := filepath.Glob(filepath.Join(archiveDir, "audit-nonhttp-*.jsonl.gz"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rids := []string{}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoder := json.NewDecoder(gz)
+		for decoder.More() {
+			var record auditsink.Record
+			if err := decoder.Decode(&record); err != nil {
+				t.Fatal(err)
+			}
+			rids = append(rids, record.Rid)
+		}
+		_ = f.Close()
+	}
+	slices.Sort(rids)
+	if strings.Join(rids, ",") != "rid_0,rid_1,rid_2,rid_3,rid_4" {
+		t.Fatalf("archived events = %v, files %v", rids, files)
+	}
+}
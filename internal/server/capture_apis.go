@@ -0,0 +1,242 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// getCaptureAppEntry loads the app entry for the capture APIs. Captured traffic
+// can include user data, so all the capture operations need app:manage
+func (s *Server) getCaptureAppEntry(ctx context.Context, appPath string) (*types.AppEntry, error) {
+	appPathDomain, err := parseAppPath(appPath)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	appEntry, err := s.db.GetAppEntryTx(ctx, tx, appPathDomain)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.enforceAppPermEntry(ctx, types.PermissionAppManage, appEntry); err != nil {
+		return nil, err
+	}
+	return appEntry, nil
+}
+
+// CaptureStart starts recording the requests served by the app on this server node
+func (s *Server) CaptureStart(ctx context.Context, appPath string, opts app.CaptureOptions) (*types.CaptureStatus, error) {
+	appEntry, err := s.getCaptureAppEntry(ctx, appPath)
+	if err != nil {
+		return nil, err
+	}
+
+	status := s.captures.Start(appEntry.Id, opts)
+	status.AppPath = appEntry.AppPathDomain().String()
+	s.Info().Str("app", status.AppPath).Msgf("Started traffic capture till %s", status.EndTime)
+	return &status, nil
+}
+
+// CaptureStop stops the recording for the app. If clearEntries is set, the recorded entries are discarded
+func (s *Server) CaptureStop(ctx context.Context, appPath string, clearEntries bool) (*types.CaptureStatus, error) {
+	appEntry, err := s.getCaptureAppEntry(ctx, appPath)
+	if err != nil {
+		return nil, err
+	}
+
+	status, ok := s.captures.Stop(appEntry.Id, clearEntries)
+	if !ok {
+		return nil, types.CreateRequestError(fmt.Sprintf("no capture found for app %s", appPath), http.StatusNotFound)
+	}
+	status.AppPath = appEntry.AppPathDomain().String()
+	return &status, nil
+}
+
+// CaptureDownload returns the entries recorded for the app
+func (s *Server) CaptureDownload(ctx context.Context, appPath string) (*types.CaptureDownloadResponse, error) {
+	appEntry, err := s.getCaptureAppEntry(ctx, appPath)
+	if err != nil {
+		return nil, err
+	}
+
+	status, entries, ok := s.captures.Entries(appEntry.Id)
+	if !ok {
+		return nil, types.CreateRequestError(fmt.Sprintf("no capture found for app %s", appPath), http.StatusNotFound)
+	}
+	status.AppPath = appEntry.AppPathDomain().String()
+	return &types.CaptureDownloadResponse{Status: status, Entries: entries}, nil
+}
+
+// CaptureReplay replays the requests recorded for a prod app against its stage app and
+// reports the differences in the responses. Only GET/HEAD requests are replayed unless
+// includeWrites is set, since replaying writes can modify the stage app data
+func (s *Server) CaptureReplay(ctx context.Context, appPath string, includeWrites bool) (*types.CaptureReplayResponse, error) {
+	appEntry, err := s.getCaptureAppEntry(ctx, appPath)
+	if err != nil {
+		return nil, err
+	}
+	if appEntry.IsDev {
+		return nil, fmt.Errorf("replay not supported for dev app")
+	}
+
+	_, entries, ok := s.captures.Entries(appEntry.Id)
+	if !ok {
+		return nil, types.CreateRequestError(fmt.Sprintf("no capture found for app %s", appPath), http.StatusNotFound)
+	}
+
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	stageEntry, err := s.getStageApp(ctx, tx, appEntry)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.enforceAppPermEntry(ctx, types.PermissionAppManage, stageEntry); err != nil {
+		return nil, err
+	}
+	stageApp, err := s.GetApp(ctx, stageEntry.AppPathDomain(), true)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &types.CaptureReplayResponse{
+		StageAppPath: stageEntry.AppPathDomain().String(),
+		Results:      make([]types.CaptureReplayResult, 0, len(entries)),
+	}
+	for _, entry := range entries {
+		result := s.replayCaptureEntry(ctx, stageApp, stageEntry, entry, includeWrites)
+		switch {
+		case result.Skipped:
+			ret.Skipped++
+		case result.Error == "" && result.ReplayStatus == result.CapturedStatus:
+			ret.Matched++
+		default:
+			ret.Mismatched++
+		}
+		ret.Results = append(ret.Results, result)
+	}
+	return ret, nil
+}
+
+func (s *Server) replayCaptureEntry(ctx context.Context, stageApp http.Handler, stageEntry *types.AppEntry,
+	entry types.CaptureEntry, includeWrites bool) types.CaptureReplayResult {
+	result := types.CaptureReplayResult{
+		Method:         entry.Method,
+		Path:           entry.Path,
+		CapturedStatus: entry.Status,
+	}
+	if !includeWrites && entry.Method != http.MethodGet && entry.Method != http.MethodHead {
+		result.Skipped = true
+		return result
+	}
+	if entry.RequestBodyTruncated {
+		result.Skipped = true
+		result.Error = "request body was truncated during capture"
+		return result
+	}
+
+	target := strings.TrimSuffix(stageEntry.Path, "/") + entry.Path
+	if entry.Query != "" {
+		target += "?" + entry.Query
+	}
+
+	// The replay runs as the user calling the API, with the stage app id in the context
+	replayCtx := &authContext{
+		Context:     ctx,
+		userId:      system.GetContextUserId(ctx),
+		appId:       string(stageEntry.Id),
+		pathDomain:  mainAppPathDomain(stageEntry.AppPathDomain(), stageEntry.MainApp, stageEntry.LinkedAppPath),
+		customPerms: make([]string, 0),
+	}
+	req, err := http.NewRequestWithContext(replayCtx, entry.Method, target, bytes.NewReader(entry.RequestBody))
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	for key, values := range entry.RequestHeaders {
+		for _, v := range values {
+			if v != app.CAPTURE_REDACTED {
+				req.Header.Add(key, v)
+			}
+		}
+	}
+	req.Host = cmp.Or(stageEntry.Domain, req.Host)
+
+	rw := newReplayResponseWriter(len(entry.ResponseBody))
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				result.Error = fmt.Sprintf("panic during replay: %v", r)
+			}
+		}()
+		stageApp.ServeHTTP(rw, req)
+	}()
+
+	result.ReplayStatus = rw.status
+	if result.ReplayStatus == 0 {
+		result.ReplayStatus = http.StatusOK
+	}
+	result.BodyMatch = !entry.ResponseBodyTruncated && !rw.overflow && bytes.Equal(rw.body.Bytes(), entry.ResponseBody)
+	return result
+}
+
+// replayResponseWriter records the status and the start of the response body,
+// enough to compare against the captured response
+type replayResponseWriter struct {
+	header   http.Header
+	status   int
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func newReplayResponseWriter(limit int) *replayResponseWriter {
+	return &replayResponseWriter{header: http.Header{}, limit: limit}
+}
+
+func (r *replayResponseWriter) Header() http.Header {
+	return r.header
+}
+
+func (r *replayResponseWriter) WriteHeader(statusCode int) {
+	if r.status == 0 {
+		r.status = statusCode
+	}
+}
+
+func (r *replayResponseWriter) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	remaining := r.limit - r.body.Len()
+	if len(b) > remaining {
+		r.overflow = true
+		if remaining > 0 {
+			r.body.Write(b[:remaining])
+		}
+		return len(b), nil
+	}
+	r.body.Write(b)
+	return len(b), nil
+}
+
+func (r *replayResponseWriter) Flush() {}
@@ -0,0 +1,423 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/passwd"
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	slackMaxRequestAge    = 5 * time.Minute // max clock difference for the request timestamp, limits replays
+	slackMaxBody          = 1 << 20
+	slackResponseUrl      = "https://hooks.slack.com/"
+	chatOpsConfirmTimeout = 5 * time.Minute
+	chatOpsCommandTimeout = 10 * time.Minute
+	chatOpsMaxApps        = 50 // max apps listed in one response
+)
+
+const chatOpsHelp = "Usage: /openrun <command> <appPathGlob>\n" +
+	"  list [appPathGlob]     list the apps, default all\n" +
+	"  reload <appPathGlob>   reload the apps from source, to the stage app for apps with staging\n" +
+	"  promote <appPathGlob>  promote the stage apps to prod\n" +
+	"  approve <appPathGlob>  show the pending plugin and permission approvals, to be confirmed\n" +
+	"  confirm <code>         confirm the approve\n" +
+	"  help                   show this message"
+
+// slackResponse is the slash command response message. Ephemeral messages are shown to
+// the user only, in_channel messages are shown to everyone in the channel
+type slackResponse struct {
+	ResponseType string `json:"response_type"`
+	Text         string `json:"text"`
+}
+
+func slackEphemeral(format string, args ...any) *slackResponse {
+	return &slackResponse{ResponseType: "ephemeral", Text: fmt.Sprintf(format, args...)}
+}
+
+// chatOpsConfirm is an approve command waiting for confirmation. The approval summary is
+// checked again on confirm, the apps are not approved if the pending approvals changed
+type chatOpsConfirm struct {
+	slackUser   string
+	appPathGlob string
+	summary     string
+	expiry      time.Time
+}
+
+// verifySlackSignature checks the request signature using the signing secret, see
+// https://api.slack.com/authentication/verifying-requests-from-slack
+func verifySlackSignature(secret, timestamp, signature string, body []byte, now time.Time) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid slack request timestamp %q", timestamp)
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > slackMaxRequestAge || age < -slackMaxRequestAge {
+		return fmt.Errorf("slack request timestamp is outside the allowed window")
+	}
+
+	hm := hmac.New(sha256.New, []byte(secret))
+	hm.Write([]byte("v0:" + timestamp + ":"))
+	hm.Write(body)
+	expected := "v0=" + hex.EncodeToString(hm.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("invalid slack request signature")
+	}
+	return nil
+}
+
+// slackHandler handles the Slack slash command. The request is authenticated using the signing
+// secret and the Slack user is mapped to an OpenRun user, the command runs as that user with
+// RBAC enforcement. Errors after authentication are returned as messages, since Slack shows
+// only a generic failure for non 200 responses
+func (h *Handler) slackHandler(w http.ResponseWriter, r *http.Request) {
+	config := h.server.Config().ChatOps.Slack
+	if !config.Enabled {
+		http.Error(w, "slack chatops is not enabled", http.StatusNotFound)
+		return
+	}
+
+	authFailure := func(msg string) {
+		h.server.insertAuthFailureEvent(r, "chatops_slack", msg)
+		http.Error(w, msg, http.StatusUnauthorized)
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, slackMaxBody)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error reading request body: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	secret, err := h.server.secretsMgr().EvalTemplate(config.SigningSecret)
+	if err != nil {
+		h.Error().Err(err).Msg("error resolving slack signing_secret")
+		http.Error(w, "error resolving slack signing secret", http.StatusInternalServerError)
+		return
+	}
+	if secret == "" {
+		authFailure("slack signing_secret is not configured")
+		return
+	}
+	if err := verifySlackSignature(secret, r.Header.Get("X-Slack-Request-Timestamp"),
+		r.Header.Get("X-Slack-Signature"), body, time.Now()); err != nil {
+		authFailure(err.Error())
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "error parsing slack command", http.StatusBadRequest)
+		return
+	}
+	if config.TeamId != "" && form.Get("team_id") != config.TeamId {
+		authFailure(fmt.Sprintf("slack workspace %s is not allowed", form.Get("team_id")))
+		return
+	}
+
+	var resp *slackResponse
+	slackUser := form.Get("user_id")
+	if userId := config.Users[slackUser]; userId == "" {
+		h.server.insertAuthFailureEvent(r, "chatops_slack", fmt.Sprintf("slack user %s is not mapped", slackUser))
+		resp = slackEphemeral("Slack user %s is not mapped to an OpenRun user, add it to chatops.slack.users in the server config", slackUser)
+	} else if ctx, err := h.server.asUserRequestContext(r.Context(), userId); err != nil {
+		resp = slackEphemeral("Error: %s", err)
+	} else {
+		resp = h.server.runChatOpsCommand(ctx, slackUser, form.Get("text"), form.Get("response_url"))
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		h.Error().Err(err).Msg("error encoding slack response")
+	}
+}
+
+// runChatOpsCommand runs the chat command as the user in ctx. list and approve return the
+// result directly. reload, promote and confirm change apps and can take longer than the Slack
+// response timeout, they run in the background and the result is posted to the response url
+func (s *Server) runChatOpsCommand(ctx context.Context, slackUser, text, responseUrl string) *slackResponse {
+	args := strings.Fields(text)
+	if len(args) == 0 || args[0] == "help" {
+		return slackEphemeral("%s", chatOpsHelp)
+	}
+
+	command := args[0]
+	switch command {
+	case "list":
+		if len(args) > 2 {
+			return slackEphemeral("list takes one optional argument: [appPathGlob]")
+		}
+		appPathGlob := "all"
+		if len(args) == 2 {
+			appPathGlob = args[1]
+		}
+		apps, err := s.GetApps(ctx, appPathGlob, false)
+		s.insertChatOpsEvent(ctx, slackUser, command, appPathGlob, err)
+		if err != nil {
+			return slackEphemeral("Error: %s", err)
+		}
+		return slackEphemeral("%s", formatChatOpsApps(apps))
+	case "reload", "promote", "approve":
+		if len(args) != 2 {
+			return slackEphemeral("%s requires one argument: <appPathGlob>", command)
+		}
+	case "confirm":
+		if len(args) != 2 {
+			return slackEphemeral("confirm requires one argument: <code>")
+		}
+	default:
+		return slackEphemeral("Unknown command %s\n%s", command, chatOpsHelp)
+	}
+
+	target := args[1]
+	if command == "approve" {
+		summary, err := s.chatOpsApproveSummary(ctx, target)
+		if err != nil {
+			s.insertChatOpsEvent(ctx, slackUser, command, target, err)
+			return slackEphemeral("Error: %s", err)
+		}
+		if summary == "" {
+			s.insertChatOpsEvent(ctx, slackUser, command, target, nil)
+			return slackEphemeral("No pending approvals for %s", target)
+		}
+		code, err := s.addChatOpsConfirm(slackUser, target, summary)
+		s.insertChatOpsEvent(ctx, slackUser, command, target, err)
+		if err != nil {
+			return slackEphemeral("Error: %s", err)
+		}
+		return slackEphemeral("Pending approvals:\n%s\nRun `/openrun confirm %s` within %s to approve",
+			summary, code, chatOpsConfirmTimeout)
+	}
+
+	if !strings.HasPrefix(responseUrl, slackResponseUrl) {
+		return slackEphemeral("Error: invalid response url")
+	}
+	if command == "confirm" {
+		confirm, err := s.takeChatOpsConfirm(slackUser, target)
+		if err != nil {
+			s.insertChatOpsEvent(ctx, slackUser, command, target, err)
+			return slackEphemeral("Error: %s", err)
+		}
+		target = confirm.appPathGlob
+		go s.runChatOpsBackground(ctx, slackUser, command, target, responseUrl, func(ctx context.Context) (string, error) {
+			// The approvals could have changed after the summary was shown, like with a reload
+			summary, err := s.chatOpsApproveSummary(ctx, confirm.appPathGlob)
+			if err != nil {
+				return "", err
+			}
+			if summary != confirm.summary {
+				return "", fmt.Errorf("pending approvals for %s changed after the approve command, run approve again", confirm.appPathGlob)
+			}
+			if _, err := s.ApproveApps(ctx, confirm.appPathGlob, false, false); err != nil {
+				return "", err
+			}
+			return fmt.Sprintf("approved %s:\n%s", confirm.appPathGlob, summary), nil
+		})
+		return slackEphemeral("Approving %s", target)
+	}
+
+	go s.runChatOpsBackground(ctx, slackUser, command, target, responseUrl, func(ctx context.Context) (string, error) {
+		if command == "reload" {
+			resp, err := s.ReloadApps(ctx, target, false, false, false, "", "", "", false, false)
+			if err != nil {
+				return "", err
+			}
+			s.CleanupVersions()
+			return fmt.Sprintf("reloaded %s, skipped (no changes) %s",
+				formatChatOpsPaths(resp.ReloadResults), formatChatOpsPaths(resp.SkippedResults)), nil
+		}
+		resp, err := s.PromoteApps(ctx, target, false, false, false)
+		if err != nil {
+			return "", err
+		}
+		return "promoted " + formatChatOpsPaths(resp.PromoteResults), nil
+	})
+	return slackEphemeral("Running %s for %s", command, target)
+}
+
+// runChatOpsBackground runs the command without the request deadline, audits it and posts the
+// result to the Slack response url. Successful changes are posted to the channel
+func (s *Server) runChatOpsBackground(ctx context.Context, slackUser, command, target, responseUrl string,
+	runFunc func(ctx context.Context) (string, error)) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), chatOpsCommandTimeout)
+	defer cancel()
+
+	result, err := runFunc(ctx)
+	s.insertChatOpsEvent(ctx, slackUser, command, target, err)
+	resp := &slackResponse{ResponseType: "in_channel", Text: fmt.Sprintf("<@%s> %s", slackUser, result)}
+	if err != nil {
+		resp = slackEphemeral("Error running %s for %s: %s", command, target, err)
+	}
+
+	if err := postSlackResponse(ctx, responseUrl, resp); err != nil {
+		s.Warn().Err(err).Msgf("Error posting slack response for %s", command)
+	}
+}
+
+func postSlackResponse(ctx context.Context, responseUrl string, resp *slackResponse) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseUrl, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	httpResp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close() //nolint:errcheck
+	if httpResp.StatusCode >= 300 {
+		return fmt.Errorf("slack response url returned %s", httpResp.Status)
+	}
+	return nil
+}
+
+// chatOpsApproveSummary returns the pending approvals for the apps, empty if there are none
+func (s *Server) chatOpsApproveSummary(ctx context.Context, appPathGlob string) (string, error) {
+	resp, err := s.ApproveApps(ctx, appPathGlob, true, false)
+	if err != nil {
+		return "", err
+	}
+	// The staged update results are the handler results, *types.ApproveResult for approve
+	stagedResults, _ := resp.StagedUpdateResults.([]any)
+	results := make([]*types.ApproveResult, 0, len(stagedResults))
+	for _, result := range stagedResults {
+		if approveResult, ok := result.(*types.ApproveResult); ok {
+			results = append(results, approveResult)
+		}
+	}
+	return formatChatOpsApprovals(results), nil
+}
+
+func formatChatOpsApprovals(results []*types.ApproveResult) string {
+	var buf strings.Builder
+	for _, result := range results {
+		if !result.NeedsApproval {
+			continue
+		}
+		fmt.Fprintf(&buf, "%s\n", result.AppPathDomain)
+		for _, load := range result.NewLoads {
+			fmt.Fprintf(&buf, "  plugin %s\n", load)
+		}
+		for _, perm := range result.NewPermissions {
+			fmt.Fprintf(&buf, "  permission %s.%s %s\n", perm.Plugin, perm.Method, perm.Arguments)
+		}
+		for _, env := range result.NewEnv {
+			fmt.Fprintf(&buf, "  env %s\n", env)
+		}
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func formatChatOpsApps(apps []types.AppResponse) string {
+	if len(apps) == 0 {
+		return "No apps found"
+	}
+	var buf strings.Builder
+	for i, app := range apps {
+		if i >= chatOpsMaxApps {
+			fmt.Fprintf(&buf, "... %d more apps, use a glob to filter", len(apps)-chatOpsMaxApps)
+			break
+		}
+		staged := ""
+		if app.StagedChanges {
+			staged = " (staged changes)"
+		}
+		fmt.Fprintf(&buf, "%s v%d %s%s\n", app.AppPathDomain(), app.Metadata.VersionMetadata.Version, app.SourceUrl, staged)
+	}
+	return strings.TrimSuffix(buf.String(), "\n")
+}
+
+func formatChatOpsPaths(apps []types.AppPathDomain) string {
+	if len(apps) == 0 {
+		return "none"
+	}
+	paths := make([]string, 0, min(len(apps), chatOpsMaxApps))
+	for i, app := range apps {
+		if i >= chatOpsMaxApps {
+			paths = append(paths, fmt.Sprintf("%d more", len(apps)-chatOpsMaxApps))
+			break
+		}
+		paths = append(paths, app.String())
+	}
+	return strings.Join(paths, ", ")
+}
+
+// addChatOpsConfirm saves the approve for confirmation and returns the confirmation code
+func (s *Server) addChatOpsConfirm(slackUser, appPathGlob, summary string) (string, error) {
+	key, err := passwd.GenerateRandomKey(4)
+	if err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(key)
+
+	s.chatOpsMu.Lock()
+	defer s.chatOpsMu.Unlock()
+	if s.chatOpsConfirms == nil {
+		s.chatOpsConfirms = make(map[string]*chatOpsConfirm)
+	}
+	for c, confirm := range s.chatOpsConfirms {
+		if time.Now().After(confirm.expiry) {
+			delete(s.chatOpsConfirms, c)
+		}
+	}
+	s.chatOpsConfirms[code] = &chatOpsConfirm{
+		slackUser:   slackUser,
+		appPathGlob: appPathGlob,
+		summary:     summary,
+		expiry:      time.Now().Add(chatOpsConfirmTimeout),
+	}
+	return code, nil
+}
+
+// takeChatOpsConfirm removes and returns the approve to confirm. The code can be confirmed
+// only by the Slack user who ran the approve
+func (s *Server) takeChatOpsConfirm(slackUser, code string) (*chatOpsConfirm, error) {
+	s.chatOpsMu.Lock()
+	defer s.chatOpsMu.Unlock()
+	confirm, ok := s.chatOpsConfirms[code]
+	if !ok || confirm.slackUser != slackUser || time.Now().After(confirm.expiry) {
+		return nil, fmt.Errorf("confirmation code %s not found, it might have expired", code)
+	}
+	delete(s.chatOpsConfirms, code)
+	return confirm, nil
+}
+
+// insertChatOpsEvent audits the chat command, with the Slack user id in the detail
+func (s *Server) insertChatOpsEvent(ctx context.Context, slackUser, command, target string, cmdErr error) {
+	event := types.AuditEvent{
+		RequestId:  system.GetContextRequestId(ctx),
+		CreateTime: time.Now(),
+		UserId:     system.GetContextUserId(ctx),
+		EventType:  types.EventTypeSystem,
+		Operation:  "chatops_" + command,
+		Target:     target,
+		Status:     string(types.EventStatusSuccess),
+		Detail:     "slack user " + slackUser,
+	}
+	if cmdErr != nil {
+		event.Status = string(types.EventStatusFailure)
+		event.Detail += ": " + cmdErr.Error()
+	}
+	if err := s.InsertAuditEvent(&event); err != nil {
+		s.Error().Err(err).Msg("error inserting audit event")
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestVerifySlackSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte("token=x&team_id=T1&user_id=U1&command=%2Fopenrun&text=list")
+	sign := func(secret string, ts time.Time) (string, string) {
+		timestamp := strconv.FormatInt(ts.Unix(), 10)
+		hm := hmac.New(sha256.New, []byte(secret))
+		hm.Write([]byte("v0:" + timestamp + ":" + string(body)))
+		return timestamp, "v0=" + hex.EncodeToString(hm.Sum(nil))
+	}
+
+	timestamp, signature := sign("secret", now.Add(-time.Minute))
+	testutil.AssertNoError(t, verifySlackSignature("secret", timestamp, signature, body, now))
+	testutil.AssertErrorContains(t, verifySlackSignature("other", timestamp, signature, body, now), "invalid slack request signature")
+	testutil.AssertErrorContains(t, verifySlackSignature("secret", timestamp, signature, append(body, 'x'), now), "invalid slack request signature")
+
+	timestamp, signature = sign("secret", now.Add(-10*time.Minute))
+	testutil.AssertErrorContains(t, verifySlackSignature("secret", timestamp, signature, body, now), "outside the allowed window")
+	testutil.AssertErrorContains(t, verifySlackSignature("secret", "", signature, body, now), "invalid slack request timestamp")
+}
+
+func TestChatOpsConfirm(t *testing.T) {
+	s := &Server{}
+	code, err := s.addChatOpsConfirm("U1", "/app1", "summary")
+	testutil.AssertNoError(t, err)
+
+	_, err = s.takeChatOpsConfirm("U2", code)
+	testutil.AssertErrorContains(t, err, "not found")
+	confirm, err := s.takeChatOpsConfirm("U1", code)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "glob", "/app1", confirm.appPathGlob)
+	_, err = s.takeChatOpsConfirm("U1", code)
+	testutil.AssertErrorContains(t, err, "not found")
+
+	code, err = s.addChatOpsConfirm("U1", "/app1", "summary")
+	testutil.AssertNoError(t, err)
+	s.chatOpsConfirms[code].expiry = time.Now().Add(-time.Second)
+	_, err = s.takeChatOpsConfirm("U1", code)
+	testutil.AssertErrorContains(t, err, "might have expired")
+}
+
+func TestFormatChatOpsApprovals(t *testing.T) {
+	summary := formatChatOpsApprovals([]*types.ApproveResult{
+		{AppPathDomain: types.AppPathDomain{Path: "/app1"}, NeedsApproval: false},
+		{AppPathDomain: types.AppPathDomain{Path: "/app2"}, NeedsApproval: true, NewLoads: []string{"exec.in"},
+			NewPermissions: []types.Permission{{Plugin: "exec.in", Method: "run", Arguments: []string{"ls"}}}},
+	})
+	testutil.AssertEqualsString(t, "summary", "/app2\n  plugin exec.in\n  permission exec.in.run [ls]", summary)
+	testutil.AssertEqualsString(t, "empty", "", formatChatOpsApprovals(nil))
+
+	testutil.AssertEqualsString(t, "paths", "none", formatChatOpsPaths(nil))
+	testutil.AssertEqualsString(t, "paths", "/app1, example.com:/app2",
+		formatChatOpsPaths([]types.AppPathDomain{{Path: "/app1"}, {Domain: "example.com", Path: "/app2"}}))
+}
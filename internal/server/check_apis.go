@@ -0,0 +1,297 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+	"golang.org/x/net/html"
+)
+
+const (
+	appCheckDefaultMaxPages = 50
+	appCheckMaxLinks        = 1000 // limit on the unique link targets requested in one check
+)
+
+// CheckApp crawls the HTML pages of the app, starting from the declared GET routes, and checks
+// for broken internal links and accessibility issues. For a prod app, the stage app is checked.
+// Only GET requests are made, forms are not submitted
+func (s *Server) CheckApp(ctx context.Context, appPath string, a11y, links bool, maxPages int) (*types.AppCheckResponse, error) {
+	appEntry, testApp, err := s.getTestApp(ctx, appPath)
+	if err != nil {
+		return nil, err
+	}
+
+	seeds := []string{}
+	for _, route := range testApp.HTMLRoutes() {
+		method, path, _ := strings.Cut(route, " ")
+		// Routes with path params cannot be requested without values, they are reached through links
+		if method == http.MethodGet && !strings.ContainsAny(path, "{*") {
+			seeds = append(seeds, path)
+		}
+	}
+	if len(seeds) == 0 {
+		return nil, fmt.Errorf("app %s has no HTML routes to check", appEntry.AppPathDomain())
+	}
+
+	reqCtx := &authContext{
+		Context:     ctx,
+		userId:      system.GetContextUserId(ctx),
+		appId:       string(appEntry.Id),
+		pathDomain:  mainAppPathDomain(appEntry.AppPathDomain(), appEntry.MainApp, appEntry.LinkedAppPath),
+		customPerms: make([]string, 0),
+	}
+	checker := newSiteChecker(reqCtx, testApp, cmp.Or(appEntry.Domain, s.Config().System.DefaultDomain, "localhost"),
+		appEntry.Path, a11y, links, cmp.Or(maxPages, appCheckDefaultMaxPages))
+	ret := checker.run(seeds)
+	ret.AppPath = appEntry.AppPathDomain().String()
+	s.Info().Str("app", ret.AppPath).Msgf("Checked app, %d pages, %d broken links, %d accessibility issues",
+		len(ret.Pages), len(ret.BrokenLinks), len(ret.Issues))
+	return ret, nil
+}
+
+// checkFetch is the result of requesting one app path
+type checkFetch struct {
+	status   int
+	location string
+	body     string // set for HTML responses only
+	isHTML   bool
+	err      string
+}
+
+func (f *checkFetch) broken() bool {
+	return f.err != "" || f.status >= http.StatusBadRequest
+}
+
+// siteChecker crawls the pages of an app in-process. The paths used are relative to the app path
+type siteChecker struct {
+	ctx      context.Context
+	handler  http.Handler
+	host     string
+	basePath string
+	a11y     bool
+	links    bool
+	maxPages int
+	fetched  map[string]*checkFetch
+}
+
+func newSiteChecker(ctx context.Context, handler http.Handler, host, basePath string, a11y, links bool, maxPages int) *siteChecker {
+	return &siteChecker{
+		ctx:      ctx,
+		handler:  handler,
+		host:     host,
+		basePath: strings.TrimSuffix(basePath, "/"),
+		a11y:     a11y,
+		links:    links,
+		maxPages: maxPages,
+		fetched:  map[string]*checkFetch{},
+	}
+}
+
+func (c *siteChecker) run(seeds []string) *types.AppCheckResponse {
+	ret := &types.AppCheckResponse{
+		Pages:       []string{},
+		BrokenLinks: []types.BrokenLink{},
+		Issues:      []types.AppCheckIssue{},
+		RuleCounts:  map[string]int{},
+	}
+	queued := map[string]bool{}
+	queue := []string{}
+	enqueue := func(path string) {
+		if !queued[path] {
+			queued[path] = true
+			queue = append(queue, path)
+		}
+	}
+	for _, seed := range seeds {
+		enqueue(seed)
+	}
+	seedCount := len(queue)
+	checked := map[string]bool{}
+
+	for i := 0; i < len(queue); i++ {
+		page := queue[i]
+		if len(ret.Pages) >= c.maxPages {
+			ret.Truncated = true
+			break
+		}
+		result := c.fetch(page)
+		if result.broken() {
+			if i < seedCount {
+				// Failures for linked pages are reported with the linking page
+				ret.BrokenLinks = append(ret.BrokenLinks, types.BrokenLink{Link: page, Status: result.status, Error: result.err})
+			}
+			continue
+		}
+		if result.location != "" {
+			if target, ok := c.resolve(page, result.location); ok {
+				enqueue(target)
+			}
+			continue
+		}
+		if !result.isHTML {
+			continue
+		}
+
+		ret.Pages = append(ret.Pages, page)
+		doc, err := html.Parse(strings.NewReader(result.body))
+		if err != nil {
+			continue
+		}
+		if c.a11y {
+			for _, issue := range checkAccessibility(doc, isFullDocument(result.body)) {
+				issue.Page = page
+				ret.Issues = append(ret.Issues, issue)
+				ret.RuleCounts[issue.Rule]++
+			}
+		}
+
+		reported := map[string]bool{}
+		for _, link := range extractLinks(doc) {
+			target, ok := c.resolve(page, link.url)
+			if !ok {
+				continue
+			}
+			if c.links && !reported[target] {
+				if c.fetched[target] == nil && len(c.fetched) >= appCheckMaxLinks {
+					ret.Truncated = true
+					continue
+				}
+				reported[target] = true
+				checked[target] = true
+				if linkResult := c.fetch(target); linkResult.broken() {
+					ret.BrokenLinks = append(ret.BrokenLinks,
+						types.BrokenLink{Page: page, Link: link.url, Status: linkResult.status, Error: linkResult.err})
+					continue
+				}
+			}
+			if link.navigable {
+				enqueue(target)
+			}
+		}
+	}
+	ret.LinksChecked = len(checked)
+	return ret
+}
+
+// fetch requests the app path through the app handler. Results are cached, each path is
+// requested at most once
+func (c *siteChecker) fetch(path string) *checkFetch {
+	if ret, ok := c.fetched[path]; ok {
+		return ret
+	}
+	ret := &checkFetch{}
+	c.fetched[path] = ret
+
+	req, err := http.NewRequestWithContext(c.ctx, http.MethodGet, "http://"+c.host+c.basePath+path, nil)
+	if err != nil {
+		ret.err = err.Error()
+		return ret
+	}
+	req.RemoteAddr = "127.0.0.1:0"
+	req.Header.Set("Accept", "text/html")
+
+	rw := newReplayResponseWriter(e2eMaxBodySize)
+	c.handler.ServeHTTP(rw, req)
+	ret.status = cmp.Or(rw.status, http.StatusOK)
+	if ret.status >= 300 && ret.status < 400 {
+		ret.location = rw.header.Get("Location")
+	}
+	if mediaType, _, _ := mime.ParseMediaType(rw.header.Get("Content-Type")); mediaType == "text/html" {
+		ret.isHTML = true
+		ret.body = rw.body.String()
+	}
+	return ret
+}
+
+// resolve resolves the link on the page and returns the app relative path (with the query
+// string) for links within the app. Links to other apps and external sites are not checked
+func (c *siteChecker) resolve(page, link string) (string, bool) {
+	link = strings.TrimSpace(link)
+	if link == "" || strings.HasPrefix(link, "#") {
+		return "", false
+	}
+	linkUrl, err := url.Parse(link)
+	if err != nil || (linkUrl.Scheme != "" && linkUrl.Scheme != "http" && linkUrl.Scheme != "https") {
+		return "", false
+	}
+	base, err := url.Parse("http://" + c.host + c.basePath + page)
+	if err != nil {
+		return "", false
+	}
+	target := base.ResolveReference(linkUrl)
+	if target.Host != c.host {
+		return "", false
+	}
+
+	path := target.Path
+	if c.basePath != "" {
+		if path != c.basePath && !strings.HasPrefix(path, c.basePath+"/") {
+			return "", false
+		}
+		path = strings.TrimPrefix(path, c.basePath)
+	}
+	if path == "" {
+		path = "/"
+	}
+	if target.RawQuery != "" {
+		path += "?" + target.RawQuery
+	}
+	return path, true
+}
+
+// pageLink is a reference from a page. Navigable links are crawled, the others (like images
+// and scripts) are only checked
+type pageLink struct {
+	url       string
+	navigable bool
+}
+
+func extractLinks(doc *html.Node) []pageLink {
+	ret := []pageLink{}
+	walkElements(doc, func(n *html.Node) {
+		var attrName string
+		navigable := false
+		switch n.Data {
+		case "a", "area":
+			attrName, navigable = "href", true
+		case "link":
+			attrName = "href"
+		case "img", "script", "iframe", "source", "audio", "video", "embed":
+			attrName = "src"
+		default:
+			return
+		}
+		if value, ok := getAttr(n, attrName); ok {
+			ret = append(ret, pageLink{url: value, navigable: navigable})
+		}
+	})
+	return ret
+}
+
+func walkElements(n *html.Node, fn func(*html.Node)) {
+	if n.Type == html.ElementNode {
+		fn(n)
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		walkElements(child, fn)
+	}
+}
+
+func getAttr(n *html.Node, key string) (string, bool) {
+	for _, attr := range n.Attr {
+		if attr.Key == key {
+			return attr.Val, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,106 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"golang.org/x/net/html"
+)
+
+func TestCheckAccessibility(t *testing.T) {
+	page := `<!doctype html>
+<html>
+<head><meta name="viewport" content="width=device-width, user-scalable=no"></head>
+<body>
+  <h1>Orders</h1>
+  <h3 id="list">List</h3>
+  <img src="/logo.png">
+  <img src="/spacer.png" alt="">
+  <a href="/next"><img src="/arrow.png" alt="Next page"></a>
+  <a href="/edit" class="icon"></a>
+  <button aria-label="Close"></button>
+  <button><span aria-hidden="true"></span></button>
+  <label for="name">Name</label><input id="name" name="name">
+  <label>Email <input name="email"></label>
+  <input name="search" placeholder="Search">
+  <input type="hidden" name="token">
+  <div id="list"></div>
+</body>
+</html>`
+	doc, err := html.Parse(strings.NewReader(page))
+	testutil.AssertNoError(t, err)
+	issues := checkAccessibility(doc, isFullDocument(page))
+
+	found := []string{}
+	for _, issue := range issues {
+		found = append(found, issue.Rule+" "+issue.Element)
+	}
+	testutil.AssertEqualsString(t, "issues", strings.Join([]string{
+		`meta-viewport <meta name="viewport">`,
+		`heading-order <h3 id="list">`,
+		`image-alt <img src="/logo.png">`,
+		`link-name <a href="/edit" class="icon">`,
+		`button-name <button>`,
+		`form-label <input name="search">`,
+		`duplicate-id <div id="list">`,
+		`html-lang <html>`,
+		`document-title <html>`,
+	}, "\n"), strings.Join(found, "\n"))
+	testutil.AssertEqualsString(t, "wcag", "1.4.4", issues[0].WCAG)
+
+	doc, err = html.Parse(strings.NewReader(`<div><img src="x.png" alt="x"></div>`))
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "partial", 0, len(checkAccessibility(doc, false)))
+}
+
+func TestSiteChecker(t *testing.T) {
+	pages := map[string]string{
+		"/myapp":                 `<html lang="en"><title>Home</title><a href="orders">Orders</a> <a href="/myapp/missing">Old</a> <a href="/other/x">Other app</a> <a href="https://example.com">Site</a>`,
+		"/myapp/orders":          `<html lang="en"><title>Orders</title><a href="/myapp">Home</a><img src="static/logo.png"><a href="/myapp/orders?page=2">Next</a>`,
+		"/myapp/static/logo.png": "",
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSuffix(r.URL.Path, "/")
+		if r.URL.Path == "/myapp/old" {
+			http.Redirect(w, r, "/myapp/orders", http.StatusFound)
+			return
+		}
+		body, ok := pages[path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if strings.HasSuffix(path, ".png") {
+			w.Header().Set("Content-Type", "image/png")
+		} else {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
+		io.WriteString(w, body) //nolint:errcheck
+	})
+
+	checker := newSiteChecker(context.Background(), handler, "localhost", "/myapp", true, true, 10)
+	ret := checker.run([]string{"/", "/old", "/deleted"})
+	testutil.AssertEqualsString(t, "pages", "/,/orders,/orders?page=2", strings.Join(ret.Pages, ","))
+	testutil.AssertEqualsInt(t, "broken", 2, len(ret.BrokenLinks))
+	testutil.AssertEqualsString(t, "broken link", "/myapp/missing", ret.BrokenLinks[0].Link)
+	testutil.AssertEqualsString(t, "broken link page", "/", ret.BrokenLinks[0].Page)
+	testutil.AssertEqualsInt(t, "broken status", 404, ret.BrokenLinks[0].Status)
+	testutil.AssertEqualsString(t, "broken route", "/deleted", ret.BrokenLinks[1].Link)
+	testutil.AssertEqualsString(t, "broken route page", "", ret.BrokenLinks[1].Page)
+	testutil.AssertEqualsInt(t, "links checked", 5, ret.LinksChecked)
+	testutil.AssertEqualsInt(t, "image alt", 2, ret.RuleCounts["image-alt"])
+	testutil.AssertEqualsBool(t, "truncated", false, ret.Truncated)
+
+	checker = newSiteChecker(context.Background(), handler, "localhost", "/myapp", false, false, 1)
+	ret = checker.run([]string{"/"})
+	testutil.AssertEqualsString(t, "limited", "/", strings.Join(ret.Pages, ","))
+	testutil.AssertEqualsBool(t, "limit truncated", true, ret.Truncated)
+	testutil.AssertEqualsInt(t, "no checks", 0, len(ret.Issues)+len(ret.BrokenLinks)+ret.LinksChecked)
+}
@@ -0,0 +1,285 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	commitStatusContext    = "openrun/sync"
+	commitStatusTimeout    = 30 * time.Second
+	commitStatusMaxApps    = 20  // max per app statuses posted for one sync run
+	commitStatusMaxDescLen = 140 // GitHub limit for the status description
+)
+
+const (
+	commitStatusGitHub = "github"
+	commitStatusGitLab = "gitlab"
+)
+
+// commitStatusTarget is the repo API to post the commit statuses to
+type commitStatusTarget struct {
+	provider string // github or gitlab
+	apiUrl   string
+	project  string // owner/repo, or the group path for GitLab
+	token    string
+}
+
+// commitStatus is one status to post on a commit, the context groups the statuses
+type commitStatus struct {
+	context     string
+	success     bool
+	description string
+	targetUrl   string
+}
+
+// parseCommitStatusRepo returns the host and the project path for the git apply path
+func parseCommitStatusRepo(applyPath string) (host, project string, err error) {
+	repo, _, err := parseGitUrl(applyPath, false)
+	if err != nil {
+		return "", "", err
+	}
+	if rest, ok := strings.CutPrefix(repo, "git@"); ok {
+		host, project, _ = strings.Cut(rest, ":")
+	} else {
+		repoUrl, err := url.Parse(repo)
+		if err != nil {
+			return "", "", err
+		}
+		host, project = repoUrl.Host, repoUrl.Path
+	}
+	project = strings.TrimSuffix(strings.Trim(project, "/"), ".git")
+	if host == "" || project == "" {
+		return "", "", fmt.Errorf("invalid git repo %s for commit status", applyPath)
+	}
+	return host, project, nil
+}
+
+// commitStatusApi returns the provider and the API url for the git host. The api url from
+// the git auth config is used if set, for GitHub Enterprise and self hosted GitLab
+func commitStatusApi(host, apiUrl string) (string, string, error) {
+	switch {
+	case strings.Contains(host, "gitlab"):
+		return commitStatusGitLab, cmp.Or(apiUrl, "https://"+host+"/api/v4"), nil
+	case host == "github.com":
+		return commitStatusGitHub, cmp.Or(apiUrl, "https://api.github.com"), nil
+	case strings.Contains(host, "github"):
+		return commitStatusGitHub, cmp.Or(apiUrl, "https://"+host+"/api/v3"), nil
+	default:
+		return "", "", fmt.Errorf("commit status is supported for GitHub and GitLab only, unknown git host %s", host)
+	}
+}
+
+// newCommitStatusTarget returns the API target for posting statuses on the apply repo. The token
+// is the api_token from the git auth entry, or the password if the entry uses a personal access token
+func (s *Server) newCommitStatusTarget(applyPath, gitAuth string) (*commitStatusTarget, error) {
+	if !system.IsGit(applyPath) {
+		return nil, fmt.Errorf("commit status requires the apply file to be in a git repo")
+	}
+	host, project, err := parseCommitStatusRepo(applyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	gitAuth = cmp.Or(gitAuth, s.Config().Security.DefaultGitAuth)
+	if gitAuth == "" {
+		return nil, fmt.Errorf("commit status requires a git_auth entry with an api_token")
+	}
+	authEntry, ok := s.Config().GitAuth[gitAuth]
+	if !ok {
+		return nil, fmt.Errorf("git auth entry %s not found in server config", gitAuth)
+	}
+	provider, apiUrl, err := commitStatusApi(host, authEntry.ApiUrl)
+	if err != nil {
+		return nil, err
+	}
+
+	token := authEntry.ApiToken
+	if token == "" && authEntry.Type != "" {
+		// The GitHub App installation token or the OIDC exchanged token is used for the API also
+		keyEntry, err := s.loadGitKey(gitAuth)
+		if err != nil {
+			return nil, err
+		}
+		token = keyEntry.password
+	} else {
+		if token == "" && authEntry.KeyFilePath == "" && authEntry.PrivateKey == "" {
+			token = authEntry.Password
+		}
+		if token, err = s.secretsMgr().EvalTemplate(token); err != nil {
+			return nil, fmt.Errorf("error resolving git auth %s api_token: %w", gitAuth, err)
+		}
+	}
+	if token == "" {
+		return nil, fmt.Errorf("git auth entry %s has no api_token for commit status", gitAuth)
+	}
+
+	return &commitStatusTarget{
+		provider: provider,
+		apiUrl:   strings.TrimSuffix(apiUrl, "/"),
+		project:  project,
+		token:    token,
+	}, nil
+}
+
+// request creates the API request to post the status on the commit
+func (t *commitStatusTarget) request(ctx context.Context, sha string, status commitStatus) (*http.Request, error) {
+	description := status.description
+	if len(description) > commitStatusMaxDescLen {
+		description = strings.ToValidUTF8(description[:commitStatusMaxDescLen-3], "") + "..."
+	}
+
+	var apiUrl string
+	body := map[string]string{
+		"description": description,
+		"target_url":  status.targetUrl,
+	}
+	if t.provider == commitStatusGitLab {
+		apiUrl = fmt.Sprintf("%s/projects/%s/statuses/%s", t.apiUrl, url.PathEscape(t.project), sha)
+		body["name"] = status.context
+		body["state"] = "failed"
+		if status.success {
+			body["state"] = "success"
+		}
+	} else {
+		apiUrl = fmt.Sprintf("%s/repos/%s/statuses/%s", t.apiUrl, t.project, sha)
+		body["context"] = status.context
+		body["state"] = "failure"
+		if status.success {
+			body["state"] = "success"
+		}
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, apiUrl, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if t.provider == commitStatusGitLab {
+		req.Header.Set("PRIVATE-TOKEN", t.token)
+	} else {
+		req.Header.Set("Accept", "application/vnd.github+json")
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	}
+	return req, nil
+}
+
+// syncCommitStatuses returns the statuses for a sync run: one for the sync and one per app which
+// was changed, linking to the app. Failed runs are rolled back, only the sync status is reported
+func syncCommitStatuses(status *types.SyncJobStatus, appUrl func(types.AppPathDomain) string) []commitStatus {
+	if status.Error != "" {
+		return []commitStatus{{context: commitStatusContext, success: false, description: "Sync failed: " + status.Error}}
+	}
+
+	resp := &status.ApplyResponse
+	actions := map[types.AppPathDomain][]string{}
+	for _, create := range resp.CreateResults {
+		actions[create.AppPathDomain] = append(actions[create.AppPathDomain], "created")
+	}
+	for _, results := range []struct {
+		action string
+		apps   []types.AppPathDomain
+	}{{"updated", resp.UpdateResults}, {"reloaded", resp.ReloadResults}, {"promoted", resp.PromoteResults}} {
+		for _, app := range results.apps {
+			if !slices.Contains(actions[app], results.action) {
+				actions[app] = append(actions[app], results.action)
+			}
+		}
+	}
+
+	ret := []commitStatus{{context: commitStatusContext, success: true,
+		description: fmt.Sprintf("Sync succeeded: %d created, %d updated, %d reloaded, %d promoted",
+			len(resp.CreateResults), len(resp.UpdateResults), len(resp.ReloadResults), len(resp.PromoteResults))}}
+	apps := slices.SortedFunc(maps.Keys(actions), func(a, b types.AppPathDomain) int {
+		return cmp.Compare(a.String(), b.String())
+	})
+	for i, app := range apps {
+		if i >= commitStatusMaxApps {
+			break
+		}
+		ret = append(ret, commitStatus{
+			context:     commitStatusContext + ": " + app.String(),
+			success:     true,
+			description: "App " + strings.Join(actions[app], ", "),
+			targetUrl:   appUrl(app),
+		})
+	}
+	return ret
+}
+
+// reportSyncCommitStatus posts the result of a sync run as statuses on the applied commit, if
+// enabled for the sync entry. Runs where the apply was skipped since there is no new commit are
+// not reported. The statuses are posted in the background, errors are logged
+func (s *Server) reportSyncCommitStatus(entry *types.SyncEntry, status *types.SyncJobStatus, repoCache *RepoCache) {
+	if !entry.Metadata.CommitStatus || status.ApplyResponse.SkippedApply || status.ApplyResponse.DryRun {
+		return
+	}
+
+	// The commit id is set for successful applies only, the failed commit is read from the repo cache
+	sha := status.CommitId
+	if sha == "" {
+		var err error
+		if sha, err = repoCache.GetSha(entry.Path, cmp.Or(entry.Metadata.GitBranch, "main"), entry.Metadata.GitAuth); err != nil {
+			s.Warn().Err(err).Msgf("Error getting commit for sync %s status", entry.Id)
+			return
+		}
+	}
+	target, err := s.newCommitStatusTarget(entry.Path, entry.Metadata.GitAuth)
+	if err != nil {
+		s.Warn().Err(err).Msgf("Error reporting commit status for sync %s", entry.Id)
+		return
+	}
+	serverConfig := s.Config()
+	statuses := syncCommitStatuses(status, func(app types.AppPathDomain) string {
+		return types.GetAppUrl(app, serverConfig)
+	})
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), commitStatusTimeout)
+		defer cancel()
+		for _, commitStatus := range statuses {
+			if err := target.post(ctx, sha, commitStatus); err != nil {
+				s.Warn().Err(err).Msgf("Error reporting commit status for sync %s", entry.Id)
+				return
+			}
+		}
+		s.Debug().Msgf("Reported %d commit statuses for sync %s on %s", len(statuses), entry.Id, sha)
+	}()
+}
+
+func (t *commitStatusTarget) post(ctx context.Context, sha string, status commitStatus) error {
+	req, err := t.request(ctx, sha, status)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("error posting commit status to %s: %s %s", t.provider, resp.Status, body)
+	}
+	return nil
+}
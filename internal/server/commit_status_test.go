@@ -0,0 +1,95 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestParseCommitStatusRepo(t *testing.T) {
+	tests := []struct {
+		applyPath string
+		host      string
+		project   string
+	}{
+		{"github.com/openrundev/apps/apps.ace", "github.com", "openrundev/apps"},
+		{"https://github.com/openrundev/apps/utils/apps.ace", "github.com", "openrundev/apps"},
+		{"git@github.com:openrundev/apps.git/apps.ace", "github.com", "openrundev/apps"},
+		{"gitlab.com/group/sub/repo//apps.ace", "gitlab.com", "group/sub/repo"},
+	}
+	for _, test := range tests {
+		host, project, err := parseCommitStatusRepo(test.applyPath)
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqualsString(t, test.applyPath+" host", test.host, host)
+		testutil.AssertEqualsString(t, test.applyPath+" project", test.project, project)
+	}
+
+	provider, apiUrl, err := commitStatusApi("github.com", "")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "provider", commitStatusGitHub, provider)
+	testutil.AssertEqualsString(t, "api url", "https://api.github.com", apiUrl)
+	_, apiUrl, err = commitStatusApi("github.example.com", "")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "api url", "https://github.example.com/api/v3", apiUrl)
+	provider, apiUrl, err = commitStatusApi("gitlab.example.com", "https://git.example.com/api/v4")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "provider", commitStatusGitLab, provider)
+	testutil.AssertEqualsString(t, "api url", "https://git.example.com/api/v4", apiUrl)
+	_, _, err = commitStatusApi("bitbucket.org", "")
+	testutil.AssertErrorContains(t, err, "unknown git host bitbucket.org")
+}
+
+func TestCommitStatusRequest(t *testing.T) {
+	status := commitStatus{context: "openrun/sync", success: false, description: strings.Repeat("x", 200), targetUrl: "https://example.com/app"}
+
+	github := &commitStatusTarget{provider: commitStatusGitHub, apiUrl: "https://api.github.com", project: "org/repo", token: "tkn"}
+	req, err := github.request(context.Background(), "abc123", status)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "url", "https://api.github.com/repos/org/repo/statuses/abc123", req.URL.String())
+	testutil.AssertEqualsString(t, "auth", "Bearer tkn", req.Header.Get("Authorization"))
+	body := map[string]string{}
+	testutil.AssertNoError(t, json.NewDecoder(req.Body).Decode(&body))
+	testutil.AssertEqualsString(t, "state", "failure", body["state"])
+	testutil.AssertEqualsString(t, "context", "openrun/sync", body["context"])
+	testutil.AssertEqualsInt(t, "description", commitStatusMaxDescLen, len(body["description"]))
+
+	gitlab := &commitStatusTarget{provider: commitStatusGitLab, apiUrl: "https://gitlab.com/api/v4", project: "group/sub/repo", token: "tkn"}
+	status.success = true
+	req, err = gitlab.request(context.Background(), "abc123", status)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "url", "https://gitlab.com/api/v4/projects/group%2Fsub%2Frepo/statuses/abc123", req.URL.String())
+	testutil.AssertEqualsString(t, "token", "tkn", req.Header.Get("PRIVATE-TOKEN"))
+	body = map[string]string{}
+	testutil.AssertNoError(t, json.NewDecoder(req.Body).Decode(&body))
+	testutil.AssertEqualsString(t, "state", "success", body["state"])
+	testutil.AssertEqualsString(t, "name", "openrun/sync", body["name"])
+}
+
+func TestSyncCommitStatuses(t *testing.T) {
+	appUrl := func(app types.AppPathDomain) string { return "https://example.com" + app.Path }
+	app1 := types.AppPathDomain{Path: "/app1"}
+	app2 := types.AppPathDomain{Path: "/app2"}
+	statuses := syncCommitStatuses(&types.SyncJobStatus{ApplyResponse: types.AppApplyResponse{
+		CreateResults:  []types.AppCreateResponse{{AppPathDomain: app2}},
+		ReloadResults:  []types.AppPathDomain{app1, app2},
+		PromoteResults: []types.AppPathDomain{app1},
+	}}, appUrl)
+	testutil.AssertEqualsInt(t, "count", 3, len(statuses))
+	testutil.AssertEqualsString(t, "sync", "Sync succeeded: 1 created, 0 updated, 2 reloaded, 1 promoted", statuses[0].description)
+	testutil.AssertEqualsString(t, "app1 context", "openrun/sync: /app1", statuses[1].context)
+	testutil.AssertEqualsString(t, "app1", "App reloaded, promoted", statuses[1].description)
+	testutil.AssertEqualsString(t, "app1 url", "https://example.com/app1", statuses[1].targetUrl)
+	testutil.AssertEqualsString(t, "app2", "App created, reloaded", statuses[2].description)
+
+	statuses = syncCommitStatuses(&types.SyncJobStatus{Error: "apply failed"}, appUrl)
+	testutil.AssertEqualsInt(t, "count", 1, len(statuses))
+	testutil.AssertEqualsBool(t, "success", false, statuses[0].success)
+	testutil.AssertEqualsString(t, "error", "Sync failed: apply failed", statuses[0].description)
+}
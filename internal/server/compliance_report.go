@@ -0,0 +1,429 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"cmp"
+	"compress/gzip"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	complianceReportPurpose = "compliance_report"
+	complianceManifestFile  = "manifest.json"
+	complianceSignatureFile = "manifest.sig"
+)
+
+// complianceReadOperations are the admin API operations which do not change the server state,
+// these are not included in the admin mutations. Operations starting with list_ are also reads
+var complianceReadOperations = map[string]bool{
+	"app_check": true, "app_container_logs": true, "app_logs": true, "app_stats": true, "binding_get": true,
+	"binding_show_account": true, "capture_download": true, "compliance_report": true, "config_get": true,
+	"contract_check": true, "dependency_graph": true, "export_apps": true, "get_app": true, "golden_test": true,
+	"keyring_list": true, "pretty_print": true, "profile_download": true, "quota_show": true, "search_apps": true,
+	"secret_get": true, "show_app_config": true, "sync_history": true, "token_list": true, "user_list": true,
+	"verify_audit": true, "verify_compliance_report": true, "version_files": true, "version_list": true,
+}
+
+// isReadOperation returns whether the admin API operation only reads the server state. Dry run
+// operations do not commit any changes
+func isReadOperation(operation string) bool {
+	return strings.HasPrefix(operation, "list_") || strings.HasSuffix(operation, "_dryrun") ||
+		complianceReadOperations[operation]
+}
+
+// isApprovalOperation returns whether the admin API operation approves app permissions, like
+// approve_apps or reload_apps_approve
+func isApprovalOperation(operation string) bool {
+	return strings.Contains(operation, "approve") && !strings.HasSuffix(operation, "_dryrun")
+}
+
+// parseReportPeriod returns the label and the time range for the report period: a quarter (Q1 or
+// 2025-Q1), a month (2025-03) or a year (2025). A quarter without a year is the latest such quarter
+// which has started. The end of the range is exclusive, times are in UTC
+func parseReportPeriod(period string, now time.Time) (string, time.Time, time.Time, error) {
+	invalid := types.CreateRequestError(
+		fmt.Sprintf("invalid period %q, expected a quarter (Q1, 2025-Q1), a month (2025-03) or a year (2025)", period),
+		http.StatusBadRequest)
+	value := strings.ToUpper(strings.TrimSpace(period))
+	parseYear := func(y string) (int, bool) {
+		year, err := strconv.Atoi(y)
+		return year, err == nil && len(y) == 4
+	}
+
+	year, explicitYear := now.UTC().Year(), false
+	quarter := value
+	if y, rest, ok := strings.Cut(value, "-"); ok {
+		if year, ok = parseYear(y); !ok {
+			return "", time.Time{}, time.Time{}, invalid
+		}
+		explicitYear = true
+		if !strings.HasPrefix(rest, "Q") {
+			month, err := strconv.Atoi(rest)
+			if err != nil || len(rest) != 2 || month < 1 || month > 12 {
+				return "", time.Time{}, time.Time{}, invalid
+			}
+			start := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+			return start.Format("2006-01"), start, start.AddDate(0, 1, 0), nil
+		}
+		quarter = rest
+	} else if year, ok := parseYear(value); ok {
+		start := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+		return value, start, start.AddDate(1, 0, 0), nil
+	}
+
+	if len(quarter) != 2 || quarter[0] != 'Q' || quarter[1] < '1' || quarter[1] > '4' {
+		return "", time.Time{}, time.Time{}, invalid
+	}
+	quarterNum := int(quarter[1] - '0')
+	start := time.Date(year, time.Month(3*quarterNum-2), 1, 0, 0, 0, 0, time.UTC)
+	if !explicitYear && start.After(now) {
+		start = start.AddDate(-1, 0, 0)
+	}
+	return fmt.Sprintf("%d-Q%d", start.Year(), quarterNum), start, start.AddDate(0, 3, 0), nil
+}
+
+// complianceReportFile is a file in the compliance report archive
+type complianceReportFile struct {
+	name    string
+	content []byte
+}
+
+// complianceApp is an app in the app inventory of the compliance report
+type complianceApp struct {
+	Path          string             `json:"path"`
+	Domain        string             `json:"domain"`
+	Id            types.AppId        `json:"id"`
+	Name          string             `json:"name"`
+	Env           string             `json:"env"`
+	SourceUrl     string             `json:"source_url"`
+	Branch        string             `json:"branch"`
+	GitSha        string             `json:"git_sha"`
+	Version       int                `json:"version"`
+	Auth          types.AppAuthnType `json:"auth"`
+	CreatedBy     string             `json:"created_by"`
+	CreateTime    *time.Time         `json:"create_time"`
+	UpdateTime    *time.Time         `json:"update_time"`
+	AppliedSyncId string             `json:"applied_sync_id"`
+	Loads         []string           `json:"loads"`
+	Permissions   []types.Permission `json:"permissions"`
+	Bindings      []string           `json:"bindings"`
+}
+
+// ComplianceReport creates the compliance evidence for the period as a signed archive: the http
+// access summary, the permission approvals, the admin mutations, the app inventory and the audit
+// log verification result. The manifest lists the SHA256 hash of each file, it is signed with the
+// keyring key
+func (s *Server) ComplianceReport(ctx context.Context, period string) (*types.ComplianceReportResponse, error) {
+	if err := s.enforceGlobalPerm(ctx, types.PermissionAuditRead, ""); err != nil {
+		return nil, err
+	}
+	if s.keyring == nil {
+		return nil, fmt.Errorf("keyring is not available, the compliance report cannot be signed")
+	}
+	now := time.Now().UTC()
+	label, start, end, err := parseReportPeriod(period, now)
+	if err != nil {
+		return nil, err
+	}
+	if start.After(now) {
+		return nil, types.CreateRequestError(fmt.Sprintf("period %s has not started", label), http.StatusBadRequest)
+	}
+
+	ret := &types.ComplianceReportResponse{Period: label, Start: start, End: end, Warnings: []string{}}
+	if end.After(now) {
+		ret.Warnings = append(ret.Warnings, fmt.Sprintf("period %s has not ended, the report includes events until %s",
+			label, now.Format(time.RFC3339)))
+	}
+	config := s.Config().System
+	for _, class := range []struct {
+		name string
+		days int
+	}{
+		{"http", retentionDays(config.HttpEventRetentionDays, config.AuditRetentionDays)},
+		{"system", retentionDays(config.NonHttpEventRetentionDays, config.AuditRetentionDays)},
+	} {
+		if class.days > 0 && start.Before(now.AddDate(0, 0, -class.days)) {
+			ret.Warnings = append(ret.Warnings, fmt.Sprintf("%s events older than %d days are deleted by the retention cleanup, "+
+				"the report for %s could be incomplete", class.name, class.days, label))
+		}
+	}
+
+	// Ensure previously queued audit events are included
+	s.FlushAuditEvents()
+	appNames, err := s.complianceAppNames()
+	if err != nil {
+		return nil, err
+	}
+	accessSummary, err := s.complianceAccessSummary(ctx, start, end, appNames)
+	if err != nil {
+		return nil, err
+	}
+	approvals, mutations, err := s.complianceAdminEvents(ctx, start, end, appNames)
+	if err != nil {
+		return nil, err
+	}
+	inventory, err := s.complianceAppInventory(ctx)
+	if err != nil {
+		return nil, err
+	}
+	verifyResult, err := s.VerifyAuditLog(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !verifyResult.Verified {
+		ret.Warnings = append(ret.Warnings, "audit log verification failed, see audit_verify.json in the report")
+	}
+	verifyJson, err := json.MarshalIndent(verifyResult, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	files := []complianceReportFile{
+		{"access_summary.csv", accessSummary},
+		{"permission_approvals.csv", approvals},
+		{"admin_mutations.csv", mutations},
+		{"app_inventory.json", inventory},
+		{"audit_verify.json", verifyJson},
+	}
+	manifest := types.ComplianceManifest{Period: label, Start: start, End: end, CreateTime: now,
+		CreatedBy: system.GetContextUserId(ctx), Files: complianceManifestFiles(files)}
+	manifestJson, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	keyId, sig, err := s.keyring.Sign(complianceReportPurpose, manifestJson)
+	if err != nil {
+		return nil, err
+	}
+	sigJson, err := json.MarshalIndent(types.ComplianceSignature{KeyId: keyId,
+		Signature: base64.StdEncoding.EncodeToString(sig)}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, complianceReportFile{complianceManifestFile, manifestJson},
+		complianceReportFile{complianceSignatureFile, sigJson})
+
+	dir := "openrun-compliance-" + label
+	if ret.Archive, err = buildComplianceArchive(dir, now, files); err != nil {
+		return nil, err
+	}
+	ret.FileName = dir + ".tar.gz"
+	ret.KeyId = keyId
+	return ret, nil
+}
+
+// VerifyComplianceReport verifies the signature of a compliance report manifest. The file hashes
+// are checked against the manifest by the client
+func (s *Server) VerifyComplianceReport(ctx context.Context, request *types.ComplianceVerifyRequest) (*types.ComplianceVerifyResponse, error) {
+	if err := s.enforceGlobalPerm(ctx, types.PermissionAuditRead, ""); err != nil {
+		return nil, err
+	}
+	if s.keyring == nil {
+		return nil, fmt.Errorf("keyring is not available, the compliance report cannot be verified")
+	}
+	sig, err := base64.StdEncoding.DecodeString(request.Signature.Signature)
+	if err != nil {
+		return &types.ComplianceVerifyResponse{Error: "the manifest signature is invalid"}, nil
+	}
+	sigs, err := s.keyring.Signatures(complianceReportPurpose, request.Manifest)
+	if err != nil {
+		return nil, err
+	}
+	for _, keySig := range sigs {
+		if hmac.Equal(keySig, sig) {
+			return &types.ComplianceVerifyResponse{Verified: true}, nil
+		}
+	}
+	for _, key := range s.keyring.List().Keys {
+		if key.Id == request.Signature.KeyId {
+			return &types.ComplianceVerifyResponse{Error: "the manifest signature does not match, the manifest has been modified"}, nil
+		}
+	}
+	return &types.ComplianceVerifyResponse{Error: fmt.Sprintf(
+		"the manifest cannot be verified, key %s is not in the keyring", request.Signature.KeyId)}, nil
+}
+
+// complianceAppNames returns the app path for each app id
+func (s *Server) complianceAppNames() (map[string]string, error) {
+	apps, err := s.apps.GetAllAppsInfo()
+	if err != nil {
+		return nil, err
+	}
+	ret := make(map[string]string, len(apps))
+	for _, app := range apps {
+		ret[string(app.Id)] = app.String()
+	}
+	return ret, nil
+}
+
+// complianceAccessSummary returns the http events in the range grouped by app, user and status
+func (s *Server) complianceAccessSummary(ctx context.Context, start, end time.Time, appNames map[string]string) ([]byte, error) {
+	rows, err := s.auditDB.QueryContext(ctx, system.RebindQuery(s.auditDbType,
+		`select app_id, user_id, status, count(*), min(create_time), max(create_time) from audit `+
+			`where event_type = ? and create_time >= ? and create_time < ? `+
+			`group by app_id, user_id, status order by app_id, user_id, status`),
+		string(types.EventTypeHTTP), start.UnixNano(), end.UnixNano())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	records := [][]string{}
+	for rows.Next() {
+		var appId, userId, status string
+		var count, first, last int64
+		if err := rows.Scan(&appId, &userId, &status, &count, &first, &last); err != nil {
+			return nil, err
+		}
+		records = append(records, []string{appNames[appId], appId, auditAppEnv(appId), userId, status,
+			strconv.FormatInt(count, 10), formatReportTime(first), formatReportTime(last)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return complianceCSV([]string{"app", "app_id", "env", "user_id", "status", "requests", "first_time", "last_time"}, records)
+}
+
+// complianceAdminEvents returns the permission approvals and the admin mutations in the range, from
+// the system events. Approvals are also included in the mutations
+func (s *Server) complianceAdminEvents(ctx context.Context, start, end time.Time, appNames map[string]string) ([]byte, []byte, error) {
+	rows, err := s.auditDB.QueryContext(ctx, system.RebindQuery(s.auditDbType,
+		`select create_time, rid, app_id, user_id, operation, target, status, detail from audit `+
+			`where event_type = ? and create_time >= ? and create_time < ? order by create_time`),
+		string(types.EventTypeSystem), start.UnixNano(), end.UnixNano())
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	approvals, mutations := [][]string{}, [][]string{}
+	for rows.Next() {
+		var createTime int64
+		var rid, appId, userId, operation, target, status, detail string
+		if err := rows.Scan(&createTime, &rid, &appId, &userId, &operation, &target, &status, &detail); err != nil {
+			return nil, nil, err
+		}
+		if isReadOperation(operation) {
+			continue
+		}
+		record := []string{formatReportTime(createTime), userId, operation, cmp.Or(target, appNames[appId]), status, rid, detail}
+		mutations = append(mutations, record)
+		if isApprovalOperation(operation) {
+			approvals = append(approvals, record)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	header := []string{"time", "user_id", "operation", "target", "status", "rid", "detail"}
+	approvalsCsv, err := complianceCSV(header, approvals)
+	if err != nil {
+		return nil, nil, err
+	}
+	mutationsCsv, err := complianceCSV(header, mutations)
+	if err != nil {
+		return nil, nil, err
+	}
+	return approvalsCsv, mutationsCsv, nil
+}
+
+// complianceAppInventory returns the current apps with their source and approved permissions
+func (s *Server) complianceAppInventory(ctx context.Context) ([]byte, error) {
+	apps, err := s.FilterApps("all", false)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(apps, func(a, b types.AppInfo) int {
+		return compareAppPathDomain(a.AppPathDomain, b.AppPathDomain)
+	})
+
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	inventory := make([]complianceApp, 0, len(apps))
+	for _, app := range apps {
+		appEntry, err := s.db.GetAppEntryTx(ctx, tx, app.AppPathDomain)
+		if err != nil {
+			return nil, fmt.Errorf("error reading app %s: %w", app.AppPathDomain, err)
+		}
+		inventory = append(inventory, complianceApp{Path: app.Path, Domain: app.Domain, Id: app.Id, Name: app.Name,
+			Env: auditAppEnv(string(app.Id)), SourceUrl: app.SourceUrl, Branch: app.Branch, GitSha: app.GitSha,
+			Version: app.Version, Auth: app.Auth, CreatedBy: appEntry.UserID, CreateTime: appEntry.CreateTime,
+			UpdateTime: appEntry.UpdateTime, AppliedSyncId: appEntry.Metadata.AppliedSyncId, Loads: appEntry.Metadata.Loads,
+			Permissions: appEntry.Metadata.Permissions, Bindings: appEntry.Metadata.Bindings})
+	}
+	return json.MarshalIndent(inventory, "", "  ")
+}
+
+func formatReportTime(unixNano int64) string {
+	return time.Unix(0, unixNano).UTC().Format(time.RFC3339)
+}
+
+func complianceCSV(header []string, records [][]string) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	if err := w.WriteAll(records); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// complianceManifestFiles returns the manifest entries for the report files
+func complianceManifestFiles(files []complianceReportFile) []types.ComplianceFile {
+	ret := make([]types.ComplianceFile, 0, len(files))
+	for _, file := range files {
+		hash := sha256.Sum256(file.content)
+		ret = append(ret, types.ComplianceFile{Name: file.name, Size: len(file.content), Sha256: hex.EncodeToString(hash[:])})
+	}
+	return ret
+}
+
+// buildComplianceArchive returns the tar.gz archive with the files under dir
+func buildComplianceArchive(dir string, modTime time.Time, files []complianceReportFile) ([]byte, error) {
+	var buf bytes.Buffer
+	gzw := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gzw)
+	for _, file := range files {
+		header := &tar.Header{Name: dir + "/" + file.name, Mode: 0600, Size: int64(len(file.content)),
+			ModTime: modTime, Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(file.content); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gzw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
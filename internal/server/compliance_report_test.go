@@ -0,0 +1,153 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestParseReportPeriod(t *testing.T) {
+	now := time.Date(2025, time.May, 10, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		period     string
+		label      string
+		start, end string
+		wantErr    bool
+	}{
+		{period: "Q1", label: "2025-Q1", start: "2025-01-01", end: "2025-04-01"},
+		{period: "q2", label: "2025-Q2", start: "2025-04-01", end: "2025-07-01"},
+		{period: "Q3", label: "2024-Q3", start: "2024-07-01", end: "2024-10-01"},
+		{period: "2024-Q4", label: "2024-Q4", start: "2024-10-01", end: "2025-01-01"},
+		{period: "2025-Q3", label: "2025-Q3", start: "2025-07-01", end: "2025-10-01"},
+		{period: "2025-03", label: "2025-03", start: "2025-03-01", end: "2025-04-01"},
+		{period: "2024-12", label: "2024-12", start: "2024-12-01", end: "2025-01-01"},
+		{period: "2024", label: "2024", start: "2024-01-01", end: "2025-01-01"},
+		{period: "Q5", wantErr: true},
+		{period: "2025-13", wantErr: true},
+		{period: "2025-3", wantErr: true},
+		{period: "25-Q1", wantErr: true},
+		{period: "", wantErr: true},
+	}
+	for _, tc := range tests {
+		t.Run(tc.period, func(t *testing.T) {
+			label, start, end, err := parseReportPeriod(tc.period, now)
+			if tc.wantErr {
+				testutil.AssertErrorContains(t, err, "invalid period")
+				return
+			}
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqualsString(t, "label", tc.label, label)
+			testutil.AssertEqualsString(t, "start", tc.start, start.Format(time.DateOnly))
+			testutil.AssertEqualsString(t, "end", tc.end, end.Format(time.DateOnly))
+		})
+	}
+}
+
+func TestComplianceOperations(t *testing.T) {
+	for op, read := range map[string]bool{"list_apps": true, "get_app": true, "apply_dryrun": true, "reload_apps": false,
+		"approve_apps": false, "secret_reveal": false, "keyring_rotate": false} {
+		testutil.AssertEqualsBool(t, op, read, isReadOperation(op))
+	}
+	for op, approval := range map[string]bool{"approve_apps": true, "reload_apps_promote_approve": true,
+		"apply_approve": true, "apply_approve_dryrun": false, "reload_apps": false} {
+		testutil.AssertEqualsBool(t, op, approval, isApprovalOperation(op))
+	}
+}
+
+func readTestArchive(t *testing.T, archive []byte) map[string]string {
+	t.Helper()
+	gzr, err := gzip.NewReader(bytes.NewReader(archive))
+	testutil.AssertNoError(t, err)
+	tr := tar.NewReader(gzr)
+	files := map[string]string{}
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		testutil.AssertNoError(t, err)
+		content, err := io.ReadAll(tr)
+		testutil.AssertNoError(t, err)
+		files[path.Base(header.Name)] = string(content)
+	}
+	return files
+}
+
+func TestComplianceReport(t *testing.T) {
+	server, db, ctx := newApplyTestServer(t)
+	defer db.Close()
+	keyring, err := NewKeyring(server.Logger, NewInmemoryKVStore(), "", nil)
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, keyring.Init(context.Background(), nil))
+	server.keyring = keyring
+	if err := server.initAuditDB("sqlite:" + filepath.Join(t.TempDir(), "audit.db")); err != nil {
+		t.Fatalf("init audit db: %v", err)
+	}
+	defer func() {
+		server.stopAuditWriter()
+		_ = server.auditDB.Close()
+	}()
+
+	for _, event := range []types.AuditEvent{
+		{RequestId: "rid_a", EventType: types.EventTypeHTTP, Operation: "GET", UserId: "alice", Status: "200"},
+		{RequestId: "rid_b", EventType: types.EventTypeHTTP, Operation: "GET", UserId: "alice", Status: "200"},
+		{RequestId: "rid_c", EventType: types.EventTypeSystem, Operation: "list_apps", UserId: "admin", Status: "Success"},
+		{RequestId: "rid_d", EventType: types.EventTypeSystem, Operation: "reload_apps_approve", UserId: "admin", Status: "Success", Target: "/app1"},
+		{RequestId: "rid_e", EventType: types.EventTypeSystem, Operation: "delete_apps", UserId: "admin", Status: "Success", Target: "/app2"},
+	} {
+		event.CreateTime = time.Now()
+		testutil.AssertNoError(t, server.InsertAuditEvent(&event))
+	}
+
+	period := "Q" + string(rune('1'+(int(time.Now().UTC().Month())-1)/3))
+	ret, err := server.ComplianceReport(ctx, period)
+	testutil.AssertNoError(t, err)
+	testutil.AssertStringContains(t, ret.FileName, "openrun-compliance-")
+	testutil.AssertStringContains(t, strings.Join(ret.Warnings, "\n"), "has not ended")
+
+	files := readTestArchive(t, ret.Archive)
+	testutil.AssertEqualsInt(t, "files", 7, len(files))
+	testutil.AssertStringContains(t, files["access_summary.csv"], ",alice,200,2,")
+	testutil.AssertStringContains(t, files["permission_approvals.csv"], "reload_apps_approve,/app1")
+	if strings.Contains(files["permission_approvals.csv"], "delete_apps") {
+		t.Errorf("unexpected approvals: %s", files["permission_approvals.csv"])
+	}
+	testutil.AssertStringContains(t, files["admin_mutations.csv"], "delete_apps,/app2")
+	if strings.Contains(files["admin_mutations.csv"], "list_apps") {
+		t.Errorf("unexpected mutations: %s", files["admin_mutations.csv"])
+	}
+	testutil.AssertStringContains(t, files["audit_verify.json"], `"verified": true`)
+
+	var signature types.ComplianceSignature
+	testutil.AssertNoError(t, json.Unmarshal([]byte(files[complianceSignatureFile]), &signature))
+	testutil.AssertEqualsString(t, "key id", ret.KeyId, signature.KeyId)
+	verify, err := server.VerifyComplianceReport(ctx, &types.ComplianceVerifyRequest{
+		Manifest: []byte(files[complianceManifestFile]), Signature: signature})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsBool(t, "verified", true, verify.Verified)
+
+	// A modified manifest fails verification
+	modified := strings.Replace(files[complianceManifestFile], `"size": `, `"size": 1`, 1)
+	verify, err = server.VerifyComplianceReport(ctx, &types.ComplianceVerifyRequest{Manifest: []byte(modified), Signature: signature})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsBool(t, "verified", false, verify.Verified)
+	testutil.AssertStringContains(t, verify.Error, "the manifest has been modified")
+
+	_, err = server.ComplianceReport(ctx, "Q7")
+	testutil.AssertErrorContains(t, err, "invalid period")
+}
@@ -0,0 +1,275 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+const (
+	ENCODING_BROTLI = "br"
+	ENCODING_GZIP   = "gzip"
+
+	brotliLevel = 4 // lower than the static file level, since dynamic responses are compressed on each request
+	gzipLevel   = 5
+)
+
+var (
+	brotliWriterPool = sync.Pool{New: func() any { return brotli.NewWriterLevel(nil, brotliLevel) }}
+	gzipWriterPool   = sync.Pool{New: func() any {
+		w, _ := gzip.NewWriterLevel(nil, gzipLevel)
+		return w
+	}}
+)
+
+// compressMiddleware compresses the responses for the allowed content types, with the encoding
+// negotiated from the Accept-Encoding header. Responses which are already encoded, like
+// precompressed static files and backend compressed proxy responses, are passed through
+func compressMiddleware(minSize int, contentTypes []string) func(http.Handler) http.Handler {
+	allowed := newContentTypeMatcher(contentTypes)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+			if encoding == "" || r.Method == http.MethodHead || r.Header.Get("Upgrade") != "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			cw := &compressResponseWriter{ResponseWriter: w, encoding: encoding, minSize: minSize, allowed: allowed}
+			defer cw.Close() //nolint:errcheck
+			next.ServeHTTP(cw, r)
+		})
+	}
+}
+
+// negotiateEncoding returns the encoding to use for the Accept-Encoding header value, brotli is
+// preferred over gzip for the same quality value. Returns empty if no supported encoding is accepted
+func negotiateEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(part, ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != ENCODING_BROTLI && name != ENCODING_GZIP {
+			continue
+		}
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			var err error
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		if q > bestQ || (q == bestQ && q > 0 && name == ENCODING_BROTLI) {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// contentTypeMatcher matches media types against the allowlist. Entries like "text/*" match all
+// the subtypes
+type contentTypeMatcher struct {
+	types    map[string]bool
+	prefixes []string
+}
+
+func newContentTypeMatcher(contentTypes []string) *contentTypeMatcher {
+	if len(contentTypes) == 0 {
+		contentTypes = COMPRESSION_ENABLED_MIME_TYPES
+	}
+	ret := &contentTypeMatcher{types: map[string]bool{}}
+	for _, contentType := range contentTypes {
+		contentType = strings.ToLower(strings.TrimSpace(contentType))
+		if prefix, ok := strings.CutSuffix(contentType, "/*"); ok {
+			ret.prefixes = append(ret.prefixes, prefix+"/")
+		} else {
+			ret.types[contentType] = true
+		}
+	}
+	return ret
+}
+
+func (m *contentTypeMatcher) match(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if m.types[mediaType] {
+		return true
+	}
+	for _, prefix := range m.prefixes {
+		if strings.HasPrefix(mediaType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// compressResponseWriter buffers the response till the min size is reached, so that small
+// responses are sent uncompressed. The decision to compress is made once, when the buffer is
+// full, on flush or at the end of the response
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding string
+	minSize  int
+	allowed  *contentTypeMatcher
+
+	status  int
+	buf     []byte
+	decided bool
+	encoder io.WriteCloser
+}
+
+var _ http.Flusher = (*compressResponseWriter)(nil)
+var _ http.Hijacker = (*compressResponseWriter)(nil)
+
+func (c *compressResponseWriter) WriteHeader(statusCode int) {
+	if c.decided || c.status != 0 {
+		return
+	}
+	if statusCode < http.StatusOK {
+		// Informational responses, like early hints, are sent right away
+		c.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+	c.status = statusCode
+}
+
+func (c *compressResponseWriter) Write(b []byte) (int, error) {
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+	if c.decided {
+		if c.encoder != nil {
+			return c.encoder.Write(b)
+		}
+		return c.ResponseWriter.Write(b)
+	}
+
+	c.buf = append(c.buf, b...)
+	if len(c.buf) >= c.minSize {
+		if err := c.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// decide writes the headers and the buffered data, compressing if the response is eligible.
+// sizeOk is false if the response is complete and is smaller than the min size
+func (c *compressResponseWriter) decide(sizeOk bool) error {
+	c.decided = true
+	if c.status == 0 {
+		c.status = http.StatusOK
+	}
+
+	header := c.Header()
+	if header.Get("Content-Type") == "" && len(c.buf) > 0 {
+		header.Set("Content-Type", http.DetectContentType(c.buf))
+	}
+	eligible := c.allowed.match(header.Get("Content-Type")) && header.Get("Content-Encoding") == "" &&
+		c.status != http.StatusNoContent && c.status != http.StatusNotModified && c.status != http.StatusPartialContent
+	if eligible {
+		header.Add("Vary", "Accept-Encoding")
+	}
+
+	if eligible && sizeOk {
+		header.Del("Content-Length")
+		header.Set("Content-Encoding", c.encoding)
+		if etag := header.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			// The compressed bytes are not the same as the original, so the ETag cannot be strong
+			header.Set("ETag", "W/"+etag)
+		}
+		c.encoder = c.newEncoder()
+	}
+
+	c.ResponseWriter.WriteHeader(c.status)
+	if len(c.buf) == 0 {
+		return nil
+	}
+	var err error
+	if c.encoder != nil {
+		_, err = c.encoder.Write(c.buf)
+	} else {
+		_, err = c.ResponseWriter.Write(c.buf)
+	}
+	c.buf = nil
+	return err
+}
+
+func (c *compressResponseWriter) newEncoder() io.WriteCloser {
+	switch c.encoding {
+	case ENCODING_BROTLI:
+		w := brotliWriterPool.Get().(*brotli.Writer)
+		w.Reset(c.ResponseWriter)
+		return w
+	default:
+		w := gzipWriterPool.Get().(*gzip.Writer)
+		w.Reset(c.ResponseWriter)
+		return w
+	}
+}
+
+// Flush sends the buffered data. A response which is flushed before reaching the min size is
+// compressed, since it is likely to be streaming more data
+func (c *compressResponseWriter) Flush() {
+	if !c.decided && (c.status != 0 || len(c.buf) > 0) {
+		if err := c.decide(true); err != nil {
+			return
+		}
+	}
+	switch encoder := c.encoder.(type) {
+	case *brotli.Writer:
+		encoder.Flush() //nolint:errcheck
+	case *gzip.Writer:
+		encoder.Flush() //nolint:errcheck
+	}
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close writes any buffered data and closes the encoder, called after the handler returns
+func (c *compressResponseWriter) Close() error {
+	if !c.decided {
+		if c.status == 0 {
+			// Nothing was written by the handler
+			return nil
+		}
+		if err := c.decide(len(c.buf) >= c.minSize && len(c.buf) > 0); err != nil {
+			return err
+		}
+	}
+	if c.encoder == nil {
+		return nil
+	}
+	err := c.encoder.Close()
+	switch encoder := c.encoder.(type) {
+	case *brotli.Writer:
+		brotliWriterPool.Put(encoder)
+	case *gzip.Writer:
+		gzipWriterPool.Put(encoder)
+	}
+	c.encoder = nil
+	return err
+}
+
+func (c *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := c.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, fmt.Errorf("response writer does not support hijacking")
+}
+
+func (c *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
@@ -0,0 +1,101 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := map[string]string{
+		"":                        "",
+		"identity":                "",
+		"gzip":                    "gzip",
+		"gzip, deflate, br, zstd": "br",
+		"br;q=0.5, gzip":          "gzip",
+		"BR;q=0.8, gzip;q=0.8":    "br",
+		"gzip;q=0, br;q=0":        "",
+		"gzip;q=abc":              "",
+	}
+	for header, expected := range tests {
+		testutil.AssertEqualsString(t, header, expected, negotiateEncoding(header))
+	}
+}
+
+func TestCompressMiddleware(t *testing.T) {
+	large := strings.Repeat("<p>hello</p>", 200)
+	handler := compressMiddleware(1024, []string{"text/html", "application/*"})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/large":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("ETag", `"abc"`)
+			io.WriteString(w, large) //nolint:errcheck
+		case "/small":
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"a": 1}`) //nolint:errcheck
+		case "/image":
+			w.Header().Set("Content-Type", "image/png")
+			io.WriteString(w, large) //nolint:errcheck
+		case "/encoded":
+			w.Header().Set("Content-Type", "text/html")
+			w.Header().Set("Content-Encoding", "br")
+			io.WriteString(w, large) //nolint:errcheck
+		case "/notmodified":
+			w.WriteHeader(http.StatusNotModified)
+		}
+	}))
+
+	call := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := call("/large", "gzip, br")
+	testutil.AssertEqualsString(t, "encoding", "br", rec.Header().Get("Content-Encoding"))
+	testutil.AssertEqualsString(t, "vary", "Accept-Encoding", rec.Header().Get("Vary"))
+	testutil.AssertEqualsString(t, "etag", `W/"abc"`, rec.Header().Get("ETag"))
+	body, err := io.ReadAll(brotli.NewReader(rec.Body))
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "brotli body", large, string(body))
+
+	rec = call("/large", "gzip")
+	testutil.AssertEqualsString(t, "gzip encoding", "gzip", rec.Header().Get("Content-Encoding"))
+	gz, err := gzip.NewReader(rec.Body)
+	testutil.AssertNoError(t, err)
+	body, err = io.ReadAll(gz)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "gzip body", large, string(body))
+
+	rec = call("/large", "")
+	testutil.AssertEqualsString(t, "no encoding", "", rec.Header().Get("Content-Encoding"))
+	testutil.AssertEqualsString(t, "plain body", large, rec.Body.String())
+
+	rec = call("/small", "br")
+	testutil.AssertEqualsString(t, "small encoding", "", rec.Header().Get("Content-Encoding"))
+	testutil.AssertEqualsString(t, "small vary", "Accept-Encoding", rec.Header().Get("Vary"))
+	testutil.AssertEqualsString(t, "small body", `{"a": 1}`, rec.Body.String())
+
+	rec = call("/image", "br")
+	testutil.AssertEqualsString(t, "image encoding", "", rec.Header().Get("Content-Encoding"))
+	testutil.AssertEqualsInt(t, "image body", len(large), rec.Body.Len())
+
+	rec = call("/encoded", "gzip")
+	testutil.AssertEqualsString(t, "already encoded", "br", rec.Header().Get("Content-Encoding"))
+	testutil.AssertEqualsString(t, "encoded body", large, rec.Body.String())
+
+	rec = call("/notmodified", "br")
+	testutil.AssertEqualsInt(t, "not modified", http.StatusNotModified, rec.Code)
+	testutil.AssertEqualsString(t, "not modified encoding", "", rec.Header().Get("Content-Encoding"))
+}
@@ -363,6 +363,43 @@ func (s *Server) DeleteConfigValue(ctx context.Context, section, key string, ver
 	return s.UpdateDynamicConfig(ctx, config, false)
 }
 
+// GetConfigSchema returns the dynamically settable config sections and their
+// fields, for building config forms without hardcoding the sections
+func (s *Server) GetConfigSchema(ctx context.Context) (*ConfigSchema, error) {
+	if err := s.enforceGlobalPerm(ctx, types.PermissionConfigRead, ""); err != nil {
+		return nil, err
+	}
+	return buildConfigSchema(), nil
+}
+
+// ValidateConfigValue checks a settings field change against the config
+// schema, without saving it. Forms use this to validate before the update
+func (s *Server) ValidateConfigValue(ctx context.Context, section, key string, value any) error {
+	if err := s.enforceGlobalPerm(ctx, types.PermissionConfigRead, ""); err != nil {
+		return err
+	}
+	if str, ok := value.(string); ok && str == RedactedValue {
+		return nil // the stored value is kept
+	}
+	return validateConfigValue(section, key, value)
+}
+
+// ValidateConfigEntry checks an entry change against the config schema,
+// without saving it
+func (s *Server) ValidateConfigEntry(ctx context.Context, section, name string, values map[string]any) error {
+	if err := s.enforceGlobalPerm(ctx, types.PermissionConfigRead, ""); err != nil {
+		return err
+	}
+	checked := make(map[string]any, len(values))
+	for key, value := range values {
+		if str, ok := value.(string); ok && str == RedactedValue {
+			continue // the stored value is kept
+		}
+		checked[key] = value
+	}
+	return validateConfigEntry(section, name, checked)
+}
+
 // validateRBACCandidate runs the full config validation (the same checks the
 // file upload path runs) plus the lockout check: publishing a config which
 // removes the caller's own config:update permission requires force
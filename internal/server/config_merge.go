@@ -92,6 +92,98 @@ func listConfigSections() []string {
 	return sections
 }
 
+// ConfigField describes one dynamically settable config field, for building
+// config forms. Key is the dotted field path within the section (the settings
+// key format), Type is one of string, bool, int, float, list, map or any
+type ConfigField struct {
+	Key    string `json:"key"`
+	Type   string `json:"type"`
+	Secret bool   `json:"secret"`
+}
+
+// ConfigSchema lists the dynamically settable sections with their fields:
+// the settings sections (security, system, app_config, ...) and the entry
+// sections (git_auth, auth, ...), where the fields are those of one entry.
+// Flat key/value sections and map valued entries have no fixed fields
+type ConfigSchema struct {
+	Settings map[string][]ConfigField `json:"settings"`
+	Entries  map[string][]ConfigField `json:"entries"`
+}
+
+// buildConfigSchema returns the config schema, driven by the ServerConfig
+// struct tags like the rest of the dynamic config machinery
+func buildConfigSchema() *ConfigSchema {
+	schema := &ConfigSchema{Settings: map[string][]ConfigField{}, Entries: map[string][]ConfigField{}}
+	for _, section := range listConfigSettingsSections() {
+		fieldType, _ := configSectionField(section)
+		fields := []ConfigField{}
+		if fieldType.Kind() == reflect.Struct {
+			fields = configStructFields(fieldType, "")
+		}
+		schema.Settings[section] = fields
+	}
+	for _, section := range listConfigSections() {
+		fieldType, _ := configSectionType(section)
+		fields := []ConfigField{}
+		if fieldType.Elem().Kind() == reflect.Struct {
+			fields = configStructFields(fieldType.Elem(), "")
+		}
+		schema.Entries[section] = fields
+	}
+	return schema
+}
+
+// configStructFields returns the fields of a config struct by toml tag,
+// nested structs flattened to dotted keys
+func configStructFields(structType reflect.Type, prefix string) []ConfigField {
+	fields := []ConfigField{}
+	for i := range structType.NumField() {
+		field := structType.Field(i)
+		tag := strings.Split(field.Tag.Get("toml"), ",")[0]
+		if tag == "-" || !field.IsExported() {
+			continue
+		}
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && fieldType.Kind() == reflect.Struct {
+			fields = append(fields, configStructFields(fieldType, prefix)...)
+			continue
+		}
+		if tag == "" {
+			continue
+		}
+		key := prefix + tag
+		if fieldType.Kind() == reflect.Struct {
+			fields = append(fields, configStructFields(fieldType, key+".")...)
+			continue
+		}
+		fields = append(fields, ConfigField{Key: key, Type: configFieldType(fieldType), Secret: isSecretConfigField(key)})
+	}
+	return fields
+}
+
+func configFieldType(fieldType reflect.Type) string {
+	switch fieldType.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "int"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.Slice, reflect.Array:
+		return "list"
+	case reflect.Map:
+		return "map"
+	default:
+		return "any"
+	}
+}
+
 // staticOnlySections are struct sections which cannot be set dynamically:
 // logging and telemetry are read on hot paths by components which cache the
 // config at startup, so a dynamic value would be misleading (and applying it
@@ -12,7 +12,7 @@ import (
 
 func TestConfigSections(t *testing.T) {
 	sections := listConfigSections()
-	for _, want := range []string{"git_auth", "auth", "saml", "client_auth", "secret", "forward", "plugin"} {
+	for _, want := range []string{"git_auth", "auth", "saml", "client_auth", "secret", "forward", "plugin", "tag_config"} {
 		found := false
 		for _, section := range sections {
 			if section == want {
@@ -242,6 +242,51 @@ func TestConfigSettingsSections(t *testing.T) {
 	}
 }
 
+func TestBuildConfigSchema(t *testing.T) {
+	schema := buildConfigSchema()
+	findField := func(fields []ConfigField, key string) *ConfigField {
+		for i := range fields {
+			if fields[i].Key == key {
+				return &fields[i]
+			}
+		}
+		return nil
+	}
+
+	for _, want := range []struct {
+		section, key, fieldType string
+		secret                  bool
+	}{
+		{"system", "default_domain", "string", false},
+		{"security", "default_git_auth", "string", false},
+		{"app_config", "rate_limit.requests_per_min", "int", false},
+		{"app_config", "cors.allow_origin", "string", false},
+	} {
+		field := findField(schema.Settings[want.section], want.key)
+		if field == nil {
+			t.Errorf("expected settings field %s %s in schema", want.section, want.key)
+			continue
+		}
+		if field.Type != want.fieldType || field.Secret != want.secret {
+			t.Errorf("field %s %s: got type %s secret %t", want.section, want.key, field.Type, field.Secret)
+		}
+	}
+	if _, ok := schema.Settings["logging"]; ok {
+		t.Error("logging is static only, must not be in the schema")
+	}
+
+	gitAuth := schema.Entries["git_auth"]
+	if field := findField(gitAuth, "private_key"); field == nil || !field.Secret {
+		t.Errorf("expected secret private_key field in git_auth, got %v", gitAuth)
+	}
+	if field := findField(gitAuth, "user_id"); field == nil || field.Secret {
+		t.Errorf("expected non secret user_id field in git_auth, got %v", gitAuth)
+	}
+	if fields, ok := schema.Entries["secret"]; !ok || len(fields) != 0 {
+		t.Errorf("secret entries have free-form fields, got %v", fields)
+	}
+}
+
 func TestValidateConfigValue(t *testing.T) {
 	if err := validateConfigValue("security", "default_git_auth", "gh"); err != nil {
 		t.Errorf("valid security value rejected: %v", err)
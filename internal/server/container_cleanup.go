@@ -6,6 +6,7 @@ package server
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/openrundev/openrun/internal/container"
@@ -98,3 +99,22 @@ func cleanupStaleContainers(ctx context.Context, logger *types.Logger, manager s
 	}
 	return retErr
 }
+
+// PruneImages removes the app images other than the keep most recent versions of each app, the
+// builder keep_versions config is used if keep is zero
+func (s *Server) PruneImages(ctx context.Context, keep int, dryRun bool) (types.ImagePruneResponse, error) {
+	if err := s.enforceGlobalPerm(ctx, types.PermissionContainerManage, ""); err != nil {
+		return types.ImagePruneResponse{}, err
+	}
+	if s.Config().System.ContainerCommand == types.CONTAINER_KUBERNETES {
+		return types.ImagePruneResponse{}, fmt.Errorf("image prune is not supported for Kubernetes, images are in the registry")
+	}
+	if s.containerRuntime() == "" {
+		return types.ImagePruneResponse{}, fmt.Errorf("no container command is configured on the server")
+	}
+	if keep == 0 {
+		keep = s.Config().Builder.KeepVersions
+	}
+	manager := container.NewCommandCM(s.Logger, s.Config(), "", "")
+	return manager.PruneImages(ctx, keep, dryRun)
+}
@@ -0,0 +1,146 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/app/apptype"
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// RunContractChecks runs the contract checks declared in the app contract.star file, to verify
+// that the proxied backend still behaves as the app expects. For a prod app, the checks are run
+// against its stage app
+func (s *Server) RunContractChecks(ctx context.Context, appPath string) (*types.ContractResponse, error) {
+	appPathDomain, err := parseAppPath(appPath)
+	if err != nil {
+		return nil, err
+	}
+	appEntry, testApp, err := s.loadTestApp(ctx, appPathDomain, types.PermissionAppManage)
+	if err != nil {
+		return nil, err
+	}
+	checks, err := testApp.ContractChecks()
+	if err != nil {
+		return nil, err
+	}
+	if checks == nil {
+		return nil, fmt.Errorf("app %s has no %s file", appEntry.AppPathDomain(), apptype.CONTRACT_FILE_NAME)
+	}
+	return s.runContractChecks(ctx, appEntry, testApp, checks), nil
+}
+
+// checkContractBeforePromote runs the contract checks against the stage app of a prod app being
+// promoted. Apps without a contract file pass
+func (s *Server) checkContractBeforePromote(ctx context.Context, appPathDomain types.AppPathDomain) error {
+	appEntry, testApp, err := s.loadTestApp(ctx, appPathDomain, types.PermissionPromote)
+	if err != nil {
+		return err
+	}
+	checks, err := testApp.ContractChecks()
+	if err != nil || checks == nil {
+		return err
+	}
+	ret := s.runContractChecks(ctx, appEntry, testApp, checks)
+	if ret.Failed == 0 {
+		return nil
+	}
+	failed := []string{}
+	for _, result := range ret.Results {
+		if !result.Passed {
+			failed = append(failed, fmt.Sprintf("%s (%s)", result.Name, strings.Join(result.Errors, ", ")))
+		}
+	}
+	return fmt.Errorf("contract checks failed for %s, not promoting: %s", ret.AppPath, strings.Join(failed, "; "))
+}
+
+func (s *Server) runContractChecks(ctx context.Context, appEntry *types.AppEntry, testApp *app.App, checks []apptype.ContractCheck) *types.ContractResponse {
+	host := cmp.Or(appEntry.Domain, s.Config().System.DefaultDomain, "localhost")
+	ret := &types.ContractResponse{AppPath: appEntry.AppPathDomain().String(), Results: make([]types.ContractCheckResult, 0, len(checks))}
+	for _, check := range checks {
+		reqCtx := &authContext{
+			Context:     ctx,
+			userId:      system.GetContextUserId(ctx),
+			appId:       string(appEntry.Id),
+			pathDomain:  mainAppPathDomain(appEntry.AppPathDomain(), appEntry.MainApp, appEntry.LinkedAppPath),
+			customPerms: make([]string, 0),
+		}
+		result := runContractCheck(reqCtx, testApp, host, appEntry.Path, check)
+		if result.Passed {
+			ret.Passed++
+		} else {
+			ret.Failed++
+		}
+		ret.Results = append(ret.Results, result)
+	}
+	s.Info().Str("app", ret.AppPath).Msgf("Ran contract checks, %d passed, %d failed", ret.Passed, ret.Failed)
+	return ret
+}
+
+// runContractCheck makes the check request through the app, in-process, so that the request
+// reaches the backend the same way as a user request
+func runContractCheck(ctx context.Context, handler http.Handler, host, basePath string, check apptype.ContractCheck) (result types.ContractCheckResult) {
+	result = types.ContractCheckResult{Name: check.Name, Method: check.Method, Path: check.Path, Errors: []string{}}
+	start := time.Now()
+	defer func() {
+		// The named result is updated after the return, for both the error and the success cases
+		result.Passed = len(result.Errors) == 0
+		result.DurationMs = time.Since(start).Milliseconds()
+	}()
+
+	target := "http://" + host + strings.TrimSuffix(basePath, "/") + check.Path
+	req, err := http.NewRequestWithContext(ctx, check.Method, target, strings.NewReader(check.Body))
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return result
+	}
+	req.RemoteAddr = "127.0.0.1:0"
+	for key, value := range check.Headers {
+		req.Header.Set(key, value)
+	}
+
+	rw := newReplayResponseWriter(e2eMaxBodySize)
+	handler.ServeHTTP(rw, req)
+	result.Status = cmp.Or(rw.status, http.StatusOK)
+	result.Errors = verifyContract(check, result.Status, rw.header, rw.body.String())
+	return result
+}
+
+// verifyContract returns the list of expectations of the check which the response does not meet
+func verifyContract(check apptype.ContractCheck, status int, header http.Header, body string) []string {
+	errs := []string{}
+	if status != check.Status {
+		errs = append(errs, fmt.Sprintf("expected status %d, got %d", check.Status, status))
+	}
+	for _, key := range slices.Sorted(maps.Keys(check.ExpectHeaders)) {
+		expected := check.ExpectHeaders[key]
+		actual := header.Get(key)
+		if actual == "" {
+			errs = append(errs, fmt.Sprintf("header %s is missing", key))
+		} else if !strings.Contains(actual, expected) {
+			errs = append(errs, fmt.Sprintf("header %s is %q, expected it to contain %q", key, actual, expected))
+		}
+	}
+	for _, fragment := range check.Contains {
+		if !strings.Contains(body, fragment) {
+			errs = append(errs, fmt.Sprintf("body does not contain %q", fragment))
+		}
+	}
+	for _, fragment := range check.NotContains {
+		if strings.Contains(body, fragment) {
+			errs = append(errs, fmt.Sprintf("body contains %q", fragment))
+		}
+	}
+	return errs
+}
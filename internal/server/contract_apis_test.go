@@ -0,0 +1,44 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/app/apptype"
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func TestRunContractCheck(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/myapp/api/status" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		io.WriteString(w, `{"status": "ok"}`) //nolint:errcheck
+	})
+
+	check := apptype.ContractCheck{Name: "status", Method: http.MethodGet, Path: "/api/status", Status: http.StatusOK,
+		ExpectHeaders: map[string]string{"Content-Type": "application/json"}, Contains: []string{`"ok"`}, NotContains: []string{"error"}}
+	result := runContractCheck(context.Background(), handler, "localhost", "/myapp", check)
+	testutil.AssertEqualsBool(t, "passed", true, result.Passed)
+	testutil.AssertEqualsInt(t, "status", 200, result.Status)
+
+	check.ExpectHeaders = map[string]string{"Content-Type": "text/html", "X-Version": "2"}
+	check.Contains = []string{"healthy"}
+	check.NotContains = []string{"status"}
+	result = runContractCheck(context.Background(), handler, "localhost", "/myapp", check)
+	testutil.AssertEqualsBool(t, "failed", false, result.Passed)
+	testutil.AssertEqualsInt(t, "errors", 4, len(result.Errors))
+	testutil.AssertEqualsString(t, "header mismatch", `header Content-Type is "application/json; charset=utf-8", expected it to contain "text/html"`, result.Errors[0])
+	testutil.AssertEqualsString(t, "header missing", "header X-Version is missing", result.Errors[1])
+
+	check = apptype.ContractCheck{Name: "missing", Method: http.MethodGet, Path: "/api/missing", Status: http.StatusOK}
+	result = runContractCheck(context.Background(), handler, "localhost", "/myapp", check)
+	testutil.AssertEqualsString(t, "status error", "expected status 200, got 404", result.Errors[0])
+}
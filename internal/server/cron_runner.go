@@ -0,0 +1,176 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/segmentio/ksuid"
+)
+
+// cronUser is the user id recorded for the jobs and audit events of scheduled tasks
+const cronUser = "cron"
+
+// runCrons submits a background job for each app scheduled task which is due. It runs on the
+// leader only, at most once a minute. Schedules are evaluated in the server time zone
+func (s *Server) runCrons(ctx context.Context, runner *jobRunner) {
+	now := time.Now()
+	minute := now.Truncate(time.Minute)
+	if minute.Equal(runner.lastCronCheck) || !s.db.IsLeader() {
+		return
+	}
+	runner.lastCronCheck = minute
+
+	appCrons, err := s.db.GetProdAppCrons(ctx)
+	if err != nil {
+		s.Error().Err(err).Msg("Error reading app crons")
+		return
+	}
+	states, err := s.db.GetCronStates(ctx, "")
+	if err != nil {
+		s.Error().Err(err).Msg("Error reading cron state")
+		return
+	}
+
+	for appId, crons := range appCrons {
+		for _, cron := range crons {
+			if ctx.Err() != nil {
+				return
+			}
+			if err := s.runCron(ctx, appId, cron, states[appId][cron.Name], minute); err != nil {
+				s.Error().Err(err).Str("app_id", string(appId)).Str("cron", cron.Name).Msg("Error running cron")
+			}
+		}
+	}
+
+	// Remove the state of crons which are no longer declared, so that a cron added back later
+	// does not run for the schedules missed in between
+	for appId, appStates := range states {
+		for name := range appStates {
+			if slices.ContainsFunc(appCrons[appId], func(c types.CronDef) bool { return c.Name == name }) {
+				continue
+			}
+			if err := s.db.DeleteCronState(ctx, appId, name); err != nil {
+				s.Error().Err(err).Str("app_id", string(appId)).Str("cron", name).Msg("Error deleting cron state")
+			}
+		}
+	}
+}
+
+// runCron submits the job for one cron if the schedule matched since its last run. Runs missed
+// while no leader was running are collapsed into one run. If the job for the previous run is
+// still active, the run is skipped
+func (s *Server) runCron(ctx context.Context, appId types.AppId, cron types.CronDef, state *types.CronState, minute time.Time) error {
+	schedule, err := system.ParseCronSchedule(cron.Schedule)
+	if err != nil {
+		return err
+	}
+
+	if state == nil || state.LastRunTime == nil {
+		// First time the cron is seen, the schedule starts from now
+		return s.db.SaveCronState(ctx, &types.CronState{AppId: appId, Name: cron.Name, LastRunTime: &minute})
+	}
+	next := schedule.Next(state.LastRunTime.In(minute.Location()))
+	if next.IsZero() || next.After(minute) {
+		return nil
+	}
+
+	state.LastRunTime = &minute
+	if state.LastJobId != "" {
+		// A job which is not found has been cleaned up, it is done
+		if lastJob, err := s.db.GetJob(ctx, state.LastJobId); err == nil && !lastJob.Done() {
+			state.SkipCount++
+			s.Warn().Str("app_id", string(appId)).Str("cron", cron.Name).Str("job", lastJob.Id).
+				Msg("Skipping cron run, previous run is still active")
+			s.insertCronAuditEvent(appId, cron, "cron_skip", types.EventStatusFailure,
+				fmt.Sprintf("previous run %s is %s", lastJob.Id, lastJob.Status))
+			return s.db.SaveCronState(ctx, state)
+		}
+	}
+
+	job := &types.JobEntry{
+		Id:       types.ID_PREFIX_JOB + ksuid.New().String(),
+		AppId:    appId,
+		FuncName: cron.Handler,
+		UserId:   cronUser,
+	}
+	if err := s.SubmitJob(ctx, job); err != nil {
+		s.insertCronAuditEvent(appId, cron, "cron_run", types.EventStatusFailure, err.Error())
+		return err
+	}
+	state.LastJobId = job.Id
+	state.SkipCount = 0
+	s.insertCronAuditEvent(appId, cron, "cron_run", types.EventStatusSuccess,
+		fmt.Sprintf("submitted job %s for %s", job.Id, cron.Handler))
+	return s.db.SaveCronState(ctx, state)
+}
+
+func (s *Server) insertCronAuditEvent(appId types.AppId, cron types.CronDef, op string, status types.EventStatus, detail string) {
+	event := types.AuditEvent{
+		RequestId:  system.GetContextRequestId(newBackgroundOperationContext(cronUser)), // each run has its own request id
+		AppId:      appId,
+		CreateTime: time.Now(),
+		UserId:     cronUser,
+		EventType:  types.EventTypeSystem,
+		Operation:  op,
+		Target:     cron.Name,
+		Status:     string(status),
+		Detail:     detail,
+	}
+	if err := s.InsertAuditEvent(&event); err != nil {
+		s.Error().Err(err).Str("app_id", string(appId)).Str("cron", cron.Name).Msg("Error inserting cron audit event")
+	}
+}
+
+// ListCrons returns the scheduled tasks declared by an app, with their last run status
+func (s *Server) ListCrons(ctx context.Context, appPath string) ([]types.CronStatus, error) {
+	appPathDomain, err := parseAppPath(appPath)
+	if err != nil {
+		return nil, err
+	}
+	appEntry, err := s.db.GetAppEntry(ctx, appPathDomain)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.enforceAppPermEntry(ctx, types.PermissionRead, appEntry); err != nil {
+		return nil, err
+	}
+
+	states, err := s.db.GetCronStates(ctx, appEntry.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]types.CronStatus, 0, len(appEntry.Metadata.Crons))
+	for _, cron := range appEntry.Metadata.Crons {
+		status := types.CronStatus{CronDef: cron}
+		if state := states[appEntry.Id][cron.Name]; state != nil {
+			status.SkipCount = state.SkipCount
+			if state.LastJobId != "" {
+				// The state time is set when the cron is first seen, it is a run time only after a job is submitted
+				status.LastRunTime = state.LastRunTime
+				if job, err := s.db.GetJob(ctx, state.LastJobId); err == nil {
+					status.LastJob = job
+				}
+			}
+		}
+		if schedule, err := system.ParseCronSchedule(cron.Schedule); err == nil && strings.HasPrefix(string(appEntry.Id), types.ID_PREFIX_APP_PROD) {
+			from := time.Now()
+			if status.LastRunTime != nil && status.LastRunTime.After(from) {
+				from = *status.LastRunTime
+			}
+			if next := schedule.Next(from); !next.IsZero() {
+				status.NextRunTime = &next
+			}
+		}
+		ret = append(ret, status)
+	}
+	return ret, nil
+}
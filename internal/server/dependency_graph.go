@@ -0,0 +1,245 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// The node types in the dependency graph
+const (
+	graphNodeApp       = "app"
+	graphNodeGitRepo   = "git_repo"
+	graphNodeDirectory = "directory"
+	graphNodeImage     = "image"
+	graphNodeSecret    = "secret"
+	graphNodeBinding   = "binding"
+	graphNodeService   = "service"
+	graphNodeGitAuth   = "git_auth"
+)
+
+// The edge types in the dependency graph
+const (
+	graphEdgeSource      = "source"
+	graphEdgeGitAuth     = "git_auth"
+	graphEdgeImage       = "image"
+	graphEdgeSecret      = "secret"
+	graphEdgeBinding     = "binding"
+	graphEdgeService     = "service"
+	graphEdgeDerivedFrom = "derived_from"
+	graphEdgeAppBlock    = "app_block"
+)
+
+// appBlockUse is an appBlock include of a block from the target app, by the caller app. The
+// uses are recorded when the blocks are rendered, since they are in the app templates
+type appBlockUse struct {
+	caller types.AppPathDomain
+	target types.AppPathDomain
+}
+
+// recordAppBlockUse records the use of a block from the target app, for the dependency graph
+func (s *Server) recordAppBlockUse(caller, target types.AppPathDomain) {
+	s.appBlockUses.Store(appBlockUse{caller: caller, target: target}, true)
+}
+
+// graphBuilder collects the nodes and edges of the dependency graph, duplicates are ignored
+type graphBuilder struct {
+	nodes     map[string]types.GraphNode
+	edges     map[types.GraphEdge]bool
+	secretRef func(defaultProvider, value string) []string
+}
+
+func newGraphBuilder(secretRef func(defaultProvider, value string) []string) *graphBuilder {
+	return &graphBuilder{
+		nodes:     map[string]types.GraphNode{},
+		edges:     map[types.GraphEdge]bool{},
+		secretRef: secretRef,
+	}
+}
+
+// node adds the node if not present and returns the node id
+func (g *graphBuilder) node(nodeType, name string) string {
+	id := nodeType + ":" + name
+	if _, ok := g.nodes[id]; !ok {
+		g.nodes[id] = types.GraphNode{Id: id, Type: nodeType, Name: name}
+	}
+	return id
+}
+
+func (g *graphBuilder) edge(from, to, edgeType string) {
+	g.edges[types.GraphEdge{From: from, To: to, Type: edgeType}] = true
+}
+
+// addSecrets adds edges for the secrets referenced in the values, like {{secret "api_key"}}
+func (g *graphBuilder) addSecrets(from, defaultProvider string, values ...map[string]string) {
+	for _, valueMap := range values {
+		for _, key := range slices.Sorted(maps.Keys(valueMap)) {
+			for _, ref := range g.secretRef(defaultProvider, valueMap[key]) {
+				g.edge(from, g.node(graphNodeSecret, ref), graphEdgeSecret)
+			}
+		}
+	}
+}
+
+// addBinding adds the binding with the service or the binding it derives from
+func (g *graphBuilder) addBinding(binding *types.Binding, defaultProvider string) {
+	id := g.node(graphNodeBinding, binding.Path)
+	if binding.DerivedFrom != "" {
+		g.edge(id, g.node(graphNodeBinding, binding.DerivedFrom), graphEdgeDerivedFrom)
+	} else if binding.ServiceType != "" {
+		g.edge(id, g.node(graphNodeService, binding.ServiceType+"/"+binding.ServiceName), graphEdgeService)
+	}
+	g.addSecrets(id, defaultProvider, binding.Metadata.Config)
+}
+
+// addApp adds the app with its source, git auth, image, secrets and bindings. The image is
+// known for apps using the image spec only, other apps build their image from the source
+func (g *graphBuilder) addApp(appEntry *types.AppEntry, defaultProvider string) string {
+	metadata := &appEntry.Metadata
+	id := g.node(graphNodeApp, appEntry.String())
+	if system.IsGit(appEntry.SourceUrl) {
+		repo, _, err := parseGitUrl(appEntry.SourceUrl, false)
+		if err != nil {
+			repo = appEntry.SourceUrl
+		}
+		g.edge(id, g.node(graphNodeGitRepo, repo), graphEdgeSource)
+		if metadata.GitAuthName != "" {
+			g.edge(id, g.node(graphNodeGitAuth, metadata.GitAuthName), graphEdgeGitAuth)
+		}
+	} else if appEntry.SourceUrl != "" && appEntry.SourceUrl != "-" {
+		g.edge(id, g.node(graphNodeDirectory, appEntry.SourceUrl), graphEdgeSource)
+	}
+
+	if image := appImage(metadata); image != "" {
+		g.edge(id, g.node(graphNodeImage, image), graphEdgeImage)
+	}
+
+	// The app level default secrets provider is set in the app config as a TOML string
+	if provider := strings.Trim(metadata.AppConfig["security.default_secrets_provider"], `"'`); provider != "" {
+		defaultProvider = provider
+	}
+	g.addSecrets(id, defaultProvider, metadata.ParamValues, metadata.AppConfig, metadata.ContainerArgs, metadata.ContainerOptions)
+
+	for _, binding := range metadata.Bindings {
+		g.edge(id, g.node(graphNodeBinding, binding), graphEdgeBinding)
+	}
+	return id
+}
+
+// graph returns the graph, sorted. If resource is set, only the resource and the nodes which
+// depend on it, directly or indirectly, are included
+func (g *graphBuilder) graph(resource string) (*types.DependencyGraph, error) {
+	include := map[string]bool{}
+	if resource == "" {
+		for id := range g.nodes {
+			include[id] = true
+		}
+	} else {
+		if _, ok := g.nodes[resource]; !ok {
+			return nil, fmt.Errorf("resource %s not found in graph, expected an id like secret:db/api_key", resource)
+		}
+		dependents := map[string][]string{}
+		for edge := range g.edges {
+			dependents[edge.To] = append(dependents[edge.To], edge.From)
+		}
+		pending := []string{resource}
+		include[resource] = true
+		for len(pending) > 0 {
+			id := pending[len(pending)-1]
+			pending = pending[:len(pending)-1]
+			for _, from := range dependents[id] {
+				if !include[from] {
+					include[from] = true
+					pending = append(pending, from)
+				}
+			}
+		}
+	}
+
+	ret := &types.DependencyGraph{
+		Nodes: []types.GraphNode{},
+		Edges: []types.GraphEdge{},
+	}
+	for _, id := range slices.Sorted(maps.Keys(include)) {
+		ret.Nodes = append(ret.Nodes, g.nodes[id])
+	}
+	for edge := range g.edges {
+		if include[edge.From] && include[edge.To] {
+			ret.Edges = append(ret.Edges, edge)
+		}
+	}
+	slices.SortFunc(ret.Edges, func(a, b types.GraphEdge) int {
+		return cmp.Or(cmp.Compare(a.From, b.From), cmp.Compare(a.To, b.To), cmp.Compare(a.Type, b.Type))
+	})
+	return ret, nil
+}
+
+// DependencyGraph returns the graph of the apps matching the glob and the resources they depend
+// on: git repos, images, secrets, bindings with their services, git auth entries and the apps
+// whose blocks they include. The prod app metadata is used, stage and preview apps are not
+// included. If resource is set, only the apps and resources which depend on it are returned
+func (s *Server) DependencyGraph(ctx context.Context, appPathGlob, resource string) (*types.DependencyGraph, error) {
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	g := newGraphBuilder(s.secretsMgr().SecretRefs)
+	defaultProvider := s.Config().AppConfig.Security.DefaultSecretsProvider
+	bindings, err := s.readableBindings(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	for _, binding := range bindings {
+		g.addBinding(binding, defaultProvider)
+	}
+
+	filteredApps, err := s.FilterApps(cmp.Or(appPathGlob, "all"), false)
+	if err != nil {
+		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+	}
+	userId := system.GetContextUserId(ctx)
+	groups := system.GetContextGroups(ctx)
+	appIds := map[types.AppPathDomain]string{}
+	for _, app := range filteredApps {
+		authorized, err := s.AuthorizeList(ctx, userId, &app, groups)
+		if err != nil {
+			return nil, err
+		}
+		if !authorized {
+			continue
+		}
+		appEntry, err := s.db.GetAppEntryTx(ctx, tx, app.AppPathDomain)
+		if err != nil {
+			return nil, fmt.Errorf("error reading app %s: %w", app.AppPathDomain, err)
+		}
+		appIds[app.AppPathDomain] = g.addApp(appEntry, defaultProvider)
+	}
+
+	s.appBlockUses.Range(func(key, _ any) bool {
+		use := key.(appBlockUse)
+		callerId, callerOk := appIds[use.caller]
+		targetId, targetOk := appIds[use.target]
+		if callerOk && targetOk {
+			g.edge(callerId, targetId, graphEdgeAppBlock)
+		}
+		return true
+	})
+
+	ret, err := g.graph(resource)
+	if err != nil {
+		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+	}
+	return ret, nil
+}
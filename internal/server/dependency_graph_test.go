@@ -0,0 +1,76 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func testSecretRefs(defaultProvider, value string) []string {
+	if name, ok := strings.CutPrefix(value, "secret:"); ok {
+		return []string{defaultProvider + "/" + name}
+	}
+	return nil
+}
+
+func TestDependencyGraph(t *testing.T) {
+	g := newGraphBuilder(testSecretRefs)
+	g.addBinding(&types.Binding{Path: "pg", ServiceType: "postgres", ServiceName: "main",
+		Metadata: types.BindingMetadata{Config: map[string]string{"password": "secret:pg_pass"}}}, "env")
+	g.addBinding(&types.Binding{Path: "pg_ro", DerivedFrom: "pg"}, "env")
+	app1 := g.addApp(&types.AppEntry{Path: "/app1", SourceUrl: "github.com/openrundev/apps/misc/app1",
+		Metadata: types.AppMetadata{
+			GitAuthName: "gh",
+			ParamValues: map[string]string{"key": "secret:api_key", "name": "x"},
+			Bindings:    []string{"pg_ro"},
+		}}, "env")
+	app2 := g.addApp(&types.AppEntry{Path: "/app2", Domain: "example.com", SourceUrl: "-",
+		Metadata: types.AppMetadata{
+			Spec:        "image",
+			ParamValues: map[string]string{"image": "nginx"},
+			AppConfig:   map[string]string{"security.default_secrets_provider": `"prop"`, "a": "secret:api_key"},
+		}}, "env")
+	g.addApp(&types.AppEntry{Path: "/app3", SourceUrl: "/home/user/app3"}, "env")
+	g.edge(app2, app1, graphEdgeAppBlock)
+
+	graph, err := g.graph("")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "nodes", 13, len(graph.Nodes))
+	edges := []string{}
+	for _, edge := range graph.Edges {
+		edges = append(edges, edge.From+" "+edge.Type+" "+edge.To)
+	}
+	testutil.AssertEqualsString(t, "edges", strings.Join([]string{
+		"app:/app1 binding binding:pg_ro",
+		"app:/app1 git_auth git_auth:gh",
+		"app:/app1 source git_repo:https://github.com/openrundev/apps",
+		"app:/app1 secret secret:env/api_key",
+		"app:/app3 source directory:/home/user/app3",
+		"app:example.com:/app2 app_block app:/app1",
+		"app:example.com:/app2 image image:nginx",
+		"app:example.com:/app2 secret secret:prop/api_key",
+		"binding:pg secret secret:env/pg_pass",
+		"binding:pg service service:postgres/main",
+		"binding:pg_ro derived_from binding:pg",
+	}, "\n"), strings.Join(edges, "\n"))
+
+	// The apps which break if the postgres password is rotated
+	graph, err = g.graph("secret:env/pg_pass")
+	testutil.AssertNoError(t, err)
+	nodes := []string{}
+	for _, node := range graph.Nodes {
+		nodes = append(nodes, node.Id)
+	}
+	testutil.AssertEqualsString(t, "dependents",
+		"[app:/app1 app:example.com:/app2 binding:pg binding:pg_ro secret:env/pg_pass]", fmt.Sprint(nodes))
+	testutil.AssertEqualsInt(t, "dependent edges", 4, len(graph.Edges))
+
+	_, err = g.graph("secret:unknown")
+	testutil.AssertErrorContains(t, err, "resource secret:unknown not found in graph")
+}
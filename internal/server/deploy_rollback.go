@@ -55,8 +55,10 @@ func bindingEffectsFromContext(ctx context.Context) *bindingAccountManager {
 // dry run the manager touches no external services. The owning scope's
 // transaction is registered on the server so its in-flight containers are
 // visible (e.g. to the stale container sweeper) until commit or finish
-// unregisters it.
+// unregisters it. The context also carries the operation's promote state, a dry
+// run does not require the promote changes to be confirmed.
 func (s *Server) beginDeployScope(ctx context.Context, ownsDB, dryRun bool) (context.Context, *operationScope) {
+	ctx = withPromoteState(ctx, dryRun)
 	txn := container.DeployTxnFromContext(ctx)
 	accounts, _ := ctx.Value(bindingEffectsCtxKey{}).(*bindingAccountManager)
 	own := false
@@ -0,0 +1,38 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+
+	"github.com/openrundev/openrun/internal/container"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// deviceOptionsChanged reports whether a gpus or devices container option is added, removed or
+// updated between the old and new options
+func deviceOptionsChanged(oldOptions, newOptions map[string]string) bool {
+	for _, options := range []map[string]string{oldOptions, newOptions} {
+		for key := range options {
+			if !container.IsDeviceOption(key) {
+				continue
+			}
+			oldValue, oldOk := oldOptions[key]
+			newValue, newOk := newOptions[key]
+			if oldOk != newOk || oldValue != newValue {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkDeviceOptions requires the container:devices permission for changing the gpus and devices
+// container options. The container_devices server config is enforced when the container is started
+func (s *Server) checkDeviceOptions(ctx context.Context, oldOptions, newOptions map[string]string) error {
+	if !deviceOptionsChanged(oldOptions, newOptions) {
+		return nil
+	}
+	return s.enforceGlobalPerm(ctx, types.PermissionContainerDevices, "")
+}
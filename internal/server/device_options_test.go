@@ -0,0 +1,19 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func TestDeviceOptionsChanged(t *testing.T) {
+	old := map[string]string{"cpus": "1", "gpus": "all"}
+	testutil.AssertEqualsBool(t, "unchanged", false, deviceOptionsChanged(old, map[string]string{"gpus": "all", "cpus": "2"}))
+	testutil.AssertEqualsBool(t, "shm_size", false, deviceOptionsChanged(nil, map[string]string{"shm_size": "1g"}))
+	testutil.AssertEqualsBool(t, "updated", true, deviceOptionsChanged(old, map[string]string{"gpus": "1"}))
+	testutil.AssertEqualsBool(t, "removed", true, deviceOptionsChanged(old, map[string]string{"cpus": "1"}))
+	testutil.AssertEqualsBool(t, "added", true, deviceOptionsChanged(nil, map[string]string{"docker.devices": "/dev/fuse"}))
+}
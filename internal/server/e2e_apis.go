@@ -0,0 +1,99 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// RunE2ETests runs an end-to-end test script against an app. For a prod app, the tests are
+// run against its stage app. Stage and dev apps are tested directly. The requests are made
+// as the user calling the API
+func (s *Server) RunE2ETests(ctx context.Context, appPath string, req *types.E2ERequest) (*types.E2EResponse, error) {
+	if strings.TrimSpace(req.Script) == "" {
+		return nil, fmt.Errorf("test script is empty")
+	}
+	appEntry, testApp, err := s.getTestApp(ctx, appPath)
+	if err != nil {
+		return nil, err
+	}
+
+	runner := &e2eRunner{
+		handler: testApp,
+		newCtx: func() context.Context {
+			return &authContext{
+				Context:     ctx,
+				userId:      system.GetContextUserId(ctx),
+				appId:       string(appEntry.Id),
+				pathDomain:  mainAppPathDomain(appEntry.AppPathDomain(), appEntry.MainApp, appEntry.LinkedAppPath),
+				customPerms: make([]string, 0),
+			}
+		},
+		host:      cmp.Or(appEntry.Domain, s.Config().System.DefaultDomain, "localhost"),
+		basePath:  appEntry.Path,
+		snapshots: req.Snapshots,
+		update:    req.Update,
+	}
+	results, err := runner.run(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &types.E2EResponse{AppPath: appEntry.AppPathDomain().String(), Results: results}
+	for _, result := range results {
+		if result.Passed {
+			ret.Passed++
+		} else {
+			ret.Failed++
+		}
+	}
+	s.Info().Str("app", ret.AppPath).Msgf("Ran e2e tests, %d passed, %d failed", ret.Passed, ret.Failed)
+	return ret, nil
+}
+
+// getTestApp returns the app to run tests against. For a prod app, that is its stage app. Stage
+// and dev apps are tested directly. The caller needs app:manage on the tested app
+func (s *Server) getTestApp(ctx context.Context, appPath string) (*types.AppEntry, *app.App, error) {
+	appPathDomain, err := parseAppPath(appPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.loadTestApp(ctx, appPathDomain, types.PermissionAppManage)
+}
+
+// loadTestApp returns the app to test, after checking that the caller has the permission on it
+func (s *Server) loadTestApp(ctx context.Context, appPathDomain types.AppPathDomain, perm types.RBACPermission) (*types.AppEntry, *app.App, error) {
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	appEntry, err := s.db.GetAppEntryTx(ctx, tx, appPathDomain)
+	if err != nil {
+		return nil, nil, err
+	}
+	if strings.HasPrefix(string(appEntry.Id), types.ID_PREFIX_APP_PROD) {
+		if appEntry, err = s.getStageApp(ctx, tx, appEntry); err != nil {
+			return nil, nil, err
+		}
+	}
+	if err := s.enforceAppPermEntry(ctx, perm, appEntry); err != nil {
+		return nil, nil, err
+	}
+	tx.Rollback() //nolint:errcheck
+
+	testApp, err := s.GetApp(ctx, appEntry.AppPathDomain(), true)
+	if err != nil {
+		return nil, nil, err
+	}
+	return appEntry, testApp, nil
+}
@@ -0,0 +1,445 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/app/starlark_type"
+	"github.com/openrundev/openrun/internal/types"
+	starlarkjson "go.starlark.net/lib/json"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+const (
+	e2eTestPrefix      = "test_"
+	e2eMaxBodySize     = 10 * 1024 * 1024
+	e2eTestTimeout     = 2 * time.Minute
+	e2eIgnoredSnapshot = "[ignored]"
+)
+
+// e2eRunner runs an end-to-end test script against an app. Each top level function named
+// test_* is a test, called with a test context which has functions to make requests to the
+// app and to make assertions. The requests are served in-process by the app handler, with
+// a cookie jar per test, so login sessions work across requests within a test
+type e2eRunner struct {
+	handler   http.Handler
+	newCtx    func() context.Context // the context for each request, with the user and app info
+	host      string
+	basePath  string
+	snapshots map[string]string
+	update    bool
+}
+
+// e2eTest is the state for one test function run
+type e2eTest struct {
+	runner *e2eRunner
+	jar    *cookiejar.Jar
+	result *types.E2ETestResult
+}
+
+func (r *e2eRunner) run(ctx context.Context, req *types.E2ERequest) ([]types.E2ETestResult, error) {
+	thread := &starlark.Thread{
+		Name:  req.ScriptName,
+		Print: func(_ *starlark.Thread, msg string) {},
+	}
+	predeclared := starlark.StringDict{
+		"json": starlarkjson.Module,
+	}
+	globals, err := starlark.ExecFileOptions(&syntax.FileOptions{}, thread, req.ScriptName, req.Script, predeclared)
+	if err != nil {
+		return nil, fmt.Errorf("error loading test script: %w", err)
+	}
+
+	tests := make([]*starlark.Function, 0)
+	for name, value := range globals {
+		fn, ok := value.(*starlark.Function)
+		if !ok || !strings.HasPrefix(name, e2eTestPrefix) {
+			continue
+		}
+		if req.Filter != "" {
+			if matched, _ := path.Match(req.Filter, name); !matched {
+				continue
+			}
+		}
+		tests = append(tests, fn)
+	}
+	// Run the tests in the order they are defined in the script
+	slices.SortFunc(tests, func(a, b *starlark.Function) int {
+		return int(a.Position().Line) - int(b.Position().Line)
+	})
+
+	results := make([]types.E2ETestResult, 0, len(tests))
+	for _, fn := range tests {
+		results = append(results, r.runTest(ctx, fn))
+	}
+	return results, nil
+}
+
+func (r *e2eRunner) runTest(ctx context.Context, fn *starlark.Function) (result types.E2ETestResult) {
+	result = types.E2ETestResult{Name: fn.Name()}
+	start := time.Now()
+	defer func() {
+		if rec := recover(); rec != nil {
+			result.Passed = false
+			result.Error = fmt.Sprintf("panic in test: %v", rec)
+		}
+		result.DurationMs = time.Since(start).Milliseconds()
+	}()
+
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	test := &e2eTest{runner: r, jar: jar, result: &result}
+
+	testCtx, cancel := context.WithTimeout(ctx, e2eTestTimeout)
+	defer cancel()
+	thread := &starlark.Thread{
+		Name:  fn.Name(),
+		Print: func(_ *starlark.Thread, msg string) {},
+	}
+	stop := context.AfterFunc(testCtx, func() { thread.Cancel(testCtx.Err().Error()) })
+	defer stop()
+
+	if _, err = starlark.Call(thread, fn, starlark.Tuple{test.testContext()}, nil); err != nil {
+		result.Error = err.Error()
+		if evalErr, ok := err.(*starlark.EvalError); ok {
+			result.Error = evalErr.Backtrace()
+		}
+		return result
+	}
+	result.Passed = true
+	return result
+}
+
+func (t *e2eTest) testContext() starlark.Value {
+	builtins := starlark.StringDict{
+		"get":     starlark.NewBuiltin("get", t.methodRequest(http.MethodGet)),
+		"post":    starlark.NewBuiltin("post", t.methodRequest(http.MethodPost)),
+		"put":     starlark.NewBuiltin("put", t.methodRequest(http.MethodPut)),
+		"delete":  starlark.NewBuiltin("delete", t.methodRequest(http.MethodDelete)),
+		"request": starlark.NewBuiltin("request", t.request),
+
+		"assert_status":       starlark.NewBuiltin("assert_status", t.assertStatus),
+		"assert_contains":     starlark.NewBuiltin("assert_contains", t.assertContains(true)),
+		"assert_not_contains": starlark.NewBuiltin("assert_not_contains", t.assertContains(false)),
+		"assert_equals":       starlark.NewBuiltin("assert_equals", t.assertEquals),
+		"assert_true":         starlark.NewBuiltin("assert_true", t.assertTrue),
+		"snapshot":            starlark.NewBuiltin("snapshot", t.snapshot),
+		"fail":                starlark.NewBuiltin("fail", t.fail),
+	}
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, builtins)
+}
+
+type starlarkBuiltin func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error)
+
+func (t *e2eTest) methodRequest(method string) starlarkBuiltin {
+	return func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		return t.request(thread, fn, append(starlark.Tuple{starlark.String(method)}, args...), kwargs)
+	}
+}
+
+// request makes a request to the app, request(method, path, params={}, headers={}, form=None, json=None, body="")
+func (t *e2eTest) request(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var method, reqPath, body starlark.String
+	var params, headers, form *starlark.Dict
+	var jsonValue starlark.Value = starlark.None
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "method", &method, "path", &reqPath, "params?", &params,
+		"headers?", &headers, "form?", &form, "json?", &jsonValue, "body?", &body); err != nil {
+		return nil, err
+	}
+
+	target := &url.URL{
+		Scheme: "http",
+		Host:   t.runner.host,
+		Path:   strings.TrimSuffix(t.runner.basePath, "/") + "/" + strings.TrimPrefix(reqPath.GoString(), "/"),
+	}
+	if strings.Contains(target.Path, "?") {
+		return nil, fmt.Errorf("%s: pass the query parameters using params", fn.Name())
+	}
+	query, err := dictToValues(params)
+	if err != nil {
+		return nil, err
+	}
+	target.RawQuery = query.Encode()
+
+	var reqBody []byte
+	contentType := ""
+	switch {
+	case form != nil:
+		values, err := dictToValues(form)
+		if err != nil {
+			return nil, err
+		}
+		reqBody = []byte(values.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	case jsonValue != starlark.None:
+		value, err := starlark_type.UnmarshalStarlark(jsonValue)
+		if err != nil {
+			return nil, err
+		}
+		if reqBody, err = json.Marshal(value); err != nil {
+			return nil, err
+		}
+		contentType = "application/json"
+	default:
+		reqBody = []byte(body.GoString())
+	}
+
+	req, err := http.NewRequestWithContext(t.runner.newCtx(), strings.ToUpper(method.GoString()), target.String(), bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req.RemoteAddr = "127.0.0.1:0"
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if headers != nil {
+		for _, item := range headers.Items() {
+			key, ok1 := item[0].(starlark.String)
+			value, ok2 := item[1].(starlark.String)
+			if !ok1 || !ok2 {
+				return nil, fmt.Errorf("%s: headers should be a dict of strings", fn.Name())
+			}
+			req.Header.Set(key.GoString(), value.GoString())
+		}
+	}
+	for _, cookie := range t.jar.Cookies(target) {
+		req.AddCookie(cookie)
+	}
+
+	rw := newReplayResponseWriter(e2eMaxBodySize)
+	t.runner.handler.ServeHTTP(rw, req)
+	t.result.Requests++
+	status := rw.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	t.jar.SetCookies(target, (&http.Response{Header: rw.header}).Cookies())
+
+	respHeaders := starlark.NewDict(len(rw.header))
+	for key, values := range rw.header {
+		respHeaders.SetKey(starlark.String(strings.ToLower(key)), starlark.String(strings.Join(values, ", "))) //nolint:errcheck
+	}
+	var respJson starlark.Value = starlark.None
+	var decoded any
+	if err := json.Unmarshal(rw.body.Bytes(), &decoded); err == nil {
+		if respJson, err = starlark_type.MarshalStarlark(decoded); err != nil {
+			return nil, err
+		}
+	}
+
+	return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+		"status":    starlark.MakeInt(status),
+		"body":      starlark.String(rw.body.String()),
+		"headers":   respHeaders,
+		"json":      respJson,
+		"url":       starlark.String(reqPath.GoString()),
+		"truncated": starlark.Bool(rw.overflow),
+	}), nil
+}
+
+func dictToValues(dict *starlark.Dict) (url.Values, error) {
+	values := url.Values{}
+	if dict == nil {
+		return values, nil
+	}
+	for _, item := range dict.Items() {
+		key, ok := item[0].(starlark.String)
+		if !ok {
+			return nil, fmt.Errorf("expected string key, got %s", item[0].Type())
+		}
+		switch v := item[1].(type) {
+		case starlark.String:
+			values.Add(key.GoString(), v.GoString())
+		case *starlark.List:
+			for i := range v.Len() {
+				values.Add(key.GoString(), starlarkText(v.Index(i)))
+			}
+		default:
+			values.Add(key.GoString(), starlarkText(v))
+		}
+	}
+	return values, nil
+}
+
+// starlarkText returns the string value for strings, the starlark representation otherwise
+func starlarkText(value starlark.Value) string {
+	if s, ok := value.(starlark.String); ok {
+		return s.GoString()
+	}
+	return value.String()
+}
+
+// responseText returns the body if value is a response, the string value otherwise
+func responseText(value starlark.Value) string {
+	if resp, ok := value.(*starlarkstruct.Struct); ok {
+		if body, err := resp.Attr("body"); err == nil && body != nil {
+			return starlarkText(body)
+		}
+	}
+	return starlarkText(value)
+}
+
+func assertionMessage(msg starlark.String, format string, args ...any) error {
+	if msg != "" {
+		return fmt.Errorf("assertion failed: %s: %s", msg.GoString(), fmt.Sprintf(format, args...))
+	}
+	return fmt.Errorf("assertion failed: %s", fmt.Sprintf(format, args...))
+}
+
+func (t *e2eTest) assertStatus(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var resp *starlarkstruct.Struct
+	var expected int
+	var msg starlark.String
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "response", &resp, "status", &expected, "msg?", &msg); err != nil {
+		return nil, err
+	}
+	t.result.Assertions++
+	statusValue, err := resp.Attr("status")
+	if err != nil {
+		return nil, err
+	}
+	status, err := starlark.AsInt32(statusValue)
+	if err != nil {
+		return nil, err
+	}
+	if status != expected {
+		url, _ := resp.Attr("url")
+		return nil, assertionMessage(msg, "expected status %d, got %d for %s", expected, status, responseText(url))
+	}
+	return starlark.None, nil
+}
+
+func (t *e2eTest) assertContains(expect bool) starlarkBuiltin {
+	return func(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var value starlark.Value
+		var substr, msg starlark.String
+		if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "value", &value, "substr", &substr, "msg?", &msg); err != nil {
+			return nil, err
+		}
+		t.result.Assertions++
+		if strings.Contains(responseText(value), substr.GoString()) != expect {
+			if expect {
+				return nil, assertionMessage(msg, "%q not found", substr.GoString())
+			}
+			return nil, assertionMessage(msg, "%q found", substr.GoString())
+		}
+		return starlark.None, nil
+	}
+}
+
+func (t *e2eTest) assertEquals(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var actual, expected starlark.Value
+	var msg starlark.String
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "actual", &actual, "expected", &expected, "msg?", &msg); err != nil {
+		return nil, err
+	}
+	t.result.Assertions++
+	equal, err := starlark.Equal(actual, expected)
+	if err != nil {
+		return nil, err
+	}
+	if !equal {
+		return nil, assertionMessage(msg, "expected %s, got %s", expected.String(), actual.String())
+	}
+	return starlark.None, nil
+}
+
+func (t *e2eTest) assertTrue(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var cond starlark.Value
+	var msg starlark.String
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "cond", &cond, "msg?", &msg); err != nil {
+		return nil, err
+	}
+	t.result.Assertions++
+	if !cond.Truth() {
+		return nil, assertionMessage(msg, "condition is false")
+	}
+	return starlark.None, nil
+}
+
+func (t *e2eTest) fail(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var msg starlark.String
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "msg", &msg); err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("test failed: %s", msg.GoString())
+}
+
+// snapshot compares the response body (or string value) with the named snapshot, after
+// normalizing whitespace. Parts matching the ignore regexes (like CSRF tokens) are masked
+func (t *e2eTest) snapshot(thread *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var value starlark.Value
+	var name starlark.String
+	var ignore *starlark.List
+	if err := starlark.UnpackArgs(fn.Name(), args, kwargs, "value", &value, "name", &name, "ignore?", &ignore); err != nil {
+		return nil, err
+	}
+	if name == "" || strings.ContainsAny(name.GoString(), `/\`) {
+		return nil, fmt.Errorf("%s: invalid snapshot name %q", fn.Name(), name.GoString())
+	}
+
+	ignorePatterns := []*regexp.Regexp{}
+	if ignore != nil {
+		for i := range ignore.Len() {
+			re, err := regexp.Compile(starlarkText(ignore.Index(i)))
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid ignore pattern: %w", fn.Name(), err)
+			}
+			ignorePatterns = append(ignorePatterns, re)
+		}
+	}
+
+	t.result.Assertions++
+	actual := normalizeSnapshot(responseText(value), ignorePatterns)
+	expected, ok := t.runner.snapshots[name.GoString()]
+	result := types.E2ESnapshotResult{Name: name.GoString()}
+	switch {
+	case !ok:
+		result.Status = types.E2ESnapshotNew
+		result.Actual = actual
+	case normalizeSnapshot(expected, ignorePatterns) == actual:
+		result.Status = types.E2ESnapshotMatch
+	default:
+		result.Status = types.E2ESnapshotMismatch
+		result.Actual = actual
+	}
+	t.result.Snapshots = append(t.result.Snapshots, result)
+	if result.Status == types.E2ESnapshotMismatch && !t.runner.update {
+		return nil, fmt.Errorf("assertion failed: snapshot %s does not match", name.GoString())
+	}
+	return starlark.None, nil
+}
+
+// normalizeSnapshot masks the ignored patterns, trims the lines and removes blank lines,
+// so that formatting only changes do not cause a mismatch
+func normalizeSnapshot(text string, ignore []*regexp.Regexp) string {
+	for _, re := range ignore {
+		text = re.ReplaceAllString(text, e2eIgnoredSnapshot)
+	}
+	lines := strings.Split(text, "\n")
+	ret := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
+			ret = append(ret, line)
+		}
+	}
+	return strings.Join(ret, "\n") + "\n"
+}
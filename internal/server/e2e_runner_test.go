@@ -0,0 +1,125 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func newE2ETestRunner(snapshots map[string]string, update bool) *e2eRunner {
+	router := chi.NewRouter()
+	router.Post("/myapp/login", func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		http.SetCookie(w, &http.Cookie{Name: "sid", Value: r.PostForm.Get("user"), Path: "/myapp"})
+		w.WriteHeader(http.StatusNoContent)
+	})
+	router.Get("/myapp/", func(w http.ResponseWriter, r *http.Request) {
+		user := "anonymous"
+		if c, err := r.Cookie("sid"); err == nil {
+			user = c.Value
+		}
+		w.Header().Set("Content-Type", "text/html")
+		_, _ = w.Write([]byte("<html>\n  <body>Hello " + user + " " + r.URL.Query().Get("q") + "</body>\n</html>\n"))
+	})
+	router.Post("/myapp/api", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		body["ok"] = true
+		_ = json.NewEncoder(w).Encode(body)
+	})
+
+	return &e2eRunner{
+		handler:   router,
+		newCtx:    context.Background,
+		host:      "localhost",
+		basePath:  "/myapp",
+		snapshots: snapshots,
+		update:    update,
+	}
+}
+
+const e2eTestScript = `
+def test_session(t):
+    t.assert_contains(t.get("/"), "Hello anonymous")
+    t.assert_status(t.post("/login", form={"user": "alice"}), 204)
+    resp = t.get("/", params={"q": "x"})
+    t.assert_status(resp, 200)
+    t.assert_contains(resp, "Hello alice x")
+    t.assert_equals(resp.headers["content-type"], "text/html")
+
+def test_json(t):
+    resp = t.post("/api", json={"n": 1})
+    t.assert_true(resp.json["ok"])
+    t.assert_equals(resp.json["n"], 1)
+
+def test_snapshot(t):
+    t.snapshot(t.get("/"), "home")
+
+def test_failure(t):
+    t.assert_status(t.get("/missing"), 200, "missing page")
+
+def helper(t):
+    t.fail("not a test")
+`
+
+func TestE2ERunner(t *testing.T) {
+	runner := newE2ETestRunner(map[string]string{"home": "<html>\n<body>Hello anonymous </body>\n</html>"}, false)
+	results, err := runner.run(context.Background(), &types.E2ERequest{ScriptName: "e2e.star", Script: e2eTestScript})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "tests", 4, len(results))
+
+	// Tests run in the order they are defined
+	testutil.AssertEqualsString(t, "first", "test_session", results[0].Name)
+	testutil.AssertEqualsBool(t, "session passed", true, results[0].Passed)
+	testutil.AssertEqualsInt(t, "session requests", 3, results[0].Requests)
+	testutil.AssertEqualsInt(t, "session assertions", 5, results[0].Assertions)
+	testutil.AssertEqualsBool(t, "json passed", true, results[1].Passed)
+
+	// The cookie jar is per test, the session from test_session is not used
+	testutil.AssertEqualsBool(t, "snapshot passed", true, results[2].Passed)
+	testutil.AssertEqualsString(t, "snapshot match", string(types.E2ESnapshotMatch), string(results[2].Snapshots[0].Status))
+
+	testutil.AssertEqualsBool(t, "failure", false, results[3].Passed)
+	testutil.AssertStringContains(t, results[3].Error, "missing page: expected status 200, got 404 for /missing")
+}
+
+func TestE2ERunnerSnapshots(t *testing.T) {
+	req := &types.E2ERequest{ScriptName: "e2e.star", Script: e2eTestScript, Filter: "test_snap*"}
+
+	// New snapshots do not fail the test
+	results, err := newE2ETestRunner(nil, false).run(context.Background(), req)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "tests", 1, len(results))
+	testutil.AssertEqualsBool(t, "new passed", true, results[0].Passed)
+	testutil.AssertEqualsString(t, "new", string(types.E2ESnapshotNew), string(results[0].Snapshots[0].Status))
+	testutil.AssertEqualsString(t, "actual", "<html>\n<body>Hello anonymous </body>\n</html>\n", results[0].Snapshots[0].Actual)
+
+	// Mismatch fails, unless updating
+	results, err = newE2ETestRunner(map[string]string{"home": "old"}, false).run(context.Background(), req)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsBool(t, "mismatch failed", false, results[0].Passed)
+	testutil.AssertStringContains(t, results[0].Error, "snapshot home does not match")
+
+	results, err = newE2ETestRunner(map[string]string{"home": "old"}, true).run(context.Background(), req)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsBool(t, "update passed", true, results[0].Passed)
+	testutil.AssertEqualsString(t, "mismatch", string(types.E2ESnapshotMismatch), string(results[0].Snapshots[0].Status))
+
+	_, err = newE2ETestRunner(nil, false).run(context.Background(), &types.E2ERequest{ScriptName: "e2e.star", Script: "def test_x(t)"})
+	testutil.AssertErrorContains(t, err, "error loading test script")
+}
+
+func TestNormalizeSnapshot(t *testing.T) {
+	testutil.AssertEqualsString(t, "whitespace", "<a>\nb\n", normalizeSnapshot("  <a>  \n\n\tb\n", nil))
+	ignore := []*regexp.Regexp{regexp.MustCompile(`csrf="[^"]*"`)}
+	testutil.AssertEqualsString(t, "ignored", "<input [ignored]>\n", normalizeSnapshot(`<input csrf="abc">`, ignore))
+}
@@ -0,0 +1,95 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/openrundev/openrun/internal/i18n"
+)
+
+// errorPageTemplate is the page shown to browsers for the router errors (401, 403, 404, 503).
+// It uses the login page stylesheets, so it is served under the same strict CSP
+var errorPageTemplate = template.Must(template.New("error").Parse(`<!doctype html>
+<html lang="{{ .Lang }}">
+  <head>
+    <meta charset="UTF-8" />
+    <meta name="viewport" content="width=device-width, initial-scale=1.0" />
+    <title>{{ .Title }} - OpenRun</title>
+    {{ if .StyleHref }}<link rel="stylesheet" href="{{ .StyleHref }}" />{{ end }}
+    {{ if .ExtraHref }}<link rel="stylesheet" href="{{ .ExtraHref }}" />{{ end }}
+  </head>
+  <body class="min-h-screen bg-base-200 flex items-center justify-center p-4">
+    <main class="w-full max-w-sm">
+      <h1 class="login-title text-2xl md:text-3xl font-extrabold italic tracking-tight mb-4">
+        OpenRun
+      </h1>
+      <div class="card bg-base-100 border border-base-300">
+        <div class="card-body p-5 gap-4">
+          <div>
+            <h2 class="text-lg font-semibold">{{ .Code }} {{ .Title }}</h2>
+            <p class="text-sm text-base-content/70">{{ .Message }}</p>
+          </div>
+          <a href="/" class="btn btn-ghost w-full">{{ .Home }}</a>
+        </div>
+      </div>
+    </main>
+  </body>
+</html>
+`))
+
+func (s *Server) catalog() *i18n.Catalog {
+	if s.messages == nil {
+		return i18n.Builtin()
+	}
+	return s.messages
+}
+
+// requestLanguage returns the language for the messages in the response. The Accept-Language
+// header is used if system.language_from_request is enabled, with system.language as the fallback
+func (s *Server) requestLanguage(r *http.Request) string {
+	config := s.Config().System
+	acceptLanguage := ""
+	if config.LanguageFromRequest {
+		acceptLanguage = r.Header.Get("Accept-Language")
+	}
+	return s.catalog().Match(acceptLanguage, config.Language)
+}
+
+// errorPage writes the localized error message for the key. Browser navigations get a HTML
+// page, other clients get the message as plain text like http.Error
+func (s *Server) errorPage(w http.ResponseWriter, r *http.Request, code int, key string, args ...any) {
+	lang := s.requestLanguage(r)
+	catalog := s.catalog()
+	message := catalog.Message(lang, key, args...)
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+	if !isBrowserNavigation(r) || r.Header.Get("HX-Request") == "true" {
+		// HTMX requests swap the response into the page, a full page is not useful there
+		http.Error(w, message, code)
+		return
+	}
+
+	data := map[string]any{
+		"Lang":    lang,
+		"Code":    code,
+		"Title":   catalog.Message(lang, "page.title."+strconv.Itoa(code)),
+		"Message": message,
+		"Home":    catalog.Message(lang, "page.home"),
+	}
+	if s.formLogin != nil {
+		data["StyleHref"] = s.formLogin.styleHref
+		data["ExtraHref"] = s.formLogin.extraHref
+	}
+	setSecurityHeaders(w)
+	w.Header().Del("Content-Length")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	if err := errorPageTemplate.Execute(w, data); err != nil {
+		s.Error().Err(err).Msg("error rendering error page")
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestErrorPage(t *testing.T) {
+	t.Parallel()
+
+	server := &Server{
+		Logger: testutil.TestLogger(),
+		staticConfig: &types.ServerConfig{
+			System: types.SystemConfig{Language: "en", LanguageFromRequest: true},
+		},
+	}
+
+	// Plain text for API clients, the default language without an Accept-Language header
+	req := httptest.NewRequest(http.MethodGet, "/app", nil)
+	w := httptest.NewRecorder()
+	server.errorPage(w, req, http.StatusUnauthorized, "error.auth_failed")
+	testutil.AssertEqualsInt(t, "code", http.StatusUnauthorized, w.Code)
+	testutil.AssertEqualsString(t, "body", "Authentication failed\n", w.Body.String())
+	testutil.AssertEqualsString(t, "language", "en", w.Header().Get("Content-Language"))
+
+	req = httptest.NewRequest(http.MethodGet, "/app", nil)
+	req.Header.Set("Accept-Language", "ja, de;q=0.8")
+	w = httptest.NewRecorder()
+	server.errorPage(w, req, http.StatusForbidden, "error.forbidden_app", "bob", "/app")
+	testutil.AssertEqualsString(t, "body", "Verboten: bob hat keinen Zugriff auf /app\n", w.Body.String())
+
+	// HTML page for browsers, the values are escaped
+	req = httptest.NewRequest(http.MethodGet, "/app", nil)
+	req.Header.Set("Accept", "text/html")
+	req.Header.Set("Accept-Language", "fr-FR,fr;q=0.9")
+	w = httptest.NewRecorder()
+	server.errorPage(w, req, http.StatusForbidden, "error.forbidden_app", "<bob>", "/app")
+	testutil.AssertEqualsInt(t, "code", http.StatusForbidden, w.Code)
+	testutil.AssertEqualsString(t, "content type", "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	testutil.AssertStringContains(t, body, `<html lang="fr-fr">`)
+	testutil.AssertStringContains(t, body, "403 Accès refusé")
+	testutil.AssertStringContains(t, body, "Interdit : &lt;bob&gt; n&#39;a pas accès à /app")
+
+	// Accept-Language is ignored when language_from_request is disabled
+	server.staticConfig.System = types.SystemConfig{Language: "es"}
+	req = httptest.NewRequest(http.MethodGet, "/app", nil)
+	req.Header.Set("Accept-Language", "de")
+	w = httptest.NewRecorder()
+	server.errorPage(w, req, http.StatusNotFound, "error.no_app")
+	testutil.AssertEqualsString(t, "body", "no se encontró ninguna aplicación coincidente\n", w.Body.String())
+}
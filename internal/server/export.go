@@ -28,6 +28,8 @@ var appExportFields = map[string]bool{
 	"app_authn":         true,
 	"git_branch":        true,
 	"git_commit":        true,
+	"git_tag":           true,
+	"source_checksum":   true,
 	"git_auth_name":     true,
 	"spec":              true,
 	"param_values":      true,
@@ -38,6 +40,12 @@ var appExportFields = map[string]bool{
 	"bindings":          true,
 	"stage_at":          true,
 	"verify":            true,
+	// image apps are exported through the image spec and its image param, which has the pinned
+	// digest when pin_digest was used on create
+	"image":      true,
+	"pin_digest": true,
+	// params_only is a query option for create, not part of the app config
+	"params_only": true,
 }
 
 // bindingExportFields is the CreateBindingRequest equivalent of appExportFields.
@@ -156,26 +164,10 @@ func (s *Server) Export(ctx context.Context, appPathGlob string, opts types.Expo
 
 	builder := newExportBuilder(opts)
 
-	allBindings, err := s.db.ListBindings(ctx, tx, "")
+	allBindings, err := s.readableBindings(ctx, tx)
 	if err != nil {
 		return "", err
 	}
-	if s.rbacManager.APIEnforced(ctx) {
-		// Under RBAC enforcement the export includes only the bindings the user
-		// holds binding:read on, matching the app filtering below
-		readable := make([]*types.Binding, 0, len(allBindings))
-		for _, binding := range allBindings {
-			authorized, err := s.rbacManager.AuthorizeResourceAPI(ctx,
-				types.PermissionBindingRead, binding.Path, binding.CreatedBy)
-			if err != nil {
-				return "", err
-			}
-			if authorized {
-				readable = append(readable, binding)
-			}
-		}
-		allBindings = readable
-	}
 	bindingsByPath := make(map[string]*types.Binding, len(allBindings))
 	for _, binding := range allBindings {
 		bindingsByPath[binding.Path] = binding
@@ -219,6 +211,31 @@ func (s *Server) Export(ctx context.Context, appPathGlob string, opts types.Expo
 	return builder.header() + body, nil
 }
 
+// readableBindings returns all the bindings. Under RBAC enforcement, only the
+// bindings the user holds binding:read on are returned, matching the app
+// list filtering
+func (s *Server) readableBindings(ctx context.Context, tx types.Transaction) ([]*types.Binding, error) {
+	allBindings, err := s.db.ListBindings(ctx, tx, "")
+	if err != nil {
+		return nil, err
+	}
+	if !s.rbacManager.APIEnforced(ctx) {
+		return allBindings, nil
+	}
+	readable := make([]*types.Binding, 0, len(allBindings))
+	for _, binding := range allBindings {
+		authorized, err := s.rbacManager.AuthorizeResourceAPI(ctx,
+			types.PermissionBindingRead, binding.Path, binding.CreatedBy)
+		if err != nil {
+			return nil, err
+		}
+		if authorized {
+			readable = append(readable, binding)
+		}
+	}
+	return readable, nil
+}
+
 // exportBindings converts the exportable bindings to binding() definitions.
 // Auto bindings are not exported (they are recreated from the app bindings
 // list) and derived bindings are ordered after the binding they derive from
@@ -314,8 +331,10 @@ func (s *Server) exportApp(ctx context.Context, tx types.Transaction, appEntry *
 
 	if system.IsGit(appEntry.SourceUrl) {
 		req.GitBranch = metadata.VersionMetadata.GitBranch
+		req.GitTag = metadata.VersionMetadata.GitTag
 		if builder.opts.ExactCommit {
 			req.GitCommit = metadata.VersionMetadata.GitCommit
+			req.GitTag = "" // the exact commit pins the resolved tag
 		}
 		gitAuth := metadata.GitAuthName
 		if builder.opts.GitAuthRef == types.ExportRefExact && gitAuth != "" {
@@ -324,6 +343,8 @@ func (s *Server) exportApp(ctx context.Context, tx types.Transaction, appEntry *
 		} else {
 			builder.defaultGitAuth = true
 		}
+	} else if system.IsPackaged(appEntry.SourceUrl) {
+		req.SourceChecksum = metadata.VersionMetadata.SourceChecksum
 	} else {
 		builder.localSources[appEntry.SourceUrl] = true
 	}
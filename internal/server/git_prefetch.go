@@ -41,7 +41,15 @@ func (s *Server) prefetchApplyAppSources(applyConfig map[types.AppPathDomain]*ty
 			workers <- struct{}{}
 			defer func() { <-workers }()
 			branch := cmp.Or(request.GitBranch, "main")
-			if _, _, _, _, err := repoCache.CheckoutRepo(sourceURL, branch, request.GitCommit,
+			commit := request.GitCommit
+			if request.GitTag != "" && request.GitBranch == "" && commit == "" {
+				var err error
+				if _, commit, err = repoCache.ResolveTag(sourceURL, request.GitTag, request.GitAuthName); err != nil {
+					s.Debug().Err(err).Msgf("git prefetch: error resolving tag for apply source %s", sourceURL)
+					return
+				}
+			}
+			if _, _, _, _, err := repoCache.CheckoutRepo(sourceURL, branch, commit,
 				request.GitAuthName, false); err != nil {
 				s.Debug().Err(err).Msgf("git prefetch: error checking out apply source %s", sourceURL)
 			}
@@ -97,9 +105,15 @@ func (s *Server) prefetchAppSource(appEntry *types.AppEntry, branch, commit, git
 	if !forceReload && currentSha != "" && currentSha == commit {
 		return // already at the requested commit, the reload will skip
 	}
-	branch = cmp.Or(branch, appEntry.Metadata.VersionMetadata.GitBranch, "main")
 	gitAuth = cmp.Or(gitAuth, appEntry.Metadata.GitAuthName)
-	newSha, err := repoCache.GetSha(appEntry.SourceUrl, branch, gitAuth)
+	var newSha string
+	var err error
+	if followsGitTag(appEntry, branch, commit) {
+		_, newSha, err = repoCache.ResolveTag(appEntry.SourceUrl, appEntry.Metadata.VersionMetadata.GitTag, gitAuth)
+		commit = newSha // checked out by commit, same as loadSourceFromGit
+	} else {
+		newSha, err = repoCache.GetSha(appEntry.SourceUrl, cmp.Or(branch, appEntry.Metadata.VersionMetadata.GitBranch, "main"), gitAuth)
+	}
 	if err != nil {
 		s.Debug().Err(err).Msgf("git prefetch: error getting sha for %s", appEntry.SourceUrl)
 		return
@@ -107,6 +121,7 @@ func (s *Server) prefetchAppSource(appEntry *types.AppEntry, branch, commit, git
 	if !forceReload && currentSha != "" && newSha == currentSha && (commit == "" || commit == currentSha) {
 		return // already at the latest commit, the reload will skip without a checkout
 	}
+	branch = cmp.Or(branch, appEntry.Metadata.VersionMetadata.GitBranch, "main")
 	if _, _, _, _, err := repoCache.CheckoutRepo(appEntry.SourceUrl, branch, commit, gitAuth, appEntry.IsDev); err != nil {
 		s.Debug().Err(err).Msgf("git prefetch: error checking out %s", appEntry.SourceUrl)
 	}
@@ -0,0 +1,232 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+const (
+	gitRepoCacheRepoDir  = "repo.git"
+	gitRepoCacheLockFile = "lock"
+	gitRepoCacheUsedFile = "last_used"
+	// A lock file older than this is left over from a crashed process
+	gitRepoCacheStaleLock = 30 * time.Minute
+	gitRepoCacheLockWait  = 5 * time.Minute
+	gitRepoCacheLockPoll  = 200 * time.Millisecond
+)
+
+// gitRepoCache is a persistent on-disk cache of git repos, kept across sync runs and server
+// restarts. There is one bare repo for each repo url, branch and git auth. A checkout fetches only
+// the new commits into the cached repo, then writes the files for the commit to the checkout dir.
+// The least recently used repos are deleted when the total size is over the limit.
+type gitRepoCache struct {
+	rootDir  string
+	maxBytes atomic.Int64
+	mu       sync.Mutex
+	locks    map[string]*sync.Mutex // in-process locks, by entry dir
+}
+
+func newGitRepoCache(rootDir string, maxBytes int64) (*gitRepoCache, error) {
+	if err := os.MkdirAll(rootDir, 0700); err != nil {
+		return nil, fmt.Errorf("error creating git repo cache dir %s: %w", rootDir, err)
+	}
+	cache := &gitRepoCache{rootDir: rootDir, locks: make(map[string]*sync.Mutex)}
+	cache.maxBytes.Store(maxBytes)
+	return cache, nil
+}
+
+// entryDir returns the cache dir for the repo, branch and git auth
+func (c *gitRepoCache) entryDir(repoURL, branch, gitAuth string) string {
+	hash := sha256.Sum256([]byte(repoURL + "\x00" + branch + "\x00" + gitAuth))
+	return filepath.Join(c.rootDir, hex.EncodeToString(hash[:16]))
+}
+
+// lock locks the entry against concurrent use, by other syncs in this process and by other server
+// processes sharing the cache dir. If wait is false, false is returned if the entry is in use
+func (c *gitRepoCache) lock(dir string, wait bool) (func(), bool, error) {
+	c.mu.Lock()
+	mu, ok := c.locks[dir]
+	if !ok {
+		mu = &sync.Mutex{}
+		c.locks[dir] = mu
+	}
+	c.mu.Unlock()
+	if !wait {
+		if !mu.TryLock() {
+			return nil, false, nil
+		}
+	} else {
+		mu.Lock()
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		mu.Unlock()
+		return nil, false, err
+	}
+	lockPath := filepath.Join(dir, gitRepoCacheLockFile)
+	deadline := time.Now().Add(gitRepoCacheLockWait)
+	for {
+		lockFile, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			fmt.Fprintf(lockFile, "%d\n", os.Getpid()) //nolint:errcheck
+			lockFile.Close()                           //nolint:errcheck
+			return func() {
+				os.Remove(lockPath) //nolint:errcheck
+				mu.Unlock()
+			}, true, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			mu.Unlock()
+			return nil, false, err
+		}
+		if info, statErr := os.Stat(lockPath); statErr == nil && time.Since(info.ModTime()) > gitRepoCacheStaleLock {
+			os.Remove(lockPath) //nolint:errcheck
+			continue
+		}
+		if !wait {
+			mu.Unlock()
+			return nil, false, nil
+		}
+		if time.Now().After(deadline) {
+			mu.Unlock()
+			return nil, false, fmt.Errorf("timed out waiting for git repo cache lock %s", lockPath)
+		}
+		time.Sleep(gitRepoCacheLockPoll)
+	}
+}
+
+// checkout fetches the branch into the cached repo and writes the files under folder for the
+// commit to targetDir, all the files if folder is empty. The branch head is used if commit is
+// empty. Returns the checkout with the commit message and hash
+func (c *gitRepoCache) checkout(repoURL, branch, commit, gitAuth string, auth transport.AuthMethod,
+	targetDir, folder string) (CacheDir, error) {
+	dir := c.entryDir(repoURL, branch, gitAuth)
+	unlock, _, err := c.lock(dir, true)
+	if err != nil {
+		return CacheDir{}, err
+	}
+	cacheDir, err := c.fetchCommit(dir, repoURL, branch, commit, auth, targetDir, folder)
+	if err == nil {
+		os.WriteFile(filepath.Join(dir, gitRepoCacheUsedFile), nil, 0600) //nolint:errcheck
+	}
+	unlock()
+	if err != nil {
+		return CacheDir{}, err
+	}
+	c.evict(dir)
+	return cacheDir, nil
+}
+
+func (c *gitRepoCache) fetchCommit(dir, repoURL, branch, commit string, auth transport.AuthMethod,
+	targetDir, folder string) (CacheDir, error) {
+	repoDir := filepath.Join(dir, gitRepoCacheRepoDir)
+	repo, err := git.PlainOpen(repoDir)
+	if err != nil && !errors.Is(err, git.ErrRepositoryNotExists) {
+		// The cached repo is corrupted, like from an interrupted first fetch. Start over
+		os.RemoveAll(repoDir) //nolint:errcheck
+		err = git.ErrRepositoryNotExists
+	}
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		if repo, err = git.PlainInit(repoDir, true); err == nil {
+			_, err = repo.CreateRemote(&config.RemoteConfig{Name: git.DefaultRemoteName, URLs: []string{repoURL}})
+		}
+	}
+	if err != nil {
+		return CacheDir{}, err
+	}
+
+	remoteRef := plumbing.NewRemoteReferenceName(git.DefaultRemoteName, branch)
+	if commit == "" || !gitHasCommit(repo, commit) {
+		// Only the objects missing from the cached repo are downloaded
+		refSpec := config.RefSpec(fmt.Sprintf("+%s:%s", plumbing.NewBranchReferenceName(branch), remoteRef))
+		err := repo.Fetch(&git.FetchOptions{RemoteName: git.DefaultRemoteName, RefSpecs: []config.RefSpec{refSpec},
+			Auth: auth, Tags: git.NoTags})
+		if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+			return CacheDir{}, fmt.Errorf("error fetching branch %s: %w", branch, err)
+		}
+	}
+	if commit == "" {
+		ref, err := repo.Reference(remoteRef, true)
+		if err != nil {
+			return CacheDir{}, fmt.Errorf("error reading branch %s: %w", branch, err)
+		}
+		commit = ref.Hash().String()
+	}
+	return materializeGitCommit(repoDir, targetDir, commit, folder)
+}
+
+func gitHasCommit(repo *git.Repository, commit string) bool {
+	_, err := repo.CommitObject(plumbing.NewHash(commit))
+	return err == nil
+}
+
+// evict deletes the least recently used repos until the cache size is within the limit. Repos
+// in use and the keep repo are skipped
+func (c *gitRepoCache) evict(keep string) {
+	dirEntries, err := os.ReadDir(c.rootDir)
+	if err != nil {
+		return
+	}
+	type cacheEntry struct {
+		dir      string
+		size     int64
+		lastUsed time.Time
+	}
+	entries := make([]cacheEntry, 0, len(dirEntries))
+	var total int64
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() {
+			continue
+		}
+		entry := cacheEntry{dir: filepath.Join(c.rootDir, dirEntry.Name())}
+		if entry.dir == keep {
+			total += dirDiskUsage(entry.dir)
+			continue
+		}
+		entry.size = dirDiskUsage(entry.dir)
+		if info, err := os.Stat(filepath.Join(entry.dir, gitRepoCacheUsedFile)); err == nil {
+			entry.lastUsed = info.ModTime()
+		}
+		total += entry.size
+		entries = append(entries, entry)
+	}
+
+	maxBytes := c.maxBytes.Load()
+	if total <= maxBytes {
+		return
+	}
+	slices.SortFunc(entries, func(a, b cacheEntry) int {
+		return a.lastUsed.Compare(b.lastUsed)
+	})
+	for _, entry := range entries {
+		if total <= maxBytes {
+			return
+		}
+		unlock, locked, err := c.lock(entry.dir, false)
+		if err != nil || !locked {
+			continue
+		}
+		os.RemoveAll(filepath.Join(entry.dir, gitRepoCacheRepoDir)) //nolint:errcheck
+		os.Remove(filepath.Join(entry.dir, gitRepoCacheUsedFile))   //nolint:errcheck
+		unlock()
+		// Fails if another process locked the entry after the unlock
+		os.Remove(entry.dir) //nolint:errcheck
+		total -= entry.size
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func commitTestFile(t *testing.T, repo *git.Repository, dir, name, contents string) string {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktree.Add(name); err != nil {
+		t.Fatal(err)
+	}
+	hash, err := worktree.Commit("update "+name, &git.CommitOptions{
+		Author: &object.Signature{Name: "OpenRun Test", Email: "test@openrun.dev", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return hash.String()
+}
+
+func TestGitRepoCacheFetchesNewCommits(t *testing.T) {
+	t.Parallel()
+	sourceDir := t.TempDir()
+	repo, err := git.PlainInitWithOptions(sourceDir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := commitTestFile(t, repo, sourceDir, "app.star", "app = 1\n")
+
+	cache, err := newGitRepoCache(t.TempDir(), 1<<30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkout := func(commit, want string) string {
+		targetDir := t.TempDir()
+		cacheDir, err := cache.checkout(sourceDir, "main", commit, "", nil, targetDir, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents, err := os.ReadFile(filepath.Join(targetDir, "app.star"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(contents) != want {
+			t.Fatalf("checkout contents = %q, want %q", contents, want)
+		}
+		return cacheDir.hash
+	}
+
+	if hash := checkout("", "app = 1\n"); hash != first {
+		t.Fatalf("checkout hash = %s, want %s", hash, first)
+	}
+	second := commitTestFile(t, repo, sourceDir, "app.star", "app = 2\n")
+	if hash := checkout("", "app = 2\n"); hash != second {
+		t.Fatalf("checkout hash = %s, want %s", hash, second)
+	}
+	// Older commits are available from the cached repo
+	if hash := checkout(first, "app = 1\n"); hash != first {
+		t.Fatalf("checkout hash = %s, want %s", hash, first)
+	}
+	if _, err := os.Stat(filepath.Join(cache.entryDir(sourceDir, "main", ""), gitRepoCacheLockFile)); !os.IsNotExist(err) {
+		t.Fatalf("lock file was not removed, stat err = %v", err)
+	}
+}
+
+func TestGitRepoCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	t.Parallel()
+	sourceDir := t.TempDir()
+	repo, err := git.PlainInitWithOptions(sourceDir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	commitTestFile(t, repo, sourceDir, "app.star", "app = 1\n")
+
+	cache, err := newGitRepoCache(t.TempDir(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := cache.checkout(sourceDir, "main", "", "", nil, t.TempDir(), ""); err != nil {
+		t.Fatal(err)
+	}
+	firstDir := cache.entryDir(sourceDir, "main", "")
+	if _, err := os.Stat(firstDir); err != nil {
+		t.Fatalf("the repo just used was evicted: %v", err)
+	}
+
+	// An entry in use is not evicted
+	unlock, locked, err := cache.lock(firstDir, false)
+	if err != nil || !locked {
+		t.Fatalf("lock failed: %t %v", locked, err)
+	}
+	if _, err := cache.checkout(sourceDir, "main", "", "other_auth", nil, t.TempDir(), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(firstDir); err != nil {
+		t.Fatalf("locked repo was evicted: %v", err)
+	}
+	unlock()
+
+	if _, err := cache.checkout(sourceDir, "main", "", "other_auth", nil, t.TempDir(), ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(firstDir); !os.IsNotExist(err) {
+		t.Fatalf("least recently used repo was not evicted, stat err = %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"cmp"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/storage/memory"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const gitPeeledSuffix = "^{}"
+
+type gitTagRef struct {
+	tag string
+	sha string
+}
+
+// followsGitTag returns true if the app source is resolved from the tag of the app. A branch or
+// commit requested explicitly, like by a reload with a commit, is used instead of the tag
+func followsGitTag(appEntry *types.AppEntry, branch, commit string) bool {
+	return appEntry.Metadata.VersionMetadata.GitTag != "" && branch == "" && commit == ""
+}
+
+func validateGitTagRequest(appRequest *types.CreateAppRequest) error {
+	if appRequest.GitTag != "" && (appRequest.GitBranch != "" || appRequest.GitCommit != "") {
+		return types.CreateRequestError("git tag cannot be used with git branch or git commit", http.StatusBadRequest)
+	}
+	return nil
+}
+
+// ResolveTag resolves the tag or semver constraint to the latest matching tag in the repo,
+// returns the tag name and its commit sha
+func (r *RepoCache) ResolveTag(sourceUrl, tagSpec, gitAuth string) (string, string, error) {
+	gitAuth = cmp.Or(gitAuth, r.server.Config().Security.DefaultGitAuth)
+	authEntry, err := r.server.loadGitKey(gitAuth)
+	if err != nil {
+		return "", "", err
+	}
+	repo, _, err := parseGitUrl(sourceUrl, authEntry.usingSSH)
+	if err != nil {
+		return "", "", err
+	}
+
+	tagKey := Repo{url: repo, branch: tagSpec, auth: gitAuth}
+	r.mu.Lock()
+	ref, ok := r.tagCache[tagKey]
+	r.mu.Unlock()
+	if ok {
+		return ref.tag, ref.sha, nil
+	}
+
+	var auth transport.AuthMethod
+	if gitAuth != "" {
+		auth, err = r.createAuthMethod(gitAuth)
+		if err != nil {
+			return "", "", err
+		}
+	}
+	tags, err := listGitTags(repo, auth)
+	if err != nil {
+		return "", "", err
+	}
+	tag, err := selectGitTag(tags, tagSpec)
+	if err != nil {
+		return "", "", fmt.Errorf("error resolving tag for %s: %w", sourceUrl, err)
+	}
+
+	r.server.Debug().Str("repo", repo).Str("tag_spec", tagSpec).Str("tag", tag).Str("sha", tags[tag]).Msg("Resolved git tag")
+	r.mu.Lock()
+	r.tagCache[tagKey] = gitTagRef{tag: tag, sha: tags[tag]}
+	r.mu.Unlock()
+	return tag, tags[tag], nil
+}
+
+// listGitTags returns the commit sha for each tag in the remote repo. Annotated tags are peeled
+// to the commit they point to
+func listGitTags(repoURL string, auth transport.AuthMethod) (map[string]string, error) {
+	remote := git.NewRemote(memory.NewStorage(), &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{repoURL},
+	})
+	refs, err := remote.List(&git.ListOptions{
+		Auth:          auth,
+		PeelingOption: git.AppendPeeled,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not list remote refs: %w", err)
+	}
+
+	tags := map[string]string{}
+	peeled := map[string]string{}
+	for _, ref := range refs {
+		if !ref.Name().IsTag() {
+			continue
+		}
+		name := ref.Name().Short()
+		if strings.HasSuffix(name, gitPeeledSuffix) {
+			peeled[strings.TrimSuffix(name, gitPeeledSuffix)] = ref.Hash().String()
+		} else if ref.Type() == plumbing.HashReference {
+			tags[name] = ref.Hash().String()
+		}
+	}
+	for name, sha := range peeled {
+		tags[name] = sha
+	}
+	return tags, nil
+}
+
+// selectGitTag returns the tag matching the spec. A spec which is a tag name matches that tag.
+// Otherwise the spec is a semver constraint, like v1.x or ^1.2, and the highest matching
+// version is returned. Pre-release versions match only if the constraint has a pre-release
+func selectGitTag(tags map[string]string, tagSpec string) (string, error) {
+	if _, ok := tags[tagSpec]; ok {
+		return tagSpec, nil
+	}
+	constraint, err := semver.NewConstraint(tagSpec)
+	if err != nil {
+		return "", fmt.Errorf("tag %q not found and is not a valid semver constraint: %w", tagSpec, err)
+	}
+
+	bestTag := ""
+	var bestVersion *semver.Version
+	for tag := range tags {
+		version, err := semver.NewVersion(tag)
+		if err != nil {
+			continue // not a version tag
+		}
+		if !constraint.Check(version) {
+			continue
+		}
+		// For the same version, like 1.2.0 and v1.2.0, the tag name order is used so the result is stable
+		if bestVersion == nil || version.GreaterThan(bestVersion) || (version.Equal(bestVersion) && tag > bestTag) {
+			bestTag = tag
+			bestVersion = version
+		}
+	}
+	if bestTag == "" {
+		return "", fmt.Errorf("no tag matches %q", tagSpec)
+	}
+	return bestTag, nil
+}
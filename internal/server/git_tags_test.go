@@ -0,0 +1,67 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func TestSelectGitTag(t *testing.T) {
+	tags := map[string]string{"v1.0.0": "a", "v1.2.0": "b", "v1.10.1": "c", "v2.0.0": "d",
+		"v2.1.0-rc1": "e", "release-old": "f", "1.10.1": "g"}
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr string
+	}{
+		{spec: "v1.x", want: "v1.10.1"},
+		{spec: "1.x", want: "v1.10.1"},
+		{spec: "~1.2", want: "v1.2.0"},
+		{spec: ">= 2.0", want: "v2.0.0"},
+		{spec: ">= 2.1.0-0", want: "v2.1.0-rc1"},
+		{spec: "*", want: "v2.0.0"},
+		{spec: "release-old", want: "release-old"},
+		{spec: "v1.0.0", want: "v1.0.0"},
+		{spec: "v3.x", wantErr: "no tag matches"},
+		{spec: "latest-release", wantErr: "not a valid semver constraint"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.spec, func(t *testing.T) {
+			tag, err := selectGitTag(tags, tc.spec)
+			if tc.wantErr != "" {
+				testutil.AssertErrorContains(t, err, tc.wantErr)
+				return
+			}
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqualsString(t, "tag", tc.want, tag)
+		})
+	}
+}
+
+func TestListGitTags(t *testing.T) {
+	sourceDir := t.TempDir()
+	repo, err := git.PlainInitWithOptions(sourceDir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")}})
+	testutil.AssertNoError(t, err)
+	first := commitTestFile(t, repo, sourceDir, "app.star", "app = 1\n")
+	second := commitTestFile(t, repo, sourceDir, "app.star", "app = 2\n")
+
+	_, err = repo.CreateTag("v1.0.0", plumbing.NewHash(first), nil)
+	testutil.AssertNoError(t, err)
+	// Annotated tags resolve to the commit, not the tag object
+	_, err = repo.CreateTag("v1.1.0", plumbing.NewHash(second), &git.CreateTagOptions{
+		Message: "release", Tagger: &object.Signature{Name: "OpenRun Test", Email: "test@openrun.dev"}})
+	testutil.AssertNoError(t, err)
+
+	tags, err := listGitTags(sourceDir, nil)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "tags", 2, len(tags))
+	testutil.AssertEqualsString(t, "v1.0.0", first, tags["v1.0.0"])
+	testutil.AssertEqualsString(t, "v1.1.0", second, tags["v1.1.0"])
+}
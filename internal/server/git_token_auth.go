@@ -0,0 +1,248 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"cmp"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	gitTokenTimeout         = 30 * time.Second
+	gitTokenRefreshMargin   = 5 * time.Minute  // tokens are refreshed this long before they expire
+	gitTokenDefaultLifetime = 10 * time.Minute // used if the token exchange does not return the expiry
+	githubAppJWTLifetime    = 9 * time.Minute  // GitHub allows up to 10 minutes
+	githubDefaultApiUrl     = "https://api.github.com"
+	githubAppTokenUser      = "x-access-token"
+	oidcTokenUser           = "oauth2" // GitLab accepts any user name with an OAuth token, oauth2 is the convention
+)
+
+// gitAccessToken is a cached access token, the entry is the git auth config the token was created for
+type gitAccessToken struct {
+	entry   types.GitAuthEntry
+	token   string
+	expires time.Time
+}
+
+// gitAuthToken returns the access token for a github_app or oidc git auth entry. The token is cached
+// and refreshed before it expires, or when the entry is changed in the config. The secrets in the
+// entry are expected to be resolved
+func (s *Server) gitAuthToken(gitAuth string, authEntry types.GitAuthEntry) (string, error) {
+	s.gitTokensMu.Lock()
+	defer s.gitTokensMu.Unlock()
+	if cached, ok := s.gitTokens[gitAuth]; ok && cached.entry == authEntry && time.Until(cached.expires) > gitTokenRefreshMargin {
+		return cached.token, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), gitTokenTimeout)
+	defer cancel()
+	client := &http.Client{Timeout: gitTokenTimeout}
+	var token string
+	var expires time.Time
+	var err error
+	switch authEntry.Type {
+	case types.GitAuthTypeGitHubApp:
+		token, expires, err = fetchGitHubAppToken(ctx, client, authEntry, time.Now())
+	case types.GitAuthTypeOIDC:
+		token, expires, err = exchangeOIDCToken(ctx, client, authEntry, time.Now())
+	default:
+		return "", fmt.Errorf("git auth %s has invalid type %q, expected %s or %s", gitAuth, authEntry.Type,
+			types.GitAuthTypeGitHubApp, types.GitAuthTypeOIDC)
+	}
+	if err != nil {
+		return "", fmt.Errorf("error getting access token for git auth %s: %w", gitAuth, err)
+	}
+
+	if s.gitTokens == nil {
+		s.gitTokens = map[string]gitAccessToken{}
+	}
+	s.gitTokens[gitAuth] = gitAccessToken{entry: authEntry, token: token, expires: expires}
+	return token, nil
+}
+
+// gitTokenUser returns the user name used with the access token for git over https
+func gitTokenUser(authEntry types.GitAuthEntry) string {
+	if authEntry.Type == types.GitAuthTypeGitHubApp {
+		return cmp.Or(authEntry.UserID, githubAppTokenUser)
+	}
+	return cmp.Or(authEntry.UserID, oidcTokenUser)
+}
+
+// fetchGitHubAppToken creates an installation access token for the GitHub App, authenticating
+// with a JWT signed by the App private key
+func fetchGitHubAppToken(ctx context.Context, client *http.Client, authEntry types.GitAuthEntry, now time.Time) (string, time.Time, error) {
+	if authEntry.AppId == "" || authEntry.InstallationId == "" {
+		return "", time.Time{}, fmt.Errorf("app_id and installation_id are required for %s auth", types.GitAuthTypeGitHubApp)
+	}
+	keyPem := []byte(authEntry.PrivateKey)
+	if len(keyPem) == 0 {
+		if authEntry.KeyFilePath == "" {
+			return "", time.Time{}, fmt.Errorf("private_key or key_file_path is required for %s auth", types.GitAuthTypeGitHubApp)
+		}
+		var err error
+		if keyPem, err = os.ReadFile(authEntry.KeyFilePath); err != nil {
+			return "", time.Time{}, fmt.Errorf("error reading GitHub App key %s: %w", authEntry.KeyFilePath, err)
+		}
+	}
+	key, err := parseRSAPrivateKey(keyPem)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	jwt, err := githubAppJWT(authEntry.AppId, key, now)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	apiUrl := strings.TrimSuffix(cmp.Or(authEntry.ApiUrl, githubDefaultApiUrl), "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		fmt.Sprintf("%s/app/installations/%s/access_tokens", apiUrl, url.PathEscape(authEntry.InstallationId)), nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+
+	var response struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := doTokenRequest(client, req, &response); err != nil {
+		return "", time.Time{}, err
+	}
+	if response.Token == "" {
+		return "", time.Time{}, fmt.Errorf("no token in GitHub App installation token response")
+	}
+	return response.Token, response.ExpiresAt, nil
+}
+
+// githubAppJWT returns the RS256 signed JWT for authenticating as the GitHub App. The issue time
+// is set in the past to allow for clock drift
+func githubAppJWT(appId string, key *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+	claims, err := json.Marshal(map[string]any{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(githubAppJWTLifetime).Unix(),
+		"iss": appId,
+	})
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("error signing GitHub App JWT: %w", err)
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parseRSAPrivateKey parses a PEM RSA key, in PKCS1 format as downloaded from GitHub or PKCS8
+func parseRSAPrivateKey(keyPem []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(keyPem)
+	if block == nil {
+		return nil, fmt.Errorf("GitHub App private key is not in PEM format")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing GitHub App private key: %w", err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("GitHub App private key is not an RSA key")
+	}
+	return key, nil
+}
+
+// exchangeOIDCToken exchanges the OIDC id token, like a Kubernetes projected service account
+// token, for a git access token using OAuth 2.0 token exchange (RFC 8693)
+func exchangeOIDCToken(ctx context.Context, client *http.Client, authEntry types.GitAuthEntry, now time.Time) (string, time.Time, error) {
+	if authEntry.TokenUrl == "" || authEntry.IdTokenFile == "" {
+		return "", time.Time{}, fmt.Errorf("token_url and id_token_file are required for %s auth", types.GitAuthTypeOIDC)
+	}
+	// The id token file is read every time, the platform rotates it
+	idToken, err := os.ReadFile(authEntry.IdTokenFile)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("error reading id token %s: %w", authEntry.IdTokenFile, err)
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:token-exchange")
+	form.Set("subject_token", strings.TrimSpace(string(idToken)))
+	form.Set("subject_token_type", "urn:ietf:params:oauth:token-type:id_token")
+	form.Set("requested_token_type", "urn:ietf:params:oauth:token-type:access_token")
+	if authEntry.Audience != "" {
+		form.Set("audience", authEntry.Audience)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, authEntry.TokenUrl, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var response struct {
+		AccessToken string          `json:"access_token"`
+		ExpiresIn   json.RawMessage `json:"expires_in"`
+	}
+	if err := doTokenRequest(client, req, &response); err != nil {
+		return "", time.Time{}, err
+	}
+	if response.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("no access_token in token exchange response")
+	}
+	lifetime := gitTokenDefaultLifetime
+	// expires_in is a number, some servers return it as a string
+	if seconds, err := strconv.Atoi(strings.Trim(string(response.ExpiresIn), `"`)); err == nil && seconds > 0 {
+		lifetime = time.Duration(seconds) * time.Second
+	}
+	return response.AccessToken, now.Add(lifetime), nil
+}
+
+func doTokenRequest(client *http.Client, req *http.Request, response any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("error calling %s: %w", req.URL.Redacted(), err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		return fmt.Errorf("error reading response from %s: %w", req.URL.Redacted(), err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message := string(body)
+		if len(message) > 200 {
+			message = message[:200] + "..."
+		}
+		return fmt.Errorf("%s returned status %d: %s", req.URL.Redacted(), resp.StatusCode, message)
+	}
+	if err := json.Unmarshal(body, response); err != nil {
+		return fmt.Errorf("error parsing response from %s: %w", req.URL.Redacted(), err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestGitHubAppToken(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	testutil.AssertNoError(t, err)
+	keyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	var calls atomic.Int32
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.Method != http.MethodPost || r.URL.Path != "/app/installations/42/access_tokens" {
+			http.Error(w, "unexpected request "+r.URL.Path, http.StatusNotFound)
+			return
+		}
+		jwt, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		parts := strings.Split(jwt, ".")
+		if len(parts) != 3 {
+			http.Error(w, "invalid jwt", http.StatusUnauthorized)
+			return
+		}
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		claimsJson, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		var claims map[string]any
+		_ = json.Unmarshal(claimsJson, &claims)
+		if claims["iss"] != "1234" {
+			http.Error(w, "invalid issuer", http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"token": "ghs_token%d", "expires_at": %q}`, calls.Load(), expires.Format(time.RFC3339))
+	}))
+	defer srv.Close()
+
+	entry := types.GitAuthEntry{Type: types.GitAuthTypeGitHubApp, AppId: "1234", InstallationId: "42",
+		PrivateKey: string(keyPem), ApiUrl: srv.URL}
+	server := &Server{}
+	token, err := server.gitAuthToken("app", entry)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "token", "ghs_token1", token)
+	testutil.AssertEqualsString(t, "user", "x-access-token", gitTokenUser(entry))
+
+	// The cached token is reused until it is close to expiry
+	token, err = server.gitAuthToken("app", entry)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "cached token", "ghs_token1", token)
+	server.gitTokens["app"] = gitAccessToken{entry: entry, token: "ghs_token1", expires: time.Now().Add(time.Minute)}
+	token, err = server.gitAuthToken("app", entry)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "refreshed token", "ghs_token2", token)
+
+	entry.AppId = "999"
+	_, err = server.gitAuthToken("app", entry)
+	testutil.AssertErrorContains(t, err, "invalid issuer")
+}
+
+func TestOIDCTokenExchange(t *testing.T) {
+	idTokenFile := filepath.Join(t.TempDir(), "token")
+	testutil.AssertNoError(t, os.WriteFile(idTokenFile, []byte("id-token-1\n"), 0600))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if r.Form.Get("grant_type") != "urn:ietf:params:oauth:grant-type:token-exchange" ||
+			r.Form.Get("subject_token") != "id-token-1" || r.Form.Get("audience") != "gitlab" {
+			http.Error(w, `{"error": "invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"access_token": "glpat-exchanged", "token_type": "Bearer", "expires_in": "3600"}`)
+	}))
+	defer srv.Close()
+
+	entry := types.GitAuthEntry{Type: types.GitAuthTypeOIDC, TokenUrl: srv.URL, IdTokenFile: idTokenFile, Audience: "gitlab"}
+	now := time.Now()
+	token, expires, err := exchangeOIDCToken(t.Context(), http.DefaultClient, entry, now)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "token", "glpat-exchanged", token)
+	testutil.AssertEqualsBool(t, "expires", true, expires.Equal(now.Add(time.Hour)))
+	testutil.AssertEqualsString(t, "user", "oauth2", gitTokenUser(entry))
+
+	entry.Audience = "other"
+	_, _, err = exchangeOIDCToken(t.Context(), http.DefaultClient, entry, now)
+	testutil.AssertErrorContains(t, err, "returned status 400")
+
+	_, err = (&Server{}).gitAuthToken("bad", types.GitAuthEntry{Type: "unknown"})
+	testutil.AssertErrorContains(t, err, `invalid type "unknown"`)
+}
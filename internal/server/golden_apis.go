@@ -0,0 +1,183 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// goldenDiffContext is the number of unchanged lines shown around each change in a golden diff
+const goldenDiffContext = 3
+
+// RunGoldenTests renders the HTML routes of an app with the fixture data and compares the output
+// with the golden files. For a prod app, the stage app is rendered. The handlers are not called,
+// so the test checks only the templates
+func (s *Server) RunGoldenTests(ctx context.Context, appPath string, req *types.GoldenRequest) (*types.GoldenResponse, error) {
+	if len(req.Fixtures) == 0 {
+		return nil, fmt.Errorf("no fixtures specified")
+	}
+	ignore := make([]*regexp.Regexp, 0, len(req.Ignore))
+	for _, pattern := range req.Ignore {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ignore pattern %q: %w", pattern, err)
+		}
+		ignore = append(ignore, re)
+	}
+
+	appEntry, testApp, err := s.getTestApp(ctx, appPath)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &types.GoldenResponse{AppPath: appEntry.AppPathDomain().String(), Results: make([]types.GoldenResult, 0, len(req.Fixtures))}
+	covered := map[string]bool{}
+	names := map[string]bool{}
+	for i := range req.Fixtures {
+		fixture := &req.Fixtures[i]
+		route := strings.ToUpper(cmp.Or(fixture.Method, http.MethodGet)) + " " + fixture.Path
+		covered[route] = true
+		result := types.GoldenResult{Name: fixture.Name, Route: route}
+		switch {
+		case fixture.Name == "" || strings.ContainsAny(fixture.Name, `/\`):
+			result.Status = types.GoldenError
+			result.Error = fmt.Sprintf("invalid fixture name %q", fixture.Name)
+		case names[fixture.Name]:
+			result.Status = types.GoldenError
+			result.Error = fmt.Sprintf("duplicate fixture name %q", fixture.Name)
+		default:
+			compareGolden(&result, fixture, req, ignore, testApp.RenderRoute)
+		}
+		names[fixture.Name] = true
+
+		if result.Status == types.GoldenMatch || (req.Update && result.Status != types.GoldenError) {
+			ret.Passed++
+		} else {
+			ret.Failed++
+		}
+		ret.Results = append(ret.Results, result)
+	}
+
+	for _, route := range testApp.HTMLRoutes() {
+		if !covered[route] {
+			ret.Uncovered = append(ret.Uncovered, route)
+		}
+	}
+	slices.Sort(ret.Uncovered)
+
+	s.Info().Str("app", ret.AppPath).Msgf("Ran golden tests, %d passed, %d failed", ret.Passed, ret.Failed)
+	return ret, nil
+}
+
+// compareGolden renders one fixture and sets the result status. A missing golden file is
+// reported as new, the test fails unless the golden files are being updated
+func compareGolden(result *types.GoldenResult, fixture *types.GoldenFixture, req *types.GoldenRequest,
+	ignore []*regexp.Regexp, render func(*types.GoldenFixture) (string, error)) {
+	output, err := render(fixture)
+	if err != nil {
+		result.Status = types.GoldenError
+		result.Error = err.Error()
+		return
+	}
+
+	actual := normalizeGolden(output, ignore)
+	expected, ok := req.Golden[fixture.Name]
+	switch {
+	case !ok:
+		result.Status = types.GoldenNew
+		result.Actual = actual
+	case normalizeGolden(expected, ignore) == actual:
+		result.Status = types.GoldenMatch
+	default:
+		result.Status = types.GoldenMismatch
+		result.Actual = actual
+		result.Diff = lineDiff(normalizeGolden(expected, ignore), actual, goldenDiffContext)
+	}
+}
+
+// goldenTagBreak matches the boundary between adjacent tags
+var goldenTagBreak = regexp.MustCompile(`>\s*<`)
+
+// normalizeGolden normalizes the rendered HTML like a snapshot, with each tag boundary on a
+// new line so that the diff points at the element which changed
+func normalizeGolden(text string, ignore []*regexp.Regexp) string {
+	return normalizeSnapshot(goldenTagBreak.ReplaceAllString(text, ">\n<"), ignore)
+}
+
+// lineDiff returns a unified style diff of the two texts, with "-" for the expected lines and
+// "+" for the actual lines. Changes are shown with the given number of lines of context, each
+// hunk starts with a "@@ line N @@" header giving the line number in the expected text
+func lineDiff(expected, actual string, context int) string {
+	a := strings.Split(strings.TrimSuffix(expected, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(actual, "\n"), "\n")
+
+	// Longest common subsequence table, lcs[i][j] is the length for a[i:] and b[j:]
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	type diffLine struct {
+		op   byte
+		text string
+		line int // line number in the expected text
+	}
+	lines := make([]diffLine, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i], i + 1})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i], i + 1})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j], i + 1})
+			j++
+		}
+	}
+
+	var buf strings.Builder
+	lastPrinted := -1
+	for k := 0; k < len(lines); k++ {
+		if lines[k].op == ' ' {
+			continue
+		}
+		start := max(k-context, lastPrinted+1)
+		end := k
+		// Extend the hunk while the next change is within the context window
+		for n := k + 1; n < len(lines) && n <= end+2*context; n++ {
+			if lines[n].op != ' ' {
+				end = n
+			}
+		}
+		end = min(end+context, len(lines)-1)
+		fmt.Fprintf(&buf, "@@ line %d @@\n", lines[start].line)
+		for n := start; n <= end; n++ {
+			fmt.Fprintf(&buf, "%c %s\n", lines[n].op, lines[n].text)
+		}
+		lastPrinted = end
+		k = end
+	}
+	return buf.String()
+}
@@ -0,0 +1,57 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestNormalizeGolden(t *testing.T) {
+	testutil.AssertEqualsString(t, "tags", "<ul>\n<li>a</li>\n<li>b</li>\n</ul>\n", normalizeGolden("<ul><li>a</li>  <li>b</li></ul>", nil))
+	ignore := []*regexp.Regexp{regexp.MustCompile(`nonce="[^"]*"`)}
+	testutil.AssertEqualsString(t, "ignored", "<script [ignored]>\n</script>\n", normalizeGolden(`<script nonce="abc"></script>`, ignore))
+}
+
+func TestLineDiff(t *testing.T) {
+	testutil.AssertEqualsString(t, "same", "", lineDiff("a\nb\n", "a\nb\n", 3))
+	testutil.AssertEqualsString(t, "changed", "@@ line 1 @@\n  a\n- b\n+ c\n  d\n", lineDiff("a\nb\nd\n", "a\nc\nd\n", 3))
+	testutil.AssertEqualsString(t, "added", "@@ line 2 @@\n  b\n+ x\n  c\n", lineDiff("a\nb\nc\nd\n", "a\nb\nx\nc\nd\n", 1))
+
+	// Changes far apart are shown in separate hunks
+	expected := "1\n2\n3\n4\n5\n6\n7\n8\n9\n"
+	actual := "x\n2\n3\n4\n5\n6\n7\n8\ny\n"
+	testutil.AssertEqualsString(t, "hunks", "@@ line 1 @@\n- 1\n+ x\n  2\n@@ line 8 @@\n  8\n- 9\n+ y\n", lineDiff(expected, actual, 1))
+}
+
+func TestCompareGolden(t *testing.T) {
+	render := func(f *types.GoldenFixture) (string, error) {
+		if f.Path == "/bad" {
+			return "", fmt.Errorf("template error")
+		}
+		return "<p>hello</p>", nil
+	}
+	req := &types.GoldenRequest{Golden: map[string]string{"match": "<p>hello</p>\n", "mismatch": "<p>bye</p>"}}
+
+	tests := map[string]types.GoldenStatus{"match": types.GoldenMatch, "mismatch": types.GoldenMismatch, "new": types.GoldenNew}
+	for name, status := range tests {
+		result := types.GoldenResult{Name: name}
+		compareGolden(&result, &types.GoldenFixture{Name: name, Path: "/"}, req, nil, render)
+		testutil.AssertEqualsString(t, name, string(status), string(result.Status))
+	}
+
+	result := types.GoldenResult{}
+	compareGolden(&result, &types.GoldenFixture{Name: "mismatch", Path: "/"}, req, nil, render)
+	testutil.AssertEqualsString(t, "diff", "@@ line 1 @@\n- <p>bye</p>\n+ <p>hello</p>\n", result.Diff)
+	testutil.AssertEqualsString(t, "actual", "<p>hello</p>\n", result.Actual)
+
+	result = types.GoldenResult{}
+	compareGolden(&result, &types.GoldenFixture{Name: "bad", Path: "/bad"}, req, nil, render)
+	testutil.AssertEqualsString(t, "error", string(types.GoldenError), string(result.Status))
+	testutil.AssertEqualsString(t, "error message", "template error", result.Error)
+}
@@ -0,0 +1,117 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/container"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// imageSpecParam is the image spec param with the image reference
+const imageSpecParam = "image"
+
+// applyImageRequest updates the create request for an app created from a prebuilt image,
+// using the image spec with no source. The image can be set using the image field, an image://
+// source url or the image param, the request is not changed if none is set
+func applyImageRequest(appRequest *types.CreateAppRequest) error {
+	if image, ok := strings.CutPrefix(appRequest.SourceUrl, types.IMAGE_SOURCE_PREFIX); ok {
+		if image == "" {
+			return fmt.Errorf("image reference is required in source url %s", appRequest.SourceUrl)
+		}
+		if appRequest.Image != "" && appRequest.Image != image {
+			return fmt.Errorf("source url %s does not match image %s", appRequest.SourceUrl, appRequest.Image)
+		}
+		appRequest.Image = image
+		appRequest.SourceUrl = types.NO_SOURCE
+	}
+	if appRequest.Image == "" {
+		if appRequest.PinDigest && (appRequest.Spec != types.ImageSpec || appRequest.ParamValues[imageSpecParam] == "") {
+			return fmt.Errorf("pin digest is supported only for apps created from an image")
+		}
+		return nil
+	}
+
+	if appRequest.Spec != "" && appRequest.Spec != types.ImageSpec {
+		return fmt.Errorf("spec %s cannot be used with an image, the image spec is used", appRequest.Spec)
+	}
+	if appRequest.SourceUrl != "" && appRequest.SourceUrl != types.NO_SOURCE {
+		return fmt.Errorf("source url %s cannot be used with an image, no source is required", appRequest.SourceUrl)
+	}
+	if appRequest.IsDev {
+		return fmt.Errorf("dev mode is not supported for apps created from an image")
+	}
+	if appRequest.GitCommit != "" || appRequest.GitTag != "" || appRequest.GitAuthName != "" {
+		return fmt.Errorf("git options are not supported for apps created from an image")
+	}
+	if current := appRequest.ParamValues[imageSpecParam]; current != "" && current != appRequest.Image {
+		return fmt.Errorf("image param %s does not match image %s", current, appRequest.Image)
+	}
+	if strings.ContainsAny(appRequest.Image, " \t\n") {
+		return fmt.Errorf("invalid image reference %q", appRequest.Image)
+	}
+
+	appRequest.Spec = types.ImageSpec
+	appRequest.SourceUrl = types.NO_SOURCE
+	appRequest.GitBranch = ""
+	if appRequest.ParamValues == nil {
+		appRequest.ParamValues = map[string]string{}
+	}
+	appRequest.ParamValues[imageSpecParam] = appRequest.Image
+	return nil
+}
+
+// prepareImageApp sets up the create request for an app created from a prebuilt image. If
+// digest pinning is requested, the image tag is resolved to the current digest and the app is
+// created with the digest pinned reference, so later reloads do not pick up a moved tag
+func (s *Server) prepareImageApp(ctx context.Context, appRequest *types.CreateAppRequest) error {
+	if err := applyImageRequest(appRequest); err != nil {
+		return types.CreateRequestError(err.Error(), http.StatusBadRequest)
+	}
+	if !appRequest.PinDigest || appRequest.ParamsOnly {
+		return nil
+	}
+
+	image := appRequest.ParamValues[imageSpecParam]
+	if strings.Contains(image, "@") {
+		// Already pinned
+		return nil
+	}
+	digest, err := s.resolveImageDigest(ctx, image)
+	if err != nil {
+		return types.CreateRequestError(fmt.Sprintf("error resolving digest for image %s: %s", image, err), http.StatusBadRequest)
+	}
+	pinned := container.DigestPinned(image, digest)
+	s.Info().Msgf("Pinned image %s to %s", image, pinned)
+	appRequest.ParamValues[imageSpecParam] = pinned
+	appRequest.Image = pinned
+	return nil
+}
+
+// resolveImageDigest returns the current digest for the image. For Kubernetes, the digest is
+// read from the registry; for the command container managers, the image is pulled
+func (s *Server) resolveImageDigest(ctx context.Context, image string) (string, error) {
+	config := s.Config()
+	if config.System.ContainerCommand == types.CONTAINER_KUBERNETES {
+		registryConfig, err := container.ImageRegistryConfig(config, image)
+		if err != nil {
+			return "", err
+		}
+		result, err := container.CheckImageReferenceExists(ctx, s.Logger, image, registryConfig)
+		if err != nil {
+			return "", err
+		}
+		if !result.Exists {
+			return "", fmt.Errorf("image not found")
+		}
+		return result.Digest, nil
+	}
+
+	manager := container.NewCommandCM(s.Logger, config, "", "")
+	return manager.RefreshImage(ctx, container.ImageName(image))
+}
@@ -0,0 +1,58 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestApplyImageRequest(t *testing.T) {
+	request := types.CreateAppRequest{
+		Image:       "ghcr.io/org/tool:1.4",
+		GitBranch:   "main",
+		ParamValues: map[string]string{"port": "8080"},
+	}
+	testutil.AssertNoError(t, applyImageRequest(&request))
+	testutil.AssertEqualsString(t, "spec", string(types.ImageSpec), string(request.Spec))
+	testutil.AssertEqualsString(t, "source", types.NO_SOURCE, request.SourceUrl)
+	testutil.AssertEqualsString(t, "branch", "", request.GitBranch)
+	testutil.AssertEqualsString(t, "image", "ghcr.io/org/tool:1.4", request.ParamValues["image"])
+	testutil.AssertEqualsString(t, "port", "8080", request.ParamValues["port"])
+
+	// The image can be given as an image:// source url
+	request = types.CreateAppRequest{SourceUrl: "image://ghcr.io/org/app:v2", PinDigest: true}
+	testutil.AssertNoError(t, applyImageRequest(&request))
+	testutil.AssertEqualsString(t, "source", types.NO_SOURCE, request.SourceUrl)
+	testutil.AssertEqualsString(t, "image", "ghcr.io/org/app:v2", request.ParamValues["image"])
+
+	// No image, the request is unchanged
+	request = types.CreateAppRequest{SourceUrl: "/src", Spec: "python-flask"}
+	testutil.AssertNoError(t, applyImageRequest(&request))
+	testutil.AssertEqualsString(t, "source", "/src", request.SourceUrl)
+
+	// Pin digest works with the image spec param also
+	request = types.CreateAppRequest{Spec: types.ImageSpec, PinDigest: true, ParamValues: map[string]string{"image": "nginx"}}
+	testutil.AssertNoError(t, applyImageRequest(&request))
+
+	tests := []struct {
+		request types.CreateAppRequest
+		err     string
+	}{
+		{types.CreateAppRequest{Image: "nginx", Spec: "python-flask"}, "spec python-flask cannot be used with an image"},
+		{types.CreateAppRequest{Image: "nginx", SourceUrl: "/src"}, "no source is required"},
+		{types.CreateAppRequest{Image: "nginx", IsDev: true}, "dev mode is not supported"},
+		{types.CreateAppRequest{Image: "nginx", GitCommit: "abc"}, "git options are not supported"},
+		{types.CreateAppRequest{Image: "nginx", ParamValues: map[string]string{"image": "redis"}}, "does not match image"},
+		{types.CreateAppRequest{Image: "nginx latest"}, "invalid image reference"},
+		{types.CreateAppRequest{SourceUrl: "/src", PinDigest: true}, "pin digest is supported only"},
+		{types.CreateAppRequest{SourceUrl: "image://"}, "image reference is required"},
+		{types.CreateAppRequest{SourceUrl: "image://nginx", Image: "redis"}, "does not match image"},
+	}
+	for _, tt := range tests {
+		testutil.AssertErrorContains(t, applyImageRequest(&tt.request), tt.err)
+	}
+}
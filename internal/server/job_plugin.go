@@ -0,0 +1,189 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/app/starlark_type"
+	"github.com/openrundev/openrun/internal/plugin"
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+	"github.com/segmentio/ksuid"
+	"go.starlark.net/starlark"
+)
+
+const defaultJobRetry = 3
+
+func initJobPlugin(server *Server) {
+	j := &jobPlugin{}
+	pluginFuncs := []plugin.PluginFunc{
+		app.CreatePluginApiName(j.Submit, app.WRITE, "submit"),
+		app.CreatePluginApiName(j.Status, app.READ, "status"),
+		app.CreatePluginApiName(j.List, app.READ, "list"),
+		app.CreatePluginApiName(j.Cancel, app.WRITE, "cancel"),
+	}
+
+	newJobPlugin := func(pluginContext *types.PluginContext) (any, error) {
+		return &jobPlugin{server: server, pluginContext: pluginContext}, nil
+	}
+
+	app.RegisterPlugin("job", newJobPlugin, pluginFuncs)
+}
+
+// jobPlugin allows apps to run functions as background jobs. The job runs outside of the
+// request, on any server, with retries on failure
+type jobPlugin struct {
+	server        *Server
+	pluginContext *types.PluginContext
+}
+
+// Submit queues a call to a top level function in the app. args is a list of positional
+// arguments or a dict of keyword arguments, which has to be JSON serializable. Returns the job id
+func (j *jobPlugin) Submit(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var fn starlark.Callable
+	var fnArgs starlark.Value = starlark.None
+	retry := defaultJobRetry
+	delay := 0
+	if err := starlark.UnpackArgs("submit", args, kwargs, "func", &fn, "args?", &fnArgs, "retry?", &retry, "delay?", &delay); err != nil {
+		return nil, err
+	}
+
+	// The function is persisted by name, so it has to be resolvable from the module globals
+	starFn, ok := fn.(*starlark.Function)
+	if !ok || starFn.Globals()[starFn.Name()] != starFn {
+		return nil, fmt.Errorf("submit: func should be a top level function defined in the app, got %s", fn.Name())
+	}
+
+	var argsJson []byte
+	switch fnArgs.(type) {
+	case starlark.NoneType, *starlark.List, starlark.Tuple, *starlark.Dict:
+		value, err := starlark_type.UnmarshalStarlark(fnArgs)
+		if err != nil {
+			return nil, fmt.Errorf("submit: error converting args: %w", err)
+		}
+		if argsJson, err = json.Marshal(value); err != nil {
+			return nil, fmt.Errorf("submit: args should be JSON serializable: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("submit: args should be a list or dict, got %s", fnArgs.Type())
+	}
+
+	if retry < 0 {
+		return nil, fmt.Errorf("submit: retry should be zero or more, got %d", retry)
+	}
+	if delay < 0 {
+		return nil, fmt.Errorf("submit: delay should be zero or more, got %d", delay)
+	}
+
+	ctx := system.GetRequestContext(thread)
+	job := &types.JobEntry{
+		Id:         types.ID_PREFIX_JOB + ksuid.New().String(),
+		AppId:      j.pluginContext.AppId,
+		FuncName:   starFn.Name(),
+		Args:       string(argsJson),
+		MaxRetries: min(retry, j.pluginContext.AppConfig.Job.MaxRetries),
+		UserId:     system.GetContextUserId(ctx),
+		RunAfter:   time.Now().Add(time.Duration(delay) * time.Second),
+	}
+	if err := j.server.SubmitJob(ctx, job); err != nil {
+		return nil, err
+	}
+	return starlark.String(job.Id), nil
+}
+
+// Status returns the job details for a job submitted by the app
+func (j *jobPlugin) Status(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var id starlark.String
+	if err := starlark.UnpackArgs("status", args, kwargs, "id", &id); err != nil {
+		return nil, err
+	}
+
+	job, err := j.server.db.GetJob(system.GetRequestContext(thread), id.GoString())
+	if err != nil {
+		return nil, err
+	}
+	if job.AppId != j.pluginContext.AppId {
+		// Jobs of other apps are not visible
+		return nil, fmt.Errorf("job %s not found", id.GoString())
+	}
+	return jobToStarlark(job)
+}
+
+// List returns the most recent jobs submitted by the app, optionally filtered by status
+func (j *jobPlugin) List(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var status starlark.String
+	limit := defaultJobLimit
+	if err := starlark.UnpackArgs("list", args, kwargs, "status?", &status, "limit?", &limit); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultJobLimit
+	}
+
+	jobs, err := j.server.db.ListJobs(system.GetRequestContext(thread), j.pluginContext.AppId, types.JobStatus(status.GoString()), limit)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]starlark.Value, 0, len(jobs))
+	for _, job := range jobs {
+		v, err := jobToStarlark(job)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, v)
+	}
+	return starlark.NewList(ret), nil
+}
+
+// Cancel cancels a job which has not completed yet
+func (j *jobPlugin) Cancel(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var id starlark.String
+	if err := starlark.UnpackArgs("cancel", args, kwargs, "id", &id); err != nil {
+		return nil, err
+	}
+
+	if err := j.server.db.CancelJob(system.GetRequestContext(thread), j.pluginContext.AppId, id.GoString()); err != nil {
+		return nil, err
+	}
+	return starlark.True, nil
+}
+
+// jobToStarlark converts the job to a starlark dict, with the JSON args and result decoded
+func jobToStarlark(job *types.JobEntry) (starlark.Value, error) {
+	var args, result any
+	var err error
+	if job.Args != "" {
+		if args, err = app.DecodeJobValue(job.Args); err != nil {
+			return nil, fmt.Errorf("error decoding job args: %w", err)
+		}
+	}
+	if job.Result != "" {
+		if result, err = app.DecodeJobValue(job.Result); err != nil {
+			return nil, fmt.Errorf("error decoding job result: %w", err)
+		}
+	}
+
+	value := map[string]any{
+		"id":          job.Id,
+		"func_name":   job.FuncName,
+		"args":        args,
+		"status":      string(job.Status),
+		"attempts":    job.Attempts,
+		"max_retries": job.MaxRetries,
+		"error":       job.Error,
+		"result":      result,
+		"user_id":     job.UserId,
+		"create_time": job.CreateTime.Unix(),
+		"update_time": job.UpdateTime.Unix(),
+		"end_time":    0,
+	}
+	if job.EndTime != nil {
+		value["end_time"] = job.EndTime.Unix()
+	}
+	return starlark_type.MarshalStarlark(value)
+}
@@ -0,0 +1,238 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	// jobLeaseGrace is added to the job timeout to get the lease duration. A running job whose
+	// lease expires is assumed to be orphaned (server went down) and is claimed again
+	jobLeaseGrace = time.Minute
+	// jobClaimBatch is the max number of jobs started on each poll
+	jobClaimBatch   = 50
+	jobMaxBackoff   = time.Hour
+	jobBaseBackoff  = 10 * time.Second
+	defaultJobLimit = 100
+)
+
+// jobRunner tracks the background jobs running on this server, per app
+type jobRunner struct {
+	mu      sync.Mutex
+	running map[types.AppId]int
+	limits  map[types.AppId]int // the worker limit of the app, as of the last job started
+	wg      sync.WaitGroup
+
+	lastCronCheck        time.Time // the minute for which the app crons were last checked
+	lastDeprecationCheck time.Time // the last time the deprecated apps were checked for deletion
+	lastPrPreviewCheck   time.Time // the last time the pull request preview apps were checked for expiry
+	lastScheduleCheck    time.Time // the minute for which the app active hours were last checked
+}
+
+func (s *Server) startJobRunner() {
+	if s.Config().System.JobPollIntervalSecs <= 0 {
+		return
+	}
+
+	interval := time.Duration(s.Config().System.JobPollIntervalSecs) * time.Second
+	s.jobTicker = time.NewTicker(interval)
+	s.jobStop = make(chan struct{})
+	runCtx, cancel := context.WithCancel(context.Background())
+	s.jobCancel = cancel
+	s.jobDone = make(chan struct{})
+	// Passed in rather than read from s inside the loop, same as the stale container cleanup
+	go s.jobRunnerLoop(s.jobTicker, s.jobStop, runCtx, s.jobDone)
+}
+
+func (s *Server) jobRunnerLoop(ticker *time.Ticker, stop <-chan struct{}, runCtx context.Context, done chan<- struct{}) {
+	runner := &jobRunner{running: map[types.AppId]int{}, limits: map[types.AppId]int{}}
+	defer close(done)
+	s.Info().Msg("Starting background job runner")
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			ticker.Stop()
+			// In-flight jobs are interrupted by the runCtx cancel, wait for their state to be saved
+			runner.wg.Wait()
+			s.Info().Msg("Background job runner stopped")
+			return
+		}
+		s.runCrons(runCtx, runner)
+		s.runDeprecationChecks(runCtx, runner)
+		s.runPrPreviewExpiry(runCtx, runner)
+		s.runAppSchedules(runCtx, runner)
+		s.claimJobs(runCtx, runner)
+	}
+}
+
+// claimJobs starts the runnable jobs, up to the worker limit of each app
+func (s *Server) claimJobs(runCtx context.Context, runner *jobRunner) {
+	for range jobClaimBatch {
+		if runCtx.Err() != nil {
+			return
+		}
+		runner.mu.Lock()
+		skipApps := map[types.AppId]bool{}
+		for appId, count := range runner.running {
+			if count >= runner.limits[appId] {
+				skipApps[appId] = true
+			}
+		}
+		runner.mu.Unlock()
+
+		// The lease is based on the default timeout, it is extended in runJob if the app timeout is higher
+		lease := time.Duration(s.Config().AppConfig.Job.TimeoutSecs)*time.Second + jobLeaseGrace
+		job, err := s.db.ClaimJob(runCtx, skipApps, lease)
+		if err != nil {
+			if !errors.Is(err, context.Canceled) {
+				s.Error().Err(err).Msg("Error claiming background job")
+			}
+			return
+		}
+		if job == nil {
+			return
+		}
+
+		if job.Attempts > job.MaxRetries+1 {
+			// The lease of the last attempt expired, the server running it went away
+			job.Status = types.JobStatusFailed
+			job.Error = "job attempt did not complete, retries exhausted"
+			if err := s.db.CompleteJob(runCtx, job); err != nil {
+				s.Error().Err(err).Str("job", job.Id).Msg("Error updating background job")
+			}
+			continue
+		}
+
+		runner.mu.Lock()
+		runner.running[job.AppId]++
+		runner.mu.Unlock()
+		runner.wg.Add(1)
+		go func() {
+			defer runner.wg.Done()
+			s.runJob(runCtx, runner, job)
+			runner.mu.Lock()
+			runner.running[job.AppId]--
+			if runner.running[job.AppId] <= 0 {
+				delete(runner.running, job.AppId)
+				delete(runner.limits, job.AppId)
+			}
+			runner.mu.Unlock()
+		}()
+	}
+}
+
+func (s *Server) runJob(runCtx context.Context, runner *jobRunner, job *types.JobEntry) {
+	logger := s.With().Str("job", job.Id).Str("app_id", string(job.AppId)).Str("func", job.FuncName).Logger()
+	err := func() error {
+		appInfo, ok := s.apps.GetAppInfo(job.AppId)
+		if !ok {
+			return fmt.Errorf("app %s not found", job.AppId)
+		}
+
+		ctx := newBackgroundOperationContext(job.UserId)
+		ctx = context.WithValue(ctx, types.APP_ID, string(job.AppId))
+		ctx = context.WithValue(ctx, types.APP_PATH_DOMAIN, appInfo.AppPathDomain)
+		application, err := s.GetApp(ctx, appInfo.AppPathDomain, true)
+		if err != nil {
+			return err
+		}
+
+		runner.mu.Lock()
+		runner.limits[job.AppId] = max(application.AppConfig.Job.Workers, 1)
+		runner.mu.Unlock()
+
+		timeout := time.Duration(application.AppConfig.Job.TimeoutSecs) * time.Second
+		if timeout <= 0 {
+			timeout = time.Duration(s.Config().AppConfig.Job.TimeoutSecs) * time.Second
+		}
+		if lease := timeout + jobLeaseGrace; job.RunAfter.Before(time.Now().Add(lease)) {
+			if err := s.db.ExtendJobLease(ctx, job, lease); err != nil {
+				return err
+			}
+		}
+		jobCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		stop := context.AfterFunc(runCtx, cancel)
+		defer stop()
+
+		job.Result, err = application.RunJob(jobCtx, job.FuncName, job.Args)
+		return err
+	}()
+
+	// The job state is saved even if the runner is being stopped
+	saveCtx := context.Background()
+	if err != nil && runCtx.Err() != nil {
+		// Interrupted by the server stopping, rerun without counting the attempt against the retries
+		logger.Info().Err(err).Msg("Background job interrupted, requeuing")
+		if err := s.db.RequeueJob(saveCtx, job); err != nil {
+			logger.Error().Err(err).Msg("Error requeuing background job")
+		}
+		return
+	}
+
+	if err == nil {
+		job.Status = types.JobStatusSucceeded
+		job.Error = ""
+	} else if job.Attempts <= job.MaxRetries {
+		logger.Warn().Err(err).Int("attempt", job.Attempts).Msg("Background job failed, will retry")
+		job.Status = types.JobStatusPending
+		job.Error = err.Error()
+		job.RunAfter = time.Now().Add(jobBackoff(job.Attempts))
+	} else {
+		logger.Error().Err(err).Int("attempt", job.Attempts).Msg("Background job failed")
+		job.Status = types.JobStatusFailed
+		job.Error = err.Error()
+	}
+	if err := s.db.CompleteJob(saveCtx, job); err != nil {
+		logger.Error().Err(err).Msg("Error updating background job")
+	}
+}
+
+// jobBackoff returns the delay before the next attempt, doubling with each attempt
+func jobBackoff(attempt int) time.Duration {
+	backoff := jobBaseBackoff
+	for i := 1; i < attempt && backoff < jobMaxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, jobMaxBackoff)
+}
+
+// SubmitJob adds a background job for the app
+func (s *Server) SubmitJob(ctx context.Context, job *types.JobEntry) error {
+	return s.db.InsertJob(ctx, job)
+}
+
+// ListJobs returns the background jobs for an app
+func (s *Server) ListJobs(ctx context.Context, appPath string, status types.JobStatus, limit int) ([]*types.JobEntry, error) {
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	appPathDomain, err := parseAppPath(appPath)
+	if err != nil {
+		return nil, err
+	}
+	appEntry, err := s.db.GetAppEntryTx(ctx, tx, appPathDomain)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.enforceAppPermEntry(ctx, types.PermissionRead, appEntry); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = defaultJobLimit
+	}
+	return s.db.ListJobs(ctx, appEntry.Id, status, limit)
+}
@@ -0,0 +1,325 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openrundev/openrun/internal/passwd"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	// keyringKeyBytes is the size of the key material. For the session cookies, the first half is
+	// the HMAC key and the second half is the AES key, the other uses derive keys with HKDF
+	keyringKeyBytes = 64
+
+	// keyringEncPrefix marks a keyring stored encrypted with the KMS key
+	keyringEncPrefix = "enc:"
+	keyringAAD       = "openrun:keyring"
+	keyringIdChars   = "abcdefghijklmnopqrstuvwxyz0123456789"
+)
+
+// keyringEntry is a keyring key with its key material
+type keyringEntry struct {
+	types.KeyringKey
+	Material []byte `json:"material"`
+}
+
+type keyringData struct {
+	Keys []keyringEntry `json:"keys"` // the active key is first
+}
+
+// Keyring holds the server keys used to sign the session cookies and to derive the sync webhook
+// secrets. The active key signs, the retired keys are still accepted for verification. So rotating
+// the key does not invalidate the existing sessions at once, they move to the new key as they are
+// saved. The keyring is stored in the metadata KV store, encrypted if a KMS key is configured
+type Keyring struct {
+	*types.Logger
+	db  KVStore
+	kek cipher.AEAD // nil if not KMS backed
+
+	updateMu sync.Mutex // serializes the rotate and remove updates
+	mu       sync.RWMutex
+	data     keyringData
+	onChange func(sessionKeyPairs [][]byte)
+}
+
+// NewKeyring creates the keyring. kmsKey is the resolved base64 encoded KMS key, empty if the
+// keyring is stored unencrypted. onChange is called with the session cookie key pairs whenever
+// the keys are loaded
+func NewKeyring(logger *types.Logger, db KVStore, kmsKey string, onChange func([][]byte)) (*Keyring, error) {
+	k := &Keyring{Logger: logger, db: db, onChange: onChange}
+	if kmsKey != "" {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(kmsKey))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("security.keyring_kms_key should resolve to a base64 encoded 32 byte key")
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+		if k.kek, err = cipher.NewGCM(block); err != nil {
+			return nil, err
+		}
+	}
+	return k, nil
+}
+
+// Init loads the keyring from the database. If there is no keyring, one is created with the
+// initial key material as the active key. The existing session secrets are passed as the initial
+// material, so the sessions created before the keyring stay valid
+func (k *Keyring) Init(ctx context.Context, initialMaterial []byte) error {
+	stored, err := k.db.FetchKVBlob(ctx, types.CONSTANT_KV_PREFIX+types.KEYRING_KV)
+	if err != nil {
+		entry, err := newKeyringEntry(initialMaterial)
+		if err != nil {
+			return err
+		}
+		data := keyringData{Keys: []keyringEntry{*entry}}
+		if err = k.store(ctx, data, false); err != nil {
+			// Maybe concurrent insert from another server, use the value from the DB
+			return k.Reload(ctx)
+		}
+		k.setData(data)
+		return nil
+	}
+
+	data, encrypted, err := k.decode(stored)
+	if err != nil {
+		return err
+	}
+	if k.kek != nil && !encrypted {
+		// The KMS key was added, store the keyring encrypted
+		if err := k.store(ctx, data, true); err != nil {
+			return err
+		}
+	}
+	k.setData(data)
+	return nil
+}
+
+// Reload loads the keyring from the database, after another server updated it
+func (k *Keyring) Reload(ctx context.Context) error {
+	stored, err := k.db.FetchKVBlob(ctx, types.CONSTANT_KV_PREFIX+types.KEYRING_KV)
+	if err != nil {
+		return fmt.Errorf("error loading keyring: %w", err)
+	}
+	data, _, err := k.decode(stored)
+	if err != nil {
+		return err
+	}
+	k.setData(data)
+	return nil
+}
+
+func newKeyringEntry(material []byte) (*keyringEntry, error) {
+	if material == nil {
+		var err error
+		if material, err = passwd.GenerateRandomKey(keyringKeyBytes); err != nil {
+			return nil, err
+		}
+	}
+	if len(material) != keyringKeyBytes {
+		return nil, fmt.Errorf("keyring key should be %d bytes, got %d", keyringKeyBytes, len(material))
+	}
+	suffix, err := passwd.GenerateRandString(8, keyringIdChars)
+	if err != nil {
+		return nil, err
+	}
+	return &keyringEntry{
+		KeyringKey: types.KeyringKey{Id: "k" + suffix, Status: types.KEYRING_KEY_ACTIVE, CreateTime: time.Now().UTC()},
+		Material:   material,
+	}, nil
+}
+
+// decode parses the stored keyring, decrypting it with the KMS key if it is encrypted
+func (k *Keyring) decode(stored []byte) (keyringData, bool, error) {
+	var data keyringData
+	encrypted := strings.HasPrefix(string(stored), keyringEncPrefix)
+	if encrypted {
+		if k.kek == nil {
+			return data, true, fmt.Errorf("keyring is encrypted, security.keyring_kms_key is required")
+		}
+		sealed, err := base64.StdEncoding.DecodeString(string(stored[len(keyringEncPrefix):]))
+		if err != nil {
+			return data, true, fmt.Errorf("error decoding keyring: %w", err)
+		}
+		nonceSize := k.kek.NonceSize()
+		if len(sealed) < nonceSize {
+			return data, true, fmt.Errorf("invalid encrypted keyring")
+		}
+		if stored, err = k.kek.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(keyringAAD)); err != nil {
+			return data, true, fmt.Errorf("error decrypting keyring, check security.keyring_kms_key: %w", err)
+		}
+	}
+	if err := json.Unmarshal(stored, &data); err != nil {
+		return data, encrypted, fmt.Errorf("error parsing keyring: %w", err)
+	}
+	if len(data.Keys) == 0 || data.Keys[0].Status != types.KEYRING_KEY_ACTIVE {
+		return data, encrypted, fmt.Errorf("keyring has no active key")
+	}
+	return data, encrypted, nil
+}
+
+// store saves the keyring, encrypted if the KMS key is configured. The keyring is inserted if
+// update is false, the insert fails if another server created the keyring
+func (k *Keyring) store(ctx context.Context, data keyringData, update bool) error {
+	value, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if k.kek != nil {
+		nonce := make([]byte, k.kek.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return err
+		}
+		sealed := k.kek.Seal(nonce, nonce, value, []byte(keyringAAD))
+		value = []byte(keyringEncPrefix + base64.StdEncoding.EncodeToString(sealed))
+	}
+	if update {
+		return k.db.UpsertKVBlob(ctx, types.CONSTANT_KV_PREFIX+types.KEYRING_KV, value, nil)
+	}
+	return k.db.StoreKVBlob(ctx, types.CONSTANT_KV_PREFIX+types.KEYRING_KV, value, nil)
+}
+
+func (k *Keyring) setData(data keyringData) {
+	k.mu.Lock()
+	k.data = data
+	k.mu.Unlock()
+	if k.onChange != nil {
+		k.onChange(k.SessionKeyPairs())
+	}
+}
+
+// Rotate adds a new active key, the current active key is retired
+func (k *Keyring) Rotate(ctx context.Context) (*types.KeyringResponse, error) {
+	k.updateMu.Lock()
+	defer k.updateMu.Unlock()
+	entry, err := newKeyringEntry(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	k.mu.RLock()
+	keys := append([]keyringEntry{*entry}, k.data.Keys...)
+	k.mu.RUnlock()
+	now := time.Now().UTC()
+	keys[1].Status = types.KEYRING_KEY_RETIRED
+	keys[1].RetireTime = &now
+
+	if err := k.update(ctx, keyringData{Keys: keys}); err != nil {
+		return nil, err
+	}
+	return k.List(), nil
+}
+
+// Remove deletes a retired key. The session cookies and webhook secrets signed with the key are
+// no longer valid
+func (k *Keyring) Remove(ctx context.Context, id string) (*types.KeyringResponse, error) {
+	k.updateMu.Lock()
+	defer k.updateMu.Unlock()
+	k.mu.RLock()
+	keys := make([]keyringEntry, 0, len(k.data.Keys))
+	found := false
+	for _, key := range k.data.Keys {
+		if key.Id != id {
+			keys = append(keys, key)
+			continue
+		}
+		found = true
+		if key.Status == types.KEYRING_KEY_ACTIVE {
+			k.mu.RUnlock()
+			return nil, fmt.Errorf("key %s is the active key, rotate the keyring before removing it", id)
+		}
+	}
+	k.mu.RUnlock()
+	if !found {
+		return nil, fmt.Errorf("key %s not found in keyring", id)
+	}
+
+	if err := k.update(ctx, keyringData{Keys: keys}); err != nil {
+		return nil, err
+	}
+	return k.List(), nil
+}
+
+func (k *Keyring) update(ctx context.Context, data keyringData) error {
+	if err := k.store(ctx, data, true); err != nil {
+		return fmt.Errorf("error storing keyring: %w", err)
+	}
+	k.setData(data)
+	return nil
+}
+
+// List returns the keyring keys, without the key material
+func (k *Keyring) List() *types.KeyringResponse {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	ret := &types.KeyringResponse{Keys: make([]types.KeyringKey, 0, len(k.data.Keys)), KmsBacked: k.kek != nil}
+	for _, key := range k.data.Keys {
+		ret.Keys = append(ret.Keys, key.KeyringKey)
+	}
+	return ret
+}
+
+// SessionKeyPairs returns the hash key and block key pairs for the session cookie codecs, the
+// active key first
+func (k *Keyring) SessionKeyPairs() [][]byte {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	pairs := make([][]byte, 0, 2*len(k.data.Keys))
+	for _, key := range k.data.Keys {
+		pairs = append(pairs, key.Material[:keyringKeyBytes/2], key.Material[keyringKeyBytes/2:])
+	}
+	return pairs
+}
+
+// Sign returns the HMAC of the data using a key derived from the active key for the purpose,
+// along with the active key id
+func (k *Keyring) Sign(purpose string, data []byte) (string, []byte, error) {
+	k.mu.RLock()
+	active := k.data.Keys[0]
+	k.mu.RUnlock()
+	sig, err := keyringSign(active.Material, purpose, data)
+	return active.Id, sig, err
+}
+
+// Signatures returns the HMAC of the data for each key in the keyring, the active key first.
+// Used to verify values signed with the active or the retired keys
+func (k *Keyring) Signatures(purpose string, data []byte) ([][]byte, error) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	ret := make([][]byte, 0, len(k.data.Keys))
+	for _, key := range k.data.Keys {
+		sig, err := keyringSign(key.Material, purpose, data)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, sig)
+	}
+	return ret, nil
+}
+
+func keyringSign(material []byte, purpose string, data []byte) ([]byte, error) {
+	key, err := hkdf.Key(sha256.New, material, nil, "openrun:"+purpose, 32)
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
@@ -0,0 +1,104 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// initKeyring loads the server keyring. The session cookie keys are updated whenever the
+// keyring changes. The KMS key is a secret reference, like {{secret "vault" "openrun_kek"}}
+func (s *Server) initKeyring(config *types.ServerConfig, secretsManager *system.SecretManager, initialMaterial []byte) error {
+	kmsKey, err := secretsManager.EvalTemplate(config.Security.KeyringKmsKey)
+	if err != nil {
+		return fmt.Errorf("error resolving security.keyring_kms_key: %w", err)
+	}
+	s.keyring, err = NewKeyring(s.Logger, s.db, kmsKey, s.oAuthManager.SetSessionKeyPairs)
+	if err != nil {
+		return err
+	}
+	return s.keyring.Init(context.Background(), initialMaterial)
+}
+
+func (s *Server) keyringNotifyHandler(updatePayload types.KeyringUpdatePayload) {
+	if updatePayload.ServerId == types.CurrentServerId || s.keyring == nil {
+		s.Trace().Str("server_id", string(updatePayload.ServerId)).Msg("Ignoring keyring update notification")
+		return
+	}
+	s.Debug().Str("server_id", string(updatePayload.ServerId)).Msgf(
+		"Received keyring update notification from %s", updatePayload.ServerId)
+	if err := s.keyring.Reload(context.Background()); err != nil {
+		s.Error().Err(err).Msg("error reloading keyring")
+	}
+}
+
+// ListKeyring returns the keyring keys, the key material is not returned
+func (s *Server) ListKeyring(ctx context.Context) (*types.KeyringResponse, error) {
+	if err := s.enforceGlobalPerm(ctx, types.PermissionConfigRead, ""); err != nil {
+		return nil, err
+	}
+	return s.keyring.List(), nil
+}
+
+// RotateKeyring adds a new active key. The retired key is still accepted till it is removed
+func (s *Server) RotateKeyring(ctx context.Context) (*types.KeyringResponse, error) {
+	if err := s.enforceGlobalPerm(ctx, types.PermissionConfigUpdate, ""); err != nil {
+		return nil, err
+	}
+	ret, err := s.keyring.Rotate(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.NotifyKeyringUpdate(); err != nil {
+		s.Error().Err(err).Msg("error notifying keyring update")
+	}
+	return ret, nil
+}
+
+// RemoveKeyringKey removes a retired key. The sessions and webhook secrets signed with the key
+// stop working
+func (s *Server) RemoveKeyringKey(ctx context.Context, id string) (*types.KeyringResponse, error) {
+	if err := s.enforceGlobalPerm(ctx, types.PermissionConfigUpdate, ""); err != nil {
+		return nil, err
+	}
+	ret, err := s.keyring.Remove(ctx, id)
+	if err != nil {
+		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+	}
+	if err := s.db.NotifyKeyringUpdate(); err != nil {
+		s.Error().Err(err).Msg("error notifying keyring update")
+	}
+	return ret, nil
+}
+
+// GetSyncWebhookSecret returns the webhook secret for a webhook sync, derived from the active
+// keyring key. After a key rotation, the git provider webhooks are updated with this secret
+// before the retired key is removed
+func (s *Server) GetSyncWebhookSecret(ctx context.Context, id string) (*types.SyncWebhookSecretResponse, error) {
+	if err := s.enforceGlobalPerm(ctx, types.PermissionSyncCreate, ""); err != nil {
+		return nil, err
+	}
+	entry, err := s.getSyncEntry(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if entry.IsScheduled {
+		return nil, types.CreateRequestError(fmt.Sprintf("sync %s is not a webhook sync", id), http.StatusBadRequest)
+	}
+	keyId, secret, err := s.syncWebhookSecret(id)
+	if err != nil {
+		return nil, err
+	}
+	return &types.SyncWebhookSecretResponse{
+		Id:            id,
+		WebhookUrl:    s.syncWebhookUrl(entry),
+		WebhookSecret: secret,
+		KeyId:         keyId,
+	}, nil
+}
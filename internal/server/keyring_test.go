@@ -0,0 +1,133 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestKeyringRotate(t *testing.T) {
+	ctx := context.Background()
+	db := NewInmemoryKVStore()
+	legacy := bytes.Repeat([]byte("k"), keyringKeyBytes)
+
+	store := NewKVSessionStore(db, legacy[:keyringKeyBytes/2], legacy[keyringKeyBytes/2:])
+	store.Options.Secure = false
+	keyring, err := NewKeyring(types.NewLogger(&types.LogConfig{}), db, "", func(pairs [][]byte) { store.SetKeyPairs(pairs...) })
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, keyring.Init(ctx, legacy))
+
+	// Session saved with the legacy key
+	req := httptest.NewRequest("GET", "/app", nil)
+	w := httptest.NewRecorder()
+	session, err := store.Get(req, "test_session")
+	testutil.AssertNoError(t, err)
+	session.Values[USER_KEY] = "user1"
+	testutil.AssertNoError(t, session.Save(req, w))
+	cookie := w.Result().Cookies()[0]
+
+	oldSig, err := keyring.Signatures("test", []byte("data"))
+	testutil.AssertNoError(t, err)
+	keys := keyring.List().Keys
+	testutil.AssertEqualsInt(t, "key count", 1, len(keys))
+	oldId := keys[0].Id
+
+	rotated, err := keyring.Rotate(ctx)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "key count", 2, len(rotated.Keys))
+	testutil.AssertEqualsString(t, "active", types.KEYRING_KEY_ACTIVE, rotated.Keys[0].Status)
+	testutil.AssertEqualsString(t, "retired", types.KEYRING_KEY_RETIRED, rotated.Keys[1].Status)
+	testutil.AssertEqualsString(t, "retired id", oldId, rotated.Keys[1].Id)
+
+	// The retired key signature is still accepted, new signatures use the new key
+	sigs, err := keyring.Signatures("test", []byte("data"))
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "sig count", 2, len(sigs))
+	testutil.AssertEqualsBool(t, "retired sig", true, bytes.Equal(oldSig[0], sigs[1]))
+	keyId, sig, err := keyring.Sign("test", []byte("data"))
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "sign key", rotated.Keys[0].Id, keyId)
+	testutil.AssertEqualsBool(t, "new sig", true, bytes.Equal(sigs[0], sig))
+
+	// The session from the retired key is still valid
+	session2, err := store.Get(newSessionRequest(cookie), "test_session")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "user", "user1", session2.Values[USER_KEY].(string))
+
+	// Another server loads the rotated keyring
+	keyring2, err := NewKeyring(types.NewLogger(&types.LogConfig{}), db, "", nil)
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, keyring2.Init(ctx, nil))
+	testutil.AssertEqualsInt(t, "key count", 2, len(keyring2.List().Keys))
+
+	_, err = keyring.Remove(ctx, rotated.Keys[0].Id)
+	testutil.AssertErrorContains(t, err, "is the active key")
+	_, err = keyring.Remove(ctx, "unknown")
+	testutil.AssertErrorContains(t, err, "not found in keyring")
+	removed, err := keyring.Remove(ctx, oldId)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "key count", 1, len(removed.Keys))
+
+	// The session from the removed key is no longer valid
+	session3, err := store.Get(newSessionRequest(cookie), "test_session")
+	if err == nil && session3.Values[USER_KEY] != nil {
+		t.Fatal("expected session from removed key to be invalid")
+	}
+}
+
+func TestKeyringKms(t *testing.T) {
+	ctx := context.Background()
+	db := NewInmemoryKVStore()
+	kmsKey := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("m"), 32))
+
+	_, err := NewKeyring(types.NewLogger(&types.LogConfig{}), db, "abc", nil)
+	testutil.AssertErrorContains(t, err, "base64 encoded 32 byte key")
+
+	// Unencrypted keyring is encrypted when the KMS key is added
+	plain, err := NewKeyring(types.NewLogger(&types.LogConfig{}), db, "", nil)
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, plain.Init(ctx, nil))
+	testutil.AssertEqualsBool(t, "kms", false, plain.List().KmsBacked)
+
+	keyring, err := NewKeyring(types.NewLogger(&types.LogConfig{}), db, kmsKey, nil)
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, keyring.Init(ctx, nil))
+	testutil.AssertEqualsBool(t, "kms", true, keyring.List().KmsBacked)
+	stored, err := db.FetchKVBlob(ctx, types.CONSTANT_KV_PREFIX+types.KEYRING_KV)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsBool(t, "encrypted", true, strings.HasPrefix(string(stored), keyringEncPrefix))
+	if strings.Contains(string(stored), "material") {
+		t.Fatal("expected keyring to be stored encrypted")
+	}
+
+	_, err = keyring.Rotate(ctx)
+	testutil.AssertNoError(t, err)
+	reloaded, err := NewKeyring(types.NewLogger(&types.LogConfig{}), db, kmsKey, nil)
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, reloaded.Init(ctx, nil))
+	testutil.AssertEqualsInt(t, "key count", 2, len(reloaded.List().Keys))
+
+	// Encrypted keyring cannot be loaded without the KMS key or with a different key
+	err = plain.Reload(ctx)
+	testutil.AssertErrorContains(t, err, "keyring_kms_key is required")
+	other, err := NewKeyring(types.NewLogger(&types.LogConfig{}), db, base64.StdEncoding.EncodeToString(bytes.Repeat([]byte("o"), 32)), nil)
+	testutil.AssertNoError(t, err)
+	err = other.Init(ctx, nil)
+	testutil.AssertErrorContains(t, err, "error decrypting keyring")
+}
+
+func newSessionRequest(cookie *http.Cookie) *http.Request {
+	req := httptest.NewRequest("GET", "/app", nil)
+	req.AddCookie(cookie)
+	return req
+}
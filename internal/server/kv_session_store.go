@@ -7,6 +7,7 @@ import (
 	"encoding/base32"
 	"fmt"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/gorilla/securecookie"
@@ -24,7 +25,8 @@ var kvSessionIDEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
 // KVSessionStore keeps session payloads server-side and puts only an opaque,
 // signed session id in the browser cookie.
 type KVSessionStore struct {
-	Codecs      []securecookie.Codec
+	mu          sync.RWMutex // guards the codecs, which are replaced on keyring rotation
+	codecs      []securecookie.Codec
 	valueCodecs []securecookie.Codec
 	Options     *sessions.Options
 	db          KVStore
@@ -34,8 +36,6 @@ var _ sessions.Store = (*KVSessionStore)(nil)
 
 func NewKVSessionStore(db KVStore, keyPairs ...[]byte) *KVSessionStore {
 	store := &KVSessionStore{
-		Codecs:      securecookie.CodecsFromPairs(keyPairs...),
-		valueCodecs: securecookie.CodecsFromPairs(keyPairs...),
 		Options: &sessions.Options{
 			Path:     "/",
 			MaxAge:   86400 * 30,
@@ -44,14 +44,38 @@ func NewKVSessionStore(db KVStore, keyPairs ...[]byte) *KVSessionStore {
 		},
 		db: db,
 	}
-	for _, codec := range store.valueCodecs {
+	store.SetKeyPairs(keyPairs...)
+	return store
+}
+
+// SetKeyPairs replaces the hash key and block key pairs. The first pair encodes, all the pairs
+// are tried for decoding, so the sessions signed with older keys stay valid
+func (s *KVSessionStore) SetKeyPairs(keyPairs ...[]byte) {
+	codecs := securecookie.CodecsFromPairs(keyPairs...)
+	valueCodecs := securecookie.CodecsFromPairs(keyPairs...)
+	for _, codec := range codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(s.Options.MaxAge)
+		}
+	}
+	for _, codec := range valueCodecs {
 		if sc, ok := codec.(*securecookie.SecureCookie); ok {
 			// Server-side values can exceed cookie limits, but should still be bounded.
 			sc.MaxLength(maxKVSessionValueLength)
+			sc.MaxAge(s.Options.MaxAge)
 		}
 	}
-	store.MaxAge(store.Options.MaxAge)
-	return store
+
+	s.mu.Lock()
+	s.codecs = codecs
+	s.valueCodecs = valueCodecs
+	s.mu.Unlock()
+}
+
+func (s *KVSessionStore) getCodecs() ([]securecookie.Codec, []securecookie.Codec) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.codecs, s.valueCodecs
 }
 
 func (s *KVSessionStore) Get(r *http.Request, name string) (*sessions.Session, error) {
@@ -69,7 +93,8 @@ func (s *KVSessionStore) New(r *http.Request, name string) (*sessions.Session, e
 		return session, nil
 	}
 
-	if err := securecookie.DecodeMulti(name, c.Value, &session.ID, s.Codecs...); err != nil {
+	codecs, _ := s.getCodecs()
+	if err := securecookie.DecodeMulti(name, c.Value, &session.ID, codecs...); err != nil {
 		return session, err
 	}
 	if session.ID == "" {
@@ -108,7 +133,8 @@ func (s *KVSessionStore) Save(r *http.Request, w http.ResponseWriter, session *s
 		return err
 	}
 
-	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	codecs, _ := s.getCodecs()
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, codecs...)
 	if err != nil {
 		return err
 	}
@@ -119,12 +145,13 @@ func (s *KVSessionStore) Save(r *http.Request, w http.ResponseWriter, session *s
 
 func (s *KVSessionStore) MaxAge(age int) {
 	s.Options.MaxAge = age
-	for _, codec := range s.Codecs {
+	codecs, valueCodecs := s.getCodecs()
+	for _, codec := range codecs {
 		if sc, ok := codec.(*securecookie.SecureCookie); ok {
 			sc.MaxAge(age)
 		}
 	}
-	for _, codec := range s.valueCodecs {
+	for _, codec := range valueCodecs {
 		if sc, ok := codec.(*securecookie.SecureCookie); ok {
 			sc.MaxAge(age)
 		}
@@ -139,11 +166,13 @@ func (s *KVSessionStore) load(r *http.Request, session *sessions.Session) error
 	if err != nil {
 		return err
 	}
-	return securecookie.DecodeMulti(session.Name(), string(value), &session.Values, s.valueCodecs...)
+	_, valueCodecs := s.getCodecs()
+	return securecookie.DecodeMulti(session.Name(), string(value), &session.Values, valueCodecs...)
 }
 
 func (s *KVSessionStore) save(r *http.Request, session *sessions.Session) error {
-	encoded, err := securecookie.EncodeMulti(session.Name(), session.Values, s.valueCodecs...)
+	_, valueCodecs := s.getCodecs()
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.Values, valueCodecs...)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,68 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/openrundev/openrun/internal/telemetry"
+)
+
+// registerMetrics registers the observable metrics which are read from the server state when
+// the metrics are collected: the DB pool stats and the app container states. Errors are logged,
+// the metrics are not required for the server to work
+func (s *Server) registerMetrics() {
+	if !telemetry.MetricsEnabled() {
+		return
+	}
+	if err := telemetry.RegisterDBPoolStats("metadata", s.db.DBStats); err != nil {
+		s.Error().Err(err).Msg("error registering metadata db pool metrics")
+	}
+	if s.auditDB != nil {
+		if err := telemetry.RegisterDBPoolStats("audit", s.auditDB.Stats); err != nil {
+			s.Error().Err(err).Msg("error registering audit db pool metrics")
+		}
+	}
+	if err := telemetry.RegisterContainerStates(s.apps.ContainerStates); err != nil {
+		s.Error().Err(err).Msg("error registering container state metrics")
+	}
+}
+
+// startMetricsServer starts the listener for the Prometheus scrape endpoint, if enabled. The
+// endpoint is on a separate address so that it is not exposed with the app routes
+func (s *Server) startMetricsServer() error {
+	config := s.Config().Telemetry
+	if !config.Prometheus || !s.telemetry.PrometheusEnabled() {
+		return nil
+	}
+	if config.PrometheusAddress == "" {
+		return errors.New("telemetry.prometheus_address is required when telemetry.prometheus is enabled")
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", s.telemetry.MetricsHandler(config.PrometheusToken))
+	s.metricsServer = &http.Server{
+		WriteTimeout: 60 * time.Second,
+		ReadTimeout:  30 * time.Second,
+		IdleTimeout:  30 * time.Second,
+		Handler:      mux,
+	}
+
+	listener, err := s.upgrader.Listen("tcp", config.PrometheusAddress, net.Listen)
+	if err != nil {
+		return fmt.Errorf("error starting metrics listener %s: %w", config.PrometheusAddress, err)
+	}
+	s.Info().Str("address", listener.Addr().String()).Msg("Starting Prometheus metrics server")
+	go func() {
+		// The metrics endpoint is not essential, an error is logged without stopping the server
+		if err := s.metricsServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.Error().Err(err).Msg("Prometheus metrics server error")
+		}
+	}()
+	return nil
+}
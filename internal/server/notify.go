@@ -0,0 +1,106 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/openrundev/openrun/internal/notify"
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// validateNotifyConfig checks the [notify.<name>] entries in the server config
+func validateNotifyConfig(configs map[string]types.NotifyConfig) error {
+	for _, name := range slices.Sorted(maps.Keys(configs)) {
+		if err := notify.Validate(name, configs[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateSyncNotify checks that the notify entries listed for a sync are in the server config
+func (s *Server) validateSyncNotify(names []string) error {
+	for _, name := range names {
+		if _, ok := s.Config().Notify[name]; !ok {
+			return fmt.Errorf("notify entry %s not found in server config", name)
+		}
+	}
+	return nil
+}
+
+// notifyTargets returns the names of the notify entries to use: the listed entries and the
+// global entries, filtered by the outcome
+func notifyTargets(configs map[string]types.NotifyConfig, listed []string, success bool) []string {
+	ret := []string{}
+	for _, name := range slices.Sorted(maps.Keys(configs)) {
+		config := configs[name]
+		if (config.Global || slices.Contains(listed, name)) && notify.ShouldNotify(config, success) {
+			ret = append(ret, name)
+		}
+	}
+	return ret
+}
+
+// notifySync sends the result of a sync run to the notify entries of the sync and the global
+// entries. Runs where the apply was skipped since there is no new commit are not notified
+func (s *Server) notifySync(entry *types.SyncEntry, status *types.SyncJobStatus, repoCache *RepoCache) {
+	if status.ApplyResponse.SkippedApply || status.ApplyResponse.DryRun {
+		return
+	}
+
+	event := notify.NewEvent(notify.EVENT_SYNC, entry.Path, &status.ApplyResponse, status.Error)
+	event.SyncId = entry.Id
+	event.UserId = entry.UserID
+	if system.IsGit(entry.Path) {
+		event.Branch = cmp.Or(entry.Metadata.GitBranch, "main")
+		if status.Error == "" {
+			event.CommitMessage = repoCache.CachedCommitMessage(entry.Path, event.Branch, entry.Metadata.GitAuth)
+		}
+	}
+	s.sendNotifications(event, entry.Metadata.Notify)
+}
+
+// notifyApply sends the result of an apply to the global notify entries. Dry runs and dev mode
+// applies are not notified
+func (s *Server) notifyApply(ctx context.Context, applyPath, branch, gitAuth string, dev bool,
+	resp *types.AppApplyResponse, applyErr error, repoCache *RepoCache) {
+	if dev || (resp != nil && (resp.DryRun || resp.SkippedApply)) {
+		return
+	}
+
+	errMsg := ""
+	if applyErr != nil {
+		errMsg = applyErr.Error()
+	}
+	event := notify.NewEvent(notify.EVENT_APPLY, applyPath, resp, errMsg)
+	event.UserId = system.GetContextUserId(ctx)
+	if system.IsGit(applyPath) {
+		event.Branch = cmp.Or(branch, "main")
+		if applyErr == nil {
+			event.CommitMessage = repoCache.CachedCommitMessage(applyPath, event.Branch, gitAuth)
+		}
+	}
+	s.sendNotifications(event, nil)
+}
+
+// sendNotifications sends the event to the notify entries in the background, errors are logged
+func (s *Server) sendNotifications(event *notify.Event, listed []string) {
+	configs := s.Config().Notify
+	for _, name := range notifyTargets(configs, listed, event.Success) {
+		config := configs[name]
+		go func() {
+			if err := notify.Send(context.Background(), config, event, s.secretsMgr().EvalTemplate); err != nil {
+				s.Warn().Err(err).Msgf("Error sending notification %s for %s", name, event.Title())
+				return
+			}
+			s.Debug().Msgf("Sent notification %s for %s", name, event.Title())
+		}()
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestNotifyTargets(t *testing.T) {
+	configs := map[string]types.NotifyConfig{
+		"all":     {Type: "slack", Global: true},
+		"errors":  {Type: "email", Global: true, On: "failure"},
+		"team":    {Type: "webhook"},
+		"success": {Type: "webhook", On: "success"},
+	}
+	testutil.AssertEqualsString(t, "success", "all",
+		strings.Join(notifyTargets(configs, nil, true), ","))
+	testutil.AssertEqualsString(t, "failure", "all,errors",
+		strings.Join(notifyTargets(configs, nil, false), ","))
+	testutil.AssertEqualsString(t, "listed", "all,success,team",
+		strings.Join(notifyTargets(configs, []string{"team", "success", "unknown"}, true), ","))
+	testutil.AssertEqualsString(t, "listed failure", "all,errors,team",
+		strings.Join(notifyTargets(configs, []string{"team", "success"}, false), ","))
+
+	testutil.AssertNoError(t, validateNotifyConfig(map[string]types.NotifyConfig{
+		"ok": {Type: "slack", Url: "https://hooks.slack.com/services/x"}}))
+	testutil.AssertErrorContains(t, validateNotifyConfig(map[string]types.NotifyConfig{
+		"ok": {Type: "slack", Url: "https://hooks.slack.com/services/x"}, "bad": {Type: "slack"}}), "notify bad: url is required")
+}
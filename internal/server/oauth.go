@@ -136,6 +136,13 @@ func (s *OAuthManager) Setup(sessionKey []byte, sessionBlockKey []byte) error {
 	return s.registerProviders(s.config.Auth, true)
 }
 
+// SetSessionKeyPairs replaces the session cookie keys, called when the keyring is loaded or updated
+func (s *OAuthManager) SetSessionKeyPairs(keyPairs [][]byte) {
+	if store, ok := s.cookieStore.(*KVSessionStore); ok {
+		store.SetKeyPairs(keyPairs...)
+	}
+}
+
 // buildProvider creates the goth provider for one auth config entry
 func (s *OAuthManager) buildProvider(providerName string, auth types.AuthConfig) (goth.Provider, error) {
 	key := auth.Key
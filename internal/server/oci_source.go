@@ -0,0 +1,157 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	ggcrtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/openrundev/openrun/internal/container"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	// ociTitleAnnotation is the file name of a layer, set by oras push
+	ociTitleAnnotation = "org.opencontainers.image.title"
+	// ociUnpackAnnotation is set by oras push for a directory packed as a tar.gz layer
+	ociUnpackAnnotation = "io.deis.oras.content.unpack"
+)
+
+// FetchOCI pulls the OCI artifact source url, like oci://ghcr.io/org/app:1.2, and extracts its
+// layers. The tag is resolved to the current manifest digest and the artifact is pulled by that
+// digest, which is returned. Directory layers pushed by oras are extracted, other layers are
+// written as files using their title annotation. Registry credentials are from the registry_auth
+// config, like for image apps
+func (r *RepoCache) FetchOCI(ctx context.Context, sourceUrl string) (string, string, error) {
+	r.mu.Lock()
+	cached, ok := r.archiveCache[sourceUrl]
+	r.mu.Unlock()
+	if ok {
+		return cached.dir, cached.sha, nil
+	}
+
+	imageRef := strings.TrimPrefix(sourceUrl, types.OCI_SOURCE_PREFIX)
+	if imageRef == "" {
+		return "", "", fmt.Errorf("artifact reference is required in source url %s", sourceUrl)
+	}
+	registryConfig, err := container.ImageRegistryConfig(r.server.Config(), imageRef)
+	if err != nil {
+		return "", "", err
+	}
+	ref, opts, err := container.GetImageReferenceConfig(ctx, imageRef, registryConfig)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid artifact reference %s: %w", imageRef, err)
+	}
+	desc, err := remote.Head(ref, opts...)
+	if err != nil {
+		return "", "", fmt.Errorf("error resolving digest for %s: %w", imageRef, err)
+	}
+	digest := desc.Digest.String()
+	pinnedRef := ref.Context().Digest(digest)
+	artifact, err := remote.Image(pinnedRef, opts...)
+	if err != nil {
+		return "", "", fmt.Errorf("error pulling %s: %w", pinnedRef, err)
+	}
+	manifest, err := artifact.Manifest()
+	if err != nil {
+		return "", "", fmt.Errorf("error reading manifest for %s: %w", pinnedRef, err)
+	}
+	if len(manifest.Layers) == 0 {
+		return "", "", fmt.Errorf("artifact %s has no layers", pinnedRef)
+	}
+
+	targetDir, err := os.MkdirTemp(r.rootDir, "oci_")
+	if err != nil {
+		return "", "", err
+	}
+	sourceDir := filepath.Join(targetDir, "src")
+	if err := os.MkdirAll(sourceDir, 0o755); err != nil {
+		return "", "", err
+	}
+	for i, layerDesc := range manifest.Layers {
+		format, err := ociLayerFormat(layerDesc.MediaType, layerDesc.Annotations)
+		if err != nil {
+			return "", "", fmt.Errorf("artifact %s layer %s: %w", pinnedRef, layerDesc.Digest, err)
+		}
+		layer, err := artifact.LayerByDigest(layerDesc.Digest)
+		if err != nil {
+			return "", "", err
+		}
+		// The blob reader verifies the layer digest
+		blob, err := layer.Compressed()
+		if err != nil {
+			return "", "", fmt.Errorf("error pulling layer %s: %w", layerDesc.Digest, err)
+		}
+		if format == "" {
+			err = writeArchiveFile(sourceDir, layerDesc.Annotations[ociTitleAnnotation], blob)
+		} else {
+			err = extractOCILayer(blob, format, filepath.Join(targetDir, fmt.Sprintf("layer_%d%s", i, format)), sourceDir)
+		}
+		blob.Close() //nolint:errcheck
+		if err != nil {
+			return "", "", fmt.Errorf("error extracting layer %s: %w", layerDesc.Digest, err)
+		}
+	}
+	sourceDir, err = archiveRootDir(sourceDir)
+	if err != nil {
+		return "", "", err
+	}
+
+	r.server.Info().Str("url", sourceUrl).Str("digest", digest).Msg("Pulled OCI artifact source")
+	r.mu.Lock()
+	r.archiveCache[sourceUrl] = archiveDir{dir: sourceDir, sha: digest}
+	r.mu.Unlock()
+	return sourceDir, digest, nil
+}
+
+// ociLayerFormat returns the archive format for extracting the layer, empty if the layer is a
+// single file to be written using its title
+func ociLayerFormat(mediaType ggcrtypes.MediaType, annotations map[string]string) (string, error) {
+	title := annotations[ociTitleAnnotation]
+	switch mediaType {
+	case ggcrtypes.OCILayer, ggcrtypes.DockerLayer:
+		return ".tar.gz", nil
+	case ggcrtypes.OCIUncompressedLayer, ggcrtypes.DockerUncompressedLayer:
+		return ".tar", nil
+	}
+	if strings.HasSuffix(string(mediaType), "+zstd") || strings.HasSuffix(string(mediaType), ".zstd") {
+		return "", fmt.Errorf("zstd compressed layers are not supported")
+	}
+	if annotations[ociUnpackAnnotation] == "true" {
+		// oras packs directories as tar.gz, with a custom media type if one is specified in the push
+		return ".tar.gz", nil
+	}
+	if title == "" {
+		return "", fmt.Errorf("layer with media type %s has no %s annotation", mediaType, ociTitleAnnotation)
+	}
+	return "", nil
+}
+
+// extractOCILayer saves the layer blob to the layer file and extracts it into the source dir
+func extractOCILayer(blob io.Reader, format, layerFile, sourceDir string) error {
+	out, err := os.Create(layerFile)
+	if err != nil {
+		return err
+	}
+	written, err := io.Copy(out, io.LimitReader(blob, archiveMaxSize+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if written > archiveMaxSize {
+		return fmt.Errorf("layer is larger than the max size of %d bytes", archiveMaxSize)
+	}
+	if err := extractArchive(layerFile, format, sourceDir); err != nil {
+		return err
+	}
+	return os.Remove(layerFile)
+}
@@ -0,0 +1,42 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"testing"
+
+	ggcrtypes "github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func TestOCILayerFormat(t *testing.T) {
+	tests := []struct {
+		name        string
+		mediaType   ggcrtypes.MediaType
+		annotations map[string]string
+		want        string
+		wantErr     string
+	}{
+		{name: "oci layer", mediaType: ggcrtypes.OCILayer, want: ".tar.gz"},
+		{name: "docker layer", mediaType: ggcrtypes.DockerLayer, want: ".tar.gz"},
+		{name: "uncompressed layer", mediaType: ggcrtypes.OCIUncompressedLayer, want: ".tar"},
+		{name: "oras directory", mediaType: "application/vnd.example.app.v1.tar+gzip",
+			annotations: map[string]string{ociTitleAnnotation: "app", ociUnpackAnnotation: "true"}, want: ".tar.gz"},
+		{name: "oras file", mediaType: "application/vnd.example.config.v1",
+			annotations: map[string]string{ociTitleAnnotation: "app.star"}, want: ""},
+		{name: "zstd layer", mediaType: ggcrtypes.OCILayerZStd, wantErr: "zstd compressed layers are not supported"},
+		{name: "no title", mediaType: "application/vnd.example.config.v1", wantErr: "has no org.opencontainers.image.title annotation"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			format, err := ociLayerFormat(tc.mediaType, tc.annotations)
+			if tc.wantErr != "" {
+				testutil.AssertErrorContains(t, err, tc.wantErr)
+				return
+			}
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqualsString(t, "format", tc.want, format)
+		})
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	ocspFetchTimeout = 10 * time.Second
+	ocspRetryDelay   = 10 * time.Minute // delay before retrying a failed OCSP fetch
+	ocspMaxResponse  = 1 << 20
+)
+
+// ocspStapler staples OCSP responses to the certificates loaded from disk. Certmagic managed
+// certificates are stapled by certmagic. The responses are cached per certificate and are
+// refreshed in the background after half the response validity, the handshake is not blocked
+// on the OCSP server
+type ocspStapler struct {
+	*types.Logger
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	entries map[[32]byte]*ocspEntry
+	client  *http.Client
+	now     func() time.Time
+}
+
+type ocspEntry struct {
+	staple     []byte // nil till the first fetch succeeds
+	nextUpdate time.Time
+	refreshAt  time.Time
+	fetching   bool
+}
+
+func newOcspStapler(logger *types.Logger) *ocspStapler {
+	return &ocspStapler{
+		Logger:  logger,
+		entries: map[[32]byte]*ocspEntry{},
+		client:  &http.Client{Timeout: ocspFetchTimeout},
+		now:     time.Now,
+	}
+}
+
+// staple sets the cached OCSP response on the certificate, starting a fetch if required.
+// Certificates without an OCSP server or without the issuer in the chain, like self signed and
+// mkcert certificates, are not stapled
+func (o *ocspStapler) staple(cert *tls.Certificate) {
+	if len(cert.Certificate) < 2 {
+		return
+	}
+	leaf := cert.Leaf
+	if leaf == nil {
+		var err error
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return
+		}
+	}
+	if len(leaf.OCSPServer) == 0 {
+		return
+	}
+
+	key := sha256.Sum256(cert.Certificate[0])
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := o.now()
+	entry, ok := o.entries[key]
+	if !ok {
+		entry = &ocspEntry{}
+		o.entries[key] = entry
+	}
+	if !entry.fetching && !now.Before(entry.refreshAt) {
+		entry.fetching = true
+		o.wg.Add(1)
+		go o.refresh(entry, leaf, cert.Certificate[1])
+	}
+	if entry.staple != nil && now.Before(entry.nextUpdate) {
+		cert.OCSPStaple = entry.staple
+	}
+}
+
+// refresh fetches the OCSP response for the entry. On failure, the fetch is retried after a delay
+func (o *ocspStapler) refresh(entry *ocspEntry, leaf *x509.Certificate, issuerDer []byte) {
+	defer o.wg.Done()
+	staple, nextUpdate, err := o.fetch(leaf, issuerDer)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	now := o.now()
+	entry.fetching = false
+	if err != nil {
+		o.Warn().Err(err).Msgf("Error fetching OCSP response for %s", leaf.Subject.CommonName)
+		entry.refreshAt = now.Add(ocspRetryDelay)
+		return
+	}
+	entry.staple = staple
+	entry.nextUpdate = nextUpdate
+	entry.refreshAt = now.Add(max(nextUpdate.Sub(now)/2, ocspRetryDelay))
+}
+
+// fetch gets the OCSP response for the leaf certificate from its OCSP server. Only good
+// responses are returned
+func (o *ocspStapler) fetch(leaf *x509.Certificate, issuerDer []byte) ([]byte, time.Time, error) {
+	issuer, err := x509.ParseCertificate(issuerDer)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	request, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ocspFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, leaf.OCSPServer[0], bytes.NewReader(request))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	resp, err := o.client.Do(req)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode != http.StatusOK {
+		return nil, time.Time{}, fmt.Errorf("OCSP server returned status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, ocspMaxResponse))
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	parsed, err := ocsp.ParseResponseForCert(body, leaf, issuer)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if parsed.Status != ocsp.Good {
+		return nil, time.Time{}, fmt.Errorf("OCSP status is not good: %d", parsed.Status)
+	}
+	nextUpdate := parsed.NextUpdate
+	if nextUpdate.IsZero() {
+		nextUpdate = o.now().Add(2 * ocspRetryDelay)
+	}
+	return body, nextUpdate, nil
+}
@@ -0,0 +1,126 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"golang.org/x/crypto/ocsp"
+)
+
+// testOcspCert returns a certificate chain with a leaf issued by a test CA, and an OCSP responder
+// signed by the CA
+func testOcspCert(t *testing.T, status *atomic.Int32, calls *atomic.Int32) (*tls.Certificate, *httptest.Server) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testutil.AssertNoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	caDer, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	testutil.AssertNoError(t, err)
+	caCert, err := x509.ParseCertificate(caDer)
+	testutil.AssertNoError(t, err)
+
+	responder := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		body, _ := io.ReadAll(r.Body)
+		req, err := ocsp.ParseRequest(body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resp, err := ocsp.CreateResponse(caCert, caCert, ocsp.Response{
+			Status:       int(status.Load()),
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Minute),
+			NextUpdate:   time.Now().Add(4 * time.Hour),
+		}, caKey)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write(resp) //nolint:errcheck
+	}))
+
+	leafKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testutil.AssertNoError(t, err)
+	leafDer, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		OCSPServer:   []string{responder.URL},
+	}, caCert, &leafKey.PublicKey, caKey)
+	testutil.AssertNoError(t, err)
+	return &tls.Certificate{Certificate: [][]byte{leafDer, caDer}, PrivateKey: crypto.Signer(leafKey)}, responder
+}
+
+func TestOcspStapler(t *testing.T) {
+	var status, calls atomic.Int32
+	status.Store(ocsp.Good)
+	cert, responder := testOcspCert(t, &status, &calls)
+	defer responder.Close()
+
+	now := time.Now()
+	stapler := newOcspStapler(testutil.TestLogger())
+	stapler.now = func() time.Time { return now }
+
+	// The first handshake starts the fetch without waiting for it
+	first := *cert
+	stapler.staple(&first)
+	stapler.wg.Wait()
+	testutil.AssertEqualsInt(t, "calls", 1, int(calls.Load()))
+
+	second := *cert
+	stapler.staple(&second)
+	stapler.wg.Wait()
+	testutil.AssertEqualsBool(t, "stapled", true, len(second.OCSPStaple) > 0)
+	testutil.AssertEqualsInt(t, "cached", 1, int(calls.Load()))
+	resp, err := ocsp.ParseResponse(second.OCSPStaple, nil)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "status", ocsp.Good, resp.Status)
+
+	// Refreshed after half the validity, the old staple is used till the refresh fails
+	now = now.Add(3 * time.Hour)
+	status.Store(ocsp.Revoked)
+	third := *cert
+	stapler.staple(&third)
+	stapler.wg.Wait()
+	testutil.AssertEqualsInt(t, "refreshed", 2, int(calls.Load()))
+	testutil.AssertEqualsBool(t, "old staple", true, len(third.OCSPStaple) > 0)
+
+	// Expired staples are not used
+	now = now.Add(2 * time.Hour)
+	fourth := *cert
+	stapler.staple(&fourth)
+	stapler.wg.Wait()
+	testutil.AssertEqualsBool(t, "expired", true, fourth.OCSPStaple == nil)
+
+	// Self signed certificates are not stapled
+	selfSigned := tls.Certificate{Certificate: [][]byte{cert.Certificate[1]}}
+	stapler.staple(&selfSigned)
+	stapler.wg.Wait()
+	testutil.AssertEqualsInt(t, "self signed", 3, int(calls.Load()))
+}
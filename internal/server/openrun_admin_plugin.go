@@ -130,15 +130,15 @@ func (c *openrunAdminPlugin) DeleteApps(thread *starlark.Thread, builtin *starla
 // ReloadApps reloads apps matching the glob from their source (git or disk)
 func (c *openrunAdminPlugin) ReloadApps(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var pathGlob starlark.String
-	var dryRun, forceReload starlark.Bool
+	var dryRun, forceReload, confirm starlark.Bool
 	approve := starlark.Bool(true)
 	promote := starlark.Bool(true)
 	if err := starlark.UnpackArgs("reload_apps", args, kwargs, "path_glob", &pathGlob,
-		"approve?", &approve, "promote?", &promote, "force_reload?", &forceReload, "dry_run?", &dryRun); err != nil {
+		"approve?", &approve, "promote?", &promote, "force_reload?", &forceReload, "dry_run?", &dryRun, "confirm?", &confirm); err != nil {
 		return nil, err
 	}
 
-	result, err := c.server.ReloadApps(system.GetRequestContext(thread), pathGlob.GoString(), bool(approve), bool(dryRun), bool(promote),
+	result, err := c.server.ReloadApps(withPromoteState(system.GetRequestContext(thread), bool(confirm)), pathGlob.GoString(), bool(approve), bool(dryRun), bool(promote),
 		"", "", "", bool(forceReload), false)
 	if err != nil {
 		return nil, err
@@ -150,13 +150,14 @@ func (c *openrunAdminPlugin) ReloadApps(thread *starlark.Thread, builtin *starla
 // glob. With dry_run, the pending permissions are returned without approving
 func (c *openrunAdminPlugin) ApproveApps(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var pathGlob starlark.String
-	var dryRun starlark.Bool
+	var dryRun, confirm starlark.Bool
 	promote := starlark.Bool(true)
-	if err := starlark.UnpackArgs("approve_apps", args, kwargs, "path_glob", &pathGlob, "promote?", &promote, "dry_run?", &dryRun); err != nil {
+	if err := starlark.UnpackArgs("approve_apps", args, kwargs, "path_glob", &pathGlob, "promote?", &promote, "dry_run?", &dryRun,
+		"confirm?", &confirm); err != nil {
 		return nil, err
 	}
 
-	result, err := c.server.ApproveApps(system.GetRequestContext(thread), pathGlob.GoString(), bool(dryRun), bool(promote))
+	result, err := c.server.ApproveApps(withPromoteState(system.GetRequestContext(thread), bool(confirm)), pathGlob.GoString(), bool(dryRun), bool(promote))
 	if err != nil {
 		return nil, err
 	}
@@ -168,10 +169,10 @@ func (c *openrunAdminPlugin) ApproveApps(thread *starlark.Thread, builtin *starl
 func (c *openrunAdminPlugin) UpdateParams(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var pathGlob starlark.String
 	var params *starlark.Dict
-	var dryRun starlark.Bool
+	var dryRun, confirm starlark.Bool
 	promote := starlark.Bool(true)
 	if err := starlark.UnpackArgs("update_params", args, kwargs, "path_glob", &pathGlob, "params", &params,
-		"promote?", &promote, "dry_run?", &dryRun); err != nil {
+		"promote?", &promote, "dry_run?", &dryRun, "confirm?", &confirm); err != nil {
 		return nil, err
 	}
 
@@ -180,7 +181,7 @@ func (c *openrunAdminPlugin) UpdateParams(thread *starlark.Thread, builtin *star
 		return nil, err
 	}
 
-	result, err := c.server.ReplaceAppParams(system.GetRequestContext(thread), pathGlob.GoString(), bool(dryRun), bool(promote), paramValues)
+	result, err := c.server.ReplaceAppParams(withPromoteState(system.GetRequestContext(thread), bool(confirm)), pathGlob.GoString(), bool(dryRun), bool(promote), paramValues)
 	if err != nil {
 		return nil, err
 	}
@@ -207,12 +208,13 @@ func (c *openrunAdminPlugin) SwitchVersion(thread *starlark.Thread, builtin *sta
 // PromoteApps promotes staged changes to prod for apps matching the glob
 func (c *openrunAdminPlugin) PromoteApps(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var pathGlob starlark.String
-	var dryRun starlark.Bool
-	if err := starlark.UnpackArgs("promote_apps", args, kwargs, "path_glob", &pathGlob, "dry_run?", &dryRun); err != nil {
+	var dryRun, contractCheck, confirm starlark.Bool
+	if err := starlark.UnpackArgs("promote_apps", args, kwargs, "path_glob", &pathGlob, "dry_run?", &dryRun, "contract_check?", &contractCheck,
+		"confirm?", &confirm); err != nil {
 		return nil, err
 	}
 
-	result, err := c.server.PromoteApps(system.GetRequestContext(thread), pathGlob.GoString(), bool(dryRun))
+	result, err := c.server.PromoteApps(system.GetRequestContext(thread), pathGlob.GoString(), bool(dryRun), bool(contractCheck), bool(confirm))
 	if err != nil {
 		return nil, err
 	}
@@ -230,7 +232,7 @@ func (c *openrunAdminPlugin) UpdateAuth(thread *starlark.Thread, builtin *starla
 	updateRequest := types.CreateUpdateAppRequest()
 	updateRequest.AuthnType = types.StringValue(auth.GoString())
 
-	result, err := c.server.UpdateAppSettings(system.GetRequestContext(thread), pathGlob.GoString(), bool(dryRun), updateRequest)
+	result, err := c.server.UpdateAppSettings(system.GetRequestContext(thread), pathGlob.GoString(), bool(dryRun), -1, updateRequest)
 	if err != nil {
 		return nil, err
 	}
@@ -245,10 +247,10 @@ func (c *openrunAdminPlugin) UpdateAuth(thread *starlark.Thread, builtin *starla
 func (c *openrunAdminPlugin) UpdateBindings(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var pathGlob starlark.String
 	var bindings *starlark.List
-	var dryRun starlark.Bool
+	var dryRun, confirm starlark.Bool
 	promote := starlark.Bool(true)
 	if err := starlark.UnpackArgs("update_bindings", args, kwargs, "path_glob", &pathGlob, "bindings", &bindings,
-		"promote?", &promote, "dry_run?", &dryRun); err != nil {
+		"promote?", &promote, "dry_run?", &dryRun, "confirm?", &confirm); err != nil {
 		return nil, err
 	}
 
@@ -265,8 +267,8 @@ func (c *openrunAdminPlugin) UpdateBindings(thread *starlark.Thread, builtin *st
 		"metadata": updateMetadata,
 		"dryRun":   bool(dryRun),
 	}
-	result, err := c.server.StagedUpdate(system.GetRequestContext(thread), pathGlob.GoString(), bool(dryRun), bool(promote),
-		c.server.updateMetadataHandler, metadataArgs, "update_metadata")
+	result, err := c.server.StagedUpdate(withPromoteState(system.GetRequestContext(thread), bool(confirm)), pathGlob.GoString(),
+		bool(dryRun), bool(promote), c.server.updateMetadataHandler, metadataArgs, "update_metadata")
 	if err != nil {
 		return nil, err
 	}
@@ -569,11 +571,12 @@ func (c *openrunAdminPlugin) RekeySecrets(thread *starlark.Thread, builtin *star
 }
 
 func (c *openrunAdminPlugin) CreateSync(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
-	var path, gitBranch, gitAuth starlark.String
+	var path, gitBranch, gitAuth, cron, timezone starlark.String
 	var dryRun, promote, approve starlark.Bool
 	var minutes starlark.Int
 	if err := starlark.UnpackArgs("create_sync", args, kwargs, "path", &path, "git_branch?", &gitBranch,
-		"git_auth?", &gitAuth, "minutes?", &minutes, "dry_run?", &dryRun, "promote?", &promote, "approve?", &approve); err != nil {
+		"git_auth?", &gitAuth, "minutes?", &minutes, "dry_run?", &dryRun, "promote?", &promote, "approve?", &approve,
+		"cron?", &cron, "timezone?", &timezone); err != nil {
 		return nil, err
 	}
 
@@ -588,6 +591,8 @@ func (c *openrunAdminPlugin) CreateSync(thread *starlark.Thread, builtin *starla
 		Promote:           bool(promote),
 		Approve:           bool(approve),
 		ScheduleFrequency: int(minutesInt),
+		ScheduleCron:      cron.GoString(),
+		ScheduleTimezone:  timezone.GoString(),
 	}
 
 	createResponse, err := c.server.CreateSyncEntry(system.GetRequestContext(thread), path.GoString(), true, bool(dryRun), &sync)
@@ -189,6 +189,86 @@ func (c *openrunPlugin) GetConfigValues(thread *starlark.Thread, builtin *starla
 	return starlark_type.ConvertToStarlark(ret)
 }
 
+// GetConfigSchema returns the dynamically settable sections with their
+// fields (key, type, secret), for building config forms
+func (c *openrunPlugin) GetConfigSchema(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	if err := starlark.UnpackArgs("get_config_schema", args, kwargs); err != nil {
+		return nil, err
+	}
+
+	schema, err := c.server.GetConfigSchema(system.GetRequestContext(thread))
+	if err != nil {
+		return nil, err
+	}
+	sectionFields := func(sections map[string][]ConfigField) map[string]any {
+		ret := map[string]any{}
+		for section, fields := range sections {
+			list := make([]any, 0, len(fields))
+			for _, field := range fields {
+				list = append(list, map[string]any{"key": field.Key, "type": field.Type, "secret": field.Secret})
+			}
+			ret[section] = list
+		}
+		return ret
+	}
+	return starlark_type.ConvertToStarlark(map[string]any{
+		"settings": sectionFields(schema.Settings),
+		"entries":  sectionFields(schema.Entries),
+	})
+}
+
+// ValidateConfigValue checks a settings field change without saving it.
+// Returns {valid, error}, an invalid value is not a call failure
+func (c *openrunPlugin) ValidateConfigValue(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var section, key starlark.String
+	var value starlark.Value
+	if err := starlark.UnpackArgs("validate_config_value", args, kwargs, "section", &section,
+		"key", &key, "value", &value); err != nil {
+		return nil, err
+	}
+
+	goValue, err := starlark_type.UnmarshalStarlark(value)
+	if err != nil {
+		return nil, err
+	}
+	return validationResult(c.server.ValidateConfigValue(system.GetRequestContext(thread),
+		section.GoString(), key.GoString(), goValue))
+}
+
+// ValidateConfigEntry checks an entry change without saving it. Returns
+// {valid, error}
+func (c *openrunPlugin) ValidateConfigEntry(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var section, name starlark.String
+	var values *starlark.Dict
+	if err := starlark.UnpackArgs("validate_config_entry", args, kwargs, "section", &section,
+		"name", &name, "values", &values); err != nil {
+		return nil, err
+	}
+
+	goValues, err := starlark_type.UnmarshalStarlark(values)
+	if err != nil {
+		return nil, err
+	}
+	valuesMap, ok := goValues.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("values must be a dict with string keys")
+	}
+	return validationResult(c.server.ValidateConfigEntry(system.GetRequestContext(thread),
+		section.GoString(), name.GoString(), valuesMap))
+}
+
+// validationResult returns the {valid, error} result for the validate calls.
+// Permission failures are returned as call errors
+func validationResult(err error) (starlark.Value, error) {
+	if err != nil {
+		if _, ok := err.(types.RequestError); ok {
+			return nil, err
+		}
+		return starlark_type.ConvertToStarlark(map[string]any{"valid": false, "error": err.Error()})
+	}
+	return starlark_type.ConvertToStarlark(map[string]any{"valid": true, "error": ""})
+}
+
 // SetConfigValue sets one dynamic config field (section + dotted key). The
 // change is validated against the config schema and takes effect immediately
 // (settings updates are not staged, unlike RBAC). version_id is the CAS token
@@ -33,6 +33,7 @@ func initOpenRunPlugin(server *Server) {
 		app.CreatePluginApiName(c.AnalyticsSummary, app.READ, "analytics_summary"),
 		app.CreatePluginApiName(c.ListOperations, app.READ, "list_operations"),
 		app.CreatePluginApiName(c.ListSync, app.READ, "list_sync"),
+		app.CreatePluginApiName(c.SyncHistory, app.READ, "sync_history"),
 		app.CreatePluginApiName(c.ListBindings, app.READ, "list_bindings"),
 		app.CreatePluginApiName(c.GetApp, app.READ, "get_app"),
 		app.CreatePluginApiName(c.ListSpecs, app.READ, "list_specs"),
@@ -44,6 +45,9 @@ func initOpenRunPlugin(server *Server) {
 		app.CreatePluginApiName(c.GetRBACConfig, app.READ, "get_rbac_config"),
 		app.CreatePluginApiName(c.GetConfigEntries, app.READ, "get_config_entries"),
 		app.CreatePluginApiName(c.GetConfigValues, app.READ, "get_config_values"),
+		app.CreatePluginApiName(c.GetConfigSchema, app.READ, "get_config_schema"),
+		app.CreatePluginApiName(c.ValidateConfigValue, app.READ, "validate_config_value"),
+		app.CreatePluginApiName(c.ValidateConfigEntry, app.READ, "validate_config_entry"),
 		app.CreatePluginApiName(c.ListConfigHistory, app.READ, "list_config_history"),
 		app.CreatePluginApiName(c.GetConfigVersion, app.READ, "get_config_version"),
 		app.CreatePluginApiName(c.ListContainers, app.READ, "list_containers"),
@@ -167,6 +171,7 @@ func (c *openrunPlugin) listAppsImpl(thread *starlark.Thread, _ *starlark.Builti
 		// it would be a filtering bypass
 		permCheck = true
 	}
+	searchTerms := parseAppSearchQuery(query.GoString())
 	ret := starlark.List{}
 	//nolint:errcheck
 	for _, app := range apps {
@@ -188,15 +193,9 @@ func (c *openrunPlugin) listAppsImpl(thread *starlark.Thread, _ *starlark.Builti
 		// For stage/preview apps, glob matching is done against the main app path
 		mainPathDomain := mainAppPathDomain(app.AppPathDomain, app.MainApp, app.LinkedAppPath)
 
-		// Check query filter
-		if query != "" {
-			queryStr := strings.ToLower(query.GoString())
-			if !strings.Contains(strings.ToLower(app.Name), queryStr) &&
-				!strings.Contains(strings.ToLower(app.String()), queryStr) &&
-				!strings.Contains(strings.ToLower(app.SourceUrl), queryStr) &&
-				!strings.Contains(strings.ToLower(app.UserID), queryStr) {
-				continue
-			}
+		// Check query filter, using the same matching as the app search API
+		if len(searchTerms) > 0 && appSearchScore(&app, searchTerms) == 0 {
+			continue
 		}
 
 		if path != "" {
@@ -353,196 +352,60 @@ func (c *openrunPlugin) ListAuditEvents(thread *starlark.Thread, builtin *starla
 		return nil, err
 	}
 
-	// audit:read grants access to the audit log across all apps
-	if err := c.server.enforceGlobalPerm(system.GetRequestContext(thread), types.PermissionAuditRead, ""); err != nil {
-		return nil, err
-	}
-
-	var query strings.Builder
-	query.WriteString("select rid, app_id, create_time, user_id, event_type, operation, target, status, detail from audit ")
-
-	filterConditions := []string{}
-	appGlobStr := strings.TrimSpace(appGlob.GoString())
-	if appGlobStr != "" {
-		appInfo, err := c.server.ParseGlob(appGlobStr)
-		if err != nil {
-			return nil, err
-		}
-		appIds := []string{}
-		for _, app := range appInfo {
-			appIds = append(appIds, "'"+string(app.Id)+"'")
-		}
-
-		filterConditions = append(filterConditions, fmt.Sprintf("app_id in (%s)", strings.Join(appIds, ",")))
-	}
-
-	queryParams := []any{}
-	userIdStr := strings.TrimSpace(userId.GoString())
-	if userIdStr != "" {
-		filterConditions = append(filterConditions, "user_id = ?")
-		queryParams = append(queryParams, userIdStr)
-	}
-
-	eventTypeStr := strings.TrimSpace(eventType.GoString())
-	if eventTypeStr != "" {
-		filterConditions = append(filterConditions, "event_type = ?")
-		queryParams = append(queryParams, eventTypeStr)
-	}
-
-	operationStr := strings.TrimSpace(operation.GoString())
-	if operationStr != "" {
-		opList, opQuery := getOpList(operationStr)
-		filterConditions = append(filterConditions, "operation in ("+opQuery+")")
-		queryParams = append(queryParams, opList...)
-	}
-
-	targetStr := strings.TrimSpace(target.GoString())
-	if targetStr != "" {
-		filterConditions = append(filterConditions, "target = ?")
-		queryParams = append(queryParams, targetStr)
+	auditQuery := types.AuditQuery{
+		AppGlob:   appGlob.GoString(),
+		UserId:    userId.GoString(),
+		EventType: eventType.GoString(),
+		Operation: operation.GoString(),
+		Target:    target.GoString(),
+		Status:    status.GoString(),
+		Rid:       rid.GoString(),
+		Detail:    detail.GoString(),
 	}
-
-	statusStr := strings.TrimSpace(status.GoString())
-	if statusStr != "" {
-		filterConditions = append(filterConditions, "status = ?")
-		queryParams = append(queryParams, statusStr)
-	}
-
-	startDateStr := strings.TrimSpace(startDate.GoString())
-	if startDateStr != "" {
-		if c.server.auditDbType == system.DB_TYPE_SQLITE {
-			filterConditions = append(filterConditions, `create_time >= strftime('%s', ?) * 1000000000`)
-		} else {
-			// Postgres
-			filterConditions = append(filterConditions, `create_time >= EXTRACT(EPOCH FROM  ?::timestamp)::bigint * 1000000000`)
-		}
-		queryParams = append(queryParams, startDateStr)
-	}
-
-	endDateStr := strings.TrimSpace(endDate.GoString())
-	if endDateStr != "" {
-		if c.server.auditDbType == system.DB_TYPE_SQLITE {
-			filterConditions = append(filterConditions, `create_time <= (strftime('%s', ?) + 86400) * 1000000000`)
-		} else {
-			// Postgres
-			filterConditions = append(filterConditions, `create_time <= (EXTRACT(EPOCH FROM  ?::timestamp)::bigint + 86400) * 1000000000`)
-		}
-		queryParams = append(queryParams, endDateStr)
-	}
-
-	ridStr := strings.TrimSpace(rid.GoString())
-	if ridStr != "" {
-		filterConditions = append(filterConditions, "rid = ?")
-		queryParams = append(queryParams, ridStr)
+	var err error
+	if auditQuery.Start, err = parseAuditTime(startDate.GoString(), false); err != nil {
+		return nil, err
 	}
-
-	detailStr := strings.TrimSpace(detail.GoString())
-	if detailStr != "" {
-		filterConditions = append(filterConditions, "detail like ?")
-		queryParams = append(queryParams, detailStr)
+	if auditQuery.End, err = parseAuditTime(endDate.GoString(), true); err != nil {
+		return nil, err
 	}
-
-	beforeTimestampStr := strings.TrimSpace(beforeTimestamp.GoString())
-	if beforeTimestampStr != "" {
-		filterConditions = append(filterConditions, " create_time < ?")
-		bt, err := strconv.ParseInt(beforeTimestampStr, 10, 64)
-		if err != nil {
-			return nil, fmt.Errorf("before_timestamp has to be a valid in value in milliseconds")
+	if beforeTimestampStr := strings.TrimSpace(beforeTimestamp.GoString()); beforeTimestampStr != "" {
+		if auditQuery.Before, err = strconv.ParseInt(beforeTimestampStr, 10, 64); err != nil {
+			return nil, fmt.Errorf("before_timestamp has to be a valid int value, the create_time_epoch of the last event")
 		}
-		queryParams = append(queryParams, bt)
-	}
-
-	if len(filterConditions) > 0 {
-		query.WriteString(" where ")
-		query.WriteString(strings.Join(filterConditions, " and "))
-	}
-
-	query.WriteString(" order by create_time desc")
-
-	limitVal, _ := limit.Int64()
-	if limitVal <= 0 || limitVal > 10_000 {
-		return nil, fmt.Errorf("limit has to be between 1 and 10000")
 	}
-	query.WriteString(" limit ?")
-	queryParams = append(queryParams, limitVal)
-
-	// Ensure previously queued audit events are visible to the query
-	c.server.FlushAuditEvents()
-	rows, err := c.server.auditDB.Query(system.RebindQuery(c.server.auditDbType, query.String()), queryParams...)
-	if err != nil {
-		return nil, err
+	limitVal, ok := limit.Int64()
+	if !ok || limitVal <= 0 || limitVal > maxAuditListLimit {
+		return nil, fmt.Errorf("limit has to be between 1 and %d", maxAuditListLimit)
 	}
-	defer rows.Close() //nolint:errcheck
+	auditQuery.Limit = int(limitVal)
 
-	apps, err := c.server.apps.GetAllAppsInfo()
+	response, err := c.server.ListAuditEvents(system.GetRequestContext(thread), auditQuery)
 	if err != nil {
 		return nil, err
 	}
-	appIdMap := map[types.AppId]types.AppInfo{}
-	for _, app := range apps {
-		appIdMap[app.Id] = app
-	}
 
 	ret := starlark.List{}
 	//nolint:errcheck
-	for rows.Next() {
-		var rid, appId, userId, eventType, operation, target, status, detail string
-		var createTime int64
-		err := rows.Scan(&rid, &appId, &createTime, &userId, &eventType, &operation, &target, &status, &detail)
-		if err != nil {
-			return nil, err
-		}
-
-		utcTime := time.Unix(0, createTime).UTC()
-
+	for _, event := range response.Events {
 		v := starlark.Dict{}
-		v.SetKey(starlark.String("rid"), starlark.String(rid))
-		v.SetKey(starlark.String("app_id"), starlark.String(appId))
-		appEnv := ""
-		switch {
-		case strings.HasPrefix(appId, types.ID_PREFIX_APP_PROD):
-			appEnv = "prod"
-		case strings.HasPrefix(appId, types.ID_PREFIX_APP_STAGE):
-			appEnv = "stage"
-		case strings.HasPrefix(appId, types.ID_PREFIX_APP_PREVIEW):
-			appEnv = "preview"
-		case strings.HasPrefix(appId, types.ID_PREFIX_APP_DEV):
-			appEnv = "dev"
-		}
-		if appInfo, ok := appIdMap[types.AppId(appId)]; ok {
-			// Staging events resolve to the main app, so links go to the
-			// prod app's detail page
-			if appEnv == "stage" && appInfo.MainApp != "" {
-				if mainInfo, ok := appIdMap[appInfo.MainApp]; ok {
-					appInfo = mainInfo
-				}
-			}
-			v.SetKey(starlark.String("app_name"), starlark.String(appInfo.Name))
-			v.SetKey(starlark.String("app_path"), starlark.String(appInfo.String()))
-		} else {
-			v.SetKey(starlark.String("app_name"), starlark.String(appId))
-			v.SetKey(starlark.String("app_path"), starlark.String(""))
-		}
-		v.SetKey(starlark.String("app_env"), starlark.String(appEnv))
-		v.SetKey(starlark.String("create_time_epoch"), starlark.String(strconv.FormatInt(createTime, 10)))
-		v.SetKey(starlark.String("create_time"), starlark.String(utcTime.Format("2006-01-02T15:04:05.999Z")))
-		v.SetKey(starlark.String("user_id"), starlark.String(userId))
-		v.SetKey(starlark.String("event_type"), starlark.String(eventType))
-		v.SetKey(starlark.String("operation"), starlark.String(operation))
-		v.SetKey(starlark.String("target"), starlark.String(target))
-		v.SetKey(starlark.String("status"), starlark.String(status))
-		v.SetKey(starlark.String("detail"), starlark.String(detail))
+		v.SetKey(starlark.String("rid"), starlark.String(event.Rid))
+		v.SetKey(starlark.String("app_id"), starlark.String(event.AppId))
+		v.SetKey(starlark.String("app_name"), starlark.String(event.AppName))
+		v.SetKey(starlark.String("app_path"), starlark.String(event.AppPath))
+		v.SetKey(starlark.String("app_env"), starlark.String(event.AppEnv))
+		v.SetKey(starlark.String("create_time_epoch"), starlark.String(strconv.FormatInt(event.CreateTimeEpoch, 10)))
+		v.SetKey(starlark.String("create_time"), starlark.String(event.CreateTime.Format("2006-01-02T15:04:05.999Z")))
+		v.SetKey(starlark.String("user_id"), starlark.String(event.UserId))
+		v.SetKey(starlark.String("event_type"), starlark.String(event.EventType))
+		v.SetKey(starlark.String("operation"), starlark.String(event.Operation))
+		v.SetKey(starlark.String("target"), starlark.String(event.Target))
+		v.SetKey(starlark.String("status"), starlark.String(event.Status))
+		v.SetKey(starlark.String("detail"), starlark.String(event.Detail))
 
 		ret.Append(&v)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating rows: %w", err)
-	}
-	if closeErr := rows.Close(); closeErr != nil {
-		return nil, fmt.Errorf("error closing rows: %w", closeErr)
-	}
-
 	return &ret, nil
 }
 
@@ -697,6 +560,7 @@ func (c *openrunPlugin) GetApp(thread *starlark.Thread, builtin *starlark.Builti
 	v.SetKey(starlark.String("spec"), starlark.String(entry.Metadata.Spec))                                   //nolint:errcheck
 	v.SetKey(starlark.String("git_branch"), starlark.String(entry.Metadata.VersionMetadata.GitBranch))        //nolint:errcheck
 	v.SetKey(starlark.String("git_commit"), starlark.String(entry.Metadata.VersionMetadata.GitCommit))        //nolint:errcheck
+	v.SetKey(starlark.String("git_tag"), starlark.String(entry.Metadata.VersionMetadata.GitResolvedTag))      //nolint:errcheck
 	v.SetKey(starlark.String("git_message"), starlark.String(entry.Metadata.VersionMetadata.GitMessage))      //nolint:errcheck
 	v.SetKey(starlark.String("git_auth"), starlark.String(entry.Metadata.GitAuthName))                        //nolint:errcheck
 	v.SetKey(starlark.String("version"), starlark.MakeInt(entry.Metadata.VersionMetadata.Version))            //nolint:errcheck
@@ -730,6 +594,30 @@ func (c *openrunPlugin) GetApp(thread *starlark.Thread, builtin *starlark.Builti
 
 // ListVersions returns the versions for the app at the given path. Use the
 // _cl_stage path suffix for the staging app's versions
+// SyncHistory returns one page of the runs of a sync entry, latest first
+func (c *openrunPlugin) SyncHistory(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var id starlark.String
+	offset := starlark.MakeInt(0)
+	limit := starlark.MakeInt(defaultSyncHistoryLimit)
+	if err := starlark.UnpackArgs("sync_history", args, kwargs, "id", &id, "offset?", &offset, "limit?", &limit); err != nil {
+		return nil, err
+	}
+	offsetValue, ok := offset.Int64()
+	if !ok {
+		return nil, fmt.Errorf("sync_history: invalid offset")
+	}
+	limitValue, ok := limit.Int64()
+	if !ok {
+		return nil, fmt.Errorf("sync_history: invalid limit")
+	}
+
+	result, err := c.server.GetSyncHistory(system.GetRequestContext(thread), id.GoString(), int(offsetValue), int(limitValue))
+	if err != nil {
+		return nil, err
+	}
+	return starlark_type.ConvertToStarlark(result)
+}
+
 func (c *openrunPlugin) ListVersions(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var path starlark.String
 	if err := starlark.UnpackArgs("list_versions", args, kwargs, "path", &path); err != nil {
@@ -0,0 +1,57 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// ProfileStart starts profiling the handlers of the app on this server node. Profiling needs
+// app:manage, like traffic capture
+func (s *Server) ProfileStart(ctx context.Context, appPath string, opts app.ProfileOptions) (*types.ProfileStatus, error) {
+	appEntry, err := s.getCaptureAppEntry(ctx, appPath)
+	if err != nil {
+		return nil, err
+	}
+
+	status := s.profiles.Start(appEntry.Id, opts)
+	status.AppPath = appEntry.AppPathDomain().String()
+	s.Info().Str("app", status.AppPath).Float64("sample_rate", status.SampleRate).Msgf("Started handler profiling till %s", status.EndTime)
+	return &status, nil
+}
+
+// ProfileStop stops profiling the app. If clearProfiles is set, the recorded profiles are discarded
+func (s *Server) ProfileStop(ctx context.Context, appPath string, clearProfiles bool) (*types.ProfileStatus, error) {
+	appEntry, err := s.getCaptureAppEntry(ctx, appPath)
+	if err != nil {
+		return nil, err
+	}
+
+	status, ok := s.profiles.Stop(appEntry.Id, clearProfiles)
+	if !ok {
+		return nil, types.CreateRequestError(fmt.Sprintf("no profile found for app %s", appPath), http.StatusNotFound)
+	}
+	status.AppPath = appEntry.AppPathDomain().String()
+	return &status, nil
+}
+
+// ProfileDownload returns the profiles recorded for the app, slowest first
+func (s *Server) ProfileDownload(ctx context.Context, appPath string) (*types.ProfileDownloadResponse, error) {
+	appEntry, err := s.getCaptureAppEntry(ctx, appPath)
+	if err != nil {
+		return nil, err
+	}
+
+	status, profiles, ok := s.profiles.Profiles(appEntry.Id)
+	if !ok {
+		return nil, types.CreateRequestError(fmt.Sprintf("no profile found for app %s", appPath), http.StatusNotFound)
+	}
+	status.AppPath = appEntry.AppPathDomain().String()
+	return &types.ProfileDownloadResponse{Status: status, Merged: app.MergeProfiles(profiles), Profiles: profiles}, nil
+}
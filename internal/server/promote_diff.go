@@ -0,0 +1,378 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/openrundev/openrun/internal/metadata"
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	PROMOTE_ADD    = "+"
+	PROMOTE_DELETE = "-"
+	PROMOTE_CHANGE = "~"
+)
+
+// confirmKinds are the change kinds for which promote requires a confirmation, since they change
+// what the prod app is allowed to do
+var confirmKinds = map[string]bool{
+	"permissions":       true,
+	"env":               true,
+	"container_options": true,
+	"container_args":    true,
+	"container_volumes": true,
+}
+
+// promoteStateCtxKey carries the operation's promoteState in the context
+type promoteStateCtxKey struct{}
+
+// promoteState is the promote confirmation for the operation. The diffs of the prod apps promoted
+// by the operation are recorded on it and added to the audit log once the transaction commits
+type promoteState struct {
+	confirmed bool
+	op        *promoteOp
+}
+
+// promoteOp is shared by the promote states of an operation
+type promoteOp struct {
+	mu      sync.Mutex
+	entries []promoteAuditEntry
+	// pending has the change kinds needing a confirmation which the stage app had before the
+	// operation updated it, for the prod apps checked by checkPendingPromote
+	pending map[types.AppId]map[string]bool
+}
+
+type promoteAuditEntry struct {
+	prodApp *types.AppEntry
+	diff    *types.AppPromoteDiff
+}
+
+// withPromoteState returns a context carrying the promote state for the operation. A state set
+// by the caller is reused, confirm marks the promote changes as confirmed. The audit entries are
+// shared with the caller's state, so they are written by the transaction owner
+func withPromoteState(ctx context.Context, confirm bool) context.Context {
+	parent, _ := ctx.Value(promoteStateCtxKey{}).(*promoteState)
+	if parent != nil {
+		if !confirm || parent.confirmed {
+			return ctx
+		}
+		return context.WithValue(ctx, promoteStateCtxKey{}, &promoteState{confirmed: true, op: parent.op})
+	}
+	return context.WithValue(ctx, promoteStateCtxKey{}, &promoteState{confirmed: confirm, op: &promoteOp{}})
+}
+
+// newAppPromoteContext returns a context for the initial promote of a new app. The new prod app has
+// no earlier settings to protect, so the promote is confirmed. The diff is not added to the audit
+// log, the create is audited
+func newAppPromoteContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, promoteStateCtxKey{}, &promoteState{confirmed: true, op: &promoteOp{}})
+}
+
+// isPromoteConfirmed returns true if the promote changes which need a confirmation are confirmed
+// for the operation
+func isPromoteConfirmed(ctx context.Context) bool {
+	state, _ := ctx.Value(promoteStateCtxKey{}).(*promoteState)
+	return state != nil && state.confirmed
+}
+
+// checkPendingPromote is called by the operations which update the stage app and then promote it,
+// before the stage app is updated. The changes made by the operation are explicit (an update command,
+// the apply file or the --approve flag), so they do not need a separate confirmation. Only the stage
+// changes which were pending before the operation do. With approve, the operation approves the stage
+// permissions, so the permission changes are not pending. The first check done for the prod app in
+// the operation is used, apply checks before its reload updates the stage app
+func checkPendingPromote(ctx context.Context, stageApp, prodApp *types.AppEntry, approve bool) {
+	state, _ := ctx.Value(promoteStateCtxKey{}).(*promoteState)
+	if state == nil {
+		return
+	}
+	state.op.mu.Lock()
+	defer state.op.mu.Unlock()
+	if _, ok := state.op.pending[prodApp.Id]; ok {
+		return
+	}
+	pending := map[string]bool{}
+	for _, change := range diffAppMetadata(&stageApp.Metadata, &prodApp.Metadata) {
+		if confirmKinds[change.Kind] && (!approve || change.Kind != "permissions") {
+			pending[change.Kind] = true
+		}
+	}
+	if state.op.pending == nil {
+		state.op.pending = map[types.AppId]map[string]bool{}
+	}
+	state.op.pending[prodApp.Id] = pending
+}
+
+// declarePromoteKinds marks the change kinds as not pending for the prod app. Used by apply for the
+// stage settings which match the apply file, the promote makes the prod app match the declared config
+func declarePromoteKinds(ctx context.Context, prodAppId types.AppId, kinds ...string) {
+	state, _ := ctx.Value(promoteStateCtxKey{}).(*promoteState)
+	if state == nil {
+		return
+	}
+	state.op.mu.Lock()
+	defer state.op.mu.Unlock()
+	for _, kind := range kinds {
+		delete(state.op.pending[prodAppId], kind)
+	}
+}
+
+// isOwnPromoteChange returns true if the promote of the prod app has only the changes made by the
+// operation, as checked by checkPendingPromote
+func isOwnPromoteChange(ctx context.Context, prodAppId types.AppId) bool {
+	state, _ := ctx.Value(promoteStateCtxKey{}).(*promoteState)
+	if state == nil {
+		return false
+	}
+	state.op.mu.Lock()
+	defer state.op.mu.Unlock()
+	pending, ok := state.op.pending[prodAppId]
+	return ok && len(pending) == 0
+}
+
+// recordPromoteDiff saves the promote diff, to be added to the audit log after commit
+func recordPromoteDiff(ctx context.Context, prodApp *types.AppEntry, diff *types.AppPromoteDiff) {
+	state, _ := ctx.Value(promoteStateCtxKey{}).(*promoteState)
+	if state == nil || len(diff.Changes) == 0 {
+		return
+	}
+	state.op.mu.Lock()
+	defer state.op.mu.Unlock()
+	state.op.entries = append(state.op.entries, promoteAuditEntry{prodApp: prodApp, diff: diff})
+}
+
+// insertPromoteAuditEvents adds the promote diffs recorded for the operation to the audit log.
+// Called after the transaction is committed, the entries are cleared so they are written once
+func (s *Server) insertPromoteAuditEvents(ctx context.Context) {
+	state, _ := ctx.Value(promoteStateCtxKey{}).(*promoteState)
+	if state == nil || s.auditDB == nil {
+		// Audit DB is not initialized (Server built directly in tests)
+		return
+	}
+	state.op.mu.Lock()
+	entries := state.op.entries
+	state.op.entries = nil
+	state.op.mu.Unlock()
+	for _, entry := range entries {
+		s.insertPromoteDiffAuditEvent(ctx, entry.prodApp, entry.diff)
+	}
+}
+
+// promoteConfirmError is returned when the promote changes need a confirmation which was not given
+func promoteConfirmError(diff *types.AppPromoteDiff) error {
+	return types.CreateRequestError(
+		fmt.Sprintf("promote changes permissions or container settings, rerun with --yes to confirm\n%s:\n%s",
+			diff.AppPathDomain, promoteDiffSummary(diff)), http.StatusBadRequest)
+}
+
+// promoteDiff returns the changes which promoting the stage app would make to the prod app
+func (s *Server) promoteDiff(ctx context.Context, tx types.Transaction, stageApp, prodApp *types.AppEntry) (*types.AppPromoteDiff, error) {
+	changes := diffAppMetadata(&stageApp.Metadata, &prodApp.Metadata)
+	stageVersion := stageApp.Metadata.VersionMetadata.Version
+	prodVersion := prodApp.Metadata.VersionMetadata.Version
+	if stageVersion != prodVersion {
+		stageFiles, err := s.getVersionFiles(ctx, tx, stageApp.Id, stageVersion)
+		if err != nil {
+			return nil, fmt.Errorf("error getting files for stage app %s: %w", stageApp, err)
+		}
+		prodFiles, err := s.getVersionFiles(ctx, tx, prodApp.Id, prodVersion)
+		if err != nil {
+			return nil, fmt.Errorf("error getting files for prod app %s: %w", prodApp, err)
+		}
+		changes = append(diffAppFiles(stageFiles, prodFiles), changes...)
+	}
+
+	return newPromoteDiff(prodApp.AppPathDomain(), changes), nil
+}
+
+func (s *Server) getVersionFiles(ctx context.Context, tx types.Transaction, appId types.AppId, version int) ([]types.AppFile, error) {
+	fileStore, err := metadata.NewFileStore(appId, version, s.db, tx)
+	if err != nil {
+		return nil, err
+	}
+	return fileStore.GetAppFiles(ctx, tx)
+}
+
+func newPromoteDiff(appPathDomain types.AppPathDomain, changes []types.PromoteChange) *types.AppPromoteDiff {
+	diff := &types.AppPromoteDiff{
+		AppPathDomain: appPathDomain,
+		Changes:       changes,
+	}
+	for _, change := range changes {
+		if confirmKinds[change.Kind] {
+			diff.ConfirmRequired = true
+			break
+		}
+	}
+	return diff
+}
+
+// promoteDiffSummary returns the changes as one line per change, used for the error message and
+// the audit event detail
+func promoteDiffSummary(diff *types.AppPromoteDiff) string {
+	lines := make([]string, 0, len(diff.Changes))
+	for _, change := range diff.Changes {
+		lines = append(lines, change.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// insertPromoteDiffAuditEvent records the changes made to the prod app by the promote
+func (s *Server) insertPromoteDiffAuditEvent(ctx context.Context, prodApp *types.AppEntry, diff *types.AppPromoteDiff) {
+	if len(diff.Changes) == 0 {
+		return
+	}
+	event := types.AuditEvent{
+		RequestId:  system.GetContextRequestId(ctx),
+		AppId:      prodApp.Id,
+		CreateTime: time.Now(),
+		UserId:     system.GetContextUserId(ctx),
+		EventType:  types.EventTypeSystem,
+		Operation:  "promote_diff",
+		Target:     prodApp.String(),
+		Status:     string(types.EventStatusSuccess),
+		Detail:     promoteDiffSummary(diff),
+	}
+	if err := s.InsertAuditEvent(&event); err != nil {
+		s.Error().Err(err).Str("app", prodApp.String()).Msg("Error inserting promote diff audit event")
+	}
+}
+
+// diffAppFiles compares the source files of the stage and prod app versions, by the file etag
+func diffAppFiles(stageFiles, prodFiles []types.AppFile) []types.PromoteChange {
+	stageEtags := make(map[string]string, len(stageFiles))
+	for _, f := range stageFiles {
+		stageEtags[f.Name] = f.Etag
+	}
+	prodEtags := make(map[string]string, len(prodFiles))
+	for _, f := range prodFiles {
+		prodEtags[f.Name] = f.Etag
+	}
+
+	// The etags are not useful in the output, only the file names are listed
+	return diffStringMaps("files", stageEtags, prodEtags, hideValue)
+}
+
+// diffAppMetadata compares the metadata which promote copies from the stage app to the prod app.
+// Values for secret looking param and config names are redacted
+func diffAppMetadata(stage, prod *types.AppMetadata) []types.PromoteChange {
+	var changes []types.PromoteChange
+	redactSecrets := func(name, value string) string {
+		if isSecretConfigField(strings.ToLower(name)) {
+			return RedactedValue
+		}
+		return value
+	}
+	changes = append(changes, diffStringMaps("params", stage.ParamValues, prod.ParamValues, redactSecrets)...)
+	changes = append(changes, diffStringMaps("app_config", stage.AppConfig, prod.AppConfig, redactSecrets)...)
+	changes = append(changes, diffStringSets("permissions", permissionStrings(stage.Permissions), permissionStrings(prod.Permissions))...)
+	changes = append(changes, diffStringMaps("env", envMap(stage.Env), envMap(prod.Env), hideValue)...)
+	changes = append(changes, diffStringMaps("container_options", stage.ContainerOptions, prod.ContainerOptions, nil)...)
+	changes = append(changes, diffStringMaps("container_args", stage.ContainerArgs, prod.ContainerArgs, nil)...)
+	changes = append(changes, diffStringSets("container_volumes", stage.ContainerVolumes, prod.ContainerVolumes)...)
+
+	if stage.Spec != prod.Spec {
+		changes = append(changes, types.PromoteChange{Op: PROMOTE_CHANGE, Kind: "spec", Name: "spec",
+			Old: string(prod.Spec), New: string(stage.Spec)})
+	}
+
+	// Spec file contents can be large, only the names are listed
+	var stageSpecFiles, prodSpecFiles map[string]string
+	if stage.SpecFiles != nil {
+		stageSpecFiles = *stage.SpecFiles
+	}
+	if prod.SpecFiles != nil {
+		prodSpecFiles = *prod.SpecFiles
+	}
+	return append(changes, diffStringMaps("spec_files", stageSpecFiles, prodSpecFiles, hideValue)...)
+}
+
+// diffStringMaps returns the added, removed and changed keys, sorted by key. The value func, if
+// set, maps the values shown in the change
+func diffStringMaps(kind string, stage, prod map[string]string, value func(name, value string) string) []types.PromoteChange {
+	if value == nil {
+		value = func(_, v string) string { return v }
+	}
+	var changes []types.PromoteChange
+	keys := slices.Sorted(maps.Keys(stage))
+	for _, key := range keys {
+		stageValue := stage[key]
+		prodValue, ok := prod[key]
+		if !ok {
+			changes = append(changes, types.PromoteChange{Op: PROMOTE_ADD, Kind: kind, Name: key, New: value(key, stageValue)})
+		} else if prodValue != stageValue {
+			changes = append(changes, types.PromoteChange{Op: PROMOTE_CHANGE, Kind: kind, Name: key,
+				Old: value(key, prodValue), New: value(key, stageValue)})
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(prod)) {
+		if _, ok := stage[key]; !ok {
+			changes = append(changes, types.PromoteChange{Op: PROMOTE_DELETE, Kind: kind, Name: key, Old: value(key, prod[key])})
+		}
+	}
+	return changes
+}
+
+// diffStringSets returns the added and removed entries, sorted
+func diffStringSets(kind string, stage, prod []string) []types.PromoteChange {
+	var changes []types.PromoteChange
+	for _, entry := range slices.Compact(slices.Sorted(slices.Values(stage))) {
+		if !slices.Contains(prod, entry) {
+			changes = append(changes, types.PromoteChange{Op: PROMOTE_ADD, Kind: kind, Name: entry})
+		}
+	}
+	for _, entry := range slices.Compact(slices.Sorted(slices.Values(prod))) {
+		if !slices.Contains(stage, entry) {
+			changes = append(changes, types.PromoteChange{Op: PROMOTE_DELETE, Kind: kind, Name: entry})
+		}
+	}
+	return changes
+}
+
+// permissionStrings returns the permissions in the plugin.method(args) format, with the
+// permit, read and secrets settings when set
+func permissionStrings(permissions []types.Permission) []string {
+	ret := make([]string, 0, len(permissions))
+	for _, p := range permissions {
+		var buf strings.Builder
+		fmt.Fprintf(&buf, "%s.%s(%s)", p.Plugin, p.Method, strings.Join(p.Arguments, ", "))
+		if len(p.Permit) > 0 {
+			fmt.Fprintf(&buf, " permit=%s", strings.Join(p.Permit, ","))
+		}
+		if p.IsRead != nil {
+			fmt.Fprintf(&buf, " is_read=%t", *p.IsRead)
+		}
+		for _, secret := range p.Secrets {
+			fmt.Fprintf(&buf, " secret=%s", strings.Join(secret, ","))
+		}
+		ret = append(ret, buf.String())
+	}
+	return ret
+}
+
+// envMap returns the approved env entries by name
+func envMap(env []string) map[string]string {
+	ret := make(map[string]string, len(env))
+	for _, entry := range env {
+		name, value, _ := strings.Cut(entry, "=")
+		ret[name] = value
+	}
+	return ret
+}
+
+// hideValue is used when only the names are listed in the diff, for env entries which could
+// have secret values and for file contents
+func hideValue(_, _ string) string {
+	return ""
+}
@@ -0,0 +1,288 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func changeStrings(changes []types.PromoteChange) []string {
+	ret := make([]string, 0, len(changes))
+	for _, change := range changes {
+		ret = append(ret, change.String())
+	}
+	return ret
+}
+
+func TestDiffAppMetadata(t *testing.T) {
+	isRead := true
+	prod := &types.AppMetadata{
+		ParamValues:      map[string]string{"title": "old", "api_token": "tok1", "removed": "x"},
+		Permissions:      []types.Permission{{Plugin: "exec.in", Method: "run", Arguments: []string{"ls"}}},
+		ContainerOptions: map[string]string{"cpus": "1"},
+		Env:              []string{"HOME", "KEY=secret1"},
+		Spec:             "python-flask",
+		SpecFiles:        &types.SpecFiles{"Containerfile": "FROM a"},
+	}
+	stage := &types.AppMetadata{
+		ParamValues: map[string]string{"title": "new", "api_token": "tok2"},
+		Permissions: []types.Permission{
+			{Plugin: "exec.in", Method: "run", Arguments: []string{"ls"}},
+			{Plugin: "http.in", Method: "get", IsRead: &isRead},
+		},
+		ContainerOptions: map[string]string{"cpus": "2"},
+		ContainerVolumes: []string{"/data"},
+		Env:              []string{"HOME", "KEY=secret2"},
+		Spec:             "python-flask",
+		SpecFiles:        &types.SpecFiles{"Containerfile": "FROM b"},
+	}
+
+	changes := changeStrings(diffAppMetadata(stage, prod))
+	expected := []string{
+		"~ params api_token: <redacted> -> <redacted>",
+		"~ params title: old -> new",
+		"- params removed: x",
+		"+ permissions http.in.get() is_read=true",
+		"~ env KEY",
+		"~ container_options cpus: 1 -> 2",
+		"+ container_volumes /data",
+		"~ spec_files Containerfile",
+	}
+	testutil.AssertEqualsString(t, "changes", strings.Join(expected, "\n"), strings.Join(changes, "\n"))
+	for _, secret := range []string{"tok1", "tok2", "secret1", "secret2"} {
+		if strings.Contains(strings.Join(changes, "\n"), secret) {
+			t.Errorf("diff has secret value %s: %v", secret, changes)
+		}
+	}
+
+	diff := newPromoteDiff(types.AppPathDomain{Path: "/app"}, diffAppMetadata(stage, prod))
+	testutil.AssertEqualsBool(t, "confirm required", true, diff.ConfirmRequired)
+
+	// Param and spec changes do not need a confirmation
+	stage.Permissions = prod.Permissions
+	stage.ContainerOptions = prod.ContainerOptions
+	stage.ContainerVolumes = nil
+	stage.Env = prod.Env
+	stage.Spec = "python-fastapi"
+	diff = newPromoteDiff(types.AppPathDomain{Path: "/app"}, diffAppMetadata(stage, prod))
+	testutil.AssertEqualsBool(t, "confirm required", false, diff.ConfirmRequired)
+	testutil.AssertStringContains(t, promoteDiffSummary(diff), "~ spec spec: python-flask -> python-fastapi")
+
+	testutil.AssertEqualsInt(t, "no changes", 0, len(diffAppMetadata(prod, prod)))
+}
+
+func TestDiffAppFiles(t *testing.T) {
+	prod := []types.AppFile{{Name: "app.star", Etag: "e1"}, {Name: "index.go.html", Etag: "e2"}, {Name: "old.txt", Etag: "e3"}}
+	stage := []types.AppFile{{Name: "app.star", Etag: "e1"}, {Name: "index.go.html", Etag: "e4"}, {Name: "new.txt", Etag: "e5"}}
+	testutil.AssertEqualsString(t, "changes", "~ files index.go.html\n+ files new.txt\n- files old.txt",
+		strings.Join(changeStrings(diffAppFiles(stage, prod)), "\n"))
+}
+
+func TestPromoteConfirm(t *testing.T) {
+	server, db, ctx := newApplyTestServer(t)
+	defer db.Close()
+	if err := server.initAuditDB("sqlite:" + filepath.Join(t.TempDir(), "audit.db")); err != nil {
+		t.Fatalf("init audit db: %v", err)
+	}
+	defer func() {
+		server.stopAuditWriter()
+		_ = server.auditDB.Close()
+	}()
+
+	appDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(appDir, "app.star"), []byte(`app = ace.app("testApp")
+`), 0600); err != nil {
+		t.Fatalf("write app.star: %v", err)
+	}
+	applyPath := filepath.Join(t.TempDir(), "apps.ace")
+	if err := os.WriteFile(applyPath, []byte(fmt.Sprintf("app(\"/apps/promote\", %q)\n", appDir)), 0600); err != nil {
+		t.Fatalf("write apply file: %v", err)
+	}
+	if _, _, err := server.Apply(ctx, types.Transaction{}, applyPath, "/apps/**", false, false, false,
+		types.AppReloadOptionNone, "", "", "", false, false, false, "", nil, false); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	updateOption := func(ctx context.Context, option string, promote bool) error {
+		update := types.CreateUpdateAppMetadataRequest()
+		update.ConfigType = types.AppMetadataContainerOptions
+		update.ConfigEntries = []string{option}
+		_, err := server.StagedUpdate(ctx, "/apps/promote", false, promote, server.updateMetadataHandler,
+			map[string]any{"metadata": update, "dryRun": false}, "update_metadata")
+		return err
+	}
+	prodOption := func() string {
+		prodApp, err := db.GetAppEntry(ctx, types.AppPathDomain{Path: "/apps/promote"})
+		testutil.AssertNoError(t, err)
+		return prodApp.Metadata.ContainerOptions["cpus"]
+	}
+
+	// The container setting change made by the update is promoted without a separate confirmation
+	testutil.AssertNoError(t, updateOption(withPromoteState(ctx, false), "cpus=2", true))
+	testutil.AssertEqualsString(t, "prod option", "2", prodOption())
+
+	// A container setting change pending on the stage app needs the confirmation, for promote and for
+	// an update with promote. The stage update is rolled back too
+	testutil.AssertNoError(t, updateOption(ctx, "cpus=3", false))
+	_, err := server.PromoteApps(ctx, "/apps/promote", false, false, false)
+	testutil.AssertErrorContains(t, err, "rerun with --yes to confirm")
+	testutil.AssertErrorContains(t, err, "~ container_options cpus: 2 -> 3")
+	err = updateOption(withPromoteState(ctx, false), "memory=1g", true)
+	testutil.AssertErrorContains(t, err, "~ container_options cpus: 2 -> 3")
+	testutil.AssertEqualsString(t, "prod option", "2", prodOption())
+
+	// A dry run does not need the confirmation
+	_, err = server.PromoteApps(ctx, "/apps/promote", true, false, false)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "prod option", "2", prodOption())
+
+	_, err = server.PromoteApps(ctx, "/apps/promote", false, false, true)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "prod option", "3", prodOption())
+
+	// Only the committed promotes are in the audit log
+	server.FlushAuditEvents()
+	events, err := server.ListAuditEvents(ctx, types.AuditQuery{Operation: "promote_diff", Limit: 10})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "audit events", 2, len(events.Events))
+	details := []string{events.Events[0].Detail, events.Events[1].Detail}
+	slices.Sort(details)
+	testutil.AssertEqualsString(t, "audit detail", "+ container_options cpus: 2\n~ container_options cpus: 2 -> 3",
+		strings.Join(details, "\n"))
+}
+
+func TestPromoteState(t *testing.T) {
+	ctx := context.Background()
+	testutil.AssertEqualsBool(t, "no state", false, isPromoteConfirmed(ctx))
+
+	parent := withPromoteState(ctx, false)
+	testutil.AssertEqualsBool(t, "not confirmed", false, isPromoteConfirmed(parent))
+	testutil.AssertEqualsBool(t, "reused", true, withPromoteState(parent, false) == parent)
+
+	// A confirmed child shares the audit entries with the parent
+	child := withPromoteState(parent, true)
+	testutil.AssertEqualsBool(t, "confirmed", true, isPromoteConfirmed(child))
+	testutil.AssertEqualsBool(t, "parent not confirmed", false, isPromoteConfirmed(parent))
+	diff := newPromoteDiff(types.AppPathDomain{Path: "/app"}, []types.PromoteChange{{Op: PROMOTE_ADD, Kind: "params", Name: "p1"}})
+	recordPromoteDiff(child, &types.AppEntry{Path: "/app"}, diff)
+	recordPromoteDiff(child, &types.AppEntry{Path: "/app"}, newPromoteDiff(types.AppPathDomain{Path: "/app"}, nil))
+	state := parent.Value(promoteStateCtxKey{}).(*promoteState)
+	testutil.AssertEqualsInt(t, "audit entries", 1, len(state.op.entries))
+}
+
+func TestCheckPendingPromote(t *testing.T) {
+	prod := &types.AppEntry{Id: "app_prd_1"}
+	stage := &types.AppEntry{Id: "app_stg_1", Metadata: types.AppMetadata{
+		Permissions: []types.Permission{{Plugin: "exec.in", Method: "run"}},
+	}}
+
+	// Pending permission changes are approved by an operation with approve
+	ctx := withPromoteState(context.Background(), false)
+	checkPendingPromote(ctx, stage, prod, false)
+	testutil.AssertEqualsBool(t, "pending permissions", false, isOwnPromoteChange(ctx, prod.Id))
+	ctx = withPromoteState(context.Background(), false)
+	checkPendingPromote(ctx, stage, prod, true)
+	testutil.AssertEqualsBool(t, "approved permissions", true, isOwnPromoteChange(ctx, prod.Id))
+
+	// The first check for the prod app is used
+	stage.Metadata.ContainerOptions = map[string]string{"cpus": "2"}
+	ctx = withPromoteState(context.Background(), false)
+	checkPendingPromote(ctx, stage, prod, true)
+	checkPendingPromote(ctx, &types.AppEntry{Id: "app_stg_1"}, prod, false)
+	testutil.AssertEqualsBool(t, "pending container options", false, isOwnPromoteChange(ctx, prod.Id))
+	declarePromoteKinds(ctx, prod.Id, "container_options")
+	testutil.AssertEqualsBool(t, "declared container options", true, isOwnPromoteChange(ctx, prod.Id))
+	testutil.AssertEqualsBool(t, "no promote state", false, isOwnPromoteChange(context.Background(), prod.Id))
+}
+
+func TestSyncPromoteConfirm(t *testing.T) {
+	server, db, ctx := newApplyTestServer(t)
+	defer db.Close()
+	if err := server.initAuditDB("sqlite:" + filepath.Join(t.TempDir(), "audit.db")); err != nil {
+		t.Fatalf("init audit db: %v", err)
+	}
+	defer func() {
+		server.stopAuditWriter()
+		_ = server.auditDB.Close()
+	}()
+
+	appDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(appDir, "app.star"), []byte(`app = ace.app("testApp")
+`), 0600); err != nil {
+		t.Fatalf("write app.star: %v", err)
+	}
+	applyPath := filepath.Join(t.TempDir(), "sync.ace")
+	writeApply := func(cpus, param string) {
+		if err := os.WriteFile(applyPath, []byte(fmt.Sprintf("app(\"/apps/syncpromote\", %q, container_opts={\"cpus\": %q}, params={\"p1\": %q})\n",
+			appDir, cpus, param)), 0600); err != nil {
+			t.Fatalf("write apply file: %v", err)
+		}
+	}
+	writeApply("1", "a")
+	response, err := server.CreateSyncEntry(ctx, applyPath, true, false,
+		&types.SyncMetadata{Promote: true, Reload: string(types.AppReloadOptionUpdated)})
+	testutil.AssertNoError(t, err)
+
+	readTx, err := db.BeginTransaction(ctx)
+	testutil.AssertNoError(t, err)
+	entry, err := db.GetSyncEntry(ctx, readTx, response.Id)
+	testutil.AssertNoError(t, err)
+	_ = readTx.Rollback()
+
+	runSync := func() *types.SyncJobStatus {
+		status, _, err := server.runSyncJob(ctx, types.Transaction{}, entry, false, true, nil)
+		testutil.AssertNoError(t, err)
+		return status
+	}
+	prodOption := func() string {
+		prodApp, err := db.GetAppEntry(ctx, types.AppPathDomain{Path: "/apps/syncpromote"})
+		testutil.AssertNoError(t, err)
+		return prodApp.Metadata.ContainerOptions["cpus"]
+	}
+	testutil.AssertEqualsString(t, "prod option", "1", prodOption())
+
+	// The container change made by the sync is promoted without the confirm option, the diff is audited
+	writeApply("2", "a")
+	testutil.AssertEqualsString(t, "sync error", "", runSync().Error)
+	testutil.AssertEqualsString(t, "prod option", "2", prodOption())
+	server.FlushAuditEvents()
+	events, err := server.ListAuditEvents(ctx, types.AuditQuery{Operation: "promote_diff", Limit: 10})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "audit events", 1, len(events.Events))
+	testutil.AssertStringContains(t, events.Events[0].Detail, "~ container_options cpus: 1 -> 2")
+
+	// A container change pending on the stage app is not promoted by the sync without the confirm option
+	update := types.CreateUpdateAppMetadataRequest()
+	update.ConfigType = types.AppMetadataContainerOptions
+	update.ConfigEntries = []string{"cpus=3"}
+	_, err = server.StagedUpdate(ctx, "/apps/syncpromote", false, false, server.updateMetadataHandler,
+		map[string]any{"metadata": update, "dryRun": false}, "update_metadata")
+	testutil.AssertNoError(t, err)
+	writeApply("2", "b")
+	testutil.AssertStringContains(t, runSync().Error, "rerun with --yes to confirm")
+	testutil.AssertEqualsString(t, "prod option", "2", prodOption())
+
+	entry.Metadata.Confirm = true
+	testutil.AssertEqualsString(t, "sync error", "", runSync().Error)
+	testutil.AssertEqualsString(t, "prod option", "3", prodOption())
+
+	// An apply with clobber resets the pending stage change to the apply file config, which is promoted
+	update.ConfigEntries = []string{"cpus=4"}
+	_, err = server.StagedUpdate(ctx, "/apps/syncpromote", false, false, server.updateMetadataHandler,
+		map[string]any{"metadata": update, "dryRun": false}, "update_metadata")
+	testutil.AssertNoError(t, err)
+	_, _, err = server.Apply(ctx, types.Transaction{}, applyPath, "/apps/**", false, false, true,
+		types.AppReloadOptionNone, "", "", "", true, false, false, "", nil, false)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "prod option", "2", prodOption())
+}
@@ -0,0 +1,181 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// CONTAINER_PLUGIN is the plugin module loaded by apps which run a container
+const CONTAINER_PLUGIN = "container.in"
+
+// quotaUsage returns the usage of the apps owned by the users the quota policy counts. For a
+// shared policy, that is all the users matching the policy, otherwise it is just the user. The
+// apps are read in the transaction, so that apps being created are counted. Containers and
+// storage are computed only if the policy limits them, unless all is set
+func (s *Server) quotaUsage(ctx context.Context, tx types.Transaction, quota types.QuotaPolicy, user string, all bool) (types.QuotaUsage, error) {
+	usage := types.QuotaUsage{}
+	allApps, err := s.db.GetAllAppsTx(ctx, tx, true)
+	if err != nil {
+		return usage, err
+	}
+
+	ownerMatches := map[string]bool{}
+	counted := func(owner string) (bool, error) {
+		if owner == user {
+			return true, nil
+		}
+		if !quota.Shared || owner == "" {
+			return false, nil
+		}
+		if matched, ok := ownerMatches[owner]; ok {
+			return matched, nil
+		}
+		matched, err := s.rbacManager.QuotaUserMatches(quota, owner)
+		ownerMatches[owner] = matched
+		return matched, err
+	}
+
+	for _, appInfo := range allApps {
+		match, err := counted(appInfo.UserID)
+		if err != nil {
+			return usage, err
+		}
+		if !match {
+			continue
+		}
+
+		if appInfo.MainApp == "" {
+			usage.Apps++
+			if all || quota.MaxContainers > 0 {
+				appEntry, err := s.db.GetAppEntryTx(ctx, tx, appInfo.AppPathDomain)
+				if err != nil {
+					return usage, err
+				}
+				if slices.Contains(appEntry.Metadata.Loads, CONTAINER_PLUGIN) {
+					usage.Containers++
+				}
+			}
+		}
+
+		if all || quota.MaxStorageMB > 0 {
+			// stage and preview apps have their own run directories
+			usage.StorageBytes += appRunDirSize(appInfo.Id)
+		}
+	}
+	return usage, nil
+}
+
+// appRunDirSize returns the disk usage of the app run directory, which has the app data and
+// the container volumes. Errors are ignored, the directory is not created for all apps
+func appRunDirSize(appId types.AppId) int64 {
+	return dirDiskUsage(fmt.Sprintf(os.ExpandEnv("$OPENRUN_HOME/run/app/%s"), appId))
+}
+
+// dirDiskUsage returns the total size of the files under dir, errors are ignored
+func dirDiskUsage(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		if info, err := d.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+func quotaName(quota types.QuotaPolicy) string {
+	if quota.Description != "" {
+		return fmt.Sprintf("quota %q", quota.Description)
+	}
+	return "quota"
+}
+
+func quotaOwner(quota types.QuotaPolicy, user string) string {
+	if quota.Shared {
+		return "team of user " + user
+	}
+	return "user " + user
+}
+
+// checkQuotas checks the quota policies of the user getting an app, after the app is created or
+// transferred in the transaction. The new app is counted against the app limit and, if
+// usesContainer is set, against the container limit. Storage is checked against the current
+// usage, since a new app has no data yet. groups are the login groups of the user, if known
+func (s *Server) checkQuotas(ctx context.Context, tx types.Transaction, user string, groups []string, usesContainer bool) error {
+	quotas, err := s.rbacManager.UserQuotas(user, groups)
+	if err != nil || len(quotas) == 0 {
+		return err
+	}
+
+	for _, quota := range quotas {
+		if quota.MaxApps == 0 && quota.MaxStorageMB == 0 && (quota.MaxContainers == 0 || !usesContainer) {
+			continue
+		}
+		usage, err := s.quotaUsage(ctx, tx, quota, user, false)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case quota.MaxApps > 0 && usage.Apps > quota.MaxApps:
+			return types.CreateRequestError(fmt.Sprintf("%s exceeded: %s has %d apps, the limit is %d",
+				quotaName(quota), quotaOwner(quota, user), usage.Apps-1, quota.MaxApps), http.StatusBadRequest)
+		case usesContainer && quota.MaxContainers > 0 && usage.Containers > quota.MaxContainers:
+			return types.CreateRequestError(fmt.Sprintf("%s exceeded: %s has %d apps running containers, the limit is %d",
+				quotaName(quota), quotaOwner(quota, user), usage.Containers-1, quota.MaxContainers), http.StatusBadRequest)
+		case quota.MaxStorageMB > 0 && usage.StorageBytes >= quota.MaxStorageMB*1024*1024:
+			return types.CreateRequestError(fmt.Sprintf("%s exceeded: %s is using %d MB of storage, the limit is %d MB",
+				quotaName(quota), quotaOwner(quota, user), usage.StorageBytes/(1024*1024), quota.MaxStorageMB), http.StatusBadRequest)
+		}
+	}
+	return nil
+}
+
+// ShowQuotas returns the quota policies applicable to the user, with the current usage. The user
+// defaults to the caller, checking another user requires the admin permission
+func (s *Server) ShowQuotas(ctx context.Context, user string) (*types.QuotaShowResponse, error) {
+	groups := system.GetContextGroups(ctx)
+	if user == "" {
+		user = system.GetContextUserId(ctx)
+	} else if user != system.GetContextUserId(ctx) {
+		if err := s.enforceGlobalPerm(ctx, types.PermissionAdmin, ""); err != nil {
+			return nil, err
+		}
+		groups = nil // login groups are known only for the caller
+	}
+
+	quotas, err := s.rbacManager.UserQuotas(user, groups)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	ret := &types.QuotaShowResponse{User: user, Quotas: make([]types.QuotaStatus, 0, len(quotas))}
+	for _, quota := range quotas {
+		usage, err := s.quotaUsage(ctx, tx, quota, user, true)
+		if err != nil {
+			return nil, err
+		}
+		ret.Quotas = append(ret.Quotas, types.QuotaStatus{QuotaPolicy: quota, Usage: usage})
+	}
+	return ret, nil
+}
@@ -0,0 +1,192 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// registryPush is an image push from a registry webhook event
+type registryPush struct {
+	Repository string // normalized repository, like docker.io/library/nginx
+	Tag        string
+}
+
+// githubPackage is the package info in the GitHub package and registry_package events, sent
+// for pushes to the GitHub container registry (ghcr.io)
+type githubPackage struct {
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace"`
+	PackageType string `json:"package_type"`
+	Owner       struct {
+		Login string `json:"login"`
+	} `json:"owner"`
+	PackageVersion *struct {
+		PackageUrl        string `json:"package_url"`
+		ContainerMetadata struct {
+			Tag struct {
+				Name string `json:"name"`
+			} `json:"tag"`
+		} `json:"container_metadata"`
+	} `json:"package_version"`
+}
+
+// registryEvent has the fields used from the Docker Hub, Harbor and GitHub (GHCR) push events
+type registryEvent struct {
+	// Docker Hub
+	PushData *struct {
+		Tag string `json:"tag"`
+	} `json:"push_data"`
+	Repository *struct {
+		RepoName string `json:"repo_name"`
+	} `json:"repository"`
+
+	// Harbor
+	Type      string `json:"type"`
+	EventData *struct {
+		Resources []struct {
+			Tag         string `json:"tag"`
+			ResourceUrl string `json:"resource_url"`
+		} `json:"resources"`
+	} `json:"event_data"`
+
+	// GitHub
+	Action          string         `json:"action"`
+	Package         *githubPackage `json:"package"`
+	RegistryPackage *githubPackage `json:"registry_package"`
+}
+
+// parseRegistryPush returns the image pushes in a registry webhook event. Events which are
+// not image pushes (like a Harbor delete) return no pushes. An error is returned if the payload
+// is not a supported registry event
+func parseRegistryPush(body []byte) ([]registryPush, error) {
+	var event registryEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("error parsing registry event, expected JSON: %w", err)
+	}
+
+	pushes := []registryPush{}
+	switch {
+	case event.PushData != nil && event.Repository != nil && event.Repository.RepoName != "":
+		// Docker Hub, the repo name is without the registry host
+		repo, _, _ := parseImageRef(event.Repository.RepoName)
+		pushes = append(pushes, registryPush{Repository: repo, Tag: event.PushData.Tag})
+	case event.EventData != nil:
+		// Harbor, the resource url has the registry host
+		if event.Type != "PUSH_ARTIFACT" {
+			return pushes, nil
+		}
+		for _, resource := range event.EventData.Resources {
+			repo, tag, _ := parseImageRef(resource.ResourceUrl)
+			pushes = append(pushes, registryPush{Repository: repo, Tag: cmp.Or(resource.Tag, tag)})
+		}
+	case event.Package != nil || event.RegistryPackage != nil:
+		pkg := event.Package
+		if pkg == nil {
+			pkg = event.RegistryPackage
+		}
+		if (event.Action != "published" && event.Action != "updated") || !strings.EqualFold(pkg.PackageType, "container") {
+			return pushes, nil
+		}
+		namespace := pkg.Namespace
+		if namespace == "" {
+			namespace = pkg.Owner.Login
+		}
+		ref := "ghcr.io/" + namespace + "/" + pkg.Name
+		tag := ""
+		if pkg.PackageVersion != nil {
+			tag = pkg.PackageVersion.ContainerMetadata.Tag.Name
+			if pkg.PackageVersion.PackageUrl != "" {
+				ref = pkg.PackageVersion.PackageUrl
+			}
+		}
+		repo, refTag, _ := parseImageRef(ref)
+		if tag == "" && strings.Contains(ref, ":") {
+			tag = refTag
+		}
+		if tag == "" {
+			// Untagged push, like a platform specific manifest of a multi-arch image
+			return pushes, nil
+		}
+		pushes = append(pushes, registryPush{Repository: repo, Tag: tag})
+	default:
+		return nil, errors.New("unsupported registry event, expected a Docker Hub, Harbor or GitHub package event")
+	}
+	return pushes, nil
+}
+
+// parseImageRef returns the normalized repository, tag and digest for an image reference.
+// Docker Hub references get the docker.io host and the library namespace for official images.
+// The tag defaults to latest if the reference has no tag and no digest
+func parseImageRef(ref string) (repo, tag, digest string) {
+	ref = strings.TrimSpace(ref)
+	ref = strings.TrimPrefix(strings.TrimPrefix(ref, "https://"), "http://")
+	ref, digest, _ = strings.Cut(ref, "@")
+	repo = ref
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		repo, tag = ref[:i], ref[i+1:]
+	}
+	if tag == "" && digest == "" {
+		tag = "latest"
+	}
+
+	host, path, found := strings.Cut(repo, "/")
+	if !found || (!strings.ContainsAny(host, ".:") && host != "localhost") {
+		host, path = "docker.io", repo
+	}
+	switch host {
+	case "index.docker.io", "registry-1.docker.io", "registry.hub.docker.com":
+		host = "docker.io"
+	}
+	if host == "docker.io" && !strings.Contains(path, "/") {
+		path = "library/" + path
+	}
+	return strings.ToLower(host + "/" + path), tag, digest
+}
+
+// appImage returns the image for an app using the image spec, empty for other apps
+func appImage(metadata *types.AppMetadata) string {
+	if metadata.Spec != types.ImageSpec {
+		return ""
+	}
+	return metadata.ParamValues[imageSpecParam]
+}
+
+// checkRegistryPush checks whether the registry webhook event is a push of the image used by
+// the app. The reason the event is ignored is returned if it is not, empty if the app has
+// to be reloaded. An error is returned for invalid events and for apps not using an image
+func checkRegistryPush(metadata *types.AppMetadata, header http.Header, body []byte) (string, error) {
+	image := appImage(metadata)
+	if image == "" {
+		return "", errors.New("registry webhook is supported only for apps using the image spec")
+	}
+	if event := header.Get("X-GitHub-Event"); event == "ping" {
+		return "ping event", nil
+	}
+
+	pushes, err := parseRegistryPush(body)
+	if err != nil {
+		return "", err
+	}
+	if len(pushes) == 0 {
+		return "not an image push event", nil
+	}
+	repo, tag, digest := parseImageRef(image)
+	if digest != "" {
+		return fmt.Sprintf("app image %s is pinned by digest", image), nil
+	}
+	for _, push := range pushes {
+		if push.Repository == repo && push.Tag == tag {
+			return "", nil
+		}
+	}
+	return fmt.Sprintf("push of %s:%s does not match app image %s", pushes[0].Repository, pushes[0].Tag, image), nil
+}
@@ -0,0 +1,121 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestParseImageRef(t *testing.T) {
+	tests := []struct {
+		ref, repo, tag, digest string
+	}{
+		{"nginx", "docker.io/library/nginx", "latest", ""},
+		{"nginx:1.27", "docker.io/library/nginx", "1.27", ""},
+		{"bob/app:v2", "docker.io/bob/app", "v2", ""},
+		{"index.docker.io/bob/app", "docker.io/bob/app", "latest", ""},
+		{"ghcr.io/Org/App:main", "ghcr.io/org/app", "main", ""},
+		{"localhost:5000/app", "localhost:5000/app", "latest", ""},
+		{"registry.example.com:5000/team/app:1.0", "registry.example.com:5000/team/app", "1.0", ""},
+		{"nginx@sha256:abcd", "docker.io/library/nginx", "", "sha256:abcd"},
+		{"https://harbor.example.com/proj/app:dev", "harbor.example.com/proj/app", "dev", ""},
+	}
+	for _, tt := range tests {
+		repo, tag, digest := parseImageRef(tt.ref)
+		if repo != tt.repo || tag != tt.tag || digest != tt.digest {
+			t.Errorf("parseImageRef(%q) = %q, %q, %q, want %q, %q, %q", tt.ref, repo, tag, digest, tt.repo, tt.tag, tt.digest)
+		}
+	}
+}
+
+const (
+	dockerHubPush = `{"push_data": {"tag": "v2", "pusher": "bob"},
+		"repository": {"repo_name": "bob/app", "namespace": "bob", "name": "app"}}`
+	harborPush = `{"type": "PUSH_ARTIFACT", "event_data": {"resources": [
+		{"digest": "sha256:1234", "tag": "dev", "resource_url": "harbor.example.com/proj/app:dev"}]}}`
+	harborDelete = `{"type": "DELETE_ARTIFACT", "event_data": {"resources": [
+		{"tag": "dev", "resource_url": "harbor.example.com/proj/app:dev"}]}}`
+	ghcrPush = `{"action": "published", "package": {"name": "app", "namespace": "org", "package_type": "CONTAINER",
+		"package_version": {"package_url": "ghcr.io/org/app:main", "container_metadata": {"tag": {"name": "main"}}}}}`
+	ghcrUntagged = `{"action": "published", "registry_package": {"name": "app", "package_type": "CONTAINER",
+		"owner": {"login": "org"}, "package_version": {"container_metadata": {"tag": {"name": ""}}}}}`
+)
+
+func TestParseRegistryPush(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []registryPush
+	}{
+		{"dockerhub", dockerHubPush, []registryPush{{"docker.io/bob/app", "v2"}}},
+		{"harbor", harborPush, []registryPush{{"harbor.example.com/proj/app", "dev"}}},
+		{"harbor delete", harborDelete, []registryPush{}},
+		{"ghcr", ghcrPush, []registryPush{{"ghcr.io/org/app", "main"}}},
+		{"ghcr untagged", ghcrUntagged, []registryPush{}},
+	}
+	for _, tt := range tests {
+		got, err := parseRegistryPush([]byte(tt.body))
+		if err != nil {
+			t.Fatalf("%s: unexpected error %s", tt.name, err)
+		}
+		testutil.AssertEqualsInt(t, tt.name+" count", len(tt.want), len(got))
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: push %d = %+v, want %+v", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+
+	if _, err := parseRegistryPush([]byte(`{"ref": "refs/heads/main"}`)); err == nil {
+		t.Errorf("expected error for unsupported event")
+	}
+	if _, err := parseRegistryPush([]byte(`not json`)); err == nil {
+		t.Errorf("expected error for invalid payload")
+	}
+}
+
+func TestCheckRegistryPush(t *testing.T) {
+	imageApp := func(image string) *types.AppMetadata {
+		return &types.AppMetadata{Spec: "image", ParamValues: map[string]string{"image": image}}
+	}
+
+	reason, err := checkRegistryPush(imageApp("bob/app:v2"), http.Header{}, []byte(dockerHubPush))
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "match", "", reason)
+
+	reason, err = checkRegistryPush(imageApp("docker.io/bob/app:v1"), http.Header{}, []byte(dockerHubPush))
+	testutil.AssertNoError(t, err)
+	testutil.AssertStringContains(t, reason, "does not match app image")
+
+	reason, err = checkRegistryPush(imageApp("bob/app@sha256:abcd"), http.Header{}, []byte(dockerHubPush))
+	testutil.AssertNoError(t, err)
+	testutil.AssertStringContains(t, reason, "pinned by digest")
+
+	reason, err = checkRegistryPush(imageApp("harbor.example.com/proj/app:dev"), http.Header{}, []byte(harborDelete))
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "delete", "not an image push event", reason)
+
+	header := http.Header{}
+	header.Set("X-GitHub-Event", "ping")
+	reason, err = checkRegistryPush(imageApp("ghcr.io/org/app:main"), header, []byte(`{"zen": "hi"}`))
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "ping", "ping event", reason)
+
+	_, err = checkRegistryPush(&types.AppMetadata{Spec: "python-flask"}, http.Header{}, []byte(dockerHubPush))
+	testutil.AssertErrorContains(t, err, "only for apps using the image spec")
+}
+
+func TestRedactWebhookToken(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, types.WEBHOOK_URL_PREFIX+"/registry?appPath=%2Fapp&token=secret", nil)
+	got := redactWebhookToken(req)
+	testutil.AssertEqualsString(t, "redacted", types.WEBHOOK_URL_PREFIX+"/registry?appPath=%2Fapp&token=REDACTED", got)
+
+	req = httptest.NewRequest(http.MethodGet, "/app?token=abc", nil)
+	testutil.AssertEqualsString(t, "app url", "/app?token=abc", redactWebhookToken(req))
+}
@@ -42,6 +42,7 @@ type CacheDir struct {
 	dir           string
 	commitMessage string
 	hash          string
+	commit        []byte // the encoded commit object, for verifying the commit signature
 }
 
 type sharedRepoKey struct {
@@ -257,13 +258,17 @@ func (c *sharedRepoCache) close() {
 }
 
 type RepoCache struct {
-	mu         sync.Mutex
-	server     *Server
-	rootDir    string
-	cache      map[Repo]CacheDir
-	shaCache   map[Repo]string // Cache for commit hashes
-	shared     *sharedRepoCache
-	sharedKeys []sharedRepoKey
+	mu       sync.Mutex
+	server   *Server
+	rootDir  string
+	cache    map[Repo]CacheDir
+	shaCache map[Repo]string    // Cache for commit hashes
+	tagCache map[Repo]gitTagRef // Cache for resolved tags, by tag spec
+	// archiveCache has the downloaded archive sources, by url
+	archiveCache map[string]archiveDir
+	shared       *sharedRepoCache
+	sharedKeys   []sharedRepoKey
+	persistent   *gitRepoCache
 }
 
 func NewRepoCache(server *Server) (*RepoCache, error) {
@@ -276,12 +281,20 @@ func NewRepoCache(server *Server) (*RepoCache, error) {
 		os.RemoveAll(tmpDir) //nolint:errcheck
 		return nil, err
 	}
+	persistent, err := server.persistentGitRepoCache()
+	if err != nil {
+		os.RemoveAll(tmpDir) //nolint:errcheck
+		return nil, err
+	}
 	return &RepoCache{
-		server:   server,
-		rootDir:  tmpDir,
-		cache:    make(map[Repo]CacheDir),
-		shaCache: make(map[Repo]string),
-		shared:   shared,
+		server:       server,
+		rootDir:      tmpDir,
+		cache:        make(map[Repo]CacheDir),
+		shaCache:     make(map[Repo]string),
+		tagCache:     make(map[Repo]gitTagRef),
+		archiveCache: make(map[string]archiveDir),
+		shared:       shared,
+		persistent:   persistent,
 	}, nil
 }
 
@@ -334,6 +347,28 @@ func (s *Server) sharedRepoCache() (*sharedRepoCache, error) {
 	return s.gitCache, nil
 }
 
+// persistentGitRepoCache returns the on-disk git repo cache, nil if the cache is disabled. The
+// size limit is updated on config changes
+func (s *Server) persistentGitRepoCache() (*gitRepoCache, error) {
+	config := s.Config().System
+	if config.GitRepoCacheMB <= 0 {
+		return nil, nil
+	}
+	maxBytes := int64(config.GitRepoCacheMB) << 20
+	s.gitCacheMu.Lock()
+	defer s.gitCacheMu.Unlock()
+	if s.gitRepoCache == nil {
+		rootDir := os.ExpandEnv(cmp.Or(config.GitRepoCacheDir, "$OPENRUN_HOME/run/git_repo_cache"))
+		cache, err := newGitRepoCache(rootDir, maxBytes)
+		if err != nil {
+			return nil, err
+		}
+		s.gitRepoCache = cache
+	}
+	s.gitRepoCache.maxBytes.Store(maxBytes)
+	return s.gitRepoCache, nil
+}
+
 func (s *Server) closeSharedRepoCache() {
 	s.gitCacheMu.Lock()
 	cache := s.gitCache
@@ -514,6 +549,9 @@ func (r *RepoCache) CheckoutRepo(sourceUrl, branch, commit, gitAuth string, isDe
 		if !isDev {
 			cloneOptions.Depth = 1
 		}
+	} else {
+		// The commit, like one resolved from a tag, may not be reachable from any branch
+		cloneOptions.Tags = git.AllTags
 	}
 
 	var auth transport.AuthMethod
@@ -599,13 +637,12 @@ func (r *RepoCache) CheckoutRepo(sourceUrl, branch, commit, gitAuth string, isDe
 			usingFullRepo = true
 			defer r.shared.release(fullRepoKey)
 			if folder != "" {
-				message, hash, materializeErr := materializeGitCommit(fullRepoPath, targetPath, commit, folder)
+				cacheDir, materializeErr := materializeGitCommit(fullRepoPath, targetPath, commit, folder)
 				if materializeErr == nil {
-					cacheDir := CacheDir{dir: targetPath, commitMessage: message, hash: hash}
 					r.putRepo(repoKey, cacheDir)
 					sharedResult = cacheDir
 					r.addSharedKey(sharedKey)
-					return targetPath, folder, message, hash, nil
+					return targetPath, folder, cacheDir.commitMessage, cacheDir.hash, nil
 				}
 				r.server.Debug().Err(materializeErr).Str("repo", repo).Str("commit", commit).Str("folder", folder).
 					Msg("Unable to materialize cached git commit, falling back to clone")
@@ -617,6 +654,25 @@ func (r *RepoCache) CheckoutRepo(sourceUrl, branch, commit, gitAuth string, isDe
 		}
 	}
 
+	if r.persistent != nil && !isDev && !usingFullRepo && branch != "" {
+		// Fetch the new commits into the on-disk repo cache instead of cloning the repo again
+		cacheDir, cacheErr := r.persistent.checkout(repo, branch, commit, gitAuth, auth, targetPath, cacheFolder)
+		if cacheErr == nil {
+			r.putRepo(repoKey, cacheDir)
+			if sharedLeader {
+				sharedResult = cacheDir
+				r.addSharedKey(sharedKey)
+			}
+			return targetPath, folder, cacheDir.commitMessage, cacheDir.hash, nil
+		}
+		r.server.Warn().Err(cacheErr).Str("repo", repo).Str("branch", branch).Str("commit", commit).
+			Msg("Unable to use the git repo cache, falling back to clone")
+		os.RemoveAll(targetPath) //nolint:errcheck
+		if mkdirErr := os.MkdirAll(targetPath, 0744); mkdirErr != nil {
+			return "", "", "", "", mkdirErr
+		}
+	}
+
 	gitRepo, err := cloneAndCheckout(cloneURL, cloneAuth)
 	if err != nil && usingFullRepo {
 		// The cached full-history repo may predate an explicitly requested
@@ -639,12 +695,17 @@ func (r *RepoCache) CheckoutRepo(sourceUrl, branch, commit, gitAuth string, isDe
 	if err != nil {
 		return "", "", "", "", err
 	}
+	encodedCommit, err := encodeGitCommit(newCommit)
+	if err != nil {
+		return "", "", "", "", err
+	}
 
 	// Save the repo in cache
 	cacheDir := CacheDir{
 		dir:           targetPath,
 		commitMessage: newCommit.Message,
 		hash:          newCommit.Hash.String(),
+		commit:        encodedCommit,
 	}
 	r.putRepo(repoKey, cacheDir)
 	if sharedLeader {
@@ -668,18 +729,18 @@ func validGitCommit(commit string) bool {
 // materializeGitCommit writes one folder from a commit already available in a
 // full-history checkout. It avoids copying the source checkout's complete git
 // object database merely to read a small app subdirectory at another commit.
-func materializeGitCommit(sourceDir, targetDir, commit, folder string) (string, string, error) {
+func materializeGitCommit(sourceDir, targetDir, commit, folder string) (CacheDir, error) {
 	repo, err := git.PlainOpen(sourceDir)
 	if err != nil {
-		return "", "", err
+		return CacheDir{}, err
 	}
 	commitObject, err := repo.CommitObject(plumbing.NewHash(commit))
 	if err != nil {
-		return "", "", err
+		return CacheDir{}, err
 	}
 	tree, err := commitObject.Tree()
 	if err != nil {
-		return "", "", err
+		return CacheDir{}, err
 	}
 	prefix := strings.Trim(folder, "/")
 	err = tree.Files().ForEach(func(file *object.File) error {
@@ -721,9 +782,39 @@ func materializeGitCommit(sourceDir, targetDir, commit, folder string) (string,
 		return cmp.Or(copyErr, closeErr, readerErr)
 	})
 	if err != nil {
-		return "", "", err
+		return CacheDir{}, err
+	}
+	encodedCommit, err := encodeGitCommit(commitObject)
+	if err != nil {
+		return CacheDir{}, err
+	}
+	return CacheDir{dir: targetDir, commitMessage: commitObject.Message, hash: commitObject.Hash.String(), commit: encodedCommit}, nil
+}
+
+// encodeGitCommit returns the encoded commit object, including the signature
+func encodeGitCommit(commitObject *object.Commit) ([]byte, error) {
+	encoded := &plumbing.MemoryObject{}
+	if err := commitObject.Encode(encoded); err != nil {
+		return nil, err
+	}
+	reader, err := encoded.Reader()
+	if err != nil {
+		return nil, err
 	}
-	return commitObject.Message, commitObject.Hash.String(), nil
+	defer reader.Close() //nolint:errcheck
+	return io.ReadAll(reader)
+}
+
+// gitCommitObject returns the encoded commit object for a commit checked out using the cache
+func (r *RepoCache) gitCommitObject(hash string) ([]byte, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, dir := range r.cache {
+		if dir.hash == hash && dir.commit != nil {
+			return dir.commit, true
+		}
+	}
+	return nil, false
 }
 
 func getUnusedRepoPath(targetDir, repoName string) string {
@@ -740,6 +831,25 @@ func getUnusedRepoPath(targetDir, repoName string) string {
 	}
 }
 
+// CachedCommitMessage returns the commit message of the branch checkout, if the branch was already
+// checked out through the cache. No git operations are done, empty is returned on a cache miss
+func (r *RepoCache) CachedCommitMessage(sourceUrl, branch, gitAuth string) string {
+	gitAuth = cmp.Or(gitAuth, r.server.Config().Security.DefaultGitAuth)
+	authEntry, err := r.server.loadGitKey(gitAuth)
+	if err != nil {
+		return ""
+	}
+	repo, _, err := parseGitUrl(sourceUrl, authEntry.usingSSH)
+	if err != nil {
+		return ""
+	}
+	dir, ok := r.getRepo(Repo{url: repo, branch: branch, auth: gitAuth})
+	if !ok {
+		return ""
+	}
+	return dir.commitMessage
+}
+
 func (r *RepoCache) Cleanup() {
 	r.mu.Lock()
 	sharedKeys := r.sharedKeys
@@ -4,6 +4,7 @@
 package server
 
 import (
+	"bytes"
 	"os"
 	"path/filepath"
 	"testing"
@@ -129,12 +130,15 @@ func TestMaterializeGitCommitFolder(t *testing.T) {
 	}
 
 	targetDir := t.TempDir()
-	message, gotHash, err := materializeGitCommit(sourceDir, targetDir, hash.String(), "apps/one/")
+	cacheDir, err := materializeGitCommit(sourceDir, targetDir, hash.String(), "apps/one/")
 	if err != nil {
 		t.Fatal(err)
 	}
-	if message != "fixture" || gotHash != hash.String() {
-		t.Fatalf("materialized commit = %q, %q; want fixture, %q", message, gotHash, hash)
+	if cacheDir.commitMessage != "fixture" || cacheDir.hash != hash.String() {
+		t.Fatalf("materialized commit = %q, %q; want fixture, %q", cacheDir.commitMessage, cacheDir.hash, hash)
+	}
+	if !bytes.Contains(cacheDir.commit, []byte("\n\nfixture")) {
+		t.Fatalf("encoded commit = %q, want the commit message", cacheDir.commit)
 	}
 	contents, err := os.ReadFile(filepath.Join(targetDir, "apps", "one", "app.star"))
 	if err != nil {
@@ -5,6 +5,7 @@ package server
 
 import (
 	"cmp"
+	"context"
 	"crypto/hmac"
 	"crypto/sha256"
 	"crypto/subtle"
@@ -31,6 +32,7 @@ import (
 const (
 	DRY_RUN_ARG              = "dryRun"
 	PROMOTE_ARG              = "promote"
+	CONFIRM_ARG              = "yes"
 	REAPPLY_ALL_ARG          = "reapplyAll"
 	DELEGATE_BUILD_OP        = "delegate_build"
 	MAX_DELEGATE_UPLOAD_SIZE = 512 << 20 // 512 MiB
@@ -141,7 +143,7 @@ func NewTCPHandler(logger *types.Logger, config *types.ServerConfig, server *Ser
 	router.Use(middleware.CleanPath)
 
 	if config.System.EnableCompression {
-		router.Use(middleware.Compress(5, COMPRESSION_ENABLED_MIME_TYPES...))
+		router.Use(compressMiddleware(config.System.CompressionMinSize, config.System.CompressionTypes))
 	}
 
 	if config.Builder.Mode == "delegate_server" {
@@ -278,7 +280,7 @@ func (h *Handler) callApp(w http.ResponseWriter, r *http.Request) {
 	// credential form - independent of MatchApp's FallbackUnknownDomains, and
 	// of whether the login form is currently enabled on this node
 	if h.server.formLogin.isReservedAuthHost(requestDomain) {
-		http.Error(w, "not found", http.StatusNotFound)
+		h.server.errorPage(w, r, http.StatusNotFound, "error.not_found")
 		return
 	}
 
@@ -302,7 +304,7 @@ func (h *Handler) callApp(w http.ResponseWriter, r *http.Request) {
 
 	if matchErr != nil && !serveListApps {
 		h.Error().Err(matchErr).Str("path", r.URL.Path).Msg("No app matched request")
-		http.Error(w, matchErr.Error(), http.StatusNotFound)
+		h.server.errorPage(w, r, http.StatusNotFound, "error.no_app")
 		return
 	}
 
@@ -316,7 +318,21 @@ func (h *Handler) callApp(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		r = newReq
-		serveApp, err = h.server.GetApp(r.Context(), matchedApp.AppPathDomain, true)
+		serveApp, err = h.server.GetApp(r.Context(), matchedApp.AppPathDomain, false)
+		if err == nil {
+			if serveApp.Settings.Inactive() {
+				h.server.inactiveAppPage(w, r, serveApp)
+				return
+			}
+			if outside, next := serveApp.OutsideActiveHours(time.Now()); outside {
+				h.server.outsideActiveHoursPage(w, r, serveApp, next)
+				return
+			}
+			// The container proxy holds the requests until a starting container is ready
+			if err = serveApp.InitializeServing(r.Context()); err != nil {
+				err = fmt.Errorf("error initializing app: %w", err)
+			}
+		}
 		if err != nil {
 			h.Error().Err(err).Str("path", r.URL.Path).Msg("Error getting app")
 			http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -464,6 +480,9 @@ func (h *Handler) apiHandler(w http.ResponseWriter, r *http.Request, enableBasic
 
 	h.Trace().Str("method", r.Method).Str("url", r.URL.String()).Err(err).Msg("API Received request")
 	if err != nil {
+		if conflict, ok := retriableConflictError(err); ok {
+			err = conflict
+		}
 		if reqError, ok := err.(types.RequestError); ok {
 			w.Header().Add("Content-Type", "application/json")
 			errStr, _ := json.Marshal(reqError)
@@ -482,6 +501,20 @@ func (h *Handler) apiHandler(w http.ResponseWriter, r *http.Request, enableBasic
 		w.WriteHeader(http.StatusOK)
 		return
 	}
+	if stream, ok := resp.(apiStream); ok {
+		writeAPIStream(w, r, stream)
+		return
+	}
+	if upgrade, ok := resp.(apiUpgrade); ok {
+		// The audit event is inserted after the stream ends, with the detail from the upgrade func
+		detail, err := serveAPIUpgrade(w, r.Header.Get("Upgrade"), upgrade)
+		event.Detail = detail
+		if err != nil {
+			event.Status = string(types.EventStatusFailure)
+			h.Warn().Err(err).Str("operation", operation).Msg("error in upgraded API connection")
+		}
+		return
+	}
 	w.Header().Add("Content-Type", "application/json")
 	err = json.NewEncoder(w).Encode(resp)
 	if err != nil {
@@ -525,10 +558,18 @@ func (h *Handler) webhookHandler(w http.ResponseWriter, r *http.Request, webhook
 	case types.WebhookPromote:
 		promote = true
 		appToken = app.Settings.WebhookTokens.Promote
+	case types.WebhookRegistry:
+		reload = true
+		appToken = app.Settings.WebhookTokens.Registry
+	case types.WebhookRegistryPromote:
+		reload = true
+		promote = true
+		appToken = app.Settings.WebhookTokens.RegistryPromote
 	default:
 		http.Error(w, fmt.Sprintf("Invalid webhook type %s", webhookType), http.StatusInternalServerError)
 		return
 	}
+	registry := webhookType == types.WebhookRegistry || webhookType == types.WebhookRegistryPromote
 
 	if appToken == "" {
 		http.Error(w, fmt.Sprintf("%s webhook is not enabled for app", webhookType), http.StatusBadRequest)
@@ -543,6 +584,10 @@ func (h *Handler) webhookHandler(w http.ResponseWriter, r *http.Request, webhook
 
 	// Authenticate the request
 	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" && registry && r.URL.Query().Get("token") != "" {
+		// Docker Hub webhooks cannot set headers, the token is passed as a query param
+		authHeader = "Bearer " + r.URL.Query().Get("token")
+	}
 	if authHeader != "" {
 		// Using Authentication header, bearer token — validate before reading body
 		if !strings.HasPrefix(authHeader, "Bearer ") {
@@ -609,10 +654,25 @@ func (h *Handler) webhookHandler(w http.ResponseWriter, r *http.Request, webhook
 		}
 	}()
 
-	h.Trace().Str("method", r.Method).Str("url", r.URL.String()).Msg("API Received request")
+	h.Trace().Str("method", r.Method).Str("url", redactWebhookToken(r)).Msg("API Received request")
 
 	var resp any
-	if reload && system.IsGit(app.SourceUrl) {
+	if registry {
+		// Reload only if the pushed image is the one used by the app
+		ignoreReason, err := checkRegistryPush(&app.Metadata, r.Header, body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if ignoreReason != "" {
+			h.Info().Msgf("Ignoring webhook call for %s, appPath: %s, %s", webhookType, appPath, ignoreReason)
+			event.Status = string(types.EventStatusSuccess)
+			event.Detail = "ignored: " + ignoreReason
+			w.Header().Add("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(map[string]string{"ignored": ignoreReason})
+			return
+		}
+	} else if reload && system.IsGit(app.SourceUrl) {
 		// validate branch name, it should match branch name in app metadata if app is using git
 		payload := map[string]any{}
 		err = json.Unmarshal(body, &payload)
@@ -646,8 +706,9 @@ func (h *Handler) webhookHandler(w http.ResponseWriter, r *http.Request, webhook
 	if reload {
 		resp, err = h.server.ReloadApps(r.Context(), appPath, false, false, promote, "", "", "", true, false)
 	} else {
-		// promote operation
-		resp, err = h.server.PromoteApps(r.Context(), appPath, false)
+		// promote operation. The webhook cannot confirm the promote, changes to permissions or container
+		// settings fail and have to be promoted using the CLI
+		resp, err = h.server.PromoteApps(r.Context(), appPath, false, false, false)
 	}
 
 	h.Info().Msgf("Webhook call for %s, appPath: %s, promote: %t, reload: %t, response %+v err %s",
@@ -720,11 +781,63 @@ func hashPayload(secret string, playloadBody []byte) string {
 	return fmt.Sprintf("%x", sum)
 }
 
+// parseIfVersion returns the app row version the update is conditional on, -1 if not set
+func parseIfVersion(r *http.Request) (int64, error) {
+	arg := r.URL.Query().Get("ifVersion")
+	if arg == "" {
+		return -1, nil
+	}
+	ifVersion, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil || ifVersion < 0 {
+		return 0, types.CreateRequestError(fmt.Sprintf("invalid ifVersion %s", arg), http.StatusBadRequest)
+	}
+	return ifVersion, nil
+}
+
+// retriableConflictError returns the app version conflict or app locked error wrapped in err, if any
+func retriableConflictError(err error) (types.RequestError, bool) {
+	var reqError types.RequestError
+	if errors.As(err, &reqError) && types.IsRetriableConflict(reqError) {
+		return reqError, true
+	}
+	return reqError, false
+}
+
+// badRequestError returns the error as a bad request error. App version conflicts and app locked
+// errors are returned as is, so that the client gets the conflict status and details to retry with
+func badRequestError(err error) error {
+	if conflict, ok := retriableConflictError(err); ok {
+		return conflict
+	}
+	return types.CreateRequestError(err.Error(), http.StatusBadRequest)
+}
+
+// promoteConfirmContext returns the request context with the promote changes confirmed when the
+// yes arg is set. Promote fails for permission and container setting changes unless confirmed
+func promoteConfirmContext(r *http.Request) (context.Context, error) {
+	confirm, err := parseBoolArg(r.URL.Query().Get(CONFIRM_ARG), false)
+	if err != nil {
+		return nil, err
+	}
+	return withPromoteState(r.Context(), confirm), nil
+}
+
 func parseBoolArg(arg string, defaultValue bool) (bool, error) {
 	if arg != "" {
 		ret, err := strconv.ParseBool(arg)
 		if err != nil {
-			return defaultValue, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+			return defaultValue, badRequestError(err)
+		}
+		return ret, nil
+	}
+	return defaultValue, nil
+}
+
+func parseIntArg(arg string, defaultValue int) (int, error) {
+	if arg != "" {
+		ret, err := strconv.Atoi(arg)
+		if err != nil {
+			return defaultValue, badRequestError(err)
 		}
 		return ret, nil
 	}
@@ -737,17 +850,55 @@ func (h *Handler) getApps(r *http.Request) (any, error) {
 	if err != nil {
 		return nil, err
 	}
+	detail, err := parseBoolArg(r.URL.Query().Get("detail"), false)
+	if err != nil {
+		return nil, err
+	}
 	updateTargetInContext(r, appPathGlob, false)
 	updateOperationInContext(r, "list_apps")
 
 	filteredApps, err := h.server.GetApps(r.Context(), appPathGlob, internal)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
+	}
+	if detail {
+		h.server.addStartTimings(filteredApps)
 	}
 
 	return &types.AppListResponse{Apps: filteredApps}, nil
 }
 
+func (h *Handler) searchApps(r *http.Request) (any, error) {
+	appPathGlob := r.URL.Query().Get("appPathGlob")
+	internal, err := parseBoolArg(r.URL.Query().Get("internal"), false)
+	if err != nil {
+		return nil, err
+	}
+	offset, err := parseIntArg(r.URL.Query().Get("offset"), 0)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := parseIntArg(r.URL.Query().Get("limit"), defaultSearchLimit)
+	if err != nil {
+		return nil, err
+	}
+	detail, err := parseBoolArg(r.URL.Query().Get("detail"), false)
+	if err != nil {
+		return nil, err
+	}
+	updateTargetInContext(r, appPathGlob, false)
+	updateOperationInContext(r, "search_apps")
+
+	ret, err := h.server.SearchApps(r.Context(), appPathGlob, r.URL.Query().Get("q"), internal, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	if detail {
+		h.server.addStartTimings(ret.Apps)
+	}
+	return ret, nil
+}
+
 func (h *Handler) stopServer(r *http.Request) (any, error) {
 	if err := h.server.enforceGlobalPerm(r.Context(), types.PermissionServerStop, ""); err != nil {
 		return nil, err
@@ -779,6 +930,59 @@ func (h *Handler) restartServer(r *http.Request) (any, error) {
 	return map[string]any{"status": "restarted, new process is serving"}, nil
 }
 
+func (h *Handler) pruneImages(r *http.Request) (any, error) {
+	keep, err := parseIntArg(r.URL.Query().Get("keep"), 0)
+	if err != nil {
+		return nil, err
+	}
+	dryRun, err := parseBoolArg(r.URL.Query().Get(DRY_RUN_ARG), false)
+	if err != nil {
+		return nil, err
+	}
+	if keep < 0 {
+		return nil, types.CreateRequestError(fmt.Sprintf("invalid keep count: %d", keep), http.StatusBadRequest)
+	}
+	updateOperationInContext(r, "prune_images")
+	return h.server.PruneImages(r.Context(), keep, dryRun)
+}
+
+func (h *Handler) listVolumes(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath != "" {
+		updateTargetInContext(r, appPath, false)
+	}
+	return h.server.ListVolumes(r.Context(), appPath)
+}
+
+func (h *Handler) backupVolume(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "backup_volume")
+	return h.server.BackupVolume(r.Context(), appPath, r.URL.Query().Get("volume"), r.URL.Query().Get("target"))
+}
+
+func (h *Handler) restoreVolume(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "restore_volume")
+	return h.server.RestoreVolume(r.Context(), appPath, r.URL.Query().Get("volume"), r.URL.Query().Get("source"))
+}
+
+func (h *Handler) gcVolumes(r *http.Request) (any, error) {
+	dryRun, err := parseBoolArg(r.URL.Query().Get(DRY_RUN_ARG), false)
+	if err != nil {
+		return nil, err
+	}
+	updateOperationInContext(r, "gc_volumes")
+	return h.server.GCVolumes(r.Context(), dryRun)
+}
+
 func (h *Handler) createApp(r *http.Request) (any, error) {
 	approve, err := parseBoolArg(r.URL.Query().Get("approve"), false)
 	if err != nil {
@@ -792,7 +996,7 @@ func (h *Handler) createApp(r *http.Request) (any, error) {
 	var appRequest types.CreateAppRequest
 	err = json.NewDecoder(r.Body).Decode(&appRequest)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	appPath := appRequest.Path
 	updateTargetInContext(r, appPath, dryRun)
@@ -800,7 +1004,7 @@ func (h *Handler) createApp(r *http.Request) (any, error) {
 
 	results, err := h.server.CreateApp(r.Context(), appPath, approve, dryRun, &appRequest)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 
 	return results, nil
@@ -821,7 +1025,32 @@ func (h *Handler) deleteApps(r *http.Request) (any, error) {
 
 	results, err := h.server.DeleteApps(r.Context(), appPathGlob, dryRun)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
+	}
+	return results, nil
+}
+
+func (h *Handler) listAppLocks(r *http.Request) (any, error) {
+	updateOperationInContext(r, "list_app_locks")
+	return h.server.ListAppLocks(r.Context())
+}
+
+func (h *Handler) unlockApps(r *http.Request) (any, error) {
+	appPathGlob := r.URL.Query().Get("appPathGlob")
+	dryRun, err := parseBoolArg(r.URL.Query().Get(DRY_RUN_ARG), false)
+	if err != nil {
+		return nil, err
+	}
+
+	if appPathGlob == "" {
+		return nil, types.CreateRequestError("appPathGlob is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPathGlob, dryRun)
+	updateOperationInContext(r, "unlock_apps")
+
+	results, err := h.server.UnlockApps(r.Context(), appPathGlob, dryRun)
+	if err != nil {
+		return nil, badRequestError(err)
 	}
 	return results, nil
 }
@@ -842,8 +1071,12 @@ func (h *Handler) approveApps(r *http.Request) (any, error) {
 		return nil, types.CreateRequestError("appPathGlob is required", http.StatusBadRequest)
 	}
 	updateOperationInContext(r, genOperationName("approve_apps", promote, false))
+	ctx, err := promoteConfirmContext(r)
+	if err != nil {
+		return nil, err
+	}
 
-	approveResult, err := h.server.ApproveApps(r.Context(), appPathGlob, dryRun, promote)
+	approveResult, err := h.server.ApproveApps(ctx, appPathGlob, dryRun, promote)
 	return approveResult, err
 }
 
@@ -863,13 +1096,17 @@ func (h *Handler) accountLink(r *http.Request) (any, error) {
 		return nil, types.CreateRequestError("appPathGlob is required", http.StatusBadRequest)
 	}
 	updateOperationInContext(r, genOperationName("account_link", promote, false))
+	ctx, err := promoteConfirmContext(r)
+	if err != nil {
+		return nil, err
+	}
 
 	args := map[string]any{
 		"plugin":  r.URL.Query().Get("plugin"),
 		"account": r.URL.Query().Get("account"),
 	}
 
-	linkResult, err := h.server.StagedUpdate(r.Context(), appPathGlob, dryRun, promote, h.server.accountLinkHandler, args, "account-link")
+	linkResult, err := h.server.StagedUpdate(ctx, appPathGlob, dryRun, promote, h.server.accountLinkHandler, args, "account-link")
 	return linkResult, err
 }
 
@@ -885,12 +1122,16 @@ func (h *Handler) updateParam(r *http.Request) (any, error) {
 		return nil, err
 	}
 	updateOperationInContext(r, genOperationName("update_params", promote, false))
+	ctx, err := promoteConfirmContext(r)
+	if err != nil {
+		return nil, err
+	}
 
 	if appPathGlob == "" {
 		return nil, types.CreateRequestError("appPathGlob is required", http.StatusBadRequest)
 	}
 
-	updateResult, err := h.server.UpdateAppParams(r.Context(), appPathGlob, dryRun, promote,
+	updateResult, err := h.server.UpdateAppParams(ctx, appPathGlob, dryRun, promote,
 		r.URL.Query().Get("paramName"), r.URL.Query().Get("paramValue"))
 	return updateResult, err
 }
@@ -925,11 +1166,15 @@ func (h *Handler) reloadApps(r *http.Request) (any, error) {
 		return nil, err
 	}
 	updateOperationInContext(r, genOperationName("reload_apps", promote, approve))
+	ctx, err := promoteConfirmContext(r)
+	if err != nil {
+		return nil, err
+	}
 
-	ret, err := h.server.ReloadApps(r.Context(), appPathGlob, approve, dryRun, promote,
+	ret, err := h.server.ReloadApps(ctx, appPathGlob, approve, dryRun, promote,
 		r.URL.Query().Get("branch"), r.URL.Query().Get("commit"), r.URL.Query().Get("gitAuth"), forceReload, verify)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 
 	return ret, nil
@@ -947,10 +1192,19 @@ func (h *Handler) promoteApps(r *http.Request) (any, error) {
 		return nil, types.CreateRequestError("appPathGlob is required", http.StatusBadRequest)
 	}
 	updateOperationInContext(r, genOperationName("promote_apps", false, false))
+	contractCheck, err := parseBoolArg(r.URL.Query().Get("contractCheck"), false)
+	if err != nil {
+		return nil, err
+	}
+
+	confirm, err := parseBoolArg(r.URL.Query().Get(CONFIRM_ARG), false)
+	if err != nil {
+		return nil, err
+	}
 
-	ret, err := h.server.PromoteApps(r.Context(), appPathGlob, dryRun)
+	ret, err := h.server.PromoteApps(r.Context(), appPathGlob, dryRun, contractCheck, confirm)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 
 	return ret, nil
@@ -978,7 +1232,7 @@ func (h *Handler) previewApp(r *http.Request) (any, error) {
 
 	ret, err := h.server.PreviewApp(r.Context(), appPath, commitId, approve, dryRun)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 
 	return ret, nil
@@ -994,7 +1248,7 @@ func (h *Handler) getApp(r *http.Request) (any, error) {
 
 	ret, err := h.server.GetAppApi(r.Context(), appPath)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 
 	return ret, nil
@@ -1013,21 +1267,26 @@ func (h *Handler) updateAppSettings(r *http.Request) (any, error) {
 	updateTargetInContext(r, appPathGlob, dryRun)
 	updateOperationInContext(r, genOperationName("update_settings", false, false))
 
+	ifVersion, err := parseIfVersion(r)
+	if err != nil {
+		return nil, err
+	}
+
 	var updateAppRequest types.UpdateAppRequest
 	err = json.NewDecoder(r.Body).Decode(&updateAppRequest)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 
-	ret, err := h.server.UpdateAppSettings(r.Context(), appPathGlob, dryRun, updateAppRequest)
+	ret, err := h.server.UpdateAppSettings(r.Context(), appPathGlob, dryRun, ifVersion, updateAppRequest)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 
 	return ret, nil
 }
 
-func (h *Handler) updateAppMetadata(r *http.Request) (any, error) {
+func (h *Handler) patchAppSettings(r *http.Request) (any, error) {
 	appPathGlob := r.URL.Query().Get("appPathGlob")
 	dryRun, err := parseBoolArg(r.URL.Query().Get(DRY_RUN_ARG), false)
 	if err != nil {
@@ -1042,148 +1301,711 @@ func (h *Handler) updateAppMetadata(r *http.Request) (any, error) {
 		return nil, types.CreateRequestError("appPathGlob is required", http.StatusBadRequest)
 	}
 	updateTargetInContext(r, appPathGlob, dryRun)
-	updateOperationInContext(r, genOperationName("update_metadata", promote, false))
+	updateOperationInContext(r, genOperationName("patch_settings", promote, false))
+	ctx, err := promoteConfirmContext(r)
+	if err != nil {
+		return nil, err
+	}
 
-	var updateAppRequest types.UpdateAppMetadataRequest
-	err = json.NewDecoder(r.Body).Decode(&updateAppRequest)
+	ifVersion, err := parseIfVersion(r)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, err
 	}
 
-	args := map[string]any{
-		"metadata": updateAppRequest,
-		"dryRun":   dryRun,
+	var patch map[string]any
+	if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+		return nil, types.CreateRequestError(fmt.Sprintf("invalid settings patch: %s", err), http.StatusBadRequest)
 	}
 
-	updateResult, err := h.server.StagedUpdate(r.Context(), appPathGlob, dryRun, promote, h.server.updateMetadataHandler, args, "update_metadata")
-	return updateResult, err
+	ret, err := h.server.PatchAppSettings(ctx, appPathGlob, dryRun, promote, ifVersion, patch)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+	return ret, nil
+}
+
+func (h *Handler) transferApps(r *http.Request) (any, error) {
+	appPathGlob := r.URL.Query().Get("appPathGlob")
+	dryRun, err := parseBoolArg(r.URL.Query().Get(DRY_RUN_ARG), false)
+	if err != nil {
+		return nil, err
+	}
+
+	if appPathGlob == "" {
+		return nil, types.CreateRequestError("appPathGlob is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPathGlob, dryRun)
+	updateOperationInContext(r, "transfer_apps")
 
+	ret, err := h.server.TransferApps(r.Context(), appPathGlob, dryRun, r.URL.Query().Get("to"))
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+	return ret, nil
 }
 
-func (h *Handler) versionList(r *http.Request) (any, error) {
-	appPath := r.URL.Query().Get("appPath")
-	if appPath == "" {
-		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+func (h *Handler) deprecateApps(r *http.Request) (any, error) {
+	appPathGlob := r.URL.Query().Get("appPathGlob")
+	dryRun, err := parseBoolArg(r.URL.Query().Get(DRY_RUN_ARG), false)
+	if err != nil {
+		return nil, err
+	}
+	undo, err := parseBoolArg(r.URL.Query().Get("undo"), false)
+	if err != nil {
+		return nil, err
 	}
-	updateTargetInContext(r, appPath, false)
-	updateOperationInContext(r, genOperationName("version_list", false, false))
 
-	ret, err := h.server.VersionList(r.Context(), appPath)
+	if appPathGlob == "" {
+		return nil, types.CreateRequestError("appPathGlob is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPathGlob, dryRun)
+	updateOperationInContext(r, "deprecate_apps")
+
+	var deleteAfter *time.Time
+	if value := r.URL.Query().Get("deleteAfter"); value != "" {
+		parsed, err := parseDeleteAfter(value)
+		if err != nil {
+			return nil, badRequestError(err)
+		}
+		deleteAfter = &parsed
+	}
+
+	ret, err := h.server.DeprecateApps(r.Context(), appPathGlob, dryRun, r.URL.Query().Get("message"), deleteAfter, undo)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+	return ret, nil
+}
+
+func (h *Handler) pauseApps(r *http.Request) (any, error) {
+	appPathGlob := r.URL.Query().Get("appPathGlob")
+	dryRun, err := parseBoolArg(r.URL.Query().Get(DRY_RUN_ARG), false)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, err
+	}
+	undo, err := parseBoolArg(r.URL.Query().Get("undo"), false)
+	if err != nil {
+		return nil, err
+	}
+
+	if appPathGlob == "" {
+		return nil, types.CreateRequestError("appPathGlob is required", http.StatusBadRequest)
 	}
+	updateTargetInContext(r, appPathGlob, dryRun)
+	updateOperationInContext(r, "pause_apps")
 
+	ret, err := h.server.PauseApps(r.Context(), appPathGlob, dryRun, r.URL.Query().Get("message"), undo)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
 	return ret, nil
 }
 
-func (h *Handler) versionFiles(r *http.Request) (any, error) {
-	appPath := r.URL.Query().Get("appPath")
-	if appPath == "" {
-		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+func (h *Handler) archiveApps(r *http.Request) (any, error) {
+	appPathGlob := r.URL.Query().Get("appPathGlob")
+	dryRun, err := parseBoolArg(r.URL.Query().Get(DRY_RUN_ARG), false)
+	if err != nil {
+		return nil, err
+	}
+	undo, err := parseBoolArg(r.URL.Query().Get("undo"), false)
+	if err != nil {
+		return nil, err
+	}
+
+	if appPathGlob == "" {
+		return nil, types.CreateRequestError("appPathGlob is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPathGlob, dryRun)
+	updateOperationInContext(r, "archive_apps")
+
+	ret, err := h.server.ArchiveApps(r.Context(), appPathGlob, dryRun, undo)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+	return ret, nil
+}
+
+func (h *Handler) updateAppMetadata(r *http.Request) (any, error) {
+	appPathGlob := r.URL.Query().Get("appPathGlob")
+	dryRun, err := parseBoolArg(r.URL.Query().Get(DRY_RUN_ARG), false)
+	if err != nil {
+		return nil, err
+	}
+	promote, err := parseBoolArg(r.URL.Query().Get(PROMOTE_ARG), false)
+	if err != nil {
+		return nil, err
+	}
+
+	if appPathGlob == "" {
+		return nil, types.CreateRequestError("appPathGlob is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPathGlob, dryRun)
+	updateOperationInContext(r, genOperationName("update_metadata", promote, false))
+	ctx, err := promoteConfirmContext(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var updateAppRequest types.UpdateAppMetadataRequest
+	err = json.NewDecoder(r.Body).Decode(&updateAppRequest)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+
+	args := map[string]any{
+		"metadata": updateAppRequest,
+		"dryRun":   dryRun,
+	}
+
+	updateResult, err := h.server.StagedUpdate(ctx, appPathGlob, dryRun, promote, h.server.updateMetadataHandler, args, "update_metadata")
+	return updateResult, err
+
+}
+
+func (h *Handler) versionList(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, genOperationName("version_list", false, false))
+
+	ret, err := h.server.VersionList(r.Context(), appPath)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+
+	return ret, nil
+}
+
+func (h *Handler) versionFiles(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	version := r.URL.Query().Get("version")
+	updateOperationInContext(r, genOperationName("version_files", false, false))
+
+	ret, err := h.server.VersionFiles(r.Context(), appPath, version)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+
+	return ret, nil
+}
+
+func (h *Handler) versionSwitch(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	version := r.URL.Query().Get("version")
+	dryRun, err := parseBoolArg(r.URL.Query().Get(DRY_RUN_ARG), false)
+	if err != nil {
+		return nil, err
+	}
+	updateTargetInContext(r, appPath, dryRun)
+	updateOperationInContext(r, genOperationName("version_switch", false, false))
+
+	ret, err := h.server.VersionSwitch(r.Context(), appPath, dryRun, version)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+
+	return ret, nil
+}
+
+func (h *Handler) tokenList(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "token_list")
+
+	ret, err := h.server.TokenList(r.Context(), appPath)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+
+	return ret, nil
+}
+
+func (h *Handler) tokenCreate(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+
+	dryRun, err := parseBoolArg(r.URL.Query().Get(DRY_RUN_ARG), false)
+	if err != nil {
+		return nil, err
+	}
+	updateTargetInContext(r, appPath, dryRun)
+	updateOperationInContext(r, "token_create")
+
+	tokenType := r.URL.Query().Get("webhookType")
+	if appPath == "" {
+		return nil, types.CreateRequestError("webhookType is required", http.StatusBadRequest)
+	}
+
+	ret, err := h.server.TokenCreate(r.Context(), appPath, types.WebhookType(tokenType), dryRun)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+
+	return ret, nil
+}
+
+func (h *Handler) tokenDelete(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+
+	dryRun, err := parseBoolArg(r.URL.Query().Get(DRY_RUN_ARG), false)
+	if err != nil {
+		return nil, err
+	}
+	updateTargetInContext(r, appPath, dryRun)
+	updateOperationInContext(r, "token_delete")
+
+	tokenType := r.URL.Query().Get("webhookType")
+	if appPath == "" {
+		return nil, types.CreateRequestError("webhookType is required", http.StatusBadRequest)
+	}
+
+	ret, err := h.server.TokenDelete(r.Context(), appPath, types.WebhookType(tokenType), dryRun)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+
+	return ret, nil
+}
+
+func (h *Handler) captureStart(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "capture_start")
+
+	durationSecs, err := parseIntArg(r.URL.Query().Get("durationSecs"), 0)
+	if err != nil {
+		return nil, err
+	}
+	maxEntries, err := parseIntArg(r.URL.Query().Get("maxEntries"), 0)
+	if err != nil {
+		return nil, err
+	}
+	maxBodyBytes, err := parseIntArg(r.URL.Query().Get("maxBodyBytes"), 0)
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := h.server.CaptureStart(r.Context(), appPath, app.CaptureOptions{
+		Duration:     time.Duration(durationSecs) * time.Second,
+		MaxEntries:   maxEntries,
+		MaxBodyBytes: maxBodyBytes,
+	})
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+	return ret, nil
+}
+
+func (h *Handler) captureStop(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "capture_stop")
+
+	clearEntries, err := parseBoolArg(r.URL.Query().Get("clear"), false)
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := h.server.CaptureStop(r.Context(), appPath, clearEntries)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+	return ret, nil
+}
+
+func (h *Handler) captureDownload(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "capture_download")
+
+	ret, err := h.server.CaptureDownload(r.Context(), appPath)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+	return ret, nil
+}
+
+func (h *Handler) captureReplay(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "capture_replay")
+
+	includeWrites, err := parseBoolArg(r.URL.Query().Get("includeWrites"), false)
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := h.server.CaptureReplay(r.Context(), appPath, includeWrites)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+	return ret, nil
+}
+
+func (h *Handler) profileStart(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "profile_start")
+
+	durationSecs, err := parseIntArg(r.URL.Query().Get("durationSecs"), 0)
+	if err != nil {
+		return nil, err
+	}
+	maxProfiles, err := parseIntArg(r.URL.Query().Get("maxProfiles"), 0)
+	if err != nil {
+		return nil, err
+	}
+	sampleRate := 0.0
+	if rate := r.URL.Query().Get("sampleRate"); rate != "" {
+		if sampleRate, err = strconv.ParseFloat(rate, 64); err != nil || sampleRate < 0 || sampleRate > 1 {
+			return nil, types.CreateRequestError("sampleRate has to be a number between 0 and 1", http.StatusBadRequest)
+		}
+	}
+
+	ret, err := h.server.ProfileStart(r.Context(), appPath, app.ProfileOptions{
+		Duration:    time.Duration(durationSecs) * time.Second,
+		SampleRate:  sampleRate,
+		MaxProfiles: maxProfiles,
+	})
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+	return ret, nil
+}
+
+func (h *Handler) profileStop(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "profile_stop")
+
+	clearProfiles, err := parseBoolArg(r.URL.Query().Get("clear"), false)
+	if err != nil {
+		return nil, err
+	}
+
+	ret, err := h.server.ProfileStop(r.Context(), appPath, clearProfiles)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+	return ret, nil
+}
+
+func (h *Handler) profileDownload(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "profile_download")
+
+	ret, err := h.server.ProfileDownload(r.Context(), appPath)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+	return ret, nil
+}
+
+func (h *Handler) listJobs(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "list_jobs")
+
+	limit, err := parseIntArg(r.URL.Query().Get("limit"), defaultJobLimit)
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, err := h.server.ListJobs(r.Context(), appPath, types.JobStatus(r.URL.Query().Get("status")), limit)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+	return &types.JobListResponse{Jobs: jobs}, nil
+}
+
+func (h *Handler) appLogs(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "app_logs")
+
+	follow, err := parseBoolArg(r.URL.Query().Get("follow"), false)
+	if err != nil {
+		return nil, err
+	}
+	lines, err := parseIntArg(r.URL.Query().Get("lines"), defaultAppLogLines)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := h.server.AppLogs(r.Context(), appPath, follow, r.URL.Query().Get("since"), r.URL.Query().Get("grep"), lines)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+	return stream, nil
+}
+
+func (h *Handler) appContainerLogs(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "app_container_logs")
+
+	follow, err := parseBoolArg(r.URL.Query().Get("follow"), false)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := parseBoolArg(r.URL.Query().Get("raw"), false)
+	if err != nil {
+		return nil, err
+	}
+	tail, err := parseIntArg(r.URL.Query().Get("tail"), defaultContainerLogLines)
+	if err != nil {
+		return nil, err
+	}
+
+	stream, err := h.server.AppContainerLogs(r.Context(), appPath, r.URL.Query().Get("service"), tail, follow, raw)
+	if err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
+func (h *Handler) appExec(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "app_exec")
+	if !strings.EqualFold(r.Header.Get("Upgrade"), system.EXEC_PROTOCOL) {
+		return nil, types.CreateRequestError("the request has to upgrade to the "+system.EXEC_PROTOCOL+" protocol", http.StatusBadRequest)
+	}
+
+	stdin, err := parseBoolArg(r.URL.Query().Get("stdin"), false)
+	if err != nil {
+		return nil, err
+	}
+	upgrade, err := h.server.AppExec(r.Context(), appPath, r.URL.Query().Get("service"), r.URL.Query()["cmd"], stdin)
+	if err != nil {
+		return nil, err
+	}
+	return upgrade, nil
+}
+
+func (h *Handler) appStats(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "app_stats")
+
+	ret, err := h.server.AppStats(r.Context(), appPath)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+	return ret, nil
+}
+
+func (h *Handler) runE2ETests(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "e2e_test")
+
+	var e2eRequest types.E2ERequest
+	if err := json.NewDecoder(r.Body).Decode(&e2eRequest); err != nil {
+		return nil, badRequestError(err)
+	}
+
+	ret, err := h.server.RunE2ETests(r.Context(), appPath, &e2eRequest)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+	return ret, nil
+}
+
+func (h *Handler) runGoldenTests(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	if appPath == "" {
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "golden_test")
+
+	var goldenRequest types.GoldenRequest
+	if err := json.NewDecoder(r.Body).Decode(&goldenRequest); err != nil {
+		return nil, badRequestError(err)
 	}
-	updateTargetInContext(r, appPath, false)
-	version := r.URL.Query().Get("version")
-	updateOperationInContext(r, genOperationName("version_files", false, false))
 
-	ret, err := h.server.VersionFiles(r.Context(), appPath, version)
+	ret, err := h.server.RunGoldenTests(r.Context(), appPath, &goldenRequest)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
-
 	return ret, nil
 }
 
-func (h *Handler) versionSwitch(r *http.Request) (any, error) {
+// appRepl is the handler for the app REPL API, which evaluates the input in a REPL session
+func (h *Handler) appRepl(r *http.Request) (any, error) {
 	appPath := r.URL.Query().Get("appPath")
 	if appPath == "" {
 		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
 	}
-	version := r.URL.Query().Get("version")
-	dryRun, err := parseBoolArg(r.URL.Query().Get(DRY_RUN_ARG), false)
-	if err != nil {
-		return nil, err
-	}
-	updateTargetInContext(r, appPath, dryRun)
-	updateOperationInContext(r, genOperationName("version_switch", false, false))
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "app_repl")
+	mode := types.ReplMode(r.URL.Query().Get("mode"))
 
-	ret, err := h.server.VersionSwitch(r.Context(), appPath, dryRun, version)
-	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+	var replRequest types.AppReplRequest
+	if err := json.NewDecoder(r.Body).Decode(&replRequest); err != nil {
+		return nil, badRequestError(err)
 	}
+	return h.server.AppRepl(r.Context(), appPath, mode, &replRequest)
+}
 
-	return ret, nil
+func (h *Handler) closeAppRepl(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
+	sessionId := r.URL.Query().Get("sessionId")
+	if appPath == "" || sessionId == "" {
+		return nil, types.CreateRequestError("appPath and sessionId are required", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "close_app_repl")
+	if err := h.server.CloseAppRepl(r.Context(), appPath, sessionId); err != nil {
+		return nil, err
+	}
+	return map[string]any{}, nil
 }
 
-func (h *Handler) tokenList(r *http.Request) (any, error) {
+func (h *Handler) runContractChecks(r *http.Request) (any, error) {
 	appPath := r.URL.Query().Get("appPath")
 	if appPath == "" {
 		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
 	}
 	updateTargetInContext(r, appPath, false)
-	updateOperationInContext(r, "token_list")
+	updateOperationInContext(r, "contract_check")
 
-	ret, err := h.server.TokenList(r.Context(), appPath)
+	ret, err := h.server.RunContractChecks(r.Context(), appPath)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
-
 	return ret, nil
 }
 
-func (h *Handler) tokenCreate(r *http.Request) (any, error) {
+func (h *Handler) checkApp(r *http.Request) (any, error) {
 	appPath := r.URL.Query().Get("appPath")
 	if appPath == "" {
 		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
 	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "app_check")
 
-	dryRun, err := parseBoolArg(r.URL.Query().Get(DRY_RUN_ARG), false)
+	a11y, err := parseBoolArg(r.URL.Query().Get("a11y"), false)
 	if err != nil {
 		return nil, err
 	}
-	updateTargetInContext(r, appPath, dryRun)
-	updateOperationInContext(r, "token_create")
-
-	tokenType := r.URL.Query().Get("webhookType")
-	if appPath == "" {
-		return nil, types.CreateRequestError("webhookType is required", http.StatusBadRequest)
+	links, err := parseBoolArg(r.URL.Query().Get("links"), false)
+	if err != nil {
+		return nil, err
 	}
-
-	ret, err := h.server.TokenCreate(r.Context(), appPath, types.WebhookType(tokenType), dryRun)
+	if !a11y && !links {
+		a11y, links = true, true
+	}
+	maxPages, err := parseIntArg(r.URL.Query().Get("maxPages"), 0)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, err
 	}
 
+	ret, err := h.server.CheckApp(r.Context(), appPath, a11y, links, maxPages)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
 	return ret, nil
 }
 
-func (h *Handler) tokenDelete(r *http.Request) (any, error) {
+func (h *Handler) listCrons(r *http.Request) (any, error) {
 	appPath := r.URL.Query().Get("appPath")
 	if appPath == "" {
 		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
 	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "list_crons")
 
-	dryRun, err := parseBoolArg(r.URL.Query().Get(DRY_RUN_ARG), false)
+	crons, err := h.server.ListCrons(r.Context(), appPath)
 	if err != nil {
-		return nil, err
+		return nil, badRequestError(err)
 	}
-	updateTargetInContext(r, appPath, dryRun)
-	updateOperationInContext(r, "token_delete")
+	return &types.CronListResponse{Crons: crons}, nil
+}
 
-	tokenType := r.URL.Query().Get("webhookType")
+func (h *Handler) showAppConfig(r *http.Request) (any, error) {
+	appPath := r.URL.Query().Get("appPath")
 	if appPath == "" {
-		return nil, types.CreateRequestError("webhookType is required", http.StatusBadRequest)
+		return nil, types.CreateRequestError("appPath is required", http.StatusBadRequest)
 	}
-
-	ret, err := h.server.TokenDelete(r.Context(), appPath, types.WebhookType(tokenType), dryRun)
+	effective, err := parseBoolArg(r.URL.Query().Get("effective"), false)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, err
 	}
+	updateTargetInContext(r, appPath, false)
+	updateOperationInContext(r, "show_app_config")
 
+	ret, err := h.server.ShowAppConfig(r.Context(), appPath, effective)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
 	return ret, nil
 }
 
 // apply is the handler for the apply API to apply app config
 func (h *Handler) apply(r *http.Request) (any, error) {
+	if planId := r.URL.Query().Get("planId"); planId != "" {
+		return h.executeApplyPlan(r, planId)
+	}
+
 	appPathGlob := r.URL.Query().Get("appPathGlob")
 	if appPathGlob == "" {
 		return nil, types.CreateRequestError("appPathGlob is required", http.StatusBadRequest)
@@ -1220,16 +2042,56 @@ func (h *Handler) apply(r *http.Request) (any, error) {
 		return nil, err
 	}
 	updateOperationInContext(r, genOperationName("apply", promote, approve))
+	ctx, err := promoteConfirmContext(r)
+	if err != nil {
+		return nil, err
+	}
 
 	dev, err := parseBoolArg(r.URL.Query().Get("dev"), false)
 	if err != nil {
 		return nil, err
 	}
 
-	ret, _, err := h.server.Apply(r.Context(), types.Transaction{}, applyPath, appPathGlob, approve, dryRun, promote,
+	// The repo cache is shared with the notification, for reading the commit message
+	repoCache, err := NewRepoCache(h.server)
+	if err != nil {
+		return nil, err
+	}
+	defer repoCache.Cleanup()
+
+	branch, gitAuth := r.URL.Query().Get("branch"), r.URL.Query().Get("gitAuth")
+	plan, err := parseBoolArg(r.URL.Query().Get("plan"), false)
+	if err != nil {
+		return nil, err
+	}
+	if plan {
+		updateOperationInContext(r, "apply_plan")
+		options := types.ApplyPlanOptions{
+			Approve:     approve,
+			Promote:     promote,
+			Reload:      types.AppReloadOption(r.URL.Query().Get("reload")),
+			Branch:      branch,
+			Commit:      r.URL.Query().Get("commit"),
+			GitAuth:     gitAuth,
+			Clobber:     clobber,
+			ForceReload: forceReload,
+			Verify:      verify,
+			Dev:         dev,
+		}
+		ret, err := h.server.PlanApply(r.Context(), applyPath, appPathGlob, options, repoCache)
+		if err != nil {
+			return nil, types.CreateRequestError(err.Error(), http.StatusInternalServerError)
+		}
+		return ret, nil
+	}
+
+	ret, _, err := h.server.Apply(ctx, types.Transaction{}, applyPath, appPathGlob, approve, dryRun, promote,
 		types.AppReloadOption(r.URL.Query().Get("reload")),
-		r.URL.Query().Get("branch"), r.URL.Query().Get("commit"), r.URL.Query().Get("gitAuth"),
-		clobber, forceReload, verify, "", nil, dev)
+		branch, r.URL.Query().Get("commit"), gitAuth,
+		clobber, forceReload, verify, "", repoCache, dev)
+	if !dryRun {
+		h.server.notifyApply(r.Context(), applyPath, branch, gitAuth, dev, ret, err, repoCache)
+	}
 	if err != nil {
 		return nil, types.CreateRequestError(err.Error(), http.StatusInternalServerError)
 	}
@@ -1237,6 +2099,42 @@ func (h *Handler) apply(r *http.Request) (any, error) {
 	return ret, nil
 }
 
+// executeApplyPlan is the handler for applying a plan saved by apply with the plan option
+func (h *Handler) executeApplyPlan(r *http.Request, planId string) (any, error) {
+	execute, err := parseBoolArg(r.URL.Query().Get("execute"), false)
+	if err != nil {
+		return nil, err
+	}
+	if !execute {
+		return nil, types.CreateRequestError("execute is required to apply a plan", http.StatusBadRequest)
+	}
+	updateTargetInContext(r, planId, false)
+	updateOperationInContext(r, "apply_plan_execute")
+	ctx, err := promoteConfirmContext(r)
+	if err != nil {
+		return nil, err
+	}
+
+	repoCache, err := NewRepoCache(h.server)
+	if err != nil {
+		return nil, err
+	}
+	defer repoCache.Cleanup()
+
+	ret, plan, err := h.server.ExecuteApplyPlan(ctx, planId, repoCache)
+	if plan != nil {
+		h.server.notifyApply(r.Context(), plan.ApplyPath, plan.Options.Branch, plan.Options.GitAuth, plan.Options.Dev,
+			ret, err, repoCache)
+	}
+	if err != nil {
+		if reqError, ok := err.(types.RequestError); ok {
+			return nil, reqError
+		}
+		return nil, types.CreateRequestError(err.Error(), http.StatusInternalServerError)
+	}
+	return ret, nil
+}
+
 // export is the handler for the export API which writes the current app and
 // binding state as a declarative config file
 func (h *Handler) export(r *http.Request) (any, error) {
@@ -1260,11 +2158,20 @@ func (h *Handler) export(r *http.Request) (any, error) {
 	}
 	config, err := h.server.Export(r.Context(), appPathGlob, options)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return &types.AppExportResponse{Config: config}, nil
 }
 
+// dependencyGraph is the handler for the API which returns the dependency graph
+// between the apps and the resources they use
+func (h *Handler) dependencyGraph(r *http.Request) (any, error) {
+	appPathGlob := cmp.Or(r.URL.Query().Get("appPathGlob"), "all")
+	updateTargetInContext(r, appPathGlob, false)
+	updateOperationInContext(r, "dependency_graph")
+	return h.server.DependencyGraph(r.Context(), appPathGlob, r.URL.Query().Get("resource"))
+}
+
 // prettyPrint is the handler for the pretty-print API which reformats an
 // existing declarative config file
 func (h *Handler) prettyPrint(r *http.Request) (any, error) {
@@ -1277,7 +2184,7 @@ func (h *Handler) prettyPrint(r *http.Request) (any, error) {
 
 	config, err := h.server.PrettyPrint(r.Context(), applyPath)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return &types.AppExportResponse{Config: config}, nil
 }
@@ -1296,14 +2203,14 @@ func (h *Handler) createSyncEntry(r *http.Request) (any, error) {
 	var sync types.SyncMetadata
 	err = json.NewDecoder(r.Body).Decode(&sync)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	updateTargetInContext(r, path, dryRun)
 	updateOperationInContext(r, "sync_create")
 
 	results, err := h.server.CreateSyncEntry(r.Context(), path, scheduled, dryRun, &sync)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 
 	return results, nil
@@ -1321,7 +2228,7 @@ func (h *Handler) runSyncEntry(r *http.Request) (any, error) {
 
 	results, err := h.server.RunSync(r.Context(), id, dryRun)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 
 	return results, nil
@@ -1343,9 +2250,32 @@ func (h *Handler) deleteSyncEntry(r *http.Request) (any, error) {
 
 	results, err := h.server.DeleteSyncEntry(r.Context(), id, dryRun)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
+	}
+
+	return results, nil
+}
+
+func (h *Handler) getSyncHistory(r *http.Request) (any, error) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		return nil, types.CreateRequestError("id is required", http.StatusBadRequest)
+	}
+	offset, err := parseIntArg(r.URL.Query().Get("offset"), 0)
+	if err != nil {
+		return nil, err
+	}
+	limit, err := parseIntArg(r.URL.Query().Get("limit"), defaultSyncHistoryLimit)
+	if err != nil {
+		return nil, err
 	}
 
+	updateTargetInContext(r, id, false)
+	updateOperationInContext(r, "sync_history")
+	results, err := h.server.GetSyncHistory(r.Context(), id, offset, limit)
+	if err != nil {
+		return nil, badRequestError(err)
+	}
 	return results, nil
 }
 
@@ -1353,12 +2283,42 @@ func (h *Handler) listSyncEntries(r *http.Request) (any, error) {
 	updateOperationInContext(r, "list_sync")
 	results, err := h.server.ListSyncEntries(r.Context())
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 
 	return results, nil
 }
 
+func (h *Handler) getSyncWebhookSecret(r *http.Request) (any, error) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		return nil, types.CreateRequestError("id is required", http.StatusBadRequest)
+	}
+	updateOperationInContext(r, "sync_webhook_secret")
+	updateTargetInContext(r, id, false)
+	return h.server.GetSyncWebhookSecret(r.Context(), id)
+}
+
+func (h *Handler) listKeyring(r *http.Request) (any, error) {
+	updateOperationInContext(r, "keyring_list")
+	return h.server.ListKeyring(r.Context())
+}
+
+func (h *Handler) rotateKeyring(r *http.Request) (any, error) {
+	updateOperationInContext(r, "keyring_rotate")
+	return h.server.RotateKeyring(r.Context())
+}
+
+func (h *Handler) removeKeyringKey(r *http.Request) (any, error) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		return nil, types.CreateRequestError("id is required", http.StatusBadRequest)
+	}
+	updateOperationInContext(r, "keyring_remove")
+	updateTargetInContext(r, id, false)
+	return h.server.RemoveKeyringKey(r.Context(), id)
+}
+
 func (h *Handler) createService(r *http.Request) (any, error) {
 	dryRun, err := parseBoolArg(r.URL.Query().Get(DRY_RUN_ARG), false)
 	if err != nil {
@@ -1367,7 +2327,7 @@ func (h *Handler) createService(r *http.Request) (any, error) {
 
 	var service types.Service
 	if err = json.NewDecoder(r.Body).Decode(&service); err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	if service.Name == "" || service.ServiceType == "" {
 		return nil, types.CreateRequestError("name and service_type are required", http.StatusBadRequest)
@@ -1377,7 +2337,7 @@ func (h *Handler) createService(r *http.Request) (any, error) {
 	updateOperationInContext(r, "service_create")
 
 	if err := h.server.CreateService(r.Context(), &service, dryRun); err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return service, nil
 }
@@ -1390,7 +2350,7 @@ func (h *Handler) updateService(r *http.Request) (any, error) {
 
 	var service types.Service
 	if err = json.NewDecoder(r.Body).Decode(&service); err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	if service.Name == "" || service.ServiceType == "" {
 		return nil, types.CreateRequestError("name and service_type are required", http.StatusBadRequest)
@@ -1400,7 +2360,7 @@ func (h *Handler) updateService(r *http.Request) (any, error) {
 	updateOperationInContext(r, "service_update")
 
 	if err := h.server.UpdateService(r.Context(), &service, dryRun); err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return service, nil
 }
@@ -1421,7 +2381,7 @@ func (h *Handler) deleteService(r *http.Request) (any, error) {
 	updateOperationInContext(r, "service_delete")
 
 	if err := h.server.DeleteService(r.Context(), name, serviceType, dryRun); err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return map[string]any{"name": name, "service_type": serviceType, "dry_run": dryRun}, nil
 }
@@ -1433,15 +2393,89 @@ func (h *Handler) listServices(r *http.Request) (any, error) {
 
 	results, err := h.server.ListServices(r.Context(), serviceType, name)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
+	}
+	return results, nil
+}
+
+func (h *Handler) listTenants(r *http.Request) (any, error) {
+	updateOperationInContext(r, "list_tenants")
+	results, err := h.server.ListTenants(r.Context())
+	if err != nil {
+		return nil, badRequestError(err)
 	}
 	return results, nil
 }
 
+func (h *Handler) listAuditEvents(r *http.Request) (any, error) {
+	updateOperationInContext(r, "list_audit_events")
+	values := r.URL.Query()
+	query := types.AuditQuery{
+		AppGlob:   values.Get("app"),
+		UserId:    values.Get("user"),
+		EventType: values.Get("event_type"),
+		Operation: values.Get("operation"),
+		Target:    values.Get("target"),
+		Status:    values.Get("status"),
+		Rid:       values.Get("rid"),
+		Detail:    values.Get("detail"),
+		Limit:     defaultAuditListLimit,
+	}
+
+	var err error
+	if query.Start, err = parseAuditTime(values.Get("start"), false); err != nil {
+		return nil, err
+	}
+	if query.End, err = parseAuditTime(values.Get("end"), true); err != nil {
+		return nil, err
+	}
+	if before := values.Get("before"); before != "" {
+		if query.Before, err = strconv.ParseInt(before, 10, 64); err != nil {
+			return nil, types.CreateRequestError("before has to be the next_before value from the previous page", http.StatusBadRequest)
+		}
+	}
+	if limit := values.Get("limit"); limit != "" {
+		if query.Limit, err = strconv.Atoi(limit); err != nil {
+			return nil, types.CreateRequestError("limit has to be a number", http.StatusBadRequest)
+		}
+	}
+	return h.server.ListAuditEvents(r.Context(), query)
+}
+
+func (h *Handler) verifyAudit(r *http.Request) (any, error) {
+	updateOperationInContext(r, "verify_audit")
+	return h.server.VerifyAuditLog(r.Context())
+}
+
+func (h *Handler) complianceReport(r *http.Request) (any, error) {
+	updateOperationInContext(r, "compliance_report")
+	period := r.URL.Query().Get("period")
+	updateTargetInContext(r, period, false)
+	return h.server.ComplianceReport(r.Context(), period)
+}
+
+func (h *Handler) verifyComplianceReport(r *http.Request) (any, error) {
+	updateOperationInContext(r, "verify_compliance_report")
+	var request types.ComplianceVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		return nil, badRequestError(err)
+	}
+	return h.server.VerifyComplianceReport(r.Context(), &request)
+}
+
+func (h *Handler) showQuota(r *http.Request) (any, error) {
+	updateOperationInContext(r, "quota_show")
+	ret, err := h.server.ShowQuotas(r.Context(), r.URL.Query().Get("user"))
+	if err != nil {
+		return nil, badRequestError(err)
+	}
+	return ret, nil
+}
+
 func (h *Handler) installProvider(r *http.Request) (any, error) {
 	var request types.ProviderInstallRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	if request.Name == "" {
 		return nil, types.CreateRequestError("name is required", http.StatusBadRequest)
@@ -1455,7 +2489,7 @@ func (h *Handler) installProvider(r *http.Request) (any, error) {
 
 	provider, err := h.server.InstallProvider(r.Context(), &request)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return provider, nil
 }
@@ -1474,7 +2508,7 @@ func (h *Handler) uninstallProvider(r *http.Request) (any, error) {
 	updateOperationInContext(r, "provider_uninstall")
 
 	if err := h.server.UninstallProvider(r.Context(), name, force); err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return map[string]any{"name": name}, nil
 }
@@ -1483,7 +2517,7 @@ func (h *Handler) listProviders(r *http.Request) (any, error) {
 	updateOperationInContext(r, "list_providers")
 	results, err := h.server.ListProviders(r.Context())
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return results, nil
 }
@@ -1496,7 +2530,7 @@ func (h *Handler) createBinding(r *http.Request) (any, error) {
 
 	var createRequest types.CreateBindingRequest
 	if err = json.NewDecoder(r.Body).Decode(&createRequest); err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	if createRequest.Path == "" {
 		return nil, types.CreateRequestError("path is required", http.StatusBadRequest)
@@ -1507,7 +2541,7 @@ func (h *Handler) createBinding(r *http.Request) (any, error) {
 
 	binding, err := h.server.CreateBinding(r.Context(), &createRequest, dryRun)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return redactBindingAccount(binding), nil
 }
@@ -1530,7 +2564,7 @@ func (h *Handler) updateBinding(r *http.Request) (any, error) {
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields()
 	if err = decoder.Decode(&updateRequest); err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	if updateRequest.Path == "" {
 		return nil, types.CreateRequestError("path is required", http.StatusBadRequest)
@@ -1541,7 +2575,7 @@ func (h *Handler) updateBinding(r *http.Request) (any, error) {
 
 	binding, err := h.server.UpdateBinding(r.Context(), updateRequest, dryRun, promote, reapplyAll)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return redactBindingAccount(binding), nil
 }
@@ -1561,7 +2595,7 @@ func (h *Handler) deleteBinding(r *http.Request) (any, error) {
 	updateOperationInContext(r, "binding_delete")
 
 	if err := h.server.DeleteBinding(r.Context(), path, dryRun); err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return map[string]any{"path": path, "dry_run": dryRun}, nil
 }
@@ -1576,7 +2610,7 @@ func (h *Handler) getBinding(r *http.Request) (any, error) {
 
 	binding, err := h.server.GetBinding(r.Context(), path)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return binding, nil
 }
@@ -1596,7 +2630,7 @@ func (h *Handler) getBindingAccount(r *http.Request) (any, error) {
 
 	account, err := h.server.GetBindingAccount(r.Context(), path, useStaging)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return account, nil
 }
@@ -1607,7 +2641,7 @@ func (h *Handler) listBindings(r *http.Request) (any, error) {
 
 	results, err := h.server.ListBindings(r.Context(), source)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return results, nil
 }
@@ -1616,7 +2650,7 @@ func (h *Handler) runBindingCommand(r *http.Request) (any, error) {
 	var runRequest types.RunBindingCommandRequest
 	decoder := json.NewDecoder(r.Body)
 	if err := decoder.Decode(&runRequest); err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	if runRequest.BindingName == "" {
 		return nil, types.CreateRequestError("binding_name is required", http.StatusBadRequest)
@@ -1630,7 +2664,7 @@ func (h *Handler) runBindingCommand(r *http.Request) (any, error) {
 
 	result, err := h.server.RunBindingCommand(r.Context(), runRequest.BindingName, runRequest.UseStaging, runRequest.Command)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return result, nil
 }
@@ -1645,7 +2679,7 @@ func (h *Handler) createSecret(r *http.Request) (any, error) {
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields()
 	if err = decoder.Decode(&createRequest); err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 
 	updateTargetInContext(r, cmp.Or(createRequest.Name, createRequest.Prefix), false)
@@ -1653,7 +2687,7 @@ func (h *Handler) createSecret(r *http.Request) (any, error) {
 
 	response, err := h.server.CreateSecret(r.Context(), &createRequest, update)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	// Record the generated name as the audit target so the create event
 	// correlates with later reveal/delete events for the same secret
@@ -1671,7 +2705,7 @@ func (h *Handler) deleteSecret(r *http.Request) (any, error) {
 	updateOperationInContext(r, "secret_delete")
 
 	if err := h.server.DeleteSecret(r.Context(), r.URL.Query().Get("provider"), name); err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return types.SecretDeleteResponse{Name: name}, nil
 }
@@ -1681,7 +2715,7 @@ func (h *Handler) listSecrets(r *http.Request) (any, error) {
 
 	results, err := h.server.ListSecrets(r.Context(), r.URL.Query().Get("provider"), r.URL.Query().Get("glob"))
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return types.SecretListResponse{Secrets: results}, nil
 }
@@ -1705,7 +2739,7 @@ func (h *Handler) getSecret(r *http.Request) (any, error) {
 
 	response, err := h.server.GetSecret(r.Context(), r.URL.Query().Get("provider"), name, reveal)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return response, nil
 }
@@ -1715,7 +2749,7 @@ func (h *Handler) rekeySecrets(r *http.Request) (any, error) {
 
 	response, err := h.server.RekeySecrets(r.Context(), r.URL.Query().Get("provider"))
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return response, nil
 }
@@ -1736,12 +2770,12 @@ func (h *Handler) userUpdate(r *http.Request) (any, error) {
 
 	var updateRequest types.UserUpdateRequest
 	if err := json.NewDecoder(r.Body).Decode(&updateRequest); err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 
 	updated, err := h.server.CreateUpdateUser(r.Context(), username, updateRequest.Password, updateRequest.Groups, update)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return types.UserUpdateResponse{Username: username, Updated: updated}, nil
 }
@@ -1752,7 +2786,7 @@ func (h *Handler) userDelete(r *http.Request) (any, error) {
 	updateOperationInContext(r, "user_delete")
 
 	if err := h.server.DeleteUser(r.Context(), username); err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return types.UserDeleteResponse{Username: username}, nil
 }
@@ -1762,7 +2796,7 @@ func (h *Handler) userList(r *http.Request) (any, error) {
 
 	users, err := h.server.ListUsers(r.Context())
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return types.UserListResponse{Users: users}, nil
 }
@@ -1781,11 +2815,11 @@ func (h *Handler) configUpdate(r *http.Request) (any, error) {
 	}
 	err = json.NewDecoder(r.Body).Decode(&dynamicConfig)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	newConfig, err := h.server.UpdateDynamicConfig(r.Context(), &dynamicConfig, force)
 	if err != nil {
-		return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		return nil, badRequestError(err)
 	}
 	return types.ConfigResponse{DynamicConfig: *newConfig}, nil
 }
@@ -1805,11 +2839,35 @@ func (h *Handler) serveInternal(enableBasicAuth bool) http.Handler {
 		h.apiHandler(w, r, enableBasicAuth, "restart_server", h.restartServer, false)
 	}))
 
+	// Prune app images
+	r.Post("/image_prune", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "prune_images", h.pruneImages, false)
+	}))
+
+	// App volumes
+	r.Get("/volumes", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "list_volumes", h.listVolumes, false)
+	}))
+	r.Post("/volume_backup", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "backup_volume", h.backupVolume, false)
+	}))
+	r.Post("/volume_restore", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "restore_volume", h.restoreVolume, false)
+	}))
+	r.Post("/volume_gc", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "gc_volumes", h.gcVolumes, false)
+	}))
+
 	// Get apps
 	r.Get("/apps", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		h.apiHandler(w, r, enableBasicAuth, "list_apps", h.getApps, false)
 	}))
 
+	// Search apps
+	r.Get("/app_search", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "search_apps", h.searchApps, false)
+	}))
+
 	// Get app
 	r.Get("/app", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		h.apiHandler(w, r, enableBasicAuth, "get_app", h.getApp, false)
@@ -1825,6 +2883,16 @@ func (h *Handler) serveInternal(enableBasicAuth bool) http.Handler {
 		h.apiHandler(w, r, enableBasicAuth, "delete_apps", h.deleteApps, true)
 	}))
 
+	// API to list the app locks held by operations in progress
+	r.Get("/app_locks", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "list_app_locks", h.listAppLocks, false)
+	}))
+
+	// API to force remove app locks left over from stuck operations
+	r.Delete("/app_locks", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "unlock_apps", h.unlockApps, false)
+	}))
+
 	// API to approve the plugin usage and permissions for the app
 	r.Post("/approve", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		h.apiHandler(w, r, enableBasicAuth, "approve_apps", h.approveApps, false)
@@ -1850,6 +2918,31 @@ func (h *Handler) serveInternal(enableBasicAuth bool) http.Handler {
 		h.apiHandler(w, r, enableBasicAuth, "update_settings", h.updateAppSettings, false)
 	}))
 
+	// API to update app settings with a JSON merge patch
+	r.Post("/app_settings_patch", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "patch_settings", h.patchAppSettings, true)
+	}))
+
+	// API to transfer the ownership of apps
+	r.Post("/app_transfer", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "transfer_apps", h.transferApps, false)
+	}))
+
+	// API to deprecate apps
+	r.Post("/app_deprecate", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "deprecate_apps", h.deprecateApps, false)
+	}))
+
+	// API to pause apps
+	r.Post("/app_pause", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "pause_apps", h.pauseApps, false)
+	}))
+
+	// API to archive apps
+	r.Post("/app_archive", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "archive_apps", h.archiveApps, false)
+	}))
+
 	// API to update app metadata
 	r.Post("/app_metadata", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		h.apiHandler(w, r, enableBasicAuth, "update_metadata", h.updateAppMetadata, true)
@@ -1895,6 +2988,106 @@ func (h *Handler) serveInternal(enableBasicAuth bool) http.Handler {
 		h.apiHandler(w, r, enableBasicAuth, "token_delete", h.tokenDelete, false)
 	}))
 
+	// App REPL evaluate, creates the session if the session id is not set
+	r.Post("/app_repl", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "app_repl", h.appRepl, false)
+	}))
+
+	// App REPL session close
+	r.Delete("/app_repl", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "close_app_repl", h.closeAppRepl, false)
+	}))
+
+	// Traffic capture start
+	r.Post("/app_capture", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "capture_start", h.captureStart, false)
+	}))
+
+	// Traffic capture stop
+	r.Delete("/app_capture", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "capture_stop", h.captureStop, false)
+	}))
+
+	// Traffic capture download
+	r.Get("/app_capture", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "capture_download", h.captureDownload, false)
+	}))
+
+	// Traffic capture replay against the stage app
+	r.Post("/app_capture/replay", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "capture_replay", h.captureReplay, false)
+	}))
+
+	// Handler profiling start
+	r.Post("/app_profile", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "profile_start", h.profileStart, false)
+	}))
+
+	// Handler profiling stop
+	r.Delete("/app_profile", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "profile_stop", h.profileStop, false)
+	}))
+
+	// Handler profiles download
+	r.Get("/app_profile", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "profile_download", h.profileDownload, false)
+	}))
+
+	// List background jobs for an app
+	r.Get("/app_jobs", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "list_jobs", h.listJobs, false)
+	}))
+
+	// List scheduled tasks for an app
+	r.Get("/app_crons", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "list_crons", h.listCrons, false)
+	}))
+
+	// Show the app config, optionally the effective config with its source layers
+	r.Get("/app_config", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "show_app_config", h.showAppConfig, false)
+	}))
+
+	// Stream the merged app and container logs for an app
+	r.Get("/app_logs", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "app_logs", h.appLogs, false)
+	}))
+
+	// Stream the logs of the app container or a service container
+	r.Get("/app_container_logs", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "app_container_logs", h.appContainerLogs, false)
+	}))
+
+	// Run a command in the app container, over a connection upgraded to the exec protocol
+	r.Post("/app_exec", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "app_exec", h.appExec, false)
+	}))
+
+	// Get the resource limits and the current usage of the app containers
+	r.Get("/app_stats", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "app_stats", h.appStats, false)
+	}))
+
+	// Run end-to-end tests against the stage app
+	r.Post("/app_e2e", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "e2e_test", h.runE2ETests, false)
+	}))
+
+	// API to render HTML routes with fixture data and compare with golden files
+	r.Post("/app_golden", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "golden_test", h.runGoldenTests, false)
+	}))
+
+	// API to run the contract checks against the proxied backend
+	r.Post("/app_contract", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "contract_check", h.runContractChecks, false)
+	}))
+
+	// API to check the app pages for broken links and accessibility issues
+	r.Post("/app_check", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "app_check", h.checkApp, false)
+	}))
+
 	// API to apply app config
 	r.Post("/apply", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		h.apiHandler(w, r, enableBasicAuth, "apply", h.apply, true)
@@ -1905,6 +3098,11 @@ func (h *Handler) serveInternal(enableBasicAuth bool) http.Handler {
 		h.apiHandler(w, r, enableBasicAuth, "export_apps", h.export, false)
 	}))
 
+	// API to get the dependency graph of apps and resources
+	r.Get("/graph", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "dependency_graph", h.dependencyGraph, false)
+	}))
+
 	// API to pretty print a declarative config file
 	r.Get("/pretty_print", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		h.apiHandler(w, r, enableBasicAuth, "pretty_print", h.prettyPrint, false)
@@ -1930,6 +3128,27 @@ func (h *Handler) serveInternal(enableBasicAuth bool) http.Handler {
 		h.apiHandler(w, r, enableBasicAuth, "list_sync", h.listSyncEntries, false)
 	}))
 
+	// API to get the run history of a sync entry
+	r.Get("/sync/history", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "sync_history", h.getSyncHistory, false)
+	}))
+
+	// API to get the webhook secret of a sync entry
+	r.Get("/sync/webhook_secret", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "sync_webhook_secret", h.getSyncWebhookSecret, false)
+	}))
+
+	// APIs to manage the server keyring
+	r.Get("/keyring", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "keyring_list", h.listKeyring, false)
+	}))
+	r.Post("/keyring/rotate", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "keyring_rotate", h.rotateKeyring, false)
+	}))
+	r.Delete("/keyring", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "keyring_remove", h.removeKeyringKey, false)
+	}))
+
 	// API to create service
 	r.Post("/service", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		h.apiHandler(w, r, enableBasicAuth, "service_create", h.createService, false)
@@ -2041,6 +3260,34 @@ func (h *Handler) serveInternal(enableBasicAuth bool) http.Handler {
 		h.apiHandler(w, r, enableBasicAuth, "user_list", h.userList, false)
 	}))
 
+	// API to list tenants
+	r.Get("/tenants", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "list_tenants", h.listTenants, false)
+	}))
+
+	// API to list audit events
+	r.Get("/audit", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "list_audit_events", h.listAuditEvents, false)
+	}))
+
+	// API to verify the audit hash chains
+	r.Get("/audit/verify", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "verify_audit", h.verifyAudit, false)
+	}))
+
+	// APIs to create and verify the signed compliance report archive
+	r.Get("/report/compliance", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "compliance_report", h.complianceReport, false)
+	}))
+	r.Post("/report/compliance/verify", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "verify_compliance_report", h.verifyComplianceReport, false)
+	}))
+
+	// API to show the quotas and usage for a user
+	r.Get("/quota", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.apiHandler(w, r, enableBasicAuth, "quota_show", h.showQuota, false)
+	}))
+
 	// API to get config
 	r.Get("/config", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		h.apiHandler(w, r, enableBasicAuth, "config_get", h.configGet, false)
@@ -2092,6 +3339,22 @@ func (h *Handler) serveWebhooks() http.Handler {
 		h.webhookHandler(w, r, types.WebhookPromote)
 	}))
 
+	// Reload image spec app on a container registry push
+	r.Post("/registry", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.webhookHandler(w, r, types.WebhookRegistry)
+	}))
+
+	// Reload and Promote image spec app on a container registry push
+	r.Post("/registry_promote", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h.webhookHandler(w, r, types.WebhookRegistryPromote)
+	}))
+
+	// Run sync job on a git push, for webhook sync entries
+	r.Post(syncWebhookPath, http.HandlerFunc(h.syncWebhookHandler))
+
+	// Slack slash command, disabled unless chatops.slack is enabled in the server config
+	r.Post("/slack", http.HandlerFunc(h.slackHandler))
+
 	return r
 }
 
@@ -5,6 +5,7 @@ package server
 
 import (
 	"crypto/tls"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -285,6 +286,46 @@ func TestRouterPathAndOperationHelpers(t *testing.T) {
 	}
 }
 
+func TestRouterVersionConflictHelpers(t *testing.T) {
+	conflict := types.CreateVersionConflictError("/app", 3, 5)
+	wrapped := fmt.Errorf("error updating app settings: %w", conflict)
+	err := badRequestError(wrapped)
+	reqErr, ok := err.(types.RequestError)
+	if !ok {
+		t.Fatalf("conflict error type: got %T", err)
+	}
+	if reqErr.Code != http.StatusConflict || reqErr.CurrentVersion != 5 || !reqErr.Retriable {
+		t.Fatalf("conflict error: got %+v", reqErr)
+	}
+	if reqErr, ok := badRequestError(fmt.Errorf("other")).(types.RequestError); !ok || reqErr.Code != http.StatusBadRequest {
+		t.Fatalf("other error: got %+v", reqErr)
+	}
+
+	for arg, want := range map[string]int64{"": -1, "0": 0, "12": 12} {
+		r := httptest.NewRequest(http.MethodPost, "/app_settings_patch?ifVersion="+arg, nil)
+		if got, err := parseIfVersion(r); err != nil || got != want {
+			t.Fatalf("ifVersion %q: want %d got %d, %v", arg, want, got, err)
+		}
+	}
+	for _, arg := range []string{"-1", "abc"} {
+		r := httptest.NewRequest(http.MethodPost, "/app_settings_patch?ifVersion="+arg, nil)
+		if _, err := parseIfVersion(r); err == nil {
+			t.Fatalf("ifVersion %q: expected error", arg)
+		}
+	}
+
+	appEntry := &types.AppEntry{Path: "/app", RowVersion: 4}
+	if err := checkAppVersion(appEntry, -1); err != nil {
+		t.Fatalf("no version check: got %v", err)
+	}
+	if err := checkAppVersion(appEntry, 4); err != nil {
+		t.Fatalf("matching version: got %v", err)
+	}
+	if err := checkAppVersion(appEntry, 3); !types.IsRetriableConflict(err) {
+		t.Fatalf("stale version: got %v", err)
+	}
+}
+
 func TestRouterBoolAndSignatureHelpers(t *testing.T) {
 	if got, err := parseBoolArg("", true); err != nil || !got {
 		t.Fatalf("empty arg: want true,nil got %t,%v", got, err)
@@ -358,7 +399,7 @@ func TestRouterServeInternalAndWebhooksRegistration(t *testing.T) {
 		t.Fatalf("webhook router should not be nil")
 	}
 
-	for _, endpoint := range []string{"/reload", "/reload_promote", "/promote"} {
+	for _, endpoint := range []string{"/reload", "/reload_promote", "/promote", "/registry", "/registry_promote"} {
 		req := httptest.NewRequest(http.MethodPost, "http://example.com"+endpoint, nil)
 		rec := httptest.NewRecorder()
 		webhooks.ServeHTTP(rec, req)
@@ -0,0 +1,52 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"time"
+)
+
+// startSecretRotationWatcher starts the loop which re-reads the secrets used by the loaded apps
+// at load time (env values, container params and build args). Apps using a changed value are
+// cleared from the app store, the next request reloads the app with the new values. For
+// container apps, the env change results in a new container. The check runs on every server,
+// since each server has its own set of loaded apps
+func (s *Server) startSecretRotationWatcher() {
+	if s.Config().System.SecretRotationIntervalSecs <= 0 {
+		return
+	}
+
+	interval := time.Duration(s.Config().System.SecretRotationIntervalSecs) * time.Second
+	s.secretRotationTicker = time.NewTicker(interval)
+	s.secretRotationStop = make(chan struct{})
+	// ticker and stop are passed in for the same reason as for the sync runner
+	go s.secretRotationRunner(s.secretRotationTicker, s.secretRotationStop)
+}
+
+func (s *Server) secretRotationRunner(ticker *time.Ticker, stop <-chan struct{}) {
+	s.Info().Msg("Starting secret rotation watcher")
+	for {
+		select {
+		case <-ticker.C:
+		case <-stop:
+			ticker.Stop()
+			s.Info().Msg("Secret rotation watcher stopped")
+			return
+		}
+		s.reloadSecretRotatedApps()
+	}
+}
+
+// reloadSecretRotatedApps clears the apps whose load time secret values have changed. Other
+// servers are not notified, they run their own check for the apps they have loaded
+func (s *Server) reloadSecretRotatedApps() {
+	rotated := s.apps.SecretRotatedApps()
+	if len(rotated) == 0 {
+		return
+	}
+	for _, pathDomain := range rotated {
+		s.Info().Str("app", pathDomain.String()).Msg("Secret value changed, reloading app")
+	}
+	s.apps.ClearAppsNoNotify(rotated)
+}
@@ -6,6 +6,7 @@ package server
 import (
 	"cmp"
 	"context"
+	"crypto/fips140"
 	"crypto/tls"
 	"crypto/x509"
 	"database/sql"
@@ -20,6 +21,7 @@ import (
 	"os/exec"
 	"path"
 	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -28,8 +30,10 @@ import (
 
 	"github.com/caddyserver/certmagic"
 	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/auditsink"
 	"github.com/openrundev/openrun/internal/builder"
 	"github.com/openrundev/openrun/internal/container"
+	"github.com/openrundev/openrun/internal/i18n"
 	"github.com/openrundev/openrun/internal/metadata"
 	"github.com/openrundev/openrun/internal/passwd"
 	"github.com/openrundev/openrun/internal/rbac"
@@ -149,12 +153,17 @@ type Server struct {
 	oAuthManager *OAuthManager
 	samlManager  *SAMLManager
 	formLogin    *FormLoginManager
+	messages     *i18n.Catalog // messages for the error pages, nil uses the built-in catalog
 	notifyClose  chan types.AppPathDomain
 	// secretsManager is swapped when a dynamic config change modifies the
 	// [secret] config; read it through secretsMgr(), never capture the
 	// manager (or one of its method values) in a long-lived object
 	secretsManager atomic.Pointer[system.SecretManager]
 	listAppsApp    *app.App
+	captures       *app.CaptureRegistry
+	profiles       *app.ProfileRegistry
+	resourceQuota  *app.ResourceQuota // resource limits of the app containers, for the container quota
+	appLogs        *appLogStore       // recent app log lines, for the app logs API
 	mu             sync.RWMutex
 	auditDB        *sql.DB
 	auditDbType    system.DBType
@@ -162,6 +171,15 @@ type Server struct {
 	auditFlush     chan chan struct{}
 	auditStop      chan struct{}
 	auditDone      chan struct{}
+	auditForwarder *auditsink.Forwarder // forwards audit events to the [audit_sink.*] sinks, nil if none
+	auditArchiver  auditsink.Archiver   // exports the expired audit events before deletion, nil if not configured
+
+	// auditChains has the head of the audit hash chains written by this server, by chain id. The
+	// audit inserts hold auditChainMu so that the events are written in chain order
+	auditChainMu        sync.Mutex
+	auditChains         map[string]auditChainHead
+	auditCheckpointMu   sync.Mutex
+	auditCheckpointSeqs map[string]int64 // the last checkpointed seq by chain id
 
 	// authFailureTimes tracks the last audit event time per unique auth
 	// failure, to rate limit the events inserted for repeated failures
@@ -171,17 +189,21 @@ type Server struct {
 	syncTimer        *time.Ticker
 	syncStop         chan struct{}
 	tlsErrorLogger   *RateLimitedErrorLogger
+	tlsPolicy        *system.TLSPolicy
+	keyring          *Keyring
 	configMu         sync.RWMutex
 	dynamicConfig    *types.DynamicConfig
 	effectiveConfig  atomic.Pointer[types.ServerConfig]
 	rbacManager      *rbac.RBACManager
 	csrfMiddleware   *http.CrossOriginProtection
 	telemetry        *telemetry.Providers
+	metricsServer    *http.Server // serves the Prometheus scrape endpoint, nil if not enabled
 
 	forwardAuthHTTPClient *http.Client
 	builderManager        *builder.Manager
 	gitCacheMu            sync.Mutex
 	gitCache              *sharedRepoCache
+	gitRepoCache          *gitRepoCache
 
 	staleContainerCleanupTicker *time.Ticker
 	staleContainerCleanupStop   chan struct{}
@@ -193,12 +215,34 @@ type Server struct {
 	staleContainerCleanupCancel context.CancelFunc
 	staleContainerCleanupDone   chan struct{}
 
+	// The secret rotation watcher fields, same lifecycle as the sync runner
+	secretRotationTicker *time.Ticker
+	secretRotationStop   chan struct{}
+
+	// The background job runner fields, same lifecycle as the stale container cleanup.
+	// jobDone is closed once the in-flight jobs have saved their state
+	jobTicker *time.Ticker
+	jobStop   chan struct{}
+	jobCancel context.CancelFunc
+	jobDone   chan struct{}
+
 	// deployTxnMu guards activeDeployTxns: the deploy transactions of
 	// operations currently in flight, whose containers must not be treated as
 	// stale by the container sweeper.
 	deployTxnMu      sync.Mutex
 	activeDeployTxns map[*container.DeployTxn]bool
 
+	// heldAppLocksMu guards heldAppLocks: the reference count of the app
+	// locks held by operations on this server, by holder id and app path.
+	// Nested operations in a request reuse the locks of the outer operation.
+	heldAppLocksMu sync.Mutex
+	heldAppLocks   map[string]int
+
+	// gitTokensMu guards gitTokens: the access tokens for the github_app and
+	// oidc git auth entries, by git auth name, reused until close to expiry
+	gitTokensMu sync.Mutex
+	gitTokens   map[string]gitAccessToken
+
 	// approvalCache caches the needs-approval audit result per app id for
 	// list_apps check_approval. An entry is valid for one (app version,
 	// approvalCacheGen) pair: approval-affecting app changes bump the app
@@ -207,6 +251,30 @@ type Server struct {
 	approvalCache    sync.Map // types.AppId -> approvalCacheEntry
 	approvalCacheGen atomic.Int64
 
+	// appBlockUses records the appBlock includes across apps seen since the
+	// server start, for the dependency graph
+	appBlockUses sync.Map // appBlockUse -> true
+
+	// replMu guards replSessions, the app REPL sessions by session id
+	replMu       sync.Mutex
+	replSessions map[string]*replSessionEntry
+
+	// chatOpsMu guards chatOpsConfirms, the chat approve commands waiting for confirmation
+	chatOpsMu       sync.Mutex
+	chatOpsConfirms map[string]*chatOpsConfirm
+
+	// syncWebhookMu guards syncWebhookRuns, the webhook triggered sync runs in progress by sync id.
+	// The value is set if a push was received during the run, the sync is run again after the run.
+	// syncWebhookRunFunc overrides the sync job run, for tests
+	syncWebhookMu      sync.Mutex
+	syncWebhookRuns    map[string]bool
+	syncWebhookRunFunc func(id string)
+
+	// prPreviewMu serializes the pull request preview updates. prPreviewRunFunc overrides the
+	// preview update, for tests
+	prPreviewMu      sync.Mutex
+	prPreviewRunFunc func(id string, pr *pullRequestEvent)
+
 	stopRequested chan struct{}
 	// providerMutex serializes binding provider installs, uninstalls and
 	// reconciles on this node: concurrent mutations of the same provider's
@@ -222,7 +290,7 @@ type Server struct {
 	upgrader    *system.Upgrader
 	connTracker connTracker
 	restartMu   sync.Mutex // single-flights RequestRestart pause/resume
-	bgMu        sync.Mutex // guards the background job fields (syncStop, staleContainerCleanupStop) across pause/resume/stop
+	bgMu        sync.Mutex // guards the background job fields (syncStop, staleContainerCleanupStop, secretRotationStop, jobStop) across pause/resume/stop
 }
 
 // NewServer creates a new instance of the OpenRun Server
@@ -232,9 +300,20 @@ func NewServer(config *types.ServerConfig) (*Server, error) {
 		return nil, fmt.Errorf("error creating metadata directory %s : %w", metadataDir, err)
 	}
 
-	l := types.NewLogger(&config.Log)
+	appLogs := newAppLogStore()
+	l := types.NewLogger(&config.Log, appLogs)
 	l.Info().Str("version", types.GetVersion()).Str("commit", types.GetCommit()).Msg("Initializing server")
 
+	// The TLS policy is applied to the default HTTP transport before any outbound clients are created
+	tlsPolicy, err := system.NewTLSPolicy(config.TLS)
+	if err != nil {
+		return nil, err
+	}
+	system.SetClientTLSPolicy(tlsPolicy)
+	if fips140.Enabled() {
+		l.Info().Msg("FIPS 140-3 mode is enabled")
+	}
+
 	// Setup secrets manager
 	secretsManager, err := system.NewSecretManager(context.Background(), config.Secret, config.AppConfig.Security.DefaultSecretsProvider, config)
 	if err != nil {
@@ -278,20 +357,27 @@ func NewServer(config *types.ServerConfig) (*Server, error) {
 		db:            db,
 		telemetry:     telemetryProviders,
 		stopRequested: make(chan struct{}),
+		appLogs:       appLogs,
+		tlsPolicy:     tlsPolicy,
 	}
 	server.secretsManager.Store(secretsManager)
 	server.forwardAuthHTTPClient = newForwardAuthHTTPClient(config)
 	db.AppNotifyFunc = server.appNotifyHandler
 	db.ConfigNotifyFunc = server.configNotifyHandler
 	db.ProviderNotifyFunc = server.providerNotifyHandler
+	db.KeyringNotifyFunc = server.keyringNotifyHandler
+	db.ResyncNotifyFunc = server.resyncNotifyHandler
 	server.apps = NewAppStore(l, server)
+	server.captures = app.NewCaptureRegistry()
+	server.profiles = app.NewProfileRegistry()
+	server.resourceQuota = app.NewResourceQuota()
 	server.authHandler = NewAdminBasicAuth(l, config)
 	server.builtinAuth = NewBuiltinAuth(l, server.Config)
 	server.notifyClose = make(chan types.AppPathDomain)
 
 	csrfMiddleware := http.NewCrossOriginProtection()
 	csrfMiddleware.SetDenyHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		http.Error(w, "Cross origin check failed - CSRF protection", http.StatusForbidden)
+		server.errorPage(w, r, http.StatusForbidden, "error.csrf")
 	}))
 	server.csrfMiddleware = csrfMiddleware
 
@@ -313,6 +399,11 @@ func NewServer(config *types.ServerConfig) (*Server, error) {
 	if err = server.oAuthManager.Setup(newSessionSecret, newSessionBlockKey); err != nil {
 		return nil, err
 	}
+	// The session cookies are signed with the keyring keys. The keyring is created with the
+	// existing session secrets as the first key, so the current sessions stay valid
+	if err = server.initKeyring(config, secretsManager, slices.Concat(newSessionSecret, newSessionBlockKey)); err != nil {
+		return nil, err
+	}
 
 	// Setup SAML auth
 	server.samlManager = NewSAMLManager(l, config, server.oAuthManager.cookieStore, db)
@@ -331,21 +422,51 @@ func NewServer(config *types.ServerConfig) (*Server, error) {
 	}
 	server.warnIfAuthDomainOccupied()
 
+	if server.messages, err = i18n.NewCatalog(config.System.MessageCatalogDir); err != nil {
+		return nil, fmt.Errorf("error loading message catalog: %w", err)
+	}
+
+	if err = validateNotifyConfig(config.Notify); err != nil {
+		return nil, err
+	}
+	if err = app.ValidateSecurityProfile(config.AppConfig.Security.Profile); err != nil {
+		return nil, err
+	}
+
 	if err = server.initAuditDB(config.Metadata.AuditDBConnection); err != nil {
 		return nil, fmt.Errorf("error initializing audit db: %w", err)
 	}
 
+	// Like git_auth, the registry_auth passwords are resolved on use, since the db secret
+	// provider is bound only after the metadata database is up
+	container.SetRegistrySecretEval(func(input string) (string, error) {
+		return server.secretsMgr().EvalTemplate(input)
+	})
+
 	server.initAccessLogger(config)
+	server.registerMetrics()
 
 	if config.System.ContainerCommand == "auto" {
 		config.System.ContainerCommand = container.LookupContainerCommand(true)
 		// if command is empty string, that means either containers are disabled in config or no container command found
 	}
 
-	server.Trace().Str("cmd", config.System.ContainerCommand).Msg("Container management command")
+	switch config.System.ContainerDriver {
+	case "", types.CONTAINER_DRIVER_CLI, types.CONTAINER_DRIVER_API:
+	default:
+		return nil, fmt.Errorf("invalid system.container_driver %q, valid options are cli and api", config.System.ContainerDriver)
+	}
+	if config.System.ContainerHost != "" && config.System.ContainerCommand != types.CONTAINER_KUBERNETES {
+		// The container CLI and the image push read the host from the environment, so all the
+		// container operations go to the same host as the api driver
+		os.Setenv(container.ContainerHostEnvName(config.System.ContainerCommand), config.System.ContainerHost) //nolint:errcheck
+	}
+
+	server.Trace().Str("cmd", config.System.ContainerCommand).Str("driver", config.System.ContainerDriver).Msg("Container management command")
 	go server.handleAppClose()
 
 	initOpenRunPlugin(server)
+	initJobPlugin(server)
 	initAdminPlugin(server)
 	initBuilderPlugin(server)
 
@@ -395,10 +516,13 @@ func NewServer(config *types.ServerConfig) (*Server, error) {
 		return nil, fmt.Errorf("error initializing rbac manager: %w", err)
 	}
 
-	// Start the sync runner (which includes the idle shutdown check) and the
-	// stale container sweeper
+	// Start the sync runner (which includes the idle shutdown check), the
+	// stale container sweeper, the secret rotation watcher and the background
+	// job runner
 	server.startSyncRunner()
 	server.startStaleContainerCleanup()
+	server.startSecretRotationWatcher()
+	server.startJobRunner()
 	telemetryCleanup = false
 	return server, nil
 }
@@ -414,8 +538,8 @@ func (s *Server) startSyncRunner() {
 	go s.syncRunner(s.syncTimer, s.syncStop)
 }
 
-// PauseBackground stops the timer driven background jobs (sync runner and
-// stale container sweeper) and suspends per-app idle container shutdown.
+// PauseBackground stops the timer driven background jobs (sync runner, job runner,
+// secret rotation watcher and stale container sweeper) and suspends per-app idle container shutdown.
 // Called when an in-place restart starts, so the old process cannot stop
 // containers the new process is starting to use: idle detection is
 // process-local (last request time, proxied byte counts), so the old
@@ -443,6 +567,21 @@ func (s *Server) PauseBackground() {
 		<-s.staleContainerCleanupDone
 		s.staleContainerCleanupDone = nil
 	}
+	if s.secretRotationStop != nil {
+		s.secretRotationTicker.Stop()
+		close(s.secretRotationStop)
+		s.secretRotationStop = nil
+	}
+	if s.jobStop != nil {
+		s.jobTicker.Stop()
+		close(s.jobStop)
+		s.jobStop = nil
+		// Interrupt the running jobs, they are requeued for the new process to run
+		s.jobCancel()
+		s.jobCancel = nil
+		<-s.jobDone
+		s.jobDone = nil
+	}
 	if s.apps != nil {
 		s.apps.PauseIdleShutdown()
 	}
@@ -468,6 +607,12 @@ func (s *Server) ResumeBackground() {
 	if s.staleContainerCleanupStop == nil {
 		s.startStaleContainerCleanup()
 	}
+	if s.secretRotationStop == nil {
+		s.startSecretRotationWatcher()
+	}
+	if s.jobStop == nil {
+		s.startJobRunner()
+	}
 	if s.apps != nil {
 		s.apps.ResumeIdleShutdown()
 	}
@@ -752,6 +897,16 @@ func (s *Server) configNotifyHandler(updatePayload types.ConfigUpdatePayload) {
 	}
 }
 
+// resyncNotifyHandler reloads the state which other servers could have updated while the
+// notifications were not being received: the apps are reloaded on the next request, the dynamic
+// config and the keyring are reloaded from the database
+func (s *Server) resyncNotifyHandler() {
+	s.Info().Msg("Reloading apps, dynamic config and keyring after missed notifications")
+	s.apps.ClearAllAppsNoNotify()
+	s.configNotifyHandler(types.ConfigUpdatePayload{})
+	s.keyringNotifyHandler(types.KeyringUpdatePayload{})
+}
+
 // bindDBSecretStore connects the db secret provider of manager to the
 // metadata database. A newly built SecretManager cannot serve stored secrets
 // until this runs, so every path that builds a manager after the metadata
@@ -826,6 +981,9 @@ func updateConfigSecrets(config *types.ServerConfig, evalSecret func(string) (st
 		}
 		config.Telemetry.Headers[k] = resolved
 	}
+	if config.Telemetry.PrometheusToken, err = evalSecret(config.Telemetry.PrometheusToken); err != nil {
+		return fmt.Errorf("resolving telemetry prometheus_token: %w", err)
+	}
 
 	return nil
 }
@@ -1102,6 +1260,10 @@ func (s *Server) Start() error {
 		}()
 	}
 
+	if err := s.startMetricsServer(); err != nil {
+		return err
+	}
+
 	if exit := s.upgrader.Exit(); exit != nil {
 		// A successful upgrade hands the listeners to the new process; drain
 		// and stop this process
@@ -1159,6 +1321,7 @@ func (s *Server) setupHTTPSServer() (*http.Server, error) {
 		certmagic.DefaultACME.Email = s.Config().Https.ServiceEmail
 		certmagic.DefaultACME.DisableHTTPChallenge = true
 		certmagic.Default.Storage = s.db.GetCertStorage() // Use the database backed storage
+		certmagic.Default.OCSP.DisableStapling = !s.tlsPolicy.OcspStapling
 
 		magicConfig := certmagic.NewDefault()
 		magicConfig.OnDemand = &certmagic.OnDemandConfig{
@@ -1187,12 +1350,21 @@ func (s *Server) setupHTTPSServer() (*http.Server, error) {
 		tlsConfig = magicConfig.TLSConfig()
 		tlsConfig.NextProtos = append([]string{"h2", "http/1.1"}, tlsConfig.NextProtos...)
 		tlsConfig.GetCertificate = magicConfig.GetCertificate
-		tlsConfig.MinVersion = tls.VersionTLS12
 	} else {
 		// Certmagic is disabled, use certs from disk or create self signed ones
+		var stapler *ocspStapler
+		if s.tlsPolicy.OcspStapling {
+			stapler = newOcspStapler(s.Logger)
+		}
+		loadCert := func(certFilePath, certKeyPath string) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(certFilePath, certKeyPath)
+			if err == nil && stapler != nil {
+				stapler.staple(&cert)
+			}
+			return &cert, err
+		}
 		tlsConfig = &tls.Config{
 			NextProtos: []string{"h2", "http/1.1"},
-			MinVersion: tls.VersionTLS12,
 			GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
 				domain := hello.ServerName
 
@@ -1223,8 +1395,7 @@ func (s *Server) setupHTTPSServer() (*http.Server, error) {
 
 					// If certificate and key files exist, load them
 					if certErr == nil && keyErr == nil {
-						cert, err := tls.LoadX509KeyPair(certFilePath, certKeyPath)
-						return &cert, err
+						return loadCert(certFilePath, certKeyPath)
 					}
 				}
 
@@ -1241,11 +1412,11 @@ func (s *Server) setupHTTPSServer() (*http.Server, error) {
 					}
 				}
 
-				cert, err := tls.LoadX509KeyPair(certFilePath, certKeyPath)
-				return &cert, err
+				return loadCert(certFilePath, certKeyPath)
 			},
 		}
 	}
+	s.tlsPolicy.Apply(tlsConfig)
 
 	if !s.Config().Https.DisableClientCerts {
 		// Request client certificates, verification is done in the handler
@@ -1345,6 +1516,9 @@ func (s *Server) Stop(ctx context.Context) error {
 		if s.udsServer != nil {
 			err3 = s.udsServer.Shutdown(ctx)
 		}
+		if s.metricsServer != nil {
+			s.metricsServer.Shutdown(ctx) //nolint:errcheck
+		}
 		// Shutdown does not wait for hijacked (websocket) connections; wait
 		// for them to finish and force-close any left when ctx expires
 		s.connTracker.drain(ctx)
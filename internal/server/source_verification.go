@@ -0,0 +1,192 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/bmatcuk/doublestar/v4"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/openrundev/openrun/internal/container"
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	sourceVerifyTimeout = 2 * time.Minute
+	pgpSignaturePrefix  = "-----BEGIN PGP SIGNATURE-----"
+)
+
+// sourceVerificationRequired returns true if the app source has to be signed, as per the
+// source verification policy. Dev apps are not verified
+func sourceVerificationRequired(config *types.SourceVerificationConfig, appEntry *types.AppEntry) (bool, error) {
+	if !config.Enabled || appEntry.IsDev {
+		return false, nil
+	}
+	if len(config.Sources) == 0 {
+		return true, nil
+	}
+	for _, pattern := range config.Sources {
+		match, err := doublestar.Match(pattern, appEntry.SourceUrl)
+		if err != nil {
+			return false, fmt.Errorf("invalid source verification pattern %s: %w", pattern, err)
+		}
+		if match {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// signingIdentityArgs returns the cosign/gitsign args for matching the signing certificate
+// against the identity
+func signingIdentityArgs(identity types.SigningIdentity) ([]string, error) {
+	if identity.Issuer == "" || identity.Subject == "" {
+		return nil, errors.New("source verification identity requires issuer and subject")
+	}
+	args := []string{}
+	if subject, ok := strings.CutPrefix(identity.Subject, types.REGEX_PREFIX); ok {
+		args = append(args, "--certificate-identity-regexp", subject)
+	} else {
+		args = append(args, "--certificate-identity", identity.Subject)
+	}
+	if issuer, ok := strings.CutPrefix(identity.Issuer, types.REGEX_PREFIX); ok {
+		args = append(args, "--certificate-oidc-issuer-regexp", issuer)
+	} else {
+		args = append(args, "--certificate-oidc-issuer", identity.Issuer)
+	}
+	return args, nil
+}
+
+// verifySource verifies the signature of the app source, if the source verification policy
+// applies to the app. The digest is the git commit hash or the OCI artifact digest. Sources
+// which cannot be signed, like archives and disk folders, are rejected
+func (s *Server) verifySource(ctx context.Context, appEntry *types.AppEntry, digest string, repoCache *RepoCache) error {
+	config := &s.Config().Security.SourceVerification
+	required, err := sourceVerificationRequired(config, appEntry)
+	if err != nil || !required {
+		return err
+	}
+	if len(config.Identities) == 0 {
+		return fmt.Errorf("source verification is enabled but no identities are configured, cannot verify %s",
+			redactArchiveUrl(appEntry.SourceUrl))
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sourceVerifyTimeout)
+	defer cancel()
+	switch {
+	case system.IsOCI(appEntry.SourceUrl):
+		err = s.verifyOCISignature(ctx, config, appEntry.SourceUrl, digest)
+	case system.IsGit(appEntry.SourceUrl):
+		commit, ok := repoCache.gitCommitObject(digest)
+		if !ok {
+			return fmt.Errorf("source verification failed for %s: commit %s not found", appEntry.SourceUrl, digest)
+		}
+		err = verifyGitCommitSignature(ctx, config, digest, commit)
+	default:
+		return fmt.Errorf("source verification failed for %s: only git and OCI artifact sources can be verified",
+			redactArchiveUrl(appEntry.SourceUrl))
+	}
+	if err != nil {
+		return fmt.Errorf("source verification failed for %s: %w", redactArchiveUrl(appEntry.SourceUrl), err)
+	}
+	s.Info().Str("source_url", redactArchiveUrl(appEntry.SourceUrl)).Str("digest", digest).Msg("Verified app source signature")
+	return nil
+}
+
+// verifyOCISignature runs cosign verify for the artifact digest. The registry_auth credentials
+// are used for pulling the signature
+func (s *Server) verifyOCISignature(ctx context.Context, config *types.SourceVerificationConfig, sourceUrl, digest string) error {
+	imageRef := strings.TrimPrefix(sourceUrl, types.OCI_SOURCE_PREFIX)
+	ref, err := name.ParseReference(imageRef)
+	if err != nil {
+		return fmt.Errorf("invalid artifact reference %s: %w", imageRef, err)
+	}
+	digestRef := ref.Context().Digest(digest).String()
+	return runSignatureVerifier(config, func(identityArgs []string) (*exec.Cmd, func(), error) {
+		args := append([]string{"verify"}, identityArgs...)
+		cmd := exec.CommandContext(ctx, cmp.Or(config.CosignPath, "cosign"), append(args, digestRef)...)
+		cleanup, err := container.WithRegistryAuth(s.Config(), cmd, imageRef)
+		return cmd, cleanup, err
+	})
+}
+
+// verifyGitCommitSignature runs gitsign verify for the commit. The commit object is written to a
+// temporary repo for gitsign to read, so the checkout is not used for verification
+func verifyGitCommitSignature(ctx context.Context, config *types.SourceVerificationConfig, hash string, encodedCommit []byte) error {
+	commitObject := &plumbing.MemoryObject{}
+	commitObject.SetType(plumbing.CommitObject)
+	if _, err := commitObject.Write(encodedCommit); err != nil {
+		return err
+	}
+	commit := &object.Commit{}
+	if err := commit.Decode(commitObject); err != nil {
+		return fmt.Errorf("error reading commit %s: %w", hash, err)
+	}
+	if commit.PGPSignature == "" {
+		return fmt.Errorf("commit %s is not signed", hash)
+	}
+	if strings.HasPrefix(commit.PGPSignature, pgpSignaturePrefix) {
+		return fmt.Errorf("commit %s has a PGP signature, only gitsign signatures are supported", hash)
+	}
+
+	repoDir, err := os.MkdirTemp("", "openrun-verify-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(repoDir) //nolint:errcheck
+	repo, err := git.PlainInit(repoDir, true)
+	if err != nil {
+		return err
+	}
+	if _, err := repo.Storer.SetEncodedObject(commitObject); err != nil {
+		return err
+	}
+
+	return runSignatureVerifier(config, func(identityArgs []string) (*exec.Cmd, func(), error) {
+		args := append([]string{"verify"}, identityArgs...)
+		cmd := exec.CommandContext(ctx, cmp.Or(config.GitsignPath, "gitsign"), append(args, hash)...)
+		cmd.Dir = repoDir
+		cmd.Env = append(os.Environ(), "GIT_DIR="+repoDir)
+		return cmd, func() {}, nil
+	})
+}
+
+// runSignatureVerifier runs the verifier command for each identity, the verification passes if
+// the signature matches any one identity
+func runSignatureVerifier(config *types.SourceVerificationConfig, newCmd func(identityArgs []string) (*exec.Cmd, func(), error)) error {
+	var errs []error
+	for _, identity := range config.Identities {
+		identityArgs, err := signingIdentityArgs(identity)
+		if err != nil {
+			return err
+		}
+		cmd, cleanup, err := newCmd(identityArgs)
+		if err != nil {
+			return err
+		}
+		output, err := cmd.CombinedOutput()
+		cleanup()
+		if err == nil {
+			return nil
+		}
+		if errors.Is(err, exec.ErrNotFound) {
+			return fmt.Errorf("%s not found, it is required for source verification: %w", cmd.Path, err)
+		}
+		errs = append(errs, fmt.Errorf("identity %s (issuer %s): %w: %s", identity.Subject, identity.Issuer,
+			err, bytes.TrimSpace(output)))
+	}
+	return fmt.Errorf("no valid signature from the configured identities: %w", errors.Join(errs...))
+}
@@ -0,0 +1,79 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestSourceVerificationRequired(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  types.SourceVerificationConfig
+		source  string
+		isDev   bool
+		want    bool
+		wantErr string
+	}{
+		{name: "disabled", config: types.SourceVerificationConfig{}, source: "github.com/org/app", want: false},
+		{name: "all sources", config: types.SourceVerificationConfig{Enabled: true}, source: "/tmp/app", want: true},
+		{name: "dev app", config: types.SourceVerificationConfig{Enabled: true}, source: "github.com/org/app", isDev: true, want: false},
+		{name: "matching", config: types.SourceVerificationConfig{Enabled: true, Sources: []string{"github.com/org/**"}},
+			source: "github.com/org/app/sub", want: true},
+		{name: "not matching", config: types.SourceVerificationConfig{Enabled: true, Sources: []string{"github.com/org/**"}},
+			source: "github.com/other/app", want: false},
+		{name: "oci", config: types.SourceVerificationConfig{Enabled: true, Sources: []string{"oci://ghcr.io/org/**"}},
+			source: "oci://ghcr.io/org/app:v1", want: true},
+		{name: "invalid pattern", config: types.SourceVerificationConfig{Enabled: true, Sources: []string{"github.com/[org"}},
+			source: "github.com/org/app", wantErr: "invalid source verification pattern"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			appEntry := &types.AppEntry{SourceUrl: tc.source, IsDev: tc.isDev}
+			got, err := sourceVerificationRequired(&tc.config, appEntry)
+			if tc.wantErr != "" {
+				testutil.AssertErrorContains(t, err, tc.wantErr)
+				return
+			}
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqualsBool(t, "required", tc.want, got)
+		})
+	}
+}
+
+func TestSigningIdentityArgs(t *testing.T) {
+	args, err := signingIdentityArgs(types.SigningIdentity{Issuer: "https://token.actions.githubusercontent.com",
+		Subject: "regex:^https://github.com/org/.*$"})
+	testutil.AssertNoError(t, err)
+	want := []string{"--certificate-identity-regexp", "^https://github.com/org/.*$",
+		"--certificate-oidc-issuer", "https://token.actions.githubusercontent.com"}
+	if !slices.Equal(want, args) {
+		t.Errorf("args = %v, want %v", args, want)
+	}
+
+	_, err = signingIdentityArgs(types.SigningIdentity{Subject: "user@example.com"})
+	testutil.AssertErrorContains(t, err, "requires issuer and subject")
+}
+
+func TestVerifyUnsignedCommit(t *testing.T) {
+	sourceDir := t.TempDir()
+	repo, err := git.PlainInitWithOptions(sourceDir, &git.PlainInitOptions{
+		InitOptions: git.InitOptions{DefaultBranch: plumbing.NewBranchReferenceName("main")}})
+	testutil.AssertNoError(t, err)
+	hash := commitTestFile(t, repo, sourceDir, "app.star", "app = 1\n")
+
+	cacheDir, err := materializeGitCommit(sourceDir, t.TempDir(), hash, "")
+	testutil.AssertNoError(t, err)
+	config := &types.SourceVerificationConfig{Enabled: true, GitsignPath: "gitsign-not-used",
+		Identities: []types.SigningIdentity{{Issuer: "https://accounts.google.com", Subject: "user@example.com"}}}
+	err = verifyGitCommitSignature(context.Background(), config, hash, cacheDir.commit)
+	testutil.AssertErrorContains(t, err, "is not signed")
+}
@@ -90,6 +90,8 @@ func formatApp(req *types.CreateAppRequest) (string, []string) {
 	addStr("git_auth", req.GitAuthName)
 	addStr("git_branch", req.GitBranch)
 	addStr("git_commit", req.GitCommit)
+	addStr("git_tag", req.GitTag)
+	addStr("source_checksum", req.SourceChecksum)
 	if len(req.ParamValues) > 0 {
 		args = append(args, dictArg("params", stringDictEntries(req.ParamValues)))
 	}
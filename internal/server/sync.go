@@ -6,19 +6,23 @@ package server
 import (
 	"cmp"
 	"context"
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"strings"
 	"time"
 
-	"github.com/openrundev/openrun/internal/passwd"
 	"github.com/openrundev/openrun/internal/rbac"
 	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/telemetry"
 	"github.com/openrundev/openrun/internal/types"
 	"github.com/segmentio/ksuid"
 )
 
+const (
+	defaultSyncHistoryLimit = 20
+	maxSyncHistoryLimit     = 500
+)
+
 func (s *Server) CreateSyncEntry(ctx context.Context, path string, scheduled, dryRun bool, sync *types.SyncMetadata) (_ *types.SyncCreateResponse, retErr error) {
 	if err := s.enforceGlobalPerm(ctx, types.PermissionSyncCreate, ""); err != nil {
 		return nil, err
@@ -32,6 +36,36 @@ func (s *Server) CreateSyncEntry(ctx context.Context, path string, scheduled, dr
 		}
 	}
 
+	if !scheduled && (sync.ScheduleCron != "" || sync.ScheduleTimezone != "") {
+		return nil, errors.New("cron schedule is supported for scheduled sync only")
+	}
+	if _, _, err := syncCronSchedule(sync); err != nil {
+		return nil, err
+	}
+
+	if err := s.validateSyncNotify(sync.Notify); err != nil {
+		return nil, err
+	}
+	if sync.PrPreview {
+		if scheduled {
+			return nil, errors.New("pull request previews are supported for webhook sync only")
+		}
+		if sync.PrPreviewTTLHours < 0 {
+			return nil, errors.New("pull request preview TTL cannot be negative")
+		}
+		if sync.PrPreviewAuth != "" {
+			if err := s.validateAppAuthnType(sync.PrPreviewAuth); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if sync.CommitStatus {
+		// Check the repo and the token before creating the entry, since reporting errors are only logged
+		if _, err := s.newCommitStatusTarget(path, sync.GitAuth); err != nil {
+			return nil, err
+		}
+	}
+
 	// Freeze the creator's authorization on the entry: background runs are
 	// authorized against this snapshot, so later grant/role edits do not change
 	// what an existing sync may do. Nil (call not RBAC enforced) means the
@@ -75,14 +109,15 @@ func (s *Server) CreateSyncEntry(ctx context.Context, path string, scheduled, dr
 	}
 	id := "cl_syn_" + strings.ToLower(genId.String())
 
+	// The webhook secret is derived from the keyring, it is not stored. The stored secret is
+	// used only by the webhook syncs created before the keyring
+	sync.WebhookSecret = ""
+	webhookSecret := ""
 	if !scheduled {
-		// Webhook sync entry
-		secret, err := passwd.GeneratePassword()
-		if err != nil {
+		if _, webhookSecret, err = s.syncWebhookSecret(id); err != nil {
 			return nil, err
 		}
-		sync.WebhookSecret = fmt.Sprintf("cl_tkn_%s", base64.StdEncoding.EncodeToString([]byte(secret)))
-	} else if sync.ScheduleFrequency <= 0 {
+	} else if sync.ScheduleFrequency <= 0 && sync.ScheduleCron == "" {
 		sync.ScheduleFrequency = s.Config().System.DefaultScheduleMins
 	}
 
@@ -111,11 +146,16 @@ func (s *Server) CreateSyncEntry(ctx context.Context, path string, scheduled, dr
 	ret := types.SyncCreateResponse{
 		Id:                syncEntry.Id,
 		DryRun:            dryRun,
-		WebhookUrl:        "", // TODO
-		WebhookSecret:     syncEntry.Metadata.WebhookSecret,
+		WebhookUrl:        s.syncWebhookUrl(&syncEntry),
+		WebhookSecret:     webhookSecret,
 		ScheduleFrequency: syncEntry.Metadata.ScheduleFrequency,
+		ScheduleCron:      syncEntry.Metadata.ScheduleCron,
 		SyncJobStatus:     *syncStatus,
 	}
+	syncEntry.Status = *syncStatus
+	if nextRun, err := nextSyncRunTime(&syncEntry); err == nil && !nextRun.IsZero() {
+		ret.NextRunTime = &nextRun
+	}
 
 	if err := s.CompleteTransaction(ctx, tx, updatedApps, dryRun, "create_sync"); err != nil {
 		return nil, err
@@ -145,6 +185,9 @@ func (s *Server) RunSync(ctx context.Context, id string, dryRun bool) (_ *types.
 	if err := s.enforceGlobalPerm(ctx, types.PermissionSyncRun, syncEntry.UserID); err != nil {
 		return nil, err
 	}
+	if !dryRun {
+		defer func() { telemetry.RecordSyncRun(ctx, "manual", syncOutcome(nil, retErr)) }()
+	}
 
 	repoCache, err := NewRepoCache(s)
 	if err != nil {
@@ -172,6 +215,8 @@ func (s *Server) RunSync(ctx context.Context, id string, dryRun bool) (_ *types.
 	}
 	if syncStatus.Error != "" {
 		// The sync job job failed, status would be already updated
+		s.reportSyncCommitStatus(syncEntry, syncStatus, repoCache)
+		s.notifySync(syncEntry, syncStatus, repoCache)
 		return nil, errors.New(syncStatus.Error)
 	}
 
@@ -181,6 +226,8 @@ func (s *Server) RunSync(ctx context.Context, id string, dryRun bool) (_ *types.
 	if err := deployScope.commit(ctx); err != nil {
 		return nil, err
 	}
+	s.reportSyncCommitStatus(syncEntry, syncStatus, repoCache)
+	s.notifySync(syncEntry, syncStatus, repoCache)
 	return syncStatus, nil
 }
 
@@ -249,7 +296,14 @@ func (s *Server) ListSyncEntries(ctx context.Context) (*types.SyncListResponse,
 	}
 
 	for _, e := range entries {
-		e.Metadata.WebhookUrl = "" // TODO: Set the actual webhook URL
+		e.Metadata.WebhookUrl = s.syncWebhookUrl(e)
+		if e.Status.FailureCount >= s.Config().System.MaxSyncFailureCount {
+			// The runner skips the entry till it is run manually
+			continue
+		}
+		if nextRun, err := nextSyncRunTime(e); err == nil && !nextRun.IsZero() {
+			e.NextRunTime = &nextRun
+		}
 	}
 
 	ret := types.SyncListResponse{
@@ -277,6 +331,9 @@ func (s *Server) syncRunner(timer *time.Ticker, stop <-chan struct{}) {
 		if err := s.db.CleanupExpiredKV(context.Background()); err != nil {
 			s.Error().Err(err).Msg("Error cleaning up expired KV entries")
 		}
+		if err := s.db.CleanupOldJobs(context.Background(), s.Config().System.JobRetentionDays); err != nil {
+			s.Error().Err(err).Msg("Error cleaning up old background jobs")
+		}
 		err := s.runSyncJobs()
 		if err != nil {
 			s.Error().Err(err).Msg("Error running sync")
@@ -351,11 +408,12 @@ func (s *Server) runSyncJobs() error {
 
 	updatedAnyApps := false
 	for _, entry := range scheduleEntries {
-		if !entry.IsScheduled || entry.Metadata.ScheduleFrequency <= 0 {
+		nextRun, err := nextSyncRunTime(entry)
+		if err != nil {
+			s.Error().Err(err).Msgf("Error reading schedule for sync job %s", entry.Id)
 			continue
 		}
-
-		if !entry.Status.LastExecutionTime.IsZero() && entry.Status.LastExecutionTime.Add(time.Duration(entry.Metadata.ScheduleFrequency)*time.Minute).After(time.Now()) {
+		if nextRun.IsZero() || nextRun.After(time.Now()) {
 			s.Trace().Msgf("Sync job %s not ready to run", entry.Id)
 			continue
 		}
@@ -371,12 +429,15 @@ func (s *Server) runSyncJobs() error {
 		// run is attributed to the user who created the sync and authorized
 		// against the creator's frozen RBAC snapshot when one is present
 		jobCtx := s.attachSyncRBAC(newBackgroundOperationContext(cmp.Or(entry.UserID, "scheduler")), entry)
-		_, updatedApps, err := s.runSyncJob(jobCtx, types.Transaction{}, entry, false, true, repoCache) // each sync runs in its own transaction
+		syncStatus, updatedApps, err := s.runSyncJob(jobCtx, types.Transaction{}, entry, false, true, repoCache) // each sync runs in its own transaction
+		telemetry.RecordSyncRun(jobCtx, "scheduled", syncOutcome(syncStatus, err))
 		if err != nil {
 			s.Error().Err(err).Msgf("Error running sync job %s", entry.Id)
 			// One failure does not stop the rest
 			continue
 		}
+		s.reportSyncCommitStatus(entry, syncStatus, repoCache)
+		s.notifySync(entry, syncStatus, repoCache)
 		if len(updatedApps) > 0 {
 			updatedAnyApps = true
 		}
@@ -388,12 +449,80 @@ func (s *Server) runSyncJobs() error {
 	return nil
 }
 
+// syncCronSchedule parses the cron expression and the timezone for a scheduled sync. The schedule
+// is nil if the sync runs every N minutes
+func syncCronSchedule(sync *types.SyncMetadata) (*system.CronSchedule, *time.Location, error) {
+	if sync.ScheduleCron == "" {
+		if sync.ScheduleTimezone != "" {
+			return nil, nil, errors.New("timezone is supported only with a cron schedule")
+		}
+		return nil, nil, nil
+	}
+	if sync.ScheduleFrequency > 0 {
+		return nil, nil, errors.New("only one of schedule minutes and cron schedule can be set")
+	}
+
+	schedule, err := system.ParseCronSchedule(sync.ScheduleCron)
+	if err != nil {
+		return nil, nil, err
+	}
+	location := time.Local
+	if sync.ScheduleTimezone != "" {
+		if location, err = time.LoadLocation(sync.ScheduleTimezone); err != nil {
+			return nil, nil, fmt.Errorf("invalid timezone %q: %w", sync.ScheduleTimezone, err)
+		}
+	}
+	return schedule, location, nil
+}
+
+// nextSyncRunTime returns the time when the scheduled sync is due to run next, the zero time if the
+// sync is not scheduled. An entry which was never run is due now. For cron schedules, the runs
+// missed while the server was down are collapsed into one run
+func nextSyncRunTime(entry *types.SyncEntry) (time.Time, error) {
+	if !entry.IsScheduled {
+		return time.Time{}, nil
+	}
+	schedule, location, err := syncCronSchedule(&entry.Metadata)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if schedule == nil && entry.Metadata.ScheduleFrequency <= 0 {
+		return time.Time{}, nil
+	}
+
+	lastRun := entry.Status.LastExecutionTime
+	if lastRun.IsZero() {
+		return time.Now(), nil
+	}
+	if schedule != nil {
+		return schedule.Next(lastRun.In(location)), nil
+	}
+	return lastRun.Add(time.Duration(entry.Metadata.ScheduleFrequency) * time.Minute), nil
+}
+
+// setSyncFailure updates the status for a failed sync run. A version conflict with a concurrent
+// update of an app or an app locked by another operation is retried on the next run, it does not
+// count toward disabling the sync
+func (s *Server) setSyncFailure(status *types.SyncJobStatus, entry *types.SyncEntry, err error) {
+	status.Error = err.Error()
+	status.FailureCount = entry.Status.FailureCount
+	if !types.IsRetriableConflict(err) {
+		status.FailureCount++
+	}
+	if status.FailureCount >= s.Config().System.MaxSyncFailureCount {
+		status.State = "Disabled"
+	} else {
+		status.State = "Failing"
+	}
+}
+
 func (s *Server) runSyncJob(ctx context.Context, inputTx types.Transaction, entry *types.SyncEntry,
 	dryRun, checkCommitHash bool, repoCache *RepoCache) (_ *types.SyncJobStatus, _ []types.AppPathDomain, retErr error) {
 	var tx types.Transaction
 	var err error
 
 	s.Debug().Msgf("Running sync job %s", entry.Id)
+	startTime := time.Now()
 	if repoCache == nil {
 		// Create a new repo cache if not passed in
 		repoCache, err = NewRepoCache(s)
@@ -420,6 +549,15 @@ func (s *Server) runSyncJob(ctx context.Context, inputTx types.Transaction, entr
 		}
 		if types.AppReloadOption(entry.Metadata.Reload) == types.AppReloadOptionMatched {
 			s.prefetchAppSources(ctx, lastRunApps, "", "", "", repoCache, entry.Metadata.ForceReload)
+			if entry.Metadata.Verify && !dryRun && s.Config().System.UseImagePreBuildStep {
+				// The apps from the last run are reloaded, build their images concurrently before
+				// the transaction is opened. This only warms the image cache, errors are reported
+				// by the reload
+				if err := s.preBuildAppImages(ctx, lastRunApps, entry.Metadata.Approve, "", "", "",
+					repoCache, entry.Metadata.ForceReload); err != nil {
+					s.Debug().Err(err).Msgf("image pre-build: error building images for sync %s", entry.Id)
+				}
+			}
 		}
 
 		tx, err = s.db.BeginTransaction(ctx)
@@ -435,6 +573,9 @@ func (s *Server) runSyncJob(ctx context.Context, inputTx types.Transaction, entr
 	// Apps created/reloaded by this job are stamped with the sync id in their
 	// metadata (AppliedSyncId)
 	ctx = context.WithValue(ctx, types.SYNC_ID, entry.Id)
+	// The changes applied by the sync are promoted, the stage changes pending from before the sync
+	// need the confirm option set on the sync entry
+	ctx = withPromoteState(ctx, entry.Metadata.Confirm)
 
 	// origCtx is the context before the rollback scope is attached; it is used
 	// for the recursive full-apply call so that nested call owns a fresh scope.
@@ -456,16 +597,10 @@ func (s *Server) runSyncJob(ctx context.Context, inputTx types.Transaction, entr
 	}
 	if applyErr != nil {
 		s.Error().Err(applyErr).Msgf("Error applying sync job %s", entry.Id)
-		status.Error = applyErr.Error()
 		applyInfo = &types.AppApplyResponse{}
 		applyInfo.DryRun = dryRun
 		applyInfo.FilteredApps = lastRunApps
-		status.FailureCount = entry.Status.FailureCount + 1
-		if status.FailureCount >= s.Config().System.MaxSyncFailureCount {
-			status.State = "Disabled"
-		} else {
-			status.State = "Failing"
-		}
+		s.setSyncFailure(&status, entry, applyErr)
 	} else {
 		status.CommitId = applyInfo.CommitId
 		status.FailureCount = 0
@@ -513,6 +648,12 @@ func (s *Server) runSyncJob(ctx context.Context, inputTx types.Transaction, entr
 			// apply path checks at Apply): global approve when approving, then
 			// app:apply and app:promote per app
 			reloadErr := s.enforceSyncReloadPerms(ctx, entry, lastRunApps, appMap)
+			if reloadErr == nil && !dryRun {
+				var unlock func()
+				if unlock, reloadErr = s.lockApps(ctx, "sync_reload", lastRunApps); reloadErr == nil {
+					defer unlock()
+				}
+			}
 
 			// In-place reloads register on the operation-level rollback stack
 			// (in ctx); the deferred finish reverts earlier apps if a later one
@@ -538,13 +679,7 @@ func (s *Server) runSyncJob(ctx context.Context, inputTx types.Transaction, entr
 			}
 
 			if reloadErr != nil {
-				status.Error = reloadErr.Error()
-				status.FailureCount = entry.Status.FailureCount + 1
-				if status.FailureCount >= s.Config().System.MaxSyncFailureCount {
-					status.State = "Disabled"
-				} else {
-					status.State = "Failing"
-				}
+				s.setSyncFailure(&status, entry, reloadErr)
 				applyInfo.ReloadResults = reloadResults
 				applyInfo.ApproveResults = approveResults
 				applyInfo.PromoteResults = promoteResults
@@ -569,6 +704,9 @@ func (s *Server) runSyncJob(ctx context.Context, inputTx types.Transaction, entr
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := s.db.InsertSyncRun(ctx, tx, newSyncRun(entry.Id, startTime, &status), s.Config().System.SyncHistoryEntries); err != nil {
+		return nil, nil, err
+	}
 
 	if status.Error != "" {
 		// Persist the failure status: LastExecutionTime, FailureCount and State
@@ -594,3 +732,64 @@ func (s *Server) runSyncJob(ctx context.Context, inputTx types.Transaction, entr
 
 	return &status, updatedApps, nil
 }
+
+// newSyncRun returns the history record for a sync run, with the apps changed by the run
+func newSyncRun(syncId string, startTime time.Time, status *types.SyncJobStatus) *types.SyncRun {
+	apply := &status.ApplyResponse
+	changes := types.SyncRunChanges{
+		SkippedApply: apply.SkippedApply,
+		Created:      make([]types.AppPathDomain, 0, len(apply.CreateResults)),
+		Updated:      apply.UpdateResults,
+		Reloaded:     apply.ReloadResults,
+		Approved:     make([]types.AppPathDomain, 0, len(apply.ApproveResults)),
+		Promoted:     apply.PromoteResults,
+		Skipped:      apply.SkippedResults,
+	}
+	for _, created := range apply.CreateResults {
+		changes.Created = append(changes.Created, created.AppPathDomain)
+	}
+	for _, approved := range apply.ApproveResults {
+		changes.Approved = append(changes.Approved, approved.AppPathDomain)
+	}
+
+	return &types.SyncRun{
+		Id:         types.ID_PREFIX_SYNC_RUN + strings.ToLower(ksuid.New().String()),
+		SyncId:     syncId,
+		StartTime:  startTime,
+		DurationMs: time.Since(startTime).Milliseconds(),
+		CommitId:   status.CommitId,
+		Error:      status.Error,
+		Changes:    changes,
+	}
+}
+
+// GetSyncHistory returns one page of the runs of a sync entry, latest first
+func (s *Server) GetSyncHistory(ctx context.Context, id string, offset, limit int) (*types.SyncHistoryResponse, error) {
+	if offset < 0 || limit <= 0 {
+		return nil, fmt.Errorf("offset should be zero or more and limit should be more than zero")
+	}
+	limit = min(limit, maxSyncHistoryLimit)
+	syncEntry, err := s.db.GetSyncEntry(ctx, types.Transaction{}, id)
+	if err != nil {
+		return nil, err
+	}
+	// sync:read globally, or ownership of the entry, allows reading the history
+	if err := s.enforceGlobalPerm(ctx, types.PermissionSyncRead, syncEntry.UserID); err != nil {
+		return nil, err
+	}
+
+	runs, total, err := s.db.ListSyncRuns(ctx, id, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	return &types.SyncHistoryResponse{Id: id, Runs: runs, Total: total, Offset: offset, Limit: limit}, nil
+}
+
+// syncOutcome returns the outcome label for the sync run metric. A run which saved an error
+// in the sync status is a failure
+func syncOutcome(status *types.SyncJobStatus, err error) string {
+	if err != nil || (status != nil && status.Error != "") {
+		return "failure"
+	}
+	return "success"
+}
@@ -0,0 +1,468 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	prActionUpdate = "update" // the pull request was opened, reopened or pushed to
+	prActionClose  = "close"  // the pull request was closed or merged
+
+	prPreviewUser = "pr_preview" // user id for the expired preview deletes
+)
+
+// pullRequestEvent is a pull request event from the git provider webhook
+type pullRequestEvent struct {
+	Number     int
+	Action     string // prActionUpdate or prActionClose
+	Branch     string // the source branch
+	BaseBranch string // the target branch
+	CommitId   string // the head commit of the source branch
+	FromFork   bool   // the source branch is in a fork of the repo
+}
+
+// parsePullRequestEvent parses the pull request events sent by GitHub, Gitea, GitLab and
+// Bitbucket. Nil is returned for other events. The ignore reason is set for pull request events
+// which do not change the code, like label updates
+func parsePullRequestEvent(header http.Header, body []byte) (*pullRequestEvent, string, error) {
+	ghEvent := cmp.Or(header.Get("X-GitHub-Event"), header.Get("X-Gitea-Event"))
+	bbEvent := header.Get("X-Event-Key")
+	switch {
+	case ghEvent == "pull_request":
+		return parseGitHubPullRequest(body)
+	case header.Get("X-Gitlab-Event") == "Merge Request Hook":
+		return parseGitLabMergeRequest(body)
+	case strings.HasPrefix(bbEvent, "pullrequest:"):
+		return parseBitbucketPullRequest(bbEvent, body)
+	case strings.HasPrefix(bbEvent, "pr:"):
+		return parseBitbucketDCPullRequest(bbEvent, body)
+	}
+	return nil, "", nil
+}
+
+// parseGitHubPullRequest parses the GitHub and Gitea pull_request event
+func parseGitHubPullRequest(body []byte) (*pullRequestEvent, string, error) {
+	type prRef struct {
+		Ref  string `json:"ref"`
+		Sha  string `json:"sha"`
+		Repo *struct {
+			FullName string `json:"full_name"`
+		} `json:"repo"`
+	}
+	var payload struct {
+		Action      string `json:"action"`
+		Number      int    `json:"number"`
+		PullRequest struct {
+			Head prRef `json:"head"`
+			Base prRef `json:"base"`
+		} `json:"pull_request"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, "", fmt.Errorf("error parsing request, expected JSON: %w", err)
+	}
+
+	pr := &pullRequestEvent{
+		Number:     payload.Number,
+		Branch:     payload.PullRequest.Head.Ref,
+		BaseBranch: payload.PullRequest.Base.Ref,
+		CommitId:   payload.PullRequest.Head.Sha,
+	}
+	head, base := payload.PullRequest.Head.Repo, payload.PullRequest.Base.Repo
+	pr.FromFork = head == nil || base == nil || !strings.EqualFold(head.FullName, base.FullName)
+	switch payload.Action {
+	case "opened", "reopened", "synchronize", "synchronized":
+		pr.Action = prActionUpdate
+	case "closed":
+		pr.Action = prActionClose
+	default:
+		return nil, fmt.Sprintf("pull request action %s does not update the preview", payload.Action), nil
+	}
+	return pr, "", validatePullRequest(pr)
+}
+
+// parseGitLabMergeRequest parses the GitLab merge request event
+func parseGitLabMergeRequest(body []byte) (*pullRequestEvent, string, error) {
+	var payload struct {
+		ObjectAttributes struct {
+			Iid             int    `json:"iid"`
+			Action          string `json:"action"`
+			SourceBranch    string `json:"source_branch"`
+			TargetBranch    string `json:"target_branch"`
+			SourceProjectId int    `json:"source_project_id"`
+			TargetProjectId int    `json:"target_project_id"`
+			LastCommit      struct {
+				Id string `json:"id"`
+			} `json:"last_commit"`
+		} `json:"object_attributes"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, "", fmt.Errorf("error parsing request, expected JSON: %w", err)
+	}
+
+	attrs := payload.ObjectAttributes
+	pr := &pullRequestEvent{
+		Number:     attrs.Iid,
+		Branch:     attrs.SourceBranch,
+		BaseBranch: attrs.TargetBranch,
+		CommitId:   attrs.LastCommit.Id,
+		FromFork:   attrs.SourceProjectId != attrs.TargetProjectId,
+	}
+	switch attrs.Action {
+	case "open", "reopen", "update":
+		pr.Action = prActionUpdate
+	case "close", "merge":
+		pr.Action = prActionClose
+	default:
+		return nil, fmt.Sprintf("merge request action %s does not update the preview", attrs.Action), nil
+	}
+	return pr, "", validatePullRequest(pr)
+}
+
+// parseBitbucketPullRequest parses the Bitbucket Cloud pull request events
+func parseBitbucketPullRequest(event string, body []byte) (*pullRequestEvent, string, error) {
+	type prRef struct {
+		Branch struct {
+			Name string `json:"name"`
+		} `json:"branch"`
+		Commit struct {
+			Hash string `json:"hash"`
+		} `json:"commit"`
+		Repository struct {
+			FullName string `json:"full_name"`
+		} `json:"repository"`
+	}
+	var payload struct {
+		PullRequest struct {
+			Id          int   `json:"id"`
+			Source      prRef `json:"source"`
+			Destination prRef `json:"destination"`
+		} `json:"pullrequest"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, "", fmt.Errorf("error parsing request, expected JSON: %w", err)
+	}
+
+	source, dest := payload.PullRequest.Source, payload.PullRequest.Destination
+	pr := &pullRequestEvent{
+		Number:     payload.PullRequest.Id,
+		Branch:     source.Branch.Name,
+		BaseBranch: dest.Branch.Name,
+		CommitId:   source.Commit.Hash,
+		FromFork:   !strings.EqualFold(source.Repository.FullName, dest.Repository.FullName),
+	}
+	switch event {
+	case "pullrequest:created", "pullrequest:updated":
+		pr.Action = prActionUpdate
+	case "pullrequest:fulfilled", "pullrequest:rejected":
+		pr.Action = prActionClose
+	default:
+		return nil, fmt.Sprintf("event %s does not update the preview", event), nil
+	}
+	return pr, "", validatePullRequest(pr)
+}
+
+// parseBitbucketDCPullRequest parses the Bitbucket Data Center pull request events
+func parseBitbucketDCPullRequest(event string, body []byte) (*pullRequestEvent, string, error) {
+	type prRef struct {
+		DisplayId    string `json:"displayId"`
+		LatestCommit string `json:"latestCommit"`
+		Repository   struct {
+			Slug    string `json:"slug"`
+			Project struct {
+				Key string `json:"key"`
+			} `json:"project"`
+		} `json:"repository"`
+	}
+	var payload struct {
+		PullRequest struct {
+			Id      int   `json:"id"`
+			FromRef prRef `json:"fromRef"`
+			ToRef   prRef `json:"toRef"`
+		} `json:"pullRequest"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, "", fmt.Errorf("error parsing request, expected JSON: %w", err)
+	}
+
+	from, to := payload.PullRequest.FromRef, payload.PullRequest.ToRef
+	pr := &pullRequestEvent{
+		Number:     payload.PullRequest.Id,
+		Branch:     from.DisplayId,
+		BaseBranch: to.DisplayId,
+		CommitId:   from.LatestCommit,
+		FromFork: !strings.EqualFold(from.Repository.Slug, to.Repository.Slug) ||
+			!strings.EqualFold(from.Repository.Project.Key, to.Repository.Project.Key),
+	}
+	switch event {
+	case "pr:opened", "pr:from_ref_updated":
+		pr.Action = prActionUpdate
+	case "pr:merged", "pr:declined", "pr:deleted":
+		pr.Action = prActionClose
+	default:
+		return nil, fmt.Sprintf("event %s does not update the preview", event), nil
+	}
+	return pr, "", validatePullRequest(pr)
+}
+
+func validatePullRequest(pr *pullRequestEvent) error {
+	if pr.Number <= 0 {
+		return fmt.Errorf("could not find the pull request number in request payload")
+	}
+	if pr.Action == prActionUpdate && pr.Branch == "" {
+		return fmt.Errorf("could not find the pull request branch in request payload")
+	}
+	return nil
+}
+
+// prPreviewIgnoreReason returns the reason the pull request event is ignored for the sync entry,
+// empty if the previews are to be updated. Pull requests from forks are ignored, since the
+// preview apps run with the app bindings and secrets. Close events delete any existing previews
+func prPreviewIgnoreReason(entry *types.SyncEntry, pr *pullRequestEvent) string {
+	if !entry.Metadata.PrPreview {
+		return "pull request previews are not enabled for the sync"
+	}
+	if pr.Action == prActionClose {
+		return ""
+	}
+	if pr.FromFork {
+		return fmt.Sprintf("pull request %d is from a fork", pr.Number)
+	}
+	syncBranch := cmp.Or(entry.Metadata.GitBranch, "main")
+	if pr.BaseBranch != syncBranch {
+		return fmt.Sprintf("pull request %d targets branch %s, expected %s", pr.Number, pr.BaseBranch, syncBranch)
+	}
+	return ""
+}
+
+// triggerPrPreview updates the preview apps for the pull request in the background. The updates
+// are run one at a time
+func (s *Server) triggerPrPreview(id string, pr *pullRequestEvent) {
+	go func() {
+		s.prPreviewMu.Lock()
+		defer s.prPreviewMu.Unlock()
+		if s.prPreviewRunFunc != nil {
+			s.prPreviewRunFunc(id, pr)
+			return
+		}
+		s.runPrPreview(id, pr)
+	}()
+}
+
+// runPrPreview creates or updates the preview apps for the pull request, for the apps from the
+// sync which use the same git repo as the sync. The previews are deleted when the pull request
+// is closed
+func (s *Server) runPrPreview(id string, pr *pullRequestEvent) {
+	entry, err := s.getSyncEntry(context.Background(), id)
+	if err != nil {
+		s.Error().Err(err).Msgf("Error reading sync entry %s for pull request preview", id)
+		return
+	}
+	ctx := s.attachSyncRBAC(newBackgroundOperationContext(cmp.Or(entry.UserID, "webhook")), entry)
+
+	if pr.Action == prActionClose {
+		previews, err := s.getPrPreviewApps(ctx, entry.Id, pr.Number)
+		if err != nil {
+			s.Error().Err(err).Msgf("Error reading preview apps for sync %s", entry.Id)
+			return
+		}
+		for _, appEntry := range previews {
+			if err := s.deletePrPreviewApp(ctx, appEntry); err != nil {
+				s.Error().Err(err).Msgf("Error deleting preview app %s", appEntry)
+			}
+		}
+		return
+	}
+
+	repoCache, err := NewRepoCache(s)
+	if err != nil {
+		s.Error().Err(err).Msgf("Error creating repo cache for sync %s", entry.Id)
+		return
+	}
+	defer repoCache.Cleanup()
+	for _, appPath := range entry.Status.ApplyResponse.FilteredApps {
+		if err := s.updatePrPreviewApp(ctx, entry, appPath, pr, repoCache); err != nil {
+			s.Error().Err(err).Msgf("Error updating preview of %s for pull request %d", appPath, pr.Number)
+		}
+	}
+}
+
+// prPreviewAppPath returns the path for the pull request preview of the app, like /myapp_cl_pr_12
+func prPreviewAppPath(mainAppPath string, number int) string {
+	return mainAppPath + types.PR_PREVIEW_SUFFIX + "_" + strconv.Itoa(number)
+}
+
+// updatePrPreviewApp creates the preview app for the pull request. An existing preview app is
+// replaced with one from the new commit. Apps which are not from the sync repo are skipped
+func (s *Server) updatePrPreviewApp(ctx context.Context, entry *types.SyncEntry, mainAppPath types.AppPathDomain,
+	pr *pullRequestEvent, repoCache *RepoCache) error {
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+
+	mainAppEntry, err := s.db.GetAppEntryTx(ctx, tx, mainAppPath)
+	if err != nil {
+		return err
+	}
+	if mainAppEntry.IsDev || !system.IsGit(mainAppEntry.SourceUrl) || !sameGitRepo(mainAppEntry.SourceUrl, entry.Path) {
+		return nil
+	}
+
+	if err := s.enforceAppPermEntry(ctx, types.PermissionPreview, mainAppEntry); err != nil {
+		return err
+	}
+	for _, bindingPath := range mainAppEntry.Metadata.Bindings {
+		if err := s.enforceBindingSource(ctx, tx, bindingPath); err != nil {
+			return err
+		}
+	}
+
+	previewAppEntry := *mainAppEntry
+	previewAppEntry.Path = prPreviewAppPath(mainAppEntry.Path, pr.Number)
+	previewAppEntry.MainApp = mainAppEntry.Id
+	previewAppEntry.LinkedAppPath = mainAppEntry.AppPathDomain().String()
+	previewAppEntry.Id = types.AppId(fmt.Sprintf("%s%s_pr%d", types.ID_PREFIX_APP_PREVIEW,
+		string(mainAppEntry.Id)[len(types.ID_PREFIX_APP_PROD):], pr.Number))
+	previewAppEntry.UserID = system.GetContextUserId(ctx)
+
+	existing, err := s.db.GetAppEntryTx(ctx, tx, previewAppEntry.AppPathDomain())
+	if err == nil {
+		if existing.Settings.PrPreview == nil || existing.Settings.PrPreview.SyncId != entry.Id {
+			return fmt.Errorf("app %s exists and is not a preview for sync %s", existing, entry.Id)
+		}
+		if pr.CommitId != "" && existing.Settings.PrPreview.CommitId == pr.CommitId {
+			return nil // no new commits, like a title update
+		}
+		// The preview is recreated from the new commit
+		if err := s.db.DeleteApp(ctx, tx, existing.Id); err != nil {
+			return err
+		}
+	}
+
+	if auth := cmp.Or(entry.Metadata.PrPreviewAuth, s.Config().System.PrPreviewAuth); auth != "" {
+		previewAppEntry.Metadata.AuthnType = types.AppAuthnType(auth)
+	}
+	prPreview := &types.PrPreview{SyncId: entry.Id, Number: pr.Number, Branch: pr.Branch, CommitId: pr.CommitId}
+	if ttlHours := cmp.Or(entry.Metadata.PrPreviewTTLHours, s.Config().System.PrPreviewTTLHours); ttlHours > 0 {
+		expireAt := time.Now().Add(time.Duration(ttlHours) * time.Hour)
+		prPreview.ExpireAt = &expireAt
+	}
+	previewAppEntry.Settings.PrPreview = prPreview
+	previewAppEntry.Settings.Deprecation = nil
+
+	auditResult, err := s.loadPreviewApp(ctx, tx, &previewAppEntry, pr.Branch, pr.CommitId, entry.Metadata.Approve, repoCache)
+	if err != nil {
+		return err
+	}
+	if auditResult.NeedsApproval && !entry.Metadata.Approve {
+		return fmt.Errorf("preview app %s needs approval, create the sync with approve to approve the previews", previewAppEntry.AppPathDomain())
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.apps.ClearApps([]types.AppPathDomain{previewAppEntry.AppPathDomain()})
+	s.Info().Msgf("Updated preview app %s for pull request %d, commit %s", previewAppEntry.AppPathDomain(), pr.Number,
+		previewAppEntry.Metadata.VersionMetadata.GitCommit)
+	return nil
+}
+
+// sameGitRepo returns true if the app source is in the git repo of the sync apply file
+func sameGitRepo(sourceUrl, applyPath string) bool {
+	sourceRepo, _, err := parseGitUrl(sourceUrl, false)
+	if err != nil {
+		return false
+	}
+	applyRepo, _, err := parseGitUrl(applyPath, false)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(strings.TrimSuffix(sourceRepo, ".git"), strings.TrimSuffix(applyRepo, ".git"))
+}
+
+// getPrPreviewApps returns the pull request preview apps for the sync. All the pull requests
+// are included if number is zero, all the syncs if syncId is empty
+func (s *Server) getPrPreviewApps(ctx context.Context, syncId string, number int) ([]*types.AppEntry, error) {
+	apps, err := s.db.GetAllApps(true)
+	if err != nil {
+		return nil, err
+	}
+	ret := []*types.AppEntry{}
+	for _, appInfo := range apps {
+		if appInfo.MainApp == "" || !strings.Contains(appInfo.Path, types.PR_PREVIEW_SUFFIX+"_") {
+			continue
+		}
+		appEntry, err := s.db.GetAppEntry(ctx, appInfo.AppPathDomain)
+		if err != nil {
+			return nil, err
+		}
+		prPreview := appEntry.Settings.PrPreview
+		if prPreview == nil || (syncId != "" && prPreview.SyncId != syncId) || (number != 0 && prPreview.Number != number) {
+			continue
+		}
+		ret = append(ret, appEntry)
+	}
+	return ret, nil
+}
+
+// deletePrPreviewApp deletes the preview app. This is done by the system, the preview apps are
+// managed by the sync, so no permission is checked
+func (s *Server) deletePrPreviewApp(ctx context.Context, appEntry *types.AppEntry) error {
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	if err := s.db.DeleteApp(ctx, tx, appEntry.Id); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.apps.ClearApps([]types.AppPathDomain{appEntry.AppPathDomain()})
+	s.appLogs.remove(appEntry.Id)
+	s.Info().Msgf("Deleted preview app %s for pull request %d", appEntry, appEntry.Settings.PrPreview.Number)
+	return nil
+}
+
+// runPrPreviewExpiry deletes the pull request preview apps which have not been updated within
+// their TTL. It runs on the leader only, at most once an hour
+func (s *Server) runPrPreviewExpiry(ctx context.Context, runner *jobRunner) {
+	now := time.Now()
+	if now.Sub(runner.lastPrPreviewCheck) < time.Hour || !s.db.IsLeader() {
+		return
+	}
+	runner.lastPrPreviewCheck = now
+
+	previews, err := s.getPrPreviewApps(ctx, "", 0)
+	if err != nil {
+		s.Error().Err(err).Msg("Error reading pull request preview apps")
+		return
+	}
+	for _, appEntry := range previews {
+		if ctx.Err() != nil {
+			return
+		}
+		expireAt := appEntry.Settings.PrPreview.ExpireAt
+		if expireAt == nil || now.Before(*expireAt) {
+			continue
+		}
+		if err := s.deletePrPreviewApp(newBackgroundOperationContext(prPreviewUser), appEntry); err != nil {
+			s.Error().Err(err).Str("app", appEntry.String()).Msg("Error deleting expired preview app")
+		}
+	}
+}
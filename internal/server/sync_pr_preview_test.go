@@ -0,0 +1,113 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestParsePullRequestEvent(t *testing.T) {
+	github := func(action, headRepo string) string {
+		return `{"action":"` + action + `","number":12,"pull_request":{"head":{"ref":"feature","sha":"abc123","repo":{"full_name":"` +
+			headRepo + `"}},"base":{"ref":"main","repo":{"full_name":"org/apps"}}}}`
+	}
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		body    string
+		want    *pullRequestEvent
+		ignored string
+		wantErr string
+	}{
+		{name: "push", header: http.Header{"X-Github-Event": {"push"}}, body: `{"ref":"refs/heads/main"}`},
+		{name: "github opened", header: http.Header{"X-Github-Event": {"pull_request"}}, body: github("opened", "org/apps"),
+			want: &pullRequestEvent{Number: 12, Action: prActionUpdate, Branch: "feature", BaseBranch: "main", CommitId: "abc123"}},
+		{name: "github synchronize", header: http.Header{"X-Github-Event": {"pull_request"}}, body: github("synchronize", "org/apps"),
+			want: &pullRequestEvent{Number: 12, Action: prActionUpdate, Branch: "feature", BaseBranch: "main", CommitId: "abc123"}},
+		{name: "github closed", header: http.Header{"X-Github-Event": {"pull_request"}}, body: github("closed", "org/apps"),
+			want: &pullRequestEvent{Number: 12, Action: prActionClose, Branch: "feature", BaseBranch: "main", CommitId: "abc123"}},
+		{name: "github fork", header: http.Header{"X-Github-Event": {"pull_request"}}, body: github("opened", "user/apps"),
+			want: &pullRequestEvent{Number: 12, Action: prActionUpdate, Branch: "feature", BaseBranch: "main", CommitId: "abc123", FromFork: true}},
+		{name: "github labeled", header: http.Header{"X-Github-Event": {"pull_request"}}, body: github("labeled", "org/apps"),
+			ignored: "action labeled does not update the preview"},
+		{name: "gitea", header: http.Header{"X-Gitea-Event": {"pull_request"}}, body: github("synchronized", "org/apps"),
+			want: &pullRequestEvent{Number: 12, Action: prActionUpdate, Branch: "feature", BaseBranch: "main", CommitId: "abc123"}},
+		{name: "gitlab merge", header: http.Header{"X-Gitlab-Event": {"Merge Request Hook"}},
+			body: `{"object_attributes":{"iid":5,"action":"merge","source_branch":"fix","target_branch":"main","source_project_id":1,"target_project_id":1,"last_commit":{"id":"def"}}}`,
+			want: &pullRequestEvent{Number: 5, Action: prActionClose, Branch: "fix", BaseBranch: "main", CommitId: "def"}},
+		{name: "gitlab approved", header: http.Header{"X-Gitlab-Event": {"Merge Request Hook"}},
+			body: `{"object_attributes":{"iid":5,"action":"approved"}}`, ignored: "action approved does not update the preview"},
+		{name: "bitbucket cloud", header: http.Header{"X-Event-Key": {"pullrequest:updated"}},
+			body: `{"pullrequest":{"id":7,"source":{"branch":{"name":"fix"},"commit":{"hash":"f00"},"repository":{"full_name":"org/apps"}},` +
+				`"destination":{"branch":{"name":"main"},"repository":{"full_name":"org/apps"}}}}`,
+			want: &pullRequestEvent{Number: 7, Action: prActionUpdate, Branch: "fix", BaseBranch: "main", CommitId: "f00"}},
+		{name: "bitbucket data center", header: http.Header{"X-Event-Key": {"pr:declined"}},
+			body: `{"pullRequest":{"id":8,"fromRef":{"displayId":"fix","latestCommit":"b00","repository":{"slug":"apps","project":{"key":"ORG"}}},` +
+				`"toRef":{"displayId":"main","repository":{"slug":"apps","project":{"key":"ORG"}}}}}`,
+			want: &pullRequestEvent{Number: 8, Action: prActionClose, Branch: "fix", BaseBranch: "main", CommitId: "b00"}},
+		{name: "no number", header: http.Header{"X-Github-Event": {"pull_request"}}, body: `{"action":"opened"}`,
+			wantErr: "could not find the pull request number"},
+		{name: "invalid json", header: http.Header{"X-Github-Event": {"pull_request"}}, body: `action=opened`, wantErr: "expected JSON"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			pr, ignored, err := parsePullRequestEvent(tc.header, []byte(tc.body))
+			if tc.wantErr != "" {
+				testutil.AssertErrorContains(t, err, tc.wantErr)
+				return
+			}
+			testutil.AssertNoError(t, err)
+			if !strings.Contains(ignored, tc.ignored) || (tc.ignored == "") != (ignored == "") {
+				t.Errorf("ignored: want %q got %q", tc.ignored, ignored)
+			}
+			if tc.want == nil {
+				if pr != nil {
+					t.Errorf("expected no pull request event, got %+v", pr)
+				}
+				return
+			}
+			if pr == nil || *pr != *tc.want {
+				t.Errorf("pull request: want %+v got %+v", tc.want, pr)
+			}
+		})
+	}
+}
+
+func TestPrPreviewIgnoreReason(t *testing.T) {
+	entry := &types.SyncEntry{Id: "cl_syn_1", Metadata: types.SyncMetadata{GitBranch: "main"}}
+	pr := &pullRequestEvent{Number: 3, Action: prActionUpdate, Branch: "fix", BaseBranch: "main"}
+	testutil.AssertStringContains(t, prPreviewIgnoreReason(entry, pr), "not enabled")
+
+	entry.Metadata.PrPreview = true
+	testutil.AssertEqualsString(t, "enabled", "", prPreviewIgnoreReason(entry, pr))
+
+	fork := *pr
+	fork.FromFork = true
+	testutil.AssertStringContains(t, prPreviewIgnoreReason(entry, &fork), "is from a fork")
+	// Close events delete the previews even if the branch info is not available
+	fork.Action = prActionClose
+	testutil.AssertEqualsString(t, "close", "", prPreviewIgnoreReason(entry, &fork))
+
+	other := *pr
+	other.BaseBranch = "release"
+	testutil.AssertStringContains(t, prPreviewIgnoreReason(entry, &other), "targets branch release, expected main")
+}
+
+func TestPrPreviewAppPath(t *testing.T) {
+	testutil.AssertEqualsString(t, "path", "/myapp_cl_pr_12", prPreviewAppPath("/myapp", 12))
+	testutil.AssertEqualsString(t, "root", "/_cl_pr_3", prPreviewAppPath("/", 3))
+}
+
+func TestSameGitRepo(t *testing.T) {
+	testutil.AssertEqualsBool(t, "same", true, sameGitRepo("github.com/org/apps/app1", "github.com/org/apps/apps.ace"))
+	testutil.AssertEqualsBool(t, "case", true, sameGitRepo("https://github.com/Org/Apps/app1", "github.com/org/apps/apps.ace"))
+	testutil.AssertEqualsBool(t, "other repo", false, sameGitRepo("github.com/org/other/app1", "github.com/org/apps/apps.ace"))
+	testutil.AssertEqualsBool(t, "subgroup", true, sameGitRepo("gitlab.com/g1/g2/apps//app1", "gitlab.com/g1/g2/apps//apps.ace"))
+}
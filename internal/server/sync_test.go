@@ -0,0 +1,61 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestNextSyncRunTime(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	testutil.AssertNoError(t, err)
+	lastRun := time.Date(2026, 3, 10, 2, 0, 20, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		entry   types.SyncEntry
+		want    time.Time
+		wantErr string
+	}{
+		{name: "webhook", entry: types.SyncEntry{Metadata: types.SyncMetadata{WebhookSecret: "x"}}},
+		{name: "minutes", entry: types.SyncEntry{IsScheduled: true, Metadata: types.SyncMetadata{ScheduleFrequency: 15},
+			Status: types.SyncJobStatus{LastExecutionTime: lastRun}}, want: lastRun.Add(15 * time.Minute)},
+		{name: "cron", entry: types.SyncEntry{IsScheduled: true, Metadata: types.SyncMetadata{ScheduleCron: "0 2 * * *", ScheduleTimezone: "UTC"},
+			Status: types.SyncJobStatus{LastExecutionTime: lastRun}}, want: time.Date(2026, 3, 11, 2, 0, 0, 0, time.UTC)},
+		{name: "cron timezone", entry: types.SyncEntry{IsScheduled: true, Metadata: types.SyncMetadata{ScheduleCron: "@daily", ScheduleTimezone: "America/New_York"},
+			Status: types.SyncJobStatus{LastExecutionTime: lastRun}}, want: time.Date(2026, 3, 10, 0, 0, 0, 0, ny)},
+		{name: "invalid cron", entry: types.SyncEntry{IsScheduled: true, Metadata: types.SyncMetadata{ScheduleCron: "0 2 * *"}},
+			wantErr: "expected 5 fields"},
+		{name: "invalid timezone", entry: types.SyncEntry{IsScheduled: true, Metadata: types.SyncMetadata{ScheduleCron: "0 2 * * *", ScheduleTimezone: "Mars/Base"}},
+			wantErr: "invalid timezone"},
+		{name: "timezone without cron", entry: types.SyncEntry{IsScheduled: true, Metadata: types.SyncMetadata{ScheduleFrequency: 5, ScheduleTimezone: "UTC"}},
+			wantErr: "timezone is supported only with a cron schedule"},
+		{name: "minutes and cron", entry: types.SyncEntry{IsScheduled: true, Metadata: types.SyncMetadata{ScheduleFrequency: 5, ScheduleCron: "0 2 * * *"}},
+			wantErr: "only one of schedule minutes and cron schedule"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			next, err := nextSyncRunTime(&tc.entry)
+			if tc.wantErr != "" {
+				testutil.AssertErrorContains(t, err, tc.wantErr)
+				return
+			}
+			testutil.AssertNoError(t, err)
+			if !next.Equal(tc.want) {
+				t.Errorf("next run: want %s got %s", tc.want, next)
+			}
+		})
+	}
+
+	// An entry which was never run is due now
+	next, err := nextSyncRunTime(&types.SyncEntry{IsScheduled: true, Metadata: types.SyncMetadata{ScheduleCron: "0 2 * * *"}})
+	testutil.AssertNoError(t, err)
+	if next.After(time.Now()) {
+		t.Errorf("expected entry to be due, next run %s", next)
+	}
+}
@@ -0,0 +1,353 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/system"
+	"github.com/openrundev/openrun/internal/telemetry"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+const (
+	syncWebhookPath = "/sync"
+	syncWebhookOp   = "webhook_sync"
+
+	syncWebhookKeyPurpose   = "sync_webhook"
+	syncWebhookSecretPrefix = "cl_tkn_"
+)
+
+// syncWebhookUrl returns the url to configure in the git provider for a webhook sync entry, empty
+// for scheduled sync entries
+func (s *Server) syncWebhookUrl(entry *types.SyncEntry) string {
+	if entry.IsScheduled {
+		return ""
+	}
+	return fmt.Sprintf("%s%s%s?id=%s", s.getServerUri(), types.WEBHOOK_URL_PREFIX, syncWebhookPath, url.QueryEscape(entry.Id))
+}
+
+func (s *Server) getSyncEntry(ctx context.Context, id string) (*types.SyncEntry, error) {
+	tx, err := s.db.BeginTransaction(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback() //nolint:errcheck
+	return s.db.GetSyncEntry(ctx, tx, id)
+}
+
+// syncWebhookSecret returns the webhook secret for the sync entry, derived from the active
+// keyring key. The id of the key used is also returned
+func (s *Server) syncWebhookSecret(id string) (string, string, error) {
+	keyId, sig, err := s.keyring.Sign(syncWebhookKeyPurpose, []byte(id))
+	if err != nil {
+		return "", "", err
+	}
+	return keyId, syncWebhookSecretPrefix + base64.RawURLEncoding.EncodeToString(sig), nil
+}
+
+// syncWebhookSecrets returns the secrets accepted for the sync entry webhook. These are the
+// secrets derived from each keyring key, so the webhooks keep working after a key rotation till
+// the old key is removed. Sync entries created before the keyring have the secret stored
+func (s *Server) syncWebhookSecrets(entry *types.SyncEntry) ([]string, error) {
+	sigs, err := s.keyring.Signatures(syncWebhookKeyPurpose, []byte(entry.Id))
+	if err != nil {
+		return nil, err
+	}
+	secrets := make([]string, 0, len(sigs)+1)
+	if entry.Metadata.WebhookSecret != "" {
+		secrets = append(secrets, entry.Metadata.WebhookSecret)
+	}
+	for _, sig := range sigs {
+		secrets = append(secrets, syncWebhookSecretPrefix+base64.RawURLEncoding.EncodeToString(sig))
+	}
+	return secrets, nil
+}
+
+// verifySyncWebhook checks the webhook request auth against the sync entry secrets, the request
+// is accepted if any of the secrets matches
+func verifySyncWebhook(secrets []string, header http.Header, body []byte) error {
+	err := errors.New("no webhook secret available")
+	for _, secret := range secrets {
+		if err = verifyWebhookSecret(secret, header, body); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+// verifyWebhookSecret checks the webhook request auth against the secret. GitHub and
+// Gitea send a HMAC SHA256 signature of the body in X-Hub-Signature-256, Bitbucket sends it in
+// X-Hub-Signature. GitLab does not sign the body, it sends the secret in X-Gitlab-Token. A bearer
+// token in the Authorization header is accepted for other callers
+func verifyWebhookSecret(secret string, header http.Header, body []byte) error {
+	if signature := header.Get("X-Hub-Signature-256"); signature != "" {
+		return validateSignature(secret, signature, body)
+	}
+	if signature := header.Get("X-Hub-Signature"); signature != "" {
+		return validateSignature(secret, signature, body)
+	}
+
+	token := header.Get("X-Gitlab-Token")
+	if token == "" {
+		authHeader := header.Get("Authorization")
+		if authHeader == "" {
+			return errors.New("no signature or token found")
+		}
+		if !strings.HasPrefix(authHeader, "Bearer ") {
+			return errors.New("bearer token is required in the Authorization header")
+		}
+		token = strings.TrimSpace(strings.TrimPrefix(authHeader, "Bearer "))
+	}
+	if subtle.ConstantTimeCompare([]byte(secret), []byte(token)) != 1 {
+		return errors.New("invalid token")
+	}
+	return nil
+}
+
+// pushedBranches returns the branches updated by the push event. The ignore reason is set for
+// events which are not branch pushes, like the ping event sent when the webhook is created
+func pushedBranches(header http.Header, body []byte) (branches []string, ignoreReason string, err error) {
+	event := cmp.Or(header.Get("X-GitHub-Event"), header.Get("X-Gitea-Event"), header.Get("X-Gitlab-Event"), header.Get("X-Event-Key"))
+	switch event {
+	case "", "push", "Push Hook", "repo:push", "repo:refs_changed":
+	default:
+		return nil, fmt.Sprintf("event %s is not a push", event), nil
+	}
+
+	var payload struct {
+		// GitHub, Gitea and GitLab
+		Ref string `json:"ref"`
+		// Bitbucket Cloud
+		Push struct {
+			Changes []struct {
+				New *struct {
+					Type string `json:"type"`
+					Name string `json:"name"`
+				} `json:"new"`
+			} `json:"changes"`
+		} `json:"push"`
+		// Bitbucket Data Center
+		Changes []struct {
+			RefId string `json:"refId"`
+		} `json:"changes"`
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, "", fmt.Errorf("error parsing request, expected JSON: %w", err)
+	}
+
+	refs := []string{}
+	if payload.Ref != "" {
+		refs = append(refs, payload.Ref)
+	}
+	for _, change := range payload.Push.Changes {
+		if change.New != nil && change.New.Type == "branch" {
+			refs = append(refs, "refs/heads/"+change.New.Name)
+		}
+	}
+	for _, change := range payload.Changes {
+		refs = append(refs, change.RefId)
+	}
+	if len(refs) == 0 {
+		return nil, "", errors.New("could not find the branch info in request payload")
+	}
+
+	for _, ref := range refs {
+		if branch, ok := strings.CutPrefix(ref, "refs/heads/"); ok {
+			branches = append(branches, branch)
+		}
+	}
+	if len(branches) == 0 {
+		// Tag pushes and deleted branches
+		return nil, fmt.Sprintf("no branch updated, refs %s", strings.Join(refs, ",")), nil
+	}
+	return branches, "", nil
+}
+
+// syncWebhookHandler handles the push webhooks for webhook sync entries. The request is verified
+// using the entry secret and the sync job is run in the background if the pushed branch is the
+// sync branch. A push received while the job is running queues one more run, pushes received
+// while a run is queued are merged with it. Pull request events update the preview apps, if
+// enabled for the entry
+func (h *Handler) syncWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "id is required for sync webhook call", http.StatusBadRequest)
+		return
+	}
+	entry, err := h.server.getSyncEntry(r.Context(), id)
+	if err != nil {
+		http.Error(w, "sync entry not found", http.StatusNotFound)
+		return
+	}
+	if entry.IsScheduled {
+		http.Error(w, fmt.Sprintf("sync %s is not a webhook sync", id), http.StatusBadRequest)
+		return
+	}
+
+	const maxWebhookBody = 10 << 20 // 10 MiB
+	r.Body = http.MaxBytesReader(w, r.Body, maxWebhookBody)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("error reading request body: %s", err), http.StatusBadRequest)
+		return
+	}
+	secrets, err := h.server.syncWebhookSecrets(entry)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := verifySyncWebhook(secrets, r.Header, body); err != nil {
+		h.server.insertAuthFailureEvent(r, syncWebhookOp, err.Error())
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	// Authenticated, all failures from here on are audited
+	event := types.AuditEvent{
+		RequestId:  system.GetContextRequestId(r.Context()),
+		CreateTime: time.Now(),
+		UserId:     system.GetContextUserId(r.Context()),
+		EventType:  types.EventTypeSystem,
+		Operation:  syncWebhookOp,
+		Target:     entry.Id,
+		Status:     string(types.EventStatusFailure),
+	}
+	defer func() {
+		if err := h.server.InsertAuditEvent(&event); err != nil {
+			h.Error().Err(err).Msg("error inserting audit event")
+		}
+	}()
+
+	writeResponse := func(code int, resp map[string]string) {
+		w.Header().Add("Content-Type", "application/json")
+		w.WriteHeader(code)
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+
+	pr, ignoreReason, err := parsePullRequestEvent(r.Header, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if pr != nil || ignoreReason != "" {
+		// Pull request event, update the preview apps
+		if ignoreReason == "" {
+			ignoreReason = prPreviewIgnoreReason(entry, pr)
+		}
+		if ignoreReason != "" {
+			h.Info().Msgf("Ignoring pull request webhook call for sync %s, %s", entry.Id, ignoreReason)
+			event.Status = string(types.EventStatusSuccess)
+			event.Detail = "ignored: " + ignoreReason
+			writeResponse(http.StatusOK, map[string]string{"id": entry.Id, "ignored": ignoreReason})
+			return
+		}
+		h.server.triggerPrPreview(entry.Id, pr)
+		h.Info().Msgf("Webhook call for sync %s, pull request %d %s", entry.Id, pr.Number, pr.Action)
+		event.Status = string(types.EventStatusSuccess)
+		event.Detail = fmt.Sprintf("pull request %d preview %s", pr.Number, pr.Action)
+		writeResponse(http.StatusAccepted, map[string]string{"id": entry.Id, "pull_request": strconv.Itoa(pr.Number), "status": pr.Action})
+		return
+	}
+
+	branches, ignoreReason, err := pushedBranches(r.Header, body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	syncBranch := cmp.Or(entry.Metadata.GitBranch, "main")
+	if ignoreReason == "" && !slices.Contains(branches, syncBranch) {
+		ignoreReason = fmt.Sprintf("branch mismatch, found %s, expected %s", strings.Join(branches, ","), syncBranch)
+	}
+	if ignoreReason != "" {
+		h.Info().Msgf("Ignoring webhook call for sync %s, %s", entry.Id, ignoreReason)
+		event.Status = string(types.EventStatusSuccess)
+		event.Detail = "ignored: " + ignoreReason
+		writeResponse(http.StatusOK, map[string]string{"id": entry.Id, "ignored": ignoreReason})
+		return
+	}
+
+	status := h.server.triggerWebhookSync(entry.Id)
+	h.Info().Msgf("Webhook call for sync %s, branch %s, run %s", entry.Id, syncBranch, status)
+	event.Status = string(types.EventStatusSuccess)
+	event.Detail = "run " + status
+	writeResponse(http.StatusAccepted, map[string]string{"id": entry.Id, "status": status})
+}
+
+// triggerWebhookSync runs the sync job in the background. Returns started if a new run was
+// started, queued if a run is in progress, in which case the job is run again after the current
+// run completes
+func (s *Server) triggerWebhookSync(id string) string {
+	s.syncWebhookMu.Lock()
+	defer s.syncWebhookMu.Unlock()
+	if s.syncWebhookRuns == nil {
+		s.syncWebhookRuns = make(map[string]bool)
+	}
+	if _, running := s.syncWebhookRuns[id]; running {
+		s.syncWebhookRuns[id] = true
+		return "queued"
+	}
+	s.syncWebhookRuns[id] = false
+	go func() {
+		for {
+			s.runWebhookSync(id)
+			s.syncWebhookMu.Lock()
+			if !s.syncWebhookRuns[id] {
+				delete(s.syncWebhookRuns, id)
+				s.syncWebhookMu.Unlock()
+				return
+			}
+			s.syncWebhookRuns[id] = false
+			s.syncWebhookMu.Unlock()
+		}
+	}()
+	return "started"
+}
+
+// runWebhookSync runs the sync job for the entry, like a scheduled run. The entry is read again,
+// so that the latest status is used for the commit check
+func (s *Server) runWebhookSync(id string) {
+	if s.syncWebhookRunFunc != nil {
+		s.syncWebhookRunFunc(id)
+		return
+	}
+
+	entry, err := s.getSyncEntry(context.Background(), id)
+	if err != nil {
+		s.Error().Err(err).Msgf("Error reading sync entry %s for webhook run", id)
+		return
+	}
+	repoCache, err := NewRepoCache(s)
+	if err != nil {
+		s.Error().Err(err).Msgf("Error creating repo cache for sync %s", id)
+		return
+	}
+	defer repoCache.Cleanup()
+
+	jobCtx := s.attachSyncRBAC(newBackgroundOperationContext(cmp.Or(entry.UserID, "webhook")), entry)
+	syncStatus, updatedApps, err := s.runSyncJob(jobCtx, types.Transaction{}, entry, false, true, repoCache) // runs in its own transaction
+	telemetry.RecordSyncRun(jobCtx, "webhook", syncOutcome(syncStatus, err))
+	if err != nil {
+		s.Error().Err(err).Msgf("Error running sync job %s for webhook", id)
+		return
+	}
+	s.reportSyncCommitStatus(entry, syncStatus, repoCache)
+	s.notifySync(entry, syncStatus, repoCache)
+	if len(updatedApps) > 0 {
+		s.CleanupVersions()
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func TestVerifySyncWebhook(t *testing.T) {
+	secret := "cl_tkn_secret"
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	signature := "sha256=" + hashPayload(secret, body)
+
+	tests := []struct {
+		name    string
+		header  http.Header
+		wantErr string
+	}{
+		{name: "github", header: http.Header{"X-Hub-Signature-256": {signature}}},
+		{name: "bitbucket", header: http.Header{"X-Hub-Signature": {signature}}},
+		{name: "gitlab", header: http.Header{"X-Gitlab-Token": {secret}}},
+		{name: "bearer", header: http.Header{"Authorization": {"Bearer " + secret}}},
+		{name: "bad signature", header: http.Header{"X-Hub-Signature-256": {"sha256=abc"}}, wantErr: "invalid payload"},
+		{name: "bad gitlab token", header: http.Header{"X-Gitlab-Token": {"other"}}, wantErr: "invalid token"},
+		{name: "basic auth", header: http.Header{"Authorization": {"Basic abc"}}, wantErr: "bearer token is required"},
+		{name: "no auth", header: http.Header{}, wantErr: "no signature or token found"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verifySyncWebhook([]string{"cl_tkn_other", secret}, tc.header, body)
+			if tc.wantErr == "" {
+				testutil.AssertNoError(t, err)
+			} else {
+				testutil.AssertErrorContains(t, err, tc.wantErr)
+			}
+		})
+	}
+
+	// The signature is of the body
+	err := verifySyncWebhook([]string{secret}, http.Header{"X-Hub-Signature-256": {signature}}, []byte(`{"ref":"refs/heads/other"}`))
+	testutil.AssertErrorContains(t, err, "invalid payload")
+
+	err = verifySyncWebhook(nil, http.Header{"X-Gitlab-Token": {secret}}, body)
+	testutil.AssertErrorContains(t, err, "no webhook secret available")
+}
+
+func TestPushedBranches(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   http.Header
+		body     string
+		branches string
+		ignored  string
+		wantErr  string
+	}{
+		{name: "github", header: http.Header{"X-Github-Event": {"push"}}, body: `{"ref":"refs/heads/main"}`, branches: "main"},
+		{name: "github ping", header: http.Header{"X-Github-Event": {"ping"}}, body: `{"zen":"x"}`, ignored: "event ping is not a push"},
+		{name: "gitlab", header: http.Header{"X-Gitlab-Event": {"Push Hook"}}, body: `{"ref":"refs/heads/dev"}`, branches: "dev"},
+		{name: "bitbucket cloud", header: http.Header{"X-Event-Key": {"repo:push"}},
+			body: `{"push":{"changes":[{"new":{"type":"branch","name":"main"}},{"new":null},{"new":{"type":"tag","name":"v1"}}]}}`, branches: "main"},
+		{name: "bitbucket data center", header: http.Header{"X-Event-Key": {"repo:refs_changed"}},
+			body: `{"changes":[{"refId":"refs/heads/main"},{"refId":"refs/heads/feature"}]}`, branches: "main,feature"},
+		{name: "tag push", header: http.Header{}, body: `{"ref":"refs/tags/v1.0"}`, ignored: "no branch updated"},
+		{name: "no ref", header: http.Header{}, body: `{}`, wantErr: "could not find the branch info"},
+		{name: "invalid json", header: http.Header{}, body: `ref=main`, wantErr: "expected JSON"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			branches, ignored, err := pushedBranches(tc.header, []byte(tc.body))
+			if tc.wantErr != "" {
+				testutil.AssertErrorContains(t, err, tc.wantErr)
+				return
+			}
+			testutil.AssertNoError(t, err)
+			testutil.AssertEqualsString(t, "branches", tc.branches, strings.Join(branches, ","))
+			if !strings.Contains(ignored, tc.ignored) || (tc.ignored == "") != (ignored == "") {
+				t.Errorf("ignored: want %q got %q", tc.ignored, ignored)
+			}
+		})
+	}
+}
+
+func TestTriggerWebhookSync(t *testing.T) {
+	s := &Server{Logger: testutil.TestLogger()}
+	release := make(chan struct{})
+	var mu sync.Mutex
+	runs := 0
+	done := make(chan struct{}, 10)
+	s.syncWebhookRunFunc = func(id string) {
+		<-release
+		mu.Lock()
+		runs++
+		mu.Unlock()
+		done <- struct{}{}
+	}
+
+	testutil.AssertEqualsString(t, "first", "started", s.triggerWebhookSync("cl_syn_1"))
+	// Pushes during the run are merged into one more run
+	testutil.AssertEqualsString(t, "second", "queued", s.triggerWebhookSync("cl_syn_1"))
+	testutil.AssertEqualsString(t, "third", "queued", s.triggerWebhookSync("cl_syn_1"))
+	// Other entries are not blocked
+	testutil.AssertEqualsString(t, "other", "started", s.triggerWebhookSync("cl_syn_2"))
+
+	close(release)
+	for range 3 {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for sync runs")
+		}
+	}
+	select {
+	case <-done:
+		t.Fatal("unexpected extra sync run")
+	case <-time.After(50 * time.Millisecond):
+	}
+	mu.Lock()
+	testutil.AssertEqualsInt(t, "runs", 3, runs)
+	mu.Unlock()
+
+	// Completed runs are removed, a new push starts a new run
+	for {
+		s.syncWebhookMu.Lock()
+		pending := len(s.syncWebhookRuns)
+		s.syncWebhookMu.Unlock()
+		if pending == 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	testutil.AssertEqualsString(t, "after", "started", s.triggerWebhookSync("cl_syn_1"))
+	<-done
+}
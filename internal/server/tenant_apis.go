@@ -0,0 +1,124 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// Tenants partition the apps on a server by domain and path prefix, see rbac/tenant.go for the
+// namespace and authorization rules. The server applies the tenant app quota on create and
+// scopes the audit log for tenant admins.
+
+// tenantApps returns the apps in the given tenants. Stage and preview apps are included with
+// their main app if includeLinked is set
+func (s *Server) tenantApps(tenants []string, includeLinked bool) ([]types.AppInfo, error) {
+	allApps, err := s.apps.GetAllAppsInfo()
+	if err != nil {
+		return nil, err
+	}
+	ret := []types.AppInfo{}
+	for _, appInfo := range allApps {
+		if appInfo.MainApp != "" && !includeLinked {
+			continue
+		}
+		tenant := s.rbacManager.TenantForApp(mainAppPathDomain(appInfo.AppPathDomain, appInfo.MainApp, appInfo.LinkedAppPath))
+		if tenant != "" && slices.Contains(tenants, tenant) {
+			ret = append(ret, appInfo)
+		}
+	}
+	return ret, nil
+}
+
+// checkTenantQuota checks that creating a new app at appPathDomain does not take its tenant over
+// the max_apps limit. Only main apps count against the limit
+func (s *Server) checkTenantQuota(appPathDomain types.AppPathDomain) error {
+	tenant := s.rbacManager.TenantForApp(appPathDomain)
+	if tenant == "" {
+		return nil
+	}
+	maxApps := s.rbacManager.Tenants()[tenant].MaxApps
+	if maxApps == 0 {
+		return nil
+	}
+	apps, err := s.tenantApps([]string{tenant}, false)
+	if err != nil {
+		return err
+	}
+	if len(apps) >= maxApps {
+		return types.CreateRequestError(
+			fmt.Sprintf("tenant %s has reached its limit of %d apps", tenant, maxApps), http.StatusBadRequest)
+	}
+	return nil
+}
+
+// auditTenantScope returns the app ids the user can read the audit events for. scoped is false
+// if the user can read the full audit log. Users without audit:read who are admins of some
+// tenants can read the audit events of the apps in those tenants
+func (s *Server) auditTenantScope(ctx context.Context) (appIds []types.AppId, scoped bool, err error) {
+	if !s.rbacManager.APIEnforced(ctx) {
+		return nil, false, nil
+	}
+	authorized, err := s.rbacManager.AuthorizeGlobalAPI(ctx, types.PermissionAuditRead, "")
+	if err != nil || authorized {
+		return nil, false, err
+	}
+	tenants, err := s.rbacManager.AdminTenants(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	if len(tenants) == 0 {
+		return nil, false, s.rbacDenied(ctx, types.PermissionAuditRead, "server")
+	}
+	apps, err := s.tenantApps(tenants, true)
+	if err != nil {
+		return nil, false, err
+	}
+	appIds = make([]types.AppId, 0, len(apps))
+	for _, appInfo := range apps {
+		appIds = append(appIds, appInfo.Id)
+	}
+	return appIds, true, nil
+}
+
+// ListTenants returns the configured tenants with their app counts. Users who are not admins
+// see only the tenants they are an admin of
+func (s *Server) ListTenants(ctx context.Context) ([]types.TenantInfo, error) {
+	tenants := s.rbacManager.Tenants()
+	names := slices.Sorted(maps.Keys(tenants))
+
+	if s.rbacManager.APIEnforced(ctx) {
+		isAdmin, err := s.rbacManager.AuthorizeGlobalAPI(ctx, types.PermissionAdmin, "")
+		if err != nil {
+			return nil, err
+		}
+		if !isAdmin {
+			if names, err = s.rbacManager.AdminTenants(ctx); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	apps, err := s.tenantApps(names, false)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]types.TenantInfo, 0, len(names))
+	for _, name := range names {
+		info := types.TenantInfo{Name: name, TenantConfig: tenants[name]}
+		for _, appInfo := range apps {
+			if s.rbacManager.TenantForApp(appInfo.AppPathDomain) == name {
+				info.AppCount++
+			}
+		}
+		ret = append(ret, info)
+	}
+	return ret, nil
+}
@@ -0,0 +1,317 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/openrundev/openrun/internal/container"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// volumeManager returns the container manager used for the volume operations. Kubernetes is
+// not supported, the volumes are persistent volume claims managed by the cluster
+func (s *Server) volumeManager() (*container.CommandCM, error) {
+	if s.Config().System.ContainerCommand == types.CONTAINER_KUBERNETES {
+		return nil, types.CreateRequestError("volume management is not supported for Kubernetes", http.StatusBadRequest)
+	}
+	if s.containerRuntime() == "" {
+		return nil, types.CreateRequestError("no container command is configured on the server", http.StatusBadRequest)
+	}
+	return container.NewCommandCM(s.Logger, s.Config(), "", ""), nil
+}
+
+// listAppVolumes returns all the app volumes, with the app path and the volume dir filled in.
+// Volumes whose app is not in the metadata database are marked as orphaned. Archived apps are
+// in the database, their volumes are retained for a restore
+func (s *Server) listAppVolumes(ctx context.Context, manager *container.CommandCM) ([]types.AppVolume, error) {
+	volumes, err := manager.ListVolumes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	apps, err := s.db.GetAllApps(true)
+	if err != nil {
+		return nil, err
+	}
+	appPaths := make(map[types.AppId]string, len(apps))
+	for _, appInfo := range apps {
+		appPaths[appInfo.Id] = appInfo.AppPathDomain.String()
+	}
+	volumeDirs := map[container.VolumeName]string{}
+	for _, loadedApp := range s.apps.LoadedApps() {
+		for name, dir := range loadedApp.VolumeDirs() {
+			volumeDirs[name] = dir
+		}
+	}
+
+	for i := range volumes {
+		path, ok := appPaths[volumes[i].AppId]
+		volumes[i].AppPath = path
+		volumes[i].Orphaned = !ok
+		volumes[i].Dir = volumeDirs[container.VolumeName(volumes[i].Name)]
+	}
+	return volumes, nil
+}
+
+// ListVolumes lists the volumes created for the apps. If appPath is set, only the volumes of
+// that app and its stage and preview apps are returned
+func (s *Server) ListVolumes(ctx context.Context, appPath string) (*types.VolumeListResponse, error) {
+	if err := s.enforceGlobalPerm(ctx, types.PermissionContainerRead, ""); err != nil {
+		return nil, err
+	}
+	manager, err := s.volumeManager()
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := s.listAppVolumes(ctx, manager)
+	if err != nil {
+		return nil, err
+	}
+	if appPath == "" {
+		return &types.VolumeListResponse{Volumes: volumes}, nil
+	}
+
+	appEntry, err := s.volumeAppEntry(ctx, appPath)
+	if err != nil {
+		return nil, err
+	}
+	apps, err := s.db.GetAllApps(true)
+	if err != nil {
+		return nil, err
+	}
+	appIds := map[types.AppId]bool{appEntry.Id: true}
+	for _, appInfo := range apps {
+		if appInfo.MainApp == appEntry.Id {
+			appIds[appInfo.Id] = true
+		}
+	}
+	filtered := []types.AppVolume{}
+	for _, volume := range volumes {
+		if appIds[volume.AppId] {
+			filtered = append(filtered, volume)
+		}
+	}
+	return &types.VolumeListResponse{Volumes: filtered}, nil
+}
+
+func (s *Server) volumeAppEntry(ctx context.Context, appPath string) (*types.AppEntry, error) {
+	appPathDomain, err := parseAppPath(appPath)
+	if err != nil {
+		return nil, err
+	}
+	return s.db.GetAppEntry(ctx, appPathDomain)
+}
+
+// resolveVolume returns the volume name for the app. The volume is either the volume name as
+// listed by ListVolumes or the volume dir from the app config, the volume name or the mount path
+// for unnamed volumes
+func resolveVolume(appId types.AppId, volume string) (container.VolumeName, error) {
+	if volume == "" {
+		return "", types.CreateRequestError("volume is required", http.StatusBadRequest)
+	}
+	if volumeAppId, ok := container.VolumeAppId(volume); ok {
+		if volumeAppId != appId {
+			return "", types.CreateRequestError(fmt.Sprintf("volume %s does not belong to app %s", volume, appId), http.StatusBadRequest)
+		}
+		return container.VolumeName(volume), nil
+	}
+	return container.GenVolumeName(appId, volume), nil
+}
+
+// BackupVolume writes a tar archive of the app volume. The backup is saved in
+// system.volume_backup_dir if target is empty, else it is uploaded to the s3://bucket/prefix
+// target url. The volume is read while the app is running, stop the app for a consistent backup
+// of volumes which are being written to
+func (s *Server) BackupVolume(ctx context.Context, appPath, volume, target string) (*types.VolumeBackupResponse, error) {
+	appEntry, err := s.volumeAppEntry(ctx, appPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.enforceGlobalPerm(ctx, types.PermissionContainerManage, ""); err != nil {
+		return nil, err
+	}
+	if err := s.enforceAppPermEntry(ctx, types.PermissionUpdate, appEntry); err != nil {
+		return nil, err
+	}
+	volumeName, err := resolveVolume(appEntry.Id, volume)
+	if err != nil {
+		return nil, err
+	}
+	manager, err := s.volumeManager()
+	if err != nil {
+		return nil, err
+	}
+	if !manager.VolumeExists(ctx, volumeName) {
+		return nil, types.CreateRequestError(fmt.Sprintf("volume %s not found", volumeName), http.StatusNotFound)
+	}
+
+	var store *volumeS3Store
+	backupDir := os.ExpandEnv(s.Config().System.VolumeBackupDir)
+	if target != "" {
+		if !strings.HasPrefix(target, "s3://") {
+			return nil, types.CreateRequestError(fmt.Sprintf("invalid target %s, has to be a s3:// url", target), http.StatusBadRequest)
+		}
+		if store, err = newVolumeS3Store(ctx, target); err != nil {
+			return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		}
+	} else if backupDir == "" {
+		return nil, types.CreateRequestError("system.volume_backup_dir is not set", http.StatusBadRequest)
+	}
+
+	// The backup is written to a temp file first, so that a partial backup is not left behind on
+	// errors. For s3, the temp file is used to compute the payload hash before the upload
+	tmpDir := ""
+	if store == nil {
+		if err := os.MkdirAll(backupDir, 0700); err != nil {
+			return nil, err
+		}
+		tmpDir = backupDir
+	}
+	fileName := fmt.Sprintf("%s-%s.tar", volumeName, time.Now().Format("20060102-150405"))
+	tmp, err := os.CreateTemp(tmpDir, fileName+".tmp*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name()) //nolint:errcheck
+	defer tmp.Close()           //nolint:errcheck
+
+	if err := manager.BackupVolume(ctx, volumeName, tmp); err != nil {
+		return nil, err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		return nil, err
+	}
+	ret := &types.VolumeBackupResponse{Volume: string(volumeName), Size: info.Size()}
+
+	if store != nil {
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return nil, err
+		}
+		key := strings.TrimPrefix(store.key+"/"+fileName, "/")
+		if err := store.put(ctx, key, tmp); err != nil {
+			return nil, err
+		}
+		ret.Location = store.location(key)
+	} else {
+		if err := tmp.Close(); err != nil {
+			return nil, err
+		}
+		ret.Location = filepath.Join(backupDir, fileName)
+		if err := os.Rename(tmp.Name(), ret.Location); err != nil {
+			return nil, err
+		}
+	}
+	s.Info().Msgf("Backed up volume %s for app %s to %s", volumeName, appEntry.AppPathDomain(), ret.Location)
+	return ret, nil
+}
+
+// RestoreVolume restores the app volume from a backup created by BackupVolume. The source is the
+// file name of the backup in system.volume_backup_dir or the s3:// url of the backup. The volume
+// should not be used by a running container, pause the app before the restore
+func (s *Server) RestoreVolume(ctx context.Context, appPath, volume, source string) (*types.VolumeRestoreResponse, error) {
+	appEntry, err := s.volumeAppEntry(ctx, appPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.enforceGlobalPerm(ctx, types.PermissionContainerManage, ""); err != nil {
+		return nil, err
+	}
+	if err := s.enforceAppPermEntry(ctx, types.PermissionUpdate, appEntry); err != nil {
+		return nil, err
+	}
+	volumeName, err := resolveVolume(appEntry.Id, volume)
+	if err != nil {
+		return nil, err
+	}
+	if source == "" {
+		return nil, types.CreateRequestError("source is required", http.StatusBadRequest)
+	}
+	manager, err := s.volumeManager()
+	if err != nil {
+		return nil, err
+	}
+	inUse, err := manager.VolumeInUse(ctx, volumeName, true)
+	if err != nil {
+		return nil, err
+	}
+	if inUse {
+		return nil, types.CreateRequestError(fmt.Sprintf("volume %s is used by a running container, pause the app using "+
+			"\"openrun app pause %s\" before the restore", volumeName, appPath), http.StatusConflict)
+	}
+
+	var reader io.ReadCloser
+	if strings.HasPrefix(source, "s3://") {
+		store, err := newVolumeS3Store(ctx, source)
+		if err != nil {
+			return nil, types.CreateRequestError(err.Error(), http.StatusBadRequest)
+		}
+		if reader, err = store.get(ctx, store.key); err != nil {
+			return nil, err
+		}
+	} else {
+		// Only the backups in the backup dir can be restored, not arbitrary files on the server
+		if strings.ContainsAny(source, `/\`) || source == "." || source == ".." {
+			return nil, types.CreateRequestError(fmt.Sprintf("invalid source %s, has to be a backup file name or a s3:// url", source),
+				http.StatusBadRequest)
+		}
+		backupDir := os.ExpandEnv(s.Config().System.VolumeBackupDir)
+		if backupDir == "" {
+			return nil, types.CreateRequestError("system.volume_backup_dir is not set", http.StatusBadRequest)
+		}
+		if reader, err = os.Open(filepath.Join(backupDir, source)); err != nil {
+			return nil, types.CreateRequestError(fmt.Sprintf("error opening backup %s: %s", source, err), http.StatusBadRequest)
+		}
+	}
+	defer reader.Close() //nolint:errcheck
+
+	if err := manager.RestoreVolume(ctx, volumeName, reader); err != nil {
+		return nil, err
+	}
+	s.Info().Msgf("Restored volume %s for app %s from %s", volumeName, appEntry.AppPathDomain(), source)
+	return &types.VolumeRestoreResponse{Volume: string(volumeName), Source: source}, nil
+}
+
+// GCVolumes removes the volumes whose apps were deleted. The volumes of archived apps are
+// retained. Orphaned volumes used by a container are kept
+func (s *Server) GCVolumes(ctx context.Context, dryRun bool) (*types.VolumeGCResponse, error) {
+	if err := s.enforceGlobalPerm(ctx, types.PermissionContainerManage, ""); err != nil {
+		return nil, err
+	}
+	manager, err := s.volumeManager()
+	if err != nil {
+		return nil, err
+	}
+	volumes, err := s.listAppVolumes(ctx, manager)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &types.VolumeGCResponse{DryRun: dryRun, Removed: []types.AppVolume{}, Kept: []types.AppVolume{}}
+	for _, volume := range volumes {
+		if !volume.Orphaned {
+			continue
+		}
+		if volume.InUse {
+			ret.Kept = append(ret.Kept, volume)
+			continue
+		}
+		if !dryRun {
+			if err := manager.RemoveVolume(ctx, container.VolumeName(volume.Name)); err != nil {
+				volume.Error = err.Error()
+				ret.Kept = append(ret.Kept, volume)
+				continue
+			}
+		}
+		ret.Removed = append(ret.Removed, volume)
+	}
+	return ret, nil
+}
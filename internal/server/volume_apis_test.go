@@ -0,0 +1,27 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"testing"
+
+	"github.com/openrundev/openrun/internal/container"
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func TestResolveVolume(t *testing.T) {
+	name, err := resolveVolume("app_prd_one", "data")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "dir", string(container.GenVolumeName("app_prd_one", "data")), string(name))
+
+	// The full volume name is accepted for the volumes of the app
+	name, err = resolveVolume("app_prd_one", string(container.GenVolumeName("app_prd_one", "/data")))
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "name", string(container.GenVolumeName("app_prd_one", "/data")), string(name))
+
+	_, err = resolveVolume("app_prd_one", string(container.GenVolumeName("app_prd_two", "data")))
+	testutil.AssertErrorContains(t, err, "does not belong to app")
+	_, err = resolveVolume("app_prd_one", "")
+	testutil.AssertErrorContains(t, err, "volume is required")
+}
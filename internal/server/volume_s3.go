@@ -0,0 +1,153 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awscfg "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// volumeS3Store uploads and downloads the volume backups using SigV4 signed requests. The
+// credentials are loaded using the default AWS config chain (env, shared config, instance role).
+// The s3 url supports the region and endpoint query params, the endpoint is for S3 compatible
+// stores like MinIO and uses path style addressing
+type volumeS3Store struct {
+	bucket      string
+	key         string // the object key for restore, the key prefix for backup
+	region      string
+	endpoint    string
+	credentials aws.CredentialsProvider
+	signer      *v4.Signer
+	client      *http.Client
+}
+
+func newVolumeS3Store(ctx context.Context, location string) (*volumeS3Store, error) {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme != "s3" {
+		return nil, fmt.Errorf("invalid s3 url %s", location)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid s3 url %s, bucket is required", location)
+	}
+
+	options := []func(*awscfg.LoadOptions) error{}
+	if region := u.Query().Get("region"); region != "" {
+		options = append(options, awscfg.WithRegion(region))
+	}
+	cfg, err := awscfg.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("error loading aws config for volume backup: %w", err)
+	}
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("region is required for %s, set the region query param or AWS_REGION", location)
+	}
+	if cfg.Credentials == nil {
+		return nil, fmt.Errorf("no aws credentials found for %s", location)
+	}
+
+	endpoint := strings.TrimSuffix(u.Query().Get("endpoint"), "/")
+	if endpoint != "" && !strings.HasPrefix(endpoint, "https://") && !strings.HasPrefix(endpoint, "http://") {
+		return nil, fmt.Errorf("invalid endpoint %s for volume backup, has to be a http(s) url", endpoint)
+	}
+
+	return &volumeS3Store{
+		bucket:      u.Host,
+		key:         strings.Trim(u.Path, "/"),
+		region:      cfg.Region,
+		endpoint:    endpoint,
+		credentials: cfg.Credentials,
+		signer:      v4.NewSigner(),
+		// No client timeout, the volume backups can be large. The request context is used for cancellation
+		client: &http.Client{},
+	}, nil
+}
+
+// location returns the s3 url for the object key
+func (s *volumeS3Store) location(key string) string {
+	return "s3://" + s.bucket + "/" + key
+}
+
+func (s *volumeS3Store) objectUrl(key string) string {
+	escapedKey := (&url.URL{Path: key}).EscapedPath()
+	if s.endpoint != "" {
+		return s.endpoint + "/" + s.bucket + "/" + escapedKey
+	}
+	return "https://" + s.bucket + ".s3." + s.region + ".amazonaws.com/" + escapedKey
+}
+
+func (s *volumeS3Store) do(ctx context.Context, req *http.Request, payloadHash string) (*http.Response, error) {
+	creds, err := s.credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error loading aws credentials for volume backup: %w", err)
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if err := s.signer.SignHTTP(ctx, creds, req, payloadHash, "s3", s.region, time.Now(), func(o *v4.SignerOptions) {
+		// S3 signs the path as sent, without escaping it again
+		o.DisableURIPathEscaping = true
+	}); err != nil {
+		return nil, fmt.Errorf("error signing volume backup request: %w", err)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close() //nolint:errcheck
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("error accessing %s, status %d: %s", req.URL.Path, resp.StatusCode, respBody)
+	}
+	return resp, nil
+}
+
+// put uploads the file as the object key. The payload hash is computed from the file, which is
+// read again for the upload
+func (s *volumeS3Store) put(ctx context.Context, key string, file *os.File) error {
+	hasher := sha256.New()
+	size, err := io.Copy(hasher, file)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectUrl(key), io.NopCloser(file))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/x-tar")
+	resp, err := s.do(ctx, req, hex.EncodeToString(hasher.Sum(nil)))
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// get downloads the object key, the caller has to close the returned body
+func (s *volumeS3Store) get(ctx context.Context, key string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectUrl(key), nil)
+	if err != nil {
+		return nil, err
+	}
+	emptyHash := sha256.Sum256(nil)
+	resp, err := s.do(ctx, req, hex.EncodeToString(emptyHash[:]))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
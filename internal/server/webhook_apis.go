@@ -7,6 +7,7 @@ import (
 	"context"
 	"encoding/base64"
 	"fmt"
+	"net/http"
 	"net/url"
 
 	"github.com/openrundev/openrun/internal/passwd"
@@ -76,6 +77,22 @@ func (s *Server) TokenList(ctx context.Context, appPath string) (*types.TokenLis
 		})
 	}
 
+	if appEntry.Settings.WebhookTokens.Registry != "" {
+		tokens = append(tokens, types.AppToken{
+			Type:  types.WebhookRegistry,
+			Url:   fmt.Sprintf("%s%s/%s?appPath=%s", uri, types.WEBHOOK_URL_PREFIX, types.WebhookRegistry, url.QueryEscape(appPath)),
+			Token: appEntry.Settings.WebhookTokens.Registry,
+		})
+	}
+
+	if appEntry.Settings.WebhookTokens.RegistryPromote != "" {
+		tokens = append(tokens, types.AppToken{
+			Type:  types.WebhookRegistryPromote,
+			Url:   fmt.Sprintf("%s%s/%s?appPath=%s", uri, types.WEBHOOK_URL_PREFIX, types.WebhookRegistryPromote, url.QueryEscape(appPath)),
+			Token: appEntry.Settings.WebhookTokens.RegistryPromote,
+		})
+	}
+
 	ret := types.TokenListResponse{Tokens: tokens}
 	return &ret, nil
 }
@@ -119,6 +136,16 @@ func (s *Server) TokenCreate(ctx context.Context, appPath string, webhookType ty
 	case types.WebhookPromote:
 		appEntry.Settings.WebhookTokens.Promote = newToken
 		tokenUrl = fmt.Sprintf("%s%s/%s?appPath=%s", uri, types.WEBHOOK_URL_PREFIX, types.WebhookPromote, url.QueryEscape(appPath))
+	case types.WebhookRegistry, types.WebhookRegistryPromote:
+		if appImage(&appEntry.Metadata) == "" {
+			return nil, types.CreateRequestError("registry webhooks are supported only for apps using the image spec", http.StatusBadRequest)
+		}
+		if webhookType == types.WebhookRegistry {
+			appEntry.Settings.WebhookTokens.Registry = newToken
+		} else {
+			appEntry.Settings.WebhookTokens.RegistryPromote = newToken
+		}
+		tokenUrl = fmt.Sprintf("%s%s/%s?appPath=%s", uri, types.WEBHOOK_URL_PREFIX, webhookType, url.QueryEscape(appPath))
 	default:
 		return nil, fmt.Errorf("unknown webhook type %s", webhookType)
 	}
@@ -170,6 +197,10 @@ func (s *Server) TokenDelete(ctx context.Context, appPath string, webhookType ty
 		appEntry.Settings.WebhookTokens.ReloadPromote = ""
 	case types.WebhookPromote:
 		appEntry.Settings.WebhookTokens.Promote = ""
+	case types.WebhookRegistry:
+		appEntry.Settings.WebhookTokens.Registry = ""
+	case types.WebhookRegistryPromote:
+		appEntry.Settings.WebhookTokens.RegistryPromote = ""
 	default:
 		return nil, fmt.Errorf("unknown webhook type %s", webhookType)
 	}
@@ -0,0 +1,151 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package system
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const allDays = 1<<7 - 1
+
+// ActiveHours is a set of weekly time windows, like "mon-fri 09:00-17:00", during which an app
+// is available. A window ending at or before its start time ends on the next day
+type ActiveHours struct {
+	windows  []hoursWindow
+	location *time.Location
+}
+
+type hoursWindow struct {
+	days       uint64 // day of week bitmask, Sunday is 0
+	start, end int    // minutes from midnight
+}
+
+// ParseActiveHours parses the active hour windows in the timezone, the server local time is used
+// if timezone is empty. Each window is an optional day of week list or range, using the cron
+// day of week syntax, followed by a HH:MM-HH:MM time range. Without the days, the window applies
+// to every day. Returns nil if there are no windows, the app is always available then
+func ParseActiveHours(windows []string, timezone string) (*ActiveHours, error) {
+	if len(windows) == 0 {
+		return nil, nil
+	}
+	location := time.Local
+	if timezone != "" {
+		var err error
+		if location, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+	}
+
+	ret := &ActiveHours{location: location}
+	for _, spec := range windows {
+		w, err := parseHoursWindow(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid active hours %q: %w", spec, err)
+		}
+		ret.windows = append(ret.windows, w)
+	}
+	return ret, nil
+}
+
+func parseHoursWindow(spec string) (hoursWindow, error) {
+	w := hoursWindow{days: allDays}
+	fields := strings.Fields(spec)
+	var timeRange string
+	switch len(fields) {
+	case 1:
+		timeRange = fields[0]
+	case 2:
+		days, err := parseCronField(fields[0], cronDow)
+		if err != nil {
+			return w, err
+		}
+		if days&(1<<7) != 0 {
+			days |= 1 // 7 is Sunday
+		}
+		w.days = days & allDays
+		timeRange = fields[1]
+	default:
+		return w, fmt.Errorf("expected days and a time range like mon-fri 09:00-17:00")
+	}
+
+	startStr, endStr, ok := strings.Cut(timeRange, "-")
+	if !ok {
+		return w, fmt.Errorf("invalid time range %q, expected HH:MM-HH:MM", timeRange)
+	}
+	var err error
+	if w.start, err = parseClockMinutes(startStr); err != nil {
+		return w, err
+	}
+	if w.end, err = parseClockMinutes(endStr); err != nil {
+		return w, err
+	}
+	if w.start == 24*60 {
+		return w, fmt.Errorf("invalid start time %q", startStr)
+	}
+	return w, nil
+}
+
+// parseClockMinutes parses a HH:MM time, returning the minutes from midnight. 24:00 is allowed
+// for the end of the day
+func parseClockMinutes(value string) (int, error) {
+	if value == "24:00" {
+		return 24 * 60, nil
+	}
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Location returns the timezone for the windows
+func (a *ActiveHours) Location() *time.Location {
+	return a.location
+}
+
+// IsActive reports whether t falls in one of the windows
+func (a *ActiveHours) IsActive(t time.Time) bool {
+	t = t.In(a.location)
+	minutes := t.Hour()*60 + t.Minute()
+	day := uint(t.Weekday())
+	prevDay := (day + 6) % 7
+	for _, w := range a.windows {
+		if w.start < w.end {
+			if w.days&(1<<day) != 0 && minutes >= w.start && minutes < w.end {
+				return true
+			}
+			continue
+		}
+		// Overnight window, started on the previous day or starting today
+		if (w.days&(1<<day) != 0 && minutes >= w.start) || (w.days&(1<<prevDay) != 0 && minutes < w.end) {
+			return true
+		}
+	}
+	return false
+}
+
+// NextStart returns the start of the first window opening after t, in the windows timezone
+func (a *ActiveHours) NextStart(t time.Time) time.Time {
+	t = t.In(a.location)
+	var next time.Time
+	for i := range 8 {
+		day := time.Date(t.Year(), t.Month(), t.Day()+i, 0, 0, 0, 0, a.location)
+		for _, w := range a.windows {
+			if w.days&(1<<uint(day.Weekday())) == 0 {
+				continue
+			}
+			start := time.Date(day.Year(), day.Month(), day.Day(), w.start/60, w.start%60, 0, 0, a.location)
+			if start.After(t) && (next.IsZero() || start.Before(next)) {
+				next = start
+			}
+		}
+		if !next.IsZero() {
+			// Windows on the later days start after this one
+			return next
+		}
+	}
+	return next
+}
@@ -0,0 +1,82 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package system
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActiveHours(t *testing.T) {
+	hours, err := ParseActiveHours([]string{"Mon-Fri 09:00-17:00", "sat 22:00-02:00"}, "UTC")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wed := time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC) // Wednesday
+	cases := []struct {
+		name   string
+		t      time.Time
+		active bool
+		next   time.Time
+	}{
+		{"before open", wed.Add(8 * time.Hour), false, wed.Add(9 * time.Hour)},
+		{"at open", wed.Add(9 * time.Hour), true, wed.AddDate(0, 0, 1).Add(9 * time.Hour)},
+		{"at close", wed.Add(17 * time.Hour), false, wed.AddDate(0, 0, 1).Add(9 * time.Hour)},
+		{"friday evening", wed.AddDate(0, 0, 2).Add(18 * time.Hour), false, wed.AddDate(0, 0, 3).Add(22 * time.Hour)},
+		{"saturday night", wed.AddDate(0, 0, 3).Add(23 * time.Hour), true, wed.AddDate(0, 0, 5).Add(9 * time.Hour)},
+		{"after midnight", wed.AddDate(0, 0, 4).Add(time.Hour), true, wed.AddDate(0, 0, 5).Add(9 * time.Hour)},
+		{"sunday", wed.AddDate(0, 0, 4).Add(3 * time.Hour), false, wed.AddDate(0, 0, 5).Add(9 * time.Hour)},
+	}
+	for _, tc := range cases {
+		if active := hours.IsActive(tc.t); active != tc.active {
+			t.Errorf("%s: expected active %t", tc.name, tc.active)
+		}
+		if next := hours.NextStart(tc.t); !next.Equal(tc.next) {
+			t.Errorf("%s: expected next start %s, got %s", tc.name, tc.next, next)
+		}
+	}
+}
+
+func TestActiveHoursTimezone(t *testing.T) {
+	hours, err := ParseActiveHours([]string{"08:00-24:00"}, "America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 12:30 UTC is 07:30 in New York (EST)
+	if hours.IsActive(time.Date(2025, 1, 15, 12, 30, 0, 0, time.UTC)) {
+		t.Error("expected inactive before 08:00 local time")
+	}
+	if !hours.IsActive(time.Date(2025, 1, 15, 13, 30, 0, 0, time.UTC)) {
+		t.Error("expected active after 08:00 local time")
+	}
+	if !hours.IsActive(time.Date(2025, 1, 16, 4, 59, 0, 0, time.UTC)) {
+		t.Error("expected active until midnight local time")
+	}
+
+	if hours, err := ParseActiveHours(nil, ""); hours != nil || err != nil {
+		t.Errorf("expected no active hours, got %v %v", hours, err)
+	}
+}
+
+func TestActiveHoursInvalid(t *testing.T) {
+	cases := []struct {
+		windows  []string
+		timezone string
+		err      string
+	}{
+		{[]string{"09:00"}, "", "invalid time range"},
+		{[]string{"mon-fri 9am-5pm"}, "", "invalid time"},
+		{[]string{"mon-xyz 09:00-17:00"}, "", "invalid value"},
+		{[]string{"mon fri 09:00-17:00"}, "", "expected days and a time range"},
+		{[]string{"24:00-08:00"}, "", "invalid start time"},
+		{[]string{"09:00-17:00"}, "Mars/Olympus", "invalid timezone"},
+	}
+	for _, tc := range cases {
+		if _, err := ParseActiveHours(tc.windows, tc.timezone); err == nil || !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%v: expected error %q, got %v", tc.windows, tc.err, err)
+		}
+	}
+}
@@ -37,6 +37,14 @@ func TestServerConfig(t *testing.T) {
 	testutil.AssertEqualsBool(t, "telemetry traces", true, c.Telemetry.Traces)
 	testutil.AssertEqualsBool(t, "telemetry metrics", true, c.Telemetry.Metrics)
 	testutil.AssertEqualsBool(t, "telemetry plugin spans", false, c.Telemetry.PluginSpans)
+	testutil.AssertEqualsBool(t, "telemetry store spans", false, c.Telemetry.StoreSpans)
+	if c.Telemetry.SampleRatio != 1.0 {
+		t.Errorf("telemetry sample ratio: want 1.0 got %f", c.Telemetry.SampleRatio)
+	}
+	testutil.AssertEqualsString(t, "telemetry compression", "gzip", c.Telemetry.Compression)
+	testutil.AssertEqualsInt(t, "telemetry export timeout", 10, c.Telemetry.ExportTimeoutSecs)
+	testutil.AssertEqualsBool(t, "telemetry prometheus", false, c.Telemetry.Prometheus)
+	testutil.AssertEqualsString(t, "telemetry prometheus address", "127.0.0.1:25224", c.Telemetry.PrometheusAddress)
 
 	// Metadata related settings
 	testutil.AssertEqualsString(t, "db connection", "sqlite:$OPENRUN_HOME/metadata/clace_metadata.db", c.Metadata.DBConnection)
@@ -54,6 +62,9 @@ func TestServerConfig(t *testing.T) {
 	testutil.AssertEqualsBool(t, "https staging", true, c.Https.UseStaging)
 	testutil.AssertEqualsString(t, "storage", "$OPENRUN_HOME/run/certmagic", c.Https.StorageLocation)
 	testutil.AssertEqualsString(t, "cache", "$OPENRUN_HOME/config/certificates", c.Https.CertLocation)
+	testutil.AssertEqualsString(t, "tls min version", "1.2", c.TLS.MinVersion)
+	testutil.AssertEqualsBool(t, "ocsp stapling", true, c.TLS.OcspStapling)
+	testutil.AssertEqualsBool(t, "fips", false, c.TLS.Fips)
 
 	// System settings
 	testutil.AssertEqualsString(t, "tailwind command", "tailwindcss", c.System.TailwindCSSCommand)
@@ -68,7 +79,11 @@ func TestServerConfig(t *testing.T) {
 	testutil.AssertEqualsInt(t, "max concurrent builds", 1, c.System.MaxConcurrentBuilds)
 	testutil.AssertEqualsInt(t, "max build wait secs", 120, c.System.MaxBuildWaitSecs)
 	testutil.AssertEqualsBool(t, "use image pre build step", true, c.System.UseImagePreBuildStep)
+	testutil.AssertEqualsInt(t, "apply concurrency", 4, c.System.ApplyConcurrency)
+	testutil.AssertEqualsString(t, "git repo cache dir", "", c.System.GitRepoCacheDir)
+	testutil.AssertEqualsInt(t, "git repo cache mb", 0, c.System.GitRepoCacheMB)
 	testutil.AssertEqualsInt(t, "file workers", 4, c.System.FileWorkers)
+	testutil.AssertEqualsInt(t, "compression min size", 1024, c.System.CompressionMinSize)
 	testutil.AssertEqualsBool(t, "fallback unknown domains", false, c.System.FallbackUnknownDomains)
 	testutil.AssertEqualsInt(t, "forward auth timeout", 30, c.System.ForwardAuthTimeoutSecs)
 
@@ -82,10 +97,22 @@ func TestServerConfig(t *testing.T) {
 	testutil.AssertEqualsInt(t, "trusted proxies", 0, len(c.Security.TrustedProxies))
 	testutil.AssertEqualsInt(t, "allowed mounts", 1, len(c.Security.AllowedMounts))
 	testutil.AssertEqualsString(t, "allowed mount", "$OPENRUN_HOME/mounts", c.Security.AllowedMounts[0])
+	testutil.AssertEqualsInt(t, "trusted sources", 1, len(c.Security.TrustedSources))
+	testutil.AssertEqualsString(t, "trusted source", "**", c.Security.TrustedSources[0])
+	testutil.AssertEqualsInt(t, "untrusted allowed builtins", 0, len(c.Security.UntrustedAllowedBuiltins))
+	testutil.AssertEqualsBool(t, "source verification", false, c.Security.SourceVerification.Enabled)
+	testutil.AssertEqualsString(t, "cosign path", "cosign", c.Security.SourceVerification.CosignPath)
 
 	// Container Settings
 	testutil.AssertEqualsString(t, "command", "auto", c.System.ContainerCommand)
+	testutil.AssertEqualsString(t, "container driver", "cli", c.System.ContainerDriver)
+	testutil.AssertEqualsString(t, "container host", "", c.System.ContainerHost)
 	testutil.AssertEqualsInt(t, "stale container cleanup interval", 5, c.System.StaleContainerCleanupIntervalMins)
+	testutil.AssertEqualsString(t, "app archive dir", "$OPENRUN_HOME/archive", c.System.AppArchiveDir)
+	testutil.AssertEqualsString(t, "volume backup dir", "$OPENRUN_HOME/backups/volumes", c.System.VolumeBackupDir)
+	testutil.AssertEqualsString(t, "volume helper image", "busybox:latest", c.System.VolumeHelperImage)
+	testutil.AssertEqualsString(t, "app cpus quota", "", c.ContainerQuota.AppCpus)
+	testutil.AssertEqualsString(t, "total memory quota", "", c.ContainerQuota.TotalMemory)
 
 	// App CORS default Settings
 	testutil.AssertEqualsString(t, "cors origin", "", c.AppConfig.CORS.AllowOrigin)
@@ -100,16 +127,45 @@ func TestServerConfig(t *testing.T) {
 	testutil.AssertEqualsInt(t, "timeout", 5, c.AppConfig.Container.HealthTimeoutSecs)
 	testutil.AssertEqualsInt(t, "deploy probe period", 1, c.AppConfig.Container.DeployProbePeriodSecs)
 	testutil.AssertEqualsInt(t, "deploy health attempts", 75, c.AppConfig.Container.DeployHealthAttempts)
+	testutil.AssertEqualsInt(t, "health interval", 0, c.AppConfig.Container.HealthIntervalSecs)
+	testutil.AssertEqualsInt(t, "readiness wait", 10, c.AppConfig.Container.ReadinessWaitSecs)
+	testutil.AssertEqualsInt(t, "schedule active hours", 0, len(c.AppConfig.Schedule.ActiveHours))
+	testutil.AssertEqualsInt(t, "schedule prestart", 5, c.AppConfig.Schedule.PrestartMins)
+	testutil.AssertEqualsBool(t, "minify html", false, c.AppConfig.Minify.HTML)
+	testutil.AssertEqualsBool(t, "minify js", true, c.AppConfig.Minify.JS)
+	testutil.AssertEqualsInt(t, "minify cache", 100, c.AppConfig.Minify.CacheEntries)
+	testutil.AssertEqualsInt(t, "image widths", 0, len(c.AppConfig.Image.Widths))
+	testutil.AssertEqualsInt(t, "image jpeg quality", 85, c.AppConfig.Image.JpegQuality)
+	testutil.AssertEqualsBool(t, "image webp", false, c.AppConfig.Image.WebP)
+	testutil.AssertEqualsInt(t, "sync history entries", 100, c.System.SyncHistoryEntries)
+	testutil.AssertEqualsInt(t, "pr preview ttl", 168, c.System.PrPreviewTTLHours)
+	testutil.AssertEqualsString(t, "pr preview auth", "", c.System.PrPreviewAuth)
+	testutil.AssertEqualsInt(t, "audit checkpoint mins", 60, c.System.AuditCheckpointMins)
+	testutil.AssertEqualsString(t, "image webp command", "cwebp -quiet -q 80 {input} -o {output}", c.System.ImageWebPCommand)
 	testutil.AssertEqualsInt(t, "deploy progress deadline", 0, c.AppConfig.Container.DeployProgressDeadlineSecs)
 	testutil.AssertEqualsInt(t, "idle", 180, c.AppConfig.Container.IdleShutdownSecs)
 	testutil.AssertEqualsInt(t, "idle bytes high watermark", 1500, c.AppConfig.Container.IdleBytesHighWatermark)
+	testutil.AssertEqualsInt(t, "wakeup wait", 60, c.AppConfig.Container.WakeupWaitSecs)
+	testutil.AssertEqualsBool(t, "lazy start", true, c.AppConfig.Container.LazyStart)
+	testutil.AssertEqualsString(t, "cpus", "", c.AppConfig.Container.Cpus)
+	testutil.AssertEqualsInt(t, "pids limit", 0, c.AppConfig.Container.PidsLimit)
 	testutil.AssertEqualsInt(t, "status interval", 20, c.AppConfig.Container.StatusCheckIntervalSecs)
 	testutil.AssertEqualsInt(t, "status attempts", 10, c.AppConfig.Container.StatusHealthAttempts)
 
 	testutil.AssertEqualsInt(t, "proxy max idle", 250, c.AppConfig.Proxy.MaxIdleConns)
 	testutil.AssertEqualsInt(t, "proxy idle timeout", 15, c.AppConfig.Proxy.IdleConnTimeoutSecs)
 	testutil.AssertEqualsBool(t, "proxy disable compression", true, c.AppConfig.Proxy.DisableCompression)
+	testutil.AssertEqualsBool(t, "proxy rewrite cookies", true, c.AppConfig.Proxy.RewriteCookies)
+	testutil.AssertEqualsInt(t, "proxy scrub headers", 2, len(c.AppConfig.Proxy.ScrubHeaders))
+	testutil.AssertEqualsBool(t, "proxy rewrite html", false, c.AppConfig.Proxy.RewriteHTML)
+	testutil.AssertEqualsInt(t, "proxy rewrite html attrs", 14, len(c.AppConfig.Proxy.RewriteHTMLAttrs))
+	testutil.AssertEqualsInt(t, "proxy max response bytes", 0, int(c.AppConfig.Proxy.MaxResponseBytes))
+	testutil.AssertEqualsInt(t, "proxy max response secs", 0, c.AppConfig.Proxy.MaxResponseSecs)
 	testutil.AssertEqualsString(t, "secrets provider", "env", c.AppConfig.Security.DefaultSecretsProvider)
+	testutil.AssertEqualsString(t, "security profile", "", c.AppConfig.Security.Profile)
+	testutil.AssertEqualsInt(t, "denied plugins", 0, len(c.AppConfig.Security.DeniedPlugins))
+	testutil.AssertEqualsInt(t, "max request body", 0, int(c.AppConfig.Security.MaxRequestBodyBytes))
+	testutil.AssertEqualsInt(t, "rate limit", 0, c.AppConfig.RateLimit.RequestsPerMin)
 	testutil.AssertEqualsInt(t, "default permissions", 3, len(c.Permissions.Allow))
 	testutil.AssertEqualsInt(t, "default container secrets", 0, len(c.Permissions.Allow[1].Secrets))
 	testutil.AssertEqualsString(t, "default http plugin", "http.in", c.Permissions.Allow[2].Plugin)
@@ -123,8 +179,13 @@ func TestServerConfig(t *testing.T) {
 	testutil.AssertEqualsString(t, "kaniko image", "ghcr.io/kaniko-build/dist/chainguard-dev-kaniko/executor:v1.25.3-slim", c.Builder.KanikoImage)
 	testutil.AssertEqualsBool(t, "kaniko cache", true, c.Builder.KanikoCache)
 	testutil.AssertEqualsString(t, "kaniko cache repo", "", c.Builder.KanikoCacheRepo)
+	testutil.AssertEqualsBool(t, "buildkit", false, c.Builder.Buildkit)
+	testutil.AssertEqualsBool(t, "cache from", true, c.Builder.CacheFrom)
+	testutil.AssertEqualsInt(t, "keep versions", 3, c.Builder.KeepVersions)
 	testutil.AssertEqualsString(t, "list apps title", "OpenRun Apps", c.System.ListAppsTitle)
 	testutil.AssertEqualsBool(t, "show hosted with", true, c.System.ShowHostedWith)
+	testutil.AssertEqualsString(t, "language", "en", c.System.Language)
+	testutil.AssertEqualsBool(t, "language from request", true, c.System.LanguageFromRequest)
 }
 
 func TestClientConfig(t *testing.T) {
@@ -137,6 +198,7 @@ func TestClientConfig(t *testing.T) {
 	testutil.AssertEqualsString(t, "server uri", "$OPENRUN_HOME/run/openrun.sock", c.ServerUri)
 	testutil.AssertEqualsString(t, "admin user", "admin", c.AdminUser)
 	testutil.AssertEqualsString(t, "default format", "basic", c.Client.DefaultFormat)
+	testutil.AssertEqualsString(t, "language", "", c.Client.Language)
 }
 
 func TestServerConfigTailwindVersionValidation(t *testing.T) {
@@ -0,0 +1,82 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package system
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// EXEC_PROTOCOL is the Upgrade protocol for the app exec API. After the switch, both sides send
+// frames with a one byte stream id, a four byte big endian length and the data
+const EXEC_PROTOCOL = "openrun-exec"
+
+const (
+	ExecStreamStdin  byte = 0 // client to server, an empty frame closes the stdin
+	ExecStreamStdout byte = 1
+	ExecStreamStderr byte = 2
+	ExecStreamExit   byte = 3 // the last frame from the server, the JSON encoded types.AppExecResult
+)
+
+// execMaxFrameSize is the max data size of a frame, larger writes are split into multiple frames
+const execMaxFrameSize = 64 * 1024
+
+// WriteExecFrame writes one frame for the stream
+func WriteExecFrame(w io.Writer, stream byte, data []byte) error {
+	if len(data) > execMaxFrameSize {
+		return fmt.Errorf("exec frame size %d is above the limit of %d", len(data), execMaxFrameSize)
+	}
+	header := make([]byte, 5, 5+len(data))
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[1:], uint32(len(data)))
+	_, err := w.Write(append(header, data...))
+	return err
+}
+
+// ReadExecFrame reads the next frame, returning the stream id and the data
+func ReadExecFrame(r io.Reader) (byte, []byte, error) {
+	header := make([]byte, 5)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > execMaxFrameSize {
+		return 0, nil, fmt.Errorf("exec frame size %d is above the limit of %d", size, execMaxFrameSize)
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return 0, nil, err
+	}
+	return header[0], data, nil
+}
+
+// ExecFrameWriter is an io.Writer which writes the data as frames for one stream. The writers
+// for the streams of a connection share the mutex, so that the frames are not interleaved
+type ExecFrameWriter struct {
+	mu     *sync.Mutex
+	w      io.Writer
+	stream byte
+}
+
+// NewExecFrameWriter returns a frame writer for the stream, mu has to be shared by the writers
+// for the same connection
+func NewExecFrameWriter(mu *sync.Mutex, w io.Writer, stream byte) *ExecFrameWriter {
+	return &ExecFrameWriter{mu: mu, w: w, stream: stream}
+}
+
+func (e *ExecFrameWriter) Write(p []byte) (int, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	written := 0
+	for written < len(p) {
+		chunk := p[written:min(len(p), written+execMaxFrameSize)]
+		if err := WriteExecFrame(e.w, e.stream, chunk); err != nil {
+			return written, err
+		}
+		written += len(chunk)
+	}
+	return written, nil
+}
@@ -0,0 +1,48 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package system
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func TestExecFrames(t *testing.T) {
+	var buf bytes.Buffer
+	var mu sync.Mutex
+	stdout := NewExecFrameWriter(&mu, &buf, ExecStreamStdout)
+	stderr := NewExecFrameWriter(&mu, &buf, ExecStreamStderr)
+
+	large := strings.Repeat("x", execMaxFrameSize+10)
+	n, err := stdout.Write([]byte(large))
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "written", len(large), n)
+	_, err = stderr.Write([]byte("error"))
+	testutil.AssertNoError(t, err)
+	testutil.AssertNoError(t, WriteExecFrame(&buf, ExecStreamStdin, nil))
+
+	// The large write is split into two frames
+	stream, data, err := ReadExecFrame(&buf)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "stream", int(ExecStreamStdout), int(stream))
+	testutil.AssertEqualsInt(t, "first frame", execMaxFrameSize, len(data))
+	_, data, err = ReadExecFrame(&buf)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "second frame", 10, len(data))
+	stream, data, err = ReadExecFrame(&buf)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "stderr", int(ExecStreamStderr), int(stream))
+	testutil.AssertEqualsString(t, "stderr data", "error", string(data))
+	stream, data, err = ReadExecFrame(&buf)
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "stdin", int(ExecStreamStdin), int(stream))
+	testutil.AssertEqualsInt(t, "stdin close", 0, len(data))
+
+	_, _, err = ReadExecFrame(bytes.NewReader([]byte{1, 0xff, 0xff, 0xff, 0xff}))
+	testutil.AssertErrorContains(t, err, "above the limit")
+}
@@ -4,6 +4,7 @@
 package system
 
 import (
+	"bufio"
 	"bytes"
 	"crypto/tls"
 	"encoding/json"
@@ -107,6 +108,96 @@ func (h *HttpClient) Delete(url string, params url.Values, output any) error {
 	return h.request(http.MethodDelete, url, params, nil, output)
 }
 
+// GetStream calls a streaming API which returns newline delimited JSON. The handler is called
+// for each line, until the response ends or the handler returns an error. The client timeout
+// is not applied, a followed stream can stay open until the user stops it
+func (h *HttpClient) GetStream(apiPath string, params url.Values, handler func(line []byte) error) error {
+	u, err := url.Parse(h.serverUri)
+	if err != nil {
+		return err
+	}
+	u.Path = path.Join(u.Path, apiPath)
+	if params != nil {
+		u.RawQuery = params.Encode()
+	}
+	request, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	request.SetBasicAuth(h.user, h.password)
+	request.Header.Set("Accept", ApplicationJson)
+	for name, value := range h.headers {
+		request.Header.Set(name, value)
+	}
+
+	streamClient := *h.client
+	streamClient.Timeout = 0
+	resp, err := streamClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return responseError(resp)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		if err := handler(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Upgrade calls an API which switches the connection to the protocol, returning the connection
+// for the bidirectional stream. The client timeout is not applied. The caller has to close the
+// returned connection
+func (h *HttpClient) Upgrade(apiPath string, params url.Values, protocol string) (io.ReadWriteCloser, error) {
+	u, err := url.Parse(h.serverUri)
+	if err != nil {
+		return nil, err
+	}
+	u.Path = path.Join(u.Path, apiPath)
+	if params != nil {
+		u.RawQuery = params.Encode()
+	}
+	request, err := http.NewRequest(http.MethodPost, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+	request.SetBasicAuth(h.user, h.password)
+	request.Header.Set("Connection", "Upgrade")
+	request.Header.Set("Upgrade", protocol)
+	for name, value := range h.headers {
+		request.Header.Set(name, value)
+	}
+
+	upgradeClient := *h.client
+	upgradeClient.Timeout = 0
+	resp, err := upgradeClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		defer resp.Body.Close() //nolint:errcheck
+		if resp.StatusCode >= http.StatusOK && resp.StatusCode < http.StatusMultipleChoices {
+			return nil, fmt.Errorf("server did not switch to protocol %s", protocol)
+		}
+		return nil, responseError(resp)
+	}
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close() //nolint:errcheck
+		return nil, fmt.Errorf("upgraded response body is not writable")
+	}
+	return conn, nil
+}
+
 func (h *HttpClient) request(method, apiPath string, params url.Values, input any, output any) error {
 	var resp *http.Response
 	var payloadBuf bytes.Buffer
@@ -147,17 +238,7 @@ func (h *HttpClient) request(method, apiPath string, params url.Values, input an
 	}
 	defer resp.Body.Close() //nolint:errcheck
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		errBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return err
-		}
-		var errResp types.RequestError
-		parseErr := json.Unmarshal(errBody, &errResp)
-		if parseErr != nil || errResp.Code == 0 {
-			errResp.Code = resp.StatusCode
-			errResp.Message = string(errBody)
-		}
-		return errResp
+		return responseError(resp)
 	}
 
 	if resp.StatusCode == http.StatusNoContent {
@@ -176,6 +257,22 @@ func (h *HttpClient) request(method, apiPath string, params url.Values, input an
 	return nil
 }
 
+// responseError returns the error for a failed API response. The body is the JSON encoded
+// request error if available, else the body text is used as the message
+func responseError(resp *http.Response) error {
+	errBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var errResp types.RequestError
+	parseErr := json.Unmarshal(errBody, &errResp)
+	if parseErr != nil || errResp.Code == 0 {
+		errResp.Code = resp.StatusCode
+		errResp.Message = string(errBody)
+	}
+	return errResp
+}
+
 func MapServerHost(host string) string {
 	if host == "0.0.0.0" {
 		return ""
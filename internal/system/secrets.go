@@ -11,18 +11,13 @@ import (
 	"fmt"
 	"os"
 	"regexp"
-	"sort"
+	"slices"
 	"strings"
 	"text/template"
 	"text/template/parse"
 	"unicode/utf8"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
-	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/bmatcuk/doublestar/v4"
-	"github.com/hashicorp/vault/api"
 	"github.com/openrundev/openrun/internal/passwd"
 	"github.com/openrundev/openrun/internal/types"
 	meta "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -116,6 +111,7 @@ func (s *SecretManager) appTemplateSecretFunc(checkAppPerms bool, appPerms [][]s
 	if !ok {
 		panic(fmt.Errorf("unknown secret provider %s", providerName))
 	}
+	secretKeys, version := splitSecretVersion(secretKeys)
 
 	if checkAppPerms {
 		if len(appPerms) == 0 {
@@ -167,13 +163,45 @@ func (s *SecretManager) appTemplateSecretFunc(checkAppPerms bool, appPerms [][]s
 		secretKey = fmt.Sprintf(printfStr, args...)
 	}
 
-	ret, err := provider.GetSecret(context.Background(), secretKey)
+	var ret string
+	var err error
+	if version == "" || version == secretVersionLatest {
+		ret, err = provider.GetSecret(context.Background(), secretKey)
+	} else {
+		versioned, ok := provider.(versionedSecretProvider)
+		if !ok {
+			panic(fmt.Errorf("secret provider %s does not support secret versions", providerName))
+		}
+		ret, err = versioned.GetSecretVersion(context.Background(), secretKey, version)
+	}
 	if err != nil {
 		panic(fmt.Errorf("error getting secret %s from %s: %w", secretKey, providerName, err))
 	}
 	return ret
 }
 
+const secretVersionLatest = "latest"
+
+// secretVersionRegex matches the version suffixes supported in secret references: latest,
+// a version number, a stage label (like AWSPREVIOUS) or a version id (uuid)
+var secretVersionRegex = regexp.MustCompile(`^(latest|[0-9]+|[A-Z][A-Z0-9_]*|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12})$`)
+
+// splitSecretVersion removes the version from the secret keys. The version is set as a name@version
+// suffix on the last key. A suffix which is not a valid version is left as part of the name
+func splitSecretVersion(secretKeys []string) ([]string, string) {
+	if len(secretKeys) == 0 {
+		return secretKeys, ""
+	}
+	last := secretKeys[len(secretKeys)-1]
+	index := strings.LastIndex(last, "@")
+	if index <= 0 || !secretVersionRegex.MatchString(last[index+1:]) {
+		return secretKeys, ""
+	}
+	keys := slices.Clone(secretKeys)
+	keys[len(keys)-1] = last[:index]
+	return keys, last[index+1:]
+}
+
 // EvalTemplate evaluates the input string and replaces any secret placeholders with the actual secret value
 func (s *SecretManager) EvalTemplate(input string) (string, error) {
 	if len(input) < 4 {
@@ -246,68 +274,116 @@ func (s *SecretManager) AppEvalTemplate(appSecrets [][]string, defaultProvider,
 // template calls the secret or secret_from function (in any position,
 // including nested pipelines and branch bodies)
 func templateReferencesSecrets(t *template.Template) bool {
+	return len(templateSecretCalls(t)) > 0
+}
+
+// templateSecretCalls returns the secret and secret_from calls in the parsed
+// template. Each call has the function name followed by the string literal
+// arguments, other arguments are not included
+func templateSecretCalls(t *template.Template) [][]string {
+	calls := [][]string{}
 	for _, tm := range t.Templates() {
 		if tm.Tree == nil || tm.Root == nil {
 			continue
 		}
-		if nodeReferencesSecrets(tm.Root) {
-			return true
-		}
+		nodeSecretCalls(tm.Root, &calls)
 	}
-	return false
+	return calls
 }
 
-func nodeReferencesSecrets(node parse.Node) bool {
+func nodeSecretCalls(node parse.Node, calls *[][]string) {
 	switch n := node.(type) {
 	case *parse.ListNode:
 		if n == nil {
-			return false
+			return
 		}
 		for _, item := range n.Nodes {
-			if nodeReferencesSecrets(item) {
-				return true
-			}
+			nodeSecretCalls(item, calls)
 		}
 	case *parse.ActionNode:
-		return pipeReferencesSecrets(n.Pipe)
+		pipeSecretCalls(n.Pipe, calls)
 	case *parse.IfNode:
-		return branchReferencesSecrets(&n.BranchNode)
+		branchSecretCalls(&n.BranchNode, calls)
 	case *parse.RangeNode:
-		return branchReferencesSecrets(&n.BranchNode)
+		branchSecretCalls(&n.BranchNode, calls)
 	case *parse.WithNode:
-		return branchReferencesSecrets(&n.BranchNode)
+		branchSecretCalls(&n.BranchNode, calls)
 	case *parse.TemplateNode:
-		return pipeReferencesSecrets(n.Pipe)
+		pipeSecretCalls(n.Pipe, calls)
 	}
-	return false
 }
 
-func branchReferencesSecrets(n *parse.BranchNode) bool {
-	if pipeReferencesSecrets(n.Pipe) || nodeReferencesSecrets(n.List) {
-		return true
+func branchSecretCalls(n *parse.BranchNode, calls *[][]string) {
+	pipeSecretCalls(n.Pipe, calls)
+	nodeSecretCalls(n.List, calls)
+	if n.ElseList != nil {
+		nodeSecretCalls(n.ElseList, calls)
 	}
-	return n.ElseList != nil && nodeReferencesSecrets(n.ElseList)
 }
 
-func pipeReferencesSecrets(pipe *parse.PipeNode) bool {
+func pipeSecretCalls(pipe *parse.PipeNode, calls *[][]string) {
 	if pipe == nil {
-		return false
+		return
 	}
 	for _, cmd := range pipe.Cmds {
 		for _, arg := range cmd.Args {
 			switch a := arg.(type) {
 			case *parse.IdentifierNode:
 				if a.Ident == "secret" || a.Ident == "secret_from" {
-					return true
+					call := []string{a.Ident}
+					for _, callArg := range cmd.Args {
+						if str, ok := callArg.(*parse.StringNode); ok {
+							call = append(call, str.Text)
+						}
+					}
+					*calls = append(*calls, call)
 				}
 			case *parse.PipeNode:
-				if pipeReferencesSecrets(a) {
-					return true
-				}
+				pipeSecretCalls(a, calls)
 			}
 		}
 	}
-	return false
+}
+
+// SecretRefs returns the secrets referenced by the secret and secret_from
+// calls in the input, as provider/key. The key is the secret keys joined
+// like for the lookup, keys_printf is not applied. Calls without string
+// literal arguments are skipped. The input is not evaluated
+func (s *SecretManager) SecretRefs(defaultProvider, input string) []string {
+	if !strings.Contains(input, "{{") || !strings.Contains(input, "}}") {
+		return nil
+	}
+	funcMap := GetFuncMap()
+	funcMap["secret"] = func(...string) string { return "" }
+	funcMap["secret_from"] = funcMap["secret"]
+	tmpl, err := template.New("secret template").Funcs(funcMap).Parse(input)
+	if err != nil {
+		return nil
+	}
+
+	var refs []string
+	for _, call := range templateSecretCalls(tmpl) {
+		providerName, keys := "", call[1:]
+		if call[0] == "secret_from" {
+			if len(keys) == 0 {
+				continue
+			}
+			providerName, keys = keys[0], keys[1:]
+		}
+		if providerName == "" || strings.ToLower(providerName) == "default" {
+			providerName = cmp.Or(defaultProvider, s.defaultProvider)
+		}
+		if len(keys) == 0 {
+			continue
+		}
+		keys, _ = splitSecretVersion(keys)
+		delimiter := ""
+		if provider, ok := s.providers[providerName]; ok {
+			delimiter = provider.GetJoinDelimiter()
+		}
+		refs = append(refs, providerName+"/"+strings.Join(keys, delimiter))
+	}
+	return refs
 }
 
 // secretProvider is an interface for secret providers
@@ -322,6 +398,13 @@ type secretProvider interface {
 	GetJoinDelimiter() string
 }
 
+// versionedSecretProvider is implemented by secret providers which support reading a specific
+// version of a secret, referenced as name@version. The latest version is read using GetSecret
+type versionedSecretProvider interface {
+	secretProvider
+	GetSecretVersion(ctx context.Context, secretName, version string) (string, error)
+}
+
 // writableSecretProvider is implemented by secret providers which support
 // storing secrets (currently the db provider)
 type writableSecretProvider interface {
@@ -577,111 +660,6 @@ func (s *SecretManager) RekeySecrets(ctx context.Context, providerName string) (
 	return &types.SecretRekeyResponse{Rekeyed: rekeyed, Skipped: skipped}, nil
 }
 
-// awsSecretProvider is a secret provider that reads secrets from AWS Secrets Manager
-type awsSecretProvider struct {
-	client *secretsmanager.Client
-}
-
-func (a *awsSecretProvider) Configure(ctx context.Context, conf map[string]any) error {
-	profileStr := ""
-	profile, ok := conf["profile"]
-	if ok {
-		profileStr, ok = profile.(string)
-		if !ok {
-			return fmt.Errorf("profile must be a string")
-		}
-	}
-
-	var cfg aws.Config
-	var err error
-	// IAM is automatically supported by config load
-	if profileStr != "" {
-		cfg, err = config.LoadDefaultConfig(ctx, config.WithSharedConfigProfile(profileStr))
-	} else {
-		cfg, err = config.LoadDefaultConfig(ctx)
-	}
-
-	if err != nil {
-		return err
-	}
-
-	a.client = secretsmanager.NewFromConfig(cfg)
-	return nil
-}
-
-func (a *awsSecretProvider) GetSecret(ctx context.Context, secretName string) (string, error) {
-	input := &secretsmanager.GetSecretValueInput{
-		SecretId: aws.String(secretName),
-	}
-	result, err := a.client.GetSecretValue(ctx, input)
-	if err != nil {
-		return "", err
-	}
-	return aws.ToString(result.SecretString), nil
-}
-
-func (a *awsSecretProvider) GetJoinDelimiter() string {
-	return "/"
-}
-
-var _ secretProvider = &awsSecretProvider{}
-
-// awsSSMProvider is a secret provider that reads secrets from AWS SSM
-type awsSSMProvider struct {
-	client *ssm.Client
-}
-
-func (a *awsSSMProvider) Configure(ctx context.Context, conf map[string]any) error {
-	profileStr := ""
-	profile, ok := conf["profile"]
-	if ok {
-		profileStr, ok = profile.(string)
-		if !ok {
-			return fmt.Errorf("profile must be a string")
-		}
-	}
-
-	var cfg aws.Config
-	var err error
-	// IAM is automatically supported by config load
-	if profileStr != "" {
-		cfg, err = config.LoadDefaultConfig(ctx, config.WithSharedConfigProfile(profileStr))
-	} else {
-		cfg, err = config.LoadDefaultConfig(ctx)
-	}
-
-	if err != nil {
-		return err
-	}
-
-	a.client = ssm.NewFromConfig(cfg)
-	return nil
-}
-
-func (a *awsSSMProvider) GetSecret(ctx context.Context, secretName string) (string, error) {
-	input := &ssm.GetParameterInput{
-		Name:           aws.String(secretName),
-		WithDecryption: aws.Bool(true),
-	}
-
-	out, err := a.client.GetParameter(ctx, input)
-	if err != nil {
-		return "", err
-	}
-	return aws.ToString(out.Parameter.Value), nil
-}
-
-func (a *awsSSMProvider) GetJoinDelimiter() string {
-	return "/"
-}
-
-var _ secretProvider = &awsSSMProvider{}
-
-// vaultSecretProvider is a secret provider that reads secrets from HashiCorp Vault
-type vaultSecretProvider struct {
-	client *api.Client
-}
-
 func getConfigString(conf map[string]any, key string) (string, error) {
 	value, ok := conf[key]
 	if !ok {
@@ -696,132 +674,19 @@ func getConfigString(conf map[string]any, key string) (string, error) {
 	return valueStr, nil
 }
 
-func (v *vaultSecretProvider) Configure(ctx context.Context, conf map[string]any) error {
-	address, err := getConfigString(conf, "address")
-	if err != nil {
-		return fmt.Errorf("vault invalid config: %w", err)
-	}
-	token, err := getConfigString(conf, "token")
-	if err != nil {
-		return fmt.Errorf("vault invalid config: %w", err)
-	}
-
-	vaultConfig := &api.Config{
-		Address: address,
-	}
-
-	client, err := api.NewClient(vaultConfig)
-	if err != nil {
-		return err
-	}
-
-	// Set the token for authentication
-	client.SetToken(token)
-	v.client = client
-	return nil
-}
-
-// GetSecret reads the secret at the given path and returns the one string value it contains.
-// It handles both KV v1 and v2 engines automatically.
-func (v *vaultSecretProvider) GetSecret(ctx context.Context, fullPath string) (string, error) {
-	// 1) List all mounts so we can detect KV versions.
-	mounts, err := v.client.Sys().ListMountsWithContext(ctx)
-	if err != nil {
-		return "", fmt.Errorf("failed to list mounts: %w", err)
-	}
-
-	// 2) Pick the longest‐matching mount for our path.
-	//    Mount keys come back with trailing slashes, e.g. "secret/" or "kv/".
-	type mountInfo struct {
-		mountPath string           // e.g. "secret/" (with slash)
-		opts      *api.MountOutput // contains .Options["version"]
-	}
-	var all []mountInfo
-	for m, o := range mounts {
-		all = append(all, mountInfo{mountPath: m, opts: o})
-	}
-	sort.Slice(all, func(i, j int) bool {
-		// longer mountPath first (“secret/data/” before “secret/”)
-		return len(all[i].mountPath) > len(all[j].mountPath)
-	})
-
-	var (
-		chosen  *mountInfo
-		relPath string
-	)
-	for _, mi := range all {
-		// trim the trailing slash for comparison
-		prefix := strings.TrimSuffix(mi.mountPath, "/")
-		if fullPath == prefix || strings.HasPrefix(fullPath, prefix+"/") {
-			chosen = &mi
-			// everything after “prefix/”
-			relPath = strings.TrimPrefix(fullPath, prefix+"/")
-			break
-		}
-	}
-	if chosen == nil {
-		return "", fmt.Errorf("no mount found matching path %q", fullPath)
-	}
-
-	// 3) Decide API version (default to v1 if not set).
-	ver := 1
-	if v, ok := chosen.opts.Options["version"]; ok && v == "2" {
-		ver = 2
-	}
-
-	// 4) Build the actual read path for the logical API.
-	//    KV v2 lives under “<mount>/data/<relPath>”
-	mountPrefix := strings.TrimSuffix(chosen.mountPath, "/")
-	var readPath string
-	if ver == 2 {
-		readPath = fmt.Sprintf("%s/data/%s", mountPrefix, relPath)
-	} else {
-		readPath = fmt.Sprintf("%s/%s", mountPrefix, relPath)
-	}
-
-	// 5) Read the secret
-	secret, err := v.client.Logical().ReadWithContext(ctx, readPath)
-	if err != nil {
-		return "", fmt.Errorf("error reading %s: %w", readPath, err)
-	}
-	if secret == nil {
-		return "", fmt.Errorf("no secret found at %s", readPath)
-	}
-
-	// 6) Extract the data map
-	var data map[string]interface{}
-	if ver == 2 {
-		// KV v2 nests values under “data”
-		raw, ok := secret.Data["data"].(map[string]interface{})
-		if !ok {
-			return "", fmt.Errorf("malformed data at %s", readPath)
-		}
-		data = raw
-	} else {
-		// KV v1 writes your keys at top level of Data
-		data = secret.Data
-	}
-
-	if len(data) != 1 {
-		return "", fmt.Errorf("expected exactly one key in secret at %s, got %d keys", readPath, len(data))
+// getConfigOptString returns the optional string config value, empty if not set
+func getConfigOptString(conf map[string]any, key string) (string, error) {
+	value, ok := conf[key]
+	if !ok {
+		return "", nil
 	}
-	for _, v := range data {
-		str, ok := v.(string)
-		if !ok {
-			return "", fmt.Errorf("secret value at %s is not a string", readPath)
-		}
-		return str, nil
+	valueStr, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("'%s' must be a string", key)
 	}
-
-	return "", fmt.Errorf("unexpected error extracting secret at %s", readPath)
-}
-
-func (v *vaultSecretProvider) GetJoinDelimiter() string {
-	return "/"
+	return valueStr, nil
 }
 
-var _ secretProvider = &vaultSecretProvider{}
-
 // envSecretProvider is a secret provider that reads secrets from environment variables
 type envSecretProvider struct {
 }
@@ -0,0 +1,235 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package system
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+const awsDefaultSessionName = "openrun"
+
+var awsVersionIdRegex = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// awsConfigOptions returns the config load options for the profile and region set in the
+// provider config
+func awsConfigOptions(conf map[string]any) ([]func(*config.LoadOptions) error, error) {
+	profile, err := getConfigOptString(conf, "profile")
+	if err != nil {
+		return nil, err
+	}
+	region, err := getConfigOptString(conf, "region")
+	if err != nil {
+		return nil, err
+	}
+
+	options := []func(*config.LoadOptions) error{}
+	if profile != "" {
+		options = append(options, config.WithSharedConfigProfile(profile))
+	}
+	if region != "" {
+		options = append(options, config.WithRegion(region))
+	}
+	return options, nil
+}
+
+// awsAssumeRoleOptions returns the role to assume and the options for the assume role call, from
+// the provider config. The role arn is empty if no role is configured
+func awsAssumeRoleOptions(conf map[string]any) (string, func(*stscreds.AssumeRoleOptions), error) {
+	roleArn, err := getConfigOptString(conf, "role_arn")
+	if err != nil {
+		return "", nil, err
+	}
+	externalId, err := getConfigOptString(conf, "external_id")
+	if err != nil {
+		return "", nil, err
+	}
+	sessionName, err := getConfigOptString(conf, "session_name")
+	if err != nil {
+		return "", nil, err
+	}
+	if roleArn == "" {
+		if externalId != "" || sessionName != "" {
+			return "", nil, fmt.Errorf("'external_id' and 'session_name' require 'role_arn' to be set")
+		}
+		return "", nil, nil
+	}
+	if sessionName == "" {
+		sessionName = awsDefaultSessionName
+	}
+
+	return roleArn, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = sessionName
+		if externalId != "" {
+			o.ExternalID = aws.String(externalId)
+		}
+	}, nil
+}
+
+// loadAWSConfig loads the AWS config for a secret provider. The credentials are read from the
+// default chain (env, shared config files, IAM role for the instance/task) using the optional
+// profile. If role_arn is set, that role is assumed using those credentials
+func loadAWSConfig(ctx context.Context, conf map[string]any) (aws.Config, error) {
+	options, err := awsConfigOptions(conf)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	roleArn, roleOptions, err := awsAssumeRoleOptions(conf)
+	if err != nil {
+		return aws.Config{}, err
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return aws.Config{}, err
+	}
+	if roleArn != "" {
+		// The assumed role credentials are cached and refreshed before they expire
+		provider := stscreds.NewAssumeRoleProvider(sts.NewFromConfig(cfg), roleArn, roleOptions)
+		cfg.Credentials = aws.NewCredentialsCache(provider)
+	}
+	return cfg, nil
+}
+
+// awsSecretKeyValue returns the value for the key from a secret value which is a JSON object.
+// String values are returned as is, other values are returned in JSON format
+func awsSecretKeyValue(value, key, secretName string) (string, error) {
+	decoder := json.NewDecoder(strings.NewReader(value))
+	decoder.UseNumber()
+	var data map[string]any
+	if err := decoder.Decode(&data); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object, cannot read key %s", secretName, key)
+	}
+
+	keyValue, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("key %s not found in secret %s", key, secretName)
+	}
+	if str, ok := keyValue.(string); ok {
+		return str, nil
+	}
+	buf := bytes.Buffer{}
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(keyValue); err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// awsSecretProvider is a secret provider that reads secrets from AWS Secrets Manager
+type awsSecretProvider struct {
+	client *secretsmanager.Client
+}
+
+func (a *awsSecretProvider) Configure(ctx context.Context, conf map[string]any) error {
+	cfg, err := loadAWSConfig(ctx, conf)
+	if err != nil {
+		return fmt.Errorf("asm invalid config: %w", err)
+	}
+
+	a.client = secretsmanager.NewFromConfig(cfg)
+	return nil
+}
+
+// GetSecret returns the secret value. If the name is in name#key format, the secret value
+// is parsed as a JSON object and the value for the key is returned
+func (a *awsSecretProvider) GetSecret(ctx context.Context, fullName string) (string, error) {
+	return a.GetSecretVersion(ctx, fullName, "")
+}
+
+// GetSecretVersion returns the value for a version of the secret. A version id (uuid) is
+// used as the VersionId, other versions are used as the staging label (like AWSPREVIOUS)
+func (a *awsSecretProvider) GetSecretVersion(ctx context.Context, fullName, version string) (string, error) {
+	secretName, key, _ := strings.Cut(fullName, "#")
+	input := &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretName),
+	}
+	if awsVersionIdRegex.MatchString(version) {
+		input.VersionId = aws.String(version)
+	} else if version != "" {
+		input.VersionStage = aws.String(version)
+	}
+	result, err := a.client.GetSecretValue(ctx, input)
+	if err != nil {
+		return "", err
+	}
+
+	value := aws.ToString(result.SecretString)
+	if result.SecretString == nil {
+		value = string(result.SecretBinary)
+	}
+	if key == "" {
+		return value, nil
+	}
+	return awsSecretKeyValue(value, key, secretName)
+}
+
+func (a *awsSecretProvider) GetJoinDelimiter() string {
+	return "/"
+}
+
+var _ versionedSecretProvider = &awsSecretProvider{}
+
+// awsSSMProvider is a secret provider that reads secrets from AWS SSM
+type awsSSMProvider struct {
+	client *ssm.Client
+}
+
+func (a *awsSSMProvider) Configure(ctx context.Context, conf map[string]any) error {
+	cfg, err := loadAWSConfig(ctx, conf)
+	if err != nil {
+		return fmt.Errorf("ssm invalid config: %w", err)
+	}
+
+	a.client = ssm.NewFromConfig(cfg)
+	return nil
+}
+
+// GetSecret returns the parameter value, SecureString values are decrypted. If the name is
+// in name#key format, the value is parsed as a JSON object and the value for the key is returned
+func (a *awsSSMProvider) GetSecret(ctx context.Context, fullName string) (string, error) {
+	return a.GetSecretVersion(ctx, fullName, "")
+}
+
+// GetSecretVersion returns the value for a version of the parameter. The version is a version
+// number or a parameter label, it is passed as name:version to SSM
+func (a *awsSSMProvider) GetSecretVersion(ctx context.Context, fullName, version string) (string, error) {
+	secretName, key, _ := strings.Cut(fullName, "#")
+	paramName := secretName
+	if version != "" {
+		paramName = secretName + ":" + version
+	}
+	input := &ssm.GetParameterInput{
+		Name:           aws.String(paramName),
+		WithDecryption: aws.Bool(true),
+	}
+
+	out, err := a.client.GetParameter(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	value := aws.ToString(out.Parameter.Value)
+	if key == "" {
+		return value, nil
+	}
+	return awsSecretKeyValue(value, key, secretName)
+}
+
+func (a *awsSSMProvider) GetJoinDelimiter() string {
+	return "/"
+}
+
+var _ versionedSecretProvider = &awsSSMProvider{}
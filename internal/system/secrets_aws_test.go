@@ -0,0 +1,63 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package system
+
+import (
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+func TestAWSSecretKeyValue(t *testing.T) {
+	t.Parallel()
+	secret := `{"user": "admin", "password": "p&ss", "port": 5432, "enabled": true, "opts": {"ssl": "on"}}`
+
+	tests := map[string]string{
+		"user":     "admin",
+		"password": "p&ss",
+		"port":     "5432",
+		"enabled":  "true",
+		"opts":     `{"ssl":"on"}`,
+	}
+	for key, expected := range tests {
+		value, err := awsSecretKeyValue(secret, key, "db")
+		testutil.AssertNoError(t, err)
+		testutil.AssertEqualsString(t, key, expected, value)
+	}
+
+	_, err := awsSecretKeyValue(secret, "missing", "db")
+	testutil.AssertErrorContains(t, err, "key missing not found in secret db")
+	_, err = awsSecretKeyValue("plain value", "user", "db")
+	testutil.AssertErrorContains(t, err, "secret db is not a JSON object, cannot read key user")
+	_, err = awsSecretKeyValue(`["user"]`, "user", "db")
+	testutil.AssertErrorContains(t, err, "secret db is not a JSON object")
+}
+
+func TestAWSConfigOptions(t *testing.T) {
+	t.Parallel()
+
+	options, err := awsConfigOptions(map[string]any{"profile": "prod", "region": "us-west-2"})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "options", 2, len(options))
+	_, err = awsConfigOptions(map[string]any{"region": int64(1)})
+	testutil.AssertErrorContains(t, err, "'region' must be a string")
+
+	roleArn, roleOptions, err := awsAssumeRoleOptions(map[string]any{})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "no role", "", roleArn)
+	testutil.AssertEqualsBool(t, "no role options", true, roleOptions == nil)
+
+	_, _, err = awsAssumeRoleOptions(map[string]any{"external_id": "ext"})
+	testutil.AssertErrorContains(t, err, "'external_id' and 'session_name' require 'role_arn' to be set")
+
+	roleArn, roleOptions, err = awsAssumeRoleOptions(map[string]any{"role_arn": "arn:aws:iam::123456789012:role/secrets", "external_id": "ext"})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "role", "arn:aws:iam::123456789012:role/secrets", roleArn)
+	opts := stscreds.AssumeRoleOptions{}
+	roleOptions(&opts)
+	testutil.AssertEqualsString(t, "session name", awsDefaultSessionName, opts.RoleSessionName)
+	testutil.AssertEqualsString(t, "external id", "ext", aws.ToString(opts.ExternalID))
+}
@@ -0,0 +1,342 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package system
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	vaultAuthToken       = "token"
+	vaultAuthAppRole     = "approle"
+	vaultDefaultCacheTTL = 60 * time.Second
+)
+
+// vaultSecretProvider is a secret provider that reads secrets from HashiCorp Vault. Token and
+// AppRole auth are supported. The token lease is renewed when less than a third of its TTL is
+// left; with AppRole, a new login is done if the token cannot be renewed. The secret values and
+// the KV mount info are cached for cache_ttl_secs
+type vaultSecretProvider struct {
+	client       *api.Client
+	authMethod   string
+	roleId       string
+	secretId     string
+	approleMount string
+	kvMounts     map[string]int // KV mount path to version, from the config. Read from the server if not set
+	cacheTTL     time.Duration
+
+	mu           sync.Mutex
+	tokenChecked bool          // whether the token TTL is known, from the login or a lookup
+	tokenTTL     time.Duration // the TTL at the last login or renewal, zero if the token does not expire
+	tokenExpiry  time.Time
+	renewable    bool
+	mounts       map[string]int
+	mountsExpiry time.Time
+	cache        map[string]vaultCacheEntry
+}
+
+type vaultCacheEntry struct {
+	value  string
+	expiry time.Time
+}
+
+func (v *vaultSecretProvider) Configure(ctx context.Context, conf map[string]any) error {
+	address, err := getConfigString(conf, "address")
+	if err != nil {
+		return fmt.Errorf("vault invalid config: %w", err)
+	}
+
+	strConfig := map[string]string{}
+	for _, key := range []string{"auth_method", "token", "role_id", "secret_id", "approle_mount", "namespace"} {
+		if strConfig[key], err = getConfigOptString(conf, key); err != nil {
+			return fmt.Errorf("vault invalid config: %w", err)
+		}
+	}
+
+	v.authMethod = strings.ToLower(strConfig["auth_method"])
+	if v.authMethod == "" {
+		v.authMethod = vaultAuthToken
+	}
+	switch v.authMethod {
+	case vaultAuthToken:
+		if strConfig["token"] == "" {
+			return fmt.Errorf("vault invalid config: missing 'token' in config")
+		}
+	case vaultAuthAppRole:
+		if strConfig["role_id"] == "" || strConfig["secret_id"] == "" {
+			return fmt.Errorf("vault invalid config: 'role_id' and 'secret_id' are required for approle auth")
+		}
+		v.roleId = strConfig["role_id"]
+		v.secretId = strConfig["secret_id"]
+		v.approleMount = strings.Trim(strConfig["approle_mount"], "/")
+		if v.approleMount == "" {
+			v.approleMount = vaultAuthAppRole
+		}
+	default:
+		return fmt.Errorf("vault invalid config: unknown auth_method %s, expected token or approle", v.authMethod)
+	}
+
+	v.cacheTTL = vaultDefaultCacheTTL
+	if ttl, ok := conf["cache_ttl_secs"]; ok {
+		ttlInt, ok := ttl.(int64)
+		if !ok {
+			return fmt.Errorf("vault invalid config: 'cache_ttl_secs' must be an integer")
+		}
+		v.cacheTTL = time.Duration(max(ttlInt, 0)) * time.Second
+	}
+
+	if mounts, ok := conf["kv_mounts"]; ok {
+		mountsMap, ok := mounts.(map[string]any)
+		if !ok {
+			return fmt.Errorf("vault invalid config: 'kv_mounts' must be a map of mount path to KV version")
+		}
+		v.kvMounts = map[string]int{}
+		for mount, version := range mountsMap {
+			versionInt, ok := version.(int64)
+			if !ok || (versionInt != 1 && versionInt != 2) {
+				return fmt.Errorf("vault invalid config: KV version for mount %s must be 1 or 2", mount)
+			}
+			v.kvMounts[strings.Trim(mount, "/")] = int(versionInt)
+		}
+	}
+
+	client, err := api.NewClient(&api.Config{Address: address})
+	if err != nil {
+		return err
+	}
+	if strConfig["namespace"] != "" {
+		client.SetNamespace(strConfig["namespace"])
+	}
+	if v.authMethod == vaultAuthToken {
+		client.SetToken(strConfig["token"])
+	} else {
+		// The login is done on the first secret read, Configure does not call the server
+		client.ClearToken()
+	}
+	v.client = client
+	v.cache = map[string]vaultCacheEntry{}
+	return nil
+}
+
+// setTokenTTL records the token TTL, from a login, a renewal or a token lookup
+func (v *vaultSecretProvider) setTokenTTL(ttl time.Duration, renewable bool) {
+	v.tokenChecked = true
+	v.tokenTTL = ttl
+	v.renewable = renewable
+	v.tokenExpiry = time.Time{}
+	if ttl > 0 {
+		v.tokenExpiry = time.Now().Add(ttl)
+	}
+}
+
+func (v *vaultSecretProvider) appRoleLogin(ctx context.Context) error {
+	secret, err := v.client.Logical().WriteWithContext(ctx, "auth/"+v.approleMount+"/login", map[string]any{
+		"role_id":   v.roleId,
+		"secret_id": v.secretId,
+	})
+	if err != nil {
+		return fmt.Errorf("vault approle login failed: %w", err)
+	}
+	if secret == nil || secret.Auth == nil || secret.Auth.ClientToken == "" {
+		return fmt.Errorf("vault approle login did not return a token")
+	}
+	v.client.SetToken(secret.Auth.ClientToken)
+	v.setTokenTTL(time.Duration(secret.Auth.LeaseDuration)*time.Second, secret.Auth.Renewable)
+	return nil
+}
+
+// ensureToken logs in if required and renews the token when it is close to expiry. Must be
+// called with the lock held
+func (v *vaultSecretProvider) ensureToken(ctx context.Context) error {
+	if !v.tokenChecked {
+		if v.authMethod == vaultAuthAppRole {
+			return v.appRoleLogin(ctx)
+		}
+		// Lookup the configured token TTL. If the lookup is not permitted by the token policy,
+		// the token is used without renewal
+		v.tokenChecked = true
+		if secret, err := v.client.Auth().Token().LookupSelfWithContext(ctx); err == nil {
+			ttl, _ := secret.TokenTTL()
+			renewable, _ := secret.TokenIsRenewable()
+			v.setTokenTTL(ttl, renewable)
+		}
+		return nil
+	}
+
+	if v.tokenExpiry.IsZero() || time.Until(v.tokenExpiry) > v.tokenTTL/3 {
+		return nil
+	}
+
+	var renewErr error
+	if v.renewable {
+		secret, err := v.client.Auth().Token().RenewSelfWithContext(ctx, 0)
+		if err == nil && secret != nil && secret.Auth != nil {
+			v.setTokenTTL(time.Duration(secret.Auth.LeaseDuration)*time.Second, secret.Auth.Renewable)
+			return nil
+		}
+		renewErr = err
+	}
+	if v.authMethod == vaultAuthAppRole {
+		return v.appRoleLogin(ctx)
+	}
+	if time.Now().After(v.tokenExpiry) {
+		return fmt.Errorf("vault token has expired, renewal failed: %v", renewErr)
+	}
+	// The token is still valid, renewal is tried again on the next read
+	return nil
+}
+
+// kvMountVersions returns the KV mounts with their version, from the config or read from the server
+func (v *vaultSecretProvider) kvMountVersions(ctx context.Context) (map[string]int, error) {
+	if v.kvMounts != nil {
+		return v.kvMounts, nil
+	}
+	if v.mounts != nil && time.Now().Before(v.mountsExpiry) {
+		return v.mounts, nil
+	}
+
+	mounts, err := v.client.Sys().ListMountsWithContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list mounts, set kv_mounts in the config if the token cannot list mounts: %w", err)
+	}
+	ret := map[string]int{}
+	for mountPath, mount := range mounts {
+		version := 1
+		if mount.Options["version"] == "2" {
+			version = 2
+		}
+		ret[strings.TrimSuffix(mountPath, "/")] = version
+	}
+	v.mounts = ret
+	v.mountsExpiry = time.Now().Add(v.cacheTTL)
+	return ret, nil
+}
+
+// vaultReadPath returns the logical API path to read the secret from, using the longest mount
+// matching the secret path. KV v2 secrets are read from <mount>/data/<path>
+func vaultReadPath(mounts map[string]int, fullPath string) (string, int, error) {
+	mountPaths := make([]string, 0, len(mounts))
+	for mountPath := range mounts {
+		mountPaths = append(mountPaths, mountPath)
+	}
+	sort.Slice(mountPaths, func(i, j int) bool {
+		return len(mountPaths[i]) > len(mountPaths[j])
+	})
+
+	for _, mountPath := range mountPaths {
+		if relPath, ok := strings.CutPrefix(fullPath, mountPath+"/"); ok && relPath != "" {
+			if mounts[mountPath] == 2 {
+				return mountPath + "/data/" + relPath, 2, nil
+			}
+			return fullPath, 1, nil
+		}
+	}
+	return "", 0, fmt.Errorf("no mount found matching path %q", fullPath)
+}
+
+// vaultSecretValue returns the string value for the key from the secret data. If key is not
+// set, the secret must have exactly one value
+func vaultSecretValue(data map[string]any, key, readPath string) (string, error) {
+	var value any
+	if key != "" {
+		var ok bool
+		if value, ok = data[key]; !ok {
+			return "", fmt.Errorf("key %s not found in secret at %s", key, readPath)
+		}
+	} else {
+		if len(data) != 1 {
+			return "", fmt.Errorf("expected exactly one key in secret at %s, got %d keys. Use path#key to select a key", readPath, len(data))
+		}
+		for _, v := range data {
+			value = v
+		}
+	}
+
+	str, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("secret value at %s is not a string", readPath)
+	}
+	return str, nil
+}
+
+// GetSecret reads the secret at the given path and returns the one string value it contains,
+// or the value for the key if the path is in path#key format. It handles both KV v1 and v2
+// engines automatically.
+func (v *vaultSecretProvider) GetSecret(ctx context.Context, fullPath string) (string, error) {
+	return v.GetSecretVersion(ctx, fullPath, "")
+}
+
+// GetSecretVersion reads a version of the secret, versions are supported for KV v2 only
+func (v *vaultSecretProvider) GetSecretVersion(ctx context.Context, fullPath, secretVersion string) (string, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	cacheKey := fullPath
+	if secretVersion != "" {
+		cacheKey = fullPath + "@" + secretVersion
+	}
+	if entry, ok := v.cache[cacheKey]; ok && time.Now().Before(entry.expiry) {
+		return entry.value, nil
+	}
+	if err := v.ensureToken(ctx); err != nil {
+		return "", err
+	}
+
+	secretPath, key, _ := strings.Cut(fullPath, "#")
+	mounts, err := v.kvMountVersions(ctx)
+	if err != nil {
+		return "", err
+	}
+	readPath, version, err := vaultReadPath(mounts, strings.Trim(secretPath, "/"))
+	if err != nil {
+		return "", err
+	}
+
+	var params map[string][]string
+	if secretVersion != "" {
+		if version != 2 {
+			return "", fmt.Errorf("secret versions are supported for KV v2 only, %s is KV v1", secretPath)
+		}
+		params = map[string][]string{"version": {secretVersion}}
+	}
+
+	secret, err := v.client.Logical().ReadWithDataWithContext(ctx, readPath, params)
+	if err != nil {
+		return "", fmt.Errorf("error reading %s: %w", readPath, err)
+	}
+	if secret == nil {
+		return "", fmt.Errorf("no secret found at %s", readPath)
+	}
+
+	data := secret.Data
+	if version == 2 {
+		// KV v2 nests values under "data"
+		var ok bool
+		if data, ok = secret.Data["data"].(map[string]any); !ok {
+			return "", fmt.Errorf("malformed data at %s", readPath)
+		}
+	}
+	value, err := vaultSecretValue(data, key, readPath)
+	if err != nil {
+		return "", err
+	}
+
+	if v.cacheTTL > 0 {
+		v.cache[cacheKey] = vaultCacheEntry{value: value, expiry: time.Now().Add(v.cacheTTL)}
+	}
+	return value, nil
+}
+
+func (v *vaultSecretProvider) GetJoinDelimiter() string {
+	return "/"
+}
+
+var _ versionedSecretProvider = &vaultSecretProvider{}
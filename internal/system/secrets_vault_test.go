@@ -0,0 +1,200 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package system
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/testutil"
+)
+
+// fakeVault serves the subset of the Vault API used by the vault secret provider
+type fakeVault struct {
+	logins   atomic.Int32
+	renews   atomic.Int32
+	reads    atomic.Int32
+	leaseTTL int
+}
+
+func (f *fakeVault) handler() http.Handler {
+	mux := http.NewServeMux()
+	writeJSON := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v) //nolint:errcheck
+	}
+	auth := func(token string) map[string]any {
+		return map[string]any{"auth": map[string]any{"client_token": token, "lease_duration": f.leaseTTL, "renewable": true}}
+	}
+
+	mux.HandleFunc("/v1/auth/approle/login", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body) //nolint:errcheck
+		if body["role_id"] != "role1" || body["secret_id"] != "secret1" {
+			w.WriteHeader(http.StatusBadRequest)
+			writeJSON(w, map[string]any{"errors": []string{"invalid role or secret id"}})
+			return
+		}
+		f.logins.Add(1)
+		writeJSON(w, auth("approle-token"))
+	})
+	mux.HandleFunc("/v1/auth/token/renew-self", func(w http.ResponseWriter, r *http.Request) {
+		f.renews.Add(1)
+		writeJSON(w, auth(r.Header.Get("X-Vault-Token")))
+	})
+	mux.HandleFunc("/v1/secret/data/app/db", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "approle-token" {
+			w.WriteHeader(http.StatusForbidden)
+			writeJSON(w, map[string]any{"errors": []string{"permission denied"}})
+			return
+		}
+		f.reads.Add(1)
+		password := "pass1"
+		if r.URL.Query().Get("version") == "1" {
+			password = "pass0"
+		}
+		writeJSON(w, map[string]any{"data": map[string]any{"data": map[string]any{"user": "admin", "password": password}}})
+	})
+	mux.HandleFunc("/v1/kv/app/key", func(w http.ResponseWriter, r *http.Request) {
+		f.reads.Add(1)
+		writeJSON(w, map[string]any{"data": map[string]any{"value": "key1"}})
+	})
+	return mux
+}
+
+func TestVaultReadPath(t *testing.T) {
+	t.Parallel()
+	mounts := map[string]int{"secret": 2, "kv": 1, "secret/team": 1}
+
+	path, version, err := vaultReadPath(mounts, "secret/app/db")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "v2 path", "secret/data/app/db", path)
+	testutil.AssertEqualsInt(t, "v2 version", 2, version)
+
+	path, version, err = vaultReadPath(mounts, "secret/team/db")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "longest mount", "secret/team/db", path)
+	testutil.AssertEqualsInt(t, "v1 version", 1, version)
+
+	_, _, err = vaultReadPath(mounts, "other/db")
+	testutil.AssertErrorContains(t, err, "no mount found matching path")
+	_, _, err = vaultReadPath(mounts, "secret")
+	testutil.AssertErrorContains(t, err, "no mount found matching path")
+}
+
+func TestVaultConfigure(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	err := (&vaultSecretProvider{}).Configure(ctx, map[string]any{"address": "http://localhost:8200"})
+	testutil.AssertErrorContains(t, err, "missing 'token' in config")
+	err = (&vaultSecretProvider{}).Configure(ctx, map[string]any{"address": "http://localhost:8200", "auth_method": "approle", "role_id": "r"})
+	testutil.AssertErrorContains(t, err, "'role_id' and 'secret_id' are required")
+	err = (&vaultSecretProvider{}).Configure(ctx, map[string]any{"address": "http://localhost:8200", "auth_method": "ldap"})
+	testutil.AssertErrorContains(t, err, "unknown auth_method ldap")
+	err = (&vaultSecretProvider{}).Configure(ctx, map[string]any{"address": "http://localhost:8200", "token": "t", "kv_mounts": map[string]any{"secret": int64(3)}})
+	testutil.AssertErrorContains(t, err, "KV version for mount secret must be 1 or 2")
+
+	p := &vaultSecretProvider{}
+	err = p.Configure(ctx, map[string]any{"address": "http://localhost:8200", "token": "t", "cache_ttl_secs": int64(0),
+		"kv_mounts": map[string]any{"secret/": int64(2)}})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "cache ttl", 0, int(p.cacheTTL))
+	testutil.AssertEqualsInt(t, "kv mount", 2, p.kvMounts["secret"])
+}
+
+func TestVaultAppRoleSecrets(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	fake := &fakeVault{leaseTTL: 3600}
+	server := httptest.NewServer(fake.handler())
+	defer server.Close()
+
+	p := &vaultSecretProvider{}
+	err := p.Configure(ctx, map[string]any{
+		"address":     server.URL,
+		"auth_method": "approle",
+		"role_id":     "role1",
+		"secret_id":   "secret1",
+		"kv_mounts":   map[string]any{"secret": int64(2), "kv": int64(1)},
+	})
+	testutil.AssertNoError(t, err)
+
+	value, err := p.GetSecret(ctx, "secret/app/db#password")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "password", "pass1", value)
+	testutil.AssertEqualsInt(t, "logins", 1, int(fake.logins.Load()))
+
+	_, err = p.GetSecret(ctx, "secret/app/db")
+	testutil.AssertErrorContains(t, err, "expected exactly one key in secret at secret/data/app/db, got 2 keys")
+	_, err = p.GetSecret(ctx, "secret/app/db#missing")
+	testutil.AssertErrorContains(t, err, "key missing not found")
+
+	value, err = p.GetSecret(ctx, "kv/app/key")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "v1 value", "key1", value)
+
+	// Cached values are not read again
+	reads := fake.reads.Load()
+	value, err = p.GetSecret(ctx, "secret/app/db#password")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "cached password", "pass1", value)
+	testutil.AssertEqualsInt(t, "cached reads", int(reads), int(fake.reads.Load()))
+
+	// Versions are cached separately from the latest value
+	value, err = p.GetSecretVersion(ctx, "secret/app/db#password", "1")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "version 1 password", "pass0", value)
+	value, err = p.GetSecret(ctx, "secret/app/db#password")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsString(t, "latest password", "pass1", value)
+	_, err = p.GetSecretVersion(ctx, "kv/app/key", "1")
+	testutil.AssertErrorContains(t, err, "secret versions are supported for KV v2 only, kv/app/key is KV v1")
+
+	// Token close to expiry is renewed
+	p.mu.Lock()
+	p.tokenExpiry = time.Now().Add(time.Minute)
+	p.cache = map[string]vaultCacheEntry{}
+	p.mu.Unlock()
+	_, err = p.GetSecret(ctx, "secret/app/db#user")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "renews", 1, int(fake.renews.Load()))
+	testutil.AssertEqualsInt(t, "logins after renew", 1, int(fake.logins.Load()))
+
+	// Token which cannot be renewed results in a new login
+	p.mu.Lock()
+	p.tokenExpiry = time.Now().Add(time.Minute)
+	p.renewable = false
+	p.cache = map[string]vaultCacheEntry{}
+	p.mu.Unlock()
+	_, err = p.GetSecret(ctx, "secret/app/db#user")
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "renews after login", 1, int(fake.renews.Load()))
+	testutil.AssertEqualsInt(t, "logins after expiry", 2, int(fake.logins.Load()))
+}
+
+func TestVaultAppRoleLoginFailure(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	fake := &fakeVault{leaseTTL: 3600}
+	server := httptest.NewServer(fake.handler())
+	defer server.Close()
+
+	p := &vaultSecretProvider{}
+	err := p.Configure(ctx, map[string]any{
+		"address":     server.URL,
+		"auth_method": "approle",
+		"role_id":     "role1",
+		"secret_id":   "wrong",
+		"kv_mounts":   map[string]any{"secret": int64(2)},
+	})
+	testutil.AssertNoError(t, err)
+	_, err = p.GetSecret(ctx, "secret/app/db#password")
+	testutil.AssertErrorContains(t, err, "vault approle login failed")
+}
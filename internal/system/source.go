@@ -4,9 +4,15 @@
 package system
 
 import (
+	"net/url"
 	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
 )
 
+// archiveSuffixes are the archive formats supported for app sources
+var archiveSuffixes = []string{".tar.gz", ".tgz", ".tar", ".zip"}
+
 // IsGit returns true if the sourceURL is a git URL
 func IsGit(url string) bool {
 	if url == "" {
@@ -15,6 +21,9 @@ func IsGit(url string) bool {
 	if url[0] == '/' || url[0] == '.' || url[0] == '~' {
 		return false
 	}
+	if IsArchive(url) {
+		return false
+	}
 	if strings.HasPrefix(url, "git@") ||
 		strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://") {
 		return true // Git URL
@@ -29,3 +38,41 @@ func IsGit(url string) bool {
 	}
 	return true
 }
+
+// IsOCI returns true if the source URL is an OCI artifact in a registry, like oci://ghcr.io/org/app:1.2
+func IsOCI(sourceUrl string) bool {
+	return strings.HasPrefix(sourceUrl, types.OCI_SOURCE_PREFIX)
+}
+
+// IsPackaged returns true if the source URL is an archive or an OCI artifact, which is downloaded
+// instead of being read from disk or checked out from git
+func IsPackaged(sourceUrl string) bool {
+	return IsArchive(sourceUrl) || IsOCI(sourceUrl)
+}
+
+// IsArchive returns true if the source URL is an archive to download: a s3://bucket/key url or a
+// http(s) url to a tarball or zip file
+func IsArchive(sourceUrl string) bool {
+	return ArchiveFormat(sourceUrl) != ""
+}
+
+// ArchiveFormat returns the archive file suffix for an archive source URL, like .tar.gz or .zip,
+// empty if the URL is not an archive
+func ArchiveFormat(sourceUrl string) string {
+	if !strings.HasPrefix(sourceUrl, "s3://") && !strings.HasPrefix(sourceUrl, "https://") &&
+		!strings.HasPrefix(sourceUrl, "http://") {
+		return ""
+	}
+	u, err := url.Parse(sourceUrl)
+	if err != nil {
+		return ""
+	}
+	// The query string is not part of the file name, like for presigned urls
+	filePath := strings.ToLower(u.Path)
+	for _, suffix := range archiveSuffixes {
+		if strings.HasSuffix(filePath, suffix) {
+			return suffix
+		}
+	}
+	return ""
+}
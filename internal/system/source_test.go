@@ -0,0 +1,37 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package system
+
+import "testing"
+
+func TestSourceType(t *testing.T) {
+	tests := []struct {
+		url     string
+		git     bool
+		archive string
+	}{
+		{"github.com/openrundev/apps/utils/bookmarks", true, ""},
+		{"https://github.com/openrundev/apps", true, ""},
+		{"git@github.com:openrundev/apps.git", true, ""},
+		{"/home/user/app", false, ""},
+		{"https://artifacts.example.com/releases/app-1.2.tar.gz", false, ".tar.gz"},
+		{"https://artifacts.example.com/app.TGZ?X-Amz-Signature=abc", false, ".tgz"},
+		{"http://localhost:8080/app.zip", false, ".zip"},
+		{"s3://mybucket/builds/app.tar", false, ".tar"},
+		{"s3://mybucket/builds/app", false, ""},
+		{"/tmp/app.tar.gz", false, ""},
+		{"oci://ghcr.io/org/app:1.2", false, ""},
+	}
+	for _, tc := range tests {
+		if got := IsGit(tc.url); got != tc.git {
+			t.Errorf("IsGit(%q) = %t, want %t", tc.url, got, tc.git)
+		}
+		if got := ArchiveFormat(tc.url); got != tc.archive {
+			t.Errorf("ArchiveFormat(%q) = %q, want %q", tc.url, got, tc.archive)
+		}
+		if got, want := IsPackaged(tc.url), tc.archive != "" || IsOCI(tc.url); got != want {
+			t.Errorf("IsPackaged(%q) = %t, want %t", tc.url, got, want)
+		}
+	}
+}
@@ -4,6 +4,7 @@
 package system
 
 import (
+	"fmt"
 	htmltemplate "html/template"
 	"strings"
 	"testing"
@@ -42,6 +43,70 @@ func TestAppEvalTemplatePassthrough(t *testing.T) {
 	}
 }
 
+func TestSecretRefs(t *testing.T) {
+	s := &SecretManager{defaultProvider: "env"}
+	for _, test := range []struct {
+		defaultProvider, input, expected string
+	}{
+		{"", `{{secret "api_key"}}`, "[env/api_key]"},
+		{"", `a {{secret_from "db" "k1"}} b {{ upper (secret "x") }}`, "[db/k1 env/x]"},
+		{"prop", `{{secret "k"}} {{secret_from "default" "k2"}}`, "[prop/k prop/k2]"},
+		{"", `{{secret .Key}} {{ unclosed`, "[]"},
+		{"", `{{secret .Key}}`, "[]"},
+		{"", "plain text", "[]"},
+		{"", `{{secret "api_key@latest"}} {{secret_from "asm" "app" "db@AWSPREVIOUS"}}`, "[env/api_key asm/appdb]"},
+	} {
+		got := fmt.Sprint(s.SecretRefs(test.defaultProvider, test.input))
+		if got != test.expected {
+			t.Fatalf("input %q: got %s, expected %s", test.input, got, test.expected)
+		}
+	}
+}
+
+func TestSplitSecretVersion(t *testing.T) {
+	for _, test := range []struct {
+		input         []string
+		keys, version string
+	}{
+		{[]string{"api_key"}, "[api_key]", ""},
+		{[]string{"api_key@latest"}, "[api_key]", "latest"},
+		{[]string{"app", "db@3"}, "[app db]", "3"},
+		{[]string{"db@AWSPREVIOUS"}, "[db]", "AWSPREVIOUS"},
+		{[]string{"db@a1b2c3d4-0000-1111-2222-333344445555"}, "[db]", "a1b2c3d4-0000-1111-2222-333344445555"},
+		{[]string{"user@example.com"}, "[user@example.com]", ""},
+		{[]string{"@3"}, "[@3]", ""},
+		{[]string{"db@3", "key"}, "[db@3 key]", ""},
+	} {
+		keys, version := splitSecretVersion(test.input)
+		if fmt.Sprint(keys) != test.keys || version != test.version {
+			t.Fatalf("input %q: got %v %q, expected %s %q", test.input, keys, version, test.keys, test.version)
+		}
+	}
+
+	// The input keys are not modified
+	input := []string{"db@3"}
+	splitSecretVersion(input)
+	if input[0] != "db@3" {
+		t.Fatalf("input keys modified: %q", input)
+	}
+}
+
+func TestSecretVersionUnsupported(t *testing.T) {
+	t.Setenv("OPENRUN_TEST_VERSIONED", "value1")
+	s := &SecretManager{funcMap: GetFuncMap(), defaultProvider: "env", providers: map[string]secretProvider{"env": &envSecretProvider{}}}
+	s.funcMap["secret"] = s.templateSecretFunc
+	s.funcMap["secret_from"] = s.templateSecretFromFunc
+
+	got, err := s.EvalTemplate(`{{secret "OPENRUN_TEST_VERSIONED@latest"}}`)
+	if err != nil || got != "value1" {
+		t.Fatalf("latest version: got %q %v", got, err)
+	}
+	_, err = s.EvalTemplate(`{{secret "OPENRUN_TEST_VERSIONED@2"}}`)
+	if err == nil || !strings.Contains(err.Error(), "secret provider env does not support secret versions") {
+		t.Fatalf("expected versions unsupported error, got %v", err)
+	}
+}
+
 func TestSafeHTMLFunc(t *testing.T) {
 	tmpl, err := htmltemplate.New("t").Funcs(GetFuncMap()).
 		Parse(`{{ .Escaped }}|{{ .Raw | safeHTML }}`)
@@ -0,0 +1,142 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package system
+
+import (
+	"crypto/fips140"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/openrundev/openrun/internal/types"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+var tlsCurves = map[string]tls.CurveID{
+	"X25519":         tls.X25519,
+	"P256":           tls.CurveP256,
+	"P384":           tls.CurveP384,
+	"P521":           tls.CurveP521,
+	"X25519MLKEM768": tls.X25519MLKEM768,
+}
+
+// fipsCipherSuites are the TLS 1.2 cipher suites approved for FIPS 140-3
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// fipsCurves are the key exchange curves approved for FIPS 140-3
+var fipsCurves = []tls.CurveID{tls.CurveP256, tls.CurveP384, tls.CurveP521}
+
+// fipsEnabled is overridden in tests
+var fipsEnabled = fips140.Enabled
+
+// TLSPolicy is the validated TLS policy from the [tls] config
+type TLSPolicy struct {
+	MinVersion       uint16
+	CipherSuites     []uint16
+	CurvePreferences []tls.CurveID
+	OcspStapling     bool
+	Fips             bool
+}
+
+// NewTLSPolicy validates the [tls] config and returns the policy. With fips set, the process has
+// to be running in FIPS 140-3 mode and only the FIPS approved cipher suites and curves are allowed.
+// The approved ones are used by default
+func NewTLSPolicy(config types.TLSConfig) (*TLSPolicy, error) {
+	policy := &TLSPolicy{MinVersion: tls.VersionTLS12, OcspStapling: config.OcspStapling, Fips: config.Fips}
+	if config.MinVersion != "" {
+		version, ok := tlsVersions[config.MinVersion]
+		if !ok {
+			return nil, fmt.Errorf("invalid tls.min_version %q, expected 1.2 or 1.3", config.MinVersion)
+		}
+		policy.MinVersion = version
+	}
+
+	for _, name := range config.CipherSuites {
+		id, err := cipherSuiteId(name)
+		if err != nil {
+			return nil, err
+		}
+		if config.Fips && !slices.Contains(fipsCipherSuites, id) {
+			return nil, fmt.Errorf("tls cipher suite %s is not FIPS approved", name)
+		}
+		policy.CipherSuites = append(policy.CipherSuites, id)
+	}
+
+	for _, name := range config.CurvePreferences {
+		id, ok := tlsCurves[strings.ReplaceAll(strings.ToUpper(name), "-", "")]
+		if !ok {
+			return nil, fmt.Errorf("invalid tls curve %s, expected one of X25519, P256, P384, P521 or X25519MLKEM768", name)
+		}
+		if config.Fips && !slices.Contains(fipsCurves, id) {
+			return nil, fmt.Errorf("tls curve %s is not FIPS approved", name)
+		}
+		policy.CurvePreferences = append(policy.CurvePreferences, id)
+	}
+
+	if config.Fips {
+		if !fipsEnabled() {
+			return nil, fmt.Errorf("tls.fips is set but FIPS 140-3 mode is not enabled, " +
+				"build with GOFIPS140=v1.0.0 or run with GODEBUG=fips140=on")
+		}
+		if len(policy.CipherSuites) == 0 {
+			policy.CipherSuites = fipsCipherSuites
+		}
+		if len(policy.CurvePreferences) == 0 {
+			policy.CurvePreferences = fipsCurves
+		}
+	}
+	return policy, nil
+}
+
+// cipherSuiteId returns the id of a secure TLS 1.2 cipher suite. The TLS 1.3 suites are not
+// configurable in Go
+func cipherSuiteId(name string) (uint16, error) {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name != name {
+			continue
+		}
+		if !slices.Contains(suite.SupportedVersions, tls.VersionTLS12) {
+			return 0, fmt.Errorf("tls cipher suite %s is TLS 1.3 only, the TLS 1.3 suites are not configurable", name)
+		}
+		return suite.ID, nil
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.Name == name {
+			return 0, fmt.Errorf("tls cipher suite %s is insecure", name)
+		}
+	}
+	return 0, fmt.Errorf("invalid tls cipher suite %s", name)
+}
+
+// Apply sets the policy on the TLS config
+func (p *TLSPolicy) Apply(tlsConfig *tls.Config) {
+	tlsConfig.MinVersion = p.MinVersion
+	if len(p.CipherSuites) > 0 {
+		tlsConfig.CipherSuites = slices.Clone(p.CipherSuites)
+	}
+	if len(p.CurvePreferences) > 0 {
+		tlsConfig.CurvePreferences = slices.Clone(p.CurvePreferences)
+	}
+}
+
+// SetClientTLSPolicy applies the policy to the default HTTP transport. The outbound HTTP clients
+// use or clone the default transport, so they inherit the policy
+func SetClientTLSPolicy(p *TLSPolicy) {
+	transport := http.DefaultTransport.(*http.Transport)
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	p.Apply(transport.TLSClientConfig)
+}
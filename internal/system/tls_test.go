@@ -0,0 +1,71 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package system
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/openrundev/openrun/internal/testutil"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+func TestNewTLSPolicy(t *testing.T) {
+	policy, err := NewTLSPolicy(types.TLSConfig{})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "default min version", tls.VersionTLS12, int(policy.MinVersion))
+
+	policy, err = NewTLSPolicy(types.TLSConfig{
+		MinVersion:       "1.3",
+		CipherSuites:     []string{"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"},
+		CurvePreferences: []string{"x25519", "P-384"},
+	})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "min version", tls.VersionTLS13, int(policy.MinVersion))
+	testutil.AssertEqualsInt(t, "cipher", int(tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256), int(policy.CipherSuites[0]))
+	testutil.AssertEqualsInt(t, "curves", 2, len(policy.CurvePreferences))
+	testutil.AssertEqualsInt(t, "curve", int(tls.CurveP384), int(policy.CurvePreferences[1]))
+
+	tlsConfig := &tls.Config{}
+	policy.Apply(tlsConfig)
+	testutil.AssertEqualsInt(t, "applied min version", tls.VersionTLS13, int(tlsConfig.MinVersion))
+	testutil.AssertEqualsInt(t, "applied ciphers", 1, len(tlsConfig.CipherSuites))
+
+	tests := []struct {
+		name    string
+		config  types.TLSConfig
+		wantErr string
+	}{
+		{name: "version", config: types.TLSConfig{MinVersion: "1.1"}, wantErr: `invalid tls.min_version "1.1"`},
+		{name: "cipher", config: types.TLSConfig{CipherSuites: []string{"TLS_UNKNOWN"}}, wantErr: "invalid tls cipher suite TLS_UNKNOWN"},
+		{name: "insecure", config: types.TLSConfig{CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}}, wantErr: "is insecure"},
+		{name: "tls13", config: types.TLSConfig{CipherSuites: []string{"TLS_AES_128_GCM_SHA256"}}, wantErr: "TLS 1.3 suites are not configurable"},
+		{name: "curve", config: types.TLSConfig{CurvePreferences: []string{"P192"}}, wantErr: "invalid tls curve P192"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewTLSPolicy(tc.config)
+			testutil.AssertErrorContains(t, err, tc.wantErr)
+		})
+	}
+}
+
+func TestNewTLSPolicyFips(t *testing.T) {
+	defer func(orig func() bool) { fipsEnabled = orig }(fipsEnabled)
+
+	fipsEnabled = func() bool { return false }
+	_, err := NewTLSPolicy(types.TLSConfig{Fips: true})
+	testutil.AssertErrorContains(t, err, "FIPS 140-3 mode is not enabled")
+
+	fipsEnabled = func() bool { return true }
+	policy, err := NewTLSPolicy(types.TLSConfig{Fips: true})
+	testutil.AssertNoError(t, err)
+	testutil.AssertEqualsInt(t, "fips ciphers", len(fipsCipherSuites), len(policy.CipherSuites))
+	testutil.AssertEqualsInt(t, "fips curves", len(fipsCurves), len(policy.CurvePreferences))
+
+	_, err = NewTLSPolicy(types.TLSConfig{Fips: true, CipherSuites: []string{"TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305_SHA256"}})
+	testutil.AssertErrorContains(t, err, "is not FIPS approved")
+	_, err = NewTLSPolicy(types.TLSConfig{Fips: true, CurvePreferences: []string{"X25519"}})
+	testutil.AssertErrorContains(t, err, "tls curve X25519 is not FIPS approved")
+}
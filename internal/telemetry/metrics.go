@@ -5,6 +5,7 @@ package telemetry
 
 import (
 	"context"
+	"database/sql"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -33,6 +34,19 @@ var (
 	appRequest               metric.Int64Counter
 	appResponse              metric.Int64Counter
 	appProxyBytes            metric.Int64Counter
+	appRequestDuration       metric.Float64Histogram
+	handlerInstrumentsOnce   sync.Once
+	handlerDuration          metric.Float64Histogram
+	syncInstrumentsOnce      sync.Once
+	syncRun                  metric.Int64Counter
+	proxyLimitOnce           sync.Once
+	proxyLimitExceeded       metric.Int64Counter
+	wakeupOnce               sync.Once
+	containerWakeup          metric.Float64Histogram
+	requestWaitOnce          sync.Once
+	containerRequestWait     metric.Float64Histogram
+	appStartOnce             sync.Once
+	appStartDuration         metric.Float64Histogram
 )
 
 // resetMetricInstruments is called from Shutdown so that a subsequent Setup
@@ -46,6 +60,19 @@ func resetMetricInstruments() {
 	appRequest = nil
 	appResponse = nil
 	appProxyBytes = nil
+	appRequestDuration = nil
+	handlerInstrumentsOnce = sync.Once{}
+	handlerDuration = nil
+	syncInstrumentsOnce = sync.Once{}
+	syncRun = nil
+	proxyLimitOnce = sync.Once{}
+	proxyLimitExceeded = nil
+	wakeupOnce = sync.Once{}
+	containerWakeup = nil
+	requestWaitOnce = sync.Once{}
+	containerRequestWait = nil
+	appStartOnce = sync.Once{}
+	appStartDuration = nil
 }
 
 func ensureDBInstruments() metric.Float64Histogram {
@@ -107,8 +134,107 @@ func ensureAppInstruments() bool {
 			appResponse = nil
 			return
 		}
+		appRequestDuration, err = meter.Float64Histogram(
+			"openrun.app.request.duration",
+			metric.WithUnit("ms"),
+			metric.WithDescription("Duration of app requests in milliseconds"),
+		)
+		if err != nil {
+			appRequest = nil
+			appResponse = nil
+			appProxyBytes = nil
+			return
+		}
 	})
-	return appRequest != nil && appResponse != nil && appProxyBytes != nil
+	return appRequest != nil && appResponse != nil && appProxyBytes != nil && appRequestDuration != nil
+}
+
+func ensureHandlerInstruments() metric.Float64Histogram {
+	handlerInstrumentsOnce.Do(func() {
+		hist, err := Meter().Float64Histogram(
+			"openrun.app.handler.duration",
+			metric.WithUnit("ms"),
+			metric.WithDescription("Duration of Starlark handler calls in milliseconds"),
+		)
+		if err != nil {
+			return
+		}
+		handlerDuration = hist
+	})
+	return handlerDuration
+}
+
+func ensureSyncInstruments() metric.Int64Counter {
+	syncInstrumentsOnce.Do(func() {
+		counter, err := Meter().Int64Counter(
+			"openrun.sync.run",
+			metric.WithDescription("Sync job runs by trigger and outcome"),
+		)
+		if err != nil {
+			return
+		}
+		syncRun = counter
+	})
+	return syncRun
+}
+
+func ensureProxyLimitInstruments() metric.Int64Counter {
+	proxyLimitOnce.Do(func() {
+		counter, err := Meter().Int64Counter(
+			"openrun.app.proxy.limit_exceeded",
+			metric.WithDescription("Proxied responses aborted by the response size or time limit"),
+		)
+		if err != nil {
+			return
+		}
+		proxyLimitExceeded = counter
+	})
+	return proxyLimitExceeded
+}
+
+func ensureWakeupInstruments() metric.Float64Histogram {
+	wakeupOnce.Do(func() {
+		hist, err := Meter().Float64Histogram(
+			"openrun.app.container.wakeup.duration",
+			metric.WithUnit("ms"),
+			metric.WithDescription("Time taken to start a stopped app container until it is ready, in milliseconds"),
+		)
+		if err != nil {
+			return
+		}
+		containerWakeup = hist
+	})
+	return containerWakeup
+}
+
+func ensureRequestWaitInstruments() metric.Float64Histogram {
+	requestWaitOnce.Do(func() {
+		hist, err := Meter().Float64Histogram(
+			"openrun.app.container.request_wait.duration",
+			metric.WithUnit("ms"),
+			metric.WithDescription("Time requests were queued waiting for the app container to be ready, in milliseconds"),
+		)
+		if err != nil {
+			return
+		}
+		containerRequestWait = hist
+	})
+	return containerRequestWait
+}
+
+func ensureAppStartInstruments() metric.Float64Histogram {
+	appStartOnce.Do(func() {
+		hist, err := Meter().Float64Histogram(
+			"openrun.app.start.duration",
+			metric.WithUnit("ms"),
+			metric.WithDescription("Time taken to load an app, by phase, in milliseconds"),
+		)
+		if err != nil {
+			return
+		}
+		appStartDuration = hist
+	})
+	return appStartDuration
 }
 
 // RecordDBCall records the duration and outcome of a SQL driver call. It is a
@@ -179,6 +305,57 @@ func RecordAppResponse(ctx context.Context, status int, attrs ...attribute.KeyVa
 	appResponse.Add(ctx, 1, metric.WithAttributes(statusAttrs...))
 }
 
+// RecordAppRequestDuration records the app request latency by route and HTTP
+// status bucket. route is the matched route pattern, not the request path, to
+// keep the cardinality bounded. It is a no-op when metrics are disabled.
+func RecordAppRequestDuration(ctx context.Context, route string, status int, start time.Time, attrs ...attribute.KeyValue) {
+	if !MetricsEnabled() || !ensureAppInstruments() {
+		return
+	}
+	durationAttrs := make([]attribute.KeyValue, 0, len(attrs)+2)
+	durationAttrs = append(durationAttrs, attrs...)
+	durationAttrs = append(durationAttrs,
+		attribute.String("openrun.app.route", route),
+		attribute.String("openrun.response.status", statusBucket(status)),
+	)
+	appRequestDuration.Record(ctx, float64(time.Since(start).Microseconds())/1000.0, metric.WithAttributes(durationAttrs...))
+}
+
+// RecordStarlarkHandler records the duration and outcome of a Starlark
+// handler call. It is a no-op when metrics are disabled.
+func RecordStarlarkHandler(ctx context.Context, handler string, start time.Time, err error, attrs ...attribute.KeyValue) {
+	if !MetricsEnabled() {
+		return
+	}
+	hist := ensureHandlerInstruments()
+	if hist == nil {
+		return
+	}
+	handlerAttrs := make([]attribute.KeyValue, 0, len(attrs)+2)
+	handlerAttrs = append(handlerAttrs, attrs...)
+	handlerAttrs = append(handlerAttrs,
+		attribute.String("openrun.handler", handler),
+		attribute.Bool("openrun.error", err != nil),
+	)
+	hist.Record(ctx, float64(time.Since(start).Microseconds())/1000.0, metric.WithAttributes(handlerAttrs...))
+}
+
+// RecordSyncRun records a sync job run. trigger is scheduled, manual or webhook,
+// outcome is success or failure. It is a no-op when metrics are disabled.
+func RecordSyncRun(ctx context.Context, trigger, outcome string) {
+	if !MetricsEnabled() {
+		return
+	}
+	counter := ensureSyncInstruments()
+	if counter == nil {
+		return
+	}
+	counter.Add(ctx, 1, metric.WithAttributes(
+		attribute.String("openrun.sync.trigger", trigger),
+		attribute.String("openrun.sync.outcome", outcome),
+	))
+}
+
 // RecordAppProxyBytes records app reverse-proxy byte counters. bytesIn is
 // traffic received from the client, and bytesOut is traffic sent to the client.
 func RecordAppProxyBytes(ctx context.Context, bytesIn, bytesOut uint64, attrs ...attribute.KeyValue) {
@@ -195,6 +372,169 @@ func RecordAppProxyBytes(ctx context.Context, bytesIn, bytesOut uint64, attrs ..
 	}
 }
 
+// RecordAppProxyLimitExceeded records a proxied response which was aborted by
+// the configured limits. limit is size or time. It is a no-op when metrics are
+// disabled.
+func RecordAppProxyLimitExceeded(ctx context.Context, limit string, attrs ...attribute.KeyValue) {
+	if !MetricsEnabled() {
+		return
+	}
+	counter := ensureProxyLimitInstruments()
+	if counter == nil {
+		return
+	}
+	counter.Add(ctx, 1, metric.WithAttributes(metricAttrs(attrs, attribute.String("openrun.proxy.limit", limit))...))
+}
+
+// RecordContainerWakeup records the time taken to start a stopped app container, like after an
+// idle shutdown, until it is ready to serve requests. It is a no-op when metrics are disabled.
+func RecordContainerWakeup(ctx context.Context, start time.Time, err error, attrs ...attribute.KeyValue) {
+	if !MetricsEnabled() {
+		return
+	}
+	hist := ensureWakeupInstruments()
+	if hist == nil {
+		return
+	}
+	hist.Record(ctx, float64(time.Since(start).Microseconds())/1000.0,
+		metric.WithAttributes(metricAttrs(attrs, attribute.Bool("openrun.error", err != nil))...))
+}
+
+// RecordContainerRequestWait records the time a request was queued waiting for the app container
+// to start and become ready. outcome is one of ready, timeout, failed or canceled. It is a no-op
+// when metrics are disabled.
+func RecordContainerRequestWait(ctx context.Context, start time.Time, outcome string, attrs ...attribute.KeyValue) {
+	if !MetricsEnabled() {
+		return
+	}
+	hist := ensureRequestWaitInstruments()
+	if hist == nil {
+		return
+	}
+	hist.Record(ctx, float64(time.Since(start).Microseconds())/1000.0,
+		metric.WithAttributes(metricAttrs(attrs, attribute.String("openrun.wait.outcome", outcome))...))
+}
+
+// AppStartPhases is the time taken by the phases of an app load, in milliseconds
+type AppStartPhases struct {
+	Reload         bool
+	Total          float64
+	SourceLoad     float64
+	StarlarkInit   float64
+	TemplateParse  float64
+	ContainerStart float64
+}
+
+// RecordAppStart records the time taken to load an app, with one data point per phase. The
+// openrun.start.reload attribute is false for the cold start of the app. It is a no-op when
+// metrics are disabled.
+func RecordAppStart(ctx context.Context, phases AppStartPhases, attrs ...attribute.KeyValue) {
+	if !MetricsEnabled() {
+		return
+	}
+	hist := ensureAppStartInstruments()
+	if hist == nil {
+		return
+	}
+	reload := attribute.Bool("openrun.start.reload", phases.Reload)
+	for _, phase := range []struct {
+		name string
+		ms   float64
+	}{
+		{"total", phases.Total},
+		{"source_load", phases.SourceLoad},
+		{"starlark_init", phases.StarlarkInit},
+		{"template_parse", phases.TemplateParse},
+		{"container_start", phases.ContainerStart},
+	} {
+		phaseAttrs := append(metricAttrs(attrs, reload), attribute.String("openrun.start.phase", phase.name))
+		hist.Record(ctx, phase.ms, metric.WithAttributes(phaseAttrs...))
+	}
+}
+
+// RegisterDBPoolStats reports the connection pool stats of a database as
+// observable metrics, collected when the metrics are read. name identifies the
+// database (metadata, audit). It is a no-op when metrics are disabled, the
+// callback is dropped when the meter provider is shut down.
+func RegisterDBPoolStats(name string, stats func() sql.DBStats) error {
+	if !MetricsEnabled() {
+		return nil
+	}
+	meter := Meter()
+	connections, err := meter.Int64ObservableGauge(
+		"openrun.db.pool.connections",
+		metric.WithDescription("Database pool connections by state"),
+	)
+	if err != nil {
+		return err
+	}
+	maxOpen, err := meter.Int64ObservableGauge(
+		"openrun.db.pool.max_open",
+		metric.WithDescription("Maximum open connections for the database pool, 0 is unlimited"),
+	)
+	if err != nil {
+		return err
+	}
+	waits, err := meter.Int64ObservableCounter(
+		"openrun.db.pool.wait",
+		metric.WithDescription("Total database pool waits for a free connection"),
+	)
+	if err != nil {
+		return err
+	}
+	waitDuration, err := meter.Float64ObservableCounter(
+		"openrun.db.pool.wait.duration",
+		metric.WithUnit("ms"),
+		metric.WithDescription("Total time waited for a free database pool connection in milliseconds"),
+	)
+	if err != nil {
+		return err
+	}
+
+	dbAttr := attribute.String("openrun.db.name", name)
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		s := stats()
+		o.ObserveInt64(connections, int64(s.InUse), metric.WithAttributes(dbAttr, attribute.String("openrun.db.state", "in_use")))
+		o.ObserveInt64(connections, int64(s.Idle), metric.WithAttributes(dbAttr, attribute.String("openrun.db.state", "idle")))
+		o.ObserveInt64(maxOpen, int64(s.MaxOpenConnections), metric.WithAttributes(dbAttr))
+		o.ObserveInt64(waits, s.WaitCount, metric.WithAttributes(dbAttr))
+		o.ObserveFloat64(waitDuration, float64(s.WaitDuration.Microseconds())/1000.0, metric.WithAttributes(dbAttr))
+		return nil
+	}, connections, maxOpen, waits, waitDuration)
+	return err
+}
+
+// AppContainerState is the container state of a loaded app, with the app
+// identity attributes from AppIdentityAttributes
+type AppContainerState struct {
+	Attrs []attribute.KeyValue
+	State string
+}
+
+// RegisterContainerStates reports the container state of the loaded apps as
+// an observable gauge, with value 1 for the current state of each app. states
+// is called when the metrics are read. It is a no-op when metrics are disabled.
+func RegisterContainerStates(states func() []AppContainerState) error {
+	if !MetricsEnabled() {
+		return nil
+	}
+	meter := Meter()
+	gauge, err := meter.Int64ObservableGauge(
+		"openrun.app.container.state",
+		metric.WithDescription("Container state of the loaded apps, 1 for the current state"),
+	)
+	if err != nil {
+		return err
+	}
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for _, state := range states() {
+			o.ObserveInt64(gauge, 1, metric.WithAttributes(metricAttrs(state.Attrs, attribute.String("openrun.container.state", state.State))...))
+		}
+		return nil
+	}, gauge)
+	return err
+}
+
 func statusBucket(status int) string {
 	switch {
 	case status == 401:
@@ -23,6 +23,9 @@ func TestMetricRecordingNoopsWhenDisabled(t *testing.T) {
 	RecordAppRequest(context.Background(), "GET")
 	RecordAppResponse(context.Background(), 200)
 	RecordAppProxyBytes(context.Background(), 10, 20)
+	RecordAppProxyLimitExceeded(context.Background(), "size")
+	RecordContainerWakeup(context.Background(), time.Now(), nil)
+	RecordContainerRequestWait(context.Background(), time.Now(), "ready")
 }
 
 func TestMetricRecordingCreatesInstrumentsWhenEnabled(t *testing.T) {
@@ -64,6 +67,26 @@ func TestMetricRecordingCreatesInstrumentsWhenEnabled(t *testing.T) {
 	if appProxyBytes == nil {
 		t.Fatal("expected app proxy byte counter to be initialized")
 	}
+
+	RecordAppProxyLimitExceeded(context.Background(), "time")
+	if proxyLimitExceeded == nil {
+		t.Fatal("expected app proxy limit counter to be initialized")
+	}
+
+	RecordContainerWakeup(context.Background(), time.Now().Add(-time.Second), nil)
+	if containerWakeup == nil {
+		t.Fatal("expected container wakeup histogram to be initialized")
+	}
+
+	RecordContainerRequestWait(context.Background(), time.Now().Add(-time.Second), "timeout")
+	if containerRequestWait == nil {
+		t.Fatal("expected container request wait histogram to be initialized")
+	}
+
+	RecordAppStart(context.Background(), AppStartPhases{Total: 120, StarlarkInit: 20, ContainerStart: 100})
+	if appStartDuration == nil {
+		t.Fatal("expected app start histogram to be initialized")
+	}
 }
 
 func TestStatusBucket(t *testing.T) {
@@ -0,0 +1,277 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetry
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// PrometheusEnabled reports whether the Providers have the Prometheus reader,
+// that is, whether MetricsHandler has metrics to serve.
+func (p *Providers) PrometheusEnabled() bool {
+	return p != nil && p.prometheusReader != nil
+}
+
+// MetricsHandler returns the handler for the Prometheus scrape endpoint. The
+// metrics are collected from the meter provider on each request and written in
+// the Prometheus text exposition format. If token is set, requests need to
+// pass it as a bearer token.
+func (p *Providers) MetricsHandler(token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if token != "" {
+			bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="metrics"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+		}
+		if !p.PrometheusEnabled() {
+			http.Error(w, "prometheus metrics are not enabled", http.StatusNotFound)
+			return
+		}
+
+		var rm metricdata.ResourceMetrics
+		if err := p.prometheusReader.Collect(r.Context(), &rm); err != nil {
+			http.Error(w, "error collecting metrics: "+err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", prometheusContentType)
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodHead {
+			return
+		}
+		writePrometheus(w, &rm) //nolint:errcheck
+	})
+}
+
+// promFamily is one metric in the exposition output. The same metric name can
+// be reported by more than one instrumentation scope, the data points are merged
+type promFamily struct {
+	name   string
+	help   string
+	kind   string // counter, gauge or histogram
+	series []promSeries
+}
+
+// promSeries is the output lines for one attribute set, a histogram has multiple lines
+type promSeries struct {
+	labels string
+	lines  []string
+}
+
+// writePrometheus writes the metrics in the Prometheus text exposition format.
+// The dots in the OpenTelemetry names are replaced, the unit is added as a
+// suffix and monotonic sums get the _total suffix. Histograms are written with
+// cumulative buckets, exponential histograms and summaries are not supported.
+func writePrometheus(w io.Writer, rm *metricdata.ResourceMetrics) error {
+	families := map[string]*promFamily{}
+	for _, scope := range rm.ScopeMetrics {
+		for _, m := range scope.Metrics {
+			addPromMetric(families, m)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, name := range slices.Sorted(maps.Keys(families)) {
+		family := families[name]
+		slices.SortStableFunc(family.series, func(a, b promSeries) int {
+			return strings.Compare(a.labels, b.labels)
+		})
+		if family.help != "" {
+			bw.WriteString("# HELP " + name + " " + escapeHelp(family.help) + "\n") //nolint:errcheck
+		}
+		bw.WriteString("# TYPE " + name + " " + family.kind + "\n") //nolint:errcheck
+		for _, series := range family.series {
+			for _, line := range series.lines {
+				bw.WriteString(line + "\n") //nolint:errcheck
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+func addPromMetric(families map[string]*promFamily, m metricdata.Metrics) {
+	baseName := promMetricName(m.Name, m.Unit)
+	switch data := m.Data.(type) {
+	case metricdata.Sum[int64]:
+		addPromSum(families, baseName, m.Description, data.IsMonotonic, data.DataPoints)
+	case metricdata.Sum[float64]:
+		addPromSum(families, baseName, m.Description, data.IsMonotonic, data.DataPoints)
+	case metricdata.Gauge[int64]:
+		addPromPoints(families, baseName, m.Description, "gauge", data.DataPoints)
+	case metricdata.Gauge[float64]:
+		addPromPoints(families, baseName, m.Description, "gauge", data.DataPoints)
+	case metricdata.Histogram[int64]:
+		addPromHistogram(families, baseName, m.Description, data.DataPoints)
+	case metricdata.Histogram[float64]:
+		addPromHistogram(families, baseName, m.Description, data.DataPoints)
+	}
+}
+
+// promFamilyFor returns the family for the name, nil if the name is already
+// used by a metric of a different kind
+func promFamilyFor(families map[string]*promFamily, name, help, kind string) *promFamily {
+	family, ok := families[name]
+	if !ok {
+		family = &promFamily{name: name, help: help, kind: kind}
+		families[name] = family
+	}
+	if family.kind != kind {
+		return nil
+	}
+	return family
+}
+
+func addPromSum[N int64 | float64](families map[string]*promFamily, name, help string, monotonic bool, points []metricdata.DataPoint[N]) {
+	if !monotonic {
+		// An up-down counter can decrease, which is a gauge for Prometheus
+		addPromPoints(families, name, help, "gauge", points)
+		return
+	}
+	addPromPoints(families, name+"_total", help, "counter", points)
+}
+
+func addPromPoints[N int64 | float64](families map[string]*promFamily, name, help, kind string, points []metricdata.DataPoint[N]) {
+	family := promFamilyFor(families, name, help, kind)
+	if family == nil {
+		return
+	}
+	for _, point := range points {
+		labels := promLabels(point.Attributes, "")
+		family.series = append(family.series, promSeries{
+			labels: labels,
+			lines:  []string{name + labels + " " + formatPromValue(float64(point.Value))},
+		})
+	}
+}
+
+func addPromHistogram[N int64 | float64](families map[string]*promFamily, name, help string, points []metricdata.HistogramDataPoint[N]) {
+	family := promFamilyFor(families, name, help, "histogram")
+	if family == nil {
+		return
+	}
+	for _, point := range points {
+		lines := make([]string, 0, len(point.Bounds)+3)
+		var cumulative uint64
+		for i, bound := range point.Bounds {
+			if i < len(point.BucketCounts) {
+				cumulative += point.BucketCounts[i]
+			}
+			le := `le="` + formatPromValue(bound) + `"`
+			lines = append(lines, name+"_bucket"+promLabels(point.Attributes, le)+" "+strconv.FormatUint(cumulative, 10))
+		}
+		labels := promLabels(point.Attributes, "")
+		lines = append(lines,
+			name+"_bucket"+promLabels(point.Attributes, `le="+Inf"`)+" "+strconv.FormatUint(point.Count, 10),
+			name+"_sum"+labels+" "+formatPromValue(float64(point.Sum)),
+			name+"_count"+labels+" "+strconv.FormatUint(point.Count, 10),
+		)
+		family.series = append(family.series, promSeries{labels: labels, lines: lines})
+	}
+}
+
+// promUnitSuffixes maps the UCUM units used by the instruments to the
+// Prometheus base unit suffix
+var promUnitSuffixes = map[string]string{
+	"ms": "_milliseconds",
+	"s":  "_seconds",
+	"By": "_bytes",
+}
+
+func promMetricName(name, unit string) string {
+	ret := sanitizePromName(name)
+	if suffix := promUnitSuffixes[unit]; suffix != "" && !strings.HasSuffix(ret, suffix) {
+		ret += suffix
+	}
+	return ret
+}
+
+// sanitizePromName replaces the characters not valid in Prometheus metric
+// and label names with _
+func sanitizePromName(name string) string {
+	var sb strings.Builder
+	for i, c := range name {
+		valid := c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (i > 0 && c >= '0' && c <= '9')
+		if valid {
+			sb.WriteRune(c)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
+
+// promLabels returns the label set for the attributes, with extra (like the
+// histogram le label) added at the end. The attribute set is sorted by key
+func promLabels(set attribute.Set, extra string) string {
+	if set.Len() == 0 && extra == "" {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	iter := set.Iter()
+	for iter.Next() {
+		kv := iter.Attribute()
+		if sb.Len() > 1 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(sanitizePromName(string(kv.Key)))
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabelValue(kv.Value.Emit()))
+		sb.WriteByte('"')
+	}
+	if extra != "" {
+		if sb.Len() > 1 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(extra)
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+var (
+	labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	helpReplacer       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
+
+func formatPromValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package telemetry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/openrundev/openrun/internal/types"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+func TestPrometheusHandler(t *testing.T) {
+	config := &types.ServerConfig{Telemetry: types.TelemetryConfig{Prometheus: true}}
+	providers, err := Setup(context.Background(), config, nil)
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	t.Cleanup(func() { _ = providers.Shutdown(context.Background()) })
+
+	if Enabled() {
+		t.Fatal("tracing should stay disabled with only the Prometheus endpoint enabled")
+	}
+	if !MetricsEnabled() || !providers.PrometheusEnabled() {
+		t.Fatal("metrics should be enabled for the Prometheus endpoint")
+	}
+
+	appAttrs := []attribute.KeyValue{
+		attribute.String("openrun.app.id", "app_prd_123"),
+		attribute.String("openrun.app.path", `/a"b`),
+	}
+	RecordAppResponse(context.Background(), 200, appAttrs...)
+	RecordAppResponse(context.Background(), 500, appAttrs...)
+	RecordAppRequestDuration(context.Background(), "/a/items/{id}", 200, time.Now().Add(-20*time.Millisecond), appAttrs...)
+	RecordStarlarkHandler(context.Background(), "handler", time.Now(), errors.New("boom"), appAttrs...)
+	RecordSyncRun(context.Background(), "scheduled", "success")
+	if err := RegisterDBPoolStats("metadata", func() sql.DBStats {
+		return sql.DBStats{MaxOpenConnections: 10, InUse: 2, Idle: 3, WaitCount: 4}
+	}); err != nil {
+		t.Fatalf("RegisterDBPoolStats: %v", err)
+	}
+	if err := RegisterContainerStates(func() []AppContainerState {
+		return []AppContainerState{{Attrs: appAttrs, State: "running"}}
+	}); err != nil {
+		t.Fatalf("RegisterContainerStates: %v", err)
+	}
+
+	handler := providers.MetricsHandler("")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != prometheusContentType {
+		t.Fatalf("content type = %q", got)
+	}
+
+	body := w.Body.String()
+	for _, want := range []string{
+		"# TYPE openrun_app_response_total counter",
+		`openrun_app_response_total{openrun_app_id="app_prd_123",openrun_app_path="/a\"b",openrun_response_status="5xx"} 1`,
+		"# TYPE openrun_app_request_duration_milliseconds histogram",
+		`openrun_app_request_duration_milliseconds_bucket{openrun_app_id="app_prd_123",openrun_app_path="/a\"b",openrun_app_route="/a/items/{id}",openrun_response_status="2xx",le="+Inf"} 1`,
+		`openrun_app_request_duration_milliseconds_count{openrun_app_id="app_prd_123",openrun_app_path="/a\"b",openrun_app_route="/a/items/{id}",openrun_response_status="2xx"} 1`,
+		`openrun_app_handler_duration_milliseconds_count{openrun_app_id="app_prd_123",openrun_app_path="/a\"b",openrun_error="true",openrun_handler="handler"} 1`,
+		`openrun_sync_run_total{openrun_sync_outcome="success",openrun_sync_trigger="scheduled"} 1`,
+		"# TYPE openrun_db_pool_connections gauge",
+		`openrun_db_pool_connections{openrun_db_name="metadata",openrun_db_state="in_use"} 2`,
+		`openrun_db_pool_connections{openrun_db_name="metadata",openrun_db_state="idle"} 3`,
+		`openrun_db_pool_max_open{openrun_db_name="metadata"} 10`,
+		`openrun_db_pool_wait_total{openrun_db_name="metadata"} 4`,
+		`openrun_app_container_state{openrun_app_id="app_prd_123",openrun_app_path="/a\"b",openrun_container_state="running"} 1`,
+	} {
+		if !strings.Contains(body, want+"\n") {
+			t.Errorf("missing %q in\n%s", want, body)
+		}
+	}
+
+	// Buckets are cumulative, the 20ms request is in the le=25 bucket but not in le=10
+	if !strings.Contains(body, `openrun_response_status="2xx",le="10"} 0`) || !strings.Contains(body, `openrun_response_status="2xx",le="25"} 1`) {
+		t.Errorf("unexpected histogram buckets in\n%s", body)
+	}
+}
+
+func TestPrometheusHandlerToken(t *testing.T) {
+	config := &types.ServerConfig{Telemetry: types.TelemetryConfig{Prometheus: true}}
+	providers, err := Setup(context.Background(), config, nil)
+	if err != nil {
+		t.Fatalf("Setup: %v", err)
+	}
+	t.Cleanup(func() { _ = providers.Shutdown(context.Background()) })
+
+	handler := providers.MetricsHandler("secret")
+	for _, tt := range []struct {
+		auth string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{"Bearer wrong", http.StatusUnauthorized},
+		{"Basic secret", http.StatusUnauthorized},
+		{"Bearer secret", http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("auth %q: status = %d, want %d", tt.auth, w.Code, tt.want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/metrics", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d", w.Code)
+	}
+
+	// Not enabled, the endpoint has nothing to serve
+	w = httptest.NewRecorder()
+	(&Providers{}).MetricsHandler("").ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("disabled status = %d", w.Code)
+	}
+}
+
+func TestPromMetricName(t *testing.T) {
+	tests := []struct {
+		name, unit, want string
+	}{
+		{"openrun.db.call.duration", "ms", "openrun_db_call_duration_milliseconds"},
+		{"http.server.request.duration", "s", "http_server_request_duration_seconds"},
+		{"openrun.app.proxy.bytes", "By", "openrun_app_proxy_bytes"},
+		{"openrun.app.request", "", "openrun_app_request"},
+		{"1st-metric", "1", "_st_metric"},
+	}
+	for _, tt := range tests {
+		if got := promMetricName(tt.name, tt.unit); got != tt.want {
+			t.Errorf("promMetricName(%q, %q) = %q, want %q", tt.name, tt.unit, got, tt.want)
+		}
+	}
+
+	if got := escapeLabelValue("a\\b\"c\nd"); got != `a\\b\"c\nd` {
+		t.Errorf("escapeLabelValue = %q", got)
+	}
+}
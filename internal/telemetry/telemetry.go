@@ -12,6 +12,7 @@ import (
 	"os"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/openrundev/openrun/internal/types"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
@@ -34,6 +35,7 @@ const instrumentationName = "github.com/openrundev/openrun"
 var (
 	enabled         atomic.Bool
 	pluginSpansOn   atomic.Bool
+	storeSpansOn    atomic.Bool
 	emptyPropagator = propagation.NewCompositeTextMapPropagator()
 )
 
@@ -42,6 +44,9 @@ var (
 type Providers struct {
 	tracerProvider *sdktrace.TracerProvider
 	meterProvider  *sdkmetric.MeterProvider
+	// prometheusReader is collected on each scrape of the Prometheus
+	// endpoint, nil when the endpoint is not enabled
+	prometheusReader *sdkmetric.ManualReader
 }
 
 // Enabled reports whether telemetry is currently active.
@@ -56,17 +61,26 @@ func PluginSpansEnabled() bool {
 	return pluginSpansOn.Load()
 }
 
+// StoreSpansEnabled reports whether a span should be created for each store
+// plugin query. Gated separately like the plugin spans.
+func StoreSpansEnabled() bool {
+	return storeSpansOn.Load()
+}
+
 // Setup initializes OpenTelemetry providers based on the server config. It
 // always returns a non-nil Providers value: when telemetry is disabled or when
 // initialization fails, Shutdown becomes a no-op and the helper functions
-// short-circuit through Enabled().
+// short-circuit through Enabled(). The Prometheus endpoint only needs the
+// meter provider, so metrics are recorded when either the OTLP metrics export
+// or the Prometheus endpoint is enabled.
 func Setup(ctx context.Context, config *types.ServerConfig, logger *types.Logger) (*Providers, error) {
 	providers := &Providers{}
 	enabled.Store(false)
 	pluginSpansOn.Store(false)
+	storeSpansOn.Store(false)
 	metricsEnabled.Store(false)
 
-	if config == nil || !config.Telemetry.Enabled {
+	if config == nil || (!config.Telemetry.Enabled && !config.Telemetry.Prometheus) {
 		return providers, nil
 	}
 
@@ -81,6 +95,23 @@ func Setup(ctx context.Context, config *types.ServerConfig, logger *types.Logger
 		}))
 	}
 
+	if config.Telemetry.Prometheus {
+		providers.prometheusReader = sdkmetric.NewManualReader()
+	}
+	if !config.Telemetry.Enabled {
+		setupMeterProvider(providers, res, providers.prometheusReader)
+		if logger != nil {
+			logger.Info().Str("address", config.Telemetry.PrometheusAddress).Msg("Prometheus metrics enabled, OpenTelemetry export is disabled")
+		}
+		return providers, nil
+	}
+
+	switch config.Telemetry.Compression {
+	case "", "gzip", "none":
+	default:
+		return providers, fmt.Errorf("invalid telemetry.compression %q, expected gzip or none", config.Telemetry.Compression)
+	}
+
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
 		propagation.TraceContext{},
 		propagation.Baggage{},
@@ -94,38 +125,58 @@ func Setup(ctx context.Context, config *types.ServerConfig, logger *types.Logger
 		tp := sdktrace.NewTracerProvider(
 			sdktrace.WithBatcher(traceExporter),
 			sdktrace.WithResource(res),
+			sdktrace.WithSampler(traceSampler(config.Telemetry.SampleRatio)),
 		)
 		providers.tracerProvider = tp
 		otel.SetTracerProvider(tp)
 	}
 
+	readers := []sdkmetric.Reader{}
 	if config.Telemetry.Metrics {
 		metricExporter, err := otlpmetrichttp.New(ctx, metricExporterOptions(config)...)
 		if err != nil {
 			return providers, fmt.Errorf("initialize OpenTelemetry metric exporter: %w", err)
 		}
-		mp := sdkmetric.NewMeterProvider(
-			sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter)),
-			sdkmetric.WithResource(res),
-		)
-		providers.meterProvider = mp
-		otel.SetMeterProvider(mp)
-		metricsEnabled.Store(true)
+		readers = append(readers, sdkmetric.NewPeriodicReader(metricExporter))
+	}
+	if providers.prometheusReader != nil {
+		readers = append(readers, providers.prometheusReader)
 	}
+	setupMeterProvider(providers, res, readers...)
 
 	enabled.Store(true)
 	pluginSpansOn.Store(config.Telemetry.PluginSpans)
+	storeSpansOn.Store(config.Telemetry.StoreSpans)
 	if logger != nil {
 		logger.Info().
 			Bool("traces", config.Telemetry.Traces).
 			Bool("metrics", config.Telemetry.Metrics).
+			Bool("prometheus", config.Telemetry.Prometheus).
 			Bool("plugin_spans", config.Telemetry.PluginSpans).
+			Bool("store_spans", config.Telemetry.StoreSpans).
+			Float64("sample_ratio", config.Telemetry.SampleRatio).
 			Str("endpoint", config.Telemetry.Endpoint).
 			Msg("OpenTelemetry enabled")
 	}
 	return providers, nil
 }
 
+// setupMeterProvider creates the meter provider with the readers and enables
+// the metric recording. No-op when there are no readers.
+func setupMeterProvider(providers *Providers, res *resource.Resource, readers ...sdkmetric.Reader) {
+	if len(readers) == 0 {
+		return
+	}
+	options := []sdkmetric.Option{sdkmetric.WithResource(res)}
+	for _, reader := range readers {
+		options = append(options, sdkmetric.WithReader(reader))
+	}
+	mp := sdkmetric.NewMeterProvider(options...)
+	providers.meterProvider = mp
+	otel.SetMeterProvider(mp)
+	metricsEnabled.Store(true)
+}
+
 // Shutdown flushes and closes the SDK providers. It is safe to call on a
 // disabled or partially-initialized Providers value.
 func (p *Providers) Shutdown(ctx context.Context) error {
@@ -134,6 +185,7 @@ func (p *Providers) Shutdown(ctx context.Context) error {
 	}
 	enabled.Store(false)
 	pluginSpansOn.Store(false)
+	storeSpansOn.Store(false)
 	metricsEnabled.Store(false)
 
 	var err error
@@ -310,8 +362,18 @@ func RequestAttributes(r *http.Request) []attribute.KeyValue {
 	return attrs
 }
 
+// traceSampler returns the sampler for the configured ratio. The root spans are
+// sampled by the ratio, child spans follow the parent's sampling decision. A
+// ratio of zero (not set) or one and above samples all traces.
+func traceSampler(ratio float64) sdktrace.Sampler {
+	if ratio <= 0 || ratio >= 1 {
+		return sdktrace.ParentBased(sdktrace.AlwaysSample())
+	}
+	return sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))
+}
+
 func traceExporterOptions(config *types.ServerConfig) []otlptracehttp.Option {
-	opts := make([]otlptracehttp.Option, 0, 2)
+	opts := make([]otlptracehttp.Option, 0, 4)
 	if endpoint := parseOTLPEndpoint(config.Telemetry.Endpoint); endpoint.configured() {
 		if endpoint.fullURL != "" {
 			opts = append(opts, otlptracehttp.WithEndpointURL(endpoint.fullURL))
@@ -325,11 +387,17 @@ func traceExporterOptions(config *types.ServerConfig) []otlptracehttp.Option {
 	if len(config.Telemetry.Headers) > 0 {
 		opts = append(opts, otlptracehttp.WithHeaders(config.Telemetry.Headers))
 	}
+	if config.Telemetry.Compression == "gzip" {
+		opts = append(opts, otlptracehttp.WithCompression(otlptracehttp.GzipCompression))
+	}
+	if config.Telemetry.ExportTimeoutSecs > 0 {
+		opts = append(opts, otlptracehttp.WithTimeout(time.Duration(config.Telemetry.ExportTimeoutSecs)*time.Second))
+	}
 	return opts
 }
 
 func metricExporterOptions(config *types.ServerConfig) []otlpmetrichttp.Option {
-	opts := make([]otlpmetrichttp.Option, 0, 2)
+	opts := make([]otlpmetrichttp.Option, 0, 4)
 	if endpoint := parseOTLPEndpoint(config.Telemetry.Endpoint); endpoint.configured() {
 		if endpoint.fullURL != "" {
 			opts = append(opts, otlpmetrichttp.WithEndpointURL(endpoint.fullURL))
@@ -343,6 +411,12 @@ func metricExporterOptions(config *types.ServerConfig) []otlpmetrichttp.Option {
 	if len(config.Telemetry.Headers) > 0 {
 		opts = append(opts, otlpmetrichttp.WithHeaders(config.Telemetry.Headers))
 	}
+	if config.Telemetry.Compression == "gzip" {
+		opts = append(opts, otlpmetrichttp.WithCompression(otlpmetrichttp.GzipCompression))
+	}
+	if config.Telemetry.ExportTimeoutSecs > 0 {
+		opts = append(opts, otlpmetrichttp.WithTimeout(time.Duration(config.Telemetry.ExportTimeoutSecs)*time.Second))
+	}
 	return opts
 }
 
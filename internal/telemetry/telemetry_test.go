@@ -8,6 +8,7 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/openrundev/openrun/internal/types"
@@ -64,6 +65,7 @@ func TestEnabledTelemetryCanPropagateWithoutExporters(t *testing.T) {
 			Traces:      false,
 			Metrics:     false,
 			PluginSpans: true,
+			StoreSpans:  true,
 		},
 	}, nil)
 	if err != nil {
@@ -81,6 +83,9 @@ func TestEnabledTelemetryCanPropagateWithoutExporters(t *testing.T) {
 	if !PluginSpansEnabled() {
 		t.Fatalf("plugin spans should be enabled")
 	}
+	if !StoreSpansEnabled() {
+		t.Fatalf("store spans should be enabled")
+	}
 
 	transport := http.DefaultTransport
 	if got := WrapTransport(transport); got == transport {
@@ -487,3 +492,47 @@ func assertAttrInt(t *testing.T, attrs map[string]attribute.Value, key string, w
 		t.Fatalf("attr %q mismatch: got %d, want %d", key, got.AsInt64(), want)
 	}
 }
+
+func TestTraceSampler(t *testing.T) {
+	// The ratio sampler uses the lower half of the trace id
+	traceID := trace.TraceID{8: 0xff, 9: 0xff, 10: 0xff, 11: 0xff, 12: 0xff, 13: 0xff, 14: 0xff, 15: 0xff}
+	root := sdktrace.SamplingParameters{ParentContext: context.Background(), TraceID: traceID}
+	for _, ratio := range []float64{0, 1, 2} {
+		if got := traceSampler(ratio).ShouldSample(root).Decision; got != sdktrace.RecordAndSample {
+			t.Errorf("ratio %v: want all traces sampled, got %v", ratio, got)
+		}
+	}
+
+	// A low ratio drops the root span, a child of a sampled parent is still sampled
+	sampler := traceSampler(0.0001)
+	if got := sampler.ShouldSample(root).Decision; got != sdktrace.Drop {
+		t.Errorf("want root span dropped, got %v", got)
+	}
+	parent := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     trace.SpanID{1},
+		TraceFlags: trace.FlagsSampled,
+	}))
+	child := sdktrace.SamplingParameters{ParentContext: parent, TraceID: traceID}
+	if got := sampler.ShouldSample(child).Decision; got != sdktrace.RecordAndSample {
+		t.Errorf("want child of sampled parent sampled, got %v", got)
+	}
+}
+
+func TestInvalidCompression(t *testing.T) {
+	providers, err := Setup(context.Background(), &types.ServerConfig{
+		Telemetry: types.TelemetryConfig{Enabled: true, Compression: "zstd"},
+	}, nil)
+	defer providers.Shutdown(context.Background()) //nolint:errcheck
+	if err == nil || !strings.Contains(err.Error(), "invalid telemetry.compression") {
+		t.Fatalf("want compression error, got %v", err)
+	}
+
+	config := &types.ServerConfig{Telemetry: types.TelemetryConfig{Compression: "gzip", ExportTimeoutSecs: 5}}
+	if got := len(traceExporterOptions(config)); got != 2 {
+		t.Errorf("want compression and timeout trace options, got %d", got)
+	}
+	if got := len(metricExporterOptions(config)); got != 2 {
+		t.Errorf("want compression and timeout metric options, got %d", got)
+	}
+}
@@ -4,6 +4,7 @@
 package types
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
@@ -14,6 +15,9 @@ import (
 type RequestError struct {
 	Message string `json:"message"`
 	Code    int    `json:"code"`
+	// CurrentVersion is the app row version, set for version conflict errors
+	CurrentVersion int64 `json:"current_version,omitempty"`
+	Retriable      bool  `json:"retriable,omitempty"`
 }
 
 func CreateRequestError(message string, code int) RequestError {
@@ -23,6 +27,37 @@ func CreateRequestError(message string, code int) RequestError {
 	}
 }
 
+// CreateVersionConflictError returns the error for an app update which conflicts with a concurrent
+// update. The update can be retried after reading the app again
+func CreateVersionConflictError(app string, expectedVersion, currentVersion int64) RequestError {
+	return RequestError{
+		Message: fmt.Sprintf("app %s was updated concurrently, expected version %d, current version %d. Retry the update",
+			app, expectedVersion, currentVersion),
+		Code:           http.StatusConflict,
+		CurrentVersion: currentVersion,
+		Retriable:      true,
+	}
+}
+
+// CreateAppLockedError returns the error for an app operation which conflicts with an operation in
+// progress on the app, which holds the app lock
+func CreateAppLockedError(lock *AppLock) RequestError {
+	return RequestError{
+		Message: fmt.Sprintf("app %s is locked by operation %s for user %s on %s since %s. Retry after the operation completes, "+
+			"or remove the lock if it is stale using \"openrun app unlock %s\"",
+			lock.Path, lock.Operation, lock.UserId, lock.Hostname, lock.AcquireTime.Format(time.RFC3339), lock.Path),
+		Code:      http.StatusConflict,
+		Retriable: true,
+	}
+}
+
+// IsRetriableConflict returns true if the error is an app version conflict error or an app locked
+// error, the operation can be retried
+func IsRetriableConflict(err error) bool {
+	var reqError RequestError
+	return errors.As(err, &reqError) && reqError.Code == http.StatusConflict && reqError.Retriable
+}
+
 func (r RequestError) Error() string {
 	if r.Message == "" {
 		return fmt.Sprintf("status code %d", r.Code)
@@ -40,6 +75,8 @@ type CreateAppRequest struct {
 	AppAuthn         AppAuthnType      `json:"app_authn"`
 	GitBranch        string            `json:"git_branch"`
 	GitCommit        string            `json:"git_commit"`
+	GitTag           string            `json:"git_tag,omitempty"`         // tag or semver constraint, instead of branch and commit
+	SourceChecksum   string            `json:"source_checksum,omitempty"` // sha256 of the archive, for archive url sources
 	GitAuthName      string            `json:"git_auth_name"`
 	Spec             AppSpec           `json:"spec"`
 	ParamValues      map[string]string `json:"param_values"`
@@ -50,6 +87,13 @@ type CreateAppRequest struct {
 	Bindings         []string          `json:"bindings"`
 	StageAt          string            `json:"stage_at"`
 	Verify           bool              `json:"verify"`
+	// Image creates the app from a prebuilt image using the image spec, with no source
+	// checkout or build. PinDigest resolves the image tag to its digest at create time
+	Image     string `json:"image,omitempty"`
+	PinDigest bool   `json:"pin_digest,omitempty"`
+	// ParamsOnly returns the param definitions for the app, without creating the app. Used
+	// by the interactive create to prompt for the param values
+	ParamsOnly bool `json:"params_only,omitempty"`
 	// fields supported by declarative apply must be merged in applyAppUpdate
 }
 
@@ -116,6 +160,27 @@ type AppExportResponse struct {
 	Config string `json:"config"`
 }
 
+// GraphNode is a node in the dependency graph, an app or a resource used by apps. The id is
+// the type and the name, like secret:db/api_key
+type GraphNode struct {
+	Id   string `json:"id"`
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+// GraphEdge is a dependency in the graph, From depends on To
+type GraphEdge struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Type string `json:"type"`
+}
+
+// DependencyGraph is the graph of the dependencies between apps and the resources they use
+type DependencyGraph struct {
+	Nodes []GraphNode `json:"nodes"`
+	Edges []GraphEdge `json:"edges"`
+}
+
 // UpdateBindingRequest is the request body for updating a binding. Binding
 // updates are limited to grant changes.
 type UpdateBindingRequest struct {
@@ -140,18 +205,115 @@ type ApproveResult struct {
 	NewPermissions      []Permission  `json:"new_permissions"`
 	ApprovedLoads       []string      `json:"approved_loads"`
 	ApprovedPermissions []Permission  `json:"approved_permissions"`
+	NewEnv              []string      `json:"new_env"`
+	ApprovedEnv         []string      `json:"approved_env"`
 	NeedsApproval       bool          `json:"needs_approval"`
+
+	// The permissions declared by the app spec, set when the spec declares them. The undeclared
+	// entries are the loads and permissions used by the app which are not in the spec bundle
+	Spec                  AppSpec      `json:"spec,omitempty"`
+	SpecLoads             []string     `json:"spec_loads,omitempty"`
+	SpecPermissions       []Permission `json:"spec_permissions,omitempty"`
+	UndeclaredLoads       []string     `json:"undeclared_loads,omitempty"`
+	UndeclaredPermissions []Permission `json:"undeclared_permissions,omitempty"`
 }
 
 type AppResponse struct {
 	AppEntry
-	StagedChanges bool `json:"staged_changes"`
+	StagedChanges bool            `json:"staged_changes"`
+	StartTiming   *AppStartTiming `json:"start_timing,omitempty"` // set for the detail listing, if the app is loaded
+}
+
+// AppStartTiming is the time taken by the phases of the last app load on the server node, in
+// milliseconds. Reload is false for the cold start of the app. The container start time includes
+// the time for the image build and the health check. The template parse time includes the style
+// setup and, for dev apps, the generation of the HTML files
+type AppStartTiming struct {
+	Time             time.Time `json:"time"`
+	Reload           bool      `json:"reload"`
+	SourceLoadMs     float64   `json:"source_load_ms"`
+	StarlarkInitMs   float64   `json:"starlark_init_ms"`
+	TemplateParseMs  float64   `json:"template_parse_ms"`
+	ContainerStartMs float64   `json:"container_start_ms"`
+	TotalMs          float64   `json:"total_ms"`
+}
+
+// ImageVersion is an app image version generated by OpenRun. Error is set if the image prune
+// could not remove the image
+type ImageVersion struct {
+	Name    string    `json:"name"`
+	ID      string    `json:"id"`
+	Created time.Time `json:"created"`
+	InUse   bool      `json:"in_use"`
+	Error   string    `json:"error,omitempty"`
+}
+
+// ImagePruneResponse is the response for the image prune API, Keep is the number of versions kept per app
+type ImagePruneResponse struct {
+	DryRun  bool           `json:"dry_run"`
+	Keep    int            `json:"keep"`
+	Kept    []ImageVersion `json:"kept"`
+	Removed []ImageVersion `json:"removed"`
+}
+
+// AppVolume is a container volume created by OpenRun for an app. Dir is the volume name or the
+// mount path from the app config, set if the app is loaded. AppPath is empty and Orphaned is
+// set if the app was deleted. Error is set if the volume garbage collection could not remove it
+type AppVolume struct {
+	Name     string `json:"name"`
+	AppId    AppId  `json:"app_id"`
+	AppPath  string `json:"app_path"`
+	Dir      string `json:"dir"`
+	InUse    bool   `json:"in_use"`
+	Orphaned bool   `json:"orphaned"`
+	Error    string `json:"error,omitempty"`
+}
+
+type VolumeListResponse struct {
+	Volumes []AppVolume `json:"volumes"`
+}
+
+// VolumeBackupResponse is the response for the volume backup API, Location is the backup file
+// path on the server or the s3:// url of the backup
+type VolumeBackupResponse struct {
+	Volume   string `json:"volume"`
+	Location string `json:"location"`
+	Size     int64  `json:"size"`
+}
+
+type VolumeRestoreResponse struct {
+	Volume string `json:"volume"`
+	Source string `json:"source"`
+}
+
+// VolumeGCResponse is the response for the volume garbage collection API. Removed has the
+// volumes of deleted apps, Kept has the ones which were not removed since they are in use
+type VolumeGCResponse struct {
+	DryRun  bool        `json:"dry_run"`
+	Removed []AppVolume `json:"removed"`
+	Kept    []AppVolume `json:"kept"`
+}
+
+// AppExecResult is sent as the last frame of the app exec stream. Error is set if the command
+// could not be run, else ExitCode is the command exit code
+type AppExecResult struct {
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
 }
 
 type AppListResponse struct {
 	Apps []AppResponse `json:"apps"`
 }
 
+// AppSearchResponse has one page of the apps matching a search query, ranked by relevance.
+// Total is the count of the matching apps across all the pages
+type AppSearchResponse struct {
+	Apps   []AppResponse `json:"apps"`
+	Total  int           `json:"total"`
+	Offset int           `json:"offset"`
+	Limit  int           `json:"limit"`
+}
+
 type AppCreateResponse struct {
 	AppPathDomain  AppPathDomain   `json:"app_path_domain"`
 	DryRun         bool            `json:"dry_run"`
@@ -160,6 +322,42 @@ type AppCreateResponse struct {
 	ApproveResults []ApproveResult `json:"approve_results"`
 	OrigSourceUrl  string          `json:"orig_source_url"`
 	SourceUrl      string          `json:"source_url"`
+	Image          string          `json:"image,omitempty"`
+	Params         []AppParamInfo  `json:"params,omitempty"`
+}
+
+// AppParamInfo is the definition of an app param, from params.star
+type AppParamInfo struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Type        string   `json:"type"`
+	Required    bool     `json:"required"`
+	HasDefault  bool     `json:"has_default"`
+	Default     string   `json:"default"`
+	DisplayType string   `json:"display_type"`
+	Values      []string `json:"values,omitempty"`
+	Regex       string   `json:"regex,omitempty"`
+}
+
+// AppLock is the advisory lock on an app, held while an operation like reload, promote, apply or
+// delete is running on the app. The lock expires if it is not renewed by the holder
+type AppLock struct {
+	Path        string    `json:"path"`
+	HolderId    string    `json:"holder_id"` // the server id and the request id
+	Hostname    string    `json:"hostname"`
+	Operation   string    `json:"operation"`
+	UserId      string    `json:"user_id"`
+	AcquireTime time.Time `json:"acquire_time"`
+	ExpireTime  time.Time `json:"expire_time"`
+}
+
+type AppLockListResponse struct {
+	Locks []AppLock `json:"locks"`
+}
+
+type AppUnlockResponse struct {
+	DryRun   bool      `json:"dry_run"`
+	Unlocked []AppLock `json:"unlocked"`
 }
 
 type AppDeleteResponse struct {
@@ -222,8 +420,40 @@ type AppApplyResponse struct {
 }
 
 type AppPromoteResponse struct {
-	DryRun         bool            `json:"dry_run"`
-	PromoteResults []AppPathDomain `json:"promote_results"`
+	DryRun         bool             `json:"dry_run"`
+	PromoteResults []AppPathDomain  `json:"promote_results"`
+	Diffs          []AppPromoteDiff `json:"diffs,omitempty"`
+}
+
+// AppPromoteDiff is the difference between the stage app and the prod app, computed before promote
+type AppPromoteDiff struct {
+	AppPathDomain AppPathDomain   `json:"app_path_domain"`
+	Changes       []PromoteChange `json:"changes"`
+	// ConfirmRequired is set when there are permission or container changes, promote requires a
+	// confirmation for those
+	ConfirmRequired bool `json:"confirm_required"`
+}
+
+// PromoteChange is a change in the prod app which promote would make
+type PromoteChange struct {
+	Op   string `json:"op"`   // + for added, - for removed, ~ for changed
+	Kind string `json:"kind"` // files, params, app_config, permissions, env, container_options, container_args, container_volumes, spec or spec_files
+	Name string `json:"name"`
+	Old  string `json:"old,omitempty"` // the prod value
+	New  string `json:"new,omitempty"` // the stage value
+}
+
+func (c PromoteChange) String() string {
+	ret := fmt.Sprintf("%s %s %s", c.Op, c.Kind, c.Name)
+	switch {
+	case c.Old != "" && c.New != "":
+		ret += fmt.Sprintf(": %s -> %s", c.Old, c.New)
+	case c.New != "":
+		ret += ": " + c.New
+	case c.Old != "":
+		ret += ": " + c.Old
+	}
+	return ret
 }
 
 type AppUpdateSettingsResponse struct {
@@ -231,6 +461,14 @@ type AppUpdateSettingsResponse struct {
 	UpdateResults []AppPathDomain `json:"update_results"`
 }
 
+// AppArchiveResponse is the response for the app archive API. BundlePaths has the bundle file
+// written for each archived main app, it is empty when the archive is undone
+type AppArchiveResponse struct {
+	DryRun        bool              `json:"dry_run"`
+	UpdateResults []AppPathDomain   `json:"update_results"`
+	BundlePaths   map[string]string `json:"bundle_paths"`
+}
+
 type AppPreviewResponse struct {
 	DryRun        bool          `json:"dry_run"`
 	HttpUrl       string        `json:"http_url"`
@@ -288,12 +526,546 @@ type TokenDeleteResponse struct {
 	DryRun bool `json:"dry_run"`
 }
 
+type JobListResponse struct {
+	Jobs []*JobEntry `json:"jobs"`
+}
+
+// CronStatus is the schedule and the last run status of a scheduled task of an app
+type CronStatus struct {
+	CronDef
+	LastRunTime *time.Time `json:"last_run_time,omitempty"`
+	NextRunTime *time.Time `json:"next_run_time,omitempty"`
+	SkipCount   int        `json:"skip_count"`
+	LastJob     *JobEntry  `json:"last_job,omitempty"`
+}
+
+type CronListResponse struct {
+	Crons []CronStatus `json:"crons"`
+}
+
+const (
+	AppConfigSourceServer        = "server"   // the server app_config, including the dynamic config settings
+	AppConfigSourceApp           = "app"      // the per-app config set with app update conf
+	AppConfigSourceTagPrefix     = "tag:"     // the tag_config entry for an app tag, like tag:internal
+	AppConfigSourceProfilePrefix = "profile:" // the security profile, like profile:strict
+)
+
+// AppConfigValue is an app config key with its value, in the TOML format, and the config layer
+// which set the value
+type AppConfigValue struct {
+	Key    string `json:"key"`
+	Value  string `json:"value"`
+	Source string `json:"source"`
+}
+
+type AppConfigResponse struct {
+	AppPathDomain AppPathDomain    `json:"app_path_domain"`
+	Effective     bool             `json:"effective"`
+	Values        []AppConfigValue `json:"values"`
+}
+
+const (
+	AppLogSourceHandler   = "handler"   // app logs and print output from the handlers
+	AppLogSourceError     = "error"     // app logs at warn level and above
+	AppLogSourceContainer = "container" // container stdout and stderr
+)
+
+// ResourceLimits are the resource limits for an app container, zero means no limit
+type ResourceLimits struct {
+	CpuMilli    int64 `json:"cpu_milli"`
+	MemoryBytes int64 `json:"memory_bytes"`
+	Pids        int64 `json:"pids"`
+}
+
+// AppContainerStats is the current resource usage of an app or service container, as reported
+// by the container manager
+type AppContainerStats struct {
+	Name       string `json:"name"`
+	CPUPercent string `json:"cpu_percent"`
+	MemUsage   string `json:"mem_usage"`
+	MemPercent string `json:"mem_percent"`
+	PIDs       string `json:"pids"`
+}
+
+// AppStatsResponse is the response for the app stats API. The limits and the usage are for the
+// server which handled the request. TotalQuota is zero for the resources without a quota
+type AppStatsResponse struct {
+	AppPathDomain AppPathDomain       `json:"app_path_domain"`
+	Limits        ResourceLimits      `json:"limits"`
+	Containers    []AppContainerStats `json:"containers"`
+	TotalUsed     ResourceLimits      `json:"total_used"`  // limits summed over the apps on the server
+	TotalQuota    ResourceLimits      `json:"total_quota"` // the container_quota totals
+}
+
+// AppLogEntry is a line in the merged app logs stream
+type AppLogEntry struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Level   string    `json:"level,omitempty"`
+	Message string    `json:"message"`
+}
+
+// CaptureEntry is one captured request/response pair. Sensitive headers and
+// query/form values are redacted before the entry is stored. Path is relative
+// to the app path, so the entry can be replayed against another app
+type CaptureEntry struct {
+	Time                  time.Time   `json:"time"`
+	Method                string      `json:"method"`
+	Path                  string      `json:"path"`
+	Query                 string      `json:"query"`
+	RequestHeaders        http.Header `json:"request_headers"`
+	RequestBody           []byte      `json:"request_body,omitempty"`
+	RequestBodyTruncated  bool        `json:"request_body_truncated"`
+	Status                int         `json:"status"`
+	ResponseHeaders       http.Header `json:"response_headers"`
+	ResponseBody          []byte      `json:"response_body,omitempty"`
+	ResponseBodyTruncated bool        `json:"response_body_truncated"`
+	DurationMs            int64       `json:"duration_ms"`
+}
+
+// CaptureStatus is the state of the traffic capture for an app on the server node
+// which handled the API call
+type CaptureStatus struct {
+	AppPath      string    `json:"app_path"`
+	Active       bool      `json:"active"`
+	StartTime    time.Time `json:"start_time"`
+	EndTime      time.Time `json:"end_time"`
+	MaxEntries   int       `json:"max_entries"`
+	MaxBodyBytes int       `json:"max_body_bytes"`
+	EntryCount   int       `json:"entry_count"`
+	Dropped      int       `json:"dropped"`
+}
+
+type CaptureDownloadResponse struct {
+	Status  CaptureStatus  `json:"status"`
+	Entries []CaptureEntry `json:"entries"`
+}
+
+// CaptureReplayResult is the outcome of replaying one captured request
+type CaptureReplayResult struct {
+	Method         string `json:"method"`
+	Path           string `json:"path"`
+	CapturedStatus int    `json:"captured_status"`
+	ReplayStatus   int    `json:"replay_status"`
+	BodyMatch      bool   `json:"body_match"`
+	Skipped        bool   `json:"skipped"`
+	Error          string `json:"error,omitempty"`
+}
+
+type CaptureReplayResponse struct {
+	StageAppPath string                `json:"stage_app_path"`
+	Matched      int                   `json:"matched"`
+	Mismatched   int                   `json:"mismatched"`
+	Skipped      int                   `json:"skipped"`
+	Results      []CaptureReplayResult `json:"results"`
+}
+
+// ProfileFunction is the time spent in a Starlark function. Self is the time with the function at the
+// top of the stack, total includes the time in the functions and plugins it called
+type ProfileFunction struct {
+	Name    string  `json:"name"`
+	SelfMs  float64 `json:"self_ms"`
+	TotalMs float64 `json:"total_ms"`
+}
+
+// ProfilePluginCall is the time spent in the calls to a plugin function
+type ProfilePluginCall struct {
+	Name    string  `json:"name"`
+	Count   int     `json:"count"`
+	TotalMs float64 `json:"total_ms"`
+	MaxMs   float64 `json:"max_ms"`
+}
+
+// HandlerProfile is the profile for one sampled request. Stacks has the time in microseconds for
+// each folded stack, the frames are separated by ";", as used by the flame graph tools
+type HandlerProfile struct {
+	Time        time.Time           `json:"time"`
+	Method      string              `json:"method"`
+	Path        string              `json:"path"`
+	Handler     string              `json:"handler"`
+	DurationMs  float64             `json:"duration_ms"`
+	Samples     int                 `json:"samples"`
+	Functions   []ProfileFunction   `json:"functions"`
+	PluginCalls []ProfilePluginCall `json:"plugin_calls"`
+	Stacks      map[string]int64    `json:"stacks"`
+}
+
+// ProfileStatus is the state of the handler profiling for an app on the server node which
+// handled the API call
+type ProfileStatus struct {
+	AppPath      string    `json:"app_path"`
+	Active       bool      `json:"active"`
+	StartTime    time.Time `json:"start_time"`
+	EndTime      time.Time `json:"end_time"`
+	SampleRate   float64   `json:"sample_rate"`
+	MaxProfiles  int       `json:"max_profiles"`
+	ProfileCount int       `json:"profile_count"`
+	Sampled      int       `json:"sampled"`
+}
+
+// ProfileDownloadResponse has the recorded profiles, slowest first. Merged has the time added up
+// across all the profiles
+type ProfileDownloadResponse struct {
+	Status   ProfileStatus    `json:"status"`
+	Merged   HandlerProfile   `json:"merged"`
+	Profiles []HandlerProfile `json:"profiles"`
+}
+
+// E2ERequest is the request for running an end-to-end test script against an app. Snapshots
+// has the expected HTML snapshots, keyed by snapshot name
+type E2ERequest struct {
+	ScriptName string            `json:"script_name"`
+	Script     string            `json:"script"`
+	Snapshots  map[string]string `json:"snapshots"`
+	Filter     string            `json:"filter"`           // glob pattern for the test function names to run
+	Update     bool              `json:"update_snapshots"` // snapshot mismatches do not fail the test
+}
+
+type E2ESnapshotStatus string
+
+const (
+	E2ESnapshotMatch    E2ESnapshotStatus = "match"
+	E2ESnapshotMismatch E2ESnapshotStatus = "mismatch"
+	E2ESnapshotNew      E2ESnapshotStatus = "new"
+)
+
+// E2ESnapshotResult is the result of a snapshot comparison. Actual is set when the
+// snapshot does not match, so that the client can update the snapshot file
+type E2ESnapshotResult struct {
+	Name   string            `json:"name"`
+	Status E2ESnapshotStatus `json:"status"`
+	Actual string            `json:"actual,omitempty"`
+}
+
+type E2ETestResult struct {
+	Name       string              `json:"name"`
+	Passed     bool                `json:"passed"`
+	Error      string              `json:"error,omitempty"`
+	Assertions int                 `json:"assertions"`
+	Requests   int                 `json:"requests"`
+	DurationMs int64               `json:"duration_ms"`
+	Snapshots  []E2ESnapshotResult `json:"snapshots,omitempty"`
+}
+
+type E2EResponse struct {
+	AppPath string          `json:"app_path"`
+	Passed  int             `json:"passed"`
+	Failed  int             `json:"failed"`
+	Results []E2ETestResult `json:"results"`
+}
+
+// AppTestResult is the result of one Starlark unit test function, from a *_test.star file
+type AppTestResult struct {
+	Name       string `json:"name"`
+	File       string `json:"file"`
+	Passed     bool   `json:"passed"`
+	Error      string `json:"error,omitempty"`
+	Assertions int    `json:"assertions"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// ReplMode is how plugin calls are handled in an app REPL session
+type ReplMode string
+
+const (
+	ReplModeReadOnly ReplMode = "read_only" // read plugin calls are run, write calls fail
+	ReplModeLive     ReplMode = "live"      // all plugin calls are run
+	ReplModeDryRun   ReplMode = "dry_run"   // plugin calls are printed without running them
+)
+
+// AppReplRequest is the input for an app REPL session, one statement or expression
+type AppReplRequest struct {
+	SessionId string `json:"session_id"`
+	Input     string `json:"input"`
+}
+
+// AppReplResponse is the result of evaluating the REPL input. Output has the text printed,
+// Result is the value of an expression, empty for statements and None
+type AppReplResponse struct {
+	SessionId string `json:"session_id"`
+	Output    string `json:"output"`
+	Result    string `json:"result"`
+	Error     string `json:"error,omitempty"`
+}
+
+// DebugBreakpoint is a breakpoint in a Starlark file of a dev app. File is relative to the app source root
+type DebugBreakpoint struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// DebugVariable is a variable in a paused Starlark frame, the value is the Starlark string representation
+type DebugVariable struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// DebugFrame is one frame of the Starlark call stack of a paused handler, innermost frame first
+type DebugFrame struct {
+	Function string          `json:"function"`
+	File     string          `json:"file"`
+	Line     int             `json:"line"`
+	Locals   []DebugVariable `json:"locals"`
+}
+
+// DebugStop is a handler invocation paused at a breakpoint
+type DebugStop struct {
+	Id       int          `json:"id"`
+	File     string       `json:"file"`
+	Line     int          `json:"line"`
+	Frames   []DebugFrame `json:"frames"`
+	PausedAt time.Time    `json:"paused_at"`
+}
+
+// DebugState is the debugger state of a dev app, the breakpoints set and the paused handlers
+type DebugState struct {
+	Breakpoints []DebugBreakpoint `json:"breakpoints"`
+	Stops       []DebugStop       `json:"stops"`
+}
+
+// GoldenFixture is the data for rendering one HTML route in a golden file test. Path is the route
+// path as declared in the app, with UrlParams giving the values for the path parameters. Data is
+// passed to the template as the handler response
+type GoldenFixture struct {
+	Name      string            `json:"name"`
+	Path      string            `json:"path"`
+	Method    string            `json:"method"`
+	Partial   bool              `json:"partial"`
+	UrlParams map[string]string `json:"url_params"`
+	Query     map[string]string `json:"query"`
+	UserId    string            `json:"user_id"`
+	Data      any               `json:"data"`
+}
+
+// GoldenRequest is the request for rendering the HTML routes of an app with fixture data and
+// comparing the output with the golden files, keyed by fixture name
+type GoldenRequest struct {
+	Fixtures []GoldenFixture   `json:"fixtures"`
+	Golden   map[string]string `json:"golden"`
+	Ignore   []string          `json:"ignore"` // regexes for the parts of the output to mask before comparing
+	Update   bool              `json:"update_golden"`
+}
+
+type GoldenStatus string
+
+const (
+	GoldenMatch    GoldenStatus = "match"
+	GoldenMismatch GoldenStatus = "mismatch"
+	GoldenNew      GoldenStatus = "new"
+	GoldenError    GoldenStatus = "error"
+)
+
+// GoldenResult is the result for one fixture. Actual is set when the output does not match, so
+// that the client can update the golden file. Diff is a line diff of the normalized HTML
+type GoldenResult struct {
+	Name   string       `json:"name"`
+	Route  string       `json:"route"`
+	Status GoldenStatus `json:"status"`
+	Diff   string       `json:"diff,omitempty"`
+	Error  string       `json:"error,omitempty"`
+	Actual string       `json:"actual,omitempty"`
+}
+
+type GoldenResponse struct {
+	AppPath   string         `json:"app_path"`
+	Passed    int            `json:"passed"`
+	Failed    int            `json:"failed"`
+	Results   []GoldenResult `json:"results"`
+	Uncovered []string       `json:"uncovered"` // declared HTML routes without a fixture
+}
+
+// ContractCheckResult is the result of one contract check against the proxied backend
+type ContractCheckResult struct {
+	Name       string   `json:"name"`
+	Method     string   `json:"method"`
+	Path       string   `json:"path"`
+	Passed     bool     `json:"passed"`
+	Status     int      `json:"status"`
+	Errors     []string `json:"errors"`
+	DurationMs int64    `json:"duration_ms"`
+}
+
+type ContractResponse struct {
+	AppPath string                `json:"app_path"`
+	Passed  int                   `json:"passed"`
+	Failed  int                   `json:"failed"`
+	Results []ContractCheckResult `json:"results"`
+}
+
+// TenantInfo is the tenant config along with the current app count
+type TenantInfo struct {
+	Name string `json:"name"`
+	TenantConfig
+	AppCount int `json:"app_count"`
+}
+
+// QuotaUsage is the current resource usage counted against a quota policy
+type QuotaUsage struct {
+	Apps         int   `json:"apps"`
+	Containers   int   `json:"containers"`
+	StorageBytes int64 `json:"storage_bytes"`
+}
+
+// QuotaStatus is a quota policy applicable to the user, with the current usage
+type QuotaStatus struct {
+	QuotaPolicy
+	Usage QuotaUsage `json:"usage"`
+}
+
+// QuotaShowResponse is the response for the quota show API
+type QuotaShowResponse struct {
+	User   string        `json:"user"`
+	Quotas []QuotaStatus `json:"quotas"`
+}
+
+// AuditQuery is the filter for listing audit events. Empty fields are not filtered on. Before is
+// the create time (unix nanoseconds) of the last event from the previous page
+type AuditQuery struct {
+	AppGlob   string
+	UserId    string
+	EventType string
+	Operation string
+	Target    string
+	Status    string
+	Rid       string
+	Detail    string
+	Start     time.Time
+	End       time.Time
+	Before    int64
+	Limit     int
+}
+
+// AuditEventInfo is an audit event, with the name and path of the app for app events
+type AuditEventInfo struct {
+	Rid             string    `json:"rid"`
+	AppId           AppId     `json:"app_id"`
+	AppName         string    `json:"app_name"`
+	AppPath         string    `json:"app_path"`
+	AppEnv          string    `json:"app_env"` // prod, stage, preview or dev, empty for non app events
+	CreateTime      time.Time `json:"create_time"`
+	CreateTimeEpoch int64     `json:"create_time_epoch"` // unix nanoseconds
+	UserId          string    `json:"user_id"`
+	EventType       string    `json:"event_type"`
+	Operation       string    `json:"operation"`
+	Target          string    `json:"target"`
+	Status          string    `json:"status"`
+	Detail          string    `json:"detail"`
+}
+
+// AuditListResponse is the response for the audit list API, newest events first. NextBefore is
+// set when the page is full, it is passed as the before filter to get the next page
+type AuditListResponse struct {
+	Events     []AuditEventInfo `json:"events"`
+	NextBefore int64            `json:"next_before,omitempty"`
+}
+
+// AuditChainStatus is the verification result for one audit hash chain. Each server process writes
+// one chain for the http events and one for the other events
+type AuditChainStatus struct {
+	ChainId        string     `json:"chain_id"`
+	FirstSeq       int64      `json:"first_seq"` // events before this were deleted by the retention cleanup
+	LastSeq        int64      `json:"last_seq"`
+	Events         int64      `json:"events"`
+	Checkpoints    int        `json:"checkpoints"`
+	CheckpointSeq  int64      `json:"checkpoint_seq"` // the seq of the latest checkpoint
+	CheckpointTime *time.Time `json:"checkpoint_time,omitempty"`
+}
+
+// AuditVerifyResponse is the response for the audit verify API. Errors are the tampering found,
+// Verified is true if there are no errors
+type AuditVerifyResponse struct {
+	Verified  bool               `json:"verified"`
+	Chains    []AuditChainStatus `json:"chains"`
+	Unchained int64              `json:"unchained"` // events written before the hash chain was enabled
+	Errors    []string           `json:"errors"`
+	Warnings  []string           `json:"warnings"`
+}
+
+// ComplianceReportResponse is the response for the compliance report API. Archive is a tar.gz
+// file with the report files, the manifest listing their hashes and the manifest signature
+type ComplianceReportResponse struct {
+	Period   string    `json:"period"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	FileName string    `json:"file_name"`
+	Archive  []byte    `json:"archive"`
+	KeyId    string    `json:"key_id"`
+	Warnings []string  `json:"warnings"`
+}
+
+// ComplianceManifest is the manifest.json file in the compliance report archive
+type ComplianceManifest struct {
+	Period     string           `json:"period"`
+	Start      time.Time        `json:"start"`
+	End        time.Time        `json:"end"`
+	CreateTime time.Time        `json:"create_time"`
+	CreatedBy  string           `json:"created_by"`
+	Files      []ComplianceFile `json:"files"`
+}
+
+// ComplianceFile is a report file in the compliance report archive, with its SHA256 hash
+type ComplianceFile struct {
+	Name   string `json:"name"`
+	Size   int    `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+// ComplianceSignature is the manifest.sig file in the compliance report archive, the signature
+// is the base64 encoded HMAC of the manifest.json contents using the keyring key
+type ComplianceSignature struct {
+	KeyId     string `json:"key_id"`
+	Signature string `json:"signature"`
+}
+
+// ComplianceVerifyRequest is the request for the compliance report verify API
+type ComplianceVerifyRequest struct {
+	Manifest  []byte              `json:"manifest"`
+	Signature ComplianceSignature `json:"signature"`
+}
+
+// ComplianceVerifyResponse is the response for the compliance report verify API
+type ComplianceVerifyResponse struct {
+	Verified bool   `json:"verified"`
+	Error    string `json:"error"`
+}
+
+// AppCheckIssue is an accessibility issue found on a page of the app
+type AppCheckIssue struct {
+	Page    string `json:"page"`
+	Rule    string `json:"rule"`
+	WCAG    string `json:"wcag"` // the WCAG success criterion, like 1.1.1
+	Message string `json:"message"`
+	Element string `json:"element"`
+}
+
+// BrokenLink is an internal link which returned an error response. Page is empty for a
+// declared route which failed
+type BrokenLink struct {
+	Page   string `json:"page"`
+	Link   string `json:"link"`
+	Status int    `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+type AppCheckResponse struct {
+	AppPath      string          `json:"app_path"`
+	Pages        []string        `json:"pages"` // the pages which were checked
+	LinksChecked int             `json:"links_checked"`
+	BrokenLinks  []BrokenLink    `json:"broken_links"`
+	Issues       []AppCheckIssue `json:"issues"`
+	RuleCounts   map[string]int  `json:"rule_counts"` // the issue count for each rule
+	Truncated    bool            `json:"truncated"`   // set if the page or link limit was reached
+}
+
 type SyncCreateResponse struct {
 	DryRun            bool          `json:"dry_run"`
 	Id                string        `json:"id"`
 	WebhookUrl        string        `json:"webhook_url"`
 	WebhookSecret     string        `json:"webhook_secret"`
 	ScheduleFrequency int           `json:"schedule_minutes"`
+	ScheduleCron      string        `json:"schedule_cron"`
+	NextRunTime       *time.Time    `json:"next_run_time,omitempty"`
 	SyncJobStatus     SyncJobStatus `json:"sync_job_status"`
 }
 
@@ -306,6 +1078,16 @@ type SyncListResponse struct {
 	Entries []*SyncEntry `json:"entries"`
 }
 
+// SyncHistoryResponse has one page of the runs of a sync entry, latest first. Total is the count
+// of the runs across all the pages
+type SyncHistoryResponse struct {
+	Id     string     `json:"id"`
+	Runs   []*SyncRun `json:"runs"`
+	Total  int        `json:"total"`
+	Offset int        `json:"offset"`
+	Limit  int        `json:"limit"`
+}
+
 type ConfigResponse struct {
 	DynamicConfig DynamicConfig `json:"dynamic_config"`
 }
@@ -400,6 +1182,34 @@ type SecretRekeyResponse struct {
 	Skipped int `json:"skipped"`
 }
 
+const (
+	KEYRING_KEY_ACTIVE  = "active"
+	KEYRING_KEY_RETIRED = "retired"
+)
+
+// KeyringKey is a key in the server keyring. The active key signs the new session cookies and
+// webhook secrets, the retired keys are used only to verify, till they are removed
+type KeyringKey struct {
+	Id         string     `json:"id"`
+	Status     string     `json:"status"`
+	CreateTime time.Time  `json:"create_time"`
+	RetireTime *time.Time `json:"retire_time,omitempty"`
+}
+
+type KeyringResponse struct {
+	Keys      []KeyringKey `json:"keys"`
+	KmsBacked bool         `json:"kms_backed"`
+}
+
+// SyncWebhookSecretResponse is the webhook secret for a webhook sync, derived from the active
+// keyring key
+type SyncWebhookSecretResponse struct {
+	Id            string `json:"id"`
+	WebhookUrl    string `json:"webhook_url"`
+	WebhookSecret string `json:"webhook_secret"`
+	KeyId         string `json:"key_id"`
+}
+
 type AppReloadOption string
 
 const (
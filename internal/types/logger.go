@@ -18,7 +18,9 @@ type Logger struct {
 	*zerolog.Logger
 }
 
-func NewLogger(config *LogConfig) *Logger {
+// NewLogger creates the server logger. The extra writers get the JSON encoded log lines, in
+// addition to the console and file writers
+func NewLogger(config *LogConfig, extraWriters ...io.Writer) *Logger {
 	var writers []io.Writer
 	if config.Console {
 		writers = append(writers, zerolog.ConsoleWriter{Out: os.Stderr})
@@ -29,6 +31,7 @@ func NewLogger(config *LogConfig) *Logger {
 			writers = append(writers, fileWriter)
 		}
 	}
+	writers = append(writers, extraWriters...)
 	mw := io.MultiWriter(writers...)
 
 	level := strings.ToUpper(config.Level)
@@ -44,13 +44,20 @@ const (
 	ID_PREFIX_SERVER        = "srv_id_"
 	ID_PREFIX_BUILDER_SES   = "bld_ses_"
 	ID_PREFIX_BUILDER_ACT   = "bld_act_"
+	ID_PREFIX_JOB           = "job_"
+	ID_PREFIX_SYNC_RUN      = "syn_run_"
+	ID_PREFIX_APPLY_PLAN    = "apl_pln_"
 	INTERNAL_URL_PREFIX     = "/_openrun"
 	WEBHOOK_URL_PREFIX      = "/_openrun_webhook"
 	APP_INTERNAL_URL_PREFIX = "/_openrun_app"
+	OPENAPI_URL_PREFIX      = "/openrun_api" // per app OpenAPI spec and Swagger UI
 	INTERNAL_APP_DELIM      = "_cl_"
 	STAGE_SUFFIX            = INTERNAL_APP_DELIM + "stage"
 	PREVIEW_SUFFIX          = INTERNAL_APP_DELIM + "preview"
-	NO_SOURCE               = "-" // No source url is provided
+	PR_PREVIEW_SUFFIX       = INTERNAL_APP_DELIM + "pr"
+	NO_SOURCE               = "-"        // No source url is provided
+	IMAGE_SOURCE_PREFIX     = "image://" // Source url for an app run from a prebuilt image, like image://ghcr.io/org/app:tag
+	OCI_SOURCE_PREFIX       = "oci://"   // Source url for app source packaged as an OCI artifact, like oci://ghcr.io/org/app:tag
 )
 
 type ContextKey string
@@ -98,6 +105,8 @@ const (
 	TL_BRANCH                   = "TL_branch"
 	TL_DEV                      = "TL_dev"
 	TL_APP_URL                  = "TL_app_url"
+	TL_PROFILER                 = "TL_profiler"
+	TL_READ_ONLY                = "TL_read_only"
 )
 
 const (
@@ -107,6 +116,9 @@ const (
 	CONTAINER_LIFETIME_COMMAND    = "command"
 
 	CONTAINER_KUBERNETES = "kubernetes"
+
+	CONTAINER_DRIVER_CLI = "cli"
+	CONTAINER_DRIVER_API = "api"
 )
 
 const (
@@ -136,6 +148,21 @@ type DevSettings struct {
 // instead of being silently dropped.
 var DevSettingsKeys = []string{"target", "command", "dir", "reload", "env_files", "additional_mounts", "port"}
 
+// ContainerService is a service container of a multi-container app, declared using
+// container.service in the container.config services list. The service runs next to the app
+// container on a per-app network, reachable using the service name as the host name
+type ContainerService struct {
+	Name    string
+	Image   string            // image to run. Empty to use Source, or the app image if Source is also empty
+	Source  string            // container file in the app source to build the service image from
+	Command []string          // overrides the image cmd if set
+	Env     map[string]string // environment for the service container
+	Volumes []string          // named volumes, in the "name:/path[:ro]" format
+}
+
+// ContainerServiceKeys are the allowed keys for each entry of the container.config services dict
+var ContainerServiceKeys = []string{"image", "src", "command", "env", "volumes"}
+
 const (
 	ANONYMOUS_USER                 = "anonymous"
 	ADMIN_USER                     = "admin"
@@ -154,6 +181,7 @@ type ServerConfig struct {
 	GlobalConfig
 	Http           HttpConfig                      `toml:"http"`
 	Https          HttpsConfig                     `toml:"https"`
+	TLS            TLSConfig                       `toml:"tls"`
 	Security       SecurityConfig                  `toml:"security"`
 	Bindings       BindingsConfig                  `toml:"bindings"`
 	Metadata       MetadataConfig                  `toml:"metadata"`
@@ -164,6 +192,7 @@ type ServerConfig struct {
 	Builder        BuilderConfig                   `toml:"builder"`
 	Kubernetes     KubernetesConfig                `toml:"kubernetes"`
 	GitAuth        map[string]GitAuthEntry         `toml:"git_auth"`
+	RegistryAuth   map[string]RegistryConfig       `toml:"registry_auth"` // credentials for pulling prebuilt images, matched by the registry host
 	Plugins        map[string]PluginSettings       `toml:"plugin"`
 	Auth           map[string]AuthConfig           `toml:"auth"`
 	BuiltinAuth    map[string]BuiltinAuthEntry     `toml:"builtin_auth"`
@@ -171,8 +200,11 @@ type ServerConfig struct {
 	ClientAuth     map[string]ClientCertConfig     `toml:"client_auth"`
 	Secret         map[string]SecretConfig         `toml:"secret"`
 	Forward        map[string]ForwardConfig        `toml:"forward"`
+	AuditSink      map[string]AuditSinkConfig      `toml:"audit_sink"`
+	Notify         map[string]NotifyConfig         `toml:"notify"`
 	ProfileMode    string                          `toml:"profile_mode"`
 	AppConfig      AppConfig                       `toml:"app_config"`
+	TagConfig      map[string]map[string]any       `toml:"tag_config"`
 	NodeConfig     NodeConfig                      `toml:"node_config"`
 	Permissions    PermissionsConfig               `toml:"permissions"`
 	AppBuilder     AppBuilderConfig                `toml:"app_builder"`
@@ -180,6 +212,10 @@ type ServerConfig struct {
 	BuilderProfile map[string]BuilderProfileConfig `toml:"builder_profile"`
 	BuilderGit     map[string]BuilderGitConfig     `toml:"builder_git"`
 	Restart        RestartConfig                   `toml:"restart"`
+	ChatOps        ChatOpsConfig                   `toml:"chatops"`
+	ContainerQuota ContainerQuotaConfig            `toml:"container_quota"`
+	// ContainerDevices controls the GPU, host device and shared memory container options
+	ContainerDevices ContainerDevicesConfig `toml:"container_devices"`
 
 	// EnableInPlaceRestart is set by the server start command; zero downtime
 	// in-place restarts need process-wide state (signal handling, re-exec)
@@ -187,12 +223,48 @@ type ServerConfig struct {
 	EnableInPlaceRestart bool `toml:"-"`
 }
 
+// ContainerQuotaConfig limits the resources the app containers on a server can use. The app
+// values are the max limits for one app, apps without a limit are run with the app quota as their
+// limit. The total values are the max of the limits summed over the apps loaded on the server.
+// Empty or zero values mean no quota
+type ContainerQuotaConfig struct {
+	AppCpus     string `toml:"app_cpus"`     // max cpus for one app, like "2" or "500m"
+	AppMemory   string `toml:"app_memory"`   // max memory for one app, like "1g" or "512Mi"
+	AppPids     int    `toml:"app_pids"`     // max number of processes for one app container
+	TotalCpus   string `toml:"total_cpus"`   // max cpus summed over all the apps
+	TotalMemory string `toml:"total_memory"` // max memory summed over all the apps
+}
+
+// ContainerDevicesConfig controls which apps can request GPUs and host devices, using the gpus and
+// devices container options. Setting those options also needs the container:devices permission
+// when RBAC is enabled
+type ContainerDevicesConfig struct {
+	AllowedApps    []string `toml:"allowed_apps"`    // app path globs which can use GPUs and devices, none when empty
+	AllowedDevices []string `toml:"allowed_devices"` // host device path patterns which can be mapped, like "/dev/dri/*"
+	MaxGpus        int      `toml:"max_gpus"`        // max GPUs for one app, zero means no limit
+	MaxShmSize     string   `toml:"max_shm_size"`    // max shm_size for one app, like "2g". Empty means no limit
+}
+
 // RestartConfig controls zero downtime in-place restarts and shutdown drain
 type RestartConfig struct {
 	DrainTimeoutSecs   int `toml:"drain_timeout_secs"`   // max wait for in-flight requests and websockets to finish on shutdown
 	UpgradeTimeoutSecs int `toml:"upgrade_timeout_secs"` // max wait for the new process to report ready during an in-place restart
 }
 
+// ChatOpsConfig is the config for operating OpenRun from chat tools
+type ChatOpsConfig struct {
+	Slack SlackConfig `toml:"slack"`
+}
+
+// SlackConfig is the config for the Slack slash command. Users maps the Slack user id to the
+// OpenRun user id the commands run as, with RBAC enforcement. Unmapped users are rejected
+type SlackConfig struct {
+	Enabled       bool              `toml:"enabled"`
+	SigningSecret string            `toml:"signing_secret"` // the Slack app signing secret; supports {{secret}} references
+	TeamId        string            `toml:"team_id"`        // the Slack workspace id, requests from other workspaces are rejected if set
+	Users         map[string]string `toml:"users"`          // Slack user id to OpenRun user id, like "U024BE7LH" = "github:alice"
+}
+
 // BuilderProfileConfig is one [builder_profile.*] entry: a named bundle of
 // how builder apps are built and published. With no profiles configured the
 // implicit default applies (default_agent_config or opencode, local publish,
@@ -403,15 +475,85 @@ type SecretConfig map[string]any
 type NodeConfig map[string]any
 
 type AppConfig struct {
-	CORS       CORS         `toml:"cors"`
-	Action     ActionConfig `toml:"action"`
-	Container  Container    `toml:"container"`
-	Kubernetes Kubernetes   `toml:"kubernetes"`
-	Proxy      Proxy        `toml:"proxy"`
-	FS         FS           `toml:"fs"`
-	Audit      Audit        `toml:"audit"`
-	Security   Security     `toml:"security"`
-	StarBase   string       `toml:"star_base"` // The base directory for starlark config files
+	CORS       CORS            `toml:"cors"`
+	Action     ActionConfig    `toml:"action"`
+	Container  Container       `toml:"container"`
+	Kubernetes Kubernetes      `toml:"kubernetes"`
+	Proxy      Proxy           `toml:"proxy"`
+	FS         FS              `toml:"fs"`
+	Audit      Audit           `toml:"audit"`
+	Security   Security        `toml:"security"`
+	Job        JobConfig       `toml:"job"`
+	Fault      FaultConfig     `toml:"fault"`
+	Mock       MockConfig      `toml:"mock"`
+	OpenAPI    OpenAPIConfig   `toml:"openapi"`
+	Schedule   Schedule        `toml:"schedule"`
+	Minify     MinifyConfig    `toml:"minify"`
+	Image      ImageConfig     `toml:"image"`
+	RateLimit  RateLimitConfig `toml:"rate_limit"`
+	StarBase   string          `toml:"star_base"` // The base directory for starlark config files
+}
+
+// Schedule is the app config for the active hours of an app. Outside the active hours, the app
+// container is stopped and requests get an "outside active hours" page. Applies to prod apps only
+type Schedule struct {
+	ActiveHours  []string `toml:"active_hours"`  // windows like "mon-fri 09:00-17:00", empty means always active
+	Timezone     string   `toml:"timezone"`      // timezone for the windows, like "America/New_York", server local time by default
+	PrestartMins int      `toml:"prestart_mins"` // the container is started this many minutes before a window opens
+}
+
+// MinifyConfig is the config for minifying the HTML output of the app templates. Applies to prod
+// mode apps only, the output of dev apps is not minified
+type MinifyConfig struct {
+	HTML         bool `toml:"html"`          // minify the rendered pages, removing comments and collapsing whitespace
+	CSS          bool `toml:"css"`           // minify the CSS in style tags, if html is enabled
+	JS           bool `toml:"js"`            // minify the JS in script tags, if html is enabled
+	CacheEntries int  `toml:"cache_entries"` // number of minified pages cached per app, 0 to disable the cache
+}
+
+// ImageConfig is the config for the variants created for the static images, when a prod app is
+// installed. The variants are served like the other static files and are listed using the srcset
+// template function. The WebP and AVIF variants are created using the encoder commands in the
+// system config
+type ImageConfig struct {
+	Widths      []int `toml:"widths"`       // widths of the resized variants, widths not smaller than the image are skipped
+	JpegQuality int   `toml:"jpeg_quality"` // quality of the resized JPEG variants, 1 to 100
+	WebP        bool  `toml:"webp"`         // create WebP variants, for the image and the resized widths
+	AVIF        bool  `toml:"avif"`         // create AVIF variants, for the image and the resized widths
+}
+
+// OpenAPIConfig is the config for the OpenAPI spec generated from the app API routes
+type OpenAPIConfig struct {
+	Enabled   bool `toml:"enabled"`    // serve the spec at <app_path>/openrun_api/openapi.json
+	SwaggerUI bool `toml:"swagger_ui"` // serve Swagger UI at <app_path>/openrun_api/docs
+}
+
+// FaultConfig is the fault injection config, used to test the error handling of an app.
+// Faults are injected only for stage apps, prod and dev apps ignore this config
+type FaultConfig struct {
+	LatencyMs   int      `toml:"latency_ms"`   // delay added to the affected calls
+	LatencyRate float64  `toml:"latency_rate"` // fraction (0 to 1) of the calls which are delayed
+	ErrorRate   float64  `toml:"error_rate"`   // fraction (0 to 1) of the calls which fail
+	ErrorStatus int      `toml:"error_status"` // the HTTP status returned for failed proxy calls
+	Plugins     []string `toml:"plugins"`      // plugin calls affected, as glob patterns like "http.in.*"
+	Proxy       bool     `toml:"proxy"`        // whether proxied upstream calls are affected
+}
+
+// MockConfig is the mock plugin mode config, used to develop an app while the upstream APIs are
+// not available. The matching plugin calls get the responses from the fixture files in the app
+// source. In record mode, the plugins are called and the calls are saved to the fixture files.
+// Mocks are used only for dev apps, other apps ignore this config
+type MockConfig struct {
+	Plugins []string `toml:"plugins"` // plugin calls to mock, as glob patterns like "http.in.*"
+	Dir     string   `toml:"dir"`     // the fixtures directory in the app source, default "mocks"
+	Record  bool     `toml:"record"`  // call the plugins and record the calls to the fixture files
+}
+
+// JobConfig is the app level config for background jobs submitted with job.submit
+type JobConfig struct {
+	Workers     int `toml:"workers"`      // max jobs running concurrently for the app, on each server
+	TimeoutSecs int `toml:"timeout_secs"` // max run time for a job attempt
+	MaxRetries  int `toml:"max_retries"`  // upper limit for the retry count requested on submit
 }
 
 type ActionConfig struct {
@@ -425,6 +567,23 @@ type Security struct {
 	// strictest full set. Levels 0, 2, 5 and 10 are currently implemented; any other
 	// value is rounded down to the nearest implemented level. Default is 2.
 	HeadersLevel int `toml:"headers_level"`
+	// Profile is the security profile, standard or strict, which sets the hardened defaults for
+	// the headers level, the request limits, the denied plugins and the audit settings. The
+	// profile values override the server app_config, the tag and per-app config override the
+	// profile. An app opts out of the server profile by setting the profile to none
+	Profile string `toml:"profile"`
+	// DeniedPlugins are the plugin calls the app is not allowed to make, as glob patterns like
+	// "exec.in.*", checked in addition to the approved permissions
+	DeniedPlugins []string `toml:"denied_plugins"`
+	// MaxRequestBodyBytes is the max size of the request body for all app requests, 0 for no limit
+	MaxRequestBodyBytes int64 `toml:"max_request_body_bytes"`
+}
+
+// RateLimitConfig is the app config for limiting the requests per client IP. Applies to prod and
+// stage apps, dev apps are not rate limited
+type RateLimitConfig struct {
+	RequestsPerMin int `toml:"requests_per_min"` // max requests per minute per client IP, 0 for no limit
+	Burst          int `toml:"burst"`            // requests allowed at once above the rate, one tenth of requests_per_min if 0
 }
 
 type CORS struct {
@@ -452,6 +611,11 @@ type Container struct {
 	HealthTimeoutSecs          int    `toml:"health_timeout_secs"`
 	DeployProbePeriodSecs      int    `toml:"deploy_probe_period_secs"`
 	DeployHealthAttempts       int    `toml:"deploy_health_attempts"`
+	// Fixed interval between the health check attempts, 0 uses an exponential backoff
+	HealthIntervalSecs int `toml:"health_interval_secs"`
+	// How long a request to a starting container waits for it to become ready before
+	// the starting page is returned
+	ReadinessWaitSecs int `toml:"readiness_wait_secs"`
 	// Overrides Kubernetes progressDeadlineSeconds when >0. Keep 0 unless tests
 	// or operators deliberately want failed rollouts to be declared earlier.
 	DeployProgressDeadlineSecs int `toml:"deploy_progress_deadline_secs"`
@@ -463,6 +627,18 @@ type Container struct {
 	IdleShutdownSecs       int  `toml:"idle_shutdown_secs"`
 	IdleShutdownDevApps    bool `toml:"idle_shutdown_dev_apps"`
 	IdleBytesHighWatermark int  `toml:"idle_bytes_high_watermark"`
+	// How long a request waits for a stopped container, like one stopped after an idle shutdown,
+	// to start and become ready before the starting page is returned
+	WakeupWaitSecs int `toml:"wakeup_wait_secs"`
+	// Start the container when a request finds it not running, like after a crash or an external
+	// stop, the request is queued up to wakeup_wait_secs while the container starts
+	LazyStart bool `toml:"lazy_start"`
+
+	// Resource limits for the app container. The cpus and memory container options, when set,
+	// take precedence. Empty or zero values mean no limit, unless a container quota is set
+	Cpus      string `toml:"cpus"`
+	Memory    string `toml:"memory"`
+	PidsLimit int    `toml:"pids_limit"`
 
 	// Status check related config
 	StatusCheckIntervalSecs int `toml:"status_check_interval_secs"`
@@ -477,10 +653,17 @@ type Kubernetes struct {
 
 type Proxy struct {
 	// Proxy related config
-	MaxIdleConns        int  `toml:"max_idle_conns"`
-	IdleConnTimeoutSecs int  `toml:"idle_conn_timeout_secs"`
-	DisableCompression  bool `toml:"disable_compression"`
-	RewriteLocation     bool `toml:"rewrite_location"`
+	MaxIdleConns        int      `toml:"max_idle_conns"`
+	IdleConnTimeoutSecs int      `toml:"idle_conn_timeout_secs"`
+	DisableCompression  bool     `toml:"disable_compression"`
+	RewriteLocation     bool     `toml:"rewrite_location"`
+	RewriteCookies      bool     `toml:"rewrite_cookies"`    // rewrite the upstream Set-Cookie domain and path to match the app
+	ScrubHeaders        []string `toml:"scrub_headers"`      // response headers removed from upstream responses
+	ScrubCookies        []string `toml:"scrub_cookies"`      // cookie names removed from upstream Set-Cookie headers
+	RewriteHTML         bool     `toml:"rewrite_html"`       // rewrite path-absolute URLs in upstream HTML to be under the app path
+	RewriteHTMLAttrs    []string `toml:"rewrite_html_attrs"` // the tag:attr entries rewritten, "*" matches any tag
+	MaxResponseBytes    int64    `toml:"max_response_bytes"` // max size of a proxied response, 0 for no limit
+	MaxResponseSecs     int      `toml:"max_response_secs"`  // max time for a proxied request including the response streaming, 0 for no limit
 }
 
 type PluginContext struct {
@@ -512,6 +695,15 @@ type HttpsConfig struct {
 	DisableClientCerts bool   `toml:"disable_client_certs"`
 }
 
+// TLSConfig is the TLS policy for the HTTPS listener and the outbound clients
+type TLSConfig struct {
+	MinVersion       string   `toml:"min_version"`       // minimum TLS version, 1.2 or 1.3
+	CipherSuites     []string `toml:"cipher_suites"`     // TLS 1.2 cipher suite names, empty for the Go defaults
+	CurvePreferences []string `toml:"curve_preferences"` // key exchange curves, like X25519 and P256, empty for the Go defaults
+	OcspStapling     bool     `toml:"ocsp_stapling"`     // staple OCSP responses to the listener certificates
+	Fips             bool     `toml:"fips"`              // require FIPS 140-3 mode, allowing only the FIPS approved algorithms
+}
+
 // SecurityConfig is the security related configuration
 type SecurityConfig struct {
 	UnsafeAdminOverTCP  bool   `toml:"unsafe_admin_over_tcp"`
@@ -521,6 +713,11 @@ type SecurityConfig struct {
 	SessionMaxAge       int    `toml:"session_max_age"`
 	SessionHttpsOnly    bool   `toml:"session_https_only"`
 
+	// KeyringKmsKey is a {{secret_from ...}} reference to a base64 encoded 32 byte key, held in
+	// an external secret provider like AWS Secrets Manager or Vault. When set, the server keyring
+	// is stored encrypted with this key in the metadata database
+	KeyringKmsKey string `toml:"keyring_kms_key"`
+
 	// DisableLoginForm reverts the system/builtin auth types to the plain
 	// HTTP Basic challenge for browsers too, disabling the HTML login page.
 	// Off by default (browsers get the login page)
@@ -541,6 +738,19 @@ type SecurityConfig struct {
 	AllowedContainerArgs     map[string]string `toml:"allowed_container_args"` // the container args that are allowed to be used in the app config
 	AllowedMounts            []string          `toml:"allowed_mounts"`         // the volume mounts paths that are allowed to be used in the app config
 
+	// TrustedSources are glob patterns matched against the app source url. Apps with a source
+	// not matching any pattern are untrusted, the Starlark builtins which expose server state
+	// and the while/recursion language features are not available to them. Dev apps are
+	// always trusted, nil (not set) trusts all apps
+	TrustedSources []string `toml:"trusted_sources"`
+	// UntrustedAllowedBuiltins are the builtins, like ace.config, or the language features,
+	// like while, which are made available to untrusted apps
+	UntrustedAllowedBuiltins []string `toml:"untrusted_allowed_builtins"`
+
+	// SourceVerification is the policy for verifying the signature of the app sources before
+	// they are deployed
+	SourceVerification SourceVerificationConfig `toml:"source_verification"`
+
 	// UnsafeAgentWithoutSandbox runs app builder agents as plain host
 	// processes instead of container sandboxes. The sandbox is the safety
 	// boundary for auto-approved agent tool calls, so this is not
@@ -562,6 +772,25 @@ type SecurityConfig struct {
 	UnsafeAllowSystemPluginsAnon bool `toml:"unsafe_allow_system_plugins_anon"`
 }
 
+// SourceVerificationConfig is the policy for app source signatures. When enabled, the git commits
+// (signed using gitsign) and the OCI artifacts (signed using cosign) of the matching app sources
+// need a valid sigstore signature from one of the identities. Apps from other sources are rejected.
+// Dev apps are not verified
+type SourceVerificationConfig struct {
+	Enabled     bool              `toml:"enabled"`
+	Sources     []string          `toml:"sources"`    // glob patterns for the app source urls to verify, all sources if empty
+	Identities  []SigningIdentity `toml:"identities"` // the signer identities which are accepted
+	CosignPath  string            `toml:"cosign_path"`
+	GitsignPath string            `toml:"gitsign_path"`
+}
+
+// SigningIdentity is a signer identity, from the keyless signing certificate. The values are
+// matched exactly, a regex: prefix does a regex match
+type SigningIdentity struct {
+	Issuer  string `toml:"issuer"`  // the OIDC issuer, like https://token.actions.githubusercontent.com
+	Subject string `toml:"subject"` // the identity, like the workflow url or the email
+}
+
 // MetadataConfig is the configuration for the Metadata persistence layer
 type MetadataConfig struct {
 	DBConnection        string `toml:"db_connection"`
@@ -606,6 +835,64 @@ type TelemetryConfig struct {
 	// invocation. Off by default because data-heavy apps may issue many
 	// plugin calls per request.
 	PluginSpans bool `toml:"plugin_spans"`
+	// StoreSpans, when true, creates a span for each store plugin query, with
+	// the table and operation. Off by default like PluginSpans.
+	StoreSpans bool `toml:"store_spans"`
+	// SampleRatio is the fraction of new traces which are sampled, from 0 to 1.
+	// Spans with a sampled parent are always sampled.
+	SampleRatio float64 `toml:"sample_ratio"`
+	// Compression is the OTLP export compression, gzip or none
+	Compression       string `toml:"compression"`
+	ExportTimeoutSecs int    `toml:"export_timeout_secs"`
+	// Prometheus, when true, serves the metrics in the Prometheus text format
+	// at /metrics on PrometheusAddress. It does not depend on Enabled, which
+	// controls the OTLP export.
+	Prometheus        bool   `toml:"prometheus"`
+	PrometheusAddress string `toml:"prometheus_address"`
+	PrometheusToken   string `toml:"prometheus_token"` // bearer token required for scrapes if set, supports {{secret}} references
+}
+
+// AuditSinkConfig is one [audit_sink.<name>] entry. Audit events are forwarded to the sink in
+// addition to being written to the audit database. Events are sent in batches from a bounded
+// queue, failed sends are retried with backoff. When the queue is full (the sink is down or
+// slow), new events are dropped for the sink, the request path is never blocked
+type AuditSinkConfig struct {
+	Type            string            `toml:"type"`              // syslog, webhook or otlp
+	Url             string            `toml:"url"`               // syslog: udp://, tcp:// or tls://host:port; webhook: https url; otlp: the OTLP/HTTP endpoint
+	Headers         map[string]string `toml:"headers"`           // webhook and otlp: request headers; supports {{secret}} references
+	Secret          string            `toml:"secret"`            // webhook: HMAC-SHA256 key for the X-OpenRun-Signature header; supports {{secret}} references
+	EventTypes      []string          `toml:"event_types"`       // the event types to forward (system, http, action, custom), all types if empty
+	Facility        string            `toml:"facility"`          // syslog: the facility name, default local0
+	AppName         string            `toml:"app_name"`          // syslog: the app name, default openrun
+	BatchSize       int               `toml:"batch_size"`        // max events per send, default 100 (syslog sends one message per event)
+	FlushIntervalMs int               `toml:"flush_interval_ms"` // max wait before a partial batch is sent, default 1000
+	QueueSize       int               `toml:"queue_size"`        // events buffered for the sink, default 10000
+	MaxRetries      int               `toml:"max_retries"`       // retries for a failed send, default 5
+	TimeoutSecs     int               `toml:"timeout_secs"`      // timeout per send, default 10
+	SkipVerify      bool              `toml:"skip_verify"`       // skip TLS certificate verification
+}
+
+// NotifyConfig is one [notify.<name>] entry, a target for the sync and apply result notifications.
+// Sync entries list the notify entries to use, entries with global set are used for all sync runs
+// and applies. The message body is a Go text/template, rendered with the notification fields
+type NotifyConfig struct {
+	Type         string            `toml:"type"`          // slack, email or webhook
+	On           string            `toml:"on"`            // when to notify: all (default), success or failure
+	Global       bool              `toml:"global"`        // notify for all sync runs and applies
+	Url          string            `toml:"url"`           // slack: the incoming webhook url; webhook: the https url; supports {{secret}} references
+	Headers      map[string]string `toml:"headers"`       // webhook: request headers; supports {{secret}} references
+	Secret       string            `toml:"secret"`        // webhook: HMAC-SHA256 key for the X-OpenRun-Signature header; supports {{secret}} references
+	ContentType  string            `toml:"content_type"`  // webhook: content type of the templated body, default text/plain
+	SmtpHost     string            `toml:"smtp_host"`     // email: the SMTP server host
+	SmtpPort     int               `toml:"smtp_port"`     // email: the SMTP server port, default 587. Port 465 uses implicit TLS
+	SmtpUser     string            `toml:"smtp_user"`     // email: the SMTP user, no auth if empty
+	SmtpPassword string            `toml:"smtp_password"` // email: the SMTP password; supports {{secret}} references
+	From         string            `toml:"from"`          // email: the from address
+	To           []string          `toml:"to"`            // email: the recipient addresses
+	Subject      string            `toml:"subject"`       // email: the subject template
+	Template     string            `toml:"template"`      // the message body template, the default message is used if empty. Webhook posts the JSON event if empty
+	TimeoutSecs  int               `toml:"timeout_secs"`  // timeout per notification, default 30
+	SkipVerify   bool              `toml:"skip_verify"`   // skip TLS certificate verification
 }
 
 const (
@@ -670,23 +957,46 @@ type SystemConfig struct {
 	WatchIgnorePatterns                 []string `toml:"watch_ignore_patterns"`
 	NodePath                            string   `toml:"node_path"`
 	ContainerCommand                    string   `toml:"container_command"`
+	ContainerDriver                     string   `toml:"container_driver"`                      // "cli" to run the container command, "api" to use the Docker Engine API
+	ContainerHost                       string   `toml:"container_host"`                        // Engine API endpoint for the api driver, also passed to the container CLI
 	StaleContainerCleanupIntervalMins   int      `toml:"stale_container_cleanup_interval_mins"` // Interval for stale OpenRun container cleanup. Set <=0 to disable.
+	JobPollIntervalSecs                 int      `toml:"job_poll_interval_secs"`                // Interval for polling the background job queue. Set <=0 to disable the job workers.
+	SecretRotationIntervalSecs          int      `toml:"secret_rotation_interval_secs"`         // Interval for checking whether the secrets used at app load have changed. Set <=0 to disable.
+	JobRetentionDays                    int      `toml:"job_retention_days"`                    // Number of days to retain completed background jobs
+	DeprecationNoticeDays               int      `toml:"deprecation_notice_days"`               // Days before the scheduled deletion of a deprecated app to notify the owner
+	AppArchiveDir                       string   `toml:"app_archive_dir"`                       // Directory where the bundles of the archived apps are written
+	VolumeBackupDir                     string   `toml:"volume_backup_dir"`                     // Directory where the volume backups are written, backups can also be written to S3
+	VolumeHelperImage                   string   `toml:"volume_helper_image"`                   // Image for the helper container used to copy the volume data, the container is not started
 	ContainerBuilder                    string   `toml:"container_builder"`
 	DefaultDomain                       string   `toml:"default_domain"`
 	RootServeListApps                   string   `toml:"root_serve_list_apps"`
 	EnableCompression                   bool     `toml:"enable_compression"`
+	CompressionMinSize                  int      `toml:"compression_min_size"` // responses smaller than this many bytes are not compressed
+	CompressionTypes                    []string `toml:"compression_types"`    // content types to compress, the default list of text types is used if empty
 	HttpEventRetentionDays              int      `toml:"http_event_retention_days"`
 	NonHttpEventRetentionDays           int      `toml:"non_http_event_retention_days"`
+	AuditRetentionDays                  int      `toml:"audit_retention_days"`                    // Max days to retain any audit event, applied along with the per class settings
+	AuditArchive                        string   `toml:"audit_archive"`                           // Directory or s3:// url to export the expired audit events to before deleting them
+	AuditCheckpointMins                 int      `toml:"audit_checkpoint_mins"`                   // Minutes between the signed checkpoints of the audit hash chains, 0 to disable
 	AllowedEnv                          []string `toml:"allowed_env"`                             // List of environment variables that are allowed to be used in the node config
 	DefaultScheduleMins                 int      `toml:"default_schedule_mins"`                   // Default schedule time in minutes for scheduled sync
 	MaxSyncFailureCount                 int      `toml:"max_sync_failure_count"`                  // Max failure count for sync jobs
 	MaxConcurrentBuilds                 int      `toml:"max_concurrent_builds"`                   // Max concurrent container builds
 	MaxBuildWaitSecs                    int      `toml:"max_build_wait_secs"`                     // Max wait time for a build lock
 	UseImagePreBuildStep                bool     `toml:"use_image_pre_build_step"`                // Pre-build container images for verified reloads before the metadata transaction starts
+	ApplyConcurrency                    int      `toml:"apply_concurrency"`                       // Max apps whose container images are pre-built concurrently, also limited by MaxConcurrentBuilds
 	EarlyHints                          bool     `toml:"early_hints"`                             // enable early hints for HTML responses
+	Language                            string   `toml:"language"`                                // default language for the server error pages
+	LanguageFromRequest                 bool     `toml:"language_from_request"`                   // use the Accept-Language request header to select the error page language
+	MessageCatalogDir                   string   `toml:"message_catalog_dir"`                     // directory with <language>.json files adding or overriding the built-in messages
 	LeaderElectionLeaseSecs             int      `toml:"leader_election_lease_secs"`              // The lease time for the leader election
 	LeaderElectionHeartbeatIntervalSecs int      `toml:"leader_election_heartbeat_interval_secs"` // The interval for the leader election heartbeat
 	FileWorkers                         int      `toml:"file_workers"`                            // number of parallel workers for file compression during app version creation
+	SyncHistoryEntries                  int      `toml:"sync_history_entries"`                    // number of sync runs retained in the history of each sync entry
+	PrPreviewTTLHours                   int      `toml:"pr_preview_ttl_hours"`                    // hours after the last update the pull request preview apps are deleted, 0 to not expire
+	PrPreviewAuth                       string   `toml:"pr_preview_auth"`                         // auth type for the pull request preview apps, the main app auth is used if empty
+	ImageWebPCommand                    string   `toml:"image_webp_command"`                      // command to create the WebP image variants, {input} and {output} are replaced with the file paths
+	ImageAVIFCommand                    string   `toml:"image_avif_command"`                      // command to create the AVIF image variants, {input} and {output} are replaced with the file paths
 	ListAppsTitle                       string   `toml:"list_apps_title"`                         // the title of the list apps page
 	ShowHostedWith                      bool     `toml:"show_hosted_with"`                        // whether to show "Hosted with OpenRun" in the list apps page
 	FallbackUnknownDomains              bool     `toml:"fallback_unknown_domains"`                // whether to fallback to default domain for unknown domains
@@ -694,6 +1004,8 @@ type SystemConfig struct {
 	BuilderAuthToken                    string   `toml:"builder_auth_token"`                      // the token for the builder auth
 	GitCheckoutCacheEntries             int      `toml:"git_checkout_cache_entries"`              // number of immutable git checkouts reused across operations; 0 disables the cache
 	GitRemoteCheckIntervalSecs          int      `toml:"git_remote_check_interval_secs"`          // reuse a checked branch head for this many seconds; 0 checks every operation
+	GitRepoCacheDir                     string   `toml:"git_repo_cache_dir"`                      // dir for the persistent git repo cache, default $OPENRUN_HOME/run/git_repo_cache
+	GitRepoCacheMB                      int      `toml:"git_repo_cache_mb"`                       // max size of the persistent git repo cache; 0 disables the cache
 	// StageAt is the default staging mode for new prod apps. "domain" stages at domain level,
 	// "path" stages at path level, and any other value is treated as the staging domain.
 	// Defaults to "domain".
@@ -726,6 +1038,14 @@ type BuilderConfig struct {
 	KanikoImage     string `toml:"kaniko_image"`
 	KanikoCache     bool   `toml:"kaniko_cache"`      // enable kaniko layer caching in the registry
 	KanikoCacheRepo string `toml:"kaniko_cache_repo"` // cache repo, defaults to <registry_url>[/<project>]/kaniko-cache
+
+	// Buildkit enables BuildKit for command mode docker builds, which supports RUN --mount=type=cache
+	// cache mounts in the Containerfile. Builds always use the CLI when set
+	Buildkit bool `toml:"buildkit"`
+	// CacheFrom reuses the unchanged layers of the app's previous image version for the build
+	CacheFrom bool `toml:"cache_from"`
+	// KeepVersions is the number of image versions per app kept by prune-images, by default
+	KeepVersions int `toml:"keep_versions"`
 }
 
 // GitAuth is a github auth config entry
@@ -734,8 +1054,24 @@ type GitAuthEntry struct {
 	KeyFilePath string `toml:"key_file_path"` // the path to the private key file
 	PrivateKey  string `toml:"private_key"`   // the private key contents (PEM), used instead of key_file_path; supports {{secret}} references
 	Password    string `toml:"password"`      // the password for the private key file
+	ApiToken    string `toml:"api_token"`     // the GitHub/GitLab API token for commit status, defaults to the password for token auth; supports {{secret}} references
+	ApiUrl      string `toml:"api_url"`       // the API url, for GitHub Enterprise and self hosted GitLab
+
+	// Type is empty for SSH key and password auth. For github_app and oidc, short lived access
+	// tokens are fetched and refreshed before they expire
+	Type           string `toml:"type"`
+	AppId          string `toml:"app_id"`          // the GitHub App id, the private_key or key_file_path is the App private key
+	InstallationId string `toml:"installation_id"` // the GitHub App installation id for the org or repos
+	TokenUrl       string `toml:"token_url"`       // the token exchange endpoint, for oidc
+	IdTokenFile    string `toml:"id_token_file"`   // the file with the OIDC id token, read for every exchange, for oidc
+	Audience       string `toml:"audience"`        // the audience requested in the token exchange, for oidc
 }
 
+const (
+	GitAuthTypeGitHubApp = "github_app"
+	GitAuthTypeOIDC      = "oidc"
+)
+
 // AuthConfig is the configuration for the Authentication provider
 type AuthConfig struct {
 	Key          string   `toml:"key"`           // the client id
@@ -789,6 +1125,7 @@ type ClientConfigStruct struct {
 	SkipCertCheck bool   `toml:"skip_cert_check"`
 	AdminPassword string `toml:"admin_password"`
 	DefaultFormat string `toml:"default_format"` // the default format for the CLI output
+	Language      string `toml:"language"`       // language for the CLI messages, defaults to the LC_ALL/LC_MESSAGES/LANG env value
 	// AsUser runs the management API call as this user id (the --as flag,
 	// e.g. builtin:user1) with RBAC enforcement instead of as the trusted
 	// administrator. Per invocation, not a config file setting
@@ -831,7 +1168,8 @@ type AppInfo struct {
 	UpdateTime     time.Time
 	RetainVersions int
 	AppliedSyncId  string
-	UserID         string // user who created the app, used for RBAC owner checks
+	UserID         string   // user who created the app, used for RBAC owner checks
+	Tags           []string // the app tags, for search
 }
 
 func CreateAppPathDomain(path, domain string) AppPathDomain {
@@ -844,7 +1182,7 @@ func CreateAppPathDomain(path, domain string) AppPathDomain {
 func CreateAppInfo(id AppId, name, path, domain string, isDev bool, mainApp AppId, linkedAppPath string,
 	auth AppAuthnType, sourceUrl string, spec AppSpec,
 	version int, gitSha, gitMessage, branch, starBase string, updatedAt time.Time, retainVersions int,
-	appliedSyncId string, userId string) AppInfo {
+	appliedSyncId string, userId string, tags []string) AppInfo {
 	return AppInfo{
 		AppPathDomain: AppPathDomain{
 			Path:   path,
@@ -867,6 +1205,7 @@ func CreateAppInfo(id AppId, name, path, domain string, isDev bool, mainApp AppI
 		RetainVersions: retainVersions,
 		AppliedSyncId:  appliedSyncId,
 		UserID:         userId,
+		Tags:           tags,
 	}
 }
 
@@ -882,6 +1221,13 @@ type Permission struct {
 	Secrets [][]string `json:"secrets" toml:"secrets"` // The secrets that are allowed to be used in the call.
 }
 
+// SpecPermissions is the permission bundle declared by an app spec, in the spec_permissions.toml
+// spec file. The permission arguments can use regex: like the permissions.allow server config
+type SpecPermissions struct {
+	Loads       []string     `toml:"loads"`
+	Permissions []Permission `toml:"permissions"`
+}
+
 // AppAuthnType is the app level authentication type
 type AppAuthnType string
 
@@ -896,6 +1242,7 @@ type AppSpec string
 
 const (
 	StaticDiskSpec AppSpec = "static_disk"
+	ImageSpec      AppSpec = "image" // Prebuilt image, no source checkout or image build
 )
 
 // VersionMetadata contains the metadata for an app
@@ -907,6 +1254,16 @@ type VersionMetadata struct {
 	GitMessage      string `json:"git_message"`
 	ApplyInfo       []byte `json:"apply_info"`
 	AppliedSyncId   string `json:"applied_sync_id"`
+	// GitTag is the tag or semver constraint, like v1.x, the app follows instead of a branch.
+	// GitResolvedTag is the latest matching tag, resolved at apply and reload
+	GitTag         string `json:"git_tag,omitempty"`
+	GitResolvedTag string `json:"git_resolved_tag,omitempty"`
+	// SourceChecksum is the expected sha256 of the archive for archive url sources, verified on
+	// every download. SourceArchiveSha is the sha256 of the archive loaded for the version
+	SourceChecksum   string `json:"source_checksum,omitempty"`
+	SourceArchiveSha string `json:"source_archive_sha,omitempty"`
+	// OCIDigest is the manifest digest of the OCI artifact loaded for the version
+	OCIDigest string `json:"oci_digest,omitempty"`
 }
 
 // AppEntry is the application configuration in the DB
@@ -925,6 +1282,8 @@ type AppEntry struct {
 	UpdateTime *time.Time  `json:"update_time"`
 	Settings   AppSettings `json:"settings"` // settings are not version controlled
 	Metadata   AppMetadata `json:"metadata"` // metadata is version controlled
+	// RowVersion is incremented on every update of the app row, updates from a stale read are rejected
+	RowVersion int64 `json:"row_version"`
 }
 
 func (ae *AppEntry) String() string {
@@ -961,6 +1320,16 @@ type AppMetadata struct {
 	Bindings         []string          `json:"bindings"`
 	AppliedSyncId    string            `json:"applied_sync_id"`             // id of the sync entry which last applied to this app, empty for imperative changes
 	BuilderPublished bool              `json:"builder_published,omitempty"` // app was published by the app builder; enables builder edit sessions
+	Crons            []CronDef         `json:"crons,omitempty"`             // scheduled tasks declared with ace.cron, loaded during the app audit
+	Env              []string          `json:"env,omitempty"`               // approved env entries, NAME for server env values and NAME=value otherwise
+}
+
+// CronDef is a scheduled task declared in the app definition with ace.cron. The handler is
+// the name of a top level function in the app, run as a background job on each schedule match
+type CronDef struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`
+	Handler  string `json:"handler"`
 }
 
 // AppSettings contains the settings for an app. Settings are not version controlled.
@@ -972,13 +1341,65 @@ type AppSettings struct {
 	StageWriteAccess   bool          `json:"stage_write_access"`
 	PreviewWriteAccess bool          `json:"preview_write_access"`
 	WebhookTokens      WebhookTokens `json:"webhook_tokens"`
-	OrigSourceUrl      string        `json:"orig_source_url"` // the original source url of the app, used for git create in dev mode
+	OrigSourceUrl      string        `json:"orig_source_url"`       // the original source url of the app, used for git create in dev mode
+	Deprecation        *Deprecation  `json:"deprecation,omitempty"` // set when the app is deprecated
+	Tags               []string      `json:"tags,omitempty"`        // the app tags, for search
+	Pause              *AppPause     `json:"pause,omitempty"`       // set when the app is paused
+	Archive            *AppArchive   `json:"archive,omitempty"`     // set when the app is archived
+	PrPreview          *PrPreview    `json:"pr_preview,omitempty"`  // set for the preview apps created for pull requests
+}
+
+// Inactive returns true if the app is paused or archived. Inactive apps are not initialized,
+// their requests get the maintenance page
+func (s *AppSettings) Inactive() bool {
+	return s.Pause != nil || s.Archive != nil
+}
+
+// Deprecation marks an app as deprecated. Users of the app see a banner with the message. If
+// DeleteAfter is set, the app is deleted after that time. The app owner is notified through an
+// audit event system.deprecation_notice_days before the deletion
+type Deprecation struct {
+	Message      string     `json:"message"`
+	DeprecatedBy string     `json:"deprecated_by"`
+	DeprecatedAt *time.Time `json:"deprecated_at"`
+	DeleteAfter  *time.Time `json:"delete_after,omitempty"`
+	NoticeSent   bool       `json:"notice_sent,omitempty"`
+}
+
+// PrPreview is the pull request info for a preview app created by a webhook sync. The preview app
+// is updated on pushes to the pull request and deleted when the pull request is closed or after
+// ExpireAt
+type PrPreview struct {
+	SyncId   string     `json:"sync_id"`
+	Number   int        `json:"number"`
+	Branch   string     `json:"branch"`
+	CommitId string     `json:"commit_id"`
+	ExpireAt *time.Time `json:"expire_at,omitempty"`
+}
+
+// AppPause marks an app as paused. The app metadata is kept, but the app is not served and its
+// containers are stopped. Users see a maintenance page with the message
+type AppPause struct {
+	Message  string     `json:"message"`
+	PausedBy string     `json:"paused_by"`
+	PausedAt *time.Time `json:"paused_at"`
+}
+
+// AppArchive marks an app as archived. The app config and source are exported to the bundle
+// file and the app containers and images are removed. The app entry is kept as a tombstone, so
+// that the app can be restored
+type AppArchive struct {
+	BundlePath string     `json:"bundle_path"`
+	ArchivedBy string     `json:"archived_by"`
+	ArchivedAt *time.Time `json:"archived_at"`
 }
 
 type WebhookTokens struct {
-	Reload        string `json:"reload"`
-	ReloadPromote string `json:"reload_promote"`
-	Promote       string `json:"promote"`
+	Reload          string `json:"reload"`
+	ReloadPromote   string `json:"reload_promote"`
+	Promote         string `json:"promote"`
+	Registry        string `json:"registry"`
+	RegistryPromote string `json:"registry_promote"`
 }
 
 type WebhookType string
@@ -987,6 +1408,10 @@ const (
 	WebhookReload        WebhookType = "reload"
 	WebhookReloadPromote WebhookType = "reload_promote"
 	WebhookPromote       WebhookType = "promote"
+	// The registry webhooks reload image spec apps on a push of the app image to the
+	// container registry (Docker Hub, GHCR, Harbor)
+	WebhookRegistry        WebhookType = "registry"
+	WebhookRegistryPromote WebhookType = "registry_promote"
 )
 
 // SpecFiles is a map of file names to file data. JSON encoding uses base 64 encoding of file text
@@ -1167,6 +1592,7 @@ type SyncEntry struct {
 	CreateTime  *time.Time    `json:"create_time"`
 	Metadata    SyncMetadata  `json:"metadata"`
 	Status      SyncJobStatus `json:"status"`
+	NextRunTime *time.Time    `json:"next_run_time,omitempty"` // for scheduled: the next run time, set in the list response
 }
 
 // RBACSnapshot freezes the sync creator's RBAC authorization at sync create
@@ -1196,15 +1622,25 @@ type SyncMetadata struct {
 	GitAuth   string `json:"git_auth"`   // the git auth entry to use for the sync
 
 	Promote     bool   `json:"promote"`      // whether this sync does a promote
+	Confirm     bool   `json:"confirm"`      // whether the promote of pending permission or container changes is confirmed
 	Approve     bool   `json:"approve"`      // whether this sync does an approve
 	Verify      bool   `json:"verify"`       // whether this sync verifies container reloads
 	Reload      string `json:"reload"`       // which apps to reload after the sync
 	Clobber     bool   `json:"clobber"`      // whether to force update the sync, overwriting non-declarative changes
 	ForceReload bool   `json:"force_reload"` // whether to force reload even if there is no new commit
 
+	CommitStatus bool     `json:"commit_status"`    // whether to post the sync result as a status on the git commit
+	Notify       []string `json:"notify,omitempty"` // the [notify.<name>] entries to send the sync results to
+
+	PrPreview         bool   `json:"pr_preview,omitempty"`           // for webhook: create preview apps for the pull requests
+	PrPreviewTTLHours int    `json:"pr_preview_ttl_hours,omitempty"` // for webhook: hours after the last update the preview apps are deleted, system default if zero
+	PrPreviewAuth     string `json:"pr_preview_auth,omitempty"`      // for webhook: auth type for the preview apps, system default if empty
+
 	WebhookUrl        string `json:"webhook_url"`        // for webhook : the url to use
 	WebhookSecret     string `json:"webhook_secret"`     // for webhook : the secret to use
 	ScheduleFrequency int    `json:"schedule_frequency"` // for scheduled: the frequency of the sync, every N minutes
+	ScheduleCron      string `json:"schedule_cron"`      // for scheduled: cron expression for the sync times, used instead of the frequency
+	ScheduleTimezone  string `json:"schedule_timezone"`  // for scheduled: timezone for the cron expression, server local time by default
 
 	RBAC *RBACSnapshot `json:"rbac,omitempty"` // creator authorization frozen at create time, nil means unrestricted
 }
@@ -1219,6 +1655,110 @@ type SyncJobStatus struct {
 	ApplyResponse     AppApplyResponse `json:"app_apply_response"`  // the response of the apply job
 }
 
+// SyncRun is the history record of one execution of a sync job, with the app changes done by
+// the run. Runs which failed have the error set, the app changes of failed runs are rolled back
+type SyncRun struct {
+	Id         string         `json:"id"`
+	SyncId     string         `json:"sync_id"`
+	StartTime  time.Time      `json:"start_time"`
+	DurationMs int64          `json:"duration_ms"`
+	CommitId   string         `json:"commit_id"`
+	Error      string         `json:"error"`
+	Changes    SyncRunChanges `json:"changes"`
+}
+
+// SyncRunChanges lists the apps changed by a sync run
+type SyncRunChanges struct {
+	SkippedApply bool            `json:"skipped_apply"` // the apply was skipped since the commit was already applied
+	Created      []AppPathDomain `json:"created"`
+	Updated      []AppPathDomain `json:"updated"`
+	Reloaded     []AppPathDomain `json:"reloaded"`
+	Approved     []AppPathDomain `json:"approved"`
+	Promoted     []AppPathDomain `json:"promoted"`
+	Skipped      []AppPathDomain `json:"skipped"`
+}
+
+// ApplyPlan is the result of a dry run apply saved for review. Executing the plan runs the apply
+// with the same options and apply file commit, failing if the result differs from the plan
+type ApplyPlan struct {
+	Id          string           `json:"id"`
+	CreateTime  time.Time        `json:"create_time"`
+	UserId      string           `json:"user_id"`
+	ApplyPath   string           `json:"apply_path"`
+	AppPathGlob string           `json:"app_path_glob"`
+	Options     ApplyPlanOptions `json:"options"`
+	// AppVersions is the row version of the existing apps updated by the plan, by app path
+	AppVersions map[string]int64 `json:"app_versions"`
+	Response    AppApplyResponse `json:"response"` // the changes computed by the plan
+	Status      ApplyPlanStatus  `json:"status"`
+	ExecuteTime *time.Time       `json:"execute_time,omitempty"`
+	ExecutedBy  string           `json:"executed_by,omitempty"`
+}
+
+type ApplyPlanOptions struct {
+	Approve     bool            `json:"approve"`
+	Promote     bool            `json:"promote"`
+	Reload      AppReloadOption `json:"reload"`
+	Branch      string          `json:"branch"`
+	Commit      string          `json:"commit"`
+	GitAuth     string          `json:"git_auth"`
+	Clobber     bool            `json:"clobber"`
+	ForceReload bool            `json:"force_reload"`
+	Verify      bool            `json:"verify"`
+	Dev         bool            `json:"dev"`
+}
+
+type ApplyPlanStatus string
+
+const (
+	ApplyPlanPending  ApplyPlanStatus = "pending"
+	ApplyPlanExecuted ApplyPlanStatus = "executed"
+)
+
+type JobStatus string
+
+const (
+	JobStatusPending   JobStatus = "pending"
+	JobStatusRunning   JobStatus = "running"
+	JobStatusSucceeded JobStatus = "succeeded"
+	JobStatusFailed    JobStatus = "failed"
+	JobStatusCancelled JobStatus = "cancelled"
+)
+
+// JobEntry is a background job submitted by an app with job.submit. The function is
+// referenced by its global name in the app code, the args and the result are stored as JSON
+type JobEntry struct {
+	Id         string     `json:"id"`
+	AppId      AppId      `json:"app_id"`
+	FuncName   string     `json:"func_name"`
+	Args       string     `json:"args"`
+	Status     JobStatus  `json:"status"`
+	Attempts   int        `json:"attempts"`
+	MaxRetries int        `json:"max_retries"`
+	Error      string     `json:"error"`
+	Result     string     `json:"result"`
+	UserId     string     `json:"user_id"`
+	RunAfter   time.Time  `json:"run_after"`
+	CreateTime time.Time  `json:"create_time"`
+	UpdateTime time.Time  `json:"update_time"`
+	EndTime    *time.Time `json:"end_time,omitempty"`
+}
+
+// Done returns true if the job is in a final state
+func (j *JobEntry) Done() bool {
+	return j.Status == JobStatusSucceeded || j.Status == JobStatusFailed || j.Status == JobStatusCancelled
+}
+
+// CronState is the last run state of a scheduled task, with the status of the job
+// which was submitted for the last run
+type CronState struct {
+	AppId       AppId      `json:"app_id"`
+	Name        string     `json:"name"`
+	LastRunTime *time.Time `json:"last_run_time,omitempty"`
+	LastJobId   string     `json:"last_job_id"`
+	SkipCount   int        `json:"skip_count"` // runs skipped since the previous run was still active
+}
+
 // NotificationMessage is the message sent through the postgres listener
 type NotificationMessage struct {
 	MessageType string `json:"message_type"`
@@ -1231,6 +1771,7 @@ var CurrentServerId ServerId // initialized in server.go init()
 const MessageTypeAppUpdate = "app_update"
 const MessageTypeConfigUpdate = "config_update"
 const MessageTypeProviderUpdate = "provider_update"
+const MessageTypeKeyringUpdate = "keyring_update"
 
 type AppUpdatePayload struct {
 	AppPathDomains []AppPathDomain `json:"app_path_domains"`
@@ -1265,6 +1806,17 @@ type ProviderUpdateMessage struct {
 	Payload     ProviderUpdatePayload `json:"payload"`
 }
 
+// KeyringUpdatePayload notifies replicas that the server keyring was rotated or a key was
+// removed; each replica reloads the keyring from the database.
+type KeyringUpdatePayload struct {
+	ServerId ServerId `json:"server_id"`
+}
+
+type KeyringUpdateMessage struct {
+	MessageType string               `json:"message_type"`
+	Payload     KeyringUpdatePayload `json:"payload"`
+}
+
 type LibraryType string
 
 const (
@@ -1338,6 +1890,36 @@ type RBACConfig struct {
 	// gets on assets they created. Missing key means the built-in default; an empty
 	// list disables the owner rule for that resource. approve is not allowed.
 	OwnerPermissions map[string][]RBACPermission `json:"owner_permissions,omitempty"`
+
+	// Tenants are the organizations sharing the server, keyed by tenant name. Each tenant owns
+	// a namespace of apps, has its own admins and is isolated from the other tenants
+	Tenants map[string]TenantConfig `json:"tenants,omitempty"`
+
+	// Quotas limit the apps and resources owned by users and teams. Every policy matching the
+	// app owner applies on app create
+	Quotas []QuotaPolicy `json:"quotas,omitempty"`
+}
+
+// TenantConfig is the config for one tenant. An app is in the tenant if its domain is one of
+// Domains, or if it is on the default domain under one of the Paths
+type TenantConfig struct {
+	Description string   `json:"description"`
+	Domains     []string `json:"domains"`  // domains owned by the tenant
+	Paths       []string `json:"paths"`    // path prefixes owned by the tenant on the default domain
+	Admins      []string `json:"admins"`   // users/groups with all the app permissions, except approve, on the tenant apps
+	Members     []string `json:"members"`  // if set, only these users/groups (and the admins) can be authorized on the tenant apps
+	MaxApps     int      `json:"max_apps"` // the max number of apps in the tenant, excluding stage and preview apps. Zero for no limit
+}
+
+// QuotaPolicy limits the resources owned by the matching users. The usage is counted per user,
+// unless Shared is set, in which case the usage of all the matching users counts against one limit
+type QuotaPolicy struct {
+	Description   string   `json:"description"`
+	Users         []string `json:"users"`          // users/groups the policy applies to
+	Shared        bool     `json:"shared"`         // the limits are for the matching users together, like for a team
+	MaxApps       int      `json:"max_apps"`       // max number of apps, excluding stage and preview apps. Zero for no limit
+	MaxContainers int      `json:"max_containers"` // max number of apps running containers. Zero for no limit
+	MaxStorageMB  int64    `json:"max_storage_mb"` // max disk usage of the app run directories in MB. Zero for no limit
 }
 
 type RBACGrant struct {
@@ -1367,6 +1949,8 @@ const (
 	PermissionTokenRead   RBACPermission = "app:token_read"   // list webhook tokens
 	PermissionTokenManage RBACPermission = "app:token_manage" // create/delete webhook tokens
 	PermissionAppManage   RBACPermission = "app:manage"       // all app permissions except approve
+	// app:exec allows running commands in the app containers, through app exec
+	PermissionExec RBACPermission = "app:exec"
 
 	PermissionSyncCreate RBACPermission = "sync:create"
 	PermissionSyncRun    RBACPermission = "sync:run"
@@ -1400,6 +1984,8 @@ const (
 
 	PermissionContainerRead   RBACPermission = "container:read"   // list containers, get container details/logs/stats
 	PermissionContainerManage RBACPermission = "container:manage" // start/stop managed containers
+	// container:devices is required to set the gpus and devices container options for an app
+	PermissionContainerDevices RBACPermission = "container:devices"
 
 	// provider:* permissions are global (granted with target "all"): binding
 	// providers are deployment-wide plugin executables, not per-resource entries.
@@ -1451,7 +2037,7 @@ var RBACPermissionGroups = []RBACPermissionGroup{
 		PermissionAccess, PermissionRead, PermissionCreate, PermissionUpdate,
 		PermissionReload, PermissionApply, PermissionDelete,
 		PermissionPromote, PermissionPreview, PermissionTokenRead,
-		PermissionTokenManage, PermissionAppManage, PermissionApprove}},
+		PermissionTokenManage, PermissionExec, PermissionAppManage, PermissionApprove}},
 	{Resource: "sync", Permissions: []RBACPermission{
 		PermissionSyncCreate, PermissionSyncRun, PermissionSyncDelete, PermissionSyncRead}},
 	{Resource: "service", Permissions: []RBACPermission{
@@ -1462,7 +2048,7 @@ var RBACPermissionGroups = []RBACPermissionGroup{
 		PermissionBindingRead, PermissionBindingRunCommand, PermissionBindingUse,
 		PermissionBindingManage, PermissionBindingReveal}},
 	{Resource: "container", Permissions: []RBACPermission{
-		PermissionContainerRead, PermissionContainerManage}},
+		PermissionContainerRead, PermissionContainerManage, PermissionContainerDevices}},
 	{Resource: "provider", Permissions: []RBACPermission{
 		PermissionProviderRead, PermissionProviderManage}},
 	{Resource: "config", Permissions: []RBACPermission{
@@ -1498,6 +2084,7 @@ const (
 	CONSTANT_KV_PREFIX          = "constant:"
 	COOKIE_SESSION_SECRET_KV    = "cookie_session_secret"
 	COOKIE_SESSION_BLOCK_KEY_KV = "cookie_session_block_key"
+	KEYRING_KV                  = "keyring"
 	OPENRUN_COOKIE_MARKER       = "_openrun_"
 	GOTHIC_SESSION_COOKIE       = "_gothic_session"
 	OAUTH_SESSION_COOKIE        = "openrun_session"
@@ -0,0 +1,24 @@
+// Copyright (c) ClaceIO, LLC
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"context"
+
+	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/app/appfs"
+	"github.com/openrundev/openrun/internal/types"
+)
+
+// RunAppTests runs the Starlark unit tests (the *_test.star files) for the app source in dir. The
+// tests run in the current process, plugin calls are mocked by the tests so no OpenRun server
+// is required. Tests whose name does not match the filter glob are skipped
+func RunAppTests(ctx context.Context, dir, filter string, params map[string]string) ([]types.AppTestResult, error) {
+	logger := types.NewLogger(&types.LogConfig{Level: "WARN", Console: true})
+	sourceFS, err := appfs.NewSourceFs(dir, appfs.NewDiskReadFS(logger, dir, nil), false)
+	if err != nil {
+		return nil, err
+	}
+	return app.RunUnitTests(ctx, logger, sourceFS, filter, params)
+}
@@ -54,12 +54,13 @@ func (c *containerPlugin) Run(thread *starlark.Thread, builtin *starlark.Builtin
 
 func (c *containerPlugin) Config(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
 	var src, lifetime, scheme, health, buildDir starlark.String
-	var port starlark.Int
-	var cargs, devSettings *starlark.Dict
+	var port, healthInterval, healthRetries starlark.Int
+	var cargs, devSettings, services *starlark.Dict
 	var volumes *starlark.List
 	if err := starlark.UnpackArgs("config", args, kwargs, "src?", &src, "port?", &port, "scheme?", &scheme,
 		"health?", &health, "lifetime?", &lifetime, "build_dir?", &buildDir, "volumes?", &volumes, "cargs", &cargs,
-		"dev_settings?", &devSettings); err != nil {
+		"dev_settings?", &devSettings, "services?", &services, "health_interval?", &healthInterval,
+		"health_retries?", &healthRetries); err != nil {
 		return nil, err
 	}
 
@@ -71,6 +72,13 @@ func (c *containerPlugin) Config(thread *starlark.Thread, builtin *starlark.Buil
 		return nil, fmt.Errorf("port must be an integer higher than or equal to zero")
 	}
 
+	if v, ok := healthInterval.Int64(); !ok || v < 0 {
+		return nil, fmt.Errorf("health_interval must be an integer higher than or equal to zero")
+	}
+	if v, ok := healthRetries.Int64(); !ok || v < 0 {
+		return nil, fmt.Errorf("health_retries must be an integer higher than or equal to zero")
+	}
+
 	if devSettings == nil {
 		devSettings = starlark.NewDict(0)
 	} else {
@@ -79,18 +87,29 @@ func (c *containerPlugin) Config(thread *starlark.Thread, builtin *starlark.Buil
 		}
 	}
 
+	if services == nil {
+		services = starlark.NewDict(0)
+	} else {
+		if err := validateServices(services); err != nil {
+			return nil, err
+		}
+	}
+
 	volumes = cmp.Or(volumes, starlark.NewList([]starlark.Value{}))
 
 	fields := starlark.StringDict{
-		"source":       starlark.String(cmp.Or(string(src), "auto")),
-		"lifetime":     starlark.String(cmp.Or(string(lifetime), "app")),
-		"port":         port,
-		"scheme":       starlark.String(cmp.Or(string(scheme), "http")),
-		"health":       starlark.String(cmp.Or(string(health), "/")),
-		"build_dir":    buildDir,
-		"volumes":      volumes,
-		"cargs":        cargs,
-		"dev_settings": devSettings,
+		"source":          starlark.String(cmp.Or(string(src), "auto")),
+		"lifetime":        starlark.String(cmp.Or(string(lifetime), "app")),
+		"port":            port,
+		"scheme":          starlark.String(cmp.Or(string(scheme), "http")),
+		"health":          starlark.String(cmp.Or(string(health), "/")),
+		"build_dir":       buildDir,
+		"volumes":         volumes,
+		"cargs":           cargs,
+		"dev_settings":    devSettings,
+		"services":        services,
+		"health_interval": healthInterval,
+		"health_retries":  healthRetries,
 	}
 
 	return starlarkstruct.FromStringDict(starlark.String("container_config"), fields), nil
@@ -110,3 +129,29 @@ func validateDevSettings(devSettings *starlark.Dict) error {
 	}
 	return nil
 }
+
+// validateServices checks the services dict at config eval time, each entry maps the service
+// name to a dict with the service config
+func validateServices(services *starlark.Dict) error {
+	for _, item := range services.Items() {
+		name, ok := item[0].(starlark.String)
+		if !ok {
+			return fmt.Errorf("services keys must be strings, got %s", item[0].Type())
+		}
+		config, ok := item[1].(*starlark.Dict)
+		if !ok {
+			return fmt.Errorf("service %s config must be a dict, got %s", string(name), item[1].Type())
+		}
+		for _, k := range config.Keys() {
+			keyStr, ok := k.(starlark.String)
+			if !ok {
+				return fmt.Errorf("service %s keys must be strings, got %s", string(name), k.Type())
+			}
+			if !slices.Contains(types.ContainerServiceKeys, string(keyStr)) {
+				return fmt.Errorf("invalid service %s key %q, allowed keys are %s", string(name), string(keyStr),
+					strings.Join(types.ContainerServiceKeys, ", "))
+			}
+		}
+	}
+	return nil
+}
@@ -43,3 +43,36 @@ func TestValidateDevSettingsRejectsUnknownAndNonStringKeys(t *testing.T) {
 		t.Fatalf("non-string key error = %v", err)
 	}
 }
+
+func TestValidateServices(t *testing.T) {
+	t.Parallel()
+
+	redis := starlark.NewDict(2)
+	for _, key := range []string{"image", "src", "command", "env", "volumes"} {
+		if err := redis.SetKey(starlark.String(key), starlark.None); err != nil {
+			t.Fatalf("SetKey(%q): %v", key, err)
+		}
+	}
+	services := starlark.NewDict(1)
+	if err := services.SetKey(starlark.String("redis"), redis); err != nil {
+		t.Fatalf("SetKey: %v", err)
+	}
+	if err := validateServices(services); err != nil {
+		t.Fatalf("validateServices returned error: %v", err)
+	}
+
+	if err := redis.SetKey(starlark.String("ports"), starlark.None); err != nil {
+		t.Fatalf("SetKey: %v", err)
+	}
+	if err := validateServices(services); err == nil || !strings.Contains(err.Error(), "invalid service redis key") {
+		t.Fatalf("unknown key error = %v", err)
+	}
+
+	notDict := starlark.NewDict(1)
+	if err := notDict.SetKey(starlark.String("worker"), starlark.String("redis:7")); err != nil {
+		t.Fatalf("SetKey: %v", err)
+	}
+	if err := validateServices(notDict); err == nil || !strings.Contains(err.Error(), "must be a dict") {
+		t.Fatalf("non-dict config error = %v", err)
+	}
+}
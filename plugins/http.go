@@ -25,6 +25,7 @@ import (
 	"github.com/openrundev/openrun/internal/app/apptype"
 	"github.com/openrundev/openrun/internal/app/starlark_type"
 	"github.com/openrundev/openrun/internal/plugin"
+	"github.com/openrundev/openrun/internal/telemetry"
 	"github.com/openrundev/openrun/internal/types"
 	"go.starlark.net/starlark"
 	"go.starlark.net/starlarkstruct"
@@ -63,7 +64,12 @@ type httpPlugin struct {
 }
 
 func NewHttpPlugin(pluginContext *types.PluginContext) (any, error) {
-	return &httpPlugin{client: http.DefaultClient}, nil
+	client := http.DefaultClient
+	if telemetry.Enabled() {
+		// Record client spans and propagate the trace context to the called service
+		client = &http.Client{Transport: telemetry.WrapTransport(http.DefaultTransport)}
+	}
+	return &httpPlugin{client: client}, nil
 }
 
 func (h *httpPlugin) Get(thread *starlark.Thread, builtin *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
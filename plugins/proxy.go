@@ -4,7 +4,10 @@
 package plugins
 
 import (
+	"fmt"
+
 	"github.com/openrundev/openrun/internal/app"
+	"github.com/openrundev/openrun/internal/app/apptype"
 	"github.com/openrundev/openrun/internal/plugin"
 	"github.com/openrundev/openrun/internal/types"
 	"go.starlark.net/starlark"
@@ -31,10 +34,15 @@ func (h *proxyPlugin) Config(thread *starlark.Thread, builtin *starlark.Builtin,
 	var preserveHost starlark.Bool
 	var stripApp = starlark.True
 	var responseHeaders = &starlark.Dict{}
+	var upstreamAuth starlark.Value = starlark.None
 	if err := starlark.UnpackArgs("config", args, kwargs, "url", &url, "strip_path?",
-		&stripPath, "preserve_host?", &preserveHost, "strip_app?", &stripApp, "response_headers", &responseHeaders); err != nil {
+		&stripPath, "preserve_host?", &preserveHost, "strip_app?", &stripApp, "response_headers", &responseHeaders,
+		"upstream_auth?", &upstreamAuth); err != nil {
 		return nil, err
 	}
+	if _, ok := upstreamAuth.(*apptype.UpstreamAuth); !ok && upstreamAuth != starlark.None {
+		return nil, fmt.Errorf("config: upstream_auth should be created using ace.basic, ace.bearer or ace.token, got %s", upstreamAuth.Type())
+	}
 
 	fields := starlark.StringDict{
 		"url":              url,
@@ -42,6 +50,7 @@ func (h *proxyPlugin) Config(thread *starlark.Thread, builtin *starlark.Builtin,
 		"preserve_host":    preserveHost,
 		"strip_app":        stripApp,
 		"response_headers": responseHeaders,
+		"upstream_auth":    upstreamAuth,
 	}
 	return starlarkstruct.FromStringDict(starlark.String("ProxyConfig"), fields), nil
 }